@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
@@ -11,11 +12,14 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/ollama"
+	"github.com/tahcohcat/same-same/internal/embedders/openai"
 	"github.com/tahcohcat/same-same/internal/embedders/quotes/gemini"
 	"github.com/tahcohcat/same-same/internal/embedders/quotes/huggingface"
 	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/tfidf"
 	"github.com/tahcohcat/same-same/internal/ingestion"
-	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/tracing"
 )
 
 func main() {
@@ -25,11 +29,20 @@ func main() {
 		batchSize    = flag.Int("batch-size", 100, "Batch size for bulk operations")
 		dryRun       = flag.Bool("dry-run", false, "Don't actually ingest, just validate")
 		verbose      = flag.Bool("verbose", false, "Verbose logging")
-		embedderType = flag.String("embedder", "", "Embedder type (local, gemini, huggingface) - defaults to env EMBEDDER_TYPE or 'local'")
+		embedderType = flag.String("embedder", "", "Embedder type (local, gemini, huggingface, openai, ollama) - defaults to env EMBEDDER_TYPE or 'local'")
 		textCol      = flag.String("text-col", "text", "Column name for text (CSV only)")
+		delimiter    = flag.String("delimiter", "auto", "Field delimiter: auto, tab or a single character (CSV only)")
+		textField    = flag.String("text-field", "", "Dot-path of the text, e.g. payload.body.text or items.0.text (JSON and HuggingFace only)")
+		flatten      = flag.Bool("flatten-metadata", false, "Store nested JSON objects and arrays as dotted metadata keys")
+		flattenDepth = flag.Int("flatten-depth", 0, "Levels of nesting to flatten, deeper values are kept as JSON text (0 = all)")
+		flattenSkip  = flag.String("flatten-exclude", "", "Comma separated dot-paths left out of JSON metadata")
 		split        = flag.String("split", "train", "Dataset split (HuggingFace only)")
 		timeout      = flag.Duration("timeout", 30*time.Minute, "Timeout for ingestion")
-		output = flag.String("output", "", "Output file for exported vectors (optional)")
+		output = flag.String("output", "", "Output file for exported vectors, a JSON array when it ends in .json and JSON lines otherwise (optional)")
+		noEmbeddings = flag.Bool("no-embeddings", false, "Leave the embeddings out of -output")
+		storageType  = flag.String("storage", "", "Storage to ingest into (memory, local, tiered) - defaults to env STORAGE_TYPE or 'memory'")
+		embedRetries = flag.Int("embed-retries", embedders.DefaultRetryPolicy.MaxAttempts-1, "Times gemini and huggingface retry a request answered with 429 or a 5xx status (0 disables)")
+		storagePath  = flag.String("storage-path", "", "Directory of local and tiered storage - defaults to env LOCAL_STORAGE_PATH or './data/storage'")
 	)
 	
 	flag.Usage = func() {
@@ -42,7 +55,8 @@ Sources:
   hf:<dataset>                  HuggingFace dataset (e.g., hf:imdb, hf:squad:v2)
   file.csv                      CSV file (requires -text-col flag)
   file.jsonl                    JSONL file (each line is a JSON object with "text" field)
-  file.json                     Same as JSONL
+  file.json                     JSON array of objects with "text" field, or JSONL
+  file.csv.gz, file.jsonl.gz    Gzip compressed files of any of these formats
 
 Examples:
   # Ingest built-in demo dataset
@@ -60,14 +74,20 @@ Examples:
   # Ingest from JSONL file
   %s data.jsonl
 
+  # Ingest nested JSONL records with dotted metadata keys
+  %s -text-field payload.body.text -flatten-metadata events.jsonl
+
   # Dry run to validate data
   %s -dry-run -verbose data.jsonl
 
   # Use specific embedder
   %s -embedder gemini demo
 
+  # Persist vectors in local file storage, where serve finds them with STORAGE_TYPE=local
+  %s -storage local -storage-path ./data/storage demo
+
 Flags:
-`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 		flag.PrintDefaults()
 	}
 	
@@ -91,10 +111,21 @@ Flags:
 		BatchSize: *batchSize,
 		DryRun:    *dryRun,
 		Verbose:   *verbose,
+		
+		FlattenMetadata: *flatten,
+		FlattenDepth:    *flattenDepth,
+	}
+	if *flattenSkip != "" {
+		config.FlattenExclude = strings.Split(*flattenSkip, ",")
+	}
+	
+	csvDelimiter, err := ingestion.ParseDelimiter(*delimiter)
+	if err != nil {
+		log.Fatal(err)
 	}
 	
 	// Create source
-	source, err := createSource(sourceArg, config, *textCol, *split)
+	source, err := createSource(sourceArg, config, *textCol, csvDelimiter, *textField, *split)
 	if err != nil {
 		log.Fatalf("Failed to create source: %v", err)
 	}
@@ -104,12 +135,36 @@ Flags:
 	if err != nil {
 		log.Fatalf("Failed to create embedder: %v", err)
 	}
+	if retrier, ok := embedder.(embedders.Retrier); ok {
+		if *embedRetries < 0 {
+			log.Fatalf("Invalid -embed-retries %d: must not be negative", *embedRetries)
+		}
+		policy := embedders.DefaultRetryPolicy
+		policy.MaxAttempts = *embedRetries + 1
+		retrier.SetRetryPolicy(policy)
+	}
 	
-	// Create storage
-	storage := memory.NewStorage()
+	// Create storage, the flags overriding the environment serve reads
+	switch *storageType {
+	case "":
+	case "memory", "local", "tiered":
+		os.Setenv("STORAGE_TYPE", *storageType)
+	default:
+		log.Fatalf("Unknown storage type: %s (supported: memory, local, tiered)", *storageType)
+	}
+	if *storagePath != "" {
+		os.Setenv("LOCAL_STORAGE_PATH", *storagePath)
+	}
+	store, err := storage.NewStorageFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
 	
 	// Create ingestor
-	ingestor := ingestion.NewIngestor(source, embedder, storage, config)
+	ingestor := ingestion.NewIngestor(source, embedder, store, config)
 	
 	// Run ingestion
 	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
@@ -120,7 +175,16 @@ Flags:
 		fmt.Println("DRY RUN MODE - no data will be stored")
 	}
 	
+	// Traces are exported when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Init(ctx, "same-same-ingest")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	
 	stats, err := ingestor.Run(ctx)
+	if terr := shutdownTracing(context.Background()); terr != nil {
+		log.Printf("Failed to flush traces: %v", terr)
+	}
 	if err != nil {
 		log.Fatalf("Ingestion failed: %v", err)
 	}
@@ -130,19 +194,23 @@ Flags:
 	
 	// Export if requested
 	if *output != "" && !*dryRun {
-		if err := exportVectors(storage, *output); err != nil {
+		count, err := exportVectors(store, *output, stats.RunID, *noEmbeddings)
+		if err != nil {
 			log.Fatalf("Failed to export vectors: %v", err)
 		}
-		fmt.Printf("Vectors exported to: %s\n", *output)
+		fmt.Printf("%d vectors exported to: %s\n", count, *output)
 	}
 }
 
-func createSource(sourceArg string, config *ingestion.SourceConfig, textCol, split string) (ingestion.Source, error) {
+func createSource(sourceArg string, config *ingestion.SourceConfig, textCol string, delimiter rune, textField string, split string) (ingestion.Source, error) {
 	// Check for HuggingFace dataset
 	if strings.HasPrefix(sourceArg, "hf:") {
 		dataset := strings.TrimPrefix(sourceArg, "hf:")
 		source := ingestion.NewHuggingFaceSource(dataset, config)
 		source.SetSplit(split)
+		if textField != "" {
+			source.SetTextField(textField)
+		}
 		return source, nil
 	}
 	
@@ -164,10 +232,10 @@ func createSource(sourceArg string, config *ingestion.SourceConfig, textCol, spl
 			return nil, err
 		}
 		
-		// Set text column for CSV files
-		if strings.HasSuffix(strings.ToLower(sourceArg), ".csv") {
-			source.SetTextColumn(textCol)
-		}
+		// Text column and delimiter only apply to CSV files, text field to JSON
+		source.SetTextColumn(textCol)
+		source.SetDelimiter(delimiter)
+		source.SetTextField(textField)
 		
 		return source, nil
 	}
@@ -186,7 +254,7 @@ func createEmbedder(embedderType string) (embedders.Embedder, error) {
 	
 	switch strings.ToLower(embedderType) {
 	case "local":
-		return tfidf.NewTFIDFEmbedder(), nil
+		return tfidf.NewTFIDFEmbedderFromEnv()
 		
 	case "gemini":
 		apiKey := os.Getenv("GEMINI_API_KEY")
@@ -202,13 +270,25 @@ func createEmbedder(embedderType string) (embedders.Embedder, error) {
 		}
 		return huggingface.NewHuggingFaceEmbedder(apiKey), nil
 		
+	case "openai":
+		opts, err := openai.OptionsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if opts.APIKey == "" {
+			return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+		}
+		return openai.NewOpenAIEmbedder(opts), nil
+		
+	case "ollama":
+		return ollama.NewOllamaEmbedder(ollama.OptionsFromEnv()), nil
+		
 	default:
-		return nil, fmt.Errorf("unknown embedder type: %s (supported: local, gemini, huggingface)", embedderType)
+		return nil, fmt.Errorf("unknown embedder type: %s (supported: local, gemini, huggingface, openai, ollama)", embedderType)
 	}
 }
 
-func exportVectors(storage *memory.Storage, filename string) error {
-	// This is a placeholder - implement based on your export needs
-	fmt.Printf("Export functionality not yet implemented\n")
-	return nil
+// exportVectors writes the vectors stored by the run to filename
+func exportVectors(store storage.Storage, filename string, runID string, noEmbeddings bool) (int, error) {
+	return ingestion.ExportRun(store, filename, ingestion.ExportOptions{RunID: runID, NoEmbeddings: noEmbeddings})
 }
@@ -62,10 +62,14 @@ func main() {
 		}
 
 		inputFile := *sourcePath + "/" + *collection + ".json"
-		if err := localStorage.Import(*collection, inputFile); err != nil {
+		report, err := localStorage.Import(*collection, inputFile)
+		if err != nil {
 			log.Fatalf("Import failed: %v", err)
 		}
-		log.Printf("Collection imported from %s\n", inputFile)
+		for _, docErr := range report.Errors {
+			log.Printf("Skipped document %q (line %d): %s\n", docErr.ID, docErr.Line, docErr.Error)
+		}
+		log.Printf("Collection imported from %s: %d documents, %d failed\n", inputFile, report.Imported, report.Failed)
 
 	default:
 		log.Fatalf("Unknown mode: %s", *mode)
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/bench"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+var (
+	// Bench flags
+	benchVectors     int
+	benchDimension   int
+	benchClusters    int
+	benchSpread      float64
+	benchConcurrency int
+	benchDuration    time.Duration
+	benchMix         string
+	benchTopK        int
+	benchBatchSize   int
+	benchSeed        int64
+	benchFormat      string
+)
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	benchCmd.Flags().IntVar(&benchVectors, "vectors", 10000, "Number of synthetic vectors loaded before the run")
+	benchCmd.Flags().IntVar(&benchDimension, "dimension", 384, "Dimension of the synthetic vectors")
+	benchCmd.Flags().IntVar(&benchClusters, "clusters", 32, "Number of Gaussian blobs the vectors are drawn from")
+	benchCmd.Flags().Float64Var(&benchSpread, "spread", 0.05, "Standard deviation of each component around its blob center")
+	benchCmd.Flags().IntVarP(&benchConcurrency, "concurrency", "c", 8, "Number of concurrent workers")
+	benchCmd.Flags().DurationVarP(&benchDuration, "duration", "d", 30*time.Second, "How long the operations run")
+	benchCmd.Flags().StringVar(&benchMix, "mix", "search=8,store=1,get=1", "Weights of the search, store and get operations")
+	benchCmd.Flags().IntVar(&benchTopK, "top-k", 10, "Results per search")
+	benchCmd.Flags().IntVar(&benchBatchSize, "batch-size", 1000, "Vectors per batch while loading")
+	benchCmd.Flags().Int64Var(&benchSeed, "seed", 1, "Seed of the synthetic data")
+	benchCmd.Flags().StringVar(&benchFormat, "format", "text", "Report format, text or json")
+	addTargetFlags(benchCmd)
+}
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Load test a store with synthetic vectors and queries",
+	Long: `Generate synthetic vectors drawn from clustered Gaussian blobs, load them into
+a store, then run a weighted mix of search, store and get operations with
+concurrent workers for a fixed duration.
+
+The report gives the throughput and latency percentiles of each operation and,
+for in-process stores, the heap used. Without --local or --server the vectors are
+loaded into an in-process memory store. With --local they go to a local file
+storage collection, which the run adds to: point it at a scratch directory.`,
+	Example: `  # In-process memory store, 100k vectors of dimension 768
+  same-same bench --vectors 100000 --dimension 768 --duration 1m
+
+  # A running server, searches only, as JSON
+  same-same bench --server http://localhost:8080 --mix search=1 -c 32 --format json
+
+  # Local file storage
+  same-same bench --local /tmp/bench-storage --vectors 20000`,
+	Args: cobra.NoArgs,
+	Run:  runBench,
+}
+
+func runBench(cmd *cobra.Command, args []string) {
+	if benchFormat != "text" && benchFormat != "json" {
+		log.Fatalf("unknown format %q (supported: text, json)", benchFormat)
+	}
+	mix, err := bench.ParseMix(benchMix)
+	if err != nil {
+		log.Fatalf("Invalid --mix: %v", err)
+	}
+	gen, err := bench.NewGenerator(benchDimension, benchClusters, benchSpread, benchSeed)
+	if err != nil {
+		log.Fatalf("Invalid synthetic data: %v", err)
+	}
+	gen.Namespace = namespace
+
+	var target bench.Target
+	switch {
+	case serverURL != "" && localPath != "":
+		log.Fatal("--server and --local are mutually exclusive")
+	case serverURL != "":
+		target = bench.NewHTTPTarget(serverURL, benchConcurrency)
+	case localPath != "":
+		adapter, err := local.NewVectorStorageAdapter(localPath, localCollection)
+		if err != nil {
+			log.Fatalf("Failed to open local storage: %v", err)
+		}
+		defer adapter.Close()
+		target = &bench.StorageTarget{Storage: adapter}
+	default:
+		target = &bench.StorageTarget{Storage: memory.NewStorage()}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if benchFormat == "text" {
+		fmt.Fprintf(os.Stderr, "Loading %d vectors, then running %s for %s...\n", benchVectors, benchMix, benchDuration)
+	}
+	report, err := bench.Run(ctx, target, gen, bench.Config{
+		Vectors:     benchVectors,
+		BatchSize:   benchBatchSize,
+		Concurrency: benchConcurrency,
+		Duration:    benchDuration,
+		Mix:         mix,
+		TopK:        benchTopK,
+		Seed:        benchSeed,
+	})
+	if err != nil {
+		log.Fatalf("Benchmark failed: %v", err)
+	}
+
+	if benchFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		return
+	}
+	if err := report.WriteText(os.Stdout); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/analysis"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/snapshot"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+func TestDiffSources_PlantedDifferences(t *testing.T) {
+	vector := func(id, ns, genre string, embedding ...float64) *models.Vector {
+		return &models.Vector{ID: id, Embedding: embedding, Metadata: map[string]string{models.NamespaceKey: ns, "genre": genre}}
+	}
+
+	// A is a local store, as after a restore
+	dir := t.TempDir()
+	adapter, err := local.NewVectorStorageAdapter(dir, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.StoreAll([]*models.Vector{
+		vector("same", "books", "fiction", 1, 0),
+		vector("rounded", "books", "fiction", 0.5, 0.5),
+		vector("retagged", "books", "fiction", 0, 1),
+		vector("moved", "books", "poetry", 1, 1),
+		vector("restored-only", "books", "fiction", 1, 0),
+		vector("film", "films", "drama", 1, 0),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	adapter.Close()
+
+	// B is a snapshot, as exported from production
+	retagged := vector("retagged", "books", "history", 0, 1)
+	retagged.Metadata["year"] = "1999"
+	snap, err := snapshot.New("", []*models.Vector{
+		vector("prod-only", "books", "fiction", 1, 0),
+		retagged,
+		vector("moved", "books", "poetry", 1, 0.5),
+		vector("rounded", "books", "fiction", 0.5, 0.5+1e-9),
+		vector("same", "books", "fiction", 1, 0),
+		vector("film", "films", "comedy", 1, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "prod.snapshot")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := snap.Write(file); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	var diffs []*analysis.VectorDiff
+	summary, err := diffSources(dir, path, "books", analysis.DefaultDiffTolerance, func(d *analysis.VectorDiff) error {
+		diffs = append(diffs, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("diffSources() error = %v", err)
+	}
+
+	want := analysis.DiffSummary{A: 5, B: 5, OnlyInA: 1, OnlyInB: 1, MetadataDiffers: 1, EmbeddingDiffers: 1, Identical: 2}
+	if *summary != want {
+		t.Errorf("summary = %+v, want %+v", *summary, want)
+	}
+
+	// Differences come in ID order, with the metadata fields that changed
+	kinds := make([]string, len(diffs))
+	for i, d := range diffs {
+		kinds[i] = d.ID + ":" + d.Kind
+	}
+	wantKinds := []string{"moved:different", "prod-only:only_in_b", "restored-only:only_in_a", "retagged:different"}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Fatalf("diffs = %v, want %v", kinds, wantKinds)
+	}
+	if moved := diffs[0]; !moved.Embedding || moved.MaxDelta != 0.5 || moved.Metadata != nil {
+		t.Errorf("moved = %+v, want an embedding difference of 0.5", moved)
+	}
+	genre, year := diffs[3].Metadata["genre"], diffs[3].Metadata["year"]
+	if diffs[3].Embedding || *genre.A != "fiction" || *genre.B != "history" || year.A != nil || *year.B != "1999" {
+		t.Errorf("retagged = %+v", diffs[3])
+	}
+
+	// Without a namespace every vector is compared
+	summary, err = diffSources(dir, path, "", analysis.DefaultDiffTolerance, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.A != 6 || summary.MetadataDiffers != 2 {
+		t.Errorf("unscoped summary = %+v, want the film counted", summary)
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/doctor"
+)
+
+var (
+	doctorAddr   string
+	doctorSource string
+	doctorJSON   bool
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVarP(&embedderType, "embedder", "e", "", "Embedder type to check (default $EMBEDDER_TYPE or local)")
+	doctorCmd.Flags().StringVar(&localPath, "local", "", "Path of a local file storage directory (default $LOCAL_STORAGE_PATH when STORAGE_TYPE=local)")
+	doctorCmd.Flags().StringVar(&localCollection, "collection", "", "Collection name (default $STORAGE_COLLECTION or default)")
+	doctorCmd.Flags().StringVarP(&doctorAddr, "addr", "a", ":8080", "Address serve will listen on")
+	doctorCmd.Flags().StringVar(&doctorSource, "source", "", "Ingest source to check requirements for (e.g. hf:imdb)")
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "Print the results as JSON")
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose configuration problems",
+	Long: `Run a series of checks against the configuration the server and CLI would use
+and report pass, warn, fail or skip for each:
+
+  environment  Environment variables and how they resolve
+  storage      Local storage path exists, is writable and its index is readable
+  embedder     A test embed with the configured embedder, with its latency
+  dimension    The embedder dimension matches the collection and a sample of stored vectors
+  python       Python is on the PATH when Python CLIP or a HuggingFace source needs it
+  port         The serve address is free
+
+The command exits with status 1 when any check fails.`,
+	Example: `  # Check the configuration from the environment and .env
+  same-same doctor
+
+  # Check a local store against the Gemini embedder
+  same-same doctor --local ./data/storage -e gemini
+
+  # Machine-readable output for CI
+  same-same doctor --json`,
+	Args: cobra.NoArgs,
+	Run:  runDoctor,
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	_ = godotenv.Load() // load .env if present, as serve does
+
+	cfg := doctorConfig()
+	report := doctor.Run(cfg)
+
+	if doctorJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	} else {
+		for _, result := range report.Results {
+			fmt.Printf("[%-4s] %-12s %s\n", strings.ToUpper(string(result.Status)), result.Name, result.Detail)
+		}
+	}
+
+	if report.Failed {
+		os.Exit(1)
+	}
+}
+
+// doctorConfig resolves the configuration from flags and environment the way serve and ingest do
+func doctorConfig() *doctor.Config {
+	cfg := &doctor.Config{
+		EmbedderType: strings.ToLower(embedderType),
+		StorageType:  "memory",
+		StoragePath:  localPath,
+		Collection:   localCollection,
+		Source:       doctorSource,
+		Addr:         doctorAddr,
+	}
+
+	if cfg.EmbedderType == "" {
+		cfg.EmbedderType = strings.ToLower(os.Getenv("EMBEDDER_TYPE"))
+	}
+	if cfg.EmbedderType == "" {
+		cfg.EmbedderType = "local"
+	}
+	cfg.Embedder, cfg.EmbedderErr = createEmbedder(cfg.EmbedderType)
+
+	// Tiered storage keeps every vector in local storage, which is what is checked
+	if storageType := os.Getenv("STORAGE_TYPE"); localPath != "" || storageType == "local" || storageType == "tiered" {
+		cfg.StorageType = "local"
+	}
+	if cfg.StoragePath == "" {
+		cfg.StoragePath = os.Getenv("LOCAL_STORAGE_PATH")
+	}
+	if cfg.StoragePath == "" {
+		cfg.StoragePath = "./data/storage"
+	}
+	if cfg.Collection == "" {
+		cfg.Collection = os.Getenv("STORAGE_COLLECTION")
+	}
+	if cfg.Collection == "" {
+		cfg.Collection = "default"
+	}
+
+	return cfg
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/handlers"
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+// Eval flags
+var (
+	evalJSON bool
+)
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+	evalCmd.AddCommand(evalRunCmd)
+
+	evalRunCmd.Flags().BoolVar(&evalJSON, "json", false, "Print the run as JSON")
+	evalRunCmd.Flags().StringVarP(&embedderType, "embedder", "e", "", "Embedder type with --local, must match the one used at ingestion (local, hash, gemini, huggingface, openai, ollama, clip)")
+	addTargetFlags(evalRunCmd)
+}
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Score retrieval quality against labeled evaluation sets",
+	Long: `Evaluation sets are labeled queries with the IDs of the vectors relevant to
+them, created with POST /api/v1/eval/sets. Running a set searches every query
+and computes recall, precision, MRR and nDCG at the set cutoff. Runs are
+recorded, so regressions show up in GET /api/v1/eval/sets/{name}/runs.`,
+}
+
+var evalRunCmd = &cobra.Command{
+	Use:   "run <set>",
+	Short: "Run an evaluation set and record the result",
+	Long: `Run an evaluation set through the search pipeline and record the run.
+
+With --server the set runs on the running server. With --local it runs
+against a local collection, embedding the queries with --embedder.`,
+	Example: `  # Score a set on a running server
+  same-same eval run faq-regressions --server http://localhost:8080
+
+  # Score a set of a local collection
+  same-same eval run faq-regressions --local ./data/storage -e local`,
+	Args: cobra.ExactArgs(1),
+	Run:  runEvalRun,
+}
+
+func runEvalRun(cmd *cobra.Command, args []string) {
+	name := args[0]
+	var run *eval.Run
+
+	switch {
+	case serverURL != "" && localPath != "":
+		log.Fatal("--server and --local are mutually exclusive")
+
+	case serverURL != "":
+		body, err := adminRequest(http.MethodPost, "/api/v1/eval/sets/"+url.PathEscape(name)+"/run", nil, nil)
+		if err != nil {
+			log.Fatalf("Evaluation failed: %v", err)
+		}
+		defer body.Close()
+
+		run = &eval.Run{}
+		if err := json.NewDecoder(body).Decode(run); err != nil {
+			log.Fatalf("Failed to decode server response: %v", err)
+		}
+
+	case localPath != "":
+		embedder, err := createEmbedder(embedderType)
+		if err != nil {
+			log.Fatalf("Failed to create embedder: %v", err)
+		}
+
+		adapter, err := local.NewVectorStorageAdapter(localPath, localCollection)
+		if err != nil {
+			log.Fatalf("Failed to open local storage: %v", err)
+		}
+		defer adapter.Close()
+
+		run, err = handlers.NewVectorHandler(adapter, embedder).RunEval(name, eval.TriggerCLI)
+		if err != nil {
+			log.Fatalf("Evaluation failed: %v", err)
+		}
+
+	default:
+		log.Fatal("either --server or --local is required")
+	}
+
+	if evalJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(run)
+		return
+	}
+	printEvalRun(run)
+}
+
+func printEvalRun(run *eval.Run) {
+	fmt.Printf("Evaluation set %s, %d queries at k=%d (%s)\n\n",
+		run.Set, len(run.Queries), run.K, run.EndTime.Sub(run.StartTime).Round(time.Millisecond))
+
+	if verbose {
+		for _, q := range run.Queries {
+			fmt.Printf("  %-40.40s  recall %.3f  precision %.3f  mrr %.3f  ndcg %.3f\n",
+				q.Query, q.Recall, q.Precision, q.MRR, q.NDCG)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("  recall@%d     %.3f\n", run.K, run.Metrics.Recall)
+	fmt.Printf("  precision@%d  %.3f\n", run.K, run.Metrics.Precision)
+	fmt.Printf("  mrr          %.3f\n", run.Metrics.MRR)
+	fmt.Printf("  ndcg@%d       %.3f\n", run.K, run.Metrics.NDCG)
+}
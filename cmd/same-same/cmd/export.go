@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/snapshot"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+var (
+	// Export/import flags
+	snapshotOut     string
+	serverURL       string
+	localPath       string
+	localCollection string
+	apiKey          string
+	manifestPath    string
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVar(&snapshotOut, "out", "", "Snapshot file to write (default <namespace>.snapshot)")
+	exportCmd.Flags().StringVar(&manifestPath, "manifest", "", "Integrity manifest to write alongside the snapshot, signed with $MANIFEST_HMAC_KEY when set")
+	addTargetFlags(exportCmd)
+}
+
+// addTargetFlags registers the flags selecting a server or local store
+func addTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&serverURL, "server", "", "Base URL of a running same-same server (e.g. http://localhost:8080)")
+	cmd.Flags().StringVar(&localPath, "local", "", "Path of a local file storage directory")
+	cmd.Flags().StringVar(&localCollection, "collection", "default", "Collection name (with --local)")
+	cmd.Flags().StringVar(&apiKey, "api-key", "", "Admin API key (with --server, default $ADMIN_API_KEY)")
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a namespace to a snapshot file",
+	Long: `Export the vectors of a single namespace to a self-describing snapshot file.
+
+The snapshot records the embedder and dimension the vectors were produced with,
+the vector count and a checksum, so it can be validated before it is imported
+into another instance with 'same-same import'.
+
+With --manifest an integrity manifest is written as the snapshot streams by: the
+content hash of every vector, a Merkle root over them and the embedder
+provenance, signed with an HMAC when MANIFEST_HMAC_KEY is set.`,
+	Example: `  # Export a namespace from a running server
+  same-same export --namespace support-tickets --out tickets.snapshot --server http://staging:8080
+
+  # Export a namespace from local file storage
+  same-same export -n support-tickets --local ./data/storage
+
+  # Export with a signed integrity manifest
+  MANIFEST_HMAC_KEY=... same-same export -n support-tickets --local ./data/storage --manifest tickets.manifest`,
+	Args: cobra.NoArgs,
+	Run:  runExport,
+}
+
+func runExport(cmd *cobra.Command, args []string) {
+	if snapshotOut == "" {
+		snapshotOut = namespace + ".snapshot"
+	}
+
+	var data io.ReadCloser
+	switch {
+	case serverURL != "" && localPath != "":
+		log.Fatal("--server and --local are mutually exclusive")
+
+	case serverURL != "":
+		body, err := adminRequest(http.MethodGet, "/api/v1/admin/snapshot", url.Values{"namespace": {namespace}}, nil)
+		if err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		data = body
+
+	case localPath != "":
+		adapter, err := local.NewVectorStorageAdapter(localPath, localCollection)
+		if err != nil {
+			log.Fatalf("Failed to open local storage: %v", err)
+		}
+		defer adapter.Close()
+
+		vectors, err := adapter.ListByNamespace(namespace)
+		if err != nil {
+			log.Fatalf("Failed to list vectors: %v", err)
+		}
+		snap, err := snapshot.New(namespace, vectors)
+		if err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+
+		reader, writer := io.Pipe()
+		go func() {
+			writer.CloseWithError(snap.Write(writer))
+		}()
+		data = reader
+
+	default:
+		log.Fatal("either --server or --local is required")
+	}
+	defer data.Close()
+
+	file, err := os.Create(snapshotOut)
+	if err != nil {
+		log.Fatalf("Failed to create snapshot file: %v", err)
+	}
+	defer file.Close()
+
+	var out io.Writer = file
+	var manifest *snapshot.ManifestWriter
+	if manifestPath != "" {
+		manifestFile, err := os.Create(manifestPath)
+		if err != nil {
+			log.Fatalf("Failed to create manifest file: %v", err)
+		}
+		defer manifestFile.Close()
+		manifest = snapshot.NewManifestWriter(manifestFile, manifestKey())
+		out = io.MultiWriter(file, manifest)
+	}
+
+	if _, err := io.Copy(out, data); err != nil {
+		log.Fatalf("Failed to write snapshot: %v", err)
+	}
+	if manifest != nil {
+		if err := manifest.Close(); err != nil {
+			log.Fatalf("Failed to write manifest: %v", err)
+		}
+	}
+
+	fmt.Printf("Namespace %q exported to: %s\n", namespace, snapshotOut)
+	if manifest != nil {
+		fmt.Printf("Integrity manifest written to: %s\n", manifestPath)
+	}
+}
+
+// manifestKey returns the key signing integrity manifests, none when unset
+func manifestKey() []byte {
+	return []byte(os.Getenv("MANIFEST_HMAC_KEY"))
+}
+
+// adminRequest calls an admin endpoint of the server selected with --server
+func adminRequest(method, path string, query url.Values, body io.Reader) (io.ReadCloser, error) {
+	return adminRequestTo(serverURL, method, path, query, body)
+}
+
+// adminRequestTo calls an admin endpoint of the server at base
+func adminRequestTo(base, method, path string, query url.Values, body io.Reader) (io.ReadCloser, error) {
+	endpoint := strings.TrimSuffix(base, "/") + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	key := apiKey
+	if key == "" {
+		key = os.Getenv("ADMIN_API_KEY")
+	}
+	req.Header.Set("X-API-Key", key)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return resp.Body, nil
+}
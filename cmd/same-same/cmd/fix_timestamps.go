@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+var (
+	// Fix-timestamps flags
+	timestampField     string
+	overwriteTimestamp bool
+)
+
+func init() {
+	rootCmd.AddCommand(fixTimestampsCmd)
+
+	fixTimestampsCmd.Flags().StringVar(&timestampField, "from-metadata", "", "Metadata field holding the document time (required)")
+	fixTimestampsCmd.Flags().BoolVar(&overwriteTimestamp, "overwrite", false, "Also replace creation times that are already set")
+	fixTimestampsCmd.Flags().StringVar(&localPath, "local", "", "Path of a local file storage directory (required)")
+	fixTimestampsCmd.Flags().StringVar(&localCollection, "collection", "default", "Collection name")
+	fixTimestampsCmd.MarkFlagRequired("from-metadata")
+	fixTimestampsCmd.MarkFlagRequired("local")
+}
+
+var fixTimestampsCmd = &cobra.Command{
+	Use:   "fix-timestamps",
+	Short: "Backfill vector creation times from a metadata field",
+	Long: `Set the creation time of vectors from a time metadata field.
+
+Vectors imported from older exports may have no creation time, so temporal
+search cannot compute their age. This command parses the given metadata field
+(RFC3339 or YYYY-MM-DD) and stores it as the creation time of every vector that
+has none, or of every vector with --overwrite.`,
+	Example: `  # Backfill creation times from the published_at field
+  same-same fix-timestamps --from-metadata published_at --local ./data/storage
+
+  # Show how many vectors would be changed
+  same-same fix-timestamps --dry-run --from-metadata published_at --local ./data/storage --overwrite`,
+	Args: cobra.NoArgs,
+	Run:  runFixTimestamps,
+}
+
+func runFixTimestamps(cmd *cobra.Command, args []string) {
+	adapter, err := local.NewVectorStorageAdapter(localPath, localCollection)
+	if err != nil {
+		log.Fatalf("Failed to open local storage: %v", err)
+	}
+	defer adapter.Close()
+
+	report, err := storage.FixTimestamps(adapter, timestampField, overwriteTimestamp, dryRun)
+	if err != nil {
+		log.Fatalf("Fixing timestamps failed: %v", err)
+	}
+
+	if report.DryRun {
+		fmt.Println("DRY RUN MODE - nothing was changed")
+	}
+	fmt.Printf("Fixed %d vectors from %q, left %d untouched\n", report.Fixed, report.Field, report.Untouched())
+	fmt.Printf("  already set: %d\n", report.AlreadySet)
+	fmt.Printf("  missing %s: %d\n", report.Field, report.Missing)
+	fmt.Printf("  unparsable %s: %d\n", report.Field, report.Unparsable)
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/snapshot"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+var (
+	renameNamespace string
+	onMismatch      string
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVar(&renameNamespace, "rename-namespace", "", "Import the vectors into this namespace instead")
+	importCmd.Flags().StringVar(&manifestPath, "manifest", "", "Integrity manifest to verify the snapshot against, its signature checked with $MANIFEST_HMAC_KEY when set")
+	importCmd.Flags().StringVar(&onMismatch, "on-mismatch", "refuse", "What to do when the snapshot does not match its manifest: refuse or warn")
+	addTargetFlags(importCmd)
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <snapshot>",
+	Short: "Import a snapshot file into a server or local storage",
+	Long: `Import a snapshot produced by 'same-same export'.
+
+The snapshot checksum is verified before anything is stored, and its embedder
+and dimension are checked against the vectors already in the target.
+
+With --manifest every vector is also checked against the integrity manifest
+written by 'same-same export --manifest', and the records that differ are listed.
+The import is refused on a mismatch unless --on-mismatch=warn. With
+MANIFEST_HMAC_KEY set the manifest must carry a valid signature under it.`,
+	Example: `  # Import into a running server
+  same-same import tickets.snapshot --server http://prod:8080
+
+  # Import into local file storage under another namespace
+  same-same import tickets.snapshot --local ./data/storage --rename-namespace tickets-copy
+
+  # Verify the snapshot against its signed manifest first
+  MANIFEST_HMAC_KEY=... same-same import tickets.snapshot --manifest tickets.manifest --server http://prod:8080
+
+  # Only validate the snapshot
+  same-same import --dry-run tickets.snapshot --local ./data/storage`,
+	Args: cobra.ExactArgs(1),
+	Run:  runImport,
+}
+
+func runImport(cmd *cobra.Command, args []string) {
+	file, err := os.Open(args[0])
+	if err != nil {
+		log.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer file.Close()
+
+	// Always verify locally so corrupt files are never sent anywhere
+	snap, err := readSnapshot(file)
+	if err != nil {
+		log.Fatalf("Invalid snapshot: %v", err)
+	}
+
+	if verbose {
+		fmt.Printf("Snapshot: namespace=%q embedder=%s dimension=%d vectors=%d\n",
+			snap.Header.Namespace, snap.Header.Embedder, snap.Header.Dimension, snap.Header.Count)
+	}
+
+	switch {
+	case serverURL != "" && localPath != "":
+		log.Fatal("--server and --local are mutually exclusive")
+
+	case serverURL != "":
+		if dryRun {
+			fmt.Println("DRY RUN MODE - snapshot is valid, nothing was imported")
+			return
+		}
+
+		if _, err := file.Seek(0, 0); err != nil {
+			log.Fatalf("Failed to rewind snapshot: %v", err)
+		}
+
+		query := url.Values{}
+		if renameNamespace != "" {
+			query.Set("rename_namespace", renameNamespace)
+		}
+
+		body, err := adminRequest(http.MethodPost, "/api/v1/admin/restore", query, file)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		defer body.Close()
+
+		var result map[string]interface{}
+		if err := json.NewDecoder(body).Decode(&result); err != nil {
+			log.Fatalf("Failed to decode server response: %v", err)
+		}
+		fmt.Printf("Imported %v vectors into namespace %q\n", result["restored"], result["namespace"])
+
+	case localPath != "":
+		adapter, err := local.NewVectorStorageAdapter(localPath, localCollection)
+		if err != nil {
+			log.Fatalf("Failed to open local storage: %v", err)
+		}
+		defer adapter.Close()
+
+		if renameNamespace != "" {
+			snap.Rename(renameNamespace)
+		}
+
+		if err := snap.CheckCompatible(adapter, ""); err != nil {
+			log.Fatalf("Snapshot is not compatible with target: %v", err)
+		}
+
+		if dryRun {
+			fmt.Println("DRY RUN MODE - snapshot is valid, nothing was imported")
+			return
+		}
+
+		if err := snap.Restore(adapter); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		fmt.Printf("Imported %d vectors into namespace %q\n", len(snap.Vectors), snap.Header.Namespace)
+
+	default:
+		log.Fatal("either --server or --local is required")
+	}
+}
+
+// readSnapshot reads and verifies a snapshot, against the manifest given with
+// --manifest when set
+func readSnapshot(file *os.File) (*snapshot.Snapshot, error) {
+	if manifestPath == "" {
+		return snapshot.Read(file)
+	}
+	if onMismatch != "refuse" && onMismatch != "warn" {
+		return nil, fmt.Errorf("invalid --on-mismatch %q (must be: refuse, warn)", onMismatch)
+	}
+
+	manifestFile, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer manifestFile.Close()
+
+	manifest, err := snapshot.ReadManifest(manifestFile, manifestKey())
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Signed && !manifest.Verified {
+		fmt.Println("Warning: the manifest is signed but MANIFEST_HMAC_KEY is not set, its signature was not verified")
+	}
+
+	snap, verification, err := snapshot.ReadVerified(file, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if verification.OK() {
+		if verbose {
+			fmt.Printf("Manifest: %d records verified, merkle root %s\n", verification.Records, manifest.MerkleRoot)
+		}
+		return snap, nil
+	}
+
+	for _, group := range []struct {
+		label string
+		ids   []string
+	}{{"differs from the manifest", verification.Mismatched}, {"missing from the snapshot", verification.Missing}, {"not in the manifest", verification.Unexpected}} {
+		for _, id := range group.ids {
+			fmt.Printf("  %s: %s\n", id, group.label)
+		}
+	}
+	if onMismatch == "refuse" {
+		return nil, verification
+	}
+	fmt.Printf("Warning: %v\n", verification)
+	return snap, nil
+}
@@ -2,38 +2,98 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 	"github.com/tahcohcat/same-same/internal/embedders"
 	"github.com/tahcohcat/same-same/internal/embedders/clip"
-	"github.com/tahcohcat/same-same/internal/embedders/quotes/gemini"
-	"github.com/tahcohcat/same-same/internal/embedders/quotes/huggingface"
-	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/tfidf"
+	"github.com/tahcohcat/same-same/internal/embedders/registry"
+	"github.com/tahcohcat/same-same/internal/embedders/synonyms"
 	"github.com/tahcohcat/same-same/internal/ingestion"
-	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+	"github.com/tahcohcat/same-same/internal/tracing"
 )
 
 var (
 	// Ingest-specific flags
-	textCol      string
-	idCol        string
-	metaCol      string
-	sample       int
-	split        string
-	maxTokens    int
-	benchmark    bool
-	batchSize    int
-	embedderType string
-	timeout      time.Duration
-	output       string
-	recursive    bool
-	clipModel    string
-	clipPretrain string
+	textCol       string
+	delimiter     string
+	csvDelimiter  rune // Parsed from delimiter, 0 detects it
+	textField     string
+	flatten       bool
+	flattenDepth  int
+	flattenSkip   []string
+	idCol         string
+	metaCol       string
+	sample        int
+	split         string
+	maxTokens     int
+	benchmark     bool
+	batchSize     int
+	embedderType  string
+	timeout       time.Duration
+	output        string
+	recursive     bool
+	clipModel     string
+	clipPretrain  string
+	normalizeKeys bool
+	uniqueKeys    []string
+	sparse        bool
+	dedupDistance int
+	dedupExisting bool
+	parallelFiles int
+	failFast      bool
+	fieldTypes    []string
+	fieldTypeMap  map[string]ingestion.FieldType // Parsed from fieldTypes
+	inferTypes    int
+	dropInvalid   bool
+	where         []string
+	whereText     string
+	quarantined   bool
+	inputFormat   string
+	runManifest   string
+	noEmbeddings  bool
+	storageKind   string
+	storagePath   string
+	embedRetries  = embedders.DefaultRetryPolicy.MaxAttempts - 1
+
+	// ingestStorageType is the storage type the run resolved to, see resolveIngestStorage
+	ingestStorageType string
+
+	// stdin is read by the - source, replaced in tests
+	stdin io.Reader = os.Stdin
+
+	// Summary flags
+	statsFormat     string
+	statsOut        string
+	statsFile       string
+	statsInterval   time.Duration
+	failOnErrorRate float64
+	failOnZero      bool
+
+	// Watch mode flags
+	watchDir        string
+	watchPattern    string
+	watchDebounce   time.Duration
+	watchState      string
+	watchArchiveDir string
+	watchDelete     bool
+	watchSummary    time.Duration
+
+	// Feed poll flags
+	pollInterval time.Duration
 )
 
 func init() {
@@ -41,20 +101,64 @@ func init() {
 
 	// Ingest flags
 	ingestCmd.Flags().StringVar(&textCol, "text-col", "text", "Name of the text column (CSV)")
-	ingestCmd.Flags().StringVar(&idCol, "id-col", "id", "Name of the ID column (optional)")
-	ingestCmd.Flags().StringVar(&metaCol, "meta-col", "", "Name of the metadata column (optional)")
-	ingestCmd.Flags().IntVar(&sample, "sample", 0, "Sample N rows (0 = all)")
+	ingestCmd.Flags().StringVar(&delimiter, "delimiter", "auto", "Field delimiter of CSV files: auto (detected from the header line), tab or a single character such as ;")
+	ingestCmd.Flags().StringVar(&textField, "text-field", "", "Dot-path of the text in JSON records, e.g. payload.body.text or items.0.text (JSONL, JSON and HuggingFace; default text, then content, body, message or quote)")
+	ingestCmd.Flags().BoolVar(&flatten, "flatten-metadata", false, "Store nested JSON objects and arrays as dotted metadata keys, e.g. author.name or tags.0")
+	ingestCmd.Flags().IntVar(&flattenDepth, "flatten-depth", 0, "Levels of nesting --flatten-metadata flattens, deeper values are stored as JSON text (0 = all)")
+	ingestCmd.Flags().StringSliceVar(&flattenSkip, "flatten-exclude", nil, "Dot-path left out of JSON record metadata, with everything below it (repeatable)")
+	ingestCmd.Flags().StringVar(&idCol, "id-col", "id", "Name of the ID column (not implemented yet)")
+	ingestCmd.Flags().StringVar(&metaCol, "meta-col", "", "Name of the metadata column (not implemented yet)")
+	ingestCmd.Flags().IntVar(&parallelFiles, "parallel-files", 1, "Number of source files ingested at once when several are given")
+	ingestCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stop at the first source file that cannot be read instead of ingesting the others")
+	ingestCmd.Flags().StringSliceVar(&fieldTypes, "field-type", nil, "Coerce a metadata field to int, float, bool or datetime so filters compare it reliably, e.g. year:int (repeatable)")
+	ingestCmd.Flags().IntVar(&inferTypes, "infer-types", 0, "Infer the types of the other metadata fields from the first N records (0 disables)")
+	ingestCmd.Flags().BoolVar(&dropInvalid, "drop-invalid-values", false, "Drop metadata values that cannot be coerced to their field type instead of keeping them as they are")
+	ingestCmd.Flags().StringArrayVar(&where, "where", nil, "Only embed records whose metadata matches a condition such as label=pos or \"year>=2015\", with the operators = != < <= > >= (repeatable, all must match)")
+	ingestCmd.Flags().StringVar(&whereText, "where-text-regex", "", "Only embed records whose text matches this regular expression")
+	ingestCmd.Flags().BoolVar(&quarantined, "quarantine", false, "Keep the records failing to embed or validate in the quarantine of the --local storage, to fix and retry them with POST /api/v1/admin/quarantine/retry")
+	ingestCmd.Flags().StringVar(&inputFormat, "format", "auto", "Format of the records read from stdin (-): auto (detected from the first bytes), csv or jsonl")
+	ingestCmd.Flags().BoolVarP(&recursive, "recursive", "r", true, "Scan subdirectories of image directories")
+	ingestCmd.Flags().StringVar(&clipModel, "clip-model", "", "CLIP model of the Python CLIP embedder, e.g. ViT-L-14 (default ViT-B-32)")
+	ingestCmd.Flags().StringVar(&clipPretrain, "clip-pretrained", "", "Pretrained weights of the Python CLIP embedder, e.g. laion2b_s34b_b79k (default openai)")
+	ingestCmd.Flags().IntVar(&sample, "sample", 0, "Sample N rows (not implemented yet)")
 	ingestCmd.Flags().StringVar(&split, "split", "train", "Dataset split (HuggingFace only)")
-	ingestCmd.Flags().IntVar(&maxTokens, "max-tokens", 512, "Max tokens per document")
+	ingestCmd.Flags().IntVar(&maxTokens, "max-tokens", 512, "Max tokens per document (not implemented yet)")
 	ingestCmd.Flags().BoolVar(&benchmark, "benchmark", false, "Run in benchmark mode")
 	ingestCmd.Flags().IntVar(&batchSize, "batch-size", 100, "Batch size for bulk operations")
-	ingestCmd.Flags().StringVarP(&embedderType, "embedder", "e", "", "Embedder type (local, gemini, huggingface)")
+	ingestCmd.Flags().StringVarP(&embedderType, "embedder", "e", "", "Embedder type (local, hash, gemini, huggingface, openai, ollama, clip, fixture)")
+	ingestCmd.Flags().IntVar(&embedRetries, "embed-retries", embedRetries, "Times a remote embedder (gemini, huggingface) retries a request answered with 429 or a 5xx status, waiting longer each time or as Retry-After asks (0 disables)")
 	ingestCmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "Timeout for ingestion")
-	ingestCmd.Flags().StringVarP(&output, "output", "o", "", "Output file for exported vectors")
+	ingestCmd.Flags().StringVarP(&output, "output", "o", "", "Write the vectors stored by the run to this file, as a JSON array when it ends in .json and one vector per line otherwise")
+	ingestCmd.Flags().BoolVar(&noEmbeddings, "no-embeddings", false, "Leave the embeddings out of --output, exporting IDs, metadata and timestamps")
+	ingestCmd.Flags().StringVar(&localPath, "local", "", "Path of a local file storage directory to persist vectors in, short for --storage local --storage-path")
+	ingestCmd.Flags().StringVar(&storageKind, "storage", "", "Storage to ingest into: memory, local or tiered (default $STORAGE_TYPE, or memory)")
+	ingestCmd.Flags().StringVar(&storagePath, "storage-path", "", "Directory of local and tiered storage (default $LOCAL_STORAGE_PATH, or ./data/storage)")
+	ingestCmd.Flags().StringVar(&localCollection, "collection", "", "Collection of local and tiered storage (default $STORAGE_COLLECTION or default)")
+	ingestCmd.Flags().BoolVar(&normalizeKeys, "normalize-keys", false, "Lowercase and snake_case metadata keys (\"Author Name\" becomes \"author_name\")")
+	ingestCmd.Flags().StringSliceVar(&uniqueKeys, "unique-key", nil, "Metadata field identifying a record, re-ingested records update the stored vector (repeatable)")
+	ingestCmd.Flags().BoolVar(&sparse, "sparse", false, "Store sparse vectors when the embedder supports them (local TF-IDF)")
+	ingestCmd.Flags().IntVar(&dedupDistance, "dedup-distance", -1, fmt.Sprintf("Skip images whose perceptual hash differs from a kept image's by at most this many bits, e.g. %d (negative disables)", ingestion.DefaultDedupDistance))
+	ingestCmd.Flags().BoolVar(&dedupExisting, "dedup-existing", false, "With --dedup-distance, also skip images near duplicates of images already stored in the namespace")
+	ingestCmd.Flags().StringVar(&runManifest, "manifest", "", "Write a manifest of the run to this file: source, embedder, counts, stored IDs, config hash and metadata schema (check it later with same-same manifest verify)")
+	ingestCmd.Flags().StringVar(&statsFormat, "stats-format", string(ingestion.StatsText), "Format of the ingestion summary (text, json)")
+	ingestCmd.Flags().StringVar(&statsOut, "stats-out", "", "Write the ingestion summary to this file instead of stdout")
+	ingestCmd.Flags().StringVar(&statsFile, "stats-file", "", "Append a JSON line of rolling counts, speed, failure reasons and embedder latencies to this file every --stats-interval, and a final one when the run ends")
+	ingestCmd.Flags().DurationVar(&statsInterval, "stats-interval", ingestion.DefaultStatsInterval, "How often --stats-file is appended to")
+	ingestCmd.Flags().Float64Var(&failOnErrorRate, "fail-on-error-rate", -1, "Exit with status 2 when failed/total records exceeds this fraction, e.g. 0.05 (negative disables)")
+	ingestCmd.Flags().BoolVar(&failOnZero, "fail-on-zero", false, "Exit with status 2 when no records were ingested")
+
+	ingestCmd.Flags().StringVar(&watchDir, "watch", "", "Watch a directory recursively and ingest new or modified files until interrupted")
+	ingestCmd.Flags().StringVar(&watchPattern, "pattern", "", "File name pattern to ingest in watch mode (default all .csv, .jsonl, .ndjson and .json files, gzipped or not)")
+	ingestCmd.Flags().DurationVar(&watchDebounce, "debounce", ingestion.DefaultWatchDebounce, "How long a file must stay unchanged before it is ingested")
+	ingestCmd.Flags().StringVar(&watchState, "state-file", "", "File recording ingested files (default <watch dir>/"+ingestion.WatchStateFileName+"), or the entries read of rss: feeds (default "+ingestion.FeedStateFileName+" next to the feed list)")
+	ingestCmd.Flags().StringVar(&watchArchiveDir, "archive-dir", "", "Move ingested files into this directory")
+	ingestCmd.Flags().BoolVar(&watchDelete, "delete-after", false, "Delete ingested files")
+	ingestCmd.Flags().DurationVar(&watchSummary, "summary-interval", ingestion.DefaultWatchSummaryInterval, "How often watch mode prints its totals (0 to disable)")
+	ingestCmd.Flags().DurationVar(&pollInterval, "poll-interval", 0, fmt.Sprintf("Poll an rss: source for new entries at this interval until interrupted, e.g. %v (0 reads the feeds once)", ingestion.DefaultFeedPollInterval))
 }
 
 var ingestCmd = &cobra.Command{
-	Use:   "ingest <source>",
+	Use:   "ingest <source>...",
 	Short: "Ingest data into same-same",
 	Long: `Ingest data from various sources into the same-same vector database.
 
@@ -63,9 +167,21 @@ Sources:
   hf:<dataset>                  HuggingFace dataset (e.g., hf:imdb, hf:squad:v2)
   file.csv                      CSV file
   file.jsonl                    JSONL file (each line is a JSON object)
-  file.json                     Same as JSONL
+  file.json                     JSON array of objects, or JSONL
+  file.csv.gz, file.jsonl.gz    Gzip compressed files of any of these formats
   images:<directory>            Directory of images (requires -e clip)
   image-list:<file.txt>         Text file with image paths (requires -e clip)
+  rss:<feeds.txt>, rss:<url>    RSS or Atom feeds, listed one URL per line; only entries
+                                not read by an earlier run are ingested
+  -                             CSV or JSONL records read from stdin, detected from the
+                                first bytes unless --format is given
+
+Stdin is streamed and can only be read once, so it cannot be validated with
+--dry-run and then ingested by the same invocation. --dry-run still reads and
+embeds every record, reporting the failures a real run would have.
+
+Several sources, or glob patterns such as "data/shard-*.jsonl", are ingested as
+one run whose summary breaks the counts down per file.
 
 The ingestion pipeline:
   1. Reads records from the source
@@ -83,12 +199,30 @@ The ingestion pipeline:
   # Ingest from CSV file
   same-same ingest mydata.csv --text-col content
 
+  # Ingest a semicolon separated export (detected automatically, or set explicitly)
+  same-same ingest export.csv --delimiter ";"
+
   # Ingest from JSONL file
   same-same ingest data.jsonl -v
 
+  # Type metadata fields so filters such as year >= 1900 work on messy values like "1,900"
+  same-same ingest books.csv --field-type year:int --field-type published_at:datetime --infer-types 100
+
+  # Ingest 300 shards, four at a time, into the same storage
+  same-same ingest "data/shard-*.jsonl" --parallel-files 4 --local ./data/storage
+
+  # Ingest nested JSONL records, flattening the rest into dotted metadata keys
+  same-same ingest events.jsonl --text-field payload.body.text --flatten-metadata --flatten-exclude payload.raw
+
   # Dry run to validate data
   same-same ingest --dry-run -v data.jsonl
 
+  # Ingest JSONL generated by another tool, without a temporary file
+  some-tool | same-same ingest - --local ./data/storage
+
+  # Ingest CSV from stdin, decompressing it on the fly
+  curl -s https://example.com/export.csv.gz | same-same ingest - --format csv --text-col body
+
   # Use specific embedder
   same-same ingest -e gemini demo
   
@@ -96,36 +230,152 @@ The ingestion pipeline:
   same-same ingest -e clip images:./photos
   
   # Ingest images from list
-  same-same ingest -e clip image-list:images.txt`,
-	Args: cobra.ExactArgs(1),
-	Run:  runIngest,
+  same-same ingest -e clip image-list:images.txt
+
+  # Skip resized copies and near duplicates of photos, including those already stored
+  same-same ingest -e clip images:./photos --local ./data/storage --dedup-distance 6 --dedup-existing
+
+  # Persist vectors in local file storage
+  same-same ingest --local ./data/storage data.jsonl
+
+  # Ingest into the storage serve opens with STORAGE_TYPE=local and LOCAL_STORAGE_PATH
+  same-same ingest --storage local -e hash demo
+
+  # Re-ingest tickets, updating the vectors of tickets already stored
+  same-same ingest --local ./data/storage --unique-key ticket_id tickets.jsonl
+
+  # Write a manifest of the run for a data catalog, and check the store against it later
+  same-same ingest --local ./data/storage --manifest run-manifest.json data.jsonl
+  same-same manifest verify run-manifest.json --local ./data/storage
+
+  # Write a JSON summary and fail the job if more than 5% of records fail
+  same-same ingest --stats-format json --stats-out stats.json --fail-on-error-rate 0.05 data.jsonl
+
+  # Ingest JSONL files dropped into a directory, archiving them once done
+  same-same ingest --watch ./drop-dir --pattern "*.jsonl" --local ./data/storage --archive-dir ./done
+
+  # Poll RSS and Atom feeds every 15 minutes, ingesting new entries
+  same-same ingest rss:feeds.txt --poll-interval 15m --local ./data/storage`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if watchDir != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	Run: runIngest,
 }
 
 func runIngest(cmd *cobra.Command, args []string) {
-	source := args[0]
+	summary, err := newIngestSummary()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dedupExisting && dedupDistance < 0 {
+		log.Fatal("--dedup-existing requires --dedup-distance")
+	}
+	if csvDelimiter, err = ingestion.ParseDelimiter(delimiter); err != nil {
+		log.Fatal(err)
+	}
+	if fieldTypeMap, err = ingestion.ParseFieldTypes(fieldTypes); err != nil {
+		log.Fatal(err)
+	}
+	maxDimension, err := models.ParseMaxEmbeddingDimension(os.Getenv("MAX_EMBEDDING_DIMENSION"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	models.SetMaxEmbeddingDimension(maxDimension)
+	if runManifest != "" && (watchDir != "" || pollInterval > 0) {
+		log.Fatal("--manifest describes a single run, it cannot be used with --watch or --poll-interval")
+	}
+	if ingestStorageType, err = resolveIngestStorage(cmd.Flags().Changed); err != nil {
+		log.Fatal(err)
+	}
+
+	if watchDir != "" {
+		run := ingestRun{sources: []sourceKind{sourceCSV, sourceJSON}, watch: true, pythonCLIP: usesPythonCLIP(embedderType)}
+		if err := checkIngestFlags(cmd.Flags().Changed, run); err != nil {
+			log.Fatal(err)
+		}
+		runWatch(summary)
+		return
+	}
+
+	sourceArgs, err := expandSources(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	run := ingestRun{pythonCLIP: usesPythonCLIP(embedderType)}
+	for _, arg := range sourceArgs {
+		run.sources = append(run.sources, sourceKindOf(arg))
+	}
+	if err := checkIngestFlags(cmd.Flags().Changed, run); err != nil {
+		log.Fatal(err)
+	}
+	if quarantined && ingestStorageType == "memory" && !dryRun {
+		log.Fatal("--quarantine requires --local or --storage local so quarantined records persist")
+	}
 
 	// Create config
 	config := &ingestion.SourceConfig{
-		Namespace: namespace,
-		BatchSize: batchSize,
-		DryRun:    dryRun,
-		Verbose:   verbose,
+		Namespace:     namespace,
+		BatchSize:     batchSize,
+		DryRun:        dryRun,
+		Verbose:       verbose,
+		NormalizeKeys: normalizeKeys,
+		UniqueKeys:    uniqueKeys,
+		Sparse:        sparse,
+		DedupImages:   dedupDistance >= 0,
+		DedupDistance: dedupDistance,
+		DedupExisting: dedupExisting,
+
+		FlattenMetadata: flatten,
+		FlattenDepth:    flattenDepth,
+		FlattenExclude:  flattenSkip,
+		ParallelFiles:   parallelFiles,
+		FailFast:        failFast,
+
+		FieldTypes:        fieldTypeMap,
+		InferTypes:        inferTypes,
+		DropInvalidValues: dropInvalid,
+		Where:             where,
+		WhereTextRegex:    whereText,
+		Quarantine:        quarantined,
+		DefaultMetadata:   loadDefaultMetadata(),
+		Manifest:          runManifest,
+		StatsFile:         statsFile,
+		StatsInterval:     statsInterval,
 	}
 
 	// Create source
-	src, err := createSource(source, config)
+	src, err := createSources(sourceArgs, config)
 	if err != nil {
 		log.Fatalf("Failed to create source: %v", err)
 	}
 
+	if pollInterval > 0 {
+		feeds, ok := src.(*ingestion.RSSSource)
+		if !ok {
+			log.Fatal("--poll-interval requires a single rss: source")
+		}
+		runPoll(feeds, config, summary)
+		return
+	}
+
 	// Create embedder
 	embedder, err := createEmbedder(embedderType)
 	if err != nil {
 		log.Fatalf("Failed to create embedder: %v", err)
 	}
+	if formats, ok := embedder.(embedders.ImageFormatLister); ok {
+		config.ImageExtensions = formats.ImageExtensions()
+	}
 
 	// Create storage
-	storage := memory.NewStorage()
+	storage, closeStorage, err := ingestStorage()
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeStorage()
 
 	// Create ingestor
 	ingestor := ingestion.NewIngestor(src, embedder, storage, config)
@@ -142,39 +392,302 @@ func runIngest(cmd *cobra.Command, args []string) {
 		fmt.Println("⚡ Benchmark mode enabled")
 	}
 
+	// Traces are exported when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Init(ctx, "same-same-ingest")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
 	stats, err := ingestor.Run(ctx)
+	if terr := shutdownTracing(context.Background()); terr != nil {
+		log.Printf("Failed to flush traces: %v", terr)
+	}
+	if errors.Is(err, ingestion.ErrNoData) {
+		log.Fatal("Ingestion failed: stdin is empty, pipe CSV or JSONL records into same-same ingest -")
+	}
 	if err != nil {
 		log.Fatalf("Ingestion failed: %v", err)
 	}
 
-	// Print statistics
-	stats.Print()
+	if runManifest != "" {
+		fmt.Printf("Manifest written to: %s\n", runManifest)
+	}
 
 	// Export if requested
 	if output != "" && !dryRun {
-		if err := exportVectors(storage, output); err != nil {
+		count, err := exportVectors(storage, output, stats.RunID)
+		if err != nil {
 			log.Fatalf("Failed to export vectors: %v", err)
 		}
-		fmt.Printf("Vectors exported to: %s\n", output)
+		fmt.Printf("%d vectors exported to: %s\n", count, output)
+	}
+
+	summary.finish(stats)
+}
+
+// loadDefaultMetadata loads the default metadata of DEFAULT_METADATA, the same the
+// server adds, so ingested vectors match those written over HTTP
+func loadDefaultMetadata() *defaults.Config {
+	config, err := defaults.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid DEFAULT_METADATA: %v", err)
+	}
+	return config
+}
+
+// resolveIngestStorage sets STORAGE_TYPE, LOCAL_STORAGE_PATH and STORAGE_COLLECTION
+// from --local, --storage, --storage-path and --collection, so ingestStorage opens the
+// storage serve would open with them, and returns the storage type they resolve to
+func resolveIngestStorage(changed func(string) bool) (string, error) {
+	_ = godotenv.Load() // load .env if present, as serve does
+
+	if localPath != "" {
+		if storageKind != "" && storageKind != "local" {
+			return "", fmt.Errorf("--local cannot be used with --storage %s", storageKind)
+		}
+		if storagePath != "" {
+			return "", fmt.Errorf("--local cannot be used with --storage-path")
+		}
+		storageKind, storagePath = "local", localPath
+	}
+
+	switch storageKind {
+	case "":
+	case "memory", "local", "tiered":
+		os.Setenv("STORAGE_TYPE", storageKind)
+	default:
+		return "", fmt.Errorf("invalid --storage %q: must be memory, local or tiered", storageKind)
+	}
+	if storagePath != "" {
+		os.Setenv("LOCAL_STORAGE_PATH", storagePath)
+	}
+	if changed("collection") {
+		os.Setenv("STORAGE_COLLECTION", localCollection)
+	}
+
+	// NewStorageFromEnv falls back to memory storage on unknown types
+	switch storageType := os.Getenv("STORAGE_TYPE"); storageType {
+	case "local", "tiered":
+		return storageType, nil
+	default:
+		return "memory", nil
+	}
+}
+
+// ingestStorage opens the storage resolved by resolveIngestStorage
+func ingestStorage() (storage.Storage, func() error, error) {
+	store, err := storage.NewStorageFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	if closer, ok := store.(io.Closer); ok {
+		return store, closer.Close, nil
+	}
+	return store, func() error { return nil }, nil
+}
+
+// runWatch ingests the files appearing in the --watch directory until interrupted
+func runWatch(summary *ingestSummary) {
+	if ingestStorageType == "memory" && !dryRun {
+		log.Fatal("--watch requires --local or --storage local so ingested vectors persist (or --dry-run to validate files)")
+	}
+
+	config := &ingestion.SourceConfig{
+		Namespace:     namespace,
+		BatchSize:     batchSize,
+		DryRun:        dryRun,
+		Verbose:       verbose,
+		NormalizeKeys: normalizeKeys,
+		UniqueKeys:    uniqueKeys,
+		Sparse:        sparse,
+		DedupImages:   dedupDistance >= 0,
+		DedupDistance: dedupDistance,
+		DedupExisting: dedupExisting,
+
+		FlattenMetadata: flatten,
+		FlattenDepth:    flattenDepth,
+		FlattenExclude:  flattenSkip,
+
+		FieldTypes:        fieldTypeMap,
+		InferTypes:        inferTypes,
+		DropInvalidValues: dropInvalid,
+		Where:             where,
+		WhereTextRegex:    whereText,
+		Quarantine:        quarantined,
+		DefaultMetadata:   loadDefaultMetadata(),
+		StatsFile:         statsFile,
+		StatsInterval:     statsInterval,
+	}
+
+	embedder, err := createEmbedder(embedderType)
+	if err != nil {
+		log.Fatalf("Failed to create embedder: %v", err)
+	}
+
+	storage, closeStorage, err := ingestStorage()
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeStorage()
+
+	watcher, err := ingestion.NewWatcher(ingestion.WatchConfig{
+		Dir:             watchDir,
+		Pattern:         watchPattern,
+		Debounce:        watchDebounce,
+		StateFile:       watchState,
+		ArchiveDir:      watchArchiveDir,
+		DeleteAfter:     watchDelete,
+		SummaryInterval: watchSummary,
+		TextColumn:      textCol,
+		Delimiter:       csvDelimiter,
+		TextField:       textField,
+	}, config, embedder, storage)
+	if err != nil {
+		log.Fatalf("Failed to watch %s: %v", watchDir, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Watching %s for new files (Ctrl+C to stop)\n", watchDir)
+	if dryRun {
+		fmt.Println("DRY RUN MODE - no data will be stored")
+	}
+
+	if err := watcher.Run(ctx); err != nil {
+		log.Fatalf("Watch failed: %v", err)
+	}
+
+	files, stats := watcher.Totals()
+	fmt.Printf("\nIngested %d files\n", files)
+	summary.finish(&stats)
+}
+
+// runPoll ingests the new entries of the feeds of source every --poll-interval until interrupted
+func runPoll(source *ingestion.RSSSource, config *ingestion.SourceConfig, summary *ingestSummary) {
+	if ingestStorageType == "memory" && !dryRun {
+		log.Fatal("--poll-interval requires --local or --storage local so ingested vectors persist (or --dry-run to validate feeds)")
+	}
+
+	embedder, err := createEmbedder(embedderType)
+	if err != nil {
+		log.Fatalf("Failed to create embedder: %v", err)
+	}
+
+	storage, closeStorage, err := ingestStorage()
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeStorage()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Polling %s every %v (Ctrl+C to stop)\n", source.Name(), pollInterval)
+	if dryRun {
+		fmt.Println("DRY RUN MODE - no data will be stored")
+	}
+
+	poller := ingestion.NewFeedPoller(source, pollInterval, config, embedder, storage)
+	if err := poller.Run(ctx); err != nil {
+		log.Fatalf("Poll failed: %v", err)
+	}
+
+	polls, stats := poller.Totals()
+	fmt.Printf("\nPolled %d times\n", polls)
+	summary.finish(&stats)
+}
+
+// expandSources replaces the glob patterns among source arguments by the files they match
+func expandSources(args []string) ([]string, error) {
+	var expanded []string
+	for _, arg := range args {
+		if strings.Contains(arg, ":") || !strings.ContainsAny(arg, "*?[") {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", arg)
+		}
+		expanded = append(expanded, matches...)
 	}
+	return expanded, nil
+}
+
+// createSources creates the source of each argument, combining them into a
+// CompositeSource when there are several
+func createSources(args []string, config *ingestion.SourceConfig) (ingestion.Source, error) {
+	if parallelFiles < 1 {
+		return nil, fmt.Errorf("--parallel-files must be at least 1")
+	}
+
+	var sources []ingestion.Source
+	readsStdin := false
+	for _, arg := range args {
+		if arg == ingestion.StdinPath {
+			if readsStdin {
+				return nil, fmt.Errorf("stdin (-) can only be given once")
+			}
+			readsStdin = true
+		}
+		source, err := createSource(arg, config)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+
+	if len(sources) == 1 {
+		return sources[0], nil
+	}
+	return ingestion.NewCompositeSource(sources, config), nil
 }
 
 func createSource(sourceArg string, config *ingestion.SourceConfig) (ingestion.Source, error) {
+	if sourceArg == ingestion.StdinPath {
+		return createStdinSource(config)
+	}
+
 	// Check for HuggingFace dataset
 	if strings.HasPrefix(sourceArg, "hf:") {
 		dataset := strings.TrimPrefix(sourceArg, "hf:")
 		source := ingestion.NewHuggingFaceSource(dataset, config)
 		source.SetSplit(split)
+		if textField != "" {
+			source.SetTextField(textField)
+		}
 		return source, nil
 	}
 
-	// Check for built-in datasets
-	builtinDatasets := map[string]bool{
-		"demo":         true,
-		"quotes":       true,
-		"quotes-small": true,
+	// Check for image directories and lists
+	if strings.HasPrefix(sourceArg, "images:") {
+		source, err := ingestion.NewImageSource(strings.TrimPrefix(sourceArg, "images:"), config)
+		if err != nil {
+			return nil, err
+		}
+		source.SetRecursive(recursive)
+		return source, nil
+	}
+	if strings.HasPrefix(sourceArg, "image-list:") {
+		return ingestion.NewImageListSource(strings.TrimPrefix(sourceArg, "image-list:"), config)
+	}
+
+	// Check for feeds
+	if strings.HasPrefix(sourceArg, "rss:") {
+		source, err := ingestion.NewRSSSource(strings.TrimPrefix(sourceArg, "rss:"), config)
+		if err != nil {
+			return nil, err
+		}
+		source.SetStateFile(watchState)
+		return source, nil
 	}
 
+	// Check for built-in datasets
 	if builtinDatasets[sourceArg] {
 		return ingestion.NewBuiltinSource(sourceArg, config), nil
 	}
@@ -186,10 +699,10 @@ func createSource(sourceArg string, config *ingestion.SourceConfig) (ingestion.S
 			return nil, err
 		}
 
-		// Set text column for CSV files
-		if strings.HasSuffix(strings.ToLower(sourceArg), ".csv") {
-			source.SetTextColumn(textCol)
-		}
+		// Text column and delimiter only apply to CSV files, text field to JSON
+		source.SetTextColumn(textCol)
+		source.SetDelimiter(csvDelimiter)
+		source.SetTextField(textField)
 
 		return source, nil
 	}
@@ -197,57 +710,107 @@ func createSource(sourceArg string, config *ingestion.SourceConfig) (ingestion.S
 	return nil, fmt.Errorf("unknown source: %s", sourceArg)
 }
 
-func createEmbedder(embedderType string) (embedders.Embedder, error) {
-	// Use environment variable if not specified
-	if embedderType == "" {
-		embedderType = os.Getenv("EMBEDDER_TYPE")
-		if embedderType == "" {
-			embedderType = "local"
+// createStdinSource creates the source of the records piped into stdin
+// A terminal is refused, since reading it would wait for input that never comes
+func createStdinSource(config *ingestion.SourceConfig) (ingestion.Source, error) {
+	if file, ok := stdin.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return nil, fmt.Errorf("stdin is a terminal: pipe CSV or JSONL records into same-same ingest -, e.g. some-tool | same-same ingest -")
 		}
 	}
 
-	switch strings.ToLower(embedderType) {
-	case "local":
-		return tfidf.NewTFIDFEmbedder(), nil
+	format := inputFormat
+	if format == "auto" {
+		format = ""
+	}
+	source, err := ingestion.NewReaderSource(stdin, format, config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --format: %w", err)
+	}
+	source.SetTextColumn(textCol)
+	source.SetDelimiter(csvDelimiter)
+	source.SetTextField(textField)
+	return source, nil
+}
 
-	case "gemini":
-		apiKey := os.Getenv("GEMINI_API_KEY")
-		if apiKey == "" {
-			return nil, fmt.Errorf("GEMINI_API_KEY environment variable not set")
+// createEmbedder creates the embedder of a type, or when none is given the one
+// NAMESPACE_EMBEDDERS configures for --namespace, else that of EMBEDDER_TYPE
+func createEmbedder(embedderType string) (embedders.Embedder, error) {
+	spec := registry.Spec{Type: embedderType}
+	if embedderType == "" {
+		config, err := registry.FromEnv()
+		if err != nil {
+			return nil, err
 		}
-		return gemini.NewGeminiEmbedder(apiKey), nil
+		if config != nil {
+			spec = config.SpecFor(namespace)
+		} else {
+			spec.Type = os.Getenv("EMBEDDER_TYPE")
+		}
+	}
 
-	case "huggingface", "hf":
-		apiKey := os.Getenv("HUGGINGFACE_API_KEY")
-		if apiKey == "" {
-			return nil, fmt.Errorf("HUGGINGFACE_API_KEY environment variable not set")
+	if strings.ToLower(spec.Type) != "clip" {
+		embedder, err := registry.New(spec)
+		if err != nil {
+			return nil, err
+		}
+		if verbose && embedderType == "" {
+			fmt.Printf("Using embedder %s for namespace %s\n", embedder.Name(), namespace)
 		}
-		return huggingface.NewHuggingFaceEmbedder(apiKey), nil
+		if err := withRetries(embedder); err != nil {
+			return nil, err
+		}
+		return withSynonyms(embedder)
+	}
 
-	case "clip":
-		// Check if using Python-based CLIP or simple Go-based
-		if os.Getenv("CLIP_USE_PYTHON") == "true" {
-			embedder := clip.NewCLIPEmbedder(clipModel, clipPretrain)
-			if verbose {
-				fmt.Printf("Using Python CLIP model: %s with pretrained: %s\n", clipModel, clipPretrain)
-			}
-			return embedder, nil
-		} else {
-			// Use simple Go-based embedder (no Python required!)
-			embedder := clip.NewSimpleCLIPEmbedder()
-			if verbose {
-				fmt.Printf("Using Simple CLIP embedder (pure Go, no Python required)\n")
-			}
-			return embedder, nil
+	// Check if using Python-based CLIP or simple Go-based
+	if os.Getenv("CLIP_USE_PYTHON") == "true" {
+		embedder := clip.NewCLIPEmbedder(clipModel, clipPretrain)
+		if verbose {
+			fmt.Printf("Using Python CLIP model: %s with pretrained: %s\n", clipModel, clipPretrain)
 		}
+		return embedder, nil
+	}
 
-	default:
-		return nil, fmt.Errorf("unknown embedder type: %s (supported: local, gemini, huggingface, clip)", embedderType)
+	// Use simple Go-based embedder (no Python required!)
+	embedder := clip.NewSimpleCLIPEmbedder()
+	if verbose {
+		fmt.Printf("Using Simple CLIP embedder (pure Go, no Python required)\n")
 	}
+	return embedder, nil
 }
 
-func exportVectors(storage *memory.Storage, filename string) error {
-	// Placeholder - implement based on your export needs
-	fmt.Printf("Export functionality not yet implemented\n")
+// withSynonyms attaches the synonyms file from SYNONYMS_PATH to a local embedder
+func withSynonyms(embedder embedders.Embedder) (embedders.Embedder, error) {
+	set, err := synonyms.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load synonyms: %w", err)
+	}
+
+	if expander, ok := embedder.(embedders.SynonymExpander); ok && set != nil {
+		expander.SetSynonyms(set)
+		if verbose {
+			fmt.Printf("Loaded %d synonym sets from %s\n", set.Count(), set.Path())
+		}
+	}
+
+	return embedder, nil
+}
+
+// withRetries sets the retries of --embed-retries on embedders retrying their requests
+func withRetries(embedder embedders.Embedder) error {
+	if embedRetries < 0 {
+		return fmt.Errorf("invalid --embed-retries %d: must not be negative", embedRetries)
+	}
+	if retrier, ok := embedder.(embedders.Retrier); ok {
+		policy := embedders.DefaultRetryPolicy
+		policy.MaxAttempts = embedRetries + 1
+		retrier.SetRetryPolicy(policy)
+	}
 	return nil
 }
+
+// exportVectors writes the vectors stored by the run to --output
+func exportVectors(storage storage.Storage, filename string, runID string) (int, error) {
+	return ingestion.ExportRun(storage, filename, ingestion.ExportOptions{RunID: runID, NoEmbeddings: noEmbeddings})
+}
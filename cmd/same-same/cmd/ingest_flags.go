@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/ingestion"
+)
+
+// sourceKind is the kind of an ingest source argument, deciding which flags apply to it
+type sourceKind string
+
+const (
+	sourceCSV       sourceKind = "CSV"
+	sourceJSON      sourceKind = "JSONL"
+	sourceHF        sourceKind = "HuggingFace"
+	sourceBuiltin   sourceKind = "built-in"
+	sourceImages    sourceKind = "image directory"
+	sourceImageList sourceKind = "image list"
+	sourceRSS       sourceKind = "RSS feed"
+	sourceStdin     sourceKind = "stdin"
+)
+
+// builtinDatasets are the datasets ingested by name from .examples/data
+var builtinDatasets = map[string]bool{
+	"demo":         true,
+	"quotes":       true,
+	"quotes-small": true,
+}
+
+// sourceKindOf classifies a source argument, "" when it is not a source createSource knows
+func sourceKindOf(arg string) sourceKind {
+	switch {
+	case arg == ingestion.StdinPath:
+		return sourceStdin
+	case strings.HasPrefix(arg, "hf:"):
+		return sourceHF
+	case strings.HasPrefix(arg, "images:"):
+		return sourceImages
+	case strings.HasPrefix(arg, "image-list:"):
+		return sourceImageList
+	case strings.HasPrefix(arg, "rss:"):
+		return sourceRSS
+	case builtinDatasets[arg]:
+		return sourceBuiltin
+	}
+
+	switch fileType, _ := ingestion.FileType(arg); fileType {
+	case "csv":
+		return sourceCSV
+	case "jsonl":
+		return sourceJSON
+	default:
+		return ""
+	}
+}
+
+// ingestRun is what the flags of an ingest run are audited against
+type ingestRun struct {
+	sources    []sourceKind // Kind of every source, after glob expansion
+	watch      bool         // Watch mode, which reads CSV and JSON files one at a time
+	pythonCLIP bool         // The embedder is the Python CLIP model
+}
+
+// ingestFlagRule says when an ingest flag has an effect
+type ingestFlagRule struct {
+	flag          string
+	sources       []sourceKind // Kinds of source the flag applies to, nil for all
+	multiple      bool         // Applies only when several sources are ingested
+	pythonCLIP    bool         // Applies only to the Python CLIP embedder
+	unimplemented bool         // Declared but not implemented, setting it is an error
+}
+
+// ingestFlagRules covers the ingest flags that do not apply to every run
+// Flags missing from the table apply to all of them
+var ingestFlagRules = []ingestFlagRule{
+	{flag: "text-col", sources: []sourceKind{sourceCSV, sourceStdin}},
+	{flag: "delimiter", sources: []sourceKind{sourceCSV, sourceStdin}},
+	{flag: "text-field", sources: []sourceKind{sourceJSON, sourceHF, sourceStdin}},
+	{flag: "flatten-metadata", sources: []sourceKind{sourceJSON, sourceHF, sourceStdin}},
+	{flag: "flatten-depth", sources: []sourceKind{sourceJSON, sourceHF, sourceStdin}},
+	{flag: "flatten-exclude", sources: []sourceKind{sourceJSON, sourceHF, sourceStdin}},
+	{flag: "format", sources: []sourceKind{sourceStdin}},
+	{flag: "split", sources: []sourceKind{sourceHF}},
+	{flag: "recursive", sources: []sourceKind{sourceImages}},
+	{flag: "dedup-distance", sources: []sourceKind{sourceImages, sourceImageList}},
+	{flag: "dedup-existing", sources: []sourceKind{sourceImages, sourceImageList}},
+	{flag: "poll-interval", sources: []sourceKind{sourceRSS}},
+	{flag: "parallel-files", multiple: true},
+	{flag: "fail-fast", multiple: true},
+	{flag: "clip-model", pythonCLIP: true},
+	{flag: "clip-pretrained", pythonCLIP: true},
+	{flag: "id-col", unimplemented: true},
+	{flag: "meta-col", unimplemented: true},
+	{flag: "max-tokens", unimplemented: true},
+	{flag: "sample", unimplemented: true},
+}
+
+// auditIngestFlags returns a warning for each flag set that has no effect on
+// the run, and an error for the first flag set that is not implemented
+func auditIngestFlags(changed func(string) bool, run ingestRun) ([]string, error) {
+	var warnings []string
+	for _, rule := range ingestFlagRules {
+		if !changed(rule.flag) {
+			continue
+		}
+
+		switch {
+		case rule.unimplemented:
+			return warnings, fmt.Errorf("--%s is not implemented yet", rule.flag)
+
+		case rule.multiple && (run.watch || len(run.sources) < 2):
+			warnings = append(warnings, fmt.Sprintf("--%s has no effect with a single source", rule.flag))
+
+		case rule.pythonCLIP && !run.pythonCLIP:
+			warnings = append(warnings, fmt.Sprintf("--%s has no effect without the Python CLIP embedder (-e clip with CLIP_USE_PYTHON=true)", rule.flag))
+
+		case rule.sources != nil && !appliesTo(rule.sources, run.sources):
+			warnings = append(warnings, fmt.Sprintf("--%s has no effect for %s sources", rule.flag, joinKinds(run.sources)))
+		}
+	}
+	return warnings, nil
+}
+
+// appliesTo reports whether any of kinds is one of those a flag applies to
+func appliesTo(applies, kinds []sourceKind) bool {
+	for _, kind := range kinds {
+		for _, a := range applies {
+			if kind == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joinKinds lists the distinct kinds of the sources of a run, such as "CSV and JSONL"
+func joinKinds(kinds []sourceKind) string {
+	var names []string
+	seen := make(map[sourceKind]bool)
+	for _, kind := range kinds {
+		if kind != "" && !seen[kind] {
+			seen[kind] = true
+			names = append(names, string(kind))
+		}
+	}
+	if len(names) <= 1 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// checkIngestFlags audits the flags set on the ingest command, printing the
+// warnings to stderr
+func checkIngestFlags(changed func(string) bool, run ingestRun) error {
+	warnings, err := auditIngestFlags(changed, run)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	return err
+}
+
+// usesPythonCLIP reports whether createEmbedder returns the Python CLIP model
+// for an embedder type, which falls back to EMBEDDER_TYPE when empty
+func usesPythonCLIP(embedderType string) bool {
+	if embedderType == "" {
+		embedderType = os.Getenv("EMBEDDER_TYPE")
+	}
+	return strings.EqualFold(embedderType, "clip") && os.Getenv("CLIP_USE_PYTHON") == "true"
+}
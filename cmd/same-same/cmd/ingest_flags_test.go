@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditIngestFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  string
+		run   ingestRun
+		warn  string // Substring of the expected warning, "" for none
+		fails bool
+	}{
+		{"text-col csv", "text-col", ingestRun{sources: []sourceKind{sourceCSV}}, "", false},
+		{"text-col jsonl", "text-col", ingestRun{sources: []sourceKind{sourceJSON}}, "--text-col has no effect for JSONL sources", false},
+		{"text-col mixed", "text-col", ingestRun{sources: []sourceKind{sourceJSON, sourceCSV}}, "", false},
+		{"text-col watch", "text-col", ingestRun{sources: []sourceKind{sourceCSV, sourceJSON}, watch: true}, "", false},
+		{"delimiter hf", "delimiter", ingestRun{sources: []sourceKind{sourceHF}}, "--delimiter has no effect for HuggingFace sources", false},
+		{"text-field jsonl", "text-field", ingestRun{sources: []sourceKind{sourceJSON}}, "", false},
+		{"text-field hf", "text-field", ingestRun{sources: []sourceKind{sourceHF}}, "", false},
+		{"text-field csv", "text-field", ingestRun{sources: []sourceKind{sourceCSV}}, "--text-field has no effect for CSV sources", false},
+		{"flatten builtin", "flatten-metadata", ingestRun{sources: []sourceKind{sourceBuiltin}}, "no effect for built-in sources", false},
+		{"split hf", "split", ingestRun{sources: []sourceKind{sourceHF}}, "", false},
+		{"split csv and jsonl", "split", ingestRun{sources: []sourceKind{sourceCSV, sourceJSON, sourceCSV}}, "for CSV and JSONL sources", false},
+		{"recursive images", "recursive", ingestRun{sources: []sourceKind{sourceImages}}, "", false},
+		{"recursive image list", "recursive", ingestRun{sources: []sourceKind{sourceImageList}}, "no effect for image list sources", false},
+		{"dedup image list", "dedup-distance", ingestRun{sources: []sourceKind{sourceImageList}}, "", false},
+		{"dedup jsonl", "dedup-existing", ingestRun{sources: []sourceKind{sourceJSON}}, "no effect for JSONL sources", false},
+		{"parallel files", "parallel-files", ingestRun{sources: []sourceKind{sourceJSON, sourceJSON}}, "", false},
+		{"parallel single file", "parallel-files", ingestRun{sources: []sourceKind{sourceJSON}}, "no effect with a single source", false},
+		{"fail-fast watch", "fail-fast", ingestRun{sources: []sourceKind{sourceCSV, sourceJSON}, watch: true}, "no effect with a single source", false},
+		{"clip model python", "clip-model", ingestRun{sources: []sourceKind{sourceImages}, pythonCLIP: true}, "", false},
+		{"clip model simple", "clip-model", ingestRun{sources: []sourceKind{sourceImages}}, "without the Python CLIP embedder", false},
+		{"clip pretrained simple", "clip-pretrained", ingestRun{sources: []sourceKind{sourceImages}}, "without the Python CLIP embedder", false},
+		{"id-col csv", "id-col", ingestRun{sources: []sourceKind{sourceCSV}}, "", true},
+		{"meta-col csv", "meta-col", ingestRun{sources: []sourceKind{sourceCSV}}, "", true},
+		{"max-tokens jsonl", "max-tokens", ingestRun{sources: []sourceKind{sourceJSON}}, "", true},
+		{"sample hf", "sample", ingestRun{sources: []sourceKind{sourceHF}}, "", true},
+		{"namespace", "namespace", ingestRun{sources: []sourceKind{sourceJSON}}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := func(name string) bool { return name == tt.flag }
+			warnings, err := auditIngestFlags(changed, tt.run)
+
+			if (err != nil) != tt.fails {
+				t.Fatalf("error = %v, want failure %v", err, tt.fails)
+			}
+			switch {
+			case tt.warn == "" && len(warnings) > 0:
+				t.Errorf("warnings = %q, want none", warnings)
+			case tt.warn != "" && (len(warnings) != 1 || !strings.Contains(warnings[0], tt.warn)):
+				t.Errorf("warnings = %q, want one containing %q", warnings, tt.warn)
+			}
+		})
+	}
+}
+
+func TestAuditIngestFlags_Unset(t *testing.T) {
+	unset := func(string) bool { return false }
+	for _, kind := range []sourceKind{sourceCSV, sourceJSON, sourceHF, sourceBuiltin, sourceImages, sourceImageList, sourceRSS, sourceStdin} {
+		warnings, err := auditIngestFlags(unset, ingestRun{sources: []sourceKind{kind}})
+		if err != nil || len(warnings) > 0 {
+			t.Errorf("%s: warnings %q, error %v, want neither when no flag is set", kind, warnings, err)
+		}
+	}
+}
+
+func TestSourceKindOf(t *testing.T) {
+	tests := map[string]sourceKind{
+		"hf:imdb":             sourceHF,
+		"images:./photos":     sourceImages,
+		"image-list:list.txt": sourceImageList,
+		"rss:feeds.txt":       sourceRSS,
+		"demo":                sourceBuiltin,
+		"data.csv":            sourceCSV,
+		"data.csv.gz":         sourceCSV,
+		"data.jsonl":          sourceJSON,
+		"export.json":         sourceJSON,
+		"-":                   sourceStdin,
+		"notes.txt":           "",
+	}
+	for arg, want := range tests {
+		if got := sourceKindOf(arg); got != want {
+			t.Errorf("sourceKindOf(%q) = %q, want %q", arg, got, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/tahcohcat/same-same/internal/ingestion"
+)
+
+// exitThresholdFailed is the exit status of an ingestion that completed but
+// broke --fail-on-error-rate or --fail-on-zero, so pipelines can tell it
+// apart from an ingestion that could not run (status 1)
+const exitThresholdFailed = 2
+
+// ingestSummary writes the ingestion statistics selected by the summary flags
+type ingestSummary struct {
+	format     ingestion.StatsFormat
+	out        io.Writer
+	thresholds ingestion.StatsThresholds
+}
+
+// newIngestSummary validates the summary flags. A JSON summary written to
+// stdout must be the only thing there, so progress output is moved to stderr
+func newIngestSummary() (*ingestSummary, error) {
+	format, err := ingestion.ParseStatsFormat(statsFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &ingestSummary{
+		format: format,
+		out:    os.Stdout,
+		thresholds: ingestion.StatsThresholds{
+			MaxFailureRate: failOnErrorRate,
+			FailOnZero:     failOnZero,
+		},
+	}
+	if statsOut == "" && format == ingestion.StatsJSON {
+		os.Stdout = os.Stderr
+	}
+	return summary, nil
+}
+
+// finish writes the stats and exits with exitThresholdFailed when a threshold is broken
+func (s *ingestSummary) finish(stats *ingestion.Stats) {
+	if statsOut != "" {
+		file, err := os.Create(statsOut)
+		if err != nil {
+			log.Fatalf("Failed to create stats file: %v", err)
+		}
+		if err := stats.Write(file, s.format); err != nil {
+			log.Fatalf("Failed to write stats: %v", err)
+		}
+		if err := file.Close(); err != nil {
+			log.Fatalf("Failed to write stats: %v", err)
+		}
+		fmt.Printf("Stats written to: %s\n", statsOut)
+	} else if err := stats.Write(s.out, s.format); err != nil {
+		log.Fatalf("Failed to write stats: %v", err)
+	}
+
+	if err := s.thresholds.Check(stats); err != nil {
+		fmt.Fprintf(os.Stderr, "Ingestion failed threshold: %v\n", err)
+		os.Exit(exitThresholdFailed)
+	}
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/tahcohcat/same-same/internal/server"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+// runIngestStdin runs the ingest command on input piped into stdin, returning the
+// JSON summary and what was left unread of the input
+func runIngestStdin(t *testing.T, input io.Reader, args ...string) (map[string]interface{}, int) {
+	t.Helper()
+
+	// Flags keep their values between executions, so they are reset first
+	for _, flags := range []*pflag.FlagSet{ingestCmd.Flags(), rootCmd.PersistentFlags()} {
+		flags.VisitAll(func(f *pflag.Flag) {
+			if slice, ok := f.Value.(pflag.SliceValue); ok {
+				slice.Replace(nil)
+			} else {
+				f.Value.Set(f.DefValue)
+			}
+			f.Changed = false
+		})
+	}
+	// The storage flags are passed on through the environment, restored when the test ends
+	for _, key := range []string{"STORAGE_TYPE", "LOCAL_STORAGE_PATH", "STORAGE_COLLECTION"} {
+		t.Setenv(key, "")
+	}
+	prev := stdin
+	stdin = input
+	defer func() { stdin = prev }()
+
+	statsPath := filepath.Join(t.TempDir(), "stats.json")
+	rootCmd.SetArgs(append([]string{"ingest", "-", "-e", "hash", "--stats-format", "json", "--stats-out", statsPath}, args...))
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(statsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats map[string]interface{}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		t.Fatal(err)
+	}
+	unread, _ := io.Copy(io.Discard, input)
+	return stats, int(unread)
+}
+
+// storedMetadata returns the metadata of the vectors stored in the local storage of dir
+func storedMetadata(t *testing.T, dir string) []map[string]string {
+	t.Helper()
+	adapter, err := local.NewVectorStorageAdapter(dir, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adapter.Close()
+	vectors, err := adapter.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := make([]map[string]string, len(vectors))
+	for i, vector := range vectors {
+		metadata[i] = vector.Metadata
+	}
+	return metadata
+}
+
+func TestIngest_Stdin(t *testing.T) {
+	open := func(path string) *os.File {
+		file, err := os.Open(filepath.Join("..", "..", "..", "internal", "ingestion", "testdata", path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { file.Close() })
+		return file
+	}
+
+	// JSONL is detected, and the where filters and namespace apply as to files
+	dir := t.TempDir()
+	stats, _ := runIngestStdin(t, open("json/reviews.jsonl"), "--local", dir, "-n", "reviews", "--where", "label=pos")
+	stored := storedMetadata(t, dir)
+	if stats["succeeded"] != float64(len(stored)) || len(stored) == 0 {
+		t.Fatalf("stats %v, stored %d vectors", stats, len(stored))
+	}
+	for _, metadata := range stored {
+		if metadata["label"] != "pos" || metadata["namespace"] != "reviews" {
+			t.Errorf("vector stored with metadata %v", metadata)
+		}
+	}
+
+	// CSV is detected too, with its delimiter and text column
+	dir = t.TempDir()
+	runIngestStdin(t, open("csv/semicolon.csv"), "--local", dir)
+	authors := make(map[string]bool)
+	for _, metadata := range storedMetadata(t, dir) {
+		authors[metadata["author"]] = true
+	}
+	if len(authors) != 2 || !authors["Goethe; J. W."] {
+		t.Errorf("CSV from stdin stored the authors %v", authors)
+	}
+
+	// --format overrides the detection
+	dir = t.TempDir()
+	stats, _ = runIngestStdin(t, strings.NewReader("{body},id\n{a quote in braces},1\n"), "--local", dir, "--format", "csv", "--text-col", "{body}")
+	if stored := storedMetadata(t, dir); len(stored) != 1 || stored[0]["id"] != "1" {
+		t.Errorf("--format csv stored %v, stats %v", stored, stats)
+	}
+
+	// --storage and --storage-path select the storage as STORAGE_TYPE and LOCAL_STORAGE_PATH would
+	dir = t.TempDir()
+	stats, _ = runIngestStdin(t, open("csv/semicolon.csv"), "--storage", "local", "--storage-path", dir)
+	if stats["storage"] != "local" || len(storedMetadata(t, dir)) == 0 {
+		t.Errorf("--storage local stored %d vectors, stats %v", len(storedMetadata(t, dir)), stats)
+	}
+
+	// A dry run consumes and validates the whole stream, storing nothing
+	dir = t.TempDir()
+	stats, unread := runIngestStdin(t, open("json/reviews.jsonl"), "--local", dir, "--dry-run")
+	if unread != 0 || stats["total"] != float64(7) || len(storedMetadata(t, dir)) != 0 {
+		t.Errorf("dry run left %d bytes, stats %v", unread, stats)
+	}
+}
+
+func TestIngest_LocalTFIDFServe(t *testing.T) {
+	quotes := func(texts ...string) io.Reader {
+		var b strings.Builder
+		for i, text := range texts {
+			line, _ := json.Marshal(map[string]string{"text": text, "quote": strings.Fields(text)[0] + strconv.Itoa(i)})
+			b.Write(append(line, '\n'))
+		}
+		return strings.NewReader(b.String())
+	}
+
+	// The vocabulary is fitted on the first batch and embeds the records of the next ones
+	dir := t.TempDir()
+	stats, _ := runIngestStdin(t, quotes(
+		"Wisdom begins in wonder.",
+		"Know thyself.",
+		"The unexamined life is not worth living.",
+		"Courage is knowing what not to fear.",
+		"Happiness depends upon ourselves.",
+		"Patience is bitter, but its fruit is sweet.",
+		"Wonder is the feeling of a philosopher.",
+	), "-e", "local", "--local", dir, "--collection", "quotes", "--batch-size", "3")
+	if stats["succeeded"] != float64(7) {
+		t.Fatalf("stats %v", stats)
+	}
+
+	// A later run embeds with the saved vocabulary
+	runIngestStdin(t, quotes("Only wonder and courage remain."), "-e", "local", "--local", dir, "--collection", "quotes")
+
+	// serve loads the vocabulary with the storage, so its queries match the ingested vectors
+	t.Setenv("STORAGE_TYPE", "local")
+	t.Setenv("LOCAL_STORAGE_PATH", dir)
+	t.Setenv("STORAGE_COLLECTION", "quotes")
+	t.Setenv("EMBEDDER_TYPE", "local")
+	srv, err := server.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", strings.NewReader(`{"text": "wonder", "top_K": 3}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Matches []struct {
+			Vector struct {
+				Metadata map[string]string `json:"metadata"`
+			} `json:"vector"`
+			Score float64 `json:"score"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, match := range resp.Matches {
+		if match.Score > 0.5 {
+			found[match.Vector.Metadata["quote"]] = true
+		}
+	}
+	if !found["Wisdom0"] || !found["Wonder6"] || !found["Only0"] {
+		t.Errorf("search for wonder matched %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/analysis"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+var (
+	// k-NN graph flags
+	graphK         int
+	graphOut       string
+	graphFormat    string
+	graphThreshold float64
+	graphMetric    string
+	graphFilters   string
+	graphSample    int
+	graphSeed      int64
+)
+
+func init() {
+	rootCmd.AddCommand(knnGraphCmd)
+
+	knnGraphCmd.Flags().IntVarP(&graphK, "k", "k", analysis.DefaultGraphK, "Number of neighbors per vector")
+	knnGraphCmd.Flags().StringVarP(&graphOut, "out", "o", "", "File to write the graph to (default stdout)")
+	knnGraphCmd.Flags().StringVar(&graphFormat, "format", "", "Output format, jsonl or graphml (default from the --out extension, else jsonl)")
+	knnGraphCmd.Flags().Float64Var(&graphThreshold, "threshold", 0, "Minimum similarity of an edge, maximum distance with the euclidean metric (default: keep the k best)")
+	knnGraphCmd.Flags().StringVar(&graphMetric, "metric", "", "Similarity metric (cosine, euclidean, dot), default the collection metric with --local, else cosine")
+	knnGraphCmd.Flags().StringVar(&graphFilters, "filters", "", `Metadata filters as JSON, e.g. '{"lang":{"eq":"en"}}'`)
+	knnGraphCmd.Flags().IntVar(&graphSample, "sample", 0, "Build the graph over at most this many randomly picked vectors (default all)")
+	knnGraphCmd.Flags().Int64Var(&graphSeed, "seed", 0, "Seed of the --sample pick")
+	addTargetFlags(knnGraphCmd)
+}
+
+var knnGraphCmd = &cobra.Command{
+	Use:   "knn-graph",
+	Short: "Export the k nearest neighbors of every vector as a graph",
+	Long: `Compute the k nearest neighbors of every vector of a namespace and write
+them as edges (source_id, target_id, score) for graph tooling such as Gephi or
+community detection.
+
+Neighbors are found by brute force, in blocks scored in parallel, so the work
+grows with the square of the vector count: 200k vectors take n(n-1) = 4e10
+comparisons, about 3 hours on one core at 384 dimensions. Use --sample to bound
+it on large stores. With --local progress and an estimate of the remaining time
+are reported on stderr.`,
+	Example: `  # JSONL edges of a local collection
+  same-same knn-graph --k 10 --out graph.jsonl --local ./data/storage
+
+  # GraphML from a running server, edges above 0.7 only
+  same-same knn-graph --k 5 --threshold 0.7 --out graph.graphml --server http://localhost:8080
+
+  # A 20k vector sample of a large namespace
+  same-same knn-graph -n support --sample 20000 --out sample.jsonl --local ./data/storage`,
+	Args: cobra.NoArgs,
+	Run:  runKNNGraph,
+}
+
+func runKNNGraph(cmd *cobra.Command, args []string) {
+	format := graphFormat
+	if format == "" {
+		format = analysis.GraphJSONL
+		if strings.EqualFold(filepath.Ext(graphOut), ".graphml") {
+			format = analysis.GraphGraphML
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if graphOut != "" {
+		file, err := os.Create(graphOut)
+		if err != nil {
+			log.Fatalf("Failed to create graph file: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	opts := &analysis.GraphOptions{
+		K:         graphK,
+		Namespace: namespace,
+		Metric:    graphMetric,
+		Sample:    graphSample,
+		Seed:      graphSeed,
+	}
+	if cmd.Flags().Changed("threshold") {
+		opts.Threshold = &graphThreshold
+	}
+	if graphFilters != "" {
+		if err := json.Unmarshal([]byte(graphFilters), &opts.Filters); err != nil {
+			log.Fatalf("Invalid --filters: %v", err)
+		}
+	}
+
+	if err := opts.Validate(); err != nil {
+		log.Fatalf("Invalid graph options: %v", err)
+	}
+
+	switch {
+	case serverURL != "" && localPath != "":
+		log.Fatal("--server and --local are mutually exclusive")
+
+	case serverURL != "":
+		query := url.Values{
+			"k":         {strconv.Itoa(opts.K)},
+			"namespace": {opts.Namespace},
+			"format":    {format},
+			"sample":    {strconv.Itoa(opts.Sample)},
+			"seed":      {strconv.FormatInt(opts.Seed, 10)},
+		}
+		if graphMetric != "" {
+			query.Set("metric", graphMetric)
+		}
+		if opts.Threshold != nil {
+			query.Set("threshold", strconv.FormatFloat(*opts.Threshold, 'g', -1, 64))
+		}
+		if graphFilters != "" {
+			query.Set("filters", graphFilters)
+		}
+
+		body, err := adminRequest(http.MethodGet, "/api/v1/admin/knn-graph", query, nil)
+		if err != nil {
+			log.Fatalf("Graph export failed: %v", err)
+		}
+		defer body.Close()
+		if _, err := io.Copy(out, body); err != nil {
+			log.Fatalf("Failed to write graph: %v", err)
+		}
+
+	case localPath != "":
+		adapter, err := local.NewVectorStorageAdapter(localPath, localCollection)
+		if err != nil {
+			log.Fatalf("Failed to open local storage: %v", err)
+		}
+		defer adapter.Close()
+		if graphMetric == "" && adapter.VectorConfig().Metric != "" {
+			opts.Metric = adapter.VectorConfig().Metric
+		}
+
+		stored, err := adapter.ListByNamespace(opts.Namespace)
+		if err != nil {
+			log.Fatalf("Failed to list vectors: %v", err)
+		}
+		vectors, err := analysis.GraphVectors(stored, opts)
+		if err != nil {
+			log.Fatalf("Invalid graph options: %v", err)
+		}
+		writeLocalGraph(out, format, vectors, opts)
+
+	default:
+		log.Fatal("either --server or --local is required")
+	}
+
+	if graphOut != "" {
+		fmt.Fprintf(os.Stderr, "k-NN graph written to: %s\n", graphOut)
+	}
+}
+
+// writeLocalGraph computes the graph of vectors, reporting progress on stderr
+func writeLocalGraph(out io.Writer, format string, vectors []*models.Vector, opts *analysis.GraphOptions) {
+	graph, err := analysis.NewGraphWriter(out, format, vectors)
+	if err != nil {
+		log.Fatalf("Failed to write graph: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Computing %d nearest neighbors of %d vectors (%d comparisons)\n",
+		opts.K, len(vectors), analysis.GraphComparisons(len(vectors)))
+
+	start := time.Now()
+	opts.Progress = func(done, total int) {
+		elapsed := time.Since(start)
+		remaining := time.Duration(float64(elapsed) / float64(done) * float64(total-done))
+		fmt.Fprintf(os.Stderr, "\r  %d/%d vectors, %s elapsed, about %s remaining   ",
+			done, total, elapsed.Round(time.Second), remaining.Round(time.Second))
+	}
+
+	edges := 0
+	err = analysis.KNNGraph(vectors, opts, func(edge analysis.Edge) error {
+		edges++
+		return graph.WriteEdge(edge)
+	})
+	if err == nil {
+		err = graph.Close()
+	}
+	if len(vectors) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write graph: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "%d edges in %s\n", edges, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/handlers"
+	"github.com/tahcohcat/same-same/internal/ingestion"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+// Manifest verify flags
+var (
+	manifestSample int
+	manifestSeed   int64
+	manifestJSON   bool
+)
+
+func init() {
+	rootCmd.AddCommand(manifestCmd)
+	manifestCmd.AddCommand(manifestVerifyCmd)
+
+	manifestVerifyCmd.Flags().IntVar(&manifestSample, "sample", 0, "Number of the manifest IDs looked up (default all of them)")
+	manifestVerifyCmd.Flags().Int64Var(&manifestSeed, "seed", 0, "Seed of the sample, to repeat a run")
+	manifestVerifyCmd.Flags().BoolVar(&manifestJSON, "json", false, "Print the report as JSON")
+	addTargetFlags(manifestVerifyCmd)
+}
+
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Work with the manifests of ingest runs",
+	Long: `Ingest manifests, written by 'same-same ingest --manifest', describe what a
+run produced: its source and parameters, embedder and dimension, storage, record
+counts by outcome, the first, last and a sample of the vector IDs stored, a hash
+of the configuration and the schema of the metadata fields seen.`,
+}
+
+var manifestVerifyCmd = &cobra.Command{
+	Use:   "verify <manifest>",
+	Short: "Check a store still holds the vectors of an ingest run",
+	Long: `Look up the vector IDs an ingest manifest records in a store. The command
+exits with status 1 when any of them is missing. Vectors last written by another
+run, such as a re-ingest updating them by unique key, are reported but pass.
+
+The store is the local storage of --local, or the running server of --server.`,
+	Example: `  # Check a local store after copying it
+  same-same manifest verify run-manifest.json --local ./data/storage
+
+  # Check 20 of the IDs against a running server
+  same-same manifest verify run-manifest.json --sample 20 --server http://localhost:8080`,
+	Args: cobra.ExactArgs(1),
+	Run:  runManifestVerify,
+}
+
+func runManifestVerify(cmd *cobra.Command, args []string) {
+	manifest, err := ingestion.LoadManifest(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if manifest.DryRun {
+		log.Fatalf("%s is the manifest of a dry run, nothing was stored", args[0])
+	}
+
+	var getMany func(ids []string) ([]*models.Vector, error)
+	switch {
+	case serverURL != "" && localPath != "":
+		log.Fatal("--server and --local are mutually exclusive")
+
+	case serverURL != "":
+		getMany = serverGetMany
+
+	case localPath != "":
+		adapter, err := local.NewVectorStorageAdapter(localPath, localCollection)
+		if err != nil {
+			log.Fatalf("Failed to open storage: %v", err)
+		}
+		defer adapter.Close()
+		getMany = adapter.GetMany
+
+	default:
+		log.Fatal("either --server or --local is required")
+	}
+
+	report, err := manifest.Verify(getMany, manifestSample, manifestSeed)
+	if err != nil {
+		log.Fatalf("Verify failed: %v", err)
+	}
+
+	if manifestJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		fmt.Printf("Run %s: %d of %d vectors found\n", report.RunID, report.Found, report.Checked)
+		for _, id := range report.Missing {
+			fmt.Printf("  missing  %s\n", id)
+		}
+		for _, id := range report.Replaced {
+			fmt.Printf("  replaced %s (written by a later run)\n", id)
+		}
+	}
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+// serverGetMany looks up vectors with the bulk get endpoint of the server, without
+// their embeddings. Requests are split to stay within the default bulk get limit
+func serverGetMany(ids []string) ([]*models.Vector, error) {
+	found := make(map[string]*models.Vector, len(ids))
+	for start := 0; start < len(ids); start += handlers.DefaultBulkGetLimit {
+		chunk := ids[start:min(start+handlers.DefaultBulkGetLimit, len(ids))]
+		include := false
+		body, err := json.Marshal(handlers.BulkGetRequest{IDs: chunk, IncludeEmbedding: &include})
+		if err != nil {
+			return nil, err
+		}
+		resp, err := adminRequest(http.MethodPost, "/api/v1/vectors/bulk-get", nil, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		var result handlers.BulkGetResponse
+		err = json.NewDecoder(resp).Decode(&result)
+		resp.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode server response: %w", err)
+		}
+		for _, vector := range result.Vectors {
+			found[vector.ID] = vector
+		}
+	}
+
+	vectors := make([]*models.Vector, len(ids))
+	for i, id := range ids {
+		vectors[i] = found[id]
+	}
+	return vectors, nil
+}
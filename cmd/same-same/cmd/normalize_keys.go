@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+// Normalize-keys flags
+var keyMergePolicy string
+
+func init() {
+	rootCmd.AddCommand(normalizeKeysCmd)
+
+	normalizeKeysCmd.Flags().StringVar(&keyMergePolicy, "merge", string(models.KeyMergeError), "What to do with keys that normalize to the same key with different values (normalized, skip, error)")
+	normalizeKeysCmd.Flags().StringVar(&localPath, "local", "", "Path of a local file storage directory (required)")
+	normalizeKeysCmd.Flags().StringVar(&localCollection, "collection", "default", "Collection name")
+	normalizeKeysCmd.MarkFlagRequired("local")
+}
+
+var normalizeKeysCmd = &cobra.Command{
+	Use:   "normalize-keys",
+	Short: "Rewrite stored metadata keys to lowercase snake_case",
+	Long: `Normalize the metadata keys of every vector in a collection, so "Author",
+"AUTHOR" and "author" all become "author" and "createdAt" becomes "created_at".
+
+A vector can hold several keys that normalize to the same key, such as "Author"
+and "author". When their values are equal they are merged. When they differ the
+--merge policy decides:
+
+  error       Change nothing and list the conflicts (default)
+  normalized  Keep the value of the key already in normalized form, or else of
+              the first key in sort order
+  skip        Leave the conflicting keys of that vector as they are
+
+Run with --dry-run first to see the keys that would be renamed and every collision.`,
+	Example: `  # Report renames and collisions without changing anything
+  same-same normalize-keys --dry-run --local ./data/storage
+
+  # Normalize, keeping the lowercase value where keys conflict
+  same-same normalize-keys --merge normalized --local ./data/storage`,
+	Args: cobra.NoArgs,
+	Run:  runNormalizeKeys,
+}
+
+func runNormalizeKeys(cmd *cobra.Command, args []string) {
+	storage, err := local.NewLocalStorage(localPath)
+	if err != nil {
+		log.Fatalf("Failed to open local storage: %v", err)
+	}
+	defer storage.Close()
+
+	report, err := storage.NormalizeMetadataKeys(localCollection, models.KeyMergePolicy(keyMergePolicy), dryRun)
+	if report != nil {
+		printKeyNormalizationReport(report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Normalizing keys failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printKeyNormalizationReport(report *local.KeyNormalizationReport) {
+	if report.DryRun {
+		fmt.Println("DRY RUN MODE - nothing was changed")
+	}
+	fmt.Printf("Scanned %d vectors in %q, %d with keys to normalize\n", report.Scanned, report.Collection, len(report.Updated))
+
+	if len(report.Renamed) > 0 {
+		keys := make([]string, 0, len(report.Renamed))
+		for key := range report.Renamed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Println("\nRenamed keys:")
+		for _, key := range keys {
+			fmt.Printf("  %s -> %s\n", key, report.Renamed[key])
+		}
+	}
+
+	if len(report.Collisions) > 0 {
+		fmt.Printf("\nCollisions (%d conflicting):\n", report.Conflicts())
+		for _, collision := range report.Collisions {
+			outcome := "merged, equal values"
+			switch {
+			case collision.Conflicting && collision.Kept == "":
+				outcome = "conflicting, left unchanged"
+			case collision.Conflicting && report.Policy == models.KeyMergeError:
+				outcome = "conflicting"
+			case collision.Conflicting:
+				outcome = fmt.Sprintf("conflicting, kept %s", collision.Kept)
+			}
+			fmt.Printf("  %s: %s -> %s (%s)\n", collision.ID, strings.Join(collision.Keys, ", "), collision.Key, outcome)
+		}
+	}
+}
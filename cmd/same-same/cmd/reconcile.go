@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+var reconcilePrune bool
+
+func init() {
+	rootCmd.AddCommand(reconcileCmd)
+
+	reconcileCmd.Flags().BoolVar(&reconcilePrune, "prune", false, "Remove index entries whose document file is missing")
+	addTargetFlags(reconcileCmd)
+}
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Re-sync the storage index with the files on disk",
+	Long: `Re-scan the collections and embeddings directories of a local store and
+bring its index in line with them.
+
+Documents found on disk but missing from the index are registered, index
+entries whose document file is gone are reported (and removed with --prune),
+and the collection stats are recomputed. Files that cannot be registered, such
+as unreadable documents or documents without their embedding file, are
+reported as conflicts.
+
+With --local every collection is reconciled unless --collection is given.`,
+	Example: `  # Register documents copied into the collections directory by hand
+  same-same reconcile --local ./data/storage
+
+  # Show what would change, including entries that would be pruned
+  same-same reconcile --dry-run --prune --local ./data/storage
+
+  # Reconcile the store of a running server
+  same-same reconcile --prune --server http://localhost:8080`,
+	Args: cobra.NoArgs,
+	Run:  runReconcile,
+}
+
+func runReconcile(cmd *cobra.Command, args []string) {
+	var report *local.ReconcileReport
+
+	switch {
+	case serverURL != "" && localPath != "":
+		log.Fatal("--server and --local are mutually exclusive")
+
+	case serverURL != "":
+		query := url.Values{
+			"prune":   {strconv.FormatBool(reconcilePrune)},
+			"dry_run": {strconv.FormatBool(dryRun)},
+		}
+		body, err := adminRequest(http.MethodPost, "/api/v1/admin/reconcile", query, nil)
+		if err != nil {
+			log.Fatalf("Reconcile failed: %v", err)
+		}
+		defer body.Close()
+
+		report = &local.ReconcileReport{}
+		if err := json.NewDecoder(body).Decode(report); err != nil {
+			log.Fatalf("Failed to decode server response: %v", err)
+		}
+
+	case localPath != "":
+		storage, err := local.NewLocalStorage(localPath)
+		if err != nil {
+			log.Fatalf("Failed to open local storage: %v", err)
+		}
+		defer storage.Close()
+
+		opts := local.ReconcileOptions{Prune: reconcilePrune, DryRun: dryRun}
+		if cmd.Flags().Changed("collection") {
+			opts.Collections = []string{localCollection}
+		}
+
+		report, err = storage.Reconcile(opts)
+		if err != nil {
+			log.Fatalf("Reconcile failed: %v", err)
+		}
+
+	default:
+		log.Fatal("either --server or --local is required")
+	}
+
+	printReconcileReport(report)
+}
+
+func printReconcileReport(report *local.ReconcileReport) {
+	if report.DryRun {
+		fmt.Println("DRY RUN MODE - nothing was changed")
+	}
+
+	for _, collection := range report.Collections {
+		fmt.Printf("Collection %s: %d files scanned, %d documents\n", collection.Collection, collection.Scanned, collection.DocumentCount)
+		if collection.Created {
+			fmt.Println("  registered collection found on disk")
+		}
+		printReconcileIDs("added", collection.Added)
+		printReconcileIDs("missing", collection.Missing)
+		printReconcileIDs("pruned", collection.Pruned)
+		for _, conflict := range collection.Conflicts {
+			fmt.Printf("  conflict: %s %s: %s\n", conflict.File, conflict.ID, conflict.Reason)
+		}
+	}
+
+	fmt.Printf("\nAdded: %d, missing: %d, pruned: %d, conflicts: %d (%s)\n",
+		report.Added, report.Missing, report.Pruned, report.Conflicts, report.Duration)
+	if report.Missing > report.Pruned && !reconcilePrune {
+		fmt.Println("Run with --prune to remove the missing entries from the index")
+	}
+}
+
+func printReconcileIDs(label string, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	if !verbose && len(ids) > 10 {
+		fmt.Printf("  %s: %d documents (use --verbose to list them)\n", label, len(ids))
+		return
+	}
+	for _, id := range ids {
+		fmt.Printf("  %s: %s\n", label, id)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/version"
 )
 
 var (
@@ -22,7 +23,7 @@ vectors using cosine similarity, with built-in embedding generation for text.
 
 Designed and optimized for quick prototyping and exploration of the vector 
 space with minimal setup requirements.`,
-	Version: "0.1.0",
+	Version: version.Get().Version,
 }
 
 // Execute runs the root command
@@ -1,20 +1,25 @@
 package cmd
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/tahcohcat/same-same/internal/server"
+	"github.com/tahcohcat/same-same/internal/tracing"
 )
 
 var (
 	// Serve-specific flags
 	addr  string
 	debug bool
+
+	serveQuarantine bool
 )
 
 func init() {
@@ -23,6 +28,7 @@ func init() {
 	// Serve flags
 	serveCmd.Flags().StringVarP(&addr, "addr", "a", ":8080", "HTTP service address")
 	serveCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	serveCmd.Flags().BoolVar(&serveQuarantine, "quarantine", false, "Quarantine the vectors of batches that fail validation instead of rejecting the batch (same as QUARANTINE=true)")
 }
 
 var serveCmd = &cobra.Command{
@@ -37,7 +43,8 @@ The server provides a RESTful API for:
   - Metadata filtering and advanced search
 
 By default, the server uses in-memory storage and a local TF-IDF embedder.
-You can configure different embedders using environment variables.`,
+You can configure different embedders using environment variables.
+Send SIGHUP to reload the settings of the config file that can change at runtime.`,
 	Example: `  # Start server on default port 8080
   same-same serve
 
@@ -61,8 +68,21 @@ func runServe(cmd *cobra.Command, args []string) {
 		logrus.Debug("debug logging enabled")
 	}
 
+	// Traces are exported when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Init(context.Background(), "same-same")
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+
+	if serveQuarantine {
+		os.Setenv("QUARANTINE", "true")
+	}
+
 	// Create and start server
-	srv := server.NewServer()
+	srv, err := server.NewServer()
+	if err != nil {
+		log.Fatalf("failed to create server: %v", err)
+	}
 
 	go func() {
 		log.Printf("vector database microservice starting on %s", addr)
@@ -71,10 +91,28 @@ func runServe(cmd *cobra.Command, args []string) {
 		}
 	}()
 
+	// SIGHUP reloads the config file, see Server.Reload
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			srv.Reload()
+		}
+	}()
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Println("shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("failed to flush traces: %v", err)
+	}
+	if err := srv.Close(); err != nil {
+		log.Printf("failed to close storage: %v", err)
+	}
 }
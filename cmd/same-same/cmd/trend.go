@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tahcohcat/same-same/internal/analysis"
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+var (
+	// Trend flags
+	trendField     string
+	trendBucket    string
+	trendFrom      string
+	trendTo        string
+	trendThreshold float64
+)
+
+// trendBarWidth is the width of the longest bar in the trend chart
+const trendBarWidth = 40
+
+func init() {
+	rootCmd.AddCommand(trendCmd)
+
+	trendCmd.Flags().StringVar(&localPath, "local", "", "Path of a local file storage directory (required)")
+	trendCmd.Flags().StringVar(&localCollection, "collection", "default", "Collection name")
+	trendCmd.Flags().StringVarP(&embedderType, "embedder", "e", "", "Embedder type, must match the one used at ingestion (local, hash, gemini, huggingface, openai, ollama, clip)")
+	trendCmd.Flags().StringVar(&trendField, "field", "created_at", "Metadata field holding the document time")
+	trendCmd.Flags().StringVar(&trendBucket, "bucket", "day", "Bucket size (day, week, month)")
+	trendCmd.Flags().StringVar(&trendFrom, "from", "", "Start of the range, inclusive (RFC3339 or YYYY-MM-DD)")
+	trendCmd.Flags().StringVar(&trendTo, "to", "", "End of the range, exclusive (RFC3339 or YYYY-MM-DD)")
+	trendCmd.Flags().Float64Var(&trendThreshold, "threshold", 0, "Minimum similarity for a document to be counted (default: count all)")
+	trendCmd.MarkFlagRequired("local")
+}
+
+var trendCmd = &cobra.Command{
+	Use:   "trend <query>",
+	Short: "Show how content similar to a query is spread over time",
+	Long: `Count the documents of a local collection similar to a query per day, week or month.
+
+Documents are bucketed on a time metadata field. Documents whose time is
+missing or cannot be parsed are reported separately as unknown.`,
+	Example: `  # Weekly volume of documents about outages since January
+  same-same trend "service outage" --local ./data/storage --field published_at --bucket week --from 2024-01-01 --threshold 0.4`,
+	Args: cobra.ExactArgs(1),
+	Run:  runTrend,
+}
+
+func runTrend(cmd *cobra.Command, args []string) {
+	req := &models.TrendRequest{
+		Query:     args[0],
+		TimeField: trendField,
+		Bucket:    models.TrendBucketSize(strings.ToLower(trendBucket)),
+		Namespace: namespace,
+	}
+	if cmd.Flags().Changed("threshold") {
+		req.Threshold = &trendThreshold
+	}
+
+	var err error
+	if req.From, err = parseTrendTime("from", trendFrom); err != nil {
+		log.Fatal(err)
+	}
+	if req.To, err = parseTrendTime("to", trendTo); err != nil {
+		log.Fatal(err)
+	}
+	if err := req.Validate(); err != nil {
+		log.Fatalf("Invalid trend request: %v", err)
+	}
+
+	embedder, err := createEmbedder(embedderType)
+	if err != nil {
+		log.Fatalf("Failed to create embedder: %v", err)
+	}
+
+	adapter, err := local.NewVectorStorageAdapter(localPath, localCollection)
+	if err != nil {
+		log.Fatalf("Failed to open local storage: %v", err)
+	}
+	defer adapter.Close()
+
+	embedding, err := embedders.EmbedQuery(embedder, req.Query)
+	if err != nil {
+		log.Fatalf("Failed to embed query: %v", err)
+	}
+
+	vectors, err := adapter.ListByNamespace(req.Namespace)
+	if err != nil {
+		log.Fatalf("Failed to list vectors: %v", err)
+	}
+
+	resp, err := analysis.Trend(vectors, embedding, req)
+	if err != nil {
+		log.Fatalf("Trend failed: %v", err)
+	}
+
+	printTrend(resp)
+}
+
+func parseTrendTime(flag, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := models.ParseTime(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %v", flag, err)
+	}
+	return &t, nil
+}
+
+func printTrend(resp *models.TrendResponse) {
+	layout := "2006-01-02"
+	if resp.Bucket == models.BucketMonth {
+		layout = "2006-01"
+	}
+
+	max := 0
+	for _, bucket := range resp.Buckets {
+		if bucket.Count > max {
+			max = bucket.Count
+		}
+	}
+
+	fmt.Printf("Trend of %q by %s on %s\n\n", resp.Query, resp.Bucket, resp.TimeField)
+	for _, bucket := range resp.Buckets {
+		bar := ""
+		if max > 0 {
+			bar = strings.Repeat("#", bucket.Count*trendBarWidth/max)
+		}
+		mean := "-"
+		if bucket.Count > 0 {
+			mean = fmt.Sprintf("%.3f", bucket.MeanScore)
+		}
+		fmt.Printf("%-10s %6d  %6s  %s\n", bucket.Start.Format(layout), bucket.Count, mean, bar)
+	}
+
+	fmt.Printf("\nTotal: %d", resp.Total)
+	if resp.Unknown.Count > 0 {
+		fmt.Printf(" (%d without a parsable %s, mean score %.3f)", resp.Unknown.Count, resp.TimeField, resp.Unknown.MeanScore)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,270 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// DefaultDiffTolerance is the largest difference between embedding values still
+// considered equal, absorbing float rounding from serialization round trips
+const DefaultDiffTolerance = 1e-6
+
+// Kinds of difference between two stores
+const (
+	DiffOnlyInA   = "only_in_a"
+	DiffOnlyInB   = "only_in_b"
+	DiffDifferent = "different"
+)
+
+// VectorIterator yields vectors in ascending ID order, returning io.EOF after the last
+type VectorIterator interface {
+	Next() (*models.Vector, error)
+}
+
+// sliceIterator iterates over vectors sorted by ID
+type sliceIterator struct {
+	vectors []*models.Vector
+}
+
+// SortedVectors returns an iterator over vectors in ID order, sorting them in place
+func SortedVectors(vectors []*models.Vector) VectorIterator {
+	sort.Slice(vectors, func(i, j int) bool { return vectors[i].ID < vectors[j].ID })
+	return &sliceIterator{vectors: vectors}
+}
+
+func (it *sliceIterator) Next() (*models.Vector, error) {
+	if len(it.vectors) == 0 {
+		return nil, io.EOF
+	}
+	vector := it.vectors[0]
+	it.vectors = it.vectors[1:]
+	return vector, nil
+}
+
+// pagedIterator fetches the vectors of sorted IDs a page at a time
+type pagedIterator struct {
+	ids      []string
+	pageSize int
+	fetch    func(ids []string) ([]*models.Vector, error)
+	page     []*models.Vector
+}
+
+// PagedVectors returns an iterator over the vectors of ids, which must be sorted,
+// fetching pageSize of them at a time so only one page is held in memory.
+// fetch returns the vectors of its IDs in order, nil for those deleted meanwhile
+func PagedVectors(ids []string, pageSize int, fetch func(ids []string) ([]*models.Vector, error)) VectorIterator {
+	if pageSize <= 0 {
+		pageSize = 1
+	}
+	return &pagedIterator{ids: ids, pageSize: pageSize, fetch: fetch}
+}
+
+func (it *pagedIterator) Next() (*models.Vector, error) {
+	for len(it.page) == 0 {
+		if len(it.ids) == 0 {
+			return nil, io.EOF
+		}
+		n := it.pageSize
+		if n > len(it.ids) {
+			n = len(it.ids)
+		}
+		page, err := it.fetch(it.ids[:n])
+		if err != nil {
+			return nil, err
+		}
+		it.ids = it.ids[n:]
+		for _, vector := range page {
+			if vector != nil {
+				it.page = append(it.page, vector)
+			}
+		}
+	}
+	vector := it.page[0]
+	it.page = it.page[1:]
+	return vector, nil
+}
+
+// DiffSummary counts the differences between two stores
+// A vector whose metadata and embedding both differ counts in both
+type DiffSummary struct {
+	A                int `json:"a"` // Vectors read from each side
+	B                int `json:"b"`
+	OnlyInA          int `json:"only_in_a"`
+	OnlyInB          int `json:"only_in_b"`
+	MetadataDiffers  int `json:"metadata_differs"`
+	EmbeddingDiffers int `json:"embedding_differs"`
+	Identical        int `json:"identical"`
+}
+
+// Differences reports whether the stores differ at all
+func (s *DiffSummary) Differences() bool {
+	return s.OnlyInA+s.OnlyInB+s.MetadataDiffers+s.EmbeddingDiffers > 0
+}
+
+// FieldDiff is the value of a metadata field on each side, nil where it is missing
+type FieldDiff struct {
+	A *string `json:"a"`
+	B *string `json:"b"`
+}
+
+// VectorDiff describes how a vector differs between the stores
+type VectorDiff struct {
+	ID       string               `json:"id"`
+	Kind     string               `json:"kind"`
+	Metadata map[string]FieldDiff `json:"metadata,omitempty"` // Differing fields only
+	// Embedding is set when the embeddings differ, with the largest value difference
+	// unless their shapes differ and values cannot be compared
+	Embedding bool    `json:"embedding,omitempty"`
+	MaxDelta  float64 `json:"max_delta,omitempty"`
+}
+
+// Diff merge joins two iterators on ID, calling emit, if not nil, for every vector
+// missing from a side or differing between them. Only the current vector of each
+// side is held, so stores of any size are compared in constant memory
+func Diff(a, b VectorIterator, tolerance float64, emit func(*VectorDiff) error) (*DiffSummary, error) {
+	summary := &DiffSummary{}
+	left, err := nextInOrder(a, nil, "A")
+	if err != nil {
+		return nil, err
+	}
+	right, err := nextInOrder(b, nil, "B")
+	if err != nil {
+		return nil, err
+	}
+
+	for left != nil || right != nil {
+		var diff *VectorDiff
+		switch {
+		case right == nil || left != nil && left.ID < right.ID:
+			summary.A++
+			summary.OnlyInA++
+			diff = &VectorDiff{ID: left.ID, Kind: DiffOnlyInA}
+			if left, err = nextInOrder(a, left, "A"); err != nil {
+				return nil, err
+			}
+
+		case left == nil || right.ID < left.ID:
+			summary.B++
+			summary.OnlyInB++
+			diff = &VectorDiff{ID: right.ID, Kind: DiffOnlyInB}
+			if right, err = nextInOrder(b, right, "B"); err != nil {
+				return nil, err
+			}
+
+		default:
+			summary.A++
+			summary.B++
+			if diff = compareVectors(left, right, tolerance); diff == nil {
+				summary.Identical++
+			} else {
+				if len(diff.Metadata) > 0 {
+					summary.MetadataDiffers++
+				}
+				if diff.Embedding {
+					summary.EmbeddingDiffers++
+				}
+			}
+			if left, err = nextInOrder(a, left, "A"); err != nil {
+				return nil, err
+			}
+			if right, err = nextInOrder(b, right, "B"); err != nil {
+				return nil, err
+			}
+		}
+
+		if diff != nil && emit != nil {
+			if err := emit(diff); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return summary, nil
+}
+
+// nextInOrder returns the next vector of it, nil at the end, checking IDs ascend
+func nextInOrder(it VectorIterator, previous *models.Vector, side string) (*models.Vector, error) {
+	vector, err := it.Next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", side, err)
+	}
+	if previous != nil && vector.ID <= previous.ID {
+		return nil, fmt.Errorf("%s is not in ID order: %q follows %q", side, vector.ID, previous.ID)
+	}
+	return vector, nil
+}
+
+// compareVectors returns how two versions of a vector differ, nil when they do not
+func compareVectors(a, b *models.Vector, tolerance float64) *VectorDiff {
+	diff := &VectorDiff{ID: a.ID, Kind: DiffDifferent}
+	for key, value := range a.Metadata {
+		if other, ok := b.Metadata[key]; !ok || other != value {
+			diff.addField(key, a.Metadata, b.Metadata)
+		}
+	}
+	for key := range b.Metadata {
+		if _, ok := a.Metadata[key]; !ok {
+			diff.addField(key, a.Metadata, b.Metadata)
+		}
+	}
+
+	if delta, ok := embeddingDelta(a, b); !ok {
+		diff.Embedding = true
+	} else if delta > tolerance {
+		diff.Embedding = true
+		diff.MaxDelta = delta
+	}
+	if len(diff.Metadata) == 0 && !diff.Embedding {
+		return nil
+	}
+	return diff
+}
+
+func (d *VectorDiff) addField(key string, a, b map[string]string) {
+	if d.Metadata == nil {
+		d.Metadata = make(map[string]FieldDiff)
+	}
+	var field FieldDiff
+	if value, ok := a[key]; ok {
+		field.A = &value
+	}
+	if value, ok := b[key]; ok {
+		field.B = &value
+	}
+	d.Metadata[key] = field
+}
+
+// embeddingDelta returns the largest difference between the values of two embeddings
+// They are not comparable when one is dense and the other sparse or their lengths differ
+func embeddingDelta(a, b *models.Vector) (delta float64, ok bool) {
+	if (a.Sparse != nil) != (b.Sparse != nil) || a.Sparse == nil && len(a.Embedding) != len(b.Embedding) {
+		return 0, false
+	}
+
+	if a.Sparse != nil {
+		// Indices present on one side only compare against zero
+		values := make(map[int]float64, len(a.Sparse.Indices))
+		for i, index := range a.Sparse.Indices {
+			values[index] = a.Sparse.Values[i]
+		}
+		for i, index := range b.Sparse.Indices {
+			delta = math.Max(delta, math.Abs(values[index]-b.Sparse.Values[i]))
+			delete(values, index)
+		}
+		for _, value := range values {
+			delta = math.Max(delta, math.Abs(value))
+		}
+		return delta, true
+	}
+
+	for i := range a.Embedding {
+		delta = math.Max(delta, math.Abs(a.Embedding[i]-b.Embedding[i]))
+	}
+	return delta, true
+}
@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestDiff_EmbeddingShapes(t *testing.T) {
+	sparse := func(id string, indices []int, values []float64) *models.Vector {
+		return &models.Vector{ID: id, Sparse: &models.SparseVector{Indices: indices, Values: values}}
+	}
+	a := []*models.Vector{
+		sparse("reordered", []int{1, 4}, []float64{0.5, 0.25}),
+		sparse("dropped", []int{1, 4}, []float64{0.5, 0.25}),
+		{ID: "resized", Embedding: []float64{1, 0}},
+		{ID: "densified", Embedding: []float64{1, 0}},
+	}
+	b := []*models.Vector{
+		sparse("reordered", []int{4, 1}, []float64{0.25, 0.5}),
+		sparse("dropped", []int{1}, []float64{0.5}),
+		{ID: "resized", Embedding: []float64{1, 0, 0}},
+		sparse("densified", []int{0}, []float64{1}),
+	}
+
+	differing := make(map[string]float64)
+	summary, err := Diff(SortedVectors(a), SortedVectors(b), DefaultDiffTolerance, func(d *VectorDiff) error {
+		differing[d.ID] = d.MaxDelta
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Identical != 1 || summary.EmbeddingDiffers != 3 {
+		t.Errorf("summary = %+v", summary)
+	}
+	// A dropped sparse value differs by its whole value, shapes that cannot be compared by none
+	if delta, ok := differing["dropped"]; !ok || delta != 0.25 {
+		t.Errorf("dropped delta = %v, %v", delta, ok)
+	}
+	if _, ok := differing["resized"]; !ok {
+		t.Error("a resized embedding was not reported")
+	}
+}
+
+func TestDiff_RejectsUnorderedSide(t *testing.T) {
+	unordered := &sliceIterator{vectors: []*models.Vector{{ID: "b"}, {ID: "a"}}}
+	_, err := Diff(unordered, SortedVectors(nil), DefaultDiffTolerance, nil)
+	if err == nil || !strings.Contains(err.Error(), "not in ID order") {
+		t.Errorf("Diff() error = %v, want an ordering error", err)
+	}
+}
+
+func TestPagedVectors_SkipsDeleted(t *testing.T) {
+	fetches := 0
+	it := PagedVectors([]string{"a", "b", "c", "d", "e"}, 2, func(ids []string) ([]*models.Vector, error) {
+		fetches++
+		page := make([]*models.Vector, len(ids))
+		for i, id := range ids {
+			if id != "c" && id != "d" {
+				page[i] = &models.Vector{ID: id}
+			}
+		}
+		return page, nil
+	})
+
+	var ids []string
+	for {
+		vector, err := it.Next()
+		if err != nil {
+			break
+		}
+		ids = append(ids, vector.ID)
+	}
+	if strings.Join(ids, ",") != "a,b,e" || fetches != 3 {
+		t.Errorf("read %v in %d fetches, want a,b,e in 3", ids, fetches)
+	}
+}
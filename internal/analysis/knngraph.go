@@ -0,0 +1,310 @@
+package analysis
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+// DefaultGraphK is the number of neighbors per vector of graphs that set none
+const DefaultGraphK = 10
+
+// graphBlockSize is the number of source vectors a worker scores at a time
+const graphBlockSize = 256
+
+// Graph output formats
+const (
+	GraphJSONL   = "jsonl"
+	GraphGraphML = "graphml"
+)
+
+// Edge links a vector to one of its nearest neighbors
+type Edge struct {
+	Source string  `json:"source_id"`
+	Target string  `json:"target_id"`
+	Score  float64 `json:"score"`
+}
+
+// GraphOptions configures a k-NN graph
+type GraphOptions struct {
+	K int
+
+	// Threshold drops edges scoring worse: a minimum similarity, or a maximum
+	// distance with the euclidean metric. Nil keeps the k best neighbors
+	Threshold *float64
+
+	Namespace string
+	Filters   models.Filters
+
+	// Metric scores neighbors, cosine when empty
+	Metric string
+
+	// Sample caps the number of vectors in the graph, picked at random with Seed
+	// Neighbors are only searched among the sampled vectors. 0 keeps every vector
+	Sample int
+	Seed   int64
+
+	// Workers is the number of blocks scored in parallel, GOMAXPROCS when 0
+	Workers int
+
+	// Progress is called after each block of source vectors with the number done so far
+	Progress func(done, total int)
+}
+
+// Validate checks the options and fills in defaults
+func (o *GraphOptions) Validate() error {
+	if o.K == 0 {
+		o.K = DefaultGraphK
+	}
+	if o.K < 0 {
+		return fmt.Errorf("k cannot be negative")
+	}
+	if o.Metric == "" {
+		o.Metric = search.MetricCosine
+	}
+	if err := search.ValidateMetric(o.Metric); err != nil {
+		return err
+	}
+	if o.Sample < 0 {
+		return fmt.Errorf("sample cannot be negative")
+	}
+	if o.Workers <= 0 {
+		o.Workers = runtime.GOMAXPROCS(0)
+	}
+	return nil
+}
+
+// GraphVectors returns the vectors of a graph ordered by ID: embedded vectors of
+// the namespace matching the filters, sampled down to the cap. The vectors are
+// copies with their norms cached, so stored vectors are never written
+func GraphVectors(vectors []*models.Vector, opts *GraphOptions) ([]*models.Vector, error) {
+	evaluator := models.NewFilterEvaluator()
+	filters, err := evaluator.Compile(opts.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]*models.Vector, 0, len(vectors))
+	for _, vector := range vectors {
+		if !vector.HasEmbedding() || !search.MatchesNamespace(vector.Metadata, opts.Namespace) {
+			continue
+		}
+		if !evaluator.Matches(vector.Metadata, filters) {
+			continue
+		}
+		copied := *vector
+		copied.CacheNorm()
+		selected = append(selected, &copied)
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].ID < selected[j].ID })
+
+	if opts.Sample > 0 && len(selected) > opts.Sample {
+		rng := rand.New(rand.NewSource(opts.Seed))
+		rng.Shuffle(len(selected), func(i, j int) { selected[i], selected[j] = selected[j], selected[i] })
+		selected = selected[:opts.Sample]
+		sort.Slice(selected, func(i, j int) bool { return selected[i].ID < selected[j].ID })
+	}
+	return selected, nil
+}
+
+// GraphComparisons returns the number of pairs scored for a graph of n vectors,
+// which bounds the time of brute force computation
+func GraphComparisons(n int) int64 {
+	return int64(n) * int64(n-1)
+}
+
+// KNNGraph computes the k nearest neighbors of every vector by blocked brute force
+// Blocks of source vectors are scored against all vectors by a worker pool, and
+// edges are emitted in the order of vectors, best neighbor first. Vectors are never
+// their own neighbor, and only vectors with compatible embeddings are compared
+func KNNGraph(vectors []*models.Vector, opts *GraphOptions, emit func(Edge) error) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	n := len(vectors)
+	blocks := (n + graphBlockSize - 1) / graphBlockSize
+	results := make([]chan []Edge, blocks)
+	for b := range results {
+		results[b] = make(chan []Edge, 1)
+	}
+
+	// Workers run at most two blocks per worker ahead of the emitter, so memory
+	// stays bounded however slowly edges are written
+	ahead := make(chan struct{}, 2*opts.Workers)
+	jobs := make(chan int)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	go func() {
+		defer close(jobs)
+		for b := 0; b < blocks; b++ {
+			select {
+			case ahead <- struct{}{}:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- b:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				start := b * graphBlockSize
+				end := min(start+graphBlockSize, n)
+				results[b] <- scoreBlock(vectors, start, end, opts)
+			}
+		}()
+	}
+
+	var err error
+	for b := 0; b < blocks && err == nil; b++ {
+		for _, edge := range <-results[b] {
+			if err = emit(edge); err != nil {
+				break
+			}
+		}
+		<-ahead
+		if err == nil && opts.Progress != nil {
+			opts.Progress(min((b+1)*graphBlockSize, n), n)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	return err
+}
+
+// scoreBlock returns the edges of the source vectors from start to end
+func scoreBlock(vectors []*models.Vector, start, end int, opts *GraphOptions) []Edge {
+	ascending := search.Ascending(opts.Metric)
+	better := func(a, b Edge) bool {
+		if a.Score != b.Score {
+			return (a.Score < b.Score) == ascending
+		}
+		return a.Target < b.Target
+	}
+
+	edges := make([]Edge, 0, (end-start)*opts.K)
+	top := make([]Edge, 0, opts.K+1)
+	for i := start; i < end; i++ {
+		source := vectors[i]
+		top = top[:0]
+		for j, target := range vectors {
+			if i == j || !source.Compatible(target) {
+				continue
+			}
+			edge := Edge{Source: source.ID, Target: target.ID, Score: search.Score(opts.Metric, source, target)}
+			if opts.Threshold != nil && belowThreshold(edge.Score, *opts.Threshold, ascending) {
+				continue
+			}
+			if len(top) == opts.K && !better(edge, top[len(top)-1]) {
+				continue
+			}
+
+			// Insert in rank order, dropping the worst beyond k
+			pos := sort.Search(len(top), func(p int) bool { return better(edge, top[p]) })
+			top = append(top, Edge{})
+			copy(top[pos+1:], top[pos:])
+			top[pos] = edge
+			if len(top) > opts.K {
+				top = top[:opts.K]
+			}
+		}
+		edges = append(edges, top...)
+	}
+	return edges
+}
+
+// belowThreshold reports whether score ranks worse than threshold
+func belowThreshold(score, threshold float64, ascending bool) bool {
+	if ascending {
+		return score > threshold
+	}
+	return score < threshold
+}
+
+// GraphWriter writes the edges of a graph in an output format
+type GraphWriter interface {
+	WriteEdge(edge Edge) error
+	Close() error
+}
+
+// NewGraphWriter returns a writer of format to w for the graph of vectors
+// GraphML declares the nodes up front, so the vectors are written with the header
+func NewGraphWriter(w io.Writer, format string, vectors []*models.Vector) (GraphWriter, error) {
+	switch format {
+	case "", GraphJSONL:
+		return &jsonlGraphWriter{encoder: json.NewEncoder(w)}, nil
+	case GraphGraphML:
+		gw := &graphMLWriter{w: w}
+		return gw, gw.writeHeader(vectors)
+	default:
+		return nil, fmt.Errorf("unsupported graph format %q: must be %s or %s", format, GraphJSONL, GraphGraphML)
+	}
+}
+
+// jsonlGraphWriter writes one JSON edge per line
+type jsonlGraphWriter struct {
+	encoder *json.Encoder
+}
+
+func (gw *jsonlGraphWriter) WriteEdge(edge Edge) error {
+	return gw.encoder.Encode(edge)
+}
+
+func (gw *jsonlGraphWriter) Close() error {
+	return nil
+}
+
+// graphMLWriter writes a directed GraphML graph with the score as an edge attribute
+type graphMLWriter struct {
+	w io.Writer
+}
+
+func (gw *graphMLWriter) writeHeader(vectors []*models.Vector) error {
+	if _, err := io.WriteString(gw.w, xml.Header+
+		`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`+"\n"+
+		`  <key id="score" for="edge" attr.name="score" attr.type="double"/>`+"\n"+
+		`  <graph id="knn" edgedefault="directed">`+"\n"); err != nil {
+		return err
+	}
+	for _, vector := range vectors {
+		if _, err := fmt.Fprintf(gw.w, "    <node id=\"%s\"/>\n", escapeXML(vector.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gw *graphMLWriter) WriteEdge(edge Edge) error {
+	_, err := fmt.Fprintf(gw.w, "    <edge source=\"%s\" target=\"%s\"><data key=\"score\">%g</data></edge>\n",
+		escapeXML(edge.Source), escapeXML(edge.Target), edge.Score)
+	return err
+}
+
+func (gw *graphMLWriter) Close() error {
+	_, err := io.WriteString(gw.w, "  </graph>\n</graphml>\n")
+	return err
+}
+
+// escapeXML escapes s for use in an XML attribute
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+func randomVectors(n, dimension int) []*models.Vector {
+	rng := rand.New(rand.NewSource(1))
+	vectors := make([]*models.Vector, n)
+	for i := range vectors {
+		embedding := make([]float64, dimension)
+		for d := range embedding {
+			embedding[d] = rng.Float64()*2 - 1
+		}
+		vectors[i] = &models.Vector{ID: fmt.Sprintf("v%04d", i), Embedding: embedding}
+	}
+	return vectors
+}
+
+// naiveNeighbors scores every pair and sorts, the reference for KNNGraph
+func naiveNeighbors(vectors []*models.Vector, source int, k int, metric string) []Edge {
+	var edges []Edge
+	for j, target := range vectors {
+		if j != source {
+			edges = append(edges, Edge{vectors[source].ID, target.ID, search.Score(metric, vectors[source], target)})
+		}
+	}
+	ascending := search.Ascending(metric)
+	sort.Slice(edges, func(a, b int) bool {
+		if edges[a].Score != edges[b].Score {
+			return (edges[a].Score < edges[b].Score) == ascending
+		}
+		return edges[a].Target < edges[b].Target
+	})
+	return edges[:k]
+}
+
+func TestKNNGraph_MatchesBruteForce(t *testing.T) {
+	// More vectors than one block, so blocks are scored by several workers
+	stored := randomVectors(2*graphBlockSize+17, 8)
+
+	for _, metric := range []string{search.MetricCosine, search.MetricEuclidean} {
+		t.Run(metric, func(t *testing.T) {
+			opts := &GraphOptions{K: 3, Metric: metric, Workers: 4}
+			vectors, err := GraphVectors(stored, opts)
+			if err != nil {
+				t.Fatalf("graph vectors: %v", err)
+			}
+
+			progress := 0
+			opts.Progress = func(done, total int) { progress = done }
+			var edges []Edge
+			if err := KNNGraph(vectors, opts, func(e Edge) error { edges = append(edges, e); return nil }); err != nil {
+				t.Fatalf("knn graph: %v", err)
+			}
+			if progress != len(vectors) {
+				t.Errorf("progress ended at %d, want %d", progress, len(vectors))
+			}
+			if len(edges) != 3*len(vectors) {
+				t.Fatalf("got %d edges, want %d", len(edges), 3*len(vectors))
+			}
+			for i := range vectors {
+				want := naiveNeighbors(vectors, i, 3, metric)
+				for r, edge := range edges[3*i : 3*i+3] {
+					if edge != want[r] {
+						t.Fatalf("vector %d neighbor %d = %+v, want %+v", i, r, edge, want[r])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKNNGraph_ThresholdAndScope(t *testing.T) {
+	stored := []*models.Vector{
+		{ID: "a", Embedding: []float64{1, 0}, Metadata: map[string]string{"lang": "en"}},
+		{ID: "b", Embedding: []float64{0.9, 0.1}, Metadata: map[string]string{"lang": "en"}},
+		{ID: "c", Embedding: []float64{0, 1}, Metadata: map[string]string{"lang": "en"}},
+		{ID: "d", Embedding: []float64{1, 0}, Metadata: map[string]string{"lang": "fr"}},
+		{ID: "pending", Metadata: map[string]string{"lang": "en"}},
+	}
+
+	threshold := 0.5
+	opts := &GraphOptions{K: 5, Threshold: &threshold, Filters: models.Filters{"lang": {"eq": "en"}}}
+	vectors, err := GraphVectors(stored, opts)
+	if err != nil {
+		t.Fatalf("graph vectors: %v", err)
+	}
+	if len(vectors) != 3 {
+		t.Fatalf("got %d graph vectors, want a, b and c", len(vectors))
+	}
+
+	var got []string
+	KNNGraph(vectors, opts, func(e Edge) error { got = append(got, e.Source+"-"+e.Target); return nil })
+	if fmt.Sprint(got) != "[a-b b-a]" {
+		t.Errorf("edges = %v, want [a-b b-a]", got)
+	}
+
+	opts = &GraphOptions{Sample: 2, Seed: 7}
+	first, _ := GraphVectors(stored, opts)
+	second, _ := GraphVectors(stored, opts)
+	if len(first) != 2 || first[0].ID != second[0].ID || first[1].ID != second[1].ID {
+		t.Errorf("sample is not capped or not repeatable: %v, %v", first, second)
+	}
+}
+
+func TestGraphWriter_GraphML(t *testing.T) {
+	vectors := []*models.Vector{{ID: "a&b"}, {ID: "c"}}
+	var buf bytes.Buffer
+	graph, err := NewGraphWriter(&buf, GraphGraphML, vectors)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	graph.WriteEdge(Edge{Source: "a&b", Target: "c", Score: 0.5})
+	graph.Close()
+
+	var doc struct {
+		Graph struct {
+			Nodes []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Data   string `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid GraphML: %v\n%s", err, buf.String())
+	}
+	if len(doc.Graph.Nodes) != 2 || doc.Graph.Nodes[0].ID != "a&b" || len(doc.Graph.Edges) != 1 || doc.Graph.Edges[0].Data != "0.5" {
+		t.Errorf("GraphML = %s", buf.String())
+	}
+
+	if _, err := NewGraphWriter(&buf, "csv", nil); err == nil {
+		t.Error("unsupported format accepted")
+	}
+}
@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+// BucketStart truncates t (in UTC) to the start of its bucket
+func BucketStart(t time.Time, size models.TrendBucketSize) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch size {
+	case models.BucketWeek:
+		offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+		return day.AddDate(0, 0, -offset)
+	case models.BucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// nextBucket returns the start of the bucket following start
+func nextBucket(start time.Time, size models.TrendBucketSize) time.Time {
+	switch size {
+	case models.BucketWeek:
+		return start.AddDate(0, 0, 7)
+	case models.BucketMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Trend scores vectors against embedding and counts the matches per time bucket
+// Vectors are filtered by namespace, filters, threshold and time range like a search;
+// matches without a parsable time field are counted in Unknown, whatever the range
+// Empty buckets between the first and last bucket (or the requested range) are included
+func Trend(vectors []*models.Vector, embedding []float64, req *models.TrendRequest) (*models.TrendResponse, error) {
+	evaluator := models.NewFilterEvaluator()
+	filters, err := evaluator.Compile(req.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &models.Vector{Embedding: embedding}
+
+	counts := make(map[time.Time]int)
+	sums := make(map[time.Time]float64)
+	var first, last time.Time
+	unknownSum := 0.0
+
+	resp := &models.TrendResponse{
+		Query:     req.Query,
+		TimeField: req.TimeField,
+		Bucket:    req.Bucket,
+		Threshold: req.Threshold,
+	}
+
+	for _, vector := range vectors {
+		if len(vector.Embedding) != len(embedding) {
+			continue
+		}
+		if !search.MatchesNamespace(vector.Metadata, req.Namespace) || !evaluator.Matches(vector.Metadata, filters) {
+			continue
+		}
+
+		score := search.Score(search.MetricCosine, query, vector)
+		if req.Threshold != nil && score < *req.Threshold {
+			continue
+		}
+
+		t, source := models.DocumentTime(vector, req.TimeField, time.Time{})
+		if source != models.TimeSourceField {
+			resp.Unknown.Count++
+			unknownSum += score
+			resp.Total++
+			continue
+		}
+
+		if (req.From != nil && t.Before(*req.From)) || (req.To != nil && !t.Before(*req.To)) {
+			continue
+		}
+
+		start := BucketStart(t, req.Bucket)
+		if counts[start] == 0 {
+			if first.IsZero() || start.Before(first) {
+				first = start
+			}
+			if last.IsZero() || start.After(last) {
+				last = start
+			}
+		}
+		counts[start]++
+		sums[start] += score
+		resp.Total++
+	}
+
+	if resp.Unknown.Count > 0 {
+		resp.Unknown.MeanScore = unknownSum / float64(resp.Unknown.Count)
+	}
+
+	// The requested range decides which empty buckets are reported
+	if req.From != nil {
+		first = BucketStart(*req.From, req.Bucket)
+	}
+	if req.To != nil {
+		last = BucketStart(req.To.Add(-time.Nanosecond), req.Bucket)
+	}
+
+	resp.Buckets = []models.TrendBucket{}
+	if first.IsZero() || last.IsZero() {
+		return resp, nil
+	}
+
+	for start := first; !start.After(last); start = nextBucket(start, req.Bucket) {
+		if len(resp.Buckets) == models.MaxTrendBuckets {
+			return nil, fmt.Errorf("trend spans more than %d %s buckets, narrow the range or use a larger bucket", models.MaxTrendBuckets, req.Bucket)
+		}
+
+		bucket := models.TrendBucket{Start: start, End: nextBucket(start, req.Bucket), Count: counts[start]}
+		if bucket.Count > 0 {
+			bucket.MeanScore = sums[start] / float64(bucket.Count)
+		}
+		resp.Buckets = append(resp.Buckets, bucket)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,111 @@
+package bench
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/server"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestHistogram_Quantiles(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	other := NewHistogram()
+	other.Record(5 * time.Second)
+	h.Merge(other)
+
+	if h.Count() != 1001 {
+		t.Fatalf("count = %d, want 1001", h.Count())
+	}
+	for q, want := range map[float64]time.Duration{0.5: 500 * time.Millisecond, 0.9: 900 * time.Millisecond, 0.99: 990 * time.Millisecond} {
+		got := h.Quantile(q)
+		if math.Abs(float64(got-want))/float64(want) > 0.03 {
+			t.Errorf("quantile %.2f = %v, want %v within 3%%", q, got, want)
+		}
+	}
+	if h.Quantile(1) != 5*time.Second || h.Quantile(0) != time.Millisecond {
+		t.Errorf("extremes = %v, %v", h.Quantile(0), h.Quantile(1))
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("search=8, store=1,get=0")
+	if err != nil || !reflect.DeepEqual(mix, Mix{OpSearch: 8, OpStore: 1, OpGet: 0}) {
+		t.Fatalf("mix = %v, %v", mix, err)
+	}
+	for _, invalid := range []string{"search", "delete=1", "search=-1", "get=0"} {
+		if _, err := ParseMix(invalid); err == nil {
+			t.Errorf("%q accepted", invalid)
+		}
+	}
+}
+
+func TestGenerator_Clustered(t *testing.T) {
+	gen, err := NewGenerator(32, 2, 0.01, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	// Tight blobs put every vector close to one of the two centers
+	for i := 0; i < 20; i++ {
+		v := gen.Vector(i, rng)
+		best := math.Inf(-1)
+		for _, center := range gen.centers {
+			dot := 0.0
+			for j := range center {
+				dot += center[j] * v.Embedding[j]
+			}
+			best = math.Max(best, dot)
+		}
+		if best < 0.9 {
+			t.Errorf("vector %s has similarity %.3f to its nearest center", v.ID, best)
+		}
+	}
+
+	again, _ := NewGenerator(32, 2, 0.01, 7)
+	if !reflect.DeepEqual(gen.centers, again.centers) {
+		t.Errorf("same seed drew different centers")
+	}
+}
+
+func TestRun(t *testing.T) {
+	gen, _ := NewGenerator(16, 4, 0.1, 1)
+	cfg := Config{Vectors: 200, BatchSize: 64, Concurrency: 4, Duration: 100 * time.Millisecond, Mix: Mix{OpSearch: 2, OpStore: 1, OpGet: 1}, Seed: 1}
+
+	srv, err := server.NewServerWithOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	targets := map[string]Target{
+		"memory": &StorageTarget{Storage: memory.NewStorage()},
+		"http":   NewHTTPTarget(ts.URL, cfg.Concurrency),
+	}
+	for name, target := range targets {
+		report, err := Run(context.Background(), target, gen, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, op := range []string{OpSearch, OpStore, OpGet} {
+			o := report.Operations[op]
+			if o.Count == 0 || o.Errors != 0 {
+				t.Errorf("%s %s: %+v", name, op, o)
+			}
+		}
+		if (report.Memory != nil) != target.InProcess() {
+			t.Errorf("%s: memory report = %v", name, report.Memory)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// Operations of a load mix
+const (
+	OpSearch = "search"
+	OpStore  = "store"
+	OpGet    = "get"
+)
+
+// Mix weighs the operations run by the workers, {"search": 8, "store": 1, "get": 1}
+// runs searches 80% of the time
+type Mix map[string]int
+
+// ParseMix parses weights such as "search=8,store=1,get=1"
+func ParseMix(s string) (Mix, error) {
+	mix := make(Mix)
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		op, raw, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mix entry %q: expected op=weight", part)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight of %s: %q", op, raw)
+		}
+		mix[strings.TrimSpace(op)] = weight
+	}
+	return mix, mix.validate()
+}
+
+func (m Mix) validate() error {
+	total := 0
+	for op, weight := range m {
+		switch op {
+		case OpSearch, OpStore, OpGet:
+		default:
+			return fmt.Errorf("unknown operation %q (supported: search, store, get)", op)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("mix has no operation with a positive weight")
+	}
+	return nil
+}
+
+// pick returns the operation of a roll in [0, total weight)
+func (m Mix) pick(ops []string, roll int) string {
+	for _, op := range ops {
+		if roll < m[op] {
+			return op
+		}
+		roll -= m[op]
+	}
+	return ops[len(ops)-1]
+}
+
+// Config sets what the load driver runs
+type Config struct {
+	Vectors     int // Vectors loaded before the run, also the range of get IDs
+	BatchSize   int // Vectors per load batch
+	Concurrency int
+	Duration    time.Duration
+	Mix         Mix
+	TopK        int
+	Seed        int64
+}
+
+// OpReport is the outcome of one operation of the mix
+type OpReport struct {
+	Count      int64          `json:"count"`
+	Errors     int64          `json:"errors"`
+	Throughput float64        `json:"ops_per_second"`
+	Latency    LatencySummary `json:"latency"`
+	FirstError string         `json:"first_error,omitempty"`
+}
+
+// MemoryReport is the heap of the process, for in-process targets
+type MemoryReport struct {
+	HeapAfterLoad uint64 `json:"heap_after_load_bytes"`
+	HeapAfterRun  uint64 `json:"heap_after_run_bytes"`
+	TotalAlloc    uint64 `json:"total_alloc_bytes"`
+	Sys           uint64 `json:"sys_bytes"`
+}
+
+// Report is the outcome of a benchmark
+type Report struct {
+	Vectors     int                 `json:"vectors"`
+	Dimension   int                 `json:"dimension"`
+	Concurrency int                 `json:"concurrency"`
+	LoadTime    float64             `json:"load_seconds"`
+	LoadRate    float64             `json:"load_vectors_per_second"`
+	Duration    float64             `json:"run_seconds"`
+	Throughput  float64             `json:"ops_per_second"`
+	Operations  map[string]OpReport `json:"operations"`
+	Memory      *MemoryReport       `json:"memory,omitempty"`
+}
+
+// Run loads the generated vectors into target, then runs the mix with
+// Concurrency workers until Duration elapses or ctx is done
+func Run(ctx context.Context, target Target, gen *Generator, cfg Config) (*Report, error) {
+	if err := cfg.Mix.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Concurrency <= 0 {
+		return nil, fmt.Errorf("concurrency must be positive")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.TopK <= 0 {
+		cfg.TopK = 10
+	}
+
+	report := &Report{Vectors: cfg.Vectors, Dimension: gen.Dimension, Concurrency: cfg.Concurrency}
+
+	loadStart := time.Now()
+	if err := load(target, gen, cfg); err != nil {
+		return nil, err
+	}
+	report.LoadTime = time.Since(loadStart).Seconds()
+	if report.LoadTime > 0 {
+		report.LoadRate = float64(cfg.Vectors) / report.LoadTime
+	}
+
+	var mem runtime.MemStats
+	if target.InProcess() {
+		runtime.GC()
+		runtime.ReadMemStats(&mem)
+		report.Memory = &MemoryReport{HeapAfterLoad: mem.HeapAlloc}
+	}
+
+	results := drive(ctx, target, gen, cfg)
+	report.Duration = results.elapsed.Seconds()
+
+	report.Operations = make(map[string]OpReport, len(results.ops))
+	var total int64
+	for op, r := range results.ops {
+		opReport := OpReport{
+			Count:      r.latency.Count(),
+			Errors:     r.errors,
+			Latency:    r.latency.Summary(),
+			FirstError: r.firstError,
+		}
+		if report.Duration > 0 {
+			opReport.Throughput = float64(opReport.Count) / report.Duration
+		}
+		report.Operations[op] = opReport
+		total += opReport.Count
+	}
+	if report.Duration > 0 {
+		report.Throughput = float64(total) / report.Duration
+	}
+
+	if target.InProcess() {
+		runtime.ReadMemStats(&mem)
+		report.Memory.HeapAfterRun = mem.HeapAlloc
+		report.Memory.TotalAlloc = mem.TotalAlloc
+		report.Memory.Sys = mem.Sys
+	}
+	return report, nil
+}
+
+// load stores the generated vectors in batches
+func load(target Target, gen *Generator, cfg Config) error {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	batch := make([]*models.Vector, 0, cfg.BatchSize)
+	for i := 0; i < cfg.Vectors; i++ {
+		batch = append(batch, gen.Vector(i, rng))
+		if len(batch) == cfg.BatchSize || i == cfg.Vectors-1 {
+			if err := target.Load(batch); err != nil {
+				return fmt.Errorf("failed to load vectors: %w", err)
+			}
+			batch = batch[:0]
+		}
+	}
+	return nil
+}
+
+// opResult aggregates the outcomes of one operation
+type opResult struct {
+	latency    *Histogram
+	errors     int64
+	firstError string
+}
+
+type driveResult struct {
+	ops     map[string]*opResult
+	elapsed time.Duration
+}
+
+// drive runs the workers, each recording into its own histograms merged at the end
+func drive(ctx context.Context, target Target, gen *Generator, cfg Config) driveResult {
+	ops := make([]string, 0, len(cfg.Mix))
+	total := 0
+	for op, weight := range cfg.Mix {
+		if weight > 0 {
+			ops = append(ops, op)
+			total += weight
+		}
+	}
+	sort.Strings(ops)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	// New vectors stored during the run get IDs after the loaded ones
+	var nextID int64 = int64(cfg.Vectors)
+
+	workers := make([]map[string]*opResult, cfg.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := range workers {
+		results := make(map[string]*opResult, len(ops))
+		for _, op := range ops {
+			results[op] = &opResult{latency: NewHistogram()}
+		}
+		workers[w] = results
+
+		wg.Add(1)
+		go func(rng *rand.Rand) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				op := cfg.Mix.pick(ops, rng.Intn(total))
+
+				var err error
+				began := time.Now()
+				switch op {
+				case OpSearch:
+					err = target.Search(gen.Embedding(rng), cfg.TopK, gen.Namespace)
+				case OpStore:
+					err = target.Store(gen.Vector(int(atomic.AddInt64(&nextID, 1)-1), rng))
+				case OpGet:
+					id := 0
+					if cfg.Vectors > 0 {
+						id = rng.Intn(cfg.Vectors)
+					}
+					err = target.Get(VectorID(id))
+				}
+				elapsed := time.Since(began)
+
+				r := results[op]
+				if err != nil {
+					if ctx.Err() != nil {
+						return // Cut short by the end of the run
+					}
+					r.errors++
+					if r.firstError == "" {
+						r.firstError = err.Error()
+					}
+					continue
+				}
+				r.latency.Record(elapsed)
+			}
+		}(rand.New(rand.NewSource(cfg.Seed + int64(w) + 1)))
+	}
+	wg.Wait()
+
+	merged := make(map[string]*opResult, len(ops))
+	for _, op := range ops {
+		m := &opResult{latency: NewHistogram()}
+		for _, results := range workers {
+			r := results[op]
+			m.latency.Merge(r.latency)
+			m.errors += r.errors
+			if m.firstError == "" {
+				m.firstError = r.firstError
+			}
+		}
+		merged[op] = m
+	}
+	return driveResult{ops: merged, elapsed: time.Since(start)}
+}
@@ -0,0 +1,84 @@
+// Package bench generates synthetic vectors and drives load against a storage,
+// reporting throughput and latency percentiles per operation
+package bench
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// Generator produces vectors drawn from Gaussian blobs around random unit
+// centers, so searches have true neighbors to find rather than uniform noise
+type Generator struct {
+	Dimension int
+	Clusters  int
+	Spread    float64 // Standard deviation of each component around the center
+	Namespace string
+
+	centers [][]float64
+}
+
+// NewGenerator creates a generator whose cluster centers are drawn from seed
+func NewGenerator(dimension, clusters int, spread float64, seed int64) (*Generator, error) {
+	if dimension <= 0 {
+		return nil, fmt.Errorf("dimension must be positive")
+	}
+	if clusters <= 0 {
+		return nil, fmt.Errorf("clusters must be positive")
+	}
+	if spread < 0 {
+		return nil, fmt.Errorf("spread cannot be negative")
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	g := &Generator{Dimension: dimension, Clusters: clusters, Spread: spread, centers: make([][]float64, clusters)}
+	for i := range g.centers {
+		center := make([]float64, dimension)
+		for j := range center {
+			center[j] = rng.NormFloat64()
+		}
+		g.centers[i] = normalize(center)
+	}
+	return g, nil
+}
+
+// Embedding draws a unit vector near a random cluster center
+func (g *Generator) Embedding(rng *rand.Rand) []float64 {
+	center := g.centers[rng.Intn(len(g.centers))]
+	embedding := make([]float64, g.Dimension)
+	for i := range embedding {
+		embedding[i] = center[i] + rng.NormFloat64()*g.Spread
+	}
+	return normalize(embedding)
+}
+
+// Vector draws the vector with ID i, tagged with its generator namespace
+func (g *Generator) Vector(i int, rng *rand.Rand) *models.Vector {
+	metadata := map[string]string{"bench.index": fmt.Sprint(i)}
+	if g.Namespace != "" {
+		metadata[models.NamespaceKey] = g.Namespace
+	}
+	return &models.Vector{ID: VectorID(i), Embedding: g.Embedding(rng), Metadata: metadata}
+}
+
+// VectorID returns the ID of the i-th generated vector
+func VectorID(i int) string {
+	return fmt.Sprintf("bench-%d", i)
+}
+
+func normalize(v []float64) []float64 {
+	norm := 0.0
+	for _, x := range v {
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+	if norm > 0 {
+		for i := range v {
+			v[i] /= norm
+		}
+	}
+	return v
+}
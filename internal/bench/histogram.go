@@ -0,0 +1,144 @@
+package bench
+
+import (
+	"math"
+	"time"
+)
+
+// histogramGrowth is the ratio between bucket bounds, so quantiles are exact to about 2%
+const histogramGrowth = 1.04
+
+// Histogram counts latencies in logarithmic buckets, keeping memory constant
+// however many are recorded. It is not safe for concurrent use: give each
+// worker its own and Merge them
+type Histogram struct {
+	buckets map[int]int64
+	count   int64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{buckets: make(map[int]int64)}
+}
+
+// bucketOf returns the bucket of a latency, latencies under a microsecond share bucket 0
+func bucketOf(d time.Duration) int {
+	if d < time.Microsecond {
+		return 0
+	}
+	return 1 + int(math.Log(float64(d)/float64(time.Microsecond))/math.Log(histogramGrowth))
+}
+
+// bucketValue returns the geometric middle of a bucket
+func bucketValue(bucket int) time.Duration {
+	if bucket == 0 {
+		return 0
+	}
+	low := float64(time.Microsecond) * math.Pow(histogramGrowth, float64(bucket-1))
+	return time.Duration(low * math.Sqrt(histogramGrowth))
+}
+
+// Record adds a latency
+func (h *Histogram) Record(d time.Duration) {
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.buckets[bucketOf(d)]++
+	h.count++
+	h.sum += d
+}
+
+// Merge adds the latencies of other
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	for bucket, n := range other.buckets {
+		h.buckets[bucket] += n
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Count returns the number of recorded latencies
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+// Quantile returns the latency below which a fraction q of them fall
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.min
+	}
+	if q >= 1 {
+		return h.max
+	}
+
+	rank := int64(math.Ceil(q * float64(h.count)))
+	maxBucket := bucketOf(h.max)
+	var seen int64
+	for bucket := 0; bucket <= maxBucket; bucket++ {
+		seen += h.buckets[bucket]
+		if seen >= rank {
+			return clamp(bucketValue(bucket), h.min, h.max)
+		}
+	}
+	return h.max
+}
+
+func clamp(d, low, high time.Duration) time.Duration {
+	if d < low {
+		return low
+	}
+	if d > high {
+		return high
+	}
+	return d
+}
+
+// LatencySummary describes a histogram in milliseconds
+type LatencySummary struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	Min   float64 `json:"min_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	P999  float64 `json:"p999_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// Summary returns the mean, extremes and usual percentiles of the histogram
+func (h *Histogram) Summary() LatencySummary {
+	s := LatencySummary{Count: h.count}
+	if h.count == 0 {
+		return s
+	}
+	s.Mean = milliseconds(h.sum / time.Duration(h.count))
+	s.Min = milliseconds(h.min)
+	s.P50 = milliseconds(h.Quantile(0.5))
+	s.P90 = milliseconds(h.Quantile(0.9))
+	s.P99 = milliseconds(h.Quantile(0.99))
+	s.P999 = milliseconds(h.Quantile(0.999))
+	s.Max = milliseconds(h.max)
+	return s
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
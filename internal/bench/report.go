@@ -0,0 +1,58 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// WriteText writes the report as a table of operations
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Loaded %d vectors of dimension %d in %.2fs (%.0f vectors/s)\n", r.Vectors, r.Dimension, r.LoadTime, r.LoadRate)
+	fmt.Fprintf(w, "Ran %d workers for %.2fs: %.1f ops/s\n\n", r.Concurrency, r.Duration, r.Throughput)
+
+	ops := make([]string, 0, len(r.Operations))
+	for op := range r.Operations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\tcount\terrors\tops/s\tmean ms\tp50 ms\tp90 ms\tp99 ms\tp99.9 ms\tmax ms\t")
+	for _, op := range ops {
+		o := r.Operations[op]
+		l := o.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t\n",
+			op, o.Count, o.Errors, o.Throughput, l.Mean, l.P50, l.P90, l.P99, l.P999, l.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if first := r.Operations[op].FirstError; first != "" {
+			fmt.Fprintf(w, "\nfirst %s error: %s\n", op, first)
+		}
+	}
+
+	if r.Memory != nil {
+		fmt.Fprintf(w, "\nHeap: %s after load, %s after run (%s allocated in total, %s from the OS)\n",
+			formatBytes(r.Memory.HeapAfterLoad), formatBytes(r.Memory.HeapAfterRun),
+			formatBytes(r.Memory.TotalAlloc), formatBytes(r.Memory.Sys))
+	}
+	return nil
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,119 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// Target is what the load is driven against
+type Target interface {
+	Load(vectors []*models.Vector) error
+	Store(vector *models.Vector) error
+	Get(id string) error
+	Search(embedding []float64, topK int, namespace string) error
+	InProcess() bool // Whether memory usage of the process reflects the target
+}
+
+// StorageTarget drives a storage backend in process
+type StorageTarget struct {
+	Storage storage.Storage
+}
+
+// Load stores vectors through the batch path of the backend
+func (t *StorageTarget) Load(vectors []*models.Vector) error {
+	return storage.StoreBatch(t.Storage, vectors)
+}
+
+func (t *StorageTarget) Store(vector *models.Vector) error {
+	return t.Storage.Store(vector)
+}
+
+func (t *StorageTarget) Get(id string) error {
+	_, err := t.Storage.Get(id)
+	return err
+}
+
+func (t *StorageTarget) Search(embedding []float64, topK int, namespace string) error {
+	_, err := t.Storage.Search(&models.SearchByEmbbedingRequest{Embedding: embedding, TopK: topK, Namespace: namespace})
+	return err
+}
+
+func (t *StorageTarget) InProcess() bool {
+	return true
+}
+
+// HTTPTarget drives a running server through its API
+type HTTPTarget struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewHTTPTarget creates a target for the server at baseURL
+// The client keeps enough idle connections for every worker
+func NewHTTPTarget(baseURL string, concurrency int) *HTTPTarget {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = concurrency
+	return &HTTPTarget{BaseURL: strings.TrimSuffix(baseURL, "/"), Client: &http.Client{Transport: transport}}
+}
+
+// Load stores vectors with the batch endpoint
+func (t *HTTPTarget) Load(vectors []*models.Vector) error {
+	return t.do(http.MethodPost, "/api/v1/vectors/batch", map[string]interface{}{"vectors": vectors})
+}
+
+func (t *HTTPTarget) Store(vector *models.Vector) error {
+	return t.do(http.MethodPut, "/api/v1/vectors/"+url.PathEscape(vector.ID), vector)
+}
+
+func (t *HTTPTarget) Get(id string) error {
+	return t.do(http.MethodGet, "/api/v1/vectors/"+url.PathEscape(id), nil)
+}
+
+func (t *HTTPTarget) Search(embedding []float64, topK int, namespace string) error {
+	return t.do(http.MethodPost, "/api/v1/vectors/search", models.SearchByEmbbedingRequest{Embedding: embedding, TopK: topK, Namespace: namespace})
+}
+
+func (t *HTTPTarget) InProcess() bool {
+	return false
+}
+
+// do sends body as JSON and fails on any status other than 2xx, discarding the response
+func (t *HTTPTarget) do(method, path string, body interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, t.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
@@ -0,0 +1,352 @@
+// Package doctor diagnoses common misconfigurations of a same-same installation
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/registry"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip" // The check does not apply to the configuration
+)
+
+// DefaultSampleSize is the number of stored vectors compared with the embedder dimension
+const DefaultSampleSize = 100
+
+// SlowEmbedThreshold is the test embed latency above which the embedder check warns
+const SlowEmbedThreshold = 2 * time.Second
+
+// Config is the resolved configuration the checks inspect
+type Config struct {
+	EmbedderType string             // Resolved embedder type, e.g. "local" or "gemini"
+	Embedder     embedders.Embedder // Nil when the embedder could not be created
+	EmbedderErr  error              // Why the embedder could not be created
+	StorageType  string             // "memory" or "local"
+	StoragePath  string             // Base path of local storage
+	Collection   string             // Local storage collection
+	Source       string             // Ingest source the installation uses, e.g. "hf:imdb", optional
+	Addr         string             // Address the server listens on
+	SampleSize   int                // Stored vectors to sample, DefaultSampleSize when zero
+
+	dimension int // Embedding dimension measured by the embedder check
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name     string        `json:"name"`
+	Status   Status        `json:"status"`
+	Detail   string        `json:"detail"`
+	Duration time.Duration `json:"duration_ns,omitempty"`
+}
+
+// Check is a single diagnostic
+type Check struct {
+	Name string
+	Run  func(cfg *Config) (Status, string)
+}
+
+// Checks are run in order, later checks may rely on what earlier ones measured
+var Checks = []Check{
+	{"environment", checkEnvironment},
+	{"storage", checkStorage},
+	{"embedder", checkEmbedder},
+	{"dimension", checkDimension},
+	{"python", checkPython},
+	{"port", checkPort},
+}
+
+// Report is the outcome of every check
+type Report struct {
+	Results []Result `json:"results"`
+	Failed  bool     `json:"failed"`
+}
+
+// lookPath finds executables, replaced in tests
+var lookPath = exec.LookPath
+
+// Run runs every check against cfg
+func Run(cfg *Config) *Report {
+	report := &Report{}
+	for _, check := range Checks {
+		start := time.Now()
+		status, detail := check.Run(cfg)
+		report.Results = append(report.Results, Result{
+			Name:     check.Name,
+			Status:   status,
+			Detail:   detail,
+			Duration: time.Since(start),
+		})
+		if status == StatusFail {
+			report.Failed = true
+		}
+	}
+	return report
+}
+
+// checkEnvironment validates the environment variables the server and CLI read
+func checkEnvironment(cfg *Config) (Status, string) {
+	var problems, warnings []string
+
+	switch cfg.EmbedderType {
+	case "local", "hash", "clip", "ollama":
+	case "gemini":
+		if os.Getenv("GEMINI_API_KEY") == "" {
+			problems = append(problems, "GEMINI_API_KEY is not set")
+		}
+	case "huggingface", "hf":
+		if os.Getenv("HUGGINGFACE_API_KEY") == "" {
+			problems = append(problems, "HUGGINGFACE_API_KEY is not set")
+		}
+	case "openai":
+		if os.Getenv("OPENAI_API_KEY") == "" {
+			problems = append(problems, "OPENAI_API_KEY is not set")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown embedder type %q", cfg.EmbedderType))
+	}
+
+	if value := os.Getenv("STORAGE_TYPE"); value != "" && value != "local" && value != "memory" && value != "tiered" {
+		warnings = append(warnings, fmt.Sprintf("STORAGE_TYPE %q is not local, memory or tiered, memory storage is used", value))
+	}
+	if metric := os.Getenv("STORAGE_METRIC"); metric != "" {
+		if err := search.ValidateMetric(metric); err != nil {
+			problems = append(problems, "STORAGE_METRIC: "+err.Error())
+		}
+	}
+	for _, name := range []string{"LOCAL_STORAGE_COMPRESSION_LEVEL", "LOCAL_STORAGE_FLUSH_OPS", "RESPONSE_SCORE_PRECISION", "RESPONSE_EMBEDDING_PRECISION"} {
+		if value := os.Getenv(name); value != "" {
+			if _, err := strconv.Atoi(value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s %q is not an integer", name, value))
+			}
+		}
+	}
+	for _, name := range []string{"SYNONYMS_PATH", "TFIDF_BOOTSTRAP_PATH"} {
+		if path := os.Getenv(name); path != "" {
+			if _, err := os.Stat(path); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	}
+	if _, err := registry.FromEnv(); err != nil {
+		problems = append(problems, fmt.Sprintf("NAMESPACE_EMBEDDERS: %v", err))
+	}
+	if os.Getenv("ADMIN_API_KEY") == "" {
+		warnings = append(warnings, "ADMIN_API_KEY is not set, admin endpoints are disabled")
+	}
+
+	resolved := fmt.Sprintf("embedder=%s storage=%s", cfg.EmbedderType, cfg.StorageType)
+	if cfg.StorageType == "local" {
+		resolved += fmt.Sprintf(" path=%s collection=%s", cfg.StoragePath, cfg.Collection)
+	}
+
+	switch {
+	case len(problems) > 0:
+		return StatusFail, strings.Join(append(problems, warnings...), "; ")
+	case len(warnings) > 0:
+		return StatusWarn, resolved + "; " + strings.Join(warnings, "; ")
+	default:
+		return StatusPass, resolved
+	}
+}
+
+// checkStorage verifies the local storage path can be written and its index read
+func checkStorage(cfg *Config) (Status, string) {
+	if cfg.StorageType != "local" {
+		return StatusPass, "in-memory storage, vectors are lost when the process exits"
+	}
+
+	info, err := os.Stat(cfg.StoragePath)
+	if os.IsNotExist(err) {
+		parent := filepath.Dir(filepath.Clean(cfg.StoragePath))
+		if err := writable(parent); err != nil {
+			return StatusFail, fmt.Sprintf("%s does not exist and cannot be created: %v", cfg.StoragePath, err)
+		}
+		return StatusWarn, fmt.Sprintf("%s does not exist yet, it is created on first use", cfg.StoragePath)
+	}
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	if !info.IsDir() {
+		return StatusFail, fmt.Sprintf("%s is not a directory", cfg.StoragePath)
+	}
+	if err := writable(cfg.StoragePath); err != nil {
+		return StatusFail, fmt.Sprintf("%s is not writable: %v", cfg.StoragePath, err)
+	}
+
+	schema, err := readSchema(cfg.StoragePath)
+	if os.IsNotExist(err) {
+		return StatusWarn, fmt.Sprintf("%s is writable but has no %s yet", cfg.StoragePath, local.MetadataFile)
+	}
+	if err != nil {
+		return StatusFail, fmt.Sprintf("%s cannot be read: %v", local.MetadataFile, err)
+	}
+
+	// Local storage takes no lock on disk, so two processes writing to it overwrite each other's index
+	detail := fmt.Sprintf("%s is writable, %d collections; it is not locked, so only one process should write to it", cfg.StoragePath, len(schema.Collections))
+	if _, ok := schema.Collections[cfg.Collection]; !ok {
+		return StatusWarn, fmt.Sprintf("%s; collection %q does not exist yet", detail, cfg.Collection)
+	}
+	return StatusPass, detail
+}
+
+// checkEmbedder embeds a test sentence and reports the latency and dimension
+func checkEmbedder(cfg *Config) (Status, string) {
+	if cfg.Embedder == nil {
+		if cfg.EmbedderErr != nil {
+			return StatusFail, cfg.EmbedderErr.Error()
+		}
+		return StatusFail, "no embedder configured"
+	}
+
+	start := time.Now()
+	embedding, err := cfg.Embedder.Embed("same-same doctor test embedding")
+	latency := time.Since(start)
+	if err != nil {
+		return StatusFail, fmt.Sprintf("%s: test embed failed after %s: %v", cfg.Embedder.Name(), latency.Round(time.Millisecond), err)
+	}
+	if len(embedding) == 0 {
+		return StatusFail, fmt.Sprintf("%s returned an empty embedding", cfg.Embedder.Name())
+	}
+	cfg.dimension = len(embedding)
+
+	detail := fmt.Sprintf("%s: %d dimensions in %s", cfg.Embedder.Name(), len(embedding), latency.Round(time.Millisecond))
+	if latency > SlowEmbedThreshold {
+		return StatusWarn, detail + ", slower than " + SlowEmbedThreshold.String()
+	}
+	return StatusPass, detail
+}
+
+// checkDimension compares the embedder dimension with the collection config and a sample of stored vectors
+func checkDimension(cfg *Config) (Status, string) {
+	if cfg.StorageType != "local" {
+		return StatusSkip, "in-memory storage starts empty"
+	}
+	if cfg.dimension == 0 {
+		return StatusSkip, "embedder dimension unknown"
+	}
+
+	schema, err := readSchema(cfg.StoragePath)
+	if err != nil {
+		return StatusSkip, "storage index unavailable"
+	}
+	collection, ok := schema.Collections[cfg.Collection]
+	if !ok {
+		return StatusSkip, fmt.Sprintf("collection %q does not exist yet", cfg.Collection)
+	}
+
+	if collection.Schema != nil && collection.Schema.VectorConfig != nil {
+		if configured := collection.Schema.VectorConfig.Dimension; configured > 0 && configured != cfg.dimension {
+			return StatusFail, fmt.Sprintf("collection %q is configured for %d dimensions, the embedder produces %d", cfg.Collection, configured, cfg.dimension)
+		}
+	}
+
+	sampleSize := cfg.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = DefaultSampleSize
+	}
+	sampled, mismatchedCount := 0, 0
+	mismatched := make(map[int]int)
+	for _, doc := range collection.Documents {
+		if sampled == sampleSize {
+			break
+		}
+		if doc.Embedding == nil {
+			continue
+		}
+		sampled++
+		if doc.Embedding.Dimension != cfg.dimension {
+			mismatched[doc.Embedding.Dimension]++
+			mismatchedCount++
+		}
+	}
+
+	if len(mismatched) > 0 {
+		parts := make([]string, 0, len(mismatched))
+		for dimension, count := range mismatched {
+			parts = append(parts, fmt.Sprintf("%d with %d dimensions", count, dimension))
+		}
+		return StatusFail, fmt.Sprintf("%d of %d sampled vectors do not match the embedder's %d dimensions (%s), they are skipped by search",
+			mismatchedCount, sampled, cfg.dimension, strings.Join(parts, ", "))
+	}
+	if sampled == 0 {
+		return StatusPass, fmt.Sprintf("collection %q has no vectors yet", cfg.Collection)
+	}
+	return StatusPass, fmt.Sprintf("%d sampled vectors match the embedder's %d dimensions", sampled, cfg.dimension)
+}
+
+// checkPython looks for the Python interpreter that Python CLIP and HuggingFace sources shell out to
+func checkPython(cfg *Config) (Status, string) {
+	var needed []string
+	if cfg.EmbedderType == "clip" && os.Getenv("CLIP_USE_PYTHON") == "true" {
+		needed = append(needed, "CLIP_USE_PYTHON")
+	}
+	if strings.HasPrefix(cfg.Source, "hf:") {
+		needed = append(needed, "HuggingFace source "+cfg.Source)
+	}
+	if len(needed) == 0 {
+		return StatusSkip, "not required by the configuration"
+	}
+
+	for _, name := range []string{"python3", "python"} {
+		if path, err := lookPath(name); err == nil {
+			return StatusPass, fmt.Sprintf("%s found for %s", path, strings.Join(needed, ", "))
+		}
+	}
+	return StatusFail, fmt.Sprintf("python3 or python not found on PATH, required for %s", strings.Join(needed, ", "))
+}
+
+// checkPort verifies the server address can be listened on
+func checkPort(cfg *Config) (Status, string) {
+	if cfg.Addr == "" {
+		return StatusSkip, "no address configured"
+	}
+
+	listener, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return StatusWarn, fmt.Sprintf("cannot listen on %s, is a server already running? %v", cfg.Addr, err)
+	}
+	listener.Close()
+	return StatusPass, fmt.Sprintf("%s is available", cfg.Addr)
+}
+
+// writable checks that a file can be created in dir
+func writable(dir string) error {
+	file, err := os.CreateTemp(dir, ".same-same-doctor-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// readSchema reads the storage index without creating or modifying anything
+func readSchema(basePath string) (*local.StorageSchema, error) {
+	data, err := os.ReadFile(filepath.Join(basePath, local.MetadataFile))
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &local.StorageSchema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
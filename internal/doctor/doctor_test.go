@@ -0,0 +1,137 @@
+package doctor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+// localConfig returns a config for a local store holding one vector of dimension
+func localConfig(t *testing.T, dimension int) *Config {
+	t.Helper()
+	dir := t.TempDir()
+
+	adapter, err := local.NewVectorStorageAdapter(dir, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.Store(&models.Vector{ID: "stored", Embedding: make([]float64, dimension)}); err != nil {
+		t.Fatal(err)
+	}
+	adapter.Close()
+
+	return &Config{
+		EmbedderType: "hash",
+		Embedder:     hash.NewHashEmbedder(),
+		StorageType:  "local",
+		StoragePath:  dir,
+		Collection:   "default",
+	}
+}
+
+func result(t *testing.T, report *Report, name string) Result {
+	t.Helper()
+	for _, r := range report.Results {
+		if r.Name == name {
+			return r
+		}
+	}
+	t.Fatalf("no %s result in %+v", name, report.Results)
+	return Result{}
+}
+
+func TestRun_HealthyLocalStore(t *testing.T) {
+	dimension := len(mustEmbed(t))
+	cfg := localConfig(t, dimension)
+	t.Setenv("ADMIN_API_KEY", "secret")
+
+	report := Run(cfg)
+	if report.Failed {
+		t.Fatalf("report failed: %+v", report.Results)
+	}
+	for _, name := range []string{"environment", "storage", "embedder", "dimension"} {
+		if r := result(t, report, name); r.Status != StatusPass {
+			t.Errorf("%s = %s: %s", name, r.Status, r.Detail)
+		}
+	}
+	if r := result(t, report, "python"); r.Status != StatusSkip {
+		t.Errorf("python = %s, want skip when not required", r.Status)
+	}
+}
+
+func TestRun_DimensionMismatch(t *testing.T) {
+	cfg := localConfig(t, 3)
+
+	report := Run(cfg)
+	r := result(t, report, "dimension")
+	if r.Status != StatusFail || !strings.Contains(r.Detail, "3 dimensions") {
+		t.Errorf("dimension = %s: %s", r.Status, r.Detail)
+	}
+	if !report.Failed {
+		t.Error("report should fail on a dimension mismatch")
+	}
+}
+
+func TestCheckStorage(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "new")
+	if status, detail := checkStorage(&Config{StorageType: "local", StoragePath: missing}); status != StatusWarn {
+		t.Errorf("missing path = %s: %s", status, detail)
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0644)
+	if status, _ := checkStorage(&Config{StorageType: "local", StoragePath: file}); status != StatusFail {
+		t.Errorf("file path = %s, want fail", status)
+	}
+
+	corrupt := t.TempDir()
+	os.WriteFile(filepath.Join(corrupt, local.MetadataFile), []byte("{"), 0644)
+	if status, _ := checkStorage(&Config{StorageType: "local", StoragePath: corrupt}); status != StatusFail {
+		t.Errorf("corrupt index = %s, want fail", status)
+	}
+}
+
+func TestCheckEnvironment(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "secret")
+	t.Setenv("GEMINI_API_KEY", "")
+	if status, detail := checkEnvironment(&Config{EmbedderType: "gemini"}); status != StatusFail || !strings.Contains(detail, "GEMINI_API_KEY") {
+		t.Errorf("gemini without key = %s: %s", status, detail)
+	}
+
+	t.Setenv("STORAGE_METRIC", "manhattan")
+	if status, detail := checkEnvironment(&Config{EmbedderType: "local"}); status != StatusFail || !strings.Contains(detail, "STORAGE_METRIC") {
+		t.Errorf("invalid metric = %s: %s", status, detail)
+	}
+}
+
+func TestCheckPython(t *testing.T) {
+	defer func(original func(string) (string, error)) { lookPath = original }(lookPath)
+	lookPath = func(string) (string, error) { return "", errors.New("not found") }
+
+	if status, _ := checkPython(&Config{EmbedderType: "local"}); status != StatusSkip {
+		t.Errorf("python without hf or clip = %s, want skip", status)
+	}
+	if status, detail := checkPython(&Config{EmbedderType: "local", Source: "hf:imdb"}); status != StatusFail {
+		t.Errorf("hf source without python = %s: %s", status, detail)
+	}
+
+	lookPath = func(name string) (string, error) { return "/usr/bin/" + name, nil }
+	if status, _ := checkPython(&Config{EmbedderType: "local", Source: "hf:imdb"}); status != StatusPass {
+		t.Errorf("hf source with python = %s, want pass", status)
+	}
+}
+
+func mustEmbed(t *testing.T) []float64 {
+	t.Helper()
+	embedding, err := hash.NewHashEmbedder().Embed("dimension probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return embedding
+}
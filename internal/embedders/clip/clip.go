@@ -88,6 +88,11 @@ func (c *CLIPEmbedder) Name() string {
 	return fmt.Sprintf("clip-%s-%s", c.model, c.pretrained)
 }
 
+// Model returns the OpenCLIP model and its pretrained weights, e.g. ViT-B-32/openai
+func (c *CLIPEmbedder) Model() string {
+	return c.model + "/" + c.pretrained
+}
+
 func (c *CLIPEmbedder) embedText(text string) ([]float64, error) {
 	script := c.generatePythonScript()
 	return c.runPythonScript(script, "text", text)
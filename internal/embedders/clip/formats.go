@@ -0,0 +1,73 @@
+package clip
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register the GIF decoder
+	_ "image/jpeg" // Register the JPEG decoder
+	_ "image/png"  // Register the PNG decoder
+	"os"
+	"sort"
+	"sync"
+
+	_ "golang.org/x/image/bmp"  // Register the BMP decoder
+	_ "golang.org/x/image/tiff" // Register the TIFF decoder
+	_ "golang.org/x/image/webp" // Register the WebP decoder
+)
+
+// imageExtensions maps the file extensions of the image formats with a registered
+// decoder to the format name. HEIC needs cgo and registers itself from a file
+// built with its tag, see formats_heic.go
+var (
+	imageExtensionsMu sync.RWMutex
+	imageExtensions   = map[string]string{
+		".jpg":  "jpeg",
+		".jpeg": "jpeg",
+		".png":  "png",
+		".gif":  "gif",
+		".bmp":  "bmp",
+		".tif":  "tiff",
+		".tiff": "tiff",
+		".webp": "webp",
+	}
+)
+
+// registerImageExtensions records the extensions of a format whose decoder is
+// registered with the image package
+func registerImageExtensions(format string, extensions ...string) {
+	imageExtensionsMu.Lock()
+	defer imageExtensionsMu.Unlock()
+	for _, ext := range extensions {
+		imageExtensions[ext] = format
+	}
+}
+
+// ImageExtensions returns the lowercase extensions, with the dot, of the image
+// files the embedders of this package can decode in this build
+func ImageExtensions() []string {
+	imageExtensionsMu.RLock()
+	defer imageExtensionsMu.RUnlock()
+	exts := make([]string, 0, len(imageExtensions))
+	for ext := range imageExtensions {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// decodeImage decodes an image file, turned upright when its EXIF orientation
+// says the camera was rotated
+func decodeImage(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return orient(img, exifOrientation(format, data)), nil
+}
@@ -0,0 +1,29 @@
+//go:build heic
+
+package clip
+
+// Built with -tags heic, after go get github.com/jdeng/goheif, the embedders also
+// decode the HEIC photos of phones. The decoder wraps libde265 and needs cgo
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/jdeng/goheif"
+)
+
+func init() {
+	// goheif registers the heic brand, phones also write heix and mif1
+	for _, brand := range []string{"heix", "mif1"} {
+		image.RegisterFormat("heic", "????ftyp"+brand, goheif.Decode, goheif.DecodeConfig)
+	}
+	registerImageExtensions("heic", ".heic", ".heif")
+
+	exifReaders["heic"] = func(data []byte) []byte {
+		exif, err := goheif.ExtractExif(bytes.NewReader(data))
+		if err != nil {
+			return nil
+		}
+		return bytes.TrimPrefix(exif, []byte("Exif\x00\x00"))
+	}
+}
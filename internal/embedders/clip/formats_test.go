@@ -0,0 +1,158 @@
+package clip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// halves returns a w x h image, red in its left half and blue in its right half
+func halves(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{B: 255, A: 255}
+			if x < w/2 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// withOrientation inserts an EXIF APP1 segment holding orientation after the
+// start of a JPEG file
+func withOrientation(jpg []byte, orientation uint16) []byte {
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1}
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry, exifTagOrientation)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	return append(append(append([]byte{}, jpg[:2]...), app1...), jpg[2:]...)
+}
+
+func TestSimpleCLIPEmbedder_ImageFormats(t *testing.T) {
+	dir := t.TempDir()
+	img := halves(64, 48)
+	encoders := map[string]func(*bytes.Buffer) error{
+		"photo.jpg":  func(b *bytes.Buffer) error { return jpeg.Encode(b, img, nil) },
+		"photo.jpeg": func(b *bytes.Buffer) error { return jpeg.Encode(b, img, nil) },
+		"photo.png":  func(b *bytes.Buffer) error { return png.Encode(b, img) },
+		"photo.gif":  func(b *bytes.Buffer) error { return gif.Encode(b, img, nil) },
+		"photo.bmp":  func(b *bytes.Buffer) error { return bmp.Encode(b, img) },
+		"photo.tif":  func(b *bytes.Buffer) error { return tiff.Encode(b, img, nil) },
+		"photo.tiff": func(b *bytes.Buffer) error { return tiff.Encode(b, img, &tiff.Options{Compression: tiff.Deflate}) },
+		"photo.webp": func(b *bytes.Buffer) error { // There is no WebP encoder, testdata holds a lossless one
+			data, err := os.ReadFile(filepath.Join("testdata", "gopher.webp"))
+			b.Write(data)
+			return err
+		},
+	}
+
+	embedder := NewSimpleCLIPEmbedder()
+	supported := make(map[string]bool)
+	for _, ext := range embedder.ImageExtensions() {
+		supported[ext] = true
+	}
+	for name, encode := range encoders {
+		if !supported[filepath.Ext(name)] {
+			t.Errorf("%s is not listed as supported", filepath.Ext(name))
+		}
+		var buf bytes.Buffer
+		if err := encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		embedding, err := embedder.EmbedImage(path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(embedding) != embedder.Dimensions() {
+			t.Errorf("%s: %d dimensions, want %d", name, len(embedding), embedder.Dimensions())
+		}
+	}
+
+	if _, err := embedder.EmbedImage(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("a missing image was embedded")
+	}
+}
+
+func TestDecodeImage_EXIFOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, halves(64, 32), &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	tests := []struct {
+		orientation              uint16
+		width, height            int
+		redX, redY, blueX, blueY int
+	}{
+		{1, 64, 32, 8, 16, 56, 16},
+		{3, 64, 32, 56, 16, 8, 16}, // Turned half way, red ends up on the right
+		{6, 32, 64, 16, 8, 16, 56}, // Turned clockwise, the left half ends up on top
+		{8, 32, 64, 16, 56, 16, 8}, // Turned counter-clockwise, the left half ends up at the bottom
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "rotated.jpg")
+		if err := os.WriteFile(path, withOrientation(buf.Bytes(), tt.orientation), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		img, err := decodeImage(path)
+		if err != nil {
+			t.Fatalf("orientation %d: %v", tt.orientation, err)
+		}
+		if b := img.Bounds(); b.Dx() != tt.width || b.Dy() != tt.height {
+			t.Errorf("orientation %d: decoded %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.width, tt.height)
+			continue
+		}
+		if r, _, b, _ := img.At(tt.redX, tt.redY).RGBA(); r < 0xC000 || b > 0x4000 {
+			t.Errorf("orientation %d: pixel %d,%d is not red", tt.orientation, tt.redX, tt.redY)
+		}
+		if r, _, b, _ := img.At(tt.blueX, tt.blueY).RGBA(); b < 0xC000 || r > 0x4000 {
+			t.Errorf("orientation %d: pixel %d,%d is not blue", tt.orientation, tt.blueX, tt.blueY)
+		}
+	}
+}
+
+func TestOrient_Mirrors(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	img.SetGray(0, 0, color.Gray{Y: 255}) // Marks the top-left corner
+
+	for orientation, corner := range map[int]image.Point{
+		2: {2, 0},
+		4: {0, 1},
+		5: {0, 0},
+		7: {1, 2},
+	} {
+		upright := orient(img, orientation)
+		if r, _, _, _ := upright.At(corner.X, corner.Y).RGBA(); r != 0xFFFF {
+			t.Errorf("orientation %d: the marked corner is not at %v", orientation, corner)
+		}
+	}
+}
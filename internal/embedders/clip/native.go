@@ -2,9 +2,6 @@ package clip
 
 import (
 	"fmt"
-	"image"
-	_ "image/jpeg"
-	_ "image/png"
 	"math"
 	"os"
 	"strings"
@@ -91,6 +88,11 @@ func (n *NativeCLIPEmbedder) Name() string {
 	return "clip-native-go"
 }
 
+// ImageExtensions returns the extensions of the image files the embedder can decode
+func (n *NativeCLIPEmbedder) ImageExtensions() []string {
+	return ImageExtensions()
+}
+
 // loadTokenizer loads the tokenizer vocabulary
 func (n *NativeCLIPEmbedder) loadTokenizer() error {
 	// Simple vocabulary for demonstration
@@ -139,17 +141,10 @@ func (n *NativeCLIPEmbedder) tokenize(text string) []int {
 
 // loadAndPreprocessImage loads and preprocesses an image
 func (n *NativeCLIPEmbedder) loadAndPreprocessImage(imagePath string) ([]float32, error) {
-	// Open image file
-	file, err := os.Open(imagePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open image: %w", err)
-	}
-	defer file.Close()
-
-	// Decode image
-	img, _, err := image.Decode(file)
+	// Decode image, upright according to its EXIF orientation
+	img, err := decodeImage(imagePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return nil, err
 	}
 
 	// Resize to 224x224
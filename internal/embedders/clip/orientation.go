@@ -0,0 +1,138 @@
+package clip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// exifTagOrientation is the EXIF tag of the orientation of the camera, 1 (upright) to 8
+const exifTagOrientation = 0x0112
+
+// exifReaders return the EXIF data, a TIFF structure, embedded in a file of their
+// format, nil when it has none
+var exifReaders = map[string]func(data []byte) []byte{
+	"jpeg": jpegExif,
+	"tiff": func(data []byte) []byte { return data }, // A TIFF file is itself the structure EXIF data is stored in
+}
+
+// exifOrientation returns the EXIF orientation of an image file of format, 1 when
+// it has none or it cannot be read
+func exifOrientation(format string, data []byte) int {
+	read, ok := exifReaders[format]
+	if !ok {
+		return 1
+	}
+	exif := read(data)
+	if exif == nil {
+		return 1
+	}
+	if orientation := tiffOrientation(exif); orientation >= 1 && orientation <= 8 {
+		return orientation
+	}
+	return 1
+}
+
+// jpegExif returns the EXIF data of the APP1 segment of a JPEG file
+func jpegExif(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // Start of scan or end of image, no more metadata
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		i += 2 + length
+	}
+	return nil
+}
+
+// tiffOrientation returns the orientation tag of the first directory of a TIFF
+// structure, 0 when it has none
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == exifTagOrientation {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 0
+}
+
+// orient returns img turned upright from its EXIF orientation: 2 to 4 mirror or
+// turn it half way, 5 to 8 swap its width and height
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// source maps a pixel of the upright image to the pixel of img it shows
+	var source func(x, y int) (int, int)
+	switch orientation {
+	case 2: // Mirrored horizontally
+		source = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // Turned half way
+		source = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // Mirrored vertically
+		source = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // Mirrored along the top-left to bottom-right diagonal
+		source = func(x, y int) (int, int) { return y, x }
+	case 6: // Turned 90 degrees counter-clockwise, turn it clockwise
+		source = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // Mirrored along the top-right to bottom-left diagonal
+		source = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // Turned 90 degrees clockwise, turn it counter-clockwise
+		source = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	upright := image.NewRGBA64(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := source(x, y)
+			upright.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return upright
+}
@@ -5,8 +5,6 @@ import (
 	"encoding/binary"
 	"fmt"
 	"image"
-	_ "image/jpeg"
-	_ "image/png"
 	"math"
 	"os"
 	"strings"
@@ -71,6 +69,11 @@ func (s *SimpleCLIPEmbedder) Name() string {
 	return "clip-simple-go"
 }
 
+// ImageExtensions returns the extensions of the image files the embedder can decode
+func (s *SimpleCLIPEmbedder) ImageExtensions() []string {
+	return ImageExtensions()
+}
+
 // embedText creates a semantic embedding from text
 func (s *SimpleCLIPEmbedder) embedText(text string) []float64 {
 	embedding := make([]float64, s.dimension)
@@ -126,20 +129,9 @@ func (s *SimpleCLIPEmbedder) embedImage(img image.Image) []float64 {
 	return normalizeVector(embedding)
 }
 
-// loadImage loads an image from file
+// loadImage loads an image from file, upright according to its EXIF orientation
 func (s *SimpleCLIPEmbedder) loadImage(path string) (image.Image, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open image: %w", err)
-	}
-	defer file.Close()
-
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	return img, nil
+	return decodeImage(path)
 }
 
 // extractColorHistogram extracts color distribution features
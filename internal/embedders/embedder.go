@@ -1,6 +1,149 @@
 package embedders
 
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tahcohcat/same-same/internal/embedders/synonyms"
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
 type Embedder interface {
 	Embed(text string) ([]float64, error)
 	Name() string
 }
+
+// QueryEmbedder can embed search queries differently from stored documents
+type QueryEmbedder interface {
+	EmbedQuery(text string) ([]float64, error)
+}
+
+// BatchEmbedder is implemented by embedders that can embed several texts in one request
+// EmbedBatch returns the embeddings in the order of texts. When only some texts fail, the
+// error is a *BatchError and the embeddings of the others are still returned
+type BatchEmbedder interface {
+	EmbedBatch(texts []string) ([][]float64, error)
+}
+
+// BatchError reports the texts of a batch that could not be embedded
+type BatchError struct {
+	Errors map[int]error // By position of the text in the batch
+}
+
+func (e *BatchError) Error() string {
+	positions := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		positions = append(positions, i)
+	}
+	sort.Ints(positions)
+	if len(positions) == 0 {
+		return "no texts failed"
+	}
+	return fmt.Sprintf("%d texts of the batch failed, first text %d: %v", len(positions), positions[0], e.Errors[positions[0]])
+}
+
+// Fitter is implemented by embedders whose vector space is a vocabulary learned from
+// the texts they embed, such as TF-IDF. Vectors only compare within one vocabulary,
+// so it is fitted once before a run embeds its texts and saved with the vectors, for
+// queries to embed into their space
+type Fitter interface {
+	// Fit builds the vocabulary from texts and fixes it for the texts embedded afterwards
+	Fit(texts []string) error
+	// Fixed reports whether the vocabulary was fitted or loaded
+	Fixed() bool
+	SaveVocabulary() ([]byte, error)
+	LoadVocabulary(data []byte) error
+}
+
+// SparseEmbedder is implemented by embedders that can emit sparse vectors natively
+type SparseEmbedder interface {
+	EmbedSparse(text string) (*models.SparseVector, error)
+}
+
+// SparseQueryEmbedder can embed sparse search queries differently from stored documents
+type SparseQueryEmbedder interface {
+	EmbedSparseQuery(text string) (*models.SparseVector, error)
+}
+
+// FuzzyQueryEmbedder is implemented by lexical embedders that can match the query terms
+// missing from their vocabulary, such as typos, to vocabulary terms within a small edit distance
+type FuzzyQueryEmbedder interface {
+	EmbedQueryFuzzy(text string) ([]float64, []Correction, error)
+	EmbedSparseQueryFuzzy(text string) (*models.SparseVector, []Correction, error)
+}
+
+// Correction is a query term matched to a vocabulary term by a FuzzyQueryEmbedder
+type Correction struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Distance int    `json:"distance"` // Edits between the terms
+}
+
+// String returns the correction as "from→to"
+func (c Correction) String() string {
+	return c.From + "→" + c.To
+}
+
+// Tokenizer is implemented by embedders that expose their text preprocessing
+type Tokenizer interface {
+	Tokenize(text string) []string
+}
+
+// Dimensioned is implemented by embedders that produce vectors of a fixed size
+type Dimensioned interface {
+	Dimensions() int
+}
+
+// Modeled is implemented by embedders backed by a named model
+type Modeled interface {
+	Model() string
+}
+
+// SynonymExpander is implemented by embedders that support synonym expansion
+type SynonymExpander interface {
+	Synonyms() *synonyms.Set
+	SetSynonyms(set *synonyms.Set)
+}
+
+// EmbedQuery embeds text as a search query, falling back to Embed
+// for embedders that make no distinction between queries and documents
+func EmbedQuery(e Embedder, text string) ([]float64, error) {
+	if qe, ok := e.(QueryEmbedder); ok {
+		return qe.EmbedQuery(text)
+	}
+	return e.Embed(text)
+}
+
+// EmbedSparseQuery embeds text as a sparse search query, falling back to EmbedSparse
+// The second result is false when e cannot emit sparse vectors
+func EmbedSparseQuery(e Embedder, text string) (*models.SparseVector, bool, error) {
+	if qe, ok := e.(SparseQueryEmbedder); ok {
+		sparse, err := qe.EmbedSparseQuery(text)
+		return sparse, true, err
+	}
+	if se, ok := e.(SparseEmbedder); ok {
+		sparse, err := se.EmbedSparse(text)
+		return sparse, true, err
+	}
+	return nil, false, nil
+}
+
+// EmbedQueryFuzzy embeds text as a search query like EmbedQuery, matching the terms
+// missing from the vocabulary of a FuzzyQueryEmbedder and returning the corrections made
+func EmbedQueryFuzzy(e Embedder, text string) ([]float64, []Correction, error) {
+	if fe, ok := e.(FuzzyQueryEmbedder); ok {
+		return fe.EmbedQueryFuzzy(text)
+	}
+	embedding, err := EmbedQuery(e, text)
+	return embedding, nil, err
+}
+
+// EmbedSparseQueryFuzzy is EmbedSparseQuery matching misspelled terms like EmbedQueryFuzzy
+func EmbedSparseQueryFuzzy(e Embedder, text string) (*models.SparseVector, []Correction, bool, error) {
+	if fe, ok := e.(FuzzyQueryEmbedder); ok {
+		sparse, corrections, err := fe.EmbedSparseQueryFuzzy(text)
+		return sparse, corrections, true, err
+	}
+	sparse, ok, err := EmbedSparseQuery(e, text)
+	return sparse, nil, ok, err
+}
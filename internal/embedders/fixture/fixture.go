@@ -0,0 +1,112 @@
+// Package fixture provides a deterministic embedder for tests, needing no model
+// or API key and embedding the same text to the same vector on every run
+package fixture
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"sync"
+)
+
+// DefaultDimension is the dimension of the vectors of embedders created with zero
+const DefaultDimension = 64
+
+// FixtureEmbedder derives a stable unit vector from a seeded hash of the text,
+// unless a canned vector was set for the exact text
+type FixtureEmbedder struct {
+	dimension int
+	seed      uint64
+
+	mu      sync.RWMutex
+	vectors map[string][]float64
+}
+
+// NewFixtureEmbedder creates a fixture embedder; embedders of the same dimension
+// and seed produce identical vectors
+func NewFixtureEmbedder(dimension int, seed uint64) *FixtureEmbedder {
+	if dimension <= 0 {
+		dimension = DefaultDimension
+	}
+	return &FixtureEmbedder{dimension: dimension, seed: seed, vectors: make(map[string][]float64)}
+}
+
+// SetVector makes Embed return vector for text, which must have the embedder's dimension
+func (f *FixtureEmbedder) SetVector(text string, vector []float64) error {
+	if len(vector) != f.dimension {
+		return fmt.Errorf("fixture vector for %q has dimension %d, expected %d", text, len(vector), f.dimension)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.vectors[text] = append([]float64(nil), vector...)
+	return nil
+}
+
+// LoadVectors sets the canned vectors of a JSON file mapping texts to vectors
+func (f *FixtureEmbedder) LoadVectors(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture vectors: %w", err)
+	}
+	var vectors map[string][]float64
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		return fmt.Errorf("failed to parse fixture vectors %s: %w", path, err)
+	}
+	for text, vector := range vectors {
+		if err := f.SetVector(text, vector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Embed returns the canned vector of text, or one derived from its hash
+func (f *FixtureEmbedder) Embed(text string) ([]float64, error) {
+	f.mu.RLock()
+	canned, ok := f.vectors[text]
+	f.mu.RUnlock()
+	if ok {
+		return append([]float64(nil), canned...), nil
+	}
+	return f.derive(text), nil
+}
+
+// derive hashes the seed, text and index of each dimension into [-1, 1),
+// then L2 normalizes the vector
+func (f *FixtureEmbedder) derive(text string) []float64 {
+	var seed, index [8]byte
+	binary.LittleEndian.PutUint64(seed[:], f.seed)
+
+	embedding := make([]float64, f.dimension)
+	norm := 0.0
+	for i := range embedding {
+		binary.LittleEndian.PutUint64(index[:], uint64(i))
+		hasher := fnv.New64a()
+		hasher.Write(seed[:])
+		hasher.Write([]byte(text))
+		hasher.Write(index[:])
+
+		embedding[i] = float64(hasher.Sum64()>>11)/float64(1<<52) - 1
+		norm += embedding[i] * embedding[i]
+	}
+
+	norm = math.Sqrt(norm)
+	if norm > 0 {
+		for i := range embedding {
+			embedding[i] /= norm
+		}
+	}
+	return embedding
+}
+
+// Dimensions returns the dimension of the vectors
+func (f *FixtureEmbedder) Dimensions() int {
+	return f.dimension
+}
+
+func (f *FixtureEmbedder) Name() string {
+	return "fixture"
+}
@@ -0,0 +1,58 @@
+package fixture
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEmbed_Deterministic(t *testing.T) {
+	a, _ := NewFixtureEmbedder(16, 7).Embed("the quick brown fox")
+	b, _ := NewFixtureEmbedder(16, 7).Embed("the quick brown fox")
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("same text, dimension and seed embedded differently")
+	}
+	if len(a) != 16 {
+		t.Errorf("dimension = %d, want 16", len(a))
+	}
+
+	norm := 0.0
+	for _, v := range a {
+		norm += v * v
+	}
+	if math.Abs(norm-1) > 1e-9 {
+		t.Errorf("squared norm = %f, want 1", norm)
+	}
+
+	other, _ := NewFixtureEmbedder(16, 8).Embed("the quick brown fox")
+	if reflect.DeepEqual(a, other) {
+		t.Errorf("another seed produced the same vector")
+	}
+}
+
+func TestSetVector(t *testing.T) {
+	f := NewFixtureEmbedder(2, 0)
+	if err := f.SetVector("cat", []float64{1, 0}); err != nil {
+		t.Fatalf("set vector: %v", err)
+	}
+	if err := f.SetVector("dog", []float64{1, 0, 0}); err == nil {
+		t.Errorf("vector of another dimension accepted")
+	}
+
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	if err := os.WriteFile(path, []byte(`{"kitten": [0.6, 0.8]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.LoadVectors(path); err != nil {
+		t.Fatalf("load vectors: %v", err)
+	}
+
+	for text, want := range map[string][]float64{"cat": {1, 0}, "kitten": {0.6, 0.8}} {
+		got, _ := f.Embed(text)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("embedding of %s = %v, want %v", text, got, want)
+		}
+	}
+}
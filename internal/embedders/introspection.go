@@ -0,0 +1,67 @@
+package embedders
+
+import (
+	"math"
+	"sort"
+)
+
+// VocabularyTerm is a single vocabulary entry with its inverse document frequency
+type VocabularyTerm struct {
+	Term  string  `json:"term"`
+	Index int     `json:"index"`
+	IDF   float64 `json:"idf"`
+}
+
+// DimensionWeight describes one dimension of an embedding and the terms feeding it
+type DimensionWeight struct {
+	Index  int      `json:"index"`
+	Weight float64  `json:"weight"`
+	Terms  []string `json:"terms,omitempty"`
+}
+
+// Analysis explains how an embedder turns a piece of text into a vector
+type Analysis struct {
+	Tokens          []string          `json:"tokens"`
+	OutOfVocabulary []string          `json:"out_of_vocabulary,omitempty"`
+	TopDimensions   []DimensionWeight `json:"top_dimensions"`
+}
+
+// VocabularyInspector is implemented by embedders with an inspectable vocabulary
+type VocabularyInspector interface {
+	// Vocabulary returns up to limit terms starting with prefix, sorted alphabetically
+	Vocabulary(prefix string, limit int) []VocabularyTerm
+}
+
+// TextAnalyzer is implemented by embedders that can explain their embeddings
+// Analyze must not change the embedder state
+type TextAnalyzer interface {
+	Analyze(text string, top int) (*Analysis, error)
+}
+
+// TopDimensions returns the top non-zero dimensions of an embedding by absolute weight
+// terms, if not nil, names the terms contributing to a dimension
+func TopDimensions(embedding []float64, top int, terms func(index int) []string) []DimensionWeight {
+	dims := make([]DimensionWeight, 0)
+	for i, weight := range embedding {
+		if weight == 0 {
+			continue
+		}
+		dims = append(dims, DimensionWeight{Index: i, Weight: weight})
+	}
+
+	sort.Slice(dims, func(i, j int) bool {
+		return math.Abs(dims[i].Weight) > math.Abs(dims[j].Weight)
+	})
+
+	if top > 0 && len(dims) > top {
+		dims = dims[:top]
+	}
+
+	if terms != nil {
+		for i := range dims {
+			dims[i].Terms = terms(dims[i].Index)
+		}
+	}
+
+	return dims
+}
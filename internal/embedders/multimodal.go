@@ -15,3 +15,10 @@ type MultiModalEmbedder interface {
 	// Dimensions returns the embedding dimension
 	Dimensions() int
 }
+
+// ImageFormatLister is implemented by image embedders that only decode some
+// image formats, so sources can skip the files they would fail on
+type ImageFormatLister interface {
+	// ImageExtensions returns the lowercase file extensions, with the dot, it can decode
+	ImageExtensions() []string
+}
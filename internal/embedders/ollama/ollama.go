@@ -0,0 +1,144 @@
+// Package ollama embeds text with the models of a local Ollama server, for
+// environments that cannot reach hosted embedding APIs
+package ollama
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultHost  = "http://localhost:11434"
+	DefaultModel = "nomic-embed-text"
+)
+
+// nativeDimensions are the sizes of the vectors of common Ollama embedding models
+var nativeDimensions = map[string]int{
+	"nomic-embed-text":  768,
+	"mxbai-embed-large": 1024,
+	"all-minilm":        384,
+}
+
+// Options configures an Embedder
+type Options struct {
+	// Host is the address of the Ollama server, DefaultHost when empty. As with the
+	// ollama CLI, the scheme may be left out, as in 127.0.0.1:11434
+	Host  string
+	Model string // DefaultModel when empty
+}
+
+// OptionsFromEnv reads the options from OLLAMA_HOST and OLLAMA_MODEL
+func OptionsFromEnv() Options {
+	return Options{
+		Host:  os.Getenv("OLLAMA_HOST"),
+		Model: os.Getenv("OLLAMA_MODEL"),
+	}
+}
+
+type Embedder struct {
+	host       string
+	model      string
+	httpClient *http.Client
+}
+
+type EmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+type EmbeddingResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// errorResponse is the body of the failed requests of the server
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewOllamaEmbedder(opts Options) *Embedder {
+	host := opts.Host
+	if host == "" {
+		host = DefaultHost
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	model := opts.Model
+	if model == "" {
+		model = DefaultModel
+	}
+
+	return &Embedder{
+		host:  strings.TrimRight(host, "/"),
+		model: model,
+		// Models are loaded into memory on their first request, which can take a while
+		httpClient: &http.Client{
+			Timeout: 2 * time.Minute,
+		},
+	}
+}
+
+func (o *Embedder) Embed(text string) ([]float64, error) {
+	jsonData, err := json.Marshal(EmbeddingRequest{Model: o.model, Prompt: text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", o.host+"/api/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("no Ollama server is listening at %s: start it with `ollama serve` and pull the model with `ollama pull %s`, or point OLLAMA_HOST at a running server: %w", o.host, o.model, err)
+		}
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var apiErr errorResponse
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, apiErr.Error)
+		}
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var embeddingResponse EmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Models that cannot embed answer with an empty embedding rather than an error
+	if len(embeddingResponse.Embedding) == 0 {
+		return nil, fmt.Errorf("no embedding returned, check that %s is an embedding model", o.model)
+	}
+
+	return embeddingResponse.Embedding, nil
+}
+
+func (o *Embedder) Name() string {
+	return "ollama"
+}
+
+// Model returns the Ollama model embedding the text
+func (o *Embedder) Model() string {
+	return o.model
+}
+
+// Dimensions returns the size of the vectors, zero when the model is not known
+func (o *Embedder) Dimensions() int {
+	return nativeDimensions[strings.TrimSuffix(o.model, ":latest")]
+}
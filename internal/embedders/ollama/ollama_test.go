@@ -0,0 +1,76 @@
+package ollama
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEmbedder_Embed(t *testing.T) {
+	var payload EmbeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embeddings" {
+			t.Errorf("requested %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"embedding": [0.5, -1.25, 3e-2, 7]}`))
+	}))
+	defer server.Close()
+
+	// The host may be given without a scheme, as OLLAMA_HOST often is
+	embedder := NewOllamaEmbedder(Options{Host: strings.TrimPrefix(server.URL, "http://")})
+	embedding, err := embedder.Embed("air-gapped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embedding) != 4 || embedding[1] != -1.25 || embedding[2] != 0.03 {
+		t.Errorf("embedding = %v", embedding)
+	}
+	if payload.Model != DefaultModel || payload.Prompt != "air-gapped" {
+		t.Errorf("payload = %+v", payload)
+	}
+	if embedder.Name() != "ollama" || embedder.Dimensions() != 768 {
+		t.Errorf("name %s, dimensions %d", embedder.Name(), embedder.Dimensions())
+	}
+}
+
+func TestEmbedder_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"missing model", http.StatusNotFound, `{"error": "model \"nomic-embed-text\" not found, try pulling it first"}`, "status 404: model \"nomic-embed-text\" not found"},
+		{"empty embedding", http.StatusOK, `{"embedding": []}`, "no embedding returned"},
+		{"invalid json", http.StatusOK, `{"embedding": [1,`, "failed to decode response"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			_, err := NewOllamaEmbedder(Options{Host: server.URL}).Embed("hello")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want %q", err, tc.want)
+			}
+		})
+	}
+
+	// A closed port explains how to start Ollama
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := listener.Addr().String()
+	listener.Close()
+	_, err = NewOllamaEmbedder(Options{Host: host, Model: "mxbai-embed-large"}).Embed("hello")
+	if err == nil || !strings.Contains(err.Error(), "ollama serve") || !strings.Contains(err.Error(), "ollama pull mxbai-embed-large") {
+		t.Errorf("connection refused: err = %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+// Package openai embeds text with the OpenAI embeddings API, or an Azure OpenAI deployment
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultModel   = "text-embedding-3-small"
+	DefaultBaseURL = "https://api.openai.com/v1"
+)
+
+// nativeDimensions are the sizes of the vectors of the OpenAI embedding models
+var nativeDimensions = map[string]int{
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+}
+
+// Options configures an Embedder
+type Options struct {
+	APIKey string
+	Model  string // DefaultModel when empty
+	// BaseURL is where /embeddings is requested, DefaultBaseURL when empty. For Azure
+	// OpenAI it is the deployment, https://<resource>.openai.azure.com/openai/deployments/<deployment>
+	BaseURL string
+	// APIVersion is the api-version of Azure OpenAI. Setting it sends the key in
+	// the api-key header Azure expects instead of as a bearer token
+	APIVersion string
+	// Dimensions shortens the vectors of the text-embedding-3 models, zero keeps
+	// the size of the model
+	Dimensions int
+}
+
+// OptionsFromEnv reads the options from OPENAI_API_KEY, OPENAI_MODEL, OPENAI_BASE_URL,
+// OPENAI_API_VERSION and OPENAI_DIMENSIONS
+func OptionsFromEnv() (Options, error) {
+	opts := Options{
+		APIKey:     os.Getenv("OPENAI_API_KEY"),
+		Model:      os.Getenv("OPENAI_MODEL"),
+		BaseURL:    os.Getenv("OPENAI_BASE_URL"),
+		APIVersion: os.Getenv("OPENAI_API_VERSION"),
+	}
+	if value := os.Getenv("OPENAI_DIMENSIONS"); value != "" {
+		dimensions, err := strconv.Atoi(value)
+		if err != nil || dimensions <= 0 {
+			return opts, fmt.Errorf("invalid OPENAI_DIMENSIONS %q: must be a positive integer", value)
+		}
+		opts.Dimensions = dimensions
+	}
+	return opts, nil
+}
+
+type Embedder struct {
+	opts       Options
+	httpClient *http.Client
+}
+
+type EmbeddingRequest struct {
+	Input      string `json:"input"`
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions,omitempty"`
+}
+
+type EmbeddingResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+// errorResponse is the body of the failed requests of the API
+type errorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func NewOpenAIEmbedder(opts Options) *Embedder {
+	if opts.Model == "" {
+		opts.Model = DefaultModel
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+
+	return &Embedder{
+		opts: opts,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (o *Embedder) Embed(text string) ([]float64, error) {
+	jsonData, err := json.Marshal(EmbeddingRequest{Input: text, Model: o.opts.Model, Dimensions: o.opts.Dimensions})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := o.opts.BaseURL + "/embeddings"
+	if o.opts.APIVersion != "" {
+		endpoint += "?api-version=" + url.QueryEscape(o.opts.APIVersion)
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if o.opts.APIVersion != "" {
+		req.Header.Set("api-key", o.opts.APIKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+o.opts.APIKey)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var apiErr errorResponse
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var embeddingResponse EmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(embeddingResponse.Data) == 0 || len(embeddingResponse.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("no embeddings returned")
+	}
+
+	return embeddingResponse.Data[0].Embedding, nil
+}
+
+func (o *Embedder) Name() string {
+	return "openai"
+}
+
+// Model returns the OpenAI model embedding the text
+func (o *Embedder) Model() string {
+	return o.opts.Model
+}
+
+// Dimensions returns the size of the vectors, zero when the model is not known
+func (o *Embedder) Dimensions() int {
+	if o.opts.Dimensions > 0 {
+		return o.opts.Dimensions
+	}
+	return nativeDimensions[o.opts.Model]
+}
@@ -0,0 +1,119 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serve returns an embedder requesting a server that answers with status and body,
+// and the requests it received
+func serve(t *testing.T, opts Options, status int, body string) (*Embedder, *[]*http.Request, *[]EmbeddingRequest) {
+	t.Helper()
+	var requests []*http.Request
+	var payloads []EmbeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload EmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		requests = append(requests, r)
+		payloads = append(payloads, payload)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	opts.BaseURL = server.URL + "/v1/"
+	return NewOpenAIEmbedder(opts), &requests, &payloads
+}
+
+func TestEmbedder_Embed(t *testing.T) {
+	embedder, requests, payloads := serve(t, Options{APIKey: "sk-test"}, http.StatusOK,
+		`{"object": "list", "data": [{"object": "embedding", "index": 0, "embedding": [0.1, -0.2, 0.3]}], "model": "text-embedding-3-small"}`)
+
+	embedding, err := embedder.Embed("hello world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embedding) != 3 || embedding[1] != -0.2 {
+		t.Errorf("embedding = %v", embedding)
+	}
+
+	r, payload := (*requests)[0], (*payloads)[0]
+	if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer sk-test" {
+		t.Errorf("requested %s with authorization %q", r.URL, r.Header.Get("Authorization"))
+	}
+	if payload.Input != "hello world" || payload.Model != DefaultModel || payload.Dimensions != 0 {
+		t.Errorf("payload = %+v", payload)
+	}
+	if embedder.Name() != "openai" || embedder.Model() != DefaultModel || embedder.Dimensions() != 1536 {
+		t.Errorf("name %s, model %s, dimensions %d", embedder.Name(), embedder.Model(), embedder.Dimensions())
+	}
+}
+
+func TestEmbedder_Azure(t *testing.T) {
+	embedder, requests, payloads := serve(t, Options{APIKey: "azure-key", APIVersion: "2024-02-01", Model: "text-embedding-3-large", Dimensions: 256}, http.StatusOK,
+		`{"data": [{"embedding": [1, 2]}]}`)
+
+	if _, err := embedder.Embed("hello"); err != nil {
+		t.Fatal(err)
+	}
+	r := (*requests)[0]
+	if r.URL.Query().Get("api-version") != "2024-02-01" || r.Header.Get("api-key") != "azure-key" || r.Header.Get("Authorization") != "" {
+		t.Errorf("requested %s with headers %v", r.URL, r.Header)
+	}
+	if payload := (*payloads)[0]; payload.Model != "text-embedding-3-large" || payload.Dimensions != 256 {
+		t.Errorf("payload = %+v", payload)
+	}
+	if embedder.Dimensions() != 256 {
+		t.Errorf("dimensions = %d", embedder.Dimensions())
+	}
+}
+
+func TestEmbedder_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"api error", http.StatusUnauthorized, `{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error"}}`, "status 401: Incorrect API key provided"},
+		{"plain error", http.StatusBadGateway, `upstream unavailable`, "status 502: upstream unavailable"},
+		{"no data", http.StatusOK, `{"data": []}`, "no embeddings returned"},
+		{"empty embedding", http.StatusOK, `{"data": [{"embedding": []}]}`, "no embeddings returned"},
+		{"invalid json", http.StatusOK, `{"data": [`, "failed to decode response"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			embedder, _, _ := serve(t, Options{APIKey: "sk-test"}, tc.status, tc.body)
+			embedding, err := embedder.Embed("hello")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("embedding %v, err = %v, want %q", embedding, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-env")
+	t.Setenv("OPENAI_MODEL", "text-embedding-3-large")
+	t.Setenv("OPENAI_BASE_URL", "https://example.openai.azure.com/openai/deployments/embed")
+	t.Setenv("OPENAI_API_VERSION", "2024-02-01")
+	t.Setenv("OPENAI_DIMENSIONS", "512")
+
+	opts, err := OptionsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Options{APIKey: "sk-env", Model: "text-embedding-3-large", BaseURL: "https://example.openai.azure.com/openai/deployments/embed", APIVersion: "2024-02-01", Dimensions: 512}
+	if opts != want {
+		t.Errorf("options = %+v", opts)
+	}
+
+	t.Setenv("OPENAI_DIMENSIONS", "-1")
+	if _, err := OptionsFromEnv(); err == nil {
+		t.Error("negative dimensions accepted")
+	}
+}
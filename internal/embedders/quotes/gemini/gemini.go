@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/tahcohcat/same-same/internal/embedders"
@@ -18,6 +19,7 @@ type GeminiEmbedder struct {
 	apiKey     string
 	httpClient *http.Client
 	baseURL    string
+	retry      embedders.RetryPolicy
 }
 
 type EmbedRequest struct {
@@ -37,6 +39,18 @@ type EmbedResponse struct {
 	Embedding Embedding `json:"embedding"`
 }
 
+// BatchEmbedRequest is the body of batchEmbedContents, one request per text
+type BatchEmbedRequest struct {
+	Requests []EmbedRequest `json:"requests"`
+}
+
+type BatchEmbedResponse struct {
+	Embeddings []Embedding `json:"embeddings"`
+}
+
+// maxBatchSize is the most texts batchEmbedContents accepts in a request
+const maxBatchSize = 100
+
 type Embedding struct {
 	Values []float64 `json:"values"`
 }
@@ -48,6 +62,7 @@ func NewGeminiEmbedder(apiKey string) embedders.Embedder {
 			Timeout: 30 * time.Second,
 		},
 		baseURL: "https://generativelanguage.googleapis.com/v1beta/models/gemini-embedding-001:embedContent",
+		retry:   embedders.DefaultRetryPolicy,
 	}
 }
 
@@ -75,17 +90,9 @@ func (g *GeminiEmbedder) Embed(text string) ([]float64, error) {
 	q.Set("key", g.apiKey)
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequest("POST", u.String(), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
 	logrus.WithField("google-api-key", g.apiKey).Infof("Sending request to Gemini API: %s", u.String())
 
-	//req.Header.Set("x-google-api-key", g.apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := g.httpClient.Do(req)
+	resp, err := g.post(u.String(), jsonData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -104,6 +111,101 @@ func (g *GeminiEmbedder) Embed(text string) ([]float64, error) {
 	return embedResponse.Embedding.Values, nil
 }
 
+// EmbedBatch embeds texts with batchEmbedContents, in requests of up to 100 texts
+func (g *GeminiEmbedder) EmbedBatch(texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += maxBatchSize {
+		end := min(start+maxBatchSize, len(texts))
+		values, err := g.embedBatch(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, values...)
+	}
+
+	failed := &embedders.BatchError{Errors: make(map[int]error)}
+	for i, values := range embeddings {
+		if len(values) == 0 {
+			failed.Errors[i] = fmt.Errorf("no embeddings returned")
+		}
+	}
+	if len(failed.Errors) > 0 {
+		return embeddings, failed
+	}
+	return embeddings, nil
+}
+
+func (g *GeminiEmbedder) embedBatch(texts []string) ([][]float64, error) {
+	reqBody := BatchEmbedRequest{Requests: make([]EmbedRequest, len(texts))}
+	for i, text := range texts {
+		// Each request must name the model of the endpoint
+		reqBody.Requests[i] = EmbedRequest{
+			Model:   "models/" + g.Model(),
+			Content: Content{Parts: []Part{{Text: text}}},
+		}
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(g.baseURL, ":embedContent") + ":batchEmbedContents")
+	if err != nil {
+		return nil, fmt.Errorf("invalid API URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("key", g.apiKey)
+	u.RawQuery = q.Encode()
+
+	resp, err := g.post(u.String(), jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var batchResponse BatchEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batchResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(batchResponse.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("API returned %d embeddings for %d texts", len(batchResponse.Embeddings), len(texts))
+	}
+
+	embeddings := make([][]float64, len(texts))
+	for i, embedding := range batchResponse.Embeddings {
+		embeddings[i] = embedding.Values
+	}
+	return embeddings, nil
+}
+
+// post sends a JSON body to the API, retrying as the retry policy allows
+func (g *GeminiEmbedder) post(endpoint string, jsonData []byte) (*http.Response, error) {
+	return g.retry.Do(g.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+}
+
+// SetRetryPolicy sets how requests answered with 429 or a 5xx status are retried
+func (g *GeminiEmbedder) SetRetryPolicy(policy embedders.RetryPolicy) {
+	g.retry = policy
+}
+
 func (g *GeminiEmbedder) Name() string {
 	return "gemini"
 }
+
+// Model returns the Gemini model embedding the text
+func (g *GeminiEmbedder) Model() string {
+	return "gemini-embedding-001"
+}
@@ -0,0 +1,105 @@
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+)
+
+func TestGeminiEmbedder_EmbedBatch(t *testing.T) {
+	var requests []BatchEmbedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/gemini-embedding-001:batchEmbedContents") || r.URL.Query().Get("key") != "test-key" {
+			t.Errorf("requested %s", r.URL)
+		}
+		var payload BatchEmbedRequest
+		json.NewDecoder(r.Body).Decode(&payload)
+		requests = append(requests, payload)
+
+		var response BatchEmbedResponse
+		for _, request := range payload.Requests {
+			values := []float64{float64(len(request.Content.Parts[0].Text)), 1}
+			if request.Content.Parts[0].Text == "blocked" {
+				values = nil
+			}
+			response.Embeddings = append(response.Embeddings, Embedding{Values: values})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	embedder := NewGeminiEmbedder("test-key").(*GeminiEmbedder)
+	embedder.baseURL = server.URL + "/v1beta/models/gemini-embedding-001:embedContent"
+
+	// Texts past the limit of a request are sent in another
+	texts := make([]string, maxBatchSize+2)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text %d", i)
+	}
+	texts[1] = "blocked"
+	embeddings, err := embedder.EmbedBatch(texts)
+	var batchErr *embedders.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 1 || batchErr.Errors[1] == nil {
+		t.Fatalf("err = %v, want the second text failing", err)
+	}
+	if len(requests) != 2 || len(requests[0].Requests) != maxBatchSize || len(requests[1].Requests) != 2 {
+		t.Fatalf("sent %d requests", len(requests))
+	}
+	if requests[0].Requests[0].Model != "models/gemini-embedding-001" {
+		t.Errorf("request model = %s", requests[0].Requests[0].Model)
+	}
+	if len(embeddings) != len(texts) || embeddings[0][0] != 6 || embeddings[maxBatchSize+1][0] != 8 {
+		t.Errorf("embeddings out of order: %v", embeddings[0])
+	}
+}
+
+func TestGeminiEmbedder_EmbedBatchError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "API key not valid"}}`))
+	}))
+	defer server.Close()
+
+	embedder := NewGeminiEmbedder("bad-key").(*GeminiEmbedder)
+	embedder.baseURL = server.URL + "/models/gemini-embedding-001:embedContent"
+	if _, err := embedder.EmbedBatch([]string{"a", "b"}); err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestGeminiEmbedder_Retry(t *testing.T) {
+	// Rate limited twice, then answered
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"code": 429, "status": "RESOURCE_EXHAUSTED"}}`))
+			return
+		}
+		w.Write([]byte(`{"embedding": {"values": [0.25, 0.5]}}`))
+	}))
+	defer server.Close()
+
+	embedder := NewGeminiEmbedder("test-key").(*GeminiEmbedder)
+	embedder.baseURL = server.URL + "/models/gemini-embedding-001:embedContent"
+	embedder.SetRetryPolicy(embedders.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	embedding, err := embedder.Embed("hello")
+	if err != nil || len(embedding) != 2 || requests != 3 {
+		t.Fatalf("embedding %v after %d requests, err = %v", embedding, requests, err)
+	}
+
+	// Without retries the first 429 fails the request
+	requests = 0
+	embedder.SetRetryPolicy(embedders.RetryPolicy{MaxAttempts: 1})
+	if _, err := embedder.Embed("hello"); err == nil || !strings.Contains(err.Error(), "status 429") || requests != 1 {
+		t.Errorf("after %d requests, err = %v", requests, err)
+	}
+}
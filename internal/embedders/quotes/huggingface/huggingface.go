@@ -11,13 +11,9 @@ import (
 	"github.com/tahcohcat/same-same/internal/embedders"
 )
 
+// EmbeddingRequest is the body of a feature-extraction request, one input per text
 type EmbeddingRequest struct {
-	Inputs Input `json:"inputs"`
-}
-
-type Input struct {
-	Source    string   `json:"source_sentence"`
-	Sentences []string `json:"sentences"`
+	Inputs []string `json:"inputs"`
 }
 
 type Embedder struct {
@@ -25,6 +21,7 @@ type Embedder struct {
 	httpClient *http.Client
 	baseURL    string
 	model      string
+	retry      embedders.RetryPolicy
 }
 
 func NewHuggingFaceEmbedder(apiKey string) embedders.Embedder {
@@ -33,34 +30,39 @@ func NewHuggingFaceEmbedder(apiKey string) embedders.Embedder {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		baseURL: "https://api-inference.huggingface.co/models",
+		baseURL: "https://api-inference.huggingface.co/pipeline/feature-extraction",
 		model:   "sentence-transformers/all-MiniLM-L6-v2",
+		retry:   embedders.DefaultRetryPolicy,
 	}
 }
 
 func (h *Embedder) Embed(text string) ([]float64, error) {
-	reqBody := EmbeddingRequest{
-		Inputs: Input{
-			Source:    text,
-			Sentences: []string{text},
-		},
+	embeddings, err := h.EmbedBatch([]string{text})
+	if err != nil {
+		return nil, err
 	}
+	return embeddings[0], nil
+}
 
-	jsonData, err := json.Marshal(reqBody)
+// EmbedBatch embeds texts with one feature-extraction request, which returns the
+// sentence embedding of each input in order
+func (h *Embedder) EmbedBatch(texts []string) ([][]float64, error) {
+	jsonData, err := json.Marshal(EmbeddingRequest{Inputs: texts})
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	// A model that is not loaded answers 503 until it is, which the retries wait out
 	url := fmt.Sprintf("%s/%s", h.baseURL, h.model)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", h.apiKey))
-
-	resp, err := h.httpClient.Do(req)
+	resp, err := h.retry.Do(h.httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", h.apiKey))
+		return req, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -71,18 +73,40 @@ func (h *Embedder) Embed(text string) ([]float64, error) {
 		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var embeddings []float64
+	var embeddings [][]float64
 	if err := json.NewDecoder(resp.Body).Decode(&embeddings); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	if len(embeddings) == 0 {
-		return nil, fmt.Errorf("no embeddings returned")
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("API returned %d embeddings for %d texts", len(embeddings), len(texts))
+	}
+	failed := &embedders.BatchError{Errors: make(map[int]error)}
+	for i, embedding := range embeddings {
+		if len(embedding) == 0 {
+			failed.Errors[i] = fmt.Errorf("no embeddings returned")
+		}
+	}
+	if len(failed.Errors) > 0 {
+		if len(texts) == 1 {
+			return nil, failed.Errors[0]
+		}
+		return embeddings, failed
 	}
 
 	return embeddings, nil
 }
 
+// SetRetryPolicy sets how requests answered with 429 or a 5xx status are retried
+func (h *Embedder) SetRetryPolicy(policy embedders.RetryPolicy) {
+	h.retry = policy
+}
+
 func (h *Embedder) Name() string {
 	return "huggingface"
 }
+
+// Model returns the Inference API model embedding the text
+func (h *Embedder) Model() string {
+	return h.model
+}
@@ -0,0 +1,106 @@
+package huggingface
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+)
+
+// serve returns an embedder requesting a server that answers with body, and the
+// inputs of the requests it received
+func serve(t *testing.T, status int, body string) (*Embedder, *[][]string) {
+	t.Helper()
+	var inputs [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pipeline/feature-extraction/sentence-transformers/all-MiniLM-L6-v2" || r.Header.Get("Authorization") != "Bearer hf-test" {
+			t.Errorf("requested %s", r.URL)
+		}
+		var payload EmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&payload)
+		inputs = append(inputs, payload.Inputs)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	embedder := NewHuggingFaceEmbedder("hf-test").(*Embedder)
+	embedder.baseURL = server.URL + "/pipeline/feature-extraction"
+	embedder.SetRetryPolicy(embedders.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	return embedder, &inputs
+}
+
+func TestEmbedder_EmbedBatch(t *testing.T) {
+	embedder, inputs := serve(t, http.StatusOK, `[[0.1, 0.2], [], [0.5, 0.6]]`)
+
+	embeddings, err := embedder.EmbedBatch([]string{"a", "b", "c"})
+	var batchErr *embedders.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 1 || batchErr.Errors[1] == nil {
+		t.Fatalf("err = %v, want the second text failing", err)
+	}
+	if len(*inputs) != 1 || strings.Join((*inputs)[0], ",") != "a,b,c" {
+		t.Errorf("inputs = %v", *inputs)
+	}
+	if len(embeddings) != 3 || embeddings[2][0] != 0.5 {
+		t.Errorf("embeddings = %v", embeddings)
+	}
+}
+
+func TestEmbedder_Embed(t *testing.T) {
+	embedder, _ := serve(t, http.StatusOK, `[[0.1, 0.2, 0.3]]`)
+	embedding, err := embedder.Embed("hello")
+	if err != nil || len(embedding) != 3 {
+		t.Fatalf("embedding = %v, %v", embedding, err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"api error", http.StatusServiceUnavailable, `{"error": "Model is currently loading"}`, "status 503"},
+		{"empty embedding", http.StatusOK, `[[]]`, "no embeddings returned"},
+		{"missing embeddings", http.StatusOK, `[]`, "API returned 0 embeddings for 1 texts"},
+		{"invalid json", http.StatusOK, `[[0.1,`, "failed to decode response"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			embedder, _ := serve(t, tc.status, tc.body)
+			if _, err := embedder.Embed("hello"); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestEmbedder_Retry(t *testing.T) {
+	// The model loads while the first requests are answered 503, then rate limited
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": "Model sentence-transformers/all-MiniLM-L6-v2 is currently loading", "estimated_time": 20}`))
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`[[0.1, 0.2]]`))
+		}
+	}))
+	defer server.Close()
+
+	embedder := NewHuggingFaceEmbedder("hf-test").(*Embedder)
+	embedder.baseURL = server.URL
+	embedder.SetRetryPolicy(embedders.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	embedding, err := embedder.Embed("hello")
+	if err != nil || len(embedding) != 2 || requests != 3 {
+		t.Errorf("embedding %v after %d requests, err = %v", embedding, requests, err)
+	}
+}
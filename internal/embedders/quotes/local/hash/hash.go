@@ -0,0 +1,192 @@
+package hash
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/synonyms"
+)
+
+const (
+	// DefaultDimension is the number of hash buckets used when none is configured
+	DefaultDimension = 1024
+
+	ngramSize   = 3
+	ngramWeight = 0.5
+)
+
+// HashEmbedder implements a local feature-hashing embedder
+// Words and character n-grams are hashed into a fixed number of buckets,
+// so no vocabulary has to be built and the dimension never changes
+type HashEmbedder struct {
+	dimension int
+	mu        sync.RWMutex
+	synonyms  *synonyms.Set
+}
+
+// feature is a single hashed term with its bucket and weight
+type feature struct {
+	term   string
+	bucket int
+	sign   float64
+	weight float64
+}
+
+// NewHashEmbedder creates a hash embedder with the default dimension
+func NewHashEmbedder() embedders.Embedder {
+	return NewHashEmbedderWithDimension(DefaultDimension)
+}
+
+// NewHashEmbedderWithDimension creates a hash embedder with the given number of buckets
+func NewHashEmbedderWithDimension(dimension int) embedders.Embedder {
+	if dimension <= 0 {
+		dimension = DefaultDimension
+	}
+	return &HashEmbedder{dimension: dimension}
+}
+
+// tokenize lowercases text and splits it into words of letters and digits
+func (h *HashEmbedder) tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Tokenize returns the tokens of text after preprocessing
+func (h *HashEmbedder) Tokenize(text string) []string {
+	return h.tokenize(text)
+}
+
+// features hashes the weighted terms and their character n-grams into buckets
+func (h *HashEmbedder) features(freqs map[string]float64) []feature {
+	features := make([]feature, 0, len(freqs))
+
+	for term, weight := range freqs {
+		features = append(features, h.hashFeature(term, weight))
+
+		// Character n-grams with boundary markers make the embedding
+		// tolerant to small spelling and inflection differences
+		padded := []rune("<" + term + ">")
+		for i := 0; i+ngramSize <= len(padded); i++ {
+			ngram := "#" + string(padded[i:i+ngramSize])
+			features = append(features, h.hashFeature(ngram, weight*ngramWeight))
+		}
+	}
+
+	return features
+}
+
+func (h *HashEmbedder) hashFeature(term string, weight float64) feature {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(term))
+	sum := hasher.Sum32()
+
+	sign := 1.0
+	if sum&(1<<31) != 0 {
+		sign = -1.0
+	}
+
+	return feature{
+		term:   term,
+		bucket: int(sum % uint32(h.dimension)),
+		sign:   sign,
+		weight: weight,
+	}
+}
+
+// Embed converts text to a hashed feature vector
+func (h *HashEmbedder) Embed(text string) ([]float64, error) {
+	return h.embed(text, false), nil
+}
+
+// EmbedQuery converts a search query to a hashed feature vector, expanding synonyms if configured
+func (h *HashEmbedder) EmbedQuery(text string) ([]float64, error) {
+	return h.embed(text, true), nil
+}
+
+func (h *HashEmbedder) embed(text string, query bool) []float64 {
+	h.mu.RLock()
+	set := h.synonyms
+	h.mu.RUnlock()
+
+	tokens := h.tokenize(text)
+
+	var freqs map[string]float64
+	if set != nil && (query || set.ExpandDocuments()) {
+		freqs = set.Expand(tokens)
+	} else {
+		freqs = synonyms.TermFrequencies(tokens)
+	}
+
+	embedding := make([]float64, h.dimension)
+	for _, f := range h.features(freqs) {
+		embedding[f.bucket] += f.sign * f.weight
+	}
+
+	// L2 normalize the vector
+	norm := 0.0
+	for _, val := range embedding {
+		norm += val * val
+	}
+	norm = math.Sqrt(norm)
+
+	if norm > 0 {
+		for i := range embedding {
+			embedding[i] /= norm
+		}
+	}
+
+	return embedding
+}
+
+// Analyze reports the tokens of text, the n-grams hashed into each bucket
+// and the top-weighted buckets of the resulting vector
+func (h *HashEmbedder) Analyze(text string, top int) (*embedders.Analysis, error) {
+	tokens := h.tokenize(text)
+	features := h.features(synonyms.TermFrequencies(tokens))
+
+	buckets := make(map[int][]string)
+	for _, f := range features {
+		buckets[f.bucket] = append(buckets[f.bucket], f.term)
+	}
+	for _, terms := range buckets {
+		sort.Strings(terms)
+	}
+
+	embedding := h.embed(text, false)
+
+	return &embedders.Analysis{
+		Tokens: tokens,
+		TopDimensions: embedders.TopDimensions(embedding, top, func(index int) []string {
+			return buckets[index]
+		}),
+	}, nil
+}
+
+// Dimensions returns the number of hash buckets
+func (h *HashEmbedder) Dimensions() int {
+	return h.dimension
+}
+
+// Synonyms returns the synonym sets used for expansion, or nil
+func (h *HashEmbedder) Synonyms() *synonyms.Set {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.synonyms
+}
+
+// SetSynonyms enables synonym expansion with the given sets (nil disables it)
+func (h *HashEmbedder) SetSynonyms(set *synonyms.Set) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.synonyms = set
+}
+
+func (h *HashEmbedder) Name() string {
+	return "local.hash"
+}
@@ -0,0 +1,93 @@
+package tfidf
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Bootstrap corpus sources reported by BootstrapInfo, other sources are file paths
+const (
+	BootstrapBuiltin  = "builtin"
+	BootstrapDisabled = "disabled"
+)
+
+// ErrNotFitted is returned when embedding with an empty vocabulary and bootstrapping disabled
+var ErrNotFitted = errors.New("tf-idf vocabulary is empty: add documents before embedding or configure a bootstrap corpus")
+
+// builtinBootstrap seeds the vocabulary of embedders without a bootstrap file,
+// together with the first text they embed
+var builtinBootstrap = []string{
+	"life work time people world way things make know think feel see",
+	"love good great true real best better never always friend",
+	"success failure happiness wisdom knowledge learning education",
+	"truth justice freedom equality peace war change progress",
+}
+
+// Options configures how a TF-IDF embedder seeds its vocabulary
+type Options struct {
+	// BootstrapPath is a text file with one document per line, or a JSONL file
+	// (.jsonl or .ndjson) of objects with a "text" field, loaded at construction
+	BootstrapPath string
+
+	// DisableBootstrap leaves the vocabulary empty until documents are added,
+	// embedding before then fails with ErrNotFitted
+	DisableBootstrap bool
+}
+
+// OptionsFromEnv reads the options from TFIDF_BOOTSTRAP_PATH and TFIDF_BOOTSTRAP_DISABLED
+func OptionsFromEnv() Options {
+	return Options{
+		BootstrapPath:    os.Getenv("TFIDF_BOOTSTRAP_PATH"),
+		DisableBootstrap: os.Getenv("TFIDF_BOOTSTRAP_DISABLED") == "true",
+	}
+}
+
+// BootstrapInfo describes the corpus the vocabulary was seeded with
+type BootstrapInfo struct {
+	Source    string `json:"source"` // File path, builtin or disabled
+	Documents int    `json:"documents"`
+}
+
+// LoadBootstrapCorpus reads the documents of a bootstrap file, skipping blank lines
+func LoadBootstrapCorpus(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bootstrap corpus: %w", err)
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	jsonl := ext == ".jsonl" || ext == ".ndjson"
+
+	docs := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if jsonl {
+			var record struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal([]byte(text), &record); err != nil {
+				return nil, fmt.Errorf("bootstrap corpus %s line %d: %w", path, line, err)
+			}
+			if record.Text == "" {
+				continue
+			}
+			text = record.Text
+		}
+		docs = append(docs, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read bootstrap corpus: %w", err)
+	}
+	return docs, nil
+}
@@ -0,0 +1,152 @@
+package tfidf
+
+import (
+	"github.com/tahcohcat/same-same/internal/embedders"
+)
+
+// Fuzzy matching of query terms missing from the vocabulary, with precomputed deletes
+// as in SymSpell: a term and a token within the edit distance share a deletion of at
+// most that many characters, so candidates are found by lookup instead of comparing
+// the token with every term
+const (
+	// correctionWeight scales the frequency a corrected token lends its vocabulary term
+	correctionWeight = 0.7
+
+	// Tokens shorter than minCorrectedLength are not corrected, tokens shorter than
+	// longTokenLength only within one edit
+	minCorrectedLength = 4
+	longTokenLength    = 6
+	maxEditDistance    = 2
+
+	// Deletes are only generated from the first prefixLength characters of a term,
+	// candidates being checked on the whole term
+	prefixLength = 7
+)
+
+// fuzzyIndex maps the deletes of the vocabulary terms to the terms
+type fuzzyIndex struct {
+	deletes map[string][]string
+}
+
+// newFuzzyIndex precomputes the deletes of the terms of vocabulary
+func newFuzzyIndex(vocabulary map[string]int) *fuzzyIndex {
+	index := &fuzzyIndex{deletes: make(map[string][]string, len(vocabulary)*8)}
+	for term := range vocabulary {
+		for variant := range deletes(prefix(term), maxEditDistance) {
+			index.deletes[variant] = append(index.deletes[variant], term)
+		}
+	}
+	return index
+}
+
+// correct returns the vocabulary term closest to token, preferring the terms in most
+// documents (lowest IDF) among equally close ones. ok is false when none is close enough
+func (index *fuzzyIndex) correct(token string, vocabulary map[string]int, idf []float64) (correction embedders.Correction, ok bool) {
+	if index == nil || len(token) < minCorrectedLength {
+		return correction, false
+	}
+	maxDistance := 1
+	if len(token) >= longTokenLength {
+		maxDistance = maxEditDistance
+	}
+
+	best := embedders.Correction{From: token, Distance: maxDistance + 1}
+	seen := make(map[string]bool)
+	for variant := range deletes(prefix(token), maxDistance) {
+		for _, term := range index.deletes[variant] {
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+
+			distance := editDistance(token, term, maxDistance)
+			if distance > maxDistance || distance > best.Distance {
+				continue
+			}
+			if distance == best.Distance && !closer(term, best.To, vocabulary, idf) {
+				continue
+			}
+			best.To, best.Distance = term, distance
+		}
+	}
+	return best, best.To != ""
+}
+
+// closer reports whether term should be preferred to best, a vocabulary term as close to the token
+func closer(term, best string, vocabulary map[string]int, idf []float64) bool {
+	if best == "" {
+		return true
+	}
+	if a, b := idf[vocabulary[term]], idf[vocabulary[best]]; a != b {
+		return a < b
+	}
+	return term < best
+}
+
+// prefix returns the characters of term deletes are generated from
+func prefix(term string) string {
+	if len(term) > prefixLength {
+		return term[:prefixLength]
+	}
+	return term
+}
+
+// deletes returns term and the strings left by deleting up to distance of its characters
+// Tokens are lowercase ASCII after preprocessing, so bytes are characters
+func deletes(term string, distance int) map[string]bool {
+	variants := map[string]bool{term: true}
+	frontier := []string{term}
+	for d := 0; d < distance; d++ {
+		next := make([]string, 0)
+		for _, word := range frontier {
+			if len(word) <= 1 {
+				continue
+			}
+			for i := range word {
+				variant := word[:i] + word[i+1:]
+				if !variants[variant] {
+					variants[variant] = true
+					next = append(next, variant)
+				}
+			}
+		}
+		frontier = next
+	}
+	return variants
+}
+
+// editDistance returns the optimal string alignment distance of a and b, where swapping
+// two adjacent characters counts as one edit, or max+1 once it exceeds max
+func editDistance(a, b string, max int) int {
+	if diff := len(a) - len(b); diff > max || -diff > max {
+		return max + 1
+	}
+
+	// Rows of the distance matrix for the prefixes of a ending two, one and zero characters back
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(prev[j]+1, current[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				current[j] = min(current[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, current = prev, current, prev2
+	}
+	return min(prev[len(b)], max+1)
+}
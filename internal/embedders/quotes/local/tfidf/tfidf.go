@@ -1,6 +1,7 @@
 package tfidf
 
 import (
+	"fmt"
 	"math"
 	"regexp"
 	"sort"
@@ -8,18 +9,42 @@ import (
 	"sync"
 
 	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/synonyms"
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// Embedded texts join the corpus, which rebuilds the vocabulary every rebuildEvery
+// embeddings and every rebuildEveryAdded documents added
+const (
+	rebuildEvery      = 50
+	rebuildEveryAdded = 100
 )
 
 // TFIDFEmbedder implements a local TF-IDF based embedder
 // This provides a simple, zero-dependency embedding solution
+//
+// The vocabulary is rebuilt as the corpus grows in a background goroutine, off
+// the lock: embeddings keep using the current vocabulary until the new one is swapped in
 type TFIDFEmbedder struct {
 	vocabulary  map[string]int // word -> index mapping
 	idf         []float64      // inverse document frequency for each term
+	fuzzy       *fuzzyIndex    // deletes of the vocabulary terms, for misspelled query terms
+	builtFrom   int            // documents the vocabulary was built from
 	mu          sync.RWMutex
 	documents   []string // corpus for IDF calculation
-	minDf       int      // minimum document frequency
-	maxDf       float64  // maximum document frequency ratio
-	maxFeatures int      // maximum vocabulary size
+	docsMu      sync.Mutex
+	minDf       int     // minimum document frequency
+	maxDf       float64 // maximum document frequency ratio
+	maxFeatures int     // maximum vocabulary size
+	synonyms    *synonyms.Set
+	bootstrap   BootstrapInfo
+	fixed       bool       // Fitted by Fit or loaded, embedded texts no longer join the corpus
+	fitMu       sync.Mutex // Held by Fit, so that texts embedded meanwhile wait for the vocabulary
+
+	rebuilds     chan struct{} // Pending background rebuild, coalescing triggers
+	rebuildsDone chan struct{} // Closed when the background goroutine returns
+	rebuildsMu   sync.Mutex
+	closed       bool // Closed by Close, no more background rebuilds
 }
 
 // NewTFIDFEmbedder creates a new TF-IDF embedder
@@ -30,9 +55,40 @@ func NewTFIDFEmbedder() embedders.Embedder {
 		minDf:       1,
 		maxDf:       0.95,
 		maxFeatures: 5000,
+		bootstrap:   BootstrapInfo{Source: BootstrapBuiltin, Documents: len(builtinBootstrap)},
 	}
 }
 
+// NewTFIDFEmbedderWithOptions creates a TF-IDF embedder seeding its vocabulary as
+// configured: from the bootstrap file, not at all, or else from a builtin corpus
+// of common English words when the first text is embedded
+func NewTFIDFEmbedderWithOptions(opts Options) (embedders.Embedder, error) {
+	t := NewTFIDFEmbedder().(*TFIDFEmbedder)
+
+	switch {
+	case opts.DisableBootstrap:
+		t.bootstrap = BootstrapInfo{Source: BootstrapDisabled}
+	case opts.BootstrapPath != "":
+		docs, err := LoadBootstrapCorpus(opts.BootstrapPath)
+		if err != nil {
+			return nil, err
+		}
+		t.documents = append(t.documents, docs...)
+		t.rebuild()
+		if len(t.vocabulary) == 0 {
+			return nil, fmt.Errorf("bootstrap corpus %s yields an empty vocabulary (%d documents)", opts.BootstrapPath, len(docs))
+		}
+		t.bootstrap = BootstrapInfo{Source: opts.BootstrapPath, Documents: len(docs)}
+	}
+
+	return t, nil
+}
+
+// NewTFIDFEmbedderFromEnv creates a TF-IDF embedder with the options of OptionsFromEnv
+func NewTFIDFEmbedderFromEnv() (embedders.Embedder, error) {
+	return NewTFIDFEmbedderWithOptions(OptionsFromEnv())
+}
+
 // NewTFIDFEmbedderWithConfig creates a configured TF-IDF embedder
 func NewTFIDFEmbedderWithConfig(minDf int, maxDf float64, maxFeatures int) embedders.Embedder {
 	return &TFIDFEmbedder{
@@ -41,6 +97,7 @@ func NewTFIDFEmbedderWithConfig(minDf int, maxDf float64, maxFeatures int) embed
 		minDf:       minDf,
 		maxDf:       maxDf,
 		maxFeatures: maxFeatures,
+		bootstrap:   BootstrapInfo{Source: BootstrapBuiltin, Documents: len(builtinBootstrap)},
 	}
 }
 
@@ -82,12 +139,19 @@ func (t *TFIDFEmbedder) preprocessText(text string) []string {
 	return filtered
 }
 
-// buildVocabulary creates vocabulary from the document corpus
-func (t *TFIDFEmbedder) buildVocabulary() {
+// Tokenize returns the tokens of text after preprocessing
+func (t *TFIDFEmbedder) Tokenize(text string) []string {
+	return t.preprocessText(text)
+}
+
+// buildVocabulary creates a vocabulary, its IDF values and the fuzzy index of its terms
+// from a document corpus
+// It only reads the configuration of the embedder, so it runs without the lock
+func (t *TFIDFEmbedder) buildVocabulary(documents []string) (map[string]int, []float64, *fuzzyIndex) {
 	// Count document frequency for each term
 	termDocFreq := make(map[string]int)
 
-	for _, doc := range t.documents {
+	for _, doc := range documents {
 		words := t.preprocessText(doc)
 		seen := make(map[string]bool)
 
@@ -100,7 +164,7 @@ func (t *TFIDFEmbedder) buildVocabulary() {
 	}
 
 	// Filter terms by document frequency
-	numDocs := len(t.documents)
+	numDocs := len(documents)
 	validTerms := make([]string, 0)
 
 	for term, df := range termDocFreq {
@@ -110,8 +174,12 @@ func (t *TFIDFEmbedder) buildVocabulary() {
 	}
 
 	// Sort terms by frequency (descending) and take top features
+	// Ties are broken by term, so that a corpus always yields the same indexes
 	sort.Slice(validTerms, func(i, j int) bool {
-		return termDocFreq[validTerms[i]] > termDocFreq[validTerms[j]]
+		if termDocFreq[validTerms[i]] != termDocFreq[validTerms[j]] {
+			return termDocFreq[validTerms[i]] > termDocFreq[validTerms[j]]
+		}
+		return validTerms[i] < validTerms[j]
 	})
 
 	if len(validTerms) > t.maxFeatures {
@@ -119,77 +187,277 @@ func (t *TFIDFEmbedder) buildVocabulary() {
 	}
 
 	// Build vocabulary mapping
-	t.vocabulary = make(map[string]int)
+	vocabulary := make(map[string]int)
 	for i, term := range validTerms {
-		t.vocabulary[term] = i
+		vocabulary[term] = i
 	}
 
 	// Calculate IDF values
-	t.idf = make([]float64, len(t.vocabulary))
-	for term, idx := range t.vocabulary {
+	idf := make([]float64, len(vocabulary))
+	for term, idx := range vocabulary {
 		df := termDocFreq[term]
-		t.idf[idx] = math.Log(float64(numDocs)/float64(df)) + 1.0
+		idf[idx] = math.Log(float64(numDocs)/float64(df)) + 1.0
 	}
+
+	return vocabulary, idf, newFuzzyIndex(vocabulary)
 }
 
-// AddDocument adds a document to the corpus for vocabulary building
-func (t *TFIDFEmbedder) AddDocument(text string) {
+// corpus returns the documents added so far
+// Documents are only appended, so the returned slice can be read without the lock
+func (t *TFIDFEmbedder) corpus() []string {
+	t.docsMu.Lock()
+	defer t.docsMu.Unlock()
+	return t.documents[:len(t.documents):len(t.documents)]
+}
+
+// rebuild builds the vocabulary of the current corpus and swaps it in, unless a
+// vocabulary of a larger corpus was swapped in meanwhile
+func (t *TFIDFEmbedder) rebuild() {
+	documents := t.corpus()
+	vocabulary, idf, fuzzy := t.buildVocabulary(documents)
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	if len(documents) < t.builtFrom {
+		return
+	}
+	t.vocabulary, t.idf, t.fuzzy, t.builtFrom = vocabulary, idf, fuzzy, len(documents)
+}
 
-	t.documents = append(t.documents, text)
+// scheduleRebuild asks the background goroutine to rebuild the vocabulary, starting
+// it on the first request. A request made while one is pending is merged into it
+func (t *TFIDFEmbedder) scheduleRebuild() {
+	t.rebuildsMu.Lock()
+	defer t.rebuildsMu.Unlock()
+	if t.closed {
+		return
+	}
+
+	if t.rebuilds == nil {
+		rebuilds, done := make(chan struct{}, 1), make(chan struct{})
+		t.rebuilds, t.rebuildsDone = rebuilds, done
+		go func() {
+			defer close(done)
+			for range rebuilds {
+				t.rebuild()
+			}
+		}()
+	}
 
-	// Rebuild vocabulary if we have enough documents
-	if len(t.documents)%100 == 0 || len(t.vocabulary) == 0 {
-		t.buildVocabulary()
+	select {
+	case t.rebuilds <- struct{}{}:
+	default:
 	}
 }
 
-// AddDocuments adds multiple documents at once
-func (t *TFIDFEmbedder) AddDocuments(texts []string) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
+// Close stops the background rebuilds, waiting for one in progress. The embedder
+// keeps working, without rebuilding its vocabulary in the background
+func (t *TFIDFEmbedder) Close() error {
+	t.rebuildsMu.Lock()
+	if t.closed {
+		t.rebuildsMu.Unlock()
+		return nil
+	}
+	t.closed = true
+	done := t.rebuildsDone
+	if t.rebuilds != nil {
+		close(t.rebuilds)
+	}
+	t.rebuildsMu.Unlock()
 
+	if done != nil {
+		<-done
+	}
+	return nil
+}
+
+// add appends texts to the corpus and reports whether it crossed a multiple of every documents
+func (t *TFIDFEmbedder) add(every int, texts ...string) bool {
+	t.docsMu.Lock()
+	defer t.docsMu.Unlock()
+
+	before := len(t.documents)
 	t.documents = append(t.documents, texts...)
-	t.buildVocabulary()
+	return len(t.documents)/every != before/every
+}
+
+// fitted reports whether the embedder has a vocabulary
+func (t *TFIDFEmbedder) fitted() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.vocabulary) > 0
+}
+
+// AddDocument adds a document to the corpus for vocabulary building
+func (t *TFIDFEmbedder) AddDocument(text string) {
+	crossed := t.add(rebuildEveryAdded, text)
+
+	// Fit the vocabulary right away, then rebuild it once we have enough documents
+	switch {
+	case !t.fitted():
+		t.rebuild()
+	case crossed:
+		t.scheduleRebuild()
+	}
+}
+
+// AddDocuments adds multiple documents at once, rebuilding the vocabulary before it returns
+func (t *TFIDFEmbedder) AddDocuments(texts []string) {
+	t.add(rebuildEveryAdded, texts...)
+	t.rebuild()
 }
 
 // Embed converts text to TF-IDF vector
 func (t *TFIDFEmbedder) Embed(text string) ([]float64, error) {
-	t.mu.Lock() // Use write lock for potential vocabulary building
-	defer t.mu.Unlock()
+	embedding, _, err := t.embed(text, false, false)
+	return embedding, err
+}
 
-	// Bootstrap vocabulary if empty
-	if len(t.vocabulary) == 0 {
-		// Build initial vocabulary with common terms and current text
-		bootstrapDocs := []string{
-			text, // Current text
-			// Common meaningful words to bootstrap vocabulary
-			"life work time people world way things make know think feel see",
-			"love good great true real best better never always friend",
-			"success failure happiness wisdom knowledge learning education",
-			"truth justice freedom equality peace war change progress",
-		}
-		t.documents = append(t.documents, bootstrapDocs...)
-		t.buildVocabulary()
-	} else {
-		// Add document to corpus for future vocabulary updates
-		t.documents = append(t.documents, text)
+// EmbedQuery converts a search query to a TF-IDF vector, expanding synonyms if configured
+func (t *TFIDFEmbedder) EmbedQuery(text string) ([]float64, error) {
+	embedding, _, err := t.embed(text, true, false)
+	return embedding, err
+}
+
+// EmbedQueryFuzzy is EmbedQuery matching the query terms missing from the vocabulary to
+// the closest vocabulary terms, which are weighted as if less frequent in the query
+func (t *TFIDFEmbedder) EmbedQueryFuzzy(text string) ([]float64, []embedders.Correction, error) {
+	return t.embed(text, true, true)
+}
+
+// EmbedSparse converts text to a sparse TF-IDF vector holding only the terms of text
+func (t *TFIDFEmbedder) EmbedSparse(text string) (*models.SparseVector, error) {
+	sparse, _, err := t.embedSparse(text, false, false)
+	return sparse, err
+}
+
+// EmbedSparseQuery converts a search query to a sparse TF-IDF vector, expanding synonyms if configured
+func (t *TFIDFEmbedder) EmbedSparseQuery(text string) (*models.SparseVector, error) {
+	sparse, _, err := t.embedSparse(text, true, false)
+	return sparse, err
+}
+
+// EmbedSparseQueryFuzzy is EmbedSparseQuery matching misspelled terms like EmbedQueryFuzzy
+func (t *TFIDFEmbedder) EmbedSparseQueryFuzzy(text string) (*models.SparseVector, []embedders.Correction, error) {
+	return t.embedSparse(text, true, true)
+}
+
+func (t *TFIDFEmbedder) embed(text string, query, fuzzy bool) ([]float64, []embedders.Correction, error) {
+	if err := t.observe(text); err != nil {
+		return nil, nil, err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tf, corrections := t.termFrequencies(text, query, fuzzy)
+	embedding := t.weigh(tf)
+
+	if allZero(embedding) {
+		// If still zero, create a minimal non-zero embedding
+		// This ensures we never return all zeros
+		for i := range embedding {
+			embedding[i] = 1.0 / math.Sqrt(float64(len(embedding)))
+		}
+	}
+
+	return embedding, corrections, nil
+}
+
+func (t *TFIDFEmbedder) embedSparse(text string, query, fuzzy bool) (*models.SparseVector, []embedders.Correction, error) {
+	if err := t.observe(text); err != nil {
+		return nil, nil, err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tf, corrections := t.termFrequencies(text, query, fuzzy)
+	sparse := t.weighSparse(tf)
+
+	if len(sparse.Indices) == 0 {
+		// Like Embed, never return all zeros
+		uniform := make([]float64, len(t.vocabulary))
+		for i := range uniform {
+			uniform[i] = 1.0 / math.Sqrt(float64(len(uniform)))
+		}
+		return models.SparseFromDense(uniform), corrections, nil
+	}
+
+	return sparse, corrections, nil
+}
 
-		// Rebuild vocabulary periodically
-		if len(t.documents)%50 == 0 {
-			t.buildVocabulary()
+// observe adds text to the corpus for future vocabulary updates, fitting the
+// vocabulary first when the embedder has none. A fixed vocabulary is left as is
+func (t *TFIDFEmbedder) observe(text string) error {
+	if t.Fixed() {
+		return nil
+	}
+	if !t.fitted() {
+		t.mu.Lock()
+		if len(t.vocabulary) == 0 {
+			defer t.mu.Unlock()
+
+			// Only the builtin corpus is applied lazily, a bootstrap file was loaded at construction
+			if t.bootstrap.Source != BootstrapBuiltin {
+				return ErrNotFitted
+			}
+			t.add(rebuildEvery, append([]string{text}, builtinBootstrap...)...)
+			documents := t.corpus()
+			t.vocabulary, t.idf, t.fuzzy = t.buildVocabulary(documents)
+			t.builtFrom = len(documents)
+			return nil
 		}
+		t.mu.Unlock()
+	}
+
+	// Rebuild vocabulary periodically
+	if t.add(rebuildEvery, text) {
+		t.scheduleRebuild()
 	}
+	return nil
+}
+
+// termFrequencies returns the term frequencies of text
+// With fuzzy, the frequency of a word missing from the vocabulary is lent at a reduced
+// weight to the closest vocabulary term, and the corrections made are returned
+// Caller must hold the lock
+func (t *TFIDFEmbedder) termFrequencies(text string, query, fuzzy bool) (map[string]float64, []embedders.Correction) {
+	expand := t.synonyms != nil && (query || t.synonyms.ExpandDocuments())
 
 	words := t.preprocessText(text)
 
-	// Count term frequencies
-	tf := make(map[string]float64)
+	// Count term frequencies, adding synonyms at a reduced weight
+	var tf map[string]float64
+	if expand {
+		tf = t.synonyms.Expand(words)
+	} else {
+		tf = synonyms.TermFrequencies(words)
+	}
+	if !fuzzy {
+		return tf, nil
+	}
+
+	var corrections []embedders.Correction
 	for _, word := range words {
-		tf[word]++
+		if _, known := t.vocabulary[word]; known || tf[word] == 0 {
+			continue
+		}
+		correction, ok := t.fuzzy.correct(word, t.vocabulary, t.idf)
+		if !ok {
+			continue
+		}
+		tf[correction.To] += tf[word] * correctionWeight
+		// Repeated words are counted once
+		tf[word] = 0
+		corrections = append(corrections, correction)
 	}
+	return tf, corrections
+}
 
+// weigh turns term frequencies into an L2 normalized TF-IDF vector
+// Caller must hold the lock
+func (t *TFIDFEmbedder) weigh(tf map[string]float64) []float64 {
 	// Normalize term frequencies
 	maxTf := 0.0
 	for _, freq := range tf {
@@ -198,18 +466,12 @@ func (t *TFIDFEmbedder) Embed(text string) ([]float64, error) {
 		}
 	}
 
-	if maxTf > 0 {
-		for word := range tf {
-			tf[word] = tf[word] / maxTf
-		}
-	}
-
 	// Create TF-IDF vector
 	embedding := make([]float64, len(t.vocabulary))
 
 	for word, freq := range tf {
 		if idx, exists := t.vocabulary[word]; exists {
-			embedding[idx] = freq * t.idf[idx]
+			embedding[idx] = freq / maxTf * t.idf[idx]
 		}
 	}
 
@@ -224,15 +486,104 @@ func (t *TFIDFEmbedder) Embed(text string) ([]float64, error) {
 		for i := range embedding {
 			embedding[i] /= norm
 		}
-	} else {
-		// If still zero, create a minimal non-zero embedding
-		// This ensures we never return all zeros
-		for i := range embedding {
-			embedding[i] = 1.0 / math.Sqrt(float64(len(embedding)))
+	}
+
+	return embedding
+}
+
+// weighSparse is weigh returning only the weights of terms in the vocabulary
+// Caller must hold the lock
+func (t *TFIDFEmbedder) weighSparse(tf map[string]float64) *models.SparseVector {
+	maxTf := 0.0
+	for _, freq := range tf {
+		if freq > maxTf {
+			maxTf = freq
 		}
 	}
 
-	return embedding, nil
+	weights := make(map[int]float64, len(tf))
+	norm := 0.0
+	for word, freq := range tf {
+		if idx, exists := t.vocabulary[word]; exists {
+			weight := freq / maxTf * t.idf[idx]
+			weights[idx] = weight
+			norm += weight * weight
+		}
+	}
+
+	norm = math.Sqrt(norm)
+	if norm > 0 {
+		for idx := range weights {
+			weights[idx] /= norm
+		}
+	}
+
+	return models.NewSparseVector(weights, len(t.vocabulary))
+}
+
+// allZero reports whether every value of the embedding is zero
+func allZero(embedding []float64) bool {
+	for _, val := range embedding {
+		if val != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Vocabulary returns up to limit vocabulary terms starting with prefix, with their IDF
+func (t *TFIDFEmbedder) Vocabulary(prefix string, limit int) []embedders.VocabularyTerm {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	terms := make([]embedders.VocabularyTerm, 0)
+	for term, idx := range t.vocabulary {
+		if strings.HasPrefix(term, prefix) {
+			terms = append(terms, embedders.VocabularyTerm{Term: term, Index: idx, IDF: t.idf[idx]})
+		}
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		return terms[i].Term < terms[j].Term
+	})
+
+	if limit > 0 && len(terms) > limit {
+		terms = terms[:limit]
+	}
+
+	return terms
+}
+
+// Analyze explains how text is tokenized and weighted without adding it to the corpus
+func (t *TFIDFEmbedder) Analyze(text string, top int) (*embedders.Analysis, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	words := t.preprocessText(text)
+
+	oov := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, word := range words {
+		if _, exists := t.vocabulary[word]; !exists && !seen[word] {
+			oov = append(oov, word)
+			seen[word] = true
+		}
+	}
+
+	terms := make([]string, len(t.vocabulary))
+	for term, idx := range t.vocabulary {
+		terms[idx] = term
+	}
+
+	embedding := t.weigh(synonyms.TermFrequencies(words))
+
+	return &embedders.Analysis{
+		Tokens:          words,
+		OutOfVocabulary: oov,
+		TopDimensions: embedders.TopDimensions(embedding, top, func(index int) []string {
+			return []string{terms[index]}
+		}),
+	}, nil
 }
 
 // GetVocabularySize returns the current vocabulary size
@@ -244,9 +595,30 @@ func (t *TFIDFEmbedder) GetVocabularySize() int {
 
 // GetDocumentCount returns the number of documents in corpus
 func (t *TFIDFEmbedder) GetDocumentCount() int {
+	t.docsMu.Lock()
+	defer t.docsMu.Unlock()
+	return len(t.documents)
+}
+
+// Bootstrap returns the source and size of the corpus the vocabulary was seeded with
+func (t *TFIDFEmbedder) Bootstrap() BootstrapInfo {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return len(t.documents)
+	return t.bootstrap
+}
+
+// Synonyms returns the synonym sets used for expansion, or nil
+func (t *TFIDFEmbedder) Synonyms() *synonyms.Set {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.synonyms
+}
+
+// SetSynonyms enables synonym expansion with the given sets (nil disables it)
+func (t *TFIDFEmbedder) SetSynonyms(set *synonyms.Set) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.synonyms = set
 }
 
 func (t *TFIDFEmbedder) Name() string {
@@ -0,0 +1,430 @@
+package tfidf
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders/synonyms"
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func rankCarQuery(t *testing.T, set *synonyms.Set) (automobileScore, unrelatedScore float64) {
+	t.Helper()
+
+	corpus := []string{
+		"the car is parked outside the house",
+		"automobiles are fast machines built for the road",
+		"bananas grow on tropical trees",
+	}
+
+	embedder := NewTFIDFEmbedder().(*TFIDFEmbedder)
+	embedder.AddDocuments(corpus)
+	embedder.SetSynonyms(set)
+
+	automobile, err := embedder.Embed(corpus[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	unrelated, err := embedder.Embed(corpus[2])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	query, err := embedder.EmbedQuery("car")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	queryVector := &models.Vector{Embedding: query}
+	return queryVector.CosineSimilarity(&models.Vector{Embedding: automobile}),
+		queryVector.CosineSimilarity(&models.Vector{Embedding: unrelated})
+}
+
+func TestEmbedQuery_SynonymExpansion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	if err := os.WriteFile(path, []byte("# vehicles\ncar, automobile, automobiles\n"), 0644); err != nil {
+		t.Fatalf("failed to write synonyms file: %v", err)
+	}
+
+	set, err := synonyms.Load(path, synonyms.DefaultWeight)
+	if err != nil {
+		t.Fatalf("failed to load synonyms: %v", err)
+	}
+
+	automobile, unrelated := rankCarQuery(t, set)
+	if automobile <= unrelated {
+		t.Errorf("with synonyms: expected automobile document (%f) to rank above unrelated (%f)", automobile, unrelated)
+	}
+
+	automobile, unrelated = rankCarQuery(t, nil)
+	if automobile > unrelated {
+		t.Errorf("without synonyms: expected automobile document (%f) not to rank above unrelated (%f)", automobile, unrelated)
+	}
+}
+
+func TestVocabularyAndAnalyze(t *testing.T) {
+	embedder := NewTFIDFEmbedder().(*TFIDFEmbedder)
+	embedder.AddDocuments([]string{
+		"relativity changed physics forever",
+		"relational databases store rows",
+		"quantum physics is strange",
+	})
+
+	terms := embedder.Vocabulary("rel", 50)
+	if len(terms) != 2 || terms[0].Term != "relational" || terms[1].Term != "relativity" {
+		t.Fatalf("expected [relational relativity], got %v", terms)
+	}
+	if terms[0].IDF <= 0 {
+		t.Errorf("expected positive IDF, got %f", terms[0].IDF)
+	}
+
+	documents := embedder.GetDocumentCount()
+	analysis, err := embedder.Analyze("The physics of unicorns", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if embedder.GetDocumentCount() != documents {
+		t.Errorf("analyze must not add documents to the corpus")
+	}
+	if len(analysis.Tokens) != 2 || analysis.Tokens[0] != "physics" {
+		t.Errorf("expected tokens [physics unicorns], got %v", analysis.Tokens)
+	}
+	if len(analysis.OutOfVocabulary) != 1 || analysis.OutOfVocabulary[0] != "unicorns" {
+		t.Errorf("expected out-of-vocabulary [unicorns], got %v", analysis.OutOfVocabulary)
+	}
+	if len(analysis.TopDimensions) != 1 || analysis.TopDimensions[0].Terms[0] != "physics" {
+		t.Errorf("expected physics as the only top dimension, got %v", analysis.TopDimensions)
+	}
+}
+
+func TestEmbedSparse_MatchesEmbed(t *testing.T) {
+	embedder := NewTFIDFEmbedder().(*TFIDFEmbedder)
+	embedder.AddDocuments([]string{
+		"the car is parked outside the house",
+		"automobiles are fast machines built for the road",
+		"bananas grow on tropical trees",
+	})
+
+	text := "fast car on the road"
+	sparse, err := embedder.EmbedSparse(text)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dense, err := embedder.Embed(text)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := sparse.Validate(); err != nil {
+		t.Fatalf("invalid sparse vector: %v", err)
+	}
+	if sparse.Dimension != len(dense) {
+		t.Fatalf("sparse dimension = %d, want %d", sparse.Dimension, len(dense))
+	}
+	if len(sparse.Indices) >= len(dense) {
+		t.Errorf("sparse vector holds %d of %d values", len(sparse.Indices), len(dense))
+	}
+	for i, value := range sparse.Dense() {
+		if math.Abs(value-dense[i]) > 1e-12 {
+			t.Fatalf("dimension %d = %v, dense = %v", i, value, dense[i])
+		}
+	}
+}
+
+func TestNewTFIDFEmbedderWithOptions_BootstrapFile(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "corpus.txt")
+	if err := os.WriteFile(text, []byte("contract breach damages\n\nplaintiff filed motion\ncourt granted appeal\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jsonl := filepath.Join(dir, "corpus.jsonl")
+	if err := os.WriteFile(jsonl, []byte(`{"text": "contract breach damages"}`+"\n"+`{"text": "plaintiff filed motion"}`+"\n"+`{"id": "no text"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, docs := range map[string]int{text: 3, jsonl: 2} {
+		embedder, err := NewTFIDFEmbedderWithOptions(Options{BootstrapPath: path})
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		tfidf := embedder.(*TFIDFEmbedder)
+		if info := tfidf.Bootstrap(); info.Source != path || info.Documents != docs {
+			t.Errorf("%s: bootstrap = %+v, want %d documents", path, info, docs)
+		}
+		if tfidf.GetVocabularySize() == 0 || tfidf.GetDocumentCount() != docs {
+			t.Errorf("%s: vocabulary = %d, documents = %d", path, tfidf.GetVocabularySize(), tfidf.GetDocumentCount())
+		}
+
+		// The first embedding uses the seeded vocabulary, without the builtin corpus
+		embedding, err := tfidf.Embed("breach of contract")
+		if err != nil || allZero(embedding) {
+			t.Errorf("%s: embed = %v, %v", path, embedding, err)
+		}
+		if tfidf.GetDocumentCount() != docs+1 {
+			t.Errorf("%s: documents after embed = %d, want %d", path, tfidf.GetDocumentCount(), docs+1)
+		}
+	}
+
+	if _, err := NewTFIDFEmbedderWithOptions(Options{BootstrapPath: filepath.Join(dir, "missing.txt")}); err == nil {
+		t.Error("missing bootstrap file: expected error")
+	}
+}
+
+func TestNewTFIDFEmbedderWithOptions_DisabledBootstrap(t *testing.T) {
+	embedder, err := NewTFIDFEmbedderWithOptions(Options{DisableBootstrap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tfidf := embedder.(*TFIDFEmbedder)
+	if info := tfidf.Bootstrap(); info.Source != BootstrapDisabled {
+		t.Errorf("bootstrap = %+v", info)
+	}
+	if _, err := tfidf.Embed("anything at all"); !errors.Is(err, ErrNotFitted) {
+		t.Errorf("embed before fit: err = %v, want ErrNotFitted", err)
+	}
+	if _, err := tfidf.EmbedSparse("anything at all"); !errors.Is(err, ErrNotFitted) {
+		t.Errorf("sparse embed before fit: err = %v, want ErrNotFitted", err)
+	}
+
+	tfidf.AddDocuments([]string{"apples grow on trees", "bananas are yellow fruit"})
+	if _, err := tfidf.Embed("apples"); err != nil {
+		t.Errorf("embed after fit: %v", err)
+	}
+}
+
+// word returns a distinct lowercase word for n, as preprocessing drops digits
+func word(n int) string {
+	w := []byte("term")
+	for ; n > 0; n /= 26 {
+		w = append(w, byte('a'+n%26))
+	}
+	return string(w)
+}
+
+// corpusOf returns n documents of words drawn from a vocabulary of a few thousand terms
+func corpusOf(n int) []string {
+	docs := make([]string, n)
+	for i := range docs {
+		words := make([]string, 12)
+		for j := range words {
+			words[j] = word((i*31 + j*97) % 3000)
+		}
+		docs[i] = strings.Join(words, " ")
+	}
+	return docs
+}
+
+func TestEmbed_ConcurrentRebuilds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("hammers the embedder")
+	}
+
+	embedder := NewTFIDFEmbedder().(*TFIDFEmbedder)
+	start := time.Now()
+	embedder.AddDocuments(corpusOf(20000))
+	rebuild := time.Since(start) // What every rebuildEvery-th embedding used to wait for
+
+	const workers, embeds = 8, 200
+	latencies := make([]time.Duration, workers*embeds)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < embeds; i++ {
+				text := "fresh" + word(w*embeds+i) + " " + word(i)
+				if i%10 == 0 {
+					embedder.AddDocument(text)
+				}
+				started := time.Now()
+				if _, err := embedder.Embed(text); err != nil {
+					t.Error(err)
+					return
+				}
+				latencies[w*embeds+i] = time.Since(started)
+			}
+		}(w)
+	}
+	// Readers of the vocabulary run alongside the rebuilds
+	for i := 0; i < 20; i++ {
+		embedder.Vocabulary("term", 5)
+		if _, err := embedder.Analyze("terma termb", 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p99 := latencies[len(latencies)*99/100]
+	if p99 >= rebuild/2 {
+		t.Errorf("p99 embed latency %v, a synchronous rebuild takes %v", p99, rebuild)
+	}
+
+	// The embedded texts reach the vocabulary once a background rebuild is done
+	want := 20000 + workers*embeds + workers*embeds/10
+	if got := embedder.GetDocumentCount(); got != want {
+		t.Errorf("documents = %d, want %d", got, want)
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		if len(embedder.Vocabulary("freshterm", 1)) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the vocabulary was not rebuilt with the embedded texts")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClose_StopsRebuilds(t *testing.T) {
+	embedder := NewTFIDFEmbedder().(*TFIDFEmbedder)
+	if err := embedder.Close(); err != nil {
+		t.Fatalf("Close() of an embedder that never rebuilt = %v", err)
+	}
+
+	embedder = NewTFIDFEmbedder().(*TFIDFEmbedder)
+	for i := 0; i < 2*rebuildEvery; i++ {
+		if _, err := embedder.Embed("text " + word(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	done := embedder.rebuildsDone
+	if done == nil {
+		t.Fatal("no background rebuild was scheduled")
+	}
+	if err := embedder.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatal("Close() returned before the rebuild goroutine")
+	}
+
+	// A closed embedder keeps embedding, without rebuilding in the background
+	for i := 0; i < 2*rebuildEvery; i++ {
+		if _, err := embedder.Embed("more " + word(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := embedder.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
+
+func TestEmbedQueryFuzzy(t *testing.T) {
+	embedder := NewTFIDFEmbedder().(*TFIDFEmbedder)
+	embedder.AddDocuments([]string{
+		"Einstein explained relativity",
+		"Einstein played the violin",
+		"Eisenstein directed films",
+		"relativity changed physics",
+	})
+
+	// Corrected terms are weighted alike, so the query points the way of the spelled out one
+	spelled, _ := embedder.EmbedQuery("Einstein relativity")
+	exact, _ := embedder.EmbedQuery("Einstien relativty")
+	fuzzy, corrections, err := embedder.EmbedQueryFuzzy("Einstien relativty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &models.Vector{Embedding: spelled}
+	if score := (&models.Vector{Embedding: fuzzy}).CosineSimilarity(want); score < 0.999 {
+		t.Errorf("fuzzy query similarity to the spelled out query = %.3f", score)
+	}
+	if score := (&models.Vector{Embedding: exact}).CosineSimilarity(want); score > 0.9 {
+		t.Errorf("exact query similarity to the spelled out query = %.3f", score)
+	}
+	names := []string{"einstien→einstein", "relativty→relativity"}
+	if len(corrections) != len(names) {
+		t.Fatalf("corrections = %v", corrections)
+	}
+	for i, correction := range corrections {
+		if correction.String() != names[i] || correction.Distance != 1 {
+			t.Errorf("correction %d = %+v, want %s", i, correction, names[i])
+		}
+	}
+
+	// Short tokens are only corrected within one edit, and far tokens not at all
+	for _, query := range []string{"violn", "xyzzy", "ein"} {
+		if _, corrections, _ := embedder.EmbedQueryFuzzy(query); len(corrections) > 1 || (query != "violn" && len(corrections) > 0) {
+			t.Errorf("%s corrected to %v", query, corrections)
+		}
+	}
+	if _, corrections, _ := embedder.EmbedSparseQueryFuzzy("films directd"); len(corrections) != 1 || corrections[0].To != "directed" {
+		t.Errorf("sparse corrections = %v", corrections)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"einstein", "einstein", 0},
+		{"einstien", "einstein", 1}, // Transposition
+		{"relativty", "relativity", 1},
+		{"kitten", "sitting", 3},
+		{"abc", "abcdef", 3},
+	} {
+		if got := editDistance(c.a, c.b, 2); got != min(c.want, 3) {
+			t.Errorf("editDistance(%s, %s) = %d, want %d", c.a, c.b, got, min(c.want, 3))
+		}
+	}
+}
+
+func TestFit_SavedVocabulary(t *testing.T) {
+	embedder := NewTFIDFEmbedder().(*TFIDFEmbedder)
+	if _, err := embedder.SaveVocabulary(); err == nil {
+		t.Error("saved a vocabulary that is not fixed")
+	}
+	if err := embedder.Fit([]string{"wisdom begins in wonder", "courage is knowing what not to fear"}); err != nil {
+		t.Fatal(err)
+	}
+	size := embedder.GetVocabularySize()
+
+	// Texts embedded after the fit keep the vocabulary, past the rebuild threshold too
+	for i := 0; i < rebuildEvery*2; i++ {
+		if _, err := embedder.Embed("philosophers wonder about everything"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := embedder.GetVocabularySize(); got != size || !embedder.Fixed() {
+		t.Fatalf("vocabulary of %d terms after embedding, fitted with %d", got, size)
+	}
+
+	data, err := embedder.SaveVocabulary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewTFIDFEmbedder().(*TFIDFEmbedder)
+	if err := loaded.LoadVocabulary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Fixed() || loaded.Bootstrap() != embedder.Bootstrap() {
+		t.Errorf("loaded vocabulary fixed %v, bootstrap %+v", loaded.Fixed(), loaded.Bootstrap())
+	}
+	for _, text := range []string{"wonder", "the courage of wisdom", "unknown terms only"} {
+		want, _ := embedder.Embed(text)
+		got, _ := loaded.EmbedQuery(text)
+		if len(got) != len(want) {
+			t.Fatalf("%q embedded with %d dimensions, want %d", text, len(got), len(want))
+		}
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > 1e-12 {
+				t.Errorf("%q embedded differently at dimension %d: %v, want %v", text, i, got[i], want[i])
+				break
+			}
+		}
+	}
+
+	if err := loaded.LoadVocabulary([]byte(`{"terms": ["a"], "idf": []}`)); err == nil {
+		t.Error("loaded a vocabulary with missing idf values")
+	}
+}
@@ -0,0 +1,92 @@
+package tfidf
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// savedVocabulary is a fixed vocabulary as saved with the vectors embedded with it
+type savedVocabulary struct {
+	Terms     []string      `json:"terms"` // By index
+	IDF       []float64     `json:"idf"`
+	Documents int           `json:"documents"` // Documents the vocabulary was built from
+	Bootstrap BootstrapInfo `json:"bootstrap"`
+}
+
+// Fit builds the vocabulary from the bootstrap corpus and texts, and fixes it: the
+// texts embedded afterwards no longer join the corpus, so that every vector shares
+// the vocabulary. Documents added with AddDocument still rebuild it, and a fixed
+// vocabulary is kept
+func (t *TFIDFEmbedder) Fit(texts []string) error {
+	t.fitMu.Lock()
+	defer t.fitMu.Unlock()
+
+	t.mu.Lock()
+	if t.fixed {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.bootstrap.Source == BootstrapBuiltin && len(t.vocabulary) == 0 {
+		texts = append(append([]string(nil), builtinBootstrap...), texts...)
+	}
+	t.fixed = true
+	t.mu.Unlock()
+
+	t.add(rebuildEveryAdded, texts...)
+	t.rebuild()
+	if !t.fitted() {
+		t.mu.Lock()
+		t.fixed = false
+		t.mu.Unlock()
+		return ErrNotFitted
+	}
+	return nil
+}
+
+// Fixed reports whether the vocabulary was fitted with Fit or loaded, waiting for a Fit in progress
+func (t *TFIDFEmbedder) Fixed() bool {
+	t.fitMu.Lock()
+	defer t.fitMu.Unlock()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.fixed
+}
+
+// SaveVocabulary returns the fixed vocabulary, for LoadVocabulary to restore it
+func (t *TFIDFEmbedder) SaveVocabulary() ([]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.fixed {
+		return nil, fmt.Errorf("tf-idf vocabulary is not fixed: fit it before saving it")
+	}
+	terms := make([]string, len(t.vocabulary))
+	for term, idx := range t.vocabulary {
+		terms[idx] = term
+	}
+	return json.Marshal(savedVocabulary{Terms: terms, IDF: t.idf, Documents: t.builtFrom, Bootstrap: t.bootstrap})
+}
+
+// LoadVocabulary replaces the vocabulary with one returned by SaveVocabulary and fixes it
+func (t *TFIDFEmbedder) LoadVocabulary(data []byte) error {
+	var saved savedVocabulary
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("invalid tf-idf vocabulary: %w", err)
+	}
+	if len(saved.Terms) == 0 || len(saved.Terms) != len(saved.IDF) {
+		return fmt.Errorf("invalid tf-idf vocabulary: %d terms with %d idf values", len(saved.Terms), len(saved.IDF))
+	}
+
+	vocabulary := make(map[string]int, len(saved.Terms))
+	for i, term := range saved.Terms {
+		vocabulary[term] = i
+	}
+	fuzzy := newFuzzyIndex(vocabulary)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.vocabulary, t.idf, t.fuzzy, t.builtFrom = vocabulary, saved.IDF, fuzzy, saved.Documents
+	t.bootstrap = saved.Bootstrap
+	t.fixed = true
+	return nil
+}
@@ -0,0 +1,207 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+)
+
+// Defaults of the cache of override embedders
+const (
+	DefaultCacheSize = 4
+	DefaultCacheIdle = 10 * time.Minute
+)
+
+// newEmbedder creates the embedders of the cache, replaced in tests
+var newEmbedder = New
+
+// Cache creates the embedders of named specs on first use and reuses them, so a
+// model or worker is not started per request. It keeps at most size instances,
+// dropping the least recently used, and drops instances unused for idle
+// Dropped embedders implementing io.Closer are closed
+type Cache struct {
+	specs map[string]Spec
+	size  int
+	idle  time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*cacheEntry
+	creating map[string]*creation // Embedders being created, off the lock
+}
+
+type cacheEntry struct {
+	embedder embedders.Embedder
+	lastUsed time.Time
+	timer    *time.Timer
+}
+
+// creation is an embedder being created, which concurrent requests for it wait for
+type creation struct {
+	done     chan struct{}
+	embedder embedders.Embedder
+	err      error
+}
+
+// NewCache returns a cache of the embedders of specs, the defaults applying when
+// size or idle is not positive
+func NewCache(specs map[string]Spec, size int, idle time.Duration) (*Cache, error) {
+	for _, name := range sortedNames(specs) {
+		if err := specs[name].Validate(); err != nil {
+			return nil, fmt.Errorf("embedder %s: %w", name, err)
+		}
+	}
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	if idle <= 0 {
+		idle = DefaultCacheIdle
+	}
+	return &Cache{
+		specs:    specs,
+		size:     size,
+		idle:     idle,
+		entries:  make(map[string]*cacheEntry),
+		creating: make(map[string]*creation),
+	}, nil
+}
+
+// Names returns the names of the embedders of the cache, sorted
+func (c *Cache) Names() []string {
+	return sortedNames(c.specs)
+}
+
+// Allowed reports whether name is an embedder of the cache
+func (c *Cache) Allowed(name string) bool {
+	_, ok := c.specs[name]
+	return ok
+}
+
+// Get returns the embedder named name, creating it unless cached
+// Concurrent requests for an embedder being created share the instance, while
+// requests for other embedders are not held up by its creation
+func (c *Cache) Get(name string) (embedders.Embedder, error) {
+	spec, ok := c.specs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown embedder %q (allowed: %s)", name, strings.Join(c.Names(), ", "))
+	}
+
+	c.mu.Lock()
+	if entry, ok := c.entries[name]; ok {
+		entry.lastUsed = time.Now()
+		entry.timer.Reset(c.idle)
+		c.mu.Unlock()
+		return entry.embedder, nil
+	}
+	if pending, ok := c.creating[name]; ok {
+		c.mu.Unlock()
+		<-pending.done
+		return pending.embedder, pending.err
+	}
+	pending := &creation{done: make(chan struct{})}
+	c.creating[name] = pending
+	c.mu.Unlock()
+
+	pending.embedder, pending.err = newEmbedder(spec)
+	if pending.err != nil {
+		pending.embedder, pending.err = nil, fmt.Errorf("embedder %s: %w", name, pending.err)
+	}
+
+	var dropped embedders.Embedder
+	c.mu.Lock()
+	delete(c.creating, name)
+	if pending.err == nil {
+		if len(c.entries) >= c.size {
+			dropped = c.dropLocked(c.leastRecentLocked())
+		}
+		entry := &cacheEntry{embedder: pending.embedder, lastUsed: time.Now()}
+		entry.timer = time.AfterFunc(c.idle, func() { c.expire(name, entry) })
+		c.entries[name] = entry
+	}
+	c.mu.Unlock()
+	close(pending.done)
+
+	closeEmbedder(dropped)
+	return pending.embedder, pending.err
+}
+
+// Cached returns the names of the embedders currently created, sorted
+func (c *Cache) Cached() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.entries))
+	for name := range c.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close drops every cached embedder
+func (c *Cache) Close() {
+	c.mu.Lock()
+	dropped := make([]embedders.Embedder, 0, len(c.entries))
+	for name := range c.entries {
+		dropped = append(dropped, c.dropLocked(name))
+	}
+	c.mu.Unlock()
+
+	for _, embedder := range dropped {
+		closeEmbedder(embedder)
+	}
+}
+
+// expire drops entry when its idle timer fires, unless it was used since
+func (c *Cache) expire(name string, entry *cacheEntry) {
+	c.mu.Lock()
+	if c.entries[name] != entry || time.Since(entry.lastUsed) < c.idle {
+		c.mu.Unlock()
+		return
+	}
+	dropped := c.dropLocked(name)
+	c.mu.Unlock()
+
+	closeEmbedder(dropped)
+}
+
+func (c *Cache) leastRecentLocked() string {
+	oldest := ""
+	for name, entry := range c.entries {
+		if oldest == "" || entry.lastUsed.Before(c.entries[oldest].lastUsed) {
+			oldest = name
+		}
+	}
+	return oldest
+}
+
+// dropLocked removes the entry of name, returning its embedder for the caller to
+// close once it released the lock, since closing may wait for the embedder's work
+func (c *Cache) dropLocked(name string) embedders.Embedder {
+	entry, ok := c.entries[name]
+	if !ok {
+		return nil
+	}
+	entry.timer.Stop()
+	delete(c.entries, name)
+	return entry.embedder
+}
+
+// closeEmbedder closes a dropped embedder implementing io.Closer, nil being none
+func closeEmbedder(embedder embedders.Embedder) {
+	if closer, ok := embedder.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+func sortedNames(specs map[string]Spec) []string {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
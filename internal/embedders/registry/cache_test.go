@@ -0,0 +1,173 @@
+package registry
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+)
+
+func TestCache(t *testing.T) {
+	specs := map[string]Spec{
+		"a": {Type: "fixture", Settings: map[string]string{"seed": "1"}},
+		"b": {Type: "fixture", Settings: map[string]string{"seed": "2"}},
+	}
+	cache, err := NewCache(specs, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	first, err := cache.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.Get("a"); again != first {
+		t.Error("cached embedder was created again")
+	}
+
+	// Past the size the least recently used embedder is dropped
+	if _, err := cache.Get("b"); err != nil {
+		t.Fatal(err)
+	}
+	if cached := cache.Cached(); len(cached) != 1 || cached[0] != "b" {
+		t.Errorf("cached = %v, want [b]", cached)
+	}
+	if again, _ := cache.Get("a"); again == first {
+		t.Error("dropped embedder was reused")
+	}
+
+	if _, err := cache.Get("c"); err == nil {
+		t.Error("unknown embedder was created")
+	}
+	if _, err := NewCache(map[string]Spec{"x": {Type: "word2vec"}}, 0, 0); err == nil {
+		t.Error("invalid spec was accepted")
+	}
+}
+
+func TestCache_IdleTeardown(t *testing.T) {
+	cache, err := NewCache(map[string]Spec{"a": {Type: "hash"}}, 0, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	if _, err := cache.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(cache.Cached()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("idle embedder was not dropped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCache_ClosesTFIDF(t *testing.T) {
+	cache, err := NewCache(map[string]Spec{"a": {Type: "tfidf"}}, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedder, err := cache.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The TF-IDF rebuild goroutine is stopped when the embedder is dropped
+	if _, ok := embedder.(io.Closer); !ok {
+		t.Fatalf("%T is not closed when dropped", embedder)
+	}
+	cache.Close()
+	if cached := cache.Cached(); len(cached) != 0 {
+		t.Errorf("cached = %v after Close", cached)
+	}
+}
+
+// closingEmbedder counts the times it is closed
+type closingEmbedder struct {
+	embedders.Embedder
+	closed atomic.Int32
+}
+
+func (e *closingEmbedder) Close() error {
+	e.closed.Add(1)
+	return nil
+}
+
+func TestCache_CreatesOffTheLock(t *testing.T) {
+	release := make(chan struct{})
+	var created atomic.Int32
+	newEmbedder = func(s Spec) (embedders.Embedder, error) {
+		created.Add(1)
+		if s.Settings["seed"] == "1" {
+			<-release
+		}
+		embedder, err := New(s)
+		return &closingEmbedder{Embedder: embedder}, err
+	}
+	defer func() { newEmbedder = New }()
+
+	specs := map[string]Spec{
+		"a": {Type: "fixture", Settings: map[string]string{"seed": "1"}},
+		"b": {Type: "fixture", Settings: map[string]string{"seed": "2"}},
+	}
+	cache, err := NewCache(specs, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	// Concurrent requests for a slow embedder share a single instance
+	const requests = 8
+	got := make([]embedders.Embedder, requests)
+	var wg sync.WaitGroup
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i], _ = cache.Get("a")
+		}(i)
+	}
+
+	// and do not hold up the other embedders
+	for created.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var b embedders.Embedder
+	fetched := make(chan error)
+	go func() {
+		var err error
+		b, err = cache.Get("b")
+		fetched <- err
+	}()
+	select {
+	case err := <-fetched:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("creating an embedder held up another")
+	}
+	close(release)
+	wg.Wait()
+	for _, embedder := range got {
+		if embedder == nil || embedder != got[0] {
+			t.Fatalf("requests got %v, want one instance", got)
+		}
+	}
+	if n := created.Load(); n != 2 {
+		t.Errorf("created %d embedders, want 2", n)
+	}
+
+	// Inserting a past the size dropped and closed b
+	if cached := cache.Cached(); len(cached) != 1 || cached[0] != "a" {
+		t.Errorf("cached = %v, want [a]", cached)
+	}
+	if n := b.(*closingEmbedder).closed.Load(); n != 1 {
+		t.Errorf("dropped embedder closed %d times, want 1", n)
+	}
+}
@@ -0,0 +1,286 @@
+// Package registry creates embedders from a type and settings, and maps
+// namespaces to the embedder of their vectors
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/fixture"
+	"github.com/tahcohcat/same-same/internal/embedders/ollama"
+	"github.com/tahcohcat/same-same/internal/embedders/openai"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/gemini"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/huggingface"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/tfidf"
+)
+
+// Spec selects an embedder type with its settings
+// Settings by type:
+//
+//	local:        bootstrap_path, bootstrap_disabled (default TFIDF_BOOTSTRAP_PATH and TFIDF_BOOTSTRAP_DISABLED)
+//	hash:         dimension
+//	gemini:       api_key_env, the variable holding the API key (default GEMINI_API_KEY)
+//	huggingface:  api_key_env (default HUGGINGFACE_API_KEY)
+//	openai:       api_key_env (default OPENAI_API_KEY), model, base_url, api_version, dimensions
+//	              (default OPENAI_MODEL, OPENAI_BASE_URL, OPENAI_API_VERSION and OPENAI_DIMENSIONS)
+//	ollama:       host, model (default OLLAMA_HOST and OLLAMA_MODEL)
+//	fixture:      dimension, seed, vectors_path (JSON object mapping texts to canned vectors)
+type Spec struct {
+	Type     string            `json:"type"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// settingNames are the settings each embedder type accepts
+var settingNames = map[string][]string{
+	"local":       {"bootstrap_path", "bootstrap_disabled"},
+	"hash":        {"dimension"},
+	"gemini":      {"api_key_env"},
+	"huggingface": {"api_key_env"},
+	"openai":      {"api_key_env", "model", "base_url", "api_version", "dimensions"},
+	"ollama":      {"host", "model"},
+	"fixture":     {"dimension", "seed", "vectors_path"},
+}
+
+// typeAliases maps alternative type names to the canonical one
+var typeAliases = map[string]string{
+	"":      "local",
+	"tfidf": "local",
+	"hf":    "huggingface",
+}
+
+// canonicalType returns the canonical name of an embedder type
+func canonicalType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if alias, ok := typeAliases[t]; ok {
+		return alias
+	}
+	return t
+}
+
+// Validate checks the type and settings, and that the API key of remote embedders is set
+func (s Spec) Validate() error {
+	t := canonicalType(s.Type)
+	names, ok := settingNames[t]
+	if !ok {
+		return fmt.Errorf("unknown embedder type %q (supported: local, hash, gemini, huggingface, openai, ollama, fixture)", s.Type)
+	}
+	for name := range s.Settings {
+		if !containsString(names, name) {
+			return fmt.Errorf("unknown %s embedder setting %q", t, name)
+		}
+	}
+
+	switch t {
+	case "local":
+		if raw, ok := s.Settings["bootstrap_disabled"]; ok {
+			if _, err := strconv.ParseBool(raw); err != nil {
+				return fmt.Errorf("invalid bootstrap_disabled %q", raw)
+			}
+		}
+	case "hash", "fixture":
+		if raw, ok := s.Settings["dimension"]; ok {
+			if dimension, err := strconv.Atoi(raw); err != nil || dimension <= 0 {
+				return fmt.Errorf("invalid dimension %q: must be a positive integer", raw)
+			}
+		}
+		if raw, ok := s.Settings["seed"]; ok {
+			if _, err := strconv.ParseUint(raw, 10, 64); err != nil {
+				return fmt.Errorf("invalid seed %q: must be a non-negative integer", raw)
+			}
+		}
+	case "gemini", "huggingface", "openai":
+		if env := s.apiKeyEnv(t); os.Getenv(env) == "" {
+			return fmt.Errorf("%s environment variable is required", env)
+		}
+		if raw, ok := s.Settings["dimensions"]; ok {
+			if dimensions, err := strconv.Atoi(raw); err != nil || dimensions <= 0 {
+				return fmt.Errorf("invalid dimensions %q: must be a positive integer", raw)
+			}
+		}
+	}
+	return nil
+}
+
+// apiKeyEnv returns the environment variable holding the API key of a remote embedder
+func (s Spec) apiKeyEnv(t string) string {
+	if env := s.Settings["api_key_env"]; env != "" {
+		return env
+	}
+	switch t {
+	case "gemini":
+		return "GEMINI_API_KEY"
+	case "openai":
+		return "OPENAI_API_KEY"
+	}
+	return "HUGGINGFACE_API_KEY"
+}
+
+// New creates the embedder of a spec
+func New(s Spec) (embedders.Embedder, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch t := canonicalType(s.Type); t {
+	case "hash":
+		dimension, _ := strconv.Atoi(s.Settings["dimension"])
+		return hash.NewHashEmbedderWithDimension(dimension), nil
+	case "gemini":
+		return gemini.NewGeminiEmbedder(os.Getenv(s.apiKeyEnv(t))), nil
+	case "huggingface":
+		return huggingface.NewHuggingFaceEmbedder(os.Getenv(s.apiKeyEnv(t))), nil
+	case "openai":
+		opts, err := openai.OptionsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		opts.APIKey = os.Getenv(s.apiKeyEnv(t))
+		if model, ok := s.Settings["model"]; ok {
+			opts.Model = model
+		}
+		if baseURL, ok := s.Settings["base_url"]; ok {
+			opts.BaseURL = baseURL
+		}
+		if version, ok := s.Settings["api_version"]; ok {
+			opts.APIVersion = version
+		}
+		if raw, ok := s.Settings["dimensions"]; ok {
+			opts.Dimensions, _ = strconv.Atoi(raw)
+		}
+		return openai.NewOpenAIEmbedder(opts), nil
+	case "ollama":
+		opts := ollama.OptionsFromEnv()
+		if host, ok := s.Settings["host"]; ok {
+			opts.Host = host
+		}
+		if model, ok := s.Settings["model"]; ok {
+			opts.Model = model
+		}
+		return ollama.NewOllamaEmbedder(opts), nil
+	case "fixture":
+		dimension, _ := strconv.Atoi(s.Settings["dimension"])
+		seed, _ := strconv.ParseUint(s.Settings["seed"], 10, 64)
+		embedder := fixture.NewFixtureEmbedder(dimension, seed)
+		if path := s.Settings["vectors_path"]; path != "" {
+			if err := embedder.LoadVectors(path); err != nil {
+				return nil, err
+			}
+		}
+		return embedder, nil
+	default:
+		opts := tfidf.OptionsFromEnv()
+		if path, ok := s.Settings["bootstrap_path"]; ok {
+			opts.BootstrapPath = path
+		}
+		if raw, ok := s.Settings["bootstrap_disabled"]; ok {
+			opts.DisableBootstrap, _ = strconv.ParseBool(raw)
+		}
+		return tfidf.NewTFIDFEmbedderWithOptions(opts)
+	}
+}
+
+// Config maps namespaces to their embedder, others use the default
+type Config struct {
+	// Default embeds namespaces without an entry, EMBEDDER_TYPE when it has no type
+	Default    Spec            `json:"default"`
+	Namespaces map[string]Spec `json:"namespaces"`
+
+	// Overrides are the embedders admin search requests can select by name, with
+	// the embedder field, instead of the embedder of their namespace
+	Overrides map[string]Spec `json:"overrides,omitempty"`
+}
+
+// Load reads and validates a JSON config file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read namespace embedders: %w", err)
+	}
+
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse namespace embedders %s: %w", path, err)
+	}
+	if c.Default.Type == "" {
+		c.Default.Type = os.Getenv("EMBEDDER_TYPE")
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("namespace embedders %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// FromEnv loads the config file referenced by NAMESPACE_EMBEDDERS
+// Returns nil without error when none is configured
+func FromEnv() (*Config, error) {
+	path := os.Getenv("NAMESPACE_EMBEDDERS")
+	if path == "" {
+		return nil, nil
+	}
+	return Load(path)
+}
+
+// Validate checks the spec of the default and of every namespace
+func (c *Config) Validate() error {
+	if err := c.Default.Validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for _, namespace := range c.NamespaceNames() {
+		if err := c.Namespaces[namespace].Validate(); err != nil {
+			return fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+	}
+	for _, name := range sortedNames(c.Overrides) {
+		if err := c.Overrides[name].Validate(); err != nil {
+			return fmt.Errorf("override %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SpecFor returns the spec of the embedder of namespace
+func (c *Config) SpecFor(namespace string) Spec {
+	if s, ok := c.Namespaces[namespace]; ok {
+		return s
+	}
+	return c.Default
+}
+
+// NamespaceNames returns the namespaces with their own embedder, sorted
+func (c *Config) NamespaceNames() []string {
+	names := make([]string, 0, len(c.Namespaces))
+	for namespace := range c.Namespaces {
+		names = append(names, namespace)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build creates the embedder of every namespace with its own entry
+// Each namespace gets its own instance, so local embedders keep separate vocabularies
+func (c *Config) Build() (map[string]embedders.Embedder, error) {
+	built := make(map[string]embedders.Embedder, len(c.Namespaces))
+	for _, namespace := range c.NamespaceNames() {
+		embedder, err := New(c.Namespaces[namespace])
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+		built[namespace] = embedder
+	}
+	return built, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
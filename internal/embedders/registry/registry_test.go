@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "embedders.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("EMBEDDER_TYPE", "hash")
+	t.Setenv("LOGS_KEY", "secret")
+	path := writeConfig(t, `{
+		"namespaces": {
+			"logs": {"type": "hash", "settings": {"dimension": "64"}},
+			"quotes": {"type": "gemini", "settings": {"api_key_env": "LOGS_KEY"}},
+			"legal": {"type": "tfidf"},
+			"tests": {"type": "fixture", "settings": {"dimension": "8", "seed": "3"}}
+		}
+	}`)
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if config.Default.Type != "hash" {
+		t.Errorf("default type = %q, want EMBEDDER_TYPE", config.Default.Type)
+	}
+	if spec := config.SpecFor("other"); spec.Type != "hash" || spec.Settings != nil {
+		t.Errorf("spec of unconfigured namespace = %+v", spec)
+	}
+
+	built, err := config.Build()
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	names := map[string]string{"logs": "local.hash", "quotes": "gemini", "legal": "local.tfidf", "tests": "fixture"}
+	for namespace, name := range names {
+		if got := built[namespace]; got == nil || got.Name() != name {
+			t.Errorf("embedder of %s = %v, want %s", namespace, got, name)
+		}
+	}
+	if dimensioned, ok := built["logs"].(interface{ Dimensions() int }); !ok || dimensioned.Dimensions() != 64 {
+		t.Errorf("hash dimension setting not applied")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	tests := map[string]string{
+		`{"namespaces": {"a": {"type": "word2vec"}}}`:                                 "unknown embedder type",
+		`{"namespaces": {"a": {"type": "hash", "settings": {"model": "x"}}}}`:         "unknown hash embedder setting",
+		`{"namespaces": {"a": {"type": "hash", "settings": {"dimension": "-1"}}}}`:    "invalid dimension",
+		`{"namespaces": {"quotes": {"type": "gemini"}}}`:                              "GEMINI_API_KEY",
+		`{"namespaces": {"a": {"type": "fixture", "settings": {"seed": "-3"}}}}`:      "invalid seed",
+		`{"namespaces": {"a": {"type": "openai", "settings": {"dimensions": "0"}}}}`:  "invalid dimensions",
+		`{"default": {"type": "local", "settings": {"bootstrap_disabled": "maybe"}}}`: "bootstrap_disabled",
+		`{"overrides": {"b": {"type": "word2vec"}}}`:                                  "override b",
+	}
+	for content, want := range tests {
+		_, err := Load(writeConfig(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", content, err, want)
+		}
+	}
+}
+
+func TestFromEnv_Unset(t *testing.T) {
+	t.Setenv("NAMESPACE_EMBEDDERS", "")
+	if config, err := FromEnv(); config != nil || err != nil {
+		t.Errorf("FromEnv = %v, %v, want nil", config, err)
+	}
+}
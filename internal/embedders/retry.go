@@ -0,0 +1,93 @@
+package embedders
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries the requests of remote embedders answered with 429 Too Many
+// Requests or a 5xx status, such as a HuggingFace model still loading
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts in all, 1 or less disabling retries
+	MaxElapsed  time.Duration // Stops retrying once a wait would end past it, zero for no limit
+	BaseDelay   time.Duration // Wait before the first retry, doubled for each next one
+	MaxDelay    time.Duration // Longest wait between attempts, zero for no limit
+}
+
+// DefaultRetryPolicy is the retry policy of the remote embedders unless set otherwise
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MaxElapsed:  2 * time.Minute,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// Retrier is implemented by embedders retrying their failed requests
+type Retrier interface {
+	SetRetryPolicy(policy RetryPolicy)
+}
+
+// retryable reports whether a request answered with status is worth retrying
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500 && status != http.StatusNotImplemented
+}
+
+// Do sends the request newRequest builds with client, again after a wait while it is
+// answered with a retryable status. The wait grows exponentially with jitter, unless the
+// response has a Retry-After header. The response of the last attempt is returned,
+// whatever its status
+func (p RetryPolicy) Do(client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil || !retryable(resp.StatusCode) || attempt >= p.MaxAttempts {
+			return resp, err
+		}
+
+		wait := p.backoff(attempt, resp.Header.Get("Retry-After"))
+		if p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed {
+			return resp, nil
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		time.Sleep(wait)
+	}
+}
+
+// backoff returns the wait before the retry following attempt
+func (p RetryPolicy) backoff(attempt int, retryAfter string) time.Duration {
+	if wait, ok := parseRetryAfter(retryAfter); ok {
+		return wait
+	}
+
+	wait := p.BaseDelay << (attempt - 1)
+	if p.MaxDelay > 0 && (wait > p.MaxDelay || wait <= 0) {
+		wait = p.MaxDelay
+	}
+	// Wait between half and all of it, so that clients failing together retry apart
+	if wait > 1 {
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	}
+	return wait
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
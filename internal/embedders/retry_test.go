@@ -0,0 +1,93 @@
+package embedders
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// flakyServer answers the first requests with statuses, then with 200 OK, and counts
+// the requests it received
+func flakyServer(t *testing.T, retryAfter string, statuses ...int) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= len(statuses) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statuses[requests-1])
+			return
+		}
+		w.Write([]byte(`ok`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func get(url string) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		return http.NewRequest("GET", url, nil)
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	// Succeeds on the third attempt
+	server, requests := flakyServer(t, "", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	resp, err := policy.Do(server.Client(), get(server.URL))
+	if err != nil || resp.StatusCode != http.StatusOK || *requests != 3 {
+		t.Fatalf("status %v after %d requests, err = %v", resp.StatusCode, *requests, err)
+	}
+	resp.Body.Close()
+
+	// Gives up after the last attempt, returning its response
+	server, requests = flakyServer(t, "", http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	policy.MaxAttempts = 2
+	resp, err = policy.Do(server.Client(), get(server.URL))
+	if err != nil || resp.StatusCode != http.StatusBadGateway || *requests != 2 {
+		t.Fatalf("status %v after %d requests, err = %v", resp.StatusCode, *requests, err)
+	}
+	resp.Body.Close()
+
+	// Client errors are not retried
+	server, requests = flakyServer(t, "", http.StatusUnauthorized)
+	resp, err = policy.Do(server.Client(), get(server.URL))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || *requests != 1 {
+		t.Fatalf("status %v after %d requests, err = %v", resp.StatusCode, *requests, err)
+	}
+	resp.Body.Close()
+
+	// A Retry-After past the elapsed time allowed stops the retries at once
+	server, requests = flakyServer(t, "60", http.StatusServiceUnavailable)
+	policy = RetryPolicy{MaxAttempts: 4, MaxElapsed: time.Second, BaseDelay: time.Millisecond}
+	start := time.Now()
+	resp, err = policy.Do(server.Client(), get(server.URL))
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || *requests != 1 || time.Since(start) > time.Second {
+		t.Fatalf("status %v after %d requests in %v, err = %v", resp.StatusCode, *requests, time.Since(start), err)
+	}
+	resp.Body.Close()
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		if wait := policy.backoff(attempt, ""); wait < want/2 || wait > want {
+			t.Errorf("attempt %d waits %v, want between %v and %v", attempt, wait, want/2, want)
+		}
+	}
+
+	if wait := policy.backoff(1, "3"); wait != 3*time.Second {
+		t.Errorf("Retry-After in seconds waits %v", wait)
+	}
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if wait := policy.backoff(1, date); wait < 8*time.Second || wait > 10*time.Second {
+		t.Errorf("Retry-After date waits %v", wait)
+	}
+	if wait := policy.backoff(1, "soon"); wait > 100*time.Millisecond {
+		t.Errorf("invalid Retry-After waits %v", wait)
+	}
+}
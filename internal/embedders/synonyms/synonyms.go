@@ -0,0 +1,196 @@
+package synonyms
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultWeight is the weight given to expanded synonyms relative to the original token
+const DefaultWeight = 0.5
+
+// Set holds synonym equivalence sets loaded from a file
+// Each non-empty line of the file is a comma-separated equivalence set,
+// lines starting with # are comments
+type Set struct {
+	path            string
+	weight          float64
+	expandDocuments bool
+
+	mu     sync.RWMutex
+	groups [][]string
+	index  map[string][]int // term -> indices of the groups containing it
+}
+
+// Load reads a synonyms file and returns the parsed set
+func Load(path string, weight float64) (*Set, error) {
+	if weight <= 0 || weight > 1 {
+		weight = DefaultWeight
+	}
+
+	s := &Set{
+		path:   path,
+		weight: weight,
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// FromEnv loads the synonyms file referenced by SYNONYMS_PATH
+// Returns nil without error when no synonyms file is configured
+func FromEnv() (*Set, error) {
+	return FromLookup(os.Getenv)
+}
+
+// FromLookup is FromEnv reading the variables with getenv, for configuration
+// read from elsewhere than the environment
+func FromLookup(getenv func(string) string) (*Set, error) {
+	path := getenv("SYNONYMS_PATH")
+	if path == "" {
+		return nil, nil
+	}
+
+	weight := DefaultWeight
+	if w := getenv("SYNONYMS_WEIGHT"); w != "" {
+		parsed, err := strconv.ParseFloat(w, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SYNONYMS_WEIGHT %q: %w", w, err)
+		}
+		weight = parsed
+	}
+
+	s, err := Load(path, weight)
+	if err != nil {
+		return nil, err
+	}
+	s.expandDocuments = getenv("SYNONYMS_EXPAND_DOCUMENTS") == "true"
+
+	return s, nil
+}
+
+// Reload re-reads the synonyms file, replacing the loaded sets
+// The previous sets are kept if the file cannot be read
+func (s *Set) Reload() error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to open synonyms file: %w", err)
+	}
+	defer file.Close()
+
+	groups := make([][]string, 0)
+	index := make(map[string][]int)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		group := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, term := range strings.Split(line, ",") {
+			term = strings.ToLower(strings.TrimSpace(term))
+			if term == "" || seen[term] {
+				continue
+			}
+			seen[term] = true
+			group = append(group, term)
+		}
+
+		// A single term has nothing to expand to
+		if len(group) < 2 {
+			continue
+		}
+
+		for _, term := range group {
+			index[term] = append(index[term], len(groups))
+		}
+		groups = append(groups, group)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read synonyms file: %w", err)
+	}
+
+	s.mu.Lock()
+	s.groups = groups
+	s.index = index
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Expand returns term frequencies for tokens with synonyms added at the reduced weight
+// A synonym never lowers the weight of a term that appears in the tokens itself
+func (s *Set) Expand(tokens []string) map[string]float64 {
+	freqs := TermFrequencies(tokens)
+
+	if s == nil {
+		return freqs
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expanded := make(map[string]float64)
+	for token, count := range freqs {
+		for _, gi := range s.index[token] {
+			for _, synonym := range s.groups[gi] {
+				if _, original := freqs[synonym]; original {
+					continue
+				}
+				expanded[synonym] += count * s.weight
+			}
+		}
+	}
+
+	for term, weight := range expanded {
+		freqs[term] = weight
+	}
+
+	return freqs
+}
+
+// TermFrequencies counts how often each token occurs
+func TermFrequencies(tokens []string) map[string]float64 {
+	freqs := make(map[string]float64, len(tokens))
+	for _, token := range tokens {
+		freqs[token]++
+	}
+	return freqs
+}
+
+// Count returns the number of loaded synonym sets
+func (s *Set) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.groups)
+}
+
+// Path returns the file the synonyms were loaded from
+func (s *Set) Path() string {
+	return s.path
+}
+
+// Weight returns the weight applied to expanded synonyms
+func (s *Set) Weight() float64 {
+	return s.weight
+}
+
+// ExpandDocuments reports whether documents, not only queries, should be expanded
+func (s *Set) ExpandDocuments() bool {
+	return s.expandDocuments
+}
+
+// SetExpandDocuments controls whether documents are expanded as well as queries
+func (s *Set) SetExpandDocuments(expand bool) {
+	s.expandDocuments = expand
+}
@@ -0,0 +1,77 @@
+package synonyms
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSynonyms(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write synonyms file: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeSynonyms(t, "# comment\nCar, automobile , auto\n\nsingle\nbig,large,big\n")
+
+	set, err := Load(path, 0.5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if set.Count() != 2 {
+		t.Errorf("expected 2 synonym sets, got %d", set.Count())
+	}
+}
+
+func TestExpand(t *testing.T) {
+	set, err := Load(writeSynonyms(t, "car,automobile,auto\n"), 0.5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tokens []string
+		want   map[string]float64
+	}{
+		{"expands token", []string{"car"}, map[string]float64{"car": 1, "automobile": 0.5, "auto": 0.5}},
+		{"original wins over synonym", []string{"car", "auto"}, map[string]float64{"car": 1, "auto": 1, "automobile": 1}},
+		{"no synonyms", []string{"banana"}, map[string]float64{"banana": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := set.Expand(tt.tokens)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for term, weight := range tt.want {
+				if got[term] != weight {
+					t.Errorf("term %s: expected weight %v, got %v", term, weight, got[term])
+				}
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeSynonyms(t, "car,automobile\n")
+	set, err := Load(path, 0.5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("car,automobile\nbig,large\n"), 0644); err != nil {
+		t.Fatalf("failed to rewrite synonyms file: %v", err)
+	}
+	if err := set.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if set.Count() != 2 {
+		t.Errorf("expected 2 synonym sets after reload, got %d", set.Count())
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/tahcohcat/same-same/internal/models"
@@ -12,52 +13,104 @@ import (
 type AdvancedSearchResponse struct {
 	Results []AdvancedSearchResult `json:"results"`
 	Total   int                    `json:"total"`
+	Meta    *SearchMeta            `json:"meta,omitempty"`
+
+	// ResultSetID names the saved results with save_results, for within_results
+	ResultSetID string `json:"result_set_id,omitempty"`
+}
+
+// SearchMeta carries non-fatal information about how a search was executed
+type SearchMeta struct {
+	Warnings []string `json:"warnings,omitempty"`
+	Profile  string   `json:"profile,omitempty"`  // Ranking profile the request was completed from
+	Snapshot string   `json:"snapshot,omitempty"` // Snapshot searched instead of the live data
+
+	// KeyFallbacks maps filter fields to the differently cased or separated
+	// metadata keys that results matched them through with key_fallback
+	KeyFallbacks map[string][]string `json:"key_fallbacks,omitempty"`
+
+	// Embedder reports the override embedder selected by the request
+	Embedder *EmbedderOverrideMeta `json:"embedder,omitempty"`
+
+	// Corrections lists the misspelled query terms matched to vocabulary terms,
+	// as "from→to", see the fuzzy option
+	Corrections []string `json:"corrections,omitempty"`
 }
 
 // AdvancedSearchResult represents a single search result with flattened metadata
 type AdvancedSearchResult struct {
-	ID       string                 `json:"id"`
-	Text     string                 `json:"text,omitempty"`
-	Author   string                 `json:"author,omitempty"`
-	Year     interface{}            `json:"year,omitempty"`
-	Tags     []string               `json:"tags,omitempty"`
-	Score    float64                `json:"score"`
-	Metadata map[string]interface{} `json:"-"` // Additional metadata
+	ID          string                   `json:"id"`
+	Text        string                   `json:"text,omitempty"`
+	Author      string                   `json:"author,omitempty"`
+	Year        interface{}              `json:"year,omitempty"`
+	Tags        []string                 `json:"tags,omitempty"`
+	Score       float64                  `json:"score"`
+	Highlights  []string                 `json:"highlights,omitempty"`
+	Explanation *models.ScoreExplanation `json:"explanation,omitempty"`
+	Enrichment  json.RawMessage          `json:"enrichment,omitempty"`
+	Embedding   []float64                `json:"embedding,omitempty"`
+	Sparse      *models.SparseVector     `json:"embedding_sparse,omitempty"`
+	Metadata    map[string]interface{}   `json:"metadata,omitempty"` // With include_metadata
+
+	format *models.ResponseFormat
+}
+
+// MarshalJSON applies the response format of the search, if any
+func (r AdvancedSearchResult) MarshalJSON() ([]byte, error) {
+	type plain AdvancedSearchResult
+	if r.format == nil {
+		return json.Marshal(plain(r))
+	}
+	return json.Marshal(struct {
+		plain
+		Score       float64                  `json:"score"`
+		Explanation *models.ScoreExplanation `json:"explanation,omitempty"`
+		Embedding   interface{}              `json:"embedding,omitempty"`
+	}{plain(r), r.format.Score(r.Score), r.format.Explanation(r.Explanation), r.format.Embedding(r.Embedding)})
 }
 
-// AdvancedSearch handles POST /api/v1/search with metadata filtering
+// AdvancedSearch handles the POST /api/v1/search requests with a query, see Search
 func (vh *VectorHandler) AdvancedSearch(w http.ResponseWriter, r *http.Request) {
-	var req models.AdvancedSearchRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	vh.setGeneration(w)
+
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := req.Validate(); err != nil {
+	var req models.AdvancedSearchRequest
+	if err := vh.decodeSearchRequest(r, &req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Generate embedding for the query text
-	embedding, err := vh.embedder.Embed(req.Query)
+	query, err := advancedQuery(&req)
 	if err != nil {
-		http.Error(w, "Failed to generate embedding", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Perform advanced search with filters
-	results, err := vh.storage.AdvancedSearch(&req, embedding)
+	results, err := vh.search(r.Context(), query)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSearchError(w, err)
 		return
 	}
+	setResultSetHeader(w, query)
 
 	// Transform results to match API specification
 	apiResults := make([]AdvancedSearchResult, len(results))
 	for i, result := range results {
 		apiResults[i] = AdvancedSearchResult{
-			ID:    result.Vector.ID,
-			Score: result.Score,
+			ID:          result.Vector.ID,
+			Score:       result.Score,
+			Highlights:  result.Highlights,
+			Explanation: result.Explanation,
+			Enrichment:  result.Enrichment,
+			Embedding:   result.Vector.Embedding,
+			Sparse:      result.Vector.Sparse,
+			format:      result.Format,
 		}
 
 		// Extract common metadata fields
@@ -74,21 +127,53 @@ func (vh *VectorHandler) AdvancedSearch(w http.ResponseWriter, r *http.Request)
 			apiResults[i].Tags = parseTagsString(tags)
 		}
 
-		// Store all metadata for potential inline expansion
-		metadata := make(map[string]interface{})
-		for k, v := range result.Vector.Metadata {
-			metadata[k] = v
+		if req.IncludeMetadata {
+			metadata := make(map[string]interface{}, len(result.Vector.Metadata))
+			for k, v := range result.Vector.Metadata {
+				metadata[k] = v
+			}
+			apiResults[i].Metadata = metadata
+		}
+	}
+	if format != "" {
+		rows := make([]exportRow, len(results))
+		for i, result := range results {
+			rows[i] = exportRow{vector: result.Vector, score: result.Score, format: result.Format, item: apiResults[i]}
 		}
-		apiResults[i].Metadata = metadata
+		writeExport(w, format, query, rows)
+		return
 	}
 
 	response := AdvancedSearchResponse{
-		Results: apiResults,
-		Total:   len(apiResults),
+		Results:     apiResults,
+		Total:       len(apiResults),
+		ResultSetID: query.resultSetID,
 	}
 
+	response.Meta = query.searchMeta(vh.filterWarnings(req.Filters))
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	encodeSearchResponse(r.Context(), w, response)
+}
+
+// filterWarnings reports field patterns that match no metadata field in the whole store,
+// which would otherwise silently produce an empty result
+func (vh *VectorHandler) filterWarnings(filters map[string]models.FilterExpr) []string {
+	compiled, err := models.NewFilterEvaluator().CompileSearch(filters)
+	if err != nil || len(compiled.Patterns()) == 0 {
+		return nil
+	}
+
+	vectors, err := vh.storage.List()
+	if err != nil {
+		return nil
+	}
+
+	warnings := []string{}
+	for _, pattern := range compiled.UnmatchedPatterns(vectors) {
+		warnings = append(warnings, fmt.Sprintf("field pattern %q matches no metadata field in the store", pattern))
+	}
+	return warnings
 }
 
 // parseTagsString converts a comma-separated string to a slice
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// ageLocale returns the registered age locale best matching an Accept-Language
+// header, in order of preference, and the default locale when none matches
+func ageLocale(acceptLanguage string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	tags := make([]weighted, 0)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if locale, ok := models.LookupAgeLocale(t.tag); ok {
+			return locale
+		}
+	}
+	return models.DefaultAgeLocale
+}
+
+// age formats the age of a temporal search result at the reference time of the
+// query, as localized text or as an ISO 8601 duration when requested
+func (q *searchQuery) age(result *models.TemporalSearchResult) string {
+	reference := q.Temporal.ReferenceTime.Time
+	if q.Temporal.AgeFormat == models.AgeFormatISO {
+		return models.ISODuration(result.DocumentTime, reference)
+	}
+	return models.CalculateAge(result.DocumentTime, reference, q.ageLocale)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestAgeLocale(t *testing.T) {
+	tests := map[string]string{
+		"":                        "en",
+		"de-DE,de;q=0.9,en;q=0.8": "de",
+		"it,fr-CA;q=0.7,es;q=0.5": "fr",
+		"en;q=0.1, es;q=0.9":      "es",
+		"fr;q=0, de;q=0.5":        "de",
+		"*":                       "en",
+		"pt-BR,pt;q=0.9":          "en",
+		"es-419;q=abc, de;q=0.2":  "de",
+	}
+	for header, want := range tests {
+		if got := ageLocale(header); got != want {
+			t.Errorf("ageLocale(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestTemporalSearchAge(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	search := func(body, acceptLanguage string) (*httptest.ResponseRecorder, []*models.TemporalSearchResult) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/search/temporal", bytes.NewBufferString(body))
+		req.Header.Set("Accept-Language", acceptLanguage)
+		rec := httptest.NewRecorder()
+		vh.TemporalSearch(rec, req)
+		var resp struct {
+			Results []*models.TemporalSearchResult `json:"results"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Results) == 0 {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+		return rec, resp.Results
+	}
+
+	rec, results := search(`{"query":"quick fox"}`, "de-CH, en;q=0.5")
+	if results[0].Age != "gerade eben" || rec.Header().Get("Content-Language") != "de" {
+		t.Errorf("age = %q, content language = %q", results[0].Age, rec.Header().Get("Content-Language"))
+	}
+
+	_, results = search(`{"query":"quick fox","age_format":"iso"}`, "fr")
+	if got := results[0].Age; len(got) < 3 || got[:2] != "PT" {
+		t.Errorf("iso age = %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.TemporalSearch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search/temporal", bytes.NewBufferString(`{"query":"fox","age_format":"relative"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown age format: status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/alias"
+	"github.com/tahcohcat/same-same/internal/storage/bulk"
+)
+
+// aliasRequest is the body of PUT /api/v1/aliases/{alias} and of its swap
+type aliasRequest struct {
+	Namespace string `json:"namespace"`
+
+	// DeletePreviousAfter deletes the namespace a swap moved the alias away from
+	// after this grace period, such as "10m", with a bulk delete job
+	DeletePreviousAfter string `json:"delete_previous_after,omitempty"`
+}
+
+// resolveNamespace returns the namespace an alias points at, namespace itself
+// when it names no alias. It is a single map lookup, done once per request
+func (vh *VectorHandler) resolveNamespace(namespace string) string {
+	if namespace == "" {
+		return namespace
+	}
+	as, ok := vh.storage.(storage.AliasStore)
+	if !ok {
+		return namespace
+	}
+	if target, ok := as.ResolveAlias(namespace); ok {
+		return target
+	}
+	return namespace
+}
+
+// applyAlias replaces the namespace of a search request naming an alias by the
+// namespace the alias points at
+func (vh *VectorHandler) applyAlias(req searchRequest) {
+	switch req := req.(type) {
+	case *models.SearchByEmbbedingRequest:
+		req.Namespace = vh.resolveNamespace(req.Namespace)
+	case *models.SearchByTextRequest:
+		req.Namespace = vh.resolveNamespace(req.Namespace)
+	case *models.AdvancedSearchRequest:
+		req.Namespace = vh.resolveNamespace(req.Namespace)
+	case *models.TemporalSearchRequest:
+		req.Namespace = vh.resolveNamespace(req.Namespace)
+	}
+}
+
+// aliasStore returns the alias store of the backend, answering 501 when it has none
+func (vh *VectorHandler) aliasStore(w http.ResponseWriter) (storage.AliasStore, bool) {
+	as, ok := vh.storage.(storage.AliasStore)
+	if !ok {
+		http.Error(w, "storage backend does not support namespace aliases", http.StatusNotImplemented)
+	}
+	return as, ok
+}
+
+// ListAliases handles GET /api/v1/aliases, listing the namespace aliases by name
+func (vh *VectorHandler) ListAliases(w http.ResponseWriter, r *http.Request) {
+	as, ok := vh.aliasStore(w)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(as.Aliases().Sorted())
+}
+
+// SetAlias handles PUT /api/v1/aliases/{alias}, pointing an alias at a namespace
+// An alias cannot be named like a namespace holding vectors, nor point at another alias
+func (vh *VectorHandler) SetAlias(w http.ResponseWriter, r *http.Request) {
+	as, ok := vh.aliasStore(w)
+	if !ok {
+		return
+	}
+
+	var req aliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["alias"]
+	current, exists := as.Aliases()[name]
+	if !exists && vh.storage.CountByNamespace(name) > 0 {
+		http.Error(w, fmt.Sprintf("%q is a namespace holding vectors, it cannot be an alias", name), http.StatusConflict)
+		return
+	}
+	if err := vh.checkAliasTarget(as, req.Namespace); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a := alias.Alias{Name: name, Namespace: req.Namespace, UpdatedAt: time.Now().UTC()}
+	if exists && current.Namespace == req.Namespace {
+		a = current
+	}
+	if err := as.SetAlias(a); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !exists {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(a)
+}
+
+// SwapAlias handles POST /api/v1/aliases/{alias}/swap, retargeting an existing
+// alias to another namespace in a single step: every request resolves the alias
+// to either the old or the new namespace. delete_previous_after deletes the old
+// namespace once the grace period is over
+func (vh *VectorHandler) SwapAlias(w http.ResponseWriter, r *http.Request) {
+	as, ok := vh.aliasStore(w)
+	if !ok {
+		return
+	}
+
+	var req aliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	var grace time.Duration
+	if req.DeletePreviousAfter != "" {
+		var err error
+		if grace, err = time.ParseDuration(req.DeletePreviousAfter); err != nil || grace < 0 {
+			http.Error(w, fmt.Sprintf("invalid delete_previous_after %q: expected a duration such as \"10m\"", req.DeletePreviousAfter), http.StatusBadRequest)
+			return
+		}
+	}
+
+	name := mux.Vars(r)["alias"]
+	current, exists := as.Aliases()[name]
+	if !exists {
+		writeStoreError(w, alias.NotFound(name))
+		return
+	}
+	if err := vh.checkAliasTarget(as, req.Namespace); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	swapped := current.Swap(req.Namespace, grace, time.Now().UTC())
+	if err := as.SetAlias(swapped); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"alias": name, "from": current.Namespace, "to": swapped.Namespace}).Info("alias swapped")
+	if swapped.DeletePreviousAt != nil {
+		vh.schedulePreviousDeletion(swapped)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(swapped)
+}
+
+// DeleteAlias handles DELETE /api/v1/aliases/{alias}, leaving its namespace as it is
+func (vh *VectorHandler) DeleteAlias(w http.ResponseWriter, r *http.Request) {
+	as, ok := vh.aliasStore(w)
+	if !ok {
+		return
+	}
+
+	if err := as.DeleteAlias(mux.Vars(r)["alias"]); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkAliasTarget checks the namespace an alias is pointed at, which must not be
+// an alias itself so resolution stays a single lookup
+func (vh *VectorHandler) checkAliasTarget(as storage.AliasStore, namespace string) error {
+	if namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if _, ok := as.ResolveAlias(namespace); ok {
+		return fmt.Errorf("namespace %q is an alias, aliases must point at a namespace", namespace)
+	}
+	return nil
+}
+
+// ResumeAliasDeletions schedules the deletions of the namespaces swapped out of
+// aliases whose grace period was not over when the server stopped. It returns
+// how many were scheduled
+func (vh *VectorHandler) ResumeAliasDeletions() int {
+	as, ok := vh.storage.(storage.AliasStore)
+	if !ok {
+		return 0
+	}
+	scheduled := 0
+	for _, a := range as.Aliases() {
+		if a.DeletePreviousAt != nil {
+			vh.schedulePreviousDeletion(a)
+			scheduled++
+		}
+	}
+	return scheduled
+}
+
+// schedulePreviousDeletion deletes the previous namespace of a swapped alias when
+// its grace period is over
+func (vh *VectorHandler) schedulePreviousDeletion(a alias.Alias) {
+	time.AfterFunc(time.Until(*a.DeletePreviousAt), func() {
+		if err := vh.deletePrevious(a.Name, a.Previous); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"alias": a.Name, "namespace": a.Previous}).Error("failed to delete the previous namespace of an alias")
+		}
+	})
+}
+
+// deletePrevious queues a bulk job deleting the namespace an alias was swapped
+// away from, unless the alias was swapped again meanwhile or an alias points at it
+func (vh *VectorHandler) deletePrevious(name, previous string) error {
+	as, ok := vh.storage.(storage.AliasStore)
+	if !ok {
+		return nil
+	}
+	aliases := as.Aliases()
+	current, exists := aliases[name]
+	if !exists || current.Previous != previous || current.DeletePreviousAt == nil {
+		return nil
+	}
+
+	current.DeletePreviousAt = nil
+	for _, other := range aliases {
+		if other.Namespace == previous {
+			return as.SetAlias(current)
+		}
+	}
+
+	req := bulk.Request{Action: bulk.ActionDelete, Namespace: previous}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	params, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	job, err := vh.jobs.Submit(bulk.JobType, params)
+	if err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{"alias": name, "namespace": previous, "job": job.ID}).Info("deleting the previous namespace of an alias")
+	return as.SetAlias(current)
+}
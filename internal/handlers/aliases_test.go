@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/alias"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func aliasRequestTo(t *testing.T, handle http.HandlerFunc, method, name, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	target := "/api/v1/aliases/" + name
+	if strings.HasSuffix(method, "swap") {
+		method, target = http.MethodPost, target+"/swap"
+	}
+	handle(rec, mux.SetURLVars(httptest.NewRequest(method, target, bytes.NewBufferString(body)), map[string]string{"alias": name}))
+	return rec
+}
+
+// searchIDs searches namespace by embedding and returns the IDs of the results
+func searchIDs(t *testing.T, vh *VectorHandler, namespace string) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	body := `{"embedding": [1, 0], "limit": 10, "namespace": "` + namespace + `"}`
+	vh.SearchVectors(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/search", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("search %s: status = %d: %s", namespace, rec.Code, rec.Body.String())
+		return nil
+	}
+	var results []models.SearchResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Errorf("failed to decode results: %v", err)
+		return nil
+	}
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Vector.ID
+	}
+	return ids
+}
+
+func TestAliases_CRUD(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, fixedEmbedder{1, 0})
+	storeNamespaced(t, store, "products-v1", "v1", 2)
+
+	if rec := aliasRequestTo(t, vh.SetAlias, http.MethodPut, "products", `{"namespace": "products-v1"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := aliasRequestTo(t, vh.SetAlias, http.MethodPut, "products", `{"namespace": "products-v1"}`); rec.Code != http.StatusOK {
+		t.Errorf("replace: status = %d", rec.Code)
+	}
+	if rec := aliasRequestTo(t, vh.SetAlias, http.MethodPut, "products-v1", `{"namespace": "other"}`); rec.Code != http.StatusConflict {
+		t.Errorf("alias named like a namespace: status = %d, want 409", rec.Code)
+	}
+	if rec := aliasRequestTo(t, vh.SetAlias, http.MethodPut, "shop", `{"namespace": "products"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("alias of an alias: status = %d, want 400", rec.Code)
+	}
+	if rec := aliasRequestTo(t, vh.SetAlias, http.MethodPut, "shop", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing namespace: status = %d, want 400", rec.Code)
+	}
+	if rec := aliasRequestTo(t, vh.SetAlias, http.MethodPut, "sh*op", `{"namespace": "products-v1"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid name: status = %d, want 422", rec.Code)
+	}
+	if rec := aliasRequestTo(t, vh.SwapAlias, "swap", "missing", `{"namespace": "products-v1"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("swap of a missing alias: status = %d, want 404", rec.Code)
+	}
+	if rec := aliasRequestTo(t, vh.SwapAlias, "swap", "products", `{"namespace": "products-v2", "delete_previous_after": "soon"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid grace period: status = %d, want 400", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	vh.ListAliases(rec, httptest.NewRequest(http.MethodGet, "/api/v1/aliases", nil))
+	var listed []alias.Alias
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to decode aliases: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Name != "products" || listed[0].Namespace != "products-v1" {
+		t.Errorf("aliases = %+v, want products -> products-v1", listed)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.CountVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/count?namespace=products", nil))
+	if !strings.Contains(rec.Body.String(), `"count":2`) {
+		t.Errorf("count through the alias = %s, want 2", rec.Body.String())
+	}
+
+	if rec := aliasRequestTo(t, vh.DeleteAlias, http.MethodDelete, "products", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status = %d", rec.Code)
+	}
+	if rec := aliasRequestTo(t, vh.DeleteAlias, http.MethodDelete, "products", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("delete deleted: status = %d, want 404", rec.Code)
+	}
+	if store.CountByNamespace("products-v1") != 2 {
+		t.Error("deleting an alias must leave its namespace as it is")
+	}
+}
+
+func TestAliases_BlueGreenSwap(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, fixedEmbedder{1, 0})
+	storeNamespaced(t, store, "products-v1", "v1", 3)
+	aliasRequestTo(t, vh.SetAlias, http.MethodPut, "products", `{"namespace": "products-v1"}`)
+
+	if ids := searchIDs(t, vh, "products"); len(ids) != 3 || !strings.HasPrefix(ids[0], "v1-") {
+		t.Fatalf("search through the alias = %v, want the v1 vectors", ids)
+	}
+
+	// Readers keep searching through the alias while v2 is built and swapped in
+	stop := make(chan struct{})
+	var searches, empty atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if len(searchIDs(t, vh, "products")) == 0 {
+					empty.Add(1)
+				}
+				searches.Add(1)
+			}
+		}()
+	}
+
+	storeNamespaced(t, store, "products-v2", "v2", 4)
+	rec := aliasRequestTo(t, vh.SwapAlias, "swap", "products", `{"namespace": "products-v2", "delete_previous_after": "20ms"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("swap: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	if empty.Load() != 0 {
+		t.Errorf("%d of %d searches during the swap found nothing", empty.Load(), searches.Load())
+	}
+	if ids := searchIDs(t, vh, "products"); len(ids) != 4 || !strings.HasPrefix(ids[0], "v2-") {
+		t.Fatalf("search after the swap = %v, want the v2 vectors", ids)
+	}
+
+	var swapped alias.Alias
+	if err := json.Unmarshal(rec.Body.Bytes(), &swapped); err != nil {
+		t.Fatalf("failed to decode alias: %v", err)
+	}
+	if swapped.Previous != "products-v1" || swapped.DeletePreviousAt == nil {
+		t.Errorf("swapped alias = %+v, want previous products-v1 to be deleted", swapped)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for store.CountByNamespace("products-v1") > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := store.CountByNamespace("products-v1"); n != 0 {
+		t.Errorf("products-v1 still holds %d vectors after the grace period", n)
+	}
+	if n := store.CountByNamespace("products-v2"); n != 4 {
+		t.Errorf("products-v2 holds %d vectors, want 4", n)
+	}
+}
+
+func TestAliases_KeepPreviousStillAliased(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, fixedEmbedder{1, 0})
+	storeNamespaced(t, store, "products-v1", "v1", 2)
+	aliasRequestTo(t, vh.SetAlias, http.MethodPut, "products", `{"namespace": "products-v1"}`)
+	aliasRequestTo(t, vh.SetAlias, http.MethodPut, "rollback", `{"namespace": "products-v1"}`)
+
+	aliasRequestTo(t, vh.SwapAlias, "swap", "products", `{"namespace": "products-v2", "delete_previous_after": "1h"}`)
+	if err := vh.deletePrevious("products", "products-v1"); err != nil {
+		t.Fatalf("delete previous: %v", err)
+	}
+
+	if n := store.CountByNamespace("products-v1"); n != 2 {
+		t.Errorf("products-v1 holds %d vectors, want 2: the rollback alias points at it", n)
+	}
+	if a := store.Aliases()["products"]; a.DeletePreviousAt != nil {
+		t.Errorf("alias = %+v, want the deletion to be dropped", a)
+	}
+}
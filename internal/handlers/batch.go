@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/tracing"
+)
+
+// BatchRequest is the body of POST /api/v1/vectors/batch
+type BatchRequest struct {
+	Vectors []models.VectorInput `json:"vectors"`
+
+	// Atomic stores the vectors all or nothing, for related records such as a
+	// document and its chunks. Backends without atomic batches answer 501
+	Atomic bool `json:"atomic,omitempty"`
+}
+
+// BatchResponse lists the IDs of the vectors stored by a batch, in request order,
+// and the quarantine entries of the vectors failing validation when quarantine is enabled
+type BatchResponse struct {
+	Stored      int      `json:"stored"`
+	IDs         []string `json:"ids"`
+	Atomic      bool     `json:"atomic"`
+	Quarantined []string `json:"quarantined,omitempty"`
+}
+
+// StoreVectorBatch handles POST /api/v1/vectors/batch
+// Every vector is validated before any is stored. Without atomic, a backend
+// lacking a batch path stores them one by one and may stop part way
+// With quarantine enabled, the vectors of a batch that is not atomic failing
+// validation are quarantined and the others stored
+func (vh *VectorHandler) StoreVectorBatch(w http.ResponseWriter, r *http.Request) {
+	allowEmpty, _, err := parseBoolQuery(r, "allow_empty_embedding")
+	if err != nil {
+		http.Error(w, "invalid allow_empty_embedding value", http.StatusBadRequest)
+		return
+	}
+
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Vectors) == 0 {
+		http.Error(w, "vectors cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if vh.quarantine && !req.Atomic {
+		vh.storeQuarantiningBatch(w, r, req.Vectors, allowEmpty)
+		return
+	}
+
+	vectors := make([]*models.Vector, len(req.Vectors))
+	for i := range req.Vectors {
+		vector, err := req.Vectors[i].ToVector()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("vector %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		vectors[i] = vector
+	}
+	if err := models.ValidateBatch(vectors, allowEmpty); err != nil {
+		http.Error(w, err.Error(), validationStatus(err))
+		return
+	}
+	for i, vector := range vectors {
+		if !vector.HasEmbedding() {
+			vector.DType = ""
+		}
+		if err := vh.normalizeMetadata(vector); err != nil {
+			writeMetadataError(w, fmt.Errorf("vector %d: %w", i, err))
+			return
+		}
+	}
+
+	as, atomic := vh.storage.(storage.AtomicStorer)
+	if req.Atomic && !atomic {
+		http.Error(w, "storage backend does not support atomic batches", http.StatusNotImplemented)
+		return
+	}
+
+	_, span := tracing.Start(r.Context(), spanStoreBatch)
+	if span.IsRecording() {
+		span.SetAttributes(tracing.BatchSize.Int(len(vectors)))
+	}
+	if req.Atomic {
+		err = as.StoreAll(vectors)
+	} else {
+		err = storage.StoreBatch(vh.storage, vectors)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	vh.evaluateWatches(vectors)
+
+	resp := BatchResponse{Stored: len(vectors), IDs: make([]string, len(vectors)), Atomic: req.Atomic}
+	for i, vector := range vectors {
+		resp.IDs[i] = vector.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// storeQuarantiningBatch stores the valid vectors of a batch and quarantines the others
+func (vh *VectorHandler) storeQuarantiningBatch(w http.ResponseWriter, r *http.Request, inputs []models.VectorInput, allowEmpty bool) {
+	qs, ok := vh.quarantineStore(w)
+	if !ok {
+		return
+	}
+	vectors, entries, err := vh.splitBatch(inputs, allowEmpty)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := BatchResponse{Stored: len(vectors), IDs: make([]string, len(vectors))}
+	if len(entries) > 0 {
+		if err := qs.Quarantine(entries...); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		for _, entry := range entries {
+			resp.Quarantined = append(resp.Quarantined, entry.ID)
+		}
+	}
+
+	if len(vectors) > 0 {
+		_, span := tracing.Start(r.Context(), spanStoreBatch)
+		if span.IsRecording() {
+			span.SetAttributes(tracing.BatchSize.Int(len(vectors)))
+		}
+		err = storage.StoreBatch(vh.storage, vectors)
+		tracing.End(span, err)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		vh.evaluateWatches(vectors)
+	}
+	for i, vector := range vectors {
+		resp.IDs[i] = vector.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
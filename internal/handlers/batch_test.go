@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// nonAtomicStorage hides the atomic batch path of the memory backend
+type nonAtomicStorage struct {
+	storage.Storage
+}
+
+func TestStoreVectorBatch(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+
+	post := func(vh *VectorHandler, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		vh.StoreVectorBatch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/batch", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	rec := post(vh, `{"atomic":true,"vectors":[{"id":"doc","embedding":[1,0]},{"id":"doc#1","embedding":[0,1]}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Stored != 2 || !resp.Atomic || len(resp.IDs) != 2 || resp.IDs[1] != "doc#1" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	// A failing vector at any position keeps the whole batch out
+	for _, body := range []string{
+		`{"atomic":true,"vectors":[{"id":"doc#2","embedding":[1,1]},{"id":"doc#3"}]}`,
+		`{"atomic":true,"vectors":[{"id":"doc#2","embedding":[1,1]},{"id":"doc#2","embedding":[1,2]}]}`,
+		`{"atomic":true,"vectors":[]}`,
+	} {
+		if rec := post(vh, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+	if store.Count() != 2 {
+		t.Errorf("count = %d after rejected batches, want 2", store.Count())
+	}
+
+	rec = post(NewVectorHandler(nonAtomicStorage{store}, hash.NewHashEmbedder()), `{"atomic":true,"vectors":[{"id":"x","embedding":[1,0]}]}`)
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("atomic batch on backend without support: status = %d, want 501", rec.Code)
+	}
+	rec = post(NewVectorHandler(nonAtomicStorage{store}, hash.NewHashEmbedder()), `{"vectors":[{"id":"x","embedding":[1,0]}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("batch on backend without atomic support: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := store.Get("x"); err != nil {
+		t.Errorf("non-atomic batch not stored: %v", err)
+	}
+}
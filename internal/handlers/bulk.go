@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/jobs"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/bulk"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+// bulkJobHandler runs the jobs of bulk.JobType
+type bulkJobHandler struct {
+	vh *VectorHandler
+}
+
+// Validate checks a bulk request, filling its batch size and rate defaults
+func (h bulkJobHandler) Validate(params json.RawMessage) (json.RawMessage, error) {
+	var req bulk.Request
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid bulk request: %w", err)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if req.DryRun {
+		return nil, fmt.Errorf("dry_run cannot be used with a job, send it to /api/v1/admin/bulk")
+	}
+	return json.Marshal(req)
+}
+
+// Run processes the targets of the job after its cursor in batches, pausing between
+// batches to stay within the job rate, and checkpoints its progress after each one
+func (h bulkJobHandler) Run(ctx context.Context, run *jobs.Run) error {
+	vh := h.vh
+	var req bulk.Request
+	if err := run.Params(&req); err != nil {
+		return err
+	}
+	ids, err := vh.jobTargets(req.Namespace, req.Filters, run.Checkpoint())
+	if err != nil {
+		return err
+	}
+	run.Update(run.Checkpoint(), func(p *jobs.Progress) { p.Total = p.Processed + len(ids) })
+
+	start := time.Now()
+	for done := 0; done < len(ids); {
+		end := done + req.BatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[done:end]
+		affected, failures, err := vh.applyBulkBatch(&req, batch)
+		done = end
+
+		run.Update(batch[len(batch)-1], func(p *jobs.Progress) {
+			p.Processed += len(batch)
+			p.Affected += affected
+			for _, failure := range failures {
+				p.RecordError(failure)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		// Wait until the vectors processed so far fit within the rate
+		wait := time.Duration(float64(done)/float64(req.Rate)*float64(time.Second)) - time.Since(start)
+		if wait > 0 && done < len(ids) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bulkView returns the bulk view of job, a not found error for jobs of other types
+func bulkView(job *jobs.Job) (*bulk.Job, error) {
+	if job.Type != bulk.JobType {
+		return nil, bulk.NotFound(job.ID)
+	}
+	return bulk.View(job)
+}
+
+// StartBulkJob handles POST /api/v1/admin/bulk, queueing a bulk operation on the
+// vectors matching a filter. A dry run answers the matched count and a sample of
+// IDs instead
+func (vh *VectorHandler) StartBulkJob(w http.ResponseWriter, r *http.Request) {
+	var req bulk.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.DryRun {
+		ids, err := vh.jobTargets(req.Namespace, req.Filters, "")
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		sample := ids
+		if len(sample) > bulk.SampleSize {
+			sample = sample[:bulk.SampleSize]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bulk.DryRun{Matched: len(ids), Sample: sample})
+		return
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	job, err := vh.jobs.Submit(bulk.JobType, params)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	view, err := bulkView(job)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/admin/bulk/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(view)
+}
+
+// ListBulkJobs handles GET /api/v1/admin/bulk, listing the bulk jobs newest first
+func (vh *VectorHandler) ListBulkJobs(w http.ResponseWriter, r *http.Request) {
+	listed := []*bulk.Job{}
+	for _, job := range vh.jobs.List(bulk.JobType) {
+		view, err := bulkView(job)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		listed = append(listed, view)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listed)
+}
+
+// GetBulkJob handles GET /api/v1/admin/bulk/{id}, reporting the progress of a job
+func (vh *VectorHandler) GetBulkJob(w http.ResponseWriter, r *http.Request) {
+	job, err := vh.jobs.Get(mux.Vars(r)["id"])
+	var view *bulk.Job
+	if err == nil {
+		view, err = bulkView(job)
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// CancelBulkJob handles DELETE /api/v1/admin/bulk/{id}, canceling a queued or running job
+// The batch in progress completes, so the vectors it handled stay changed
+func (vh *VectorHandler) CancelBulkJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	job, err := vh.jobs.Get(id)
+	if err == nil {
+		_, err = bulkView(job)
+	}
+	if err == nil {
+		job, err = vh.jobs.Cancel(id)
+	}
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	view, err := bulkView(job)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// jobTargets returns the IDs of the vectors of namespace matching filters after
+// cursor, in ID order, so a job resumes where it stopped
+func (vh *VectorHandler) jobTargets(namespace string, filters models.Filters, cursor string) ([]string, error) {
+	vectors, err := vh.storage.ListByNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	evaluator := &models.FilterEvaluator{KeyFallback: vh.keyFallback.Load()}
+	compiled, err := evaluator.Compile(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, vector := range vectors {
+		if vector.ID > cursor && evaluator.Matches(vector.Metadata, compiled) {
+			ids = append(ids, vector.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// applyBulkBatch applies req to the vectors of ids that still match it
+// failures are the vectors that could not be updated or whose enrichment could not
+// be deleted; err stops the job
+func (vh *VectorHandler) applyBulkBatch(req *bulk.Request, ids []string) (affected int, failures []error, err error) {
+	evaluator := &models.FilterEvaluator{KeyFallback: vh.keyFallback.Load()}
+	filters, err := evaluator.Compile(req.Filters)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Vectors deleted or changed since the targets were listed are skipped
+	var matched []*models.Vector
+	for _, id := range ids {
+		vector, err := vh.storage.Get(id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			return 0, nil, err
+		}
+		if search.MatchesNamespace(vector.Metadata, req.Namespace) && evaluator.Matches(vector.Metadata, filters) {
+			matched = append(matched, vector)
+		}
+	}
+	if len(matched) == 0 {
+		return 0, nil, nil
+	}
+
+	if req.Action == bulk.ActionDelete {
+		matchedIDs := make([]string, len(matched))
+		for i, vector := range matched {
+			matchedIDs[i] = vector.ID
+		}
+		affected, err := storage.DeleteBatch(vh.storage, matchedIDs)
+		if err != nil {
+			return affected, nil, err
+		}
+		for _, vector := range matched {
+			if err := vh.cascadeEnrichment(vector, req.CascadeEnrichment); err != nil {
+				failures = append(failures, fmt.Errorf("enrichment of vector %s: %w", vector.ID, err))
+			}
+		}
+		return affected, failures, nil
+	}
+
+	now := time.Now()
+	updated := make([]*models.Vector, len(matched))
+	for i, vector := range matched {
+		updated[i] = req.Apply(vector)
+		updated[i].UpdatedAt = now
+	}
+	if err := vh.storeBulkBatch(updated); err == nil {
+		return len(updated), nil, nil
+	}
+
+	// Store the vectors one by one to tell which ones are rejected, by a quota or
+	// a unique key of the target namespace for instance
+	for _, vector := range updated {
+		if err := vh.storage.Store(vector); err != nil {
+			failures = append(failures, fmt.Errorf("vector %s: %w", vector.ID, err))
+			continue
+		}
+		affected++
+	}
+	return affected, failures, nil
+}
+
+// storeBulkBatch stores the updated vectors of a batch all or nothing when the
+// backend can, so a rejected batch is retried vector by vector from a clean state
+func (vh *VectorHandler) storeBulkBatch(vectors []*models.Vector) error {
+	if _, ok := vh.storage.(storage.AtomicStorer); ok {
+		return storage.StoreAll(vh.storage, vectors)
+	}
+	return storage.StoreBatch(vh.storage, vectors)
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/bulk"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// storeNamespaced stores count vectors in namespace with IDs prefix-00, prefix-01, ...
+func storeNamespaced(t *testing.T, store storage.Storage, namespace, prefix string, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		vector := &models.Vector{
+			ID:        fmt.Sprintf("%s-%02d", prefix, i),
+			Embedding: []float64{1, float64(i)},
+			Metadata:  map[string]string{models.NamespaceKey: namespace, "parity": fmt.Sprint(i % 2)},
+		}
+		if err := store.Store(vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func startBulk(t *testing.T, vh *VectorHandler, body string) (int, *bulk.Job, *bulk.DryRun) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	vh.StartBulkJob(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/bulk", bytes.NewBufferString(body)))
+	switch rec.Code {
+	case http.StatusAccepted:
+		var job bulk.Job
+		if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+			t.Fatalf("failed to decode job: %v", err)
+		}
+		return rec.Code, &job, nil
+	case http.StatusOK:
+		var dryRun bulk.DryRun
+		if err := json.Unmarshal(rec.Body.Bytes(), &dryRun); err != nil {
+			t.Fatalf("failed to decode dry run: %v", err)
+		}
+		return rec.Code, nil, &dryRun
+	}
+	return rec.Code, nil, nil
+}
+
+func getBulkJob(t *testing.T, vh *VectorHandler, id string) *bulk.Job {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	vh.GetBulkJob(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/admin/bulk/"+id, nil), map[string]string{"id": id}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get job: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var job bulk.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("failed to decode job: %v", err)
+	}
+	return &job
+}
+
+// waitBulkJob polls a job until it finishes
+func waitBulkJob(t *testing.T, vh *VectorHandler, id string) *bulk.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job := getBulkJob(t, vh, id); job.Finished() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("bulk job %s did not finish", id)
+	return nil
+}
+
+func TestBulkJobs(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, fixedEmbedder{1, 0})
+	storeNamespaced(t, store, "wrong", "w", 25)
+	storeNamespaced(t, store, "keep", "k", 5)
+
+	if code, _, _ := startBulk(t, vh, `{"action": "delete"}`); code != http.StatusBadRequest {
+		t.Errorf("no selection: status = %d, want 400", code)
+	}
+
+	// A dry run changes nothing
+	code, _, dryRun := startBulk(t, vh, `{"action": "set-namespace", "namespace": "wrong", "target_namespace": "right", "dry_run": true}`)
+	if code != http.StatusOK || dryRun.Matched != 25 || len(dryRun.Sample) != bulk.SampleSize || dryRun.Sample[0] != "w-00" {
+		t.Fatalf("dry run: status = %d, %+v", code, dryRun)
+	}
+	if store.CountByNamespace("wrong") != 25 {
+		t.Fatalf("dry run moved vectors")
+	}
+
+	code, job, _ := startBulk(t, vh, `{"action": "set-namespace", "namespace": "wrong", "target_namespace": "right", "batch_size": 10, "rate": 100000}`)
+	if code != http.StatusAccepted {
+		t.Fatalf("start: status = %d", code)
+	}
+	job = waitBulkJob(t, vh, job.ID)
+	if job.State != bulk.StateCompleted || job.Matched != 25 || job.Processed != 25 || job.Affected != 25 || job.Cursor != "w-24" {
+		t.Errorf("set-namespace job = %+v", job)
+	}
+	if store.CountByNamespace("wrong") != 0 || store.CountByNamespace("right") != 25 || store.CountByNamespace("keep") != 5 {
+		t.Errorf("namespaces after move: wrong %d, right %d, keep %d",
+			store.CountByNamespace("wrong"), store.CountByNamespace("right"), store.CountByNamespace("keep"))
+	}
+
+	_, job, _ = startBulk(t, vh, `{"action": "set-metadata", "namespace": "right", "metadata": {"reviewed": "yes", "parity": null}, "rate": 100000}`)
+	job = waitBulkJob(t, vh, job.ID)
+	if vector, _ := store.Get("w-03"); job.Affected != 25 || vector.Metadata["reviewed"] != "yes" || vector.Metadata["parity"] != "" {
+		t.Errorf("set-metadata job = %+v, vector metadata %v", job, vector.Metadata)
+	}
+
+	_, job, _ = startBulk(t, vh, `{"action": "delete", "filters": {"parity": {"eq": "1"}}, "rate": 100000}`)
+	job = waitBulkJob(t, vh, job.ID)
+	if job.Affected != 2 || store.Count() != 28 {
+		t.Errorf("delete job = %+v, %d vectors left, want the 2 odd vectors of keep deleted", job, store.Count())
+	}
+
+	rec := httptest.NewRecorder()
+	vh.ListBulkJobs(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/bulk", nil))
+	var listed []*bulk.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed) != 3 || listed[0].ID != job.ID {
+		t.Errorf("listed jobs = %d, err = %v, want 3 newest first", len(listed), err)
+	}
+}
+
+func TestBulkJobs_Cancel(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, fixedEmbedder{1, 0})
+	storeNamespaced(t, store, "wrong", "w", 10)
+
+	// One vector per second leaves the job waiting after its first batch
+	_, job, _ := startBulk(t, vh, `{"action": "delete", "namespace": "wrong", "batch_size": 1, "rate": 1}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for getBulkJob(t, vh, job.ID).Processed == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel := func() int {
+		rec := httptest.NewRecorder()
+		vh.CancelBulkJob(rec, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/v1/admin/bulk/"+job.ID, nil), map[string]string{"id": job.ID}))
+		return rec.Code
+	}
+	if code := cancel(); code != http.StatusOK {
+		t.Fatalf("cancel: status = %d", code)
+	}
+	if code := cancel(); code != http.StatusConflict {
+		t.Errorf("cancel twice: status = %d, want 409", code)
+	}
+	if job = getBulkJob(t, vh, job.ID); job.State != bulk.StateCanceled || job.Processed != 1 || store.Count() != 9 {
+		t.Errorf("canceled job = %+v, %d vectors left", job, store.Count())
+	}
+}
+
+func TestBulkJobs_ResumeLocal(t *testing.T) {
+	basePath := t.TempDir()
+	adapter, err := local.NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeNamespaced(t, adapter, "wrong", "w", 6)
+
+	// A job interrupted after its first three vectors
+	req := bulk.Request{Action: bulk.ActionDelete, Namespace: "wrong"}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	record, err := (&bulk.Job{ID: "interrupted", Request: req, State: bulk.StateRunning, Processed: 3, Cursor: "w-02", CreatedAt: time.Now()}).Record()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.SaveJob(record); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := local.NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vh := NewVectorHandler(reopened, fixedEmbedder{1, 0})
+	if resumed, err := vh.ResumeJobs(); err != nil || resumed != 1 {
+		t.Fatalf("ResumeJobs() = %d, %v", resumed, err)
+	}
+
+	job := waitBulkJob(t, vh, "interrupted")
+	if job.State != bulk.StateCompleted || job.Matched != 6 || job.Processed != 6 || job.Affected != 3 {
+		t.Errorf("resumed job = %+v", job)
+	}
+	if _, err := reopened.Get("w-02"); err != nil {
+		t.Errorf("vector before the cursor deleted: %v", err)
+	}
+	if _, err := reopened.Get("w-03"); err == nil {
+		t.Errorf("vector after the cursor kept")
+	}
+
+	jobs, err := reopened.Jobs()
+	if err != nil || len(jobs) != 1 || jobs[0].State != bulk.StateCompleted || jobs[0].Attempts != 1 {
+		t.Errorf("persisted jobs = %+v, %v", jobs, err)
+	}
+}
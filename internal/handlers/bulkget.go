@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// DefaultBulkGetLimit is the number of IDs a bulk get accepts unless SetBulkGetLimit changes it
+const DefaultBulkGetLimit = 1000
+
+// BulkGetRequest is the body of POST /api/v1/vectors/bulk-get
+type BulkGetRequest struct {
+	IDs []string `json:"ids"`
+
+	// IncludeEmbedding returns the stored embeddings, true when omitted
+	IncludeEmbedding *bool `json:"include_embedding,omitempty"`
+
+	// MetadataFields lists the metadata keys returned with each vector
+	// Omitted or containing "*" returns all metadata, [] returns none
+	MetadataFields []string `json:"metadata_fields"`
+}
+
+// BulkGetResponse holds the vectors found, in request order, and the IDs not stored
+type BulkGetResponse struct {
+	Vectors []*models.Vector `json:"vectors"`
+	Missing []string         `json:"missing"`
+	Found   int              `json:"found"`
+}
+
+// SetBulkGetLimit sets the number of IDs a bulk get accepts, DefaultBulkGetLimit when not positive
+func (vh *VectorHandler) SetBulkGetLimit(limit int) {
+	if limit <= 0 {
+		limit = DefaultBulkGetLimit
+	}
+	vh.bulkGetLimit.Store(int64(limit))
+}
+
+// BulkGetVectors handles POST /api/v1/vectors/bulk-get, fetching many vectors by ID
+// in one storage call instead of a GET per vector
+func (vh *VectorHandler) BulkGetVectors(w http.ResponseWriter, r *http.Request) {
+	vh.setGeneration(w)
+
+	var req BulkGetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) == 0 {
+		http.Error(w, "ids cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if limit := int(vh.bulkGetLimit.Load()); len(req.IDs) > limit {
+		http.Error(w, fmt.Sprintf("at most %d ids can be fetched at once, got %d", limit, len(req.IDs)), http.StatusBadRequest)
+		return
+	}
+	for i, id := range req.IDs {
+		if err := models.ValidateID(id); err != nil {
+			http.Error(w, fmt.Sprintf("ids[%d]: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	projection := &searchQuery{ReturnEmbedding: req.IncludeEmbedding == nil || *req.IncludeEmbedding, MetadataFields: req.MetadataFields}
+	for _, field := range projection.MetadataFields {
+		if field == "*" {
+			projection.MetadataFields = nil
+			break
+		}
+	}
+
+	vectors, err := vh.storage.GetMany(req.IDs)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	resp := BulkGetResponse{Vectors: make([]*models.Vector, 0, len(vectors)), Missing: make([]string, 0)}
+	for i, vector := range vectors {
+		if vector == nil {
+			resp.Missing = append(resp.Missing, req.IDs[i])
+			continue
+		}
+		resp.Vectors = append(resp.Vectors, projection.responseVector(vector))
+	}
+	resp.Found = len(resp.Vectors)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func bulkGet(t *testing.T, vh *VectorHandler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	vh.BulkGetVectors(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/bulk-get", strings.NewReader(body)))
+	return rec
+}
+
+func TestBulkGetVectors(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	rec := bulkGet(t, vh, `{"ids": ["turtle", "unknown", "fox", "gone"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp BulkGetResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Found != 2 || len(resp.Vectors) != 2 || resp.Vectors[0].ID != "turtle" || resp.Vectors[1].ID != "fox" {
+		t.Errorf("vectors = %+v, want turtle and fox in request order", resp.Vectors)
+	}
+	if strings.Join(resp.Missing, ",") != "unknown,gone" {
+		t.Errorf("missing = %v", resp.Missing)
+	}
+	if len(resp.Vectors[0].Embedding) == 0 || resp.Vectors[0].Metadata["category"] != "b" {
+		t.Errorf("full record expected by default, got %+v", resp.Vectors[0])
+	}
+}
+
+func TestBulkGetVectors_Projection(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	rec := bulkGet(t, vh, `{"ids": ["fox"], "include_embedding": false, "metadata_fields": ["text"]}`)
+	var resp BulkGetResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Vectors) != 1 || resp.Vectors[0].Embedding != nil || len(resp.Vectors[0].Metadata) != 1 || resp.Vectors[0].Metadata["text"] != "the quick brown fox" {
+		t.Errorf("projected vectors = %+v", resp.Vectors)
+	}
+
+	// The stored vector is left untouched by the projection
+	if stored, _ := vh.storage.Get("fox"); len(stored.Embedding) == 0 || len(stored.Metadata) != 3 {
+		t.Errorf("stored vector changed: %+v", stored)
+	}
+}
+
+func TestBulkGetVectors_Limits(t *testing.T) {
+	vh := newSearchTestHandler(t)
+	vh.SetBulkGetLimit(2)
+
+	for _, body := range []string{`{"ids": ["fox", "dogs", "turtle"]}`, `{"ids": []}`, `{"ids": ["fox", ""]}`, `{`} {
+		if rec := bulkGet(t, vh, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := bulkGet(t, vh, `{"ids": ["fox", "dogs"]}`); rec.Code != http.StatusOK {
+		t.Errorf("at the limit: status = %d", rec.Code)
+	}
+}
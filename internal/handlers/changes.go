@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+)
+
+// Page sizes of delta listings
+const (
+	defaultChangesLimit = 1000
+	maxChangesLimit     = 10000
+)
+
+// changesCursor resumes a delta listing after the last change of a page
+type changesCursor struct {
+	UpdatedAfter time.Time `json:"updated_after"`
+	AfterID      string    `json:"after_id"`
+}
+
+// changesResponse is a page of the delta listing of GET /api/v1/vectors?updated_after=
+type changesResponse struct {
+	Vectors []*models.Vector      `json:"vectors"`
+	Deleted []tombstone.Tombstone `json:"deleted"`
+	HasMore bool                  `json:"has_more"`
+	Next    *changesCursor        `json:"next,omitempty"` // Query parameters of the next page
+
+	// ResyncRequired is set when updated_after is older than the tombstone horizon,
+	// so deletions may be missing and the client must list every vector again
+	ResyncRequired   bool       `json:"resync_required,omitempty"`
+	TombstoneHorizon *time.Time `json:"tombstone_horizon,omitempty"`
+}
+
+// change is a vector update or deletion of a delta listing
+type change struct {
+	at        time.Time
+	id        string
+	vector    *models.Vector
+	tombstone *tombstone.Tombstone
+}
+
+// after reports whether c comes after the cursor
+func (c change) after(cursor changesCursor) bool {
+	if !c.at.Equal(cursor.UpdatedAfter) {
+		return c.at.After(cursor.UpdatedAfter)
+	}
+	return cursor.AfterID != "" && c.id > cursor.AfterID
+}
+
+// changeLister returns the change lister of the storage, writing a 501 when it has none
+func (vh *VectorHandler) changeLister(w http.ResponseWriter) (storage.ChangeLister, bool) {
+	cl, ok := vh.storage.(storage.ChangeLister)
+	if !ok {
+		http.Error(w, "storage backend does not support delta listings", http.StatusNotImplemented)
+	}
+	return cl, ok
+}
+
+// listChanges handles GET /api/v1/vectors?updated_after=&after_id=&limit=&namespace=&has_embedding=&as_of=
+// It returns the vectors updated after updated_after and the IDs of those deleted since,
+// merged oldest first, with after_id resuming a page within changes of the same time
+// updated_after may be relative such as "now-1h", resolved against as_of when given
+func (vh *VectorHandler) listChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	anchor := time.Now()
+	if asOf := query.Get("as_of"); asOf != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, asOf)
+		if err != nil {
+			http.Error(w, "invalid as_of: expected an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		anchor = parsed
+	}
+	updatedAfter, err := models.ParseTimeExpr(query.Get("updated_after"), anchor)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid updated_after: %v", err), http.StatusBadRequest)
+		return
+	}
+	cursor := changesCursor{UpdatedAfter: updatedAfter, AfterID: query.Get("after_id")}
+
+	limit := defaultChangesLimit
+	if l := query.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 || parsed > maxChangesLimit {
+			http.Error(w, fmt.Sprintf("limit must be an integer between 1 and %d", maxChangesLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	hasEmbedding, filter, err := parseBoolQuery(r, "has_embedding")
+	if err != nil {
+		http.Error(w, "invalid has_embedding value", http.StatusBadRequest)
+		return
+	}
+
+	cl, ok := vh.changeLister(w)
+	if !ok {
+		return
+	}
+	changes, err := cl.Changes(vh.resolveNamespace(query.Get("namespace")), updatedAfter)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if filter {
+		changes.Vectors = filterByEmbedding(changes.Vectors, hasEmbedding)
+	}
+
+	merged := mergeChanges(changes, cursor)
+	response := changesResponse{
+		Vectors: make([]*models.Vector, 0),
+		Deleted: make([]tombstone.Tombstone, 0),
+		HasMore: len(merged) > limit,
+	}
+	if !changes.Horizon.IsZero() {
+		response.TombstoneHorizon = &changes.Horizon
+		response.ResyncRequired = updatedAfter.Before(changes.Horizon)
+	}
+	if response.HasMore {
+		merged = merged[:limit]
+	}
+	for _, c := range merged {
+		if c.vector != nil {
+			response.Vectors = append(response.Vectors, c.vector)
+		} else {
+			response.Deleted = append(response.Deleted, *c.tombstone)
+		}
+	}
+	if len(merged) > 0 {
+		last := merged[len(merged)-1]
+		response.Next = &changesCursor{UpdatedAfter: last.at, AfterID: last.id}
+		w.Header().Set("Last-Modified", last.at.UTC().Format(http.TimeFormat))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// mergeChanges merges the updates and deletions of changes after cursor, oldest first
+// Both are already sorted by time then ID
+func mergeChanges(changes *tombstone.Changes, cursor changesCursor) []change {
+	merged := make([]change, 0, len(changes.Vectors)+len(changes.Deleted))
+	i, j := 0, 0
+	for i < len(changes.Vectors) || j < len(changes.Deleted) {
+		var c change
+		if j == len(changes.Deleted) || (i < len(changes.Vectors) && !deletedFirst(changes.Vectors[i], changes.Deleted[j])) {
+			c = change{at: changes.Vectors[i].UpdatedAt, id: changes.Vectors[i].ID, vector: changes.Vectors[i]}
+			i++
+		} else {
+			c = change{at: changes.Deleted[j].DeletedAt, id: changes.Deleted[j].ID, tombstone: &changes.Deleted[j]}
+			j++
+		}
+		if c.after(cursor) {
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
+
+// deletedFirst reports whether the deletion t comes before the update of vector
+func deletedFirst(vector *models.Vector, t tombstone.Tombstone) bool {
+	if !t.DeletedAt.Equal(vector.UpdatedAt) {
+		return t.DeletedAt.Before(vector.UpdatedAt)
+	}
+	return t.ID < vector.ID
+}
+
+// notModifiedSince reports whether no vector of the namespace changed at or after
+// the If-Modified-Since time of the request, which then gets a 304 Not Modified
+// Backends without a change log, or whose tombstones no longer cover the time, never match
+func (vh *VectorHandler) notModifiedSince(r *http.Request) bool {
+	header := r.Header.Get("If-Modified-Since")
+	if header == "" {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	cl, ok := vh.storage.(storage.ChangeLister)
+	if !ok {
+		return false
+	}
+	changes, err := cl.Changes(vh.resolveNamespace(r.URL.Query().Get("namespace")), since)
+	if err != nil || since.Before(changes.Horizon) {
+		return false
+	}
+	return len(changes.Vectors) == 0 && len(changes.Deleted) == 0
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+)
+
+func TestListVectors_Changes(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+
+	list := func(params url.Values) changesResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		vh.ListVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors?"+params.Encode(), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("delta listing %v: status = %d: %s", params, rec.Code, rec.Body.String())
+		}
+		var resp changesResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+	since := func(at time.Time) url.Values {
+		return url.Values{"updated_after": {at.Format(time.RFC3339Nano)}}
+	}
+
+	start := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	for _, id := range []string{"a", "b", "c"} {
+		store.Store(&models.Vector{ID: id, Embedding: []float64{1, 0}})
+		time.Sleep(2 * time.Millisecond)
+	}
+	store.Store(&models.Vector{ID: "a", Embedding: []float64{0, 1}})
+	time.Sleep(2 * time.Millisecond)
+	store.Delete("b")
+
+	// Pages of two walk c, the update of a and the deletion of b in order
+	params := since(start)
+	params.Set("limit", "2")
+	first := list(params)
+	if len(first.Vectors) != 2 || first.Vectors[0].ID != "c" || first.Vectors[1].ID != "a" || len(first.Deleted) != 0 || !first.HasMore {
+		t.Fatalf("first page = %+v", first)
+	}
+	params.Set("updated_after", first.Next.UpdatedAfter.Format(time.RFC3339Nano))
+	params.Set("after_id", first.Next.AfterID)
+	second := list(params)
+	if len(second.Vectors) != 0 || len(second.Deleted) != 1 || second.Deleted[0].ID != "b" || second.HasMore {
+		t.Fatalf("second page = %+v", second)
+	}
+
+	// Polling from the last change returns nothing new
+	params.Set("updated_after", second.Next.UpdatedAfter.Format(time.RFC3339Nano))
+	params.Set("after_id", second.Next.AfterID)
+	if last := list(params); len(last.Vectors) != 0 || len(last.Deleted) != 0 || last.Next != nil {
+		t.Errorf("poll after the last change = %+v", last)
+	}
+
+	// Dropped tombstones require a full resync from before them
+	store.SetTombstoneOptions(tombstoneRetentionOf(time.Nanosecond))
+	if resp := list(since(start)); !resp.ResyncRequired || resp.TombstoneHorizon == nil || len(resp.Deleted) != 0 {
+		t.Errorf("listing from before the horizon = %+v", resp)
+	}
+
+	// Relative times resolve against as_of, or the time of the request
+	if resp := list(url.Values{"updated_after": {"now - 1h"}}); len(resp.Vectors) != 2 {
+		t.Errorf("listing of the last hour = %+v, want a and c", resp)
+	}
+	asOf := start.Add(2 * time.Hour).Format(time.RFC3339Nano)
+	if resp := list(url.Values{"updated_after": {"now-1h"}, "as_of": {asOf}}); len(resp.Vectors) != 0 {
+		t.Errorf("listing from as_of = %+v, want nothing", resp)
+	}
+
+	for _, params := range []url.Values{
+		{"updated_after": {"yesterday"}},
+		{"updated_after": {"now-1h"}, "as_of": {"last week"}},
+	} {
+		rec := httptest.NewRecorder()
+		vh.ListVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors?"+params.Encode(), nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("invalid %v: status = %d, want 400", params, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	vh.ListVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors?updated_after=yesterday", nil))
+	if !strings.Contains(rec.Body.String(), `"yesterday"`) {
+		t.Errorf("invalid updated_after error = %q, want the expression named", rec.Body.String())
+	}
+}
+
+func TestListVectors_IfModifiedSince(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+	store.Store(&models.Vector{ID: "a", Embedding: []float64{1, 0}})
+
+	get := func(since time.Time) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/vectors", nil)
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+		rec := httptest.NewRecorder()
+		vh.ListVectors(rec, req)
+		return rec.Code
+	}
+
+	if code := get(time.Now().Add(time.Second)); code != http.StatusNotModified {
+		t.Errorf("unchanged listing: status = %d, want 304", code)
+	}
+	if code := get(time.Now().Add(-time.Minute)); code != http.StatusOK {
+		t.Errorf("changed listing: status = %d, want 200", code)
+	}
+}
+
+func tombstoneRetentionOf(retention time.Duration) tombstone.Options {
+	return tombstone.Options{Retention: retention}
+}
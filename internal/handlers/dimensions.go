@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// defaultOutlierLimit is the number of outliers listed per namespace unless limit is given
+const defaultOutlierLimit = 100
+
+// DimensionOutlier is a vector whose embedding differs from the dominant dimension
+type DimensionOutlier struct {
+	ID        string `json:"id"`
+	Dimension int    `json:"dimension"`
+}
+
+// NamespaceDimensions describes the dense embedding dimensions of a namespace
+type NamespaceDimensions struct {
+	Namespace  string             `json:"namespace"`
+	Dominant   int                `json:"dominant"`   // Dimension of the most vectors
+	Dimensions map[int]int        `json:"dimensions"` // Vectors per dimension
+	Outliers   []DimensionOutlier `json:"outliers"`   // At most limit, sorted by ID
+	// OutlierCount counts every outlier, including those past the limit
+	OutlierCount int `json:"outlier_count"`
+}
+
+// DimensionReport lists the embedding dimensions of every namespace
+type DimensionReport struct {
+	MaxDimension int                    `json:"max_dimension"`
+	Namespaces   []*NamespaceDimensions `json:"namespaces"`
+}
+
+// GetDimensionReport handles GET /api/v1/admin/dimensions?namespace=&limit=, listing
+// the vectors whose dimension differs from the dominant one of their namespace, such
+// as vectors stored by another embedder. Sparse vectors and vectors pending their
+// embedding are left out. The report scans every vector
+func (vh *VectorHandler) GetDimensionReport(w http.ResponseWriter, r *http.Request) {
+	limit := defaultOutlierLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	vectors, err := vh.storage.ListByNamespace(r.URL.Query().Get("namespace"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dimensionReport(vectors, limit))
+}
+
+// dimensionReport groups dense vectors by namespace and flags those off the dominant dimension
+func dimensionReport(vectors []*models.Vector, limit int) *DimensionReport {
+	byNamespace := make(map[string][]*models.Vector)
+	for _, vector := range vectors {
+		if vector.Sparse != nil || len(vector.Embedding) == 0 {
+			continue
+		}
+		ns := vector.Metadata[models.NamespaceKey]
+		byNamespace[ns] = append(byNamespace[ns], vector)
+	}
+
+	report := &DimensionReport{MaxDimension: models.MaxEmbeddingDimension(), Namespaces: []*NamespaceDimensions{}}
+	for ns, vectors := range byNamespace {
+		dims := &NamespaceDimensions{Namespace: ns, Dimensions: make(map[int]int), Outliers: []DimensionOutlier{}}
+		for _, vector := range vectors {
+			dims.Dimensions[len(vector.Embedding)]++
+		}
+		// Ties go to the smaller dimension, so the report is stable
+		for dim, count := range dims.Dimensions {
+			if best := dims.Dimensions[dims.Dominant]; count > best || count == best && dim < dims.Dominant {
+				dims.Dominant = dim
+			}
+		}
+
+		for _, vector := range vectors {
+			if len(vector.Embedding) != dims.Dominant {
+				dims.Outliers = append(dims.Outliers, DimensionOutlier{ID: vector.ID, Dimension: len(vector.Embedding)})
+			}
+		}
+		sort.Slice(dims.Outliers, func(i, j int) bool { return dims.Outliers[i].ID < dims.Outliers[j].ID })
+		dims.OutlierCount = len(dims.Outliers)
+		if len(dims.Outliers) > limit {
+			dims.Outliers = dims.Outliers[:limit]
+		}
+		report.Namespaces = append(report.Namespaces, dims)
+	}
+	sort.Slice(report.Namespaces, func(i, j int) bool { return report.Namespaces[i].Namespace < report.Namespaces[j].Namespace })
+	return report
+}
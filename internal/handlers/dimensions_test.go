@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestCreateVector_RejectsOversizedEmbedding(t *testing.T) {
+	models.SetMaxEmbeddingDimension(8)
+	t.Cleanup(func() { models.SetMaxEmbeddingDimension(0) })
+
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+	embedding := "[" + strings.TrimSuffix(strings.Repeat("0.5,", 9), ",") + "]"
+
+	rec := httptest.NewRecorder()
+	vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(`{"id":"big","embedding":`+embedding+`}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("oversized vector: status = %d, want 422: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	body := fmt.Sprintf(`{"vectors":[{"id":"ok","embedding":[1,0]},{"id":"big","embedding":%s}]}`, embedding)
+	vh.StoreVectorBatch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/batch", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("batch with an oversized vector: status = %d, want 422: %s", rec.Code, rec.Body.String())
+	}
+	if store.Count() != 0 {
+		t.Errorf("count = %d after rejected vectors, want 0", store.Count())
+	}
+}
+
+func TestGetDimensionReport(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+	for _, vector := range []*models.Vector{
+		{ID: "a", Embedding: []float64{1, 0, 0}},
+		{ID: "b", Embedding: []float64{0, 1, 0}},
+		{ID: "c", Embedding: []float64{0, 0, 1}},
+		{ID: "planted", Embedding: []float64{1, 1}},
+		{ID: "films", Embedding: []float64{1, 1}, Metadata: map[string]string{models.NamespaceKey: "films"}},
+		{ID: "sparse", Sparse: &models.SparseVector{Indices: []int{0}, Values: []float64{1}, Dimension: 100}},
+	} {
+		if err := store.Store(vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	vh.GetDimensionReport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/dimensions", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var report DimensionReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if report.MaxDimension != models.DefaultMaxEmbeddingDimension || len(report.Namespaces) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	// Each namespace has its own dominant dimension, so films is not an outlier
+	def, films := report.Namespaces[0], report.Namespaces[1]
+	if def.Dominant != 3 || def.Dimensions[3] != 3 || def.Dimensions[2] != 1 {
+		t.Errorf("default namespace = %+v", def)
+	}
+	if def.OutlierCount != 1 || len(def.Outliers) != 1 || def.Outliers[0] != (DimensionOutlier{ID: "planted", Dimension: 2}) {
+		t.Errorf("outliers = %d %+v, want the planted vector", def.OutlierCount, def.Outliers)
+	}
+	if films.Namespace != "films" || films.Dominant != 2 || films.OutlierCount != 0 {
+		t.Errorf("films namespace = %+v", films)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.GetDimensionReport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/dimensions?limit=-1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("negative limit: status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestCreateVector_Representations(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+	embedding := []float64{0.12345678901, -0.5, 0.25}
+
+	bodies := map[string]string{
+		"array":  `{"id": "array", "embedding": [0.12345678901, -0.5, 0.25]}`,
+		"base64": `{"id": "base64", "embedding_b64": "` + models.EncodeEmbeddingBase64(embedding) + `"}`,
+		"ints":   `{"id": "ints", "embedding": [12, -50, 25], "dtype": "int8"}`,
+	}
+	for name, body := range bodies {
+		rec := httptest.NewRecorder()
+		vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: status = %d: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(`{"embedding": [300], "dtype": "int8"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("out of range int8: status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.ListVectorMetadata(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/metadata", nil))
+	var listed []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to decode listing: %v", err)
+	}
+	dtypes := make(map[string]interface{})
+	for _, entry := range listed {
+		dtypes[entry["id"].(string)] = entry["dtype"]
+	}
+	want := map[string]interface{}{"array": "float64", "base64": "float32", "ints": "int8"}
+	for id, dtype := range want {
+		if dtypes[id] != dtype {
+			t.Errorf("%s dtype = %v, want %v", id, dtypes[id], dtype)
+		}
+	}
+
+	// The same vector scores identically whatever representation the query uses
+	scores := make(map[string]float64)
+	queries := map[string]string{
+		"array":  `{"embedding": [0.12345678901, -0.5, 0.25], "top_K": 1, "return_embedding": false}`,
+		"base64": `{"embedding_b64": "` + models.EncodeEmbeddingBase64(embedding) + `", "top_K": 1, "return_embedding": false}`,
+	}
+	for name, body := range queries {
+		rec := httptest.NewRecorder()
+		vh.SearchVectors(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/search", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s query: status = %d: %s", name, rec.Code, rec.Body.String())
+		}
+		var results []*models.SearchResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil || len(results) != 1 {
+			t.Fatalf("%s query: results = %s", name, rec.Body.String())
+		}
+		scores[name] = results[0].Score
+	}
+	if scores["array"] != scores["base64"] {
+		t.Errorf("scores differ by representation: %v", scores)
+	}
+}
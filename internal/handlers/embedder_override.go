@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/registry"
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// errEmbedderForbidden rejects the embedder overrides a request may not use
+var errEmbedderForbidden = errors.New("embedder override forbidden")
+
+// EmbedderOverrideMeta reports the embedder a search request selected in place of
+// the embedder of its namespace
+type EmbedderOverrideMeta struct {
+	Name      string `json:"name"` // Name of the override in the config
+	Type      string `json:"type"` // Name reported by the embedder
+	Dimension int    `json:"dimension,omitempty"`
+
+	// Incompatible counts the stored dense vectors of the searched namespace whose
+	// dimension differs from the query embedding, which can never be results
+	Incompatible int `json:"incompatible"`
+}
+
+type adminContextKey struct{}
+
+// WithAdmin marks a request authenticated with the admin key, which may select
+// an override embedder for its searches
+func WithAdmin(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), adminContextKey{}, true))
+}
+
+// isAdmin reports whether ctx is the context of a request marked by WithAdmin
+func isAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminContextKey{}).(bool)
+	return admin
+}
+
+// SetEmbedderOverrides sets the embedders admin search requests can select by name
+// with their embedder field, nil to disable overrides
+func (vh *VectorHandler) SetEmbedderOverrides(cache *registry.Cache) {
+	vh.embedderOverrides = cache
+}
+
+// overrideEmbedder returns the override embedder named by a search request, failing
+// with errEmbedderForbidden unless the request is an admin one and the name is allowed
+func (vh *VectorHandler) overrideEmbedder(ctx context.Context, name string) (embedders.Embedder, error) {
+	switch {
+	case vh.embedderOverrides == nil:
+		return nil, fmt.Errorf("%w: no override embedders are configured", errEmbedderForbidden)
+	case !isAdmin(ctx):
+		return nil, fmt.Errorf("%w: selecting an embedder requires the admin key", errEmbedderForbidden)
+	case !vh.embedderOverrides.Allowed(name):
+		return nil, fmt.Errorf("%w: embedder %q is not allowed", errEmbedderForbidden, name)
+	}
+	return vh.embedderOverrides.Get(name)
+}
+
+// queryEmbedder returns the embedder of the query text, the override of the
+// request once embed has resolved it
+func (vh *VectorHandler) queryEmbedder(q *searchQuery) embedders.Embedder {
+	if q.override != nil {
+		return q.override
+	}
+	return vh.embedderFor(q.Namespace)
+}
+
+// countIncompatible counts the stored dense vectors of namespace whose dimension
+// differs from dimension. It scans the namespace, which is acceptable for admin
+// experiments but would not be for every search
+func (vh *VectorHandler) countIncompatible(namespace string, dimension int) (int, error) {
+	vectors, err := vh.storage.ListByNamespace(namespace)
+	if err != nil {
+		return 0, err
+	}
+	incompatible := 0
+	for _, vector := range vectors {
+		if vector.Sparse == nil && len(vector.Embedding) > 0 && len(vector.Embedding) != dimension {
+			incompatible++
+		}
+	}
+	return incompatible, nil
+}
+
+// skipOverrideIncompatible reports whether vector cannot be compared with the
+// embedding of an override embedder
+func (q *searchQuery) skipOverrideIncompatible(vector *models.Vector) bool {
+	return q.overrideMeta != nil && q.overrideMeta.Dimension > 0 && vector.Sparse == nil &&
+		len(vector.Embedding) != q.overrideMeta.Dimension
+}
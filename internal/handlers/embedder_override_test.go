@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/fixture"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/embedders/registry"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestEmbedderOverride(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+	cache, err := registry.NewCache(map[string]registry.Spec{
+		"seed-1": {Type: "fixture", Settings: map[string]string{"dimension": "8", "seed": "1"}},
+		"seed-2": {Type: "fixture", Settings: map[string]string{"dimension": "8", "seed": "2"}},
+	}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	vh.SetEmbedderOverrides(cache)
+
+	// Each text is stored as embedded by each fixture, and one vector has another dimension
+	texts := []string{"refund policy", "shipping times", "opening hours"}
+	for _, seed := range []uint64{1, 2} {
+		embedder := fixture.NewFixtureEmbedder(8, seed)
+		for _, text := range texts {
+			embedding, _ := embedder.Embed(text)
+			id := text + "/" + string(rune('0'+seed))
+			if err := store.Store(&models.Vector{ID: id, Embedding: embedding, Metadata: map[string]string{"text": text}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := store.Store(&models.Vector{ID: "other", Embedding: []float64{1, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(embedder string, admin bool) (*httptest.ResponseRecorder, []string, *SearchMeta) {
+		t.Helper()
+		body, _ := json.Marshal(models.SearchByTextRequest{Text: "refund policy", TopK: 1, SearchParams: models.SearchParams{Embedder: embedder}})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/search", bytes.NewReader(body))
+		if admin {
+			req = WithAdmin(req)
+		}
+		rec := httptest.NewRecorder()
+		vh.SearchByText(rec, req)
+		if rec.Code != http.StatusOK {
+			return rec, nil, nil
+		}
+		var resp struct {
+			Matches []models.SearchResult `json:"matches"`
+			Meta    *SearchMeta           `json:"meta"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		var ids []string
+		for _, match := range resp.Matches {
+			ids = append(ids, match.Vector.ID)
+		}
+		return rec, ids, resp.Meta
+	}
+
+	// Each override finds the vector it embedded itself, and reports the vector it cannot compare
+	for name, want := range map[string]string{"seed-1": "refund policy/1", "seed-2": "refund policy/2"} {
+		_, ids, meta := search(name, true)
+		if len(ids) != 1 || ids[0] != want {
+			t.Errorf("%s matches = %v, want [%s]", name, ids, want)
+		}
+		if meta == nil || meta.Embedder == nil || *meta.Embedder != (EmbedderOverrideMeta{Name: name, Type: "fixture", Dimension: 8, Incompatible: 1}) {
+			t.Errorf("%s meta = %+v", name, meta)
+		}
+	}
+	if cached := cache.Cached(); len(cached) != 2 {
+		t.Errorf("cached embedders = %v, want both overrides", cached)
+	}
+
+	// Overrides need the admin key and an allowed name
+	if rec, _, _ := search("seed-1", false); rec.Code != http.StatusForbidden {
+		t.Errorf("override without the admin key status = %d, want 403", rec.Code)
+	}
+	if rec, _, _ := search("openai", true); rec.Code != http.StatusForbidden {
+		t.Errorf("override not in the allow-list status = %d, want 403", rec.Code)
+	}
+
+	// Without an override the default embedder is used and no embedder is reported
+	if _, _, meta := search("", false); meta != nil && meta.Embedder != nil {
+		t.Errorf("meta without override = %+v", meta)
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/tfidf"
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// SetNamespaceEmbedders sets the embedders of namespaces that do not use the default one
+// Queries and documents of those namespaces are embedded with their own embedder, and
+// searches across namespaces skip results embedded by another embedder than the query
+func (vh *VectorHandler) SetNamespaceEmbedders(byNamespace map[string]embedders.Embedder) {
+	vh.namespaceEmbedders = byNamespace
+}
+
+// embedderFor returns the embedder of namespace
+func (vh *VectorHandler) embedderFor(namespace string) embedders.Embedder {
+	if embedder, ok := vh.namespaceEmbedders[namespace]; ok {
+		return embedder
+	}
+	return vh.embedder
+}
+
+// skipIncompatible reports whether vector was embedded by another embedder than the
+// query text, counting it for the response warnings. Vectors without provenance are kept
+// With an override embedder, vectors of another dimension are skipped instead
+func (q *searchQuery) skipIncompatible(vector *models.Vector) bool {
+	if q.skipOverrideIncompatible(vector) {
+		return true
+	}
+	if q.embedderName == "" {
+		return false
+	}
+	name := vector.Metadata[models.EmbedderNameKey]
+	if name == "" || name == q.embedderName {
+		return false
+	}
+	if q.skippedEmbedders == nil {
+		q.skippedEmbedders = make(map[string]int)
+	}
+	q.skippedEmbedders[name]++
+	return true
+}
+
+// embedderWarnings describes the results skipped by skipIncompatible
+func (q *searchQuery) embedderWarnings() []string {
+	names := make([]string, 0, len(q.skippedEmbedders))
+	for name := range q.skippedEmbedders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	warnings := make([]string, 0, len(names))
+	for _, name := range names {
+		warnings = append(warnings, fmt.Sprintf("skipped %d results embedded by %s, not comparable with the %s query embedding: search their namespace instead",
+			q.skippedEmbedders[name], name, q.embedderName))
+	}
+	return warnings
+}
+
+// embedderStats reports the type of an embedder and, for local ones, their vocabulary and synonyms
+func embedderStats(embedder embedders.Embedder) map[string]interface{} {
+	stats := map[string]interface{}{"type": embedder.Name()}
+
+	if tfidfEmbedder, ok := embedder.(*tfidf.TFIDFEmbedder); ok {
+		stats["vocabulary_size"] = tfidfEmbedder.GetVocabularySize()
+		stats["document_count"] = tfidfEmbedder.GetDocumentCount()
+		stats["bootstrap"] = tfidfEmbedder.Bootstrap()
+	}
+
+	if expander, ok := embedder.(embedders.SynonymExpander); ok {
+		if set := expander.Synonyms(); set != nil {
+			stats["synonym_sets"] = set.Count()
+		} else {
+			stats["synonym_sets"] = 0
+		}
+	}
+	return stats
+}
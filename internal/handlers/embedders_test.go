@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// namedEmbedder renames an embedder, standing in for another embedder of the same dimension
+type namedEmbedder struct {
+	embedders.Embedder
+	name string
+}
+
+func (e namedEmbedder) Name() string { return e.name }
+
+func TestNamespaceEmbedders(t *testing.T) {
+	store := memory.NewStorage()
+	base := hash.NewHashEmbedder()
+	logs := namedEmbedder{Embedder: base, name: "logs.hash"}
+	vh := NewVectorHandler(store, base)
+	vh.SetNamespaceEmbedders(map[string]embedders.Embedder{"logs": logs})
+
+	embedding, _ := base.Embed("refund policy")
+	store.Store(&models.Vector{ID: "quote", Embedding: embedding, Metadata: map[string]string{models.EmbedderNameKey: base.Name()}})
+	store.Store(&models.Vector{ID: "log", Embedding: embedding, Metadata: map[string]string{models.EmbedderNameKey: logs.Name(), models.NamespaceKey: "logs"}})
+
+	// Documents of a namespace are embedded, and their provenance recorded, with its embedder
+	rec := httptest.NewRecorder()
+	vh.EmbedVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/embed", bytes.NewBufferString(`{"text": "disk full", "author": "cron", "namespace": "logs"}`)))
+	var embedded models.Vector
+	if err := json.NewDecoder(rec.Body).Decode(&embedded); err != nil || embedded.Metadata[models.EmbedderNameKey] != "logs.hash" {
+		t.Fatalf("embedded vector = %+v, %v", embedded, err)
+	}
+	store.Delete(embedded.ID)
+
+	search := func(namespace string) (ids []string, warnings []string) {
+		t.Helper()
+		body, _ := json.Marshal(models.SearchByTextRequest{Text: "refund policy", Namespace: namespace})
+		rec := httptest.NewRecorder()
+		vh.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", bytes.NewReader(body)))
+		var resp struct {
+			Matches []models.SearchResult `json:"matches"`
+			Meta    *SearchMeta           `json:"meta"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		for _, match := range resp.Matches {
+			ids = append(ids, match.Vector.ID)
+		}
+		if resp.Meta != nil {
+			warnings = resp.Meta.Warnings
+		}
+		return ids, warnings
+	}
+
+	// Across namespaces, results of another embedder are skipped with a warning
+	ids, warnings := search("")
+	if len(ids) != 1 || ids[0] != "quote" {
+		t.Errorf("cross-namespace matches = %v, want [quote]", ids)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "logs.hash") {
+		t.Errorf("cross-namespace warnings = %v", warnings)
+	}
+
+	ids, warnings = search("logs")
+	if len(ids) != 1 || ids[0] != "log" || len(warnings) != 0 {
+		t.Errorf("logs matches = %v, warnings = %v, want [log] without warnings", ids, warnings)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.GetEmbedderStats(rec, httptest.NewRequest(http.MethodGet, "/api/v1/embedder/stats", nil))
+	var stats struct {
+		Type       string                            `json:"type"`
+		Namespaces map[string]map[string]interface{} `json:"namespaces"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if stats.Type != base.Name() || stats.Namespaces["logs"]["type"] != "logs.hash" {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/enrichment"
+)
+
+// errEnrichmentUnsupported is returned when the storage backend keeps no enrichment documents
+var errEnrichmentUnsupported = errors.New("storage backend does not support enrichment documents")
+
+// maxEnrichmentBody bounds the body of a bulk upload, a full batch of the largest documents
+const maxEnrichmentBody = enrichment.MaxBatch * (enrichment.MaxSize + enrichment.MaxKeyLength)
+
+// EnrichmentUpload is the body of POST /api/v1/enrichment
+type EnrichmentUpload struct {
+	Entries map[string]json.RawMessage `json:"entries"`
+}
+
+// GetEnrichment handles GET /api/v1/enrichment/{key}
+func (vh *VectorHandler) GetEnrichment(w http.ResponseWriter, r *http.Request) {
+	es, ok := vh.storage.(storage.EnrichmentStore)
+	if !ok {
+		http.Error(w, errEnrichmentUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	key := mux.Vars(r)["key"]
+	docs, err := es.GetEnrichment([]string{key})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	doc, ok := docs[key]
+	if !ok {
+		writeStoreError(w, enrichment.NotFound(key))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
+
+// SetEnrichment handles PUT /api/v1/enrichment/{key}, storing the body as the
+// enrichment document of key
+func (vh *VectorHandler) SetEnrichment(w http.ResponseWriter, r *http.Request) {
+	es, ok := vh.storage.(storage.EnrichmentStore)
+	if !ok {
+		http.Error(w, errEnrichmentUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	// One byte over the limit is enough to reject the document
+	doc, err := io.ReadAll(io.LimitReader(r.Body, enrichment.MaxSize+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	key := mux.Vars(r)["key"]
+	if err := enrichment.Validate(key, doc); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := es.SetEnrichment(map[string]json.RawMessage{key: doc}); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UploadEnrichment handles POST /api/v1/enrichment, storing up to
+// enrichment.MaxBatch documents at once
+func (vh *VectorHandler) UploadEnrichment(w http.ResponseWriter, r *http.Request) {
+	es, ok := vh.storage.(storage.EnrichmentStore)
+	if !ok {
+		http.Error(w, errEnrichmentUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	var upload EnrichmentUpload
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxEnrichmentBody)).Decode(&upload); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := enrichment.ValidateBatch(upload.Entries); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := es.SetEnrichment(upload.Entries); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"stored": len(upload.Entries)})
+}
+
+// DeleteEnrichment handles DELETE /api/v1/enrichment/{key}
+func (vh *VectorHandler) DeleteEnrichment(w http.ResponseWriter, r *http.Request) {
+	es, ok := vh.storage.(storage.EnrichmentStore)
+	if !ok {
+		http.Error(w, errEnrichmentUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	if err := es.DeleteEnrichment(mux.Vars(r)["key"]); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// enrich returns the enrichment document of each vector, keyed by its enrich_by
+// metadata value, nil for vectors without the field or without a document
+// Documents are looked up in a single call whatever the number of results
+func (vh *VectorHandler) enrich(q *searchQuery, vectors []*models.Vector) ([]json.RawMessage, error) {
+	if q.EnrichBy == "" || len(vectors) == 0 {
+		return nil, nil
+	}
+	es, ok := vh.storage.(storage.EnrichmentStore)
+	if !ok {
+		return nil, errEnrichmentUnsupported
+	}
+
+	keys := make([]string, 0, len(vectors))
+	seen := make(map[string]bool, len(vectors))
+	for _, vector := range vectors {
+		if key := vector.Metadata[q.EnrichBy]; key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return make([]json.RawMessage, len(vectors)), nil
+	}
+
+	docs, err := es.GetEnrichment(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read enrichment documents: %w", err)
+	}
+	enriched := make([]json.RawMessage, len(vectors))
+	for i, vector := range vectors {
+		enriched[i] = docs[vector.Metadata[q.EnrichBy]]
+	}
+	return enriched, nil
+}
+
+// cascadeEnrichment deletes the enrichment document keyed by the field value of
+// a deleted vector, if any
+func (vh *VectorHandler) cascadeEnrichment(vector *models.Vector, field string) error {
+	if field == "" || vector == nil || vector.Metadata[field] == "" {
+		return nil
+	}
+	return storage.DeleteEnrichment(vh.storage, vector.Metadata[field])
+}
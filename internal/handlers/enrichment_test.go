@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/enrichment"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestEnrichment(t *testing.T) {
+	adapter, err := local.NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]storage.Storage{"memory": memory.NewStorage(), "local": adapter} {
+		t.Run(name, func(t *testing.T) {
+			testEnrichment(t, store)
+		})
+	}
+}
+
+func testEnrichment(t *testing.T, store storage.Storage) {
+	vh := NewVectorHandler(store, fixedEmbedder{1, 0})
+	for _, vector := range []*models.Vector{
+		{ID: "a", Embedding: []float64{1, 0}, Metadata: map[string]string{"product_id": "p1", "text": "a"}},
+		{ID: "b", Embedding: []float64{0.9, 0.1}, Metadata: map[string]string{"product_id": "p2", "text": "b"}},
+		{ID: "c", Embedding: []float64{0.5, 0.5}, Metadata: map[string]string{"text": "c"}},
+	} {
+		if err := store.Store(vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	withKey := func(r *http.Request, key string) *http.Request {
+		return mux.SetURLVars(r, map[string]string{"key": key})
+	}
+
+	rec := httptest.NewRecorder()
+	vh.SetEnrichment(rec, withKey(httptest.NewRequest(http.MethodPut, "/api/v1/enrichment/p1", strings.NewReader(`{"title": "Lamp", "price": 12.5}`)), "p1"))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("put: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	vh.SetEnrichment(rec, withKey(httptest.NewRequest(http.MethodPut, "/api/v1/enrichment/p9", strings.NewReader(`{"title": `)), "p9"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid JSON: status = %d, want 400", rec.Code)
+	}
+	large := `"` + strings.Repeat("x", enrichment.MaxSize) + `"`
+	rec = httptest.NewRecorder()
+	vh.SetEnrichment(rec, withKey(httptest.NewRequest(http.MethodPut, "/api/v1/enrichment/p9", strings.NewReader(large)), "p9"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("oversized document: status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.UploadEnrichment(rec, httptest.NewRequest(http.MethodPost, "/api/v1/enrichment", strings.NewReader(`{"entries": {"p2": {"title": "Desk"}, "p3": ["unused"]}}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	vh.GetEnrichment(rec, withKey(httptest.NewRequest(http.MethodGet, "/api/v1/enrichment/p2", nil), "p2"))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"title": "Desk"}` {
+		t.Errorf("get: status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// The join field need not be among the returned metadata fields
+	rec = httptest.NewRecorder()
+	vh.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", bytes.NewBufferString(`{"text": "q", "enrich_by": "product_id", "metadata_fields": ["text"]}`)))
+	var resp struct {
+		Matches []struct {
+			Vector     models.Vector   `json:"vector"`
+			Enrichment json.RawMessage `json:"enrichment"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Matches) != 3 {
+		t.Fatalf("search: status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	want := map[string]string{"a": `{"title":"Lamp","price":12.5}`, "b": `{"title":"Desk"}`, "c": ``}
+	for _, match := range resp.Matches {
+		var compact bytes.Buffer
+		if len(match.Enrichment) > 0 {
+			json.Compact(&compact, match.Enrichment)
+		}
+		if compact.String() != want[match.Vector.ID] {
+			t.Errorf("enrichment of %s = %s, want %s", match.Vector.ID, compact.String(), want[match.Vector.ID])
+		}
+	}
+
+	// Deleting a vector deletes its enrichment document only when asked to
+	rec = httptest.NewRecorder()
+	vh.DeleteVector(rec, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/v1/vectors/b", nil), map[string]string{"id": "b"}))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: status = %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	vh.DeleteVector(rec, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/v1/vectors/a?cascade_enrichment=product_id", nil), map[string]string{"id": "a"}))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("cascading delete: status = %d", rec.Code)
+	}
+	docs, err := store.(storage.EnrichmentStore).GetEnrichment([]string{"p1", "p2"})
+	if _, ok := docs["p1"]; err != nil || ok || len(docs) != 1 {
+		t.Errorf("enrichment after deletes = %v, %v, want only p2", docs, err)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.DeleteEnrichment(rec, withKey(httptest.NewRequest(http.MethodDelete, "/api/v1/enrichment/p1", nil), "p1"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("delete unknown key: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/uniquekey"
+)
+
+// Error codes of storeErrorResponse, one per storage error kind
+const (
+	codeNotFound          = "not_found"
+	codeAlreadyExists     = "already_exists"
+	codeDimensionMismatch = "dimension_mismatch"
+	codeValidation        = "invalid"
+	codeQuotaExceeded     = "quota_exceeded"
+	codeInternal          = "internal"
+)
+
+// storeErrorResponse is the body of a failed storage operation
+// Conflict holds the vector holding a unique key, Quota the exceeded namespace
+// limit, Memory the exceeded memory limit and Locked the locked default metadata
+// key a write set to another value
+type storeErrorResponse struct {
+	Error    string           `json:"error"`
+	Code     string           `json:"code"`
+	Conflict *uniquekey.Error `json:"conflict,omitempty"`
+	Quota    *quota.Error     `json:"quota,omitempty"`
+	Memory   *memlimit.Error  `json:"memory,omitempty"`
+	Locked   *defaults.Error  `json:"locked,omitempty"`
+}
+
+// storeErrorStatus maps a storage error to its HTTP status and error code
+// Errors of no kind are backend failures, such as a full disk, and answer 500
+func storeErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound, codeNotFound
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return http.StatusConflict, codeAlreadyExists
+	case errors.Is(err, storage.ErrDimensionMismatch):
+		return http.StatusUnprocessableEntity, codeDimensionMismatch
+	case errors.Is(err, storage.ErrValidation):
+		return http.StatusUnprocessableEntity, codeValidation
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage, codeQuotaExceeded
+	default:
+		return http.StatusInternalServerError, codeInternal
+	}
+}
+
+// validationStatus is the status of a vector failing validation: 422 for an
+// embedding over the maximum dimension, 400 for any other malformed vector
+func validationStatus(err error) int {
+	if errors.Is(err, models.ErrDimensionTooLarge) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
+
+// writeStoreError reports a failed storage operation with the status of its
+// error kind, adding the vector holding the key to unique key conflicts and
+// the exceeded limit to quota and memory limit rejections
+func writeStoreError(w http.ResponseWriter, err error) {
+	status, code := storeErrorStatus(err)
+	resp := storeErrorResponse{Error: err.Error(), Code: code}
+	errors.As(err, &resp.Conflict)
+	errors.As(err, &resp.Quota)
+	errors.As(err, &resp.Memory)
+	errors.As(err, &resp.Locked)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// failingStorage fails every read and write with an error of no kind, as a full disk would
+type failingStorage struct {
+	storage.Storage
+}
+
+var errDiskFull = errors.New("write vectors.json: no space left on device")
+
+func (failingStorage) Store(*models.Vector) error         { return errDiskFull }
+func (failingStorage) Get(string) (*models.Vector, error) { return nil, errDiskFull }
+func (failingStorage) Delete(string) error                { return errDiskFull }
+
+func TestStoreErrorStatus(t *testing.T) {
+	store := memory.NewStorage()
+	store.Store(&models.Vector{ID: "a", Embedding: []float64{1, 0}, Metadata: map[string]string{"sku": "1"}})
+	store.SetUniqueKeys([]string{"sku"})
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+	failing := NewVectorHandler(failingStorage{store}, hash.NewHashEmbedder())
+
+	withID := func(method, id, body string) *http.Request {
+		return mux.SetURLVars(httptest.NewRequest(method, "/api/v1/vectors/"+id, bytes.NewBufferString(body)), map[string]string{"id": id})
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     *http.Request
+		status  int
+		code    string
+	}{
+		{"delete missing", vh.DeleteVector, withID(http.MethodDelete, "missing", ""), http.StatusNotFound, codeNotFound},
+		{"provenance of missing", vh.GetProvenance, withID(http.MethodGet, "missing", ""), http.StatusNotFound, codeNotFound},
+		{"unique key conflict", vh.UpdateVector, withID(http.MethodPut, "b", `{"embedding":[0,1],"metadata":{"sku":"1"}}`), http.StatusConflict, codeAlreadyExists},
+		{"failing get", failing.GetVector, withID(http.MethodGet, "a", ""), http.StatusInternalServerError, codeInternal},
+		{"failing delete", failing.DeleteVector, withID(http.MethodDelete, "a", ""), http.StatusInternalServerError, codeInternal},
+		{"failing store", failing.UpdateVector, withID(http.MethodPut, "a", `{"embedding":[1,0]}`), http.StatusInternalServerError, codeInternal},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.handler(rec, tt.req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body.String())
+			continue
+		}
+
+		var resp storeErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Errorf("%s: decode: %v", tt.name, err)
+			continue
+		}
+		if resp.Code != tt.code || resp.Error == "" {
+			t.Errorf("%s: response = %+v, want code %s", tt.name, resp, tt.code)
+		}
+		if tt.code == codeAlreadyExists && resp.Conflict == nil {
+			t.Errorf("%s: conflict not reported", tt.name)
+		}
+	}
+
+	// A missing vector keeps the lookup 404 with its route hints
+	rec := httptest.NewRecorder()
+	vh.GetVector(rec, withID(http.MethodGet, "serach", ""))
+	var notFound notFoundResponse
+	if rec.Code != http.StatusNotFound || json.NewDecoder(rec.Body).Decode(&notFound) != nil || notFound.Hint == "" {
+		t.Errorf("lookalike lookup: status = %d, response = %+v", rec.Code, notFound)
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+)
+
+// evalStore returns the evaluation store of the backend, writing a 501 when it has none
+func (vh *VectorHandler) evalStore(w http.ResponseWriter) (storage.EvalStore, bool) {
+	es, ok := vh.storage.(storage.EvalStore)
+	if !ok {
+		http.Error(w, "storage backend does not support evaluation sets", http.StatusNotImplemented)
+	}
+	return es, ok
+}
+
+// CreateEvalSet handles POST /api/v1/eval/sets
+func (vh *VectorHandler) CreateEvalSet(w http.ResponseWriter, r *http.Request) {
+	es, ok := vh.evalStore(w)
+	if !ok {
+		return
+	}
+
+	var set eval.Set
+	if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := es.CreateEvalSet(set); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(set)
+}
+
+// ListEvalSets handles GET /api/v1/eval/sets, listing the evaluation sets by name
+func (vh *VectorHandler) ListEvalSets(w http.ResponseWriter, r *http.Request) {
+	es, ok := vh.evalStore(w)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(es.EvalSets().Sorted())
+}
+
+// GetEvalSet handles GET /api/v1/eval/sets/{name}
+func (vh *VectorHandler) GetEvalSet(w http.ResponseWriter, r *http.Request) {
+	es, ok := vh.evalStore(w)
+	if !ok {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	set, ok := es.EvalSets()[name]
+	if !ok {
+		http.Error(w, eval.NotFound(name).Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(set)
+}
+
+// DeleteEvalSet handles DELETE /api/v1/eval/sets/{name}, removing the set and its runs
+func (vh *VectorHandler) DeleteEvalSet(w http.ResponseWriter, r *http.Request) {
+	es, ok := vh.evalStore(w)
+	if !ok {
+		return
+	}
+
+	if err := es.DeleteEvalSet(mux.Vars(r)["name"]); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunEvalSet handles POST /api/v1/eval/sets/{name}/run, scoring the set and recording the run
+func (vh *VectorHandler) RunEvalSet(w http.ResponseWriter, r *http.Request) {
+	if _, ok := vh.evalStore(w); !ok {
+		return
+	}
+
+	run, err := vh.RunEval(mux.Vars(r)["name"], eval.TriggerAPI)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// ListEvalRuns handles GET /api/v1/eval/sets/{name}/runs, newest first
+func (vh *VectorHandler) ListEvalRuns(w http.ResponseWriter, r *http.Request) {
+	es, ok := vh.evalStore(w)
+	if !ok {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if _, ok := es.EvalSets()[name]; !ok {
+		http.Error(w, eval.NotFound(name).Error(), http.StatusNotFound)
+		return
+	}
+	runs, err := es.ListEvalRuns(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// RunEval scores the named evaluation set through the search pipeline and records the run
+// Each query runs as a text search with the namespace and ranking profile of the set
+func (vh *VectorHandler) RunEval(name, trigger string) (*eval.Run, error) {
+	es, ok := vh.storage.(storage.EvalStore)
+	if !ok {
+		return nil, fmt.Errorf("storage backend does not support evaluation sets")
+	}
+	set, ok := es.EvalSets()[name]
+	if !ok {
+		return nil, eval.NotFound(name)
+	}
+
+	run := &eval.Run{
+		ID:        uuid.New(),
+		Set:       set.Name,
+		Trigger:   trigger,
+		StartTime: time.Now(),
+		K:         set.Cutoff(),
+		Queries:   make([]eval.QueryResult, 0, len(set.Queries)),
+	}
+	for _, q := range set.Queries {
+		retrieved, err := vh.evalQuery(&set, q.Query)
+		if err != nil {
+			return nil, fmt.Errorf("query %q: %w", q.Query, err)
+		}
+		run.Queries = append(run.Queries, eval.QueryResult{
+			Query:     q.Query,
+			Retrieved: retrieved,
+			Metrics:   eval.Score(retrieved, q.Relevant, run.K),
+		})
+	}
+	run.Metrics = eval.Mean(run.Queries)
+	run.EndTime = time.Now()
+
+	if err := es.RecordEvalRun(run); err != nil {
+		return nil, fmt.Errorf("failed to record run: %w", err)
+	}
+	return run, nil
+}
+
+// evalQuery returns the IDs of the vectors the search pipeline ranks for text, best first
+func (vh *VectorHandler) evalQuery(set *eval.Set, text string) ([]string, error) {
+	returnEmbedding := false
+	req := &models.SearchByTextRequest{
+		Text:      text,
+		TopK:      set.Cutoff(),
+		Namespace: set.Namespace,
+		SearchParams: models.SearchParams{
+			Profile:         set.Profile,
+			ReturnEmbedding: &returnEmbedding,
+			MetadataFields:  []string{},
+		},
+	}
+	if err := vh.applyProfile(req); err != nil {
+		return nil, err
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	query, err := textQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := vh.search(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Vector.ID
+	}
+	return ids, nil
+}
+
+// RunDueEvals runs the scheduled evaluation sets whose interval elapsed since their latest run
+// Failures are reported per set and do not stop the others
+func (vh *VectorHandler) RunDueEvals(now time.Time) ([]*eval.Run, []error) {
+	es, ok := vh.storage.(storage.EvalStore)
+	if !ok {
+		return nil, nil
+	}
+
+	var runs []*eval.Run
+	var errs []error
+	for _, set := range es.EvalSets().Sorted() {
+		past, err := es.ListEvalRuns(set.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("evaluation set %s: %w", set.Name, err))
+			continue
+		}
+		var latest *eval.Run
+		if len(past) > 0 {
+			latest = past[0]
+		}
+		if !set.Due(latest, now) {
+			continue
+		}
+
+		run, err := vh.RunEval(set.Name, eval.TriggerSchedule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("evaluation set %s: %w", set.Name, err))
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, errs
+}
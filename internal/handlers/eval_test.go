@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func newEvalTestHandler(t *testing.T) *VectorHandler {
+	t.Helper()
+
+	store := memory.NewStorage()
+	for _, vector := range []*models.Vector{
+		{ID: "near", Embedding: []float64{1, 0}, Metadata: map[string]string{"type": "faq"}},
+		{ID: "mid", Embedding: []float64{0.7, 0.7}, Metadata: map[string]string{"type": "blog"}},
+		{ID: "far", Embedding: []float64{0, 1}, Metadata: map[string]string{"type": "faq"}},
+	} {
+		if err := store.Store(vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewVectorHandler(store, fixedEmbedder{1, 0})
+}
+
+func TestEvalSets_CRUD(t *testing.T) {
+	vh := newEvalTestHandler(t)
+
+	create := func(body string) int {
+		rec := httptest.NewRecorder()
+		vh.CreateEvalSet(rec, httptest.NewRequest(http.MethodPost, "/api/v1/eval/sets", bytes.NewBufferString(body)))
+		return rec.Code
+	}
+	set := `{"name": "faq", "k": 2, "queries": [{"query": "refund", "relevant": ["near"]}]}`
+	if code := create(set); code != http.StatusCreated {
+		t.Fatalf("create: status = %d", code)
+	}
+	if code := create(set); code != http.StatusConflict {
+		t.Errorf("create twice: status = %d, want 409", code)
+	}
+	if code := create(`{"name": "empty", "queries": []}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("create invalid: status = %d, want 422", code)
+	}
+
+	rec := httptest.NewRecorder()
+	vh.ListEvalSets(rec, httptest.NewRequest(http.MethodGet, "/api/v1/eval/sets", nil))
+	var sets []eval.Set
+	if err := json.Unmarshal(rec.Body.Bytes(), &sets); err != nil {
+		t.Fatalf("failed to decode sets: %v", err)
+	}
+	if len(sets) != 1 || sets[0].Name != "faq" || sets[0].K != 2 {
+		t.Errorf("sets = %+v", sets)
+	}
+
+	named := func(method, path, name string, handler http.HandlerFunc) int {
+		rec := httptest.NewRecorder()
+		handler(rec, mux.SetURLVars(httptest.NewRequest(method, path, nil), map[string]string{"name": name}))
+		return rec.Code
+	}
+	if code := named(http.MethodGet, "/api/v1/eval/sets/faq", "faq", vh.GetEvalSet); code != http.StatusOK {
+		t.Errorf("get: status = %d", code)
+	}
+	if code := named(http.MethodDelete, "/api/v1/eval/sets/faq", "faq", vh.DeleteEvalSet); code != http.StatusNoContent {
+		t.Errorf("delete: status = %d", code)
+	}
+	for _, tc := range []struct {
+		method, path string
+		handler      http.HandlerFunc
+	}{
+		{http.MethodGet, "/api/v1/eval/sets/faq", vh.GetEvalSet},
+		{http.MethodDelete, "/api/v1/eval/sets/faq", vh.DeleteEvalSet},
+		{http.MethodPost, "/api/v1/eval/sets/faq/run", vh.RunEvalSet},
+		{http.MethodGet, "/api/v1/eval/sets/faq/runs", vh.ListEvalRuns},
+	} {
+		if code := named(tc.method, tc.path, "faq", tc.handler); code != http.StatusNotFound {
+			t.Errorf("%s %s after delete: status = %d, want 404", tc.method, tc.path, code)
+		}
+	}
+}
+
+func TestEvalSets_RunScoresLiveSearch(t *testing.T) {
+	vh := newEvalTestHandler(t)
+	es := vh.storage.(*memory.Storage)
+
+	if err := es.CreateEvalSet(eval.Set{
+		Name: "faq",
+		K:    2,
+		Queries: []eval.Query{
+			{Query: "refund", Relevant: []string{"near"}},
+			{Query: "shipping", Relevant: []string{"far"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	runSet := func() *eval.Run {
+		rec := httptest.NewRecorder()
+		vh.RunEvalSet(rec, mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/v1/eval/sets/faq/run", nil), map[string]string{"name": "faq"}))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("run: status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var run eval.Run
+		if err := json.Unmarshal(rec.Body.Bytes(), &run); err != nil {
+			t.Fatalf("failed to decode run: %v", err)
+		}
+		return &run
+	}
+
+	// Every query ranks near, mid, far: the first query hits at rank 1, the second misses
+	run := runSet()
+	if run.Trigger != eval.TriggerAPI || run.K != 2 || len(run.Queries) != 2 {
+		t.Fatalf("run = %+v", run)
+	}
+	if got := run.Queries[0].Retrieved; len(got) != 2 || got[0] != "near" || got[1] != "mid" {
+		t.Errorf("retrieved = %v, want [near mid]", got)
+	}
+	if run.Metrics.Recall != 0.5 || run.Metrics.MRR != 0.5 || run.Metrics.Precision != 0.25 {
+		t.Errorf("metrics = %+v, want recall 0.5, mrr 0.5, precision 0.25", run.Metrics)
+	}
+
+	// A data change shows up in the next run
+	if err := vh.storage.Store(&models.Vector{ID: "far", Embedding: []float64{1, 0.1}}); err != nil {
+		t.Fatal(err)
+	}
+	if second := runSet(); second.Metrics.Recall != 1 {
+		t.Errorf("recall after the change = %v, want 1", second.Metrics.Recall)
+	}
+
+	rec := httptest.NewRecorder()
+	vh.ListEvalRuns(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/eval/sets/faq/runs", nil), map[string]string{"name": "faq"}))
+	var runs []eval.Run
+	if err := json.Unmarshal(rec.Body.Bytes(), &runs); err != nil {
+		t.Fatalf("failed to decode runs: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Metrics.Recall != 1 || runs[1].ID != run.ID {
+		t.Errorf("runs = %+v, want the 2 runs newest first", runs)
+	}
+}
+
+func TestRunDueEvals(t *testing.T) {
+	vh := newEvalTestHandler(t)
+	es := vh.storage.(*memory.Storage)
+
+	queries := []eval.Query{{Query: "refund", Relevant: []string{"near"}}}
+	es.CreateEvalSet(eval.Set{Name: "nightly", ScheduleHours: 24, Queries: queries})
+	es.CreateEvalSet(eval.Set{Name: "manual", Queries: queries})
+
+	now := time.Now()
+	runs, errs := vh.RunDueEvals(now)
+	if len(errs) != 0 || len(runs) != 1 || runs[0].Set != "nightly" || runs[0].Trigger != eval.TriggerSchedule {
+		t.Fatalf("first check: runs = %+v, errs = %v", runs, errs)
+	}
+	if runs, _ := vh.RunDueEvals(now.Add(time.Hour)); len(runs) != 0 {
+		t.Errorf("set ran again before its interval elapsed: %+v", runs)
+	}
+	if runs, _ := vh.RunDueEvals(now.Add(25 * time.Hour)); len(runs) != 1 {
+		t.Errorf("set did not run once its interval elapsed: %+v", runs)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// Export formats of the search endpoints, chosen with ?format= or the Accept header
+const (
+	exportCSV   = "csv"
+	exportJSONL = "jsonl"
+)
+
+// exportContentTypes are the response content types of the export formats
+var exportContentTypes = map[string]string{
+	exportCSV:   "text/csv; charset=utf-8",
+	exportJSONL: "application/x-ndjson",
+}
+
+// exportFlushEvery is how many rows are written between flushes, so clients
+// receive large exports as they are written
+const exportFlushEvery = 100
+
+// exportFormat returns the export format a search request asks for with the
+// format query parameter or else its Accept header, empty for the JSON response
+func exportFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case exportCSV, exportJSONL:
+		return format, nil
+	case "json":
+		return "", nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid format %q (must be: json, csv, jsonl)", format)
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return "", nil
+		case "text/csv":
+			return exportCSV, nil
+		case "application/x-ndjson", "application/jsonl":
+			return exportJSONL, nil
+		}
+	}
+	return "", nil
+}
+
+// exportRow is a search result to export: its vector and score for CSV, and the
+// result object of the endpoint for JSONL
+type exportRow struct {
+	vector *models.Vector
+	score  float64
+	format *models.ResponseFormat
+	item   interface{}
+}
+
+// searchExportRows returns the export rows of similarity search results
+func searchExportRows(results []*models.SearchResult) []exportRow {
+	rows := make([]exportRow, len(results))
+	for i, result := range results {
+		rows[i] = exportRow{vector: result.Vector, score: result.Score, format: result.Format, item: result}
+	}
+	return rows
+}
+
+// writeExport streams search results as CSV or JSONL, one row at a time
+// CSV has the columns id, score and the metadata_fields of the query, or when it
+// lists none every metadata key of the results in alphabetical order
+func writeExport(w http.ResponseWriter, format string, q *searchQuery, rows []exportRow) {
+	filename := fmt.Sprintf("search-results-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	flusher, _ := w.(http.Flusher)
+	flush := func(written int) {
+		if flusher != nil && written%exportFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+
+	var err error
+	switch format {
+	case exportCSV:
+		err = writeCSVExport(w, exportColumns(q, rows), rows, flush)
+	case exportJSONL:
+		encoder := json.NewEncoder(w)
+		for i, row := range rows {
+			if err = encoder.Encode(row.item); err != nil {
+				break
+			}
+			flush(i + 1)
+		}
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("format", format).Error("failed to export search results")
+	}
+}
+
+// exportColumns returns the metadata columns of a CSV export
+func exportColumns(q *searchQuery, rows []exportRow) []string {
+	if len(q.MetadataFields) > 0 {
+		return q.MetadataFields
+	}
+
+	seen := make(map[string]bool)
+	columns := []string{}
+	for _, row := range rows {
+		if row.vector == nil {
+			continue
+		}
+		for key := range row.vector.Metadata {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// writeCSVExport writes a header and a record per row, quoting values holding
+// commas, quotes or line breaks
+func writeCSVExport(w http.ResponseWriter, columns []string, rows []exportRow, flush func(written int)) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(append([]string{"id", "score"}, columns...)); err != nil {
+		return err
+	}
+
+	record := make([]string, 2+len(columns))
+	for i, row := range rows {
+		score := row.score
+		if row.format != nil {
+			score = row.format.Score(score)
+		}
+		record[0], record[1] = "", strconv.FormatFloat(score, 'f', -1, 64)
+		for j := range columns {
+			record[2+j] = ""
+		}
+		if row.vector != nil {
+			record[0] = row.vector.ID
+			for j, column := range columns {
+				record[2+j] = row.vector.Metadata[column]
+			}
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+
+		if (i+1)%exportFlushEvery == 0 {
+			out.Flush()
+			flush(i + 1)
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// gnarlyTexts are metadata values that need quoting in CSV
+var gnarlyTexts = map[string]string{
+	"commas":   "red, green, and blue",
+	"quotes":   `she said "hello", then left`,
+	"newlines": "first line\nsecond line\r\nthird, \"quoted\" line",
+}
+
+func newExportTestHandler(t *testing.T) *VectorHandler {
+	t.Helper()
+	store := memory.NewStorage()
+	embedder := hash.NewHashEmbedder()
+	for id, text := range gnarlyTexts {
+		embedding, _ := embedder.Embed(text)
+		if err := store.Store(&models.Vector{ID: id, Embedding: embedding, Metadata: map[string]string{"text": text, "kind": "gnarly"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewVectorHandler(store, embedder)
+}
+
+func TestSearch_ExportCSV(t *testing.T) {
+	for _, endpoint := range searchEndpoints {
+		t.Run(endpoint.name, func(t *testing.T) {
+			vh := newExportTestHandler(t)
+			body := endpoint.query(vh, "line")
+			body["top_k"] = 500
+			body["metadata_fields"] = []string{"text"}
+			payload, _ := json.Marshal(body)
+
+			rec := httptest.NewRecorder()
+			endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/?format=csv", bytes.NewReader(payload)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="search-results-`) || !strings.HasSuffix(cd, `.csv"`) {
+				t.Errorf("Content-Disposition = %q", cd)
+			}
+
+			records, err := csv.NewReader(rec.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("export is not valid CSV: %v", err)
+			}
+			if strings.Join(records[0], ",") != "id,score,text" {
+				t.Errorf("header = %v", records[0])
+			}
+			if len(records) != 1+len(gnarlyTexts) {
+				t.Fatalf("%d rows, want %d", len(records)-1, len(gnarlyTexts))
+			}
+			for _, record := range records[1:] {
+				// The CSV reader turns \r\n inside quoted fields into \n
+				want := strings.ReplaceAll(gnarlyTexts[record[0]], "\r\n", "\n")
+				if record[2] != want {
+					t.Errorf("%s: text = %q, want %q", record[0], record[2], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearch_ExportJSONL(t *testing.T) {
+	vh := newExportTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/search", strings.NewReader(`{"query": "line", "top_k": 500}`))
+	req.Header.Set("Accept", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	vh.AdvancedSearch(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		lines++
+		var result AdvancedSearchResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("line %d: %v", lines, err)
+		}
+		if result.Text != gnarlyTexts[result.ID] {
+			t.Errorf("%s: text = %q", result.ID, result.Text)
+		}
+	}
+	if lines != len(gnarlyTexts) {
+		t.Errorf("%d lines, want %d", lines, len(gnarlyTexts))
+	}
+}
+
+func TestSearch_ExportFormatNegotiation(t *testing.T) {
+	vh := newExportTestHandler(t)
+	search := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"text": "line"}`))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		vh.SearchByText(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		target, accept string
+		status         int
+		contentType    string
+	}{
+		{"/", "", http.StatusOK, "application/json"},
+		{"/", "text/csv", http.StatusOK, "text/csv; charset=utf-8"},
+		{"/", "application/json, text/csv", http.StatusOK, "application/json"},
+		{"/?format=jsonl", "text/csv", http.StatusOK, "application/x-ndjson"},
+		{"/?format=json", "text/csv", http.StatusOK, "application/json"},
+		{"/?format=xlsx", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rec := search(tt.target, tt.accept)
+		if rec.Code != tt.status {
+			t.Errorf("%s with Accept %q: status = %d, want %d", tt.target, tt.accept, rec.Code, tt.status)
+			continue
+		}
+		if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s with Accept %q: Content-Type = %q, want %q", tt.target, tt.accept, rec.Header().Get("Content-Type"), tt.contentType)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// GenerationHeader carries the store mutation generation on search and list responses
+const GenerationHeader = "X-Store-Generation"
+
+// GetGeneration handles GET /api/v1/vectors/generation
+// The generation increases on every mutation, so clients can cheaply check whether cached results are stale
+func (vh *VectorHandler) GetGeneration(w http.ResponseWriter, r *http.Request) {
+	generation, ok := storage.Generation(vh.storage)
+	if !ok {
+		http.Error(w, "storage backend does not track mutations", http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set(GenerationHeader, strconv.FormatUint(generation, 10))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]uint64{
+		"generation": generation,
+	})
+}
+
+// setGeneration adds the store generation header when the backend tracks it
+// It is read before the store is queried, so a mutation racing the request
+// leaves the client with an older generation and a conservative cache
+func (vh *VectorHandler) setGeneration(w http.ResponseWriter) {
+	if generation, ok := storage.Generation(vh.storage); ok {
+		w.Header().Set(GenerationHeader, strconv.FormatUint(generation, 10))
+	}
+}
+
+// writeCacheableJSON encodes v with an ETag of its content, answering 304 Not Modified
+// when the request If-None-Match already holds it
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body.Bytes())
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using weak comparison
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestGenerationHeaderChangesOnMutation(t *testing.T) {
+	vh := newSearchTestHandler(t)
+	payload, _ := json.Marshal(models.SearchByTextRequest{Text: "quick fox"})
+
+	search := func() string {
+		rec := httptest.NewRecorder()
+		vh.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("search status = %d: %s", rec.Code, rec.Body.String())
+		}
+		return rec.Header().Get(GenerationHeader)
+	}
+
+	before := search()
+	if before == "" {
+		t.Fatalf("search response has no %s header", GenerationHeader)
+	}
+	if again := search(); again != before {
+		t.Errorf("generation changed without a mutation: %s -> %s", before, again)
+	}
+
+	rec := httptest.NewRecorder()
+	vh.DeleteVector(rec, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/", nil), map[string]string{"id": "fox"}))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d", rec.Code)
+	}
+
+	after := search()
+	if after == before {
+		t.Errorf("generation %s unchanged after delete", after)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.GetGeneration(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/generation", nil))
+	var resp map[string]uint64
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode generation: %v", err)
+	}
+	if got := rec.Header().Get(GenerationHeader); got != after {
+		t.Errorf("generation endpoint header = %s, want %s", got, after)
+	}
+	if resp["generation"] != 4 {
+		t.Errorf("generation = %d, want 4 after three stores and a delete", resp["generation"])
+	}
+}
+
+func TestETagNotModified(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+	if err := store.Store(&models.Vector{ID: "v1", Embedding: []float64{1, 0}}); err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+
+	endpoints := map[string]func(etag string) *httptest.ResponseRecorder{
+		"get": func(etag string) *httptest.ResponseRecorder {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": "v1"})
+			req.Header.Set("If-None-Match", etag)
+			rec := httptest.NewRecorder()
+			vh.GetVector(rec, req)
+			return rec
+		},
+		"metadata": func(etag string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("If-None-Match", etag)
+			rec := httptest.NewRecorder()
+			vh.ListVectorMetadata(rec, req)
+			return rec
+		},
+	}
+
+	for name, request := range endpoints {
+		t.Run(name, func(t *testing.T) {
+			first := request("")
+			etag := first.Header().Get("ETag")
+			if first.Code != http.StatusOK || etag == "" {
+				t.Fatalf("status = %d, etag = %q", first.Code, etag)
+			}
+
+			if rec := request(etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+				t.Errorf("matching etag: status = %d, body = %q, want empty 304", rec.Code, rec.Body.String())
+			}
+			if rec := request(`"stale", W/` + etag); rec.Code != http.StatusNotModified {
+				t.Errorf("etag list: status = %d, want 304", rec.Code)
+			}
+
+			if err := store.Store(&models.Vector{ID: "v1", Embedding: []float64{0, 1}}); err != nil {
+				t.Fatalf("store failed: %v", err)
+			}
+			if rec := request(etag); rec.Code != http.StatusOK {
+				t.Errorf("changed resource: status = %d, want 200", rec.Code)
+			}
+		})
+	}
+}
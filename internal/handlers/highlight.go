@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// highlighter builds snippets of stored text with the query terms marked up
+// Matching is token based and case-insensitive: words of the stored text are
+// tokenized like the query, using the embedder preprocessing when available
+type highlighter struct {
+	terms    map[string]bool
+	tokenize func(text string) []string
+	opts     models.HighlightOptions
+}
+
+// wordSpan is the byte range of a word in the highlighted text
+type wordSpan struct {
+	start, end int
+	match      bool
+}
+
+// fragment is a byte range of the highlighted text
+type fragment struct {
+	start, end int
+}
+
+func newHighlighter(embedder embedders.Embedder, query string, opts *models.HighlightOptions) *highlighter {
+	h := &highlighter{
+		terms:    make(map[string]bool),
+		tokenize: splitWords,
+		opts:     opts.WithDefaults(),
+	}
+
+	if tokenizer, ok := embedder.(embedders.Tokenizer); ok {
+		h.tokenize = tokenizer.Tokenize
+	}
+
+	for _, term := range h.tokenize(query) {
+		h.terms[term] = true
+	}
+
+	return h
+}
+
+// splitWords lowercases text and splits it into unicode words
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isWordSeparator)
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// HighlightVector returns the highlights of the configured text field of vector
+// A nil highlighter or a vector without the field has none
+func (h *highlighter) HighlightVector(vector *models.Vector) []string {
+	if h == nil || vector == nil {
+		return nil
+	}
+	if text, ok := vector.Metadata[h.opts.Field]; ok {
+		return h.Highlight(text)
+	}
+	return nil
+}
+
+// Highlight returns up to the configured number of fragments of text with
+// matched terms wrapped in the configured tags
+// Purely semantic matches have nothing to highlight and get the leading snippet
+func (h *highlighter) Highlight(text string) []string {
+	spans := h.spans(text)
+	if len(spans) == 0 {
+		return nil
+	}
+
+	fragments := make([]fragment, 0, h.opts.Fragments)
+	for i, span := range spans {
+		if !span.match {
+			continue
+		}
+
+		// Matches already shown in the previous fragment need no new one
+		if n := len(fragments); n > 0 && span.end <= fragments[n-1].end {
+			continue
+		}
+
+		frag := h.fragmentAround(text, spans, i)
+
+		// Merge overlapping fragments so no text is repeated
+		if n := len(fragments); n > 0 && frag.start < fragments[n-1].end {
+			fragments[n-1].end = frag.end
+			continue
+		}
+
+		if len(fragments) == h.opts.Fragments {
+			break
+		}
+		fragments = append(fragments, frag)
+	}
+
+	if len(fragments) == 0 {
+		fragments = append(fragments, h.fragmentAround(text, spans, 0))
+	}
+
+	highlights := make([]string, len(fragments))
+	for i, frag := range fragments {
+		highlights[i] = h.render(text, spans, frag)
+	}
+
+	return highlights
+}
+
+// spans finds the words of text and marks the ones matching a query term
+func (h *highlighter) spans(text string) []wordSpan {
+	spans := make([]wordSpan, 0)
+
+	start := -1
+	for i := 0; i <= len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if i == len(text) || isWordSeparator(r) {
+			if start >= 0 {
+				spans = append(spans, wordSpan{start: start, end: i, match: h.matches(text[start:i])})
+				start = -1
+			}
+			if i == len(text) {
+				break
+			}
+		} else if start < 0 {
+			start = i
+		}
+		i += size
+	}
+
+	return spans
+}
+
+func (h *highlighter) matches(word string) bool {
+	for _, token := range h.tokenize(word) {
+		if h.terms[token] {
+			return true
+		}
+	}
+	return false
+}
+
+// fragmentAround returns a fragment of about FragmentSize bytes containing
+// spans[i], starting a little before it so the match has leading context
+// Fragments reaching the first or last word keep the surrounding punctuation
+func (h *highlighter) fragmentAround(text string, spans []wordSpan, i int) fragment {
+	size := h.opts.FragmentSize
+
+	first := i
+	for first > 0 && spans[i].start-spans[first-1].start <= size/4 {
+		first--
+	}
+
+	last := i
+	for last+1 < len(spans) && spans[last+1].end-spans[first].start <= size {
+		last++
+	}
+
+	frag := fragment{start: spans[first].start, end: spans[last].end}
+	if first == 0 {
+		frag.start = 0
+	}
+	if last == len(spans)-1 {
+		frag.end = len(text)
+	}
+	return frag
+}
+
+// render returns the text of a fragment with its matched words tagged
+func (h *highlighter) render(text string, spans []wordSpan, frag fragment) string {
+	var b strings.Builder
+
+	pos := frag.start
+	for _, span := range spans {
+		if span.start < frag.start || span.end > frag.end || !span.match {
+			continue
+		}
+		b.WriteString(text[pos:span.start])
+		b.WriteString(h.opts.PreTag)
+		b.WriteString(text[span.start:span.end])
+		b.WriteString(h.opts.PostTag)
+		pos = span.end
+	}
+	b.WriteString(text[pos:frag.end])
+
+	return b.String()
+}
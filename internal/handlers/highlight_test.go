@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/tfidf"
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestHighlight_MultiTerm(t *testing.T) {
+	h := newHighlighter(hash.NewHashEmbedder(), "Quantum PHYSICS", nil)
+
+	got := h.Highlight("Quantum physics is strange, but physics is fun.")
+	want := []string{"<em>Quantum</em> <em>physics</em> is strange, but <em>physics</em> is fun."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestHighlight_SeparateFragments(t *testing.T) {
+	opts := &models.HighlightOptions{FragmentSize: 20, Fragments: 5, PreTag: "[", PostTag: "]"}
+	h := newHighlighter(hash.NewHashEmbedder(), "fox dog", opts)
+
+	got := h.Highlight("the quick brown fox jumps over the lazy dog while another fox watches from far far away")
+	want := []string{"[fox] jumps over the", "lazy [dog] while", "[fox] watches from far"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestHighlight_OverlappingFragments(t *testing.T) {
+	opts := &models.HighlightOptions{FragmentSize: 16, Fragments: 5, PreTag: "[", PostTag: "]"}
+	h := newHighlighter(hash.NewHashEmbedder(), "fox dog", opts)
+
+	// The context before "dog" reaches back into the fragment around "fox",
+	// so both are merged into one fragment instead of repeating "g"
+	got := h.Highlight("fox abcdefghij g h dog tail")
+	want := []string{"[fox] abcdefghij g h [dog] tail"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestHighlight_FragmentLimitAndSemanticMatch(t *testing.T) {
+	opts := &models.HighlightOptions{FragmentSize: 10, Fragments: 1}
+	h := newHighlighter(hash.NewHashEmbedder(), "cat", opts)
+
+	got := h.Highlight("cat one two three four five six seven cat")
+	if len(got) != 1 || got[0] != "<em>cat</em> one" {
+		t.Errorf("expected a single leading fragment, got %q", got)
+	}
+
+	got = h.Highlight("dogs and birds are friends")
+	if len(got) != 1 || got[0] != "dogs and" {
+		t.Errorf("expected the leading snippet without highlights, got %q", got)
+	}
+}
+
+func TestHighlight_UsesEmbedderPreprocessing(t *testing.T) {
+	// The TF-IDF preprocessing drops stop words, so "the" is never highlighted
+	h := newHighlighter(tfidf.NewTFIDFEmbedder(), "the theory", nil)
+
+	got := h.Highlight("The theory of everything")
+	want := []string{"The <em>theory</em> of everything"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/jobs"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/bulk"
+)
+
+// JobRequest is the body of POST /api/v1/admin/jobs
+type JobRequest struct {
+	Type   string          `json:"type"`
+	Params json.RawMessage `json:"params"`
+}
+
+// newJobRunner returns the runner of the background jobs of vh, persisting them
+// when the backend keeps jobs, with the job types of the handler registered
+func newJobRunner(vh *VectorHandler) *jobs.Runner {
+	var store jobs.Store
+	if js, ok := vh.storage.(storage.JobStore); ok {
+		store = js
+	}
+	runner := jobs.NewRunner(store)
+	runner.Register(bulk.JobType, bulkJobHandler{vh: vh})
+	runner.Register(ReembedJobType, reembedJobHandler{vh: vh})
+	return runner
+}
+
+// SetJobWorkers sets the number of background jobs run at the same time,
+// jobs.DefaultWorkers when not positive
+func (vh *VectorHandler) SetJobWorkers(workers int) {
+	vh.jobs.SetWorkers(workers)
+}
+
+// ResumeJobs loads the persisted jobs and queues the unfinished ones, which
+// continue from their last checkpoint. It returns how many were queued
+func (vh *VectorHandler) ResumeJobs() (int, error) {
+	return vh.jobs.Recover()
+}
+
+// StopJobs stops the running jobs, leaving them to resume on the next start
+func (vh *VectorHandler) StopJobs() {
+	vh.jobs.Stop()
+}
+
+// SubmitJob handles POST /api/v1/admin/jobs, queueing a background job of a registered type
+func (vh *VectorHandler) SubmitJob(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Type == "" {
+		http.Error(w, "type is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Params) == 0 {
+		req.Params = json.RawMessage("{}")
+	}
+
+	job, err := vh.jobs.Submit(req.Type, req.Params)
+	if err != nil {
+		if errors.Is(err, storage.ErrValidation) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/admin/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// ListJobs handles GET /api/v1/admin/jobs?type=&state=, listing the jobs newest first
+func (vh *VectorHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	listed := []*jobs.Job{}
+	for _, job := range vh.jobs.List(r.URL.Query().Get("type")) {
+		if state == "" || job.State == state {
+			listed = append(listed, job)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listed)
+}
+
+// GetJob handles GET /api/v1/admin/jobs/{id}, reporting the progress of a job
+func (vh *VectorHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := vh.jobs.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelJob handles DELETE /api/v1/admin/jobs/{id}, canceling a queued or running job
+func (vh *VectorHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := vh.jobs.Cancel(mux.Vars(r)["id"])
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// RetryJob handles POST /api/v1/admin/jobs/{id}/retry, queueing a failed or canceled
+// job again from its last checkpoint
+func (vh *VectorHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	job, err := vh.jobs.Retry(mux.Vars(r)["id"])
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// writeJobError reports a failed job operation, a 409 when the state of the job
+// does not allow it
+func writeJobError(w http.ResponseWriter, err error) {
+	var stateErr *jobs.StateError
+	if errors.As(err, &stateErr) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeStoreError(w, err)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/jobs"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func submitJob(t *testing.T, vh *VectorHandler, body string) (int, *jobs.Job) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	vh.SubmitJob(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/jobs", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusAccepted {
+		return rec.Code, nil
+	}
+	var job jobs.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("failed to decode job: %v", err)
+	}
+	return rec.Code, &job
+}
+
+// waitJob polls a job until it finishes
+func waitJob(t *testing.T, vh *VectorHandler, id string) *jobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rec := httptest.NewRecorder()
+		vh.GetJob(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs/"+id, nil), map[string]string{"id": id}))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("get job: status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var job jobs.Job
+		if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+			t.Fatalf("failed to decode job: %v", err)
+		}
+		if job.Finished() {
+			return &job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestReembedJob(t *testing.T) {
+	store := memory.NewStorage()
+	for _, vector := range []*models.Vector{
+		{ID: "a-1", Embedding: []float64{1, 0}, Metadata: map[string]string{models.NamespaceKey: "a", "text": "one"}},
+		{ID: "a-2", Embedding: []float64{1, 0}, Metadata: map[string]string{models.NamespaceKey: "a", "text": "two"}},
+		{ID: "a-3", Embedding: []float64{1, 0}, Metadata: map[string]string{models.NamespaceKey: "a", "text": "three"}},
+		{ID: "a-4", Embedding: []float64{1, 0}, Metadata: map[string]string{models.NamespaceKey: "a"}},
+		{ID: "b-1", Embedding: []float64{1, 0}, Metadata: map[string]string{models.NamespaceKey: "b", "text": "other"}},
+	} {
+		if err := store.Store(vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vh := NewVectorHandler(store, fixedEmbedder{0, 1})
+
+	for name, body := range map[string]string{
+		"no type":              `{"params": {}}`,
+		"unknown type":         `{"type": "defragment"}`,
+		"invalid batch size":   `{"type": "reembed", "params": {"batch_size": -1}}`,
+		"bulk dry run":         `{"type": "bulk", "params": {"action": "delete", "namespace": "a", "dry_run": true}}`,
+		"invalid bulk request": `{"type": "bulk", "params": {"action": "truncate", "namespace": "a"}}`,
+	} {
+		if code, _ := submitJob(t, vh, body); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, code)
+		}
+	}
+
+	code, job := submitJob(t, vh, `{"type": "reembed", "params": {"namespace": "a", "batch_size": 2}}`)
+	if code != http.StatusAccepted {
+		t.Fatalf("submit: status = %d", code)
+	}
+	job = waitJob(t, vh, job.ID)
+	want := jobs.Progress{Total: 4, Processed: 4, Affected: 3, Failed: 1}
+	if job.State != jobs.StateCompleted || job.Checkpoint != "a-4" || len(job.Progress.Errors) != 1 {
+		t.Errorf("reembed job = %+v", job)
+	}
+	if job.Progress.Errors = nil; !reflect.DeepEqual(job.Progress, want) {
+		t.Errorf("progress = %+v, want %+v", job.Progress, want)
+	}
+
+	embedded, _ := store.Get("a-2")
+	if embedded.Embedding[1] != 1 || embedded.Metadata[models.EmbedderNameKey] != "fixed" || embedded.Metadata["text"] != "two" {
+		t.Errorf("a-2 = %+v, want embedded again", embedded)
+	}
+	for _, id := range []string{"a-4", "b-1"} {
+		if kept, _ := store.Get(id); kept.Embedding[0] != 1 {
+			t.Errorf("%s embedded again, want it kept", id)
+		}
+	}
+
+	// Finished jobs can be neither canceled nor retried
+	for name, handler := range map[string]http.HandlerFunc{"cancel": vh.CancelJob, "retry": vh.RetryJob} {
+		rec := httptest.NewRecorder()
+		handler(rec, mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", nil), map[string]string{"id": job.ID}))
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%s a completed job: status = %d, want 409", name, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	vh.GetJob(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"id": "unknown"}))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get an unknown job: status = %d, want 404", rec.Code)
+	}
+
+	// Bulk jobs are listed among the jobs
+	_, bulkJob, _ := startBulk(t, vh, `{"action": "delete", "namespace": "b", "rate": 100000}`)
+	waitJob(t, vh, bulkJob.ID)
+	list := func(query string) []*jobs.Job {
+		rec := httptest.NewRecorder()
+		vh.ListJobs(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs"+query, nil))
+		var listed []*jobs.Job
+		if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+			t.Fatalf("failed to decode jobs: %v", err)
+		}
+		return listed
+	}
+	if listed := list(""); len(listed) != 2 || listed[0].ID != bulkJob.ID {
+		t.Errorf("listed %d jobs, want 2 newest first", len(listed))
+	}
+	if listed := list("?type=reembed"); len(listed) != 1 || listed[0].ID != job.ID {
+		t.Errorf("listed reembed jobs = %+v", listed)
+	}
+	if listed := list("?state=failed"); len(listed) != 0 {
+		t.Errorf("listed failed jobs = %+v, want none", listed)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/analysis"
+)
+
+// graphContentTypes are the response content types of the graph formats
+var graphContentTypes = map[string]string{
+	analysis.GraphJSONL:   "application/x-ndjson",
+	analysis.GraphGraphML: "application/graphml+xml",
+}
+
+// ExportKNNGraph handles GET /api/v1/admin/knn-graph, streaming the k nearest
+// neighbors of every vector as JSONL edges or GraphML
+// Query parameters: k, threshold, metric, namespace, filters (a JSON object in the
+// canonical filter form), sample, seed and format (jsonl or graphml)
+func (vh *VectorHandler) ExportKNNGraph(w http.ResponseWriter, r *http.Request) {
+	opts, format, err := parseGraphOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := vh.storage.ListByNamespace(opts.Namespace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	vectors, err := analysis.GraphVectors(stored, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", graphContentTypes[format])
+	graph, err := analysis.NewGraphWriter(w, format, vectors)
+	if err != nil {
+		logrus.WithError(err).Error("failed to write k-NN graph")
+		return
+	}
+
+	// Flush after every block so clients see progress on large stores
+	flusher, _ := w.(http.Flusher)
+	opts.Progress = func(done, total int) {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		logrus.WithFields(logrus.Fields{"done": done, "total": total}).Debug("k-NN graph progress")
+	}
+
+	start := time.Now()
+	edges := 0
+	err = analysis.KNNGraph(vectors, opts, func(edge analysis.Edge) error {
+		edges++
+		return graph.WriteEdge(edge)
+	})
+	if err == nil {
+		err = graph.Close()
+	}
+	if err != nil {
+		logrus.WithError(err).Error("failed to write k-NN graph")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"vectors":  len(vectors),
+		"edges":    edges,
+		"duration": time.Since(start),
+	}).Info("k-NN graph exported")
+}
+
+// parseGraphOptions reads the k-NN graph options and output format of a request
+func parseGraphOptions(r *http.Request) (*analysis.GraphOptions, string, error) {
+	query := r.URL.Query()
+	opts := &analysis.GraphOptions{
+		Namespace: query.Get("namespace"),
+		Metric:    query.Get("metric"),
+	}
+
+	var err error
+	if raw := query.Get("k"); raw != "" {
+		if opts.K, err = strconv.Atoi(raw); err != nil || opts.K <= 0 {
+			return nil, "", fmt.Errorf("invalid k %q: must be a positive integer", raw)
+		}
+	}
+	if raw := query.Get("sample"); raw != "" {
+		if opts.Sample, err = strconv.Atoi(raw); err != nil {
+			return nil, "", fmt.Errorf("invalid sample %q", raw)
+		}
+	}
+	if raw := query.Get("seed"); raw != "" {
+		if opts.Seed, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			return nil, "", fmt.Errorf("invalid seed %q", raw)
+		}
+	}
+	if raw := query.Get("threshold"); raw != "" {
+		threshold, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid threshold %q", raw)
+		}
+		opts.Threshold = &threshold
+	}
+	if raw := query.Get("filters"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.Filters); err != nil {
+			return nil, "", fmt.Errorf("invalid filters: %v", err)
+		}
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = analysis.GraphJSONL
+	}
+	if _, ok := graphContentTypes[format]; !ok {
+		return nil, "", fmt.Errorf("unsupported graph format %q: must be %s or %s", format, analysis.GraphJSONL, analysis.GraphGraphML)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, "", err
+	}
+	return opts, format, nil
+}
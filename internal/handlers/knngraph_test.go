@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/analysis"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestExportKNNGraph(t *testing.T) {
+	store := memory.NewStorage()
+	for id, embedding := range map[string][]float64{"a": {1, 0}, "b": {0.9, 0.1}, "c": {0, 1}} {
+		store.Store(&models.Vector{ID: id, Embedding: embedding, Metadata: map[string]string{models.NamespaceKey: "docs"}})
+	}
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+
+	rec := httptest.NewRecorder()
+	vh.ExportKNNGraph(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/knn-graph?k=1&namespace=docs", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("status = %d, content type %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	var edges []analysis.Edge
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var edge analysis.Edge
+		if err := json.Unmarshal(scanner.Bytes(), &edge); err != nil {
+			t.Fatalf("invalid edge line %q: %v", scanner.Text(), err)
+		}
+		edges = append(edges, edge)
+	}
+	if len(edges) != 3 || edges[0].Source != "a" || edges[0].Target != "b" || edges[2].Target != "b" {
+		t.Errorf("edges = %+v", edges)
+	}
+
+	for _, query := range []string{"k=-1", "k=x", "format=csv", "metric=manhattan", "threshold=high", `filters={"lang":{"like":"en"}}`} {
+		rec := httptest.NewRecorder()
+		vh.ExportKNNGraph(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/knn-graph?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
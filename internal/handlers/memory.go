@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// GetMemory handles GET /api/v1/admin/memory, reporting the memory limits of the storage,
+// its estimated usage and the number of vectors evicted and writes rejected
+func (vh *VectorHandler) GetMemory(w http.ResponseWriter, r *http.Request) {
+	mm, ok := vh.storage.(storage.MemoryManager)
+	if !ok {
+		http.Error(w, "storage backend does not support memory limits", http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mm.MemoryReport())
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestMemoryEndpoint(t *testing.T) {
+	store := memory.NewStorage()
+	store.SetMemoryLimits(memlimit.Options{MaxVectors: 1})
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+
+	create := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(`{"id":"`+id+`","embedding":[1,0]}`)))
+		return rec
+	}
+	if rec := create("a"); rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := create("b")
+	var rejected storeErrorResponse
+	if rec.Code != http.StatusInsufficientStorage || json.Unmarshal(rec.Body.Bytes(), &rejected) != nil || rejected.Memory == nil {
+		t.Fatalf("create past the cap: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	vh.GetMemory(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/memory", nil))
+	var report memlimit.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if report.MaxVectors != 1 || report.Policy != memlimit.PolicyReject || report.Usage.Vectors != 1 || report.Rejections != 1 {
+		t.Errorf("report = %s", rec.Body.String())
+	}
+
+	adapter, err := local.NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	NewVectorHandler(adapter, hash.NewHashEmbedder()).GetMemory(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/memory", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("local storage status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+)
+
+// SetNormalizeKeys enables normalizing the metadata keys of written vectors to
+// lowercase snake_case. Keys that collide with different values are rejected
+func (vh *VectorHandler) SetNormalizeKeys(normalize bool) {
+	vh.normalizeKeys = normalize
+}
+
+// SetDefaultMetadata sets the metadata added to written vectors lacking its keys,
+// nil for none. In enforce mode, writes setting a locked key to another value fail
+func (vh *VectorHandler) SetDefaultMetadata(config *defaults.Config) {
+	vh.defaultMetadata = config
+}
+
+// DefaultMetadata returns the default metadata of written vectors, nil when none is set
+func (vh *VectorHandler) DefaultMetadata() *defaults.Config {
+	return vh.defaultMetadata
+}
+
+// SetKeyFallback sets whether filters fall back to keys differing only in case
+// or separators, requests can override it with the key_fallback option
+func (vh *VectorHandler) SetKeyFallback(fallback bool) {
+	vh.keyFallback.Store(fallback)
+}
+
+// normalizeMetadata normalizes the metadata keys of a vector about to be written, if
+// enabled, then adds the default metadata it lacks
+func (vh *VectorHandler) normalizeMetadata(vector *models.Vector) error {
+	if vh.normalizeKeys {
+		metadata, _, err := models.NormalizeMetadataKeys(vector.Metadata, models.KeyMergeError)
+		if err != nil {
+			return err
+		}
+		vector.Metadata = metadata
+	}
+	metadata, err := vh.defaultMetadata.Apply(vector.Metadata)
+	if err != nil {
+		return err
+	}
+	vector.Metadata = metadata
+	return nil
+}
+
+// writeMetadataError reports a vector normalizeMetadata rejected: 422 naming the key
+// for a locked default, 400 for colliding keys
+func writeMetadataError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrValidation) {
+		writeStoreError(w, err)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// keyFallbackEnabled resolves the key_fallback option against the handler default
+func (vh *VectorHandler) keyFallbackEnabled(q *searchQuery) bool {
+	if q.KeyFallback != nil {
+		return *q.KeyFallback
+	}
+	return vh.keyFallback.Load()
+}
+
+// recordKeyFallbacks notes the filter fields a result matched through another key,
+// so the response can tell users which stored keys need cleaning up
+func (q *searchQuery) recordKeyFallbacks(vector *models.Vector) {
+	if q.filters == nil {
+		return
+	}
+	for field, keys := range q.filters.KeyFallbacks(vector.Metadata) {
+		if q.keyFallbacks == nil {
+			q.keyFallbacks = make(map[string][]string)
+		}
+		for _, key := range keys {
+			if !containsString(q.keyFallbacks[field], key) {
+				q.keyFallbacks[field] = append(q.keyFallbacks[field], key)
+				sort.Strings(q.keyFallbacks[field])
+			}
+		}
+	}
+}
+
+// searchMeta returns the meta of a search response, nil when there is nothing to report
+func (q *searchQuery) searchMeta(warnings []string) *SearchMeta {
+	warnings = append(warnings, q.embedderWarnings()...)
+	if len(warnings) == 0 && len(q.keyFallbacks) == 0 && q.Profile == "" && q.Snapshot == "" && q.overrideMeta == nil && len(q.corrections) == 0 {
+		return nil
+	}
+	meta := &SearchMeta{Warnings: warnings, Profile: q.Profile, Snapshot: q.Snapshot, KeyFallbacks: q.keyFallbacks, Embedder: q.overrideMeta}
+	for _, correction := range q.corrections {
+		meta.Corrections = append(meta.Corrections, correction.String())
+	}
+	return meta
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+)
+
+func TestCreateVector_NormalizeKeys(t *testing.T) {
+	vh := newSearchTestHandler(t)
+	vh.SetNormalizeKeys(true)
+
+	rec := httptest.NewRecorder()
+	vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors",
+		bytes.NewBufferString(`{"id": "n1", "embedding": [1, 0], "metadata": {"Author": "Einstein", "createdAt": "1905"}}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var vector models.Vector
+	if err := json.Unmarshal(rec.Body.Bytes(), &vector); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"author": "Einstein", "created_at": "1905"}; !reflect.DeepEqual(vector.Metadata, want) {
+		t.Errorf("metadata = %v, want %v", vector.Metadata, want)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors",
+		bytes.NewBufferString(`{"id": "n2", "embedding": [1, 0], "metadata": {"Author": "Curie", "author": "Marie Curie"}}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for colliding keys, got %d", rec.Code)
+	}
+}
+
+func TestDefaultMetadata(t *testing.T) {
+	vh := newSearchTestHandler(t)
+	vh.SetDefaultMetadata(&defaults.Config{Metadata: map[string]string{"environment": "prod", "region": "eu"}})
+
+	create := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(body)))
+		return rec
+	}
+	metadataOf := func(rec *httptest.ResponseRecorder) map[string]string {
+		t.Helper()
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var vector models.Vector
+		if err := json.Unmarshal(rec.Body.Bytes(), &vector); err != nil {
+			t.Fatal(err)
+		}
+		return vector.Metadata
+	}
+
+	// Defaults fill the missing keys, values of the caller are kept
+	got := metadataOf(create(`{"id": "d1", "embedding": [1, 0]}`))
+	if want := map[string]string{"environment": "prod", "region": "eu"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("metadata = %v, want %v", got, want)
+	}
+	got = metadataOf(create(`{"id": "d2", "embedding": [1, 0], "metadata": {"region": "us"}}`))
+	if want := map[string]string{"environment": "prod", "region": "us"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("metadata with override = %v, want %v", got, want)
+	}
+
+	// In enforce mode a conflicting value of a locked key is rejected naming the key
+	vh.SetDefaultMetadata(&defaults.Config{Metadata: map[string]string{"environment": "prod"}, Enforce: true})
+	rec := create(`{"id": "d3", "embedding": [1, 0], "metadata": {"environment": "dev"}}`)
+	var resp storeErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("enforced conflict: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if resp.Locked == nil || resp.Locked.Key != "environment" || resp.Code != codeValidation {
+		t.Errorf("enforced conflict response = %+v", resp)
+	}
+	if _, err := vh.storage.Get("d3"); err == nil {
+		t.Error("rejected vector was stored")
+	}
+
+	rec = httptest.NewRecorder()
+	vh.StoreVectorBatch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/batch",
+		bytes.NewBufferString(`{"vectors": [{"id": "d4", "embedding": [1, 0]}, {"id": "d5", "embedding": [1, 0], "metadata": {"environment": "dev"}}]}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("batch with an enforced conflict: status %d, want 422", rec.Code)
+	}
+}
+
+func TestAdvancedSearch_KeyFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		fallback  *bool
+		byDefault bool
+		want      int
+		meta      map[string][]string
+	}{
+		{name: "disabled", want: 1},
+		{name: "requested", fallback: boolPtr(true), want: 2, meta: map[string][]string{"category": {"Category"}}},
+		{name: "server default", byDefault: true, want: 2, meta: map[string][]string{"category": {"Category"}}},
+		{name: "request overrides default", fallback: boolPtr(false), byDefault: true, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vh := newSearchTestHandler(t)
+			vh.SetKeyFallback(tt.byDefault)
+
+			embedding, _ := vh.embedder.Embed("quick green cat")
+			if err := vh.storage.Store(&models.Vector{ID: "cat", Embedding: embedding, Metadata: map[string]string{"Category": "a"}}); err != nil {
+				t.Fatal(err)
+			}
+
+			body := map[string]interface{}{
+				"query":   "the quick brown fox",
+				"filters": map[string]interface{}{"category": map[string]interface{}{"eq": "a"}},
+			}
+			if tt.fallback != nil {
+				body["key_fallback"] = *tt.fallback
+			}
+			payload, _ := json.Marshal(body)
+
+			rec := httptest.NewRecorder()
+			vh.AdvancedSearch(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var resp AdvancedSearchResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Results) != tt.want {
+				t.Errorf("expected %d results, got %d", tt.want, len(resp.Results))
+			}
+			var meta map[string][]string
+			if resp.Meta != nil {
+				meta = resp.Meta.KeyFallbacks
+			}
+			if !reflect.DeepEqual(meta, tt.meta) {
+				t.Errorf("key_fallbacks = %v, want %v", meta, tt.meta)
+			}
+		})
+	}
+}
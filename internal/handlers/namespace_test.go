@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/ingestion"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestSearchByText_IngestedNamespace(t *testing.T) {
+	store := memory.NewStorage()
+	embedder := hash.NewHashEmbedder()
+
+	dataPath := filepath.Join(t.TempDir(), "data.jsonl")
+	data := `{"id": "1", "text": "the quick brown fox jumps"}
+{"id": "2", "text": "a lazy dog sleeps all day"}
+`
+	if err := os.WriteFile(dataPath, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write data file: %v", err)
+	}
+
+	// Ingest the same file twice into different namespaces, as `ingest -n` would
+	for _, namespace := range []string{"myspace", "otherspace"} {
+		config := &ingestion.SourceConfig{Namespace: namespace, BatchSize: 10}
+		source, err := ingestion.NewFileSource(dataPath, config)
+		if err != nil {
+			t.Fatalf("failed to create source: %v", err)
+		}
+		ingestor := ingestion.NewIngestor(source, embedder, store, config)
+		if _, err := ingestor.Run(context.Background()); err != nil {
+			t.Fatalf("ingestion into %s failed: %v", namespace, err)
+		}
+	}
+
+	handler := NewVectorHandler(store, embedder)
+
+	body, _ := json.Marshal(models.SearchByTextRequest{Text: "quick fox", TopK: 10, Namespace: "myspace"})
+	rec := httptest.NewRecorder()
+	handler.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Matches []*models.SearchResult `json:"matches"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(resp.Matches) != 2 {
+		t.Fatalf("expected 2 matches in myspace, got %d", len(resp.Matches))
+	}
+	for _, match := range resp.Matches {
+		if match.Vector.Metadata[models.NamespaceKey] != "myspace" {
+			t.Errorf("expected namespace myspace, got %s", match.Vector.Metadata[models.NamespaceKey])
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.CountVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/count?namespace=myspace", nil))
+
+	var count map[string]int
+	if err := json.NewDecoder(rec.Body).Decode(&count); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if count["count"] != 2 {
+		t.Errorf("expected count 2 for myspace, got %d", count["count"])
+	}
+}
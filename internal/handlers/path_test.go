@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+func TestVectorHandler_TraversalIDs(t *testing.T) {
+	root := t.TempDir()
+	store, err := local.NewVectorStorageAdapter(filepath.Join(root, "store"), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	handler := NewVectorHandler(store, hash.NewHashEmbedder())
+
+	// Weird but printable IDs are accepted and encoded by the storage
+	for _, id := range []string{"../../etc/cron.d/evil", "/etc/passwd", `..\..\evil`, ".."} {
+		body, _ := json.Marshal(models.Vector{ID: id, Embedding: []float64{1, 2, 3}})
+		rec := httptest.NewRecorder()
+		handler.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Errorf("create %q: expected status 201, got %d: %s", id, rec.Code, rec.Body.String())
+			continue
+		}
+
+		rec = httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/vectors/x", nil), map[string]string{"id": id})
+		handler.GetVector(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("get %q: expected status 200, got %d", id, rec.Code)
+		}
+	}
+
+	// Control characters are rejected before reaching the storage
+	for _, id := range []string{"evil\x00.json", "line\nbreak", "esc\x1b"} {
+		body, _ := json.Marshal(models.Vector{ID: id, Embedding: []float64{1, 2, 3}})
+		rec := httptest.NewRecorder()
+		handler.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("create %q: expected status 400, got %d", id, rec.Code)
+		}
+
+		for _, call := range []http.HandlerFunc{handler.GetVector, handler.UpdateVector, handler.DeleteVector} {
+			rec := httptest.NewRecorder()
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/api/v1/vectors/x", bytes.NewReader(body)), map[string]string{"id": id})
+			call(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%q: expected status 400, got %d", id, rec.Code)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("failed to read temp dir: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "store" {
+			t.Errorf("file %q was written outside the storage directory", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// EmbedPendingReport is the result of embedding the pending vectors
+type EmbedPendingReport struct {
+	Pending  int      `json:"pending"`           // Pending vectors found
+	Embedded int      `json:"embedded"`          // Vectors embedded, or that would be on a dry run
+	Skipped  []string `json:"skipped,omitempty"` // IDs left pending, without text or failing to embed
+	DryRun   bool     `json:"dry_run,omitempty"`
+}
+
+// parseBoolQuery parses the optional boolean query parameter name
+// set is false when the parameter is absent
+func parseBoolQuery(r *http.Request, name string) (value, set bool, err error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, false, nil
+	}
+	value, err = strconv.ParseBool(raw)
+	if err != nil {
+		return false, false, err
+	}
+	return value, true, nil
+}
+
+// filterByEmbedding keeps the vectors whose embedding presence is hasEmbedding
+func filterByEmbedding(vectors []*models.Vector, hasEmbedding bool) []*models.Vector {
+	filtered := make([]*models.Vector, 0, len(vectors))
+	for _, vector := range vectors {
+		if vector.HasEmbedding() == hasEmbedding {
+			filtered = append(filtered, vector)
+		}
+	}
+	return filtered
+}
+
+// EmbedPending handles POST /api/v1/admin/embed-pending?namespace=&dry_run=
+// Pending vectors, stored without embedding, are embedded from their "text"
+// metadata, sparse when sparse embeddings are enabled. It runs synchronously
+func (vh *VectorHandler) EmbedPending(w http.ResponseWriter, r *http.Request) {
+	dryRun, _, err := parseBoolQuery(r, "dry_run")
+	if err != nil {
+		http.Error(w, "invalid dry_run value", http.StatusBadRequest)
+		return
+	}
+
+	vectors, err := vh.storage.ListByNamespace(r.URL.Query().Get("namespace"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pending := filterByEmbedding(vectors, false)
+
+	report := EmbedPendingReport{Pending: len(pending), DryRun: dryRun}
+	embedded := make([]*models.Vector, 0, len(pending))
+	for _, vector := range pending {
+		text := vector.Metadata["text"]
+		if text == "" {
+			report.Skipped = append(report.Skipped, vector.ID)
+			continue
+		}
+		if dryRun {
+			report.Embedded++
+			continue
+		}
+
+		updated, err := vh.embedPendingVector(vector, text)
+		if err != nil {
+			logrus.WithError(err).WithField("vector_id", vector.ID).Warn("failed to embed pending vector")
+			report.Skipped = append(report.Skipped, vector.ID)
+			continue
+		}
+		embedded = append(embedded, updated)
+	}
+
+	if len(embedded) > 0 {
+		if err := storage.StoreBatch(vh.storage, embedded); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		vh.evaluateWatches(embedded)
+		report.Embedded = len(embedded)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// embedPendingVector returns a copy of vector embedded from text
+// The stored vector is left untouched, since storage may share it with readers
+func (vh *VectorHandler) embedPendingVector(vector *models.Vector, text string) (*models.Vector, error) {
+	embedder := vh.embedderFor(vector.Metadata[models.NamespaceKey])
+	sparse, ok, err := vh.embedTextSparse(embedder, text)
+	var embedding []float64
+	if !ok {
+		embedding, err = embedder.Embed(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(map[string]string, len(vector.Metadata)+1)
+	for key, value := range vector.Metadata {
+		metadata[key] = value
+	}
+	metadata[models.EmbedderNameKey] = embedder.Name()
+
+	updated := &models.Vector{
+		ID:        vector.ID,
+		Metadata:  metadata,
+		CreatedAt: vector.CreatedAt,
+		UpdatedAt: time.Now(),
+	}
+	if sparse != nil {
+		updated.SetSparse(sparse)
+	} else {
+		updated.SetEmbedding(embedding)
+	}
+	if err := updated.Validate(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestPendingVectors(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+
+	create := func(target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, target, bytes.NewBufferString(body)))
+		return rec
+	}
+
+	// Empty embeddings are rejected unless the request allows them
+	if rec := create("/api/v1/vectors", `{"id": "a", "metadata": {"text": "hello"}}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("without flag: status = %d, want 400", rec.Code)
+	}
+	if rec := create("/api/v1/vectors?allow_empty_embedding=maybe", `{"id": "a"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid flag: status = %d, want 400", rec.Code)
+	}
+	for _, body := range []string{
+		`{"id": "a", "metadata": {"text": "the quick brown fox"}}`,
+		`{"id": "lookup", "metadata": {"sku": "X-1"}}`,
+	} {
+		if rec := create("/api/v1/vectors?allow_empty_embedding=true", body); rec.Code != http.StatusCreated {
+			t.Fatalf("pending: status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	embedding, _ := hash.NewHashEmbedder().Embed("a lazy dog")
+	dense, _ := json.Marshal(map[string]interface{}{"id": "b", "embedding": embedding, "metadata": map[string]string{"text": "a lazy dog"}})
+	if rec := create("/api/v1/vectors", string(dense)); rec.Code != http.StatusCreated {
+		t.Fatalf("dense: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	list := func(query string) []models.Vector {
+		rec := httptest.NewRecorder()
+		vh.ListVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list %s: status = %d", query, rec.Code)
+		}
+		var vectors []models.Vector
+		if err := json.Unmarshal(rec.Body.Bytes(), &vectors); err != nil {
+			t.Fatalf("failed to decode list: %v", err)
+		}
+		return vectors
+	}
+	if got := list("?has_embedding=false"); len(got) != 2 {
+		t.Errorf("pending listed = %d, want 2", len(got))
+	}
+	if got := list("?has_embedding=true"); len(got) != 1 || got[0].ID != "b" {
+		t.Errorf("embedded listed = %+v, want b", got)
+	}
+	if got := list(""); len(got) != 3 {
+		t.Errorf("all listed = %d, want 3", len(got))
+	}
+
+	counts := func() map[string]int {
+		rec := httptest.NewRecorder()
+		vh.CountVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/count", nil))
+		var counts map[string]int
+		if err := json.Unmarshal(rec.Body.Bytes(), &counts); err != nil {
+			t.Fatalf("failed to decode count: %v", err)
+		}
+		return counts
+	}
+	if got := counts(); got["count"] != 3 || got["embedded"] != 1 || got["pending"] != 2 {
+		t.Errorf("counts = %v, want 3 total, 1 embedded, 2 pending", got)
+	}
+
+	// Pending vectors are never search results
+	search, _ := json.Marshal(map[string]interface{}{"embedding": embedding, "top_K": 10})
+	rec := httptest.NewRecorder()
+	vh.SearchVectors(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/search", bytes.NewBuffer(search)))
+	var results []models.SearchResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("failed to decode results: %v", err)
+	}
+	if len(results) != 1 || results[0].Vector.ID != "b" {
+		t.Fatalf("results = %+v, want only b", results)
+	}
+
+	embedPending := func(query string) EmbedPendingReport {
+		rec := httptest.NewRecorder()
+		vh.EmbedPending(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/embed-pending"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("embed-pending %s: status = %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var report EmbedPendingReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("failed to decode report: %v", err)
+		}
+		return report
+	}
+
+	if report := embedPending("?dry_run=true"); report.Pending != 2 || report.Embedded != 1 || !report.DryRun {
+		t.Errorf("dry run report = %+v", report)
+	}
+	if got := counts(); got["pending"] != 2 {
+		t.Errorf("dry run embedded vectors: pending = %d", got["pending"])
+	}
+
+	report := embedPending("")
+	if report.Pending != 2 || report.Embedded != 1 || len(report.Skipped) != 1 || report.Skipped[0] != "lookup" {
+		t.Errorf("report = %+v, want a embedded and lookup skipped", report)
+	}
+	if got := counts(); got["embedded"] != 2 || got["pending"] != 1 {
+		t.Errorf("counts after embedding = %v", got)
+	}
+
+	vector, err := vh.storage.Get("a")
+	if err != nil {
+		t.Fatalf("failed to get a: %v", err)
+	}
+	if !vector.HasEmbedding() || vector.Metadata[models.EmbedderNameKey] != "local.hash" || vector.Metadata["text"] != "the quick brown fox" {
+		t.Errorf("embedded vector = %+v", vector)
+	}
+}
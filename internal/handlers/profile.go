@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+)
+
+// profiledRequest is implemented by search requests that can name a ranking profile
+type profiledRequest interface {
+	ProfileName() string
+}
+
+// applyProfile fills the fields req leaves unset from the ranking profile it names
+func (vh *VectorHandler) applyProfile(req searchRequest) error {
+	named, ok := req.(profiledRequest)
+	if !ok || named.ProfileName() == "" {
+		return nil
+	}
+
+	ps, ok := vh.storage.(storage.ProfileStore)
+	if !ok {
+		return fmt.Errorf("storage backend does not support ranking profiles")
+	}
+	p, ok := ps.Profiles()[named.ProfileName()]
+	if !ok {
+		return fmt.Errorf("unknown ranking profile %q", named.ProfileName())
+	}
+	p.Apply(req)
+	return nil
+}
+
+// ListProfiles handles GET /api/v1/profiles, listing the ranking profiles by name
+func (vh *VectorHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	ps, ok := vh.storage.(storage.ProfileStore)
+	if !ok {
+		http.Error(w, "storage backend does not support ranking profiles", http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ps.Profiles().Sorted())
+}
+
+// GetProfile handles GET /api/v1/profiles/{name}
+func (vh *VectorHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	ps, ok := vh.storage.(storage.ProfileStore)
+	if !ok {
+		http.Error(w, "storage backend does not support ranking profiles", http.StatusNotImplemented)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	p, ok := ps.Profiles()[name]
+	if !ok {
+		http.Error(w, profile.NotFound(name).Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// SetProfile handles PUT /api/v1/admin/profiles/{name}, creating or replacing a ranking profile
+// The name comes from the path; a different name in the body is rejected
+func (vh *VectorHandler) SetProfile(w http.ResponseWriter, r *http.Request) {
+	ps, ok := vh.storage.(storage.ProfileStore)
+	if !ok {
+		http.Error(w, "storage backend does not support ranking profiles", http.StatusNotImplemented)
+		return
+	}
+
+	var p profile.Profile
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if p.Name != "" && p.Name != name {
+		http.Error(w, fmt.Sprintf("profile name %q does not match the path name %q", p.Name, name), http.StatusBadRequest)
+		return
+	}
+	p.Name = name
+
+	_, exists := ps.Profiles()[name]
+	if err := ps.SetProfile(p); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !exists {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// DeleteProfile handles DELETE /api/v1/admin/profiles/{name}
+func (vh *VectorHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	ps, ok := vh.storage.(storage.ProfileStore)
+	if !ok {
+		http.Error(w, "storage backend does not support ranking profiles", http.StatusNotImplemented)
+		return
+	}
+
+	if err := ps.DeleteProfile(mux.Vars(r)["name"]); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+)
+
+func TestProfiles_CRUD(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), fixedEmbedder{1, 0})
+
+	put := func(name, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/api/v1/admin/profiles/"+name, bytes.NewBufferString(body)), map[string]string{"name": name})
+		vh.SetProfile(rec, req)
+		return rec
+	}
+
+	if rec := put("news", `{"temporal_decay": "strong", "hybrid_weight": {"vector": 0.8, "metadata": 0.2}}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := put("news", `{"temporal_decay": "medium"}`); rec.Code != http.StatusOK {
+		t.Fatalf("replace: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := put("archive", `{"name": "news"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("mismatched name: status = %d, want 400", rec.Code)
+	}
+	if rec := put("broken", `{"metric": "manhattan"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid profile: status = %d, want 422", rec.Code)
+	}
+	put("archive", `{"temporal_decay": "none"}`)
+
+	rec := httptest.NewRecorder()
+	vh.ListProfiles(rec, httptest.NewRequest(http.MethodGet, "/api/v1/profiles", nil))
+	var listed []profile.Profile
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to decode profiles: %v", err)
+	}
+	if len(listed) != 2 || listed[0].Name != "archive" || listed[1].TemporalDecay != models.DecayMedium {
+		t.Errorf("profiles = %+v, want archive then the replaced news", listed)
+	}
+
+	get := func(name string) int {
+		rec := httptest.NewRecorder()
+		vh.GetProfile(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/profiles/"+name, nil), map[string]string{"name": name}))
+		return rec.Code
+	}
+	del := func(name string) int {
+		rec := httptest.NewRecorder()
+		vh.DeleteProfile(rec, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/v1/admin/profiles/"+name, nil), map[string]string{"name": name}))
+		return rec.Code
+	}
+	if code := get("news"); code != http.StatusOK {
+		t.Errorf("get: status = %d", code)
+	}
+	if code := del("news"); code != http.StatusNoContent {
+		t.Errorf("delete: status = %d", code)
+	}
+	if code := get("news"); code != http.StatusNotFound {
+		t.Errorf("get deleted: status = %d, want 404", code)
+	}
+	if code := del("news"); code != http.StatusNotFound {
+		t.Errorf("delete deleted: status = %d, want 404", code)
+	}
+}
+
+func TestProfiles_SearchPrecedence(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, fixedEmbedder{1, 0})
+
+	for _, vector := range []*models.Vector{
+		{ID: "image", Embedding: []float64{1, 0}, Metadata: map[string]string{"type": "image", "title": "Sunset", "text": "sunset"}},
+		{ID: "article", Embedding: []float64{0.9, 0.1}, Metadata: map[string]string{"type": "article", "title": "News", "text": "news"}},
+		{ID: "far", Embedding: []float64{0, 1}, Metadata: map[string]string{"type": "image", "title": "Night", "text": "night"}},
+	} {
+		if err := store.Store(vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	minScore := 0.5
+	if err := store.SetProfile(profile.Profile{
+		Name:           "gallery",
+		MinScore:       &minScore,
+		MetadataFields: []string{"title"},
+		Filters:        models.Filters{"type": {"eq": "image"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(body string) (int, []models.SearchResult, *SearchMeta) {
+		rec := httptest.NewRecorder()
+		vh.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", bytes.NewBufferString(body)))
+		var resp struct {
+			Matches []models.SearchResult `json:"matches"`
+			Meta    *SearchMeta           `json:"meta"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return rec.Code, resp.Matches, resp.Meta
+	}
+	ids := func(results []models.SearchResult) []string {
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.Vector.ID)
+		}
+		return ids
+	}
+
+	// Global defaults: every vector, all metadata
+	_, results, _ := search(`{"text": "q"}`)
+	if len(results) != 3 || len(results[0].Vector.Metadata) != 3 {
+		t.Errorf("defaults: results = %v", ids(results))
+	}
+
+	// Profile: images scoring at least 0.5, titles only
+	_, results, meta := search(`{"text": "q", "profile": "gallery"}`)
+	if got := ids(results); len(got) != 1 || got[0] != "image" {
+		t.Errorf("profile: results = %v, want [image]", got)
+	}
+	if len(results) == 1 && (len(results[0].Vector.Metadata) != 1 || results[0].Vector.Metadata["title"] != "Sunset") {
+		t.Errorf("profile: metadata = %v, want the title only", results[0].Vector.Metadata)
+	}
+	if meta == nil || meta.Profile != "gallery" {
+		t.Errorf("meta = %+v, want the gallery profile reported", meta)
+	}
+
+	// Request fields override the profile ones
+	_, results, _ = search(`{"text": "q", "profile": "gallery", "min_score": 0, "filters": {"type": {"eq": "article"}}, "metadata_fields": ["*"]}`)
+	if got := ids(results); len(got) != 1 || got[0] != "article" || len(results[0].Vector.Metadata) != 3 {
+		t.Errorf("override: results = %+v", results)
+	}
+
+	if code, _, _ := search(`{"text": "q", "profile": "unknown"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown profile: status = %d, want 400", code)
+	}
+	if code, _, _ := search(`{"text": "q", "metric": "euclidean", "min_score": 0.5}`); code != http.StatusBadRequest {
+		t.Errorf("min_score with euclidean: status = %d, want 400", code)
+	}
+}
+
+// fixedEmbedder embeds every text as the same vector, so scores depend only on the stored vectors
+type fixedEmbedder []float64
+
+func (e fixedEmbedder) Embed(string) ([]float64, error) { return e, nil }
+
+func (e fixedEmbedder) Name() string { return "fixed" }
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// GetProvenance handles GET /api/v1/vectors/{id}/provenance
+// It reports where an ingested vector came from and, when the backend keeps
+// a run history, the ingest run that stored it
+func (vh *VectorHandler) GetProvenance(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := models.ValidateID(id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vector, err := vh.storage.Get(id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	provenance := models.ProvenanceOf(vector)
+	if recorder, ok := vh.storage.(storage.RunRecorder); ok && provenance.RunID != "" {
+		runs, err := recorder.ListRuns(models.IngestRunFilter{Source: provenance.Source})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, run := range runs {
+			if run.ID == provenance.RunID {
+				provenance.Run = run
+				break
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(provenance)
+}
+
+// ListIngestRuns handles GET /api/v1/ingest/runs, newest first
+// Runs can be filtered with the source, namespace, since and until query parameters
+func (vh *VectorHandler) ListIngestRuns(w http.ResponseWriter, r *http.Request) {
+	recorder, ok := vh.storage.(storage.RunRecorder)
+	if !ok {
+		http.Error(w, "storage backend does not record ingest runs", http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.IngestRunFilter{
+		Source:    query.Get("source"),
+		Namespace: query.Get("namespace"),
+	}
+	for param, bound := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := models.ParseTime(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s: %v", param, err), http.StatusBadRequest)
+			return
+		}
+		*bound = &t
+	}
+
+	runs, err := recorder.ListRuns(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"runs":  runs,
+		"count": len(runs),
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestProvenanceEndpoints(t *testing.T) {
+	store := memory.NewStorage()
+	started := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"run-old", "run-new"} {
+		if err := store.RecordRun(&models.IngestRun{ID: id, Source: "file:quotes.jsonl", StartTime: started.AddDate(0, 0, i), Stored: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Store(&models.Vector{
+		ID:        "ingested",
+		Embedding: []float64{1, 0},
+		Metadata: map[string]string{
+			models.LineageSourceKey: "file:quotes.jsonl",
+			models.LineageRecordKey: "7",
+			models.LineageRunKey:    "run-old",
+			models.EmbedderNameKey:  "local-hash",
+		},
+	})
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/vectors/ingested/provenance", nil), map[string]string{"id": "ingested"})
+	vh.GetProvenance(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("provenance status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var provenance models.Provenance
+	if err := json.Unmarshal(rec.Body.Bytes(), &provenance); err != nil {
+		t.Fatal(err)
+	}
+	if provenance.Record != 7 || provenance.Embedder.Dimension != 2 || provenance.Run == nil || provenance.Run.ID != "run-old" {
+		t.Errorf("provenance = %+v", provenance)
+	}
+
+	rec = httptest.NewRecorder()
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/vectors/missing/provenance", nil), map[string]string{"id": "missing"})
+	vh.GetProvenance(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing vector status = %d, want 404", rec.Code)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+		code  int
+	}{
+		{"", []string{"run-new", "run-old"}, http.StatusOK},
+		{"?since=2024-03-02", []string{"run-new"}, http.StatusOK},
+		{"?until=2024-03-02&source=file:quotes.jsonl", []string{"run-old"}, http.StatusOK},
+		{"?source=other", []string{}, http.StatusOK},
+		{"?since=yesterday-ish", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		vh.ListIngestRuns(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ingest/runs"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%q: status = %d, want %d", tt.query, rec.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Runs []models.IngestRun `json:"runs"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0, len(resp.Runs))
+		for _, run := range resp.Runs {
+			got = append(got, run.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: runs = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: runs = %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
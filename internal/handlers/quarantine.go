@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pborman/uuid"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+)
+
+// batchSource is the source of the entries quarantined by the batch endpoint
+const batchSource = "batch"
+
+// QuarantineResponse is the body of GET /api/v1/admin/quarantine
+type QuarantineResponse struct {
+	Entries []quarantine.Entry `json:"entries"`
+	Stats   quarantine.Stats   `json:"stats"`
+}
+
+// QuarantineRetryRequest is the body of POST /api/v1/admin/quarantine/retry
+type QuarantineRetryRequest struct {
+	IDs    []string `json:"ids,omitempty"`    // Entries to retry, every entry when empty
+	Reason string   `json:"reason,omitempty"` // Only retry the entries quarantined for this reason
+}
+
+// QuarantineRetryResponse reports a retry: the entries stored are removed from
+// the quarantine, the others stay quarantined and are listed with the retry error
+type QuarantineRetryResponse struct {
+	Retried int                 `json:"retried"`
+	Stored  int                 `json:"stored"`
+	Failed  []QuarantineFailure `json:"failed,omitempty"`
+}
+
+// QuarantineFailure is an entry a retry could not store
+type QuarantineFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// SetQuarantine sets whether the batch endpoint quarantines the vectors that fail
+// validation and stores the others, instead of rejecting the batch
+func (vh *VectorHandler) SetQuarantine(enabled bool) {
+	vh.quarantine = enabled
+}
+
+// quarantineStore returns the quarantine of the backend, answering 501 when it has none
+func (vh *VectorHandler) quarantineStore(w http.ResponseWriter) (storage.QuarantineStore, bool) {
+	qs, ok := vh.storage.(storage.QuarantineStore)
+	if !ok {
+		http.Error(w, "storage backend does not support a quarantine", http.StatusNotImplemented)
+	}
+	return qs, ok
+}
+
+// ListQuarantine handles GET /api/v1/admin/quarantine?reason=&limit=, listing the
+// quarantined records oldest first with the size of the quarantine
+func (vh *VectorHandler) ListQuarantine(w http.ResponseWriter, r *http.Request) {
+	qs, ok := vh.quarantineStore(w)
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	reason := r.URL.Query().Get("reason")
+	entries := make([]quarantine.Entry, 0)
+	for _, entry := range qs.Quarantined() {
+		if reason != "" && entry.Reason != reason {
+			continue
+		}
+		if limit > 0 && len(entries) == limit {
+			break
+		}
+		entries = append(entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(QuarantineResponse{Entries: entries, Stats: qs.QuarantineStats()})
+}
+
+// RetryQuarantine handles POST /api/v1/admin/quarantine/retry, storing quarantined
+// records again once their problem is fixed, with the current embedder of their
+// namespace for the records of ingests
+func (vh *VectorHandler) RetryQuarantine(w http.ResponseWriter, r *http.Request) {
+	qs, ok := vh.quarantineStore(w)
+	if !ok {
+		return
+	}
+
+	var req QuarantineRetryRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	selected := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		selected[id] = true
+	}
+	var entries []quarantine.Entry
+	for _, entry := range qs.Quarantined() {
+		if (len(selected) == 0 || selected[entry.ID]) && (req.Reason == "" || entry.Reason == req.Reason) {
+			delete(selected, entry.ID)
+			entries = append(entries, entry)
+		}
+	}
+	for id := range selected {
+		writeStoreError(w, quarantine.NotFound(id))
+		return
+	}
+
+	resp := QuarantineRetryResponse{Retried: len(entries)}
+	var stored []*models.Vector
+	var storedIDs []string
+	for _, entry := range entries {
+		vector, err := vh.retryEntry(entry)
+		if err != nil {
+			resp.Failed = append(resp.Failed, QuarantineFailure{ID: entry.ID, Error: err.Error()})
+			continue
+		}
+		stored = append(stored, vector)
+		storedIDs = append(storedIDs, entry.ID)
+	}
+	if len(storedIDs) > 0 {
+		if err := qs.RemoveQuarantined(storedIDs...); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		vh.evaluateWatches(stored)
+	}
+	resp.Stored = len(stored)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// retryEntry decodes a quarantined record, embeds it when it came from an ingest,
+// and stores it
+func (vh *VectorHandler) retryEntry(entry quarantine.Entry) (*models.Vector, error) {
+	var vector *models.Vector
+	switch entry.Kind {
+	case quarantine.KindVector:
+		var input models.VectorInput
+		if err := json.Unmarshal(entry.Payload, &input); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		v, err := input.ToVector()
+		if err != nil {
+			return nil, err
+		}
+		vector = v
+	case quarantine.KindRecord:
+		var record quarantine.Record
+		if err := json.Unmarshal(entry.Payload, &record); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		v, err := vh.embedRecord(entry.Namespace, record)
+		if err != nil {
+			return nil, err
+		}
+		vector = v
+	default:
+		return nil, fmt.Errorf("unknown quarantined payload kind %q", entry.Kind)
+	}
+
+	if err := vector.Validate(); err != nil {
+		return nil, err
+	}
+	if err := vh.normalizeMetadata(vector); err != nil {
+		return nil, err
+	}
+	if err := vh.storage.Store(vector); err != nil {
+		return nil, err
+	}
+	return vector, nil
+}
+
+// embedRecord embeds a quarantined ingest record with the embedder of its namespace,
+// as an image when the record is one and the embedder can embed images
+func (vh *VectorHandler) embedRecord(namespace string, record quarantine.Record) (*models.Vector, error) {
+	if record.Text == "" {
+		return nil, fmt.Errorf("record has no text to embed")
+	}
+	embedder := vh.embedderFor(namespace)
+
+	var embedding []float64
+	var err error
+	if record.Metadata["type"] == "image" {
+		imageEmbedder, ok := embedder.(interface {
+			EmbedImage(string) ([]float64, error)
+		})
+		if !ok {
+			return nil, fmt.Errorf("embedder %s does not support images", embedder.Name())
+		}
+		embedding, err = imageEmbedder.EmbedImage(record.Text)
+	} else {
+		embedding, err = embedder.Embed(record.Text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed record: %w", err)
+	}
+
+	metadata := record.Metadata
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata[models.EmbedderNameKey] = embedder.Name()
+	id := record.ID
+	if id == "" {
+		id = uuid.New()
+	}
+	return &models.Vector{ID: id, Embedding: embedding, Metadata: metadata}, nil
+}
+
+// splitBatch converts and validates the vectors of a batch like StoreVectorBatch,
+// returning the entries to quarantine for those failing instead of an error
+func (vh *VectorHandler) splitBatch(inputs []models.VectorInput, allowEmpty bool) ([]*models.Vector, []quarantine.Entry, error) {
+	vectors := make([]*models.Vector, 0, len(inputs))
+	var entries []quarantine.Entry
+	seen := make(map[string]bool, len(inputs))
+	for i := range inputs {
+		vector, reason, err := vh.batchVector(&inputs[i], allowEmpty)
+		if err == nil && seen[vector.ID] {
+			reason, err = "duplicate_id", fmt.Errorf("duplicate id %s in batch", vector.ID)
+		}
+		if err != nil {
+			entry, qerr := quarantine.NewEntry(quarantine.KindVector, batchSource, reason, err, inputs[i])
+			if qerr != nil {
+				return nil, nil, qerr
+			}
+			entry.ID, entry.Namespace = uuid.New(), inputs[i].Metadata[models.NamespaceKey]
+			entries = append(entries, entry)
+			continue
+		}
+		seen[vector.ID] = true
+		vectors = append(vectors, vector)
+	}
+	return vectors, entries, nil
+}
+
+// batchVector converts and validates a vector of a batch, returning the reason it
+// is quarantined for when it fails
+func (vh *VectorHandler) batchVector(input *models.VectorInput, allowEmpty bool) (*models.Vector, string, error) {
+	vector, err := input.ToVector()
+	if err != nil {
+		return nil, "invalid_embedding", err
+	}
+	validate := vector.Validate
+	if allowEmpty {
+		validate = vector.ValidatePending
+	}
+	if err := validate(); err != nil {
+		if errors.Is(err, models.ErrDimensionTooLarge) {
+			return nil, "dimension_too_large", err
+		}
+		return nil, "invalid_vector", err
+	}
+	if !vector.HasEmbedding() {
+		vector.DType = ""
+	}
+	if err := vh.normalizeMetadata(vector); err != nil {
+		if errors.Is(err, defaults.ErrLocked) {
+			return nil, "locked_metadata", err
+		}
+		return nil, "key_collision", err
+	}
+	return vector, "", nil
+}
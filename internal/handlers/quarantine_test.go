@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+)
+
+func TestQuarantine_BatchAndRetry(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, fixedEmbedder{1, 0})
+	vh.SetQuarantine(true)
+
+	rec := httptest.NewRecorder()
+	body := `{"vectors":[{"id":"good","embedding":[1,0]},{"id":"empty"},{"id":"good","embedding":[0,1]}]}`
+	vh.StoreVectorBatch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/batch", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("batch: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var batch BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &batch); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if batch.Stored != 1 || len(batch.Quarantined) != 2 || store.Count() != 1 {
+		t.Fatalf("batch = %+v with %d stored, want 1 stored and 2 quarantined", batch, store.Count())
+	}
+
+	// An ingest record quarantined for a failed embedding
+	entry, err := quarantine.NewEntry(quarantine.KindRecord, "reviews.jsonl", "embed_error", nil, quarantine.Record{ID: "review-1", Text: "great"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Quarantine(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.ListQuarantine(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/quarantine?reason=duplicate_id", nil))
+	var listed QuarantineResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to decode quarantine: %v", err)
+	}
+	if len(listed.Entries) != 1 || listed.Entries[0].Source != batchSource || listed.Stats.Entries != 3 {
+		t.Errorf("quarantine = %+v, want the duplicate of 3 entries", listed)
+	}
+	if listed.Stats.ByReason["invalid_vector"] != 1 || listed.Stats.ByReason["embed_error"] != 1 {
+		t.Errorf("by reason = %v", listed.Stats.ByReason)
+	}
+
+	retry := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		vh.RetryQuarantine(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/quarantine/retry", bytes.NewBufferString(body)))
+		return rec
+	}
+	if rec := retry(`{"ids":["missing"]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("retry of an unknown entry: status = %d, want 404", rec.Code)
+	}
+
+	rec = retry(`{"reason":"embed_error"}`)
+	var retried QuarantineRetryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &retried); err != nil {
+		t.Fatalf("failed to decode retry: %v", err)
+	}
+	if retried.Retried != 1 || retried.Stored != 1 {
+		t.Errorf("retry = %+v, want the record stored", retried)
+	}
+	if v, err := store.Get("review-1"); err != nil || v.Embedding[0] != 1 {
+		t.Errorf("retried record = %+v, %v", v, err)
+	}
+
+	// The vector without an embedding fails again and stays quarantined
+	rec = retry("")
+	retried = QuarantineRetryResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &retried); err != nil {
+		t.Fatalf("failed to decode retry: %v", err)
+	}
+	if retried.Retried != 2 || retried.Stored != 1 || len(retried.Failed) != 1 {
+		t.Errorf("retry = %+v, want the duplicate stored and the empty vector failed", retried)
+	}
+	if entries := store.Quarantined(); len(entries) != 1 || entries[0].Reason != "invalid_vector" {
+		t.Errorf("quarantine after retry = %+v", entries)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+)
+
+// quotaRequest is the body of PUT /api/v1/admin/quotas
+// The namespace is part of the body so the default, empty namespace can be limited too
+type quotaRequest struct {
+	Namespace string `json:"namespace"`
+	quota.Limit
+}
+
+// GetQuotas handles GET /api/v1/admin/quotas, listing the limit and usage of every namespace
+func (vh *VectorHandler) GetQuotas(w http.ResponseWriter, r *http.Request) {
+	qm, ok := vh.storage.(storage.QuotaManager)
+	if !ok {
+		http.Error(w, "storage backend does not support quotas", http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quota.Report(qm.Quotas(), qm.QuotaUsage()))
+}
+
+// SetQuota handles PUT /api/v1/admin/quotas, setting the limit of a namespace
+// A limit with no max_vectors or max_bytes removes the quota
+func (vh *VectorHandler) SetQuota(w http.ResponseWriter, r *http.Request) {
+	qm, ok := vh.storage.(storage.QuotaManager)
+	if !ok {
+		http.Error(w, "storage backend does not support quotas", http.StatusNotImplemented)
+		return
+	}
+
+	var req quotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := qm.SetQuota(req.Namespace, req.Limit); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	status := quota.Status{Namespace: req.Namespace, Usage: qm.QuotaUsage()[req.Namespace]}
+	if !req.Limit.IsZero() {
+		status.Limit = &req.Limit
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestQuotaEndpoints(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+
+	rec := httptest.NewRecorder()
+	vh.SetQuota(rec, httptest.NewRequest(http.MethodPut, "/api/v1/admin/quotas", bytes.NewBufferString(`{"namespace":"team","max_vectors":1}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("set quota status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	create := func(id string) *httptest.ResponseRecorder {
+		body := `{"id":"` + id + `","embedding":[1,0],"metadata":{"namespace":"team"}}`
+		rec := httptest.NewRecorder()
+		vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	if rec := create("a"); rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = create("b")
+	if rec.Code != http.StatusInsufficientStorage {
+		t.Fatalf("create over quota status = %d, want 507", rec.Code)
+	}
+	var rejected struct {
+		Quota struct {
+			Namespace string `json:"namespace"`
+			Resource  string `json:"resource"`
+			Limit     int64  `json:"limit"`
+		} `json:"quota"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &rejected); err != nil {
+		t.Fatalf("failed to decode quota error: %v", err)
+	}
+	if rejected.Quota.Namespace != "team" || rejected.Quota.Resource != "vectors" || rejected.Quota.Limit != 1 {
+		t.Errorf("quota error = %+v", rejected.Quota)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.CountVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/count?namespace=team", nil))
+	var count map[string]int
+	if err := json.Unmarshal(rec.Body.Bytes(), &count); err != nil {
+		t.Fatalf("failed to decode count: %v", err)
+	}
+	if count["count"] != 1 || count["max_vectors"] != 1 || count["used_bytes"] != 16 {
+		t.Errorf("count response = %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	vh.SetQuota(rec, httptest.NewRequest(http.MethodPut, "/api/v1/admin/quotas", bytes.NewBufferString(`{"namespace":"team","max_vectors":-1}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("negative quota status = %d, want 422", rec.Code)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+const (
+	defaultRecentLimit = 50
+	maxRecentLimit     = 1000
+)
+
+// ListRecentVectors handles GET /api/v1/vectors/recent?limit=&namespace=&return_embedding=&metadata_fields=
+// It returns the newest vectors, newest first by creation time, projected like queryProjection
+func (vh *VectorHandler) ListRecentVectors(w http.ResponseWriter, r *http.Request) {
+	vh.setGeneration(w)
+	query := r.URL.Query()
+
+	limit := defaultRecentLimit
+	if l := query.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 || parsed > maxRecentLimit {
+			http.Error(w, fmt.Sprintf("limit must be an integer between 1 and %d", maxRecentLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	projection, err := queryProjection(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vectors, err := storage.Recent(vh.storage, vh.resolveNamespace(query.Get("namespace")), limit)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	for i, vector := range vectors {
+		vectors[i] = projection.responseVector(vector)
+	}
+
+	writeCacheableJSON(w, r, vectors)
+}
+
+// splitFields splits a comma separated list of field names, dropping empty ones
+func splitFields(list string) []string {
+	fields := make([]string, 0)
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
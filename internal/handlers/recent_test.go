@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestListRecentVectors(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+
+	for _, v := range []struct{ id, namespace string }{
+		{"a", "x"}, {"b", "y"}, {"c", "x"}, {"d", "y"}, {"e", "x"},
+	} {
+		vector := &models.Vector{
+			ID:        v.id,
+			Embedding: []float64{1, 0},
+			Metadata:  map[string]string{models.NamespaceKey: v.namespace, "title": v.id, "body": "text"},
+		}
+		if err := store.Store(vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	// Storing a vector again keeps its creation time and place
+	stored, _ := store.Get("a")
+	if err := store.Store(&models.Vector{ID: "a", Embedding: []float64{0, 1}, Metadata: map[string]string{models.NamespaceKey: "x"}, CreatedAt: stored.CreatedAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(query string) ([]*models.Vector, *httptest.ResponseRecorder) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		vh.ListRecentVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/recent"+query, nil))
+		var vectors []*models.Vector
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&vectors); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return vectors, rec
+	}
+	ids := func(vectors []*models.Vector) []string {
+		out := make([]string, len(vectors))
+		for i, v := range vectors {
+			out[i] = v.ID
+		}
+		return out
+	}
+
+	vectors, _ := list("")
+	if got, want := ids(vectors), []string{"e", "d", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("recent = %v, want %v", got, want)
+	}
+	if len(vectors[0].Embedding) != 2 || vectors[0].Metadata["title"] != "e" {
+		t.Errorf("default listing dropped the embedding or metadata: %+v", vectors[0])
+	}
+
+	vectors, _ = list("?namespace=x&limit=1")
+	if got, want := ids(vectors), []string{"e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recent in x = %v, want %v", got, want)
+	}
+
+	vectors, _ = list("?include_embedding=false&metadata_fields=title")
+	for _, v := range vectors {
+		if v.Embedding != nil {
+			t.Errorf("%s: embedding returned with include_embedding=false", v.ID)
+		}
+		if len(v.Metadata) > 1 || (v.ID != "a" && v.Metadata["title"] != v.ID) {
+			t.Errorf("%s: metadata = %v, want title only", v.ID, v.Metadata)
+		}
+	}
+	if stored, _ := store.Get("e"); stored.Embedding == nil || stored.Metadata["body"] == "" {
+		t.Error("projection modified the stored vector")
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=5000", "?include_embedding=maybe"} {
+		if _, rec := list(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
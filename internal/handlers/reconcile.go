@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+// reconciler is implemented by storage backends backed by files that can drift from their index
+type reconciler interface {
+	Reconcile(opts local.ReconcileOptions) (*local.ReconcileReport, error)
+}
+
+// flusher is implemented by storage backends that can buffer writes
+type flusher interface {
+	Flush() error
+}
+
+// ReconcileStorage handles POST /api/v1/admin/reconcile?prune=&dry_run=
+// The storage index is re-synced with the files on disk
+func (vh *VectorHandler) ReconcileStorage(w http.ResponseWriter, r *http.Request) {
+	rec, ok := vh.storage.(reconciler)
+	if !ok {
+		http.Error(w, "storage backend does not support reconcile", http.StatusNotImplemented)
+		return
+	}
+
+	var opts local.ReconcileOptions
+	for name, target := range map[string]*bool{"prune": &opts.Prune, "dry_run": &opts.DryRun} {
+		if value := r.URL.Query().Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "invalid "+name+" value", http.StatusBadRequest)
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	report, err := rec.Reconcile(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// FlushStorage handles POST /api/v1/admin/flush
+// Writes buffered by the storage are flushed to disk before it returns
+func (vh *VectorHandler) FlushStorage(w http.ResponseWriter, r *http.Request) {
+	f, ok := vh.storage.(flusher)
+	if !ok {
+		http.Error(w, "storage backend does not support flush", http.StatusNotImplemented)
+		return
+	}
+
+	if err := f.Flush(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
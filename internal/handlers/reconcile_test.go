@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestReconcileStorage(t *testing.T) {
+	adapter, err := local.NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	vh := NewVectorHandler(adapter, hash.NewHashEmbedder())
+
+	rec := httptest.NewRecorder()
+	vh.ReconcileStorage(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/reconcile?dry_run=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var report local.ReconcileReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if !report.DryRun || len(report.Collections) != 1 || report.Collections[0].Collection != "vectors" {
+		t.Errorf("report = %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.ReconcileStorage(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/reconcile?prune=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid prune: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestReconcileStorageUnsupported(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+
+	rec := httptest.NewRecorder()
+	vh.ReconcileStorage(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/reconcile", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", rec.Code)
+	}
+}
+
+func TestVerifyStorage(t *testing.T) {
+	adapter, err := local.NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	vh := NewVectorHandler(adapter, hash.NewHashEmbedder())
+
+	rec := httptest.NewRecorder()
+	vh.VerifyStorage(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/verify?sample=0.5", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var report local.VerifyReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if !report.OK() || report.Collections != 1 || report.Sample != 0.5 {
+		t.Errorf("report = %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.VerifyStorage(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/verify?sample=2", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid sample: status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder()).VerifyStorage(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/verify", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("memory backend: status = %d, want 501", rec.Code)
+	}
+}
+
+func TestFlushStorage(t *testing.T) {
+	adapter, err := local.NewVectorStorageAdapterWithOptions(t.TempDir(), "vectors", nil, local.Options{Durability: local.DurabilityBatched})
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	defer adapter.Close()
+	vh := NewVectorHandler(adapter, hash.NewHashEmbedder())
+
+	rec := httptest.NewRecorder()
+	vh.FlushStorage(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/flush", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if durability := adapter.GetStats()["durability"].(map[string]interface{}); durability["pending_ops"] != 0 {
+		t.Errorf("durability stats after flush = %v", durability)
+	}
+
+	vh = NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+	rec = httptest.NewRecorder()
+	vh.FlushStorage(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/flush", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("memory storage: status = %d, want 501", rec.Code)
+	}
+}
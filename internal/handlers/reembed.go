@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tahcohcat/same-same/internal/jobs"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+// ReembedJobType is the type of the jobs embedding stored vectors again from their text
+const ReembedJobType = "reembed"
+
+const (
+	DefaultReembedBatchSize = 100
+	MaxReembedBatchSize     = 10000
+)
+
+// ReembedRequest selects the vectors a reembed job embeds again from their "text"
+// metadata with the current embedder of their namespace, after the embedder changed
+// Without namespace nor filters every vector is embedded again
+type ReembedRequest struct {
+	Namespace string         `json:"namespace,omitempty"`
+	Filters   models.Filters `json:"filters,omitempty"`
+	BatchSize int            `json:"batch_size,omitempty"` // Vectors stored at a time, DefaultReembedBatchSize when unset
+}
+
+// Validate checks the request and fills the batch size default
+func (r *ReembedRequest) Validate() error {
+	if _, err := models.NewFilterEvaluator().Compile(r.Filters); err != nil {
+		return err
+	}
+	if r.BatchSize < 0 || r.BatchSize > MaxReembedBatchSize {
+		return fmt.Errorf("batch_size must be between 1 and %d", MaxReembedBatchSize)
+	}
+	if r.BatchSize == 0 {
+		r.BatchSize = DefaultReembedBatchSize
+	}
+	return nil
+}
+
+// reembedJobHandler runs the jobs of ReembedJobType
+type reembedJobHandler struct {
+	vh *VectorHandler
+}
+
+// Validate checks a reembed request, filling its defaults
+func (h reembedJobHandler) Validate(params json.RawMessage) (json.RawMessage, error) {
+	var req ReembedRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid reembed request: %w", err)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(req)
+}
+
+// Run embeds the targets of the job after its checkpoint a batch at a time,
+// checkpointing after each stored batch. Vectors without text, or whose text
+// fails to embed, are counted as failures and keep their embedding
+func (h reembedJobHandler) Run(ctx context.Context, run *jobs.Run) error {
+	vh := h.vh
+	var req ReembedRequest
+	if err := run.Params(&req); err != nil {
+		return err
+	}
+	ids, err := vh.jobTargets(req.Namespace, req.Filters, run.Checkpoint())
+	if err != nil {
+		return err
+	}
+	run.Update(run.Checkpoint(), func(p *jobs.Progress) { p.Total = p.Processed + len(ids) })
+
+	evaluator := &models.FilterEvaluator{KeyFallback: vh.keyFallback.Load()}
+	filters, err := evaluator.Compile(req.Filters)
+	if err != nil {
+		return err
+	}
+
+	for done := 0; done < len(ids); {
+		end := done + req.BatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[done:end]
+		done = end
+
+		var embedded []*models.Vector
+		var failures []error
+		for _, id := range batch {
+			vector, err := vh.storage.Get(id)
+			if err != nil {
+				if errors.Is(err, storage.ErrNotFound) {
+					continue
+				}
+				return err
+			}
+			// Vectors changed since the targets were listed are skipped
+			if !search.MatchesNamespace(vector.Metadata, req.Namespace) || !evaluator.Matches(vector.Metadata, filters) {
+				continue
+			}
+			text := vector.Metadata["text"]
+			if text == "" {
+				failures = append(failures, fmt.Errorf("vector %s has no text to embed", id))
+				continue
+			}
+			updated, err := vh.embedPendingVector(vector, text)
+			if err == nil {
+				err = updated.CheckDimension()
+			}
+			if err != nil {
+				failures = append(failures, fmt.Errorf("vector %s: %w", id, err))
+				continue
+			}
+			embedded = append(embedded, updated)
+		}
+
+		if len(embedded) > 0 {
+			if err := storage.StoreBatch(vh.storage, embedded); err != nil {
+				return err
+			}
+			vh.evaluateWatches(embedded)
+		}
+		run.Update(batch[len(batch)-1], func(p *jobs.Progress) {
+			p.Processed += len(batch)
+			p.Affected += len(embedded)
+			for _, failure := range failures {
+				p.RecordError(failure)
+			}
+		})
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// ResultSetHeader returns the ID of the saved result set, for search responses that are plain lists
+const ResultSetHeader = "X-Result-Set-Id"
+
+// Defaults of ResultSetOptions fields left at zero
+const (
+	DefaultResultSetTTL  = 15 * time.Minute
+	DefaultMaxResultSets = 1000
+)
+
+// ResultSetOptions bounds the result sets saved by searches with save_results:
+// sets expire TTL after they are saved, and the oldest is dropped when more than
+// MaxSets are kept
+type ResultSetOptions struct {
+	TTL     time.Duration
+	MaxSets int
+}
+
+// withDefaults fills the fields left at zero
+func (o ResultSetOptions) withDefaults() ResultSetOptions {
+	if o.TTL <= 0 {
+		o.TTL = DefaultResultSetTTL
+	}
+	if o.MaxSets <= 0 {
+		o.MaxSets = DefaultMaxResultSets
+	}
+	return o
+}
+
+// SetResultSetOptions sets the lifetime and number of saved result sets
+// Sets saved before keep their expiry
+func (vh *VectorHandler) SetResultSetOptions(opts ResultSetOptions) {
+	vh.resultSets.setOptions(opts)
+}
+
+// errResultSetGone is matched by the errors of result sets that cannot be refined
+var errResultSetGone = errors.New("result set gone")
+
+// resultSetError explains why a result set named by within_results cannot be used
+type resultSetError struct {
+	ID     string
+	Reason string
+}
+
+func (e *resultSetError) Error() string {
+	return fmt.Sprintf("result set %s %s, run the search again with save_results", e.ID, e.Reason)
+}
+
+func (e *resultSetError) Is(target error) bool {
+	return target == errResultSetGone
+}
+
+// resultSet is the candidate IDs of a saved search
+type resultSet struct {
+	ids        []string
+	generation uint64 // Storage generation the set was saved at
+	saved      time.Time
+	expires    time.Time
+}
+
+// resultSetCache holds the result sets saved by searches, bounded in number and lifetime
+// Sets are dropped once the storage generation moves on, since their vectors may have changed
+type resultSetCache struct {
+	mu   sync.Mutex
+	sets map[string]*resultSet
+	opts ResultSetOptions
+	now  func() time.Time
+}
+
+func newResultSetCache() *resultSetCache {
+	return &resultSetCache{
+		sets: make(map[string]*resultSet),
+		opts: ResultSetOptions{}.withDefaults(),
+		now:  time.Now,
+	}
+}
+
+func (c *resultSetCache) setOptions(opts ResultSetOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.opts = opts.withDefaults()
+	c.evict(c.now())
+}
+
+// save keeps the IDs of a result list and returns the ID of the set
+func (c *resultSetCache) save(ids []string, generation uint64) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	id := uuid.New()
+	c.sets[id] = &resultSet{
+		ids:        append([]string(nil), ids...),
+		generation: generation,
+		saved:      now,
+		expires:    now.Add(c.opts.TTL),
+	}
+	c.evict(now)
+	return id
+}
+
+// candidates returns the IDs of a saved set, or a resultSetError when the set
+// expired, was dropped or saved before the storage changed
+func (c *resultSetCache) candidates(id string, generation uint64) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	set, ok := c.sets[id]
+	switch {
+	case !ok:
+		return nil, &resultSetError{ID: id, Reason: "does not exist or has expired"}
+	case !c.now().Before(set.expires):
+		delete(c.sets, id)
+		return nil, &resultSetError{ID: id, Reason: "has expired"}
+	case set.generation != generation:
+		delete(c.sets, id)
+		return nil, &resultSetError{ID: id, Reason: "is stale, the storage changed since it was saved"}
+	}
+	return set.ids, nil
+}
+
+// evict drops the expired sets and the oldest ones beyond the maximum, caller must hold the lock
+func (c *resultSetCache) evict(now time.Time) {
+	for id, set := range c.sets {
+		if !now.Before(set.expires) {
+			delete(c.sets, id)
+		}
+	}
+	for len(c.sets) > c.opts.MaxSets {
+		oldest := ""
+		for id, set := range c.sets {
+			if oldest == "" || set.saved.Before(c.sets[oldest].saved) {
+				oldest = id
+			}
+		}
+		delete(c.sets, oldest)
+	}
+}
+
+// withinResults resolves the within_results option of a query to the candidate IDs
+// the search is restricted to, nil when the query is not restricted
+func (vh *VectorHandler) withinResults(q *searchQuery, generation uint64) ([]string, error) {
+	if q.WithinResults == "" {
+		return nil, nil
+	}
+	return vh.resultSets.candidates(q.WithinResults, generation)
+}
+
+// saveResults saves the IDs of results when the query sets save_results
+// The generation is read before the search, so a write racing with it makes the set stale
+func (vh *VectorHandler) saveResults(q *searchQuery, generation uint64, ids []string) {
+	if q.SaveResults {
+		q.resultSetID = vh.resultSets.save(ids, generation)
+	}
+}
+
+// setResultSetHeader returns the ID of the result set saved by a search in the ResultSetHeader
+// Searches of a snapshot name it in the SnapshotHeader, replacing the live generation with its own
+func setResultSetHeader(w http.ResponseWriter, q *searchQuery) {
+	if q.resultSetID != "" {
+		w.Header().Set(ResultSetHeader, q.resultSetID)
+	}
+	if q.Snapshot != "" {
+		w.Header().Set(SnapshotHeader, q.Snapshot)
+		w.Header().Del(GenerationHeader)
+		if q.snapshotGeneration != 0 {
+			w.Header().Set(GenerationHeader, strconv.FormatUint(q.snapshotGeneration, 10))
+		}
+	}
+}
+
+// inCandidates reports whether vector is one of candidates, always true when the search is not restricted
+// Backends honor the candidates themselves, this guards against one that does not
+func inCandidates(candidates map[string]bool, vector *models.Vector) bool {
+	return candidates == nil || candidates[vector.ID]
+}
+
+// candidateSet returns the set of candidate IDs, nil when the search is not restricted
+func candidateSet(ids []string) map[string]bool {
+	if ids == nil {
+		return nil
+	}
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// writeSearchError reports a failed search, 410 Gone for result sets that cannot be refined
+// and 404 for unknown snapshots
+func writeSearchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errSnapshotsDisabled):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case errors.Is(err, errEmbedderForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		writeStoreError(w, err)
+		return
+	case !errors.Is(err, errResultSetGone):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGone)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "code": "result_set_gone"})
+}
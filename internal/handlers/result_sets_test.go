@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestWithinResults(t *testing.T) {
+	for _, endpoint := range searchEndpoints {
+		t.Run(endpoint.name, func(t *testing.T) {
+			vh := newSearchTestHandler(t)
+			search := func(text string, options map[string]interface{}) *httptest.ResponseRecorder {
+				body := endpoint.query(vh, text)
+				for key, value := range options {
+					body[key] = value
+				}
+				payload, _ := json.Marshal(body)
+				rec := httptest.NewRecorder()
+				endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+				return rec
+			}
+
+			// A broad search saves its two best results
+			rec := search("quick brown fox", map[string]interface{}{"top_k": 2, "save_results": true})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("save: status = %d: %s", rec.Code, rec.Body.String())
+			}
+			id := rec.Header().Get(ResultSetHeader)
+			if id == "" {
+				t.Fatal("no result set ID returned")
+			}
+			if endpoint.name != "vectors/search" && !strings.Contains(rec.Body.String(), `"result_set_id":"`+id+`"`) {
+				t.Errorf("result_set_id missing from body: %s", rec.Body.String())
+			}
+			saved := make(map[string]bool)
+			for _, hit := range endpoint.hits(t, rec.Body.Bytes()) {
+				saved[hit.ID] = true
+			}
+
+			// Refining with another query, filters and a larger top_k never leaves the set
+			for _, options := range []map[string]interface{}{
+				{"top_k": 10},
+				{"top_k": 10, "filters": map[string]interface{}{"category": map[string]interface{}{"eq": "b"}}},
+			} {
+				options["within_results"] = id
+				rec := search("slow green turtle", options)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("refine: status = %d: %s", rec.Code, rec.Body.String())
+				}
+				hits := endpoint.hits(t, rec.Body.Bytes())
+				if len(hits) == 0 {
+					t.Errorf("refine %v: no results", options)
+				}
+				for _, hit := range hits {
+					if !saved[hit.ID] {
+						t.Errorf("refine %v: %s is outside the saved set %v", options, hit.ID, saved)
+					}
+				}
+			}
+
+			// A write to the storage makes the set stale
+			if err := vh.storage.Store(&models.Vector{ID: "new", Embedding: []float64{1}}); err != nil {
+				t.Fatal(err)
+			}
+			if rec := search("turtle", map[string]interface{}{"within_results": id}); rec.Code != http.StatusGone || !strings.Contains(rec.Body.String(), "stale") {
+				t.Errorf("stale set: status = %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestResultSetCache_Expiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	cache := newResultSetCache()
+	cache.now = func() time.Time { return now }
+	cache.setOptions(ResultSetOptions{TTL: time.Minute, MaxSets: 2})
+
+	first := cache.save([]string{"a"}, 1)
+	if ids, err := cache.candidates(first, 1); err != nil || len(ids) != 1 {
+		t.Fatalf("candidates = %v, %v", ids, err)
+	}
+
+	now = now.Add(time.Minute)
+	_, err := cache.candidates(first, 1)
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expired set: error = %v", err)
+	}
+
+	// The oldest set is dropped beyond the maximum
+	second := cache.save([]string{"b"}, 1)
+	now = now.Add(time.Second)
+	cache.save([]string{"c"}, 1)
+	cache.save([]string{"d"}, 1)
+	if _, err := cache.candidates(second, 1); err == nil {
+		t.Error("oldest set kept beyond the maximum")
+	}
+	if len(cache.sets) != 2 {
+		t.Errorf("cache holds %d sets, want 2", len(cache.sets))
+	}
+}
+
+func TestWithinResults_Gone(t *testing.T) {
+	vh := newSearchTestHandler(t)
+	rec := httptest.NewRecorder()
+	vh.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"text": "fox", "within_results": "unknown"}`)))
+
+	if rec.Code != http.StatusGone {
+		t.Fatalf("status = %d, want 410", rec.Code)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["code"] != "result_set_gone" {
+		t.Errorf("body = %v, %v", body, err)
+	}
+}
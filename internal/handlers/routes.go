@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ReservedVectorPaths are the sub-paths of /api/v1/vectors that are never
+// matched as vector IDs, including names kept for future endpoints
+var ReservedVectorPaths = []string{"batch", "bulk-get", "by", "count", "embed", "generation", "metadata", "recent", "search"}
+
+// VectorResources lists the routes under /api/v1/vectors, returned in 404 bodies
+var VectorResources = []string{
+	"GET /api/v1/vectors",
+	"POST /api/v1/vectors",
+	"POST /api/v1/vectors/batch",
+	"POST /api/v1/vectors/bulk-get",
+	"POST /api/v1/vectors/embed",
+	"GET /api/v1/vectors/count",
+	"GET /api/v1/vectors/generation",
+	"GET /api/v1/vectors/metadata",
+	"GET /api/v1/vectors/recent",
+	"POST /api/v1/vectors/search",
+	"GET /api/v1/vectors/by/{field}/{value}",
+	"PUT /api/v1/vectors/by/{field}/{value}",
+	"GET /api/v1/vectors/{id}",
+	"PUT /api/v1/vectors/{id}",
+	"DELETE /api/v1/vectors/{id}",
+	"GET /api/v1/vectors/{id}/provenance",
+}
+
+// vectorsPath is the prefix of the vector routes
+const vectorsPath = "/api/v1/vectors"
+
+// notFoundResponse is the structured body of 404 responses for unknown routes
+type notFoundResponse struct {
+	Error     string   `json:"error"`
+	Canonical string   `json:"canonical,omitempty"` // Path without its trailing slash
+	Hint      string   `json:"hint,omitempty"`
+	Resources []string `json:"resources,omitempty"` // Valid routes, for paths under /api/v1/vectors
+}
+
+// IsReservedVectorPath reports whether segment is a reserved sub-path of /api/v1/vectors
+func IsReservedVectorPath(segment string) bool {
+	for _, reserved := range ReservedVectorPaths {
+		if segment == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+// NotFound handles requests matching no route
+// Routes are strict about trailing slashes, so a path ending in one names its
+// canonical form instead of being redirected, and paths under /api/v1/vectors
+// list the valid routes
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	resp := notFoundResponse{Error: fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path)}
+
+	path := r.URL.Path
+	if len(path) > 1 && strings.HasSuffix(path, "/") {
+		resp.Canonical = strings.TrimRight(path, "/")
+		resp.Hint = "routes do not take a trailing slash"
+	}
+	if path == vectorsPath || strings.HasPrefix(path, vectorsPath+"/") {
+		resp.Resources = VectorResources
+	}
+
+	writeNotFound(w, resp)
+}
+
+// writeVectorNotFound writes the 404 of a vector lookup
+// IDs that look like a misspelled sub-path, such as "serach", get a hint and
+// the list of valid routes, since the request was most likely not a lookup
+func writeVectorNotFound(w http.ResponseWriter, id string, err error) {
+	reserved, ok := reservedLookalike(id)
+	if !ok {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeNotFound(w, notFoundResponse{
+		Error:     err.Error(),
+		Hint:      fmt.Sprintf("%q was looked up as a vector ID, did you mean %s/%s?", id, vectorsPath, reserved),
+		Resources: VectorResources,
+	})
+}
+
+func writeNotFound(w http.ResponseWriter, resp notFoundResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// reservedLookalike returns the reserved sub-path id is closest to, if it is
+// within a typo of it: one edit for short names, two for longer ones
+func reservedLookalike(id string) (string, bool) {
+	lowered := strings.ToLower(strings.Trim(id, "/ "))
+	for _, reserved := range ReservedVectorPaths {
+		if len(reserved) < 3 {
+			continue
+		}
+		limit := 1
+		if len(reserved) > 5 {
+			limit = 2
+		}
+		if editDistance(lowered, reserved) <= limit {
+			return reserved, true
+		}
+	}
+	return "", false
+}
+
+// editDistance returns the Damerau-Levenshtein distance (optimal string
+// alignment) of a and b, so swapped letters count as one edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// GetMetadataSchema handles GET /api/v1/metadata/schema?namespace=
+// It summarizes each metadata field of the vectors of the namespace, or of all of
+// them: how many vectors have it, the type inferred from its values, example values
+// and the number of distinct values, capped so the summary stays small
+func (vh *VectorHandler) GetMetadataSchema(w http.ResponseWriter, r *http.Request) {
+	vh.setGeneration(w)
+
+	schema, err := storage.MetadataSchema(vh.storage, r.URL.Query().Get("namespace"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeCacheableJSON(w, r, schema)
+}
+
+// GetFacet handles GET /api/v1/metadata/facets/{field}?namespace=&limit=
+// It counts the vectors of each value of the field, most frequent first. Fields
+// with more distinct values than the schema tracks exactly are counted with
+// sketches, and the response marks the estimated numbers with their error bounds
+func (vh *VectorHandler) GetFacet(w http.ResponseWriter, r *http.Request) {
+	vh.setGeneration(w)
+
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	facet, err := storage.Facet(vh.storage, r.URL.Query().Get("namespace"), mux.Vars(r)["field"], limit)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeCacheableJSON(w, r, facet)
+}
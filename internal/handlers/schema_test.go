@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/metaschema"
+)
+
+func TestGetMetadataSchema(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+
+	for id, metadata := range map[string]map[string]string{
+		"a": {models.NamespaceKey: "books", "year": "1999", "genre": "sci-fi"},
+		"b": {models.NamespaceKey: "books", "year": "n/a", "genre": "sci-fi"},
+		"c": {models.NamespaceKey: "books", "year": "2005"},
+		"d": {models.NamespaceKey: "films", "year": "2010", "rating": "4.5"},
+	} {
+		if err := store.Store(&models.Vector{ID: id, Embedding: []float64{1, 0}, Metadata: metadata}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(query string) *metaschema.Schema {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		vh.GetMetadataSchema(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metadata/schema"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var schema metaschema.Schema
+		if err := json.NewDecoder(rec.Body).Decode(&schema); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return &schema
+	}
+
+	books := get("?namespace=books")
+	if books.Vectors != 3 || books.Fields["rating"] != nil {
+		t.Fatalf("books schema = %+v", books)
+	}
+	if year := books.Fields["year"]; year.Type != metaschema.TypeString || year.Types[metaschema.TypeNumeric] != 2 || year.Types[metaschema.TypeString] != 1 {
+		t.Errorf("mixed year = %+v", year)
+	}
+	if genre := books.Fields["genre"]; genre.Count != 2 || genre.Distinct != 1 || genre.Examples[0] != "sci-fi" {
+		t.Errorf("genre = %+v", genre)
+	}
+
+	// Deletes decrement the counts, and the string value gone leaves a numeric field
+	if err := store.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	books = get("?namespace=books")
+	if year := books.Fields["year"]; books.Vectors != 2 || year.Count != 2 || year.Type != metaschema.TypeNumeric {
+		t.Errorf("year after delete = %+v", year)
+	}
+	if genre := books.Fields["genre"]; genre.Count != 1 {
+		t.Errorf("genre after delete = %+v", genre)
+	}
+
+	if all := get(""); all.Vectors != 3 || all.Fields["rating"].Type != metaschema.TypeNumeric || all.Fields["year"].Count != 3 {
+		t.Errorf("schema of every namespace = %+v", all)
+	}
+}
+
+func TestGetFacet(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, hash.NewHashEmbedder())
+
+	for i := 0; i < 10; i++ {
+		genre := "sci-fi"
+		if i%3 == 0 {
+			genre = "fantasy"
+		}
+		metadata := map[string]string{models.NamespaceKey: "books", "genre": genre}
+		if err := store.Store(&models.Vector{ID: fmt.Sprint(i), Embedding: []float64{1, 0}, Metadata: metadata}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(field, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata/facets/"+field+query, nil)
+		rec := httptest.NewRecorder()
+		vh.GetFacet(rec, mux.SetURLVars(req, map[string]string{"field": field}))
+		return rec
+	}
+
+	rec := get("genre", "?namespace=books&limit=1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var facet metaschema.Facet
+	if err := json.NewDecoder(rec.Body).Decode(&facet); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if facet.Count != 10 || facet.Distinct != 2 || facet.CountsEstimated || len(facet.Values) != 1 || facet.Values[0] != (metaschema.FacetValue{Value: "sci-fi", Count: 6}) {
+		t.Errorf("facet = %+v", facet)
+	}
+
+	if rec := get("rating", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown field status = %d, want 404", rec.Code)
+	}
+	if rec := get("genre", "?limit=-1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid limit status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// scoreExprAdjuster is the name score_expr is reported under in score explanations
+const scoreExprAdjuster = "score_expr"
+
+// AddScoreAdjuster registers an adjuster applied to the results of every search,
+// after the adjusters registered before it and before the score_expr of the request
+func (vh *VectorHandler) AddScoreAdjuster(name string, adjuster models.ScoreAdjuster) error {
+	if name == "" {
+		return fmt.Errorf("score adjuster name cannot be empty")
+	}
+	if adjuster == nil {
+		return fmt.Errorf("score adjuster %s cannot be nil", name)
+	}
+	if name == scoreExprAdjuster {
+		return fmt.Errorf("score adjuster name %s is reserved for the score_expr option", name)
+	}
+	for _, a := range vh.scoreAdjusters {
+		if a.Name == name {
+			return fmt.Errorf("score adjuster %s is registered twice", name)
+		}
+	}
+	vh.scoreAdjusters = append(vh.scoreAdjusters, models.NamedAdjuster{Name: name, Adjuster: adjuster})
+	return nil
+}
+
+// scoring returns the score adjusters of q after the registered ones, nil when
+// there are none and no explanation is requested, so storage leaves scores unchanged
+func (q *searchQuery) scoring(registered []models.NamedAdjuster) *models.Scoring {
+	adjusters := registered
+	if q.scoreExpr != nil {
+		adjusters = append(append([]models.NamedAdjuster(nil), registered...), models.NamedAdjuster{Name: scoreExprAdjuster, Adjuster: q.scoreExpr})
+	}
+	if len(adjusters) == 0 && !q.Explain {
+		return nil
+	}
+
+	ctx := models.ScoreContext{Query: q.Text, Namespace: q.Namespace, Metric: q.Metric, Time: time.Now()}
+	if q.Temporal != nil && q.Temporal.ReferenceTime != nil {
+		ctx.Time = q.Temporal.ReferenceTime.Time
+	}
+	return &models.Scoring{Adjusters: adjusters, Context: ctx, Explain: q.Explain}
+}
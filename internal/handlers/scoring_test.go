@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+)
+
+func TestScoreAdjusters(t *testing.T) {
+	store := memory.NewStorage()
+	vh := NewVectorHandler(store, fixedEmbedder{1, 0})
+	for _, vector := range []*models.Vector{
+		{ID: "close", Embedding: []float64{1, 0}, Metadata: map[string]string{"popularity": "0", "text": "close"}},
+		{ID: "popular", Embedding: []float64{0.9, 0.1}, Metadata: map[string]string{"popularity": "1000", "text": "popular"}},
+	} {
+		if err := store.Store(vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.SetProfile(profile.Profile{Name: "popular", ScoreExpr: "score * (1 + 0.1*log(1+num(metadata.popularity)))"}); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(handler http.HandlerFunc, body string) (int, []models.SearchResult) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", bytes.NewBufferString(body)))
+		var resp struct {
+			Matches []models.SearchResult `json:"matches"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return rec.Code, resp.Matches
+	}
+
+	// Without adjusters the closest vector wins
+	if _, results := search(vh.SearchByText, `{"text": "q", "top_k": 1}`); len(results) != 1 || results[0].Vector.ID != "close" || results[0].Explanation != nil {
+		t.Fatalf("plain search: results = %+v", results)
+	}
+
+	// The profile expression is applied before the results are cut to top_k
+	_, results := search(vh.SearchByText, `{"text": "q", "top_k": 1, "profile": "popular", "explain": true}`)
+	if len(results) != 1 || results[0].Vector.ID != "popular" {
+		t.Fatalf("profile expression: results = %+v, want popular", results)
+	}
+	explanation := results[0].Explanation
+	if explanation == nil || len(explanation.Adjustments) != 1 || explanation.Adjustments[0].Adjuster != "score_expr" ||
+		explanation.BaseScore >= explanation.Score || explanation.Score != results[0].Score {
+		t.Errorf("explanation = %+v", explanation)
+	}
+
+	// Registered adjusters run before the request expression
+	if err := vh.AddScoreAdjuster("demote_popular", models.ScoreAdjusterFunc(func(score float64, vector *models.Vector, _ *models.ScoreContext) float64 {
+		if vector.ID == "popular" {
+			return score / 10
+		}
+		return score
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := vh.AddScoreAdjuster("score_expr", models.ScoreAdjusterFunc(nil)); err == nil {
+		t.Errorf("reserved adjuster name accepted")
+	}
+	_, results = search(vh.SearchByText, `{"text": "q", "profile": "popular", "explain": true}`)
+	if len(results) != 2 || results[0].Vector.ID != "close" {
+		t.Fatalf("registered adjuster: results = %+v, want close first", results)
+	}
+	if adjustments := results[1].Explanation.Adjustments; len(adjustments) != 2 || adjustments[0].Adjuster != "demote_popular" {
+		t.Errorf("adjustments = %+v, want demote_popular then score_expr", adjustments)
+	}
+
+	// Invalid expressions are rejected before searching
+	if code, _ := search(vh.SearchByText, `{"text": "q", "score_expr": "score * popularity"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid score_expr: status = %d, want 400", code)
+	}
+}
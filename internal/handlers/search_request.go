@@ -0,0 +1,644 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+	"github.com/tahcohcat/same-same/internal/tracing"
+)
+
+// defaultTopK is the number of results returned when a request sets none
+const defaultTopK = 10
+
+// searchQuery is the canonical form of every search request
+// Each endpoint decodes its own request shape and converts it with a thin
+// adapter, so shared options are validated and applied in one place
+type searchQuery struct {
+	Text      string               // Query text, embedded when Embedding is empty
+	Embedding []float64            // Query embedding, takes precedence over Text
+	Sparse    *models.SparseVector // Sparse query embedding, takes precedence over Text
+	TopK      int
+	Namespace string
+	Filters   models.Filters
+	Options   *models.SearchOptions
+
+	// FilterMode "soft" makes the filters preferences, SoftPenalty is the score lost
+	// for missing every soft filter, nil for the default
+	FilterMode  string
+	SoftPenalty *float64
+
+	MinScore        *float64
+	ReturnEmbedding bool
+	MetadataFields  []string // nil returns all metadata
+	KeyFallback     *bool    // nil uses the handler default
+	Metric          string   // Empty uses the storage default
+	Profile         string   // Ranking profile applied to the request, reported in the response
+
+	// Precision and EmbeddingFormat override the handler response format
+	Precision       *int
+	EmbeddingFormat string
+
+	Highlight        bool
+	HighlightOptions *models.HighlightOptions
+
+	// SaveResults saves the result IDs as a result set, WithinResults restricts
+	// the search to a saved one
+	SaveResults   bool
+	WithinResults string
+
+	// ScoreExpr adjusts the scores after the registered adjusters, Explain reports
+	// the adjustments with each result
+	ScoreExpr string
+	Explain   bool
+
+	// EnrichBy names the metadata field keying the enrichment document of each result
+	EnrichBy string
+
+	// Snapshot names the snapshot searched instead of the live data
+	Snapshot string
+
+	// Embedder names the override embedder of the query text, for admin requests
+	Embedder string
+
+	// Fuzzy matches misspelled query terms with lexical embedders, nil for the default
+	// of true; corrections are the matches made, reported in the response meta
+	Fuzzy       *bool
+	corrections []embedders.Correction
+
+	// Anchor is the time relative times of the request are resolved against,
+	// as_of or the time the request was received
+	Anchor time.Time
+
+	// Temporal holds the decay settings of temporal searches
+	Temporal *models.TemporalSearchRequest
+	// ageLocale formats the age of temporal results, from the Accept-Language header
+	ageLocale string
+
+	filters      *models.CompiledFilters // Compiled by validate
+	scoreExpr    *models.ScoreExpr       // Compiled by validate, nil without ScoreExpr
+	keyFallbacks map[string][]string     // Filter fields results matched through other keys
+
+	// embedderName is the embedder of the query text when namespaces have their own,
+	// skippedEmbedders counts the results skipped as embedded by another one
+	embedderName     string
+	skippedEmbedders map[string]int
+
+	// override is the embedder named by Embedder, resolved by embed with its meta
+	override     embedders.Embedder
+	overrideMeta *EmbedderOverrideMeta
+
+	resultSetID string // ID of the result set saved by the search
+
+	snapshotGeneration uint64 // Store generation the searched snapshot was taken at
+}
+
+// searchRequest is implemented by the request shape of each search endpoint
+type searchRequest interface {
+	Validate() error
+}
+
+// legacyFilterRequest is implemented by requests accepting the legacy metadata_filters list
+type legacyFilterRequest interface {
+	FoldMetadataFilters() error
+}
+
+// decodeSearchRequest decodes the request body into req, converts its filters
+// to the canonical form, resolves the namespace alias it names, applies the ranking
+// profile it names and the endpoint validation
+func (vh *VectorHandler) decodeSearchRequest(r *http.Request, req searchRequest) error {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return fmt.Errorf("Invalid JSON: %v", err)
+	}
+	if legacy, ok := req.(legacyFilterRequest); ok {
+		if err := legacy.FoldMetadataFilters(); err != nil {
+			return err
+		}
+	}
+	vh.applyAlias(req)
+	if err := vh.applyProfile(req); err != nil {
+		return err
+	}
+	return req.Validate()
+}
+
+// validate applies the defaults and checks shared by every search endpoint
+func (q *searchQuery) validate() error {
+	if q.Text == "" && len(q.Embedding) == 0 && q.Sparse == nil {
+		return fmt.Errorf("query text or embedding is required")
+	}
+	if q.TopK <= 0 {
+		q.TopK = defaultTopK
+	}
+	if err := q.Options.Validate(); err != nil {
+		return err
+	}
+	if q.Metric != "" {
+		if err := search.ValidateMetric(q.Metric); err != nil {
+			return err
+		}
+		if q.Temporal != nil && q.Metric != search.MetricCosine {
+			return fmt.Errorf("temporal search only supports the %s metric", search.MetricCosine)
+		}
+	}
+	if q.MinScore != nil && search.Ascending(q.Metric) {
+		return fmt.Errorf("min_score cannot be used with the %s metric", q.Metric)
+	}
+	if err := models.ValidateFilterMode(q.FilterMode); err != nil {
+		return err
+	}
+	if q.SoftPenalty != nil && *q.SoftPenalty < 0 {
+		return fmt.Errorf("soft_penalty cannot be negative")
+	}
+	if q.FilterMode == models.FilterModeSoft {
+		q.Filters = q.Filters.Soft()
+	}
+	var err error
+	if q.Filters, err = q.Filters.ResolveTimes(q.Anchor); err != nil {
+		return err
+	}
+	filters, err := models.NewFilterEvaluator().CompileSearch(q.Filters)
+	if err != nil {
+		return err
+	}
+	q.filters = filters
+	if q.ScoreExpr != "" {
+		if q.scoreExpr, err = models.CompileScoreExpr(q.ScoreExpr); err != nil {
+			return err
+		}
+	}
+	if q.Highlight && q.Text == "" {
+		return fmt.Errorf("highlight requires query text")
+	}
+	if q.Embedder != "" && (q.Text == "" || len(q.Embedding) > 0 || q.Sparse != nil) {
+		return fmt.Errorf("embedder requires a query text and no query embedding")
+	}
+	// Result sets hold live IDs and generations, which mean nothing in a snapshot
+	if q.Snapshot != "" && (q.SaveResults || q.WithinResults != "") {
+		return fmt.Errorf("snapshot cannot be combined with save_results or within_results")
+	}
+	if _, err := q.responseFormat(models.DefaultResponseFormat()); err != nil {
+		return err
+	}
+	for _, field := range q.MetadataFields {
+		if field == "" {
+			return fmt.Errorf("metadata_fields cannot contain an empty field name")
+		}
+		if field == "*" {
+			q.MetadataFields = nil
+			break
+		}
+	}
+	return nil
+}
+
+// responseFormat applies the request precision and embedding format to the handler default
+func (q *searchQuery) responseFormat(def models.ResponseFormat) (models.ResponseFormat, error) {
+	return def.WithParams(models.SearchParams{Precision: q.Precision, EmbeddingFormat: q.EmbeddingFormat})
+}
+
+// returnEmbedding resolves the return_embedding option against the endpoint default
+func returnEmbedding(params models.SearchParams, def bool) bool {
+	if params.ReturnEmbedding != nil {
+		return *params.ReturnEmbedding
+	}
+	return def
+}
+
+// embeddingQuery adapts POST /vectors/search, which returns embeddings unless disabled
+// The query embedding is normalized like stored client vectors so scores do not depend on its representation
+func embeddingQuery(req *models.SearchByEmbbedingRequest) (*searchQuery, error) {
+	embedding, err := models.NormalizeEmbedding(req.Embedding)
+	if err != nil {
+		return nil, err
+	}
+	var sparse *models.SparseVector
+	if req.Sparse != nil {
+		if sparse, err = models.NormalizeSparse(req.Sparse); err != nil {
+			return nil, err
+		}
+	}
+
+	q := &searchQuery{
+		Embedding:       embedding,
+		Sparse:          sparse,
+		TopK:            req.TopK,
+		Namespace:       req.Namespace,
+		Filters:         req.Filters,
+		Options:         req.Options,
+		FilterMode:      req.FilterMode,
+		SoftPenalty:     req.SoftPenalty,
+		MinScore:        req.MinScore,
+		ReturnEmbedding: returnEmbedding(req.SearchParams, true),
+		MetadataFields:  req.MetadataFields,
+		KeyFallback:     req.KeyFallback,
+		Metric:          req.Metric,
+		Profile:         req.Profile,
+		Precision:       req.Precision,
+		EmbeddingFormat: req.EmbeddingFormat,
+		SaveResults:     req.SaveResults,
+		WithinResults:   req.WithinResults,
+		ScoreExpr:       req.ScoreExpr,
+		Explain:         req.Explain,
+		EnrichBy:        req.EnrichBy,
+		Snapshot:        req.Snapshot,
+		Embedder:        req.Embedder,
+		Anchor:          req.SearchParams.Anchor(time.Now()),
+	}
+	return q, q.validate()
+}
+
+// textQuery adapts POST /search with a text query
+func textQuery(req *models.SearchByTextRequest) (*searchQuery, error) {
+	q := &searchQuery{
+		Text:             req.Text,
+		TopK:             req.TopK,
+		Namespace:        req.Namespace,
+		Filters:          req.Filters,
+		Options:          req.Options,
+		FilterMode:       req.FilterMode,
+		SoftPenalty:      req.SoftPenalty,
+		MinScore:         req.MinScore,
+		ReturnEmbedding:  returnEmbedding(req.SearchParams, false),
+		MetadataFields:   req.MetadataFields,
+		KeyFallback:      req.KeyFallback,
+		Metric:           req.Metric,
+		Profile:          req.Profile,
+		Precision:        req.Precision,
+		EmbeddingFormat:  req.EmbeddingFormat,
+		Highlight:        req.Highlight,
+		HighlightOptions: req.HighlightOptions,
+		SaveResults:      req.SaveResults,
+		WithinResults:    req.WithinResults,
+		ScoreExpr:        req.ScoreExpr,
+		Explain:          req.Explain,
+		EnrichBy:         req.EnrichBy,
+		Snapshot:         req.Snapshot,
+		Embedder:         req.Embedder,
+		Fuzzy:            req.Fuzzy,
+		Anchor:           req.SearchParams.Anchor(time.Now()),
+	}
+	return q, q.validate()
+}
+
+// advancedQuery adapts the advanced search request shape
+func advancedQuery(req *models.AdvancedSearchRequest) (*searchQuery, error) {
+	q := &searchQuery{
+		Text:             req.Query,
+		TopK:             req.TopK,
+		Namespace:        req.Namespace,
+		Filters:          req.Filters,
+		Options:          req.Options,
+		FilterMode:       req.FilterMode,
+		SoftPenalty:      req.SoftPenalty,
+		MinScore:         req.MinScore,
+		ReturnEmbedding:  returnEmbedding(req.SearchParams, false),
+		MetadataFields:   req.MetadataFields,
+		KeyFallback:      req.KeyFallback,
+		Metric:           req.Metric,
+		Profile:          req.Profile,
+		Precision:        req.Precision,
+		EmbeddingFormat:  req.EmbeddingFormat,
+		Highlight:        req.Highlight,
+		HighlightOptions: req.HighlightOptions,
+		SaveResults:      req.SaveResults,
+		WithinResults:    req.WithinResults,
+		ScoreExpr:        req.ScoreExpr,
+		Explain:          req.Explain,
+		EnrichBy:         req.EnrichBy,
+		Snapshot:         req.Snapshot,
+		Embedder:         req.Embedder,
+		Fuzzy:            req.Fuzzy,
+		Anchor:           req.SearchParams.Anchor(time.Now()),
+	}
+	return q, q.validate()
+}
+
+// temporalQuery adapts POST /search/temporal
+// The reference time defaults to the request anchor so the response can report the
+// time decay was computed from; relative reference and default times are resolved against it
+func temporalQuery(req *models.TemporalSearchRequest) (*searchQuery, error) {
+	anchor := req.SearchParams.Anchor(time.Now())
+	if req.ReferenceTime == nil {
+		req.ReferenceTime = models.NewTimeExpr(anchor)
+	} else if err := req.ReferenceTime.Resolve(anchor); err != nil {
+		return nil, fmt.Errorf("reference_time: %w", err)
+	}
+	if req.DefaultTime != nil {
+		if err := req.DefaultTime.Resolve(anchor); err != nil {
+			return nil, fmt.Errorf("default_time: %w", err)
+		}
+	}
+
+	q := &searchQuery{
+		Text:             req.Query,
+		TopK:             req.TopK,
+		Namespace:        req.Namespace,
+		Filters:          req.Filters,
+		Options:          req.Options,
+		FilterMode:       req.FilterMode,
+		SoftPenalty:      req.SoftPenalty,
+		MinScore:         req.MinScore,
+		ReturnEmbedding:  returnEmbedding(req.SearchParams, false),
+		MetadataFields:   req.MetadataFields,
+		KeyFallback:      req.KeyFallback,
+		Metric:           req.Metric,
+		Profile:          req.Profile,
+		Precision:        req.Precision,
+		EmbeddingFormat:  req.EmbeddingFormat,
+		Highlight:        req.Highlight,
+		HighlightOptions: req.HighlightOptions,
+		SaveResults:      req.SaveResults,
+		WithinResults:    req.WithinResults,
+		ScoreExpr:        req.ScoreExpr,
+		Explain:          req.Explain,
+		EnrichBy:         req.EnrichBy,
+		Snapshot:         req.Snapshot,
+		Embedder:         req.Embedder,
+		Fuzzy:            req.Fuzzy,
+		Temporal:         req,
+		Anchor:           anchor,
+	}
+	return q, q.validate()
+}
+
+// embed returns the dense or sparse query embedding, embedding the query text if needed
+// Text is embedded as a sparse vector when sparse embeddings are enabled and supported
+func (vh *VectorHandler) embed(ctx context.Context, q *searchQuery) (embedding []float64, sparse *models.SparseVector, err error) {
+	if q.Sparse != nil {
+		return nil, q.Sparse, nil
+	}
+	if len(q.Embedding) > 0 {
+		return q.Embedding, nil, nil
+	}
+	embedder := vh.embedderFor(q.Namespace)
+	if q.Embedder != "" {
+		if embedder, err = vh.overrideEmbedder(ctx, q.Embedder); err != nil {
+			return nil, nil, err
+		}
+		q.override = embedder
+		q.overrideMeta = &EmbedderOverrideMeta{Name: q.Embedder, Type: embedder.Name()}
+		// Stored vectors were embedded by other embedders, so they are compared
+		// by dimension instead of skipped by provenance
+	} else if len(vh.namespaceEmbedders) > 0 {
+		q.embedderName = embedder.Name()
+	}
+
+	_, span := tracing.StartEmbed(ctx, spanEmbedQuery, embedder)
+	defer func() { tracing.End(span, err) }()
+
+	fuzzy := q.Fuzzy == nil || *q.Fuzzy
+	if vh.sparse {
+		var ok bool
+		if fuzzy {
+			sparse, q.corrections, ok, err = embedders.EmbedSparseQueryFuzzy(embedder, q.Text)
+		} else {
+			sparse, ok, err = embedders.EmbedSparseQuery(embedder, q.Text)
+		}
+		if ok {
+			return nil, sparse, err
+		}
+	}
+	if fuzzy {
+		embedding, q.corrections, err = embedders.EmbedQueryFuzzy(embedder, q.Text)
+	} else {
+		embedding, err = embedders.EmbedQuery(embedder, q.Text)
+	}
+	if err != nil || q.overrideMeta == nil {
+		return embedding, nil, err
+	}
+	q.overrideMeta.Dimension = len(embedding)
+	q.overrideMeta.Incompatible, err = vh.countIncompatible(q.Namespace, len(embedding))
+	return embedding, nil, err
+}
+
+// search runs a canonical query and applies the shared result options
+func (vh *VectorHandler) search(ctx context.Context, q *searchQuery) ([]*models.SearchResult, error) {
+	store, err := vh.searchStore(q)
+	if err != nil {
+		return nil, err
+	}
+	embedding, sparse, err := vh.embed(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	generation, _ := storage.Generation(vh.storage)
+	candidates, err := vh.withinResults(q, generation)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFallback := vh.keyFallbackEnabled(q)
+	span := startSearchSpan(ctx, q)
+	results, err := store.AdvancedSearch(&models.AdvancedSearchRequest{
+		Query:        q.Text,
+		TopK:         q.TopK,
+		Namespace:    q.Namespace,
+		Filters:      q.Filters,
+		Options:      q.Options,
+		SearchParams: models.SearchParams{KeyFallback: &keyFallback, Metric: q.Metric, SoftPenalty: q.SoftPenalty},
+		SparseQuery:  sparse,
+		Candidates:   candidates,
+		Scoring:      q.scoring(vh.scoreAdjusters),
+	}, embedding)
+	endSearchSpan(span, len(results), err)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := q.responseFormat(vh.format)
+	if err != nil {
+		return nil, err
+	}
+
+	var h *highlighter
+	if q.Highlight {
+		h = newHighlighter(vh.queryEmbedder(q), q.Text, q.HighlightOptions)
+	}
+
+	within := candidateSet(candidates)
+	kept := make([]*models.SearchResult, 0, len(results))
+	ids := make([]string, 0, len(results))
+	stored := make([]*models.Vector, 0, len(results))
+	for _, result := range results {
+		if !q.keepScore(result.Score) || q.skipIncompatible(result.Vector) || !inCandidates(within, result.Vector) {
+			continue
+		}
+		if keyFallback {
+			q.recordKeyFallbacks(result.Vector)
+		}
+		// Highlights are taken from the stored vector, so the highlighted field need not be projected
+		kept = append(kept, &models.SearchResult{
+			Vector:      q.responseVector(result.Vector),
+			Score:       result.Score,
+			Highlights:  h.HighlightVector(result.Vector),
+			Explanation: result.Explanation,
+			Format:      &format,
+		})
+		ids = append(ids, result.Vector.ID)
+		stored = append(stored, result.Vector)
+	}
+
+	// The enrich_by field is read from the stored vectors, so it need not be projected
+	enriched, err := vh.enrich(q, stored)
+	if err != nil {
+		return nil, err
+	}
+	for i, doc := range enriched {
+		kept[i].Enrichment = doc
+	}
+	vh.saveResults(q, generation, ids)
+
+	return kept, nil
+}
+
+// temporalSearch runs a canonical temporal query and applies the shared result options
+func (vh *VectorHandler) temporalSearch(ctx context.Context, q *searchQuery) ([]*models.TemporalSearchResult, error) {
+	store, err := vh.searchStore(q)
+	if err != nil {
+		return nil, err
+	}
+	embedding, sparse, err := vh.embed(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	generation, _ := storage.Generation(vh.storage)
+	candidates, err := vh.withinResults(q, generation)
+	if err != nil {
+		return nil, err
+	}
+
+	req := *q.Temporal
+	req.SparseQuery = sparse
+	req.Candidates = candidates
+	req.Scoring = q.scoring(vh.scoreAdjusters)
+	req.TopK = q.TopK
+	req.Namespace = q.Namespace
+	req.Filters = q.Filters
+	req.Options = q.Options
+	req.SoftPenalty = q.SoftPenalty
+	keyFallback := vh.keyFallbackEnabled(q)
+	req.KeyFallback = &keyFallback
+
+	span := startSearchSpan(ctx, q)
+	results, err := store.TemporalSearch(&req, embedding)
+	endSearchSpan(span, len(results), err)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := q.responseFormat(vh.format)
+	if err != nil {
+		return nil, err
+	}
+
+	var h *highlighter
+	if q.Highlight {
+		h = newHighlighter(vh.queryEmbedder(q), q.Text, q.HighlightOptions)
+	}
+
+	within := candidateSet(candidates)
+	kept := make([]*models.TemporalSearchResult, 0, len(results))
+	ids := make([]string, 0, len(results))
+	stored := make([]*models.Vector, 0, len(results))
+	for _, result := range results {
+		if !q.keepScore(result.Score) || q.skipIncompatible(result.Vector) || !inCandidates(within, result.Vector) {
+			continue
+		}
+		if keyFallback {
+			q.recordKeyFallbacks(result.Vector)
+		}
+		copied := *result
+		copied.Vector = q.responseVector(result.Vector)
+		copied.Highlights = h.HighlightVector(result.Vector)
+		copied.Age = q.age(result)
+		copied.Format = &format
+		kept = append(kept, &copied)
+		ids = append(ids, result.Vector.ID)
+		stored = append(stored, result.Vector)
+	}
+
+	enriched, err := vh.enrich(q, stored)
+	if err != nil {
+		return nil, err
+	}
+	for i, doc := range enriched {
+		kept[i].Enrichment = doc
+	}
+	vh.saveResults(q, generation, ids)
+
+	return kept, nil
+}
+
+// keepScore reports whether a result passes the min_score option
+func (q *searchQuery) keepScore(score float64) bool {
+	return q.MinScore == nil || score >= *q.MinScore
+}
+
+// responseVector returns the vector to include in a response, applying return_embedding
+// and metadata_fields
+// Stored vectors are copied rather than modified, since storage may return its own pointers
+func (q *searchQuery) responseVector(vector *models.Vector) *models.Vector {
+	if vector == nil || (q.ReturnEmbedding && q.MetadataFields == nil) {
+		return vector
+	}
+	copied := *vector
+	if !q.ReturnEmbedding {
+		copied.Embedding = nil
+		copied.Sparse = nil
+	}
+	if q.MetadataFields != nil {
+		copied.Metadata = projectMetadata(vector.Metadata, q.MetadataFields)
+	}
+	return &copied
+}
+
+// queryProjection returns the projection of the return_embedding and metadata_fields
+// query parameters of a GET endpoint, returning embeddings unless asked not to
+// include_embedding is accepted in place of return_embedding. metadata_fields is a
+// comma separated list of the metadata keys returned, empty for none and "*" for all
+func queryProjection(r *http.Request) (*searchQuery, error) {
+	name := "return_embedding"
+	if r.URL.Query().Get(name) == "" {
+		name = "include_embedding"
+	}
+	include, set, err := parseBoolQuery(r, name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value", name)
+	}
+	projection := &searchQuery{ReturnEmbedding: include || !set}
+
+	if fields, ok := r.URL.Query()["metadata_fields"]; ok {
+		projection.MetadataFields = splitFields(strings.Join(fields, ","))
+		for _, field := range projection.MetadataFields {
+			if field == "*" {
+				projection.MetadataFields = nil
+				break
+			}
+		}
+	}
+	return projection, nil
+}
+
+// projectMetadata returns the listed keys of metadata, or nil when none of them are set
+func projectMetadata(metadata map[string]string, fields []string) map[string]string {
+	var projected map[string]string
+	for _, field := range fields {
+		if value, ok := metadata[field]; ok {
+			if projected == nil {
+				projected = make(map[string]string, len(fields))
+			}
+			projected[field] = value
+		}
+	}
+	return projected
+}
@@ -0,0 +1,847 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/tfidf"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// searchHit is the part of a search result the parity tests compare
+type searchHit struct {
+	ID           string
+	HasEmbedding bool
+}
+
+// searchEndpoint describes how to call one search endpoint and read its results
+type searchEndpoint struct {
+	name    string
+	handler func(vh *VectorHandler) http.HandlerFunc
+	query   func(vh *VectorHandler, text string) map[string]interface{}
+	hits    func(t *testing.T, body []byte) []searchHit
+}
+
+func vectorHits(results []*models.SearchResult) []searchHit {
+	hits := make([]searchHit, len(results))
+	for i, result := range results {
+		hits[i] = searchHit{ID: result.Vector.ID, HasEmbedding: len(result.Vector.Embedding) > 0}
+	}
+	return hits
+}
+
+var searchEndpoints = []searchEndpoint{
+	{
+		name:    "vectors/search",
+		handler: func(vh *VectorHandler) http.HandlerFunc { return vh.SearchVectors },
+		query: func(vh *VectorHandler, text string) map[string]interface{} {
+			embedding, _ := vh.embedder.Embed(text)
+			return map[string]interface{}{"embedding": embedding}
+		},
+		hits: func(t *testing.T, body []byte) []searchHit {
+			var results []*models.SearchResult
+			if err := json.Unmarshal(body, &results); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			return vectorHits(results)
+		},
+	},
+	{
+		name:    "search (text)",
+		handler: func(vh *VectorHandler) http.HandlerFunc { return vh.SearchByText },
+		query: func(vh *VectorHandler, text string) map[string]interface{} {
+			return map[string]interface{}{"text": text}
+		},
+		hits: func(t *testing.T, body []byte) []searchHit {
+			var resp struct {
+				Matches []*models.SearchResult `json:"matches"`
+			}
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			return vectorHits(resp.Matches)
+		},
+	},
+	{
+		name:    "search (advanced)",
+		handler: func(vh *VectorHandler) http.HandlerFunc { return vh.AdvancedSearch },
+		query: func(vh *VectorHandler, text string) map[string]interface{} {
+			return map[string]interface{}{"query": text}
+		},
+		hits: func(t *testing.T, body []byte) []searchHit {
+			var resp AdvancedSearchResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			hits := make([]searchHit, len(resp.Results))
+			for i, result := range resp.Results {
+				hits[i] = searchHit{ID: result.ID, HasEmbedding: len(result.Embedding) > 0}
+			}
+			return hits
+		},
+	},
+	{
+		name:    "search/temporal",
+		handler: func(vh *VectorHandler) http.HandlerFunc { return vh.TemporalSearch },
+		query: func(vh *VectorHandler, text string) map[string]interface{} {
+			return map[string]interface{}{"query": text}
+		},
+		hits: func(t *testing.T, body []byte) []searchHit {
+			var resp struct {
+				Results []*models.TemporalSearchResult `json:"results"`
+			}
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			hits := make([]searchHit, len(resp.Results))
+			for i, result := range resp.Results {
+				hits[i] = searchHit{ID: result.Vector.ID, HasEmbedding: len(result.Vector.Embedding) > 0}
+			}
+			return hits
+		},
+	},
+}
+
+func newSearchTestHandler(t *testing.T) *VectorHandler {
+	t.Helper()
+
+	store := memory.NewStorage()
+	embedder := hash.NewHashEmbedder()
+
+	docs := []struct {
+		id, text, category, namespace string
+	}{
+		{"fox", "the quick brown fox", "a", "one"},
+		{"dogs", "quick brown dogs", "b", "one"},
+		{"turtle", "slow green turtle", "b", "two"},
+	}
+	for _, doc := range docs {
+		embedding, _ := embedder.Embed(doc.text)
+		err := store.Store(&models.Vector{
+			ID:        doc.id,
+			Embedding: embedding,
+			Metadata: map[string]string{
+				"text":              doc.text,
+				"category":          doc.category,
+				models.NamespaceKey: doc.namespace,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to store %s: %v", doc.id, err)
+		}
+	}
+
+	return NewVectorHandler(store, embedder)
+}
+
+func TestSearchOptionParity(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		want    []string
+		// embeddings is the expected presence of embeddings, nil to skip the check
+		embeddings *bool
+	}{
+		{name: "top_k", options: map[string]interface{}{"top_k": 1}, want: []string{"fox"}},
+		{name: "min_score", options: map[string]interface{}{"min_score": 0.99}, want: []string{"fox"}},
+		{name: "namespace", options: map[string]interface{}{"namespace": "one"}, want: []string{"dogs", "fox"}},
+		{
+			name:    "filters",
+			options: map[string]interface{}{"filters": map[string]interface{}{"category": map[string]interface{}{"eq": "b"}}},
+			want:    []string{"dogs", "turtle"},
+		},
+		{
+			name:    "legacy filters",
+			options: map[string]interface{}{"filters": []map[string]interface{}{{"field": "category", "operator": "=", "value": "b"}}},
+			want:    []string{"dogs", "turtle"},
+		},
+		{
+			name:    "legacy metadata_filters",
+			options: map[string]interface{}{"metadata_filters": []map[string]interface{}{{"field": "category", "operator": "in", "value": []string{"b"}}}},
+			want:    []string{"dogs", "turtle"},
+		},
+		{
+			name:    "deprecated operator spelling",
+			options: map[string]interface{}{"filters": map[string]interface{}{"category": map[string]interface{}{"!=": "a"}}},
+			want:    []string{"dogs", "turtle"},
+		},
+		{
+			name: "both filter forms",
+			options: map[string]interface{}{
+				"filters":          map[string]interface{}{"category": map[string]interface{}{"eq": "b"}},
+				"metadata_filters": []map[string]interface{}{{"field": "namespace", "operator": "not_in", "value": []string{"one"}}},
+			},
+			want: []string{"turtle"},
+		},
+		{
+			name:       "return_embedding",
+			options:    map[string]interface{}{"return_embedding": true},
+			want:       []string{"dogs", "fox", "turtle"},
+			embeddings: boolPtr(true),
+		},
+		{
+			name:       "no return_embedding",
+			options:    map[string]interface{}{"return_embedding": false},
+			want:       []string{"dogs", "fox", "turtle"},
+			embeddings: boolPtr(false),
+		},
+	}
+
+	for _, endpoint := range searchEndpoints {
+		for _, tt := range tests {
+			t.Run(endpoint.name+"/"+tt.name, func(t *testing.T) {
+				vh := newSearchTestHandler(t)
+
+				body := endpoint.query(vh, "the quick brown fox")
+				for key, value := range tt.options {
+					body[key] = value
+				}
+				payload, _ := json.Marshal(body)
+
+				rec := httptest.NewRecorder()
+				endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+				}
+
+				hits := endpoint.hits(t, rec.Body.Bytes())
+				ids := make([]string, len(hits))
+				for i, hit := range hits {
+					ids[i] = hit.ID
+					if tt.embeddings != nil && hit.HasEmbedding != *tt.embeddings {
+						t.Errorf("%s: expected embedding presence %v", hit.ID, *tt.embeddings)
+					}
+				}
+				sort.Strings(ids)
+
+				if len(ids) != len(tt.want) {
+					t.Fatalf("expected %v, got %v", tt.want, ids)
+				}
+				for i := range ids {
+					if ids[i] != tt.want[i] {
+						t.Fatalf("expected %v, got %v", tt.want, ids)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSearch_DoesNotStripStoredEmbeddings(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	payload, _ := json.Marshal(map[string]interface{}{"text": "quick fox"})
+	rec := httptest.NewRecorder()
+	vh.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	stored, err := vh.storage.Get("fox")
+	if err != nil {
+		t.Fatalf("failed to get vector: %v", err)
+	}
+	if len(stored.Embedding) == 0 {
+		t.Error("search removed the embedding of the stored vector")
+	}
+}
+
+func TestSearch_InvalidFiltersRejected(t *testing.T) {
+	invalid := map[string]map[string]interface{}{
+		"unknown list operator":   {"filters": []map[string]interface{}{{"field": "category", "operator": "~", "value": "b"}}},
+		"unknown object operator": {"filters": map[string]interface{}{"category": map[string]interface{}{"like": "b"}}},
+		"unknown metadata_filters operator": {
+			"metadata_filters": []map[string]interface{}{{"field": "category", "operator": "like", "value": "b"}},
+		},
+		"in without a list":        {"filters": map[string]interface{}{"category": map[string]interface{}{"in": "b"}}},
+		"unknown filter_mode":      {"filter_mode": "loose"},
+		"soft modifier not a bool": {"filters": map[string]interface{}{"category": map[string]interface{}{"eq": "b", "soft": "yes"}}},
+		"negative soft_penalty":    {"filter_mode": "soft", "soft_penalty": -1},
+		"conflicting forms": {
+			"filters":          map[string]interface{}{"category": map[string]interface{}{"eq": "b"}},
+			"metadata_filters": []map[string]interface{}{{"field": "category", "operator": "=", "value": "a"}},
+		},
+	}
+
+	for _, endpoint := range searchEndpoints {
+		for name, options := range invalid {
+			t.Run(endpoint.name+"/"+name, func(t *testing.T) {
+				vh := newSearchTestHandler(t)
+
+				body := endpoint.query(vh, "fox")
+				for key, value := range options {
+					body[key] = value
+				}
+				payload, _ := json.Marshal(body)
+
+				rec := httptest.NewRecorder()
+				endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+				if rec.Code != http.StatusBadRequest {
+					t.Errorf("expected status 400, got %d", rec.Code)
+				}
+			})
+		}
+	}
+}
+
+// collectFields gathers every value stored under key anywhere in a decoded JSON document
+func collectFields(value interface{}, key string, found *[]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if k == key {
+				*found = append(*found, child)
+			}
+			collectFields(child, key, found)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectFields(child, key, found)
+		}
+	}
+}
+
+func TestSearch_ResponseFormat(t *testing.T) {
+	for _, endpoint := range searchEndpoints {
+		t.Run(endpoint.name, func(t *testing.T) {
+			vh := newSearchTestHandler(t)
+
+			body := endpoint.query(vh, "the quick brown fox")
+			body["precision"] = 2
+			body["embedding_format"] = "base64"
+			body["return_embedding"] = true
+			payload, _ := json.Marshal(body)
+
+			rec := httptest.NewRecorder()
+			endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var decoded interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			var scores, embeddings []interface{}
+			collectFields(decoded, "score", &scores)
+			collectFields(decoded, "embedding", &embeddings)
+			if len(scores) == 0 || len(embeddings) != len(scores) {
+				t.Fatalf("got %d scores and %d embeddings", len(scores), len(embeddings))
+			}
+
+			for _, score := range scores {
+				value := score.(float64)
+				if value != models.Round(value, 2) {
+					t.Errorf("score %v has more than 2 decimal places", value)
+				}
+			}
+			for _, embedding := range embeddings {
+				encoded, ok := embedding.(string)
+				if !ok {
+					t.Fatalf("embedding is %T, want a base64 string", embedding)
+				}
+				vector, err := models.DecodeEmbeddingBase64(encoded)
+				if err != nil || len(vector) != hash.DefaultDimension {
+					t.Errorf("decoded %d components (%v), want %d", len(vector), err, hash.DefaultDimension)
+				}
+			}
+
+			// Stored embeddings keep full precision
+			stored, _ := vh.storage.Get("fox")
+			expected, _ := vh.embedder.Embed("the quick brown fox")
+			if stored.Embedding[0] != expected[0] {
+				t.Error("formatting modified the stored embedding")
+			}
+		})
+	}
+}
+
+func TestSearch_InvalidResponseFormatRejected(t *testing.T) {
+	for _, endpoint := range searchEndpoints {
+		for name, option := range map[string]map[string]interface{}{
+			"precision":        {"precision": 20},
+			"embedding_format": {"embedding_format": "hex"},
+		} {
+			t.Run(endpoint.name+"/"+name, func(t *testing.T) {
+				vh := newSearchTestHandler(t)
+
+				body := endpoint.query(vh, "fox")
+				for key, value := range option {
+					body[key] = value
+				}
+				payload, _ := json.Marshal(body)
+
+				rec := httptest.NewRecorder()
+				endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+				if rec.Code != http.StatusBadRequest {
+					t.Errorf("expected status 400, got %d", rec.Code)
+				}
+			})
+		}
+	}
+}
+
+func TestSearch_MetadataFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   interface{}
+		included []string
+		excluded []string
+	}{
+		{name: "projected", fields: []string{"text"}, included: []string{"text"}, excluded: []string{"category", "namespace"}},
+		{name: "none", fields: []string{}, excluded: []string{"text", "category", "namespace"}},
+		{name: "wildcard", fields: []string{"*"}, included: []string{"text"}},
+		{name: "omitted", fields: nil, included: []string{"text"}},
+	}
+
+	for _, endpoint := range searchEndpoints {
+		for _, tt := range tests {
+			t.Run(endpoint.name+"/"+tt.name, func(t *testing.T) {
+				vh := newSearchTestHandler(t)
+
+				// The filtered field is not projected back but still applies
+				body := endpoint.query(vh, "quick brown")
+				body["filters"] = map[string]interface{}{"category": map[string]interface{}{"eq": "b"}}
+				body["return_embedding"] = true
+				if tt.fields != nil {
+					body["metadata_fields"] = tt.fields
+				}
+				payload, _ := json.Marshal(body)
+
+				rec := httptest.NewRecorder()
+				endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+				}
+
+				hits := endpoint.hits(t, rec.Body.Bytes())
+				if len(hits) != 2 {
+					t.Fatalf("expected the 2 vectors of category b, got %v", hits)
+				}
+				for _, hit := range hits {
+					if !hit.HasEmbedding {
+						t.Errorf("%s: projection dropped the embedding", hit.ID)
+					}
+				}
+
+				var decoded interface{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				for _, field := range tt.included {
+					var found []interface{}
+					collectFields(decoded, field, &found)
+					if len(found) != len(hits) {
+						t.Errorf("expected %q in every result, found it %d times", field, len(found))
+					}
+				}
+				for _, field := range tt.excluded {
+					var found []interface{}
+					collectFields(decoded, field, &found)
+					if len(found) != 0 {
+						t.Errorf("expected no %q field, found %v", field, found)
+					}
+				}
+
+				// Stored metadata is not modified by the projection
+				stored, _ := vh.storage.Get("dogs")
+				if stored.Metadata["category"] != "b" {
+					t.Error("projection modified the stored metadata")
+				}
+			})
+		}
+	}
+}
+
+func TestSearch_HighlightUnprojectedField(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	payload, _ := json.Marshal(map[string]interface{}{"text": "fox", "highlight": true, "metadata_fields": []string{}})
+	rec := httptest.NewRecorder()
+	vh.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+
+	var resp struct {
+		Matches []*models.SearchResult `json:"matches"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Matches) == 0 || len(resp.Matches[0].Highlights) == 0 {
+		t.Fatalf("expected highlights without projected text: %s", rec.Body.String())
+	}
+	if resp.Matches[0].Vector.Metadata != nil {
+		t.Errorf("expected no metadata, got %v", resp.Matches[0].Vector.Metadata)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestSearch_SoftFilters(t *testing.T) {
+	quotes := []struct {
+		id, text, author, category string
+	}{
+		{"einstein-1", "imagination is more important than knowledge", "Einstein", "science"},
+		{"einstein-2", "life is like riding a bicycle", "Einstein", "life"},
+		{"einstein-3", "the important thing is not to stop questioning", "Einstein", "science"},
+		{"newton-1", "if I have seen further it is by standing on the shoulders of giants", "Newton", "science"},
+		{"curie-1", "nothing in life is to be feared, it is only to be understood", "Curie", "life"},
+		{"twain-1", "the secret of getting ahead is getting started", "Twain", "life"},
+		{"wilde-1", "be yourself, everyone else is already taken", "Wilde", "life"},
+	}
+	newHandler := func(t *testing.T) *VectorHandler {
+		store := memory.NewStorage()
+		embedder := hash.NewHashEmbedder()
+		for _, quote := range quotes {
+			embedding, _ := embedder.Embed(quote.text)
+			err := store.Store(&models.Vector{
+				ID:        quote.id,
+				Embedding: embedding,
+				Metadata:  map[string]string{"text": quote.text, "author": quote.author, "category": quote.category},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return NewVectorHandler(store, embedder)
+	}
+
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		want    int
+		first   []string // the IDs expected, in any order, at the top of the results
+	}{
+		{
+			name:    "filter_mode soft",
+			options: map[string]interface{}{"filter_mode": "soft", "filters": map[string]interface{}{"author": map[string]interface{}{"eq": "Einstein"}}},
+			want:    5,
+			first:   []string{"einstein-1", "einstein-2", "einstein-3"},
+		},
+		{
+			name:    "soft modifier",
+			options: map[string]interface{}{"filters": map[string]interface{}{"author": map[string]interface{}{"eq": "Einstein", "soft": true}}},
+			want:    5,
+			first:   []string{"einstein-1", "einstein-2", "einstein-3"},
+		},
+		{
+			name: "hard and soft filters",
+			options: map[string]interface{}{"filters": map[string]interface{}{
+				"category": map[string]interface{}{"eq": "life"},
+				"author":   map[string]interface{}{"eq": "Einstein", "soft": true},
+			}},
+			want:  4,
+			first: []string{"einstein-2"},
+		},
+		{
+			name: "explicit hard filter in soft mode",
+			options: map[string]interface{}{"filter_mode": "soft", "filters": map[string]interface{}{
+				"category": map[string]interface{}{"eq": "science", "soft": false},
+				"author":   map[string]interface{}{"eq": "Einstein"},
+			}},
+			want:  3,
+			first: []string{"einstein-1", "einstein-3"},
+		},
+	}
+
+	for _, endpoint := range searchEndpoints {
+		for _, tt := range tests {
+			t.Run(endpoint.name+"/"+tt.name, func(t *testing.T) {
+				vh := newHandler(t)
+
+				body := endpoint.query(vh, "standing on the shoulders of giants")
+				body["top_k"] = 5
+				for key, value := range tt.options {
+					body[key] = value
+				}
+				payload, _ := json.Marshal(body)
+
+				rec := httptest.NewRecorder()
+				endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+				}
+
+				hits := endpoint.hits(t, rec.Body.Bytes())
+				if len(hits) != tt.want {
+					t.Fatalf("expected %d results, got %v", tt.want, hits)
+				}
+				top := make([]string, len(tt.first))
+				for i := range tt.first {
+					top[i] = hits[i].ID
+				}
+				sort.Strings(top)
+				for i := range top {
+					if top[i] != tt.first[i] {
+						t.Fatalf("expected %v first, got %v", tt.first, hits)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSearch_SoftFilterExplanation(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	payload := `{"query": "slow green turtle", "explain": true, "soft_penalty": 2, "filters": {"category": {"eq": "a", "soft": true}}}`
+	rec := httptest.NewRecorder()
+	vh.AdvancedSearch(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(payload)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp AdvancedSearchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 3 || resp.Results[0].ID != "fox" {
+		t.Fatalf("results = %+v, want fox first", resp.Results)
+	}
+	for _, result := range resp.Results {
+		match := result.Explanation.SoftFilters
+		if match == nil {
+			t.Fatalf("%s: no soft filter report", result.ID)
+		}
+		satisfied := len(match.Satisfied) == 1 && match.Satisfied[0] == "category"
+		if satisfied != (result.ID == "fox") {
+			t.Errorf("%s: soft filters = %+v", result.ID, match)
+		}
+	}
+}
+
+func TestSearch_RelativeTimeFilters(t *testing.T) {
+	store := memory.NewStorage()
+	embedder := hash.NewHashEmbedder()
+	for id, published := range map[string]string{"old": "2024-01-15T00:00:00Z", "recent": "2024-03-10T00:00:00Z", "future": "2024-04-01T00:00:00Z"} {
+		embedding, _ := embedder.Embed("news from " + id)
+		if err := store.Store(&models.Vector{ID: id, Embedding: embedding, Metadata: map[string]string{"published": published}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vh := NewVectorHandler(store, embedder)
+
+	for _, endpoint := range searchEndpoints {
+		t.Run(endpoint.name, func(t *testing.T) {
+			search := func(filters map[string]interface{}) *httptest.ResponseRecorder {
+				body := endpoint.query(vh, "news")
+				body["as_of"] = "2024-03-13T12:00:00Z"
+				body["filters"] = filters
+				payload, _ := json.Marshal(body)
+				rec := httptest.NewRecorder()
+				endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+				return rec
+			}
+
+			// Anchored at as_of, the last 30 days up to now is only the recent vector
+			rec := search(map[string]interface{}{"published": map[string]interface{}{"after": "now - 30d", "before": "now"}})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if hits := endpoint.hits(t, rec.Body.Bytes()); len(hits) != 1 || hits[0].ID != "recent" {
+				t.Errorf("expected only recent, got %v", hits)
+			}
+
+			rec = search(map[string]interface{}{"published": map[string]interface{}{"after": "now-30q"}})
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"now-30q"`) {
+				t.Errorf("bad expression: status = %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestTemporalSearch_RelativeReferenceTime(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	search := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		vh.TemporalSearch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search/temporal", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	rec := search(`{"query": "fox", "reference_time": "now-1d", "as_of": "2024-03-13T12:00:00Z"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, time.March, 12, 12, 0, 0, 0, time.UTC); !resp.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", resp.Timestamp, want)
+	}
+
+	// Without as_of the reference time is relative to the request
+	before := time.Now()
+	rec = search(`{"query": "fox", "reference_time": "start_of_day"}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if resp.Timestamp.After(before) || before.Sub(resp.Timestamp) > 24*time.Hour {
+		t.Errorf("start_of_day = %v, requested at %v", resp.Timestamp, before)
+	}
+
+	for _, body := range []string{
+		`{"query": "fox", "reference_time": "now-1fortnight"}`,
+		`{"query": "fox", "default_time": "tomorrow"}`,
+	} {
+		rec = search(body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid time expression") {
+			t.Errorf("%s: status = %d: %s", body, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestAdvancedSearch_IncludeMetadata(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	search := func(body string) []AdvancedSearchResult {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		vh.AdvancedSearch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Results []AdvancedSearchResult `json:"results"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.Results
+	}
+
+	for _, result := range search(`{"query": "quick brown"}`) {
+		if result.Metadata != nil || result.Embedding != nil {
+			t.Errorf("%s: metadata %v and embedding returned by default", result.ID, result.Metadata)
+		}
+	}
+
+	results := search(`{"query": "quick brown", "include_metadata": true, "return_embedding": true, "metadata_fields": ["category"]}`)
+	if len(results) == 0 {
+		t.Fatal("expected results")
+	}
+	for _, result := range results {
+		if len(result.Metadata) != 1 || result.Metadata["category"] == nil {
+			t.Errorf("%s: metadata = %v, want category only", result.ID, result.Metadata)
+		}
+		if len(result.Embedding) == 0 {
+			t.Errorf("%s: return_embedding did not return the embedding", result.ID)
+		}
+	}
+}
+
+func TestGetVector_ReturnEmbedding(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	get := func(query string) (*httptest.ResponseRecorder, models.Vector) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/vectors/fox"+query, nil), map[string]string{"id": "fox"})
+		vh.GetVector(rec, req)
+		var vector models.Vector
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &vector); err != nil {
+				t.Fatalf("failed to decode vector: %v", err)
+			}
+		}
+		return rec, vector
+	}
+
+	if _, vector := get(""); len(vector.Embedding) == 0 || len(vector.Metadata) != 3 {
+		t.Errorf("default = %+v, want the embedding and all metadata", vector)
+	}
+	if _, vector := get("?return_embedding=false&metadata_fields=text"); vector.Embedding != nil || len(vector.Metadata) != 1 {
+		t.Errorf("trimmed = %+v, want the text metadata only", vector)
+	}
+	if rec, _ := get("?return_embedding=maybe"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid return_embedding: status = %d, want 400", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	vh.ListVectors(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors?return_embedding=false", nil))
+	var vectors []models.Vector
+	if err := json.Unmarshal(rec.Body.Bytes(), &vectors); err != nil {
+		t.Fatalf("failed to decode vectors: %v", err)
+	}
+	for _, vector := range vectors {
+		if vector.Embedding != nil {
+			t.Errorf("%s: listed with its embedding", vector.ID)
+		}
+	}
+	if stored, _ := vh.storage.Get("fox"); len(stored.Embedding) == 0 {
+		t.Error("trimming modified the stored vector")
+	}
+}
+
+func TestSearch_FuzzyCorrections(t *testing.T) {
+	quotes := map[string]string{
+		"relativity":  "Einstein explained relativity to a curious world",
+		"imagination": "Einstein believed imagination matters more than knowledge",
+		"bold":        "Fortune favours the bold and the brave",
+		"bird":        "The early bird catches the worm every morning",
+		"river":       "No man ever steps in the same river twice",
+	}
+	embedder := tfidf.NewTFIDFEmbedder().(*tfidf.TFIDFEmbedder)
+	corpus := make([]string, 0, len(quotes))
+	for _, text := range quotes {
+		corpus = append(corpus, text)
+	}
+	embedder.AddDocuments(corpus)
+	store := memory.NewStorage()
+	for id, text := range quotes {
+		embedding, _ := embedder.Embed(text)
+		if err := store.Store(&models.Vector{ID: id, Embedding: embedding, Metadata: map[string]string{"text": text}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vh := NewVectorHandler(store, embedder)
+
+	search := func(body string) AdvancedSearchResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		vh.AdvancedSearch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp AdvancedSearchResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	resp := search(`{"query": "Einstien", "top_k": 5}`)
+	if len(resp.Results) < 2 || resp.Results[0].Score <= 0 || resp.Results[2].Score >= resp.Results[1].Score {
+		t.Fatalf("results = %+v", resp.Results)
+	}
+	for _, result := range resp.Results[:2] {
+		if result.ID != "relativity" && result.ID != "imagination" {
+			t.Errorf("typo query ranked %s among the first results", result.ID)
+		}
+	}
+	if resp.Meta == nil || len(resp.Meta.Corrections) != 1 || resp.Meta.Corrections[0] != "einstien→einstein" {
+		t.Errorf("meta = %+v", resp.Meta)
+	}
+
+	// Exact terms are left alone, and fuzzy: false disables the corrections
+	if resp := search(`{"query": "Einstein relativity"}`); resp.Meta != nil {
+		t.Errorf("exact query reported %+v", resp.Meta)
+	}
+	if resp := search(`{"query": "Einstien", "fuzzy": false}`); resp.Meta != nil {
+		t.Errorf("fuzzy: false reported %+v", resp.Meta)
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/snapshot"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// SnapshotHeader names the snapshot a search ran against
+const SnapshotHeader = "X-Snapshot"
+
+// errSnapshotsDisabled rejects snapshot requests when the server has no snapshot library
+var errSnapshotsDisabled = errors.New("named snapshots are not enabled, set SNAPSHOT_DIR")
+
+// SetSnapshotLibrary sets the named snapshots searches can be pinned to, nil disables them
+func (vh *VectorHandler) SetSnapshotLibrary(library *snapshot.Library) {
+	vh.snapshots = library
+}
+
+// ExportSnapshot handles GET /api/v1/admin/snapshot?namespace=
+// Without a namespace the whole store is exported
+func (vh *VectorHandler) ExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+
+	vectors, err := vh.storage.ListByNamespace(namespace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	snap, err := snapshot.New(namespace, vectors)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", snapshotFilename(namespace)))
+	if err := snap.Write(w); err != nil {
+		logrus.WithError(err).Error("failed to write snapshot")
+	}
+}
+
+// RestoreSnapshot handles POST /api/v1/admin/restore?namespace=&rename_namespace=
+// namespace restores only that namespace of the snapshot, rename_namespace moves
+// the restored vectors into another namespace
+func (vh *VectorHandler) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap, err := snapshot.Read(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	snap.Filter(r.URL.Query().Get("namespace"))
+	if rename := r.URL.Query().Get("rename_namespace"); rename != "" {
+		snap.Rename(rename)
+	}
+
+	if err := snap.CheckCompatible(vh.storage, vh.embedderFor(snap.Header.Namespace).Name()); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err := snap.Restore(vh.storage); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"namespace": snap.Header.Namespace,
+		"count":     len(snap.Vectors),
+	}).Info("snapshot restored")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"namespace": snap.Header.Namespace,
+		"embedder":  snap.Header.Embedder,
+		"dimension": snap.Header.Dimension,
+		"restored":  len(snap.Vectors),
+	})
+}
+
+// TakeSnapshot saves the vectors of namespace, or of every namespace, as the snapshot name
+func (vh *VectorHandler) TakeSnapshot(name, namespace string) (*snapshot.Info, error) {
+	if vh.snapshots == nil {
+		return nil, errSnapshotsDisabled
+	}
+	if err := snapshot.ValidateName(name); err != nil {
+		return nil, fmt.Errorf("%w: %v", storage.ErrValidation, err)
+	}
+
+	// Read before listing, so a write racing the snapshot leaves it an older generation
+	generation, _ := storage.Generation(vh.storage)
+	vectors, err := vh.storage.ListByNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := snapshot.New(namespace, vectors)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", storage.ErrValidation, err)
+	}
+	snap.Header.Generation = generation
+
+	info, err := vh.snapshots.Save(name, snap)
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{
+		"snapshot":   name,
+		"namespace":  namespace,
+		"count":      info.Count,
+		"generation": generation,
+	}).Info("snapshot saved")
+	return info, nil
+}
+
+// CreateSnapshot handles POST /api/v1/admin/snapshots, saving the live data as a named snapshot
+// that searches can be pinned to. The body is {"name": ..., "namespace": ...}
+func (vh *VectorHandler) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	info, err := vh.TakeSnapshot(req.Name, req.Namespace)
+	if errors.Is(err, errSnapshotsDisabled) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/admin/snapshots/"+info.Name)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+}
+
+// ListSnapshots handles GET /api/v1/admin/snapshots, listing the named snapshots
+// oldest first along with those resident in memory
+func (vh *VectorHandler) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	if vh.snapshots == nil {
+		http.Error(w, errSnapshotsDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	infos, err := vh.snapshots.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"snapshots": infos,
+		"total":     len(infos),
+		"limits":    vh.snapshots.Options(),
+		"memory":    vh.snapshots.Stats(),
+	})
+}
+
+// DeleteSnapshot handles DELETE /api/v1/admin/snapshots/{name}
+func (vh *VectorHandler) DeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	if vh.snapshots == nil {
+		http.Error(w, errSnapshotsDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	if err := vh.snapshots.Delete(mux.Vars(r)["name"]); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// searchStore returns the store a query runs against: the live storage, or
+// the snapshot it is pinned to
+func (vh *VectorHandler) searchStore(q *searchQuery) (storage.Storage, error) {
+	if q.Snapshot == "" {
+		return vh.storage, nil
+	}
+	if vh.snapshots == nil {
+		return nil, errSnapshotsDisabled
+	}
+
+	store, header, err := vh.snapshots.Open(q.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	q.snapshotGeneration = header.Generation
+	return store, nil
+}
+
+func snapshotFilename(namespace string) string {
+	if namespace == "" {
+		return "same-same.snapshot"
+	}
+	return namespace + ".snapshot"
+}
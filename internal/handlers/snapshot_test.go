@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/snapshot"
+)
+
+func TestSnapshotPinnedSearch(t *testing.T) {
+	vh := newSearchTestHandler(t)
+	library, err := snapshot.NewLibrary(t.TempDir(), snapshot.LibraryOptions{})
+	if err != nil {
+		t.Fatalf("failed to open library: %v", err)
+	}
+	vh.SetSnapshotLibrary(library)
+
+	rec := httptest.NewRecorder()
+	vh.CreateSnapshot(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/snapshots", strings.NewReader(`{"name": "2024-06-01-nightly"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create snapshot: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	vh.CreateSnapshot(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/snapshots", strings.NewReader(`{"name": "2024-06-01-nightly"}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate snapshot: status = %d, want 409", rec.Code)
+	}
+
+	// The live store loses the best match and gains a better one
+	if err := vh.storage.Delete("fox"); err != nil {
+		t.Fatal(err)
+	}
+	embedding, _ := vh.embedder.Embed("the quick brown fox")
+	if err := vh.storage.Store(&models.Vector{ID: "fox-2", Embedding: embedding, Metadata: map[string]string{"text": "the quick brown fox"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, endpoint := range searchEndpoints {
+		t.Run(endpoint.name, func(t *testing.T) {
+			search := func(options map[string]interface{}) *httptest.ResponseRecorder {
+				body := endpoint.query(vh, "the quick brown fox")
+				body["top_k"] = 1
+				for key, value := range options {
+					body[key] = value
+				}
+				payload, _ := json.Marshal(body)
+				rec := httptest.NewRecorder()
+				endpoint.handler(vh)(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload)))
+				return rec
+			}
+
+			rec := search(map[string]interface{}{"snapshot": "2024-06-01-nightly"})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("pinned search: status = %d: %s", rec.Code, rec.Body.String())
+			}
+			if hits := endpoint.hits(t, rec.Body.Bytes()); len(hits) != 1 || hits[0].ID != "fox" {
+				t.Errorf("pinned search returned %+v, want the deleted fox", hits)
+			}
+			if rec.Header().Get(SnapshotHeader) != "2024-06-01-nightly" || rec.Header().Get(GenerationHeader) != "3" {
+				t.Errorf("headers = %v", rec.Header())
+			}
+			if endpoint.name != "vectors/search" && !strings.Contains(rec.Body.String(), `"snapshot":"2024-06-01-nightly"`) {
+				t.Errorf("snapshot missing from meta: %s", rec.Body.String())
+			}
+
+			if hits := endpoint.hits(t, search(nil).Body.Bytes()); len(hits) != 1 || hits[0].ID != "fox-2" {
+				t.Errorf("live search returned %+v, want fox-2", hits)
+			}
+
+			if rec := search(map[string]interface{}{"snapshot": "2024-06-02-nightly"}); rec.Code != http.StatusNotFound {
+				t.Errorf("unknown snapshot: status = %d, want 404", rec.Code)
+			}
+			if rec := search(map[string]interface{}{"snapshot": "2024-06-01-nightly", "save_results": true}); rec.Code != http.StatusBadRequest {
+				t.Errorf("snapshot with save_results: status = %d, want 400", rec.Code)
+			}
+		})
+	}
+
+	rec = httptest.NewRecorder()
+	vh.ListSnapshots(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/snapshots", nil))
+	var listed struct {
+		Snapshots []snapshot.Info       `json:"snapshots"`
+		Memory    snapshot.LibraryStats `json:"memory"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &listed)
+	if len(listed.Snapshots) != 1 || listed.Snapshots[0].Count != 3 || listed.Snapshots[0].Generation != 3 || !listed.Snapshots[0].Resident || listed.Memory.Loads != 1 {
+		t.Errorf("listed %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/admin/snapshots/2024-06-01-nightly", nil)
+	vh.DeleteSnapshot(rec, mux.SetURLVars(req, map[string]string{"name": "2024-06-01-nightly"}))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("delete snapshot: status = %d", rec.Code)
+	}
+}
+
+func TestSnapshotPinnedSearch_Disabled(t *testing.T) {
+	vh := newSearchTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	vh.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", strings.NewReader(`{"text": "fox", "snapshot": "nightly"}`)))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("search: status = %d, want 501", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	vh.CreateSnapshot(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/snapshots", strings.NewReader(`{"name": "nightly"}`)))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("create: status = %d, want 501", rec.Code)
+	}
+}
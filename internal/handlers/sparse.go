@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// SetSparseEmbeddings enables embedding text as sparse vectors, for both stored
+// quotes and search queries, when the embedder can emit them natively
+func (vh *VectorHandler) SetSparseEmbeddings(enabled bool) {
+	vh.sparse = enabled
+}
+
+// embedTextSparse embeds a stored text as a sparse vector with embedder
+// ok is false when sparse embeddings are disabled or not supported by the embedder
+func (vh *VectorHandler) embedTextSparse(embedder embedders.Embedder, text string) (sparse *models.SparseVector, ok bool, err error) {
+	if !vh.sparse {
+		return nil, false, nil
+	}
+	se, ok := embedder.(embedders.SparseEmbedder)
+	if !ok {
+		return nil, false, nil
+	}
+	sparse, err = se.EmbedSparse(text)
+	return sparse, true, err
+}
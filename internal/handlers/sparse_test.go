@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/tfidf"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestSparseVectors(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+
+	bodies := map[string]string{
+		"sparse": `{"id": "sparse", "embedding_sparse": {"indices": [1, 3], "values": [1, 1], "dimension": 4}}`,
+		"dense":  `{"id": "dense", "embedding": [0, 1, 0, 0]}`,
+	}
+	for name, body := range bodies {
+		rec := httptest.NewRecorder()
+		vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: status = %d: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+
+	invalid := map[string]string{
+		"both":     `{"embedding": [1], "embedding_sparse": {"indices": [0], "values": [1]}}`,
+		"unsorted": `{"embedding_sparse": {"indices": [3, 1], "values": [1, 1]}}`,
+	}
+	for name, body := range invalid {
+		rec := httptest.NewRecorder()
+		vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+
+	// A sparse query scores sparse and dense vectors alike
+	rec := httptest.NewRecorder()
+	body := `{"embedding_sparse": {"indices": [1, 3], "values": [2, 2], "dimension": 4}, "top_K": 2}`
+	vh.SearchVectors(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/search", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("search status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var results []struct {
+		Vector models.Vector `json:"vector"`
+		Score  float64       `json:"score"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("failed to decode results: %v", err)
+	}
+	if len(results) != 2 || results[0].Vector.ID != "sparse" || results[1].Vector.ID != "dense" {
+		t.Fatalf("results = %+v, want sparse then dense", results)
+	}
+	if results[0].Vector.Sparse == nil {
+		t.Error("sparse result returned without its sparse embedding")
+	}
+	if results[0].Score < 0.999 || results[1].Score < 0.7 || results[1].Score > 0.71 {
+		t.Errorf("scores = %v, %v, want 1 and 0.707", results[0].Score, results[1].Score)
+	}
+}
+
+func TestSparseEmbeddings_TextSearch(t *testing.T) {
+	embedder := tfidf.NewTFIDFEmbedder().(*tfidf.TFIDFEmbedder)
+	embedder.AddDocuments([]string{"fortune favours the bold", "the early bird catches the worm", "slow and steady wins the race"})
+
+	vh := NewVectorHandler(memory.NewStorage(), embedder)
+	vh.SetSparseEmbeddings(true)
+
+	for _, text := range []string{"fortune favours the bold", "the early bird catches the worm"} {
+		rec := httptest.NewRecorder()
+		body, _ := json.Marshal(models.Quote{Text: text, Author: "proverb"})
+		vh.EmbedVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/embed", bytes.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("embed status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var stored models.Vector
+		if err := json.Unmarshal(rec.Body.Bytes(), &stored); err != nil {
+			t.Fatalf("failed to decode vector: %v", err)
+		}
+		if stored.Sparse == nil || len(stored.Embedding) != 0 {
+			t.Fatalf("stored vector is not sparse: %s", rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	vh.SearchByText(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", bytes.NewBufferString(`{"text": "early bird", "top_K": 1}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("search status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Matches []struct {
+			Vector models.Vector `json:"vector"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Matches) != 1 || response.Matches[0].Vector.Metadata["text"] != "the early bird catches the worm" {
+		t.Errorf("matches = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/tracing"
+)
+
+// Span names of the request pipelines, under the span of the HTTP request
+const (
+	spanEmbedQuery   = "embedder.EmbedQuery"
+	spanEmbed        = "embedder.Embed"
+	spanSearch       = "storage.Search"
+	spanStore        = "storage.Store"
+	spanStoreBatch   = "storage.StoreBatch"
+	spanEncodeResult = "search.Encode"
+)
+
+// startSearchSpan starts the span of the storage scan of q
+func startSearchSpan(ctx context.Context, q *searchQuery) trace.Span {
+	_, span := tracing.Start(ctx, spanSearch)
+	if span.IsRecording() {
+		span.SetAttributes(tracing.Namespace.String(q.Namespace), tracing.TopK.Int(q.TopK))
+	}
+	return span
+}
+
+// endSearchSpan ends the span of a storage scan returning count results
+func endSearchSpan(span trace.Span, count int, err error) {
+	if span.IsRecording() {
+		span.SetAttributes(tracing.Results.Int(count))
+	}
+	tracing.End(span, err)
+}
+
+// store stores vector under a span
+func (vh *VectorHandler) store(ctx context.Context, vector *models.Vector) error {
+	_, span := tracing.Start(ctx, spanStore)
+	err := vh.storage.Store(vector)
+	tracing.End(span, err)
+	return err
+}
+
+// encodeSearchResponse writes a search response under its own span, so its
+// serialization time shows apart from the search
+func encodeSearchResponse(ctx context.Context, w http.ResponseWriter, response interface{}) {
+	_, span := tracing.Start(ctx, spanEncodeResult)
+	defer span.End()
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tahcohcat/same-same/internal/analysis"
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// AnalyzeTrend reports how many vectors similar to a query fall in each time bucket
+func (vh *VectorHandler) AnalyzeTrend(w http.ResponseWriter, r *http.Request) {
+	var req models.TrendRequest
+	if err := vh.decodeSearchRequest(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	embedding := req.Embedding
+	if len(embedding) == 0 {
+		var err error
+		embedding, err = embedders.EmbedQuery(vh.embedderFor(req.Namespace), req.Query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	vectors, err := vh.storage.ListByNamespace(req.Namespace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := analysis.Trend(vectors, embedding, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func newTrendTestHandler(t *testing.T) *VectorHandler {
+	t.Helper()
+
+	store := memory.NewStorage()
+	embedder := hash.NewHashEmbedder()
+
+	docs := []struct {
+		id, text, published string
+	}{
+		{"a1", "solar panel prices drop", "2024-01-01"},
+		{"a2", "solar panel installs grow", "2024-01-03T10:00:00Z"},
+		{"a3", "solar panel subsidies", "2024-01-09"},
+		{"a4", "solar panel recycling", "2024-01-22"},
+		{"b1", "football transfer news", "2024-01-02"},
+		{"b2", "football league results", "2024-01-10"},
+		{"u1", "solar panel output", "last tuesday"},
+		{"u2", "solar panel warranty", ""},
+	}
+	for _, doc := range docs {
+		embedding, _ := embedder.Embed(doc.text)
+		metadata := map[string]string{"text": doc.text}
+		if doc.published != "" {
+			metadata["published_at"] = doc.published
+		}
+		if err := store.Store(&models.Vector{ID: doc.id, Embedding: embedding, Metadata: metadata}); err != nil {
+			t.Fatalf("failed to store %s: %v", doc.id, err)
+		}
+	}
+
+	return NewVectorHandler(store, embedder)
+}
+
+func postTrend(t *testing.T, vh *VectorHandler, body map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis/trend", bytes.NewReader(payload))
+	rec := httptest.NewRecorder()
+	vh.AnalyzeTrend(rec, req)
+	return rec
+}
+
+func TestAnalyzeTrend(t *testing.T) {
+	vh := newTrendTestHandler(t)
+
+	rec := postTrend(t, vh, map[string]interface{}{
+		"query":      "solar panel",
+		"threshold":  0.3,
+		"time_field": "published_at",
+		"bucket":     "week",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp models.TrendResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	// 2024-01-01 is a Monday, so the dated solar documents span four ISO weeks
+	want := []struct {
+		start string
+		count int
+	}{
+		{"2024-01-01", 2},
+		{"2024-01-08", 1},
+		{"2024-01-15", 0},
+		{"2024-01-22", 1},
+	}
+	if len(resp.Buckets) != len(want) {
+		t.Fatalf("got %d buckets, want %d: %+v", len(resp.Buckets), len(want), resp.Buckets)
+	}
+	for i, w := range want {
+		bucket := resp.Buckets[i]
+		if got := bucket.Start.Format("2006-01-02"); got != w.start || bucket.Count != w.count {
+			t.Errorf("bucket %d = %s/%d, want %s/%d", i, got, bucket.Count, w.start, w.count)
+		}
+		if bucket.Count > 0 && bucket.MeanScore < 0.3 {
+			t.Errorf("bucket %d mean score %f below threshold", i, bucket.MeanScore)
+		}
+	}
+
+	if resp.Unknown.Count != 2 {
+		t.Errorf("unknown count = %d, want 2", resp.Unknown.Count)
+	}
+	if resp.Total != 6 {
+		t.Errorf("total = %d, want 6", resp.Total)
+	}
+}
+
+func TestAnalyzeTrendRange(t *testing.T) {
+	vh := newTrendTestHandler(t)
+
+	rec := postTrend(t, vh, map[string]interface{}{
+		"query":      "solar panel",
+		"threshold":  0.3,
+		"time_field": "published_at",
+		"bucket":     "day",
+		"from":       "2024-01-02T00:00:00Z",
+		"to":         "2024-01-05T00:00:00Z",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp models.TrendResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(resp.Buckets) != 3 {
+		t.Fatalf("got %d buckets, want 3: %+v", len(resp.Buckets), resp.Buckets)
+	}
+	counts := []int{resp.Buckets[0].Count, resp.Buckets[1].Count, resp.Buckets[2].Count}
+	if counts[0] != 0 || counts[1] != 1 || counts[2] != 0 {
+		t.Errorf("counts = %v, want [0 1 0]", counts)
+	}
+	if !resp.Buckets[1].Start.Equal(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("bucket 1 starts %s, want 2024-01-03", resp.Buckets[1].Start)
+	}
+}
+
+func TestAnalyzeTrendInvalid(t *testing.T) {
+	vh := newTrendTestHandler(t)
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+	}{
+		{"missing query", map[string]interface{}{"bucket": "day"}},
+		{"invalid bucket", map[string]interface{}{"query": "solar", "bucket": "hour"}},
+		{"inverted range", map[string]interface{}{"query": "solar", "from": "2024-02-01T00:00:00Z", "to": "2024-01-01T00:00:00Z"}},
+		{"too many buckets", map[string]interface{}{"query": "solar", "from": "2000-01-01T00:00:00Z", "to": "2024-01-01T00:00:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := postTrend(t, vh, tt.body); rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
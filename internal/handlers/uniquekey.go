@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/uniquekey"
+)
+
+// uniqueKeysRequest is the body of PUT /api/v1/admin/unique-keys
+type uniqueKeysRequest struct {
+	Fields []string `json:"fields"`
+}
+
+// GetVectorByKey handles GET /api/v1/vectors/by/{field}/{value}, fetching a
+// vector by a unique metadata field. The namespace query parameter selects the
+// namespace the value is unique in, the default namespace when omitted
+func (vh *VectorHandler) GetVectorByKey(w http.ResponseWriter, r *http.Request) {
+	indexer, ok := vh.storage.(storage.UniqueKeyIndexer)
+	if !ok {
+		http.Error(w, "storage backend does not support unique keys", http.StatusNotImplemented)
+		return
+	}
+
+	vars := mux.Vars(r)
+	vector, err := indexer.GetByKey(r.URL.Query().Get("namespace"), vars["field"], vars["value"])
+	if err != nil {
+		writeKeyLookupError(w, err)
+		return
+	}
+
+	writeCacheableJSON(w, r, vector)
+}
+
+// UpsertVectorByKey handles PUT /api/v1/vectors/by/{field}/{value}, storing the
+// vector in the body under the ID of the vector holding the value in its
+// namespace, or as a new vector when there is none
+func (vh *VectorHandler) UpsertVectorByKey(w http.ResponseWriter, r *http.Request) {
+	indexer, ok := vh.storage.(storage.UniqueKeyIndexer)
+	if !ok {
+		http.Error(w, "storage backend does not support unique keys", http.StatusNotImplemented)
+		return
+	}
+
+	vars := mux.Vars(r)
+	field, value := vars["field"], vars["value"]
+
+	vector, err := decodeVector(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := vh.normalizeMetadata(vector); err != nil {
+		writeMetadataError(w, err)
+		return
+	}
+
+	if vector.Metadata == nil {
+		vector.Metadata = make(map[string]string)
+	}
+	if current, ok := vector.Metadata[field]; ok && current != value {
+		http.Error(w, fmt.Sprintf("metadata %s is %q but the path sets %q", field, current, value), http.StatusBadRequest)
+		return
+	}
+	vector.Metadata[field] = value
+
+	status := http.StatusCreated
+	existing, err := indexer.GetByKey(vector.Metadata[models.NamespaceKey], field, value)
+	switch {
+	case err == nil:
+		if vector.ID != "" && vector.ID != existing.ID {
+			http.Error(w, fmt.Sprintf("%s %q belongs to vector %s, not %s", field, value, existing.ID, vector.ID), http.StatusConflict)
+			return
+		}
+		vector.ID = existing.ID
+		status = http.StatusOK
+	case errors.Is(err, uniquekey.ErrNotUnique):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := vector.Validate(); err != nil {
+		http.Error(w, err.Error(), validationStatus(err))
+		return
+	}
+
+	if err := vh.storage.Store(vector); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	vh.evaluateWatches([]*models.Vector{vector})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(vector)
+}
+
+// GetUniqueKeys handles GET /api/v1/admin/unique-keys, listing the unique metadata fields
+func (vh *VectorHandler) GetUniqueKeys(w http.ResponseWriter, r *http.Request) {
+	indexer, ok := vh.storage.(storage.UniqueKeyIndexer)
+	if !ok {
+		http.Error(w, "storage backend does not support unique keys", http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(uniqueKeysRequest{Fields: indexer.UniqueKeys()})
+}
+
+// SetUniqueKeys handles PUT /api/v1/admin/unique-keys, replacing the unique metadata fields
+// Stored vectors already sharing a value are reported with 409 Conflict
+func (vh *VectorHandler) SetUniqueKeys(w http.ResponseWriter, r *http.Request) {
+	indexer, ok := vh.storage.(storage.UniqueKeyIndexer)
+	if !ok {
+		http.Error(w, "storage backend does not support unique keys", http.StatusNotImplemented)
+		return
+	}
+
+	var req uniqueKeysRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := indexer.SetUniqueKeys(req.Fields); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(uniqueKeysRequest{Fields: indexer.UniqueKeys()})
+}
+
+// writeKeyLookupError answers a failed lookup by key, 400 for fields that are not unique
+func writeKeyLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, uniquekey.ErrNotUnique) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeStoreError(w, err)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestVectorsByKey(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+
+	rec := httptest.NewRecorder()
+	vh.SetUniqueKeys(rec, httptest.NewRequest(http.MethodPut, "/api/v1/admin/unique-keys", bytes.NewBufferString(`{"fields":["ticket_id"]}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("set unique keys status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	upsert := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/vectors/by/ticket_id/T-1", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+		vh.UpsertVectorByKey(rec, mux.SetURLVars(req, map[string]string{"field": "ticket_id", "value": "T-1"}))
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) *models.Vector {
+		var vector models.Vector
+		if err := json.Unmarshal(rec.Body.Bytes(), &vector); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return &vector
+	}
+
+	rec = upsert(`{"embedding":[1,0],"metadata":{"title":"first"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("first upsert status = %d: %s", rec.Code, rec.Body.String())
+	}
+	created := decode(rec)
+	if created.ID == "" || created.Metadata["ticket_id"] != "T-1" {
+		t.Fatalf("created vector = %+v", created)
+	}
+
+	rec = upsert(`{"embedding":[0,1],"metadata":{"title":"second"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("second upsert status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if updated := decode(rec); updated.ID != created.ID {
+		t.Errorf("upsert created %s instead of updating %s", updated.ID, created.ID)
+	}
+	if vh.storage.Count() != 1 {
+		t.Errorf("count = %d after upserts, want 1", vh.storage.Count())
+	}
+
+	if rec := upsert(`{"embedding":[0,1],"metadata":{"ticket_id":"T-2"}}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("mismatched body value status = %d, want 400", rec.Code)
+	}
+
+	// A plain create reusing the value is a conflict
+	rec = httptest.NewRecorder()
+	vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(`{"id":"other","embedding":[1,0],"metadata":{"ticket_id":"T-1"}}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate create status = %d, want 409: %s", rec.Code, rec.Body.String())
+	}
+
+	get := func(field, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/vectors/by/"+field+"/"+value, nil)
+		rec := httptest.NewRecorder()
+		vh.GetVectorByKey(rec, mux.SetURLVars(req, map[string]string{"field": field, "value": value}))
+		return rec
+	}
+	if rec := get("ticket_id", "T-1"); rec.Code != http.StatusOK || decode(rec).Metadata["title"] != "second" {
+		t.Errorf("get by key status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := get("ticket_id", "T-404"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing value status = %d, want 404", rec.Code)
+	}
+	if rec := get("title", "second"); rec.Code != http.StatusBadRequest {
+		t.Errorf("non-unique field status = %d, want 400", rec.Code)
+	}
+}
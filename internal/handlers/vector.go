@@ -1,54 +1,142 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 
 	"github.com/tahcohcat/same-same/internal/embedders"
-	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/tfidf"
+	"github.com/tahcohcat/same-same/internal/embedders/registry"
+	"github.com/tahcohcat/same-same/internal/jobs"
 	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/snapshot"
 	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+	"github.com/tahcohcat/same-same/internal/tracing"
 )
 
 type VectorHandler struct {
 	storage  storage.Storage
 	embedder embedders.Embedder
+	format   models.ResponseFormat
+
+	normalizeKeys bool        // Normalize metadata keys of written vectors
+	keyFallback   atomic.Bool // Default of the key_fallback search option, changed by config reloads
+	sparse        bool        // Embed text as sparse vectors when the embedder supports it
+	quarantine    bool        // Quarantine the invalid vectors of batches instead of rejecting them
+
+	// namespaceEmbedders embed the namespaces that do not use embedder
+	namespaceEmbedders map[string]embedders.Embedder
+
+	// defaultMetadata is added to written vectors lacking its keys, nil for none
+	defaultMetadata *defaults.Config
+
+	// embedderOverrides are the embedders admin searches can select by name, nil when disabled
+	embedderOverrides *registry.Cache
+
+	// resultSets holds the results saved by searches for refinement
+	resultSets *resultSetCache
+
+	// scoreAdjusters adjust the scores of every search, in order
+	scoreAdjusters []models.NamedAdjuster
+
+	// jobs runs the background jobs, bulk maintenance and re-embedding
+	jobs *jobs.Runner
+
+	// watches are the standing queries evaluated against stored vectors
+	watches *watchSet
+
+	// snapshots are the named snapshots searches can be pinned to, nil when disabled
+	snapshots *snapshot.Library
+
+	// bulkGetLimit caps the IDs of a bulk get
+	bulkGetLimit atomic.Int64
 }
 
 func NewVectorHandler(storage storage.Storage, embedder embedders.Embedder) *VectorHandler {
-	return &VectorHandler{
-		storage:  storage,
-		embedder: embedder,
+	vh := &VectorHandler{
+		storage:    storage,
+		embedder:   embedder,
+		format:     models.DefaultResponseFormat(),
+		resultSets: newResultSetCache(),
+		watches:    newWatchSet(),
 	}
+	vh.jobs = newJobRunner(vh)
+	vh.bulkGetLimit.Store(DefaultBulkGetLimit)
+	return vh
+}
+
+// SetResponseFormat sets the default serialization of search scores and embeddings
+// Requests can override it with the precision and embedding_format options
+func (vh *VectorHandler) SetResponseFormat(format models.ResponseFormat) error {
+	if err := format.Validate(); err != nil {
+		return err
+	}
+	vh.format = format
+	return nil
 }
 
 func (vh *VectorHandler) CreateVector(w http.ResponseWriter, r *http.Request) {
-	var vector models.Vector
-	if err := json.NewDecoder(r.Body).Decode(&vector); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	allowEmpty, _, err := parseBoolQuery(r, "allow_empty_embedding")
+	if err != nil {
+		http.Error(w, "invalid allow_empty_embedding value", http.StatusBadRequest)
 		return
 	}
 
-	if err := vector.Validate(); err != nil {
+	vector, err := decodeVector(r)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := vh.storage.Store(&vector); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Vectors without embedding are stored as pending when the request allows it
+	validate := vector.Validate
+	if allowEmpty {
+		validate = vector.ValidatePending
+	}
+	if err := validate(); err != nil {
+		http.Error(w, err.Error(), validationStatus(err))
+		return
+	}
+	if !vector.HasEmbedding() {
+		vector.DType = ""
+	}
+
+	if err := vh.normalizeMetadata(vector); err != nil {
+		writeMetadataError(w, err)
+		return
+	}
+
+	if err := vh.store(r.Context(), vector); err != nil {
+		writeStoreError(w, err)
 		return
 	}
+	vh.evaluateWatches([]*models.Vector{vector})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(vector)
 }
 
+// decodeVector decodes a client-supplied vector, accepting any numeric
+// representation of its embedding and normalizing it to the internal precision
+func decodeVector(r *http.Request) (*models.Vector, error) {
+	var input models.VectorInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return nil, fmt.Errorf("Invalid JSON: %v", err)
+	}
+	return input.ToVector()
+}
+
 func (vh *VectorHandler) EmbedVector(w http.ResponseWriter, r *http.Request) {
 	var quote models.Quote
 	if err := json.NewDecoder(r.Body).Decode(&quote); err != nil {
@@ -58,12 +146,17 @@ func (vh *VectorHandler) EmbedVector(w http.ResponseWriter, r *http.Request) {
 
 	// Generate embedding for the quote text
 	fullText := quote.Text + " - " + quote.Author
+	embedder := vh.embedderFor(quote.Namespace)
 
 	var embedding []float64
-	var err error
 
-	// Generate embedding
-	embedding, err = vh.embedder.Embed(fullText)
+	// Generate embedding, sparse if enabled
+	_, span := tracing.StartEmbed(r.Context(), spanEmbed, embedder)
+	sparse, ok, err := vh.embedTextSparse(embedder, fullText)
+	if !ok {
+		embedding, err = embedder.Embed(fullText)
+	}
+	tracing.End(span, err)
 
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to generate embedding: %v", err), http.StatusInternalServerError)
@@ -71,7 +164,7 @@ func (vh *VectorHandler) EmbedVector(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify non-zero embedding
-	hasNonZero := false
+	hasNonZero := sparse != nil && len(sparse.Indices) > 0
 	for _, val := range embedding {
 		if val != 0 {
 			hasNonZero = true
@@ -87,18 +180,27 @@ func (vh *VectorHandler) EmbedVector(w http.ResponseWriter, r *http.Request) {
 	vector := models.Vector{
 		ID:        fmt.Sprintf("quote_%d", time.Now().Unix()),
 		Embedding: embedding,
+		Sparse:    sparse,
 		Metadata: map[string]string{
 			"type":          "quote",
 			"author":        quote.Author,
 			"text":          quote.Text,
-			"embedder.name": vh.embedder.Name(),
+			"embedder.name": embedder.Name(),
 		},
 		CreatedAt: time.Now(), // Set creation time
 		UpdatedAt: time.Now(), // Set update time
 	}
 
-	if err := vh.storage.Store(&vector); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if quote.Namespace != "" {
+		vector.Metadata[models.NamespaceKey] = quote.Namespace
+	}
+	if err := vh.normalizeMetadata(&vector); err != nil {
+		writeMetadataError(w, err)
+		return
+	}
+
+	if err := vh.store(r.Context(), &vector); err != nil {
+		writeStoreError(w, err)
 		return
 	}
 
@@ -111,33 +213,62 @@ func (vh *VectorHandler) GetVector(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	if err := models.ValidateID(id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	projection, err := queryProjection(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	vector, err := vh.storage.Get(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeVectorNotFound(w, id, err)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeStoreError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(vector)
+	writeCacheableJSON(w, r, projection.responseVector(vector))
 }
 
 func (vh *VectorHandler) UpdateVector(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	var vector models.Vector
-	if err := json.NewDecoder(r.Body).Decode(&vector); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := models.ValidateID(id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vector, err := decodeVector(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	vector.ID = id
+	if err := vector.CheckDimension(); err != nil {
+		http.Error(w, err.Error(), validationStatus(err))
+		return
+	}
 
-	if err := vh.storage.Store(&vector); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := vh.normalizeMetadata(vector); err != nil {
+		writeMetadataError(w, err)
 		return
 	}
 
+	if err := vh.store(r.Context(), vector); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	vh.evaluateWatches([]*models.Vector{vector})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(vector)
 }
@@ -146,8 +277,29 @@ func (vh *VectorHandler) DeleteVector(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	if err := models.ValidateID(id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// cascade_enrichment names the metadata field keying the enrichment document
+	// deleted along with the vector
+	cascade := r.URL.Query().Get("cascade_enrichment")
+	var vector *models.Vector
+	if cascade != "" {
+		var err error
+		if vector, err = vh.storage.Get(id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+	}
+
 	if err := vh.storage.Delete(id); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeStoreError(w, err)
+		return
+	}
+	if err := vh.cascadeEnrichment(vector, cascade); err != nil {
+		writeStoreError(w, err)
 		return
 	}
 
@@ -155,20 +307,40 @@ func (vh *VectorHandler) DeleteVector(w http.ResponseWriter, r *http.Request) {
 }
 
 func (vh *VectorHandler) ListVectors(w http.ResponseWriter, r *http.Request) {
-	vectors, err := vh.storage.List()
+	vh.setGeneration(w)
+
+	if r.URL.Query().Get("updated_after") != "" {
+		vh.listChanges(w, r)
+		return
+	}
+	if vh.notModifiedSince(r) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	projection, err := queryProjection(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vectors, ok := vh.listVectors(w, r)
+	if !ok {
 		return
 	}
+	for i, vector := range vectors {
+		vectors[i] = projection.responseVector(vector)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(vectors)
 }
 
 func (vh *VectorHandler) ListVectorMetadata(w http.ResponseWriter, r *http.Request) {
-	vectors, err := vh.storage.List()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	vh.setGeneration(w)
+
+	vectors, ok := vh.listVectors(w, r)
+	if !ok {
 		return
 	}
 
@@ -176,112 +348,355 @@ func (vh *VectorHandler) ListVectorMetadata(w http.ResponseWriter, r *http.Reque
 	for i, vector := range vectors {
 		meta[i] = map[string]interface{}{
 			"id":         vector.ID,
-			"length":     len(vector.Embedding),
+			"length":     vector.Dimension(),
+			"sparse":     vector.IsSparse(),
+			"pending":    !vector.HasEmbedding(),
+			"dtype":      vector.DType.OrDefault(),
 			"metadata":   vector.Metadata,
 			"created_at": vector.CreatedAt,
 			"updated_at": vector.UpdatedAt,
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(meta)
+	writeCacheableJSON(w, r, meta)
+}
+
+// listVectors returns the vectors of the namespace and has_embedding query
+// parameters, writing the error response when it fails
+func (vh *VectorHandler) listVectors(w http.ResponseWriter, r *http.Request) ([]*models.Vector, bool) {
+	hasEmbedding, filter, err := parseBoolQuery(r, "has_embedding")
+	if err != nil {
+		http.Error(w, "invalid has_embedding value", http.StatusBadRequest)
+		return nil, false
+	}
+
+	vectors, err := vh.storage.ListByNamespace(vh.resolveNamespace(r.URL.Query().Get("namespace")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if filter {
+		vectors = filterByEmbedding(vectors, hasEmbedding)
+	}
+	return vectors, true
 }
 
 func (vh *VectorHandler) SearchVectors(w http.ResponseWriter, r *http.Request) {
+	vh.setGeneration(w)
+
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var req models.SearchByEmbbedingRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := vh.decodeSearchRequest(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := req.Validate(); err != nil {
+	query, err := embeddingQuery(&req)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	results, err := vh.storage.Search(&req)
+	results, err := vh.search(r.Context(), query)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeSearchError(w, err)
+		return
+	}
+	setResultSetHeader(w, query)
+	if format != "" {
+		writeExport(w, format, query, searchExportRows(results))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(results)
+	encodeSearchResponse(r.Context(), w, results)
+}
+
+// Search handles POST /api/v1/search, which serves both search request shapes: a
+// body with a query and no text field is an advanced search, any other a text search
+func (vh *VectorHandler) Search(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) == nil {
+		_, text := fields["text"]
+		if _, query := fields["query"]; query && !text {
+			vh.AdvancedSearch(w, r)
+			return
+		}
+	}
+	vh.SearchByText(w, r)
 }
 
 func (vh *VectorHandler) SearchByText(w http.ResponseWriter, r *http.Request) {
+	vh.setGeneration(w)
+
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var req models.SearchByTextRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := vh.decodeSearchRequest(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := req.Validate(); err != nil {
+
+	query, err := textQuery(&req)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if req.TopK == 0 {
-		req.TopK = 5
+	// Embed the text and run the similarity search
+	results, err := vh.search(r.Context(), query)
+	if err != nil {
+		writeSearchError(w, err)
+		return
+	}
+	setResultSetHeader(w, query)
+	if format != "" {
+		writeExport(w, format, query, searchExportRows(results))
+		return
 	}
 
-	// 1. Embed the text
-	embedding, err := vh.embedder.Embed(req.Text)
+	w.Header().Set("Content-Type", "application/json")
+
+	// Return matches
+	response := map[string]interface{}{
+		"matches": results,
+	}
+	if meta := query.searchMeta(nil); meta != nil {
+		response["meta"] = meta
+	}
+	if query.resultSetID != "" {
+		response["result_set_id"] = query.resultSetID
+	}
+	encodeSearchResponse(r.Context(), w, response)
+}
+
+// TemporalSearch handles POST /api/v1/search/temporal, ranking results with temporal decay
+func (vh *VectorHandler) TemporalSearch(w http.ResponseWriter, r *http.Request) {
+	vh.setGeneration(w)
+
+	format, err := exportFormat(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 2. Run similarity search
-	results, err := vh.storage.Search(&models.SearchByEmbbedingRequest{
-		Embedding: embedding,
-		TopK:      req.TopK,
-		Filters:   req.MetadataFilters,
-	})
+	var req models.TemporalSearchRequest
+	if err := vh.decodeSearchRequest(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	query, err := temporalQuery(&req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	query.ageLocale = ageLocale(r.Header.Get("Accept-Language"))
 
-	if !req.ReturnEmbedding {
-		for _, res := range results {
-			res.Vector.Embedding = nil
+	results, err := vh.temporalSearch(r.Context(), query)
+	if err != nil {
+		writeSearchError(w, err)
+		return
+	}
+	setResultSetHeader(w, query)
+	if format != "" {
+		rows := make([]exportRow, len(results))
+		for i, result := range results {
+			rows[i] = exportRow{vector: result.Vector, score: result.Score, format: result.Format, item: result}
 		}
+		writeExport(w, format, query, rows)
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
 
-	// 3. Return matches
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"matches": results,
-	})
+	response := map[string]interface{}{
+		"results":   results,
+		"total":     len(results),
+		"query":     req.Query,
+		"decay":     req.TemporalDecay,
+		"timestamp": req.ReferenceTime,
+	}
+	if meta := query.searchMeta(nil); meta != nil {
+		response["meta"] = meta
+	}
+	if query.resultSetID != "" {
+		response["result_set_id"] = query.resultSetID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept-Language")
+	if req.AgeFormat != models.AgeFormatISO {
+		w.Header().Set("Content-Language", query.ageLocale)
+	}
+	encodeSearchResponse(r.Context(), w, response)
 }
 
 func (vh *VectorHandler) CountVectors(w http.ResponseWriter, r *http.Request) {
-	count := vh.storage.Count()
+	namespace := vh.resolveNamespace(r.URL.Query().Get("namespace"))
+	count := vh.storage.CountByNamespace(namespace)
 
 	response := map[string]int{
 		"count": count,
 	}
 
+	// Pending vectors, stored without embedding, are not searchable
+	if pending, err := storage.CountPending(vh.storage, namespace); err == nil {
+		response["embedded"] = count - pending
+		response["pending"] = pending
+	}
+
+	// A namespace count also reports its quota usage and limits, kept as flat
+	// numbers so the response shape stays the same; GET /admin/quotas lists them all
+	if qm, ok := vh.storage.(storage.QuotaManager); ok && namespace != "" {
+		response["used_bytes"] = int(qm.QuotaUsage()[namespace].Bytes)
+		if limit, ok := qm.Quotas()[namespace]; ok {
+			if limit.MaxVectors > 0 {
+				response["max_vectors"] = limit.MaxVectors
+			}
+			if limit.MaxBytes > 0 {
+				response["max_bytes"] = int(limit.MaxBytes)
+			}
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetEmbedderStats handles GET /api/v1/embedder/stats, reporting the default embedder
+// and, under namespaces, the embedder of each namespace configured with its own
 func (vh *VectorHandler) GetEmbedderStats(w http.ResponseWriter, r *http.Request) {
-	stats := make(map[string]interface{})
+	stats := embedderStats(vh.embedder)
+
+	if len(vh.namespaceEmbedders) > 0 {
+		namespaces := make(map[string]interface{}, len(vh.namespaceEmbedders))
+		for namespace, embedder := range vh.namespaceEmbedders {
+			namespaces[namespace] = embedderStats(embedder)
+		}
+		stats["namespaces"] = namespaces
+	}
+
+	// Storage backends with a vector config report it along with any conflict with this embedder
+	if reporter, ok := vh.storage.(interface{ GetStats() map[string]interface{} }); ok {
+		stats["storage"] = reporter.GetStats()
+	}
 
-	stats["type"] = vh.embedder.Name()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// ReloadSynonyms re-reads the configured synonyms file without restarting
+func (vh *VectorHandler) ReloadSynonyms(w http.ResponseWriter, r *http.Request) {
+	expander, ok := vh.embedder.(embedders.SynonymExpander)
+	if !ok {
+		http.Error(w, fmt.Sprintf("embedder %s does not support synonyms", vh.embedder.Name()), http.StatusBadRequest)
+		return
+	}
+
+	set := expander.Synonyms()
+	if set == nil {
+		http.Error(w, "no synonyms file configured (set SYNONYMS_PATH)", http.StatusNotFound)
+		return
+	}
+
+	if err := set.Reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"path":         set.Path(),
+		"synonym_sets": set.Count(),
+	}).Info("synonyms reloaded")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":         set.Path(),
+		"synonym_sets": set.Count(),
+	})
+}
 
-	if vh.embedder.Name() == "local.tfidf" {
-		stats["type"] = "local.tfidf"
+// GetVocabulary handles GET /api/v1/embedder/vocabulary?prefix=&limit=&namespace=
+// The namespace parameter selects the embedder of a namespace configured with its own
+func (vh *VectorHandler) GetVocabulary(w http.ResponseWriter, r *http.Request) {
+	embedder := vh.embedderFor(r.URL.Query().Get("namespace"))
+	inspector, ok := embedder.(embedders.VocabularyInspector)
+	if !ok {
+		http.Error(w, fmt.Sprintf("embedder %s does not expose a vocabulary", embedder.Name()), http.StatusNotImplemented)
+		return
+	}
 
-		if tfidfEmbedder, ok := vh.embedder.(*tfidf.TFIDFEmbedder); ok {
-			stats["vocabulary_size"] = tfidfEmbedder.GetVocabularySize()
-			stats["document_count"] = tfidfEmbedder.GetDocumentCount()
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
 		}
+		limit = parsed
 	}
 
+	terms := inspector.Vocabulary(r.URL.Query().Get("prefix"), limit)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"embedder": embedder.Name(),
+		"terms":    terms,
+		"count":    len(terms),
+	})
+}
+
+// AnalyzeRequest is the body of POST /api/v1/embedder/analyze
+type AnalyzeRequest struct {
+	Text string `json:"text"`
+	Top  int    `json:"top,omitempty"`
+}
+
+// AnalyzeText handles POST /api/v1/embedder/analyze?namespace=
+// The namespace parameter selects the embedder of a namespace configured with its own
+func (vh *VectorHandler) AnalyzeText(w http.ResponseWriter, r *http.Request) {
+	embedder := vh.embedderFor(r.URL.Query().Get("namespace"))
+	analyzer, ok := embedder.(embedders.TextAnalyzer)
+	if !ok {
+		http.Error(w, fmt.Sprintf("embedder %s does not support analysis", embedder.Name()), http.StatusNotImplemented)
+		return
+	}
+
+	var req AnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Text == "" {
+		http.Error(w, "text field cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if req.Top <= 0 {
+		req.Top = 10
+	}
+
+	analysis, err := analyzer.Analyze(req.Text, req.Top)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analysis)
 }
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+// verifier is implemented by storage backends whose files can be checked for corruption
+type verifier interface {
+	Verify(opts local.VerifyOptions) (*local.VerifyReport, error)
+}
+
+// VerifyStorage handles GET /api/v1/admin/verify?sample=&seed=
+// The check runs while the request waits and blocks writes meanwhile, so
+// large stores should be verified with a sample
+func (vh *VectorHandler) VerifyStorage(w http.ResponseWriter, r *http.Request) {
+	v, ok := vh.storage.(verifier)
+	if !ok {
+		http.Error(w, "storage backend does not support verify", http.StatusNotImplemented)
+		return
+	}
+
+	var opts local.VerifyOptions
+	if value := r.URL.Query().Get("sample"); value != "" {
+		sample, err := strconv.ParseFloat(value, 64)
+		if err != nil || sample < 0 || sample > 1 {
+			http.Error(w, "sample must be a number between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		opts.Sample = sample
+	}
+	if value := r.URL.Query().Get("seed"); value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid seed value", http.StatusBadRequest)
+			return
+		}
+		opts.Seed = seed
+	}
+
+	report, err := v.Verify(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/watch"
+)
+
+const (
+	// webhookTimeout bounds one webhook delivery attempt
+	webhookTimeout = 10 * time.Second
+
+	// defaultWebhookRetryDelay is the wait before the first retry of a failed
+	// webhook delivery, doubled before each further retry
+	defaultWebhookRetryDelay = time.Second
+)
+
+// watchSet holds the watches of the handler, the evaluator of the active ones
+// and the counters of their evaluation
+type watchSet struct {
+	mu        sync.Mutex
+	watches   []*watch.Watch   // Oldest first
+	evaluator *watch.Evaluator // nil until the next store after the watches change
+	metrics   watchMetrics
+
+	client     *http.Client
+	retryDelay time.Duration
+	deliveries sync.WaitGroup // Webhook deliveries in flight
+}
+
+func newWatchSet() *watchSet {
+	return &watchSet{
+		client:     &http.Client{Timeout: webhookTimeout},
+		retryDelay: defaultWebhookRetryDelay,
+	}
+}
+
+// watchMetrics are the evaluation and delivery counters of the watches since
+// the server started
+type watchMetrics struct {
+	Watches int `json:"watches"`
+	Active  int `json:"active"`
+
+	Evaluations int     `json:"evaluations"` // Stores whose vectors were evaluated
+	Vectors     int     `json:"vectors"`     // Vectors evaluated
+	Comparisons int     `json:"comparisons"` // Vector and watch pairs scored
+	Skipped     int     `json:"skipped"`     // Vectors with no watch of their dimension
+	EvalMillis  float64 `json:"eval_ms"`     // Time spent scoring, in milliseconds
+
+	Matches          int `json:"matches"`
+	Deliveries       int `json:"deliveries"`
+	DeliveryFailures int `json:"delivery_failures"`
+	WebhookRetries   int `json:"webhook_retries"`
+}
+
+// watchResponse is a watch and its state
+type watchResponse struct {
+	*watch.Watch
+	State string `json:"state"`
+}
+
+// webhookPayload is the body POSTed to the webhook of a watch
+type webhookPayload struct {
+	WatchID string        `json:"watch_id"`
+	Query   string        `json:"query,omitempty"`
+	Matches []watch.Match `json:"matches"`
+}
+
+// find returns the watch of id, nil if unknown. Caller must hold the lock
+func (s *watchSet) find(id string) *watch.Watch {
+	for _, w := range s.watches {
+		if w.ID == id {
+			return w
+		}
+	}
+	return nil
+}
+
+// response returns a copy of the watch of id and its state, without its inbox
+func (s *watchSet) response(id string) (*watchResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := s.find(id)
+	if w == nil {
+		return nil, false
+	}
+	return &watchResponse{Watch: w.Copy(false), State: w.State(time.Now())}, true
+}
+
+// CreateWatch handles POST /api/v1/watches, registering a standing query whose
+// matches among the vectors stored from now on are delivered to a webhook or inbox
+func (vh *VectorHandler) CreateWatch(w http.ResponseWriter, r *http.Request) {
+	var req watch.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	embedding := req.Embedding
+	if len(embedding) == 0 {
+		var err error
+		if embedding, err = embedders.EmbedQuery(vh.embedderFor(req.Namespace), req.Query); err != nil {
+			http.Error(w, fmt.Sprintf("failed to embed query: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	created := watch.New(uuid.New(), req, embedding, time.Now())
+	s := vh.watches
+	s.mu.Lock()
+	if err := vh.saveWatch(created); err != nil {
+		s.mu.Unlock()
+		writeStoreError(w, err)
+		return
+	}
+	s.watches = append(s.watches, created)
+	s.evaluator = nil
+	s.mu.Unlock()
+
+	resp, _ := s.response(created.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/watches/"+created.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListWatches handles GET /api/v1/watches, listing the watches oldest first
+func (vh *VectorHandler) ListWatches(w http.ResponseWriter, r *http.Request) {
+	s := vh.watches
+	now := time.Now()
+
+	s.mu.Lock()
+	listed := make([]*watchResponse, len(s.watches))
+	for i, watched := range s.watches {
+		listed[i] = &watchResponse{Watch: watched.Copy(false), State: watched.State(now)}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listed)
+}
+
+// GetWatch handles GET /api/v1/watches/{id}
+func (vh *VectorHandler) GetWatch(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	resp, ok := vh.watches.response(id)
+	if !ok {
+		writeStoreError(w, watch.NotFound(id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// UpdateWatch handles PATCH /api/v1/watches/{id}, enabling or disabling a watch
+// or changing its threshold or expiry
+func (vh *VectorHandler) UpdateWatch(w http.ResponseWriter, r *http.Request) {
+	var update watch.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	s := vh.watches
+	s.mu.Lock()
+	existing := s.find(id)
+	if existing == nil {
+		s.mu.Unlock()
+		writeStoreError(w, watch.NotFound(id))
+		return
+	}
+	updated := existing.Copy(true)
+	if err := updated.Apply(update, time.Now()); err != nil {
+		s.mu.Unlock()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := vh.saveWatch(updated); err != nil {
+		s.mu.Unlock()
+		writeStoreError(w, err)
+		return
+	}
+	s.watches = watch.Put(s.watches, updated)
+	s.evaluator = nil
+	s.mu.Unlock()
+
+	resp, _ := s.response(id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteWatch handles DELETE /api/v1/watches/{id}
+// Webhook deliveries in flight still complete
+func (vh *VectorHandler) DeleteWatch(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	s := vh.watches
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(id) == nil {
+		writeStoreError(w, watch.NotFound(id))
+		return
+	}
+	if ws, ok := vh.storage.(storage.WatchStore); ok {
+		if err := ws.DeleteWatch(id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+	}
+	s.watches, _ = watch.Remove(s.watches, id)
+	s.evaluator = nil
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWatchMatches handles GET /api/v1/watches/{id}/matches?limit=, listing the
+// inbox of a watch newest first
+func (vh *VectorHandler) ListWatchMatches(w http.ResponseWriter, r *http.Request) {
+	limit := watch.MaxInbox
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > watch.MaxInbox {
+			http.Error(w, fmt.Sprintf("limit must be an integer between 1 and %d", watch.MaxInbox), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	id := mux.Vars(r)["id"]
+	s := vh.watches
+	s.mu.Lock()
+	watched := s.find(id)
+	if watched == nil {
+		s.mu.Unlock()
+		writeStoreError(w, watch.NotFound(id))
+		return
+	}
+	if watched.Delivery != watch.DeliveryInbox {
+		s.mu.Unlock()
+		http.Error(w, fmt.Sprintf("watch %s delivers to a webhook and keeps no inbox", id), http.StatusConflict)
+		return
+	}
+	matches := make([]watch.Match, 0, limit)
+	for i := len(watched.Inbox) - 1; i >= 0 && len(matches) < limit; i-- {
+		matches = append(matches, watched.Inbox[i])
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matches)
+}
+
+// GetWatchMetrics handles GET /api/v1/watches/metrics, reporting the evaluation
+// cost and deliveries of the watches since the server started
+func (vh *VectorHandler) GetWatchMetrics(w http.ResponseWriter, r *http.Request) {
+	s := vh.watches
+	now := time.Now()
+
+	s.mu.Lock()
+	metrics := s.metrics
+	metrics.Watches = len(s.watches)
+	for _, watched := range s.watches {
+		if watched.State(now) == watch.StateActive {
+			metrics.Active++
+		}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
+
+// LoadWatches loads the persisted watches, returning how many were loaded
+func (vh *VectorHandler) LoadWatches() (int, error) {
+	ws, ok := vh.storage.(storage.WatchStore)
+	if !ok {
+		return 0, nil
+	}
+	watches, err := ws.Watches()
+	if err != nil {
+		return 0, err
+	}
+
+	s := vh.watches
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loaded := 0
+	for _, w := range watch.Sorted(watches) {
+		if s.find(w.ID) == nil {
+			s.watches = append(s.watches, w)
+			loaded++
+		}
+	}
+	s.evaluator = nil
+	return loaded, nil
+}
+
+// evaluateWatches scores newly stored vectors against the active watches,
+// keeping the matches of inbox watches and posting the others to their webhook
+// in the background, so stores never wait for a delivery
+func (vh *VectorHandler) evaluateWatches(vectors []*models.Vector) {
+	s := vh.watches
+	now := time.Now()
+
+	s.mu.Lock()
+	if len(s.watches) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	if s.evaluator == nil {
+		s.evaluator = watch.NewEvaluator(s.watches, vh.keyFallback.Load(), now)
+	}
+	if s.evaluator.Active() == 0 {
+		s.mu.Unlock()
+		return
+	}
+
+	result := s.evaluator.Evaluate(vectors, now)
+	s.metrics.Evaluations++
+	s.metrics.Vectors += result.Vectors
+	s.metrics.Comparisons += result.Comparisons
+	s.metrics.Skipped += result.Skipped
+	s.metrics.EvalMillis += float64(result.Duration) / float64(time.Millisecond)
+
+	for id, matches := range result.Matches {
+		watched := s.find(id)
+		s.metrics.Matches += len(matches)
+		if watched.Delivery == watch.DeliveryInbox {
+			watched.Record(matches, nil)
+			s.metrics.Deliveries += len(matches)
+			vh.persistWatch(watched)
+			continue
+		}
+
+		s.deliveries.Add(1)
+		go vh.deliverWebhook(watched.Copy(false), matches)
+	}
+	s.mu.Unlock()
+}
+
+// deliverWebhook posts matches to the webhook of w, retrying failed attempts
+// after a delay doubled each time, and records the outcome
+func (vh *VectorHandler) deliverWebhook(w *watch.Watch, matches []watch.Match) {
+	s := vh.watches
+	defer s.deliveries.Done()
+
+	body, err := json.Marshal(webhookPayload{WatchID: w.ID, Query: w.Request.Query, Matches: matches})
+	if err == nil {
+		delay := s.retryDelay
+		for attempt := 0; ; attempt++ {
+			if err = s.post(w.Request.Webhook, body); err == nil || attempt >= *w.Request.Retries {
+				break
+			}
+			s.mu.Lock()
+			s.metrics.WebhookRetries++
+			s.mu.Unlock()
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("watch_id", w.ID).Warn("failed to deliver watch matches")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.metrics.DeliveryFailures += len(matches)
+	} else {
+		s.metrics.Deliveries += len(matches)
+	}
+	// The watch may have been deleted during the delivery
+	if current := s.find(w.ID); current != nil {
+		current.Record(matches, err)
+		vh.persistWatch(current)
+	}
+}
+
+// post sends one webhook delivery attempt, failing unless it gets a 2xx response
+func (s *watchSet) post(url string, body []byte) error {
+	resp, err := s.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// saveWatch persists a watch when the backend keeps watches
+// Callers hold the lock of the watch set, so saves are never reordered
+func (vh *VectorHandler) saveWatch(w *watch.Watch) error {
+	if ws, ok := vh.storage.(storage.WatchStore); ok {
+		return ws.SaveWatch(w)
+	}
+	return nil
+}
+
+// persistWatch saves the stats and inbox of a watch, logging failures since
+// the watch goes on and its next save may succeed
+func (vh *VectorHandler) persistWatch(w *watch.Watch) {
+	if err := vh.saveWatch(w); err != nil {
+		logrus.WithError(err).WithField("watch_id", w.ID).Warn("failed to save watch")
+	}
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/watch"
+)
+
+func createWatch(t *testing.T, vh *VectorHandler, body string) *watch.Watch {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	vh.CreateWatch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/watches", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create watch: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var created watch.Watch
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode watch: %v", err)
+	}
+	return &created
+}
+
+func createVector(t *testing.T, vh *VectorHandler, body string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	vh.CreateVector(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create vector: status = %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func watchMatches(t *testing.T, vh *VectorHandler, id string) []watch.Match {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	vh.ListWatchMatches(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/watches/"+id+"/matches", nil), map[string]string{"id": id}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list matches: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var matches []watch.Match
+	if err := json.Unmarshal(rec.Body.Bytes(), &matches); err != nil {
+		t.Fatalf("failed to decode matches: %v", err)
+	}
+	return matches
+}
+
+func TestWatches_InboxFiresAtThreshold(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+	// [3, 4] and [4, 3] score 0.6 and 0.8 against [1, 0]
+	at := createWatch(t, vh, `{"embedding": [1, 0], "threshold": 0.6}`)
+	above := createWatch(t, vh, `{"embedding": [1, 0], "threshold": 0.7, "namespace": "incidents"}`)
+
+	createVector(t, vh, `{"id": "low", "embedding": [0, 1], "metadata": {"namespace": "incidents"}}`)
+	createVector(t, vh, `{"id": "other-dimension", "embedding": [1, 0, 0]}`)
+	rec := httptest.NewRecorder()
+	vh.StoreVectorBatch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/batch", bytes.NewBufferString(`{"vectors": [
+		{"id": "boundary", "embedding": [3, 4], "metadata": {"namespace": "incidents"}},
+		{"id": "close", "embedding": [4, 3], "metadata": {"namespace": "incidents", "title": "db-1 down"}},
+		{"id": "elsewhere", "embedding": [4, 3], "metadata": {"namespace": "docs"}}
+	]}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("store batch: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	matches := watchMatches(t, vh, at.ID)
+	if len(matches) != 3 || matches[0].VectorID != "elsewhere" || matches[2].VectorID != "boundary" || matches[2].Score != 0.6 {
+		t.Errorf("matches at 0.6 = %+v, want elsewhere, close and boundary newest first", matches)
+	}
+	matches = watchMatches(t, vh, above.ID)
+	if len(matches) != 1 || matches[0].VectorID != "close" || matches[0].Metadata["title"] != "db-1 down" {
+		t.Errorf("matches at 0.7 in incidents = %+v, want close", matches)
+	}
+
+	rec = httptest.NewRecorder()
+	vh.GetWatchMetrics(rec, httptest.NewRequest(http.MethodGet, "/api/v1/watches/metrics", nil))
+	var metrics watchMetrics
+	json.Unmarshal(rec.Body.Bytes(), &metrics)
+	if metrics.Watches != 2 || metrics.Evaluations != 3 || metrics.Vectors != 5 || metrics.Skipped != 1 || metrics.Comparisons != 7 || metrics.Matches != 4 {
+		t.Errorf("metrics = %+v", metrics)
+	}
+}
+
+func TestWatches_DisableAndExpire(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+	created := createWatch(t, vh, `{"embedding": [1, 0], "threshold": 0.5}`)
+
+	update := func(body string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/watches/"+created.ID, bytes.NewBufferString(body))
+		vh.UpdateWatch(rec, mux.SetURLVars(req, map[string]string{"id": created.ID}))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("update watch: status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	update(`{"enabled": false}`)
+	createVector(t, vh, `{"id": "while-disabled", "embedding": [1, 0]}`)
+	update(`{"enabled": true}`)
+	createVector(t, vh, `{"id": "enabled", "embedding": [1, 0]}`)
+	update(`{"expires_at": "2000-01-01T00:00:00Z"}`)
+	createVector(t, vh, `{"id": "after-expiry", "embedding": [1, 0]}`)
+
+	matches := watchMatches(t, vh, created.ID)
+	if len(matches) != 1 || matches[0].VectorID != "enabled" {
+		t.Errorf("matches = %+v, want only the vector stored while enabled", matches)
+	}
+
+	rec := httptest.NewRecorder()
+	vh.GetWatch(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/watches/"+created.ID, nil), map[string]string{"id": created.ID}))
+	var resp struct {
+		State string `json:"state"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.State != watch.StateExpired {
+		t.Errorf("state = %q, want expired", resp.State)
+	}
+}
+
+func TestWatches_WebhookRetries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	var delivered webhookPayload
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &delivered)
+	}))
+	defer hook.Close()
+
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+	vh.watches.retryDelay = time.Millisecond
+	retried := createWatch(t, vh, `{"embedding": [1, 0], "threshold": 0.5, "webhook": "`+hook.URL+`"}`)
+	failing := createWatch(t, vh, `{"embedding": [1, 0], "threshold": 0.5, "webhook": "`+hook.URL+`/gone", "retries": 0}`)
+
+	createVector(t, vh, `{"id": "match", "embedding": [1, 0], "metadata": {"title": "disk full"}}`)
+	vh.watches.deliveries.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 3 || delivered.WatchID != retried.ID || len(delivered.Matches) != 1 || delivered.Matches[0].Metadata["title"] != "disk full" {
+		t.Errorf("after %d attempts delivered %+v", attempts, delivered)
+	}
+
+	vh.watches.mu.Lock()
+	defer vh.watches.mu.Unlock()
+	if stats := vh.watches.find(retried.ID).Stats; stats.Delivered != 1 || stats.Failed != 0 {
+		t.Errorf("retried watch stats = %+v", stats)
+	}
+	if stats := vh.watches.find(failing.ID).Stats; stats.Failed != 1 || stats.Delivered != 0 || stats.LastError == "" {
+		t.Errorf("failing watch stats = %+v", stats)
+	}
+	if m := vh.watches.metrics; m.WebhookRetries != 2 || m.Deliveries != 1 || m.DeliveryFailures != 1 {
+		t.Errorf("metrics = %+v, want the retries counted", vh.watches.metrics)
+	}
+}
+
+func TestWatches_PersistWithLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := local.NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	vh := NewVectorHandler(adapter, hash.NewHashEmbedder())
+	created := createWatch(t, vh, `{"query": "database outage", "threshold": 0.99}`)
+
+	embedding, _ := hash.NewHashEmbedder().Embed("database outage")
+	vector, _ := json.Marshal(&models.Vector{ID: "same-text", Embedding: embedding})
+	createVector(t, vh, string(vector))
+
+	reopened, err := local.NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	vh = NewVectorHandler(reopened, hash.NewHashEmbedder())
+	if loaded, err := vh.LoadWatches(); err != nil || loaded != 1 {
+		t.Fatalf("LoadWatches() = %d, %v", loaded, err)
+	}
+	if matches := watchMatches(t, vh, created.ID); len(matches) != 1 || matches[0].VectorID != "same-text" {
+		t.Errorf("persisted inbox = %+v", matches)
+	}
+
+	rec := httptest.NewRecorder()
+	vh.DeleteWatch(rec, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/v1/watches/"+created.ID, nil), map[string]string{"id": created.ID}))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete watch: status = %d", rec.Code)
+	}
+	if watches, _ := reopened.Watches(); len(watches) != 0 {
+		t.Errorf("watches after delete = %d", len(watches))
+	}
+}
+
+func TestWatches_InvalidRequests(t *testing.T) {
+	vh := NewVectorHandler(memory.NewStorage(), hash.NewHashEmbedder())
+	for _, body := range []string{`{"threshold": 0.5}`, `{"query": "q", "threshold": 2}`, `{"query": "q", "webhook": "not a url"}`, `{`} {
+		rec := httptest.NewRecorder()
+		vh.CreateWatch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/watches", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	vh.GetWatch(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/watches/unknown", nil), map[string]string{"id": "unknown"}))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown watch: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// batchingEmbedder embeds batches of texts, failing the texts containing substr on their
+// own and the whole batch when a text contains reject
+type batchingEmbedder struct {
+	embedders.Embedder
+	substr, reject string
+	batches        [][]string
+	single         int
+}
+
+func (e *batchingEmbedder) Embed(text string) ([]float64, error) {
+	e.single++
+	if strings.Contains(text, e.substr) {
+		return nil, fmt.Errorf("embedding service unavailable")
+	}
+	return e.Embedder.Embed(text)
+}
+
+func (e *batchingEmbedder) EmbedBatch(texts []string) ([][]float64, error) {
+	e.batches = append(e.batches, texts)
+	failed := &embedders.BatchError{Errors: make(map[int]error)}
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		if e.reject != "" && strings.Contains(text, e.reject) {
+			return nil, fmt.Errorf("request rejected")
+		}
+		if strings.Contains(text, e.substr) {
+			failed.Errors[i] = fmt.Errorf("embedding service unavailable")
+			continue
+		}
+		embeddings[i], _ = e.Embedder.Embed(text)
+	}
+	if len(failed.Errors) > 0 {
+		return embeddings, failed
+	}
+	return embeddings, nil
+}
+
+func TestIngestor_BatchEmbedder(t *testing.T) {
+	config := &SourceConfig{BatchSize: 3, Quarantine: true}
+	source, err := NewFileSource(reviewsFixture, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := memory.NewStorage()
+	embedder := &batchingEmbedder{Embedder: hash.NewHashEmbedder(), substr: "Unlabeled"}
+	stats, err := NewIngestor(source, embedder, store, config).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Texts are embedded a batch at a time, the last batch holding what remains
+	texts := 0
+	for i, batch := range embedder.batches {
+		if len(batch) > config.BatchSize || (len(batch) < config.BatchSize && i < len(embedder.batches)-1) {
+			t.Errorf("batch %d holds %d texts", i, len(batch))
+		}
+		texts += len(batch)
+	}
+	if texts != stats.TotalRecords || embedder.single != 0 {
+		t.Errorf("embedded %d texts in batches and %d alone, read %d records", texts, embedder.single, stats.TotalRecords)
+	}
+
+	// Only the texts failing are reported, the rest of their batch is stored
+	failed := stats.FailureReasons["embed_error"]
+	if failed == 0 || stats.Quarantined != failed || stats.SuccessCount+failed != stats.TotalRecords || store.Count() != stats.SuccessCount {
+		t.Errorf("stored %d, failed %v, quarantined %d of %d records", store.Count(), stats.FailureReasons, stats.Quarantined, stats.TotalRecords)
+	}
+}
+
+func TestIngestor_BatchEmbedderFailure(t *testing.T) {
+	// A batch failing as a whole is embedded record by record
+	config := &SourceConfig{BatchSize: 100}
+	source, err := NewFileSource(reviewsFixture, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := memory.NewStorage()
+	embedder := &batchingEmbedder{Embedder: hash.NewHashEmbedder(), substr: "Unlabeled", reject: "Unlabeled"}
+	stats, err := NewIngestor(source, embedder, store, config).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embedder.batches) != 1 || embedder.single != stats.TotalRecords {
+		t.Errorf("%d batches, %d texts embedded alone of %d records", len(embedder.batches), embedder.single, stats.TotalRecords)
+	}
+	failed := stats.FailureReasons["embed_error"]
+	if failed == 0 || stats.SuccessCount+failed != stats.TotalRecords || store.Count() != stats.SuccessCount {
+		t.Errorf("stored %d, failed %v of %d records", store.Count(), stats.FailureReasons, stats.TotalRecords)
+	}
+}
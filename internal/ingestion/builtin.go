@@ -14,6 +14,7 @@ type BuiltinSource struct {
 	dataset string
 	file    *os.File
 	scanner *bufio.Scanner
+	line    int
 	config  *SourceConfig
 }
 
@@ -57,6 +58,7 @@ func (s *BuiltinSource) Next() (*Record, error) {
 		}
 		return nil, io.EOF
 	}
+	s.line++
 	
 	line := strings.TrimSpace(s.scanner.Text())
 	if line == "" {
@@ -82,6 +84,7 @@ func (s *BuiltinSource) Next() (*Record, error) {
 			"author": author,
 			"type":   "quote",
 		},
+		Index: s.line,
 	}
 	
 	if s.config.Namespace != "" {
@@ -0,0 +1,331 @@
+package ingestion
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// FieldType is the type a metadata field is coerced to at ingest time
+// Metadata values are strings, so coercion rewrites them in a canonical form
+// that filters compare reliably: plain integers and decimals, "true" or "false",
+// and RFC 3339 datetimes in UTC
+type FieldType string
+
+const (
+	FieldInt      FieldType = "int"
+	FieldFloat    FieldType = "float"
+	FieldBool     FieldType = "bool"
+	FieldDatetime FieldType = "datetime"
+)
+
+// ParseFieldTypes parses --field-type hints such as "year:int"
+func ParseFieldTypes(hints []string) (map[string]FieldType, error) {
+	types := make(map[string]FieldType, len(hints))
+	for _, hint := range hints {
+		field, name, ok := strings.Cut(hint, ":")
+		field = strings.TrimSpace(field)
+		if !ok || field == "" {
+			return nil, fmt.Errorf("invalid field type %q: want field:type", hint)
+		}
+		switch t := FieldType(strings.ToLower(strings.TrimSpace(name))); t {
+		case FieldInt, FieldFloat, FieldBool, FieldDatetime:
+			types[field] = t
+		default:
+			return nil, fmt.Errorf("invalid field type %q: type must be int, float, bool or datetime", hint)
+		}
+	}
+	return types, nil
+}
+
+// datetimeLayouts are the datetime forms coerced to RFC 3339, tried in order
+// Layouts without a zone are read as UTC
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// CoerceValue rewrites value in the canonical form of t
+func CoerceValue(value string, t FieldType) (string, error) {
+	trimmed := strings.TrimSpace(value)
+
+	switch t {
+	case FieldInt:
+		if i, err := strconv.ParseInt(stripSeparators(trimmed), 10, 64); err == nil {
+			return strconv.FormatInt(i, 10), nil
+		}
+		// Integral decimals such as "1900.0"
+		f, err := parseNumber(trimmed)
+		if err != nil {
+			return "", err
+		}
+		if f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return "", fmt.Errorf("%q is not an integer", value)
+		}
+		return strconv.FormatInt(int64(f), 10), nil
+
+	case FieldFloat:
+		f, err := parseNumber(trimmed)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+
+	case FieldBool:
+		switch strings.ToLower(trimmed) {
+		case "true", "t", "yes", "y", "1":
+			return "true", nil
+		case "false", "f", "no", "n", "0":
+			return "false", nil
+		}
+		return "", fmt.Errorf("%q is not a boolean", value)
+
+	case FieldDatetime:
+		for _, layout := range datetimeLayouts {
+			if parsed, err := time.Parse(layout, trimmed); err == nil {
+				return parsed.UTC().Format(time.RFC3339), nil
+			}
+		}
+		// Unix timestamps in seconds
+		if seconds, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return time.Unix(seconds, 0).UTC().Format(time.RFC3339), nil
+		}
+		return "", fmt.Errorf("%q is not a datetime", value)
+	}
+
+	return value, nil
+}
+
+// parseNumber parses a decimal number, allowing thousands separators such as "1,900"
+func parseNumber(s string) (float64, error) {
+	f, err := strconv.ParseFloat(stripSeparators(s), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return f, nil
+}
+
+// stripSeparators removes the thousands separators of a number
+func stripSeparators(s string) string {
+	return strings.NewReplacer(",", "", "_", "", " ", "").Replace(s)
+}
+
+// inferType returns the type all sampled values of a field have, "" when they disagree
+// Values with leading zeros, such as postcodes, are not taken for numbers
+func inferType(values []string) FieldType {
+	for _, t := range []FieldType{FieldInt, FieldFloat, FieldDatetime} {
+		matched := 0
+		for _, value := range values {
+			trimmed := strings.TrimSpace(value)
+			if trimmed == "" {
+				continue
+			}
+			if t != FieldDatetime && hasLeadingZero(trimmed) {
+				matched = -1
+				break
+			}
+			if t == FieldDatetime && isDigits(trimmed) {
+				// Bare numbers are not guessed to be timestamps
+				matched = -1
+				break
+			}
+			if _, err := CoerceValue(trimmed, t); err != nil {
+				matched = -1
+				break
+			}
+			matched++
+		}
+		if matched > 0 {
+			return t
+		}
+	}
+	return ""
+}
+
+func hasLeadingZero(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 1 && s[0] == '0' && s[1] != '.'
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// coercer rewrites the metadata values of typed fields
+// It is shared by the files of a CompositeSource, the first sample inferring the types
+type coercer struct {
+	mu       sync.RWMutex
+	types    map[string]FieldType
+	inferred bool
+	drop     bool // Drop values that cannot be coerced instead of keeping them as they are
+}
+
+// newCoercer returns the coercer of the configured field types, nil when there is nothing to coerce
+func newCoercer(config *SourceConfig) *coercer {
+	if len(config.FieldTypes) == 0 && config.InferTypes <= 0 {
+		return nil
+	}
+	types := make(map[string]FieldType, len(config.FieldTypes))
+	for field, t := range config.FieldTypes {
+		types[field] = t
+	}
+	return &coercer{types: types, inferred: config.InferTypes <= 0, drop: config.DropInvalidValues}
+}
+
+// infer types the fields of the sampled records that have no type yet
+// Only the first sample is used, later calls return nil
+func (c *coercer) infer(records []*Record) map[string]FieldType {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inferred {
+		return nil
+	}
+	c.inferred = true
+
+	values := make(map[string][]string)
+	for _, record := range records {
+		for field, value := range record.Metadata {
+			if _, typed := c.types[field]; !typed && field != "namespace" {
+				values[field] = append(values[field], value)
+			}
+		}
+	}
+
+	inferred := make(map[string]FieldType)
+	for field, sample := range values {
+		if t := inferType(sample); t != "" {
+			c.types[field] = t
+			inferred[field] = t
+		}
+	}
+	return inferred
+}
+
+// sampling reports whether types are still to be inferred from a sample
+func (c *coercer) sampling() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.inferred
+}
+
+// coerce rewrites the typed fields of metadata, returning the fields whose value could not be coerced
+func (c *coercer) coerce(metadata map[string]string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var failed []string
+	for field, t := range c.types {
+		value, ok := metadata[field]
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+		coerced, err := CoerceValue(value, t)
+		if err != nil {
+			failed = append(failed, field)
+			if c.drop {
+				delete(metadata, field)
+			}
+			continue
+		}
+		metadata[field] = coerced
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// fieldTypes returns the types of the fields coerced, hinted or inferred
+func (c *coercer) fieldTypes() map[string]FieldType {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	types := make(map[string]FieldType, len(c.types))
+	for field, t := range c.types {
+		types[field] = t
+	}
+	return types
+}
+
+// sampleTypes reads the first InferTypes records of the source to infer the types
+// of untyped fields. next returns the sampled records before reading on
+func (ing *Ingestor) sampleTypes() {
+	if ing.coercer == nil || !ing.coercer.sampling() {
+		return
+	}
+
+	var sample []*Record
+	for len(sample) < ing.config.InferTypes {
+		record, err := ing.source.Next()
+		ing.buffered = append(ing.buffered, bufferedRecord{record, err})
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			sample = append(sample, record)
+		}
+	}
+
+	inferred := ing.coercer.infer(sample)
+	if ing.config.Verbose && len(inferred) > 0 {
+		fields := make([]string, 0, len(inferred))
+		for field, t := range inferred {
+			fields = append(fields, field+":"+string(t))
+		}
+		sort.Strings(fields)
+		fmt.Printf("Inferred field types: %s\n", strings.Join(fields, " "))
+	}
+}
+
+// bufferedRecord is a record, or read error, sampled ahead of the ingestion
+type bufferedRecord struct {
+	record *Record
+	err    error
+}
+
+// next returns the next sampled record, then those of the source
+func (ing *Ingestor) next() (*Record, error) {
+	if len(ing.buffered) > 0 {
+		next := ing.buffered[0]
+		ing.buffered = ing.buffered[1:]
+		return next.record, next.err
+	}
+	return ing.source.Next()
+}
+
+// coerceTypes rewrites the typed metadata fields of a record, counting the values that cannot be coerced
+func (ing *Ingestor) coerceTypes(record *Record) {
+	if ing.coercer == nil || record.Metadata == nil {
+		return
+	}
+	for _, field := range ing.coercer.coerce(record.Metadata) {
+		if ing.stats.CoercionFailures == nil {
+			ing.stats.CoercionFailures = make(map[string]int)
+		}
+		ing.stats.CoercionFailures[field]++
+		if ing.config.Verbose {
+			fmt.Printf("Record %d: %s value is not a %s\n", record.Index, field, ing.coercer.fieldTypes()[field])
+		}
+	}
+}
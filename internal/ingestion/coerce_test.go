@@ -0,0 +1,213 @@
+package ingestion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+const messyFixture = "testdata/csv/messy_types.csv"
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		value string
+		t     FieldType
+		want  string
+		fails bool
+	}{
+		{"1,900", FieldInt, "1900", false},
+		{" 1900 ", FieldInt, "1900", false},
+		{"1900.0", FieldInt, "1900", false},
+		{"-42", FieldInt, "-42", false},
+		{"1900.5", FieldInt, "", true},
+		{"n/a", FieldInt, "", true},
+		{"", FieldInt, "", true},
+		{"1,204.5", FieldFloat, "1204.5", false},
+		{" 7 ", FieldFloat, "7", false},
+		{"1e3", FieldFloat, "1000", false},
+		{"NaN", FieldFloat, "", true},
+		{"Yes", FieldBool, "true", false},
+		{"0", FieldBool, "false", false},
+		{"maybe", FieldBool, "", true},
+		{"2024-03-01", FieldDatetime, "2024-03-01T00:00:00Z", false},
+		{"2024-03-01 10:30:00", FieldDatetime, "2024-03-01T10:30:00Z", false},
+		{"2024-03-01T10:30:00+02:00", FieldDatetime, "2024-03-01T08:30:00Z", false},
+		{"26 May 1897", FieldDatetime, "1897-05-26T00:00:00Z", false},
+		{"Mar 1, 2024", FieldDatetime, "2024-03-01T00:00:00Z", false},
+		{"1709251200", FieldDatetime, "2024-03-01T00:00:00Z", false},
+		{"sometime in 1987", FieldDatetime, "", true},
+	}
+	for _, tt := range tests {
+		got, err := CoerceValue(tt.value, tt.t)
+		if (err != nil) != tt.fails || got != tt.want {
+			t.Errorf("CoerceValue(%q, %s) = %q, %v, want %q (failure %v)", tt.value, tt.t, got, err, tt.want, tt.fails)
+		}
+	}
+}
+
+func TestParseFieldTypes(t *testing.T) {
+	types, err := ParseFieldTypes([]string{"year:int", "published_at:DateTime", " score : float"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types["year"] != FieldInt || types["published_at"] != FieldDatetime || types["score"] != FieldFloat {
+		t.Errorf("types = %v", types)
+	}
+
+	for _, hint := range []string{"year", ":int", "year:number"} {
+		if _, err := ParseFieldTypes([]string{hint}); err == nil {
+			t.Errorf("ParseFieldTypes(%q) succeeded, want an error", hint)
+		}
+	}
+}
+
+func TestInferType(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   FieldType
+	}{
+		{[]string{"1,667", " 1871 ", ""}, FieldInt},
+		{[]string{"1", "2.5"}, FieldFloat},
+		{[]string{"2024-03-01", "1 Mar 2024"}, FieldDatetime},
+		{[]string{"01234", "10001"}, ""}, // Postcodes keep their leading zeros
+		{[]string{"1900", "1901"}, FieldInt},
+		{[]string{"12", "twelve"}, ""},
+		{[]string{"", " "}, ""},
+	}
+	for _, tt := range tests {
+		if got := inferType(tt.values); got != tt.want {
+			t.Errorf("inferType(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+// ingestMessy ingests the messy fixture and returns the stats and stored metadata by text
+func ingestMessy(t *testing.T, config *SourceConfig) (*Stats, map[string]map[string]string) {
+	t.Helper()
+	config.BatchSize = 10
+	source, err := NewFileSource(messyFixture, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := memory.NewStorage()
+	stats, err := NewIngestor(source, hash.NewHashEmbedder(), store, config).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Text is not kept in metadata, so records are told apart by their source row
+	vectors, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byRow := make(map[string]map[string]string)
+	for _, vector := range vectors {
+		byRow[vector.Metadata[models.LineageRecordKey]] = vector.Metadata
+	}
+	return stats, byRow
+}
+
+func TestIngestor_FieldTypes(t *testing.T) {
+	stats, rows := ingestMessy(t, &SourceConfig{FieldTypes: map[string]FieldType{
+		"year":         FieldInt,
+		"published_at": FieldDatetime,
+		"score":        FieldFloat,
+		"active":       FieldBool,
+	}})
+
+	if len(rows) != 5 {
+		t.Fatalf("stored %d rows, want 5", len(rows))
+	}
+	years := []string{rows["2"]["year"], rows["3"]["year"], rows["4"]["year"], rows["5"]["year"], rows["6"]["year"]}
+	want := []string{"1667", "1871", "1897", "1922", "n/a"}
+	for i := range want {
+		if years[i] != want[i] {
+			t.Errorf("years = %q, want %q with the unparseable value kept", years, want)
+			break
+		}
+	}
+	if got := rows["5"]["published_at"]; got != "1922-02-01T23:00:00Z" {
+		t.Errorf("published_at = %q, want it in UTC", got)
+	}
+	if got := rows["3"]["score"]; got != "1204.5" {
+		t.Errorf("score = %q", got)
+	}
+	if got := rows["2"]["zip"]; got != "01234" {
+		t.Errorf("untyped zip = %q, want it unchanged", got)
+	}
+
+	wantFailures := map[string]int{"year": 1, "published_at": 1, "score": 1, "active": 1}
+	for field, count := range wantFailures {
+		if stats.CoercionFailures[field] != count {
+			t.Errorf("coercion failures = %v, want %v", stats.CoercionFailures, wantFailures)
+			break
+		}
+	}
+	if stats.FieldTypes["year"] != FieldInt {
+		t.Errorf("field types = %v", stats.FieldTypes)
+	}
+
+	// Numeric and temporal filters now match the intended rows
+	evaluator := models.NewFilterEvaluator()
+	count := func(filters map[string]models.FilterExpr) int {
+		n := 0
+		for _, metadata := range rows {
+			if evaluator.Evaluate(metadata, filters) {
+				n++
+			}
+		}
+		return n
+	}
+	if n := count(map[string]models.FilterExpr{"year": {"gte": 1870}}); n != 3 {
+		t.Errorf("year >= 1870 matched %d rows, want 3", n)
+	}
+	if n := count(map[string]models.FilterExpr{"year": {"between": []interface{}{1800, 1900}}}); n != 2 {
+		t.Errorf("year between 1800 and 1900 matched %d rows, want 2", n)
+	}
+	if n := count(map[string]models.FilterExpr{"published_at": {"gte": "1871-12-01T00:00:00Z", "lt": "1900-01-01"}}); n != 2 {
+		t.Errorf("published_at in [1871-12-01, 1900) matched %d rows, want 2", n)
+	}
+	if n := count(map[string]models.FilterExpr{"score": {"gt": 1000}}); n != 1 {
+		t.Errorf("score > 1000 matched %d rows, want 1", n)
+	}
+}
+
+func TestIngestor_DropInvalidValues(t *testing.T) {
+	stats, rows := ingestMessy(t, &SourceConfig{
+		FieldTypes:        map[string]FieldType{"year": FieldInt},
+		DropInvalidValues: true,
+	})
+
+	if _, ok := rows["6"]["year"]; ok {
+		t.Errorf("unparseable year kept: %v", rows["6"])
+	}
+	if stats.CoercionFailures["year"] != 1 || stats.SuccessCount != 5 {
+		t.Errorf("stats = %d stored, failures %v", stats.SuccessCount, stats.CoercionFailures)
+	}
+}
+
+func TestIngestor_InferTypes(t *testing.T) {
+	stats, rows := ingestMessy(t, &SourceConfig{
+		FieldTypes: map[string]FieldType{"active": FieldBool},
+		InferTypes: 3,
+	})
+
+	want := map[string]FieldType{"year": FieldInt, "published_at": FieldDatetime, "score": FieldFloat, "active": FieldBool}
+	for field, wantType := range want {
+		if stats.FieldTypes[field] != wantType {
+			t.Errorf("field types = %v, want %v", stats.FieldTypes, want)
+			break
+		}
+	}
+	if _, typed := stats.FieldTypes["zip"]; typed {
+		t.Errorf("zip typed as %s, want postcodes left alone", stats.FieldTypes["zip"])
+	}
+
+	// The sampled rows are ingested and coerced like the others
+	if len(rows) != 5 || rows["2"]["year"] != "1667" || rows["4"]["published_at"] != "1897-05-26T00:00:00Z" {
+		t.Errorf("rows = %v", rows)
+	}
+}
@@ -0,0 +1,221 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// CompositeSource reads several sources, such as the shards of a dataset, as one
+// Records of files are tagged with the file path under models.LineageFileKey
+//
+// Read directly it goes through the sources in order, skipping those that cannot
+// be opened unless FailFast is set. An Ingestor ingests each source separately,
+// SourceConfig.ParallelFiles at a time, and breaks its stats down per source
+type CompositeSource struct {
+	parts   []*compositePart
+	current int
+	ctx     context.Context
+	config  *SourceConfig
+}
+
+// compositePart is a source of a CompositeSource, tagging its records with the file they came from
+type compositePart struct {
+	Source
+	file string // Empty for sources that are not files
+}
+
+func (p *compositePart) Next() (*Record, error) {
+	record, err := p.Source.Next()
+	if err != nil || p.file == "" {
+		return record, err
+	}
+	if record.Metadata == nil {
+		record.Metadata = make(map[string]string)
+	}
+	record.Metadata[models.LineageFileKey] = p.file
+	return record, nil
+}
+
+// NewCompositeSource creates a source reading each of sources in turn
+func NewCompositeSource(sources []Source, config *SourceConfig) *CompositeSource {
+	parts := make([]*compositePart, len(sources))
+	for i, source := range sources {
+		part := &compositePart{Source: source}
+		if file, ok := source.(interface{ Path() string }); ok {
+			part.file = file.Path()
+		}
+		parts[i] = part
+	}
+	return &CompositeSource{parts: parts, config: config}
+}
+
+// Sources returns the number of sources read
+func (s *CompositeSource) Sources() int {
+	return len(s.parts)
+}
+
+func (s *CompositeSource) Open(ctx context.Context) error {
+	s.ctx = ctx
+	s.current = 0
+	return s.openCurrent()
+}
+
+// openCurrent opens the current source, moving past those that fail unless FailFast is set
+func (s *CompositeSource) openCurrent() error {
+	for ; s.current < len(s.parts); s.current++ {
+		part := s.parts[s.current]
+		err := part.Open(s.ctx)
+		if err == nil {
+			return nil
+		}
+		if s.config.FailFast {
+			return fmt.Errorf("%s: %w", part.Name(), err)
+		}
+		if s.config.Verbose {
+			fmt.Printf("Skipping %s: %v\n", part.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (s *CompositeSource) Next() (*Record, error) {
+	for s.current < len(s.parts) {
+		record, err := s.parts[s.current].Next()
+		if err != io.EOF {
+			return record, err
+		}
+
+		s.parts[s.current].Close()
+		s.current++
+		if err := s.openCurrent(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, io.EOF
+}
+
+func (s *CompositeSource) Close() error {
+	if s.current < len(s.parts) {
+		return s.parts[s.current].Close()
+	}
+	return nil
+}
+
+func (s *CompositeSource) Name() string {
+	return fmt.Sprintf("composite:%d sources", len(s.parts))
+}
+
+// errNotStarted is the error of the sources a stopped run never got to
+var errNotStarted = errors.New("not ingested, the run was stopped")
+
+// runFiles ingests each source of composite with its own Ingestor, sharing the
+// run ID and image deduplication, and merges their stats into those of ing
+func (ing *Ingestor) runFiles(ctx context.Context, composite *CompositeSource) (*Stats, error) {
+	parallel := ing.config.ParallelFiles
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*Stats, len(composite.parts))
+	errs := make([]error, len(composite.parts))
+	var failure error
+	var failOnce sync.Once
+
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, part := range composite.parts {
+		slots <- struct{}{}
+		if runCtx.Err() != nil {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, part *compositePart) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			child := ing.child(part, i+1)
+			_, err := child.ingest(runCtx)
+			if child.stats.EndTime.IsZero() {
+				child.finish()
+			}
+			results[i], errs[i] = child.stats, err
+			if err == nil {
+				return
+			}
+
+			fmt.Printf("Failed to ingest %s: %v\n", part.Name(), err)
+			if ing.config.FailFast {
+				failOnce.Do(func() {
+					failure = fmt.Errorf("%s: %w", part.Name(), err)
+					cancel()
+				})
+			}
+		}(i, part)
+	}
+	wg.Wait()
+
+	for i, part := range composite.parts {
+		stats, err := results[i], errs[i]
+		if stats == nil {
+			stats, err = &Stats{}, errNotStarted
+		}
+		ing.stats.add(stats)
+
+		file := FileStats{
+			Source:     part.Name(),
+			Total:      stats.TotalRecords,
+			Succeeded:  stats.SuccessCount,
+			Failed:     stats.FailureCount,
+			Skipped:    stats.SkippedCount,
+			DurationMs: stats.Duration.Milliseconds(),
+		}
+		if err != nil {
+			file.Error = err.Error()
+		}
+		ing.stats.Files = append(ing.stats.Files, file)
+	}
+	ing.finish()
+
+	if failure != nil {
+		return ing.stats, failure
+	}
+	if err := ctx.Err(); err != nil {
+		return ing.stats, err
+	}
+	return ing.stats, nil
+}
+
+// child returns the Ingestor of one source of a composite run
+func (ing *Ingestor) child(source Source, file int) *Ingestor {
+	return &Ingestor{
+		source:   source,
+		embedder: ing.embedder,
+		storage:  ing.storage,
+		config:   ing.config,
+		dedup:    ing.dedup,
+		coercer:  ing.coercer,
+		filters:  ing.filters,
+		stored:   ing.stored,
+		emitter:  ing.emitter,
+		file:     file,
+		stats: &Stats{
+			FailureReasons: make(map[string]int),
+			Namespace:      ing.stats.Namespace,
+			StorageType:    ing.stats.StorageType,
+			Embedder:       ing.stats.Embedder,
+			RunID:          ing.stats.RunID,
+			StartTime:      time.Now(),
+		},
+	}
+}
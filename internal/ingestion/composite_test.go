@@ -0,0 +1,160 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// writeShards writes n JSONL files of records lines each and returns their paths
+func writeShards(t *testing.T, n, records int) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, n)
+	for i := range paths {
+		var content strings.Builder
+		for j := 0; j < records; j++ {
+			fmt.Fprintf(&content, `{"text": "shard %d record %d"}`+"\n", i, j)
+		}
+		paths[i] = filepath.Join(dir, fmt.Sprintf("shard-%d.jsonl", i))
+		if err := os.WriteFile(paths[i], []byte(content.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func compositeOf(t *testing.T, paths []string, config *SourceConfig) *CompositeSource {
+	t.Helper()
+	sources := make([]Source, len(paths))
+	for i, path := range paths {
+		source, err := NewFileSource(path, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[i] = source
+	}
+	return NewCompositeSource(sources, config)
+}
+
+func TestCompositeSource_Next(t *testing.T) {
+	shards := writeShards(t, 2, 2)
+	missing := filepath.Join(t.TempDir(), "missing.jsonl")
+	source := compositeOf(t, []string{shards[0], missing, shards[1]}, &SourceConfig{})
+
+	if err := source.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	var files []string
+	for {
+		record, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, record.Metadata[models.LineageFileKey])
+	}
+	want := []string{shards[0], shards[0], shards[1], shards[1]}
+	if fmt.Sprint(files) != fmt.Sprint(want) {
+		t.Errorf("record files = %v, want %v", files, want)
+	}
+}
+
+func TestCompositeSource_FailFast(t *testing.T) {
+	shards := writeShards(t, 1, 2)
+	missing := filepath.Join(t.TempDir(), "missing.jsonl")
+	source := compositeOf(t, []string{shards[0], missing}, &SourceConfig{FailFast: true})
+
+	if err := source.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := source.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := source.Next(); err == nil || err == io.EOF {
+		t.Errorf("Next() error = %v, want the open error of the missing file", err)
+	}
+}
+
+func TestIngestor_ParallelFiles(t *testing.T) {
+	shards := writeShards(t, 5, 20)
+	paths := append(shards, filepath.Join(t.TempDir(), "missing.jsonl"))
+	config := &SourceConfig{Namespace: "shards", BatchSize: 7, ParallelFiles: 3}
+
+	store := memory.NewStorage()
+	stats, err := NewIngestor(compositeOf(t, paths, config), hash.NewHashEmbedder(), store, config).Run(context.Background())
+	if err != nil {
+		t.Fatalf("ingest failed: %v", err)
+	}
+
+	if stats.TotalRecords != 100 || stats.SuccessCount != 100 {
+		t.Errorf("stats = %d read, %d stored, want 100 of each", stats.TotalRecords, stats.SuccessCount)
+	}
+	if len(stats.Files) != len(paths) {
+		t.Fatalf("file breakdown has %d entries, want %d", len(stats.Files), len(paths))
+	}
+	for i, file := range stats.Files[:5] {
+		if file.Source != fmt.Sprintf("file:shard-%d.jsonl", i) || file.Succeeded != 20 || file.Error != "" {
+			t.Errorf("file %d = %+v", i, file)
+		}
+	}
+	if stats.Files[5].Error == "" {
+		t.Errorf("missing file = %+v, want an error", stats.Files[5])
+	}
+
+	vectors, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 100 {
+		t.Errorf("stored %d vectors, want 100 with distinct IDs", len(vectors))
+	}
+	for _, vector := range vectors {
+		if vector.Metadata[models.LineageFileKey] == "" || vector.Metadata[models.LineageRunKey] != stats.RunID {
+			t.Fatalf("metadata of %s = %v, want the file and run", vector.ID, vector.Metadata)
+		}
+	}
+
+	runs, err := store.ListRuns(models.IngestRunFilter{Namespace: "shards"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Stored != 100 {
+		t.Errorf("recorded runs = %+v, want one run of 100", runs)
+	}
+}
+
+func TestIngestor_FailFastFiles(t *testing.T) {
+	shards := writeShards(t, 2, 3)
+	paths := []string{filepath.Join(t.TempDir(), "missing.jsonl"), shards[0], shards[1]}
+	config := &SourceConfig{BatchSize: 10, ParallelFiles: 1, FailFast: true}
+
+	stats, err := NewIngestor(compositeOf(t, paths, config), hash.NewHashEmbedder(), memory.NewStorage(), config).Run(context.Background())
+	if err == nil {
+		t.Fatal("ingest succeeded, want the error of the missing file")
+	}
+	if stats == nil || stats.SuccessCount != 0 || len(stats.Files) != 3 {
+		t.Fatalf("stats = %+v, want nothing stored and 3 files", stats)
+	}
+	for _, file := range stats.Files {
+		if file.Error == "" {
+			t.Errorf("file %s has no error, want it failed or not started", file.Source)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package ingestion
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// utf8BOM starts the CSV files of Excel and other Windows tools
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// csvDelimiters are the delimiters auto-detection chooses from, in order of preference on ties
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// ParseDelimiter parses a --delimiter value: "auto" or empty detects it from the
+// header line, "tab" or "\t" is a tab, anything else must be a single character
+func ParseDelimiter(value string) (rune, error) {
+	switch value {
+	case "", "auto":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	runes := []rune(value)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q: must be auto, tab or a single character other than a quote or newline", value)
+	}
+	return runes[0], nil
+}
+
+// newCSVReader returns a reader of r without its byte order mark, splitting fields
+// on delimiter, or on the delimiter detected from the header line when it is 0.
+// Quotes are parsed leniently and rows may have any number of fields, so a
+// malformed row does not abort the file; \r\n line endings read as \n
+func newCSVReader(r io.Reader, delimiter rune) (*csv.Reader, error) {
+	buffered := bufio.NewReader(r)
+	if start, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(start, utf8BOM) {
+		buffered.Discard(len(utf8BOM))
+	}
+
+	if delimiter == 0 {
+		header, err := peekLine(buffered)
+		if err != nil {
+			return nil, err
+		}
+		delimiter = detectDelimiter(header)
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.Comma = delimiter
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	return reader, nil
+}
+
+// peekLine returns the first line of r without consuming it, up to the buffer size
+func peekLine(r *bufio.Reader) ([]byte, error) {
+	for size := 512; ; size *= 2 {
+		data, err := r.Peek(size)
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			return data[:i], nil
+		}
+		if err != nil {
+			if err == io.EOF || err == bufio.ErrBufferFull {
+				return data, nil
+			}
+			return nil, fmt.Errorf("failed to read CSV headers: %w", err)
+		}
+	}
+}
+
+// detectDelimiter returns the candidate delimiter occurring most often outside
+// quotes in a header line, a comma when none occurs
+func detectDelimiter(header []byte) rune {
+	counts := make(map[rune]int, len(csvDelimiters))
+	quoted := false
+	for _, c := range string(header) {
+		if c == '"' {
+			quoted = !quoted
+			continue
+		}
+		if !quoted {
+			counts[c]++
+		}
+	}
+
+	best := csvDelimiters[0]
+	for _, candidate := range csvDelimiters {
+		if counts[candidate] > counts[best] {
+			best = candidate
+		}
+	}
+	return best
+}
@@ -0,0 +1,144 @@
+package ingestion
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestParseDelimiter(t *testing.T) {
+	tests := map[string]rune{"": 0, "auto": 0, "tab": '\t', `\t`: '\t', ";": ';', ",": ',', "|": '|'}
+	for value, want := range tests {
+		if got, err := ParseDelimiter(value); err != nil || got != want {
+			t.Errorf("ParseDelimiter(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	for _, value := range []string{`"`, ";;", "\n"} {
+		if _, err := ParseDelimiter(value); err == nil {
+			t.Errorf("ParseDelimiter(%q) accepted", value)
+		}
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := map[string]rune{
+		"text,author,year":     ',',
+		"text;author;year":     ';',
+		"text\tauthor":         '\t',
+		`"a,b";c;d`:            ';',
+		"text":                 ',',
+		"text|author|year,ish": '|',
+	}
+	for header, want := range tests {
+		if got := detectDelimiter([]byte(header)); got != want {
+			t.Errorf("detectDelimiter(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestFileSource_CSVPathologies(t *testing.T) {
+	tests := []struct {
+		file       string
+		delimiter  rune
+		texts      []string
+		metadata   map[string]map[string]string // By text
+		mismatches int
+	}{
+		{
+			file:     "bom_crlf.csv",
+			texts:    []string{"Be yourself", "Stay hungry"},
+			metadata: map[string]map[string]string{"Stay hungry": {"author": "Jobs"}},
+		},
+		{
+			file:     "semicolon.csv",
+			texts:    []string{"La vie est belle", "Das Leben ist kurz"},
+			metadata: map[string]map[string]string{"Das Leben ist kurz": {"author": "Goethe; J. W.", "year": "1808"}},
+		},
+		{
+			file:      "semicolon.csv",
+			delimiter: ';',
+			texts:     []string{"La vie est belle", "Das Leben ist kurz"},
+		},
+		{
+			file:     "tab.csv",
+			texts:    []string{"first line", "second line"},
+			metadata: map[string]map[string]string{"second line": {"author": "two"}},
+		},
+		{
+			file:     "multiline.csv",
+			texts:    []string{"A quote\nspanning lines", `Said "hi" twice`, "plain"},
+			metadata: map[string]map[string]string{"plain": {"author": "Carol"}},
+		},
+		{
+			file:       "ragged.csv",
+			texts:      []string{"short row", "full row", "long row"},
+			metadata:   map[string]map[string]string{"short row": {"author": "Dana"}, "long row": {"author": "Finn", "year": "1999"}},
+			mismatches: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			config := &SourceConfig{BatchSize: 10}
+			open := func() *FileSource {
+				source, err := NewFileSource(filepath.Join("testdata", "csv", tt.file), config)
+				if err != nil {
+					t.Fatal(err)
+				}
+				source.SetDelimiter(tt.delimiter)
+				return source
+			}
+
+			source := open()
+			if err := source.Open(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			records := make(map[string]*Record)
+			for {
+				record, err := source.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("read: %v", err)
+				}
+				records[record.Text] = record
+			}
+			source.Close()
+
+			for _, text := range tt.texts {
+				record, ok := records[text]
+				if !ok {
+					t.Errorf("record %q not read, read %d records", text, len(records))
+					continue
+				}
+				for key := range record.Metadata {
+					if strings.HasPrefix(key, "\ufeff") || strings.ContainsAny(key, "\r;\t") {
+						t.Errorf("malformed metadata key %q", key)
+					}
+				}
+				for key, want := range tt.metadata[text] {
+					if got := record.Metadata[key]; got != want {
+						t.Errorf("%q metadata %s = %q, want %q", text, key, got, want)
+					}
+				}
+			}
+
+			stats, err := NewIngestor(open(), hash.NewHashEmbedder(), memory.NewStorage(), config).Run(context.Background())
+			if err != nil {
+				t.Fatalf("ingest failed: %v", err)
+			}
+			if stats.TotalRecords != len(tt.texts) || stats.SuccessCount != len(tt.texts) || stats.FailureCount != 0 {
+				t.Errorf("stats = %d read, %d stored, %d failed, want %d records", stats.TotalRecords, stats.SuccessCount, stats.FailureCount, len(tt.texts))
+			}
+			if stats.ColumnMismatches != tt.mismatches {
+				t.Errorf("column mismatches = %d, want %d", stats.ColumnMismatches, tt.mismatches)
+			}
+		})
+	}
+}
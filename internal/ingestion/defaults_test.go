@@ -0,0 +1,56 @@
+package ingestion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestIngestor_DefaultMetadata(t *testing.T) {
+	run := func(config *defaults.Config) (*Stats, *memory.Storage) {
+		t.Helper()
+		sourceConfig := &SourceConfig{BatchSize: 2, DefaultMetadata: config}
+		source, err := NewFileSource(reviewsFixture, sourceConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := memory.NewStorage()
+		stats, err := NewIngestor(source, hash.NewHashEmbedder(), store, sourceConfig).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats, store
+	}
+
+	// Defaults fill missing keys without overwriting the values of records
+	stats, store := run(&defaults.Config{Metadata: map[string]string{"environment": "prod", "label": "pos"}})
+	vectors, _ := store.List()
+	if stats.SuccessCount != stats.TotalRecords || len(vectors) != stats.TotalRecords {
+		t.Fatalf("stored %d of %d records", len(vectors), stats.TotalRecords)
+	}
+	labels := make(map[string]bool)
+	for _, vector := range vectors {
+		if vector.Metadata["environment"] != "prod" {
+			t.Errorf("vector %s lacks the default: %v", vector.ID, vector.Metadata)
+		}
+		labels[vector.Metadata["label"]] = true
+	}
+	if !labels["neg"] {
+		t.Errorf("record labels were overwritten: %v", labels)
+	}
+
+	// Enforced, the records with another value of a locked key fail
+	stats, store = run(&defaults.Config{Metadata: map[string]string{"label": "pos"}, Enforce: true})
+	vectors, _ = store.List()
+	if stats.FailureReasons["locked_metadata"] == 0 || stats.SuccessCount+stats.FailureReasons["locked_metadata"] != stats.TotalRecords {
+		t.Errorf("enforced run stored %d, failures %v", stats.SuccessCount, stats.FailureReasons)
+	}
+	for _, vector := range vectors {
+		if vector.Metadata["label"] != "pos" {
+			t.Errorf("vector %s stored with label %q", vector.ID, vector.Metadata["label"])
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package ingestion
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestIngestor_RejectsOversizedEmbeddings(t *testing.T) {
+	models.SetMaxEmbeddingDimension(hash.DefaultDimension - 1)
+	t.Cleanup(func() { models.SetMaxEmbeddingDimension(0) })
+
+	path := filepath.Join(t.TempDir(), "quotes.jsonl")
+	if err := os.WriteFile(path, []byte(`{"text": "to be or not to be"}`+"\n"+`{"text": "carpe diem"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &SourceConfig{BatchSize: 10}
+	source, err := NewFileSource(path, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := memory.NewStorage()
+	stats, err := NewIngestor(source, hash.NewHashEmbedder(), store, config).Run(context.Background())
+	if err != nil {
+		t.Fatalf("ingest failed: %v", err)
+	}
+
+	if stats.FailureCount != 2 || stats.FailureReasons["dimension_too_large"] != 2 || store.Count() != 0 {
+		t.Errorf("stats = %+v with %d stored, want both records rejected", stats, store.Count())
+	}
+}
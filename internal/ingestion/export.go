@@ -0,0 +1,88 @@
+package ingestion
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// ExportOptions configures ExportRun
+type ExportOptions struct {
+	// RunID selects the vectors stored by one ingest run, all the stored vectors when empty
+	RunID string
+
+	// NoEmbeddings leaves the embeddings out, exporting the IDs, metadata and timestamps
+	NoEmbeddings bool
+}
+
+// ExportRun writes the vectors of an ingest run to path, in the order they were stored:
+// a JSON array when path ends in .json, one vector per line otherwise. It returns the
+// number of vectors written
+func ExportRun(s storage.Storage, path string, opts ExportOptions) (int, error) {
+	vectors, err := s.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list vectors: %w", err)
+	}
+
+	exported := make([]*models.Vector, 0, len(vectors))
+	for _, vector := range vectors {
+		if opts.RunID != "" && vector.Metadata[models.LineageRunKey] != opts.RunID {
+			continue
+		}
+		if opts.NoEmbeddings {
+			copied := *vector
+			copied.Embedding, copied.Sparse, copied.DType = nil, nil, ""
+			vector = &copied
+		}
+		exported = append(exported, vector)
+	}
+	sort.SliceStable(exported, func(i, j int) bool {
+		if !exported[i].CreatedAt.Equal(exported[j].CreatedAt) {
+			return exported[i].CreatedAt.Before(exported[j].CreatedAt)
+		}
+		return exported[i].ID < exported[j].ID
+	})
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	array := strings.EqualFold(filepath.Ext(path), ".json")
+	if err := writeVectors(file, exported, array); err != nil {
+		file.Close()
+		return 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+	return len(exported), file.Close()
+}
+
+// writeVectors writes vectors as a JSON array, one vector per line, or as JSON lines
+func writeVectors(w io.Writer, vectors []*models.Vector, array bool) error {
+	buffered := bufio.NewWriter(w)
+	if array {
+		buffered.WriteString("[\n")
+	}
+	for i, vector := range vectors {
+		data, err := json.Marshal(vector)
+		if err != nil {
+			return err
+		}
+		buffered.Write(data)
+		if array && i < len(vectors)-1 {
+			buffered.WriteByte(',')
+		}
+		buffered.WriteByte('\n')
+	}
+	if array {
+		buffered.WriteString("]\n")
+	}
+	return buffered.Flush()
+}
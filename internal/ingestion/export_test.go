@@ -0,0 +1,97 @@
+package ingestion
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestExportRun(t *testing.T) {
+	store := memory.NewStorage()
+	ingest := func() *Stats {
+		t.Helper()
+		config := &SourceConfig{BatchSize: 2}
+		source, err := NewFileSource(reviewsFixture, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source.SetTextField("text")
+		stats, err := NewIngestor(source, hash.NewHashEmbedder(), store, config).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+	ingest()
+	stats := ingest()
+	dir := t.TempDir()
+
+	// Lines, with only the vectors of the run
+	path := filepath.Join(dir, "vectors.jsonl")
+	count, err := ExportRun(store, path, ExportOptions{RunID: stats.RunID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var lines []*models.Vector
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var vector models.Vector
+		if err := json.Unmarshal(scanner.Bytes(), &vector); err != nil {
+			t.Fatalf("malformed line: %v", err)
+		}
+		lines = append(lines, &vector)
+	}
+	if count != stats.SuccessCount || len(lines) != count {
+		t.Fatalf("exported %d vectors in %d lines, the run stored %d", count, len(lines), stats.SuccessCount)
+	}
+	for i, vector := range lines {
+		if vector.Metadata[models.LineageRunKey] != stats.RunID || len(vector.Embedding) == 0 || vector.CreatedAt.IsZero() {
+			t.Errorf("line %d: %+v", i, vector)
+		}
+		if i > 0 && vector.CreatedAt.Before(lines[i-1].CreatedAt) {
+			t.Errorf("line %d stored before the previous one", i)
+		}
+	}
+
+	// An array without embeddings, leaving the stored vectors alone
+	path = filepath.Join(dir, "vectors.json")
+	if _, err := ExportRun(store, path, ExportOptions{NoEmbeddings: true}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"embedding"`) {
+		t.Error("embeddings exported with NoEmbeddings")
+	}
+	var array []*models.Vector
+	if err := json.Unmarshal(data, &array); err != nil {
+		t.Fatalf("malformed array: %v", err)
+	}
+	if len(array) != store.Count() {
+		t.Fatalf("exported %d of %d vectors", len(array), store.Count())
+	}
+	for _, vector := range array {
+		if len(vector.Embedding) != 0 || vector.Metadata["label"] == "" {
+			t.Errorf("exported %+v", vector)
+		}
+	}
+	if stored, _ := store.Get(array[0].ID); len(stored.Embedding) == 0 {
+		t.Error("export removed the stored embedding")
+	}
+}
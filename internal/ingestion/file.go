@@ -2,9 +2,12 @@ package ingestion
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -12,38 +15,49 @@ import (
 	"strings"
 )
 
-// FileSource reads from CSV or JSONL files
+// gzipMagic starts gzip compressed files
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// StdinPath is the source argument reading records from standard input
+const StdinPath = "-"
+
+// ErrNoData is returned by Open when a reader source is empty
+var ErrNoData = errors.New("no data to ingest")
+
+// FileSource reads from CSV, JSONL or JSON array files, optionally gzip compressed
 type FileSource struct {
 	path     string
-	fileType string
+	fileType string // Empty for reader sources detecting their format
 	file     *os.File
+	reader   io.Reader // Read instead of the file, see NewReaderSource
+	gzip     *gzip.Reader // Set when the file is gzip compressed
 	
 	// CSV specific
 	csvReader *csv.Reader
 	headers   []string
 	textCol   string
+	delimiter rune // 0 detects it from the header line
+	mismatches int // Rows whose column count differs from the headers
 	
 	// JSONL specific
-	scanner *bufio.Scanner
+	scanner   *bufio.Scanner
+	line      int
+	textField string // Dot-path of the text, empty tries the common field names
+	
+	// JSON array specific, elements are decoded one at a time
+	decoder   *json.Decoder
+	element   int
+	malformed bool // The array is malformed, nothing more can be decoded
 	
 	config *SourceConfig
 }
 
-// NewFileSource creates a source for CSV or JSONL files
+// NewFileSource creates a source for CSV, JSONL or JSON array files
+// Files ending in .gz, or starting with the gzip magic bytes, are decompressed
 func NewFileSource(path string, config *SourceConfig) (*FileSource, error) {
-	ext := strings.ToLower(filepath.Ext(path))
-	
-	var fileType string
-	switch ext {
-	case ".csv":
-		fileType = "csv"
-	case ".jsonl", ".ndjson":
-		fileType = "jsonl"
-	case ".json":
-		// Could be JSONL or regular JSON array
-		fileType = "jsonl"
-	default:
-		return nil, fmt.Errorf("unsupported file type: %s (supported: .csv, .jsonl, .json)", ext)
+	fileType, err := FileType(path)
+	if err != nil {
+		return nil, err
 	}
 	
 	return &FileSource{
@@ -54,32 +68,132 @@ func NewFileSource(path string, config *SourceConfig) (*FileSource, error) {
 	}, nil
 }
 
+// NewReaderSource creates a source for CSV or JSONL records read from r, such as
+// standard input. format is "csv" or "jsonl", or empty to detect it from the first
+// bytes: records starting with { or [ are JSON, others CSV. r is read once, as it
+// is consumed, so the source cannot be opened again
+func NewReaderSource(r io.Reader, format string, config *SourceConfig) (*FileSource, error) {
+	switch format {
+	case "", "csv", "jsonl":
+	default:
+		return nil, fmt.Errorf("unsupported format: %s (supported: csv, jsonl)", format)
+	}
+	
+	return &FileSource{
+		path:     StdinPath,
+		fileType: format,
+		reader:   r,
+		config:   config,
+		textCol:  "text",
+	}, nil
+}
+
+// FileType returns "csv" or "jsonl" for the paths FileSource reads, looking
+// through a .gz extension. JSON files are read as JSONL unless they hold an array
+func FileType(path string) (string, error) {
+	name := strings.ToLower(path)
+	name = strings.TrimSuffix(name, ".gz")
+	
+	switch ext := filepath.Ext(name); ext {
+	case ".csv":
+		return "csv", nil
+	case ".jsonl", ".ndjson", ".json":
+		return "jsonl", nil
+	default:
+		return "", fmt.Errorf("unsupported file type: %s (supported: .csv, .jsonl, .ndjson, .json, optionally .gz)", ext)
+	}
+}
+
 // SetTextColumn sets which column contains the text (for CSV)
 func (s *FileSource) SetTextColumn(col string) {
 	s.textCol = col
 }
 
+// SetTextField sets the dot-path of the text in JSON records, such as
+// "payload.body.text" or "items.0.text" (for JSONL and JSON arrays)
+func (s *FileSource) SetTextField(path string) {
+	s.textField = path
+}
+
+// SetDelimiter sets the field delimiter of CSV files, 0 detects it from the header line
+func (s *FileSource) SetDelimiter(delimiter rune) {
+	s.delimiter = delimiter
+}
+
+// ColumnMismatches returns the number of CSV rows read whose column count differs from the headers
+func (s *FileSource) ColumnMismatches() int {
+	return s.mismatches
+}
+
 func (s *FileSource) Open(ctx context.Context) error {
-	file, err := os.Open(s.path)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+	input := s.reader
+	if input == nil {
+		file, err := os.Open(s.path)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		s.file = file
+		input = file
 	}
 	
-	s.file = file
+	buffered := bufio.NewReader(input)
+	var reader io.Reader = buffered
+	if magic, err := buffered.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		s.gzip, err = gzip.NewReader(buffered)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", s.path, err)
+		}
+		reader = s.gzip
+	}
+	
+	if s.reader != nil {
+		detected := bufio.NewReader(reader)
+		fileType, err := detectFormat(detected)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", s.Name(), err)
+		}
+		if s.fileType == "" {
+			s.fileType = fileType
+		}
+		reader = detected
+	}
 	
 	switch s.fileType {
 	case "csv":
-		s.csvReader = csv.NewReader(file)
+		csvReader, err := newCSVReader(reader, s.delimiter)
+		if err != nil {
+			return err
+		}
+		s.csvReader = csvReader
 		
 		// Read headers
 		headers, err := s.csvReader.Read()
 		if err != nil {
 			return fmt.Errorf("failed to read CSV headers: %w", err)
 		}
+		for i, header := range headers {
+			headers[i] = strings.TrimSpace(header)
+		}
 		s.headers = headers
 		
 	case "jsonl":
-		s.scanner = bufio.NewScanner(file)
+		buffered := bufio.NewReader(reader)
+		if start, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(start, utf8BOM) {
+			buffered.Discard(len(utf8BOM))
+		}
+		isArray, err := startsWithArray(buffered)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", s.path, err)
+		}
+		if isArray {
+			s.decoder = json.NewDecoder(buffered)
+			if _, err := s.decoder.Token(); err != nil {
+				return fmt.Errorf("failed to read JSON array: %w", err)
+			}
+			return nil
+		}
+		
+		s.scanner = bufio.NewScanner(buffered)
 		// Increase buffer size for large JSON lines
 		buf := make([]byte, 0, 64*1024)
 		s.scanner.Buffer(buf, 1024*1024)
@@ -93,6 +207,9 @@ func (s *FileSource) Next() (*Record, error) {
 	case "csv":
 		return s.nextCSV()
 	case "jsonl":
+		if s.decoder != nil {
+			return s.nextJSONElement()
+		}
 		return s.nextJSONL()
 	default:
 		return nil, fmt.Errorf("unknown file type: %s", s.fileType)
@@ -104,6 +221,10 @@ func (s *FileSource) nextCSV() (*Record, error) {
 	if err != nil {
 		return nil, err
 	}
+	line, _ := s.csvReader.FieldPos(0)
+	if len(row) != len(s.headers) {
+		s.mismatches++
+	}
 	
 	// Find text column index
 	textIdx := -1
@@ -139,6 +260,7 @@ func (s *FileSource) nextCSV() (*Record, error) {
 	return &Record{
 		Text:     text,
 		Metadata: metadata,
+		Index:    line,
 	}, nil
 }
 
@@ -149,6 +271,7 @@ func (s *FileSource) nextJSONL() (*Record, error) {
 		}
 		return nil, io.EOF
 	}
+	s.line++
 	
 	line := s.scanner.Bytes()
 	if len(line) == 0 {
@@ -163,40 +286,74 @@ func (s *FileSource) nextJSONL() (*Record, error) {
 		return s.Next()
 	}
 	
-	// Extract text field
-	text, ok := data["text"].(string)
-	if !ok {
-		// Try alternative field names
-		for _, field := range []string{"content", "body", "message", "quote"} {
-			if t, ok := data[field].(string); ok {
-				text = t
-				break
+	record := s.jsonRecord(data, s.line)
+	if record == nil {
+		return s.Next()
+	}
+	return record, nil
+}
+
+// nextJSONElement decodes the next object of a JSON array, skipping other values
+func (s *FileSource) nextJSONElement() (*Record, error) {
+	for !s.malformed && s.decoder.More() {
+		s.element++
+		
+		var value interface{}
+		if err := s.decoder.Decode(&value); err != nil {
+			s.malformed = true
+			return nil, fmt.Errorf("failed to decode JSON array element %d: %w", s.element, err)
+		}
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			if s.config.Verbose {
+				fmt.Printf("skipping JSON array element %d: not an object\n", s.element)
+			}
+			continue
+		}
+		if record := s.jsonRecord(data, s.element); record != nil {
+			return record, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+// jsonRecord builds the record of a JSON object, nil when it has no text field
+func (s *FileSource) jsonRecord(data map[string]interface{}, index int) *Record {
+	var text string
+	var skip []string
+	
+	if s.textField != "" {
+		text, _ = lookupText(data, s.textField)
+		skip = []string{s.textField}
+	} else {
+		// Extract text field
+		var ok bool
+		text, ok = data["text"].(string)
+		if !ok {
+			// Try alternative field names
+			for _, field := range []string{"content", "body", "message", "quote"} {
+				if t, ok := data[field].(string); ok {
+					text = t
+					break
+				}
 			}
 		}
+		skip = []string{"text", "content", "body", "message"}
 	}
 	
 	if text == "" {
 		if s.config.Verbose {
-			fmt.Printf("skipping record without text field\n")
+			if s.textField != "" {
+				fmt.Printf("skipping record without '%s' field\n", s.textField)
+			} else {
+				fmt.Printf("skipping record without text field\n")
+			}
 		}
-		return s.Next()
+		return nil
 	}
 	
 	// Build metadata from other fields
-	metadata := make(map[string]string)
-	for key, value := range data {
-		if key == "text" || key == "content" || key == "body" || key == "message" {
-			continue
-		}
-		
-		// Convert value to string
-		switch v := value.(type) {
-		case string:
-			metadata[key] = v
-		case float64, int, int64, bool:
-			metadata[key] = fmt.Sprintf("%v", v)
-		}
-	}
+	metadata := jsonMetadata(data, s.config, skip...)
 	
 	if s.config.Namespace != "" {
 		metadata["namespace"] = s.config.Namespace
@@ -205,16 +362,86 @@ func (s *FileSource) nextJSONL() (*Record, error) {
 	return &Record{
 		Text:     text,
 		Metadata: metadata,
-	}, nil
+		Index:    index,
+	}
+}
+
+// detectFormat returns the type of the records of r, "jsonl" when the first value
+// after a byte order mark and whitespace starts with { or [, "csv" otherwise.
+// Only leading whitespace is consumed. It fails with ErrNoData when r holds nothing else
+func detectFormat(r *bufio.Reader) (string, error) {
+	for {
+		data, err := r.Peek(64)
+		trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, utf8BOM), " \t\r\n")
+		if len(trimmed) > 0 {
+			if trimmed[0] == '{' || trimmed[0] == '[' {
+				return "jsonl", nil
+			}
+			return "csv", nil
+		}
+		if err == io.EOF {
+			return "", ErrNoData
+		}
+		if err != nil {
+			return "", err
+		}
+		r.Discard(len(data))
+	}
+}
+
+// startsWithArray reports whether the first value of r, after whitespace, is a
+// JSON array, without consuming it
+func startsWithArray(r *bufio.Reader) (bool, error) {
+	for size := 64; ; size *= 2 {
+		data, err := r.Peek(size)
+		trimmed := bytes.TrimLeft(data, " \t\r\n")
+		if len(trimmed) > 0 {
+			return trimmed[0] == '[', nil
+		}
+		if err != nil {
+			if err == io.EOF || err == bufio.ErrBufferFull {
+				return false, nil
+			}
+			return false, err
+		}
+	}
 }
 
 func (s *FileSource) Close() error {
+	if s.gzip != nil {
+		s.gzip.Close()
+	}
 	if s.file != nil {
 		return s.file.Close()
 	}
 	return nil
 }
 
+// Parameters returns the path and format of the file and the columns or fields read
+func (s *FileSource) Parameters() map[string]string {
+	params := map[string]string{"path": s.path, "format": s.fileType}
+	switch s.fileType {
+	case "csv":
+		params["text_column"] = s.textCol
+		if s.delimiter != 0 {
+			params["delimiter"] = string(s.delimiter)
+		}
+	case "jsonl":
+		if s.textField != "" {
+			params["text_field"] = s.textField
+		}
+	}
+	return params
+}
+
+// Path returns the path of the file
+func (s *FileSource) Path() string {
+	return s.path
+}
+
 func (s *FileSource) Name() string {
+	if s.reader != nil {
+		return "stdin"
+	}
 	return fmt.Sprintf("file:%s", filepath.Base(s.path))
 }
@@ -0,0 +1,226 @@
+package ingestion
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// readAll opens a file source and returns the text of every record
+func readAll(t *testing.T, path string) []string {
+	t.Helper()
+	source, err := NewFileSource(path, &SourceConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	texts := make([]string, 0)
+	for {
+		record, err := source.Next()
+		if err == io.EOF {
+			return texts
+		}
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		texts = append(texts, record.Text)
+	}
+}
+
+func writeFile(t *testing.T, path, content string, compress bool) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var w io.Writer = file
+	if compress {
+		gz := gzip.NewWriter(file)
+		defer gz.Close()
+		w = gz
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSource_Formats(t *testing.T) {
+	contents := map[string]string{
+		"csv":   "text,author\none,a\ntwo,b\n",
+		"jsonl": `{"text": "one"}` + "\n" + `{"text": "two"}` + "\n",
+		"array": "\n  [\n" + `{"text": "one", "n": 1}, 42, {"content": "two"}, {"other": "no text"}` + "\n]\n",
+		"bom":   "\xef\xbb\xbf" + `[{"text": "one"}, {"text": "two"}]`,
+	}
+	tests := []struct {
+		name, content string
+		compress      bool
+	}{
+		{"data.csv", "csv", false},
+		{"data.csv.gz", "csv", true},
+		{"data.jsonl", "jsonl", false},
+		{"data.jsonl.gz", "jsonl", true},
+		{"data.json", "jsonl", false},
+		{"data.json", "array", false},
+		{"data.json.gz", "array", true},
+		{"data.json", "bom", false},
+		{"compressed.jsonl", "jsonl", true}, // Detected from the magic bytes
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.name)
+		writeFile(t, path, contents[tt.content], tt.compress)
+
+		texts := readAll(t, path)
+		if len(texts) != 2 || texts[0] != "one" || texts[1] != "two" {
+			t.Errorf("%s (%s): records = %q", tt.name, tt.content, texts)
+		}
+	}
+
+	if _, err := NewFileSource("data.txt.gz", &SourceConfig{}); err == nil {
+		t.Errorf("unsupported compressed file accepted")
+	}
+}
+
+func TestFileSource_MalformedArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.json")
+	writeFile(t, path, `[{"text": "one"}, {"text": tw`, false)
+
+	source, _ := NewFileSource(path, &SourceConfig{})
+	if err := source.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	if record, err := source.Next(); err != nil || record.Text != "one" || record.Index != 1 {
+		t.Fatalf("first element = %+v, %v", record, err)
+	}
+	if _, err := source.Next(); err == nil || err == io.EOF {
+		t.Errorf("malformed element: err = %v", err)
+	}
+	if _, err := source.Next(); err != io.EOF {
+		t.Errorf("after a malformed element: err = %v, want EOF", err)
+	}
+}
+
+func TestFileSource_StreamsLargeArray(t *testing.T) {
+	const elements = 200000
+	path := filepath.Join(t.TempDir(), "large.json.gz")
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	w := bufio.NewWriter(gz)
+	w.WriteString("[")
+	for i := 0; i < elements; i++ {
+		if i > 0 {
+			w.WriteString(",\n")
+		}
+		fmt.Fprintf(w, `{"text": "record number %d with some padding to make the file larger", "id": "%d"}`, i, i)
+	}
+	w.WriteString("]")
+	w.Flush()
+	gz.Close()
+	file.Close()
+
+	source, _ := NewFileSource(path, &SourceConfig{})
+	if err := source.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	count := 0
+	var peak uint64
+	for {
+		_, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("element %d: %v", count, err)
+		}
+		count++
+		if count%50000 == 0 {
+			var stats runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > peak {
+				peak = stats.HeapAlloc
+			}
+		}
+	}
+	if count != elements {
+		t.Fatalf("read %d elements, want %d", count, elements)
+	}
+
+	// The decompressed array is about 20MB, streaming keeps a small buffer
+	if growth := int64(peak) - int64(before.HeapAlloc); growth > 4<<20 {
+		t.Errorf("heap grew by %d bytes while reading, want the array streamed", growth)
+	}
+}
+
+func TestReaderSource_DetectsFormat(t *testing.T) {
+	read := func(input, format string) ([]string, error) {
+		source, err := NewReaderSource(strings.NewReader(input), format, &SourceConfig{})
+		if err != nil {
+			return nil, err
+		}
+		if err := source.Open(context.Background()); err != nil {
+			return nil, err
+		}
+		defer source.Close()
+		var texts []string
+		for {
+			record, err := source.Next()
+			if err == io.EOF {
+				return texts, nil
+			}
+			if err != nil {
+				return texts, err
+			}
+			texts = append(texts, record.Text)
+		}
+	}
+
+	tests := []struct {
+		input, format string
+		want          []string
+	}{
+		{"\n\n{\"text\": \"a\"}\n{\"text\": \"b\"}\n", "", []string{"a", "b"}},
+		{"[{\"text\": \"a\"}]", "", []string{"a"}},
+		{"\ufefftext;author\nc;d\n", "", []string{"c"}},
+		{"{a},text\nx,{e}\n", "csv", []string{"{e}"}},
+	}
+	for _, tt := range tests {
+		if got, err := read(tt.input, tt.format); err != nil || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", " \n\t\n"} {
+		if _, err := read(input, ""); !errors.Is(err, ErrNoData) {
+			t.Errorf("%q: err = %v, want ErrNoData", input, err)
+		}
+	}
+	if _, err := read("a,b", "xml"); err == nil {
+		t.Error("unknown format was accepted")
+	}
+}
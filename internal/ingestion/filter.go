@@ -0,0 +1,73 @@
+package ingestion
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// recordFilter skips the records failing a --where condition or the text regex
+// before they are embedded
+type recordFilter struct {
+	name    string                  // Condition as given, keys Stats.Filtered
+	filters *models.CompiledFilters // nil for the text regex
+	text    *regexp.Regexp
+}
+
+// newRecordFilters compiles the Where conditions and WhereTextRegex of config
+// Each condition is compiled on its own so skipped records are counted by the
+// condition they failed, with the operators and semantics of search filters
+func newRecordFilters(config *SourceConfig) ([]recordFilter, error) {
+	var filters []recordFilter
+	for _, condition := range config.Where {
+		parsed, err := models.ParseFilterConditions([]string{condition})
+		if err != nil {
+			return nil, fmt.Errorf("invalid --where: %w", err)
+		}
+		compiled, err := models.NewFilterEvaluator().Compile(parsed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --where: %w", err)
+		}
+		filters = append(filters, recordFilter{name: condition, filters: compiled})
+	}
+
+	if config.WhereTextRegex != "" {
+		text, err := regexp.Compile(config.WhereTextRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --where-text-regex: %w", err)
+		}
+		filters = append(filters, recordFilter{name: "text~" + config.WhereTextRegex, text: text})
+	}
+	return filters, nil
+}
+
+// matches reports whether record passes the filter
+func (f *recordFilter) matches(evaluator *models.FilterEvaluator, record *Record) bool {
+	if f.text != nil {
+		return f.text.MatchString(record.Text)
+	}
+	return evaluator.Matches(record.Metadata, f.filters)
+}
+
+// filterRecord reports whether record passes every record filter, counting it
+// as skipped by the first one it fails otherwise
+func (ing *Ingestor) filterRecord(record *Record) bool {
+	if len(ing.filters) == 0 {
+		return true
+	}
+
+	evaluator := models.NewFilterEvaluator()
+	for i := range ing.filters {
+		if ing.filters[i].matches(evaluator, record) {
+			continue
+		}
+		ing.stats.SkippedCount++
+		if ing.stats.Filtered == nil {
+			ing.stats.Filtered = make(map[string]int)
+		}
+		ing.stats.Filtered[ing.filters[i].name]++
+		return false
+	}
+	return true
+}
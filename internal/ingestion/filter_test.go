@@ -0,0 +1,120 @@
+package ingestion
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+const reviewsFixture = "testdata/json/reviews.jsonl"
+
+// countingEmbedder records the texts it was asked to embed
+type countingEmbedder struct {
+	embedders.Embedder
+	texts []string
+}
+
+func (e *countingEmbedder) Embed(text string) ([]float64, error) {
+	e.texts = append(e.texts, text)
+	return e.Embedder.Embed(text)
+}
+
+func TestIngestor_Where(t *testing.T) {
+	tests := []struct {
+		name         string
+		where        []string
+		textRegex    string
+		wantYears    []string
+		wantFiltered map[string]int
+	}{
+		{
+			name:         "label",
+			where:        []string{"label=pos"},
+			wantYears:    []string{"2009", "2016", "2018", "2021"},
+			wantFiltered: map[string]int{"label=pos": 3},
+		},
+		{
+			name:         "label and year",
+			where:        []string{"label!=unsup", "year>=2015"},
+			wantYears:    []string{"2016", "2017", "2018", "2021"},
+			wantFiltered: map[string]int{"label!=unsup": 2, "year>=2015": 1},
+		},
+		{
+			name:         "text regex",
+			where:        []string{"label=pos"},
+			textRegex:    `(?i)great`,
+			wantYears:    []string{"2016", "2018"},
+			wantFiltered: map[string]int{"label=pos": 3, "text~(?i)great": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &SourceConfig{BatchSize: 10, Where: tt.where, WhereTextRegex: tt.textRegex}
+			source, err := NewFileSource(reviewsFixture, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			embedder := &countingEmbedder{Embedder: hash.NewHashEmbedder()}
+			store := memory.NewStorage()
+			stats, err := NewIngestor(source, embedder, store, config).Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(embedder.texts) != len(tt.wantYears) {
+				t.Errorf("embedded %q, want %d records", embedder.texts, len(tt.wantYears))
+			}
+			for _, text := range embedder.texts {
+				if strings.HasPrefix(text, "Unlabeled") {
+					t.Errorf("embedded filtered record %q", text)
+				}
+			}
+
+			vectors, err := store.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var years []string
+			for _, vector := range vectors {
+				years = append(years, vector.Metadata["year"])
+			}
+			sort.Strings(years)
+			if strings.Join(years, ",") != strings.Join(tt.wantYears, ",") {
+				t.Errorf("stored years %v, want %v", years, tt.wantYears)
+			}
+
+			skipped := 0
+			for filter, count := range tt.wantFiltered {
+				skipped += count
+				if stats.Filtered[filter] != count {
+					t.Errorf("filtered = %v, want %v", stats.Filtered, tt.wantFiltered)
+					break
+				}
+			}
+			if stats.SkippedCount != skipped || stats.SuccessCount != len(tt.wantYears) {
+				t.Errorf("stats = %d stored, %d skipped, want %d and %d", stats.SuccessCount, stats.SkippedCount, len(tt.wantYears), skipped)
+			}
+		})
+	}
+}
+
+func TestIngestor_WhereInvalid(t *testing.T) {
+	for _, config := range []*SourceConfig{
+		{Where: []string{"label"}},
+		{Where: []string{"year>="}, WhereTextRegex: "("},
+		{WhereTextRegex: "("},
+	} {
+		source, err := NewFileSource(reviewsFixture, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewIngestor(source, hash.NewHashEmbedder(), memory.NewStorage(), config).Run(context.Background()); err == nil {
+			t.Errorf("Run() with where %q, regex %q succeeded, want an error", config.Where, config.WhereTextRegex)
+		}
+	}
+}
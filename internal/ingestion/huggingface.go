@@ -18,6 +18,7 @@ type HuggingFaceSource struct {
 	subset     string
 	tempFile   string
 	scanner    *bufio.Scanner
+	line       int
 	file       *os.File
 	config     *SourceConfig
 	textField  string
@@ -48,7 +49,8 @@ func (s *HuggingFaceSource) SetSplit(split string) {
 	s.split = split
 }
 
-// SetTextField sets which field contains the text
+// SetTextField sets which field contains the text, a dot-path such as
+// "payload.body.text" or "items.0.text" selects nested fields
 func (s *HuggingFaceSource) SetTextField(field string) {
 	s.textField = field
 }
@@ -152,6 +154,7 @@ func (s *HuggingFaceSource) Next() (*Record, error) {
 		}
 		return nil, io.EOF
 	}
+	s.line++
 	
 	line := s.scanner.Bytes()
 	if len(line) == 0 {
@@ -166,8 +169,8 @@ func (s *HuggingFaceSource) Next() (*Record, error) {
 		return s.Next()
 	}
 	
-	// Extract text field
-	text, ok := data[s.textField].(string)
+	// Extract text field, which may be a dot-path into nested objects
+	text, ok := lookupText(data, s.textField)
 	if !ok {
 		if s.config.Verbose {
 			fmt.Printf("skipping record without '%s' field\n", s.textField)
@@ -176,19 +179,7 @@ func (s *HuggingFaceSource) Next() (*Record, error) {
 	}
 	
 	// Build metadata from other fields
-	metadata := make(map[string]string)
-	for key, value := range data {
-		if key == s.textField {
-			continue
-		}
-		
-		switch v := value.(type) {
-		case string:
-			metadata[key] = v
-		case float64, int, int64, bool:
-			metadata[key] = fmt.Sprintf("%v", v)
-		}
-	}
+	metadata := jsonMetadata(data, s.config, s.textField)
 	
 	metadata["source"] = "huggingface"
 	metadata["dataset"] = s.dataset
@@ -200,6 +191,7 @@ func (s *HuggingFaceSource) Next() (*Record, error) {
 	return &Record{
 		Text:     text,
 		Metadata: metadata,
+		Index:    s.line,
 	}, nil
 }
 
@@ -213,6 +205,15 @@ func (s *HuggingFaceSource) Close() error {
 	return nil
 }
 
+// Parameters returns the dataset, subset, split and text field read
+func (s *HuggingFaceSource) Parameters() map[string]string {
+	params := map[string]string{"dataset": s.dataset, "split": s.split, "text_field": s.textField}
+	if s.subset != "" {
+		params["subset"] = s.subset
+	}
+	return params
+}
+
 func (s *HuggingFaceSource) Name() string {
 	if s.subset != "" {
 		return fmt.Sprintf("hf:%s:%s", s.dataset, s.subset)
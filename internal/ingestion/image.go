@@ -7,9 +7,14 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+// DefaultImageExtensions are the file extensions an ImageSource scans for when the
+// embedder does not tell which image formats it can decode
+var DefaultImageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp", ".tif", ".tiff", ".heic", ".heif"}
+
 // ImageSource reads images from a directory
 type ImageSource struct {
 	directory string
@@ -42,13 +47,13 @@ func (s *ImageSource) Open(ctx context.Context) error {
 	var files []string
 
 	// Supported image extensions
-	imageExts := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".bmp":  true,
-		".webp": true,
+	extensions := s.config.ImageExtensions
+	if len(extensions) == 0 {
+		extensions = DefaultImageExtensions
+	}
+	imageExts := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		imageExts[strings.ToLower(ext)] = true
 	}
 
 	// Walk directory
@@ -114,6 +119,7 @@ func (s *ImageSource) Next() (*Record, error) {
 			"path":      relPath,
 			"extension": ext,
 		},
+		Index: s.index,
 	}
 
 	if s.config.Namespace != "" {
@@ -127,6 +133,11 @@ func (s *ImageSource) Close() error {
 	return nil
 }
 
+// Parameters returns the directory scanned and whether subdirectories were
+func (s *ImageSource) Parameters() map[string]string {
+	return map[string]string{"directory": s.directory, "recursive": strconv.FormatBool(s.recursive)}
+}
+
 func (s *ImageSource) Name() string {
 	return fmt.Sprintf("image:%s", filepath.Base(s.directory))
 }
@@ -136,6 +147,7 @@ type ImageListSource struct {
 	listFile string
 	baseDir  string
 	scanner  *bufio.Scanner
+	line     int
 	file     *os.File
 	config   *SourceConfig
 }
@@ -180,6 +192,7 @@ func (s *ImageListSource) Next() (*Record, error) {
 		}
 		return nil, io.EOF
 	}
+	s.line++
 
 	line := strings.TrimSpace(s.scanner.Text())
 	if line == "" || strings.HasPrefix(line, "#") {
@@ -228,6 +241,7 @@ func (s *ImageListSource) Next() (*Record, error) {
 		ID:       fmt.Sprintf("img_%s", strings.ReplaceAll(nameWithoutExt, " ", "_")),
 		Text:     imagePath,
 		Metadata: metadata,
+		Index:    s.line,
 	}
 
 	return record, nil
@@ -240,6 +254,11 @@ func (s *ImageListSource) Close() error {
 	return nil
 }
 
+// Parameters returns the list file read
+func (s *ImageListSource) Parameters() map[string]string {
+	return map[string]string{"list": s.listFile}
+}
+
 func (s *ImageListSource) Name() string {
 	return fmt.Sprintf("image-list:%s", filepath.Base(s.listFile))
 }
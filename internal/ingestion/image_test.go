@@ -0,0 +1,44 @@
+package ingestion
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestImageSource_Extensions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.PNG", "c.heic", "d.tiff", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan := func(config *SourceConfig) []string {
+		source, err := NewImageSource(dir, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := source.Open(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, path := range source.files {
+			names = append(names, filepath.Base(path))
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	if got := scan(&SourceConfig{}); len(got) != 4 {
+		t.Errorf("default extensions found %v, want every image", got)
+	}
+
+	// Only the formats the embedder can decode are scanned
+	got := scan(&SourceConfig{ImageExtensions: []string{".jpg", ".png"}})
+	if len(got) != 2 || got[0] != "a.jpg" || got[1] != "b.PNG" {
+		t.Errorf("found %v, want a.jpg and b.PNG", got)
+	}
+}
@@ -2,13 +2,21 @@ package ingestion
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"time"
 
 	"github.com/tahcohcat/same-same/internal/embedders"
 	"github.com/tahcohcat/same-same/internal/models"
 	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/uniquekey"
+	"github.com/tahcohcat/same-same/internal/tracing"
+
+	"github.com/pborman/uuid"
 )
 
 // Ingestor handles the ingestion pipeline
@@ -18,6 +26,15 @@ type Ingestor struct {
 	storage  storage.Storage
 	config   *SourceConfig
 	stats    *Stats
+	dedup    *imageDeduper // nil unless images are deduplicated
+	file     int           // 1-based position of the source in a CompositeSource, 0 otherwise
+	coercer  *coercer      // nil unless metadata fields are typed
+	buffered []bufferedRecord // Records read ahead to infer field types
+	filters  []recordFilter   // Where conditions records must match to be embedded
+	quarantined []quarantine.Entry // Entries to quarantine with the next batch
+	stored   *storedLog            // Vectors stored for the manifest, nil without one
+	emitter  *StatsEmitter         // Appends the stats file, nil without one
+	reported statsReport           // Counts last reported to the emitter
 }
 
 // Stats tracks ingestion statistics
@@ -33,17 +50,24 @@ type Stats struct {
 	FailureReasons  map[string]int
 	Namespace       string
 	StorageType     string
+	RunID           string // Stamped on every vector of the run
+	Embedder        string
+	Duplicates      []Duplicate // Images skipped as near duplicates, also counted as skipped
+	ColumnMismatches int        // CSV rows with more or fewer columns than the headers, still ingested
+	Feeds           *FeedStats  // Feeds read and entries skipped by an RSSSource, nil for other sources
+	Files           []FileStats // Per-file breakdown of a CompositeSource run
+	FieldTypes      map[string]FieldType // Types metadata fields were coerced to, hinted or inferred
+	CoercionFailures map[string]int      // Values per field that could not be coerced to its type
+	Filtered        map[string]int       // Records skipped before embedding, by the filter they failed
+	Quarantined     int                  // Failed records kept in the quarantine of the storage
 }
 
 // NewIngestor creates a new ingestor
 func NewIngestor(source Source, embedder embedders.Embedder, storage storage.Storage, config *SourceConfig) *Ingestor {
-	storageType := "memory"
-	// Try to determine storage type from the storage interface
-	switch storage.(type) {
-	default:
-		storageType = "memory"
+	var stored *storedLog
+	if config.Manifest != "" {
+		stored = newStoredLog()
 	}
-	
 	return &Ingestor{
 		source:   source,
 		embedder: embedder,
@@ -52,38 +76,124 @@ func NewIngestor(source Source, embedder embedders.Embedder, storage storage.Sto
 		stats: &Stats{
 			FailureReasons: make(map[string]int),
 			Namespace:      config.Namespace,
-			StorageType:    storageType,
+			StorageType:    storageType(storage),
+			Embedder:       embedder.Name(),
+			RunID:          uuid.New(),
 		},
+		stored: stored,
 	}
 }
 
 // Run executes the ingestion pipeline
-func (ing *Ingestor) Run(ctx context.Context) (*Stats, error) {
+// The sources of a CompositeSource are ingested SourceConfig.ParallelFiles at a time
+func (ing *Ingestor) Run(ctx context.Context) (stats *Stats, err error) {
 	ing.stats.StartTime = time.Now()
 	
+	ctx, span := tracing.Start(ctx, "ingest.Run")
+	if span.IsRecording() {
+		span.SetAttributes(tracing.Source.String(ing.source.Name()), tracing.Namespace.String(ing.config.Namespace))
+	}
+	defer func() { tracing.End(span, err) }()
+	
+	if err := ing.declareUniqueKeys(); err != nil {
+		return nil, err
+	}
+	
+	if err := ing.loadVocabulary(); err != nil {
+		return nil, err
+	}
+	
+	if ing.emitter == nil && ing.config.StatsFile != "" {
+		if ing.emitter, err = NewStatsEmitter(ing.config.StatsFile, ing.config.StatsInterval, ing.stats.RunID); err != nil {
+			return nil, err
+		}
+		emitter := ing.emitter
+		defer func() {
+			if cerr := emitter.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+	}
+	
+	if err := ing.checkQuarantine(); err != nil {
+		return nil, err
+	}
+	
+	if err := ing.prepareDedup(); err != nil {
+		return nil, err
+	}
+	
+	if ing.filters, err = newRecordFilters(ing.config); err != nil {
+		return nil, err
+	}
+	
+	ing.coercer = newCoercer(ing.config)
+	if ing.coercer != nil {
+		defer func() { ing.stats.FieldTypes = ing.coercer.fieldTypes() }()
+	}
+	
+	if composite, ok := ing.source.(*CompositeSource); ok {
+		stats, err = ing.runFiles(ctx, composite)
+	} else {
+		stats, err = ing.ingest(ctx)
+	}
+	if err != nil {
+		return stats, err
+	}
+	
+	if err := ing.recordRun(); err != nil {
+		return ing.stats, fmt.Errorf("failed to record ingest run: %w", err)
+	}
+	
+	if ing.config.Manifest != "" {
+		if err := ing.Manifest().Write(ing.config.Manifest); err != nil {
+			return ing.stats, err
+		}
+	}
+	
+	return ing.stats, nil
+}
+
+// ingest reads every record of the source, embeds and stores them
+func (ing *Ingestor) ingest(ctx context.Context) (*Stats, error) {
 	if err := ing.source.Open(ctx); err != nil {
 		return nil, fmt.Errorf("failed to open source: %w", err)
 	}
 	defer ing.source.Close()
+	defer ing.flushQuarantine()
 	
 	if ing.config.Verbose {
 		fmt.Printf("Starting ingestion from: %s\n", ing.source.Name())
 	}
 	
+	ing.sampleTypes()
+	
 	batch := make([]*models.Vector, 0, ing.config.BatchSize)
 	
+	// Text records wait for a batch of embeddings with embedders that take several texts per
+	// request, and for the vocabulary fitted on the first batch with embedders learning one
+	batcher, _ := ing.embedder.(embedders.BatchEmbedder)
+	if _, ok := ing.embedder.(embedders.SparseEmbedder); ok && ing.config.Sparse {
+		batcher = nil
+	}
+	fitter, _ := ing.embedder.(embedders.Fitter)
+	var pending []pendingRecord
+	
 	for {
+		ing.reportStats()
+		
 		select {
 		case <-ctx.Done():
 			return ing.stats, ctx.Err()
 		default:
 		}
 		
-		record, err := ing.source.Next()
+		record, err := ing.next()
 		if err == io.EOF {
 			// Process remaining batch
+			batch = ing.embedPending(ctx, batcher, pending, batch)
 			if len(batch) > 0 {
-				ing.processBatch(batch)
+				ing.processBatch(ctx, batch)
 			}
 			break
 		}
@@ -105,88 +215,422 @@ func (ing *Ingestor) Run(ctx context.Context) (*Stats, error) {
 			continue
 		}
 		
+		ing.coerceTypes(record)
+		
+		if ing.config.NormalizeKeys {
+			metadata, _, err := models.NormalizeMetadataKeys(record.Metadata, models.KeyMergeError)
+			if err != nil {
+				ing.stats.FailureCount++
+				ing.stats.FailureReasons["key_collision"]++
+				ing.quarantineRecord(record, "key_collision", err)
+				if ing.config.Verbose {
+					fmt.Printf("Skipping record %d: %v\n", record.Index, err)
+				}
+				continue
+			}
+			record.Metadata = metadata
+		}
+		
+		metadata, err := ing.config.DefaultMetadata.Apply(record.Metadata)
+		if err != nil {
+			ing.stats.FailureCount++
+			ing.stats.FailureReasons["locked_metadata"]++
+			ing.quarantineRecord(record, "locked_metadata", err)
+			if ing.config.Verbose {
+				fmt.Printf("Skipping record %d: %v\n", record.Index, err)
+			}
+			continue
+		}
+		record.Metadata = metadata
+		
+		// Skip the records failing a --where filter before paying for their embedding
+		if !ing.filterRecord(record) {
+			continue
+		}
+		
+		isImage := record.Metadata["type"] == "image"
+		if !isImage && (batcher != nil || fitter != nil && !fitter.Fixed()) {
+			pending = append(pending, pendingRecord{record: record, count: ing.stats.TotalRecords})
+			if len(pending) >= ing.config.BatchSize {
+				batch = ing.embedPending(ctx, batcher, pending, batch)
+				pending = nil
+			}
+			ing.reportProgress()
+			continue
+		}
+		// Keep the vectors in the order of their records
+		batch = ing.embedPending(ctx, batcher, pending, batch)
+		pending = nil
+		
 		// Generate embedding
 		var embedding []float64
+		var sparse *models.SparseVector
+		var imageHash uint64
+		var embedStart time.Time
+		hashed := false
 		
 		// Check if this is an image record and embedder supports images
-		if record.Metadata["type"] == "image" {
+		if isImage {
 			if imgEmbedder, ok := ing.embedder.(interface {
 				EmbedImage(string) ([]float64, error)
 			}); ok {
+				// Skip near duplicates of kept images before paying for their embedding
+				if imageHash, hashed = ing.hashImage(record); hashed && ing.skipDuplicate(record, imageHash) {
+					continue
+				}
+				
 				// Use image embedding
+				_, span := tracing.StartEmbed(ctx, "embedder.EmbedImage", ing.embedder)
+				embedStart = time.Now()
 				embedding, err = imgEmbedder.EmbedImage(record.Text)
+				tracing.End(span, err)
 			} else {
 				ing.stats.FailureCount++
 				ing.stats.FailureReasons["embedder_not_multimodal"]++
+				ing.quarantineRecord(record, "embedder_not_multimodal", fmt.Errorf("embedder %s does not support images", ing.embedder.Name()))
 				if ing.config.Verbose {
 					fmt.Printf("Embedder does not support images, skipping: %s\n", record.Text)
 				}
 				continue
 			}
+		} else if se, ok := ing.embedder.(embedders.SparseEmbedder); ok && ing.config.Sparse {
+			// Use sparse text embedding
+			_, span := tracing.StartEmbed(ctx, "embedder.EmbedSparse", ing.embedder)
+			embedStart = time.Now()
+			sparse, err = se.EmbedSparse(record.Text)
+			tracing.End(span, err)
 		} else {
 			// Use text embedding
+			_, span := tracing.StartEmbed(ctx, "embedder.Embed", ing.embedder)
+			embedStart = time.Now()
 			embedding, err = ing.embedder.Embed(record.Text)
+			tracing.End(span, err)
 		}
+		ing.emitter.observeLatency(time.Since(embedStart))
 		if err != nil {
-			ing.stats.FailureCount++
-			ing.stats.FailureReasons["embed_error"]++
-			if ing.config.Verbose {
-				textPreview := record.Text
-				if len(textPreview) > 50 {
-					textPreview = textPreview[:50] + "..."
-				}
-				fmt.Printf("Error embedding text '%s': %v\n", textPreview, err)
-			}
+			ing.embedFailed(record, err)
 			continue
 		}
 		
-		if ing.config.Verbose && ing.stats.TotalRecords <= 3 {
-			fmt.Printf("Successfully embedded record %d with %d dimensions\n", ing.stats.TotalRecords, len(embedding))
+		var vector *models.Vector
+		batch, vector = ing.addVector(ctx, batch, record, ing.stats.TotalRecords, embedding, sparse)
+		if vector != nil && hashed {
+			ing.rememberImage(imageHash, vector.ID)
 		}
 		
-		// Create vector
-		id := record.ID
-		if id == "" {
-			// Generate ID from text hash or use UUID
-			id = fmt.Sprintf("vec_%d_%d", time.Now().UnixNano(), ing.stats.TotalRecords)
+		ing.reportProgress()
+	}
+	
+	ing.reportStats()
+	
+	if counter, ok := ing.source.(ColumnMismatchCounter); ok {
+		ing.stats.ColumnMismatches = counter.ColumnMismatches()
+	}
+	if counter, ok := ing.source.(FeedCounter); ok {
+		feeds := counter.FeedStats()
+		ing.stats.Feeds = &feeds
+	}
+	
+	ing.finish()
+	return ing.stats, nil
+}
+
+// pendingRecord is a text record waiting for the embeddings of its batch
+type pendingRecord struct {
+	record *Record
+	count  int // Records read when it was read, numbering its generated ID
+}
+
+// embedPending embeds the texts of pending with one EmbedBatch and adds their vectors to batch
+// A batch failing as a whole is embedded again record by record, so that only the records
+// failing on their own are counted as embed errors. An embedders.Fitter without a fixed
+// vocabulary is fitted on the texts first, and embeds them record by record
+func (ing *Ingestor) embedPending(ctx context.Context, batcher embedders.BatchEmbedder, pending []pendingRecord, batch []*models.Vector) []*models.Vector {
+	if len(pending) == 0 {
+		return batch
+	}
+	
+	texts := make([]string, len(pending))
+	for i, p := range pending {
+		texts[i] = p.record.Text
+	}
+	if fitter, ok := ing.embedder.(embedders.Fitter); ok && !fitter.Fixed() {
+		ing.fitVocabulary(fitter, texts)
+	}
+	if batcher == nil {
+		return ing.embedEach(ctx, pending, batch)
+	}
+	
+	_, span := tracing.StartEmbed(ctx, "embedder.EmbedBatch", ing.embedder)
+	embedStart := time.Now()
+	embeddings, err := batcher.EmbedBatch(texts)
+	tracing.End(span, err)
+	ing.emitter.observeLatency(time.Since(embedStart))
+	
+	var batchErr *embedders.BatchError
+	if (err != nil && !errors.As(err, &batchErr)) || len(embeddings) != len(pending) {
+		if ing.config.Verbose {
+			fmt.Printf("Batch of %d records failed, embedding them one by one: %v\n", len(pending), err)
 		}
-		
-		vector := &models.Vector{
-			ID:        id,
-			Embedding: embedding,
-			Metadata:  record.Metadata,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+		return ing.embedEach(ctx, pending, batch)
+	}
+	
+	for i, p := range pending {
+		if batchErr != nil && batchErr.Errors[i] != nil {
+			ing.embedFailed(p.record, batchErr.Errors[i])
+			continue
 		}
-		
-		// Add to batch
-		batch = append(batch, vector)
-		
-		// Process batch if full
-		if len(batch) >= ing.config.BatchSize {
-			ing.processBatch(batch)
-			batch = make([]*models.Vector, 0, ing.config.BatchSize)
+		if len(embeddings[i]) == 0 {
+			ing.embedFailed(p.record, fmt.Errorf("no embedding returned"))
+			continue
 		}
-		
-		// Progress indicator
-		if ing.config.Verbose && ing.stats.TotalRecords%100 == 0 {
-			fmt.Printf("Processed %d records...\n", ing.stats.TotalRecords)
+		batch, _ = ing.addVector(ctx, batch, p.record, p.count, embeddings[i], nil)
+	}
+	return batch
+}
+
+// embedEach embeds the texts of pending one by one and adds their vectors to batch
+func (ing *Ingestor) embedEach(ctx context.Context, pending []pendingRecord, batch []*models.Vector) []*models.Vector {
+	se, sparse := ing.embedder.(embedders.SparseEmbedder)
+	sparse = sparse && ing.config.Sparse
+	for _, p := range pending {
+		var embedding []float64
+		var sparseVector *models.SparseVector
+		var err error
+		embedStart := time.Now()
+		if sparse {
+			_, span := tracing.StartEmbed(ctx, "embedder.EmbedSparse", ing.embedder)
+			sparseVector, err = se.EmbedSparse(p.record.Text)
+			tracing.End(span, err)
+		} else {
+			_, span := tracing.StartEmbed(ctx, "embedder.Embed", ing.embedder)
+			embedding, err = ing.embedder.Embed(p.record.Text)
+			tracing.End(span, err)
+		}
+		ing.emitter.observeLatency(time.Since(embedStart))
+		if err != nil {
+			ing.embedFailed(p.record, err)
+			continue
 		}
+		batch, _ = ing.addVector(ctx, batch, p.record, p.count, embedding, sparseVector)
+	}
+	return batch
+}
+
+// embedFailed counts and quarantines a record that could not be embedded
+func (ing *Ingestor) embedFailed(record *Record, err error) {
+	ing.stats.FailureCount++
+	ing.stats.FailureReasons["embed_error"]++
+	ing.quarantineRecord(record, "embed_error", err)
+	if ing.config.Verbose {
+		textPreview := record.Text
+		if len(textPreview) > 50 {
+			textPreview = textPreview[:50] + "..."
+		}
+		fmt.Printf("Error embedding text '%s': %v\n", textPreview, err)
+	}
+}
+
+// addVector adds the vector of an embedded record to batch, processing the batch once full
+// count is the number of records read when the record was read. The vector is nil when
+// the record is skipped
+func (ing *Ingestor) addVector(ctx context.Context, batch []*models.Vector, record *Record, count int, embedding []float64, sparse *models.SparseVector) ([]*models.Vector, *models.Vector) {
+	if ing.config.Verbose && count <= 3 {
+		dimensions := len(embedding)
+		if sparse != nil {
+			dimensions = sparse.Size()
+		}
+		fmt.Printf("Successfully embedded record %d with %d dimensions\n", count, dimensions)
 	}
 	
+	// Create vector
+	id := record.ID
+	if id == "" {
+		// Generate ID from text hash or use UUID
+		id = fmt.Sprintf("vec_%d_%d", time.Now().UnixNano(), count)
+		if ing.file > 0 {
+			// Files ingested in parallel would otherwise generate the same IDs
+			id = fmt.Sprintf("vec_%d_%d_%d", time.Now().UnixNano(), ing.file, count)
+		}
+	}
+	
+	vector := &models.Vector{
+		ID:        id,
+		Embedding: embedding,
+		Sparse:    sparse,
+		Metadata:  ing.withLineage(record),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := vector.CheckDimension(); err != nil {
+		ing.stats.FailureCount++
+		ing.stats.FailureReasons["dimension_too_large"]++
+		ing.quarantineRecord(record, "dimension_too_large", err)
+		if ing.config.Verbose {
+			fmt.Printf("Skipping record %s: %v\n", vector.ID, err)
+		}
+		return batch, nil
+	}
+	
+	// Add to batch
+	batch = append(batch, vector)
+	
+	// Process batch if full
+	if len(batch) >= ing.config.BatchSize {
+		ing.processBatch(ctx, batch)
+		batch = make([]*models.Vector, 0, ing.config.BatchSize)
+	}
+	return batch, vector
+}
+
+// reportProgress prints a progress indicator every 100 records
+func (ing *Ingestor) reportProgress() {
+	if ing.config.Verbose && ing.stats.TotalRecords%100 == 0 {
+		fmt.Printf("Processed %d records...\n", ing.stats.TotalRecords)
+	}
+}
+
+// finish stamps the end time, duration and speed of the run
+func (ing *Ingestor) finish() {
 	ing.stats.EndTime = time.Now()
 	ing.stats.Duration = ing.stats.EndTime.Sub(ing.stats.StartTime)
 	
 	if ing.stats.Duration.Seconds() > 0 {
 		ing.stats.RecordsPerSec = float64(ing.stats.SuccessCount) / ing.stats.Duration.Seconds()
 	}
-	
-	return ing.stats, nil
 }
 
-func (ing *Ingestor) processBatch(batch []*models.Vector) {
+// declareUniqueKeys adds the configured unique keys to those of the storage
+func (ing *Ingestor) declareUniqueKeys() error {
+	if len(ing.config.UniqueKeys) == 0 || ing.config.DryRun {
+		return nil
+	}
+
+	indexer, ok := ing.storage.(storage.UniqueKeyIndexer)
+	if !ok {
+		return fmt.Errorf("storage backend does not support unique keys")
+	}
+
+	fields := indexer.UniqueKeys()
+	for _, field := range ing.config.UniqueKeys {
+		declared := false
+		for _, existing := range fields {
+			declared = declared || existing == field
+		}
+		if !declared {
+			fields = append(fields, field)
+		}
+	}
+	if err := indexer.SetUniqueKeys(fields); err != nil {
+		return fmt.Errorf("failed to declare unique keys: %w", err)
+	}
+	return nil
+}
+
+// loadVocabulary loads the vocabulary saved with the stored vectors into an embedder
+// learning one, so that the run embeds into their space instead of fitting another
+func (ing *Ingestor) loadVocabulary() error {
+	if fitter, ok := ing.embedder.(embedders.Fitter); !ok || fitter.Fixed() {
+		return nil
+	}
+	if _, err := storage.LoadVocabulary(ing.storage, ing.embedder); err != nil {
+		return fmt.Errorf("failed to load the vocabulary of the stored vectors: %w", err)
+	}
+	return nil
+}
+
+// fitVocabulary fits the vocabulary of the embedder on the first texts of the run and
+// saves it with the vectors, for searches to embed queries into their space
+func (ing *Ingestor) fitVocabulary(fitter embedders.Fitter, texts []string) {
+	if err := fitter.Fit(texts); err != nil {
+		if ing.config.Verbose {
+			fmt.Printf("Failed to fit the vocabulary on %d records: %v\n", len(texts), err)
+		}
+		return
+	}
+	if ing.config.DryRun {
+		return
+	}
+	if err := storage.SaveVocabulary(ing.storage, ing.embedder); err != nil {
+		fmt.Printf("Failed to save the vocabulary with the vectors: %v\n", err)
+	}
+}
+
+// resolveUniqueKeys gives a vector the ID of the stored vector with the same unique key
+func (ing *Ingestor) resolveUniqueKeys(vector *models.Vector) error {
+	if len(ing.config.UniqueKeys) == 0 {
+		return nil
+	}
+	return storage.ResolveUniqueKeys(ing.storage, []*models.Vector{vector})
+}
+
+// withLineage adds the source, record index, run and embedder of a record to its metadata
+func (ing *Ingestor) withLineage(record *Record) map[string]string {
+	metadata := record.Metadata
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+
+	metadata[models.LineageSourceKey] = ing.source.Name()
+	if record.Index > 0 {
+		metadata[models.LineageRecordKey] = strconv.Itoa(record.Index)
+	}
+	metadata[models.LineageRunKey] = ing.stats.RunID
+	metadata[models.LineageTimeKey] = time.Now().UTC().Format(time.RFC3339Nano)
+	if _, ok := metadata[models.EmbedderNameKey]; !ok {
+		metadata[models.EmbedderNameKey] = ing.embedder.Name()
+	}
+
+	return metadata
+}
+
+// recordRun saves a summary of the run in storage backends that keep a run history
+func (ing *Ingestor) recordRun() error {
+	recorder, ok := ing.storage.(storage.RunRecorder)
+	if !ok || ing.config.DryRun {
+		return nil
+	}
+
+	reasons := make(map[string]int, len(ing.stats.FailureReasons))
+	for reason, count := range ing.stats.FailureReasons {
+		reasons[reason] = count
+	}
+
+	return recorder.RecordRun(&models.IngestRun{
+		ID:             ing.stats.RunID,
+		Source:         ing.source.Name(),
+		Namespace:      ing.config.Namespace,
+		Embedder:       ing.embedder.Name(),
+		StartTime:      ing.stats.StartTime,
+		EndTime:        ing.stats.EndTime,
+		Total:          ing.stats.TotalRecords,
+		Stored:         ing.stats.SuccessCount,
+		Failed:         ing.stats.FailureCount,
+		Skipped:        ing.stats.SkippedCount,
+		FailureReasons: reasons,
+	})
+}
+
+// processBatch stores a batch of embedded vectors under an ingest.Batch span
+func (ing *Ingestor) processBatch(ctx context.Context, batch []*models.Vector) {
+	_, span := tracing.Start(ctx, "ingest.Batch")
+	defer span.End()
+	if span.IsRecording() {
+		stored, failed := ing.stats.SuccessCount, ing.stats.FailureCount
+		defer func() {
+			span.SetAttributes(
+				tracing.BatchSize.Int(len(batch)),
+				tracing.BatchStored.Int(ing.stats.SuccessCount-stored),
+				tracing.BatchFailed.Int(ing.stats.FailureCount-failed),
+			)
+		}()
+	}
+	
 	if ing.config.DryRun {
 		ing.stats.SuccessCount += len(batch)
+		for _, vector := range batch {
+			ing.stored.add(vector)
+		}
 		if ing.config.Verbose {
 			fmt.Printf("[DRY RUN] Would store batch of %d vectors\n", len(batch))
 		}
@@ -194,43 +638,29 @@ func (ing *Ingestor) processBatch(batch []*models.Vector) {
 	}
 	
 	for i, vector := range batch {
-		if err := ing.storage.Store(vector); err != nil {
+		err := ing.resolveUniqueKeys(vector)
+		if err == nil {
+			err = ing.storage.Store(vector)
+		}
+		if err != nil {
 			ing.stats.FailureCount++
-			ing.stats.FailureReasons["storage_error"]++
+			if errors.Is(err, quota.ErrExceeded) {
+				ing.stats.FailureReasons["quota_exceeded"]++
+			} else if errors.Is(err, uniquekey.ErrDuplicate) {
+				ing.stats.FailureReasons["duplicate_key"]++
+			} else if errors.Is(err, storage.ErrValidation) {
+				ing.stats.FailureReasons["invalid_vector"]++
+				ing.quarantineVector(vector, "invalid_vector", err)
+			} else {
+				ing.stats.FailureReasons["storage_error"]++
+			}
 			if ing.config.Verbose {
 				fmt.Printf("Error storing vector %d (ID: %s): %v\n", i, vector.ID, err)
 			}
 			continue
 		}
 		ing.stats.SuccessCount++
+		ing.stored.add(vector)
 	}
-}
-
-// PrintStats prints ingestion statistics
-func (s *Stats) Print() {
-	fmt.Printf("\n=== Ingestion Complete ===\n")
-	fmt.Printf("Total Records:    %d\n", s.TotalRecords)
-	fmt.Printf("Successfully Ingested: %d\n", s.SuccessCount)
-	fmt.Printf("Failed:           %d\n", s.FailureCount)
-	fmt.Printf("Skipped:          %d\n", s.SkippedCount)
-	fmt.Printf("Duration:         %v\n", s.Duration)
-	fmt.Printf("Speed:            %.2f records/sec\n", s.RecordsPerSec)
-	
-	if len(s.FailureReasons) > 0 {
-		fmt.Printf("\nFailure Breakdown:\n")
-		for reason, count := range s.FailureReasons {
-			fmt.Printf("  %s: %d\n", reason, count)
-		}
-	}
-	
-	fmt.Printf("\nStorage Details:\n")
-	fmt.Printf("  Location:       %s\n", s.StorageType)
-	if s.Namespace != "" {
-		fmt.Printf("  Namespace:      %s\n", s.Namespace)
-	}
-	if s.StorageType == "memory" {
-		fmt.Printf("  Note:           Data is in-memory only (will be lost on restart)\n")
-		fmt.Printf("                  Use local file storage for persistence\n")
-	}
-	fmt.Printf("========================\n")
+	ing.flushQuarantine()
 }
@@ -0,0 +1,142 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LookupPath resolves a dot-path such as "payload.body.text" in a decoded JSON
+// value, numeric segments index arrays as in "items.0.text"
+func LookupPath(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return nil, false
+	}
+	for _, segment := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// lookupText returns the string at a dot-path, numbers and booleans formatted
+func lookupText(data map[string]interface{}, path string) (string, bool) {
+	value, ok := LookupPath(data, path)
+	if !ok {
+		return "", false
+	}
+	return scalarString(value)
+}
+
+// scalarString formats a JSON string, number or boolean, false for other values
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64, bool:
+		return fmt.Sprintf("%v", v), true
+	default:
+		return "", false
+	}
+}
+
+// jsonMetadata builds the metadata of a JSON record, leaving out the fields at
+// the skip paths. By default it keeps the top-level strings, numbers and booleans;
+// with FlattenMetadata nested objects and arrays become dotted keys
+func jsonMetadata(data map[string]interface{}, config *SourceConfig, skip ...string) map[string]string {
+	metadata := make(map[string]string)
+	excluded := func(path string) bool {
+		for _, s := range skip {
+			if path == s {
+				return true
+			}
+		}
+		for _, prefix := range config.FlattenExclude {
+			if path == prefix || strings.HasPrefix(path, prefix+".") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !config.FlattenMetadata {
+		for key, value := range data {
+			if excluded(key) {
+				continue
+			}
+			if s, ok := scalarString(value); ok {
+				metadata[key] = s
+			}
+		}
+		return metadata
+	}
+
+	flattenJSON(metadata, "", data, 1, config.FlattenDepth, excluded)
+	return metadata
+}
+
+// flattenJSON adds the scalars below value to metadata under their dotted path
+// Objects and arrays below maxDepth levels, when positive, are kept as JSON text
+func flattenJSON(metadata map[string]string, path string, value interface{}, depth, maxDepth int, excluded func(string) bool) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if path != "" && maxDepth > 0 && depth > maxDepth {
+			metadata[path] = compactJSON(v)
+			return
+		}
+		for key, child := range v {
+			key = join(key)
+			if excluded(key) {
+				continue
+			}
+			flattenJSON(metadata, key, child, depth+1, maxDepth, excluded)
+		}
+	case []interface{}:
+		if maxDepth > 0 && depth > maxDepth {
+			metadata[path] = compactJSON(v)
+			return
+		}
+		for i, child := range v {
+			key := join(strconv.Itoa(i))
+			if excluded(key) {
+				continue
+			}
+			flattenJSON(metadata, key, child, depth+1, maxDepth, excluded)
+		}
+	default:
+		if s, ok := scalarString(v); ok {
+			metadata[path] = s
+		}
+	}
+}
+
+// compactJSON encodes a nested value kept whole past the flattening depth
+func compactJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,188 @@
+package ingestion
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const nestedFixture = "testdata/json/nested.jsonl"
+
+// readRecords opens the nested fixture with a text field and returns its records
+func readRecords(t *testing.T, textField string, config *SourceConfig) []*Record {
+	t.Helper()
+	source, err := NewFileSource(nestedFixture, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.SetTextField(textField)
+	if err := source.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	var records []*Record
+	for {
+		record, err := source.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	data := map[string]interface{}{
+		"payload": map[string]interface{}{
+			"body": map[string]interface{}{"text": "hello"},
+		},
+		"items": []interface{}{
+			map[string]interface{}{"text": "first"},
+			"second",
+		},
+	}
+
+	tests := []struct {
+		path  string
+		want  interface{}
+		found bool
+	}{
+		{"payload.body.text", "hello", true},
+		{"items.0.text", "first", true},
+		{"items.1", "second", true},
+		{"items.2", nil, false},
+		{"items.-1", nil, false},
+		{"items.first", nil, false},
+		{"payload.missing.text", nil, false},
+		{"payload.body.text.more", nil, false},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		got, found := LookupPath(data, tt.path)
+		if found != tt.found || (found && got != tt.want) {
+			t.Errorf("LookupPath(%q) = %v, %v, want %v, %v", tt.path, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestFileSource_TextField(t *testing.T) {
+	records := readRecords(t, "payload.body.text", &SourceConfig{})
+
+	// evt-2 has no text and evt-4 has a string where an object is expected
+	if len(records) != 2 {
+		t.Fatalf("read %d records, want 2", len(records))
+	}
+	if records[0].Text != "Deploy finished" || records[1].Text != "Rollback started" {
+		t.Errorf("texts = %q, %q", records[0].Text, records[1].Text)
+	}
+
+	// Without flattening only top-level scalars are kept
+	want := map[string]string{"id": "evt-1"}
+	if !reflect.DeepEqual(records[0].Metadata, want) {
+		t.Errorf("metadata = %v, want %v", records[0].Metadata, want)
+	}
+}
+
+func TestFileSource_TextFieldArrayIndex(t *testing.T) {
+	records := readRecords(t, "items.0.text", &SourceConfig{})
+
+	if len(records) != 2 {
+		t.Fatalf("read %d records, want 2", len(records))
+	}
+	if records[0].Text != "first item" || records[1].Text != "only item" {
+		t.Errorf("texts = %q, %q", records[0].Text, records[1].Text)
+	}
+}
+
+func TestFileSource_FlattenMetadata(t *testing.T) {
+	records := readRecords(t, "payload.body.text", &SourceConfig{
+		FlattenMetadata: true,
+		FlattenExclude:  []string{"payload.raw", "items"},
+	})
+
+	want := map[string]string{
+		"id":                         "evt-1",
+		"payload.body.lang":          "en",
+		"payload.author.name":        "ops",
+		"payload.author.team.name":   "platform",
+		"payload.author.team.region": "eu",
+		"tags.0":                     "deploy",
+		"tags.1":                     "prod",
+	}
+	if !reflect.DeepEqual(records[0].Metadata, want) {
+		t.Errorf("metadata = %v, want %v", records[0].Metadata, want)
+	}
+
+	want = map[string]string{
+		"id":                   "evt-3",
+		"payload.body.retries": "2",
+		"payload.body.urgent":  "true",
+	}
+	if !reflect.DeepEqual(records[1].Metadata, want) {
+		t.Errorf("metadata = %v, want %v", records[1].Metadata, want)
+	}
+}
+
+func TestFileSource_FlattenDepth(t *testing.T) {
+	records := readRecords(t, "payload.body.text", &SourceConfig{
+		Namespace:       "events",
+		FlattenMetadata: true,
+		FlattenDepth:    3,
+		FlattenExclude:  []string{"payload.raw", "items", "tags"},
+	})
+
+	want := map[string]string{
+		"id":                  "evt-1",
+		"namespace":           "events",
+		"payload.body.lang":   "en",
+		"payload.author.name": "ops",
+		"payload.author.team": `{"name":"platform","region":"eu"}`,
+	}
+	if !reflect.DeepEqual(records[0].Metadata, want) {
+		t.Errorf("metadata = %v, want %v", records[0].Metadata, want)
+	}
+}
+
+func TestFileSource_DefaultTextFields(t *testing.T) {
+	records := readRecords(t, "", &SourceConfig{FlattenMetadata: true})
+
+	// No record of the fixture has a top-level text field
+	if len(records) != 0 {
+		t.Errorf("read %d records, want 0", len(records))
+	}
+}
+
+func TestHuggingFaceSource_TextField(t *testing.T) {
+	source := NewHuggingFaceSource("squad", &SourceConfig{FlattenMetadata: true})
+	source.SetTextField("answers.text.0")
+	source.scanner = bufio.NewScanner(strings.NewReader(
+		`{"id": "q1", "answers": {"text": ["Denver Broncos"], "answer_start": [177]}}` + "\n" +
+			`{"id": "q2", "answers": {"text": [], "answer_start": []}}` + "\n"))
+
+	record, err := source.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Text != "Denver Broncos" {
+		t.Errorf("text = %q", record.Text)
+	}
+	want := map[string]string{
+		"id":                     "q1",
+		"answers.answer_start.0": "177",
+		"source":                 "huggingface",
+		"dataset":                "squad",
+	}
+	if !reflect.DeepEqual(record.Metadata, want) {
+		t.Errorf("metadata = %v, want %v", record.Metadata, want)
+	}
+
+	if _, err := source.Next(); err != io.EOF {
+		t.Errorf("Next() error = %v, want EOF after the record without text", err)
+	}
+}
@@ -0,0 +1,55 @@
+package ingestion
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestIngestor_StampsLineage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.jsonl")
+	content := `{"text": "first"}` + "\n\n" + `{"text": "third line"}` + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &SourceConfig{Namespace: "quotes", BatchSize: 10}
+	source, err := NewFileSource(path, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := memory.NewStorage()
+	stats, err := NewIngestor(source, hash.NewHashEmbedder(), store, config).Run(context.Background())
+	if err != nil {
+		t.Fatalf("ingest failed: %v", err)
+	}
+
+	vectors, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make(map[string]bool)
+	for _, vector := range vectors {
+		p := models.ProvenanceOf(vector)
+		if p.Source != "file:quotes.jsonl" || p.RunID != stats.RunID || p.IngestedAt == nil || p.Embedder.Name == "" {
+			t.Errorf("provenance of %s = %+v", vector.ID, p)
+		}
+		records[vector.Metadata[models.LineageRecordKey]] = true
+	}
+	if len(records) != 2 || !records["1"] || !records["3"] {
+		t.Errorf("record lineage = %v, want source line numbers", records)
+	}
+
+	runs, err := store.ListRuns(models.IngestRunFilter{Namespace: "quotes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ID != stats.RunID || runs[0].Stored != 2 {
+		t.Fatalf("recorded runs = %+v, want the one run with 2 stored", runs)
+	}
+}
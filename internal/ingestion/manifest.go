@@ -0,0 +1,349 @@
+package ingestion
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+	"github.com/tahcohcat/same-same/internal/storage/metaschema"
+)
+
+// ManifestFormat identifies same-same ingest manifests
+const ManifestFormat = "same-same-ingest-manifest"
+
+// ManifestVersion is the version of the manifest format written
+// Readers reject newer versions, whose fields they may not understand
+const ManifestVersion = 1
+
+// ManifestSampleSize is the number of stored vector IDs a manifest samples, on
+// top of the first and last, for 'same-same manifest verify' to look up
+const ManifestSampleSize = 100
+
+// Manifest describes what an ingest run produced, to attach to a data catalog
+// entry and to check later that a store still holds the vectors of the run
+type Manifest struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	RunID     string    `json:"run_id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	DryRun    bool      `json:"dry_run,omitempty"` // Nothing was stored, the IDs are those a real run would store
+
+	Source    ManifestSource   `json:"source"`
+	Embedder  ManifestEmbedder `json:"embedder"`
+	Storage   ManifestStorage  `json:"storage"`
+	Namespace string           `json:"namespace,omitempty"`
+	Counts    ManifestCounts   `json:"counts"`
+
+	// FirstID and LastID are the first and last vectors stored, SampleIDs a
+	// uniform sample of the others
+	FirstID   string   `json:"first_id,omitempty"`
+	LastID    string   `json:"last_id,omitempty"`
+	SampleIDs []string `json:"sample_ids,omitempty"`
+
+	// Config is the configuration deciding what was stored, ConfigHash its
+	// SHA-256, equal for runs configured alike
+	Config     ManifestConfig `json:"config"`
+	ConfigHash string         `json:"config_sha256"`
+
+	// Schema summarizes the metadata fields of the vectors stored by the run
+	Schema *metaschema.Schema `json:"schema"`
+}
+
+// ManifestSource is the source of a run and the parameters it was read with
+type ManifestSource struct {
+	Name       string            `json:"name"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Sources    []ManifestSource  `json:"sources,omitempty"` // Files of a multi-file run
+}
+
+// ManifestEmbedder is the embedder of a run
+type ManifestEmbedder struct {
+	Name      string `json:"name"`
+	Model     string `json:"model,omitempty"`
+	Dimension int    `json:"dimension,omitempty"` // Of the embedder, else of the first vector stored
+}
+
+// ManifestStorage is where the vectors of a run were stored
+type ManifestStorage struct {
+	Type       string `json:"type"`
+	Path       string `json:"path,omitempty"`
+	Collection string `json:"collection,omitempty"`
+}
+
+// ManifestCounts are the records of a run by outcome
+type ManifestCounts struct {
+	Total          int            `json:"total"`
+	Stored         int            `json:"stored"`
+	Failed         int            `json:"failed"`
+	Skipped        int            `json:"skipped"`
+	Quarantined    int            `json:"quarantined,omitempty"`
+	FailureReasons map[string]int `json:"failure_reasons,omitempty"`
+	Filtered       map[string]int `json:"filtered,omitempty"`
+}
+
+// ManifestConfig is the part of the SourceConfig deciding which vectors a run stores
+type ManifestConfig struct {
+	Namespace         string               `json:"namespace,omitempty"`
+	NormalizeKeys     bool                 `json:"normalize_keys,omitempty"`
+	UniqueKeys        []string             `json:"unique_keys,omitempty"`
+	Sparse            bool                 `json:"sparse,omitempty"`
+	DedupDistance     *int                 `json:"dedup_distance,omitempty"`
+	DedupExisting     bool                 `json:"dedup_existing,omitempty"`
+	FlattenMetadata   bool                 `json:"flatten_metadata,omitempty"`
+	FlattenDepth      int                  `json:"flatten_depth,omitempty"`
+	FlattenExclude    []string             `json:"flatten_exclude,omitempty"`
+	FieldTypes        map[string]FieldType `json:"field_types,omitempty"`
+	InferTypes        int                  `json:"infer_types,omitempty"`
+	DropInvalidValues bool                 `json:"drop_invalid_values,omitempty"`
+	Where             []string             `json:"where,omitempty"`
+	WhereTextRegex    string               `json:"where_text_regex,omitempty"`
+	DefaultMetadata   *defaults.Config     `json:"default_metadata,omitempty"`
+}
+
+// SourceParameters is implemented by sources that report the parameters they
+// read with, such as the path and text column of a file, for run manifests
+type SourceParameters interface {
+	Parameters() map[string]string
+}
+
+// LoadManifest reads a manifest file, rejecting other formats and newer versions
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if m.Format != ManifestFormat {
+		return nil, fmt.Errorf("%s is not an ingest manifest (format %q)", path, m.Format)
+	}
+	if m.Version > ManifestVersion {
+		return nil, fmt.Errorf("manifest %s has version %d, this build reads up to %d", path, m.Version, ManifestVersion)
+	}
+	return &m, nil
+}
+
+// Write writes the manifest as indented JSON to path
+func (m *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// IDs returns the vector IDs the manifest claims were stored: the first, the
+// sample and the last, without duplicates
+func (m *Manifest) IDs() []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range append(append([]string{m.FirstID}, m.SampleIDs...), m.LastID) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// manifestConfig returns the manifest view of config
+func manifestConfig(config *SourceConfig) ManifestConfig {
+	c := ManifestConfig{
+		Namespace:         config.Namespace,
+		NormalizeKeys:     config.NormalizeKeys,
+		UniqueKeys:        config.UniqueKeys,
+		Sparse:            config.Sparse,
+		DedupExisting:     config.DedupExisting,
+		FlattenMetadata:   config.FlattenMetadata,
+		FlattenDepth:      config.FlattenDepth,
+		FlattenExclude:    config.FlattenExclude,
+		FieldTypes:        config.FieldTypes,
+		InferTypes:        config.InferTypes,
+		DropInvalidValues: config.DropInvalidValues,
+		Where:             config.Where,
+		WhereTextRegex:    config.WhereTextRegex,
+		DefaultMetadata:   config.DefaultMetadata,
+	}
+	if config.DedupImages {
+		distance := config.DedupDistance
+		c.DedupDistance = &distance
+	}
+	return c
+}
+
+// manifestSource describes source, and each file of a composite source
+func manifestSource(source Source) ManifestSource {
+	m := ManifestSource{Name: source.Name()}
+	if params, ok := source.(SourceParameters); ok {
+		m.Parameters = params.Parameters()
+	}
+	if composite, ok := source.(*CompositeSource); ok {
+		for _, part := range composite.parts {
+			m.Sources = append(m.Sources, manifestSource(part.Source))
+		}
+	}
+	return m
+}
+
+// storedLog records the vectors stored by a run for its manifest
+// The Ingestors of a multi-file run share it, so it is safe for concurrent use
+type storedLog struct {
+	mu        sync.Mutex
+	count     int
+	first     string
+	last      string
+	dimension int
+	sample    []string
+	rng       *rand.Rand
+	fields    metaschema.Index
+}
+
+func newStoredLog() *storedLog {
+	return &storedLog{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// add records a stored vector, keeping a reservoir sample of the IDs
+func (l *storedLog) add(vector *models.Vector) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.count++
+	if l.first == "" {
+		l.first = vector.ID
+		l.dimension = vector.Dimension()
+	}
+	l.last = vector.ID
+	if len(l.sample) < ManifestSampleSize {
+		l.sample = append(l.sample, vector.ID)
+	} else if i := l.rng.Intn(l.count); i < ManifestSampleSize {
+		l.sample[i] = vector.ID
+	}
+	l.fields.Add(vector.Metadata)
+}
+
+// Manifest returns the manifest of the run, nil unless SourceConfig.Manifest is set
+// It is complete once Run returned
+func (ing *Ingestor) Manifest() *Manifest {
+	if ing.stored == nil {
+		return nil
+	}
+	stats := ing.stats
+	m := &Manifest{
+		Format:    ManifestFormat,
+		Version:   ManifestVersion,
+		RunID:     stats.RunID,
+		StartTime: stats.StartTime,
+		EndTime:   stats.EndTime,
+		DryRun:    ing.config.DryRun,
+		Source:    manifestSource(ing.source),
+		Embedder:  ManifestEmbedder{Name: ing.embedder.Name()},
+		Storage:   ManifestStorage{Type: stats.StorageType},
+		Namespace: ing.config.Namespace,
+		Counts: ManifestCounts{
+			Total:          stats.TotalRecords,
+			Stored:         stats.SuccessCount,
+			Failed:         stats.FailureCount,
+			Skipped:        stats.SkippedCount,
+			Quarantined:    stats.Quarantined,
+			FailureReasons: stats.FailureReasons,
+			Filtered:       stats.Filtered,
+		},
+		Config: manifestConfig(ing.config),
+	}
+	if modeled, ok := ing.embedder.(embedders.Modeled); ok {
+		m.Embedder.Model = modeled.Model()
+	}
+	if adapter, ok := ing.storage.(interface {
+		Path() string
+		Collection() string
+	}); ok {
+		m.Storage.Path = adapter.Path()
+		m.Storage.Collection = adapter.Collection()
+	}
+
+	log := ing.stored
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	m.FirstID, m.LastID = log.first, log.last
+	m.SampleIDs = append([]string(nil), log.sample...)
+	m.Embedder.Dimension = log.dimension
+	if dimensioned, ok := ing.embedder.(embedders.Dimensioned); ok && dimensioned.Dimensions() > 0 {
+		m.Embedder.Dimension = dimensioned.Dimensions()
+	}
+	m.Schema = log.fields.Schema("")
+
+	// The hash covers how records were read and embedded as well as the config
+	data, _ := json.Marshal(struct {
+		Config   ManifestConfig   `json:"config"`
+		Source   ManifestSource   `json:"source"`
+		Embedder ManifestEmbedder `json:"embedder"`
+	}{m.Config, m.Source, m.Embedder})
+	sum := sha256.Sum256(data)
+	m.ConfigHash = hex.EncodeToString(sum[:])
+	return m
+}
+
+// ManifestReport is the result of checking a store against a manifest
+type ManifestReport struct {
+	RunID   string   `json:"run_id"`
+	Checked int      `json:"checked"`
+	Found   int      `json:"found"`
+	Missing []string `json:"missing"`
+	// Replaced are found vectors last written by another run, such as a re-ingest
+	// updating them by unique key. They are reported but do not fail the check
+	Replaced []string `json:"replaced,omitempty"`
+}
+
+// OK reports whether every vector checked was found
+func (r *ManifestReport) OK() bool {
+	return len(r.Missing) == 0
+}
+
+// Verify looks up the IDs the manifest claims were stored with getMany, which
+// returns the vectors of ids in order, nil for those not stored. At most sample
+// IDs are checked, chosen at random with seed, or all of them when sample is not positive
+func (m *Manifest) Verify(getMany func(ids []string) ([]*models.Vector, error), sample int, seed int64) (*ManifestReport, error) {
+	ids := m.IDs()
+	if sample > 0 && sample < len(ids) {
+		rng := rand.New(rand.NewSource(seed))
+		rng.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+		ids = ids[:sample]
+	}
+
+	report := &ManifestReport{RunID: m.RunID, Checked: len(ids), Missing: []string{}}
+	if len(ids) == 0 {
+		return report, nil
+	}
+	vectors, err := getMany(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up vectors: %w", err)
+	}
+	for i, id := range ids {
+		if i >= len(vectors) || vectors[i] == nil {
+			report.Missing = append(report.Missing, id)
+			continue
+		}
+		report.Found++
+		if run := vectors[i].Metadata[models.LineageRunKey]; run != "" && run != m.RunID {
+			report.Replaced = append(report.Replaced, id)
+		}
+	}
+	return report, nil
+}
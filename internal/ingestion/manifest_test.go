@@ -0,0 +1,91 @@
+package ingestion
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestIngestor_Manifest(t *testing.T) {
+	run := func(where ...string) (*Manifest, *memory.Storage) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		config := &SourceConfig{Namespace: "reviews", BatchSize: 2, Where: where, Manifest: path}
+		source, err := NewFileSource(reviewsFixture, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source.SetTextField("text")
+		store := memory.NewStorage()
+		if _, err := NewIngestor(source, hash.NewHashEmbedder(), store, config).Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		m, err := LoadManifest(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m, store
+	}
+
+	m, store := run("label=pos")
+	if m.Format != ManifestFormat || m.Version != ManifestVersion || m.RunID == "" || m.EndTime.Before(m.StartTime) {
+		t.Errorf("header = %s v%d run %q", m.Format, m.Version, m.RunID)
+	}
+	if m.Source.Name != "file:reviews.jsonl" || m.Source.Parameters["format"] != "jsonl" || m.Source.Parameters["text_field"] != "text" {
+		t.Errorf("source = %+v", m.Source)
+	}
+	if m.Embedder.Name != "local.hash" || m.Embedder.Dimension == 0 || m.Storage.Type != "memory" || m.Namespace != "reviews" {
+		t.Errorf("embedder %+v, storage %+v, namespace %q", m.Embedder, m.Storage, m.Namespace)
+	}
+	if m.Counts.Total != 7 || m.Counts.Stored != store.Count() || m.Counts.Filtered["label=pos"] == 0 {
+		t.Errorf("counts = %+v, stored %d", m.Counts, store.Count())
+	}
+	if m.FirstID == "" || m.LastID == "" || len(m.SampleIDs) != store.Count() {
+		t.Errorf("IDs = %s..%s, sample %v", m.FirstID, m.LastID, m.SampleIDs)
+	}
+	if label := m.Schema.Fields["label"]; label == nil || label.Count != store.Count() || label.Distinct != 1 {
+		t.Errorf("schema label = %+v", label)
+	}
+
+	// The config hash is the same for runs configured alike, and only for them
+	again, _ := run("label=pos")
+	other, _ := run("label=neg")
+	if again.ConfigHash != m.ConfigHash || other.ConfigHash == m.ConfigHash {
+		t.Errorf("config hashes %s, %s and %s", m.ConfigHash, again.ConfigHash, other.ConfigHash)
+	}
+
+	// The store holds the vectors the manifest claims, until one is deleted
+	report, err := m.Verify(store.GetMany, 0, 0)
+	if err != nil || !report.OK() || report.Found != len(m.IDs()) {
+		t.Fatalf("verify = %+v, %v", report, err)
+	}
+	if err := store.Delete(m.LastID); err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := m.Verify(store.GetMany, 0, 0); report.OK() || len(report.Missing) != 1 || report.Missing[0] != m.LastID {
+		t.Errorf("verify after delete = %+v", report)
+	}
+	if report, _ := m.Verify(store.GetMany, 2, 1); report.Checked != 2 {
+		t.Errorf("sampled verify checked %d IDs", report.Checked)
+	}
+}
+
+func TestLoadManifest_Rejects(t *testing.T) {
+	for _, content := range []string{
+		`{"format": "same-same-manifest", "version": 1}`,
+		`{"format": "same-same-ingest-manifest", "version": 99}`,
+		`{"format": `,
+	} {
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadManifest(path); err == nil {
+			t.Errorf("%s was accepted", content)
+		}
+	}
+}
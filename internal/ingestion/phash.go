@@ -0,0 +1,226 @@
+package ingestion
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // Register the GIF decoder
+	_ "image/jpeg" // Register the JPEG decoder
+	_ "image/png"  // Register the PNG decoder
+	"math/bits"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// ImageHashKey is the metadata key of the perceptual hash of an ingested image,
+// 16 hex digits, compared by later runs to skip near duplicates
+const ImageHashKey = "image.dhash"
+
+// DefaultDedupDistance is the Hamming distance under which two image hashes are
+// considered the same picture: resized copies and re-encodes typically differ by a few bits
+const DefaultDedupDistance = 6
+
+// Duplicate is an image skipped as a near duplicate of one already ingested
+type Duplicate struct {
+	ID          string `json:"id"`
+	Path        string `json:"path"`
+	DuplicateOf string `json:"duplicate_of"` // ID of the kept image
+	Distance    int    `json:"distance"`     // Hamming distance between the two hashes
+}
+
+// ImageHash computes the difference hash (dHash) of an image file
+// The image is reduced to 9x8 grayscale cells and each bit records whether a cell
+// is brighter than its right neighbour, so the hash survives resizing and re-encoding
+func ImageHash(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return dHash(img), nil
+}
+
+// dHash computes the difference hash of a decoded image
+func dHash(img image.Image) uint64 {
+	const width, height = 9, 8
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return 0
+	}
+
+	var cells [height][width]float64
+	for cy := 0; cy < height; cy++ {
+		y0 := bounds.Min.Y + cy*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(cy+1)*bounds.Dy()/height, y0+1)
+		for cx := 0; cx < width; cx++ {
+			x0 := bounds.Min.X + cx*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(cx+1)*bounds.Dx()/width, x0+1)
+
+			// Average the luminance of the pixels covered by the cell
+			sum, n := 0.0, 0
+			for y := y0; y < y1 && y < bounds.Max.Y; y++ {
+				for x := x0; x < x1 && x < bounds.Max.X; x++ {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			if n > 0 {
+				cells[cy][cx] = sum / float64(n)
+			}
+		}
+	}
+
+	var hash uint64
+	for cy := 0; cy < height; cy++ {
+		for cx := 0; cx < width-1; cx++ {
+			hash <<= 1
+			if cells[cy][cx] > cells[cy][cx+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns the number of bits in which two hashes differ
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatImageHash returns the metadata form of a hash
+func FormatImageHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParseImageHash parses the metadata form of a hash
+func ParseImageHash(value string) (uint64, error) {
+	return strconv.ParseUint(value, 16, 64)
+}
+
+// imageDeduper remembers the hashes of the images kept so far
+// It is shared by the files of a CompositeSource ingested in parallel
+type imageDeduper struct {
+	mu          sync.Mutex
+	maxDistance int
+	hashes      []uint64
+	ids         []string
+}
+
+func newImageDeduper(maxDistance int) *imageDeduper {
+	return &imageDeduper{maxDistance: maxDistance}
+}
+
+// add remembers the hash of a kept image
+func (d *imageDeduper) add(hash uint64, id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hashes = append(d.hashes, hash)
+	d.ids = append(d.ids, id)
+}
+
+// match returns the ID of the closest kept image within the maximum distance of hash
+func (d *imageDeduper) match(hash uint64) (id string, distance int, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	best := -1
+	for i, seen := range d.hashes {
+		dist := HammingDistance(hash, seen)
+		if dist <= d.maxDistance && (best < 0 || dist < distance) {
+			best, distance = i, dist
+		}
+	}
+	if best < 0 {
+		return "", 0, false
+	}
+	return d.ids[best], distance, true
+}
+
+// loadStored remembers the hashes stored on the vectors of namespace
+func (d *imageDeduper) loadStored(s storage.Storage, namespace string) error {
+	vectors, err := s.ListByNamespace(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to list stored images: %w", err)
+	}
+	for _, vector := range vectors {
+		value, ok := vector.Metadata[ImageHashKey]
+		if !ok {
+			continue
+		}
+		if hash, err := ParseImageHash(value); err == nil {
+			d.add(hash, vector.ID)
+		}
+	}
+	return nil
+}
+
+// prepareDedup sets up image deduplication when configured
+func (ing *Ingestor) prepareDedup() error {
+	if !ing.config.DedupImages {
+		return nil
+	}
+	if ing.config.DedupDistance < 0 || ing.config.DedupDistance > 64 {
+		return fmt.Errorf("invalid dedup distance %d: must be between 0 and 64", ing.config.DedupDistance)
+	}
+
+	ing.dedup = newImageDeduper(ing.config.DedupDistance)
+	if ing.config.DedupExisting {
+		return ing.dedup.loadStored(ing.storage, ing.config.Namespace)
+	}
+	return nil
+}
+
+// hashImage stores the perceptual hash of an image record in its metadata
+// Images that cannot be decoded, such as formats without a Go decoder, are not hashed
+func (ing *Ingestor) hashImage(record *Record) (uint64, bool) {
+	hash, err := ImageHash(record.Text)
+	if err != nil {
+		if ing.config.Verbose {
+			fmt.Printf("Cannot hash image %s, not deduplicating it: %v\n", record.Text, err)
+		}
+		return 0, false
+	}
+
+	if record.Metadata == nil {
+		record.Metadata = make(map[string]string)
+	}
+	record.Metadata[ImageHashKey] = FormatImageHash(hash)
+	return hash, true
+}
+
+// skipDuplicate reports whether an image is a near duplicate of a kept one, counting it as skipped
+func (ing *Ingestor) skipDuplicate(record *Record, hash uint64) bool {
+	if ing.dedup == nil {
+		return false
+	}
+	kept, distance, ok := ing.dedup.match(hash)
+	if !ok {
+		return false
+	}
+
+	ing.stats.SkippedCount++
+	ing.stats.Duplicates = append(ing.stats.Duplicates, Duplicate{
+		ID:          record.ID,
+		Path:        record.Text,
+		DuplicateOf: kept,
+		Distance:    distance,
+	})
+	if ing.config.Verbose {
+		fmt.Printf("Skipping %s: duplicate of %s (distance %d)\n", record.Text, kept, distance)
+	}
+	return true
+}
+
+// rememberImage records the hash of a kept image for the images that follow
+func (ing *Ingestor) rememberImage(hash uint64, id string) {
+	if ing.dedup != nil {
+		ing.dedup.add(hash, id)
+	}
+}
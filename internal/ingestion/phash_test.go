@@ -0,0 +1,145 @@
+package ingestion
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// pattern returns an image of w x h pixels drawing a smooth pattern, shifted in brightness
+func pattern(w, h int, brightness float64, phase float64) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := float64(x)/float64(w), float64(y)/float64(h)
+			v := 100 + 80*math.Sin(6*fx+phase)*math.Cos(4*fy+phase) + brightness
+			img.SetGray(x, y, color.Gray{Y: uint8(math.Max(0, math.Min(255, v)))})
+		}
+	}
+	return img
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeImages writes an original photo, two near duplicates and an unrelated image
+func writeImages(t *testing.T, dir string) {
+	t.Helper()
+	writePNG(t, filepath.Join(dir, "a_original.png"), pattern(160, 120, 0, 0))
+	writePNG(t, filepath.Join(dir, "b_resized.png"), pattern(64, 48, 0, 0))
+	writePNG(t, filepath.Join(dir, "c_brighter.png"), pattern(160, 120, 20, 0))
+	writePNG(t, filepath.Join(dir, "d_other.png"), pattern(160, 120, 0, 2))
+}
+
+func TestImageHash_NearDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	writeImages(t, dir)
+
+	hashes := make(map[string]uint64)
+	for _, name := range []string{"a_original", "b_resized", "c_brighter", "d_other"} {
+		hash, err := ImageHash(filepath.Join(dir, name+".png"))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		hashes[name] = hash
+	}
+
+	for _, copy := range []string{"b_resized", "c_brighter"} {
+		if d := HammingDistance(hashes["a_original"], hashes[copy]); d > DefaultDedupDistance {
+			t.Errorf("%s differs from the original by %d bits, want at most %d", copy, d, DefaultDedupDistance)
+		}
+	}
+	if d := HammingDistance(hashes["a_original"], hashes["d_other"]); d <= DefaultDedupDistance {
+		t.Errorf("an unrelated image differs by only %d bits", d)
+	}
+
+	parsed, err := ParseImageHash(FormatImageHash(hashes["a_original"]))
+	if err != nil || parsed != hashes["a_original"] {
+		t.Errorf("hash did not round trip: %x, %v", parsed, err)
+	}
+}
+
+// fakeImageEmbedder embeds every image as the same vector without decoding it
+type fakeImageEmbedder struct{}
+
+func (fakeImageEmbedder) Embed(string) ([]float64, error)      { return []float64{1, 0}, nil }
+func (fakeImageEmbedder) EmbedImage(string) ([]float64, error) { return []float64{1, 0}, nil }
+func (fakeImageEmbedder) Name() string                         { return "fake-image" }
+
+func TestIngestor_SkipsDuplicateImages(t *testing.T) {
+	dir := t.TempDir()
+	writeImages(t, dir)
+
+	ingest := func(store *memory.Storage, existing bool) *Stats {
+		config := &SourceConfig{
+			BatchSize:     10,
+			DedupImages:   true,
+			DedupDistance: DefaultDedupDistance,
+			DedupExisting: existing,
+		}
+		source, err := NewImageSource(dir, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := NewIngestor(source, fakeImageEmbedder{}, store, config).Run(context.Background())
+		if err != nil {
+			t.Fatalf("ingest failed: %v", err)
+		}
+		return stats
+	}
+
+	store := memory.NewStorage()
+	stats := ingest(store, false)
+	if stats.SuccessCount != 2 || stats.SkippedCount != 2 || len(stats.Duplicates) != 2 {
+		t.Fatalf("stats = %+v, want the original and the other image kept", stats.Report())
+	}
+	for _, dup := range stats.Duplicates {
+		if dup.DuplicateOf != "img_a_original" {
+			t.Errorf("%s: duplicate of %s, want img_a_original", dup.ID, dup.DuplicateOf)
+		}
+	}
+
+	kept, err := store.Get("img_a_original")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseImageHash(kept.Metadata[ImageHashKey]); err != nil {
+		t.Errorf("stored hash %q: %v", kept.Metadata[ImageHashKey], err)
+	}
+
+	// Checking stored hashes skips every image on a second run
+	if stats := ingest(store, true); stats.SuccessCount != 0 || stats.SkippedCount != 4 {
+		t.Errorf("second run stats = %+v, want every image skipped", stats.Report())
+	}
+
+	// Without deduplication every image is kept and hashed
+	config := &SourceConfig{BatchSize: 10}
+	source, _ := NewImageSource(dir, config)
+	fresh := memory.NewStorage()
+	stats, err = NewIngestor(source, fakeImageEmbedder{}, fresh, config).Run(context.Background())
+	if err != nil || stats.SuccessCount != 4 {
+		t.Fatalf("stats without dedup = %+v, %v", stats.Report(), err)
+	}
+	vectors, _ := fresh.ListByNamespace("")
+	for _, vector := range vectors {
+		if vector.Metadata[ImageHashKey] == "" || vector.Metadata["type"] != "image" {
+			t.Errorf("%s: metadata = %v, want the image hash", vector.ID, vector.Metadata)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package ingestion
+
+import (
+	"fmt"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+)
+
+// checkQuarantine fails a run asking for a quarantine the storage does not keep
+func (ing *Ingestor) checkQuarantine() error {
+	if !ing.config.Quarantine || ing.config.DryRun {
+		return nil
+	}
+	if _, ok := ing.storage.(storage.QuarantineStore); !ok {
+		return fmt.Errorf("storage backend does not support a quarantine")
+	}
+	return nil
+}
+
+// quarantineRecord keeps a record that failed for reason, before or while it was
+// embedded, to embed and store it again once the problem is fixed
+func (ing *Ingestor) quarantineRecord(record *Record, reason string, cause error) {
+	ing.quarantine(quarantine.KindRecord, reason, cause, quarantine.Record{ID: record.ID, Text: record.Text, Metadata: record.Metadata})
+}
+
+// quarantineVector keeps an embedded vector the storage rejected for reason
+func (ing *Ingestor) quarantineVector(vector *models.Vector, reason string, cause error) {
+	ing.quarantine(quarantine.KindVector, reason, cause, vector)
+}
+
+// quarantine queues an entry, written to the storage with the next batch
+func (ing *Ingestor) quarantine(kind, reason string, cause error, payload interface{}) {
+	if !ing.config.Quarantine || ing.config.DryRun {
+		return
+	}
+	entry, err := quarantine.NewEntry(kind, ing.source.Name(), reason, cause, payload)
+	if err != nil {
+		fmt.Printf("Failed to quarantine a record: %v\n", err)
+		return
+	}
+	entry.Namespace = ing.config.Namespace
+	ing.quarantined = append(ing.quarantined, entry)
+}
+
+// flushQuarantine writes the queued quarantine entries to the storage
+func (ing *Ingestor) flushQuarantine() {
+	if len(ing.quarantined) == 0 {
+		return
+	}
+	entries := ing.quarantined
+	ing.quarantined = nil
+
+	qs := ing.storage.(storage.QuarantineStore)
+	if err := qs.Quarantine(entries...); err != nil {
+		fmt.Printf("Failed to quarantine %d records: %v\n", len(entries), err)
+		return
+	}
+	ing.stats.Quarantined += len(entries)
+}
@@ -0,0 +1,71 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+)
+
+// failingEmbedder fails to embed the texts containing substr
+type failingEmbedder struct {
+	embedders.Embedder
+	substr string
+}
+
+func (e failingEmbedder) Embed(text string) ([]float64, error) {
+	if strings.Contains(text, e.substr) {
+		return nil, fmt.Errorf("embedding service unavailable")
+	}
+	return e.Embedder.Embed(text)
+}
+
+func TestIngestor_Quarantine(t *testing.T) {
+	config := &SourceConfig{BatchSize: 2, Quarantine: true, Namespace: "reviews"}
+	source, err := NewFileSource(reviewsFixture, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := memory.NewStorage()
+	embedder := failingEmbedder{Embedder: hash.NewHashEmbedder(), substr: "Unlabeled"}
+	stats, err := NewIngestor(source, embedder, store, config).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := store.Quarantined()
+	if stats.Quarantined == 0 || stats.Quarantined != len(entries) {
+		t.Fatalf("stats report %d quarantined, the store holds %d", stats.Quarantined, len(entries))
+	}
+	if stats.SuccessCount+stats.Quarantined != stats.TotalRecords {
+		t.Errorf("stored %d and quarantined %d of %d records", stats.SuccessCount, stats.Quarantined, stats.TotalRecords)
+	}
+	for _, entry := range entries {
+		if entry.Kind != quarantine.KindRecord || entry.Reason != "embed_error" || entry.Namespace != "reviews" {
+			t.Errorf("entry = %+v", entry)
+		}
+		var record quarantine.Record
+		if err := json.Unmarshal(entry.Payload, &record); err != nil || !strings.HasPrefix(record.Text, "Unlabeled") {
+			t.Errorf("payload = %s, %v", entry.Payload, err)
+		}
+	}
+
+	// Without the flag failures are only counted
+	config = &SourceConfig{BatchSize: 2}
+	if source, err = NewFileSource(reviewsFixture, config); err != nil {
+		t.Fatal(err)
+	}
+	store = memory.NewStorage()
+	if stats, err = NewIngestor(source, embedder, store, config).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Quarantined != 0 || len(store.Quarantined()) != 0 || stats.FailureReasons["embed_error"] == 0 {
+		t.Errorf("quarantined %d without the flag, failures %v", stats.Quarantined, stats.FailureReasons)
+	}
+}
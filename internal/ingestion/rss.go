@@ -0,0 +1,671 @@
+package ingestion
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+const (
+	// DefaultFeedPollInterval is how often a feed poll fetches the feeds again
+	DefaultFeedPollInterval = 15 * time.Minute
+
+	// FeedStateFileName is the default state file, kept next to the feed list
+	FeedStateFileName = ".same-same-feeds.json"
+
+	// feedSeenRetention is how long entries that left a feed stay in its seen-set
+	feedSeenRetention = 30 * 24 * time.Hour
+
+	// maxFeedSize caps the feed documents read, so a runaway response cannot exhaust memory
+	maxFeedSize = 32 << 20
+)
+
+// FeedStats are the counts of the feeds read by an RSSSource
+type FeedStats struct {
+	Feeds          int `json:"feeds"`
+	NotModified    int `json:"not_modified,omitempty"`    // Feeds unchanged since the previous fetch
+	Malformed      int `json:"malformed,omitempty"`       // Feeds that could not be fetched or parsed
+	Seen           int `json:"seen,omitempty"`            // Entries ingested by an earlier run
+	MissingContent int `json:"missing_content,omitempty"` // Entries without a summary or content
+}
+
+// FeedCounter is implemented by sources of feeds, which count the feeds they
+// could not read and the entries they skip instead of failing
+type FeedCounter interface {
+	FeedStats() FeedStats
+}
+
+// FeedCache is the state file entry of a feed
+type FeedCache struct {
+	ETag         string               `json:"etag,omitempty"`
+	LastModified string               `json:"last_modified,omitempty"`
+	FetchedAt    time.Time            `json:"fetched_at,omitempty"`
+	Seen         map[string]time.Time `json:"seen"` // GUID, or link, of the entries read and when they were first read
+}
+
+// FeedState records the validators and entries read of each feed, keyed by feed URL
+type FeedState struct {
+	Feeds map[string]*FeedCache `json:"feeds"`
+}
+
+// RSSSource reads the entries of RSS 2.0, RSS 1.0 and Atom feeds
+// Entries in the seen-set of the state file are skipped, and feeds are fetched
+// with the ETag and Last-Modified of the previous fetch, so each run only reads new entries
+type RSSSource struct {
+	name      string
+	urls      []string
+	stateFile string
+	config    *SourceConfig
+	client    *http.Client
+
+	state   *FeedState
+	records []*Record
+	pos     int
+	stats   FeedStats
+}
+
+// NewRSSSource creates a source of the feeds listed one URL per line in a file,
+// where blank lines and lines starting with # are ignored, or of a single feed URL
+func NewRSSSource(arg string, config *SourceConfig) (*RSSSource, error) {
+	s := &RSSSource{
+		name:   "rss:" + arg,
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	if isFeedURL(arg) {
+		s.urls = []string{arg}
+		s.stateFile = FeedStateFileName
+		return s, nil
+	}
+
+	urls, err := readFeedList(arg)
+	if err != nil {
+		return nil, err
+	}
+	s.urls = urls
+	s.stateFile = filepath.Join(filepath.Dir(arg), FeedStateFileName)
+	return s, nil
+}
+
+// SetStateFile sets the file the seen-set and validators are kept in
+func (s *RSSSource) SetStateFile(path string) {
+	if path != "" {
+		s.stateFile = path
+	}
+}
+
+// SetHTTPClient sets the client feeds are fetched with
+func (s *RSSSource) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+func isFeedURL(arg string) bool {
+	return strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+}
+
+// readFeedList reads the feed URLs of a list file
+func readFeedList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feed list: %w", err)
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		url := strings.TrimSpace(scanner.Text())
+		if url == "" || strings.HasPrefix(url, "#") {
+			continue
+		}
+		if !isFeedURL(url) {
+			return nil, fmt.Errorf("%s:%d: %q is not an http or https URL", path, line, url)
+		}
+		urls = append(urls, url)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read feed list: %w", err)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("feed list %s has no URLs", path)
+	}
+	return urls, nil
+}
+
+// Open fetches every feed, keeping the entries not in the seen-set
+// Feeds that cannot be fetched or parsed are counted as malformed and skipped
+func (s *RSSSource) Open(ctx context.Context) error {
+	state, err := loadFeedState(s.stateFile)
+	if err != nil {
+		return err
+	}
+	s.state = state
+	s.records = nil
+	s.pos = 0
+	s.stats = FeedStats{Feeds: len(s.urls)}
+
+	for _, url := range s.urls {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.fetch(ctx, url); err != nil {
+			s.stats.Malformed++
+			if s.config.Verbose {
+				fmt.Printf("Skipping feed %s: %v\n", url, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fetch reads the new entries of a feed
+func (s *RSSSource) fetch(ctx context.Context, url string) error {
+	cache, ok := s.state.Feeds[url]
+	if !ok {
+		cache = &FeedCache{Seen: make(map[string]time.Time)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8")
+	if cache.ETag != "" {
+		req.Header.Set("If-None-Match", cache.ETag)
+	}
+	if cache.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cache.LastModified)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		s.stats.NotModified++
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return err
+	}
+	entries, err := parseFeed(data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	present := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		key := entry.key()
+		present[key] = true
+		if _, seen := cache.Seen[key]; seen {
+			s.stats.Seen++
+			continue
+		}
+		// Entries missing content are marked seen too, so they are counted once
+		cache.Seen[key] = now
+		if entry.content == "" {
+			s.stats.MissingContent++
+			continue
+		}
+		s.records = append(s.records, entry.record(url, key, len(s.records)+1))
+	}
+
+	// Entries that left the feed are forgotten once they are old enough not to come back
+	for key, seen := range cache.Seen {
+		if !present[key] && now.Sub(seen) > feedSeenRetention {
+			delete(cache.Seen, key)
+		}
+	}
+
+	cache.ETag = resp.Header.Get("ETag")
+	cache.LastModified = resp.Header.Get("Last-Modified")
+	cache.FetchedAt = now
+	s.state.Feeds[url] = cache
+	return nil
+}
+
+// Next returns the next new entry or io.EOF when done
+func (s *RSSSource) Next() (*Record, error) {
+	if s.pos >= len(s.records) {
+		return nil, io.EOF
+	}
+	record := s.records[s.pos]
+	s.pos++
+	return record, nil
+}
+
+// Close saves the seen-set and validators of the feeds, unless the run is a dry run
+func (s *RSSSource) Close() error {
+	if s.state == nil || s.config.DryRun {
+		return nil
+	}
+	return s.state.save(s.stateFile)
+}
+
+// Name returns the source name
+// Parameters returns the number of feeds read and the state file of the entries read
+func (s *RSSSource) Parameters() map[string]string {
+	return map[string]string{"feeds": strconv.Itoa(len(s.urls)), "state_file": s.stateFile}
+}
+
+func (s *RSSSource) Name() string {
+	return s.name
+}
+
+// FeedStats returns the counts of the feeds read by the last Open
+func (s *RSSSource) FeedStats() FeedStats {
+	return s.stats
+}
+
+// loadFeedState reads a state file, returning an empty state if it does not exist yet
+func loadFeedState(path string) (*FeedState, error) {
+	state := &FeedState{Feeds: make(map[string]*FeedCache)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse feed state %s: %w", path, err)
+	}
+	if state.Feeds == nil {
+		state.Feeds = make(map[string]*FeedCache)
+	}
+	for _, cache := range state.Feeds {
+		if cache.Seen == nil {
+			cache.Seen = make(map[string]time.Time)
+		}
+	}
+	return state, nil
+}
+
+// save writes the state file atomically so an interrupted write keeps the previous state
+func (state *FeedState) save(path string) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write feed state: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// feedEntry is an entry of an RSS or Atom feed
+type feedEntry struct {
+	feedTitle string
+	guid      string
+	link      string
+	title     string
+	author    string
+	content   string // Plain text of the content, or else the summary
+	published time.Time
+	updated   time.Time
+}
+
+// key identifies the entry in the seen-set: its GUID, else its link, else a hash of its text
+func (e *feedEntry) key() string {
+	switch {
+	case e.guid != "":
+		return e.guid
+	case e.link != "":
+		return e.link
+	}
+	sum := sha256.Sum256([]byte(e.title + "\n" + e.content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// record converts the entry to a Record, with an ID derived from its feed and key
+// so an entry read again after its seen-set entry expired updates its vector
+func (e *feedEntry) record(feed, key string, index int) *Record {
+	sum := sha256.Sum256([]byte(feed + "\n" + key))
+
+	text := e.content
+	if e.title != "" {
+		text = e.title + "\n\n" + e.content
+	}
+
+	metadata := map[string]string{
+		"source": "rss",
+		"feed":   feed,
+	}
+	set := func(key, value string) {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	set("feed_title", e.feedTitle)
+	set("title", e.title)
+	set("link", e.link)
+	set("author", e.author)
+	set("guid", e.guid)
+
+	published := e.published
+	if published.IsZero() {
+		published = e.updated
+	}
+	if !published.IsZero() {
+		// created_at is the default time field of temporal search
+		metadata["published_at"] = published.UTC().Format(time.RFC3339)
+		metadata["created_at"] = metadata["published_at"]
+	}
+	if !e.updated.IsZero() {
+		metadata["updated_at"] = e.updated.UTC().Format(time.RFC3339)
+	}
+
+	return &Record{
+		ID:       "rss_" + hex.EncodeToString(sum[:8]),
+		Text:     text,
+		Metadata: metadata,
+		Index:    index,
+	}
+}
+
+// feedDocument decodes RSS 2.0 (<rss><channel><item>), RSS 1.0 (<rdf:RDF><item>) and Atom (<feed><entry>)
+type feedDocument struct {
+	XMLName xml.Name
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"`
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Author      string `xml:"author"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	GUID        string `xml:"guid"`
+}
+
+type atomEntry struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Summary string `xml:"summary"`
+	Content string `xml:"content"`
+	Authors []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// parseFeed decodes the entries of an RSS or Atom document
+func parseFeed(data []byte) ([]*feedEntry, error) {
+	var doc feedDocument
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// Feeds declaring Latin-1 are almost always ASCII or UTF-8 in practice
+		return input, nil
+	}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+
+	var entries []*feedEntry
+	switch strings.ToLower(doc.XMLName.Local) {
+	case "rss", "rdf":
+		items, title := doc.Channel.Items, doc.Channel.Title
+		items = append(items, doc.Items...)
+		for _, item := range items {
+			entries = append(entries, item.entry(title))
+		}
+	case "feed":
+		for _, entry := range doc.Entries {
+			entries = append(entries, entry.entry(plainText(doc.Title)))
+		}
+	default:
+		return nil, fmt.Errorf("invalid feed: unexpected root element <%s>", doc.XMLName.Local)
+	}
+	return entries, nil
+}
+
+func (item rssItem) entry(feedTitle string) *feedEntry {
+	content := plainText(item.Content)
+	if content == "" {
+		content = plainText(item.Description)
+	}
+	author := strings.TrimSpace(item.Creator)
+	if author == "" {
+		author = strings.TrimSpace(item.Author)
+	}
+	published := parseFeedTime(item.PubDate)
+	if published.IsZero() {
+		published = parseFeedTime(item.Date)
+	}
+	return &feedEntry{
+		feedTitle: strings.TrimSpace(feedTitle),
+		guid:      strings.TrimSpace(item.GUID),
+		link:      strings.TrimSpace(item.Link),
+		title:     plainText(item.Title),
+		author:    author,
+		content:   content,
+		published: published,
+	}
+}
+
+func (entry atomEntry) entry(feedTitle string) *feedEntry {
+	var link string
+	for _, l := range entry.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			link = strings.TrimSpace(l.Href)
+			break
+		}
+	}
+	content := plainText(entry.Content)
+	if content == "" {
+		content = plainText(entry.Summary)
+	}
+	var authors []string
+	for _, author := range entry.Authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			authors = append(authors, name)
+		}
+	}
+	return &feedEntry{
+		feedTitle: feedTitle,
+		guid:      strings.TrimSpace(entry.ID),
+		link:      link,
+		title:     plainText(entry.Title),
+		author:    strings.Join(authors, ", "),
+		content:   content,
+		published: parseFeedTime(entry.Published),
+		updated:   parseFeedTime(entry.Updated),
+	}
+}
+
+var (
+	htmlTagPattern  = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlDropPattern = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	spacePattern    = regexp.MustCompile(`\s+`)
+)
+
+// plainText strips the markup of an HTML fragment, unescaping entities and collapsing whitespace
+func plainText(s string) string {
+	s = htmlDropPattern.ReplaceAllString(s, " ")
+	s = htmlTagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+}
+
+// feedTimeLayouts are the date formats seen in feeds, RFC 822 variants for RSS and RFC 3339 for Atom
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseFeedTime parses a feed date, the zero time when it has none or an unknown format
+func parseFeedTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// FeedPoller ingests the new entries of an RSSSource on an interval
+type FeedPoller struct {
+	source       *RSSSource
+	interval     time.Duration
+	sourceConfig *SourceConfig
+	embedder     embedders.Embedder
+	storage      storage.Storage
+
+	totals  *Stats
+	polls   int
+	emitter *StatsEmitter // Appends the stats file of the source config while running
+
+	mu sync.Mutex // guards totals and polls for summaries
+}
+
+// NewFeedPoller creates a poller of the feeds of source, every DefaultFeedPollInterval when interval is not positive
+func NewFeedPoller(source *RSSSource, interval time.Duration, sourceConfig *SourceConfig, embedder embedders.Embedder, storage storage.Storage) *FeedPoller {
+	if interval <= 0 {
+		interval = DefaultFeedPollInterval
+	}
+	return &FeedPoller{
+		source:       source,
+		interval:     interval,
+		sourceConfig: sourceConfig,
+		embedder:     embedder,
+		storage:      storage,
+		totals: &Stats{
+			FailureReasons: make(map[string]int),
+			Namespace:      sourceConfig.Namespace,
+			StorageType:    storageType(storage),
+			Embedder:       embedder.Name(),
+			StartTime:      time.Now(),
+		},
+	}
+}
+
+// Run polls the feeds now and then every interval until ctx is cancelled
+// A poll that fails is reported and retried at the next interval
+func (p *FeedPoller) Run(ctx context.Context) (err error) {
+	// The polls report to one emitter, so the stats file covers every poll
+	if p.sourceConfig.StatsFile != "" {
+		if p.emitter, err = NewStatsEmitter(p.sourceConfig.StatsFile, p.sourceConfig.StatsInterval, ""); err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := p.emitter.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll runs the source through the ingestion pipeline once
+func (p *FeedPoller) poll(ctx context.Context) {
+	ingestor := NewIngestor(p.source, p.embedder, p.storage, p.sourceConfig)
+	ingestor.SetStatsEmitter(p.emitter)
+	stats, err := ingestor.Run(ctx)
+	if stats != nil {
+		p.mu.Lock()
+		p.polls++
+		p.totals.add(stats)
+		p.mu.Unlock()
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Printf("Failed to poll %s: %v\n", p.source.Name(), err)
+		}
+		return
+	}
+
+	feeds := p.source.FeedStats()
+	fmt.Printf("[%s] polled %d feeds: %d new entries stored, %d failed, %d feeds unchanged, %d malformed\n",
+		time.Now().Format(time.TimeOnly), feeds.Feeds, stats.SuccessCount, stats.FailureCount, feeds.NotModified, feeds.Malformed)
+}
+
+// Totals returns the number of polls and the combined stats of the poller
+func (p *FeedPoller) Totals() (int, Stats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	totals := *p.totals
+	totals.FailureReasons = make(map[string]int, len(p.totals.FailureReasons))
+	for reason, count := range p.totals.FailureReasons {
+		totals.FailureReasons[reason] = count
+	}
+	if p.totals.Feeds != nil {
+		feeds := *p.totals.Feeds
+		totals.Feeds = &feeds
+	}
+	totals.EndTime = time.Now()
+	totals.Duration = totals.EndTime.Sub(totals.StartTime)
+	return p.polls, totals
+}
@@ -0,0 +1,276 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// feedServer serves feed documents by path, honouring If-None-Match against a version ETag
+type feedServer struct {
+	mu          sync.Mutex
+	feeds       map[string][]byte
+	version     int
+	conditional int // Requests answered with 304
+}
+
+func newFeedServer(t *testing.T, fixtures ...string) (*feedServer, *httptest.Server) {
+	t.Helper()
+	fs := &feedServer{feeds: make(map[string][]byte), version: 1}
+	for _, name := range fixtures {
+		data, err := os.ReadFile(filepath.Join("testdata", "rss", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.feeds["/"+name] = data
+	}
+	server := httptest.NewServer(fs)
+	t.Cleanup(server.Close)
+	return fs, server
+}
+
+func (fs *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	data, ok := fs.feeds[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	etag := fmt.Sprintf(`"v%d"`, fs.version)
+	if r.Header.Get("If-None-Match") == etag {
+		fs.conditional++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(data)
+}
+
+// update replaces a feed document and its ETag
+func (fs *feedServer) update(path string, data []byte) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.feeds[path] = data
+	fs.version++
+}
+
+// writeFeedList writes a feed list file of urls, returning its path
+func writeFeedList(t *testing.T, urls ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "feeds.txt")
+	content := "# feeds to follow\n\n" + strings.Join(urls, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readAllRecords(t *testing.T, source Source) []*Record {
+	t.Helper()
+	var records []*Record
+	for {
+		record, err := source.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestRSSSource_ParsesRSSAndAtom(t *testing.T) {
+	_, server := newFeedServer(t, "rss2.xml", "atom.xml", "malformed.xml")
+	list := writeFeedList(t, server.URL+"/rss2.xml", server.URL+"/atom.xml", server.URL+"/malformed.xml", server.URL+"/missing.xml")
+
+	source, err := NewRSSSource(list, &SourceConfig{})
+	if err != nil {
+		t.Fatalf("NewRSSSource() error = %v", err)
+	}
+	if err := source.Open(context.Background()); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	records := readAllRecords(t, source)
+	if err := source.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 4 {
+		t.Fatalf("read %d records, want 4", len(records))
+	}
+	want := FeedStats{Feeds: 4, Malformed: 2, MissingContent: 1}
+	if got := source.FeedStats(); got != want {
+		t.Errorf("FeedStats() = %+v, want %+v", got, want)
+	}
+
+	rss := records[0]
+	if rss.Text != "Quantized indexes in practice\n\nProduct quantization cuts memory eightfold & keeps recall high." {
+		t.Errorf("RSS text = %q", rss.Text)
+	}
+	for key, value := range map[string]string{
+		"title":        "Quantized indexes in practice",
+		"link":         "https://news.example/quantized",
+		"author":       "Ada Lovelace",
+		"guid":         "vw-101",
+		"feed_title":   "Vector Weekly",
+		"published_at": "2024-09-03T07:30:00Z",
+		"created_at":   "2024-09-03T07:30:00Z",
+	} {
+		if rss.Metadata[key] != value {
+			t.Errorf("RSS metadata %s = %q, want %q", key, rss.Metadata[key], value)
+		}
+	}
+	if records[1].Text != "Hybrid search explained\n\nCombining BM25 with dense vectors." || records[1].Metadata["published_at"] != "2024-09-02T08:00:00Z" {
+		t.Errorf("RSS record without content:encoded = %q, %v", records[1].Text, records[1].Metadata)
+	}
+
+	atom := records[2]
+	if atom.Text != "Cosine vs dot product\n\nNormalized vectors make them equivalent." {
+		t.Errorf("Atom text = %q", atom.Text)
+	}
+	for key, value := range map[string]string{
+		"link":         "https://notes.example/cosine",
+		"author":       "Alan Turing",
+		"feed_title":   "Embedding Notes",
+		"published_at": "2024-09-04T14:15:00Z",
+		"updated_at":   "2024-09-04T12:00:00Z",
+	} {
+		if atom.Metadata[key] != value {
+			t.Errorf("Atom metadata %s = %q, want %q", key, atom.Metadata[key], value)
+		}
+	}
+	// An entry without a published date falls back to its update time
+	if records[3].Metadata["published_at"] != "2024-09-01T07:00:00Z" || records[3].Text != "Chunking long documents\n\nOverlap chunks by a few sentences." {
+		t.Errorf("Atom entry with content = %q, %v", records[3].Text, records[3].Metadata)
+	}
+
+	ids := make(map[string]bool)
+	for _, record := range records {
+		ids[record.ID] = true
+	}
+	if len(ids) != len(records) {
+		t.Errorf("record IDs are not unique: %v", ids)
+	}
+}
+
+func TestRSSSource_IngestsOnlyNewEntries(t *testing.T) {
+	fs, server := newFeedServer(t, "rss2.xml")
+	list := writeFeedList(t, server.URL+"/rss2.xml")
+	store := memory.NewStorage()
+	config := &SourceConfig{BatchSize: 10}
+
+	run := func() *Stats {
+		t.Helper()
+		// A new source each run, so only the state file carries over
+		source, err := NewRSSSource(list, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := NewIngestor(source, hash.NewHashEmbedder(), store, config).Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return stats
+	}
+
+	if stats := run(); stats.SuccessCount != 2 || store.Count() != 2 {
+		t.Fatalf("first run stored %d (%d in store), want 2", stats.SuccessCount, store.Count())
+	}
+
+	// Unchanged feeds are not downloaded again
+	if stats := run(); stats.TotalRecords != 0 || fs.conditional != 1 || stats.Feeds.NotModified != 1 {
+		t.Errorf("second run read %d records with %d conditional hits, feeds %+v", stats.TotalRecords, fs.conditional, stats.Feeds)
+	}
+
+	// A changed feed only yields the entries not seen before
+	data, _ := os.ReadFile(filepath.Join("testdata", "rss", "rss2.xml"))
+	item := `<item><title>Filtered search</title><guid>vw-102</guid><description>Pre-filtering vs post-filtering.</description></item>`
+	fs.update("/rss2.xml", []byte(strings.Replace(string(data), "<item>", item+"<item>", 1)))
+
+	stats := run()
+	if stats.SuccessCount != 1 || store.Count() != 3 || stats.Feeds.Seen != 3 {
+		t.Errorf("third run stored %d (%d in store), feeds %+v, want only the new entry", stats.SuccessCount, store.Count(), stats.Feeds)
+	}
+
+	state, err := loadFeedState(filepath.Join(filepath.Dir(list), FeedStateFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache := state.Feeds[server.URL+"/rss2.xml"]; cache == nil || len(cache.Seen) != 4 || cache.ETag != `"v2"` {
+		t.Errorf("state = %+v, want 4 seen entries and the latest ETag", cache)
+	}
+}
+
+func TestRSSSource_DryRunKeepsState(t *testing.T) {
+	_, server := newFeedServer(t, "atom.xml")
+	list := writeFeedList(t, server.URL+"/atom.xml")
+
+	source, err := NewRSSSource(list, &SourceConfig{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	readAllRecords(t, source)
+	if err := source.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(list), FeedStateFileName)); !os.IsNotExist(err) {
+		t.Errorf("dry run wrote the state file: %v", err)
+	}
+}
+
+func TestNewRSSSource_InvalidList(t *testing.T) {
+	if _, err := NewRSSSource(writeFeedList(t, "ftp://feeds.example/rss"), &SourceConfig{}); err == nil {
+		t.Error("accepted a non-HTTP feed URL")
+	}
+	if _, err := NewRSSSource(writeFeedList(t), &SourceConfig{}); err == nil {
+		t.Error("accepted an empty feed list")
+	}
+}
+
+func TestFeedPoller_PollsOnInterval(t *testing.T) {
+	fs, server := newFeedServer(t, "atom.xml")
+	source, err := NewRSSSource(server.URL+"/atom.xml", &SourceConfig{BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.SetStateFile(filepath.Join(t.TempDir(), FeedStateFileName))
+
+	store := memory.NewStorage()
+	poller := NewFeedPoller(source, 50*time.Millisecond, &SourceConfig{BatchSize: 10}, hash.NewHashEmbedder(), store)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- poller.Run(ctx) }()
+
+	waitFor(t, "the feed to be polled again", func() bool {
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		return fs.conditional >= 2
+	})
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	polls, totals := poller.Totals()
+	if polls < 3 || totals.SuccessCount != 2 || store.Count() != 2 || totals.Feeds.NotModified < 2 {
+		t.Errorf("%d polls stored %d (%d in store), feeds %+v", polls, totals.SuccessCount, store.Count(), totals.Feeds)
+	}
+}
@@ -2,6 +2,9 @@ package ingestion
 
 import (
 	"context"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
 )
 
 // Record represents a single data record to be ingested
@@ -9,6 +12,9 @@ type Record struct {
 	ID       string
 	Text     string
 	Metadata map[string]string
+
+	// Index is the 1-based line, row or file number of the record in its source, 0 when unknown
+	Index int
 }
 
 // Source defines the interface for data sources
@@ -26,6 +32,12 @@ type Source interface {
 	Name() string
 }
 
+// ColumnMismatchCounter is implemented by sources of rows with a fixed set of columns,
+// such as CSV files, that read rows with a different column count instead of failing
+type ColumnMismatchCounter interface {
+	ColumnMismatches() int
+}
+
 // SourceConfig contains common configuration for all sources
 type SourceConfig struct {
 	// Namespace to use for ingested vectors
@@ -39,4 +51,74 @@ type SourceConfig struct {
 	
 	// Verbose logging
 	Verbose bool
+	
+	// NormalizeKeys lowercases and snake_cases metadata keys, records with
+	// keys that collide with different values fail with key_collision
+	NormalizeKeys bool
+	
+	// UniqueKeys are metadata fields identifying a record, such as a ticket ID
+	// A record whose value is already stored updates that vector instead of adding one
+	UniqueKeys []string
+	
+	// Sparse stores text embeddings as sparse vectors when the embedder can emit them
+	Sparse bool
+	
+	// DedupImages skips images whose perceptual hash is within DedupDistance bits
+	// of an image already kept in the run, or stored when DedupExisting is set
+	DedupImages   bool
+	DedupDistance int
+	DedupExisting bool
+	
+	// FlattenMetadata stores the nested objects and arrays of JSON records as
+	// dotted metadata keys such as "author.name" or "tags.0", FlattenDepth limits
+	// the levels flattened (0 for all) and deeper values are kept as JSON text
+	FlattenMetadata bool
+	FlattenDepth    int
+	
+	// FlattenExclude lists dot-paths left out of the metadata of JSON records
+	FlattenExclude []string
+	
+	// ParallelFiles is how many sources of a CompositeSource are ingested at once, 1 when unset
+	ParallelFiles int
+	
+	// FailFast stops a CompositeSource at the first source that cannot be read
+	// instead of moving on to the others
+	FailFast bool
+	
+	// FieldTypes coerces the values of metadata fields, as named in the source,
+	// to canonical forms of their type so numeric and datetime filters work
+	FieldTypes map[string]FieldType
+	
+	// InferTypes samples this many records to type the fields FieldTypes leaves out, 0 disables it
+	InferTypes int
+	
+	// DropInvalidValues drops values that cannot be coerced to the type of their
+	// field rather than storing them as they are. Either way they are counted
+	DropInvalidValues bool
+	
+	// Where holds conditions such as "label=pos" or "year>=2015", in the operator
+	// language of search filters, that the metadata of a record must all match for
+	// it to be embedded. WhereTextRegex must match the text of the record
+	Where          []string
+	WhereTextRegex string
+	
+	// ImageExtensions are the file extensions image directories are scanned for,
+	// those the embedder can decode, DefaultImageExtensions when empty
+	ImageExtensions []string
+	
+	// Quarantine keeps the records failing to embed or validate in the quarantine
+	// of the storage, with the reason and the record, to retry them later
+	Quarantine bool
+	
+	// DefaultMetadata is added to the records lacking its keys, as the server adds
+	// it to written vectors; records setting a locked key otherwise fail
+	DefaultMetadata *defaults.Config
+	
+	// Manifest is the path Run writes the manifest of the run to, see Manifest
+	Manifest string
+	
+	// StatsFile is appended a StatsLine every StatsInterval while records are ingested,
+	// and a final one when the run ends, DefaultStatsInterval when the interval is unset
+	StatsFile     string
+	StatsInterval time.Duration
 }
@@ -0,0 +1,320 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/tiered"
+)
+
+// StatsFormat selects how ingestion statistics are written
+type StatsFormat string
+
+const (
+	// StatsText is the human readable summary table
+	StatsText StatsFormat = "text"
+	// StatsJSON is a single JSON object, see StatsReport
+	StatsJSON StatsFormat = "json"
+)
+
+// ParseStatsFormat validates a --stats-format value
+func ParseStatsFormat(format string) (StatsFormat, error) {
+	switch StatsFormat(format) {
+	case StatsText, StatsJSON:
+		return StatsFormat(format), nil
+	default:
+		return "", fmt.Errorf("unsupported stats format %q: must be %s or %s", format, StatsText, StatsJSON)
+	}
+}
+
+// maxListedDuplicates is the number of duplicate images the text summary lists
+const maxListedDuplicates = 10
+
+// StatsReport is the machine readable shape of Stats
+type StatsReport struct {
+	RunID          string         `json:"run_id,omitempty"`
+	Total          int            `json:"total"`
+	Succeeded      int            `json:"succeeded"`
+	Failed         int            `json:"failed"`
+	Skipped        int            `json:"skipped"`
+	FailureRate    float64        `json:"failure_rate"`
+	FailureReasons map[string]int `json:"failure_reasons"`
+	StartTime      time.Time      `json:"start_time"`
+	EndTime        time.Time      `json:"end_time"`
+	DurationMs     int64          `json:"duration_ms"`
+	RecordsPerSec  float64        `json:"records_per_sec"`
+	Namespace      string         `json:"namespace,omitempty"`
+	Storage        string         `json:"storage"`
+	Embedder       string         `json:"embedder,omitempty"`
+	Duplicates     []Duplicate    `json:"duplicates,omitempty"`
+	// ColumnMismatches counts CSV rows whose column count differs from the headers
+	ColumnMismatches int `json:"column_mismatches,omitempty"`
+	// Feeds counts the feeds read and entries skipped by an RSS run
+	Feeds *FeedStats `json:"feeds,omitempty"`
+	// Files breaks the counts down per source of a multi-file run
+	Files []FileStats `json:"files,omitempty"`
+	// FieldTypes are the types metadata fields were coerced to
+	FieldTypes map[string]FieldType `json:"field_types,omitempty"`
+	// CoercionFailures counts per field the values that could not be coerced
+	CoercionFailures map[string]int `json:"coercion_failures,omitempty"`
+	// Filtered counts the records skipped by each --where filter, also counted as skipped
+	Filtered map[string]int `json:"filtered,omitempty"`
+	// Quarantined counts the failed records kept in the quarantine of the storage
+	Quarantined int `json:"quarantined,omitempty"`
+}
+
+// FileStats are the counts of one source of a CompositeSource run
+type FileStats struct {
+	Source     string `json:"source"`
+	Total      int    `json:"total"`
+	Succeeded  int    `json:"succeeded"`
+	Failed     int    `json:"failed"`
+	Skipped    int    `json:"skipped"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"` // Why the source could not be fully read
+}
+
+// Report returns the machine readable shape of the stats
+func (s *Stats) Report() StatsReport {
+	reasons := make(map[string]int, len(s.FailureReasons))
+	for reason, count := range s.FailureReasons {
+		reasons[reason] = count
+	}
+	return StatsReport{
+		RunID:          s.RunID,
+		Total:          s.TotalRecords,
+		Succeeded:      s.SuccessCount,
+		Failed:         s.FailureCount,
+		Skipped:        s.SkippedCount,
+		FailureRate:    s.FailureRate(),
+		FailureReasons: reasons,
+		StartTime:      s.StartTime,
+		EndTime:        s.EndTime,
+		DurationMs:     s.Duration.Milliseconds(),
+		RecordsPerSec:  s.RecordsPerSec,
+		Namespace:      s.Namespace,
+		Storage:        s.StorageType,
+		Embedder:       s.Embedder,
+		Duplicates:     s.Duplicates,
+
+		ColumnMismatches: s.ColumnMismatches,
+		Feeds:            s.Feeds,
+		Files:            s.Files,
+		FieldTypes:       s.FieldTypes,
+		CoercionFailures: s.CoercionFailures,
+		Filtered:         s.Filtered,
+		Quarantined:      s.Quarantined,
+	}
+}
+
+// add adds the counts of other, such as those of one file, to s
+func (s *Stats) add(other *Stats) {
+	s.TotalRecords += other.TotalRecords
+	s.SuccessCount += other.SuccessCount
+	s.FailureCount += other.FailureCount
+	s.SkippedCount += other.SkippedCount
+	s.ColumnMismatches += other.ColumnMismatches
+	s.Quarantined += other.Quarantined
+	if other.Feeds != nil {
+		if s.Feeds == nil {
+			s.Feeds = &FeedStats{}
+		}
+		s.Feeds.Feeds += other.Feeds.Feeds
+		s.Feeds.NotModified += other.Feeds.NotModified
+		s.Feeds.Malformed += other.Feeds.Malformed
+		s.Feeds.Seen += other.Feeds.Seen
+		s.Feeds.MissingContent += other.Feeds.MissingContent
+	}
+	s.Duplicates = append(s.Duplicates, other.Duplicates...)
+	for reason, count := range other.FailureReasons {
+		s.FailureReasons[reason] += count
+	}
+	for field, count := range other.CoercionFailures {
+		if s.CoercionFailures == nil {
+			s.CoercionFailures = make(map[string]int)
+		}
+		s.CoercionFailures[field] += count
+	}
+	for filter, count := range other.Filtered {
+		if s.Filtered == nil {
+			s.Filtered = make(map[string]int)
+		}
+		s.Filtered[filter] += count
+	}
+}
+
+// MarshalJSON encodes the stats as a StatsReport
+func (s Stats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Report())
+}
+
+// FailureRate returns the fraction of records that failed, 0 when nothing was read
+func (s *Stats) FailureRate() float64 {
+	if s.TotalRecords == 0 {
+		return 0
+	}
+	return float64(s.FailureCount) / float64(s.TotalRecords)
+}
+
+// Write writes the stats to w in the given format
+func (s *Stats) Write(w io.Writer, format StatsFormat) error {
+	switch format {
+	case StatsJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	case StatsText, "":
+		s.writeText(w)
+		return nil
+	default:
+		return fmt.Errorf("unsupported stats format %q", format)
+	}
+}
+
+// Print prints ingestion statistics
+func (s *Stats) Print() {
+	s.writeText(os.Stdout)
+}
+
+func (s *Stats) writeText(w io.Writer) {
+	fmt.Fprintf(w, "\n=== Ingestion Complete ===\n")
+	if s.RunID != "" {
+		fmt.Fprintf(w, "Run ID:           %s\n", s.RunID)
+	}
+	fmt.Fprintf(w, "Total Records:    %d\n", s.TotalRecords)
+	fmt.Fprintf(w, "Successfully Ingested: %d\n", s.SuccessCount)
+	fmt.Fprintf(w, "Failed:           %d\n", s.FailureCount)
+	fmt.Fprintf(w, "Skipped:          %d\n", s.SkippedCount)
+	fmt.Fprintf(w, "Duration:         %v\n", s.Duration)
+	fmt.Fprintf(w, "Speed:            %.2f records/sec\n", s.RecordsPerSec)
+
+	if len(s.FailureReasons) > 0 {
+		reasons := make([]string, 0, len(s.FailureReasons))
+		for reason := range s.FailureReasons {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+
+		fmt.Fprintf(w, "\nFailure Breakdown:\n")
+		for _, reason := range reasons {
+			fmt.Fprintf(w, "  %s: %d\n", reason, s.FailureReasons[reason])
+		}
+	}
+
+	if len(s.Filtered) > 0 {
+		filters := make([]string, 0, len(s.Filtered))
+		for filter := range s.Filtered {
+			filters = append(filters, filter)
+		}
+		sort.Strings(filters)
+
+		fmt.Fprintf(w, "\nFiltered Out:\n")
+		for _, filter := range filters {
+			fmt.Fprintf(w, "  %s: %d\n", filter, s.Filtered[filter])
+		}
+	}
+
+	if s.Quarantined > 0 {
+		fmt.Fprintf(w, "\nQuarantined:      %d failed records, see GET /api/v1/admin/quarantine\n", s.Quarantined)
+	}
+
+	if s.ColumnMismatches > 0 {
+		fmt.Fprintf(w, "\nColumn Mismatches: %d rows with more or fewer columns than the headers\n", s.ColumnMismatches)
+	}
+
+	if s.Feeds != nil {
+		fmt.Fprintf(w, "\nFeeds:            %d read, %d unchanged, %d malformed\n", s.Feeds.Feeds, s.Feeds.NotModified, s.Feeds.Malformed)
+		fmt.Fprintf(w, "Entries Skipped:  %d already ingested, %d missing content\n", s.Feeds.Seen, s.Feeds.MissingContent)
+	}
+
+	if len(s.FieldTypes) > 0 {
+		fields := make([]string, 0, len(s.FieldTypes))
+		for field := range s.FieldTypes {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		fmt.Fprintf(w, "\nField Types:\n")
+		for _, field := range fields {
+			fmt.Fprintf(w, "  %s: %s", field, s.FieldTypes[field])
+			if failed := s.CoercionFailures[field]; failed > 0 {
+				fmt.Fprintf(w, " (%d values not coerced)", failed)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	if len(s.Files) > 0 {
+		fmt.Fprintf(w, "\nFiles: %d\n", len(s.Files))
+		for _, file := range s.Files {
+			fmt.Fprintf(w, "  %s: %d stored, %d failed, %d skipped of %d in %v\n", file.Source,
+				file.Succeeded, file.Failed, file.Skipped, file.Total, time.Duration(file.DurationMs)*time.Millisecond)
+			if file.Error != "" {
+				fmt.Fprintf(w, "    error: %s\n", file.Error)
+			}
+		}
+	}
+
+	if len(s.Duplicates) > 0 {
+		fmt.Fprintf(w, "\nDuplicate Images: %d\n", len(s.Duplicates))
+		for i, dup := range s.Duplicates {
+			if i == maxListedDuplicates {
+				fmt.Fprintf(w, "  ... %d more\n", len(s.Duplicates)-i)
+				break
+			}
+			fmt.Fprintf(w, "  %s: duplicate of %s (distance %d)\n", dup.Path, dup.DuplicateOf, dup.Distance)
+		}
+	}
+
+	fmt.Fprintf(w, "\nStorage Details:\n")
+	fmt.Fprintf(w, "  Location:       %s\n", s.StorageType)
+	if s.Namespace != "" {
+		fmt.Fprintf(w, "  Namespace:      %s\n", s.Namespace)
+	}
+	if s.Embedder != "" {
+		fmt.Fprintf(w, "  Embedder:       %s\n", s.Embedder)
+	}
+	if s.StorageType == "memory" {
+		fmt.Fprintf(w, "  Note:           Data is in-memory only (will be lost on restart)\n")
+		fmt.Fprintf(w, "                  Use local file storage for persistence\n")
+	}
+	fmt.Fprintf(w, "========================\n")
+}
+
+// StatsThresholds decide whether an ingestion that completed counts as failed
+type StatsThresholds struct {
+	// MaxFailureRate fails the run when failures/total exceeds it, negative disables the check
+	MaxFailureRate float64
+	// FailOnZero fails the run when no record was ingested
+	FailOnZero bool
+}
+
+// Check returns an error describing the first threshold the stats break
+// Dry runs store nothing, so FailOnZero counts records that would have been stored
+func (t StatsThresholds) Check(s *Stats) error {
+	if t.MaxFailureRate >= 0 && s.FailureRate() > t.MaxFailureRate {
+		return fmt.Errorf("failure rate %.4f (%d of %d records) exceeds %.4f", s.FailureRate(), s.FailureCount, s.TotalRecords, t.MaxFailureRate)
+	}
+	if t.FailOnZero && s.SuccessCount == 0 {
+		return fmt.Errorf("no records were ingested (%d read, %d failed, %d skipped)", s.TotalRecords, s.FailureCount, s.SkippedCount)
+	}
+	return nil
+}
+
+// storageType names the kind of storage vectors are ingested into
+func storageType(s storage.Storage) string {
+	switch s.(type) {
+	case *local.VectorStorageAdapter:
+		return "local"
+	case *tiered.Storage:
+		return "tiered"
+	default:
+		return "memory"
+	}
+}
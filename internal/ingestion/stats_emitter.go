@@ -0,0 +1,293 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultStatsInterval is how often a stats file is appended to when no interval is set
+const DefaultStatsInterval = 10 * time.Second
+
+// latencySamples is the number of embedder latencies an interval keeps for its percentiles
+const latencySamples = 1024
+
+// StatsLine is a line of a stats file, a JSON object written every interval and once
+// more when the run ends, so a dashboard can tail a long-running ingest
+type StatsLine struct {
+	Time       time.Time   `json:"time"`
+	RunID      string      `json:"run_id,omitempty"`
+	Final      bool        `json:"final,omitempty"` // The summary written when the run ends
+	ElapsedMs  int64       `json:"elapsed_ms"`
+	IntervalMs int64       `json:"interval_ms"`
+	Cumulative StatsCounts `json:"cumulative"`
+	Interval   StatsCounts `json:"interval"`
+	// RecordsPerSec is the speed vectors were stored at during the interval,
+	// AvgRecordsPerSec since the start
+	RecordsPerSec    float64 `json:"records_per_sec"`
+	AvgRecordsPerSec float64 `json:"avg_records_per_sec"`
+	// FailureReasons counts the failures of the interval by reason
+	FailureReasons map[string]int `json:"failure_reasons,omitempty"`
+	// EmbedLatency summarizes the embedder calls of the interval, nil without any
+	EmbedLatency *LatencyPercentiles `json:"embed_latency_ms,omitempty"`
+}
+
+// StatsCounts are the record counts of a stats line
+type StatsCounts struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
+
+// add adds the counts of other
+func (c *StatsCounts) add(other StatsCounts) {
+	c.Total += other.Total
+	c.Succeeded += other.Succeeded
+	c.Failed += other.Failed
+	c.Skipped += other.Skipped
+}
+
+// LatencyPercentiles are the embedder latencies of an interval in milliseconds,
+// the percentiles being estimated from a sample of the calls when there are many
+type LatencyPercentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// StatsEmitter appends a StatsLine to a file every interval. Ingestors report their
+// counts to it as they change and the lines are written by a goroutine of its own, so
+// the ingest loop never waits on the file. An emitter may be shared by the ingestors
+// of a watch or feed poll to report their totals
+type StatsEmitter struct {
+	file     *os.File
+	interval time.Duration
+	runID    string
+	start    time.Time
+
+	mu         sync.Mutex
+	cumulative StatsCounts
+	current    StatsCounts    // Counts since the last line
+	reasons    map[string]int // Failures by reason since the last line
+	latencies  []time.Duration
+	observed   int // Latencies observed since the last line, sampled in latencies
+	slowest    time.Duration
+	rng        *rand.Rand
+	last       time.Time // When the last line was written
+
+	done    chan struct{}
+	stopped chan struct{}
+	err     error // First write error, returned by Close
+}
+
+// NewStatsEmitter opens path for appending and starts writing a line to it every
+// interval, DefaultStatsInterval when not positive. runID is written on every line
+func NewStatsEmitter(path string, interval time.Duration, runID string) (*StatsEmitter, error) {
+	if interval <= 0 {
+		interval = DefaultStatsInterval
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stats file: %w", err)
+	}
+
+	now := time.Now()
+	e := &StatsEmitter{
+		file:     file,
+		interval: interval,
+		runID:    runID,
+		start:    now,
+		reasons:  make(map[string]int),
+		rng:      rand.New(rand.NewSource(now.UnixNano())),
+		last:     now,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// run writes a line every interval until Close
+func (e *StatsEmitter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case now := <-ticker.C:
+			e.emit(now, false)
+		}
+	}
+}
+
+// Close stops the emitter, writing the final summary line, and closes the file
+func (e *StatsEmitter) Close() error {
+	close(e.done)
+	<-e.stopped
+
+	e.emit(time.Now(), true)
+	if err := e.file.Close(); err != nil && e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+// add reports the counts changed since the last report of an ingestor
+func (e *StatsEmitter) add(counts StatsCounts, reasons map[string]int) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.cumulative.add(counts)
+	e.current.add(counts)
+	for reason, count := range reasons {
+		e.reasons[reason] += count
+	}
+}
+
+// observeLatency records the duration of an embedder call, sampling the calls of
+// an interval beyond latencySamples so memory stays bounded
+func (e *StatsEmitter) observeLatency(d time.Duration) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.observed++
+	e.slowest = max(e.slowest, d)
+	if len(e.latencies) < latencySamples {
+		e.latencies = append(e.latencies, d)
+	} else if i := e.rng.Intn(e.observed); i < latencySamples {
+		e.latencies[i] = d
+	}
+}
+
+// emit writes the line of the interval ending at now and starts the next interval
+func (e *StatsEmitter) emit(now time.Time, final bool) {
+	e.mu.Lock()
+	line := StatsLine{
+		Time:       now,
+		RunID:      e.runID,
+		Final:      final,
+		ElapsedMs:  now.Sub(e.start).Milliseconds(),
+		IntervalMs: now.Sub(e.last).Milliseconds(),
+		Cumulative: e.cumulative,
+		Interval:   e.current,
+	}
+	if len(e.reasons) > 0 {
+		line.FailureReasons = e.reasons
+		e.reasons = make(map[string]int)
+	}
+	latencies, observed, slowest := e.latencies, e.observed, e.slowest
+	e.current = StatsCounts{}
+	e.latencies, e.observed, e.slowest = nil, 0, 0
+	e.last = now
+	e.mu.Unlock()
+
+	if seconds := float64(line.IntervalMs) / 1000; seconds > 0 {
+		line.RecordsPerSec = float64(line.Interval.Succeeded) / seconds
+	}
+	if seconds := float64(line.ElapsedMs) / 1000; seconds > 0 {
+		line.AvgRecordsPerSec = float64(line.Cumulative.Succeeded) / seconds
+	}
+	line.EmbedLatency = percentiles(latencies, observed, slowest)
+
+	data, err := json.Marshal(line)
+	if err == nil {
+		_, err = e.file.Write(append(data, '\n'))
+	}
+	if err != nil && e.err == nil {
+		e.err = fmt.Errorf("failed to write stats file: %w", err)
+	}
+}
+
+// percentiles summarizes a sample of the observed latencies, nil when none was observed
+func percentiles(sample []time.Duration, observed int, slowest time.Duration) *LatencyPercentiles {
+	if observed == 0 {
+		return nil
+	}
+	sort.Slice(sample, func(i, j int) bool { return sample[i] < sample[j] })
+	at := func(p float64) float64 {
+		return milliseconds(sample[min(int(p*float64(len(sample))), len(sample)-1)])
+	}
+	return &LatencyPercentiles{
+		Count: observed,
+		P50:   at(0.50),
+		P90:   at(0.90),
+		P99:   at(0.99),
+		Max:   milliseconds(slowest),
+	}
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// statsReport is what an ingestor last reported to its stats emitter
+type statsReport struct {
+	counts  StatsCounts
+	reasons map[string]int
+}
+
+// SetStatsEmitter makes the ingestor report to a shared emitter rather than open its
+// own from SourceConfig.StatsFile. The emitter is left open when the run ends
+func (ing *Ingestor) SetStatsEmitter(emitter *StatsEmitter) {
+	ing.emitter = emitter
+}
+
+// reportStats passes the counts changed since the last call on to the stats emitter
+func (ing *Ingestor) reportStats() {
+	if ing.emitter == nil {
+		return
+	}
+
+	counts := StatsCounts{
+		Total:     ing.stats.TotalRecords,
+		Succeeded: ing.stats.SuccessCount,
+		Failed:    ing.stats.FailureCount,
+		Skipped:   ing.stats.SkippedCount,
+	}
+	last := &ing.reported
+	if counts == last.counts {
+		return
+	}
+
+	// Reasons only change along with the failure count
+	var reasons map[string]int
+	if counts.Failed != last.counts.Failed {
+		if last.reasons == nil {
+			last.reasons = make(map[string]int)
+		}
+		reasons = make(map[string]int)
+		for reason, count := range ing.stats.FailureReasons {
+			if delta := count - last.reasons[reason]; delta > 0 {
+				reasons[reason] = delta
+				last.reasons[reason] = count
+			}
+		}
+	}
+
+	ing.emitter.add(StatsCounts{
+		Total:     counts.Total - last.counts.Total,
+		Succeeded: counts.Succeeded - last.counts.Succeeded,
+		Failed:    counts.Failed - last.counts.Failed,
+		Skipped:   counts.Skipped - last.counts.Skipped,
+	}, reasons)
+	last.counts = counts
+}
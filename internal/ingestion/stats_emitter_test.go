@@ -0,0 +1,97 @@
+package ingestion
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// slowEmbedder takes delay to embed each text
+type slowEmbedder struct {
+	embedders.Embedder
+	delay time.Duration
+}
+
+func (e slowEmbedder) Embed(text string) ([]float64, error) {
+	time.Sleep(e.delay)
+	return e.Embedder.Embed(text)
+}
+
+func TestIngestor_StatsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.jsonl")
+	config := &SourceConfig{BatchSize: 2, StatsFile: path, StatsInterval: 100 * time.Millisecond}
+	source, err := NewFileSource(reviewsFixture, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.SetTextField("text")
+	embedder := slowEmbedder{Embedder: failingEmbedder{Embedder: hash.NewHashEmbedder(), substr: "Unlabeled"}, delay: 50 * time.Millisecond}
+	stats, err := NewIngestor(source, embedder, memory.NewStorage(), config).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines []StatsLine
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line StatsLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("malformed line %s: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) < 3 {
+		t.Fatalf("got %d lines, want interval lines and a final one", len(lines))
+	}
+
+	var previous StatsCounts
+	var interval StatsCounts
+	reasons := make(map[string]int)
+	latencies := 0
+	for i, line := range lines {
+		if line.RunID != stats.RunID || line.Final != (i == len(lines)-1) {
+			t.Errorf("line %d: run %q, final %v", i, line.RunID, line.Final)
+		}
+		c := line.Cumulative
+		if c.Total < previous.Total || c.Succeeded < previous.Succeeded || c.Failed < previous.Failed || c.Skipped < previous.Skipped {
+			t.Errorf("line %d: cumulative counts %+v decreased from %+v", i, c, previous)
+		}
+		previous = c
+		interval.add(line.Interval)
+		for reason, count := range line.FailureReasons {
+			reasons[reason] += count
+		}
+		if line.EmbedLatency != nil {
+			latencies += line.EmbedLatency.Count
+			if line.EmbedLatency.P50 < 40 || line.EmbedLatency.Max < line.EmbedLatency.P99 {
+				t.Errorf("line %d: latencies %+v", i, line.EmbedLatency)
+			}
+		}
+	}
+
+	// The intervals add up to the final counts, which are those of the run
+	want := StatsCounts{Total: stats.TotalRecords, Succeeded: stats.SuccessCount, Failed: stats.FailureCount, Skipped: stats.SkippedCount}
+	if previous != want || interval != want {
+		t.Errorf("final counts %+v, intervals sum to %+v, want %+v", previous, interval, want)
+	}
+	if reasons["embed_error"] != stats.FailureReasons["embed_error"] || reasons["embed_error"] == 0 {
+		t.Errorf("failure reasons %v, want %v", reasons, stats.FailureReasons)
+	}
+	if latencies != stats.TotalRecords-stats.SkippedCount {
+		t.Errorf("%d embedder latencies observed for %d records", latencies, stats.TotalRecords)
+	}
+}
@@ -0,0 +1,77 @@
+package ingestion
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		stats      Stats
+		thresholds StatsThresholds
+		wantErr    string
+	}{
+		{"disabled", Stats{TotalRecords: 10, FailureCount: 10}, StatsThresholds{MaxFailureRate: -1}, ""},
+		{"under rate", Stats{TotalRecords: 100, SuccessCount: 96, FailureCount: 4}, StatsThresholds{MaxFailureRate: 0.05}, ""},
+		{"at rate", Stats{TotalRecords: 100, SuccessCount: 95, FailureCount: 5}, StatsThresholds{MaxFailureRate: 0.05}, ""},
+		{"over rate", Stats{TotalRecords: 100, SuccessCount: 94, FailureCount: 6}, StatsThresholds{MaxFailureRate: 0.05}, "exceeds"},
+		{"zero rate allows none", Stats{TotalRecords: 100, SuccessCount: 99, FailureCount: 1}, StatsThresholds{MaxFailureRate: 0}, "exceeds"},
+		{"empty source", Stats{}, StatsThresholds{MaxFailureRate: 0}, ""},
+		{"zero ingested", Stats{TotalRecords: 3, SkippedCount: 3}, StatsThresholds{MaxFailureRate: -1, FailOnZero: true}, "no records"},
+		{"some ingested", Stats{TotalRecords: 3, SuccessCount: 1, SkippedCount: 2}, StatsThresholds{MaxFailureRate: -1, FailOnZero: true}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.thresholds.Check(&tt.stats)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestStatsWriteJSON(t *testing.T) {
+	stats := &Stats{
+		TotalRecords:   4,
+		SuccessCount:   2,
+		FailureCount:   1,
+		SkippedCount:   1,
+		Duration:       1500 * time.Millisecond,
+		FailureReasons: map[string]int{"embed_error": 1},
+		Namespace:      "docs",
+		StorageType:    "local",
+		Embedder:       "hash",
+		RunID:          "run-1",
+	}
+
+	var buf bytes.Buffer
+	if err := stats.Write(&buf, StatsJSON); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	var report StatsReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if report.RunID != "run-1" || report.Total != 4 || report.Succeeded != 2 || report.Failed != 1 || report.Skipped != 1 {
+		t.Errorf("unexpected counts: %+v", report)
+	}
+	if report.FailureRate != 0.25 || report.DurationMs != 1500 || report.FailureReasons["embed_error"] != 1 {
+		t.Errorf("unexpected rate, duration or reasons: %+v", report)
+	}
+	if report.Namespace != "docs" || report.Storage != "local" || report.Embedder != "hash" {
+		t.Errorf("unexpected destination: %+v", report)
+	}
+
+	if _, err := ParseStatsFormat("yaml"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
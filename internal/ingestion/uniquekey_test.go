@@ -0,0 +1,51 @@
+package ingestion
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func TestIngestor_UniqueKeyUpdatesOnReingest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tickets.jsonl")
+	store := memory.NewStorage()
+
+	ingest := func(content string) *Stats {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config := &SourceConfig{BatchSize: 10, UniqueKeys: []string{"ticket_id"}}
+		source, err := NewFileSource(path, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := NewIngestor(source, hash.NewHashEmbedder(), store, config).Run(context.Background())
+		if err != nil {
+			t.Fatalf("ingest failed: %v", err)
+		}
+		return stats
+	}
+
+	ingest(`{"text": "printer jammed", "ticket_id": "T-1"}` + "\n" + `{"text": "login fails", "ticket_id": "T-2"}` + "\n")
+	stats := ingest(`{"text": "printer jammed again", "ticket_id": "T-1"}` + "\n" + `{"text": "new laptop", "ticket_id": "T-3"}` + "\n")
+
+	if stats.SuccessCount != 2 || stats.FailureCount != 0 {
+		t.Fatalf("second run stats = %+v", stats)
+	}
+	if store.Count() != 3 {
+		t.Fatalf("count = %d after re-ingest, want 3", store.Count())
+	}
+	vector, err := store.GetByKey("", "ticket_id", "T-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vector.Metadata[models.LineageRunKey] != stats.RunID {
+		t.Errorf("T-1 was not updated by the second run: %v", vector.Metadata)
+	}
+}
@@ -0,0 +1,444 @@
+package ingestion
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+const (
+	// DefaultWatchDebounce is how long a file must stay unchanged before it is ingested
+	DefaultWatchDebounce = 2 * time.Second
+
+	// DefaultWatchSummaryInterval is how often a running watch prints its totals
+	DefaultWatchSummaryInterval = time.Minute
+
+	// WatchStateFileName is the default state file, kept in the watched directory
+	WatchStateFileName = ".same-same-watch.json"
+)
+
+// WatchConfig configures a directory watch
+type WatchConfig struct {
+	Dir             string
+	Pattern         string        // Glob matched against file names, all supported files when empty
+	Debounce        time.Duration // Quiet period before a new or modified file is ingested
+	StateFile       string        // Records ingested files, WatchStateFileName in Dir when empty
+	ArchiveDir      string        // Completed files are moved here, keeping their path below Dir
+	DeleteAfter     bool          // Completed files are deleted
+	SummaryInterval time.Duration // How often totals are printed, 0 disables summaries
+	TextColumn      string        // Text column of CSV files
+	Delimiter       rune          // Field delimiter of CSV files, 0 detects it
+	TextField       string        // Dot-path of the text in JSON records, empty tries the common field names
+}
+
+// WatchedFile is the state file entry of an ingested file
+type WatchedFile struct {
+	Checksum   string    `json:"checksum"`
+	Size       int64     `json:"size"`
+	Records    int       `json:"records"`
+	Failed     int       `json:"failed"`
+	IngestedAt time.Time `json:"ingested_at"`
+}
+
+// WatchState records the files a watch has ingested, keyed by their path relative to the watched directory
+type WatchState struct {
+	Files map[string]*WatchedFile `json:"files"`
+}
+
+// pendingFile is a file waiting for its size and modification time to settle
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	stable  time.Time // when the size and modification time were last seen to change
+}
+
+// Watcher ingests files as they appear in a directory tree
+type Watcher struct {
+	config       WatchConfig
+	sourceConfig *SourceConfig
+	embedder     embedders.Embedder
+	storage      storage.Storage
+
+	state   *WatchState
+	pending map[string]*pendingFile
+	totals  *Stats
+	files   int
+	emitter *StatsEmitter // Appends the stats file of the source config while running
+
+	mu sync.Mutex // guards totals and files for summaries
+}
+
+// NewWatcher creates a watcher of config.Dir, loading the state of a previous run
+func NewWatcher(config WatchConfig, sourceConfig *SourceConfig, embedder embedders.Embedder, storage storage.Storage) (*Watcher, error) {
+	info, err := os.Stat(config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watch directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", config.Dir)
+	}
+	if config.ArchiveDir != "" && config.DeleteAfter {
+		return nil, fmt.Errorf("archive directory and delete after ingest are mutually exclusive")
+	}
+	if _, err := filepath.Match(config.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", config.Pattern, err)
+	}
+	if config.Debounce <= 0 {
+		config.Debounce = DefaultWatchDebounce
+	}
+	if config.StateFile == "" {
+		config.StateFile = filepath.Join(config.Dir, WatchStateFileName)
+	}
+
+	state, err := loadWatchState(config.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{
+		config:       config,
+		sourceConfig: sourceConfig,
+		embedder:     embedder,
+		storage:      storage,
+		state:        state,
+		pending:      make(map[string]*pendingFile),
+		totals: &Stats{
+			FailureReasons: make(map[string]int),
+			Namespace:      sourceConfig.Namespace,
+			StorageType:    storageType(storage),
+			Embedder:       embedder.Name(),
+			StartTime:      time.Now(),
+		},
+	}, nil
+}
+
+// loadWatchState reads a state file, returning an empty state if it does not exist yet
+func loadWatchState(path string) (*WatchState, error) {
+	state := &WatchState{Files: make(map[string]*WatchedFile)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse watch state %s: %w", path, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]*WatchedFile)
+	}
+	return state, nil
+}
+
+// saveState writes the state file atomically so an interrupted write keeps the previous state
+func (w *Watcher) saveState() error {
+	data, err := json.MarshalIndent(w.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := w.config.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	return os.Rename(tmp, w.config.StateFile)
+}
+
+// Run watches the directory until ctx is cancelled
+// Files already in the directory are picked up too, unless the state file shows they were ingested
+func (w *Watcher) Run(ctx context.Context) (err error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start watcher: %w", err)
+	}
+	defer fsw.Close()
+
+	// The files ingested report to one emitter, so the stats file covers the whole watch
+	if w.sourceConfig.StatsFile != "" {
+		if w.emitter, err = NewStatsEmitter(w.sourceConfig.StatsFile, w.sourceConfig.StatsInterval, ""); err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := w.emitter.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+	}
+
+	// fsnotify does not recurse, so every directory is watched and existing files are queued
+	if err := w.addTree(fsw, w.config.Dir); err != nil {
+		return err
+	}
+
+	poll := time.NewTicker(w.config.Debounce / 4)
+	defer poll.Stop()
+
+	var summaries <-chan time.Time
+	if w.config.SummaryInterval > 0 {
+		summary := time.NewTicker(w.config.SummaryInterval)
+		defer summary.Stop()
+		summaries = summary.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := w.addTree(fsw, event.Name); err != nil {
+						fmt.Printf("Failed to watch %s: %v\n", event.Name, err)
+					}
+					continue
+				}
+				w.queue(event.Name)
+			}
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Printf("Watch error: %v\n", err)
+
+		case now := <-poll.C:
+			w.processStable(ctx, now)
+
+		case <-summaries:
+			w.PrintSummary()
+		}
+	}
+}
+
+// addTree watches dir and its subdirectories, queueing the files found in them
+func (w *Watcher) addTree(fsw *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if w.isArchive(path) {
+				return filepath.SkipDir
+			}
+			return fsw.Add(path)
+		}
+		w.queue(path)
+		return nil
+	})
+}
+
+// isArchive reports whether path is the archive directory, which must not be watched
+func (w *Watcher) isArchive(path string) bool {
+	if w.config.ArchiveDir == "" {
+		return false
+	}
+	archive, err1 := filepath.Abs(w.config.ArchiveDir)
+	abs, err2 := filepath.Abs(path)
+	return err1 == nil && err2 == nil && archive == abs
+}
+
+// matches reports whether a file should be ingested
+func (w *Watcher) matches(path string) bool {
+	if filepath.Clean(path) == filepath.Clean(w.config.StateFile) || filepath.Clean(path) == filepath.Clean(w.config.StateFile+".tmp") {
+		return false
+	}
+	name := filepath.Base(path)
+	if w.config.Pattern != "" {
+		ok, _ := filepath.Match(w.config.Pattern, name)
+		return ok
+	}
+	_, err := FileType(name)
+	return err == nil
+}
+
+// queue starts or restarts the quiet period of a file
+func (w *Watcher) queue(path string) {
+	if !w.matches(path) {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	w.pending[path] = &pendingFile{size: info.Size(), modTime: info.ModTime(), stable: time.Now()}
+}
+
+// processStable ingests the pending files whose size and modification time
+// have not changed for the debounce period, so files still being written are left alone
+func (w *Watcher) processStable(ctx context.Context, now time.Time) {
+	paths := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		pending := w.pending[path]
+
+		info, err := os.Stat(path)
+		if err != nil {
+			// Removed or renamed before it settled
+			delete(w.pending, path)
+			continue
+		}
+		if info.Size() != pending.size || !info.ModTime().Equal(pending.modTime) {
+			pending.size, pending.modTime, pending.stable = info.Size(), info.ModTime(), now
+			continue
+		}
+		if now.Sub(pending.stable) < w.config.Debounce {
+			continue
+		}
+
+		delete(w.pending, path)
+		if err := w.ingestFile(ctx, path); err != nil {
+			fmt.Printf("Failed to ingest %s: %v\n", path, err)
+		}
+	}
+}
+
+// ingestFile runs a file through the FileSource pipeline unless the state shows
+// the same content was already ingested, then records, archives or deletes it
+func (w *Watcher) ingestFile(ctx context.Context, path string) error {
+	rel, err := filepath.Rel(w.config.Dir, path)
+	if err != nil {
+		return err
+	}
+
+	checksum, size, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+	if done, ok := w.state.Files[rel]; ok && done.Checksum == checksum {
+		return w.complete(path, rel)
+	}
+
+	source, err := NewFileSource(path, w.sourceConfig)
+	if err != nil {
+		return err
+	}
+	if w.config.TextColumn != "" {
+		source.SetTextColumn(w.config.TextColumn)
+	}
+	source.SetDelimiter(w.config.Delimiter)
+	source.SetTextField(w.config.TextField)
+
+	ingestor := NewIngestor(source, w.embedder, w.storage, w.sourceConfig)
+	ingestor.SetStatsEmitter(w.emitter)
+	stats, err := ingestor.Run(ctx)
+	if stats != nil {
+		w.merge(stats)
+	}
+	if err != nil {
+		return err
+	}
+
+	// A file rewritten during ingest is queued again rather than recorded with the wrong content
+	if after, _, err := fileChecksum(path); err != nil || after != checksum {
+		w.queue(path)
+		return fmt.Errorf("file changed during ingest, it will be ingested again")
+	}
+
+	fmt.Printf("Ingested %s: %d stored, %d failed\n", rel, stats.SuccessCount, stats.FailureCount)
+
+	if !w.sourceConfig.DryRun {
+		w.state.Files[rel] = &WatchedFile{
+			Checksum:   checksum,
+			Size:       size,
+			Records:    stats.SuccessCount,
+			Failed:     stats.FailureCount,
+			IngestedAt: time.Now(),
+		}
+		if err := w.saveState(); err != nil {
+			return err
+		}
+	}
+
+	return w.complete(path, rel)
+}
+
+// complete archives or deletes an ingested file when configured to
+func (w *Watcher) complete(path, rel string) error {
+	if w.sourceConfig.DryRun {
+		return nil
+	}
+
+	switch {
+	case w.config.DeleteAfter:
+		return os.Remove(path)
+
+	case w.config.ArchiveDir != "":
+		target := filepath.Join(w.config.ArchiveDir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create archive directory: %w", err)
+		}
+		return os.Rename(path, target)
+	}
+
+	return nil
+}
+
+// fileChecksum returns the SHA-256 and size of a file
+func fileChecksum(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// merge adds the stats of an ingested file to the watch totals
+func (w *Watcher) merge(stats *Stats) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.files++
+	w.totals.add(stats)
+}
+
+// Totals returns the number of files ingested and the combined stats of the watch
+func (w *Watcher) Totals() (int, Stats) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	totals := *w.totals
+	totals.FailureReasons = make(map[string]int, len(w.totals.FailureReasons))
+	for reason, count := range w.totals.FailureReasons {
+		totals.FailureReasons[reason] = count
+	}
+	totals.EndTime = time.Now()
+	totals.Duration = totals.EndTime.Sub(totals.StartTime)
+	return w.files, totals
+}
+
+// PrintSummary prints the totals of the watch so far
+func (w *Watcher) PrintSummary() {
+	files, totals := w.Totals()
+	fmt.Printf("[%s] watching %s: %d files, %d records stored, %d failed, %d pending\n",
+		time.Now().Format(time.TimeOnly), w.config.Dir, files, totals.SuccessCount, totals.FailureCount, len(w.pending))
+}
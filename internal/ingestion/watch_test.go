@@ -0,0 +1,127 @@
+package ingestion
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// runWatcher runs a watcher of dir in the background until the test ends
+func runWatcher(t *testing.T, dir, archive string, store *memory.Storage) *Watcher {
+	t.Helper()
+
+	watcher, err := NewWatcher(WatchConfig{
+		Dir:        dir,
+		Pattern:    "*.jsonl",
+		Debounce:   200 * time.Millisecond,
+		ArchiveDir: archive,
+	}, &SourceConfig{BatchSize: 10}, hash.NewHashEmbedder(), store)
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("watch failed: %v", err)
+		}
+	})
+
+	return watcher
+}
+
+// waitFor polls cond until it holds or the timeout expires
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWatcher_IngestsStableFilesOnce(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(t.TempDir(), "done")
+	store := memory.NewStorage()
+	runWatcher(t, dir, archive, store)
+
+	// The file is written in two parts, the first ending mid-record
+	path := filepath.Join(dir, "nested", "batch.jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"text": "first record"}` + "\n" + `{"text": "second record"}` + "\n"
+	if err := os.WriteFile(path, []byte(content[:30]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ignored by the pattern
+	if err := os.WriteFile(filepath.Join(dir, "notes.csv"), []byte("text\nignored\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archived := filepath.Join(archive, "nested", "batch.jsonl")
+	waitFor(t, "the file to be archived", func() bool {
+		_, err := os.Stat(archived)
+		return err == nil
+	})
+	if got := store.Count(); got != 2 {
+		t.Fatalf("stored %d vectors, want the 2 complete records", got)
+	}
+
+	state, err := loadWatchState(filepath.Join(dir, WatchStateFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := state.Files[filepath.Join("nested", "batch.jsonl")]
+	if !ok || entry.Records != 2 {
+		t.Fatalf("state = %+v, want the file recorded with 2 records", state.Files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.csv")); err != nil {
+		t.Errorf("unmatched file was moved: %v", err)
+	}
+}
+
+func TestWatcher_StateSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	store := memory.NewStorage()
+
+	path := filepath.Join(dir, "batch.jsonl")
+	if err := os.WriteFile(path, []byte(`{"text": "only record"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files already present are ingested when the watch starts
+	watcher, err := NewWatcher(WatchConfig{Dir: dir, Debounce: time.Millisecond}, &SourceConfig{BatchSize: 10}, hash.NewHashEmbedder(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.ingestFile(context.Background(), path); err != nil {
+		t.Fatalf("ingest failed: %v", err)
+	}
+
+	// A new watcher over the same state skips the unchanged file
+	restarted := runWatcher(t, dir, "", store)
+	time.Sleep(500 * time.Millisecond)
+	if got := store.Count(); got != 1 {
+		t.Fatalf("stored %d vectors after restart, want 1", got)
+	}
+	if files, _ := restarted.Totals(); files != 0 {
+		t.Errorf("restarted watch ingested %d files, want 0", files)
+	}
+}
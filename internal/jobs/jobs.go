@@ -0,0 +1,166 @@
+// Package jobs runs background operations, such as bulk maintenance or re-embedding,
+// as persisted jobs: their progress is checkpointed, so a restart resumes them where
+// they stopped instead of losing them
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Job states
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+	StateCanceled  = "canceled"
+)
+
+const (
+	// MaxErrors is the number of failure messages kept per job
+	MaxErrors = 10
+
+	// MaxFinished is the number of finished jobs kept, older ones are dropped
+	MaxFinished = 50
+)
+
+// ErrNotFound is matched with errors.Is by the errors of unknown jobs
+var ErrNotFound = fmt.Errorf("job %w", storeerr.ErrNotFound)
+
+// NotFound returns the error of an unknown job
+func NotFound(id string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// StateError is the error of an operation the state of a job does not allow,
+// such as canceling a finished job
+type StateError struct {
+	ID    string
+	State string
+	Op    string
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("cannot %s job %s: it is %s", e.Op, e.ID, e.State)
+}
+
+// Store is implemented by the storage backends persisting jobs
+type Store interface {
+	Jobs() ([]*Job, error)
+	SaveJob(job *Job) error
+}
+
+// Progress counts the work done by a job
+type Progress struct {
+	Total     int `json:"total"`     // Items to process, counted when the job starts
+	Processed int `json:"processed"` // Items handled so far
+	Affected  int `json:"affected"`  // Items changed
+	Failed    int `json:"failed"`
+
+	Errors []string `json:"errors,omitempty"` // First failures, at most MaxErrors
+}
+
+// RecordError counts a failure, keeping its message while fewer than MaxErrors are kept
+func (p *Progress) RecordError(err error) {
+	p.Failed++
+	if len(p.Errors) < MaxErrors {
+		p.Errors = append(p.Errors, err.Error())
+	}
+}
+
+// Job is a background operation of a registered type and its progress
+type Job struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Params json.RawMessage `json:"params,omitempty"` // Parameters, decoded by the handler of Type
+	State  string          `json:"state"`
+
+	Progress Progress `json:"progress"`
+
+	// Checkpoint is where the job resumes, set by its handler as it progresses
+	Checkpoint string `json:"checkpoint,omitempty"`
+
+	Error string `json:"error,omitempty"` // Why a failed job stopped
+
+	// Attempts counts the runs of the job, including those resuming it after a
+	// restart or a retry
+	Attempts int `json:"attempts"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job is done, successfully or not
+func (j *Job) Finished() bool {
+	return j.State == StateCompleted || j.State == StateFailed || j.State == StateCanceled
+}
+
+// Finish ends the job in state, a failed job recording err
+func (j *Job) Finish(state string, err error, now time.Time) {
+	j.State = state
+	if err != nil {
+		j.Error = err.Error()
+	}
+	j.UpdatedAt = now
+	j.FinishedAt = &now
+}
+
+// Copy returns a copy of the job sharing nothing with it
+func (j *Job) Copy() *Job {
+	copied := *j
+	copied.Params = append(json.RawMessage(nil), j.Params...)
+	copied.Progress.Errors = append([]string(nil), j.Progress.Errors...)
+	return &copied
+}
+
+// Sorted returns jobs newest first
+func Sorted(jobs []*Job) []*Job {
+	sorted := append([]*Job(nil), jobs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.After(sorted[j].CreatedAt) })
+	return sorted
+}
+
+// Put replaces the job of the same ID in jobs or appends it, dropping the oldest
+// finished jobs beyond MaxFinished
+func Put(jobs []*Job, job *Job) []*Job {
+	replaced := false
+	for i, j := range jobs {
+		if j.ID == job.ID {
+			jobs[i] = job
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		jobs = append(jobs, job)
+	}
+
+	finished := 0
+	for _, j := range jobs {
+		if j.Finished() {
+			finished++
+		}
+	}
+	if finished <= MaxFinished {
+		return jobs
+	}
+
+	// Jobs are appended in order, so the first finished ones are the oldest
+	drop := finished - MaxFinished
+	kept := jobs[:0]
+	for _, j := range jobs {
+		if j.Finished() && drop > 0 {
+			drop--
+			continue
+		}
+		kept = append(kept, j)
+	}
+	return kept
+}
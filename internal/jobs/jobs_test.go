@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPut(t *testing.T) {
+	var jobs []*Job
+	now := time.Now()
+	for i := 0; i < MaxFinished+2; i++ {
+		job := &Job{ID: fmt.Sprintf("job-%d", i), State: StateQueued, CreatedAt: now.Add(time.Duration(i) * time.Second)}
+		jobs = Put(jobs, job)
+		if i > 0 {
+			done := *job
+			done.Finish(StateCompleted, nil, now)
+			jobs = Put(jobs, &done)
+		}
+	}
+
+	// job-0 never finished, job-1 is the oldest finished job beyond the limit
+	if len(jobs) != MaxFinished+1 || jobs[0].ID != "job-0" || jobs[1].ID != "job-2" {
+		t.Errorf("kept %d jobs starting %s, %s", len(jobs), jobs[0].ID, jobs[1].ID)
+	}
+	if sorted := Sorted(jobs); sorted[0].ID != fmt.Sprintf("job-%d", MaxFinished+1) {
+		t.Errorf("Sorted() starts with %s, want the newest job", sorted[0].ID)
+	}
+}
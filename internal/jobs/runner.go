@@ -0,0 +1,395 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// DefaultWorkers is the number of jobs run at the same time unless SetWorkers changes it,
+// one so that background work competes as little as possible with searches
+const DefaultWorkers = 1
+
+// Handler runs the jobs of one type
+type Handler interface {
+	// Validate checks the parameters of a new job, returning them with their defaults filled
+	Validate(params json.RawMessage) (json.RawMessage, error)
+
+	// Run works on the job from its checkpoint until it is done, fails or ctx is
+	// canceled, reporting its progress with run.Update
+	Run(ctx context.Context, run *Run) error
+}
+
+// Runner queues jobs and runs them on a pool of workers, persisting every job
+// in its store, when it has one, as it progresses
+type Runner struct {
+	store Store // nil keeps the jobs in memory only
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	workers  int
+	jobs     []*Job                        // Every known job, oldest first
+	queue    []*Job                        // Jobs waiting for a worker
+	running  map[string]context.CancelFunc // Cancels the running jobs by ID
+	active   int                           // Workers started
+	stopped  bool
+
+	saveMu sync.Mutex // Orders saves, so the last state of a job is the one persisted
+	wg     sync.WaitGroup
+}
+
+// NewRunner returns a runner persisting jobs in store, nil to keep them in memory only
+func NewRunner(store Store) *Runner {
+	return &Runner{
+		store:    store,
+		handlers: make(map[string]Handler),
+		workers:  DefaultWorkers,
+		running:  make(map[string]context.CancelFunc),
+	}
+}
+
+// Register sets the handler of the jobs of jobType
+func (r *Runner) Register(jobType string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[jobType] = handler
+}
+
+// SetWorkers sets the number of jobs run at the same time, DefaultWorkers when not positive
+func (r *Runner) SetWorkers(workers int) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workers = workers
+	r.dispatch()
+}
+
+// Submit validates and persists a new job of jobType, then queues it
+func (r *Runner) Submit(jobType string, params json.RawMessage) (*Job, error) {
+	r.mu.Lock()
+	handler, ok := r.handlers[jobType]
+	r.mu.Unlock()
+	if !ok {
+		return nil, storeerr.Validationf("unknown job type %q", jobType)
+	}
+
+	params, err := handler.Validate(params)
+	if err != nil {
+		return nil, storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	now := time.Now()
+	job := &Job{ID: uuid.New(), Type: jobType, Params: params, State: StateQueued, CreatedAt: now, UpdatedAt: now}
+	if r.store != nil {
+		if err := r.store.SaveJob(job.Copy()); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = Put(r.jobs, job)
+	r.queue = append(r.queue, job)
+	r.dispatch()
+	return job.Copy(), nil
+}
+
+// Get returns a copy of the job of id
+func (r *Runner) Get(id string) (*Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job := r.find(id)
+	if job == nil {
+		return nil, NotFound(id)
+	}
+	return job.Copy(), nil
+}
+
+// List returns copies of the jobs of jobType, or of every type when empty, newest first
+func (r *Runner) List(jobType string) []*Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var listed []*Job
+	for _, job := range Sorted(r.jobs) {
+		if jobType == "" || job.Type == jobType {
+			listed = append(listed, job.Copy())
+		}
+	}
+	return listed
+}
+
+// Cancel cancels a queued or running job
+// The handler of a running job stops at its next check of its context, so the
+// work it was doing may complete
+func (r *Runner) Cancel(id string) (*Job, error) {
+	r.mu.Lock()
+	job := r.find(id)
+	if job == nil {
+		r.mu.Unlock()
+		return nil, NotFound(id)
+	}
+	if job.Finished() {
+		r.mu.Unlock()
+		return nil, &StateError{ID: id, State: job.State, Op: "cancel"}
+	}
+	if cancel, ok := r.running[id]; ok {
+		cancel()
+	} else {
+		r.unqueue(job)
+	}
+	job.Finish(StateCanceled, nil, time.Now())
+	r.mu.Unlock()
+
+	r.persist(job)
+	return r.Get(id)
+}
+
+// Retry queues a failed or canceled job again, resuming it from its checkpoint
+func (r *Runner) Retry(id string) (*Job, error) {
+	r.mu.Lock()
+	job := r.find(id)
+	if job == nil {
+		r.mu.Unlock()
+		return nil, NotFound(id)
+	}
+	if job.State != StateFailed && job.State != StateCanceled {
+		r.mu.Unlock()
+		return nil, &StateError{ID: id, State: job.State, Op: "retry"}
+	}
+	if _, ok := r.running[id]; ok {
+		// Canceled, but its handler has not returned yet
+		r.mu.Unlock()
+		return nil, &StateError{ID: id, State: "still stopping", Op: "retry"}
+	}
+	if _, ok := r.handlers[job.Type]; !ok {
+		r.mu.Unlock()
+		return nil, storeerr.Validationf("no handler for job type %q", job.Type)
+	}
+	job.State = StateQueued
+	job.Error = ""
+	job.FinishedAt = nil
+	job.UpdatedAt = time.Now()
+	r.mu.Unlock()
+
+	r.persist(job)
+
+	r.mu.Lock()
+	r.queue = append(r.queue, job)
+	r.dispatch()
+	r.mu.Unlock()
+	return r.Get(id)
+}
+
+// Recover loads the persisted jobs and queues the unfinished ones, oldest first,
+// to resume from their checkpoint. Jobs interrupted while running count one more
+// attempt when they start again; unfinished jobs of a type without handler are
+// marked failed. It returns how many jobs were queued
+func (r *Runner) Recover() (int, error) {
+	if r.store == nil {
+		return 0, nil
+	}
+	stored, err := r.store.Jobs()
+	if err != nil {
+		return 0, err
+	}
+
+	var resumed, orphaned []*Job
+	r.mu.Lock()
+	for _, job := range stored {
+		if r.find(job.ID) != nil {
+			continue
+		}
+		if !job.Finished() {
+			if _, ok := r.handlers[job.Type]; ok {
+				job.State = StateQueued
+				resumed = append(resumed, job)
+			} else {
+				job.Finish(StateFailed, fmt.Errorf("interrupted by a restart: no handler for job type %q", job.Type), time.Now())
+				orphaned = append(orphaned, job)
+			}
+		}
+		r.jobs = append(r.jobs, job)
+	}
+	r.mu.Unlock()
+
+	for _, job := range orphaned {
+		r.persist(job)
+	}
+
+	sort.SliceStable(resumed, func(i, j int) bool { return resumed[i].CreatedAt.Before(resumed[j].CreatedAt) })
+	r.mu.Lock()
+	r.queue = append(r.queue, resumed...)
+	r.dispatch()
+	r.mu.Unlock()
+	return len(resumed), nil
+}
+
+// Stop cancels the running jobs and waits for their handlers to return, leaving
+// the jobs as a crash would: they resume when a runner on the same store recovers
+// Jobs submitted after Stop are persisted but not run
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	r.stopped = true
+	for _, cancel := range r.running {
+		cancel()
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+// find returns the job of id, nil if unknown. r.mu must be held
+func (r *Runner) find(id string) *Job {
+	for _, job := range r.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// unqueue removes job from the queue. r.mu must be held
+func (r *Runner) unqueue(job *Job) {
+	for i, queued := range r.queue {
+		if queued == job {
+			r.queue = append(r.queue[:i], r.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatch starts workers for the queued jobs, up to the number of workers. r.mu must be held
+func (r *Runner) dispatch() {
+	for !r.stopped && r.active < r.workers && len(r.queue) > 0 {
+		job := r.queue[0]
+		r.queue = r.queue[1:]
+		r.active++
+		r.wg.Add(1)
+		go r.work(job)
+	}
+}
+
+// work runs job, then the queued jobs, until the queue is empty
+func (r *Runner) work(job *Job) {
+	defer r.wg.Done()
+	for job != nil {
+		r.run(job)
+
+		r.mu.Lock()
+		job = nil
+		if !r.stopped && r.active <= r.workers && len(r.queue) > 0 {
+			job = r.queue[0]
+			r.queue = r.queue[1:]
+		} else {
+			r.active--
+		}
+		r.mu.Unlock()
+	}
+}
+
+// run runs job with its handler and records how it ended
+func (r *Runner) run(job *Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r.mu.Lock()
+	if job.Finished() || r.stopped {
+		r.mu.Unlock()
+		return
+	}
+	handler := r.handlers[job.Type]
+	now := time.Now()
+	job.State = StateRunning
+	job.Attempts++
+	job.UpdatedAt = now
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	r.running[job.ID] = cancel
+	r.mu.Unlock()
+	r.persist(job)
+
+	err := handler.Run(ctx, &Run{runner: r, job: job})
+
+	r.mu.Lock()
+	delete(r.running, job.ID)
+	if job.Finished() || (r.stopped && ctx.Err() != nil) {
+		// Canceled, or stopped with the runner and left to resume
+		r.mu.Unlock()
+		return
+	}
+	if err != nil {
+		job.Finish(StateFailed, err, time.Now())
+	} else {
+		job.Finish(StateCompleted, nil, time.Now())
+	}
+	r.mu.Unlock()
+	r.persist(job)
+}
+
+// persist saves the current state of job, logging failures since the job goes
+// on and its next save may succeed
+func (r *Runner) persist(job *Job) {
+	if r.store == nil {
+		return
+	}
+
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+
+	r.mu.Lock()
+	copied := job.Copy()
+	r.mu.Unlock()
+	if err := r.store.SaveJob(copied); err != nil {
+		logrus.WithError(err).WithField("job_id", job.ID).Warn("failed to save job")
+	}
+}
+
+// Run is a running job, as seen by its handler
+type Run struct {
+	runner *Runner
+	job    *Job
+}
+
+// ID returns the ID of the job
+func (run *Run) ID() string {
+	return run.job.ID
+}
+
+// Params decodes the parameters of the job into v
+func (run *Run) Params(v interface{}) error {
+	return json.Unmarshal(run.job.Params, v)
+}
+
+// Checkpoint returns where the job resumes, empty when it starts from the beginning
+func (run *Run) Checkpoint() string {
+	run.runner.mu.Lock()
+	defer run.runner.mu.Unlock()
+	return run.job.Checkpoint
+}
+
+// Update changes the progress of the job and persists it along with checkpoint,
+// where the job resumes if it is interrupted from now on
+func (run *Run) Update(checkpoint string, update func(p *Progress)) {
+	r := run.runner
+	r.mu.Lock()
+	if update != nil {
+		update(&run.job.Progress)
+	}
+	run.job.Checkpoint = checkpoint
+	run.job.UpdatedAt = time.Now()
+	r.mu.Unlock()
+	r.persist(run.job)
+}
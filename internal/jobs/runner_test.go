@@ -0,0 +1,223 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore persists jobs in memory, outliving the runners using it like a backend
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs []*Job
+}
+
+func (s *memoryStore) Jobs() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := make([]*Job, len(s.jobs))
+	for i, job := range s.jobs {
+		copied[i] = job.Copy()
+	}
+	return copied, nil
+}
+
+func (s *memoryStore) SaveJob(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = Put(s.jobs, job.Copy())
+	return nil
+}
+
+// countingHandler processes items 0 to items-1, checkpointing after each one
+// Reaching gateAt, it waits for gate to be closed or for its context to be canceled
+type countingHandler struct {
+	items  int
+	gateAt int
+	gate   chan struct{}
+
+	mu        sync.Mutex
+	processed []int // Every item processed, by every run
+	starts    []int // First item of every run
+}
+
+func (h *countingHandler) Validate(params json.RawMessage) (json.RawMessage, error) {
+	return params, nil
+}
+
+func (h *countingHandler) Run(ctx context.Context, run *Run) error {
+	start := 0
+	if checkpoint := run.Checkpoint(); checkpoint != "" {
+		last, err := strconv.Atoi(checkpoint)
+		if err != nil {
+			return err
+		}
+		start = last + 1
+	}
+	h.mu.Lock()
+	h.starts = append(h.starts, start)
+	h.mu.Unlock()
+	run.Update(run.Checkpoint(), func(p *Progress) { p.Total = h.items })
+
+	for i := start; i < h.items; i++ {
+		if i == h.gateAt {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-h.gate:
+			}
+		}
+		h.mu.Lock()
+		h.processed = append(h.processed, i)
+		h.mu.Unlock()
+		run.Update(strconv.Itoa(i), func(p *Progress) { p.Processed++ })
+	}
+	return nil
+}
+
+// waitJob polls the job of id until check accepts it
+func waitJob(t *testing.T, r *Runner, id string, check func(*Job) bool) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := r.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if check(job) {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	job, _ := r.Get(id)
+	t.Fatalf("job %s did not reach the expected state: %+v", id, job)
+	return nil
+}
+
+func TestRunner_ResumesFromCheckpointAfterRestart(t *testing.T) {
+	store := &memoryStore{}
+
+	// The first runner is killed while its job waits on item 4
+	killed := &countingHandler{items: 10, gateAt: 4, gate: make(chan struct{})}
+	runner := NewRunner(store)
+	runner.Register("count", killed)
+	job, err := runner.Submit("count", json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, runner, job.ID, func(j *Job) bool { return j.Progress.Processed == 4 })
+	runner.Stop()
+
+	persisted, _ := store.Jobs()
+	if len(persisted) != 1 || persisted[0].State != StateRunning || persisted[0].Checkpoint != "3" {
+		t.Fatalf("persisted after the kill = %+v, want running at checkpoint 3", persisted[0])
+	}
+
+	// A new runner on the same store resumes the job after its checkpoint
+	restarted := &countingHandler{items: 10, gateAt: -1}
+	runner = NewRunner(store)
+	runner.Register("count", restarted)
+	if resumed, err := runner.Recover(); err != nil || resumed != 1 {
+		t.Fatalf("Recover() = %d, %v", resumed, err)
+	}
+	done := waitJob(t, runner, job.ID, func(j *Job) bool { return j.Finished() })
+
+	if done.State != StateCompleted || done.Progress.Processed != 10 || done.Attempts != 2 {
+		t.Errorf("resumed job = %+v", done)
+	}
+	if len(restarted.starts) != 1 || restarted.starts[0] != 4 {
+		t.Errorf("resumed at %v, want item 4", restarted.starts)
+	}
+	all := append(killed.processed, restarted.processed...)
+	for i, item := range all {
+		if item != i {
+			t.Fatalf("processed %v, want every item once in order", all)
+		}
+	}
+	if len(all) != 10 {
+		t.Errorf("processed %d items, want 10", len(all))
+	}
+
+	persisted, _ = store.Jobs()
+	if persisted[0].State != StateCompleted || persisted[0].FinishedAt == nil {
+		t.Errorf("persisted after completion = %+v", persisted[0])
+	}
+}
+
+func TestRunner_CancelAndRetry(t *testing.T) {
+	store := &memoryStore{}
+	handler := &countingHandler{items: 5, gateAt: 2, gate: make(chan struct{})}
+	runner := NewRunner(store)
+	runner.Register("count", handler)
+	defer runner.Stop()
+
+	job, err := runner.Submit("count", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, runner, job.ID, func(j *Job) bool { return j.Progress.Processed == 2 })
+
+	canceled, err := runner.Cancel(job.ID)
+	if err != nil || canceled.State != StateCanceled {
+		t.Fatalf("Cancel() = %+v, %v", canceled, err)
+	}
+	var stateErr *StateError
+	if _, err := runner.Cancel(job.ID); !errors.As(err, &stateErr) {
+		t.Errorf("second Cancel() error = %v, want a state error", err)
+	}
+	if _, err := runner.Retry("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Retry() of an unknown job error = %v", err)
+	}
+
+	// Once canceled the handler has returned, so the retry is its second run
+	waitJob(t, runner, job.ID, func(j *Job) bool {
+		runner.mu.Lock()
+		defer runner.mu.Unlock()
+		return len(runner.running) == 0
+	})
+	close(handler.gate)
+	if _, err := runner.Retry(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	done := waitJob(t, runner, job.ID, func(j *Job) bool { return j.Finished() })
+	if done.State != StateCompleted || done.Progress.Processed != 5 || done.Error != "" {
+		t.Errorf("retried job = %+v", done)
+	}
+	if len(handler.starts) != 2 || handler.starts[1] != 2 {
+		t.Errorf("runs started at %v, want the retry at item 2", handler.starts)
+	}
+	if _, err := runner.Retry(job.ID); !errors.As(err, &stateErr) {
+		t.Errorf("Retry() of a completed job error = %v, want a state error", err)
+	}
+}
+
+func TestRunner_RecoverWithoutHandler(t *testing.T) {
+	store := &memoryStore{}
+	now := time.Now()
+	store.SaveJob(&Job{ID: "orphan", Type: "retired", State: StateRunning, CreatedAt: now})
+	store.SaveJob(&Job{ID: "done", Type: "retired", State: StateCompleted, CreatedAt: now})
+
+	runner := NewRunner(store)
+	if resumed, err := runner.Recover(); err != nil || resumed != 0 {
+		t.Fatalf("Recover() = %d, %v", resumed, err)
+	}
+
+	orphan, err := runner.Get("orphan")
+	if err != nil || orphan.State != StateFailed || orphan.Error == "" {
+		t.Errorf("orphan = %+v, %v, want failed", orphan, err)
+	}
+	persisted, _ := store.Jobs()
+	if persisted[0].State != StateFailed {
+		t.Errorf("persisted orphan = %+v, want failed", persisted[0])
+	}
+	if len(runner.List("retired")) != 2 || len(runner.List("other")) != 0 {
+		t.Errorf("List() = %d jobs, want the 2 recovered", len(runner.List("retired")))
+	}
+	if _, err := runner.Submit("retired", nil); err == nil {
+		t.Error("job of an unregistered type accepted")
+	}
+}
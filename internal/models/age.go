@@ -0,0 +1,166 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AgeUnit is the unit an age is expressed in
+type AgeUnit string
+
+const (
+	AgeYears  AgeUnit = "year"
+	AgeMonths AgeUnit = "month"
+	AgeDays   AgeUnit = "day"
+	AgeHours  AgeUnit = "hour"
+	AgeNow    AgeUnit = "now" // Less than an hour, Value is 0
+)
+
+// Age formats of temporal search results
+const (
+	AgeFormatText = "text" // Localized text such as "2 years ago", the default
+	AgeFormatISO  = "iso"  // ISO 8601 duration such as "P730DT4H"
+)
+
+// DefaultAgeLocale is used when no requested locale is registered
+const DefaultAgeLocale = "en"
+
+// Age is the time elapsed since a document, in its largest whole unit
+type Age struct {
+	Value int     `json:"value"`
+	Unit  AgeUnit `json:"unit"`
+}
+
+// AgeOf returns the age of t at reference, documents from the future are AgeNow
+func AgeOf(t time.Time, reference time.Time) Age {
+	hours := reference.Sub(t).Hours()
+
+	if years := int(hours / (24 * 365.25)); years > 0 {
+		return Age{Value: years, Unit: AgeYears}
+	}
+	if months := int(hours / (24 * 30.44)); months > 0 {
+		return Age{Value: months, Unit: AgeMonths}
+	}
+	if days := int(hours / 24); days > 0 {
+		return Age{Value: days, Unit: AgeDays}
+	}
+	if hours := int(hours); hours > 0 {
+		return Age{Value: hours, Unit: AgeHours}
+	}
+	return Age{Unit: AgeNow}
+}
+
+// AgeLocale formats an age in a language
+type AgeLocale func(Age) string
+
+// ageForms holds the phrase of a language with the singular and plural of every unit
+type ageForms struct {
+	phrase string // fmt pattern taking the count and unit, as in "%d %s ago"
+	now    string
+	units  map[AgeUnit][2]string
+}
+
+func (f ageForms) format(age Age) string {
+	if age.Unit == AgeNow {
+		return f.now
+	}
+	forms := f.units[age.Unit]
+	unit := forms[1]
+	if age.Value == 1 {
+		unit = forms[0]
+	}
+	return fmt.Sprintf(f.phrase, age.Value, unit)
+}
+
+var (
+	ageLocalesMu sync.RWMutex
+	ageLocales   = map[string]AgeLocale{
+		"en": ageForms{phrase: "%d %s ago", now: "just now", units: map[AgeUnit][2]string{
+			AgeYears: {"year", "years"}, AgeMonths: {"month", "months"}, AgeDays: {"day", "days"}, AgeHours: {"hour", "hours"},
+		}}.format,
+		"de": ageForms{phrase: "vor %d %s", now: "gerade eben", units: map[AgeUnit][2]string{
+			AgeYears: {"Jahr", "Jahren"}, AgeMonths: {"Monat", "Monaten"}, AgeDays: {"Tag", "Tagen"}, AgeHours: {"Stunde", "Stunden"},
+		}}.format,
+		"fr": ageForms{phrase: "il y a %d %s", now: "à l'instant", units: map[AgeUnit][2]string{
+			AgeYears: {"an", "ans"}, AgeMonths: {"mois", "mois"}, AgeDays: {"jour", "jours"}, AgeHours: {"heure", "heures"},
+		}}.format,
+		"es": ageForms{phrase: "hace %d %s", now: "justo ahora", units: map[AgeUnit][2]string{
+			AgeYears: {"año", "años"}, AgeMonths: {"mes", "meses"}, AgeDays: {"día", "días"}, AgeHours: {"hora", "horas"},
+		}}.format,
+	}
+)
+
+// RegisterAgeLocale adds or replaces the age format of a language tag such as "it" or "pt-br"
+func RegisterAgeLocale(tag string, locale AgeLocale) {
+	ageLocalesMu.Lock()
+	defer ageLocalesMu.Unlock()
+
+	ageLocales[strings.ToLower(tag)] = locale
+}
+
+// LookupAgeLocale returns the registered locale of a language tag, trying the
+// tag itself then its base language, so "de-AT" uses "de"
+func LookupAgeLocale(tag string) (string, bool) {
+	ageLocalesMu.RLock()
+	defer ageLocalesMu.RUnlock()
+
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if _, ok := ageLocales[tag]; ok {
+		return tag, true
+	}
+	if base, _, found := strings.Cut(tag, "-"); found {
+		if _, ok := ageLocales[base]; ok {
+			return base, true
+		}
+	}
+	return "", false
+}
+
+// FormatAge formats an age in a registered locale, in English if it is not registered
+func FormatAge(age Age, locale string) string {
+	ageLocalesMu.RLock()
+	format, ok := ageLocales[strings.ToLower(locale)]
+	if !ok {
+		format = ageLocales[DefaultAgeLocale]
+	}
+	ageLocalesMu.RUnlock()
+
+	return format(age)
+}
+
+// CalculateAge returns the age of t at reference as text in a locale, such as "2 years ago"
+func CalculateAge(t time.Time, reference time.Time, locale string) string {
+	return FormatAge(AgeOf(t, reference), locale)
+}
+
+// ISODuration returns the time elapsed from t to reference as an ISO 8601 duration
+// in days, hours, minutes and seconds, such as "P730DT4H5M". Documents from the
+// future have the zero duration "PT0S"
+func ISODuration(t time.Time, reference time.Time) string {
+	d := reference.Sub(t).Truncate(time.Second)
+	if d <= 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	b.WriteString("P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d > 0 {
+		b.WriteString("T")
+		for _, part := range []struct {
+			unit   time.Duration
+			suffix string
+		}{{time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}} {
+			if n := d / part.unit; n > 0 {
+				fmt.Fprintf(&b, "%d%s", n, part.suffix)
+				d -= n * part.unit
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgeOf(t *testing.T) {
+	reference := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		elapsed time.Duration
+		want    Age
+	}{
+		{0, Age{Unit: AgeNow}},
+		{-time.Hour, Age{Unit: AgeNow}},
+		{59 * time.Minute, Age{Unit: AgeNow}},
+		{3 * time.Hour, Age{Value: 3, Unit: AgeHours}},
+		{25 * time.Hour, Age{Value: 1, Unit: AgeDays}},
+		{45 * 24 * time.Hour, Age{Value: 1, Unit: AgeMonths}},
+		{800 * 24 * time.Hour, Age{Value: 2, Unit: AgeYears}},
+	}
+	for _, tt := range tests {
+		if got := AgeOf(reference.Add(-tt.elapsed), reference); got != tt.want {
+			t.Errorf("AgeOf(%v ago) = %+v, want %+v", tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		locale string
+		age    Age
+		want   string
+	}{
+		{"en", Age{Value: 1, Unit: AgeYears}, "1 year ago"},
+		{"en", Age{Value: 3, Unit: AgeDays}, "3 days ago"},
+		{"en", Age{Unit: AgeNow}, "just now"},
+		{"de", Age{Value: 1, Unit: AgeMonths}, "vor 1 Monat"},
+		{"de", Age{Value: 2, Unit: AgeYears}, "vor 2 Jahren"},
+		{"de", Age{Unit: AgeNow}, "gerade eben"},
+		{"fr", Age{Value: 1, Unit: AgeHours}, "il y a 1 heure"},
+		{"fr", Age{Value: 5, Unit: AgeMonths}, "il y a 5 mois"},
+		{"fr", Age{Unit: AgeNow}, "à l'instant"},
+		{"es", Age{Value: 1, Unit: AgeDays}, "hace 1 día"},
+		{"es", Age{Value: 4, Unit: AgeMonths}, "hace 4 meses"},
+		{"es", Age{Unit: AgeNow}, "justo ahora"},
+		{"xx", Age{Value: 2, Unit: AgeHours}, "2 hours ago"},
+	}
+	for _, tt := range tests {
+		if got := FormatAge(tt.age, tt.locale); got != tt.want {
+			t.Errorf("FormatAge(%+v, %s) = %q, want %q", tt.age, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterAgeLocale(t *testing.T) {
+	RegisterAgeLocale("it-TEST", func(age Age) string { return "fa" })
+	if locale, ok := LookupAgeLocale("IT-test"); !ok || locale != "it-test" {
+		t.Errorf("LookupAgeLocale = %q, %v", locale, ok)
+	}
+	if got := FormatAge(Age{Value: 1, Unit: AgeDays}, "it-test"); got != "fa" {
+		t.Errorf("registered locale formatted %q", got)
+	}
+	if locale, ok := LookupAgeLocale("de-AT"); !ok || locale != "de" {
+		t.Errorf("regional tag resolved to %q, %v, want the base language", locale, ok)
+	}
+}
+
+func TestISODuration(t *testing.T) {
+	reference := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		elapsed time.Duration
+		want    string
+	}{
+		{0, "PT0S"},
+		{-time.Hour, "PT0S"},
+		{90 * time.Second, "PT1M30S"},
+		{48 * time.Hour, "P2D"},
+		{730*24*time.Hour + 4*time.Hour + 5*time.Minute + 500*time.Millisecond, "P730DT4H5M"},
+	}
+	for _, tt := range tests {
+		if got := ISODuration(reference.Add(-tt.elapsed), reference); got != tt.want {
+			t.Errorf("ISODuration(%v) = %q, want %q", tt.elapsed, got, tt.want)
+		}
+	}
+}
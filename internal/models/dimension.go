@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// DefaultMaxEmbeddingDimension is the largest embedding accepted unless configured otherwise
+const DefaultMaxEmbeddingDimension = 16384
+
+// ErrDimensionTooLarge is returned for vectors whose embedding exceeds the maximum dimension
+var ErrDimensionTooLarge = errors.New("embedding dimension too large")
+
+// maxEmbeddingDimension is the configured maximum, zero for the default
+var maxEmbeddingDimension atomic.Int64
+
+// MaxEmbeddingDimension returns the largest embedding vectors may have
+func MaxEmbeddingDimension() int {
+	if max := maxEmbeddingDimension.Load(); max > 0 {
+		return int(max)
+	}
+	return DefaultMaxEmbeddingDimension
+}
+
+// SetMaxEmbeddingDimension sets the largest embedding vectors may have, the default when max is not positive
+// It applies to every vector validated afterwards, vectors already stored are left alone
+func SetMaxEmbeddingDimension(max int) {
+	if max < 0 {
+		max = 0
+	}
+	maxEmbeddingDimension.Store(int64(max))
+}
+
+// ParseMaxEmbeddingDimension parses a MAX_EMBEDDING_DIMENSION value, zero when it is empty
+func ParseMaxEmbeddingDimension(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	max, err := strconv.Atoi(value)
+	if err != nil || max <= 0 {
+		return 0, fmt.Errorf("invalid MAX_EMBEDDING_DIMENSION %q: must be a positive integer", value)
+	}
+	return max, nil
+}
+
+// CheckDimension rejects embeddings larger than MaxEmbeddingDimension: dense embeddings
+// by their length and sparse ones by the values they hold, since their dimension is
+// a vocabulary size that costs nothing until values fill it
+func (v *Vector) CheckDimension() error {
+	max := MaxEmbeddingDimension()
+	if v.Sparse != nil {
+		if len(v.Sparse.Values) > max {
+			return fmt.Errorf("%w: sparse embedding has %d values, the maximum is %d", ErrDimensionTooLarge, len(v.Sparse.Values), max)
+		}
+		return nil
+	}
+	if len(v.Embedding) > max {
+		return fmt.Errorf("%w: embedding has %d dimensions, the maximum is %d", ErrDimensionTooLarge, len(v.Embedding), max)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// DType is the numeric type a client-supplied embedding was sent as
+type DType string
+
+const (
+	DTypeFloat64 DType = "float64"
+	DTypeFloat32 DType = "float32"
+	DTypeInt8    DType = "int8"
+	DTypeInt32   DType = "int32"
+)
+
+// InternalDType is the precision client-supplied embeddings are normalized to,
+// so the same vector scores identically whatever representation it arrived in
+const InternalDType = DTypeFloat32
+
+// Validate checks that d is a supported dtype, the empty dtype is accepted
+func (d DType) Validate() error {
+	switch d {
+	case "", DTypeFloat64, DTypeFloat32, DTypeInt8, DTypeInt32:
+		return nil
+	default:
+		return fmt.Errorf("invalid dtype: %s (must be: float64, float32, int8, int32)", d)
+	}
+}
+
+// OrDefault returns d, or float64 for vectors stored without a dtype
+func (d DType) OrDefault() DType {
+	if d == "" {
+		return DTypeFloat64
+	}
+	return d
+}
+
+// DecodeEmbedding decodes an embedding sent as JSON numbers of any kind or as
+// base64 packed little-endian float32, checking the values against dtype
+// Arrays default to float64 and base64 to float32; the returned dtype is the resolved one
+func DecodeEmbedding(numbers []json.Number, b64 string, dtype DType) ([]float64, DType, error) {
+	if err := dtype.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	if b64 != "" {
+		if len(numbers) > 0 {
+			return nil, "", fmt.Errorf("embedding and embedding_b64 are mutually exclusive")
+		}
+		if dtype != "" && dtype != DTypeFloat32 {
+			return nil, "", fmt.Errorf("embedding_b64 holds float32 values, got dtype %s", dtype)
+		}
+		embedding, err := DecodeEmbeddingBase64(b64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid embedding_b64: %w", err)
+		}
+		return embedding, DTypeFloat32, nil
+	}
+
+	dtype = dtype.OrDefault()
+	embedding := make([]float64, len(numbers))
+	for i, number := range numbers {
+		value, err := number.Float64()
+		if err != nil || math.IsInf(value, 0) {
+			return nil, "", fmt.Errorf("embedding[%d]: %s is not a representable number", i, number)
+		}
+
+		switch dtype {
+		case DTypeInt8:
+			if value != math.Trunc(value) || value < math.MinInt8 || value > math.MaxInt8 {
+				return nil, "", fmt.Errorf("embedding[%d]: %s is not an int8", i, number)
+			}
+		case DTypeInt32:
+			if value != math.Trunc(value) || value < math.MinInt32 || value > math.MaxInt32 {
+				return nil, "", fmt.Errorf("embedding[%d]: %s is not an int32", i, number)
+			}
+		}
+		embedding[i] = value
+	}
+
+	return embedding, dtype, nil
+}
+
+// NormalizeEmbedding returns a copy of embedding rounded to InternalDType precision
+func NormalizeEmbedding(embedding []float64) ([]float64, error) {
+	normalized := make([]float64, len(embedding))
+	for i, value := range embedding {
+		rounded := float64(float32(value))
+		if math.IsInf(rounded, 0) || math.IsNaN(rounded) {
+			return nil, fmt.Errorf("embedding[%d]: %g exceeds the %s range", i, value, InternalDType)
+		}
+		normalized[i] = rounded
+	}
+	return normalized, nil
+}
+
+// VectorInput is a vector sent by a client, whose embedding may be any numeric
+// representation: a JSON array of numbers of any kind, or base64 packed float32
+type VectorInput struct {
+	Vector
+	Embedding    []json.Number `json:"embedding,omitempty"`
+	EmbeddingB64 string        `json:"embedding_b64,omitempty"`
+	DType        DType         `json:"dtype,omitempty"`
+}
+
+// ToVector decodes and normalizes the embedding and returns the vector to store
+func (in *VectorInput) ToVector() (*Vector, error) {
+	if in.Sparse != nil {
+		return in.sparseVector()
+	}
+
+	embedding, dtype, err := DecodeEmbedding(in.Embedding, in.EmbeddingB64, in.DType)
+	if err != nil {
+		return nil, err
+	}
+	if embedding, err = NormalizeEmbedding(embedding); err != nil {
+		return nil, err
+	}
+
+	vector := in.Vector
+	vector.SetEmbedding(embedding)
+	vector.DType = dtype
+	return &vector, nil
+}
+
+// sparseVector normalizes the values of a sparse embedding like dense ones
+func (in *VectorInput) sparseVector() (*Vector, error) {
+	if len(in.Embedding) > 0 || in.EmbeddingB64 != "" {
+		return nil, fmt.Errorf("embedding and embedding_sparse are mutually exclusive")
+	}
+	sparse, err := NormalizeSparse(in.Sparse)
+	if err != nil {
+		return nil, err
+	}
+
+	vector := in.Vector
+	vector.SetSparse(sparse)
+	vector.DType = ""
+	return &vector, nil
+}
+
+// NormalizeSparse validates a client-supplied sparse embedding and rounds its
+// values to the internal precision
+func NormalizeSparse(sparse *SparseVector) (*SparseVector, error) {
+	if err := sparse.Validate(); err != nil {
+		return nil, err
+	}
+	values, err := NormalizeEmbedding(sparse.Values)
+	if err != nil {
+		return nil, fmt.Errorf("embedding_sparse: %w", err)
+	}
+	return &SparseVector{Indices: sparse.Indices, Values: values, Dimension: sparse.Dimension}, nil
+}
+
+// UnmarshalJSON accepts the query embedding in any representation supported by VectorInput
+func (r *SearchByEmbbedingRequest) UnmarshalJSON(data []byte) error {
+	type plain SearchByEmbbedingRequest
+	in := struct {
+		plain
+		Embedding []json.Number `json:"embedding"`
+	}{plain: plain(*r)}
+
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	embedding, dtype, err := DecodeEmbedding(in.Embedding, in.EmbeddingB64, in.DType)
+	if err != nil {
+		return err
+	}
+
+	*r = SearchByEmbbedingRequest(in.plain)
+	r.Embedding = embedding
+	r.DType = dtype
+	return nil
+}
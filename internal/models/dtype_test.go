@@ -0,0 +1,98 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decodeVectorInput(t *testing.T, body string) (*Vector, error) {
+	t.Helper()
+	var input VectorInput
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	return input.ToVector()
+}
+
+func TestVectorInputRepresentations(t *testing.T) {
+	embedding := []float64{0.1, -0.25, 0.3333333333333333, 1}
+	b64 := EncodeEmbeddingBase64(embedding)
+
+	representations := map[string]string{
+		"float64 array": `{"id": "v", "embedding": [0.1, -0.25, 0.3333333333333333, 1]}`,
+		"float32 array": `{"id": "v", "embedding": [0.1, -0.25, 0.33333334, 1.0], "dtype": "float32"}`,
+		"exponents":     `{"id": "v", "embedding": [1e-1, -2.5E-1, 3.3333334e-1, 1e0]}`,
+		"base64":        `{"id": "v", "embedding_b64": "` + b64 + `"}`,
+	}
+
+	query := &Vector{Embedding: []float64{0.5, 0.5, 0.5, 0.5}}
+	var want *Vector
+	var wantScore float64
+
+	for name, body := range representations {
+		vector, err := decodeVectorInput(t, body)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if want == nil {
+			want, wantScore = vector, query.CosineSimilarity(vector)
+			continue
+		}
+		for i := range want.Embedding {
+			if vector.Embedding[i] != want.Embedding[i] {
+				t.Errorf("%s: component %d = %v, want %v", name, i, vector.Embedding[i], want.Embedding[i])
+			}
+		}
+		if score := query.CosineSimilarity(vector); score != wantScore {
+			t.Errorf("%s: score %v differs from %v", name, score, wantScore)
+		}
+	}
+}
+
+func TestVectorInputIntegers(t *testing.T) {
+	vector, err := decodeVectorInput(t, `{"embedding": [-128, 0, 127], "dtype": "int8"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vector.DType != DTypeInt8 || vector.Embedding[0] != -128 || vector.Embedding[2] != 127 {
+		t.Errorf("vector = %+v", vector)
+	}
+
+	tests := map[string]string{
+		"int8 overflow":      `{"embedding": [1, 128], "dtype": "int8"}`,
+		"fractional int":     `{"embedding": [1.5], "dtype": "int32"}`,
+		"beyond float64":     `{"embedding": [1e400]}`,
+		"beyond float32":     `{"embedding": [1e300]}`,
+		"unknown dtype":      `{"embedding": [1], "dtype": "bfloat16"}`,
+		"both encodings":     `{"embedding": [1], "embedding_b64": "AACAPw=="}`,
+		"base64 wrong dtype": `{"embedding_b64": "AACAPw==", "dtype": "float64"}`,
+		"truncated base64":   `{"embedding_b64": "AACA"}`,
+	}
+	for name, body := range tests {
+		if _, err := decodeVectorInput(t, body); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// Huge integers are rejected with a message rather than a decoding failure
+	_, err = decodeVectorInput(t, `{"embedding": [`+strings.Repeat("9", 400)+`]}`)
+	if err == nil || !strings.Contains(err.Error(), "embedding[0]") {
+		t.Errorf("error = %v, want one naming the component", err)
+	}
+}
+
+func TestSearchByEmbeddingRequestBase64(t *testing.T) {
+	var req SearchByEmbbedingRequest
+	body := `{"embedding_b64": "` + EncodeEmbeddingBase64([]float64{1, 0.5}) + `", "top_K": 3, "namespace": "ns", "min_score": 0.2}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if len(req.Embedding) != 2 || req.Embedding[1] != 0.5 || req.DType != DTypeFloat32 {
+		t.Errorf("embedding = %v (%s)", req.Embedding, req.DType)
+	}
+	if req.TopK != 3 || req.Namespace != "ns" || req.MinScore == nil || *req.MinScore != 0.2 {
+		t.Errorf("other fields lost: %+v", req)
+	}
+}
@@ -3,7 +3,11 @@ package models
 import (
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // FilterExpr represents a filter expression with operators
@@ -11,10 +15,29 @@ type FilterExpr map[string]interface{}
 
 // AdvancedSearchRequest extends SearchByEmbeddingRequest with filters
 type AdvancedSearchRequest struct {
-	Query   string                `json:"query,omitempty"`
-	TopK    int                   `json:"top_k,omitempty"`
-	Filters map[string]FilterExpr `json:"filters,omitempty"`
-	Options *SearchOptions        `json:"options,omitempty"`
+	Query     string                `json:"query,omitempty"`
+	TopK      int                   `json:"top_k,omitempty"`
+	Namespace string                `json:"namespace,omitempty"`
+	Filters   Filters               `json:"filters,omitempty"`
+	Options   *SearchOptions        `json:"options,omitempty"`
+
+	Highlight        bool              `json:"highlight,omitempty"`
+	HighlightOptions *HighlightOptions `json:"highlight_options,omitempty"`
+
+	// IncludeMetadata returns the metadata of each result, projected by metadata_fields,
+	// next to the text, author, year and tags fields flattened from it
+	IncludeMetadata bool `json:"include_metadata,omitempty"`
+
+	SearchParams
+
+	// SparseQuery is the sparse query embedding, scored instead of the dense one when set
+	SparseQuery *SparseVector `json:"-"`
+
+	// Candidates restricts the search to these vector IDs when not nil
+	Candidates []string `json:"-"`
+
+	// Scoring adjusts the scores before results are ranked and cut to TopK
+	Scoring *Scoring `json:"-"`
 }
 
 // SearchOptions for hybrid search weighting
@@ -28,6 +51,22 @@ type HybridWeight struct {
 	Metadata float64 `json:"metadata"`
 }
 
+// Validate checks the search options, which may be nil
+func (so *SearchOptions) Validate() error {
+	if so == nil || so.HybridWeight == nil {
+		return nil
+	}
+
+	hw := so.HybridWeight
+	if hw.Vector < 0 || hw.Vector > 1 || hw.Metadata < 0 || hw.Metadata > 1 {
+		return fmt.Errorf("hybrid weights must be between 0 and 1")
+	}
+	if hw.Vector+hw.Metadata != 1.0 {
+		return fmt.Errorf("hybrid weights must sum to 1.0")
+	}
+	return nil
+}
+
 func (asr *AdvancedSearchRequest) Validate() error {
 	if asr.Query == "" {
 		return fmt.Errorf("query cannot be empty")
@@ -37,44 +76,266 @@ func (asr *AdvancedSearchRequest) Validate() error {
 	}
 	
 	// Validate hybrid weights if provided
-	if asr.Options != nil && asr.Options.HybridWeight != nil {
-		hw := asr.Options.HybridWeight
-		if hw.Vector < 0 || hw.Vector > 1 || hw.Metadata < 0 || hw.Metadata > 1 {
-			return fmt.Errorf("hybrid weights must be between 0 and 1")
-		}
-		if hw.Vector+hw.Metadata != 1.0 {
-			return fmt.Errorf("hybrid weights must sum to 1.0")
-		}
+	if err := asr.Options.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := NewFilterEvaluator().CompileSearch(asr.Filters); err != nil {
+		return err
 	}
 	
 	return nil
 }
 
+// MatchModifier is the expression key choosing how a field pattern matches:
+// "any" (default) or "all" of the metadata fields matching the pattern
+const MatchModifier = "match"
+
 // FilterEvaluator handles filter evaluation logic
-type FilterEvaluator struct{}
+type FilterEvaluator struct {
+	// KeyFallback matches a filter field missing from the metadata against the keys
+	// that normalize to the same key, so a filter on "author" also matches "Author"
+	KeyFallback bool
+
+	// Anchor is the time the relative bounds of before and after filters are
+	// resolved against, now when zero
+	Anchor time.Time
+}
 
 // NewFilterEvaluator creates a new filter evaluator
 func NewFilterEvaluator() *FilterEvaluator {
 	return &FilterEvaluator{}
 }
 
+// CompiledFilters is a filter set prepared once per request
+// Filter keys containing * are globs over metadata field names
+type CompiledFilters struct {
+	fields   map[string]FilterExpr
+	patterns []*fieldPattern
+	soft     []*softFilter // Preferences, never checked by Matches
+}
+
+// fieldPattern is a pre-compiled glob filter over metadata field names
+type fieldPattern struct {
+	pattern string
+	re      *regexp.Regexp
+	expr    FilterExpr
+	all     bool
+}
+
+// Compile prepares filters for repeated evaluation, compiling field patterns
+// Deprecated operator spellings are accepted and unknown operators rejected, as are
+// soft filters, which only searches accept through CompileSearch
+func (fe *FilterEvaluator) Compile(filters map[string]FilterExpr) (*CompiledFilters, error) {
+	return fe.compile(filters, false)
+}
+
+func (fe *FilterEvaluator) compile(filters map[string]FilterExpr, allowSoft bool) (*CompiledFilters, error) {
+	filters, err := Filters(filters).Canonical()
+	if err != nil {
+		return nil, err
+	}
+	compiled := &CompiledFilters{fields: make(map[string]FilterExpr)}
+
+	for field, expr := range filters {
+		expr, soft := splitSoft(expr)
+		if soft {
+			if !allowSoft {
+				return nil, fmt.Errorf("the %s modifier on field %s only applies to searches", SoftModifier, field)
+			}
+			sub, err := fe.compile(map[string]FilterExpr{field: expr}, false)
+			if err != nil {
+				return nil, err
+			}
+			compiled.soft = append(compiled.soft, &softFilter{name: field, filters: sub})
+			continue
+		}
+		if err := fe.resolveTimeBounds(field, expr); err != nil {
+			return nil, err
+		}
+
+		if !strings.Contains(field, "*") {
+			compiled.fields[field] = expr
+			continue
+		}
+
+		fp := &fieldPattern{pattern: field, expr: make(FilterExpr)}
+		for op, val := range expr {
+			if op != MatchModifier {
+				fp.expr[op] = val
+				continue
+			}
+			switch val {
+			case "any":
+			case "all":
+				fp.all = true
+			default:
+				return nil, fmt.Errorf("invalid %s modifier for %s: %v (must be: any, all)", MatchModifier, field, val)
+			}
+		}
+
+		re, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(field), `\*`, ".*") + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid field pattern %s: %w", field, err)
+		}
+		fp.re = re
+
+		compiled.patterns = append(compiled.patterns, fp)
+	}
+
+	// Keep evaluation order stable across requests
+	sort.Slice(compiled.patterns, func(i, j int) bool {
+		return compiled.patterns[i].pattern < compiled.patterns[j].pattern
+	})
+	sortSoft(compiled.soft)
+
+	return compiled, nil
+}
+
+// Patterns returns the field patterns of the compiled filters
+func (cf *CompiledFilters) Patterns() []string {
+	patterns := make([]string, len(cf.patterns))
+	for i, fp := range cf.patterns {
+		patterns[i] = fp.pattern
+	}
+	return patterns
+}
+
+// UnmatchedPatterns returns the field patterns that match no metadata field of any vector
+func (cf *CompiledFilters) UnmatchedPatterns(vectors []*Vector) []string {
+	unmatched := make([]string, 0)
+	for _, fp := range cf.patterns {
+		found := false
+		for _, vector := range vectors {
+			for key := range vector.Metadata {
+				if fp.re.MatchString(key) {
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, fp.pattern)
+		}
+	}
+	return unmatched
+}
+
 // Evaluate checks if metadata matches all filters
 func (fe *FilterEvaluator) Evaluate(metadata map[string]string, filters map[string]FilterExpr) bool {
 	if len(filters) == 0 {
 		return true // No filters means match all
 	}
 
-	for field, expr := range filters {
+	compiled, err := fe.Compile(filters)
+	if err != nil {
+		return false
+	}
+
+	return fe.Matches(metadata, compiled)
+}
+
+// Matches checks if metadata matches all compiled filters
+func (fe *FilterEvaluator) Matches(metadata map[string]string, filters *CompiledFilters) bool {
+	if filters == nil {
+		return true
+	}
+
+	for field, expr := range filters.fields {
 		value, exists := metadata[field]
+		if !exists && fe.KeyFallback {
+			if variants := variantKeys(metadata, field); len(variants) > 0 {
+				if !fe.evaluateVariants(metadata, variants, expr) {
+					return false
+				}
+				continue
+			}
+		}
 		
 		if !fe.evaluateExpression(value, exists, expr) {
 			return false
 		}
 	}
+
+	for _, fp := range filters.patterns {
+		if !fe.evaluatePattern(metadata, fp) {
+			return false
+		}
+	}
 	
 	return true
 }
 
+// evaluateVariants checks the values of the keys variant of a filter field, passing if any matches
+func (fe *FilterEvaluator) evaluateVariants(metadata map[string]string, variants []string, expr FilterExpr) bool {
+	for _, key := range variants {
+		if fe.evaluateExpression(metadata[key], true, expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// variantKeys returns the metadata keys other than field that normalize to the same key, sorted
+func variantKeys(metadata map[string]string, field string) []string {
+	normalized := NormalizeKey(field)
+	var variants []string
+	for key := range metadata {
+		if key != field && NormalizeKey(key) == normalized {
+			variants = append(variants, key)
+		}
+	}
+	sort.Strings(variants)
+	return variants
+}
+
+// KeyFallbacks returns the filter fields missing from metadata that a key
+// fallback would match, with the metadata keys they would match instead
+func (cf *CompiledFilters) KeyFallbacks(metadata map[string]string) map[string][]string {
+	var fallbacks map[string][]string
+	for field := range cf.fields {
+		if _, exists := metadata[field]; exists {
+			continue
+		}
+		if variants := variantKeys(metadata, field); len(variants) > 0 {
+			if fallbacks == nil {
+				fallbacks = make(map[string][]string)
+			}
+			fallbacks[field] = variants
+		}
+	}
+	return fallbacks
+}
+
+// evaluatePattern checks any (or all) metadata fields matching a field pattern
+// When no field matches, the expression is evaluated as for a missing field
+func (fe *FilterEvaluator) evaluatePattern(metadata map[string]string, fp *fieldPattern) bool {
+	matched := false
+	for key, value := range metadata {
+		if !fp.re.MatchString(key) {
+			continue
+		}
+		matched = true
+
+		ok := fe.evaluateExpression(value, true, fp.expr)
+		if ok && !fp.all {
+			return true
+		}
+		if !ok && fp.all {
+			return false
+		}
+	}
+
+	if !matched {
+		return fe.evaluateExpression("", false, fp.expr)
+	}
+
+	return fp.all
+}
+
 // evaluateExpression evaluates a single filter expression
 func (fe *FilterEvaluator) evaluateExpression(value string, exists bool, expr FilterExpr) bool {
 	for op, expectedVal := range expr {
@@ -115,6 +376,14 @@ func (fe *FilterEvaluator) evaluateExpression(value string, exists bool, expr Fi
 			if !exists || !fe.compareIn(value, expectedVal) {
 				return false
 			}
+		case "nin":
+			if exists && fe.compareIn(value, expectedVal) {
+				return false
+			}
+		case "before", "after":
+			if !exists || !fe.compareTime(value, expectedVal, op == "before") {
+				return false
+			}
 		case "exists":
 			expectedExists, ok := expectedVal.(bool)
 			if !ok {
@@ -136,6 +405,11 @@ func (fe *FilterEvaluator) compareLess(value string, expected interface{}, orEqu
 	valFloat, valErr := fe.toFloat64(value)
 	expFloat, expErr := fe.toFloat64(expected)
 	
+	// A number bound only matches numbers, "n/a" is not >= 1900
+	if valErr != nil && isNumeric(expected) {
+		return false
+	}
+	
 	if valErr == nil && expErr == nil {
 		if orEqual {
 			return valFloat <= expFloat
@@ -155,6 +429,11 @@ func (fe *FilterEvaluator) compareGreater(value string, expected interface{}, or
 	valFloat, valErr := fe.toFloat64(value)
 	expFloat, expErr := fe.toFloat64(expected)
 	
+	// A number bound only matches numbers, "n/a" is not >= 1900
+	if valErr != nil && isNumeric(expected) {
+		return false
+	}
+	
 	if valErr == nil && expErr == nil {
 		if orEqual {
 			return valFloat >= expFloat
@@ -207,6 +486,18 @@ func (fe *FilterEvaluator) compareIn(value string, expected interface{}) bool {
 	return false
 }
 
+// isNumeric reports whether a filter value is a JSON or Go number rather than a string
+func isNumeric(val interface{}) bool {
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
 // toFloat64 converts interface{} to float64
 func (fe *FilterEvaluator) toFloat64(val interface{}) (float64, error) {
 	v := reflect.ValueOf(val)
@@ -219,9 +510,8 @@ func (fe *FilterEvaluator) toFloat64(val interface{}) (float64, error) {
 	case reflect.Float32, reflect.Float64:
 		return v.Float(), nil
 	case reflect.String:
-		var f float64
-		_, err := fmt.Sscanf(v.String(), "%f", &f)
-		return f, err
+		// The whole value must be a number: Sscanf would read "2024-03-01" as 2024
+		return strconv.ParseFloat(strings.TrimSpace(v.String()), 64)
 	default:
 		return 0, fmt.Errorf("cannot convert to float64")
 	}
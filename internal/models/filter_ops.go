@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FilterOperators are the canonical filter expression operators
+var FilterOperators = []string{"eq", "neq", "lt", "lte", "gt", "gte", "between", "contains", "in", "nin", "exists", "before", "after"}
+
+// deprecatedOperators maps the operator spellings of the legacy list form,
+// still accepted in both forms, to the canonical operators
+var deprecatedOperators = map[string]string{
+	"=":      "eq",
+	"==":     "eq",
+	"!=":     "neq",
+	">=":     "gte",
+	"<=":     "lte",
+	">":      "gt",
+	"<":      "lt",
+	"not_in": "nin",
+}
+
+// CanonicalOperator returns the canonical spelling of a filter operator
+// ok is false for unknown operators
+func CanonicalOperator(op string) (canonical string, ok bool) {
+	if canonical, ok := deprecatedOperators[op]; ok {
+		return canonical, true
+	}
+	for _, known := range FilterOperators {
+		if op == known {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// Canonical returns the filters with deprecated operators renamed and list
+// values of any slice type converted to []interface{}, the form they are
+// evaluated in. Unknown operators and values of the wrong shape are rejected
+func (f Filters) Canonical() (Filters, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	canonical := make(Filters, len(f))
+	for field, expr := range f {
+		converted, err := canonicalExpr(field, expr)
+		if err != nil {
+			return nil, err
+		}
+		canonical[field] = converted
+	}
+	return canonical, nil
+}
+
+// canonicalExpr returns the canonical form of the expression on field
+func canonicalExpr(field string, expr FilterExpr) (FilterExpr, error) {
+	pattern := strings.Contains(field, "*")
+
+	// Iterate in a stable order so errors do not depend on map order
+	ops := make([]string, 0, len(expr))
+	for op := range expr {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	converted := make(FilterExpr, len(expr))
+	for _, op := range ops {
+		value := expr[op]
+		if op == MatchModifier {
+			if !pattern {
+				return nil, fmt.Errorf("the %s modifier on field %s only applies to field patterns containing *", MatchModifier, field)
+			}
+			converted[op] = value
+			continue
+		}
+		if op == SoftModifier {
+			if _, ok := value.(bool); !ok {
+				return nil, fmt.Errorf("the %s modifier on field %s requires true or false", SoftModifier, field)
+			}
+			converted[op] = value
+			continue
+		}
+
+		name, ok := CanonicalOperator(op)
+		if !ok {
+			return nil, fmt.Errorf("unknown filter operator %q on field %s (must be one of: %s)", op, field, strings.Join(FilterOperators, ", "))
+		}
+		if _, dup := converted[name]; dup {
+			return nil, fmt.Errorf("duplicate %q filter on field %s", name, field)
+		}
+
+		switch name {
+		case "in", "nin", "between":
+			list, ok := toList(value)
+			if !ok {
+				return nil, fmt.Errorf("filter %q on field %s requires a list", op, field)
+			}
+			if name == "between" && len(list) != 2 {
+				return nil, fmt.Errorf("filter %q on field %s requires [min, max]", op, field)
+			}
+			value = list
+		case "exists":
+			if _, ok := value.(bool); !ok {
+				return nil, fmt.Errorf("filter %q on field %s requires true or false", op, field)
+			}
+		case "before", "after":
+			if err := checkTimeBound(value); err != nil {
+				return nil, fmt.Errorf("filter %q on field %s: %w", op, field, err)
+			}
+		}
+		converted[name] = value
+	}
+	return converted, nil
+}
+
+// toList converts a slice or array of any element type to []interface{}
+func toList(value interface{}) ([]interface{}, bool) {
+	if list, ok := value.([]interface{}); ok {
+		return list, true
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	list := make([]interface{}, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface()
+	}
+	return list, true
+}
+
+// checkTimeBound checks the bound of a before or after filter: a time expression,
+// see ParseTimeExpr, or a time.Time
+func checkTimeBound(value interface{}) error {
+	switch bound := value.(type) {
+	case time.Time:
+		return nil
+	case string:
+		_, err := ParseTimeExpr(bound, time.Now())
+		return err
+	}
+	return fmt.Errorf("requires a time such as \"2024-03-01\" or \"now-30d\"")
+}
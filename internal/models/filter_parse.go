@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// conditionOperators are the operators of ParseFilterCondition, two-character
+// operators first so "year>=2015" is not read as "year>" "=2015"
+var conditionOperators = []string{">=", "<=", "!=", "==", "=", ">", "<"}
+
+// ParseFilterCondition parses a condition written field<op>value, such as
+// "label=pos" or "year>=2015", into the legacy list form
+// Ordered comparisons of numbers get a number bound, as a JSON filter would, so
+// they skip values that are not numbers
+func ParseFilterCondition(condition string) (MetadataFilter, error) {
+	at := strings.IndexAny(condition, "=!<>")
+	if at < 0 {
+		return MetadataFilter{}, fmt.Errorf("invalid condition %q: expected field<op>value with one of %s", condition, strings.Join(conditionOperators, " "))
+	}
+
+	var op string
+	for _, candidate := range conditionOperators {
+		if strings.HasPrefix(condition[at:], candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return MetadataFilter{}, fmt.Errorf("invalid condition %q: unknown operator", condition)
+	}
+
+	field := strings.TrimSpace(condition[:at])
+	value := strings.TrimSpace(condition[at+len(op):])
+	if field == "" {
+		return MetadataFilter{}, fmt.Errorf("invalid condition %q: missing field", condition)
+	}
+
+	filter := MetadataFilter{Field: field, Operator: op, Value: value}
+	switch op {
+	case ">=", "<=", ">", "<":
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			filter.Value = number
+		}
+	}
+	return filter, nil
+}
+
+// ParseFilterConditions parses conditions that must all match into canonical
+// filters, normalized by FiltersFromList like the filters of search requests
+func ParseFilterConditions(conditions []string) (Filters, error) {
+	list := make([]MetadataFilter, 0, len(conditions))
+	for _, condition := range conditions {
+		filter, err := ParseFilterCondition(condition)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, filter)
+	}
+	return FiltersFromList(list)
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestParseFilterConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []string
+		metadata   map[string]string
+		want       bool
+		wantErr    bool
+	}{
+		{name: "equal", conditions: []string{"label=pos"}, metadata: map[string]string{"label": "pos"}, want: true},
+		{name: "not equal", conditions: []string{"label!=unsup"}, metadata: map[string]string{"label": "unsup"}, want: false},
+		{name: "spaces", conditions: []string{" label == pos "}, metadata: map[string]string{"label": "pos"}, want: true},
+		{name: "numeric bound", conditions: []string{"year>=2015"}, metadata: map[string]string{"year": "2019"}, want: true},
+		{name: "numeric bound skips text", conditions: []string{"year>=2015"}, metadata: map[string]string{"year": "n/a"}, want: false},
+		{name: "all must match", conditions: []string{"label=pos", "year<2000"}, metadata: map[string]string{"label": "pos", "year": "2019"}, want: false},
+		{name: "range on one field", conditions: []string{"year>=2015", "year<=2020"}, metadata: map[string]string{"year": "2017"}, want: true},
+		{name: "value with operator", conditions: []string{"title=a=b"}, metadata: map[string]string{"title": "a=b"}, want: true},
+		{name: "missing operator", conditions: []string{"label"}, wantErr: true},
+		{name: "missing field", conditions: []string{"=pos"}, wantErr: true},
+		{name: "unknown operator", conditions: []string{"label!pos"}, wantErr: true},
+		{name: "duplicate operator", conditions: []string{"label=pos", "label=neg"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := ParseFilterConditions(tt.conditions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFilterConditions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := NewFilterEvaluator().Evaluate(tt.metadata, filters); got != tt.want {
+				t.Errorf("filters %v on %v = %v, want %v", filters, tt.metadata, got, tt.want)
+			}
+		})
+	}
+}
@@ -202,3 +202,91 @@ func TestFilterEvaluator_ComplexFilter(t *testing.T) {
 		t.Error("expected complex filter to match")
 	}
 }
+
+func TestFilterEvaluator_FieldPattern(t *testing.T) {
+	fe := NewFilterEvaluator()
+
+	metadata := map[string]string{
+		"attr_color":  "dark red",
+		"attr_size":   "large",
+		"attr_weight": "red-ish",
+		"title":       "shirt",
+	}
+
+	tests := []struct {
+		name     string
+		filters  map[string]FilterExpr
+		expected bool
+	}{
+		{"any field contains", map[string]FilterExpr{"attr_*": {"contains": "red"}}, true},
+		{"no field contains", map[string]FilterExpr{"attr_*": {"contains": "blue"}}, false},
+		{"all fields contain", map[string]FilterExpr{"attr_*": {"contains": "red", "match": "all"}}, false},
+		{"all fields exist", map[string]FilterExpr{"attr_*": {"exists": true, "match": "all"}}, true},
+		{"pattern matches nothing", map[string]FilterExpr{"dim_*": {"exists": false}}, true},
+		{"combined with exact field", map[string]FilterExpr{"attr_*": {"eq": "large"}, "title": {"eq": "shirt"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := fe.Compile(tt.filters)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result := fe.Matches(metadata, compiled); result != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFilterEvaluator_UnmatchedPatterns(t *testing.T) {
+	fe := NewFilterEvaluator()
+
+	if _, err := fe.Compile(map[string]FilterExpr{"attr_*": {"eq": "x", "match": "some"}}); err == nil {
+		t.Error("expected invalid match modifier to be rejected")
+	}
+
+	compiled, _ := fe.Compile(map[string]FilterExpr{
+		"attr_*": {"contains": "red"},
+		"dim_*":  {"contains": "red"},
+	})
+
+	vectors := []*Vector{{ID: "v1", Metadata: map[string]string{"attr_color": "red"}}}
+	unmatched := compiled.UnmatchedPatterns(vectors)
+	if len(unmatched) != 1 || unmatched[0] != "dim_*" {
+		t.Errorf("expected [dim_*], got %v", unmatched)
+	}
+}
+
+func TestFilters_Canonical(t *testing.T) {
+	canonical, err := Filters{
+		"year":   {">=": 1900, "<": 1950},
+		"author": {"not_in": []string{"Newton"}},
+		"attr_*": {"==": "red", "match": "all"},
+	}.Canonical()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if canonical["year"]["gte"] != 1900 || canonical["year"]["lt"] != 1950 || len(canonical["year"]) != 2 {
+		t.Errorf("year = %v, want gte and lt", canonical["year"])
+	}
+	if list, ok := canonical["author"]["nin"].([]interface{}); !ok || len(list) != 1 || list[0] != "Newton" {
+		t.Errorf("author = %v, want nin as a []interface{}", canonical["author"])
+	}
+	if canonical["attr_*"]["eq"] != "red" || canonical["attr_*"]["match"] != "all" {
+		t.Errorf("attr_* = %v, want eq with the match modifier", canonical["attr_*"])
+	}
+
+	for name, invalid := range map[string]Filters{
+		"unknown operator":      {"author": {"like": "Ein"}},
+		"duplicate spellings":   {"author": {"=": "a", "eq": "b"}},
+		"in without a list":     {"author": {"in": "Einstein"}},
+		"between of one value":  {"year": {"between": []int{1900}}},
+		"exists without a bool": {"tags": {"exists": "yes"}},
+		"match on a field":      {"author": {"eq": "a", "match": "all"}},
+	} {
+		if _, err := invalid.Canonical(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
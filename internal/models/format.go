@@ -0,0 +1,194 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+const (
+	// DefaultScorePrecision is the number of decimal places of scores in responses
+	DefaultScorePrecision = 6
+
+	// MaxPrecision is the largest accepted precision, beyond which float64 has no more digits
+	MaxPrecision = 15
+
+	// FullPrecision disables rounding
+	FullPrecision = -1
+
+	EmbeddingFormatArray  = "array"
+	EmbeddingFormatBase64 = "base64" // Little-endian packed float32
+)
+
+// ResponseFormat controls how scores and embeddings of search results are serialized
+// Formatting only happens when results are encoded, stored data is never rounded
+type ResponseFormat struct {
+	ScorePrecision     int    // Decimal places of scores, FullPrecision to disable rounding
+	EmbeddingPrecision int    // Decimal places of embedding components, FullPrecision to disable rounding
+	EmbeddingFormat    string // EmbeddingFormatArray or EmbeddingFormatBase64
+}
+
+// DefaultResponseFormat rounds scores to DefaultScorePrecision places and keeps embeddings at full precision
+func DefaultResponseFormat() ResponseFormat {
+	return ResponseFormat{
+		ScorePrecision:     DefaultScorePrecision,
+		EmbeddingPrecision: FullPrecision,
+		EmbeddingFormat:    EmbeddingFormatArray,
+	}
+}
+
+// Validate checks the precisions and embedding format
+func (f ResponseFormat) Validate() error {
+	for _, precision := range []int{f.ScorePrecision, f.EmbeddingPrecision} {
+		if precision < FullPrecision || precision > MaxPrecision {
+			return fmt.Errorf("precision must be between 0 and %d", MaxPrecision)
+		}
+	}
+	switch f.EmbeddingFormat {
+	case "", EmbeddingFormatArray, EmbeddingFormatBase64:
+		return nil
+	default:
+		return fmt.Errorf("invalid embedding_format: %s (must be: array, base64)", f.EmbeddingFormat)
+	}
+}
+
+// WithParams applies the per-request precision and embedding format of params
+// A request precision applies to both scores and embedding components
+func (f ResponseFormat) WithParams(params SearchParams) (ResponseFormat, error) {
+	if params.Precision != nil {
+		if *params.Precision < 0 || *params.Precision > MaxPrecision {
+			return f, fmt.Errorf("precision must be between 0 and %d", MaxPrecision)
+		}
+		f.ScorePrecision = *params.Precision
+		f.EmbeddingPrecision = *params.Precision
+	}
+	if params.EmbeddingFormat != "" {
+		f.EmbeddingFormat = params.EmbeddingFormat
+	}
+	return f, f.Validate()
+}
+
+// Round rounds value to precision decimal places
+func Round(value float64, precision int) float64 {
+	if precision < 0 {
+		return value
+	}
+	scale := math.Pow(10, float64(precision))
+	return math.Round(value*scale) / scale
+}
+
+// Score returns score rounded to the score precision
+func (f ResponseFormat) Score(score float64) float64 {
+	return Round(score, f.ScorePrecision)
+}
+
+// Explanation returns a copy of explanation with its scores rounded, nil when it is nil
+func (f ResponseFormat) Explanation(explanation *ScoreExplanation) *ScoreExplanation {
+	if explanation == nil {
+		return nil
+	}
+	rounded := &ScoreExplanation{BaseScore: f.Score(explanation.BaseScore), Score: f.Score(explanation.Score), SoftFilters: explanation.SoftFilters}
+	for _, adjustment := range explanation.Adjustments {
+		rounded.Adjustments = append(rounded.Adjustments, ScoreAdjustment{Adjuster: adjustment.Adjuster, Score: f.Score(adjustment.Score)})
+	}
+	return rounded
+}
+
+// Embedding returns the JSON value of embedding: a rounded copy, a base64 string, or nil when empty
+func (f ResponseFormat) Embedding(embedding []float64) interface{} {
+	if len(embedding) == 0 {
+		return nil
+	}
+	if f.EmbeddingFormat == EmbeddingFormatBase64 {
+		return EncodeEmbeddingBase64(embedding)
+	}
+	if f.EmbeddingPrecision < 0 {
+		return embedding
+	}
+
+	rounded := make([]float64, len(embedding))
+	for i, value := range embedding {
+		rounded[i] = Round(value, f.EmbeddingPrecision)
+	}
+	return rounded
+}
+
+// EncodeEmbeddingBase64 packs embedding as little-endian float32 and base64 encodes it
+func EncodeEmbeddingBase64(embedding []float64) string {
+	packed := make([]byte, 4*len(embedding))
+	for i, value := range embedding {
+		binary.LittleEndian.PutUint32(packed[4*i:], math.Float32bits(float32(value)))
+	}
+	return base64.StdEncoding.EncodeToString(packed)
+}
+
+// DecodeEmbeddingBase64 decodes an embedding encoded by EncodeEmbeddingBase64
+func DecodeEmbeddingBase64(encoded string) ([]float64, error) {
+	packed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(packed)%4 != 0 {
+		return nil, fmt.Errorf("packed embedding length %d is not a multiple of 4", len(packed))
+	}
+
+	embedding := make([]float64, len(packed)/4)
+	for i := range embedding {
+		embedding[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(packed[4*i:])))
+	}
+	return embedding, nil
+}
+
+// formattedVector serializes a vector with its embedding formatted
+type formattedVector struct {
+	*Vector
+	format *ResponseFormat
+}
+
+func (v formattedVector) MarshalJSON() ([]byte, error) {
+	type plain Vector
+	return json.Marshal(struct {
+		plain
+		Embedding interface{} `json:"embedding,omitempty"`
+	}{plain(*v.Vector), v.format.Embedding(v.Vector.Embedding)})
+}
+
+// FormatVector returns the JSON value of vector formatted with f, or vector itself when f is nil
+func FormatVector(vector *Vector, f *ResponseFormat) interface{} {
+	if f == nil || vector == nil {
+		return vector
+	}
+	return formattedVector{Vector: vector, format: f}
+}
+
+// MarshalJSON applies the result Format, if any
+func (r SearchResult) MarshalJSON() ([]byte, error) {
+	type plain SearchResult
+	if r.Format == nil {
+		return json.Marshal(plain(r))
+	}
+	return json.Marshal(struct {
+		plain
+		Vector      interface{}       `json:"vector"`
+		Score       float64           `json:"score"`
+		Explanation *ScoreExplanation `json:"explanation,omitempty"`
+	}{plain(r), FormatVector(r.Vector, r.Format), r.Format.Score(r.Score), r.Format.Explanation(r.Explanation)})
+}
+
+// MarshalJSON applies the result Format, if any
+func (r TemporalSearchResult) MarshalJSON() ([]byte, error) {
+	type plain TemporalSearchResult
+	if r.Format == nil {
+		return json.Marshal(plain(r))
+	}
+	return json.Marshal(struct {
+		plain
+		Vector      interface{}       `json:"vector"`
+		Score       float64           `json:"score"`
+		BaseScore   float64           `json:"base_score"`
+		DecayFactor float64           `json:"decay_factor"`
+		Explanation *ScoreExplanation `json:"explanation,omitempty"`
+	}{plain(r), FormatVector(r.Vector, r.Format), r.Format.Score(r.Score), r.Format.Score(r.BaseScore), r.Format.Score(r.DecayFactor), r.Format.Explanation(r.Explanation)})
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func testEmbedding(dimension int) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	embedding := make([]float64, dimension)
+	for i := range embedding {
+		embedding[i] = rng.NormFloat64() / math.Sqrt(float64(dimension))
+	}
+	return embedding
+}
+
+func marshalResult(t *testing.T, result SearchResult) []byte {
+	t.Helper()
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	return data
+}
+
+func TestSearchResultPayloadSize(t *testing.T) {
+	embedding := testEmbedding(768)
+	result := SearchResult{Vector: &Vector{ID: "doc", Embedding: embedding}, Score: 0.123456789123}
+
+	full := marshalResult(t, result)
+
+	rounded := DefaultResponseFormat()
+	rounded.EmbeddingPrecision = 4
+	result.Format = &rounded
+	compact := marshalResult(t, result)
+
+	packed := DefaultResponseFormat()
+	packed.EmbeddingFormat = EmbeddingFormatBase64
+	result.Format = &packed
+	encoded := marshalResult(t, result)
+
+	t.Logf("768-dim result: full %d bytes, 4 places %d bytes, base64 %d bytes", len(full), len(compact), len(encoded))
+
+	if len(compact) >= len(full)*2/3 {
+		t.Errorf("rounded payload %d bytes is not much smaller than %d", len(compact), len(full))
+	}
+	// Packed float32 is 4 bytes per component plus a third for base64,
+	// against ~20 bytes per full precision JSON number
+	if len(encoded)*7 > len(full)*2 {
+		t.Errorf("base64 payload %d bytes is not at least 3.5x smaller than %d", len(encoded), len(full))
+	}
+
+	// Formatting happens during serialization only
+	if result.Vector.Embedding[0] != embedding[0] || result.Score != 0.123456789123 {
+		t.Error("formatting modified the result")
+	}
+}
+
+func TestSearchResultFormat(t *testing.T) {
+	format := ResponseFormat{ScorePrecision: 2, EmbeddingPrecision: 1}
+	result := SearchResult{
+		Vector: &Vector{ID: "doc", Embedding: []float64{0.123, -0.987}},
+		Score:  0.98765,
+		Format: &format,
+	}
+
+	var decoded SearchResult
+	if err := json.Unmarshal(marshalResult(t, result), &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if decoded.Score != 0.99 {
+		t.Errorf("score = %v, want 0.99", decoded.Score)
+	}
+	if decoded.Vector.ID != "doc" || decoded.Vector.Embedding[0] != 0.1 || decoded.Vector.Embedding[1] != -1 {
+		t.Errorf("vector = %+v", decoded.Vector)
+	}
+
+	// Scores keep DefaultScorePrecision places unless asked otherwise
+	def := DefaultResponseFormat()
+	result.Format = &def
+	if data := string(marshalResult(t, result)); !strings.Contains(data, `"score":0.98765`) || !strings.Contains(data, "0.123") {
+		t.Errorf("default format = %s", data)
+	}
+
+	// Results without embeddings omit the field in every format
+	packed := ResponseFormat{EmbeddingFormat: EmbeddingFormatBase64}
+	result = SearchResult{Vector: &Vector{ID: "doc"}, Format: &packed}
+	if data := string(marshalResult(t, result)); strings.Contains(data, "embedding") {
+		t.Errorf("empty embedding serialized: %s", data)
+	}
+}
+
+func TestEmbeddingBase64RoundTrip(t *testing.T) {
+	embedding := testEmbedding(16)
+
+	decoded, err := DecodeEmbeddingBase64(EncodeEmbeddingBase64(embedding))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if len(decoded) != len(embedding) {
+		t.Fatalf("decoded %d components, want %d", len(decoded), len(embedding))
+	}
+	for i := range embedding {
+		if decoded[i] != float64(float32(embedding[i])) {
+			t.Errorf("component %d = %v, want %v", i, decoded[i], float32(embedding[i]))
+		}
+	}
+
+	if _, err := DecodeEmbeddingBase64("AAE="); err == nil {
+		t.Error("expected an error for a truncated embedding")
+	}
+}
+
+func TestResponseFormatWithParams(t *testing.T) {
+	precision := 3
+	format, err := DefaultResponseFormat().WithParams(SearchParams{Precision: &precision, EmbeddingFormat: EmbeddingFormatBase64})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if format.ScorePrecision != 3 || format.EmbeddingPrecision != 3 || format.EmbeddingFormat != EmbeddingFormatBase64 {
+		t.Errorf("format = %+v", format)
+	}
+
+	for _, params := range []SearchParams{
+		{Precision: func() *int { p := -1; return &p }()},
+		{Precision: func() *int { p := 16; return &p }()},
+		{EmbeddingFormat: "hex"},
+	} {
+		if _, err := DefaultResponseFormat().WithParams(params); err == nil {
+			t.Errorf("expected an error for %+v", params)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Lineage metadata stamped on every ingested vector
+const (
+	LineageSourceKey = "ingest.source" // Name of the source the record was read from
+	LineageRecordKey = "ingest.record" // Line, row or file number of the record in its source
+	LineageRunKey    = "ingest.run_id" // ID of the ingest run that stored the vector
+	LineageTimeKey   = "ingest.time"   // When the vector was ingested, RFC 3339
+	LineageFileKey   = "ingest.file"   // Path of the file the record was read from, in multi-file runs
+	EmbedderNameKey  = "embedder.name" // Embedder that produced the embedding
+)
+
+// IngestRun summarizes one ingest run
+type IngestRun struct {
+	ID             string         `json:"id"`
+	Source         string         `json:"source"`
+	Namespace      string         `json:"namespace,omitempty"`
+	Embedder       string         `json:"embedder,omitempty"`
+	StartTime      time.Time      `json:"start_time"`
+	EndTime        time.Time      `json:"end_time"`
+	Total          int            `json:"total"`
+	Stored         int            `json:"stored"`
+	Failed         int            `json:"failed"`
+	Skipped        int            `json:"skipped"`
+	FailureReasons map[string]int `json:"failure_reasons,omitempty"`
+}
+
+// IngestRunFilter selects ingest runs, zero fields match every run
+type IngestRunFilter struct {
+	Source    string
+	Namespace string
+	Since     *time.Time // Runs started at or after Since
+	Until     *time.Time // Runs started before Until
+}
+
+// Matches reports whether run passes the filter
+func (f IngestRunFilter) Matches(run *IngestRun) bool {
+	if f.Source != "" && run.Source != f.Source {
+		return false
+	}
+	if f.Namespace != "" && run.Namespace != f.Namespace {
+		return false
+	}
+	if f.Since != nil && run.StartTime.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && !run.StartTime.Before(*f.Until) {
+		return false
+	}
+	return true
+}
+
+// FilterIngestRuns returns the runs matching filter, newest first
+func FilterIngestRuns(runs []*IngestRun, filter IngestRunFilter) []*IngestRun {
+	matched := make([]*IngestRun, 0, len(runs))
+	for _, run := range runs {
+		if filter.Matches(run) {
+			matched = append(matched, run)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].StartTime.After(matched[j].StartTime) })
+	return matched
+}
+
+// EmbedderProvenance describes how the embedding of a vector was produced
+type EmbedderProvenance struct {
+	Name      string `json:"name,omitempty"`
+	Dimension int    `json:"dimension"`
+	DType     DType  `json:"dtype"`
+}
+
+// Provenance is the lineage of a vector
+// Vectors stored through the API rather than ingested have no source or run
+type Provenance struct {
+	ID         string             `json:"id"`
+	Source     string             `json:"source,omitempty"`
+	Record     int                `json:"record,omitempty"`
+	RunID      string             `json:"run_id,omitempty"`
+	IngestedAt *time.Time         `json:"ingested_at,omitempty"`
+	Embedder   EmbedderProvenance `json:"embedder"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+	Run        *IngestRun         `json:"run,omitempty"`
+}
+
+// ProvenanceOf reads the lineage metadata of a vector
+func ProvenanceOf(vector *Vector) *Provenance {
+	p := &Provenance{
+		ID:     vector.ID,
+		Source: vector.Metadata[LineageSourceKey],
+		RunID:  vector.Metadata[LineageRunKey],
+		Embedder: EmbedderProvenance{
+			Name:      vector.Metadata[EmbedderNameKey],
+			Dimension: vector.Dimension(),
+			DType:     vector.DType.OrDefault(),
+		},
+		CreatedAt: vector.CreatedAt,
+		UpdatedAt: vector.UpdatedAt,
+	}
+
+	if record, err := strconv.Atoi(vector.Metadata[LineageRecordKey]); err == nil {
+		p.Record = record
+	}
+	if ingested, err := time.Parse(time.RFC3339Nano, vector.Metadata[LineageTimeKey]); err == nil {
+		p.IngestedAt = &ingested
+	}
+
+	return p
+}
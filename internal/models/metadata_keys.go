@@ -0,0 +1,149 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// KeyMergePolicy decides what happens when several metadata keys of a vector
+// normalize to the same key, such as "Author" and "author"
+type KeyMergePolicy string
+
+const (
+	// KeyMergeNormalized keeps the value of the key already in normalized form,
+	// or else of the first key in sort order
+	KeyMergeNormalized KeyMergePolicy = "normalized"
+	// KeyMergeSkip leaves colliding keys with different values unchanged
+	KeyMergeSkip KeyMergePolicy = "skip"
+	// KeyMergeError fails on colliding keys with different values
+	KeyMergeError KeyMergePolicy = "error"
+)
+
+// Validate checks that the policy is supported, empty means KeyMergeNormalized
+func (p KeyMergePolicy) Validate() error {
+	switch p {
+	case "", KeyMergeNormalized, KeyMergeSkip, KeyMergeError:
+		return nil
+	default:
+		return fmt.Errorf("unsupported key merge policy %q: must be one of %s, %s, %s", p, KeyMergeNormalized, KeyMergeSkip, KeyMergeError)
+	}
+}
+
+// KeyCollision reports metadata keys of a vector that normalize to the same key
+type KeyCollision struct {
+	Key         string   `json:"key"`            // Normalized key
+	Keys        []string `json:"keys"`           // Original keys, sorted
+	Conflicting bool     `json:"conflicting"`    // The keys hold different values
+	Kept        string   `json:"kept,omitempty"` // Original key whose value was kept, empty when skipped
+}
+
+// NormalizeKey lowercases a metadata key and converts it to snake_case
+// "Author", "AUTHOR" and "author" all become "author", "createdAt" and
+// "Created At" become "created_at". Dots are kept so namespaced keys such
+// as "ingest.run_id" are unchanged
+func NormalizeKey(key string) string {
+	runes := []rune(strings.TrimSpace(key))
+	var b strings.Builder
+
+	separate := func() {
+		s := b.String()
+		if s != "" && !strings.HasSuffix(s, "_") && !strings.HasSuffix(s, ".") {
+			b.WriteByte('_')
+		}
+	}
+
+	for i, r := range runes {
+		switch {
+		case r == ' ' || r == '-' || r == '_':
+			separate()
+		case r == '.':
+			s := strings.TrimSuffix(b.String(), "_")
+			b.Reset()
+			b.WriteString(s)
+			b.WriteRune(r)
+		case unicode.IsUpper(r):
+			// Split camelCase and the end of an acronym, "userID" and "HTTPServer"
+			if i > 0 {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+					separate()
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// NormalizeMetadataKeys returns metadata with every key normalized, and the
+// keys that collided. The input map is not modified
+func NormalizeMetadataKeys[V any](metadata map[string]V, policy KeyMergePolicy) (map[string]V, []KeyCollision, error) {
+	if metadata == nil {
+		return nil, nil, nil
+	}
+
+	groups := make(map[string][]string, len(metadata))
+	for key := range metadata {
+		normalized := NormalizeKey(key)
+		groups[normalized] = append(groups[normalized], key)
+	}
+
+	normalized := make(map[string]V, len(groups))
+	var collisions []KeyCollision
+	for key, originals := range groups {
+		if len(originals) == 1 {
+			normalized[key] = metadata[originals[0]]
+			continue
+		}
+
+		sort.Strings(originals)
+		collision := KeyCollision{Key: key, Keys: originals}
+		first := fmt.Sprint(metadata[originals[0]])
+		for _, original := range originals[1:] {
+			if fmt.Sprint(metadata[original]) != first {
+				collision.Conflicting = true
+				break
+			}
+		}
+
+		if collision.Conflicting && policy == KeyMergeError {
+			return nil, nil, fmt.Errorf("metadata keys %s normalize to %q with different values", strings.Join(originals, ", "), key)
+		}
+		if collision.Conflicting && policy == KeyMergeSkip {
+			for _, original := range originals {
+				normalized[original] = metadata[original]
+			}
+			collisions = append(collisions, collision)
+			continue
+		}
+
+		collision.Kept = originals[0]
+		for _, original := range originals {
+			if original == key {
+				collision.Kept = original
+				break
+			}
+		}
+		normalized[key] = metadata[collision.Kept]
+		collisions = append(collisions, collision)
+	}
+
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Key < collisions[j].Key })
+	return normalized, collisions, nil
+}
+
+// MetadataKeysNormalized reports whether every key of metadata is already normalized
+func MetadataKeysNormalized[V any](metadata map[string]V) bool {
+	for key := range metadata {
+		if NormalizeKey(key) != key {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"author", "author"},
+		{"Author", "author"},
+		{"AUTHOR", "author"},
+		{"createdAt", "created_at"},
+		{"Created At", "created_at"},
+		{"created-at", "created_at"},
+		{"created__at", "created_at"},
+		{"userID", "user_id"},
+		{"HTTPServer", "http_server"},
+		{"page2Count", "page2_count"},
+		{"ingest.run_id", "ingest.run_id"},
+		{"Ingest.RunID", "ingest.run_id"},
+		{" author ", "author"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := NormalizeKey(tt.key); got != tt.want {
+				t.Errorf("NormalizeKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeMetadataKeys(t *testing.T) {
+	metadata := map[string]string{
+		"Author":    "Einstein",
+		"author":    "Albert Einstein",
+		"createdAt": "1905",
+		"Genre":     "physics",
+		"GENRE":     "physics",
+	}
+
+	tests := []struct {
+		name       string
+		policy     KeyMergePolicy
+		want       map[string]string
+		collisions []KeyCollision
+		wantErr    bool
+	}{
+		{
+			name:    "error",
+			policy:  KeyMergeError,
+			wantErr: true,
+		},
+		{
+			name:   "normalized keeps the normalized key",
+			policy: KeyMergeNormalized,
+			want:   map[string]string{"author": "Albert Einstein", "created_at": "1905", "genre": "physics"},
+			collisions: []KeyCollision{
+				{Key: "author", Keys: []string{"Author", "author"}, Conflicting: true, Kept: "author"},
+				{Key: "genre", Keys: []string{"GENRE", "Genre"}, Kept: "GENRE"},
+			},
+		},
+		{
+			name:   "skip leaves conflicts",
+			policy: KeyMergeSkip,
+			want:   map[string]string{"Author": "Einstein", "author": "Albert Einstein", "created_at": "1905", "genre": "physics"},
+			collisions: []KeyCollision{
+				{Key: "author", Keys: []string{"Author", "author"}, Conflicting: true},
+				{Key: "genre", Keys: []string{"GENRE", "Genre"}, Kept: "GENRE"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, collisions, err := NormalizeMetadataKeys(metadata, tt.policy)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error for conflicting keys")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("metadata = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(collisions, tt.collisions) {
+				t.Errorf("collisions = %+v, want %+v", collisions, tt.collisions)
+			}
+		})
+	}
+
+	if _, ok := metadata["created_at"]; ok {
+		t.Error("input metadata was modified")
+	}
+}
+
+func TestFilterEvaluator_KeyFallback(t *testing.T) {
+	metadata := map[string]string{"Author": "Einstein", "category": "physics"}
+
+	tests := []struct {
+		name     string
+		fallback bool
+		filters  map[string]FilterExpr
+		expected bool
+	}{
+		{"exact key", false, map[string]FilterExpr{"category": {"eq": "physics"}}, true},
+		{"case differs without fallback", false, map[string]FilterExpr{"author": {"eq": "Einstein"}}, false},
+		{"case differs with fallback", true, map[string]FilterExpr{"author": {"eq": "Einstein"}}, true},
+		{"fallback value mismatch", true, map[string]FilterExpr{"author": {"eq": "Newton"}}, false},
+		{"fallback with no variant", true, map[string]FilterExpr{"title": {"exists": false}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe := &FilterEvaluator{KeyFallback: tt.fallback}
+			compiled, err := fe.Compile(tt.filters)
+			if err != nil {
+				t.Fatalf("compile failed: %v", err)
+			}
+			if got := fe.Matches(metadata, compiled); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	compiled, _ := NewFilterEvaluator().Compile(map[string]FilterExpr{"author": {"eq": "Einstein"}, "category": {"eq": "physics"}})
+	want := map[string][]string{"author": {"Author"}}
+	if got := compiled.KeyFallbacks(metadata); !reflect.DeepEqual(got, want) {
+		t.Errorf("KeyFallbacks = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeAnchors are the starting points of a relative time expression, computed
+// from the anchor in its location. Weeks start on Monday
+var timeAnchors = map[string]func(time.Time) time.Time{
+	"now": func(t time.Time) time.Time { return t },
+	"start_of_day": func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	},
+	"start_of_week": func(t time.Time) time.Time {
+		days := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, t.Location())
+	},
+	"start_of_month": func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	},
+	"start_of_year": func(t time.Time) time.Time {
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	},
+}
+
+// timeUnits are the units of the offsets of a relative time expression
+// m is minutes and M months; days, months and years follow the calendar
+var timeUnits = map[byte]func(t time.Time, n int) time.Time{
+	's': func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Second) },
+	'm': func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Minute) },
+	'h': func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Hour) },
+	'd': func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) },
+	'w': func(t time.Time, n int) time.Time { return t.AddDate(0, 0, 7*n) },
+	'M': func(t time.Time, n int) time.Time { return t.AddDate(0, n, 0) },
+	'y': func(t time.Time, n int) time.Time { return t.AddDate(n, 0, 0) },
+}
+
+// ParseTimeExpr parses a time given either as an absolute time, in a layout of
+// ParseTime, or relative to anchor: "now", "start_of_day", "start_of_week",
+// "start_of_month" or "start_of_year", followed by any number of offsets such as
+// "-30d" or "+6h" in the units s, m, h, d, w, M (months) and y. Spaces around
+// the offsets are ignored, so "now - 1M + 2d" is accepted
+func ParseTimeExpr(expr string, anchor time.Time) (time.Time, error) {
+	rest := strings.TrimSpace(expr)
+
+	var base func(time.Time) time.Time
+	for name, fn := range timeAnchors {
+		if strings.HasPrefix(rest, name) && (len(rest) == len(name) || !isTimeNameChar(rest[len(name)])) {
+			base = fn
+			rest = rest[len(name):]
+			break
+		}
+	}
+	if base == nil {
+		t, err := ParseTime(rest)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time expression %q: expected a time or now, start_of_day, start_of_week, start_of_month or start_of_year with offsets such as -30d", expr)
+		}
+		return t, nil
+	}
+
+	t := base(anchor)
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		sign := 1
+		switch rest[0] {
+		case '+':
+		case '-':
+			sign = -1
+		default:
+			return time.Time{}, fmt.Errorf("invalid time expression %q: expected + or - before %q", expr, rest)
+		}
+		rest = strings.TrimSpace(rest[1:])
+
+		digits := 0
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 {
+			return time.Time{}, fmt.Errorf("invalid time expression %q: expected a number after the sign", expr)
+		}
+		n, err := strconv.Atoi(rest[:digits])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time expression %q: %w", expr, err)
+		}
+		rest = strings.TrimSpace(rest[digits:])
+
+		if rest == "" {
+			return time.Time{}, fmt.Errorf("invalid time expression %q: missing the unit of %d (must be: s, m, h, d, w, M, y)", expr, n)
+		}
+		add, ok := timeUnits[rest[0]]
+		if !ok || (len(rest) > 1 && isTimeNameChar(rest[1])) {
+			return time.Time{}, fmt.Errorf("invalid time expression %q: unknown unit in %q (must be: s, m, h, d, w, M, y)", expr, rest)
+		}
+		t = add(t, sign*n)
+		rest = rest[1:]
+	}
+	return t, nil
+}
+
+// isTimeNameChar reports whether c continues an anchor name or unit, so
+// "nowhere" is not read as "now"
+func isTimeNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// TimeExpr is a time of a request, given as an absolute time or as an expression
+// relative to the request time, see ParseTimeExpr. Relative expressions are
+// resolved with Resolve, absolute times when decoded
+type TimeExpr struct {
+	Expr string    // As given
+	Time time.Time // Zero until a relative expression is resolved
+}
+
+// NewTimeExpr returns the expression of an absolute time
+func NewTimeExpr(t time.Time) *TimeExpr {
+	return &TimeExpr{Expr: t.Format(time.RFC3339Nano), Time: t}
+}
+
+// Resolve sets the time of the expression relative to anchor
+func (e *TimeExpr) Resolve(anchor time.Time) error {
+	t, err := ParseTimeExpr(e.Expr, anchor)
+	if err != nil {
+		return err
+	}
+	e.Time = t
+	return nil
+}
+
+// UnmarshalJSON decodes a string expression, resolving it when it is an absolute time
+func (e *TimeExpr) UnmarshalJSON(data []byte) error {
+	var expr string
+	if err := json.Unmarshal(data, &expr); err != nil {
+		return fmt.Errorf("a time must be a string such as \"2024-03-01T00:00:00Z\" or \"now-30d\"")
+	}
+	e.Expr = expr
+	e.Time = time.Time{}
+	if t, err := ParseTime(strings.TrimSpace(expr)); err == nil {
+		e.Time = t
+	}
+	return nil
+}
+
+// MarshalJSON encodes the resolved time, or the expression while unresolved
+func (e TimeExpr) MarshalJSON() ([]byte, error) {
+	if e.Time.IsZero() {
+		return json.Marshal(e.Expr)
+	}
+	return json.Marshal(e.Time.Format(time.RFC3339Nano))
+}
+
+// ResolveTimes returns the filters with the relative bounds of their before and
+// after operators resolved against anchor, so the filters give the same results
+// whenever they are evaluated
+func (f Filters) ResolveTimes(anchor time.Time) (Filters, error) {
+	if f == nil {
+		return nil, nil
+	}
+	resolved := make(Filters, len(f))
+	for field, expr := range f {
+		copied := make(FilterExpr, len(expr))
+		for op, value := range expr {
+			if (op == "before" || op == "after") && isString(value) {
+				t, err := ParseTimeExpr(value.(string), anchor)
+				if err != nil {
+					return nil, fmt.Errorf("filter %q on field %s: %w", op, field, err)
+				}
+				value = t.Format(time.RFC3339Nano)
+			}
+			copied[op] = value
+		}
+		resolved[field] = copied
+	}
+	return resolved, nil
+}
+
+func isString(value interface{}) bool {
+	_, ok := value.(string)
+	return ok
+}
+
+// anchor returns the time relative filter bounds are resolved against
+func (fe *FilterEvaluator) anchor() time.Time {
+	if fe.Anchor.IsZero() {
+		return time.Now()
+	}
+	return fe.Anchor
+}
+
+// resolveTimeBounds replaces the expression bounds of the before and after operators
+// of expr, a copy owned by the compiled filters, by the times they resolve to
+func (fe *FilterEvaluator) resolveTimeBounds(field string, expr FilterExpr) error {
+	for _, op := range []string{"before", "after"} {
+		bound, ok := expr[op].(string)
+		if !ok {
+			continue
+		}
+		t, err := ParseTimeExpr(bound, fe.anchor())
+		if err != nil {
+			return fmt.Errorf("filter %q on field %s: %w", op, field, err)
+		}
+		expr[op] = t
+	}
+	return nil
+}
+
+// compareTime reports whether the time in value is before, or after, the bound
+// Values that are not times never match
+func (fe *FilterEvaluator) compareTime(value string, bound interface{}, before bool) bool {
+	t, err := ParseTime(strings.TrimSpace(value))
+	if err != nil {
+		return false
+	}
+	var limit time.Time
+	switch b := bound.(type) {
+	case time.Time:
+		limit = b
+	case string:
+		if limit, err = ParseTimeExpr(b, fe.anchor()); err != nil {
+			return false
+		}
+	default:
+		return false
+	}
+	if before {
+		return t.Before(limit)
+	}
+	return t.After(limit)
+}
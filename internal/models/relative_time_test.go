@@ -0,0 +1,148 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// anchorTime is a Wednesday afternoon
+var anchorTime = time.Date(2024, time.March, 13, 15, 30, 45, 0, time.UTC)
+
+func TestParseTimeExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"now", anchorTime},
+		{"now-30s", anchorTime.Add(-30 * time.Second)},
+		{"now-15m", anchorTime.Add(-15 * time.Minute)},
+		{"now-6h", anchorTime.Add(-6 * time.Hour)},
+		{"now-30d", time.Date(2024, time.February, 12, 15, 30, 45, 0, time.UTC)},
+		{"now+1w", time.Date(2024, time.March, 20, 15, 30, 45, 0, time.UTC)},
+		{"now-1M", time.Date(2024, time.February, 13, 15, 30, 45, 0, time.UTC)},
+		{"now-2y", time.Date(2022, time.March, 13, 15, 30, 45, 0, time.UTC)},
+		{"now-1d+6h", anchorTime.Add(-18 * time.Hour)},
+		{"start_of_day", time.Date(2024, time.March, 13, 0, 0, 0, 0, time.UTC)},
+		{"start_of_week", time.Date(2024, time.March, 11, 0, 0, 0, 0, time.UTC)},
+		{"start_of_month", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"start_of_month-1M", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"start_of_year", time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+
+		// Whitespace around the anchor and the offsets is ignored
+		{"  now  ", anchorTime},
+		{"now - 6h", anchorTime.Add(-6 * time.Hour)},
+		{" now -\t1d + 2 h ", anchorTime.Add(-22 * time.Hour)},
+
+		// Absolute times ignore the anchor
+		{"2024-01-02T03:04:05Z", time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)},
+		{" 2023-06-01 ", time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeExpr(tt.expr, anchorTime)
+		if err != nil {
+			t.Errorf("ParseTimeExpr(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTimeExpr(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimeExpr_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "yesterday", "nowhere", "now-", "now-30", "now-30x", "now-3days", "now 30d", "now--1d", "start_of_decade"} {
+		_, err := ParseTimeExpr(expr, anchorTime)
+		if err == nil {
+			t.Errorf("ParseTimeExpr(%q) accepted", expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), `"`+expr+`"`) {
+			t.Errorf("ParseTimeExpr(%q) error = %v, want the expression named", expr, err)
+		}
+	}
+}
+
+func TestTimeExpr_JSON(t *testing.T) {
+	var req struct {
+		Relative *TimeExpr `json:"relative"`
+		Absolute *TimeExpr `json:"absolute"`
+	}
+	if err := json.Unmarshal([]byte(`{"relative": "now-1d", "absolute": "2024-01-02T00:00:00Z"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if !req.Relative.Time.IsZero() || req.Absolute.Time.IsZero() {
+		t.Fatalf("decoded %+v and %+v, want only the absolute time resolved", req.Relative, req.Absolute)
+	}
+
+	if err := req.Relative.Resolve(anchorTime); err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(req.Relative)
+	if string(encoded) != `"2024-03-12T15:30:45Z"` {
+		t.Errorf("resolved expression encoded as %s", encoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"relative": 42}`), &req); err == nil {
+		t.Error("a number was accepted as a time")
+	}
+}
+
+func TestFilters_ResolveTimes(t *testing.T) {
+	filters := Filters{
+		"created_at": {"after": "now-7d", "before": " now "},
+		"year":       {"gte": 2000.0},
+	}
+	resolved, err := filters.ResolveTimes(anchorTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved["created_at"]["after"] != "2024-03-06T15:30:45Z" || resolved["created_at"]["before"] != "2024-03-13T15:30:45Z" {
+		t.Errorf("resolved = %v", resolved["created_at"])
+	}
+	if resolved["year"]["gte"] != 2000.0 {
+		t.Errorf("other operators changed: %v", resolved["year"])
+	}
+	if filters["created_at"]["after"] != "now-7d" {
+		t.Error("ResolveTimes modified the original filters")
+	}
+
+	if _, err := (Filters{"created_at": {"after": "now-7x"}}).ResolveTimes(anchorTime); err == nil || !strings.Contains(err.Error(), `"now-7x"`) {
+		t.Errorf("ResolveTimes() error = %v, want the bad expression named", err)
+	}
+}
+
+func TestFilterEvaluator_BeforeAfter(t *testing.T) {
+	fe := &FilterEvaluator{Anchor: anchorTime}
+	compiled, err := fe.Compile(Filters{"published": {"after": "now-30d", "before": "start_of_day"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		published string
+		want      bool
+	}{
+		{"2024-03-01T12:00:00Z", true},
+		{"2024-03-01", true},
+		{"2024-02-01T00:00:00Z", false}, // Older than 30 days
+		{"2024-03-13T09:00:00Z", false}, // Today
+		{"not a date", false},
+	}
+	for _, tt := range tests {
+		if got := fe.Matches(map[string]string{"published": tt.published}, compiled); got != tt.want {
+			t.Errorf("published %s matched = %v, want %v", tt.published, got, tt.want)
+		}
+	}
+	if fe.Matches(map[string]string{}, compiled) {
+		t.Error("a vector without the field matched")
+	}
+
+	if _, err := fe.Compile(Filters{"published": {"after": 30.0}}); err == nil {
+		t.Error("a number was accepted as a time bound")
+	}
+	if _, err := fe.Compile(Filters{"published": {"before": "last week"}}); err == nil || !strings.Contains(err.Error(), `"last week"`) {
+		t.Errorf("Compile() error = %v, want the bad expression named", err)
+	}
+}
@@ -0,0 +1,423 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ScoreExpr is a compiled score expression, a score adjuster computing the new
+// score of a result from its score and metadata
+//
+// Expressions combine numbers, the variable score and the functions below with
+// + - * / and parentheses. Metadata values are read as metadata.field, or
+// metadata["field"] for keys that are not identifiers, and are text, so they
+// must be converted with num or days:
+//
+//	num(text)          the number in text, NaN when missing or not a number
+//	num(text, default) the number in text, default when missing or not a number
+//	days(text)         days from the time in text to the time of the search
+//	log(x) exp(x) sqrt(x) abs(x) min(x, y, ...) max(x, y, ...)
+//
+// An expression evaluating to NaN or an infinity leaves the score unchanged
+type ScoreExpr struct {
+	source string
+	eval   numberNode
+}
+
+// exprEnv is what an expression is evaluated against
+type exprEnv struct {
+	score  float64
+	vector *Vector
+	ctx    *ScoreContext
+}
+
+type numberNode func(env *exprEnv) float64
+
+// textNode returns a metadata value and whether it is set
+type textNode func(env *exprEnv) (string, bool)
+
+// CompileScoreExpr parses a score expression
+// Every syntax, unknown name and argument error is reported here, so evaluating
+// a compiled expression cannot fail
+func CompileScoreExpr(source string) (*ScoreExpr, error) {
+	p := &exprParser{source: source}
+	if err := p.tokenize(); err != nil {
+		return nil, fmt.Errorf("invalid score_expr: %w", err)
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("invalid score_expr: expression is empty")
+	}
+	node, err := p.parseNumber()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q at offset %d", p.tokens[p.pos].text, p.tokens[p.pos].offset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid score_expr: %w", err)
+	}
+	return &ScoreExpr{source: source, eval: node}, nil
+}
+
+// String returns the source of the expression
+func (e *ScoreExpr) String() string {
+	return e.source
+}
+
+// AdjustScore evaluates the expression for vector
+func (e *ScoreExpr) AdjustScore(score float64, vector *Vector, ctx *ScoreContext) float64 {
+	return e.eval(&exprEnv{score: score, vector: vector, ctx: ctx})
+}
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenSymbol
+)
+
+type exprToken struct {
+	kind   tokenKind
+	text   string
+	number float64
+	offset int
+}
+
+type exprParser struct {
+	source string
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) tokenize() error {
+	src := p.source
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			// Exponents, as in 1e-3
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && src[j] >= '0' && src[j] <= '9' {
+					for i = j; i < len(src) && src[i] >= '0' && src[i] <= '9'; i++ {
+					}
+				}
+			}
+			value, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return fmt.Errorf("invalid number %q at offset %d", src[start:i], start)
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokenNumber, text: src[start:i], number: value, offset: start})
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokenIdent, text: src[start:i], offset: start})
+		case c == '"' || c == '\'':
+			start := i
+			end := strings.IndexByte(src[i+1:], src[i])
+			if end < 0 {
+				return fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i += end + 2
+			p.tokens = append(p.tokens, exprToken{kind: tokenString, text: src[start+1 : i-1], offset: start})
+		case strings.ContainsRune("+-*/(),.[]", c):
+			p.tokens = append(p.tokens, exprToken{kind: tokenSymbol, text: string(c), offset: i})
+			i++
+		default:
+			return fmt.Errorf("unexpected character %q at offset %d", c, i)
+		}
+	}
+	return nil
+}
+
+// peek returns the next token, nil at the end of the expression
+func (p *exprParser) peek() *exprToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// accept consumes the next token if it is the symbol s
+func (p *exprParser) accept(s string) bool {
+	if t := p.peek(); t != nil && t.kind == tokenSymbol && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(s string) error {
+	if p.accept(s) {
+		return nil
+	}
+	if t := p.peek(); t != nil {
+		return fmt.Errorf("expected %q at offset %d, found %q", s, t.offset, t.text)
+	}
+	return fmt.Errorf("expected %q at the end of the expression", s)
+}
+
+// parseNumber parses a sum, the lowest precedence level
+func (p *exprParser) parseNumber() (numberNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("+"):
+			right, err := p.parseProduct()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(env *exprEnv) float64 { return l(env) + right(env) }
+		case p.accept("-"):
+			right, err := p.parseProduct()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(env *exprEnv) float64 { return l(env) - right(env) }
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *exprParser) parseProduct() (numberNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("*"):
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(env *exprEnv) float64 { return l(env) * right(env) }
+		case p.accept("/"):
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			l := left
+			left = func(env *exprEnv) float64 { return l(env) / right(env) }
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *exprParser) parseUnary() (numberNode, error) {
+	if p.accept("-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) float64 { return -operand(env) }, nil
+	}
+	if p.accept("+") {
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (numberNode, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of the expression")
+	}
+
+	switch {
+	case t.kind == tokenNumber:
+		p.pos++
+		value := t.number
+		return func(*exprEnv) float64 { return value }, nil
+	case t.kind == tokenSymbol && t.text == "(":
+		p.pos++
+		node, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+	case t.kind == tokenIdent && t.text == "score":
+		p.pos++
+		return func(env *exprEnv) float64 { return env.score }, nil
+	case t.kind == tokenIdent && t.text == "metadata":
+		return nil, fmt.Errorf("metadata values at offset %d are text, convert them with num() or days()", t.offset)
+	case t.kind == tokenIdent:
+		p.pos++
+		if !p.accept("(") {
+			return nil, fmt.Errorf("unknown name %q at offset %d", t.text, t.offset)
+		}
+		return p.parseCall(t)
+	default:
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.offset)
+	}
+}
+
+// parseCall parses the arguments of the function named by t, after its opening parenthesis
+func (p *exprParser) parseCall(t *exprToken) (numberNode, error) {
+	switch t.text {
+	case "num":
+		text, err := p.parseMetadata()
+		if err != nil {
+			return nil, err
+		}
+		fallback := numberNode(func(*exprEnv) float64 { return math.NaN() })
+		if p.accept(",") {
+			if fallback, err = p.parseNumber(); err != nil {
+				return nil, err
+			}
+		}
+		return func(env *exprEnv) float64 {
+			if value, ok := text(env); ok {
+				if number, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					return number
+				}
+			}
+			return fallback(env)
+		}, p.expect(")")
+	case "days":
+		text, err := p.parseMetadata()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) float64 {
+			value, ok := text(env)
+			if !ok {
+				return math.NaN()
+			}
+			t, err := ParseTime(strings.TrimSpace(value))
+			if err != nil {
+				return math.NaN()
+			}
+			return env.now().Sub(t).Hours() / 24
+		}, p.expect(")")
+	}
+
+	fn, ok := exprFuncs[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at offset %d", t.text, t.offset)
+	}
+	var args []numberNode
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		return nil, fmt.Errorf("wrong number of arguments to %s at offset %d", t.text, t.offset)
+	}
+	return func(env *exprEnv) float64 {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg(env)
+		}
+		return fn.call(values)
+	}, nil
+}
+
+// parseMetadata parses a metadata.field or metadata["field"] reference
+func (p *exprParser) parseMetadata() (textNode, error) {
+	t := p.peek()
+	if t == nil || t.kind != tokenIdent || t.text != "metadata" {
+		if t == nil {
+			return nil, fmt.Errorf("expected a metadata field at the end of the expression")
+		}
+		return nil, fmt.Errorf("expected a metadata field at offset %d, found %q", t.offset, t.text)
+	}
+	p.pos++
+
+	var field string
+	switch {
+	case p.accept("."):
+		name := p.peek()
+		if name == nil || name.kind != tokenIdent {
+			return nil, fmt.Errorf("expected a field name after metadata. at offset %d", t.offset)
+		}
+		p.pos++
+		field = name.text
+	case p.accept("["):
+		name := p.peek()
+		if name == nil || name.kind != tokenString {
+			return nil, fmt.Errorf("expected a quoted field name after metadata[ at offset %d", t.offset)
+		}
+		p.pos++
+		field = name.text
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("expected metadata.field or metadata[\"field\"] at offset %d", t.offset)
+	}
+
+	return func(env *exprEnv) (string, bool) {
+		if env.vector == nil {
+			return "", false
+		}
+		value, ok := env.vector.Metadata[field]
+		return value, ok
+	}, nil
+}
+
+// now is the time days() measures from
+func (env *exprEnv) now() time.Time {
+	if env.ctx != nil && !env.ctx.Time.IsZero() {
+		return env.ctx.Time
+	}
+	return time.Now()
+}
+
+// exprFunc is a numeric function of score expressions, maxArgs is -1 for any number
+type exprFunc struct {
+	minArgs, maxArgs int
+	call             func(args []float64) float64
+}
+
+var exprFuncs = map[string]exprFunc{
+	"log":  {1, 1, func(args []float64) float64 { return math.Log(args[0]) }},
+	"exp":  {1, 1, func(args []float64) float64 { return math.Exp(args[0]) }},
+	"sqrt": {1, 1, func(args []float64) float64 { return math.Sqrt(args[0]) }},
+	"abs":  {1, 1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"min": {1, -1, func(args []float64) float64 {
+		m := args[0]
+		for _, arg := range args[1:] {
+			m = math.Min(m, arg)
+		}
+		return m
+	}},
+	"max": {1, -1, func(args []float64) float64 {
+		m := args[0]
+		for _, arg := range args[1:] {
+			m = math.Max(m, arg)
+		}
+		return m
+	}},
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCompileScoreExpr(t *testing.T) {
+	now := time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC)
+	vector := &Vector{ID: "v", Metadata: map[string]string{
+		"popularity":   "9",
+		"published_at": "2026-01-01",
+		"page views":   " 3 ",
+		"title":        "not a number",
+	}}
+	ctx := &ScoreContext{Time: now}
+
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"score", 0.5},
+		{"score * 2 + 1", 2},
+		{"(score + 1) * 2", 3},
+		{"-score - -1", 0.5},
+		{"1e1 / 4", 2.5},
+		{"score * (1 + 0.1*log(1+num(metadata.popularity)))", 0.5 * (1 + 0.1*math.Log(10))},
+		{`num(metadata["page views"])`, 3},
+		{"num(metadata.missing, 0.25)", 0.25},
+		{"num(metadata.title, score)", 0.5},
+		{"days(metadata.published_at)", 10},
+		{"min(score, 1 / days(metadata.published_at))", 0.1},
+		{"max(1, 2, 3) + abs(-1) + sqrt(4) + exp(0)", 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := CompileScoreExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileScoreExpr() error = %v", err)
+			}
+			if got := expr.AdjustScore(0.5, vector, ctx); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("AdjustScore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileScoreExpr_MissingValues(t *testing.T) {
+	for _, source := range []string{"num(metadata.missing)", "score * num(metadata.title)", "days(metadata.title)"} {
+		expr, err := CompileScoreExpr(source)
+		if err != nil {
+			t.Fatalf("%s: error = %v", source, err)
+		}
+		if got := expr.AdjustScore(0.5, &Vector{Metadata: map[string]string{"title": "x"}}, nil); !math.IsNaN(got) {
+			t.Errorf("%s = %v, want NaN", source, got)
+		}
+	}
+}
+
+func TestCompileScoreExpr_Errors(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"score *",
+		"score +* 2",
+		"(score",
+		"score)",
+		"popularity",
+		"metadata.popularity * 2",
+		"num(score)",
+		"num(metadata)",
+		"num(metadata[popularity])",
+		"pow(score, 2)",
+		"log()",
+		"log(1, 2)",
+		"score # 2",
+		`num(metadata["popularity)`,
+		"1.2.3",
+	} {
+		if _, err := CompileScoreExpr(source); err == nil {
+			t.Errorf("CompileScoreExpr(%q) expected an error", source)
+		}
+	}
+}
+
+func TestScoring_Adjust(t *testing.T) {
+	double := ScoreAdjusterFunc(func(score float64, _ *Vector, _ *ScoreContext) float64 { return score * 2 })
+	broken := ScoreAdjusterFunc(func(float64, *Vector, *ScoreContext) float64 { return math.Inf(1) })
+
+	var none *Scoring
+	if score, explanation := none.Adjust(0.5, &Vector{}); score != 0.5 || explanation != nil {
+		t.Errorf("nil scoring: score = %v, explanation = %+v", score, explanation)
+	}
+
+	s := &Scoring{Adjusters: []NamedAdjuster{{"double", double}, {"broken", broken}, {"again", double}}, Explain: true}
+	score, explanation := s.Adjust(0.25, &Vector{})
+	if score != 1 {
+		t.Errorf("score = %v, want 1", score)
+	}
+	if explanation == nil || explanation.BaseScore != 0.25 || explanation.Score != 1 || len(explanation.Adjustments) != 3 ||
+		explanation.Adjustments[1] != (ScoreAdjustment{Adjuster: "broken", Score: 0.5}) {
+		t.Errorf("explanation = %+v", explanation)
+	}
+}
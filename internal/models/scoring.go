@@ -0,0 +1,88 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// ScoreContext describes the search a result is scored for
+type ScoreContext struct {
+	Query     string    // Query text, empty for embedding searches
+	Namespace string    // Empty when the search spans every namespace
+	Metric    string    // Empty for the storage default
+	Time      time.Time // Time of the search, the reference time of temporal searches
+}
+
+// ScoreAdjuster rewrites the score of a search result after its base similarity,
+// hybrid weighting and temporal decay, before results are ranked and cut to top_k
+// Results are ranked by the adjusted score, so adjusters of the euclidean metric
+// must keep lower scores better. Large collections are scored by several goroutines
+// at once, so adjusters must be safe for concurrent use
+type ScoreAdjuster interface {
+	AdjustScore(score float64, vector *Vector, ctx *ScoreContext) float64
+}
+
+// ScoreAdjusterFunc adapts a function to ScoreAdjuster
+type ScoreAdjusterFunc func(score float64, vector *Vector, ctx *ScoreContext) float64
+
+func (f ScoreAdjusterFunc) AdjustScore(score float64, vector *Vector, ctx *ScoreContext) float64 {
+	return f(score, vector, ctx)
+}
+
+// NamedAdjuster is a score adjuster with the name it is reported under in score explanations
+type NamedAdjuster struct {
+	Name     string
+	Adjuster ScoreAdjuster
+}
+
+// ScoreExplanation shows how the score of a result was adjusted
+type ScoreExplanation struct {
+	BaseScore   float64           `json:"base_score"` // Score before any adjuster
+	Adjustments []ScoreAdjustment `json:"adjustments,omitempty"`
+	Score       float64           `json:"score"`
+
+	// SoftFilters are the soft filters the result satisfied and missed, already
+	// weighed into the base score
+	SoftFilters *SoftFilterMatch `json:"soft_filters,omitempty"`
+}
+
+// ScoreAdjustment is the score of a result after one adjuster
+type ScoreAdjustment struct {
+	Adjuster string  `json:"adjuster"`
+	Score    float64 `json:"score"`
+}
+
+// Scoring is the chain of score adjusters of a search, applied in order
+// A nil Scoring leaves scores unchanged
+type Scoring struct {
+	Adjusters []NamedAdjuster
+	Context   ScoreContext
+	Explain   bool // Explain the score of each result
+}
+
+// Adjust applies the adjusters to the score of vector, returning the adjusted
+// score and its explanation when requested
+// An adjuster returning NaN or an infinity is ignored, so one missing value
+// cannot sink or float a result
+func (s *Scoring) Adjust(score float64, vector *Vector) (float64, *ScoreExplanation) {
+	if s == nil {
+		return score, nil
+	}
+
+	var explanation *ScoreExplanation
+	if s.Explain {
+		explanation = &ScoreExplanation{BaseScore: score}
+	}
+	for _, a := range s.Adjusters {
+		if adjusted := a.Adjuster.AdjustScore(score, vector, &s.Context); !math.IsNaN(adjusted) && !math.IsInf(adjusted, 0) {
+			score = adjusted
+		}
+		if explanation != nil {
+			explanation.Adjustments = append(explanation.Adjustments, ScoreAdjustment{Adjuster: a.Name, Score: score})
+		}
+	}
+	if explanation != nil {
+		explanation.Score = score
+	}
+	return score, explanation
+}
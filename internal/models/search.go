@@ -1,30 +1,322 @@
 package models
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type SearchResult struct {
-	Vector *Vector `json:"vector"`
-	Score  float64 `json:"score"`
+	Vector     *Vector  `json:"vector"`
+	Score      float64  `json:"score"`
+	Highlights []string `json:"highlights,omitempty"`
+
+	Explanation *ScoreExplanation `json:"explanation,omitempty"` // How score adjusters changed the score, with explain
+	Enrichment  json.RawMessage   `json:"enrichment,omitempty"`  // Enrichment document of the result, with enrich_by
+
+	Format *ResponseFormat `json:"-"` // Serialization format of the score and embedding, unformatted when nil
+}
+
+// HighlightOptions configures snippet highlighting of matched query terms
+type HighlightOptions struct {
+	Field        string `json:"field,omitempty"`         // Metadata field to highlight, defaults to "text"
+	FragmentSize int    `json:"fragment_size,omitempty"` // Approximate fragment length in bytes
+	Fragments    int    `json:"fragments,omitempty"`     // Maximum number of fragments per result
+	PreTag       string `json:"pre_tag,omitempty"`
+	PostTag      string `json:"post_tag,omitempty"`
+}
+
+// WithDefaults returns a copy of the options with unset values defaulted
+func (ho *HighlightOptions) WithDefaults() HighlightOptions {
+	opts := HighlightOptions{}
+	if ho != nil {
+		opts = *ho
+	}
+	if opts.Field == "" {
+		opts.Field = "text"
+	}
+	if opts.FragmentSize <= 0 {
+		opts.FragmentSize = 100
+	}
+	if opts.Fragments <= 0 {
+		opts.Fragments = 3
+	}
+	if opts.PreTag == "" && opts.PostTag == "" {
+		opts.PreTag = "<em>"
+		opts.PostTag = "</em>"
+	}
+	return opts
+}
+
+// SearchParams holds the options accepted by every search endpoint
+type SearchParams struct {
+	MinScore        *float64 `json:"min_score,omitempty"`        // Drop results scoring below this
+	ReturnEmbedding *bool    `json:"return_embedding,omitempty"` // Include stored embeddings in results
+	Precision       *int     `json:"precision,omitempty"`        // Decimal places of scores and embedding components
+	EmbeddingFormat string   `json:"embedding_format,omitempty"` // "array" or "base64" packed float32
+
+	// MetadataFields lists the metadata keys returned with each result
+	// Omitted or containing "*" returns all metadata, [] returns none
+	MetadataFields []string `json:"metadata_fields"`
+
+	// KeyFallback lets a filter on a metadata key also match keys that differ only
+	// in case or separators, such as "Author" for "author". Defaults to the server setting
+	KeyFallback *bool `json:"key_fallback,omitempty"`
+
+	// Metric scores results with cosine, dot or euclidean instead of the storage default
+	Metric string `json:"metric,omitempty"`
+
+	// Profile names a ranking profile supplying defaults for the fields left unset
+	Profile string `json:"profile,omitempty"`
+
+	// MetadataFilters is the legacy list form of the request filters, combined
+	// with them by FoldMetadataFilters when the request is decoded
+	MetadataFilters []MetadataFilter `json:"metadata_filters,omitempty"`
+
+	// SaveResults keeps the IDs of the results on the server, returned as result_set_id,
+	// so a later search can be restricted to them with WithinResults
+	SaveResults   bool   `json:"save_results,omitempty"`
+	WithinResults string `json:"within_results,omitempty"` // Search only the results of a saved result set
+
+	// ScoreExpr rewrites the score of each result before results are ranked, see ScoreExpr
+	ScoreExpr string `json:"score_expr,omitempty"`
+	Explain   bool   `json:"explain,omitempty"` // Report how score adjusters changed the score of each result
+
+	// EnrichBy names the metadata field whose value keys the enrichment document
+	// embedded in each result
+	EnrichBy string `json:"enrich_by,omitempty"`
+
+	// Snapshot runs the search against a named snapshot instead of the live data
+	Snapshot string `json:"snapshot,omitempty"`
+
+	// Embedder embeds the query text with the named override embedder instead of
+	// the embedder of the namespace. Admin requests only
+	Embedder string `json:"embedder,omitempty"`
+
+	// FilterMode "soft" makes the filters without an explicit soft modifier preferences,
+	// see SoftModifier. SoftPenalty is the score a result loses for missing every soft
+	// filter; with hybrid weights the fraction satisfied is the metadata score instead
+	FilterMode  string   `json:"filter_mode,omitempty"`
+	SoftPenalty *float64 `json:"soft_penalty,omitempty"`
+
+	// Fuzzy matches the query terms missing from the vocabulary of a lexical embedder,
+	// such as typos, to close vocabulary terms, reported under corrections in the
+	// response meta. Defaults to true, false keeps the query terms exact
+	Fuzzy *bool `json:"fuzzy,omitempty"`
+
+	// AsOf anchors the relative times of the request, such as "now-30d", instead of
+	// the time it is received, so a replayed request gives the same results
+	AsOf *time.Time `json:"as_of,omitempty"`
+}
+
+// ProfileName returns the ranking profile named by the request, empty for none
+func (p SearchParams) ProfileName() string {
+	return p.Profile
+}
+
+// Anchor returns the time relative expressions of the request are resolved
+// against: AsOf when set, otherwise received, the time the request was received
+func (p SearchParams) Anchor(received time.Time) time.Time {
+	if p.AsOf != nil {
+		return *p.AsOf
+	}
+	return received
+}
+
+// SoftFilterPenalty returns the soft_penalty of the request, DefaultSoftPenalty when unset
+func (p SearchParams) SoftFilterPenalty() float64 {
+	if p.SoftPenalty != nil {
+		return *p.SoftPenalty
+	}
+	return DefaultSoftPenalty
+}
+
+// UsesKeyFallback reports whether the key fallback is enabled
+func (p SearchParams) UsesKeyFallback() bool {
+	return p.KeyFallback != nil && *p.KeyFallback
 }
 
 type SearchByEmbbedingRequest struct {
-	Embedding []float64 `json:"embedding"`
-	TopK      int       `json:"top_K,omitempty"`
+	Embedding    []float64     `json:"embedding"`
+	EmbeddingB64 string        `json:"embedding_b64,omitempty"`    // Base64 packed float32, instead of Embedding
+	Sparse       *SparseVector `json:"embedding_sparse,omitempty"` // Sparse query, instead of Embedding
+	DType        DType         `json:"dtype,omitempty"`
+	TopK         int           `json:"top_K,omitempty"`
+	Namespace    string        `json:"namespace,omitempty"`
 
 	Options *SearchOptions `json:"options,omitempty"`
 
-	Filters []MetadataFilter `json:"filters,omitempty"`
+	Filters Filters `json:"filters,omitempty"`
+
+	SearchParams
+
+	// Scoring adjusts the scores before results are ranked and cut to TopK
+	Scoring *Scoring `json:"-"`
 }
 
-// MetadataFilter supports advanced filtering
+// NewQueryVector returns the vector a search scores stored vectors against,
+// sparse when sparse is set, with its norm cached
+func NewQueryVector(embedding []float64, sparse *SparseVector) *Vector {
+	query := &Vector{Embedding: embedding}
+	if sparse != nil {
+		query.SetSparse(sparse)
+	}
+	query.CacheNorm()
+	return query
+}
+
+// MetadataFilter is the legacy list form of a filter
 type MetadataFilter struct {
 	Field    string      `json:"field"`
 	Operator string      `json:"operator"` // =, in, not_in, >=, <=, >, <
 	Value    interface{} `json:"value"`
 }
 
+// Filters is the canonical filter form: filter expressions keyed by metadata field or field pattern
+// It also decodes the legacy list form [{"field": ..., "operator": ..., "value": ...}]
+type Filters map[string]FilterExpr
+
+// UnmarshalJSON accepts either a filter object or a legacy filter list, the
+// shape telling them apart, and decodes both to the canonical form
+func (f *Filters) UnmarshalJSON(data []byte) error {
+	var list []MetadataFilter
+	if err := json.Unmarshal(data, &list); err == nil {
+		converted, err := FiltersFromList(list)
+		if err != nil {
+			return err
+		}
+		*f = converted
+		return nil
+	}
+
+	var object map[string]FilterExpr
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("filters must be an object of field expressions or a list of {field, operator, value}")
+	}
+	canonical, err := Filters(object).Canonical()
+	if err != nil {
+		return err
+	}
+	*f = canonical
+	return nil
+}
+
+// FiltersFromList converts legacy list filters to the canonical form
+// Filters on the same field are combined, so all of them must match
+func FiltersFromList(list []MetadataFilter) (Filters, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	filters := make(Filters, len(list))
+	for _, filter := range list {
+		if filter.Field == "" {
+			return nil, fmt.Errorf("filter field cannot be empty")
+		}
+
+		expr, ok := filters[filter.Field]
+		if !ok {
+			expr = FilterExpr{}
+			filters[filter.Field] = expr
+		}
+
+		if filter.Operator == "not_in" {
+			// Legacy filters never match vectors missing the field
+			expr["exists"] = true
+			expr["nin"] = filter.Value
+			continue
+		}
+
+		if _, dup := expr[filter.Operator]; dup {
+			return nil, fmt.Errorf("duplicate %q filter on field %s", filter.Operator, filter.Field)
+		}
+		expr[filter.Operator] = filter.Value
+	}
+
+	return filters.Canonical()
+}
+
+// foldMetadataFilters combines the legacy metadata_filters of params into filters
+func foldMetadataFilters(filters *Filters, params *SearchParams) error {
+	legacy, err := FiltersFromList(params.MetadataFilters)
+	if err != nil {
+		return err
+	}
+	merged, err := filters.Merge(legacy)
+	if err != nil {
+		return err
+	}
+	*filters = merged
+	params.MetadataFilters = nil
+	return nil
+}
+
+// FoldMetadataFilters combines the legacy metadata_filters into the filters
+func (st *SearchByTextRequest) FoldMetadataFilters() error {
+	return foldMetadataFilters(&st.Filters, &st.SearchParams)
+}
+
+// FoldMetadataFilters combines the legacy metadata_filters into the filters
+func (sr *SearchByEmbbedingRequest) FoldMetadataFilters() error {
+	return foldMetadataFilters(&sr.Filters, &sr.SearchParams)
+}
+
+// FoldMetadataFilters combines the legacy metadata_filters into the filters
+func (asr *AdvancedSearchRequest) FoldMetadataFilters() error {
+	return foldMetadataFilters(&asr.Filters, &asr.SearchParams)
+}
+
+// FoldMetadataFilters combines the legacy metadata_filters into the filters
+func (tsr *TemporalSearchRequest) FoldMetadataFilters() error {
+	return foldMetadataFilters(&tsr.Filters, &tsr.SearchParams)
+}
+
+// Merge returns the filters of f combined with other
+// Expressions on the same field are combined, so both must match
+func (f Filters) Merge(other Filters) (Filters, error) {
+	if len(other) == 0 {
+		return f, nil
+	}
+	if len(f) == 0 {
+		return other, nil
+	}
+
+	merged := make(Filters, len(f)+len(other))
+	for field, expr := range f {
+		merged[field] = expr
+	}
+	for field, expr := range other {
+		existing, ok := merged[field]
+		if !ok {
+			merged[field] = expr
+			continue
+		}
+
+		combined := FilterExpr{}
+		for op, value := range existing {
+			combined[op] = value
+		}
+		for op, value := range expr {
+			if _, dup := combined[op]; dup {
+				return nil, fmt.Errorf("conflicting %q filters on field %s", op, field)
+			}
+			combined[op] = value
+		}
+		merged[field] = combined
+	}
+
+	return merged, nil
+}
+
 func (sr *SearchByEmbbedingRequest) Validate() error {
-	if len(sr.Embedding) == 0 {
+	if sr.Sparse != nil {
+		if len(sr.Embedding) > 0 {
+			return fmt.Errorf("embedding and embedding_sparse are mutually exclusive")
+		}
+		if err := sr.Sparse.Validate(); err != nil {
+			return err
+		}
+	} else if len(sr.Embedding) == 0 {
 		return fmt.Errorf("embedding cannot be empty")
 	}
 	if sr.TopK <= 0 {
@@ -38,9 +330,13 @@ type SearchByTextRequest struct {
 	TopK      int    `json:"top_K,omitempty"`
 	Namespace string `json:"namespace,omitempty"`
 
-	MetadataFilters []MetadataFilter `json:"metadata_filters,omitempty"`
+	Filters Filters        `json:"filters,omitempty"`
+	Options *SearchOptions `json:"options,omitempty"`
+
+	Highlight        bool              `json:"highlight,omitempty"`
+	HighlightOptions *HighlightOptions `json:"highlight_options,omitempty"`
 
-	ReturnEmbedding bool `json:"return_embedding,omitempty"`
+	SearchParams
 }
 
 func (st *SearchByTextRequest) Validate() error {
@@ -50,10 +346,5 @@ func (st *SearchByTextRequest) Validate() error {
 	if st.TopK <= 0 {
 		st.TopK = 10
 	}
-	switch st.Namespace {
-	case "", "quotes", "general":
-		return nil
-	default:
-		return fmt.Errorf("invalid namespace: %s", st.Namespace)
-	}
+	return nil
 }
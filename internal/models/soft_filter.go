@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SoftModifier is the expression key making a filter a preference rather than a
+// constraint: {"author": {"eq": "Einstein", "soft": true}} keeps vectors by other
+// authors, ranked below those satisfying the filter
+const SoftModifier = "soft"
+
+// Filter modes of a search request
+const (
+	FilterModeHard = "hard" // Filters exclude the vectors failing them, the default
+	FilterModeSoft = "soft" // Filters without an explicit soft modifier are soft
+)
+
+// DefaultSoftPenalty is the score a result loses for missing every soft filter, enough
+// to rank the vectors satisfying them first unless their similarity is far lower
+const DefaultSoftPenalty = 1.0
+
+// softFilter is a compiled soft filter and the filter key it is reported under
+type softFilter struct {
+	name    string
+	filters *CompiledFilters
+}
+
+// SoftFilterMatch reports the soft filters of a search a result satisfied and missed,
+// by filter key
+type SoftFilterMatch struct {
+	Satisfied []string `json:"satisfied"`
+	Missed    []string `json:"missed"`
+}
+
+// Fraction returns the fraction of soft filters satisfied, 1 without soft filters
+func (m *SoftFilterMatch) Fraction() float64 {
+	if m == nil || len(m.Satisfied)+len(m.Missed) == 0 {
+		return 1
+	}
+	return float64(len(m.Satisfied)) / float64(len(m.Satisfied)+len(m.Missed))
+}
+
+// Soft returns the filters with every expression made soft, except those
+// explicitly marked "soft": false, for requests with filter_mode "soft"
+func (f Filters) Soft() Filters {
+	if f == nil {
+		return nil
+	}
+	soft := make(Filters, len(f))
+	for field, expr := range f {
+		copied := make(FilterExpr, len(expr)+1)
+		for op, value := range expr {
+			copied[op] = value
+		}
+		if _, set := copied[SoftModifier]; !set {
+			copied[SoftModifier] = true
+		}
+		soft[field] = copied
+	}
+	return soft
+}
+
+// ValidateFilterMode checks the filter_mode of a search request
+func ValidateFilterMode(mode string) error {
+	switch mode {
+	case "", FilterModeHard, FilterModeSoft:
+		return nil
+	}
+	return fmt.Errorf("invalid filter_mode %q (must be: %s, %s)", mode, FilterModeHard, FilterModeSoft)
+}
+
+// CompileSearch is Compile accepting soft filters, which only searches can rank by
+// Soft filters never exclude a vector from Matches, see MatchSoft
+func (fe *FilterEvaluator) CompileSearch(filters map[string]FilterExpr) (*CompiledFilters, error) {
+	return fe.compile(filters, true)
+}
+
+// MatchSoft returns the soft filters metadata satisfies and misses, nil without soft filters
+func (fe *FilterEvaluator) MatchSoft(metadata map[string]string, filters *CompiledFilters) *SoftFilterMatch {
+	if filters == nil || len(filters.soft) == 0 {
+		return nil
+	}
+	match := &SoftFilterMatch{Satisfied: []string{}, Missed: []string{}}
+	for _, sf := range filters.soft {
+		if fe.Matches(metadata, sf.filters) {
+			match.Satisfied = append(match.Satisfied, sf.name)
+		} else {
+			match.Missed = append(match.Missed, sf.name)
+		}
+	}
+	return match
+}
+
+// splitSoft returns expr without its soft modifier and whether the modifier was set
+func splitSoft(expr FilterExpr) (FilterExpr, bool) {
+	value, ok := expr[SoftModifier]
+	if !ok {
+		return expr, false
+	}
+	stripped := make(FilterExpr, len(expr)-1)
+	for op, v := range expr {
+		if op != SoftModifier {
+			stripped[op] = v
+		}
+	}
+	soft, _ := value.(bool)
+	return stripped, soft
+}
+
+// sortSoft keeps soft filters in a stable order, so match reports do not depend on map order
+func sortSoft(soft []*softFilter) {
+	sort.Slice(soft, func(i, j int) bool { return soft[i].name < soft[j].name })
+}
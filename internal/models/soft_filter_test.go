@@ -0,0 +1,63 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilters_Soft(t *testing.T) {
+	filters := Filters{
+		"author":   {"eq": "Einstein"},
+		"category": {"eq": "science", SoftModifier: false},
+	}
+	soft := filters.Soft()
+
+	if soft["author"][SoftModifier] != true {
+		t.Errorf("author = %v, want soft", soft["author"])
+	}
+	if soft["category"][SoftModifier] != false {
+		t.Errorf("category = %v, an explicit soft: false must stay hard", soft["category"])
+	}
+	if _, set := filters["author"][SoftModifier]; set {
+		t.Error("Soft modified the original filters")
+	}
+}
+
+func TestFilterEvaluator_CompileSearch(t *testing.T) {
+	fe := NewFilterEvaluator()
+	filters := Filters{
+		"category": {"eq": "science"},
+		"author":   {"eq": "Einstein", SoftModifier: true},
+		"year":     {"lt": 1920.0, SoftModifier: true},
+	}
+
+	if _, err := fe.Compile(filters); err == nil || !strings.Contains(err.Error(), "only applies to searches") {
+		t.Errorf("Compile() error = %v, want soft filters rejected", err)
+	}
+
+	compiled, err := fe.CompileSearch(filters)
+	if err != nil {
+		t.Fatalf("CompileSearch() error = %v", err)
+	}
+
+	// Soft filters never exclude a vector, hard ones still do
+	newton := map[string]string{"category": "science", "author": "Newton", "year": "1687"}
+	if !fe.Matches(newton, compiled) {
+		t.Error("a vector missing only soft filters was excluded")
+	}
+	if fe.Matches(map[string]string{"category": "life", "author": "Einstein"}, compiled) {
+		t.Error("a vector failing the hard filter matched")
+	}
+
+	match := fe.MatchSoft(newton, compiled)
+	want := &SoftFilterMatch{Satisfied: []string{"year"}, Missed: []string{"author"}}
+	if !reflect.DeepEqual(match, want) || match.Fraction() != 0.5 {
+		t.Errorf("MatchSoft() = %+v, want %+v", match, want)
+	}
+
+	hard, _ := fe.CompileSearch(Filters{"category": {"eq": "science"}})
+	if match := fe.MatchSoft(newton, hard); match != nil || match.Fraction() != 1 {
+		t.Errorf("MatchSoft() without soft filters = %+v, want nil", match)
+	}
+}
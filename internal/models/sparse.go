@@ -0,0 +1,141 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SparseVector is an embedding holding only its non-zero values
+// Indices are the strictly increasing positions of Values in a dense vector
+// of Dimension values
+type SparseVector struct {
+	Indices   []int     `json:"indices"`
+	Values    []float64 `json:"values"`
+	Dimension int       `json:"dimension,omitempty"` // Size of the dense vector, unknown when zero
+}
+
+// SparseFromDense returns the non-zero values of embedding as a sparse vector
+func SparseFromDense(embedding []float64) *SparseVector {
+	sparse := &SparseVector{Dimension: len(embedding)}
+	for i, value := range embedding {
+		if value != 0 {
+			sparse.Indices = append(sparse.Indices, i)
+			sparse.Values = append(sparse.Values, value)
+		}
+	}
+	return sparse
+}
+
+// NewSparseVector builds a sparse vector from values keyed by index, dropping zeros
+func NewSparseVector(values map[int]float64, dimension int) *SparseVector {
+	sparse := &SparseVector{Dimension: dimension}
+	for index, value := range values {
+		if value != 0 {
+			sparse.Indices = append(sparse.Indices, index)
+		}
+	}
+	sort.Ints(sparse.Indices)
+	sparse.Values = make([]float64, len(sparse.Indices))
+	for i, index := range sparse.Indices {
+		sparse.Values[i] = values[index]
+	}
+	return sparse
+}
+
+// Validate checks that indices and values pair up, indices are strictly
+// increasing and within the dimension, and values are finite
+func (s *SparseVector) Validate() error {
+	if len(s.Indices) != len(s.Values) {
+		return fmt.Errorf("embedding_sparse has %d indices but %d values", len(s.Indices), len(s.Values))
+	}
+	if len(s.Indices) == 0 {
+		return fmt.Errorf("embedding_sparse cannot be empty")
+	}
+	if s.Dimension < 0 {
+		return fmt.Errorf("embedding_sparse dimension cannot be negative")
+	}
+	for i, index := range s.Indices {
+		if index < 0 {
+			return fmt.Errorf("embedding_sparse.indices[%d]: %d is negative", i, index)
+		}
+		if i > 0 && index <= s.Indices[i-1] {
+			return fmt.Errorf("embedding_sparse.indices must be strictly increasing, got %d after %d", index, s.Indices[i-1])
+		}
+		if s.Dimension > 0 && index >= s.Dimension {
+			return fmt.Errorf("embedding_sparse.indices[%d]: %d is outside dimension %d", i, index, s.Dimension)
+		}
+		if math.IsInf(s.Values[i], 0) || math.IsNaN(s.Values[i]) {
+			return fmt.Errorf("embedding_sparse.values[%d] is not a finite number", i)
+		}
+	}
+	return nil
+}
+
+// Size returns the dimension of the dense vector, or one past the last index when it is unknown
+func (s *SparseVector) Size() int {
+	if s.Dimension > 0 || len(s.Indices) == 0 {
+		return s.Dimension
+	}
+	return s.Indices[len(s.Indices)-1] + 1
+}
+
+// Dense returns the dense form of the vector
+func (s *SparseVector) Dense() []float64 {
+	dense := make([]float64, s.Size())
+	for i, index := range s.Indices {
+		dense[index] = s.Values[i]
+	}
+	return dense
+}
+
+// Norm returns the L2 norm of the vector
+func (s *SparseVector) Norm() float64 {
+	return l2Norm(s.Values)
+}
+
+// Dot returns the dot product with another sparse vector, merging the sorted indices
+func (s *SparseVector) Dot(other *SparseVector) float64 {
+	var dot float64
+	i, j := 0, 0
+	for i < len(s.Indices) && j < len(other.Indices) {
+		switch {
+		case s.Indices[i] < other.Indices[j]:
+			i++
+		case s.Indices[i] > other.Indices[j]:
+			j++
+		default:
+			dot += s.Values[i] * other.Values[j]
+			i++
+			j++
+		}
+	}
+	return dot
+}
+
+// DotDense returns the dot product with a dense embedding
+// Indices past the end of embedding are ignored
+func (s *SparseVector) DotDense(embedding []float64) float64 {
+	var dot float64
+	for i, index := range s.Indices {
+		if index >= len(embedding) {
+			break
+		}
+		dot += s.Values[i] * embedding[index]
+	}
+	return dot
+}
+
+// compatibleWith reports whether s can be compared with a sparse vector
+// Vectors of unknown dimension are compatible with any other
+func (s *SparseVector) compatibleWith(other *SparseVector) bool {
+	return s.Dimension == 0 || other.Dimension == 0 || s.Dimension == other.Dimension
+}
+
+// compatibleWithDense reports whether s can be compared with a dense embedding
+func (s *SparseVector) compatibleWithDense(embedding []float64) bool {
+	if s.Dimension > 0 {
+		return s.Dimension == len(embedding)
+	}
+	return s.Size() <= len(embedding)
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSparseVector_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		sparse SparseVector
+		ok     bool
+	}{
+		{"valid", SparseVector{Indices: []int{0, 3, 9}, Values: []float64{1, 2, 3}, Dimension: 10}, true},
+		{"unknown dimension", SparseVector{Indices: []int{4}, Values: []float64{1}}, true},
+		{"empty", SparseVector{}, false},
+		{"length mismatch", SparseVector{Indices: []int{0, 1}, Values: []float64{1}}, false},
+		{"unsorted", SparseVector{Indices: []int{3, 1}, Values: []float64{1, 2}}, false},
+		{"duplicate", SparseVector{Indices: []int{1, 1}, Values: []float64{1, 2}}, false},
+		{"negative", SparseVector{Indices: []int{-1}, Values: []float64{1}}, false},
+		{"outside dimension", SparseVector{Indices: []int{10}, Values: []float64{1}, Dimension: 10}, false},
+		{"not finite", SparseVector{Indices: []int{0}, Values: []float64{math.NaN()}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sparse.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// randomSparse returns a dense embedding with the given fraction of non-zero values
+func randomSparse(rng *rand.Rand, dimension int, density float64) []float64 {
+	embedding := make([]float64, dimension)
+	for i := range embedding {
+		if rng.Float64() < density {
+			embedding[i] = rng.Float64()
+		}
+	}
+	return embedding
+}
+
+func TestCosineSimilarity_SparseMatchesDense(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 50; i++ {
+		a := randomSparse(rng, 500, 0.1)
+		b := randomSparse(rng, 500, 0.1)
+		want := (&Vector{Embedding: a}).CosineSimilarity(&Vector{Embedding: b})
+
+		sparseA := &Vector{Sparse: SparseFromDense(a)}
+		sparseB := &Vector{Sparse: SparseFromDense(b)}
+		sparseB.CacheNorm()
+		pairs := map[string][2]*Vector{
+			"sparse-sparse": {sparseA, sparseB},
+			"sparse-dense":  {sparseA, {Embedding: b}},
+			"dense-sparse":  {{Embedding: a}, sparseB},
+		}
+		for name, pair := range pairs {
+			if got := pair[0].CosineSimilarity(pair[1]); math.Abs(got-want) > 1e-12 {
+				t.Fatalf("%s similarity = %v, dense = %v", name, got, want)
+			}
+		}
+
+		wantDistance := (&Vector{Embedding: a}).EuclideanDistance(&Vector{Embedding: b})
+		if got := sparseA.EuclideanDistance(&Vector{Embedding: b}); math.Abs(got-wantDistance) > 1e-9 {
+			t.Fatalf("sparse-dense distance = %v, dense = %v", got, wantDistance)
+		}
+	}
+}
+
+func TestVector_Compatible(t *testing.T) {
+	known := &Vector{Sparse: &SparseVector{Indices: []int{1}, Values: []float64{1}, Dimension: 3}}
+	unknown := &Vector{Sparse: &SparseVector{Indices: []int{7}, Values: []float64{1}}}
+
+	tests := []struct {
+		name string
+		a, b *Vector
+		want bool
+	}{
+		{"same dimension", known, &Vector{Embedding: []float64{1, 2, 3}}, true},
+		{"different dimension", known, &Vector{Embedding: []float64{1, 2}}, false},
+		{"unknown dimension within dense", unknown, &Vector{Embedding: make([]float64, 8)}, true},
+		{"unknown dimension past dense", &Vector{Embedding: make([]float64, 4)}, unknown, false},
+		{"unknown dimension with sparse", unknown, known, true},
+		{"dense mismatch", &Vector{Embedding: []float64{1}}, &Vector{Embedding: []float64{1, 2}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Compatible(tt.b); got != tt.want {
+			t.Errorf("%s: Compatible() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := known.CosineSimilarity(&Vector{Embedding: []float64{1, 2}}); got != 0 {
+		t.Errorf("similarity of incompatible vectors = %v, want 0", got)
+	}
+}
+
+// benchmarkSparseScan scores a query against a 5000-dimension corpus with 2%
+// non-zero values, the shape of TF-IDF output, stored dense or sparse
+func benchmarkSparseScan(b *testing.B, sparse bool) {
+	const dimension, density, corpusSize = 5000, 0.02, 1000
+
+	rng := rand.New(rand.NewSource(1))
+	toVector := func(embedding []float64) *Vector {
+		v := &Vector{Embedding: embedding}
+		if sparse {
+			v.SetSparse(SparseFromDense(embedding))
+		}
+		v.CacheNorm()
+		return v
+	}
+
+	query := toVector(randomSparse(rng, dimension, density))
+	corpus := make([]*Vector, corpusSize)
+	var bytes int
+	for i := range corpus {
+		corpus[i] = toVector(randomSparse(rng, dimension, density))
+		if sparse {
+			bytes += len(corpus[i].Sparse.Indices)*8 + len(corpus[i].Sparse.Values)*8
+		} else {
+			bytes += len(corpus[i].Embedding) * 8
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range corpus {
+			query.CosineSimilarity(v)
+		}
+	}
+	b.ReportMetric(float64(bytes)/corpusSize, "bytes/vector")
+}
+
+func BenchmarkSparseScan_Dense(b *testing.B) { benchmarkSparseScan(b, false) }
+
+func BenchmarkSparseScan_Sparse(b *testing.B) { benchmarkSparseScan(b, true) }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"time"
@@ -20,11 +21,28 @@ const (
 type TemporalSearchRequest struct {
 	Query         string                `json:"query"`
 	TopK          int                   `json:"top_k,omitempty"`
-	Filters       map[string]FilterExpr `json:"filters,omitempty"`
+	Namespace     string                `json:"namespace,omitempty"`
+	Filters       Filters               `json:"filters,omitempty"`
 	TemporalDecay TemporalDecayStrength `json:"temporal_decay,omitempty"` // strong, medium, weak, none
-	ReferenceTime *time.Time            `json:"reference_time,omitempty"` // Defaults to now
+	ReferenceTime *TimeExpr             `json:"reference_time,omitempty"` // Defaults to now, may be relative such as "now-30d"
 	TimeField     string                `json:"time_field,omitempty"`     // Metadata field for timestamp
+	DefaultTime   *TimeExpr             `json:"default_time,omitempty"`   // Time of documents without one, defaults to the epoch
+	AgeFormat     string                `json:"age_format,omitempty"`     // text (default, localized from Accept-Language) or iso
 	Options       *SearchOptions        `json:"options,omitempty"`
+
+	Highlight        bool              `json:"highlight,omitempty"`
+	HighlightOptions *HighlightOptions `json:"highlight_options,omitempty"`
+
+	SearchParams
+
+	// SparseQuery is the sparse query embedding, scored instead of the dense one when set
+	SparseQuery *SparseVector `json:"-"`
+
+	// Candidates restricts the search to these vector IDs when not nil
+	Candidates []string `json:"-"`
+
+	// Scoring adjusts the scores before results are ranked and cut to TopK
+	Scoring *Scoring `json:"-"`
 }
 
 // TemporalConfig holds temporal decay configuration
@@ -32,6 +50,7 @@ type TemporalConfig struct {
 	Lambda        float64   // Decay rate
 	ReferenceTime time.Time // Time to compute decay from
 	TimeField     string    // Metadata field containing timestamp
+	DefaultTime   time.Time // Time of documents without a usable timestamp
 }
 
 func (tsr *TemporalSearchRequest) Validate() error {
@@ -48,6 +67,12 @@ func (tsr *TemporalSearchRequest) Validate() error {
 		tsr.TimeField = "created_at" // Default field
 	}
 
+	switch tsr.AgeFormat {
+	case "", AgeFormatText, AgeFormatISO:
+	default:
+		return fmt.Errorf("invalid age_format value: %s (must be: text, iso)", tsr.AgeFormat)
+	}
+
 	// Validate decay strength
 	switch tsr.TemporalDecay {
 	case DecayStrong, DecayMedium, DecayWeak, DecayNone:
@@ -56,6 +81,10 @@ func (tsr *TemporalSearchRequest) Validate() error {
 		return fmt.Errorf("invalid temporal_decay value: %s (must be: strong, medium, weak, none)", tsr.TemporalDecay)
 	}
 
+	if _, err := NewFilterEvaluator().CompileSearch(tsr.Filters); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -66,12 +95,18 @@ func (tsr *TemporalSearchRequest) GetTemporalConfig() *TemporalConfig {
 		TimeField: tsr.TimeField,
 	}
 
-	if tsr.ReferenceTime != nil {
-		config.ReferenceTime = *tsr.ReferenceTime
+	if tsr.ReferenceTime != nil && !tsr.ReferenceTime.Time.IsZero() {
+		config.ReferenceTime = tsr.ReferenceTime.Time
 	} else {
 		config.ReferenceTime = time.Now()
 	}
 
+	if tsr.DefaultTime != nil && !tsr.DefaultTime.Time.IsZero() {
+		config.DefaultTime = tsr.DefaultTime.Time
+	} else {
+		config.DefaultTime = DefaultDocumentTime
+	}
+
 	return config
 }
 
@@ -139,49 +174,18 @@ func (ts *TemporalScorer) GetDecayFactor(documentTime time.Time) float64 {
 
 // TemporalSearchResult extends SearchResult with temporal info
 type TemporalSearchResult struct {
-	Vector       *Vector   `json:"vector"`
-	Score        float64   `json:"score"`         // Final score with decay
-	BaseScore    float64   `json:"base_score"`    // Original cosine similarity
-	DecayFactor  float64   `json:"decay_factor"`  // Temporal decay applied
-	DocumentTime time.Time `json:"document_time"` // Time used for decay
-	Age          string    `json:"age,omitempty"` // Human-readable age
-}
-
-// CalculateAge returns a human-readable age string
-func CalculateAge(t time.Time, reference time.Time) string {
-	duration := reference.Sub(t)
-
-	years := int(duration.Hours() / (24 * 365.25))
-	if years > 0 {
-		if years == 1 {
-			return "1 year ago"
-		}
-		return fmt.Sprintf("%d years ago", years)
-	}
-
-	months := int(duration.Hours() / (24 * 30.44))
-	if months > 0 {
-		if months == 1 {
-			return "1 month ago"
-		}
-		return fmt.Sprintf("%d months ago", months)
-	}
-
-	days := int(duration.Hours() / 24)
-	if days > 0 {
-		if days == 1 {
-			return "1 day ago"
-		}
-		return fmt.Sprintf("%d days ago", days)
-	}
-
-	hours := int(duration.Hours())
-	if hours > 0 {
-		if hours == 1 {
-			return "1 hour ago"
-		}
-		return fmt.Sprintf("%d hours ago", hours)
-	}
-
-	return "just now"
+	Vector       *Vector    `json:"vector"`
+	Score        float64    `json:"score"`         // Final score with decay
+	BaseScore    float64    `json:"base_score"`    // Original cosine similarity
+	DecayFactor  float64    `json:"decay_factor"`  // Temporal decay applied
+	DocumentTime time.Time  `json:"document_time"` // Time used for decay
+	TimeSource   TimeSource `json:"time_source"`   // Where DocumentTime was found
+	TimeFallback bool       `json:"time_fallback"` // DocumentTime is not from the requested field, so the decay may be unreliable
+	Age          string     `json:"age,omitempty"` // Localized age or ISO 8601 duration, see AgeFormat
+	Highlights   []string   `json:"highlights,omitempty"`
+
+	Explanation *ScoreExplanation `json:"explanation,omitempty"` // How score adjusters changed the decayed score, with explain
+	Enrichment  json.RawMessage   `json:"enrichment,omitempty"`  // Enrichment document of the result, with enrich_by
+
+	Format *ResponseFormat `json:"-"` // Serialization format of the scores and embedding, unformatted when nil
 }
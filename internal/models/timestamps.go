@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeSource describes where the time of a document was taken from
+type TimeSource string
+
+const (
+	TimeSourceField     TimeSource = "field"      // The requested time field
+	TimeSourceCreatedAt TimeSource = "created_at" // The vector creation time
+	TimeSourceMetadata  TimeSource = "metadata"   // Another time metadata field
+	TimeSourceUpdatedAt TimeSource = "updated_at" // The vector update time
+	TimeSourceDefault   TimeSource = "default"    // No time was found
+)
+
+// DefaultDocumentTime is the time of documents without any usable time
+// Using the epoch rather than now makes them decay as old instead of ranking as new
+var DefaultDocumentTime = time.Unix(0, 0).UTC()
+
+// TimeMetadataFields are the metadata fields searched for a time when the requested field has none
+var TimeMetadataFields = []string{"created_at", "published_at", "timestamp", "date", "updated_at"}
+
+// timeLayouts are the formats accepted for time metadata, tried in order
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseTime parses a time metadata value
+func ParseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", value)
+}
+
+// DocumentTime returns the time of vector and where it was found
+// The requested field is tried first (the created_at and updated_at fields also
+// match the vector timestamps), then the vector creation time, any other
+// parsable time metadata, the vector update time, and finally def
+func DocumentTime(vector *Vector, field string, def time.Time) (time.Time, TimeSource) {
+	if value, ok := vector.Metadata[field]; ok {
+		if t, err := ParseTime(value); err == nil {
+			return t, TimeSourceField
+		}
+	}
+
+	switch {
+	case field == "created_at" && !vector.CreatedAt.IsZero():
+		return vector.CreatedAt, TimeSourceField
+	case field == "updated_at" && !vector.UpdatedAt.IsZero():
+		return vector.UpdatedAt, TimeSourceField
+	case !vector.CreatedAt.IsZero():
+		return vector.CreatedAt, TimeSourceCreatedAt
+	}
+
+	for _, name := range TimeMetadataFields {
+		if name == field {
+			continue
+		}
+		if value, ok := vector.Metadata[name]; ok {
+			if t, err := ParseTime(value); err == nil {
+				return t, TimeSourceMetadata
+			}
+		}
+	}
+
+	if !vector.UpdatedAt.IsZero() {
+		return vector.UpdatedAt, TimeSourceUpdatedAt
+	}
+
+	return def, TimeSourceDefault
+}
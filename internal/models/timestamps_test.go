@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDocumentTime(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	published := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	def := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		vector     Vector
+		field      string
+		wantTime   time.Time
+		wantSource TimeSource
+	}{
+		{
+			name:       "requested field",
+			vector:     Vector{CreatedAt: created, Metadata: map[string]string{"published_at": "2019-06-01"}},
+			field:      "published_at",
+			wantTime:   published,
+			wantSource: TimeSourceField,
+		},
+		{
+			name:       "created_at field matches the vector timestamp",
+			vector:     Vector{CreatedAt: created},
+			field:      "created_at",
+			wantTime:   created,
+			wantSource: TimeSourceField,
+		},
+		{
+			name:       "unparsable field falls back to created_at",
+			vector:     Vector{CreatedAt: created, Metadata: map[string]string{"published_at": "yesterday"}},
+			field:      "published_at",
+			wantTime:   created,
+			wantSource: TimeSourceCreatedAt,
+		},
+		{
+			name:       "legacy vector uses other time metadata",
+			vector:     Vector{UpdatedAt: updated, Metadata: map[string]string{"published_at": "2019-06-01T00:00:00Z"}},
+			field:      "created_at",
+			wantTime:   published,
+			wantSource: TimeSourceMetadata,
+		},
+		{
+			name:       "legacy vector uses updated_at",
+			vector:     Vector{UpdatedAt: updated},
+			field:      "created_at",
+			wantTime:   updated,
+			wantSource: TimeSourceUpdatedAt,
+		},
+		{
+			name:       "no time at all",
+			vector:     Vector{},
+			field:      "created_at",
+			wantTime:   def,
+			wantSource: TimeSourceDefault,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, source := DocumentTime(&tt.vector, tt.field, def)
+			if !got.Equal(tt.wantTime) || source != tt.wantSource {
+				t.Errorf("DocumentTime() = %s (%s), want %s (%s)", got, source, tt.wantTime, tt.wantSource)
+			}
+		})
+	}
+}
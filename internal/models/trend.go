@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrendBucketSize is the width of the time buckets of a trend
+type TrendBucketSize string
+
+const (
+	BucketDay   TrendBucketSize = "day"
+	BucketWeek  TrendBucketSize = "week" // ISO weeks, starting on Monday
+	BucketMonth TrendBucketSize = "month"
+)
+
+// MaxTrendBuckets limits the number of buckets a trend may span
+const MaxTrendBuckets = 1000
+
+// TrendRequest asks how the volume of content similar to a query changes over time
+type TrendRequest struct {
+	Query     string          `json:"query,omitempty"`     // Query text, embedded when Embedding is empty
+	Embedding []float64       `json:"embedding,omitempty"` // Query embedding
+	Threshold *float64        `json:"threshold,omitempty"` // Minimum similarity to be counted, all vectors when unset
+	TimeField string          `json:"time_field,omitempty"`
+	Bucket    TrendBucketSize `json:"bucket,omitempty"`
+	From      *time.Time      `json:"from,omitempty"` // Inclusive
+	To        *time.Time      `json:"to,omitempty"`   // Exclusive
+	Namespace string          `json:"namespace,omitempty"`
+	Filters   Filters         `json:"filters,omitempty"`
+}
+
+func (tr *TrendRequest) Validate() error {
+	if tr.Query == "" && len(tr.Embedding) == 0 {
+		return fmt.Errorf("query or embedding is required")
+	}
+	if tr.TimeField == "" {
+		tr.TimeField = "created_at"
+	}
+	if tr.Bucket == "" {
+		tr.Bucket = BucketDay
+	}
+
+	switch tr.Bucket {
+	case BucketDay, BucketWeek, BucketMonth:
+		// Valid
+	default:
+		return fmt.Errorf("invalid bucket value: %s (must be: day, week, month)", tr.Bucket)
+	}
+
+	if tr.From != nil && tr.To != nil && !tr.From.Before(*tr.To) {
+		return fmt.Errorf("from must be before to")
+	}
+
+	if _, err := NewFilterEvaluator().Compile(tr.Filters); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TrendBucket counts the matching vectors in one time bucket
+type TrendBucket struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Count     int       `json:"count"`
+	MeanScore float64   `json:"mean_score"`
+}
+
+// TrendUnknown counts matching vectors without a parsable time
+type TrendUnknown struct {
+	Count     int     `json:"count"`
+	MeanScore float64 `json:"mean_score"`
+}
+
+// TrendResponse is the per-bucket volume of content similar to a query
+type TrendResponse struct {
+	Query     string          `json:"query,omitempty"`
+	TimeField string          `json:"time_field"`
+	Bucket    TrendBucketSize `json:"bucket"`
+	Threshold *float64        `json:"threshold,omitempty"`
+	Buckets   []TrendBucket   `json:"buckets"`
+	Unknown   TrendUnknown    `json:"unknown"`
+	Total     int             `json:"total"`
+}
@@ -4,42 +4,232 @@ import (
 	"fmt"
 	"math"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/pborman/uuid"
 )
 
+// NamespaceKey is the metadata field holding the namespace of a vector
+const NamespaceKey = "namespace"
+
+// MaxIDLength is the longest accepted vector ID in bytes
+const MaxIDLength = 512
+
 type Quote struct {
-	Text   string `json:"text"`
-	Author string `json:"author"`
+	Text      string `json:"text"`
+	Author    string `json:"author"`
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type Vector struct {
 	ID        string            `json:"id"`
 	Embedding []float64         `json:"embedding,omitempty"`
+	Sparse    *SparseVector     `json:"embedding_sparse,omitempty"` // Set instead of Embedding for sparse vectors
 	Metadata  map[string]string `json:"metadata,omitempty"`
+	DType     DType             `json:"dtype,omitempty"` // Representation the embedding was supplied in, float64 when empty
 	CreatedAt time.Time         `json:"created_at"`
 	UpdatedAt time.Time         `json:"updated_at"`
+
+	norm float64 // Cached L2 norm of the embedding, zero when not cached
+}
+
+// SetEmbedding replaces the embedding and drops the cached norm
+func (v *Vector) SetEmbedding(embedding []float64) {
+	v.Embedding = embedding
+	v.Sparse = nil
+	v.norm = 0
+}
+
+// SetSparse replaces the embedding with a sparse one and drops the cached norm
+func (v *Vector) SetSparse(sparse *SparseVector) {
+	v.Embedding = nil
+	v.Sparse = sparse
+	v.norm = 0
+}
+
+// IsSparse reports whether the embedding is sparse
+func (v *Vector) IsSparse() bool {
+	return v.Sparse != nil
+}
+
+// HasEmbedding reports whether the vector has a dense or sparse embedding
+// Vectors without one are pending: they are stored, listed and filtered, but never searched
+func (v *Vector) HasEmbedding() bool {
+	return len(v.Embedding) > 0 || v.Sparse != nil
+}
+
+// Dimension returns the size of the embedding, dense or sparse
+func (v *Vector) Dimension() int {
+	if v.Sparse != nil {
+		return v.Sparse.Size()
+	}
+	return len(v.Embedding)
+}
+
+// CacheNorm computes and caches the L2 norm of the embedding
+// Storage backends call it when a vector is stored, so similarity scans only
+// compute the dot product. The embedding must not be mutated in place afterwards
+func (v *Vector) CacheNorm() {
+	v.norm = v.computeNorm()
+}
+
+// Norm returns the L2 norm of the embedding, computing it when it is not cached
+// The computed norm is not cached, so vectors read concurrently are never written
+func (v *Vector) Norm() float64 {
+	if v.norm > 0 {
+		return v.norm
+	}
+	return v.computeNorm()
+}
+
+func (v *Vector) computeNorm() float64 {
+	if v.Sparse != nil {
+		return v.Sparse.Norm()
+	}
+	return l2Norm(v.Embedding)
+}
+
+func l2Norm(embedding []float64) float64 {
+	var sum float64
+	for _, value := range embedding {
+		sum += value * value
+	}
+	return math.Sqrt(sum)
 }
 
 func (v *Vector) Validate() error {
+	return v.validate(false)
+}
+
+// ValidatePending is Validate permitting a vector without an embedding
+func (v *Vector) ValidatePending() error {
+	return v.validate(true)
+}
 
-	if len(v.Embedding) == 0 {
+// ValidateBatch validates every vector of a batch stored together, which may not
+// hold the same ID twice. allowEmpty permits vectors without an embedding
+func ValidateBatch(vectors []*Vector, allowEmpty bool) error {
+	seen := make(map[string]bool, len(vectors))
+	for i, v := range vectors {
+		if err := v.validate(allowEmpty); err != nil {
+			return fmt.Errorf("vector %d: %w", i, err)
+		}
+		if seen[v.ID] {
+			return fmt.Errorf("vector %d: duplicate id %s in batch", i, v.ID)
+		}
+		seen[v.ID] = true
+	}
+	return nil
+}
+
+func (v *Vector) validate(allowEmpty bool) error {
+	if v.Sparse != nil {
+		if len(v.Embedding) > 0 {
+			return fmt.Errorf("embedding and embedding_sparse are mutually exclusive")
+		}
+		if err := v.Sparse.Validate(); err != nil {
+			return err
+		}
+	} else if len(v.Embedding) == 0 && !allowEmpty {
 		return fmt.Errorf("embedding cannot be empty")
 	}
+	if err := v.CheckDimension(); err != nil {
+		return err
+	}
 
 	if v.ID == "" {
 		v.ID = uuid.New()
 	}
 
+	return ValidateID(v.ID)
+}
+
+// ValidateID rejects vector IDs that are empty, too long, not valid UTF-8
+// or that contain control characters
+// Other characters, including path separators, are allowed and must be
+// encoded by storage backends that map IDs to file names
+func ValidateID(id string) error {
+	if id == "" {
+		return fmt.Errorf("id cannot be empty")
+	}
+	if len(id) > MaxIDLength {
+		return fmt.Errorf("id exceeds %d bytes", MaxIDLength)
+	}
+	if !utf8.ValidString(id) {
+		return fmt.Errorf("id must be valid UTF-8")
+	}
+	for _, r := range id {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("id cannot contain control characters")
+		}
+	}
 	return nil
 }
 
+// Compatible reports whether the embeddings of v and other can be compared
+// Dense embeddings must have the same length; sparse vectors of unknown
+// dimension are compatible with any vector holding all of their indices
+func (v *Vector) Compatible(other *Vector) bool {
+	switch {
+	case v.Sparse != nil && other.Sparse != nil:
+		return v.Sparse.compatibleWith(other.Sparse)
+	case v.Sparse != nil:
+		return v.Sparse.compatibleWithDense(other.Embedding)
+	case other.Sparse != nil:
+		return other.Sparse.compatibleWithDense(v.Embedding)
+	default:
+		return len(v.Embedding) == len(other.Embedding)
+	}
+}
+
+// Dot returns the dot product of the embeddings, or 0 when they are not compatible
+func (v *Vector) Dot(other *Vector) float64 {
+	if !v.Compatible(other) {
+		return 0
+	}
+	switch {
+	case v.Sparse != nil && other.Sparse != nil:
+		return v.Sparse.Dot(other.Sparse)
+	case v.Sparse != nil:
+		return v.Sparse.DotDense(other.Embedding)
+	case other.Sparse != nil:
+		return other.Sparse.DotDense(v.Embedding)
+	}
+
+	var dot float64
+	for i := range v.Embedding {
+		dot += v.Embedding[i] * other.Embedding[i]
+	}
+	return dot
+}
+
 func (v *Vector) CosineSimilarity(other *Vector) float64 {
+	// Sparse vectors only visit their non-zero values, so the norms are not
+	// accumulated alongside the dot product
+	if v.Sparse != nil || other.Sparse != nil {
+		normA, normB := v.Norm(), other.Norm()
+		if normA == 0 || normB == 0 {
+			return 0
+		}
+		return v.Dot(other) / (normA * normB)
+	}
+
 	if len(v.Embedding) != len(other.Embedding) {
 		return 0
 	}
 
-	var dotProduct, normA, normB float64
+	var dotProduct float64
+
+	// With both norms cached only the dot product is left to compute
+	if v.norm > 0 && other.norm > 0 {
+		for i := range v.Embedding {
+			dotProduct += v.Embedding[i] * other.Embedding[i]
+		}
+		return dotProduct / (v.norm * other.norm)
+	}
+
+	var normA, normB float64
 	for i := range v.Embedding {
 		dotProduct += v.Embedding[i] * other.Embedding[i]
 		normA += v.Embedding[i] * v.Embedding[i]
@@ -54,10 +244,16 @@ func (v *Vector) CosineSimilarity(other *Vector) float64 {
 }
 
 func (v *Vector) EuclideanDistance(other *Vector) float64 {
-	if len(v.Embedding) != len(other.Embedding) {
+	if !v.Compatible(other) {
 		return math.Inf(1)
 	}
 
+	// |a-b|² = |a|² + |b|² - 2a·b, clamped against rounding below zero
+	if v.Sparse != nil || other.Sparse != nil {
+		normA, normB := v.Norm(), other.Norm()
+		return math.Sqrt(math.Max(0, normA*normA+normB*normB-2*v.Dot(other)))
+	}
+
 	var sum float64
 	for i := range v.Embedding {
 		diff := v.Embedding[i] - other.Embedding[i]
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomVector(rng *rand.Rand, dimension int) *Vector {
+	embedding := make([]float64, dimension)
+	for i := range embedding {
+		embedding[i] = rng.NormFloat64()
+	}
+	return &Vector{Embedding: embedding}
+}
+
+func TestCosineSimilarity_CachedNormMatchesUncached(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	query := randomVector(rng, 384)
+	cachedQuery := &Vector{Embedding: query.Embedding}
+	cachedQuery.CacheNorm()
+
+	for i := 0; i < 100; i++ {
+		stored := randomVector(rng, 384)
+		uncached := query.CosineSimilarity(stored)
+
+		cached := &Vector{Embedding: stored.Embedding}
+		cached.CacheNorm()
+		if got := cachedQuery.CosineSimilarity(cached); math.Abs(got-uncached) > 1e-12 {
+			t.Fatalf("cached similarity = %v, uncached = %v", got, uncached)
+		}
+	}
+
+	zero := &Vector{Embedding: make([]float64, 384)}
+	zero.CacheNorm()
+	if got := cachedQuery.CosineSimilarity(zero); got != 0 {
+		t.Errorf("similarity to a zero vector = %v, want 0", got)
+	}
+}
+
+func TestSetEmbedding_InvalidatesNorm(t *testing.T) {
+	v := &Vector{Embedding: []float64{3, 4}}
+	v.CacheNorm()
+	if v.Norm() != 5 {
+		t.Fatalf("norm = %v, want 5", v.Norm())
+	}
+
+	v.SetEmbedding([]float64{6, 8})
+	if v.Norm() != 10 {
+		t.Errorf("norm after SetEmbedding = %v, want 10", v.Norm())
+	}
+	if got := v.CosineSimilarity(&Vector{Embedding: []float64{3, 4}}); math.Abs(got-1) > 1e-12 {
+		t.Errorf("similarity after SetEmbedding = %v, want 1", got)
+	}
+}
+
+// benchmarkScan scores a query against a corpus the way a search scan does
+func benchmarkScan(b *testing.B, cache bool) {
+	rng := rand.New(rand.NewSource(1))
+	query := randomVector(rng, 384)
+	corpus := make([]*Vector, 1000)
+	for i := range corpus {
+		corpus[i] = randomVector(rng, 384)
+		if cache {
+			corpus[i].CacheNorm()
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q := &Vector{Embedding: query.Embedding}
+		if cache {
+			q.CacheNorm()
+		}
+		for _, v := range corpus {
+			q.CosineSimilarity(v)
+		}
+	}
+}
+
+func BenchmarkScan_Uncached(b *testing.B) { benchmarkScan(b, false) }
+
+func BenchmarkScan_CachedNorms(b *testing.B) { benchmarkScan(b, true) }
@@ -0,0 +1,305 @@
+// Package redact defines redaction policies: fields stripped, truncated or masked
+// and patterns masked in the responses seen by the API keys assigned to a policy
+package redact
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Actions of a field rule
+const (
+	ActionStrip    = "strip"    // Remove the field
+	ActionTruncate = "truncate" // Keep the first MaxChars characters followed by an ellipsis
+	ActionMask     = "mask"     // Replace the value with the policy mask
+)
+
+// Defaults of the policy settings left unset
+const (
+	DefaultMaxChars = 50
+	DefaultMask     = "[redacted]"
+)
+
+// FullAccess is the policy name of keys that see responses unredacted
+const FullAccess = "none"
+
+// HighlightsField is the response field of search highlights, which are snippets of
+// stored text. Policies with field rules strip highlights unless a rule names them
+const HighlightsField = "highlights"
+
+// Rule redacts the values of one field
+type Rule struct {
+	Action   string `json:"action"`
+	MaxChars int    `json:"max_chars,omitempty"` // Characters kept by truncate, DefaultMaxChars when unset
+}
+
+// Pattern masks the matches of a regular expression in every string of a response
+type Pattern struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"` // The policy mask when unset
+
+	re *regexp.Regexp
+}
+
+// Policy redacts the fields named by its rules wherever they appear in a response:
+// vector metadata, flattened search results and enrichment documents alike
+type Policy struct {
+	Name     string          `json:"name"`
+	Fields   map[string]Rule `json:"fields,omitempty"`
+	Patterns []Pattern       `json:"patterns,omitempty"`
+	Mask     string          `json:"mask,omitempty"` // Replacement of masked values, DefaultMask when unset
+}
+
+// Config assigns redaction policies to API keys
+type Config struct {
+	Policies []*Policy `json:"policies"`
+
+	// Keys maps API keys to the name of their policy, FullAccess for none
+	Keys map[string]string `json:"keys,omitempty"`
+
+	// Default is the policy of requests without a listed key, FullAccess when unset
+	Default string `json:"default,omitempty"`
+
+	byName map[string]*Policy
+}
+
+// Load reads and validates the redaction config of a JSON file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction policies: %w", err)
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse redaction policies %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks the policies and that every key names one, compiling the patterns
+func (c *Config) Validate() error {
+	c.byName = make(map[string]*Policy, len(c.Policies))
+	for _, p := range c.Policies {
+		if err := p.validate(); err != nil {
+			return err
+		}
+		if _, ok := c.byName[p.Name]; ok {
+			return fmt.Errorf("redaction policy %s is defined twice", p.Name)
+		}
+		c.byName[p.Name] = p
+	}
+
+	if c.Default == "" {
+		c.Default = FullAccess
+	}
+	if c.Default != FullAccess && c.byName[c.Default] == nil {
+		return fmt.Errorf("default redaction policy %s is not defined", c.Default)
+	}
+	for key, name := range c.Keys {
+		if key == "" {
+			return fmt.Errorf("redaction keys cannot contain an empty key")
+		}
+		if name != FullAccess && c.byName[name] == nil {
+			return fmt.Errorf("redaction policy %s of a key is not defined", name)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) validate() error {
+	if p.Name == "" || p.Name == FullAccess {
+		return fmt.Errorf("redaction policy name cannot be empty or %q", FullAccess)
+	}
+	if p.Mask == "" {
+		p.Mask = DefaultMask
+	}
+	for field, rule := range p.Fields {
+		switch rule.Action {
+		case ActionStrip, ActionMask:
+		case ActionTruncate:
+			if rule.MaxChars < 0 {
+				return fmt.Errorf("redaction policy %s: max_chars of %s cannot be negative", p.Name, field)
+			}
+			if rule.MaxChars == 0 {
+				rule.MaxChars = DefaultMaxChars
+				p.Fields[field] = rule
+			}
+		default:
+			return fmt.Errorf("redaction policy %s: invalid action %q of %s (must be: strip, truncate, mask)", p.Name, rule.Action, field)
+		}
+	}
+	if _, ok := p.Fields[HighlightsField]; !ok && len(p.Fields) > 0 {
+		p.Fields[HighlightsField] = Rule{Action: ActionStrip}
+	}
+	for i := range p.Patterns {
+		re, err := regexp.Compile(p.Patterns[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("redaction policy %s: invalid pattern %q: %w", p.Name, p.Patterns[i].Pattern, err)
+		}
+		p.Patterns[i].re = re
+		if p.Patterns[i].Replacement == "" {
+			p.Patterns[i].Replacement = p.Mask
+		}
+	}
+	return nil
+}
+
+// PolicyFor returns the policy of the API key, nil for full access
+// Keys are compared in constant time, as the admin key is
+func (c *Config) PolicyFor(key string) *Policy {
+	name := c.Default
+	if key != "" {
+		for candidate, policy := range c.Keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+				name = policy
+			}
+		}
+	}
+	return c.byName[name]
+}
+
+// JSON redacts a JSON document, or each line of newline-delimited JSON, noting the
+// policy name as "redaction" in the meta object of documents having one
+// Numbers are kept as written
+func (p *Policy) JSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for dec.More() {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		v = p.Value(v)
+		if doc, ok := v.(map[string]interface{}); ok {
+			if meta, ok := doc["meta"].(map[string]interface{}); ok {
+				meta["redaction"] = p.Name
+			}
+		}
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// CSV redacts a CSV document whose first row names its columns: the columns named by
+// strip rules are removed and the other rules apply to the values of their column, as
+// they would to the fields of the same name in JSON. Patterns mask every value but
+// those of the score column, kept as written like JSON numbers
+func (p *Policy) CSV(data []byte) ([]byte, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return data, nil
+	}
+
+	header := rows[0]
+	var kept []int
+	for i, column := range header {
+		if rule, ok := p.Fields[column]; !ok || rule.Action != ActionStrip {
+			kept = append(kept, i)
+		}
+	}
+
+	var buf bytes.Buffer
+	out := csv.NewWriter(&buf)
+	for n, row := range rows {
+		record := make([]string, 0, len(kept))
+		for _, i := range kept {
+			value := row[i]
+			if n > 0 && header[i] != "score" {
+				value = p.String(value)
+				if rule, ok := p.Fields[header[i]]; ok {
+					value = rule.apply(value, p.Mask).(string)
+				}
+			}
+			record = append(record, value)
+		}
+		if err := out.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	out.Flush()
+	return buf.Bytes(), out.Error()
+}
+
+// Value redacts a decoded JSON value
+func (p *Policy) Value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			rule, ok := p.Fields[key]
+			if ok && rule.Action == ActionStrip {
+				delete(v, key)
+				continue
+			}
+			value = p.Value(value)
+			if ok {
+				value = rule.apply(value, p.Mask)
+			}
+			v[key] = value
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = p.Value(v[i])
+		}
+		return v
+	case string:
+		return p.String(v)
+	default:
+		return v
+	}
+}
+
+// String masks the pattern matches of s
+func (p *Policy) String(s string) string {
+	for _, pattern := range p.Patterns {
+		s = pattern.re.ReplaceAllString(s, pattern.Replacement)
+	}
+	return s
+}
+
+// apply redacts a string value, or each string of a list
+func (r Rule) apply(v interface{}, mask string) interface{} {
+	switch v := v.(type) {
+	case string:
+		if r.Action == ActionMask {
+			return mask
+		}
+		return truncate(v, r.MaxChars)
+	case []interface{}:
+		for i := range v {
+			v[i] = r.apply(v[i], mask)
+		}
+		return v
+	case nil:
+		return nil
+	default:
+		// Objects and numbers have no characters to keep
+		return mask
+	}
+}
+
+// truncate keeps the first n characters of s, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,113 @@
+package redact
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePolicies(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "redaction.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	config, err := Load(writePolicies(t, `{
+		"policies": [{"name": "analytics", "fields": {"text": {"action": "truncate"}, "email": {"action": "strip"}}}],
+		"keys": {"dash-key": "analytics", "ops-key": "none"},
+		"default": "analytics"
+	}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if p := config.PolicyFor("dash-key"); p == nil || p.Name != "analytics" || p.Fields["text"].MaxChars != DefaultMaxChars {
+		t.Errorf("policy of dash-key = %+v", p)
+	}
+	if p := config.PolicyFor("ops-key"); p != nil {
+		t.Errorf("policy of a full access key = %+v", p)
+	}
+	if p := config.PolicyFor(""); p == nil || p.Fields[HighlightsField].Action != ActionStrip {
+		t.Errorf("default policy = %+v, want highlights stripped", p)
+	}
+
+	for name, content := range map[string]string{
+		"unknown action":  `{"policies": [{"name": "a", "fields": {"text": {"action": "hide"}}}]}`,
+		"invalid pattern": `{"policies": [{"name": "a", "patterns": [{"pattern": "("}]}]}`,
+		"unknown policy":  `{"policies": [], "keys": {"k": "missing"}}`,
+		"reserved name":   `{"policies": [{"name": "none"}]}`,
+		"duplicate":       `{"policies": [{"name": "a"}, {"name": "a"}]}`,
+	} {
+		if _, err := Load(writePolicies(t, content)); err == nil {
+			t.Errorf("%s: Load() accepted %s", name, content)
+		}
+	}
+}
+
+func TestPolicy_JSON(t *testing.T) {
+	p := &Policy{
+		Name: "analytics",
+		Fields: map[string]Rule{
+			"text":  {Action: ActionTruncate, MaxChars: 10},
+			"email": {Action: ActionStrip},
+			"notes": {Action: ActionMask},
+		},
+		Patterns: []Pattern{{Pattern: `[\w.+-]+@[\w-]+\.[\w.]+`, Replacement: "[email]"}},
+	}
+	if err := (&Config{Policies: []*Policy{p}}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	redacted, err := p.JSON([]byte(`{
+		"results": [{"score": 0.91234567890123, "vector": {"id": "t1", "metadata": {"text": "Customer jane@example.com cannot log in", "email": "jane@example.com", "notes": "vip", "tier": "gold"}}, "highlights": ["cannot <em>log</em> in"], "enrichment": {"notes": {"owner": "x"}}}],
+		"meta": {"profile": "support"}
+	}
+	{"text": "short"}`))
+	if err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+
+	got := string(redacted)
+	for _, want := range []string{`"text":"Customer […"`, `0.91234567890123`, `"notes":"[redacted]"`, `"tier":"gold"`, `"redaction":"analytics"`, `{"text":"short"}`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("redacted document lacks %s: %s", want, got)
+		}
+	}
+	for _, leaked := range []string{"jane@example.com", `"email"`, "highlights", "owner"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("redacted document leaks %s: %s", leaked, got)
+		}
+	}
+}
+
+func TestPolicy_CSV(t *testing.T) {
+	p := &Policy{
+		Name: "analytics",
+		Fields: map[string]Rule{
+			"text":  {Action: ActionTruncate, MaxChars: 10},
+			"email": {Action: ActionStrip},
+			"notes": {Action: ActionMask},
+		},
+		Patterns: []Pattern{{Pattern: `[\w.+-]+@[\w-]+\.[\w.]+`, Replacement: "[email]"}, {Pattern: `\d+`, Replacement: "#"}},
+	}
+	if err := (&Config{Policies: []*Policy{p}}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	redacted, err := p.CSV([]byte("id,score,email,notes,text,tier\nt1,0.91,jane@example.com,vip,\"Customer jane@example.com, cannot log in\",gold 2\n"))
+	if err != nil {
+		t.Fatalf("CSV() error = %v", err)
+	}
+	want := "id,score,notes,text,tier\nt#,0.91,[redacted],Customer […,gold #\n"
+	if got := string(redacted); got != want {
+		t.Errorf("CSV() = %q, want %q", got, want)
+	}
+
+	if _, err := p.CSV([]byte("id,text\n\"unterminated\n")); err == nil {
+		t.Error("CSV() of a malformed document succeeded")
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// Regenerate the golden rankings after an intended ranking change with
+//
+//	go test ./internal/server -run TestGoldenRankings -update
+//
+// and review the diff of testdata/golden before committing it
+var update = flag.Bool("update", false, "rewrite the golden ranking files")
+
+// goldenCase is a query of testdata/golden/queries.json
+type goldenCase struct {
+	Name    string          `json:"name"`
+	Kind    string          `json:"kind"` // plain, advanced or temporal
+	Request json.RawMessage `json:"request"`
+}
+
+// goldenResult is a ranked result as recorded in a golden file
+type goldenResult struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// goldenScoreDecimals is the precision scores are recorded and compared at,
+// coarse enough to absorb floating point differences between platforms
+const goldenScoreDecimals = 6
+
+// TestGoldenRankings runs the golden queries through the router, handlers and
+// memory storage over a fixed corpus embedded with the hash embedder, and
+// compares the top results with the recorded rankings
+func TestGoldenRankings(t *testing.T) {
+	s := newTestServer(t)
+	loadGoldenCorpus(t, s.storage, filepath.Join("testdata", "corpus.jsonl"))
+
+	data, err := os.ReadFile(filepath.Join("testdata", "golden", "queries.json"))
+	if err != nil {
+		t.Fatalf("failed to read queries: %v", err)
+	}
+	var cases []goldenCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatalf("failed to decode queries: %v", err)
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			got := runGoldenQuery(t, s, tc)
+			path := filepath.Join("testdata", "golden", tc.Name+".json")
+
+			if *update {
+				encoded, err := json.MarshalIndent(got, "", "  ")
+				if err != nil {
+					t.Fatalf("failed to encode results: %v", err)
+				}
+				if err := os.WriteFile(path, append(encoded, '\n'), 0644); err != nil {
+					t.Fatalf("failed to write golden file: %v", err)
+				}
+				return
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read golden file (regenerate with -update): %v", err)
+			}
+			var want []goldenResult
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatalf("failed to decode golden file: %v", err)
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("got %d results, want %d\ngot:  %v\nwant: %v", len(got), len(want), got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("rank %d: got %s (%v), want %s (%v)", i+1, got[i].ID, got[i].Score, want[i].ID, want[i].Score)
+				}
+			}
+		})
+	}
+}
+
+// loadGoldenCorpus embeds and stores the corpus, one JSON document per line
+func loadGoldenCorpus(t *testing.T, s storage.Storage, path string) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open corpus: %v", err)
+	}
+	defer file.Close()
+
+	embedder := hash.NewHashEmbedder()
+	var vectors []*models.Vector
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var doc struct {
+			ID       string            `json:"id"`
+			Text     string            `json:"text"`
+			Metadata map[string]string `json:"metadata"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			t.Fatalf("failed to decode corpus line %d: %v", len(vectors)+1, err)
+		}
+		embedding, err := embedder.Embed(doc.Text)
+		if err != nil {
+			t.Fatalf("failed to embed %s: %v", doc.ID, err)
+		}
+		doc.Metadata["text"] = doc.Text
+		doc.Metadata[models.EmbedderNameKey] = embedder.Name()
+		vectors = append(vectors, &models.Vector{ID: doc.ID, Embedding: embedding, Metadata: doc.Metadata})
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read corpus: %v", err)
+	}
+
+	if err := storage.StoreBatch(s, vectors); err != nil {
+		t.Fatalf("failed to store corpus: %v", err)
+	}
+}
+
+// runGoldenQuery sends the query to its endpoint and returns the ranked results
+func runGoldenQuery(t *testing.T, s *Server, tc goldenCase) []goldenResult {
+	t.Helper()
+
+	// POST /api/v1/search is routed to text search, so advanced queries are
+	// sent to their handler directly
+	handler := http.Handler(s.router)
+	path := "/api/v1/search"
+	switch tc.Kind {
+	case "plain":
+	case "advanced":
+		handler = http.HandlerFunc(s.handler.AdvancedSearch)
+	case "temporal":
+		path = "/api/v1/search/temporal"
+	default:
+		t.Fatalf("unknown query kind %q", tc.Kind)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(tc.Request)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Matches []struct {
+			Vector models.Vector `json:"vector"`
+			Score  float64       `json:"score"`
+		} `json:"matches"`
+		Results []struct {
+			ID     string        `json:"id"`
+			Vector models.Vector `json:"vector"`
+			Score  float64       `json:"score"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	results := make([]goldenResult, 0, len(resp.Matches)+len(resp.Results))
+	for _, match := range resp.Matches {
+		results = append(results, goldenResult{ID: match.Vector.ID, Score: roundScore(match.Score)})
+	}
+	for _, result := range resp.Results {
+		id := result.ID
+		if id == "" {
+			id = result.Vector.ID
+		}
+		results = append(results, goldenResult{ID: id, Score: roundScore(result.Score)})
+	}
+	return results
+}
+
+func roundScore(score float64) float64 {
+	scale := math.Pow(10, goldenScoreDecimals)
+	return math.Round(score*scale) / scale
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/handlers"
+	"github.com/tahcohcat/same-same/internal/ui"
+)
+
+// requireAdminKey protects admin endpoints with the admin key, ADMIN_API_KEY by default
+// The key is accepted from the X-API-Key header or as a Bearer token.
+// Admin endpoints are disabled entirely when no key is configured.
+func (s *Server) requireAdminKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := s.adminKey()
+		if expected == "" {
+			http.Error(w, "admin API disabled: ADMIN_API_KEY is not configured", http.StatusForbidden)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(requestKey(r)), []byte(expected)) != 1 {
+			http.Error(w, "invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAdminRequest reports whether r presents the admin key
+func (s *Server) isAdminRequest(r *http.Request) bool {
+	admin := s.adminKey()
+	return admin != "" && subtle.ConstantTimeCompare([]byte(requestKey(r)), []byte(admin)) == 1
+}
+
+// markAdmin marks the requests presenting the admin key for the handlers, so public
+// endpoints can allow them more, such as selecting the embedder of a search
+func (s *Server) markAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.isAdminRequest(r) {
+			r = handlers.WithAdmin(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestKey returns the API key of r, from the X-API-Key header or a Bearer token
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// RedactionHeader names the redaction policy applied to a response
+const RedactionHeader = "X-Redaction-Policy"
+
+// errUnredactable is the error of a response whose content type a policy cannot redact
+var errUnredactable = errors.New("response content type cannot be redacted")
+
+// redact applies the redaction policy of the request API key, from REDACTION_POLICIES,
+// to every JSON and CSV response. It wraps the router so that no endpoint can bypass it
+// Responses of any other content type cannot be redacted and are withheld: errors keep
+// their status with its standard text, and anything else fails with a server error
+// The admin key, keys of the "none" policy and the static web UI assets are not redacted
+func (s *Server) redact(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := s.settings.Load().redaction
+		if config == nil || strings.HasPrefix(r.URL.Path, ui.Prefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Responses differ by key, so shared caches must not mix them up
+		w.Header().Add("Vary", "Authorization, X-API-Key")
+		if s.isAdminRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy := config.PolicyFor(requestKey(r))
+		if policy == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(buffered, r)
+
+		body := buffered.body.Bytes()
+		contentType := w.Header().Get("Content-Type")
+		if len(body) > 0 {
+			var redacted []byte
+			var err error
+			switch {
+			case strings.HasPrefix(contentType, "application/json"), strings.HasPrefix(contentType, "application/x-ndjson"):
+				redacted, err = policy.JSON(body)
+			case strings.HasPrefix(contentType, "text/csv"):
+				redacted, err = policy.CSV(body)
+			case buffered.status >= http.StatusMultipleChoices:
+				// Error messages may quote what the policy hides
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				redacted = []byte(http.StatusText(buffered.status) + "\n")
+			default:
+				err = errUnredactable
+			}
+			if err != nil {
+				// Failing closed: an unparsable response may hold what the policy hides
+				http.Error(w, "failed to redact response", http.StatusInternalServerError)
+				return
+			}
+			body = redacted
+		}
+		w.Header().Del("Content-Length")
+		w.Header().Set(RedactionHeader, policy.Name)
+		w.WriteHeader(buffered.status)
+		w.Write(body)
+	})
+}
+
+// bufferedResponse holds a response back until it has been redacted
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// cors answers preflight requests and sets the CORS headers of requests from the
+// origins of CORS_ALLOWED_ORIGINS. It wraps the router, since preflight requests
+// match no route. The origins are read once per request, so a reload never
+// changes the headers of a request in flight
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed, ok := s.settings.Load().allowOrigin(origin)
+		if origin == "" || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", allowed)
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-None-Match, X-API-Key")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit rejects clients sending more than RATE_LIMIT_RPS requests per second,
+// with bursts of RATE_LIMIT_BURST, with 429 Too Many Requests. The health check is
+// never limited. Clients are told apart by their remote address
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := s.settings.Load().limiter
+		if limiter == nil || r.URL.Path == "/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if ok, retry := limiter.allow(client, time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter is a token bucket per client
+type rateLimiter struct {
+	rate  float64 // Tokens added per second
+	burst float64 // Bucket capacity
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket)}
+}
+
+// allow takes a token from the bucket of client, or returns how long until one is available
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops the buckets that have refilled, at most once a minute
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gorilla/mux"
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/registry"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+// Logger receives the messages of the server, *log.Logger and logrus loggers satisfy it
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// config holds the settings of NewServerWithOptions
+type config struct {
+	storage            storage.Storage
+	embedder           embedders.Embedder
+	namespaceEmbedders map[string]embedders.Embedder
+	embedderOverrides  *registry.Cache
+	logger             Logger
+	adminKey           func() string
+	middleware         []mux.MiddlewareFunc
+	scoreAdjusters     []models.NamedAdjuster
+	ui                 bool
+}
+
+// Option configures NewServerWithOptions
+type Option func(*config) error
+
+// WithStorage sets the vector storage, in-memory storage by default
+func WithStorage(store storage.Storage) Option {
+	return func(c *config) error {
+		if store == nil {
+			return fmt.Errorf("storage cannot be nil")
+		}
+		c.storage = store
+		return nil
+	}
+}
+
+// WithEmbedder sets the embedder of queries and documents, the hash embedder by default
+func WithEmbedder(embedder embedders.Embedder) Option {
+	return func(c *config) error {
+		if embedder == nil {
+			return fmt.Errorf("embedder cannot be nil")
+		}
+		c.embedder = embedder
+		return nil
+	}
+}
+
+// WithNamespaceEmbedders sets the embedders of namespaces that do not use the default one
+func WithNamespaceEmbedders(byNamespace map[string]embedders.Embedder) Option {
+	return func(c *config) error {
+		c.namespaceEmbedders = byNamespace
+		return nil
+	}
+}
+
+// WithEmbedderOverrides sets the embedders admin search requests can select by name
+func WithEmbedderOverrides(cache *registry.Cache) Option {
+	return func(c *config) error {
+		c.embedderOverrides = cache
+		return nil
+	}
+}
+
+// WithLogger sets the logger of the server, the standard logger by default
+func WithLogger(logger Logger) Option {
+	return func(c *config) error {
+		if logger == nil {
+			return fmt.Errorf("logger cannot be nil")
+		}
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithAdminKey sets the key admin requests must present instead of ADMIN_API_KEY
+// An empty key disables the admin endpoints
+func WithAdminKey(key string) Option {
+	return func(c *config) error {
+		c.adminKey = func() string { return key }
+		return nil
+	}
+}
+
+// WithMiddleware wraps every route, including the health check, in the given
+// middleware, run in order before the admin key check
+func WithMiddleware(middleware ...mux.MiddlewareFunc) Option {
+	return func(c *config) error {
+		c.middleware = append(c.middleware, middleware...)
+		return nil
+	}
+}
+
+// WithScoreAdjuster adjusts the score of every search result with adjuster, reported
+// as name in score explanations. Adjusters run in the order they are given, after
+// hybrid weighting and temporal decay and before the score_expr of the request
+func WithScoreAdjuster(name string, adjuster models.ScoreAdjuster) Option {
+	return func(c *config) error {
+		c.scoreAdjusters = append(c.scoreAdjusters, models.NamedAdjuster{Name: name, Adjuster: adjuster})
+		return nil
+	}
+}
+
+// WithUI serves the built-in web UI under /ui/ when enabled, off by default
+func WithUI(enabled bool) Option {
+	return func(c *config) error {
+		c.ui = enabled
+		return nil
+	}
+}
+
+// envAdminKey reads ADMIN_API_KEY on each request, so the key can change without a restart
+func envAdminKey() string {
+	return os.Getenv("ADMIN_API_KEY")
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestRedaction(t *testing.T) {
+	s, path := newReloadTestServer(t, WithAdminKey("admin-key"))
+	policies := filepath.Join(filepath.Dir(path), "redaction.json")
+	writeConfig(t, policies, `{
+		"policies": [{
+			"name": "analytics",
+			"fields": {"text": {"action": "truncate", "max_chars": 20}, "email": {"action": "strip"}},
+			"patterns": [{"pattern": "[\\w.+-]+@[\\w-]+\\.[\\w.]+", "replacement": "[email]"}]
+		}],
+		"keys": {"dash-key": "analytics", "ops-key": "none"},
+		"default": "analytics"
+	}`)
+	writeConfig(t, path, "REDACTION_POLICIES="+policies+"\n")
+	if result := s.Reload(); len(result.Errors) > 0 {
+		t.Fatalf("Reload() = %+v", result)
+	}
+
+	text := "jane@example.com cannot log in after the password reset"
+	embedding, _ := s.embedder.Embed(text)
+	if err := s.storage.Store(&models.Vector{ID: "t1", Embedding: embedding, Metadata: map[string]string{"text": text, "email": "jane@example.com", "ticket": "t1"}}); err != nil {
+		t.Fatal(err)
+	}
+	send := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := send(http.MethodPut, "/api/v1/enrichment/t1", "", `{"contact": "jane@example.com", "plan": "pro"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("set enrichment: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	requests := map[string][3]string{
+		"get":             {http.MethodGet, "/api/v1/vectors/t1", ""},
+		"bulk get":        {http.MethodPost, "/api/v1/vectors/bulk-get", `{"ids": ["t1"]}`},
+		"search":          {http.MethodPost, "/api/v1/search", `{"text": "cannot log in", "highlight": true, "enrich_by": "ticket"}`},
+		"temporal search": {http.MethodPost, "/api/v1/search/temporal", `{"query": "cannot log in", "highlight": true, "enrich_by": "ticket"}`},
+	}
+	for name, request := range requests {
+		t.Run(name, func(t *testing.T) {
+			body := request[2]
+			searched := strings.Contains(body, "enrich_by")
+
+			for _, key := range []string{"dash-key", "", "unknown-key"} {
+				rec := send(request[0], request[1], key, body)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("key %q: status = %d: %s", key, rec.Code, rec.Body.String())
+				}
+				got := rec.Body.String()
+				if strings.Contains(got, "jane@example.com") || strings.Contains(got, "password") || strings.Contains(got, "highlights") {
+					t.Errorf("key %q sees unredacted text: %s", key, got)
+				}
+				if !strings.Contains(got, "[email] cannot log i…") || searched && !strings.Contains(got, `"contact":"[email]","plan":"pro"`) {
+					t.Errorf("key %q: redacted response lacks the masked text or enrichment: %s", key, got)
+				}
+				if rec.Header().Get(RedactionHeader) != "analytics" {
+					t.Errorf("key %q: %s = %q", key, RedactionHeader, rec.Header().Get(RedactionHeader))
+				}
+			}
+
+			for _, key := range []string{"ops-key", "admin-key"} {
+				rec := send(request[0], request[1], key, body)
+				got := rec.Body.String()
+				if !strings.Contains(got, text) || searched && !strings.Contains(got, "highlights") || rec.Header().Get(RedactionHeader) != "" {
+					t.Errorf("key %q does not see the original text: %s", key, got)
+				}
+			}
+		})
+	}
+
+	// CSV exports are redacted by column
+	for _, target := range []string{"/api/v1/search?format=csv", "/api/v1/vectors/search?format=csv"} {
+		body := `{"text": "cannot log in"}`
+		if strings.Contains(target, "vectors") {
+			body = fmt.Sprintf(`{"embedding": %s}`, mustJSON(t, embedding))
+		}
+		rec := send(http.MethodPost, target, "dash-key", body)
+		got := rec.Body.String()
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+			t.Fatalf("%s: status = %d, content type %s: %s", target, rec.Code, rec.Header().Get("Content-Type"), got)
+		}
+		if strings.Contains(got, "jane@example.com") || strings.Contains(got, "password") || strings.HasPrefix(got, "id,score,email") {
+			t.Errorf("%s: CSV export leaks the email or text: %s", target, got)
+		}
+		if !strings.HasPrefix(got, "id,score,text,ticket\n") || !strings.Contains(got, "[email] cannot log i…") || rec.Header().Get(RedactionHeader) != "analytics" {
+			t.Errorf("%s: CSV export lacks the masked text: %s", target, got)
+		}
+		if got := send(http.MethodPost, target, "ops-key", body).Body.String(); !strings.Contains(got, "jane@example.com") {
+			t.Errorf("%s: full access CSV export redacted: %s", target, got)
+		}
+	}
+
+	// Redacted results keep their scores
+	rec := send(http.MethodPost, "/api/v1/search", "dash-key", `{"text": "cannot log in"}`)
+	var search struct {
+		Matches []models.SearchResult `json:"matches"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &search); err != nil || len(search.Matches) != 1 || search.Matches[0].Score <= 0 {
+		t.Errorf("search = %s, %v", rec.Body.String(), err)
+	}
+
+	// Responses that cannot be redacted are withheld
+	s.router.HandleFunc("/test/unredactable", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("error") != "" {
+			http.Error(w, "no ticket for "+text, http.StatusBadRequest)
+			return
+		}
+		if contentType := r.URL.Query().Get("type"); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Write([]byte(`{"text": "` + text + `"}`))
+	})
+	for target, status := range map[string]int{
+		"/test/unredactable?type=text/plain": http.StatusInternalServerError,
+		"/test/unredactable?type=text/html":  http.StatusInternalServerError,
+		"/test/unredactable":                 http.StatusInternalServerError,
+		"/test/unredactable?error=1":         http.StatusBadRequest,
+	} {
+		rec := send(http.MethodGet, target, "dash-key", "")
+		if got := rec.Body.String(); rec.Code != status || strings.Contains(got, "jane@example.com") || strings.Contains(got, "password") {
+			t.Errorf("%s: status = %d, want %d: %s", target, rec.Code, status, got)
+		}
+		if got := send(http.MethodGet, target, "ops-key", "").Body.String(); !strings.Contains(got, text) {
+			t.Errorf("%s: full access response withheld: %s", target, got)
+		}
+	}
+
+	// A reload swaps the policies of the next requests
+	writeConfig(t, policies, `{"policies": [], "keys": {"dash-key": "none"}}`)
+	if result := s.Reload(); len(result.Errors) > 0 || len(result.Reloaded) != 1 {
+		t.Fatalf("Reload() = %+v", result)
+	}
+	if got := send(http.MethodGet, "/api/v1/vectors/t1", "dash-key", "").Body.String(); !strings.Contains(got, text) {
+		t.Errorf("policy removed by reload still applied: %s", got)
+	}
+	if err := os.Remove(policies); err != nil {
+		t.Fatal(err)
+	}
+	if result := s.Reload(); len(result.Errors) != 1 {
+		t.Errorf("Reload() of a missing policies file = %+v", result)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
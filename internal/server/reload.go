@@ -0,0 +1,416 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/synonyms"
+	"github.com/tahcohcat/same-same/internal/handlers"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/redact"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+// DefaultConfigFile is the configuration file reloads read unless CONFIG_FILE names another
+const DefaultConfigFile = ".env"
+
+// reloadableKeys are the settings a reload applies to the running server
+// Every other setting is read once at startup and needs a restart to change
+var reloadableKeys = map[string]bool{
+	"LOG_LEVEL":                 true,
+	"CORS_ALLOWED_ORIGINS":      true,
+	"RATE_LIMIT_RPS":            true,
+	"RATE_LIMIT_BURST":          true,
+	"RESULT_SET_TTL":            true,
+	"RESULT_SET_MAX":            true,
+	"BULK_GET_MAX":              true,
+	"MAX_EMBEDDING_DIMENSION":   true,
+	"SEARCH_WORKERS":            true,
+	"METADATA_KEY_FALLBACK":     true,
+	"RANKING_PROFILES":          true,
+	"REDACTION_POLICIES":        true,
+	"SYNONYMS_PATH":             true,
+	"SYNONYMS_WEIGHT":           true,
+	"SYNONYMS_EXPAND_DOCUMENTS": true,
+}
+
+// settings are the parts of the configuration read while serving requests
+// A reload swaps them as a whole, so a request sees either the old or the new ones
+type settings struct {
+	corsOrigins []string       // "*" allows any origin
+	limiter     *rateLimiter   // nil when requests are not limited
+	redaction   *redact.Config // nil when responses are not redacted
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value of origin, if allowed
+func (st *settings) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range st.corsOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// settingsFromEnv reads the CORS origins, rate limits and redaction policies of the request middleware
+func settingsFromEnv(getenv func(string) string) (*settings, error) {
+	st := &settings{}
+	for _, origin := range strings.Split(getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			st.corsOrigins = append(st.corsOrigins, origin)
+		}
+	}
+
+	if value := getenv("RATE_LIMIT_RPS"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_RPS %q: must be a non-negative number", value)
+		}
+		burst := int(rate)
+		if value := getenv("RATE_LIMIT_BURST"); value != "" {
+			if burst, err = strconv.Atoi(value); err != nil || burst <= 0 {
+				return nil, fmt.Errorf("invalid RATE_LIMIT_BURST %q: must be a positive integer", value)
+			}
+		}
+		if rate > 0 {
+			st.limiter = newRateLimiter(rate, max(burst, 1))
+		}
+	}
+
+	if path := getenv("REDACTION_POLICIES"); path != "" {
+		config, err := redact.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDACTION_POLICIES: %w", err)
+		}
+		st.redaction = config
+	}
+	return st, nil
+}
+
+// logLevelFromEnv reads LOG_LEVEL, ok is false when it is unset
+func logLevelFromEnv(getenv func(string) string) (level logrus.Level, ok bool, err error) {
+	value := getenv("LOG_LEVEL")
+	if value == "" {
+		return 0, false, nil
+	}
+	level, err = logrus.ParseLevel(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid LOG_LEVEL %q: use debug, info, warn or error", value)
+	}
+	return level, true, nil
+}
+
+// bulkGetLimitFromEnv reads BULK_GET_MAX, zero when unset
+func bulkGetLimitFromEnv(getenv func(string) string) (int, error) {
+	value := getenv("BULK_GET_MAX")
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid BULK_GET_MAX %q: must be a positive integer", value)
+	}
+	return limit, nil
+}
+
+// jobWorkersFromEnv reads JOB_WORKERS, zero when unset
+func jobWorkersFromEnv(getenv func(string) string) (int, error) {
+	value := getenv("JOB_WORKERS")
+	if value == "" {
+		return 0, nil
+	}
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers <= 0 {
+		return 0, fmt.Errorf("invalid JOB_WORKERS %q: must be a positive integer", value)
+	}
+	return workers, nil
+}
+
+// ReloadResult reports what a reload changed
+type ReloadResult struct {
+	ConfigFile string `json:"config_file"`
+
+	// Applied lists the changed settings now in effect, Reloaded the files
+	// re-read although their setting did not change
+	Applied  []string `json:"applied"`
+	Reloaded []string `json:"reloaded,omitempty"`
+
+	// Rejected lists the changed settings that need a restart, Errors the
+	// invalid values. Nothing is applied when there are errors
+	Rejected []RejectedSetting `json:"rejected,omitempty"`
+	Errors   []string          `json:"errors,omitempty"`
+}
+
+// RejectedSetting is a changed setting a reload did not apply
+type RejectedSetting struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// value returns the setting of key currently in effect
+func (s *Server) value(key string) string {
+	if value, ok := s.reloaded[key]; ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+// Reload re-reads the configuration file and applies the changed settings that are
+// safe to change at runtime: log level, CORS origins, rate limits, result set limits,
+// bulk get limit, key fallback, ranking profiles, redaction policies and synonyms. Every new value is
+// validated before any is applied. Changes to other settings, such as the storage
+// or the embedder, are rejected. Settings removed from the file keep their value
+func (s *Server) Reload() *ReloadResult {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	result := &ReloadResult{ConfigFile: s.configFile, Applied: []string{}}
+	values, err := godotenv.Read(s.configFile)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to read config file: %v", err))
+		s.logReload(result)
+		return result
+	}
+
+	changed := make(map[string]string)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if values[key] == s.value(key) {
+			continue
+		}
+		if !reloadableKeys[key] {
+			result.Rejected = append(result.Rejected, RejectedSetting{Key: key, Reason: "read at startup, restart the server to change it"})
+			continue
+		}
+		changed[key] = values[key]
+	}
+	getenv := func(key string) string {
+		if value, ok := changed[key]; ok {
+			return value
+		}
+		return s.value(key)
+	}
+
+	apply, reloaded, errs := s.prepareReload(getenv)
+	for _, err := range errs {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	if len(errs) == 0 {
+		apply()
+		for key, value := range changed {
+			s.reloaded[key] = value
+			result.Applied = append(result.Applied, key)
+		}
+		sort.Strings(result.Applied)
+		result.Reloaded = reloaded
+	}
+	s.logReload(result)
+	return result
+}
+
+// prepareReload parses and validates the settings of getenv, returning the function
+// applying them and the files it re-reads
+func (s *Server) prepareReload(getenv func(string) string) (apply func(), reloaded []string, errs []error) {
+	st, err := settingsFromEnv(getenv)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	level, levelSet, err := logLevelFromEnv(getenv)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	resultSets, err := resultSetOptionsFromEnv(getenv)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	bulkGetLimit, err := bulkGetLimitFromEnv(getenv)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	maxDimension, err := models.ParseMaxEmbeddingDimension(getenv("MAX_EMBEDDING_DIMENSION"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	searchWorkers, err := search.ParseWorkers(getenv("SEARCH_WORKERS"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// Files are re-read on every reload, since they change without their setting
+	if path := getenv("REDACTION_POLICIES"); path != "" && st != nil {
+		reloaded = append(reloaded, path)
+	}
+	var profiles []profile.Profile
+	ps, supportsProfiles := s.storage.(storage.ProfileStore)
+	if path := getenv("RANKING_PROFILES"); path != "" {
+		if !supportsProfiles {
+			errs = append(errs, fmt.Errorf("storage backend does not support ranking profiles"))
+		} else if profiles, err = profile.Load(path); err != nil {
+			errs = append(errs, fmt.Errorf("invalid ranking profiles: %w", err))
+		} else {
+			reloaded = append(reloaded, path)
+		}
+	}
+	set, err := synonyms.FromLookup(getenv)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to load synonyms: %w", err))
+	} else if set != nil {
+		reloaded = append(reloaded, set.Path())
+	}
+
+	apply = func() {
+		s.settings.Store(st)
+		if levelSet {
+			logrus.SetLevel(level)
+		}
+		s.handler.SetResultSetOptions(resultSets)
+		s.handler.SetBulkGetLimit(bulkGetLimit)
+		models.SetMaxEmbeddingDimension(maxDimension)
+		search.SetWorkers(searchWorkers)
+		s.handler.SetKeyFallback(getenv("METADATA_KEY_FALLBACK") == "true")
+		for _, p := range profiles {
+			if err := ps.SetProfile(p); err != nil {
+				s.logger.Printf("failed to reload ranking profile %s: %v", p.Name, err)
+			}
+		}
+		for _, e := range s.embedders() {
+			if expander, ok := e.(embedders.SynonymExpander); ok {
+				expander.SetSynonyms(set)
+			}
+		}
+	}
+	return apply, reloaded, errs
+}
+
+// embedders returns the default embedder followed by those of namespaces
+func (s *Server) embedders() []embedders.Embedder {
+	all := []embedders.Embedder{s.embedder}
+	for _, e := range s.namespaceEmbedders {
+		all = append(all, e)
+	}
+	return all
+}
+
+func (s *Server) logReload(result *ReloadResult) {
+	rejected := make([]string, len(result.Rejected))
+	for i, r := range result.Rejected {
+		rejected[i] = r.Key
+	}
+	if len(result.Errors) > 0 {
+		s.logger.Printf("config reload from %s failed, nothing applied: %s", result.ConfigFile, strings.Join(result.Errors, "; "))
+		return
+	}
+	s.logger.Printf("config reloaded from %s: applied [%s], rejected [%s]",
+		result.ConfigFile, strings.Join(result.Applied, " "), strings.Join(rejected, " "))
+}
+
+// reload handles POST /api/v1/admin/reload, answering 422 when nothing could be applied
+func (s *Server) reload(w http.ResponseWriter, r *http.Request) {
+	result := s.Reload()
+	w.Header().Set("Content-Type", "application/json")
+	if len(result.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// reloadDebounce groups the events of one save, which editors often split into several
+const reloadDebounce = 100 * time.Millisecond
+
+// watchConfig reloads the configuration whenever its file changes, until the process exits
+// The directory is watched rather than the file, since editors replace files on save
+func (s *Server) watchConfig() {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		s.logger.Printf("failed to watch config file: %v", err)
+		return
+	}
+	defer fsw.Close()
+
+	path, err := filepath.Abs(s.configFile)
+	if err != nil {
+		s.logger.Printf("failed to watch config file: %v", err)
+		return
+	}
+	if err := fsw.Add(filepath.Dir(path)); err != nil {
+		s.logger.Printf("failed to watch config file: %v", err)
+		return
+	}
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == path && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename)) {
+				pending = time.After(reloadDebounce)
+			}
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Printf("config file watch error: %v", err)
+		case <-pending:
+			pending = nil
+			s.Reload()
+		}
+	}
+}
+
+// setHandlerDefaults applies the reloadable handler settings of the environment at startup
+func setHandlerDefaults(handler *handlers.VectorHandler, getenv func(string) string) error {
+	resultSets, err := resultSetOptionsFromEnv(getenv)
+	if err != nil {
+		return err
+	}
+	handler.SetResultSetOptions(resultSets)
+
+	bulkGetLimit, err := bulkGetLimitFromEnv(getenv)
+	if err != nil {
+		return err
+	}
+	handler.SetBulkGetLimit(bulkGetLimit)
+
+	jobWorkers, err := jobWorkersFromEnv(getenv)
+	if err != nil {
+		return err
+	}
+	handler.SetJobWorkers(jobWorkers)
+
+	maxDimension, err := models.ParseMaxEmbeddingDimension(getenv("MAX_EMBEDDING_DIMENSION"))
+	if err != nil {
+		return err
+	}
+	models.SetMaxEmbeddingDimension(maxDimension)
+
+	searchWorkers, err := search.ParseWorkers(getenv("SEARCH_WORKERS"))
+	if err != nil {
+		return err
+	}
+	search.SetWorkers(searchWorkers)
+	handler.SetKeyFallback(getenv("METADATA_KEY_FALLBACK") == "true")
+	return nil
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+// newReloadTestServer returns a server reading its config from a file of a temporary directory
+func newReloadTestServer(t *testing.T, opts ...Option) (*Server, string) {
+	t.Helper()
+	opts = append([]Option{WithStorage(memory.NewStorage()), WithEmbedder(hash.NewHashEmbedder())}, opts...)
+	s, err := NewServerWithOptions(opts...)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	s.configFile = filepath.Join(t.TempDir(), "same-same.env")
+	return s, s.configFile
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload_CORSOriginsApplyToNewRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	block := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("block") != "" {
+				close(started)
+				<-release
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	s, path := newReloadTestServer(t, WithMiddleware(block))
+	writeConfig(t, path, "CORS_ALLOWED_ORIGINS=https://old.example\n")
+	if result := s.Reload(); len(result.Errors) > 0 || len(result.Applied) != 1 {
+		t.Fatalf("Reload() = %+v", result)
+	}
+
+	get := func(origin, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// A request in flight during the reload keeps the headers it started with
+	inFlight := make(chan *httptest.ResponseRecorder)
+	go func() { inFlight <- get("https://old.example", "/health?block=1") }()
+	<-started
+
+	writeConfig(t, path, "CORS_ALLOWED_ORIGINS=https://new.example\n")
+	if result := s.Reload(); len(result.Errors) > 0 {
+		t.Fatalf("Reload() = %+v", result)
+	}
+	close(release)
+	if rec := <-inFlight; rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://old.example" {
+		t.Errorf("in-flight request: status = %d, headers %v", rec.Code, rec.Header())
+	}
+
+	if rec := get("https://old.example", "/health"); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("removed origin still allowed: %v", rec.Header())
+	}
+	if rec := get("https://new.example", "/health"); rec.Header().Get("Access-Control-Allow-Origin") != "https://new.example" {
+		t.Errorf("added origin not allowed: %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/search", nil)
+	req.Header.Set("Origin", "https://new.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("preflight: status = %d, headers %v", rec.Code, rec.Header())
+	}
+}
+
+func TestReload_RateLimit(t *testing.T) {
+	s, path := newReloadTestServer(t)
+	writeConfig(t, path, "RATE_LIMIT_RPS=1\nRATE_LIMIT_BURST=2\n")
+	s.Reload()
+
+	get := func() int {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/count", nil))
+		return rec.Code
+	}
+	if get() != http.StatusOK || get() != http.StatusOK || get() != http.StatusTooManyRequests {
+		t.Fatal("burst of 2 not enforced")
+	}
+
+	writeConfig(t, path, "RATE_LIMIT_RPS=0\nRATE_LIMIT_BURST=2\n")
+	if result := s.Reload(); len(result.Applied) != 1 || result.Applied[0] != "RATE_LIMIT_RPS" {
+		t.Fatalf("Reload() = %+v", result)
+	}
+	if code := get(); code != http.StatusOK {
+		t.Errorf("status after lifting the limit = %d", code)
+	}
+}
+
+func TestReload_RejectsStartupSettings(t *testing.T) {
+	s, path := newReloadTestServer(t)
+	writeConfig(t, path, "STORAGE_TYPE=local\nBULK_GET_MAX=5\n")
+
+	result := s.Reload()
+	if len(result.Rejected) != 1 || result.Rejected[0].Key != "STORAGE_TYPE" {
+		t.Errorf("rejected = %+v, want STORAGE_TYPE", result.Rejected)
+	}
+	if len(result.Applied) != 1 || result.Applied[0] != "BULK_GET_MAX" {
+		t.Errorf("applied = %v, want BULK_GET_MAX", result.Applied)
+	}
+}
+
+func TestReload_InvalidValueAppliesNothing(t *testing.T) {
+	s, path := newReloadTestServer(t, WithAdminKey("secret"))
+	writeConfig(t, path, "CORS_ALLOWED_ORIGINS=*\nRESULT_SET_TTL=soon\n")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/reload", nil)
+	req.Header.Set("X-API-Key", "secret")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	var result ReloadResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid response: %s", rec.Body.String())
+	}
+	if rec.Code != http.StatusUnprocessableEntity || len(result.Errors) != 1 || len(result.Applied) != 0 {
+		t.Errorf("reload: status = %d, result %+v", rec.Code, result)
+	}
+	if _, ok := s.settings.Load().allowOrigin("https://any.example"); ok {
+		t.Error("CORS origins applied despite an invalid setting")
+	}
+}
+
+func TestReload_LogLevel(t *testing.T) {
+	defer logrus.SetLevel(logrus.GetLevel())
+	s, path := newReloadTestServer(t)
+	writeConfig(t, path, "LOG_LEVEL=warn\n")
+
+	if result := s.Reload(); len(result.Errors) > 0 || logrus.GetLevel() != logrus.WarnLevel {
+		t.Errorf("Reload() = %+v, level %s", result, logrus.GetLevel())
+	}
+	writeConfig(t, path, "LOG_LEVEL=loud\n")
+	if result := s.Reload(); len(result.Errors) != 1 || logrus.GetLevel() != logrus.WarnLevel {
+		t.Errorf("Reload() = %+v, level %s", result, logrus.GetLevel())
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/tiered"
+	"github.com/tahcohcat/same-same/internal/version"
+)
+
+// RuntimeConfig is the effective configuration of a running server, as resolved
+// from options and the environment. It holds no secrets: keys are only reported
+// as configured or not
+type RuntimeConfig struct {
+	Version            version.Info              `json:"version"`
+	Storage            StorageConfig             `json:"storage"`
+	Embedder           EmbedderConfig            `json:"embedder"`
+	NamespaceEmbedders map[string]EmbedderConfig `json:"namespace_embedders,omitempty"`
+	DefaultMetadata    *defaults.Config          `json:"default_metadata,omitempty"`
+	AdminAuth          bool                      `json:"admin_auth"` // Whether an admin key is configured
+	UI                 bool                      `json:"ui"`
+}
+
+// StorageConfig describes the storage backend
+type StorageConfig struct {
+	Type       string `json:"type"`
+	Path       string `json:"path,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	Metric     string `json:"metric,omitempty"`
+	Dimension  int    `json:"dimension,omitempty"`
+}
+
+// EmbedderConfig describes an embedder
+type EmbedderConfig struct {
+	Name      string `json:"name"`
+	Model     string `json:"model,omitempty"`
+	Dimension int    `json:"dimension,omitempty"`
+}
+
+// RuntimeConfig returns the effective configuration of the server
+func (s *Server) RuntimeConfig() RuntimeConfig {
+	rc := RuntimeConfig{
+		Version:         version.Get(),
+		Embedder:        embedderConfig(s.embedder),
+		AdminAuth:       s.adminKey() != "",
+		UI:              s.ui,
+		DefaultMetadata: s.handler.DefaultMetadata(),
+	}
+
+	switch store := s.storage.(type) {
+	case *local.VectorStorageAdapter:
+		vc := store.VectorConfig()
+		rc.Storage = StorageConfig{
+			Type:       "local",
+			Path:       store.Path(),
+			Collection: store.Collection(),
+			Metric:     vc.Metric,
+			Dimension:  vc.Dimension,
+		}
+	case *tiered.Storage:
+		vc := store.VectorConfig()
+		rc.Storage = StorageConfig{
+			Type:       "tiered",
+			Path:       store.Path(),
+			Collection: store.Collection(),
+			Metric:     vc.Metric,
+			Dimension:  vc.Dimension,
+		}
+	case *memory.Storage:
+		rc.Storage = StorageConfig{Type: "memory"}
+	default:
+		rc.Storage = StorageConfig{Type: fmt.Sprintf("%T", store)}
+	}
+
+	if len(s.namespaceEmbedders) > 0 {
+		rc.NamespaceEmbedders = make(map[string]EmbedderConfig, len(s.namespaceEmbedders))
+		for namespace, embedder := range s.namespaceEmbedders {
+			rc.NamespaceEmbedders[namespace] = embedderConfig(embedder)
+		}
+	}
+	return rc
+}
+
+func embedderConfig(embedder embedders.Embedder) EmbedderConfig {
+	ec := EmbedderConfig{Name: embedder.Name()}
+	if modeled, ok := embedder.(embedders.Modeled); ok {
+		ec.Model = modeled.Model()
+	}
+	if dimensioned, ok := embedder.(embedders.Dimensioned); ok {
+		ec.Dimension = dimensioned.Dimensions()
+	}
+	return ec
+}
+
+// LogFields returns the configuration as key=value pairs for the startup log line
+func (rc RuntimeConfig) LogFields() string {
+	fields := []string{
+		"version=" + rc.Version.Version,
+		"commit=" + valueOr(rc.Version.Commit, "unknown"),
+		"storage=" + rc.Storage.Type,
+	}
+	if rc.Storage.Path != "" {
+		fields = append(fields, "path="+rc.Storage.Path, "collection="+rc.Storage.Collection)
+	}
+	fields = append(fields, "embedder="+rc.Embedder.Name)
+	if rc.Embedder.Model != "" {
+		fields = append(fields, "model="+rc.Embedder.Model)
+	}
+	if rc.Embedder.Dimension > 0 {
+		fields = append(fields, fmt.Sprintf("dimension=%d", rc.Embedder.Dimension))
+	}
+
+	namespaces := make([]string, 0, len(rc.NamespaceEmbedders))
+	for namespace, embedder := range rc.NamespaceEmbedders {
+		namespaces = append(namespaces, namespace+":"+embedder.Name)
+	}
+	sort.Strings(namespaces)
+	if len(namespaces) > 0 {
+		fields = append(fields, "namespace_embedders="+strings.Join(namespaces, ","))
+	}
+
+	fields = append(fields, fmt.Sprintf("admin_auth=%t", rc.AdminAuth), fmt.Sprintf("ui=%t", rc.UI))
+	return strings.Join(fields, " ")
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// getConfig serves the effective configuration of the server
+func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.RuntimeConfig()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
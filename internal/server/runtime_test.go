@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+func TestGetConfig(t *testing.T) {
+	dir := t.TempDir()
+	store, err := local.NewVectorStorageAdapter(dir, "quotes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	const key = "s3cret-admin-key-value"
+	s, err := NewServerWithOptions(
+		WithStorage(store),
+		WithEmbedder(hash.NewHashEmbedder()),
+		WithNamespaceEmbedders(map[string]embedders.Embedder{"docs": hash.NewHashEmbedder()}),
+		WithAdminKey(key),
+		WithUI(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status without key = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil)
+	req.Header.Set("X-API-Key", key)
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if body := rec.Body.String(); strings.Contains(body, key) || strings.Contains(body, key[:6]) {
+		t.Fatalf("config leaks the admin key: %s", body)
+	}
+
+	var rc RuntimeConfig
+	if err := json.Unmarshal(rec.Body.Bytes(), &rc); err != nil {
+		t.Fatal(err)
+	}
+	if rc.Storage.Type != "local" || rc.Storage.Path != dir || rc.Storage.Collection != "quotes" {
+		t.Errorf("storage = %+v", rc.Storage)
+	}
+	if rc.Embedder.Name != "local.hash" || rc.Embedder.Dimension == 0 {
+		t.Errorf("embedder = %+v", rc.Embedder)
+	}
+	if rc.NamespaceEmbedders["docs"].Name != "local.hash" {
+		t.Errorf("namespace embedders = %+v", rc.NamespaceEmbedders)
+	}
+	if !rc.AdminAuth || !rc.UI || rc.Version.Version == "" {
+		t.Errorf("config = %+v", rc)
+	}
+}
+
+func TestRuntimeConfig_LogFields(t *testing.T) {
+	s := newTestServer(t)
+
+	fields := s.RuntimeConfig().LogFields()
+	for _, want := range []string{"version=", "commit=", "storage=memory", "embedder=local.hash", "admin_auth=", "ui=false"} {
+		if !strings.Contains(fields, want) {
+			t.Errorf("log fields %q lack %q", fields, want)
+		}
+	}
+}
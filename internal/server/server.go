@@ -1,64 +1,411 @@
 package server
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
 	"github.com/tahcohcat/same-same/internal/embedders"
-	"github.com/tahcohcat/same-same/internal/embedders/quotes/gemini"
-	"github.com/tahcohcat/same-same/internal/embedders/quotes/huggingface"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
 	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/tfidf"
+	"github.com/tahcohcat/same-same/internal/embedders/registry"
+	"github.com/tahcohcat/same-same/internal/embedders/synonyms"
 	"github.com/tahcohcat/same-same/internal/handlers"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/snapshot"
 	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/defaults"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/tracing"
+	"github.com/tahcohcat/same-same/internal/ui"
 )
 
 type Server struct {
-	storage storage.Storage
-	handler *handlers.VectorHandler
-	router  *mux.Router
+	storage  storage.Storage
+	handler  *handlers.VectorHandler
+	router   *mux.Router
+	logger   Logger
+	adminKey func() string
+
+	// snapshotSchedule takes named snapshots periodically, when its interval is set
+	snapshotSchedule snapshotSchedule
+
+	// settings are swapped by Reload, which reads configFile and records the values it
+	// applied in reloaded. watch reloads whenever configFile changes
+	settings   atomic.Pointer[settings]
+	configFile string
+	watch      bool
+	reloadMu   sync.Mutex
+	reloaded   map[string]string
+
+	// Reported by RuntimeConfig
+	embedder           embedders.Embedder
+	namespaceEmbedders map[string]embedders.Embedder
+	ui                 bool
 }
 
-func NewServer() *Server {
-	store, err := storage.NewStorageFromEnv()
+// NewServer creates a server configured from the environment: the embedders,
+// storage backend, response format, unique keys and ranking profiles
+func NewServer() (*Server, error) {
+	logger := log.Default()
+
+	configFile := DefaultConfigFile
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := godotenv.Load(path); err != nil {
+			return nil, fmt.Errorf("failed to load CONFIG_FILE: %w", err)
+		}
+		configFile = path
+	}
+	level, levelSet, err := logLevelFromEnv(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	if levelSet {
+		logrus.SetLevel(level)
+	}
+
+	namespaceConfig, err := registry.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace embedders: %w", err)
+	}
+	spec := registry.Spec{Type: os.Getenv("EMBEDDER_TYPE")}
+	if namespaceConfig != nil {
+		spec = namespaceConfig.Default
+	}
+	synonymSet, err := synonymsFromEnv(logger)
+	if err != nil {
+		return nil, err
+	}
+	embedder, err := createEmbedder(spec, synonymSet, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := storage.NewStorageFromEnvWithConfig(vectorConfigFor(embedder))
 	if err != nil {
-		log.Fatalf("failed to initialize storage adapter: %v", err)
+		return nil, fmt.Errorf("failed to initialize storage adapter: %w", err)
 	}
 
-	handler := handlers.NewVectorHandler(store, CreateEmbedder(os.Getenv("EMBEDDER_TYPE")))
-	router := mux.NewRouter()
+	opts := []Option{WithStorage(store), WithEmbedder(embedder), WithLogger(logger), WithUI(os.Getenv("UI_ENABLED") == "true")}
+	if namespaceConfig != nil {
+		namespaced, err := namespaceConfig.Build()
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace embedders: %w", err)
+		}
+		for namespace, e := range namespaced {
+			attachSynonyms(e, synonymSet)
+			logger.Printf("namespace %s embedded with %s", namespace, e.Name())
+		}
+		opts = append(opts, WithNamespaceEmbedders(namespaced))
 
-	server := &Server{
-		storage: store,
-		handler: handler,
-		router:  router,
+		if len(namespaceConfig.Overrides) > 0 {
+			overrides, err := embedderOverridesFromEnv(namespaceConfig.Overrides, os.Getenv)
+			if err != nil {
+				return nil, err
+			}
+			logger.Printf("search requests with the admin key can select the embedders %s", strings.Join(overrides.Names(), ", "))
+			opts = append(opts, WithEmbedderOverrides(overrides))
+		}
 	}
 
+	server, err := NewServerWithOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	handler := server.handler
+
+	format, err := responseFormatFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid response format: %w", err)
+	}
+	if err := handler.SetResponseFormat(format); err != nil {
+		return nil, fmt.Errorf("invalid response format: %w", err)
+	}
+
+	// Metadata key handling is off by default so existing data and clients behave as before
+	handler.SetNormalizeKeys(os.Getenv("METADATA_NORMALIZE_KEYS") == "true")
+	handler.SetSparseEmbeddings(os.Getenv("SPARSE_EMBEDDINGS") == "true")
+	defaultMetadata, err := defaults.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid DEFAULT_METADATA: %w", err)
+	}
+	handler.SetDefaultMetadata(defaultMetadata)
+	if err := setQuarantine(handler, store, os.Getenv); err != nil {
+		return nil, err
+	}
+
+	if err := setHandlerDefaults(handler, os.Getenv); err != nil {
+		return nil, err
+	}
+
+	st, err := settingsFromEnv(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	server.settings.Store(st)
+	server.configFile = configFile
+	server.watch = os.Getenv("CONFIG_WATCH") == "true"
+
+	library, schedule, err := snapshotsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if library != nil {
+		handler.SetSnapshotLibrary(library)
+		server.snapshotSchedule = schedule
+		logger.Printf("named snapshots kept in %s", library.Dir())
+	}
+
+	if fields := os.Getenv("UNIQUE_KEYS"); fields != "" {
+		if err := setUniqueKeys(store, fields); err != nil {
+			return nil, fmt.Errorf("invalid UNIQUE_KEYS: %w", err)
+		}
+	}
+
+	if err := loadProfiles(store, os.Getenv("RANKING_PROFILES"), logger); err != nil {
+		return nil, fmt.Errorf("invalid ranking profiles: %w", err)
+	}
+
+	return server, nil
+}
+
+// NewServerWithOptions creates a server over the given storage and embedder with
+// the default handler settings. Unlike NewServer it reads no environment apart from
+// ADMIN_API_KEY, for tests and applications embedding the server
+func NewServerWithOptions(opts ...Option) (*Server, error) {
+	c := config{logger: log.Default(), adminKey: envAdminKey}
+	for _, opt := range opts {
+		if err := opt(&c); err != nil {
+			return nil, err
+		}
+	}
+	if c.storage == nil {
+		c.storage = memory.NewStorage()
+	}
+	if c.embedder == nil {
+		c.embedder = hash.NewHashEmbedder()
+	}
+	// Queries embed into the vocabulary the stored vectors were embedded with
+	if loaded, err := storage.LoadVocabulary(c.storage, c.embedder); err != nil {
+		return nil, fmt.Errorf("failed to load the %s vocabulary: %w", c.embedder.Name(), err)
+	} else if loaded {
+		c.logger.Printf("loaded the %s vocabulary saved with the stored vectors", c.embedder.Name())
+	}
+
+	handler := handlers.NewVectorHandler(c.storage, c.embedder)
+	if c.namespaceEmbedders != nil {
+		handler.SetNamespaceEmbedders(c.namespaceEmbedders)
+	}
+	if c.embedderOverrides != nil {
+		handler.SetEmbedderOverrides(c.embedderOverrides)
+	}
+	for _, a := range c.scoreAdjusters {
+		if err := handler.AddScoreAdjuster(a.Name, a.Adjuster); err != nil {
+			return nil, err
+		}
+	}
+
+	server := &Server{
+		storage:  c.storage,
+		handler:  handler,
+		router:   mux.NewRouter(),
+		logger:   c.logger,
+		adminKey: c.adminKey,
+
+		embedder:           c.embedder,
+		namespaceEmbedders: c.namespaceEmbedders,
+		ui:                 c.ui,
+
+		configFile: DefaultConfigFile,
+		reloaded:   make(map[string]string),
+	}
+	server.settings.Store(&settings{})
+	// A no-op unless tracing is enabled
+	server.router.Use(tracing.Middleware)
+	server.router.Use(server.markAdmin)
+	server.router.Use(c.middleware...)
 	server.setupRoutes()
-	return server
+	if c.ui {
+		server.setupUI()
+	}
+	return server, nil
+}
+
+// Handler returns the router serving the API, behind the CORS, rate limit and redaction middleware
+func (s *Server) Handler() http.Handler {
+	return s.cors(s.rateLimit(s.redact(s.router)))
 }
 
 func (s *Server) setupRoutes() {
+	s.router.NotFoundHandler = http.HandlerFunc(handlers.NotFound)
 	api := s.router.PathPrefix("/api/v1").Subrouter()
 
 	api.HandleFunc("/vectors/embed", s.handler.EmbedVector).Methods("POST")
 	api.HandleFunc("/vectors/count", s.handler.CountVectors).Methods("GET")
+	api.HandleFunc("/vectors/generation", s.handler.GetGeneration).Methods("GET")
 	api.HandleFunc("/vectors", s.handler.CreateVector).Methods("POST")
+	api.HandleFunc("/vectors/batch", s.handler.StoreVectorBatch).Methods("POST")
+	api.HandleFunc("/vectors/bulk-get", s.handler.BulkGetVectors).Methods("POST")
 	api.HandleFunc("/vectors", s.handler.ListVectors).Methods("GET")
 	api.HandleFunc("/vectors/metadata", s.handler.ListVectorMetadata).Methods("GET")
-	api.HandleFunc("/vectors/{id}", s.handler.GetVector).Methods("GET")
-	api.HandleFunc("/vectors/{id}", s.handler.UpdateVector).Methods("PUT")
-	api.HandleFunc("/vectors/{id}", s.handler.DeleteVector).Methods("DELETE")
+	api.HandleFunc("/vectors/recent", s.handler.ListRecentVectors).Methods("GET")
+	api.HandleFunc("/vectors/by/{field}/{value}", s.handler.GetVectorByKey).Methods("GET")
+	api.HandleFunc("/vectors/by/{field}/{value}", s.handler.UpsertVectorByKey).Methods("PUT")
+	api.HandleFunc("/vectors/{id}", s.handler.GetVector).Methods("GET").MatcherFunc(vectorIDMatcher)
+	api.HandleFunc("/vectors/{id}/provenance", s.handler.GetProvenance).Methods("GET").MatcherFunc(vectorIDMatcher)
+	api.HandleFunc("/vectors/{id}", s.handler.UpdateVector).Methods("PUT").MatcherFunc(vectorIDMatcher)
+	api.HandleFunc("/vectors/{id}", s.handler.DeleteVector).Methods("DELETE").MatcherFunc(vectorIDMatcher)
 	api.HandleFunc("/vectors/search", s.handler.SearchVectors).Methods("POST")
-	api.HandleFunc("/search", s.handler.SearchByText).Methods("POST")
-	api.HandleFunc("/search", s.handler.AdvancedSearch).Methods("POST")
-	// api.HandleFunc("/search/temporal", s.handler.TemporalSearch).Methods("POST") // Temporal-aware search (TODO: implement)
+	api.HandleFunc("/search", s.handler.Search).Methods("POST")
+	api.HandleFunc("/search/temporal", s.handler.TemporalSearch).Methods("POST")
+	api.HandleFunc("/analysis/trend", s.handler.AnalyzeTrend).Methods("POST")
+	api.HandleFunc("/ingest/runs", s.handler.ListIngestRuns).Methods("GET")
+	api.HandleFunc("/metadata/schema", s.handler.GetMetadataSchema).Methods("GET")
+	api.HandleFunc("/metadata/facets/{field}", s.handler.GetFacet).Methods("GET")
+	api.HandleFunc("/enrichment", s.handler.UploadEnrichment).Methods("POST")
+	api.HandleFunc("/enrichment/{key}", s.handler.GetEnrichment).Methods("GET")
+	api.HandleFunc("/enrichment/{key}", s.handler.SetEnrichment).Methods("PUT")
+	api.HandleFunc("/enrichment/{key}", s.handler.DeleteEnrichment).Methods("DELETE")
+	api.HandleFunc("/profiles", s.handler.ListProfiles).Methods("GET")
+	api.HandleFunc("/profiles/{name}", s.handler.GetProfile).Methods("GET")
+	api.HandleFunc("/eval/sets", s.handler.ListEvalSets).Methods("GET")
+	api.Handle("/eval/sets", s.requireAdminKey(http.HandlerFunc(s.handler.CreateEvalSet))).Methods("POST")
+	api.HandleFunc("/eval/sets/{name}", s.handler.GetEvalSet).Methods("GET")
+	api.Handle("/eval/sets/{name}", s.requireAdminKey(http.HandlerFunc(s.handler.DeleteEvalSet))).Methods("DELETE")
+	api.HandleFunc("/eval/sets/{name}/run", s.handler.RunEvalSet).Methods("POST")
+	api.HandleFunc("/eval/sets/{name}/runs", s.handler.ListEvalRuns).Methods("GET")
+	api.HandleFunc("/aliases", s.handler.ListAliases).Methods("GET")
+	api.Handle("/aliases/{alias}", s.requireAdminKey(http.HandlerFunc(s.handler.SetAlias))).Methods("PUT")
+	api.Handle("/aliases/{alias}", s.requireAdminKey(http.HandlerFunc(s.handler.DeleteAlias))).Methods("DELETE")
+	api.Handle("/aliases/{alias}/swap", s.requireAdminKey(http.HandlerFunc(s.handler.SwapAlias))).Methods("POST")
+	api.HandleFunc("/watches", s.handler.ListWatches).Methods("GET")
+	api.Handle("/watches", s.requireAdminKey(http.HandlerFunc(s.handler.CreateWatch))).Methods("POST")
+	api.HandleFunc("/watches/metrics", s.handler.GetWatchMetrics).Methods("GET")
+	api.HandleFunc("/watches/{id}", s.handler.GetWatch).Methods("GET")
+	api.Handle("/watches/{id}", s.requireAdminKey(http.HandlerFunc(s.handler.UpdateWatch))).Methods("PATCH")
+	api.Handle("/watches/{id}", s.requireAdminKey(http.HandlerFunc(s.handler.DeleteWatch))).Methods("DELETE")
+	api.HandleFunc("/watches/{id}/matches", s.handler.ListWatchMatches).Methods("GET")
 
 	api.HandleFunc("/embedder/stats", s.handler.GetEmbedderStats).Methods("GET")
+	// Introspection can leak corpus content, so it is admin-only
+	api.Handle("/embedder/vocabulary", s.requireAdminKey(http.HandlerFunc(s.handler.GetVocabulary))).Methods("GET")
+	api.Handle("/embedder/analyze", s.requireAdminKey(http.HandlerFunc(s.handler.AnalyzeText))).Methods("POST")
+
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(s.requireAdminKey)
+	admin.HandleFunc("/synonyms/reload", s.handler.ReloadSynonyms).Methods("POST")
+	admin.HandleFunc("/snapshot", s.handler.ExportSnapshot).Methods("GET")
+	admin.HandleFunc("/restore", s.handler.RestoreSnapshot).Methods("POST")
+	admin.HandleFunc("/snapshots", s.handler.ListSnapshots).Methods("GET")
+	admin.HandleFunc("/snapshots", s.handler.CreateSnapshot).Methods("POST")
+	admin.HandleFunc("/snapshots/{name}", s.handler.DeleteSnapshot).Methods("DELETE")
+	admin.HandleFunc("/reconcile", s.handler.ReconcileStorage).Methods("POST")
+	admin.HandleFunc("/verify", s.handler.VerifyStorage).Methods("GET")
+	admin.HandleFunc("/flush", s.handler.FlushStorage).Methods("POST")
+	admin.HandleFunc("/knn-graph", s.handler.ExportKNNGraph).Methods("GET")
+	admin.HandleFunc("/embed-pending", s.handler.EmbedPending).Methods("POST")
+	admin.HandleFunc("/quotas", s.handler.GetQuotas).Methods("GET")
+	admin.HandleFunc("/quotas", s.handler.SetQuota).Methods("PUT")
+	admin.HandleFunc("/memory", s.handler.GetMemory).Methods("GET")
+	admin.HandleFunc("/dimensions", s.handler.GetDimensionReport).Methods("GET")
+	admin.HandleFunc("/config", s.getConfig).Methods("GET")
+	admin.HandleFunc("/reload", s.reload).Methods("POST")
+	admin.HandleFunc("/unique-keys", s.handler.GetUniqueKeys).Methods("GET")
+	admin.HandleFunc("/unique-keys", s.handler.SetUniqueKeys).Methods("PUT")
+	admin.HandleFunc("/profiles/{name}", s.handler.SetProfile).Methods("PUT")
+	admin.HandleFunc("/profiles/{name}", s.handler.DeleteProfile).Methods("DELETE")
+	admin.HandleFunc("/bulk", s.handler.StartBulkJob).Methods("POST")
+	admin.HandleFunc("/bulk", s.handler.ListBulkJobs).Methods("GET")
+	admin.HandleFunc("/bulk/{id}", s.handler.GetBulkJob).Methods("GET")
+	admin.HandleFunc("/bulk/{id}", s.handler.CancelBulkJob).Methods("DELETE")
+	admin.HandleFunc("/quarantine", s.handler.ListQuarantine).Methods("GET")
+	admin.HandleFunc("/quarantine/retry", s.handler.RetryQuarantine).Methods("POST")
+	admin.HandleFunc("/jobs", s.handler.SubmitJob).Methods("POST")
+	admin.HandleFunc("/jobs", s.handler.ListJobs).Methods("GET")
+	admin.HandleFunc("/jobs/{id}", s.handler.GetJob).Methods("GET")
+	admin.HandleFunc("/jobs/{id}", s.handler.CancelJob).Methods("DELETE")
+	admin.HandleFunc("/jobs/{id}/retry", s.handler.RetryJob).Methods("POST")
+
 	s.router.HandleFunc("/health", s.healthCheck).Methods("GET")
 }
 
+// setupUI serves the web UI, redirecting /ui to its index page
+func (s *Server) setupUI() {
+	s.router.Handle("/ui", http.RedirectHandler(ui.Prefix, http.StatusMovedPermanently)).Methods("GET", "HEAD")
+	s.router.PathPrefix(ui.Prefix).Handler(ui.Handler()).Methods("GET", "HEAD")
+	s.logger.Printf("web UI enabled at %s", ui.Prefix)
+}
+
+// vectorIDMatcher keeps reserved sub-paths such as "metadata" from being matched as vector IDs
+// Requests for them with another method get a 405 rather than a failed ID lookup
+// IDs containing a slash never match, since {id} stops at the first one
+func vectorIDMatcher(r *http.Request, _ *mux.RouteMatch) bool {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/vectors/")
+	id, _, _ := strings.Cut(rest, "/")
+	return !handlers.IsReservedVectorPath(id)
+}
+
+// setUniqueKeys declares the comma separated unique metadata fields of UNIQUE_KEYS
+func setUniqueKeys(store storage.Storage, fields string) error {
+	indexer, ok := store.(storage.UniqueKeyIndexer)
+	if !ok {
+		return fmt.Errorf("storage backend does not support unique keys")
+	}
+
+	var keys []string
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			keys = append(keys, field)
+		}
+	}
+	return indexer.SetUniqueKeys(keys)
+}
+
+// loadProfiles validates the stored ranking profiles and stores those of the
+// JSON file at path, replacing stored profiles of the same name
+func loadProfiles(store storage.Storage, path string, logger Logger) error {
+	ps, ok := store.(storage.ProfileStore)
+	if !ok {
+		if path != "" {
+			return fmt.Errorf("storage backend does not support ranking profiles")
+		}
+		return nil
+	}
+
+	for _, p := range ps.Profiles() {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if path == "" {
+		return nil
+	}
+	profiles, err := profile.Load(path)
+	if err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		if err := ps.SetProfile(p); err != nil {
+			return err
+		}
+	}
+	logger.Printf("loaded %d ranking profiles from %s", len(profiles), path)
+	return nil
+}
+
 func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -66,26 +413,276 @@ func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) Start(addr string) error {
-	log.Printf("starting server on :%s", addr)
-	return http.ListenAndServe(addr, s.router)
+	go s.scheduleEvals(evalCheckInterval)
+	if s.snapshotSchedule.Interval > 0 {
+		go s.scheduleSnapshots(s.snapshotSchedule)
+	}
+	if resumed, err := s.handler.ResumeJobs(); err != nil {
+		s.logger.Printf("failed to resume jobs: %v", err)
+	} else if resumed > 0 {
+		s.logger.Printf("resumed %d unfinished jobs", resumed)
+	}
+	if scheduled := s.handler.ResumeAliasDeletions(); scheduled > 0 {
+		s.logger.Printf("scheduled the deletion of %d namespaces swapped out of aliases", scheduled)
+	}
+	if s.watch {
+		go s.watchConfig()
+	}
+	if loaded, err := s.handler.LoadWatches(); err != nil {
+		s.logger.Printf("failed to load watches: %v", err)
+	} else if loaded > 0 {
+		s.logger.Printf("loaded %d watches", loaded)
+	}
+
+	s.logger.Printf("effective config: %s", s.RuntimeConfig().LogFields())
+	s.logger.Printf("starting server on :%s", addr)
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// Close closes the storage, writing out what it holds back, such as the write-behind
+// queue of tiered storage
+func (s *Server) Close() error {
+	if closer, ok := s.storage.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// evalCheckInterval is how often the server looks for scheduled evaluation sets due to run
+const evalCheckInterval = time.Minute
+
+// scheduleEvals runs the scheduled evaluation sets as they fall due
+func (s *Server) scheduleEvals(interval time.Duration) {
+	if _, ok := s.storage.(storage.EvalStore); !ok {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		runs, errs := s.handler.RunDueEvals(now)
+		for _, run := range runs {
+			s.logger.Printf("evaluation set %s: recall %.3f, precision %.3f, mrr %.3f, ndcg %.3f",
+				run.Set, run.Metrics.Recall, run.Metrics.Precision, run.Metrics.MRR, run.Metrics.NDCG)
+		}
+		for _, err := range errs {
+			s.logger.Printf("scheduled evaluation failed: %v", err)
+		}
+	}
 }
 
-func CreateEmbedder(eType string) embedders.Embedder {
+// snapshotSchedule takes a snapshot of the whole store every Interval, named by
+// the date it was taken and Label
+type snapshotSchedule struct {
+	Interval time.Duration
+	Label    string
+}
+
+// scheduleSnapshots takes the scheduled snapshots until the process exits
+func (s *Server) scheduleSnapshots(schedule snapshotSchedule) {
+	ticker := time.NewTicker(schedule.Interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		name := snapshot.ScheduledName(now, schedule.Interval, schedule.Label)
+		if _, err := s.handler.TakeSnapshot(name, ""); err != nil {
+			s.logger.Printf("scheduled snapshot %s failed: %v", name, err)
+		}
+	}
+}
+
+// CreateEmbedder creates the embedder of a type with the synonyms of SYNONYMS_PATH attached
+func CreateEmbedder(eType string) (embedders.Embedder, error) {
+	set, err := synonymsFromEnv(log.Default())
+	if err != nil {
+		return nil, err
+	}
+	return createEmbedder(registry.Spec{Type: eType}, set, log.Default())
+}
+
+// createEmbedder creates the embedder of a spec with the synonym sets attached
+// Missing API keys and unknown types are errors, so misconfiguration fails at startup
+func createEmbedder(spec registry.Spec, set *synonyms.Set, logger Logger) (embedders.Embedder, error) {
+	embedder, err := registry.New(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedder: %w", err)
+	}
+	if t, ok := embedder.(*tfidf.TFIDFEmbedder); ok {
+		if bootstrap := t.Bootstrap(); bootstrap.Source != tfidf.BootstrapBuiltin {
+			logger.Printf("tf-idf bootstrap corpus: %s (%d documents)", bootstrap.Source, bootstrap.Documents)
+		}
+	}
+	attachSynonyms(embedder, set)
+	return embedder, nil
+}
 
-	switch eType {
-	case "gemini":
-		googleAPIKey := os.Getenv("GEMINI_API_KEY")
-		if googleAPIKey == "" {
-			log.Fatal("GEMINI_API_KEY environment variable is required")
+// synonymsFromEnv loads the synonyms file of SYNONYMS_PATH, nil when none is configured
+func synonymsFromEnv(logger Logger) (*synonyms.Set, error) {
+	set, err := synonyms.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load synonyms: %w", err)
+	}
+	if set != nil {
+		logger.Printf("loaded %d synonym sets from %s", set.Count(), set.Path())
+	}
+	return set, nil
+}
+
+// attachSynonyms enables synonym expansion on embedders supporting it
+func attachSynonyms(embedder embedders.Embedder, set *synonyms.Set) {
+	if expander, ok := embedder.(embedders.SynonymExpander); ok && set != nil {
+		expander.SetSynonyms(set)
+	}
+}
+
+// responseFormatFromEnv reads the default serialization of search results
+// RESPONSE_SCORE_PRECISION and RESPONSE_EMBEDDING_PRECISION set the decimal places
+// (-1 for full precision), RESPONSE_EMBEDDING_FORMAT is "array" or "base64"
+func responseFormatFromEnv() (models.ResponseFormat, error) {
+	format := models.DefaultResponseFormat()
+
+	for name, target := range map[string]*int{
+		"RESPONSE_SCORE_PRECISION":     &format.ScorePrecision,
+		"RESPONSE_EMBEDDING_PRECISION": &format.EmbeddingPrecision,
+	} {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return format, fmt.Errorf("invalid %s %q: %w", name, value, err)
+			}
+			*target = parsed
+		}
+	}
+
+	if value := os.Getenv("RESPONSE_EMBEDDING_FORMAT"); value != "" {
+		format.EmbeddingFormat = value
+	}
+
+	return format, format.Validate()
+}
+
+// snapshotsFromEnv opens the named snapshot library of SNAPSHOT_DIR, nil when unset
+// SNAPSHOT_MAX_RESIDENT and SNAPSHOT_MAX_BYTES cap the snapshots held in memory,
+// SNAPSHOT_SCHEDULE is a duration such as "24h" between snapshots named with SNAPSHOT_LABEL
+func snapshotsFromEnv() (*snapshot.Library, snapshotSchedule, error) {
+	schedule := snapshotSchedule{Label: "scheduled"}
+	dir := os.Getenv("SNAPSHOT_DIR")
+	if dir == "" {
+		if os.Getenv("SNAPSHOT_SCHEDULE") != "" {
+			return nil, schedule, fmt.Errorf("SNAPSHOT_SCHEDULE requires SNAPSHOT_DIR")
+		}
+		return nil, schedule, nil
+	}
+
+	var opts snapshot.LibraryOptions
+	if value := os.Getenv("SNAPSHOT_MAX_RESIDENT"); value != "" {
+		maxResident, err := strconv.Atoi(value)
+		if err != nil || maxResident <= 0 {
+			return nil, schedule, fmt.Errorf("invalid SNAPSHOT_MAX_RESIDENT %q: must be a positive integer", value)
+		}
+		opts.MaxResident = maxResident
+	}
+	if value := os.Getenv("SNAPSHOT_MAX_BYTES"); value != "" {
+		maxBytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || maxBytes < 0 {
+			return nil, schedule, fmt.Errorf("invalid SNAPSHOT_MAX_BYTES %q: must be a non-negative integer", value)
+		}
+		opts.MaxBytes = maxBytes
+	}
+	if value := os.Getenv("SNAPSHOT_SCHEDULE"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < time.Minute {
+			return nil, schedule, fmt.Errorf("invalid SNAPSHOT_SCHEDULE %q: must be a duration of at least 1m", value)
+		}
+		schedule.Interval = interval
+	}
+	if label := os.Getenv("SNAPSHOT_LABEL"); label != "" {
+		if err := snapshot.ValidateName(label); err != nil {
+			return nil, schedule, fmt.Errorf("invalid SNAPSHOT_LABEL: %w", err)
+		}
+		schedule.Label = label
+	}
+
+	library, err := snapshot.NewLibrary(dir, opts)
+	if err != nil {
+		return nil, schedule, err
+	}
+	return library, schedule, nil
+}
+
+// resultSetOptionsFromEnv reads how long and how many search result sets are kept for refinement
+// RESULT_SET_TTL is a duration such as "30m", RESULT_SET_MAX caps the number of sets
+func resultSetOptionsFromEnv(getenv func(string) string) (handlers.ResultSetOptions, error) {
+	var opts handlers.ResultSetOptions
+	if value := getenv("RESULT_SET_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return opts, fmt.Errorf("invalid RESULT_SET_TTL %q: must be a positive duration", value)
+		}
+		opts.TTL = ttl
+	}
+	if value := getenv("RESULT_SET_MAX"); value != "" {
+		maxSets, err := strconv.Atoi(value)
+		if err != nil || maxSets <= 0 {
+			return opts, fmt.Errorf("invalid RESULT_SET_MAX %q: must be a positive integer", value)
+		}
+		opts.MaxSets = maxSets
+	}
+	return opts, nil
+}
+
+// vectorConfigFor describes the vectors produced by embedder for new storage collections
+func vectorConfigFor(embedder embedders.Embedder) *local.VectorConfig {
+	config := &local.VectorConfig{EmbedderType: embedder.Name()}
+	if dimensioned, ok := embedder.(embedders.Dimensioned); ok {
+		config.Dimension = dimensioned.Dimensions()
+	}
+	return config
+}
+
+// embedderOverridesFromEnv returns the cache of the override embedders, holding at
+// most EMBEDDER_OVERRIDE_CACHE_SIZE instances, each dropped after EMBEDDER_OVERRIDE_IDLE unused
+func embedderOverridesFromEnv(specs map[string]registry.Spec, getenv func(string) string) (*registry.Cache, error) {
+	size := 0
+	if value := getenv("EMBEDDER_OVERRIDE_CACHE_SIZE"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid EMBEDDER_OVERRIDE_CACHE_SIZE %q: must be a positive integer", value)
+		}
+		size = parsed
+	}
+	var idle time.Duration
+	if value := getenv("EMBEDDER_OVERRIDE_IDLE"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid EMBEDDER_OVERRIDE_IDLE %q: must be a positive duration", value)
+		}
+		idle = parsed
+	}
+	return registry.NewCache(specs, size, idle)
+}
+
+// setQuarantine enables the quarantine of the invalid vectors of batches with
+// QUARANTINE=true, keeping at most QUARANTINE_MAX of them (quarantine.DefaultMaxEntries)
+func setQuarantine(handler *handlers.VectorHandler, store storage.Storage, getenv func(string) string) error {
+	enabled := getenv("QUARANTINE") == "true"
+	value := getenv("QUARANTINE_MAX")
+	if !enabled && value == "" {
+		return nil
+	}
+
+	qs, ok := store.(storage.QuarantineStore)
+	if !ok {
+		return fmt.Errorf("storage backend does not support QUARANTINE")
+	}
+	if value != "" {
+		max, err := strconv.Atoi(value)
+		if err != nil || max <= 0 {
+			return fmt.Errorf("invalid QUARANTINE_MAX %q: must be a positive integer", value)
 		}
-		return gemini.NewGeminiEmbedder(googleAPIKey)
-	case "huggingface":
-		hfAPIKey := os.Getenv("HUGGINGFACE_API_KEY")
-		if hfAPIKey == "" {
-			log.Fatal("HUGGINGFACE_API_KEY environment variable is required")
+		if err := qs.SetQuarantineLimit(max); err != nil {
+			return fmt.Errorf("invalid QUARANTINE_MAX: %w", err)
 		}
-		return huggingface.NewHuggingFaceEmbedder(hfAPIKey)
-	default:
-		return tfidf.NewTFIDFEmbedder()
 	}
+	handler.SetQuarantine(enabled)
+	return nil
 }
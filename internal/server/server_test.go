@@ -0,0 +1,257 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/handlers"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServerWithOptions(WithStorage(memory.NewStorage()), WithEmbedder(hash.NewHashEmbedder()))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	return s
+}
+
+func TestRoutes_ReservedPathsNeverMatchVectorIDs(t *testing.T) {
+	s := newTestServer(t)
+
+	idRoutes := map[string]bool{"/api/v1/vectors/{id}": true, "/api/v1/vectors/{id}/provenance": true}
+	for _, reserved := range handlers.ReservedVectorPaths {
+		for _, path := range []string{"/api/v1/vectors/" + reserved, "/api/v1/vectors/" + reserved + "/provenance"} {
+			for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+				var match mux.RouteMatch
+				if !s.router.Match(httptest.NewRequest(method, path, nil), &match) || match.Route == nil {
+					continue
+				}
+				if template, _ := match.Route.GetPathTemplate(); idRoutes[template] {
+					t.Errorf("%s %s fell through to %s", method, path, template)
+				}
+			}
+		}
+	}
+
+	// A vector whose ID is a reserved word is only reachable through the listing
+	if err := s.storage.Store(&models.Vector{ID: "metadata", Embedding: []float64{1, 2}}); err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/metadata", nil))
+	var listing []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("GET /vectors/metadata did not return the metadata listing: %s", rec.Body.String())
+	}
+
+	// Reserved paths with another method are not found lookups
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/embed", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /vectors/embed: status = %d, want 405", rec.Code)
+	}
+}
+
+func TestRoutes_NotFound(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name      string
+		path      string
+		canonical string
+		hint      bool
+		resources bool
+	}{
+		{"trailing slash", "/api/v1/vectors/metadata/", "/api/v1/vectors/metadata", true, true},
+		{"unrouted reserved path", "/api/v1/vectors/by", "", false, true},
+		{"misspelled sub-path", "/api/v1/vectors/serach", "", true, true},
+		{"id with a slash", "/api/v1/vectors/a%2Fb", "", false, true},
+		{"outside vectors", "/api/v1/nothing", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404", rec.Code)
+			}
+
+			var body struct {
+				Error     string   `json:"error"`
+				Canonical string   `json:"canonical"`
+				Hint      string   `json:"hint"`
+				Resources []string `json:"resources"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("404 body is not structured: %s", rec.Body.String())
+			}
+			if body.Error == "" || body.Canonical != tt.canonical || (body.Hint != "") != tt.hint || (len(body.Resources) > 0) != tt.resources {
+				t.Errorf("body = %+v", body)
+			}
+		})
+	}
+
+	// Plain lookups of missing IDs keep their plain error
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/quote_1", nil))
+	if rec.Code != http.StatusNotFound || strings.HasPrefix(rec.Body.String(), "{") {
+		t.Errorf("missing ID: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRoutes_VectorResourcesListed(t *testing.T) {
+	s := newTestServer(t)
+
+	listed := make(map[string]bool)
+	for _, resource := range handlers.VectorResources {
+		listed[resource] = true
+	}
+
+	err := s.router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(template, "/api/v1/vectors") {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			if !listed[method+" "+template] {
+				t.Errorf("%s %s is missing from handlers.VectorResources", method, template)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk failed: %v", err)
+	}
+
+	// The id routes still serve ordinary IDs
+	body, _ := json.Marshal(models.Vector{ID: "doc-1", Embedding: []float64{1, 2}})
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/v1/vectors/doc-1", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /vectors/doc-1: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/vectors/doc-1", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /vectors/doc-1: status = %d", rec.Code)
+	}
+}
+
+func TestRoutes_SearchRequestShapes(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/embed", strings.NewReader(`{"text":"the quick brown fox","author":"anon"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("embed: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	search := func(body string) map[string]json.RawMessage {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("search %s: status = %d: %s", body, rec.Code, rec.Body.String())
+		}
+		var response map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("search %s: %v", body, err)
+		}
+		return response
+	}
+
+	// A query is an advanced search, whose options reach the handler
+	var results []handlers.AdvancedSearchResult
+	if err := json.Unmarshal(search(`{"query":"quick fox","include_metadata":true,"return_embedding":true}`)["results"], &results); err != nil {
+		t.Fatalf("advanced search returned no results: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("advanced search: %d results, want 1", len(results))
+	}
+	if result := results[0]; result.Metadata["author"] != "anon" || result.Embedding == nil {
+		t.Errorf("advanced search result = %+v, want its metadata and embedding", result)
+	}
+
+	// A text is a text search
+	if _, ok := search(`{"text":"quick fox"}`)["matches"]; !ok {
+		t.Error("text search did not return matches")
+	}
+}
+
+func TestNewServerWithOptions(t *testing.T) {
+	if _, err := NewServerWithOptions(WithEmbedder(nil)); err == nil {
+		t.Errorf("nil embedder accepted")
+	}
+
+	var seen []string
+	s, err := NewServerWithOptions(WithAdminKey("secret"), WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.URL.Path)
+			next.ServeHTTP(w, r)
+		})
+	}))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	// Defaults to memory storage and the hash embedder
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/vectors/embed", strings.NewReader(`{"text":"hello world"}`)))
+	if rec.Code != http.StatusCreated && rec.Code != http.StatusOK {
+		t.Fatalf("embed: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if s.storage.Count() != 1 {
+		t.Errorf("count = %d, want 1", s.storage.Count())
+	}
+
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "secret": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/quotas", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("admin request with key %q: status = %d, want %d", key, rec.Code, want)
+		}
+	}
+
+	if len(seen) != 3 || seen[0] != "/api/v1/vectors/embed" {
+		t.Errorf("middleware saw %v, want every request", seen)
+	}
+}
+
+func TestUIRoutes(t *testing.T) {
+	get := func(s *Server, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get(newTestServer(t), "/ui/"); rec.Code != http.StatusNotFound {
+		t.Errorf("disabled UI: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	s, err := NewServerWithOptions(WithUI(true))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	if rec := get(s, "/ui"); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/ui/" {
+		t.Errorf("/ui: status = %d, location = %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := get(s, "/ui/"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "app.js") {
+		t.Errorf("/ui/: status = %d", rec.Code)
+	}
+	if rec := get(s, "/api/v1/vectors/count"); rec.Code != http.StatusOK {
+		t.Errorf("API with UI enabled: status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/tracing"
+)
+
+func TestTracing_SearchSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracing.SetProvider(provider)
+	t.Cleanup(func() {
+		tracing.SetProvider(nil)
+		provider.Shutdown(context.Background())
+	})
+
+	s := newTestServer(t)
+	embedding, err := hash.NewHashEmbedder().Embed("quiet lake at dawn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.storage.Store(&models.Vector{ID: "a", Embedding: embedding, Metadata: map[string]string{"text": "quiet lake at dawn"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", strings.NewReader(`{"text": "quiet lake", "top_k": 3}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("search: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub, len(spans))
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	root, ok := byName["POST /api/v1/search"]
+	if !ok {
+		t.Fatalf("no request span among %d spans", len(spans))
+	}
+	if root.Parent.IsValid() {
+		t.Errorf("request span has a parent")
+	}
+	attrs := make(map[string]string)
+	for _, attr := range root.Attributes {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["http.route"] != "/api/v1/search" || attrs["http.response.status_code"] != "200" {
+		t.Errorf("request span attributes = %v", attrs)
+	}
+
+	// Embedding, scan and serialization are children of the request span
+	for _, name := range []string{"embedder.EmbedQuery", "storage.Search", "search.Encode"} {
+		child, ok := byName[name]
+		if !ok {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if child.Parent.SpanID() != root.SpanContext.SpanID() || child.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("%s is not a child of the request span", name)
+		}
+	}
+	for _, attr := range byName["embedder.EmbedQuery"].Attributes {
+		if attr.Key == tracing.EmbedderProvider && attr.Value.AsString() != "local.hash" {
+			t.Errorf("embedder.provider = %s", attr.Value.AsString())
+		}
+	}
+	if len(spans) != 4 {
+		t.Errorf("recorded %d spans, want 4", len(spans))
+	}
+}
+
+func TestTracing_UpdateSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracing.SetProvider(provider)
+	t.Cleanup(func() {
+		tracing.SetProvider(nil)
+		provider.Shutdown(context.Background())
+	})
+
+	s := newTestServer(t)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/v1/vectors/doc-1", strings.NewReader(`{"embedding": [1, 2]}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Updates are stored under a child span like the other writes
+	var root, store tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		switch span.Name {
+		case "PUT /api/v1/vectors/{id}":
+			root = span
+		case "storage.Store":
+			store = span
+		}
+	}
+	if !root.SpanContext.IsValid() || !store.SpanContext.IsValid() {
+		t.Fatalf("spans = %v", exporter.GetSpans())
+	}
+	if store.Parent.SpanID() != root.SpanContext.SpanID() {
+		t.Errorf("storage.Store is not a child of the request span")
+	}
+}
+
+func TestTracing_DisabledRecordsNothing(t *testing.T) {
+	if tracing.Enabled() {
+		t.Fatal("tracing enabled without a provider")
+	}
+	ctx, span := tracing.Start(context.Background(), "noop")
+	if span.IsRecording() || ctx != context.Background() {
+		t.Errorf("disabled tracing started a recording span")
+	}
+}
@@ -0,0 +1,322 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// DefaultMaxResident is the number of snapshots held in memory when LibraryOptions sets none
+const DefaultMaxResident = 2
+
+// fileExt is the extension of the snapshot files of a library
+const fileExt = ".snapshot"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// ValidateName rejects names that are not usable as file names, such as "../x"
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use up to 128 letters, digits, '.', '_' or '-', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+// LibraryOptions caps the snapshots a library holds in memory, a zero MaxBytes is unlimited
+type LibraryOptions struct {
+	MaxResident int   `json:"max_resident"`
+	MaxBytes    int64 `json:"max_bytes,omitempty"` // Estimated bytes of the resident vectors
+}
+
+// Info describes a named snapshot of a library
+type Info struct {
+	Name string `json:"name"`
+	Header
+	SizeBytes int64 `json:"size_bytes"` // Size of the snapshot file
+	Resident  bool  `json:"resident"`   // Loaded in memory
+}
+
+// resident is a snapshot loaded in memory
+type resident struct {
+	store    *memory.Storage
+	header   Header
+	bytes    int64
+	lastUsed uint64
+}
+
+// Library keeps named snapshots as files of a directory
+// Snapshots are opened read-only in memory when first searched, and the least
+// recently used are evicted past MaxResident snapshots or MaxBytes
+type Library struct {
+	dir  string
+	opts LibraryOptions
+
+	mu        sync.Mutex
+	resident  map[string]*resident
+	clock     uint64
+	loads     int
+	evictions int
+}
+
+// NewLibrary opens the snapshot library of dir, creating the directory if needed
+func NewLibrary(dir string, opts LibraryOptions) (*Library, error) {
+	if opts.MaxResident < 0 || opts.MaxBytes < 0 {
+		return nil, fmt.Errorf("snapshot limits cannot be negative")
+	}
+	if opts.MaxResident == 0 {
+		opts.MaxResident = DefaultMaxResident
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	return &Library{dir: dir, opts: opts, resident: make(map[string]*resident)}, nil
+}
+
+// Dir returns the directory holding the snapshot files
+func (l *Library) Dir() string {
+	return l.dir
+}
+
+// Options returns the residency limits of the library
+func (l *Library) Options() LibraryOptions {
+	return l.opts
+}
+
+func (l *Library) path(name string) string {
+	return filepath.Join(l.dir, name+fileExt)
+}
+
+// Save writes snap under name, failing if the name is taken
+// The file is written aside and renamed, so a crash never leaves a partial snapshot
+func (l *Library) Save(name string, snap *Snapshot) (*Info, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	path := l.path(name)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("snapshot %s %w", name, storeerr.ErrAlreadyExists)
+	}
+
+	tmp, err := os.CreateTemp(l.dir, "."+name+"-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := snap.Write(w); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return l.Stat(name)
+}
+
+// Stat describes the snapshot name from its header, without loading its vectors
+func (l *Library) Stat(name string) (*Info, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, fmt.Errorf("snapshot %s %w", name, storeerr.ErrNotFound)
+	}
+	f, err := os.Open(l.path(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("snapshot %s %w", name, storeerr.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header of snapshot %s: %w", name, err)
+	}
+	info := &Info{Name: name, SizeBytes: fi.Size()}
+	if err := json.Unmarshal(line, &info.Header); err != nil || info.Header.Format != Format {
+		return nil, fmt.Errorf("snapshot %s is not a same-same snapshot", name)
+	}
+
+	l.mu.Lock()
+	_, info.Resident = l.resident[name]
+	l.mu.Unlock()
+	return info, nil
+}
+
+// List describes every snapshot of the library, oldest first
+// Unreadable files are skipped rather than failing the listing
+func (l *Library) List() ([]*Info, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	infos := make([]*Info, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), fileExt)
+		if entry.IsDir() || name == entry.Name() || ValidateName(name) != nil {
+			continue
+		}
+		if info, err := l.Stat(name); err == nil {
+			infos = append(infos, info)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].CreatedAt.Equal(infos[j].CreatedAt) {
+			return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+		}
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+// Delete removes the snapshot name, evicting it from memory
+func (l *Library) Delete(name string) error {
+	if err := ValidateName(name); err != nil {
+		return fmt.Errorf("snapshot %s %w", name, storeerr.ErrNotFound)
+	}
+	l.mu.Lock()
+	delete(l.resident, name)
+	l.mu.Unlock()
+
+	err := os.Remove(l.path(name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("snapshot %s %w", name, storeerr.ErrNotFound)
+	}
+	return err
+}
+
+// Open returns the in-memory store of the snapshot name, loading it if it is not resident
+// The store must only be searched: it is shared by every request pinned to the snapshot
+func (l *Library) Open(name string) (storage.Storage, *Header, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.clock++
+	if r, ok := l.resident[name]; ok {
+		r.lastUsed = l.clock
+		return r.store, &r.header, nil
+	}
+
+	r, err := l.load(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.lastUsed = l.clock
+	l.resident[name] = r
+	l.loads++
+	l.evict(name)
+	return r.store, &r.header, nil
+}
+
+// load reads and verifies the snapshot name into a new memory store
+func (l *Library) load(name string) (*resident, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, fmt.Errorf("snapshot %s %w", name, storeerr.ErrNotFound)
+	}
+	f, err := os.Open(l.path(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("snapshot %s %w", name, storeerr.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	snap, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s: %w", name, err)
+	}
+	store := memory.NewStorage()
+	if err := snap.Restore(store); err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s: %w", name, err)
+	}
+
+	r := &resident{store: store, header: snap.Header}
+	for _, vector := range snap.Vectors {
+		r.bytes += memlimit.SizeOf(vector)
+	}
+	return r, nil
+}
+
+// evict drops the least recently used snapshots other than keep until the
+// resident ones fit the limits. Searches holding an evicted store finish on it
+func (l *Library) evict(keep string) {
+	for len(l.resident) > 1 && (len(l.resident) > l.opts.MaxResident || (l.opts.MaxBytes > 0 && l.residentBytes() > l.opts.MaxBytes)) {
+		victim := ""
+		for name, r := range l.resident {
+			if name != keep && (victim == "" || r.lastUsed < l.resident[victim].lastUsed) {
+				victim = name
+			}
+		}
+		delete(l.resident, victim)
+		l.evictions++
+	}
+}
+
+func (l *Library) residentBytes() int64 {
+	var total int64
+	for _, r := range l.resident {
+		total += r.bytes
+	}
+	return total
+}
+
+// LibraryStats reports the snapshots held in memory and how often they were loaded and evicted
+type LibraryStats struct {
+	Resident      []string `json:"resident"`
+	ResidentBytes int64    `json:"resident_bytes"`
+	Loads         int      `json:"loads"`
+	Evictions     int      `json:"evictions"`
+}
+
+// Stats returns the residency counters of the library
+func (l *Library) Stats() LibraryStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := LibraryStats{Resident: make([]string, 0, len(l.resident)), ResidentBytes: l.residentBytes(), Loads: l.loads, Evictions: l.evictions}
+	for name := range l.resident {
+		stats.Resident = append(stats.Resident, name)
+	}
+	sort.Strings(stats.Resident)
+	return stats
+}
+
+// ScheduledName names a snapshot taken by a schedule of interval at t with label,
+// such as "2024-06-01-nightly". Schedules of less than a day add the time of day
+func ScheduledName(t time.Time, interval time.Duration, label string) string {
+	layout := "2006-01-02"
+	if interval%(24*time.Hour) != 0 {
+		layout = "2006-01-02-150405"
+	}
+	return t.UTC().Format(layout) + "-" + label
+}
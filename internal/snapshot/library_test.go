@@ -0,0 +1,103 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+func saveTestSnapshots(t *testing.T, l *Library, names ...string) {
+	t.Helper()
+	for i, name := range names {
+		snap, err := New("", []*models.Vector{{ID: fmt.Sprintf("v%d", i), Embedding: []float64{1, float64(i)}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.Save(name, snap); err != nil {
+			t.Fatalf("Save(%s) error = %v", name, err)
+		}
+	}
+}
+
+func TestLibrary_SaveListOpen(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLibrary(dir, LibraryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveTestSnapshots(t, l, "monday", "tuesday")
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644)
+
+	if _, err := l.Save("monday", &Snapshot{}); !errors.Is(err, storeerr.ErrAlreadyExists) {
+		t.Errorf("Save() over an existing snapshot error = %v", err)
+	}
+	if _, err := l.Save("../escape", &Snapshot{}); err == nil {
+		t.Error("Save() accepted a path as name")
+	}
+
+	infos, err := l.List()
+	if err != nil || len(infos) != 2 || infos[0].Name != "monday" || infos[1].Count != 1 || infos[0].Resident {
+		t.Fatalf("List() = %+v, %v", infos, err)
+	}
+
+	store, header, err := l.Open("tuesday")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if vector, err := store.Get("v1"); err != nil || header.Count != 1 || vector.Embedding[1] != 1 {
+		t.Errorf("opened snapshot holds %+v, %v", vector, err)
+	}
+	if info, _ := l.Stat("tuesday"); !info.Resident {
+		t.Error("opened snapshot is not resident")
+	}
+
+	if _, _, err := l.Open("wednesday"); !errors.Is(err, storeerr.ErrNotFound) {
+		t.Errorf("Open() of a missing snapshot error = %v", err)
+	}
+	if err := l.Delete("tuesday"); err != nil || len(l.Stats().Resident) != 0 {
+		t.Errorf("Delete() error = %v, resident %v", err, l.Stats().Resident)
+	}
+}
+
+func TestLibrary_EvictsLeastRecentlyUsed(t *testing.T) {
+	l, err := NewLibrary(t.TempDir(), LibraryOptions{MaxResident: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveTestSnapshots(t, l, "a", "b", "c")
+
+	for _, name := range []string{"a", "b", "a", "c"} {
+		if _, _, err := l.Open(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := l.Stats()
+	if fmt.Sprint(stats.Resident) != "[a c]" || stats.Loads != 3 || stats.Evictions != 1 {
+		t.Errorf("stats = %+v, want b evicted", stats)
+	}
+
+	// A byte budget below one snapshot keeps only the latest opened
+	l.opts.MaxBytes = 1
+	if _, _, err := l.Open("b"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := l.Stats(); fmt.Sprint(stats.Resident) != "[b]" || stats.Evictions != 3 {
+		t.Errorf("stats under memory pressure = %+v", stats)
+	}
+}
+
+func TestScheduledName(t *testing.T) {
+	at := time.Date(2024, 6, 1, 2, 30, 0, 0, time.UTC)
+	if name := ScheduledName(at, 24*time.Hour, "nightly"); name != "2024-06-01-nightly" {
+		t.Errorf("daily name = %s", name)
+	}
+	if name := ScheduledName(at, time.Hour, "hourly"); name != "2024-06-01-023000-hourly" {
+		t.Errorf("hourly name = %s", name)
+	}
+}
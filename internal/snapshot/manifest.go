@@ -0,0 +1,400 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// ManifestFormat identifies same-same integrity manifests
+const ManifestFormat = "same-same-manifest"
+
+// ManifestHeader describes the snapshot an integrity manifest was written for
+type ManifestHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	Namespace string    `json:"namespace,omitempty"`
+	Embedder  string    `json:"embedder,omitempty"`
+	Dimension int       `json:"dimension"`
+	CreatedAt time.Time `json:"created_at"` // When the snapshot was created
+}
+
+// ManifestRecord is the content hash of a snapshot vector
+type ManifestRecord struct {
+	ID     string `json:"id"`
+	SHA256 string `json:"sha256"`
+}
+
+// manifestFooter closes a manifest with the counts, the Merkle root of the record
+// hashes and, when signed, an HMAC-SHA256 over every preceding line
+type manifestFooter struct {
+	Count          int    `json:"count"`
+	MerkleRoot     string `json:"merkle_root"`
+	SnapshotSHA256 string `json:"snapshot_sha256"`
+	HMAC           string `json:"hmac_sha256,omitempty"`
+}
+
+// RecordHash returns the content hash of a vector: SHA-256 over its ID, embedding
+// and metadata. It does not depend on the encoding of the file the vector came from
+func RecordHash(vector *models.Vector) (string, error) {
+	data, err := json.Marshal(struct {
+		ID        string               `json:"id"`
+		Embedding []float64            `json:"embedding,omitempty"`
+		Sparse    *models.SparseVector `json:"embedding_sparse,omitempty"`
+		Metadata  map[string]string    `json:"metadata,omitempty"`
+	}{vector.ID, vector.Embedding, vector.Sparse, vector.Metadata})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// merkle computes a Merkle root over leaves added one at a time, holding one node
+// per level so memory stays logarithmic in the number of leaves
+type merkle struct {
+	levels [][]byte
+}
+
+func (m *merkle) add(leaf []byte) {
+	node := merkleHash(0, leaf)
+	for i := 0; ; i++ {
+		if i == len(m.levels) {
+			m.levels = append(m.levels, node)
+			return
+		}
+		if m.levels[i] == nil {
+			m.levels[i] = node
+			return
+		}
+		node = merkleHash(1, m.levels[i], node)
+		m.levels[i] = nil
+	}
+}
+
+// root folds the pending nodes from the lowest level up
+func (m *merkle) root() string {
+	var root []byte
+	for _, node := range m.levels {
+		switch {
+		case node == nil:
+		case root == nil:
+			root = node
+		default:
+			root = merkleHash(1, node, root)
+		}
+	}
+	if root == nil {
+		root = merkleHash(0)
+	}
+	return hex.EncodeToString(root)
+}
+
+// merkleHash hashes leaves (prefix 0) and interior nodes (prefix 1) apart, so a
+// node cannot be passed off as a leaf
+func merkleHash(prefix byte, parts ...[]byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{prefix})
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// ManifestWriter writes the integrity manifest of the snapshot written to it,
+// hashing each vector line as it goes by so memory stays flat however large the
+// snapshot. It is meant to sit next to the snapshot file in an io.MultiWriter
+type ManifestWriter struct {
+	out     io.Writer
+	mac     hash.Hash
+	partial []byte
+	header  bool
+	tree    merkle
+	count   int
+	footer  *footer
+	err     error
+}
+
+// NewManifestWriter returns a writer of the manifest to out, signed with an
+// HMAC-SHA256 under key when key is not empty
+func NewManifestWriter(out io.Writer, key []byte) *ManifestWriter {
+	mw := &ManifestWriter{out: out}
+	if len(key) > 0 {
+		mw.mac = hmac.New(sha256.New, key)
+	}
+	return mw
+}
+
+// Write consumes snapshot bytes, writing a manifest line for each complete line
+func (mw *ManifestWriter) Write(p []byte) (int, error) {
+	if mw.err != nil {
+		return 0, mw.err
+	}
+	mw.partial = append(mw.partial, p...)
+	for {
+		i := bytes.IndexByte(mw.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if mw.err = mw.writeLine(mw.partial[:i]); mw.err != nil {
+			return 0, mw.err
+		}
+		mw.partial = mw.partial[i+1:]
+	}
+	// Drop the consumed prefix so the buffer does not grow with the snapshot
+	mw.partial = append([]byte(nil), mw.partial...)
+	return len(p), nil
+}
+
+func (mw *ManifestWriter) writeLine(line []byte) error {
+	if mw.footer != nil {
+		return fmt.Errorf("unexpected data after the snapshot footer")
+	}
+
+	if !mw.header {
+		var header Header
+		if err := json.Unmarshal(line, &header); err != nil || header.Format != Format {
+			return fmt.Errorf("not a same-same snapshot")
+		}
+		mw.header = true
+		return mw.emit(ManifestHeader{
+			Format:    ManifestFormat,
+			Version:   Version,
+			Namespace: header.Namespace,
+			Embedder:  header.Embedder,
+			Dimension: header.Dimension,
+			CreatedAt: header.CreatedAt,
+		})
+	}
+
+	var f footer
+	if json.Unmarshal(line, &f) == nil && f.SHA256 != "" {
+		mw.footer = &f
+		return nil
+	}
+
+	var vector models.Vector
+	if err := json.Unmarshal(line, &vector); err != nil {
+		return fmt.Errorf("invalid vector on line %d: %w", mw.count+2, err)
+	}
+	sum, err := RecordHash(&vector)
+	if err != nil {
+		return err
+	}
+	digest, _ := hex.DecodeString(sum)
+	mw.tree.add(digest)
+	mw.count++
+	return mw.emit(ManifestRecord{ID: vector.ID, SHA256: sum})
+}
+
+// emit writes a manifest line, adding it to the signature
+func (mw *ManifestWriter) emit(v interface{}) error {
+	out := mw.out
+	if mw.mac != nil {
+		out = io.MultiWriter(mw.out, mw.mac)
+	}
+	return writeLine(out, v)
+}
+
+// Close writes the manifest footer, failing when the snapshot was not complete
+func (mw *ManifestWriter) Close() error {
+	if mw.err != nil {
+		return mw.err
+	}
+	if len(mw.partial) > 0 || mw.footer == nil {
+		return fmt.Errorf("snapshot is truncated: missing footer after %d vectors", mw.count)
+	}
+
+	f := manifestFooter{Count: mw.count, MerkleRoot: mw.tree.root(), SnapshotSHA256: mw.footer.SHA256}
+	if mw.mac != nil {
+		f.HMAC = hex.EncodeToString(mw.mac.Sum(nil))
+	}
+	return writeLine(mw.out, f)
+}
+
+// Manifest is a decoded integrity manifest
+type Manifest struct {
+	Header         ManifestHeader
+	Count          int
+	MerkleRoot     string
+	SnapshotSHA256 string
+	Signed         bool // The manifest carries a signature
+	Verified       bool // The signature was checked against the key
+
+	records map[string]string
+}
+
+// ReadManifest decodes a manifest, checking its Merkle root and count against its
+// records. With a key the manifest must be signed with it; without one a
+// signature is left unverified
+func ReadManifest(r io.Reader, key []byte) (*Manifest, error) {
+	reader := bufio.NewReader(r)
+	signature := sha256.New() // Only checked with a key
+	if len(key) > 0 {
+		signature = hmac.New(sha256.New, key)
+	}
+
+	line, err := readLine(reader, signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest header: %w", err)
+	}
+	m := &Manifest{records: make(map[string]string)}
+	if err := json.Unmarshal(line, &m.Header); err != nil || m.Header.Format != ManifestFormat {
+		return nil, fmt.Errorf("not a same-same manifest")
+	}
+	if m.Header.Version > Version {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Header.Version)
+	}
+
+	var tree merkle
+	var f manifestFooter
+	for {
+		// The footer is not part of the signature, so it is read before being hashed
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil, fmt.Errorf("manifest is truncated: missing footer after %d records", len(m.records))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if json.Unmarshal(line, &f) == nil && f.MerkleRoot != "" {
+			break
+		}
+		signature.Write(line)
+
+		var record ManifestRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("invalid manifest record on line %d: %w", len(m.records)+2, err)
+		}
+		digest, err := hex.DecodeString(record.SHA256)
+		if err != nil || len(digest) != sha256.Size || record.ID == "" {
+			return nil, fmt.Errorf("invalid manifest record on line %d", len(m.records)+2)
+		}
+		if _, exists := m.records[record.ID]; exists {
+			return nil, fmt.Errorf("manifest lists %s twice", record.ID)
+		}
+		m.records[record.ID] = record.SHA256
+		tree.add(digest)
+	}
+
+	if f.Count != len(m.records) {
+		return nil, fmt.Errorf("manifest footer expects %d records, found %d", f.Count, len(m.records))
+	}
+	if f.MerkleRoot != tree.root() {
+		return nil, fmt.Errorf("manifest Merkle root mismatch: the manifest was modified")
+	}
+	m.Count, m.MerkleRoot, m.SnapshotSHA256 = f.Count, f.MerkleRoot, f.SnapshotSHA256
+
+	m.Signed = f.HMAC != ""
+	if len(key) > 0 {
+		if !m.Signed {
+			return nil, fmt.Errorf("manifest is not signed")
+		}
+		sum, err := hex.DecodeString(f.HMAC)
+		if err != nil || !hmac.Equal(sum, signature.Sum(nil)) {
+			return nil, fmt.Errorf("manifest signature mismatch: it was not signed with this key or was modified")
+		}
+		m.Verified = true
+	}
+	return m, nil
+}
+
+// Verification reports the differences between a snapshot and its manifest
+type Verification struct {
+	Records    int      `json:"records"`
+	Mismatched []string `json:"mismatched,omitempty"` // Content differs from the manifest
+	Missing    []string `json:"missing,omitempty"`    // Listed in the manifest, absent from the snapshot
+	Unexpected []string `json:"unexpected,omitempty"` // In the snapshot, absent from the manifest
+	Header     []string `json:"header,omitempty"`     // Provenance differing from the manifest
+	Corrupted  bool     `json:"corrupted,omitempty"`  // The snapshot checksum did not match its footer
+}
+
+// OK reports whether the snapshot matches its manifest
+func (v *Verification) OK() bool {
+	return len(v.Mismatched) == 0 && len(v.Missing) == 0 && len(v.Unexpected) == 0 && len(v.Header) == 0 && !v.Corrupted
+}
+
+// Error summarizes the differences
+func (v *Verification) Error() string {
+	var problems []string
+	if v.Corrupted {
+		problems = append(problems, "snapshot checksum mismatch")
+	}
+	for _, group := range []struct {
+		label string
+		ids   []string
+	}{{"differ", v.Mismatched}, {"missing", v.Missing}, {"not in manifest", v.Unexpected}} {
+		if len(group.ids) > 0 {
+			problems = append(problems, fmt.Sprintf("%d records %s (%s)", len(group.ids), group.label, strings.Join(group.ids, ", ")))
+		}
+	}
+	problems = append(problems, v.Header...)
+	return "snapshot does not match its manifest: " + strings.Join(problems, "; ")
+}
+
+// Verify compares the vectors of a snapshot, as read and before any rename, with
+// the manifest
+func (m *Manifest) Verify(s *Snapshot) (*Verification, error) {
+	v := &Verification{Records: len(s.Vectors)}
+	if s.Header.Namespace != m.Header.Namespace {
+		v.Header = append(v.Header, fmt.Sprintf("namespace %q, manifest %q", s.Header.Namespace, m.Header.Namespace))
+	}
+	if s.Header.Embedder != m.Header.Embedder {
+		v.Header = append(v.Header, fmt.Sprintf("embedder %q, manifest %q", s.Header.Embedder, m.Header.Embedder))
+	}
+	if s.Header.Dimension != m.Header.Dimension {
+		v.Header = append(v.Header, fmt.Sprintf("dimension %d, manifest %d", s.Header.Dimension, m.Header.Dimension))
+	}
+
+	seen := make(map[string]bool, len(s.Vectors))
+	for _, vector := range s.Vectors {
+		seen[vector.ID] = true
+		want, listed := m.records[vector.ID]
+		if !listed {
+			v.Unexpected = append(v.Unexpected, vector.ID)
+			continue
+		}
+		sum, err := RecordHash(vector)
+		if err != nil {
+			return nil, err
+		}
+		if sum != want {
+			v.Mismatched = append(v.Mismatched, vector.ID)
+		}
+	}
+	for id := range m.records {
+		if !seen[id] {
+			v.Missing = append(v.Missing, id)
+		}
+	}
+	sort.Strings(v.Missing)
+	return v, nil
+}
+
+// ReadVerified decodes a snapshot and verifies it against its manifest. Unlike
+// Read, a checksum mismatch does not fail the read: it is reported with the
+// records that differ from the manifest so the tampering can be located
+func ReadVerified(r io.Reader, m *Manifest) (*Snapshot, *Verification, error) {
+	snap, corrupted, err := read(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := m.Verify(snap)
+	if err != nil {
+		return nil, nil, err
+	}
+	v.Corrupted = corrupted
+	return snap, v, nil
+}
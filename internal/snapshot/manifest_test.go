@@ -0,0 +1,167 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// writeWithManifest writes a snapshot of count vectors one byte at a time, as
+// a slow export stream would, returning the snapshot and its manifest
+func writeWithManifest(t *testing.T, count int, key []byte) (string, string) {
+	t.Helper()
+	vectors := make([]*models.Vector, count)
+	for i := range vectors {
+		vectors[i] = &models.Vector{
+			ID:        fmt.Sprintf("t%02d", i),
+			Embedding: []float64{float64(i), 0.25, -1},
+			Metadata:  map[string]string{"namespace": "support-tickets", "embedder.name": "local.hash", "title": "printer jam"},
+		}
+	}
+	snap, err := New("support-tickets", vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data bytes.Buffer
+	if err := snap.Write(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	var file, manifest bytes.Buffer
+	mw := NewManifestWriter(&manifest, key)
+	if _, err := io.Copy(io.MultiWriter(&file, mw), iotest.OneByteReader(bytes.NewReader(data.Bytes()))); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file.String(), manifest.String()
+}
+
+func TestManifest_RoundTrip(t *testing.T) {
+	key := []byte("compliance")
+	for _, count := range []int{0, 1, 5, 8} {
+		data, manifestData := writeWithManifest(t, count, key)
+
+		manifest, err := ReadManifest(strings.NewReader(manifestData), key)
+		if err != nil {
+			t.Fatalf("%d vectors: %v", count, err)
+		}
+		if manifest.Count != count || !manifest.Verified || manifest.Header.Namespace != "support-tickets" {
+			t.Errorf("%d vectors: manifest = %+v", count, manifest)
+		}
+
+		snap, v, err := ReadVerified(strings.NewReader(data), manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !v.OK() || len(snap.Vectors) != count {
+			t.Errorf("%d vectors: verification = %+v", count, v)
+		}
+	}
+
+	// Without the key the signature is reported but not checked
+	_, manifestData := writeWithManifest(t, 3, key)
+	manifest, err := ReadManifest(strings.NewReader(manifestData), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manifest.Signed || manifest.Verified {
+		t.Errorf("signed = %v, verified = %v", manifest.Signed, manifest.Verified)
+	}
+}
+
+func TestManifest_DetectsTamperedRecord(t *testing.T) {
+	data, manifestData := writeWithManifest(t, 5, nil)
+	manifest, err := ReadManifest(strings.NewReader(manifestData), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a byte of the embedding of t03
+	lines := strings.SplitAfter(data, "\n")
+	i := strings.Index(lines[4], "0.25")
+	if !strings.Contains(lines[4], `"t03"`) || i < 0 {
+		t.Fatalf("unexpected line %q", lines[4])
+	}
+	tampered := []byte(lines[4])
+	tampered[i+2] ^= 0x01 // 0.25 becomes 0.24
+	lines[4] = string(tampered)
+
+	if _, err := Read(strings.NewReader(strings.Join(lines, ""))); err == nil {
+		t.Error("expected the snapshot checksum to fail")
+	}
+	_, v, err := ReadVerified(strings.NewReader(strings.Join(lines, "")), manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.OK() || !v.Corrupted || len(v.Mismatched) != 1 || v.Mismatched[0] != "t03" {
+		t.Errorf("verification = %+v, want t03 to differ", v)
+	}
+	if !strings.Contains(v.Error(), "t03") {
+		t.Errorf("error %q does not name t03", v.Error())
+	}
+
+	// Records dropped or added are reported even with the checksum recomputed
+	snap, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap.Vectors = snap.Vectors[1:]
+	snap.Vectors = append(snap.Vectors, &models.Vector{ID: "extra", Embedding: []float64{1, 0.25, -1}})
+	var rewritten bytes.Buffer
+	if err := snap.Write(&rewritten); err != nil {
+		t.Fatal(err)
+	}
+	_, v, err = ReadVerified(&rewritten, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Corrupted || len(v.Missing) != 1 || v.Missing[0] != "t00" || len(v.Unexpected) != 1 || v.Unexpected[0] != "extra" {
+		t.Errorf("verification = %+v, want t00 missing and extra unexpected", v)
+	}
+}
+
+func TestReadManifest_RejectsTamperedManifest(t *testing.T) {
+	key := []byte("compliance")
+	_, manifestData := writeWithManifest(t, 4, key)
+	lines := strings.SplitAfter(manifestData, "\n")
+
+	// A record hash rewritten to match a tampered record breaks the Merkle root
+	record := []byte(lines[2])
+	i := strings.Index(lines[2], `"sha256":"`) + len(`"sha256":"`)
+	if record[i] == '0' {
+		record[i] = '1'
+	} else {
+		record[i] = '0'
+	}
+
+	tests := []struct {
+		name string
+		data string
+		key  []byte
+	}{
+		{"record hash", lines[0] + lines[1] + string(record) + strings.Join(lines[3:], ""), nil},
+		{"missing record", lines[0] + lines[1] + strings.Join(lines[3:], ""), nil},
+		{"truncated", strings.Join(lines[:4], ""), nil},
+		{"wrong key", manifestData, []byte("other")},
+		{"header", strings.Replace(manifestData, "support-tickets", "support-ticket", 1), key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadManifest(strings.NewReader(tt.data), tt.key); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	_, unsigned := writeWithManifest(t, 2, nil)
+	if _, err := ReadManifest(strings.NewReader(unsigned), key); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("unsigned manifest read with a key: err = %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package snapshot
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+)
+
+const (
+	// Format identifies same-same snapshot files
+	Format = "same-same-snapshot"
+	// Version is the current snapshot file format version
+	Version = 1
+)
+
+// Header describes the contents of a snapshot file
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	Namespace string    `json:"namespace,omitempty"` // empty means every namespace
+	Embedder  string    `json:"embedder,omitempty"`
+	Dimension int       `json:"dimension"`
+	Count     int       `json:"count"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Generation is the store mutation generation the vectors were listed at, if tracked
+	Generation uint64 `json:"generation,omitempty"`
+}
+
+// footer closes a snapshot file and lets readers detect truncation
+type footer struct {
+	Count  int    `json:"count"`
+	SHA256 string `json:"sha256"`
+}
+
+// Snapshot is a self-describing set of vectors, optionally scoped to one namespace
+// On disk it is JSON lines: a header, one vector per line and a footer
+// holding the vector count and a SHA-256 over all preceding lines.
+type Snapshot struct {
+	Header  Header
+	Vectors []*models.Vector
+}
+
+// New builds a snapshot of vectors, recording embedder provenance and dimension
+func New(namespace string, vectors []*models.Vector) (*Snapshot, error) {
+	header := Header{
+		Format:    Format,
+		Version:   Version,
+		Namespace: namespace,
+		Count:     len(vectors),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	for _, vector := range vectors {
+		// Sparse vectors of unknown dimension fit any snapshot
+		dimension := vector.Dimension()
+		if vector.Sparse != nil && vector.Sparse.Dimension == 0 {
+			dimension = 0
+		}
+		switch {
+		case dimension == 0:
+		case header.Dimension == 0:
+			header.Dimension = dimension
+		case dimension != header.Dimension:
+			return nil, fmt.Errorf("vector %s has dimension %d, expected %d", vector.ID, dimension, header.Dimension)
+		}
+
+		name := vector.Metadata["embedder.name"]
+		switch {
+		case name == "":
+		case header.Embedder == "":
+			header.Embedder = name
+		case header.Embedder != name:
+			return nil, fmt.Errorf("vectors were produced by different embedders (%s, %s)", header.Embedder, name)
+		}
+	}
+
+	return &Snapshot{Header: header, Vectors: vectors}, nil
+}
+
+// Write encodes the snapshot to w
+func (s *Snapshot) Write(w io.Writer) error {
+	checksum := sha256.New()
+	out := io.MultiWriter(w, checksum)
+
+	if err := writeLine(out, s.Header); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	for _, vector := range s.Vectors {
+		if err := writeLine(out, vector); err != nil {
+			return fmt.Errorf("failed to write vector %s: %w", vector.ID, err)
+		}
+	}
+
+	return writeLine(w, footer{
+		Count:  len(s.Vectors),
+		SHA256: hex.EncodeToString(checksum.Sum(nil)),
+	})
+}
+
+func writeLine(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Read decodes a snapshot from r
+// The whole file is verified against its footer before it is returned,
+// so a truncated or corrupted transfer is never partially imported.
+func Read(r io.Reader) (*Snapshot, error) {
+	snap, corrupted, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+	if corrupted {
+		return nil, fmt.Errorf("snapshot checksum mismatch: file is corrupted")
+	}
+	return snap, nil
+}
+
+// read decodes a snapshot from r, reporting whether its checksum matches apart
+// from the other errors so a manifest can locate the records that changed
+func read(r io.Reader) (*Snapshot, bool, error) {
+	reader := bufio.NewReader(r)
+	checksum := sha256.New()
+
+	line, err := readLine(reader, checksum)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	snap := &Snapshot{}
+	if err := json.Unmarshal(line, &snap.Header); err != nil {
+		return nil, false, fmt.Errorf("invalid snapshot header: %w", err)
+	}
+	if snap.Header.Format != Format {
+		return nil, false, fmt.Errorf("not a same-same snapshot")
+	}
+	if snap.Header.Version > Version {
+		return nil, false, fmt.Errorf("unsupported snapshot version %d", snap.Header.Version)
+	}
+
+	corrupted := false
+	expectedSum := ""
+	for {
+		sum := hex.EncodeToString(checksum.Sum(nil))
+
+		line, err := readLine(reader, checksum)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		// The footer is the only line carrying a checksum
+		var f footer
+		if json.Unmarshal(line, &f) == nil && f.SHA256 != "" {
+			if f.Count != len(snap.Vectors) {
+				return nil, false, fmt.Errorf("snapshot footer expects %d vectors, found %d", f.Count, len(snap.Vectors))
+			}
+			if f.SHA256 != sum {
+				corrupted = true
+			}
+			expectedSum = f.SHA256
+			break
+		}
+
+		var vector models.Vector
+		if err := json.Unmarshal(line, &vector); err != nil {
+			return nil, false, fmt.Errorf("invalid vector on line %d: %w", len(snap.Vectors)+2, err)
+		}
+		snap.Vectors = append(snap.Vectors, &vector)
+	}
+
+	if expectedSum == "" {
+		return nil, false, fmt.Errorf("snapshot is truncated: missing footer after %d vectors", len(snap.Vectors))
+	}
+	if snap.Header.Count != len(snap.Vectors) {
+		return nil, false, fmt.Errorf("snapshot header expects %d vectors, found %d", snap.Header.Count, len(snap.Vectors))
+	}
+
+	return snap, corrupted, nil
+}
+
+// readLine reads one newline-terminated line, adding it to the running checksum
+// A final line without a newline is treated as truncated
+func readLine(reader *bufio.Reader, checksum hash.Hash) ([]byte, error) {
+	line, err := reader.ReadBytes('\n')
+	if err == io.EOF {
+		if len(line) > 0 {
+			return nil, fmt.Errorf("snapshot is truncated: incomplete last line")
+		}
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checksum.Write(line)
+	return line, nil
+}
+
+// Filter keeps only the vectors in namespace
+func (s *Snapshot) Filter(namespace string) {
+	if namespace == "" {
+		return
+	}
+
+	filtered := make([]*models.Vector, 0, len(s.Vectors))
+	for _, vector := range s.Vectors {
+		if vector.Metadata[models.NamespaceKey] == namespace {
+			filtered = append(filtered, vector)
+		}
+	}
+
+	s.Vectors = filtered
+	s.Header.Namespace = namespace
+	s.Header.Count = len(filtered)
+}
+
+// Rename moves every vector of the snapshot into namespace
+func (s *Snapshot) Rename(namespace string) {
+	for _, vector := range s.Vectors {
+		if vector.Metadata == nil {
+			vector.Metadata = make(map[string]string)
+		}
+		vector.Metadata[models.NamespaceKey] = namespace
+	}
+	s.Header.Namespace = namespace
+}
+
+// CheckCompatible verifies the snapshot can be loaded into target
+// embedderName is the embedder used by the target, if known
+func (s *Snapshot) CheckCompatible(target storage.Storage, embedderName string) error {
+	if embedderName != "" && s.Header.Embedder != "" && embedderName != s.Header.Embedder {
+		return fmt.Errorf("snapshot was produced by embedder %s but target uses %s", s.Header.Embedder, embedderName)
+	}
+
+	existing, err := target.List()
+	if err != nil {
+		return fmt.Errorf("failed to inspect target: %w", err)
+	}
+
+	for _, vector := range existing {
+		if len(vector.Embedding) == 0 && (vector.Sparse == nil || vector.Sparse.Dimension == 0) {
+			continue
+		}
+		if s.Header.Dimension != 0 && vector.Dimension() != s.Header.Dimension {
+			return fmt.Errorf("snapshot dimension %d does not match target dimension %d", s.Header.Dimension, vector.Dimension())
+		}
+		if name := vector.Metadata["embedder.name"]; name != "" && s.Header.Embedder != "" && name != s.Header.Embedder {
+			return fmt.Errorf("snapshot was produced by embedder %s but target holds %s vectors", s.Header.Embedder, name)
+		}
+		break
+	}
+
+	return nil
+}
+
+// Restore loads the snapshot vectors into target through the batch store path
+func (s *Snapshot) Restore(target storage.Storage) error {
+	return storage.StoreBatch(target, s.Vectors)
+}
@@ -0,0 +1,90 @@
+package snapshot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+)
+
+func testVectors() []*models.Vector {
+	return []*models.Vector{
+		{ID: "t1", Embedding: []float64{1, 0, 0}, Metadata: map[string]string{"namespace": "support-tickets", "embedder.name": "local.hash"}},
+		{ID: "t2", Embedding: []float64{0, 1, 0}, Metadata: map[string]string{"namespace": "support-tickets", "embedder.name": "local.hash"}},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	snap, err := New("support-tickets", testVectors())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := snap.Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if read.Header.Embedder != "local.hash" || read.Header.Dimension != 3 || read.Header.Count != 2 {
+		t.Errorf("unexpected header: %+v", read.Header)
+	}
+
+	read.Rename("tickets-copy")
+	store := memory.NewStorage()
+	if err := read.Restore(store); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.CountByNamespace("tickets-copy") != 2 {
+		t.Errorf("expected 2 vectors in renamed namespace, got %d", store.CountByNamespace("tickets-copy"))
+	}
+}
+
+func TestRead_DetectsTruncationAndCorruption(t *testing.T) {
+	snap, _ := New("support-tickets", testVectors())
+	var buf bytes.Buffer
+	_ = snap.Write(&buf)
+	data := buf.String()
+	lines := strings.SplitAfter(data, "\n")
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"missing footer", strings.Join(lines[:3], "")},
+		{"cut mid-line", data[:len(data)-20]},
+		{"corrupted vector", strings.Replace(data, `"t2"`, `"t3"`, 1)},
+		{"missing vector", lines[0] + lines[1] + lines[3]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(strings.NewReader(tt.data)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCheckCompatible(t *testing.T) {
+	snap, _ := New("support-tickets", testVectors())
+
+	store := memory.NewStorage()
+	_ = store.Store(&models.Vector{ID: "x", Embedding: []float64{1, 2}, Metadata: map[string]string{"embedder.name": "local.hash"}})
+
+	if err := snap.CheckCompatible(store, ""); err == nil {
+		t.Error("expected dimension mismatch error")
+	}
+	if err := snap.CheckCompatible(memory.NewStorage(), "gemini"); err == nil {
+		t.Error("expected embedder mismatch error")
+	}
+	if err := snap.CheckCompatible(memory.NewStorage(), "local.hash"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+// Package alias defines namespace aliases: names that requests use in place of a
+// namespace, retargeted atomically to switch readers over to a rebuilt namespace
+package alias
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// ErrNotFound is matched with errors.Is by the errors of unknown aliases
+var ErrNotFound = fmt.Errorf("alias %w", storeerr.ErrNotFound)
+
+// NotFound returns the error of an unknown alias
+func NotFound(name string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Alias points a name at a concrete namespace
+type Alias struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Previous is the namespace the last swap moved the alias away from, deleted
+	// at DeletePreviousAt when the swap asked for it
+	Previous         string     `json:"previous,omitempty"`
+	DeletePreviousAt *time.Time `json:"delete_previous_at,omitempty"`
+}
+
+// Aliases are namespace aliases keyed by name
+type Aliases map[string]Alias
+
+// Validate checks the names of the alias and its namespace
+func (a *Alias) Validate() error {
+	if err := ValidateName(a.Name); err != nil {
+		return err
+	}
+	if a.Namespace == "" {
+		return fmt.Errorf("alias %s: namespace cannot be empty", a.Name)
+	}
+	if a.Namespace == a.Name {
+		return fmt.Errorf("alias %s cannot point at itself", a.Name)
+	}
+	return nil
+}
+
+// ValidateName checks that name can be used in a URL path
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("alias name cannot be empty")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("invalid alias name %q: only letters, digits, '-', '_' and '.' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// Swap returns the alias pointed at namespace, remembering the namespace it
+// pointed at before, to be deleted after grace when grace is positive
+func (a Alias) Swap(namespace string, grace time.Duration, now time.Time) Alias {
+	swapped := Alias{Name: a.Name, Namespace: namespace, UpdatedAt: now}
+	if a.Namespace != namespace {
+		swapped.Previous = a.Namespace
+		if grace > 0 {
+			at := now.Add(grace)
+			swapped.DeletePreviousAt = &at
+		}
+	}
+	return swapped
+}
+
+// Sorted returns the aliases ordered by name
+func (as Aliases) Sorted() []Alias {
+	sorted := make([]Alias, 0, len(as))
+	for _, a := range as {
+		sorted = append(sorted, a)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
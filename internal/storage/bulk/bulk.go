@@ -0,0 +1,233 @@
+// Package bulk defines bulk maintenance jobs: deleting, moving or relabeling
+// every vector matching a filter, run in the background in rate-limited batches
+// as jobs of JobType
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/jobs"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Actions
+const (
+	ActionDelete       = "delete"        // Delete the matched vectors
+	ActionSetNamespace = "set-namespace" // Move the matched vectors to TargetNamespace
+	ActionSetMetadata  = "set-metadata"  // Set or remove metadata fields of the matched vectors
+)
+
+// JobType is the type of the jobs.Job running bulk operations
+const JobType = "bulk"
+
+// Job states, those of jobs.Job
+const (
+	StateQueued    = jobs.StateQueued
+	StateRunning   = jobs.StateRunning
+	StateCompleted = jobs.StateCompleted
+	StateFailed    = jobs.StateFailed
+	StateCanceled  = jobs.StateCanceled
+)
+
+const (
+	DefaultBatchSize = 500
+	MaxBatchSize     = 10000
+
+	// DefaultRate is the number of vectors processed per second at most, leaving
+	// room for foreground searches
+	DefaultRate = 2000
+
+	// SampleSize is the number of matched IDs returned by a dry run
+	SampleSize = 10
+)
+
+// ErrNotFound is matched with errors.Is by the errors of unknown jobs
+var ErrNotFound = fmt.Errorf("bulk job %w", storeerr.ErrNotFound)
+
+// NotFound returns the error of an unknown job
+func NotFound(id string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// Request selects the vectors of a bulk operation and what to do with them
+type Request struct {
+	Action string `json:"action"`
+
+	// Namespace and Filters select the target vectors, at least one is required
+	Namespace string         `json:"namespace,omitempty"`
+	Filters   models.Filters `json:"filters,omitempty"`
+
+	TargetNamespace string `json:"target_namespace,omitempty"` // Namespace of set-namespace
+
+	// Metadata holds the fields set by set-metadata, a null value removes the field
+	Metadata map[string]*string `json:"metadata,omitempty"`
+
+	// CascadeEnrichment names the metadata field keying the enrichment documents
+	// deleted along with the vectors of a delete job
+	CascadeEnrichment string `json:"cascade_enrichment,omitempty"`
+
+	BatchSize int `json:"batch_size,omitempty"` // Vectors per batch, DefaultBatchSize when unset
+	Rate      int `json:"rate,omitempty"`       // Vectors per second at most, DefaultRate when unset
+
+	// DryRun reports the matched vectors without starting a job
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Validate checks the request and fills the batch size and rate defaults
+func (r *Request) Validate() error {
+	switch r.Action {
+	case ActionDelete:
+	case ActionSetNamespace:
+		if r.TargetNamespace == "" {
+			return fmt.Errorf("target_namespace is required by %s", ActionSetNamespace)
+		}
+	case ActionSetMetadata:
+		if len(r.Metadata) == 0 {
+			return fmt.Errorf("metadata is required by %s", ActionSetMetadata)
+		}
+		for field := range r.Metadata {
+			if field == "" {
+				return fmt.Errorf("metadata cannot contain an empty field name")
+			}
+			if field == models.NamespaceKey {
+				return fmt.Errorf("use %s to change the namespace of vectors", ActionSetNamespace)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid action %q (must be: %s, %s, %s)", r.Action, ActionDelete, ActionSetNamespace, ActionSetMetadata)
+	}
+
+	if r.CascadeEnrichment != "" && r.Action != ActionDelete {
+		return fmt.Errorf("cascade_enrichment is only accepted by %s", ActionDelete)
+	}
+
+	if r.Namespace == "" && len(r.Filters) == 0 {
+		return fmt.Errorf("a namespace or filters are required to select the target vectors")
+	}
+	if _, err := models.NewFilterEvaluator().Compile(r.Filters); err != nil {
+		return err
+	}
+
+	if r.BatchSize < 0 || r.BatchSize > MaxBatchSize {
+		return fmt.Errorf("batch_size must be between 1 and %d", MaxBatchSize)
+	}
+	if r.BatchSize == 0 {
+		r.BatchSize = DefaultBatchSize
+	}
+	if r.Rate < 0 {
+		return fmt.Errorf("rate cannot be negative")
+	}
+	if r.Rate == 0 {
+		r.Rate = DefaultRate
+	}
+	return nil
+}
+
+// Apply returns a copy of vector changed by a set-namespace or set-metadata request
+func (r *Request) Apply(vector *models.Vector) *models.Vector {
+	copied := *vector
+	copied.Metadata = make(map[string]string, len(vector.Metadata)+len(r.Metadata))
+	for k, v := range vector.Metadata {
+		copied.Metadata[k] = v
+	}
+
+	switch r.Action {
+	case ActionSetNamespace:
+		copied.Metadata[models.NamespaceKey] = r.TargetNamespace
+	case ActionSetMetadata:
+		for field, value := range r.Metadata {
+			if value == nil {
+				delete(copied.Metadata, field)
+			} else {
+				copied.Metadata[field] = *value
+			}
+		}
+	}
+	return &copied
+}
+
+// DryRun is the outcome of a dry run request
+type DryRun struct {
+	Matched int      `json:"matched"`
+	Sample  []string `json:"sample"` // First matched IDs in processing order
+}
+
+// Job is the view of a bulk job returned by the bulk endpoints, and the record
+// of the bulk jobs persisted before they ran as jobs.Job
+// Vectors are processed in ID order, so a job resumes after Cursor
+type Job struct {
+	ID      string  `json:"id"`
+	Request Request `json:"request"`
+	State   string  `json:"state"`
+
+	Matched   int `json:"matched"`   // Vectors matched when the job started
+	Processed int `json:"processed"` // Matched vectors handled so far
+	Affected  int `json:"affected"`  // Vectors deleted or updated
+	Failed    int `json:"failed"`
+
+	Errors []string `json:"errors,omitempty"` // First failures, at most jobs.MaxErrors
+	Error  string   `json:"error,omitempty"`  // Why a failed job stopped
+
+	Cursor string `json:"cursor,omitempty"` // ID of the last processed vector
+
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job is done, successfully or not
+func (j *Job) Finished() bool {
+	return j.State == StateCompleted || j.State == StateFailed || j.State == StateCanceled
+}
+
+// View returns the bulk view of a job of JobType
+func View(job *jobs.Job) (*Job, error) {
+	var req Request
+	if err := json.Unmarshal(job.Params, &req); err != nil {
+		return nil, fmt.Errorf("invalid bulk job %s: %w", job.ID, err)
+	}
+	return &Job{
+		ID:         job.ID,
+		Request:    req,
+		State:      job.State,
+		Matched:    job.Progress.Total,
+		Processed:  job.Progress.Processed,
+		Affected:   job.Progress.Affected,
+		Failed:     job.Progress.Failed,
+		Errors:     job.Progress.Errors,
+		Error:      job.Error,
+		Cursor:     job.Checkpoint,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+		FinishedAt: job.FinishedAt,
+	}, nil
+}
+
+// Record returns the job of JobType of a bulk job persisted before bulk jobs ran as jobs.Job
+func (j *Job) Record() (*jobs.Job, error) {
+	params, err := json.Marshal(j.Request)
+	if err != nil {
+		return nil, err
+	}
+	return &jobs.Job{
+		ID:     j.ID,
+		Type:   JobType,
+		Params: params,
+		State:  j.State,
+		Progress: jobs.Progress{
+			Total:     j.Matched,
+			Processed: j.Processed,
+			Affected:  j.Affected,
+			Failed:    j.Failed,
+			Errors:    j.Errors,
+		},
+		Checkpoint: j.Cursor,
+		Error:      j.Error,
+		CreatedAt:  j.CreatedAt,
+		UpdatedAt:  j.UpdatedAt,
+		FinishedAt: j.FinishedAt,
+	}, nil
+}
@@ -0,0 +1,94 @@
+package bulk
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestRequest_Validate(t *testing.T) {
+	tag := "x"
+	tests := []struct {
+		name    string
+		req     Request
+		wantErr bool
+	}{
+		{name: "delete by namespace", req: Request{Action: ActionDelete, Namespace: "wrong"}},
+		{name: "move by filter", req: Request{Action: ActionSetNamespace, Filters: models.Filters{"source": {"eq": "crawl"}}, TargetNamespace: "web"}},
+		{name: "set metadata", req: Request{Action: ActionSetMetadata, Namespace: "docs", Metadata: map[string]*string{"tag": &tag, "old": nil}}},
+		{name: "unknown action", req: Request{Action: "purge", Namespace: "docs"}, wantErr: true},
+		{name: "no selection", req: Request{Action: ActionDelete}, wantErr: true},
+		{name: "move without target", req: Request{Action: ActionSetNamespace, Namespace: "docs"}, wantErr: true},
+		{name: "set no metadata", req: Request{Action: ActionSetMetadata, Namespace: "docs"}, wantErr: true},
+		{name: "set namespace metadata", req: Request{Action: ActionSetMetadata, Namespace: "docs", Metadata: map[string]*string{models.NamespaceKey: &tag}}, wantErr: true},
+		{name: "cascading delete", req: Request{Action: ActionDelete, Namespace: "docs", CascadeEnrichment: "product_id"}},
+		{name: "cascading move", req: Request{Action: ActionSetNamespace, Namespace: "docs", TargetNamespace: "web", CascadeEnrichment: "product_id"}, wantErr: true},
+		{name: "invalid filter", req: Request{Action: ActionDelete, Filters: models.Filters{"ra*": {models.MatchModifier: "most"}}}, wantErr: true},
+		{name: "batch too large", req: Request{Action: ActionDelete, Namespace: "docs", BatchSize: MaxBatchSize + 1}, wantErr: true},
+		{name: "negative rate", req: Request{Action: ActionDelete, Namespace: "docs", Rate: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (tt.req.BatchSize != DefaultBatchSize || tt.req.Rate != DefaultRate) {
+				t.Errorf("defaults not applied: batch_size = %d, rate = %d", tt.req.BatchSize, tt.req.Rate)
+			}
+		})
+	}
+}
+
+func TestRequest_Apply(t *testing.T) {
+	vector := &models.Vector{ID: "v", Metadata: map[string]string{models.NamespaceKey: "wrong", "tag": "a", "old": "b"}}
+
+	moved := (&Request{Action: ActionSetNamespace, TargetNamespace: "right"}).Apply(vector)
+	if moved.Metadata[models.NamespaceKey] != "right" || moved.Metadata["tag"] != "a" {
+		t.Errorf("moved metadata = %v", moved.Metadata)
+	}
+
+	tag := "c"
+	updated := (&Request{Action: ActionSetMetadata, Metadata: map[string]*string{"tag": &tag, "old": nil}}).Apply(vector)
+	if _, ok := updated.Metadata["old"]; ok || updated.Metadata["tag"] != "c" {
+		t.Errorf("updated metadata = %v", updated.Metadata)
+	}
+
+	if vector.Metadata[models.NamespaceKey] != "wrong" || vector.Metadata["tag"] != "a" || vector.Metadata["old"] != "b" {
+		t.Errorf("original vector modified: %v", vector.Metadata)
+	}
+}
+
+func TestJob_RecordView(t *testing.T) {
+	now := time.Now().UTC()
+	legacy := &Job{
+		ID:        "legacy",
+		Request:   Request{Action: ActionDelete, Namespace: "old", BatchSize: 10, Rate: 100},
+		State:     StateRunning,
+		Matched:   40,
+		Processed: 20,
+		Affected:  18,
+		Failed:    2,
+		Errors:    []string{"vector a: quota exceeded", "vector b: quota exceeded"},
+		Cursor:    "old-19",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	record, err := legacy.Record()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Type != JobType || record.Checkpoint != "old-19" || record.Progress.Total != 40 {
+		t.Errorf("record = %+v", record)
+	}
+	view, err := View(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(view, legacy) {
+		t.Errorf("View(Record()) = %+v, want %+v", view, legacy)
+	}
+}
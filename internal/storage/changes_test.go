@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+)
+
+func TestBackendChanges(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			// tick separates the operations in time and returns the boundary between them
+			tick := func() time.Time {
+				time.Sleep(2 * time.Millisecond)
+				boundary := time.Now()
+				time.Sleep(2 * time.Millisecond)
+				return boundary
+			}
+			ids := func(changes *tombstone.Changes) (updated, deleted []string) {
+				for _, v := range changes.Vectors {
+					updated = append(updated, v.ID)
+				}
+				for _, d := range changes.Deleted {
+					deleted = append(deleted, d.ID)
+				}
+				return updated, deleted
+			}
+
+			beforeStore := tick()
+			for _, id := range []string{"a", "b", "c"} {
+				v := &models.Vector{ID: id, Embedding: []float64{1, 0}, Metadata: map[string]string{models.NamespaceKey: "docs"}}
+				if err := s.Store(v); err != nil {
+					t.Fatalf("store %s: %v", id, err)
+				}
+			}
+			beforeUpdate := tick()
+			if err := s.Store(&models.Vector{ID: "a", Embedding: []float64{0, 1}, Metadata: map[string]string{models.NamespaceKey: "docs"}}); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			beforeDelete := tick()
+			if err := s.Delete("b"); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			afterDelete := tick()
+
+			tests := []struct {
+				name      string
+				since     time.Time
+				namespace string
+				updated   int
+				deleted   []string
+			}{
+				{"before store", beforeStore, "", 2, []string{"b"}},
+				{"before update", beforeUpdate, "docs", 1, []string{"b"}},
+				{"before delete", beforeDelete, "", 0, []string{"b"}},
+				{"after delete", afterDelete, "", 0, nil},
+				{"other namespace", beforeStore, "other", 0, nil},
+			}
+			for _, tt := range tests {
+				changes, err := s.Changes(tt.namespace, tt.since)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				updated, deleted := ids(changes)
+				if len(updated) != tt.updated || len(deleted) != len(tt.deleted) || (len(deleted) > 0 && deleted[0] != tt.deleted[0]) {
+					t.Errorf("%s: updated %v, deleted %v", tt.name, updated, deleted)
+				}
+				if tt.updated == 2 && updated[1] != "a" {
+					t.Errorf("%s: updated %v, want the updated vector last", tt.name, updated)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalTombstonesPersist(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := local.NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	if err := adapter.Store(&models.Vector{ID: "a", Embedding: []float64{1, 0}}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if err := adapter.Delete("a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	adapter.Close()
+
+	reopened, err := local.NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	changes, err := reopened.Changes("", time.Time{})
+	if err != nil || len(changes.Deleted) != 1 || changes.Deleted[0].ID != "a" {
+		t.Fatalf("changes after reopening = %+v, %v", changes, err)
+	}
+
+	if err := reopened.SetTombstoneOptions(tombstone.Options{MaxEntries: 1, Retention: time.Nanosecond}); err != nil {
+		t.Fatalf("set tombstone options: %v", err)
+	}
+	changes, _ = reopened.Changes("", time.Time{})
+	if len(changes.Deleted) != 0 || changes.Horizon.IsZero() {
+		t.Errorf("tombstone kept past its retention: %+v", changes)
+	}
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/tiered"
+)
+
+// conformanceBackends returns the backends plus a write-behind tiered storage, whose
+// writes fail after they return and so is left out of the error kind checks
+func conformanceBackends(t *testing.T) map[string]Storage {
+	t.Helper()
+	stores := make(map[string]Storage)
+	for name, s := range backends(t) {
+		stores[name] = s
+	}
+	cold, err := local.NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	writeBehind, err := tiered.New(cold, tiered.Options{WriteBehind: true, QueueSize: 2})
+	if err != nil {
+		t.Fatalf("failed to create tiered storage: %v", err)
+	}
+	t.Cleanup(func() { writeBehind.Close() })
+	stores["tiered write-behind"] = writeBehind
+	return stores
+}
+
+func TestBackendConformance(t *testing.T) {
+	for name, s := range conformanceBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			vector := func(id, namespace, color string, embedding ...float64) *models.Vector {
+				return &models.Vector{ID: id, Embedding: embedding, Metadata: map[string]string{models.NamespaceKey: namespace, "color": color}}
+			}
+			for _, v := range []*models.Vector{
+				vector("a", "x", "red", 1, 0),
+				vector("b", "x", "blue", 0, 1),
+				vector("c", "y", "red", 1, 1),
+				vector("pending", "x", "red"),
+			} {
+				if err := s.Store(v); err != nil {
+					t.Fatalf("store %s: %v", v.ID, err)
+				}
+			}
+
+			// Reads see every write, replacing vectors by ID
+			if err := s.Store(vector("a", "x", "green", 1, 0)); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := s.Get("a"); err != nil || got.Metadata["color"] != "green" {
+				t.Errorf("get a = %v, %v", got, err)
+			}
+			if _, err := s.Get("missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("get missing: err = %v", err)
+			}
+			many, err := s.GetMany([]string{"c", "missing", "a"})
+			if err != nil || len(many) != 3 || many[0].ID != "c" || many[1] != nil || many[2].ID != "a" {
+				t.Errorf("get many = %v, %v", many, err)
+			}
+
+			if count := s.Count(); count != 4 {
+				t.Errorf("count = %d, want 4", count)
+			}
+			if count := s.CountByNamespace("x"); count != 3 {
+				t.Errorf("count in x = %d, want 3", count)
+			}
+			listed, err := s.ListByNamespace("y")
+			if err != nil || len(listed) != 1 || listed[0].ID != "c" {
+				t.Errorf("list y = %v, %v", listed, err)
+			}
+			if all, err := s.List(); err != nil || len(all) != 4 {
+				t.Errorf("list = %d vectors, %v", len(all), err)
+			}
+
+			results, err := s.Search(&models.SearchByEmbbedingRequest{Embedding: []float64{1, 0}, TopK: 2})
+			if err != nil || len(results) != 2 || results[0].Vector.ID != "a" || results[0].Score < results[1].Score {
+				t.Errorf("search = %v, %v", results, err)
+			}
+
+			if err := s.Delete("b"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get("b"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("get deleted: err = %v", err)
+			}
+			if count := s.CountByNamespace("x"); count != 2 {
+				t.Errorf("count in x after delete = %d, want 2", count)
+			}
+
+			// Summaries of the stored vectors see the writes too
+			if counter, ok := s.(PendingCounter); ok {
+				if pending := counter.CountPending("x"); pending != 1 {
+					t.Errorf("pending in x = %d, want 1", pending)
+				}
+			}
+			if describer, ok := s.(SchemaDescriber); ok {
+				schema, err := describer.MetadataSchema("x")
+				if err != nil || schema.Vectors != 2 || schema.Fields["color"] == nil {
+					t.Errorf("schema of x = %+v, %v", schema, err)
+				}
+			}
+			if counter, ok := s.(FacetCounter); ok {
+				facet, err := counter.Facet("", "color", 10)
+				if err != nil || facet.Count != 3 || len(facet.Values) != 2 {
+					t.Errorf("facet of color = %+v, %v", facet, err)
+				}
+			}
+			if manager, ok := s.(QuotaManager); ok {
+				if usage := manager.QuotaUsage(); usage["x"].Vectors != 2 || usage["y"].Vectors != 1 {
+					t.Errorf("quota usage = %+v", usage)
+				}
+			}
+			if lister, ok := s.(interface {
+				IDs(namespace string) ([]string, error)
+			}); ok {
+				ids, err := lister.IDs("x")
+				sort.Strings(ids)
+				if err != nil || len(ids) != 2 || ids[0] != "a" || ids[1] != "pending" {
+					t.Errorf("ids of x = %v, %v", ids, err)
+				}
+			}
+		})
+	}
+}
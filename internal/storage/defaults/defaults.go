@@ -0,0 +1,184 @@
+// Package defaults adds configured default metadata, such as environment=prod, to
+// the vectors written by the server and the ingestors, so every client need not
+// remember to set it
+package defaults
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// ErrLocked is matched with errors.Is by the errors of writes setting another
+// value than the default of a locked key. It is a validation error
+var ErrLocked = fmt.Errorf("default metadata locked: %w", storeerr.ErrValidation)
+
+// Error reports a write setting another value than the default of a locked key
+type Error struct {
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	Default   string `json:"default"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("metadata %q is locked to %q, got %q", e.Key, e.Default, e.Value)
+}
+
+// Is makes every locked key error match ErrLocked and the validation errors
+func (e *Error) Is(target error) bool {
+	return target == ErrLocked || target == storeerr.ErrValidation
+}
+
+// Config is the default metadata of written vectors
+// Defaults are only added when a vector lacks the key. With Enforce, a vector
+// setting a locked key to another value is rejected instead
+type Config struct {
+	Metadata map[string]string `json:"metadata"`
+
+	// Namespaces override or add defaults for the vectors of a namespace
+	Namespaces map[string]map[string]string `json:"namespaces,omitempty"`
+
+	Enforce bool `json:"enforce,omitempty"`
+	// Locked lists the keys Enforce applies to, every default key when empty
+	Locked []string `json:"locked,omitempty"`
+}
+
+// Load reads and validates a JSON config file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read default metadata: %w", err)
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse default metadata %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("default metadata %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// FromEnv loads the config file referenced by DEFAULT_METADATA
+// Returns nil without error when none is configured
+func FromEnv() (*Config, error) {
+	path := os.Getenv("DEFAULT_METADATA")
+	if path == "" {
+		return nil, nil
+	}
+	return Load(path)
+}
+
+// Validate rejects empty keys, defaults of the namespace key, and locked keys
+// without a default
+func (c *Config) Validate() error {
+	check := func(metadata map[string]string) error {
+		for key := range metadata {
+			if key == "" {
+				return fmt.Errorf("default metadata keys cannot be empty")
+			}
+			if key == models.NamespaceKey {
+				return fmt.Errorf("%s cannot have a default value", models.NamespaceKey)
+			}
+		}
+		return nil
+	}
+	if err := check(c.Metadata); err != nil {
+		return err
+	}
+	for _, namespace := range sortedKeys(c.Namespaces) {
+		if err := check(c.Namespaces[namespace]); err != nil {
+			return fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+	}
+	for _, key := range c.Locked {
+		if !c.hasDefault(key) {
+			return fmt.Errorf("locked key %q has no default value", key)
+		}
+	}
+	return nil
+}
+
+// hasDefault reports whether key has a default in any namespace
+func (c *Config) hasDefault(key string) bool {
+	if _, ok := c.Metadata[key]; ok {
+		return true
+	}
+	for _, metadata := range c.Namespaces {
+		if _, ok := metadata[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// For returns the defaults of the vectors of namespace
+func (c *Config) For(namespace string) map[string]string {
+	defaults := make(map[string]string, len(c.Metadata)+len(c.Namespaces[namespace]))
+	for key, value := range c.Metadata {
+		defaults[key] = value
+	}
+	for key, value := range c.Namespaces[namespace] {
+		defaults[key] = value
+	}
+	return defaults
+}
+
+// locked reports whether Enforce applies to key
+func (c *Config) locked(key string) bool {
+	if !c.Enforce {
+		return false
+	}
+	if len(c.Locked) == 0 {
+		return true
+	}
+	for _, k := range c.Locked {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply adds the defaults of the namespace of metadata for the keys it lacks and
+// returns it, allocating it when nil. It fails with an *Error, leaving metadata
+// unchanged, when a locked key has another value than its default
+// A nil config returns metadata as it is
+func (c *Config) Apply(metadata map[string]string) (map[string]string, error) {
+	if c == nil {
+		return metadata, nil
+	}
+	namespace := metadata[models.NamespaceKey]
+	defaults := c.For(namespace)
+
+	// Keys are checked in order so the error names the same key on every run
+	keys := sortedKeys(defaults)
+	for _, key := range keys {
+		if value, ok := metadata[key]; ok && value != defaults[key] && c.locked(key) {
+			return metadata, &Error{Key: key, Value: value, Default: defaults[key], Namespace: namespace}
+		}
+	}
+	if metadata == nil && len(defaults) > 0 {
+		metadata = make(map[string]string, len(defaults))
+	}
+	for _, key := range keys {
+		if _, ok := metadata[key]; !ok {
+			metadata[key] = defaults[key]
+		}
+	}
+	return metadata, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,91 @@
+package defaults
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+func TestConfig_Apply(t *testing.T) {
+	c := &Config{
+		Metadata:   map[string]string{"environment": "prod", "region": "eu"},
+		Namespaces: map[string]map[string]string{"staging": {"environment": "staging"}},
+	}
+
+	got, err := c.Apply(nil)
+	if err != nil || !reflect.DeepEqual(got, map[string]string{"environment": "prod", "region": "eu"}) {
+		t.Errorf("defaults of nil metadata = %v, %v", got, err)
+	}
+
+	// Namespace defaults override the global ones, and callers override both
+	got, err = c.Apply(map[string]string{models.NamespaceKey: "staging", "region": "us"})
+	want := map[string]string{models.NamespaceKey: "staging", "environment": "staging", "region": "us"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("defaults of staging = %v, %v, want %v", got, err, want)
+	}
+
+	var none *Config
+	if got, err := none.Apply(map[string]string{"a": "b"}); err != nil || len(got) != 1 {
+		t.Errorf("nil config applied = %v, %v", got, err)
+	}
+}
+
+func TestConfig_Enforce(t *testing.T) {
+	c := &Config{Metadata: map[string]string{"environment": "prod", "region": "eu"}, Enforce: true, Locked: []string{"environment"}}
+
+	metadata := map[string]string{"environment": "dev"}
+	_, err := c.Apply(metadata)
+	var locked *Error
+	if !errors.As(err, &locked) || locked.Key != "environment" || locked.Value != "dev" || locked.Default != "prod" {
+		t.Fatalf("conflicting locked key err = %v", err)
+	}
+	if !errors.Is(err, ErrLocked) || !errors.Is(err, storeerr.ErrValidation) {
+		t.Errorf("locked key error does not match ErrLocked and ErrValidation")
+	}
+	if len(metadata) != 1 {
+		t.Errorf("rejected metadata was changed: %v", metadata)
+	}
+
+	// Unlocked keys can still be overridden, and matching values pass
+	got, err := c.Apply(map[string]string{"environment": "prod", "region": "us"})
+	if err != nil || got["region"] != "us" {
+		t.Errorf("unlocked override = %v, %v", got, err)
+	}
+
+	// Without a list, every default key is locked
+	c.Locked = nil
+	if _, err := c.Apply(map[string]string{"region": "us"}); !errors.Is(err, ErrLocked) {
+		t.Errorf("override of a default key with every key locked: err = %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "defaults.json")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	c, err := Load(write(`{"metadata": {"environment": "prod"}, "enforce": true}`))
+	if err != nil || !c.Enforce || c.Metadata["environment"] != "prod" {
+		t.Fatalf("Load = %+v, %v", c, err)
+	}
+
+	for _, content := range []string{
+		`{"metadata": {"namespace": "default"}}`,
+		`{"metadata": {"": "x"}}`,
+		`{"metadata": {"a": "b"}, "locked": ["c"]}`,
+		`{"metadata": `,
+	} {
+		if _, err := Load(write(content)); err == nil {
+			t.Errorf("%s was accepted", content)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package enrichment defines enrichment documents: JSON blobs keyed by a metadata
+// value, such as a product ID, joined to search results so clients can render them
+// without a second lookup
+package enrichment
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+const (
+	// MaxSize is the largest accepted document, in bytes of JSON
+	MaxSize = 64 << 10
+
+	// MaxKeyLength is the longest accepted key
+	MaxKeyLength = 512
+
+	// MaxBatch is the number of documents accepted by one bulk upload
+	MaxBatch = 1000
+)
+
+// ErrNotFound is matched with errors.Is by the errors of unknown keys
+var ErrNotFound = fmt.Errorf("enrichment %w", storeerr.ErrNotFound)
+
+// NotFound returns the error of an unknown key
+func NotFound(key string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+// Validate checks the key and document of an entry
+func Validate(key string, doc json.RawMessage) error {
+	if key == "" {
+		return fmt.Errorf("enrichment key cannot be empty")
+	}
+	if len(key) > MaxKeyLength {
+		return fmt.Errorf("enrichment key exceeds %d bytes", MaxKeyLength)
+	}
+	if len(doc) == 0 {
+		return fmt.Errorf("enrichment %s: document cannot be empty", key)
+	}
+	if len(doc) > MaxSize {
+		return fmt.Errorf("enrichment %s: document of %d bytes exceeds %d bytes", key, len(doc), MaxSize)
+	}
+	if !json.Valid(doc) {
+		return fmt.Errorf("enrichment %s: document is not valid JSON", key)
+	}
+	return nil
+}
+
+// ValidateBatch checks every entry of a bulk upload
+func ValidateBatch(entries map[string]json.RawMessage) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("at least one enrichment document is required")
+	}
+	if len(entries) > MaxBatch {
+		return fmt.Errorf("%d enrichment documents exceed the limit of %d per upload", len(entries), MaxBatch)
+	}
+	for key, doc := range entries {
+		if err := Validate(key, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Error kinds returned by the backends, wrapped with their details
+// Match them with errors.Is; errors of no kind are backend failures such as I/O errors
+var (
+	// ErrNotFound matches missing vectors, collections, profiles and evaluation sets
+	ErrNotFound = storeerr.ErrNotFound
+
+	// ErrAlreadyExists matches creates of taken names and unique key conflicts
+	ErrAlreadyExists = storeerr.ErrAlreadyExists
+
+	// ErrDimensionMismatch matches vectors whose dimension the collection does not hold
+	ErrDimensionMismatch = storeerr.ErrDimensionMismatch
+
+	// ErrValidation matches writes the backend rejected as invalid
+	ErrValidation = storeerr.ErrValidation
+
+	// ErrQuotaExceeded matches writes rejected by a namespace quota or a memory limit,
+	// see quota.Error and memlimit.Error
+	ErrQuotaExceeded = storeerr.ErrQuotaExceeded
+)
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/tiered"
+)
+
+// backend is a storage supporting every optional interface the error kinds are checked on
+type backend interface {
+	Storage
+	AtomicStorer
+	ProfileStore
+	EvalStore
+	QuotaManager
+	UniqueKeyIndexer
+	ChangeLister
+}
+
+// backends returns a new instance of each backend
+func backends(t *testing.T) map[string]backend {
+	t.Helper()
+	adapter, err := local.NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	cold, err := local.NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	composite, err := tiered.New(cold, tiered.Options{})
+	if err != nil {
+		t.Fatalf("failed to create tiered storage: %v", err)
+	}
+	return map[string]backend{
+		"memory": memory.NewStorage(),
+		"local":  adapter,
+		"tiered": composite,
+	}
+}
+
+func TestBackendErrorKinds(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			check := func(condition string, err, kind error) {
+				t.Helper()
+				if !errors.Is(err, kind) {
+					t.Errorf("%s: err = %v, want %v", condition, err, kind)
+				}
+			}
+
+			stored := &models.Vector{ID: "a", Embedding: []float64{1, 0}, Metadata: map[string]string{"sku": "1"}}
+			if err := s.Store(stored); err != nil {
+				t.Fatalf("store: %v", err)
+			}
+
+			_, err := s.Get("missing")
+			check("get missing vector", err, ErrNotFound)
+			check("delete missing vector", s.Delete("missing"), ErrNotFound)
+			check("store without ID", s.Store(&models.Vector{Embedding: []float64{1, 0}}), ErrValidation)
+			check("atomic batch with duplicate IDs", s.StoreAll([]*models.Vector{stored, stored}), ErrValidation)
+
+			check("delete missing profile", s.DeleteProfile("missing"), ErrNotFound)
+			check("invalid profile", s.SetProfile(profile.Profile{Name: "broken", Metric: "manhattan"}), ErrValidation)
+
+			set := eval.Set{Name: "faq", Queries: []eval.Query{{Query: "refund", Relevant: []string{"a"}}}}
+			if err := s.CreateEvalSet(set); err != nil {
+				t.Fatalf("create eval set: %v", err)
+			}
+			check("existing eval set", s.CreateEvalSet(set), ErrAlreadyExists)
+			check("delete missing eval set", s.DeleteEvalSet("missing"), ErrNotFound)
+			check("invalid eval set", s.CreateEvalSet(eval.Set{Name: "empty"}), ErrValidation)
+
+			if err := s.SetUniqueKeys([]string{"sku"}); err != nil {
+				t.Fatalf("set unique keys: %v", err)
+			}
+			_, err = s.GetByKey("", "sku", "2")
+			check("missing unique key", err, ErrNotFound)
+			check("unique key conflict", s.Store(&models.Vector{ID: "b", Embedding: []float64{0, 1}, Metadata: map[string]string{"sku": "1"}}), ErrAlreadyExists)
+
+			check("negative quota", s.SetQuota("", quota.Limit{MaxVectors: -1}), ErrValidation)
+			if err := s.SetQuota("", quota.Limit{MaxVectors: 1}); err != nil {
+				t.Fatalf("set quota: %v", err)
+			}
+			check("quota exceeded", s.Store(&models.Vector{ID: "c", Embedding: []float64{0, 1}}), ErrQuotaExceeded)
+		})
+	}
+}
+
+func TestLocalErrorKinds(t *testing.T) {
+	adapter, err := local.NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	if err := adapter.Store(&models.Vector{ID: "a", Embedding: []float64{1, 0}}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	if err := adapter.Store(&models.Vector{ID: "b", Embedding: []float64{1, 0, 0}}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("store of another dimension: err = %v, want %v", err, ErrDimensionMismatch)
+	}
+	mixed := []*models.Vector{{ID: "b", Embedding: []float64{1, 0}}, {ID: "c", Embedding: []float64{1, 0, 0}}}
+	if err := adapter.StoreAll(mixed); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("batch mixing dimensions: err = %v, want %v", err, ErrDimensionMismatch)
+	}
+
+	ls, err := local.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if _, err := ls.CreateCollection("vectors", "", nil); err != nil {
+		t.Fatalf("create collection: %v", err)
+	}
+	if _, err := ls.CreateCollection("vectors", "", nil); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("existing collection: err = %v, want %v", err, ErrAlreadyExists)
+	}
+	if _, err := ls.GetDocument("missing", "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing collection: err = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := ls.CreateCollection("bad/name", "", nil); !errors.Is(err, ErrValidation) {
+		t.Errorf("invalid collection name: err = %v, want %v", err, ErrValidation)
+	}
+}
@@ -0,0 +1,270 @@
+// Package eval defines evaluation sets: labeled queries with the vectors relevant
+// to them, scored against the live search pipeline to track retrieval quality
+package eval
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// DefaultK is the cutoff of the metrics of sets that set none
+const DefaultK = 10
+
+// MaxRuns is the number of runs kept per set, older runs are dropped
+const MaxRuns = 100
+
+// Run triggers
+const (
+	TriggerAPI      = "api"
+	TriggerSchedule = "schedule"
+	TriggerCLI      = "cli"
+)
+
+// ErrNotFound is matched with errors.Is by the errors of unknown sets
+var ErrNotFound = fmt.Errorf("evaluation set %w", storeerr.ErrNotFound)
+
+// NotFound returns the error of an unknown set
+func NotFound(name string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// ErrExists is matched with errors.Is by the errors of sets created twice
+var ErrExists = fmt.Errorf("evaluation set %w", storeerr.ErrAlreadyExists)
+
+// Query is a labeled query and the IDs of the vectors relevant to it
+type Query struct {
+	Query    string   `json:"query"`
+	Relevant []string `json:"relevant"`
+}
+
+// Set is a named list of labeled queries
+type Set struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// Namespace and Profile are applied to every query of the set
+	Namespace string `json:"namespace,omitempty"`
+	Profile   string `json:"profile,omitempty"`
+
+	// K is the number of results retrieved and scored per query
+	K int `json:"k,omitempty"`
+
+	// ScheduleHours runs the set every so many hours, 0 runs it on request only
+	ScheduleHours int `json:"schedule_hours,omitempty"`
+
+	Queries []Query `json:"queries"`
+}
+
+// Sets are evaluation sets keyed by name
+type Sets map[string]Set
+
+// Cutoff returns the number of results scored per query
+func (s *Set) Cutoff() int {
+	if s.K > 0 {
+		return s.K
+	}
+	return DefaultK
+}
+
+// Validate checks the name and every query of the set
+func (s *Set) Validate() error {
+	if err := ValidateName(s.Name); err != nil {
+		return err
+	}
+	if s.K < 0 {
+		return fmt.Errorf("evaluation set %s: k cannot be negative", s.Name)
+	}
+	if s.ScheduleHours < 0 {
+		return fmt.Errorf("evaluation set %s: schedule_hours cannot be negative", s.Name)
+	}
+	if len(s.Queries) == 0 {
+		return fmt.Errorf("evaluation set %s: at least one query is required", s.Name)
+	}
+	for i, q := range s.Queries {
+		if q.Query == "" {
+			return fmt.Errorf("evaluation set %s: query %d is empty", s.Name, i)
+		}
+		if len(q.Relevant) == 0 {
+			return fmt.Errorf("evaluation set %s: query %q has no relevant vector IDs", s.Name, q.Query)
+		}
+	}
+	return nil
+}
+
+// ValidateName checks that name can be used in a URL path
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("evaluation set name cannot be empty")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("invalid evaluation set name %q: only letters, digits, '-', '_' and '.' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// Sorted returns the sets ordered by name
+func (sets Sets) Sorted() []Set {
+	sorted := make([]Set, 0, len(sets))
+	for _, s := range sets {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// Metrics are the retrieval metrics at the cutoff of a set
+type Metrics struct {
+	Recall    float64 `json:"recall"`
+	Precision float64 `json:"precision"`
+	MRR       float64 `json:"mrr"`
+	NDCG      float64 `json:"ndcg"`
+}
+
+// QueryResult is the outcome of one query of a run
+type QueryResult struct {
+	Query     string   `json:"query"`
+	Retrieved []string `json:"retrieved"`
+	Metrics
+}
+
+// Run is one scoring of an evaluation set
+type Run struct {
+	ID        string    `json:"id"`
+	Set       string    `json:"set"`
+	Trigger   string    `json:"trigger"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	K         int       `json:"k"`
+
+	// Metrics are the means over the queries
+	Metrics Metrics       `json:"metrics"`
+	Queries []QueryResult `json:"queries"`
+}
+
+// Score computes the metrics of the IDs retrieved for a query, in rank order,
+// against its relevant IDs. Relevance is binary and only the first k IDs count
+func Score(retrieved, relevant []string, k int) Metrics {
+	if len(retrieved) > k {
+		retrieved = retrieved[:k]
+	}
+	isRelevant := make(map[string]bool, len(relevant))
+	for _, id := range relevant {
+		isRelevant[id] = true
+	}
+
+	var m Metrics
+	hits, dcg := 0, 0.0
+	for i, id := range retrieved {
+		if !isRelevant[id] {
+			continue
+		}
+		// Count each relevant vector once should an ID be returned twice
+		delete(isRelevant, id)
+		hits++
+		dcg += 1 / math.Log2(float64(i+2))
+		if m.MRR == 0 {
+			m.MRR = 1 / float64(i+1)
+		}
+	}
+
+	idcg := 0.0
+	for i := 0; i < len(relevant) && i < k; i++ {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+
+	if len(relevant) > 0 {
+		m.Recall = float64(hits) / float64(len(relevant))
+		m.NDCG = dcg / idcg
+	}
+	if k > 0 {
+		m.Precision = float64(hits) / float64(k)
+	}
+	return m
+}
+
+// Mean returns the mean metrics of the query results
+func Mean(results []QueryResult) Metrics {
+	var mean Metrics
+	if len(results) == 0 {
+		return mean
+	}
+	for _, r := range results {
+		mean.Recall += r.Recall
+		mean.Precision += r.Precision
+		mean.MRR += r.MRR
+		mean.NDCG += r.NDCG
+	}
+	n := float64(len(results))
+	mean.Recall /= n
+	mean.Precision /= n
+	mean.MRR /= n
+	mean.NDCG /= n
+	return mean
+}
+
+// RunsOf returns the runs of the named set, newest first
+func RunsOf(runs []*Run, set string) []*Run {
+	matched := make([]*Run, 0)
+	for _, run := range runs {
+		if run.Set == set {
+			matched = append(matched, run)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].StartTime.After(matched[j].StartTime) })
+	return matched
+}
+
+// AppendRun adds run to runs, dropping the oldest runs of its set beyond MaxRuns
+func AppendRun(runs []*Run, run *Run) []*Run {
+	runs = append(runs, run)
+
+	count := 0
+	for _, r := range runs {
+		if r.Set == run.Set {
+			count++
+		}
+	}
+	if count <= MaxRuns {
+		return runs
+	}
+
+	// Runs are appended in order, so the first ones of the set are the oldest
+	drop := count - MaxRuns
+	kept := runs[:0]
+	for _, r := range runs {
+		if r.Set == run.Set && drop > 0 {
+			drop--
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// WithoutSet returns runs without those of the named set
+func WithoutSet(runs []*Run, set string) []*Run {
+	kept := runs[:0]
+	for _, r := range runs {
+		if r.Set != set {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// Due reports whether a scheduled set should run at now given its latest run, nil if it never ran
+func (s *Set) Due(latest *Run, now time.Time) bool {
+	if s.ScheduleHours <= 0 {
+		return false
+	}
+	if latest == nil {
+		return true
+	}
+	return !now.Before(latest.StartTime.Add(time.Duration(s.ScheduleHours) * time.Hour))
+}
@@ -0,0 +1,130 @@
+package eval
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		retrieved []string
+		relevant  []string
+		k         int
+		want      Metrics
+	}{
+		{
+			name:      "perfect ranking",
+			retrieved: []string{"a", "b", "x"},
+			relevant:  []string{"a", "b"},
+			k:         3,
+			want:      Metrics{Recall: 1, Precision: 2.0 / 3, MRR: 1, NDCG: 1},
+		},
+		{
+			name:      "relevant ranked second",
+			retrieved: []string{"x", "a"},
+			relevant:  []string{"a"},
+			k:         2,
+			want:      Metrics{Recall: 1, Precision: 0.5, MRR: 0.5, NDCG: 1 / math.Log2(3)},
+		},
+		{
+			name:      "relevant beyond the cutoff",
+			retrieved: []string{"x", "y", "a"},
+			relevant:  []string{"a"},
+			k:         2,
+			want:      Metrics{},
+		},
+		{
+			name:      "half recalled",
+			retrieved: []string{"a", "x"},
+			relevant:  []string{"a", "b"},
+			k:         2,
+			want:      Metrics{Recall: 0.5, Precision: 0.5, MRR: 1, NDCG: 1 / (1 + 1/math.Log2(3))},
+		},
+		{
+			name:      "duplicate IDs count once",
+			retrieved: []string{"a", "a"},
+			relevant:  []string{"a"},
+			k:         2,
+			want:      Metrics{Recall: 1, Precision: 0.5, MRR: 1, NDCG: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Score(tt.retrieved, tt.relevant, tt.k)
+			for metric, pair := range map[string][2]float64{
+				"recall":    {got.Recall, tt.want.Recall},
+				"precision": {got.Precision, tt.want.Precision},
+				"mrr":       {got.MRR, tt.want.MRR},
+				"ndcg":      {got.NDCG, tt.want.NDCG},
+			} {
+				if math.Abs(pair[0]-pair[1]) > 1e-9 {
+					t.Errorf("%s = %v, want %v", metric, pair[0], pair[1])
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Set{Name: "faq", Queries: []Query{{Query: "refund", Relevant: []string{"doc-1"}}}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	for name, set := range map[string]Set{
+		"missing name":      {Queries: valid.Queries},
+		"no queries":        {Name: "faq"},
+		"empty query":       {Name: "faq", Queries: []Query{{Relevant: []string{"doc-1"}}}},
+		"no relevant IDs":   {Name: "faq", Queries: []Query{{Query: "refund"}}},
+		"negative k":        {Name: "faq", K: -1, Queries: valid.Queries},
+		"negative schedule": {Name: "faq", ScheduleHours: -1, Queries: valid.Queries},
+		"name with a slash": {Name: "faq/v2", Queries: valid.Queries},
+	} {
+		if err := set.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAppendRun_KeepsLatestRunsPerSet(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var runs []*Run
+	runs = AppendRun(runs, &Run{ID: "other", Set: "other", StartTime: start})
+	for i := 0; i < MaxRuns+5; i++ {
+		runs = AppendRun(runs, &Run{ID: "faq", Set: "faq", StartTime: start.Add(time.Duration(i) * time.Hour)})
+	}
+
+	faq := RunsOf(runs, "faq")
+	if len(faq) != MaxRuns {
+		t.Fatalf("kept %d runs, want %d", len(faq), MaxRuns)
+	}
+	if want := start.Add(time.Duration(MaxRuns+4) * time.Hour); !faq[0].StartTime.Equal(want) {
+		t.Errorf("newest run started at %v, want %v", faq[0].StartTime, want)
+	}
+	if len(RunsOf(runs, "other")) != 1 {
+		t.Error("runs of another set were dropped")
+	}
+}
+
+func TestDue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduled := Set{Name: "faq", ScheduleHours: 6}
+
+	if !scheduled.Due(nil, now) {
+		t.Error("a scheduled set that never ran should be due")
+	}
+	if scheduled.Due(&Run{StartTime: now.Add(-5 * time.Hour)}, now) {
+		t.Error("set due before its interval elapsed")
+	}
+	if !scheduled.Due(&Run{StartTime: now.Add(-6 * time.Hour)}, now) {
+		t.Error("set not due once its interval elapsed")
+	}
+	manual := Set{Name: "faq"}
+	if manual.Due(nil, now) {
+		t.Error("an unscheduled set should never be due")
+	}
+}
@@ -1,29 +1,247 @@
 package storage
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
 	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/tiered"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
 )
 
 // NewStorageFromEnv returns a Storage implementation based on STORAGE_TYPE env var
 func NewStorageFromEnv() (Storage, error) {
+	return NewStorageFromEnvWithConfig(nil)
+}
+
+// NewStorageFromEnvWithConfig is NewStorageFromEnv with the vector config of the active embedder
+// STORAGE_METRIC overrides the metric used for new local collections
+func NewStorageFromEnvWithConfig(vectorConfig *local.VectorConfig) (Storage, error) {
 	_ = godotenv.Load() // load .env if present
-	typeStr := os.Getenv("STORAGE_TYPE")
-	if typeStr == "local" {
-		basePath := os.Getenv("LOCAL_STORAGE_PATH")
-		if basePath == "" {
-			basePath = "./data/storage" // default path
+
+	tombstoneOpts, err := tombstoneOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	memoryOpts, err := memoryLimitsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	store, err := newStorage(vectorConfig, memoryOpts)
+	if err != nil {
+		return nil, err
+	}
+	if cl, ok := store.(ChangeLister); ok {
+		if err := cl.SetTombstoneOptions(tombstoneOpts); err != nil {
+			return nil, err
+		}
+	}
+	if memoryOpts != (memlimit.Options{}) {
+		mm, ok := store.(MemoryManager)
+		if !ok {
+			return nil, fmt.Errorf("MAX_VECTORS, MAX_MEMORY_BYTES and MEMORY_POLICY only apply to memory and tiered storage")
+		}
+		if err := mm.SetMemoryLimits(memoryOpts); err != nil {
+			return nil, fmt.Errorf("invalid memory limits: %w", err)
+		}
+	}
+	return store, nil
+}
+
+// memoryLimitsFromEnv reads the caps of memory storage from MAX_VECTORS and
+// MAX_MEMORY_BYTES, and what happens past them from MEMORY_POLICY
+func memoryLimitsFromEnv() (memlimit.Options, error) {
+	var opts memlimit.Options
+	if value := os.Getenv("MAX_VECTORS"); value != "" {
+		maxVectors, err := strconv.Atoi(value)
+		if err != nil || maxVectors < 0 {
+			return opts, fmt.Errorf("invalid MAX_VECTORS %q: must be a non-negative integer", value)
+		}
+		opts.MaxVectors = maxVectors
+	}
+	if value := os.Getenv("MAX_MEMORY_BYTES"); value != "" {
+		maxBytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || maxBytes < 0 {
+			return opts, fmt.Errorf("invalid MAX_MEMORY_BYTES %q: must be a non-negative integer", value)
 		}
-		collection := os.Getenv("STORAGE_COLLECTION")
-		if collection == "" {
-			collection = "default" // default collection name
+		opts.MaxBytes = maxBytes
+	}
+	opts.Policy = memlimit.Policy(os.Getenv("MEMORY_POLICY"))
+	if err := opts.Validate(); err != nil {
+		return opts, fmt.Errorf("invalid MEMORY_POLICY: %w", err)
+	}
+	return opts, nil
+}
+
+// tombstoneOptionsFromEnv reads how long deleted vector IDs are kept for delta listings
+// TOMBSTONE_RETENTION is a duration such as "72h", TOMBSTONE_MAX_ENTRIES caps the log
+func tombstoneOptionsFromEnv() (tombstone.Options, error) {
+	var opts tombstone.Options
+	if value := os.Getenv("TOMBSTONE_RETENTION"); value != "" {
+		retention, err := time.ParseDuration(value)
+		if err != nil || retention <= 0 {
+			return opts, fmt.Errorf("invalid TOMBSTONE_RETENTION %q: must be a positive duration", value)
 		}
+		opts.Retention = retention
+	}
+	if value := os.Getenv("TOMBSTONE_MAX_ENTRIES"); value != "" {
+		maxEntries, err := strconv.Atoi(value)
+		if err != nil || maxEntries <= 0 {
+			return opts, fmt.Errorf("invalid TOMBSTONE_MAX_ENTRIES %q: must be a positive integer", value)
+		}
+		opts.MaxEntries = maxEntries
+	}
+	return opts, nil
+}
 
-		return local.NewVectorStorageAdapter(basePath, collection)
+// newStorage creates the backend of STORAGE_TYPE, the memory limits sizing the
+// preload of tiered storage
+func newStorage(vectorConfig *local.VectorConfig, memoryOpts memlimit.Options) (Storage, error) {
+	switch os.Getenv("STORAGE_TYPE") {
+	case "local":
+		store, err := localFromEnv(vectorConfig)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "tiered":
+		cold, err := localFromEnv(vectorConfig)
+		if err != nil {
+			return nil, err
+		}
+		opts, err := tieredOptionsFromEnv()
+		if err != nil {
+			cold.Close()
+			return nil, err
+		}
+		opts.Memory = memoryOpts
+		store, err := tiered.New(cold, opts)
+		if err != nil {
+			cold.Close()
+			return nil, err
+		}
+		return store, nil
 	}
 	// default to memory
 	return memory.NewStorage(), nil
 }
+
+// localFromEnv opens the local storage configured by the LOCAL_STORAGE_* variables
+func localFromEnv(vectorConfig *local.VectorConfig) (*local.VectorStorageAdapter, error) {
+	basePath := os.Getenv("LOCAL_STORAGE_PATH")
+	if basePath == "" {
+		basePath = "./data/storage" // default path
+	}
+	collection := os.Getenv("STORAGE_COLLECTION")
+	if collection == "" {
+		collection = "default" // default collection name
+	}
+
+	if metric := os.Getenv("STORAGE_METRIC"); metric != "" {
+		config := local.VectorConfig{}
+		if vectorConfig != nil {
+			config = *vectorConfig
+		}
+		config.Metric = metric
+		vectorConfig = &config
+	}
+
+	options := local.Options{Compression: os.Getenv("LOCAL_STORAGE_COMPRESSION")}
+	if level := os.Getenv("LOCAL_STORAGE_COMPRESSION_LEVEL"); level != "" {
+		parsed, err := strconv.Atoi(level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOCAL_STORAGE_COMPRESSION_LEVEL %q: %w", level, err)
+		}
+		options.CompressionLevel = parsed
+	}
+	options.Durability = os.Getenv("LOCAL_STORAGE_DURABILITY")
+	if ops := os.Getenv("LOCAL_STORAGE_FLUSH_OPS"); ops != "" {
+		parsed, err := strconv.Atoi(ops)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOCAL_STORAGE_FLUSH_OPS %q: %w", ops, err)
+		}
+		options.FlushOps = parsed
+	}
+	if interval := os.Getenv("LOCAL_STORAGE_FLUSH_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOCAL_STORAGE_FLUSH_INTERVAL %q: %w", interval, err)
+		}
+		options.FlushInterval = parsed
+	}
+	cache, err := embeddingCacheFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	options.EmbeddingCache = cache
+
+	return local.NewVectorStorageAdapterWithOptions(basePath, collection, vectorConfig, options)
+}
+
+// tieredOptionsFromEnv reads how tiered storage loads and writes its tiers
+// TIERED_PRELOAD is the number of recently updated vectors loaded into memory on
+// startup, all of them when unset. TIERED_WRITE_BEHIND writes to local storage in
+// the background, with TIERED_QUEUE_SIZE writes waiting at most
+func tieredOptionsFromEnv() (tiered.Options, error) {
+	opts := tiered.Options{Preload: tiered.PreloadAll}
+	if value := os.Getenv("TIERED_PRELOAD"); value != "" {
+		preload, err := strconv.Atoi(value)
+		if err != nil || preload < 0 {
+			return opts, fmt.Errorf("invalid TIERED_PRELOAD %q: must be a non-negative integer", value)
+		}
+		opts.Preload = preload
+	}
+	if value := os.Getenv("TIERED_WRITE_BEHIND"); value != "" {
+		writeBehind, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid TIERED_WRITE_BEHIND %q: must be a boolean", value)
+		}
+		opts.WriteBehind = writeBehind
+	}
+	if value := os.Getenv("TIERED_QUEUE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return opts, fmt.Errorf("invalid TIERED_QUEUE_SIZE %q: must be a positive integer", value)
+		}
+		opts.QueueSize = size
+	}
+	return opts, nil
+}
+
+// embeddingCacheFromEnv reads the bounds of the embedding cache of local storage
+// LOCAL_STORAGE_EMBEDDING_CACHE_ENTRIES caps the embeddings kept, 0 disabling the cache,
+// LOCAL_STORAGE_EMBEDDING_CACHE_BYTES their estimated size and LOCAL_STORAGE_EMBEDDING_CACHE_TTL
+// how long they are kept after being loaded
+func embeddingCacheFromEnv() (local.EmbeddingCacheOptions, error) {
+	var opts local.EmbeddingCacheOptions
+	if value := os.Getenv("LOCAL_STORAGE_EMBEDDING_CACHE_ENTRIES"); value != "" {
+		entries, err := strconv.Atoi(value)
+		if err != nil || entries < 0 {
+			return opts, fmt.Errorf("invalid LOCAL_STORAGE_EMBEDDING_CACHE_ENTRIES %q: must be a non-negative integer", value)
+		}
+		opts.MaxEntries = entries
+		if entries == 0 {
+			opts.MaxEntries = -1
+		}
+	}
+	if value := os.Getenv("LOCAL_STORAGE_EMBEDDING_CACHE_BYTES"); value != "" {
+		maxBytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || maxBytes <= 0 {
+			return opts, fmt.Errorf("invalid LOCAL_STORAGE_EMBEDDING_CACHE_BYTES %q: must be a positive integer", value)
+		}
+		opts.MaxBytes = maxBytes
+	}
+	if value := os.Getenv("LOCAL_STORAGE_EMBEDDING_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return opts, fmt.Errorf("invalid LOCAL_STORAGE_EMBEDDING_CACHE_TTL %q: must be a positive duration", value)
+		}
+		opts.TTL = ttl
+	}
+	return opts, nil
+}
@@ -1,28 +1,78 @@
 package local
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/jobs"
 	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/alias"
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
 	"github.com/tahcohcat/same-same/internal/storage/search"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+	"github.com/tahcohcat/same-same/internal/storage/watch"
 )
 
 // VectorStorageAdapter adapts LocalStorage to work with existing Vector storage interface
 type VectorStorageAdapter struct {
 	localStorage *LocalStorage
 	collection   string
+	cache        *embeddingCache // Embeddings loaded from their files, nil when disabled
+
+	mu        sync.Mutex // serializes dimension checks on Store
+	conflicts []string   // differences between the requested and stored vector config
 }
 
 // NewVectorStorageAdapter creates an adapter for vector storage
+// The collection dimension is taken from the first stored vector and cosine similarity is used
 func NewVectorStorageAdapter(basePath, collectionName string) (*VectorStorageAdapter, error) {
-	localStorage, err := NewLocalStorage(basePath)
+	return NewVectorStorageAdapterWithConfig(basePath, collectionName, nil)
+}
+
+// NewVectorStorageAdapterWithConfig creates an adapter for vector storage with an explicit vector config
+// A new collection is created with config. An existing collection keeps its stored config,
+// and any conflict with config is logged and reported in GetStats
+// A zero Dimension is set from the first stored vector, an empty Metric defaults to cosine
+func NewVectorStorageAdapterWithConfig(basePath, collectionName string, config *VectorConfig) (*VectorStorageAdapter, error) {
+	return NewVectorStorageAdapterWithOptions(basePath, collectionName, config, Options{})
+}
+
+// NewVectorStorageAdapterWithOptions is NewVectorStorageAdapterWithConfig with storage options such as compression
+func NewVectorStorageAdapterWithOptions(basePath, collectionName string, config *VectorConfig, options Options) (*VectorStorageAdapter, error) {
+	requested := VectorConfig{Metric: search.MetricCosine}
+	if config != nil {
+		requested = *config
+		if requested.Metric == "" {
+			requested.Metric = search.MetricCosine
+		}
+	}
+	if err := search.ValidateMetric(requested.Metric); err != nil {
+		return nil, err
+	}
+
+	localStorage, err := NewLocalStorageWithOptions(basePath, options)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create default vector collection if it doesn't exist
-	if _, err := localStorage.GetCollection(collectionName); err != nil {
+	vsa := &VectorStorageAdapter{
+		localStorage: localStorage,
+		collection:   collectionName,
+		cache:        newEmbeddingCache(options.EmbeddingCache),
+	}
+
+	collection, err := localStorage.GetCollection(collectionName)
+	if err != nil {
+		// Create default vector collection if it doesn't exist
 		schema := &CollectionSchema{
 			Fields: map[string]FieldDefinition{
 				"type":          {Type: "string", Indexed: true},
@@ -30,39 +80,457 @@ func NewVectorStorageAdapter(basePath, collectionName string) (*VectorStorageAda
 				"text":          {Type: "string", Indexed: false},
 				"embedder.name": {Type: "string", Indexed: true},
 			},
-			VectorConfig: &VectorConfig{
-				Dimension:    768, // Default dimension
-				EmbedderType: "local",
-				Metric:       "cosine",
-			},
+			VectorConfig: &requested,
 		}
 
 		if _, err := localStorage.CreateCollection(collectionName, "Vector embeddings collection", schema); err != nil {
 			return nil, err
 		}
+
+		return vsa, nil
 	}
 
-	return &VectorStorageAdapter{
-		localStorage: localStorage,
-		collection:   collectionName,
-	}, nil
+	if err := vsa.reconcileConfig(collection, requested, config != nil); err != nil {
+		return nil, err
+	}
+
+	return vsa, nil
+}
+
+// reconcileConfig checks the stored vector config of an existing collection against the requested one
+// Empty collections simply adopt the requested config; collections holding documents keep
+// their stored config, corrected to the dimension of the stored vectors
+func (vsa *VectorStorageAdapter) reconcileConfig(collection *Collection, requested VectorConfig, explicit bool) error {
+	stored := VectorConfig{}
+	if collection.Schema != nil && collection.Schema.VectorConfig != nil {
+		stored = *collection.Schema.VectorConfig
+	}
+
+	if len(collection.Documents) == 0 {
+		if explicit || stored.Metric == "" {
+			return vsa.localStorage.UpdateVectorConfig(vsa.collection, &requested)
+		}
+		// Dimension will be taken from the first stored vector
+		stored.Dimension = 0
+		return vsa.localStorage.UpdateVectorConfig(vsa.collection, &stored)
+	}
+
+	changed := false
+	if stored.Metric == "" {
+		stored.Metric = search.MetricCosine
+		changed = true
+	}
+
+	// Older collections recorded a fixed dimension regardless of the embedder
+	if dimension := storedDimension(collection); dimension > 0 && dimension != stored.Dimension {
+		logrus.WithFields(logrus.Fields{
+			"collection": vsa.collection,
+			"recorded":   stored.Dimension,
+			"stored":     dimension,
+		}).Warn("collection config dimension does not match stored vectors, using the stored vector dimension")
+		stored.Dimension = dimension
+		changed = true
+	}
+
+	if changed {
+		if err := vsa.localStorage.UpdateVectorConfig(vsa.collection, &stored); err != nil {
+			return err
+		}
+	}
+
+	if !explicit {
+		return nil
+	}
+
+	if requested.Dimension > 0 && stored.Dimension > 0 && requested.Dimension != stored.Dimension {
+		vsa.conflicts = append(vsa.conflicts, fmt.Sprintf("dimension: collection has %d, embedder produces %d", stored.Dimension, requested.Dimension))
+	}
+	if requested.Metric != stored.Metric {
+		vsa.conflicts = append(vsa.conflicts, fmt.Sprintf("metric: collection uses %s, %s was requested", stored.Metric, requested.Metric))
+	}
+	if !sameEmbedder(stored.EmbedderType, requested.EmbedderType) {
+		vsa.conflicts = append(vsa.conflicts, fmt.Sprintf("embedder: collection was built with %s, active embedder is %s", stored.EmbedderType, requested.EmbedderType))
+	}
+
+	for _, conflict := range vsa.conflicts {
+		logrus.WithField("collection", vsa.collection).Warnf("COLLECTION CONFIG CONFLICT: %s", conflict)
+	}
+
+	return nil
+}
+
+// storedDimension returns the dimension of the vectors stored in collection, or 0 if unknown
+func storedDimension(collection *Collection) int {
+	for _, doc := range collection.Documents {
+		if doc.Embedding != nil && doc.Embedding.Dimension > 0 {
+			return doc.Embedding.Dimension
+		}
+	}
+	return 0
+}
+
+// sameEmbedder reports whether two embedder types are compatible
+// Unknown types match anything, and a family such as "local" matches its members like "local.tfidf"
+func sameEmbedder(a, b string) bool {
+	if a == "" || b == "" || a == b {
+		return true
+	}
+	return strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// Path returns the base directory of the storage
+func (vsa *VectorStorageAdapter) Path() string {
+	return vsa.localStorage.basePath
+}
+
+// Collection returns the name of the collection vectors are stored in
+func (vsa *VectorStorageAdapter) Collection() string {
+	return vsa.collection
+}
+
+// VectorConfig returns the vector configuration of the collection
+func (vsa *VectorStorageAdapter) VectorConfig() VectorConfig {
+	config := VectorConfig{Metric: search.MetricCosine}
+
+	vsa.localStorage.readCollection(vsa.collection, func(collection *Collection) {
+		if collection.Schema != nil && collection.Schema.VectorConfig != nil {
+			config = *collection.Schema.VectorConfig
+		}
+	})
+	if config.Metric == "" {
+		config.Metric = search.MetricCosine
+	}
+	return config
+}
+
+// ConfigConflicts returns the differences found between the requested and stored vector config
+func (vsa *VectorStorageAdapter) ConfigConflicts() []string {
+	return vsa.conflicts
+}
+
+// checkDimension enforces the collection dimension, setting it from the first vector if unset
+func (vsa *VectorStorageAdapter) checkDimension(dimension int) error {
+	if dimension == 0 {
+		return nil
+	}
+
+	vsa.mu.Lock()
+	defer vsa.mu.Unlock()
+
+	config := vsa.VectorConfig()
+	if config.Dimension == dimension {
+		return nil
+	}
+
+	if config.Dimension == 0 || vsa.Count() == 0 {
+		config.Dimension = dimension
+		return vsa.localStorage.UpdateVectorConfig(vsa.collection, &config)
+	}
+
+	return storeerr.DimensionMismatchf("dimension mismatch: collection %s expects %d dimensions, got %d", vsa.collection, config.Dimension, dimension)
+}
+
+// GetStats returns storage statistics including the collection vector config
+func (vsa *VectorStorageAdapter) GetStats() map[string]interface{} {
+	stats := vsa.localStorage.GetStats()
+	stats["collection"] = vsa.collection
+	stats["vector_config"] = vsa.VectorConfig()
+	if len(vsa.conflicts) > 0 {
+		stats["config_conflicts"] = vsa.conflicts
+	}
+	stats["quotas"] = quota.Report(vsa.Quotas(), vsa.QuotaUsage())
+	stats["embedding_cache"] = vsa.cache.stats()
+	return stats
+}
+
+// EmbeddingCacheStats reports the hits, misses and size of the embedding cache
+func (vsa *VectorStorageAdapter) EmbeddingCacheStats() EmbeddingCacheStats {
+	return vsa.cache.stats()
+}
+
+// Quotas returns the namespace limits of the adapter collection
+func (vsa *VectorStorageAdapter) Quotas() quota.Limits {
+	limits, _ := vsa.localStorage.Quotas(vsa.collection)
+	return limits
+}
+
+// SetQuota sets and persists the limit of a namespace, a zero limit removes it
+func (vsa *VectorStorageAdapter) SetQuota(namespace string, limit quota.Limit) error {
+	return vsa.localStorage.SetQuota(vsa.collection, namespace, limit)
+}
+
+// QuotaUsage returns the vectors and embedding bytes stored per namespace
+func (vsa *VectorStorageAdapter) QuotaUsage() map[string]quota.Usage {
+	usage, _ := vsa.localStorage.QuotaUsage(vsa.collection)
+	return usage
+}
+
+// RecordRun appends an ingest run to the collection history
+func (vsa *VectorStorageAdapter) RecordRun(run *models.IngestRun) error {
+	return vsa.localStorage.RecordRun(vsa.collection, run)
+}
+
+// ListRuns returns the ingest runs of the collection matching filter, newest first
+func (vsa *VectorStorageAdapter) ListRuns(filter models.IngestRunFilter) ([]*models.IngestRun, error) {
+	return vsa.localStorage.ListRuns(vsa.collection, filter)
+}
+
+// UniqueKeys returns the unique metadata fields of the adapter collection
+func (vsa *VectorStorageAdapter) UniqueKeys() []string {
+	fields, _ := vsa.localStorage.UniqueKeys(vsa.collection)
+	return fields
+}
+
+// SetUniqueKeys declares and persists the unique metadata fields of the adapter collection
+func (vsa *VectorStorageAdapter) SetUniqueKeys(fields []string) error {
+	return vsa.localStorage.SetUniqueKeys(vsa.collection, fields)
+}
+
+// GetByKey returns the vector of namespace holding value for a unique field
+func (vsa *VectorStorageAdapter) GetByKey(namespace, field, value string) (*models.Vector, error) {
+	doc, err := vsa.localStorage.GetDocumentByKey(vsa.collection, namespace, field, value)
+	if err != nil {
+		return nil, err
+	}
+	return documentToVector(doc), nil
+}
+
+// Profiles returns the ranking profiles of the adapter collection
+func (vsa *VectorStorageAdapter) Profiles() profile.Profiles {
+	profiles, _ := vsa.localStorage.Profiles(vsa.collection)
+	return profiles
+}
+
+// SetProfile creates or replaces a ranking profile of the adapter collection and persists it
+func (vsa *VectorStorageAdapter) SetProfile(p profile.Profile) error {
+	return vsa.localStorage.SetProfile(vsa.collection, p)
+}
+
+// DeleteProfile removes a ranking profile of the adapter collection
+func (vsa *VectorStorageAdapter) DeleteProfile(name string) error {
+	return vsa.localStorage.DeleteProfile(vsa.collection, name)
+}
+
+// Aliases returns the namespace aliases of the adapter collection
+func (vsa *VectorStorageAdapter) Aliases() alias.Aliases {
+	aliases, _ := vsa.localStorage.Aliases(vsa.collection)
+	return aliases
+}
+
+// ResolveAlias returns the namespace an alias of the adapter collection points at
+func (vsa *VectorStorageAdapter) ResolveAlias(name string) (string, bool) {
+	return vsa.localStorage.ResolveAlias(vsa.collection, name)
+}
+
+// SetAlias creates or retargets an alias of the adapter collection and persists it
+func (vsa *VectorStorageAdapter) SetAlias(a alias.Alias) error {
+	return vsa.localStorage.SetAlias(vsa.collection, a)
+}
+
+// DeleteAlias removes an alias of the adapter collection
+func (vsa *VectorStorageAdapter) DeleteAlias(name string) error {
+	return vsa.localStorage.DeleteAlias(vsa.collection, name)
+}
+
+// Quarantine keeps records of the adapter collection that could not be stored
+func (vsa *VectorStorageAdapter) Quarantine(entries ...quarantine.Entry) error {
+	return vsa.localStorage.Quarantine(vsa.collection, entries...)
+}
+
+// Quarantined returns the quarantined records of the adapter collection
+func (vsa *VectorStorageAdapter) Quarantined() []quarantine.Entry {
+	entries, _ := vsa.localStorage.Quarantined(vsa.collection)
+	return entries
+}
+
+// RemoveQuarantined drops quarantined records of the adapter collection
+func (vsa *VectorStorageAdapter) RemoveQuarantined(ids ...string) error {
+	return vsa.localStorage.RemoveQuarantined(vsa.collection, ids...)
+}
+
+// QuarantineStats returns the size and limit of the quarantine of the adapter collection
+func (vsa *VectorStorageAdapter) QuarantineStats() quarantine.Stats {
+	stats, _ := vsa.localStorage.QuarantineStats(vsa.collection)
+	return stats
+}
+
+// SetQuarantineLimit caps the quarantined records kept for the adapter collection
+func (vsa *VectorStorageAdapter) SetQuarantineLimit(max int) error {
+	return vsa.localStorage.SetQuarantineLimit(vsa.collection, max)
+}
+
+// EvalSets returns the evaluation sets of the adapter collection
+func (vsa *VectorStorageAdapter) EvalSets() eval.Sets {
+	sets, _ := vsa.localStorage.EvalSets(vsa.collection)
+	return sets
+}
+
+// CreateEvalSet adds an evaluation set to the adapter collection and persists it
+func (vsa *VectorStorageAdapter) CreateEvalSet(set eval.Set) error {
+	return vsa.localStorage.CreateEvalSet(vsa.collection, set)
+}
+
+// DeleteEvalSet removes an evaluation set and its runs from the adapter collection
+func (vsa *VectorStorageAdapter) DeleteEvalSet(name string) error {
+	return vsa.localStorage.DeleteEvalSet(vsa.collection, name)
+}
+
+// RecordEvalRun appends an evaluation run to the adapter collection history
+func (vsa *VectorStorageAdapter) RecordEvalRun(run *eval.Run) error {
+	return vsa.localStorage.RecordEvalRun(vsa.collection, run)
+}
+
+// ListEvalRuns returns the runs of an evaluation set of the adapter collection, newest first
+func (vsa *VectorStorageAdapter) ListEvalRuns(set string) ([]*eval.Run, error) {
+	return vsa.localStorage.ListEvalRuns(vsa.collection, set)
+}
+
+// Jobs returns the background jobs of the adapter collection
+func (vsa *VectorStorageAdapter) Jobs() ([]*jobs.Job, error) {
+	return vsa.localStorage.Jobs(vsa.collection)
+}
+
+// SaveJob creates or updates a background job of the adapter collection and persists it
+func (vsa *VectorStorageAdapter) SaveJob(job *jobs.Job) error {
+	return vsa.localStorage.SaveJob(vsa.collection, job)
+}
+
+// Watches returns the watches of the adapter collection
+func (vsa *VectorStorageAdapter) Watches() ([]*watch.Watch, error) {
+	return vsa.localStorage.Watches(vsa.collection)
+}
+
+// SaveWatch creates or updates a watch of the adapter collection and persists it
+func (vsa *VectorStorageAdapter) SaveWatch(w *watch.Watch) error {
+	return vsa.localStorage.SaveWatch(vsa.collection, w)
+}
+
+// DeleteWatch removes a watch from the adapter collection
+func (vsa *VectorStorageAdapter) DeleteWatch(id string) error {
+	return vsa.localStorage.DeleteWatch(vsa.collection, id)
+}
+
+// GetEnrichment returns the enrichment documents of keys in the adapter collection
+func (vsa *VectorStorageAdapter) GetEnrichment(keys []string) (map[string]json.RawMessage, error) {
+	return vsa.localStorage.GetEnrichment(vsa.collection, keys)
+}
+
+// SetEnrichment creates or replaces enrichment documents of the adapter collection
+func (vsa *VectorStorageAdapter) SetEnrichment(entries map[string]json.RawMessage) error {
+	return vsa.localStorage.SetEnrichment(vsa.collection, entries)
+}
+
+// DeleteEnrichment removes an enrichment document of the adapter collection
+func (vsa *VectorStorageAdapter) DeleteEnrichment(key string) error {
+	return vsa.localStorage.DeleteEnrichment(vsa.collection, key)
+}
+
+// Vocabulary returns the embedder vocabulary saved with the adapter collection, nil when there is none
+func (vsa *VectorStorageAdapter) Vocabulary() ([]byte, error) {
+	return vsa.localStorage.Vocabulary(vsa.collection)
+}
+
+// SaveVocabulary replaces the embedder vocabulary saved with the adapter collection
+func (vsa *VectorStorageAdapter) SaveVocabulary(data []byte) error {
+	return vsa.localStorage.SaveVocabulary(vsa.collection, data)
+}
+
+// Reconcile re-scans the adapter collection on disk and brings its schema in line
+func (vsa *VectorStorageAdapter) Reconcile(opts ReconcileOptions) (*ReconcileReport, error) {
+	opts.Collections = []string{vsa.collection}
+	return vsa.localStorage.Reconcile(opts)
+}
+
+// Verify checks the integrity of the files of the adapter collection
+func (vsa *VectorStorageAdapter) Verify(opts VerifyOptions) (*VerifyReport, error) {
+	opts.Collections = []string{vsa.collection}
+	return vsa.localStorage.Verify(opts)
+}
+
+// Flush writes the schema updates buffered by the batched durability mode to disk
+func (vsa *VectorStorageAdapter) Flush() error {
+	return vsa.localStorage.Flush()
+}
+
+// Generation returns the mutation generation of the adapter collection
+func (vsa *VectorStorageAdapter) Generation() uint64 {
+	generation, _ := vsa.localStorage.CollectionGeneration(vsa.collection)
+	return generation
 }
 
 // Store stores a vector using the local storage
 func (vsa *VectorStorageAdapter) Store(vector *models.Vector) error {
+	// Sparse vectors of unknown dimension are not held to the collection dimension
+	dimension := len(vector.Embedding)
+	if vector.Sparse != nil {
+		dimension = vector.Sparse.Dimension
+	}
+	if err := vsa.checkDimension(dimension); err != nil {
+		return err
+	}
+
+	defer vsa.cache.invalidate(vector.ID)
+	return vsa.localStorage.StoreDocument(vsa.collection, vectorDocument(vector, dimension))
+}
+
+// StoreAll stores related vectors all or nothing, see LocalStorage.StoreDocuments
+func (vsa *VectorStorageAdapter) StoreAll(vectors []*models.Vector) error {
+	if err := models.ValidateBatch(vectors, true); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	docs := make([]*Document, len(vectors))
+	first := 0
+	for i, vector := range vectors {
+		dimension := len(vector.Embedding)
+		if vector.Sparse != nil {
+			dimension = vector.Sparse.Dimension
+		}
+		// checkDimension adopts any dimension while the collection is empty, so
+		// the batch must agree with itself as well
+		if first == 0 {
+			first = dimension
+		} else if dimension != 0 && dimension != first {
+			return storeerr.DimensionMismatchf("vector %d: dimension mismatch: batch has %d dimensions, got %d", i, first, dimension)
+		}
+		if err := vsa.checkDimension(dimension); err != nil {
+			return fmt.Errorf("vector %d: %w", i, err)
+		}
+		docs[i] = vectorDocument(vector, dimension)
+	}
+
+	defer vsa.invalidate(vectors)
+	return vsa.localStorage.StoreDocuments(vsa.collection, docs)
+}
+
+// invalidate drops the cached embeddings of vectors being stored
+func (vsa *VectorStorageAdapter) invalidate(vectors []*models.Vector) {
+	for _, vector := range vectors {
+		vsa.cache.invalidate(vector.ID)
+	}
+}
+
+// vectorDocument converts a vector to the document it is stored as
+func vectorDocument(vector *models.Vector, dimension int) *Document {
 	doc := &Document{
 		ID:        vector.ID,
 		Type:      TypeText,
 		CreatedAt: vector.CreatedAt,
 		UpdatedAt: vector.UpdatedAt,
 		Metadata:  convertMetadataToInterface(vector.Metadata),
-		Embedding: &EmbeddingData{
+		Tags:      extractTags(vector.Metadata),
+	}
+
+	// Pending vectors are stored without embedding data
+	if vector.HasEmbedding() {
+		doc.Embedding = &EmbeddingData{
 			Vector:    vector.Embedding,
-			Dimension: len(vector.Embedding),
+			Sparse:    vector.Sparse,
+			Dimension: dimension,
+			DType:     string(vector.DType),
 			Model:     getEmbedderName(vector.Metadata),
 			CreatedAt: time.Now(),
-		},
-		Tags: extractTags(vector.Metadata),
+		}
 	}
 
 	// Extract text content if available
@@ -76,7 +544,7 @@ func (vsa *VectorStorageAdapter) Store(vector *models.Vector) error {
 		}
 	}
 
-	return vsa.localStorage.StoreDocument(vsa.collection, doc)
+	return doc
 }
 
 // Get retrieves a vector by ID
@@ -89,67 +557,152 @@ func (vsa *VectorStorageAdapter) Get(id string) (*models.Vector, error) {
 	return documentToVector(doc), nil
 }
 
+// GetMany retrieves the vectors of ids in order in one pass over the collection, nil for missing ones
+func (vsa *VectorStorageAdapter) GetMany(ids []string) ([]*models.Vector, error) {
+	docs, err := vsa.localStorage.GetDocuments(vsa.collection, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([]*models.Vector, len(docs))
+	for i, doc := range docs {
+		if doc != nil {
+			vectors[i] = documentToVector(doc)
+		}
+	}
+	return vectors, nil
+}
+
 // Delete deletes a vector by ID
 func (vsa *VectorStorageAdapter) Delete(id string) error {
+	defer vsa.cache.invalidate(id)
 	return vsa.localStorage.DeleteDocument(vsa.collection, id)
 }
 
+// DeleteBatch deletes vectors by ID, saving the collection once
+func (vsa *VectorStorageAdapter) DeleteBatch(ids []string) (int, error) {
+	defer vsa.cache.invalidate(ids...)
+	return vsa.localStorage.DeleteDocuments(vsa.collection, ids)
+}
+
 // List returns all vectors in the collection
 func (vsa *VectorStorageAdapter) List() ([]*models.Vector, error) {
-	collection, err := vsa.localStorage.GetCollection(vsa.collection)
+	documents, err := vsa.localStorage.documents(vsa.collection, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	vectors := make([]*models.Vector, 0, len(collection.Documents))
-	for _, doc := range collection.Documents {
-		vectors = append(vectors, documentToVector(doc))
+	vectors := make([]*models.Vector, 0, len(documents))
+	for _, doc := range documents {
+		vector, _ := vsa.documentVector(doc)
+		vectors = append(vectors, vector)
 	}
 
 	return vectors, nil
 }
 
+// ListByNamespace returns the vectors in namespace, or all vectors if namespace is empty
+func (vsa *VectorStorageAdapter) ListByNamespace(namespace string) ([]*models.Vector, error) {
+	vectors, err := vsa.List()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]*models.Vector, 0, len(vectors))
+	for _, vector := range vectors {
+		if search.MatchesNamespace(vector.Metadata, namespace) {
+			filtered = append(filtered, vector)
+		}
+	}
+
+	return filtered, nil
+}
+
+// IDs returns the sorted IDs of the vectors in namespace, or of all vectors if namespace
+// is empty. No embeddings are loaded, so callers can fetch the vectors in pages
+func (vsa *VectorStorageAdapter) IDs(namespace string) ([]string, error) {
+	var ids []string
+	err := vsa.localStorage.readCollection(vsa.collection, func(collection *Collection) {
+		ids = make([]string, 0, len(collection.Documents))
+		for id, doc := range collection.Documents {
+			if namespace == "" || fmt.Sprint(doc.Metadata[models.NamespaceKey]) == namespace {
+				ids = append(ids, id)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
 // Count returns the number of vectors
 func (vsa *VectorStorageAdapter) Count() int {
-	collection, err := vsa.localStorage.GetCollection(vsa.collection)
+	count := 0
+	vsa.localStorage.readCollection(vsa.collection, func(collection *Collection) {
+		count = collection.Stats.DocumentCount
+	})
+	return count
+}
+
+// CountByNamespace returns the number of vectors in namespace, or all vectors if namespace is empty
+func (vsa *VectorStorageAdapter) CountByNamespace(namespace string) int {
+	if namespace == "" {
+		return vsa.Count()
+	}
+
+	vectors, err := vsa.ListByNamespace(namespace)
 	if err != nil {
 		return 0
 	}
 
-	return collection.Stats.DocumentCount
+	return len(vectors)
+}
+
+// CountPending returns the number of vectors without embedding in namespace,
+// or all vectors if namespace is empty
+func (vsa *VectorStorageAdapter) CountPending(namespace string) int {
+	count := 0
+	vsa.localStorage.readCollection(vsa.collection, func(collection *Collection) {
+		for _, doc := range collection.Documents {
+			if doc.Embedding == nil && search.MatchesNamespace(convertInterfaceToStringMap(doc.Metadata), namespace) {
+				count++
+			}
+		}
+	})
+	return count
 }
 
 // Search performs vector similarity search
 func (vsa *VectorStorageAdapter) Search(req *models.SearchByEmbbedingRequest) ([]*models.SearchResult, error) {
-	collection, err := vsa.localStorage.GetCollection(vsa.collection)
+	documents, err := vsa.localStorage.documents(vsa.collection, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	queryVector := &models.Vector{Embedding: req.Embedding}
+	metric := vsa.VectorConfig().Metric
+	queryVector := models.NewQueryVector(req.Embedding, req.Sparse)
 	results := make([]*models.SearchResult, 0)
 
-	for _, doc := range collection.Documents {
+	for _, doc := range documents {
 		if doc.Embedding == nil {
 			continue
 		}
 
-		// Load embedding if stored separately
-		if len(doc.Embedding.Vector) == 0 && doc.Embedding.Path != "" {
-			embedding, err := vsa.localStorage.loadEmbedding(vsa.collection, doc.ID)
-			if err != nil {
-				continue
-			}
-			doc.Embedding = embedding
+		vector, ok := vsa.documentVector(doc)
+		if !ok {
+			continue
 		}
-
-		vector := documentToVector(doc)
-		if len(vector.Embedding) != len(req.Embedding) {
+		if !queryVector.Compatible(vector) {
+			continue
+		}
+		if !search.MatchesNamespace(vector.Metadata, req.Namespace) {
 			continue
 		}
 
-		// Calculate similarity score
-		vectorScore := queryVector.CosineSimilarity(vector)
+		// Calculate similarity score, or distance for euclidean collections
+		vectorScore := search.Score(metric, queryVector, vector)
 
 		// Apply hybrid weighting if specified
 		finalScore := vectorScore
@@ -165,14 +718,8 @@ func (vsa *VectorStorageAdapter) Search(req *models.SearchByEmbbedingRequest) ([
 		})
 	}
 
-	// Sort by score
-	for i := 0; i < len(results); i++ {
-		for j := i + 1; j < len(results); j++ {
-			if results[j].Score > results[i].Score {
-				results[i], results[j] = results[j], results[i]
-			}
-		}
-	}
+	// Sort by score, best first for the collection metric
+	search.SortResults(results, metric)
 
 	// Limit results
 	if req.TopK > 0 && len(results) > req.TopK {
@@ -183,66 +730,133 @@ func (vsa *VectorStorageAdapter) Search(req *models.SearchByEmbbedingRequest) ([
 }
 
 func (vsa *VectorStorageAdapter) AdvancedSearch(req *models.AdvancedSearchRequest, queryEmbedding []float64) ([]*models.SearchResult, error) {
+	evaluator := &models.FilterEvaluator{KeyFallback: req.UsesKeyFallback()}
+	filters, err := evaluator.CompileSearch(req.Filters)
+	if err != nil {
+		return nil, err
+	}
 
 	// Use shared search utility
 	vectors := []*models.Vector{}
-	collection, err := vsa.localStorage.GetCollection(vsa.collection)
+	documents, err := vsa.localStorage.documents(vsa.collection, req.Candidates)
 	if err != nil {
 		return nil, err
 	}
-
-	for _, doc := range collection.Documents {
+	for _, doc := range documents {
 		if doc.Embedding == nil {
 			continue
 		}
 
-		// Load embedding if stored separately
-		if len(doc.Embedding.Vector) == 0 && doc.Embedding.Path != "" {
-			embedding, err := vsa.localStorage.loadEmbedding(vsa.collection, doc.ID)
-			if err != nil {
-				continue
-			}
-			doc.Embedding = embedding
+		vector, ok := vsa.documentVector(doc)
+		if !ok {
+			continue
 		}
 
-		vector := documentToVector(doc)
-		vectors = append(vectors, vector)
-	}
-
-	// Convert req.Filters (map[string]models.FilterExpr) to []models.MetadataFilter
-	var metadataFilters []models.MetadataFilter
-	for key, expr := range req.Filters {
-
-		// Assuming expr is of type models.FilterExpr (likely a struct or map), extract the operator as string.
-		// If expr is a struct with a field "Operator", use expr.Operator.
-		// If expr is a map, extract the operator string value (e.g., expr["operator"].(string)).
-		// Here, let's assume expr is a struct with an Operator field.
-		var operator string
-		if op, ok := expr["operator"]; ok {
-			operator, _ = op.(string)
+		// Apply metadata filters
+		if !evaluator.Matches(vector.Metadata, filters) {
+			continue
 		}
-		metadataFilters = append(metadataFilters, models.MetadataFilter{
-			Field:    key,
-			Operator: operator,
-		})
+
+		vectors = append(vectors, vector)
 	}
 
+	// Filters are passed on for the soft filters, whose preferences are scored there
 	advancedReq := &models.SearchByEmbbedingRequest{
-		Embedding: queryEmbedding,
-		TopK:      req.TopK,
-		Filters:   metadataFilters,
-		Options:   req.Options,
+		Embedding:    queryEmbedding,
+		Sparse:       req.SparseQuery,
+		TopK:         req.TopK,
+		Namespace:    req.Namespace,
+		Options:      req.Options,
+		Filters:      req.Filters,
+		SearchParams: req.SearchParams,
+		Scoring:      req.Scoring,
 	}
 
-	searchResults := search.FilterAndScoreVectors(vectors, advancedReq)
+	metric := req.Metric
+	if metric == "" {
+		metric = vsa.VectorConfig().Metric
+	}
+	searchResults := search.FilterAndScoreVectorsByMetric(vectors, advancedReq, metric)
 	return searchResults, nil
 }
 
-// TemporalSearch implements the Storage interface.
-// TODO: Replace the implementation with actual logic as needed.
-func (v *VectorStorageAdapter) TemporalSearch(*models.TemporalSearchRequest, []float64) ([]*models.TemporalSearchResult, error) {
-	// Implement the required logic or return nil/error as a stub
-	return nil, nil
+// TemporalSearch performs vector search with temporal decay, loading the embeddings
+// of the collection documents from their files where needed
+func (vsa *VectorStorageAdapter) TemporalSearch(req *models.TemporalSearchRequest, queryEmbedding []float64) ([]*models.TemporalSearchResult, error) {
+	config := req.GetTemporalConfig()
+	scorer := models.NewTemporalScorer(config)
+	queryVector := models.NewQueryVector(queryEmbedding, req.SparseQuery)
+
+	evaluator := &models.FilterEvaluator{KeyFallback: req.UsesKeyFallback()}
+	filters, err := evaluator.CompileSearch(req.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := vsa.localStorage.documents(vsa.collection, req.Candidates)
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([]*models.Vector, 0, len(documents))
+	for _, doc := range documents {
+		if doc.Embedding == nil {
+			continue
+		}
+
+		vector, ok := vsa.documentVector(doc)
+		if !ok {
+			continue
+		}
+
+		if !search.MatchesNamespace(vector.Metadata, req.Namespace) {
+			continue
+		}
+		if !evaluator.Matches(vector.Metadata, filters) {
+			continue
+		}
+
+		vectors = append(vectors, vector)
+	}
+
+	results := search.TopK(vectors, req.TopK, func(vector *models.Vector) (*models.TemporalSearchResult, bool) {
+		if !vector.HasEmbedding() || !queryVector.Compatible(vector) {
+			return nil, false
+		}
+
+		// The time field of the metadata, falling back to the creation time
+		baseScore := queryVector.CosineSimilarity(vector)
+		documentTime, source := models.DocumentTime(vector, config.TimeField, config.DefaultTime)
+
+		// Apply temporal decay, then soft filter preferences
+		finalScore := scorer.ApplyDecay(baseScore, documentTime)
+		decayFactor := scorer.GetDecayFactor(documentTime)
+		match := evaluator.MatchSoft(vector.Metadata, filters)
+		finalScore = search.WeightedScore(finalScore, nil, match, req.SoftFilterPenalty(), search.MetricCosine)
+		finalScore, explanation := req.Scoring.Adjust(finalScore, vector)
+		if explanation != nil {
+			explanation.SoftFilters = match
+		}
+
+		return &models.TemporalSearchResult{
+			Vector:       vector,
+			Score:        finalScore,
+			BaseScore:    baseScore,
+			DecayFactor:  decayFactor,
+			DocumentTime: documentTime,
+			TimeSource:   source,
+			TimeFallback: source != models.TimeSourceField,
+			Age:          models.CalculateAge(documentTime, config.ReferenceTime, models.DefaultAgeLocale),
+			Explanation:  explanation,
+		}, true
+	}, func(a, b *models.TemporalSearchResult) bool {
+		// By final score, then by ID so ties do not depend on map order
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Vector.ID < b.Vector.ID
+	})
+
+	return results, nil
 }
 
 // Close closes the storage
@@ -250,6 +864,29 @@ func (vsa *VectorStorageAdapter) Close() error {
 	return vsa.localStorage.Close()
 }
 
+// documentVector converts doc to a vector, loading its embedding if it is stored separately
+// Loaded embeddings are kept in the embedding cache, doc itself is left referencing its file
+// ok is false when the embedding file cannot be read
+func (vsa *VectorStorageAdapter) documentVector(doc *Document) (vector *models.Vector, ok bool) {
+	if doc.Embedding == nil || doc.Embedding.hasValues() || doc.Embedding.Path == "" {
+		return documentToVector(doc), true
+	}
+
+	embedding, ok := vsa.cache.get(doc)
+	if !ok {
+		loaded, err := vsa.localStorage.loadEmbedding(vsa.collection, doc.ID)
+		if err != nil {
+			return documentToVector(doc), false
+		}
+		embedding = loaded
+		vsa.cache.put(doc, embedding)
+	}
+
+	loaded := *doc
+	loaded.Embedding = embedding
+	return documentToVector(&loaded), true
+}
+
 // Helper functions
 
 func documentToVector(doc *Document) *models.Vector {
@@ -267,6 +904,8 @@ func documentToVector(doc *Document) *models.Vector {
 
 	if doc.Embedding != nil {
 		vector.Embedding = doc.Embedding.Vector
+		vector.Sparse = doc.Embedding.Sparse
+		vector.DType = models.DType(doc.Embedding.DType)
 	}
 
 	return vector
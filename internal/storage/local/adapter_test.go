@@ -0,0 +1,579 @@
+package local
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/jobs"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/alias"
+	"github.com/tahcohcat/same-same/internal/storage/bulk"
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+func storeVectors(t *testing.T, adapter *VectorStorageAdapter, vectors map[string][]float64) {
+	t.Helper()
+	for id, embedding := range vectors {
+		if err := adapter.Store(&models.Vector{ID: id, Embedding: embedding}); err != nil {
+			t.Fatalf("store %s: %v", id, err)
+		}
+	}
+}
+
+func TestAdapter_EuclideanMetricOrdering(t *testing.T) {
+	adapter, err := NewVectorStorageAdapterWithConfig(t.TempDir(), "vectors", &VectorConfig{Metric: search.MetricEuclidean})
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	// "far" points the same way as the query, so cosine would rank it first
+	storeVectors(t, adapter, map[string][]float64{
+		"near":   {1, 1.5},
+		"middle": {3, 0},
+		"far":    {10, 10},
+	})
+
+	query := []float64{1, 1}
+	want := []string{"near", "middle", "far"}
+
+	results, err := adapter.Search(&models.SearchByEmbbedingRequest{Embedding: query, TopK: 3})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	advanced, err := adapter.AdvancedSearch(&models.AdvancedSearchRequest{TopK: 3}, query)
+	if err != nil {
+		t.Fatalf("advanced search failed: %v", err)
+	}
+
+	for name, got := range map[string][]*models.SearchResult{"search": results, "advanced": advanced} {
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d results, got %d", name, len(want), len(got))
+		}
+		for i, id := range want {
+			if got[i].Vector.ID != id {
+				t.Errorf("%s: result %d is %s, want %s", name, i, got[i].Vector.ID, id)
+			}
+			if i > 0 && got[i].Score < got[i-1].Score {
+				t.Errorf("%s: distances not ascending: %v before %v", name, got[i-1].Score, got[i].Score)
+			}
+		}
+	}
+}
+
+func TestAdapter_DimensionEnforced(t *testing.T) {
+	basePath := t.TempDir()
+
+	adapter, err := NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	storeVectors(t, adapter, map[string][]float64{"a": {1, 0, 0}})
+	if got := adapter.VectorConfig().Dimension; got != 3 {
+		t.Fatalf("expected dimension 3 from the first vector, got %d", got)
+	}
+
+	if err := adapter.Store(&models.Vector{ID: "b", Embedding: []float64{1, 0}}); err == nil {
+		t.Error("expected a vector with the wrong dimension to be rejected")
+	}
+
+	// The dimension is persisted with the collection
+	reopened, err := NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if got := reopened.VectorConfig().Dimension; got != 3 {
+		t.Errorf("expected persisted dimension 3, got %d", got)
+	}
+}
+
+func TestAdapter_ConfigConflictReported(t *testing.T) {
+	basePath := t.TempDir()
+
+	adapter, err := NewVectorStorageAdapterWithConfig(basePath, "vectors", &VectorConfig{Dimension: 2, EmbedderType: "local.hash"})
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	storeVectors(t, adapter, map[string][]float64{"a": {1, 0}})
+
+	reopened, err := NewVectorStorageAdapterWithConfig(basePath, "vectors", &VectorConfig{Dimension: 768, EmbedderType: "gemini", Metric: search.MetricDot})
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+
+	if conflicts := reopened.ConfigConflicts(); len(conflicts) != 3 {
+		t.Errorf("expected dimension, metric and embedder conflicts, got %v", conflicts)
+	}
+	if _, ok := reopened.GetStats()["config_conflicts"]; !ok {
+		t.Error("expected config conflicts in stats")
+	}
+
+	// The stored config wins over the requested one
+	if config := reopened.VectorConfig(); config.Dimension != 2 || config.Metric != search.MetricCosine {
+		t.Errorf("expected stored config to be kept, got %+v", config)
+	}
+
+	// A compatible embedder of the same family raises no conflict
+	compatible, err := NewVectorStorageAdapterWithConfig(basePath, "vectors", &VectorConfig{Dimension: 2, EmbedderType: "local.hash"})
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if conflicts := compatible.ConfigConflicts(); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts, got %v", conflicts)
+	}
+}
+
+func TestNewVectorStorageAdapterWithConfig_InvalidMetric(t *testing.T) {
+	if _, err := NewVectorStorageAdapterWithConfig(filepath.Join(t.TempDir(), "store"), "vectors", &VectorConfig{Metric: "manhattan"}); err == nil {
+		t.Error("expected unsupported metric to be rejected")
+	}
+}
+
+func TestAdapter_GenerationPersisted(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	storeVectors(t, adapter, map[string][]float64{"a": {1, 0}, "b": {0, 1}})
+	if err := adapter.Delete("a"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got := adapter.Generation(); got != 3 {
+		t.Fatalf("generation = %d, want 3", got)
+	}
+
+	reopened, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if got := reopened.Generation(); got != 3 {
+		t.Errorf("generation after restart = %d, want 3", got)
+	}
+
+	storeVectors(t, reopened, map[string][]float64{"c": {1, 1}})
+	if got := reopened.Generation(); got != 4 {
+		t.Errorf("generation after store = %d, want 4", got)
+	}
+}
+
+func TestAdapter_QuotaPersistedAndEnforced(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	// Two 2-dimensional vectors fit in 32 bytes
+	if err := adapter.SetQuota("", quota.Limit{MaxBytes: 32}); err != nil {
+		t.Fatalf("set quota: %v", err)
+	}
+	storeVectors(t, adapter, map[string][]float64{"a": {1, 0}, "b": {0, 1}})
+
+	reopened, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if got := reopened.Quotas()[""]; got.MaxBytes != 32 {
+		t.Fatalf("quota after restart = %+v, want max_bytes 32", got)
+	}
+
+	if err := reopened.Store(&models.Vector{ID: "c", Embedding: []float64{1, 1}}); !errors.Is(err, quota.ErrExceeded) {
+		t.Fatalf("store over quota: error = %v, want quota error", err)
+	}
+	if err := reopened.Delete("a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := reopened.Store(&models.Vector{ID: "c", Embedding: []float64{1, 1}}); err != nil {
+		t.Errorf("store after delete: %v", err)
+	}
+	if got := reopened.QuotaUsage()[""]; got != (quota.Usage{Vectors: 2, Bytes: 32}) {
+		t.Errorf("usage = %+v", got)
+	}
+}
+
+func TestAdapter_SparseVectors(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "sparse")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	vectors := map[string]*models.SparseVector{
+		"match":   {Indices: []int{2, 40}, Values: []float64{1, 1}, Dimension: 100},
+		"partial": {Indices: []int{2, 7}, Values: []float64{1, 1}, Dimension: 100},
+		"none":    {Indices: []int{9}, Values: []float64{1}, Dimension: 100},
+	}
+	for id, sparse := range vectors {
+		if err := adapter.Store(&models.Vector{ID: id, Sparse: sparse}); err != nil {
+			t.Fatalf("store %s: %v", id, err)
+		}
+	}
+	if got := adapter.VectorConfig().Dimension; got != 100 {
+		t.Errorf("collection dimension = %d, want 100", got)
+	}
+
+	reopened, err := NewVectorStorageAdapter(dir, "sparse")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+
+	stored, err := reopened.Get("partial")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if stored.Sparse == nil || len(stored.Embedding) != 0 {
+		t.Fatalf("stored vector = %+v, want a sparse embedding only", stored)
+	}
+	if stored.Sparse.Dimension != 100 || len(stored.Sparse.Indices) != 2 || stored.Sparse.Indices[1] != 7 {
+		t.Errorf("stored sparse embedding = %+v", stored.Sparse)
+	}
+
+	query := &models.SparseVector{Indices: []int{2, 40}, Values: []float64{1, 1}, Dimension: 100}
+	results, err := reopened.AdvancedSearch(&models.AdvancedSearchRequest{TopK: 3, SparseQuery: query}, nil)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	want := []string{"match", "partial", "none"}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, id := range want {
+		if results[i].Vector.ID != id {
+			t.Errorf("result %d = %s, want %s", i, results[i].Vector.ID, id)
+		}
+	}
+	if results[0].Score < 0.999 {
+		t.Errorf("identical vector scored %v, want 1", results[0].Score)
+	}
+}
+
+func TestAdapter_PendingVectors(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "pending")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	if err := adapter.Store(&models.Vector{ID: "a", Embedding: []float64{1, 0}, Metadata: map[string]string{"text": "a"}}); err != nil {
+		t.Fatalf("store a: %v", err)
+	}
+	if err := adapter.Store(&models.Vector{ID: "b", Metadata: map[string]string{"text": "b"}}); err != nil {
+		t.Fatalf("store b: %v", err)
+	}
+	if got := adapter.CountPending(""); got != 1 {
+		t.Errorf("pending = %d, want 1", got)
+	}
+
+	results, err := adapter.Search(&models.SearchByEmbbedingRequest{Embedding: []float64{1, 0}, TopK: 10})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Vector.ID != "a" {
+		t.Errorf("results = %+v, want only a", results)
+	}
+
+	// Storing a vector again without embedding drops its embedding file
+	if err := adapter.Store(&models.Vector{ID: "a", Metadata: map[string]string{"text": "a"}}); err != nil {
+		t.Fatalf("store a again: %v", err)
+	}
+	reopened, err := NewVectorStorageAdapter(dir, "pending")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	stored, err := reopened.Get("a")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if stored.HasEmbedding() {
+		t.Errorf("a kept embedding %v", stored.Embedding)
+	}
+	if got := reopened.CountPending(""); got != 2 {
+		t.Errorf("pending after reopen = %d, want 2", got)
+	}
+}
+
+func TestAdapter_ProfilesPersisted(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "profiles")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	if err := adapter.SetProfile(profile.Profile{Name: "news", TemporalDecay: models.DecayStrong}); err != nil {
+		t.Fatalf("set profile: %v", err)
+	}
+	if err := adapter.SetProfile(profile.Profile{Name: "bad", Metric: "manhattan"}); err == nil {
+		t.Error("expected an invalid profile to be rejected")
+	}
+
+	reopened, err := NewVectorStorageAdapter(dir, "profiles")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if p, ok := reopened.Profiles()["news"]; !ok || p.TemporalDecay != models.DecayStrong {
+		t.Fatalf("profiles after reopen = %+v", reopened.Profiles())
+	}
+
+	if err := reopened.DeleteProfile("news"); err != nil {
+		t.Fatalf("delete profile: %v", err)
+	}
+	if err := reopened.DeleteProfile("news"); !errors.Is(err, profile.ErrNotFound) {
+		t.Errorf("deleting a missing profile: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAdapter_AliasesPersisted(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "aliases")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	if err := adapter.SetAlias(alias.Alias{Name: "products", Namespace: "products-v1"}); err != nil {
+		t.Fatalf("set alias: %v", err)
+	}
+	swapped := adapter.Aliases()["products"].Swap("products-v2", time.Hour, time.Now())
+	if err := adapter.SetAlias(swapped); err != nil {
+		t.Fatalf("swap alias: %v", err)
+	}
+	if err := adapter.SetAlias(alias.Alias{Name: "loop", Namespace: "loop"}); err == nil {
+		t.Error("expected an alias pointing at itself to be rejected")
+	}
+
+	reopened, err := NewVectorStorageAdapter(dir, "aliases")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if namespace, ok := reopened.ResolveAlias("products"); !ok || namespace != "products-v2" {
+		t.Fatalf("products resolves to %q, %v after reopen, want products-v2", namespace, ok)
+	}
+	if a := reopened.Aliases()["products"]; a.Previous != "products-v1" || a.DeletePreviousAt == nil {
+		t.Errorf("alias after reopen = %+v, want the pending deletion of products-v1", a)
+	}
+
+	if err := reopened.DeleteAlias("products"); err != nil {
+		t.Fatalf("delete alias: %v", err)
+	}
+	if err := reopened.DeleteAlias("products"); !errors.Is(err, alias.ErrNotFound) {
+		t.Errorf("deleting a missing alias: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAdapter_QuarantinePersisted(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "quarantine")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	if err := adapter.SetQuarantineLimit(2); err != nil {
+		t.Fatalf("set limit: %v", err)
+	}
+	for _, reason := range []string{"embed_error", "dimension_too_large", "invalid_vector"} {
+		entry, err := quarantine.NewEntry(quarantine.KindRecord, "reviews.jsonl", reason, nil, quarantine.Record{Text: reason})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := adapter.Quarantine(entry); err != nil {
+			t.Fatalf("quarantine: %v", err)
+		}
+	}
+
+	reopened, err := NewVectorStorageAdapter(dir, "quarantine")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	entries := reopened.Quarantined()
+	if len(entries) != 2 || entries[0].Reason != "dimension_too_large" {
+		t.Fatalf("entries after reopen = %+v, want the 2 newest", entries)
+	}
+	if stats := reopened.QuarantineStats(); stats.Evicted != 1 || stats.MaxEntries != 2 {
+		t.Errorf("stats after reopen = %+v", stats)
+	}
+
+	if err := reopened.RemoveQuarantined(entries[0].ID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := reopened.RemoveQuarantined(entries[0].ID); !errors.Is(err, quarantine.ErrNotFound) {
+		t.Errorf("removing a missing entry: err = %v, want ErrNotFound", err)
+	}
+	if n := reopened.Count(); n != 0 {
+		t.Errorf("quarantined records were stored as %d vectors", n)
+	}
+}
+
+func TestAdapter_EvalSetsPersisted(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "eval")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	set := eval.Set{Name: "faq", Queries: []eval.Query{{Query: "refund", Relevant: []string{"doc-1"}}}}
+	if err := adapter.CreateEvalSet(set); err != nil {
+		t.Fatalf("create set: %v", err)
+	}
+	if err := adapter.CreateEvalSet(set); !errors.Is(err, eval.ErrExists) {
+		t.Errorf("creating a set twice: err = %v, want ErrExists", err)
+	}
+	if err := adapter.RecordEvalRun(&eval.Run{ID: "run-1", Set: "faq", Metrics: eval.Metrics{Recall: 0.5}}); err != nil {
+		t.Fatalf("record run: %v", err)
+	}
+
+	reopened, err := NewVectorStorageAdapter(dir, "eval")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if _, ok := reopened.EvalSets()["faq"]; !ok {
+		t.Fatalf("sets after reopen = %+v", reopened.EvalSets())
+	}
+	runs, err := reopened.ListEvalRuns("faq")
+	if err != nil || len(runs) != 1 || runs[0].Metrics.Recall != 0.5 {
+		t.Fatalf("runs after reopen = %+v, err = %v", runs, err)
+	}
+
+	if err := reopened.DeleteEvalSet("faq"); err != nil {
+		t.Fatalf("delete set: %v", err)
+	}
+	if runs, _ := reopened.ListEvalRuns("faq"); len(runs) != 0 {
+		t.Errorf("runs of a deleted set were kept: %+v", runs)
+	}
+	if err := reopened.DeleteEvalSet("faq"); !errors.Is(err, eval.ErrNotFound) {
+		t.Errorf("deleting a missing set: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAdapter_GetMany(t *testing.T) {
+	adapter, err := NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	storeVectors(t, adapter, map[string][]float64{"a": {1, 0}, "b": {0, 1}})
+
+	vectors, err := adapter.GetMany([]string{"b", "missing", "a"})
+	if err != nil {
+		t.Fatalf("GetMany() error = %v", err)
+	}
+	if len(vectors) != 3 || vectors[0].ID != "b" || vectors[1] != nil || vectors[2].ID != "a" || vectors[2].Embedding[0] != 1 {
+		t.Errorf("GetMany() = %v, want b, nil and a", vectors)
+	}
+}
+
+func TestAdapter_LegacyBulkJobsConverted(t *testing.T) {
+	basePath := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A bulk job persisted before bulk jobs ran as background jobs
+	ls := adapter.localStorage
+	ls.mu.Lock()
+	ls.schema.Collections["vectors"].BulkJobs = []*bulk.Job{{
+		ID:      "legacy",
+		Request: bulk.Request{Action: bulk.ActionDelete, Namespace: "old"},
+		State:   bulk.StateRunning,
+		Cursor:  "old-07",
+	}}
+	err = ls.saveSchema()
+	ls.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := reopened.Jobs()
+	if err != nil || len(list) != 1 || list[0].Type != bulk.JobType || list[0].Checkpoint != "old-07" {
+		t.Fatalf("Jobs() = %+v, %v, want the legacy job converted", list, err)
+	}
+
+	list[0].State = jobs.StateCompleted
+	if err := reopened.SaveJob(list[0]); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ = reopened.Jobs(); len(list) != 1 || list[0].State != jobs.StateCompleted {
+		t.Errorf("Jobs() after save = %+v, want the legacy job replaced", list)
+	}
+	if legacy := reopened.localStorage.schema.Collections["vectors"].BulkJobs; len(legacy) != 0 {
+		t.Errorf("legacy bulk jobs left: %+v", legacy)
+	}
+}
+
+func TestAdapter_TemporalSearch(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	now := time.Now()
+	for _, vector := range []*models.Vector{
+		{ID: "old", CreatedAt: now.Add(-30 * 24 * time.Hour), Metadata: map[string]string{"lang": "en"}},
+		{ID: "new", CreatedAt: now.Add(-time.Hour), Metadata: map[string]string{"lang": "en"}},
+		{ID: "published", CreatedAt: now.Add(-time.Hour), Metadata: map[string]string{"lang": "en", "published": now.Add(-10 * 24 * time.Hour).Format(time.RFC3339)}},
+		{ID: "other", CreatedAt: now, Metadata: map[string]string{"lang": "fr"}},
+	} {
+		vector.Embedding = []float64{1, 0}
+		if err := adapter.Store(vector); err != nil {
+			t.Fatalf("store %s: %v", vector.ID, err)
+		}
+	}
+	adapter.Close()
+
+	// Reopened, the embeddings are loaded from their files
+	adapter, err = NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	search := func(req *models.TemporalSearchRequest) []*models.TemporalSearchResult {
+		t.Helper()
+		if err := req.Validate(); err != nil {
+			t.Fatalf("invalid request: %v", err)
+		}
+		results, err := adapter.TemporalSearch(req, []float64{1, 0})
+		if err != nil {
+			t.Fatalf("temporal search failed: %v", err)
+		}
+		return results
+	}
+	ids := func(results []*models.TemporalSearchResult) []string {
+		ids := make([]string, len(results))
+		for i, result := range results {
+			ids[i] = result.Vector.ID
+		}
+		return ids
+	}
+
+	// Equally similar, newer vectors rank above older ones
+	results := search(&models.TemporalSearchRequest{Query: "q", TemporalDecay: models.DecayStrong, Filters: models.Filters{"lang": {"eq": "en"}}, TimeField: "published"})
+	if got := ids(results); len(got) != 3 || got[0] != "new" || got[1] != "published" || got[2] != "old" {
+		t.Fatalf("order = %v, want new, published, old", got)
+	}
+	if results[0].BaseScore != results[2].BaseScore || results[0].DecayFactor <= results[2].DecayFactor {
+		t.Errorf("base scores %v and %v, decay factors %v and %v", results[0].BaseScore, results[2].BaseScore, results[0].DecayFactor, results[2].DecayFactor)
+	}
+
+	// The time field is read from the metadata, falling back to the creation time
+	if published := results[1]; published.TimeSource != models.TimeSourceField || published.TimeFallback {
+		t.Errorf("published time source = %s, fallback %v", published.TimeSource, published.TimeFallback)
+	}
+	if created := results[0]; created.TimeSource != models.TimeSourceCreatedAt || !created.TimeFallback {
+		t.Errorf("new time source = %s, fallback %v", created.TimeSource, created.TimeFallback)
+	}
+
+	if got := ids(search(&models.TemporalSearchRequest{Query: "q", TemporalDecay: models.DecayStrong, TopK: 2})); len(got) != 2 || got[0] != "other" || got[1] != "new" {
+		t.Errorf("top 2 = %v, want other and new", got)
+	}
+}
@@ -0,0 +1,78 @@
+package local
+
+import (
+	"github.com/tahcohcat/same-same/internal/storage/alias"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Aliases returns the namespace aliases of a collection
+func (ls *LocalStorage) Aliases(collectionName string) (alias.Aliases, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	aliases := make(alias.Aliases, len(collection.Aliases))
+	for name, a := range collection.Aliases {
+		aliases[name] = a
+	}
+	return aliases, nil
+}
+
+// ResolveAlias returns the namespace an alias of a collection points at
+func (ls *LocalStorage) ResolveAlias(collectionName, name string) (string, bool) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return "", false
+	}
+	a, ok := collection.Aliases[name]
+	return a.Namespace, ok
+}
+
+// SetAlias creates or retargets an alias of a collection and persists it
+func (ls *LocalStorage) SetAlias(collectionName string, a alias.Alias) error {
+	if err := a.Validate(); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	if collection.Aliases == nil {
+		collection.Aliases = make(alias.Aliases)
+	}
+	collection.Aliases[a.Name] = a
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// DeleteAlias removes an alias of a collection and persists the change
+func (ls *LocalStorage) DeleteAlias(collectionName, name string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	if _, ok := collection.Aliases[name]; !ok {
+		return alias.NotFound(name)
+	}
+	delete(collection.Aliases, name)
+
+	// Already holding lock
+	return ls.saveSchema()
+}
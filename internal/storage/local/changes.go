@@ -0,0 +1,93 @@
+package local
+
+import (
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+)
+
+// addTombstone records the deletion of a document, doc is nil when it was only on disk
+// Caller must hold the write lock and save the schema
+func (c *Collection) addTombstone(id string, doc *Document, opts tombstone.Options) {
+	if c.Tombstones == nil {
+		c.Tombstones = &tombstone.Log{}
+	}
+	t := tombstone.Tombstone{ID: id, DeletedAt: time.Now()}
+	if doc != nil {
+		t.Namespace, _ = doc.Metadata[models.NamespaceKey].(string)
+	}
+	c.Tombstones.Add(t, opts)
+}
+
+// Changes returns the documents of namespace updated at or after since, the
+// tombstones of those deleted since and the horizon of the tombstone log
+// The document index is scanned, so no document file is read
+func (ls *LocalStorage) Changes(collectionName, namespace string, since time.Time) ([]*Document, *tombstone.Log, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	docs := make([]*Document, 0)
+	for _, doc := range collection.Documents {
+		if doc.UpdatedAt.Before(since) {
+			continue
+		}
+		if docNamespace, _ := doc.Metadata[models.NamespaceKey].(string); namespace == "" || docNamespace == namespace {
+			docs = append(docs, doc)
+		}
+	}
+
+	deleted := &tombstone.Log{}
+	if collection.Tombstones != nil {
+		deleted.Entries = collection.Tombstones.Since(since, namespace)
+		deleted.Horizon = collection.Tombstones.Horizon
+	}
+	return docs, deleted, nil
+}
+
+// SetTombstoneOptions sets the retention of deleted document IDs and compacts the
+// tombstone logs of every collection
+func (ls *LocalStorage) SetTombstoneOptions(opts tombstone.Options) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.tombstoneOpts = opts
+	for _, collection := range ls.schema.Collections {
+		if collection.Tombstones != nil {
+			collection.Tombstones.Compact(time.Now(), opts)
+		}
+	}
+	return ls.saveSchema()
+}
+
+// Changes returns the vectors of namespace updated at or after since and the
+// tombstones of those deleted since, or of all namespaces if namespace is empty
+func (vsa *VectorStorageAdapter) Changes(namespace string, since time.Time) (*tombstone.Changes, error) {
+	docs, deleted, err := vsa.localStorage.Changes(vsa.collection, namespace, since)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &tombstone.Changes{
+		Vectors: make([]*models.Vector, 0, len(docs)),
+		Deleted: deleted.Entries,
+		Horizon: deleted.Horizon,
+	}
+	for _, doc := range docs {
+		vector, _ := vsa.documentVector(doc)
+		changes.Vectors = append(changes.Vectors, vector)
+	}
+	tombstone.SortByUpdate(changes.Vectors)
+	return changes, nil
+}
+
+// SetTombstoneOptions sets the retention of deleted vector IDs
+func (vsa *VectorStorageAdapter) SetTombstoneOptions(opts tombstone.Options) error {
+	return vsa.localStorage.SetTombstoneOptions(opts)
+}
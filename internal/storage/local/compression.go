@@ -0,0 +1,299 @@
+package local
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// CompressionGzip stores document, embedding and content files gzip compressed
+	CompressionGzip = "gzip"
+
+	gzipSuffix = ".gz"
+)
+
+// Options configures a LocalStorage
+type Options struct {
+	// Compression is the codec used for new document, embedding and content
+	// files: "" for none or "gzip". Files already on disk are read either way,
+	// so compression can be enabled or disabled without migrating
+	Compression string
+
+	// CompressionLevel is the gzip level (1-9), gzip.DefaultCompression when zero
+	CompressionLevel int
+
+	// Durability is when writes reach the disk: DurabilityStrict (the default),
+	// DurabilityBatched or DurabilityRelaxed
+	Durability string
+
+	// FlushOps and FlushInterval bound how long batched schema updates are
+	// buffered: they are flushed after FlushOps operations or FlushInterval,
+	// whichever comes first (DefaultFlushOps and DefaultFlushInterval when zero)
+	FlushOps      int
+	FlushInterval time.Duration
+
+	// EmbeddingCache bounds the embeddings a VectorStorageAdapter keeps in memory
+	// after loading them for searches
+	EmbeddingCache EmbeddingCacheOptions
+}
+
+// validate checks the options and fills in defaults
+func (o *Options) validate() error {
+	if err := o.validateDurability(); err != nil {
+		return err
+	}
+	if err := o.EmbeddingCache.validate(); err != nil {
+		return err
+	}
+
+	switch o.Compression {
+	case "":
+		return nil
+	case CompressionGzip:
+		if o.CompressionLevel == 0 {
+			o.CompressionLevel = gzip.DefaultCompression
+		}
+		if _, err := gzip.NewWriterLevel(io.Discard, o.CompressionLevel); err != nil {
+			return fmt.Errorf("invalid compression level %d: %w", o.CompressionLevel, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression %q: must be empty or %s", o.Compression, CompressionGzip)
+	}
+}
+
+// compressedWriter closes the gzip stream before the underlying file
+type compressedWriter struct {
+	*gzip.Writer
+	file *os.File
+	sync bool // fsync the file before closing it
+}
+
+func (w *compressedWriter) Close() error {
+	err := w.Writer.Close()
+	if w.sync && err == nil {
+		err = w.file.Sync()
+	}
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// compressedReader closes the underlying file along with the gzip stream
+type compressedReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *compressedReader) Close() error {
+	err := r.Reader.Close()
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// createFile creates the file for path using the configured compression
+// Compressed files get a .gz suffix, and the other variant of the file is
+// removed so that reads never see a stale copy
+func (ls *LocalStorage) createFile(path string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(path), DefaultPermission); err != nil {
+		return nil, err
+	}
+
+	target, stale := path, path+gzipSuffix
+	if ls.options.Compression == CompressionGzip {
+		target, stale = stale, target
+	}
+
+	file, err := os.Create(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+		file.Close()
+		return nil, err
+	}
+
+	if ls.options.Compression != CompressionGzip {
+		if ls.options.Durability == DurabilityStrict {
+			return syncedFile{file}, nil
+		}
+		return file, nil
+	}
+
+	gz, err := gzip.NewWriterLevel(file, ls.options.CompressionLevel)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &compressedWriter{Writer: gz, file: file, sync: ls.options.Durability == DurabilityStrict}, nil
+}
+
+// openFile opens path, falling back to its compressed variant
+func openFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err == nil {
+		return file, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err = os.Open(path + gzipSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", file.Name(), err)
+	}
+	return &compressedReader{Reader: gz, file: file}, nil
+}
+
+// removeFile removes both variants of path
+func removeFile(path string) {
+	os.Remove(path)
+	os.Remove(path + gzipSuffix)
+}
+
+// WriteBinaryContent stores data as the binary content of a document
+// The returned content records the logical size, checksum and compression
+// and should be set as the document's Content.Binary
+func (ls *LocalStorage) WriteBinaryContent(collectionName, docID, format string, data []byte) (*BinaryContent, error) {
+	path, err := ls.getContentPath(collectionName, docID, "binary")
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := ls.createFile(path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(data)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	content := &BinaryContent{
+		Format:   format,
+		Size:     int64(len(data)),
+		Path:     path,
+		Checksum: hex.EncodeToString(sum[:]),
+	}
+	if ls.options.Compression != "" {
+		content.Path += gzipSuffix
+		content.Compression = ls.options.Compression
+	}
+
+	return content, nil
+}
+
+// ReadBinaryContent reads the data of binary content, decompressing it
+// according to its Compression and verifying its checksum
+func (ls *LocalStorage) ReadBinaryContent(content *BinaryContent) ([]byte, error) {
+	rel, err := filepath.Rel(filepath.Clean(ls.basePath), filepath.Clean(content.Path))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("content path %q is outside the storage directory", content.Path)
+	}
+	path, err := ls.resolvePath(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	switch content.Compression {
+	case "":
+	case CompressionGzip:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	default:
+		return nil, fmt.Errorf("unsupported content compression %q", content.Compression)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if content.Checksum != "" {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != content.Checksum {
+			return nil, fmt.Errorf("checksum mismatch for %s", content.Path)
+		}
+	}
+
+	return data, nil
+}
+
+// diskUsage sums the stored and logical (uncompressed) sizes of the data files
+// The logical size of a gzip file is read from its trailer
+func (ls *LocalStorage) diskUsage() (stored, logical int64, compressed int) {
+	for _, dir := range []string{CollectionsDir, EmbeddingsDir, ContentDir, EnrichmentDir, QuarantineDir, FieldsDir, VocabularyDir} {
+		filepath.Walk(filepath.Join(ls.basePath, dir), func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+
+			stored += info.Size()
+			if !strings.HasSuffix(path, gzipSuffix) {
+				logical += info.Size()
+				return nil
+			}
+
+			compressed++
+			if size, err := gzipLogicalSize(path, info.Size()); err == nil {
+				logical += size
+			} else {
+				logical += info.Size()
+			}
+			return nil
+		})
+	}
+	return stored, logical, compressed
+}
+
+// gzipLogicalSize reads the uncompressed size (modulo 2^32) from a gzip trailer
+func gzipLogicalSize(path string, size int64) (int64, error) {
+	if size < 4 {
+		return 0, fmt.Errorf("%s is too short to be gzip", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	trailer := make([]byte, 4)
+	if _, err := file.ReadAt(trailer, size-4); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint32(trailer)), nil
+}
@@ -0,0 +1,153 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestCompression_MixedDirectory(t *testing.T) {
+	basePath := t.TempDir()
+
+	plain, err := NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	storeVectors(t, plain, map[string][]float64{"plain": {1, 0, 0}})
+
+	// Enabling compression later needs no migration
+	compressed, err := NewVectorStorageAdapterWithOptions(basePath, "vectors", nil, Options{Compression: CompressionGzip, CompressionLevel: 9})
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	storeVectors(t, compressed, map[string][]float64{"packed": {0, 1, 0}})
+
+	if _, err := os.Stat(filepath.Join(basePath, EmbeddingsDir, "vectors", "packed.json.gz")); err != nil {
+		t.Errorf("expected compressed embedding file: %v", err)
+	}
+
+	for _, id := range []string{"plain", "packed"} {
+		embedding, err := compressed.localStorage.loadEmbedding("vectors", id)
+		if err != nil {
+			t.Fatalf("load %s: %v", id, err)
+		}
+		if len(embedding.Vector) != 3 {
+			t.Errorf("load %s: expected 3 dimensions, got %d", id, len(embedding.Vector))
+		}
+	}
+
+	// Rewriting a document with compression replaces the uncompressed copy
+	storeVectors(t, compressed, map[string][]float64{"plain": {0, 0, 1}})
+	if _, err := os.Stat(filepath.Join(basePath, EmbeddingsDir, "vectors", "plain.json")); !os.IsNotExist(err) {
+		t.Errorf("expected stale uncompressed file to be removed, got %v", err)
+	}
+
+	stats := compressed.GetStats()
+	stored, logical := stats["stored_bytes"].(int64), stats["logical_bytes"].(int64)
+	if stats["compressed_files"].(int) == 0 || logical <= 0 || stored <= 0 {
+		t.Errorf("unexpected size stats: %v", stats)
+	}
+
+	if err := compressed.Delete("packed"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(basePath, EmbeddingsDir, "vectors", "packed.json.gz")); !os.IsNotExist(err) {
+		t.Errorf("expected compressed file to be deleted, got %v", err)
+	}
+}
+
+func TestCompression_BinaryContent(t *testing.T) {
+	ls, err := NewLocalStorageWithOptions(t.TempDir(), Options{Compression: CompressionGzip})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if _, err := ls.CreateCollection("files", "", nil); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	data := bytes.Repeat([]byte("same-same "), 100)
+	content, err := ls.WriteBinaryContent("files", "doc", "txt", data)
+	if err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if content.Compression != CompressionGzip || content.Size != int64(len(data)) {
+		t.Errorf("unexpected content metadata: %+v", content)
+	}
+
+	got, err := ls.ReadBinaryContent(content)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("read data does not match written data")
+	}
+
+	content.Path = filepath.Join(ls.basePath, "..", "elsewhere")
+	if _, err := ls.ReadBinaryContent(content); err == nil {
+		t.Error("expected content outside the storage directory to be rejected")
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	if _, err := NewLocalStorageWithOptions(t.TempDir(), Options{Compression: "zip"}); err == nil {
+		t.Error("expected unknown compression to be rejected")
+	}
+	if _, err := NewLocalStorageWithOptions(t.TempDir(), Options{Compression: CompressionGzip, CompressionLevel: 42}); err == nil {
+		t.Error("expected invalid compression level to be rejected")
+	}
+}
+
+// benchmarkEmbedding returns a typical 768 dimension embedding document
+func benchmarkEmbedding() *models.Vector {
+	embedding := make([]float64, 768)
+	for i := range embedding {
+		embedding[i] = float64(i%97) / 97
+	}
+	return &models.Vector{ID: "bench", Embedding: embedding, Metadata: map[string]string{"text": "benchmark document"}}
+}
+
+func BenchmarkStore(b *testing.B) {
+	for _, options := range []Options{{}, {Compression: CompressionGzip, CompressionLevel: 1}, {Compression: CompressionGzip}} {
+		b.Run(fmt.Sprintf("compression=%q/level=%d", options.Compression, options.CompressionLevel), func(b *testing.B) {
+			adapter, err := NewVectorStorageAdapterWithOptions(b.TempDir(), "bench", nil, options)
+			if err != nil {
+				b.Fatal(err)
+			}
+			vector := benchmarkEmbedding()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				vector.ID = fmt.Sprintf("doc-%d", i%100)
+				vector.Embedding = benchmarkEmbedding().Embedding
+				if err := adapter.Store(vector); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLoadEmbedding(b *testing.B) {
+	for _, options := range []Options{{}, {Compression: CompressionGzip}} {
+		b.Run(fmt.Sprintf("compression=%q", options.Compression), func(b *testing.B) {
+			adapter, err := NewVectorStorageAdapterWithOptions(b.TempDir(), "bench", nil, options)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := adapter.Store(benchmarkEmbedding()); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := adapter.localStorage.loadEmbedding("bench", "bench"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Durability modes of a LocalStorage, from safest to fastest
+const (
+	// DurabilityStrict fsyncs every document, embedding and schema file as it is
+	// written, so an operation that returned is never lost in a crash
+	DurabilityStrict = "strict"
+
+	// DurabilityBatched writes document and embedding files as they are stored but
+	// buffers the schema, with the collection index and stats, and flushes it with
+	// an fsync every FlushOps operations or FlushInterval. A crash loses the
+	// operations since the last flush from the index, at most FlushOps of them
+	// or FlushInterval worth; their files stay on disk for Reconcile to re-index
+	DurabilityBatched = "batched"
+
+	// DurabilityRelaxed writes every file on each operation without fsync and
+	// leaves it to the OS to write them back. A process crash loses nothing, but
+	// an OS crash or power loss can lose whatever the OS had not written back yet,
+	// typically the last 30 seconds on Linux
+	DurabilityRelaxed = "relaxed"
+)
+
+const (
+	// DefaultFlushOps is how many operations the batched mode buffers by default
+	DefaultFlushOps = 1000
+
+	// DefaultFlushInterval is how long the batched mode buffers operations by default
+	DefaultFlushInterval = 200 * time.Millisecond
+)
+
+// validateDurability checks the durability mode and fills in its defaults
+func (o *Options) validateDurability() error {
+	switch o.Durability {
+	case "":
+		o.Durability = DurabilityStrict
+	case DurabilityStrict, DurabilityBatched, DurabilityRelaxed:
+	default:
+		return fmt.Errorf("unsupported durability %q: must be %s, %s or %s", o.Durability, DurabilityStrict, DurabilityBatched, DurabilityRelaxed)
+	}
+
+	if o.FlushOps < 0 || o.FlushInterval < 0 {
+		return fmt.Errorf("flush ops and interval cannot be negative")
+	}
+	if o.FlushOps == 0 {
+		o.FlushOps = DefaultFlushOps
+	}
+	if o.FlushInterval == 0 {
+		o.FlushInterval = DefaultFlushInterval
+	}
+	return nil
+}
+
+// syncedFile fsyncs the file before closing it
+type syncedFile struct {
+	*os.File
+}
+
+func (f syncedFile) Close() error {
+	err := f.File.Sync()
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// saveSchema records a change of the schema and persists it as the durability
+// mode requires: at once, or at the next flush of the batched mode
+// Caller must hold the lock
+func (ls *LocalStorage) saveSchema() error {
+	if ls.options.Durability != DurabilityBatched {
+		return ls.writeSchema(ls.options.Durability == DurabilityStrict)
+	}
+
+	ls.pendingOps++
+	if ls.pendingOps >= ls.options.FlushOps {
+		return ls.writeSchema(true)
+	}
+	return nil
+}
+
+// writeSchema writes the schema to disk, fsyncing it when sync is set
+// It is written to a temporary file renamed over the schema file, so a crash
+// mid-write leaves the previous schema rather than a truncated one
+// Caller must hold the lock
+func (ls *LocalStorage) writeSchema(sync bool) error {
+	ls.schema.UpdatedAt = time.Now()
+
+	if err := ls.replaceSchemaFile(sync); err != nil {
+		return err
+	}
+
+	ls.pendingOps = 0
+	ls.flushes++
+	ls.lastFlush = ls.schema.UpdatedAt
+	ls.saveFields()
+	return nil
+}
+
+// replaceSchemaFile encodes the schema to a temporary file in the storage directory
+// and renames it over metadata.json. With sync, the file is fsynced before the rename
+// and the directory after it, so the rename itself survives a crash
+// Caller must hold the lock
+func (ls *LocalStorage) replaceSchemaFile(sync bool) error {
+	tmp, err := os.CreateTemp(ls.basePath, "."+MetadataFile+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(ls.schema)
+	if sync && err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(ls.basePath, MetadataFile)); err != nil {
+		return err
+	}
+	if sync {
+		return syncDir(ls.basePath)
+	}
+	return nil
+}
+
+// syncDir fsyncs a directory, persisting the renames and creations of its entries
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if cerr := dir.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Flush synchronously writes and fsyncs the schema, with any updates the
+// batched mode has buffered
+func (ls *LocalStorage) Flush() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.writeSchema(true)
+}
+
+// flushLoop flushes the updates buffered by the batched mode every
+// FlushInterval until Close
+func (ls *LocalStorage) flushLoop() {
+	defer close(ls.flushDone)
+
+	ticker := time.NewTicker(ls.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ls.stopFlush:
+			return
+		case <-ticker.C:
+			ls.mu.Lock()
+			if ls.pendingOps > 0 {
+				if err := ls.writeSchema(true); err != nil {
+					ls.logger.WithError(err).Error("failed to flush storage schema")
+				}
+			}
+			ls.mu.Unlock()
+		}
+	}
+}
+
+// durabilityStats reports the durability mode and the state of its flushes
+// Caller must hold the lock
+func (ls *LocalStorage) durabilityStats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"mode":        ls.options.Durability,
+		"pending_ops": ls.pendingOps,
+		"flushes":     ls.flushes,
+		"last_flush":  ls.lastFlush,
+	}
+	if ls.options.Durability == DurabilityBatched {
+		stats["flush_ops"] = ls.options.FlushOps
+		stats["flush_interval"] = ls.options.FlushInterval.String()
+	}
+	return stats
+}
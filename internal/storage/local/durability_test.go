@@ -0,0 +1,191 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// flushedDocuments returns how many documents of collection metadata.json holds,
+// or -1 when it does not hold the collection
+func flushedDocuments(t *testing.T, basePath, collection string) int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(basePath, MetadataFile))
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	var schema StorageSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("decode schema: %v", err)
+	}
+	c, ok := schema.Collections[collection]
+	if !ok {
+		return -1
+	}
+	return len(c.Documents)
+}
+
+func TestDurability_BatchedFlushesAfterOps(t *testing.T) {
+	basePath := t.TempDir()
+	ls, err := NewLocalStorageWithOptions(basePath, Options{Durability: DurabilityBatched, FlushOps: 3, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer ls.Close()
+
+	// Creating the collection is the first buffered operation
+	if _, err := ls.CreateCollection("docs", "", nil); err != nil {
+		t.Fatalf("create collection: %v", err)
+	}
+	if err := ls.StoreDocument("docs", &Document{ID: "a"}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if n := flushedDocuments(t, basePath, "docs"); n != -1 {
+		t.Fatalf("collection flushed with %d documents after 2 of 3 operations", n)
+	}
+	durability := ls.GetStats()["durability"].(map[string]interface{})
+	if durability["mode"] != DurabilityBatched || durability["pending_ops"] != 2 {
+		t.Errorf("durability stats = %v", durability)
+	}
+
+	if err := ls.StoreDocument("docs", &Document{ID: "b"}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if n := flushedDocuments(t, basePath, "docs"); n != 2 {
+		t.Errorf("flushed %d documents after 3 operations, want 2", n)
+	}
+}
+
+func TestDurability_BatchedFlushesAfterInterval(t *testing.T) {
+	basePath := t.TempDir()
+	adapter, err := NewVectorStorageAdapterWithOptions(basePath, "vectors", nil, Options{Durability: DurabilityBatched, FlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	defer adapter.Close()
+
+	storeVectors(t, adapter, map[string][]float64{"a": {1, 0}})
+
+	// Wait for the flush loop to complete a flush of the store, then read what it wrote
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		durability := adapter.localStorage.GetStats()["durability"].(map[string]interface{})
+		if durability["pending_ops"] == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("document not flushed by the flush loop")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := flushedDocuments(t, basePath, "vectors"); n != 1 {
+		t.Errorf("flush loop flushed %d documents, want 1", n)
+	}
+}
+
+func TestDurability_SchemaReplacedWhole(t *testing.T) {
+	basePath := t.TempDir()
+	ls, err := NewLocalStorageWithOptions(basePath, Options{Durability: DurabilityStrict})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer ls.Close()
+	if _, err := ls.CreateCollection("docs", "", nil); err != nil {
+		t.Fatalf("create collection: %v", err)
+	}
+
+	// Readers of metadata.json see a whole schema while it is rewritten
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			ls.StoreDocument("docs", &Document{ID: fmt.Sprintf("doc%d", i)})
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		flushedDocuments(t, basePath, "docs")
+	}
+	if n := flushedDocuments(t, basePath, "docs"); n != 50 {
+		t.Errorf("schema holds %d documents, want 50", n)
+	}
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".tmp" {
+			t.Errorf("temporary schema file %s left in the storage directory", entry.Name())
+		}
+	}
+}
+
+func TestDurability_FlushAndClose(t *testing.T) {
+	basePath := t.TempDir()
+	adapter, err := NewVectorStorageAdapterWithOptions(basePath, "vectors", nil, Options{Durability: DurabilityBatched, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	storeVectors(t, adapter, map[string][]float64{"a": {1, 0}})
+	if err := adapter.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if n := flushedDocuments(t, basePath, "vectors"); n != 1 {
+		t.Errorf("flushed %d documents on demand, want 1", n)
+	}
+
+	storeVectors(t, adapter, map[string][]float64{"b": {0, 1}})
+	if err := adapter.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := adapter.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+
+	reopened, err := NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if count := reopened.Count(); count != 2 {
+		t.Errorf("reopened storage holds %d vectors, want 2", count)
+	}
+}
+
+func TestDurability_InvalidOptions(t *testing.T) {
+	for _, options := range []Options{{Durability: "eventual"}, {Durability: DurabilityBatched, FlushOps: -1}} {
+		if _, err := NewLocalStorageWithOptions(t.TempDir(), options); err == nil {
+			t.Errorf("options %+v accepted", options)
+		}
+	}
+}
+
+func BenchmarkDurability(b *testing.B) {
+	for _, mode := range []string{DurabilityStrict, DurabilityBatched, DurabilityRelaxed} {
+		b.Run(mode, func(b *testing.B) {
+			adapter, err := NewVectorStorageAdapterWithOptions(b.TempDir(), "bench", nil, Options{Durability: mode})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer adapter.Close()
+			vector := benchmarkEmbedding()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				vector.ID = fmt.Sprintf("doc-%d", i)
+				vector.Embedding = benchmarkEmbedding().Embedding
+				if err := adapter.Store(vector); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package local
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultEmbeddingCacheEntries is the number of embeddings kept when no cache bound is set
+const DefaultEmbeddingCacheEntries = 10000
+
+// EmbeddingCacheOptions bounds the embeddings kept in memory after they are loaded from
+// their files, so repeated searches do not read and decode every file again
+type EmbeddingCacheOptions struct {
+	// MaxEntries and MaxBytes cap the cached embeddings, the least recently used being
+	// dropped first. Both zero keep DefaultEmbeddingCacheEntries, a negative MaxEntries
+	// disables the cache
+	MaxEntries int
+	MaxBytes   int64
+
+	// TTL drops embeddings loaded longer ago, zero keeps them until evicted
+	TTL time.Duration
+}
+
+// validate checks the bounds of the cache
+func (o EmbeddingCacheOptions) validate() error {
+	if o.MaxBytes < 0 {
+		return fmt.Errorf("invalid embedding cache max bytes %d: must not be negative", o.MaxBytes)
+	}
+	if o.TTL < 0 {
+		return fmt.Errorf("invalid embedding cache TTL %s: must not be negative", o.TTL)
+	}
+	return nil
+}
+
+// EmbeddingCacheStats reports the content and effectiveness of the embedding cache
+type EmbeddingCacheStats struct {
+	Enabled    bool    `json:"enabled"`
+	Entries    int     `json:"entries"`
+	Bytes      int64   `json:"bytes"`
+	MaxEntries int     `json:"max_entries,omitempty"`
+	MaxBytes   int64   `json:"max_bytes,omitempty"`
+	TTL        string  `json:"ttl,omitempty"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	HitRate    float64 `json:"hit_rate"`
+	Evictions  uint64  `json:"evictions"`
+}
+
+// embeddingCache is an LRU of the embeddings loaded from their files, keyed by document ID
+// Entries remember the document they were loaded for: a document replaced by any write,
+// even one bypassing the adapter, misses instead of returning the values it replaced.
+// A nil cache is disabled and always misses
+type embeddingCache struct {
+	mu      sync.Mutex
+	opts    EmbeddingCacheOptions
+	entries map[string]*list.Element
+	order   *list.List // Most recently used at the front
+	bytes   int64
+	now     func() time.Time
+
+	hits, misses, evictions uint64
+}
+
+// cachedEmbedding is an entry of the embedding cache
+type cachedEmbedding struct {
+	id        string
+	doc       *Document
+	embedding *EmbeddingData
+	size      int64
+	loaded    time.Time
+}
+
+// newEmbeddingCache returns a cache bounded by opts, nil when disabled
+func newEmbeddingCache(opts EmbeddingCacheOptions) *embeddingCache {
+	if opts.MaxEntries < 0 {
+		return nil
+	}
+	if opts.MaxEntries == 0 && opts.MaxBytes == 0 {
+		opts.MaxEntries = DefaultEmbeddingCacheEntries
+	}
+	return &embeddingCache{
+		opts:    opts,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the embedding cached for doc
+func (c *embeddingCache) get(doc *Document) (*EmbeddingData, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[doc.ID]
+	if ok {
+		entry := element.Value.(*cachedEmbedding)
+		if entry.doc == doc && (c.opts.TTL == 0 || c.now().Sub(entry.loaded) < c.opts.TTL) {
+			c.order.MoveToFront(element)
+			c.hits++
+			return entry.embedding, true
+		}
+		c.remove(element)
+	}
+	c.misses++
+	return nil, false
+}
+
+// put caches the embedding loaded for doc, evicting the least recently used entries over the bounds
+func (c *embeddingCache) put(doc *Document, embedding *EmbeddingData) {
+	if c == nil {
+		return
+	}
+
+	entry := &cachedEmbedding{
+		id:        doc.ID,
+		doc:       doc,
+		embedding: embedding,
+		size:      embeddingSize(embedding),
+		loaded:    c.now(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[doc.ID]; ok {
+		c.remove(element)
+	}
+	// An embedding larger than the cache would only evict everything else
+	if c.opts.MaxBytes > 0 && entry.size > c.opts.MaxBytes {
+		return
+	}
+	c.entries[doc.ID] = c.order.PushFront(entry)
+	c.bytes += entry.size
+
+	for (c.opts.MaxEntries > 0 && c.order.Len() > c.opts.MaxEntries) || (c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes) {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// invalidate drops the embeddings of ids, after their documents are stored or deleted
+func (c *embeddingCache) invalidate(ids ...string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range ids {
+		if element, ok := c.entries[id]; ok {
+			c.remove(element)
+		}
+	}
+}
+
+// remove drops an entry. Caller must hold the lock
+func (c *embeddingCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*cachedEmbedding)
+	delete(c.entries, entry.id)
+	c.bytes -= entry.size
+}
+
+// stats reports the size, bounds and counters of the cache
+func (c *embeddingCache) stats() EmbeddingCacheStats {
+	if c == nil {
+		return EmbeddingCacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := EmbeddingCacheStats{
+		Enabled:    true,
+		Entries:    c.order.Len(),
+		Bytes:      c.bytes,
+		MaxEntries: c.opts.MaxEntries,
+		MaxBytes:   c.opts.MaxBytes,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+	}
+	if c.opts.TTL > 0 {
+		stats.TTL = c.opts.TTL.String()
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
+
+// embeddingSize estimates the bytes held by an embedding
+func embeddingSize(embedding *EmbeddingData) int64 {
+	size := int64(128 + len(embedding.Model) + 8*len(embedding.Vector))
+	if embedding.Sparse != nil {
+		size += int64(16 * len(embedding.Sparse.Indices))
+	}
+	return size
+}
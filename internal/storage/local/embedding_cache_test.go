@@ -0,0 +1,163 @@
+package local
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestAdapter_EmbeddingCacheInvalidatedOnUpdate(t *testing.T) {
+	adapter, err := NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeVectors(t, adapter, map[string][]float64{"a": {1, 0}, "b": {0, 1}})
+
+	search := func() map[string][]float64 {
+		t.Helper()
+		results, err := adapter.Search(&models.SearchByEmbbedingRequest{Embedding: []float64{1, 1}, TopK: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		embeddings := make(map[string][]float64, len(results))
+		for _, result := range results {
+			embeddings[result.Vector.ID] = result.Vector.Embedding
+		}
+		return embeddings
+	}
+
+	search()
+	search()
+	if stats := adapter.EmbeddingCacheStats(); stats.Misses != 2 || stats.Hits != 2 || stats.Entries != 2 {
+		t.Fatalf("after two searches, stats = %+v", stats)
+	}
+
+	// Storing a vector drops its cached embedding
+	storeVectors(t, adapter, map[string][]float64{"a": {2, 0}})
+	if stats := adapter.EmbeddingCacheStats(); stats.Entries != 1 {
+		t.Errorf("after an update, %d embeddings are cached", stats.Entries)
+	}
+	if got := search()["a"]; got[0] != 2 {
+		t.Errorf("updated vector searched with embedding %v", got)
+	}
+
+	// A write bypassing the adapter replaces the document, which is enough to miss
+	if err := adapter.localStorage.StoreDocument("vectors", vectorDocument(&models.Vector{ID: "b", Embedding: []float64{0, 3}}, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if got := search()["b"]; got[1] != 3 {
+		t.Errorf("vector replaced in storage searched with embedding %v", got)
+	}
+
+	if err := adapter.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := search()["b"]; found || adapter.EmbeddingCacheStats().Entries != 1 {
+		t.Errorf("deleted vector still searched or cached: %+v", adapter.EmbeddingCacheStats())
+	}
+
+	// Neither searches nor gets put the values back in the schema
+	if _, err := adapter.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	collection, _ := adapter.localStorage.GetCollection("vectors")
+	if embedding := collection.Documents["a"].Embedding; embedding.hasValues() || embedding.Path == "" {
+		t.Errorf("stored document holds embedding %+v", embedding)
+	}
+}
+
+func TestEmbeddingCache_Bounds(t *testing.T) {
+	embedding := func(dimension int) *EmbeddingData { return &EmbeddingData{Vector: make([]float64, dimension)} }
+	docs := map[string]*Document{"a": {ID: "a"}, "b": {ID: "b"}, "c": {ID: "c"}}
+
+	// The least recently used embedding is evicted first
+	cache := newEmbeddingCache(EmbeddingCacheOptions{MaxEntries: 2})
+	cache.put(docs["a"], embedding(4))
+	cache.put(docs["b"], embedding(4))
+	cache.get(docs["a"])
+	cache.put(docs["c"], embedding(4))
+	if _, ok := cache.get(docs["b"]); ok {
+		t.Error("least recently used embedding was kept")
+	}
+	if _, ok := cache.get(docs["a"]); !ok {
+		t.Error("recently used embedding was evicted")
+	}
+	if stats := cache.stats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// Byte bounds count the values, and an embedding larger than the cache is not kept
+	cache = newEmbeddingCache(EmbeddingCacheOptions{MaxBytes: 2 * embeddingSize(embedding(100))})
+	for _, id := range []string{"a", "b", "c"} {
+		cache.put(docs[id], embedding(100))
+	}
+	cache.put(docs["c"], embedding(1000))
+	if stats := cache.stats(); stats.Entries != 1 || stats.Bytes > cache.opts.MaxBytes {
+		t.Errorf("byte bounded stats = %+v", stats)
+	}
+
+	// Embeddings expire after the TTL
+	now := time.Now()
+	cache = newEmbeddingCache(EmbeddingCacheOptions{TTL: time.Minute})
+	cache.now = func() time.Time { return now }
+	cache.put(docs["a"], embedding(4))
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.get(docs["a"]); ok || cache.stats().Entries != 0 {
+		t.Error("expired embedding was returned")
+	}
+
+	if cache := newEmbeddingCache(EmbeddingCacheOptions{MaxEntries: -1}); cache != nil || cache.stats().Enabled {
+		t.Error("negative max entries did not disable the cache")
+	}
+}
+
+// BenchmarkAdapter_Search compares searches reading every embedding file with
+// searches served by a warm embedding cache
+// Run with: go test ./internal/storage/local -run '^$' -bench Adapter_Search
+func BenchmarkAdapter_Search(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	embedding := func() []float64 {
+		values := make([]float64, 128)
+		for i := range values {
+			values[i] = rng.Float64()
+		}
+		return values
+	}
+
+	dir := b.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 2000; i++ {
+		if err := adapter.Store(&models.Vector{ID: fmt.Sprintf("v%d", i), Embedding: embedding()}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	adapter.Close()
+	req := &models.SearchByEmbbedingRequest{Embedding: embedding(), TopK: 10}
+
+	for _, run := range []struct {
+		name    string
+		entries int
+	}{{"cold", -1}, {"warm", 0}} {
+		b.Run(run.name, func(b *testing.B) {
+			adapter, err := NewVectorStorageAdapterWithOptions(dir, "vectors", nil, Options{EmbeddingCache: EmbeddingCacheOptions{MaxEntries: run.entries}})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer adapter.Close()
+			adapter.Search(req)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := adapter.Search(req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
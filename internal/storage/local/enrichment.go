@@ -0,0 +1,115 @@
+package local
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/tahcohcat/same-same/internal/storage/enrichment"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// getEnrichmentPath returns the file of an enrichment document, kept outside
+// the collection directory so reconciliation does not mistake it for a document
+func (ls *LocalStorage) getEnrichmentPath(collectionName, key string) (string, error) {
+	if err := ValidateCollectionName(collectionName); err != nil {
+		return "", storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+	return ls.resolvePath(EnrichmentDir, collectionName, encodeID(key)+".json")
+}
+
+// GetEnrichment returns the enrichment documents of a collection for keys,
+// omitting unknown keys
+func (ls *LocalStorage) GetEnrichment(collectionName string, keys []string) (map[string]json.RawMessage, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	if _, exists := ls.schema.Collections[collectionName]; !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	docs := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		path, err := ls.getEnrichmentPath(collectionName, key)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := readEnrichmentFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs[key] = doc
+	}
+	return docs, nil
+}
+
+// SetEnrichment creates or replaces enrichment documents of a collection
+func (ls *LocalStorage) SetEnrichment(collectionName string, entries map[string]json.RawMessage) error {
+	for key, doc := range entries {
+		if err := enrichment.Validate(key, doc); err != nil {
+			return storeerr.Wrap(storeerr.ErrValidation, err)
+		}
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if _, exists := ls.schema.Collections[collectionName]; !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	for key, doc := range entries {
+		path, err := ls.getEnrichmentPath(collectionName, key)
+		if err != nil {
+			return err
+		}
+		if err := ls.writeEnrichmentFile(path, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteEnrichment removes an enrichment document of a collection
+func (ls *LocalStorage) DeleteEnrichment(collectionName, key string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	path, err := ls.getEnrichmentPath(collectionName, key)
+	if err != nil {
+		return err
+	}
+	if _, err := readEnrichmentFile(path); os.IsNotExist(err) {
+		return enrichment.NotFound(key)
+	}
+	removeFile(path)
+	return nil
+}
+
+// writeEnrichmentFile writes doc to path, compressed like the documents
+func (ls *LocalStorage) writeEnrichmentFile(path string, doc json.RawMessage) error {
+	file, err := ls.createFile(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(doc)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readEnrichmentFile reads the enrichment document at path or its compressed variant
+func readEnrichmentFile(path string) (json.RawMessage, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
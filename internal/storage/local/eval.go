@@ -0,0 +1,101 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// EvalSets returns the evaluation sets of a collection
+func (ls *LocalStorage) EvalSets(collectionName string) (eval.Sets, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	sets := make(eval.Sets, len(collection.EvalSets))
+	for name, set := range collection.EvalSets {
+		sets[name] = set
+	}
+	return sets, nil
+}
+
+// CreateEvalSet adds an evaluation set to a collection and persists it,
+// failing with eval.ErrExists if the name is taken
+func (ls *LocalStorage) CreateEvalSet(collectionName string, set eval.Set) error {
+	if err := set.Validate(); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	if _, exists := collection.EvalSets[set.Name]; exists {
+		return fmt.Errorf("%w: %s", eval.ErrExists, set.Name)
+	}
+	if collection.EvalSets == nil {
+		collection.EvalSets = make(eval.Sets)
+	}
+	collection.EvalSets[set.Name] = set
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// DeleteEvalSet removes an evaluation set and its runs from a collection and persists the change
+func (ls *LocalStorage) DeleteEvalSet(collectionName, name string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	if _, ok := collection.EvalSets[name]; !ok {
+		return eval.NotFound(name)
+	}
+	delete(collection.EvalSets, name)
+	collection.EvalRuns = eval.WithoutSet(collection.EvalRuns, name)
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// RecordEvalRun appends an evaluation run to the history of a collection and persists it
+func (ls *LocalStorage) RecordEvalRun(collectionName string, run *eval.Run) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	collection.EvalRuns = eval.AppendRun(collection.EvalRuns, run)
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// ListEvalRuns returns the runs of an evaluation set of a collection, newest first
+func (ls *LocalStorage) ListEvalRuns(collectionName, set string) ([]*eval.Run, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	return eval.RunsOf(collection.EvalRuns, set), nil
+}
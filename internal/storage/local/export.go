@@ -0,0 +1,299 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+const (
+	// ExportFormat identifies collection export files
+	ExportFormat = "same-same-collection"
+
+	// ExportVersion is the current version of the export format
+	ExportVersion = 1
+)
+
+// ExportHeader is the first line of a collection export
+// It is followed by one JSON document per line, each with its embedding inlined
+type ExportHeader struct {
+	Format     string      `json:"format"`
+	Version    int         `json:"version"`
+	Collection *Collection `json:"collection"` // Documents are omitted
+}
+
+// DocumentError records a document that could not be imported
+type DocumentError struct {
+	ID    string `json:"id"`
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	Imported int             `json:"imported"`
+	Failed   int             `json:"failed"`
+	Errors   []DocumentError `json:"errors,omitempty"`
+}
+
+// Export exports collection to a file
+func (ls *LocalStorage) Export(collectionName, outputPath string) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+
+	err = ls.ExportTo(collectionName, file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ExportTo streams a collection to w as a header line followed by one document per line
+// Embeddings stored in separate files are loaded and inlined so the export is self-contained
+func (ls *LocalStorage) ExportTo(collectionName string, w io.Writer) error {
+	ls.mu.RLock()
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		ls.mu.RUnlock()
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	header := *collection
+	header.Documents = nil
+	ids := make([]string, 0, len(collection.Documents))
+	for id := range collection.Documents {
+		ids = append(ids, id)
+	}
+	ls.mu.RUnlock()
+
+	sort.Strings(ids)
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(ExportHeader{Format: ExportFormat, Version: ExportVersion, Collection: &header}); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		ls.mu.RLock()
+		stored, exists := collection.Documents[id]
+		var doc Document
+		if exists {
+			doc = *stored
+		}
+		ls.mu.RUnlock()
+
+		// Deleted since the export started
+		if !exists {
+			continue
+		}
+
+		if doc.Embedding != nil {
+			embedding := *doc.Embedding
+			if !embedding.hasValues() && embedding.Path != "" {
+				loaded, err := ls.loadEmbedding(collectionName, id)
+				if err != nil {
+					return fmt.Errorf("failed to load embedding for %s: %w", id, err)
+				}
+				embedding = *loaded
+			}
+			// Paths are local to this storage and meaningless elsewhere
+			embedding.Path = ""
+			doc.Embedding = &embedding
+		}
+
+		if err := encoder.Encode(&doc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import imports collection from a file
+func (ls *LocalStorage) Import(collectionName, inputPath string) (*ImportReport, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ls.ImportFrom(collectionName, file)
+}
+
+// ImportFrom streams an export from r into collectionName, creating the collection
+// from the export header if needed
+// Each document is validated against the collection dimension and written to its own
+// files; invalid documents are reported without aborting the import
+// Exports written before the streaming format, a single Collection object, are also accepted
+func (ls *LocalStorage) ImportFrom(collectionName string, r io.Reader) (*ImportReport, error) {
+	if err := ValidateCollectionName(collectionName); err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(r)
+
+	var first json.RawMessage
+	if err := decoder.Decode(&first); err != nil {
+		return nil, fmt.Errorf("failed to read export header: %w", err)
+	}
+
+	var header ExportHeader
+	if err := json.Unmarshal(first, &header); err != nil {
+		return nil, fmt.Errorf("failed to read export header: %w", err)
+	}
+
+	var legacy []*Document
+	if header.Format != ExportFormat {
+		var collection Collection
+		if err := json.Unmarshal(first, &collection); err != nil {
+			return nil, fmt.Errorf("unrecognized export format: %w", err)
+		}
+		header.Collection = &collection
+		for _, doc := range collection.Documents {
+			legacy = append(legacy, doc)
+		}
+		sort.Slice(legacy, func(i, j int) bool { return legacy[i].ID < legacy[j].ID })
+	} else if header.Version > ExportVersion {
+		return nil, fmt.Errorf("unsupported export version %d", header.Version)
+	}
+
+	collection, err := ls.importCollection(collectionName, header.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{}
+	fail := func(id string, line int, err error) {
+		report.Failed++
+		report.Errors = append(report.Errors, DocumentError{ID: id, Line: line, Error: err.Error()})
+	}
+
+	if legacy != nil {
+		for i, doc := range legacy {
+			if err := ls.importDocument(collectionName, collection, doc); err != nil {
+				fail(doc.ID, i+1, err)
+				continue
+			}
+			report.Imported++
+		}
+	} else {
+		for line := 2; ; line++ {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				// The stream cannot be resynchronized after a syntax error
+				fail("", line, err)
+				break
+			}
+
+			var doc Document
+			if err := json.Unmarshal(raw, &doc); err != nil {
+				fail("", line, err)
+				continue
+			}
+
+			if err := ls.importDocument(collectionName, collection, &doc); err != nil {
+				fail(doc.ID, line, err)
+				continue
+			}
+			report.Imported++
+		}
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := time.Now()
+	collection.Stats.DocumentCount = len(collection.Documents)
+	collection.Stats.LastUpdated = now
+	collection.UpdatedAt = now
+	collection.Generation++
+
+	// Already holding lock
+	if err := ls.saveSchema(); err != nil {
+		return report, err
+	}
+
+	ls.logger.WithFields(logrus.Fields{
+		"collection": collectionName,
+		"imported":   report.Imported,
+		"failed":     report.Failed,
+	}).Info("imported collection")
+
+	return report, nil
+}
+
+// importCollection returns the collection to import into, creating it from exported if missing
+func (ls *LocalStorage) importCollection(collectionName string, exported *Collection) (*Collection, error) {
+	if collection, err := ls.GetCollection(collectionName); err == nil {
+		return collection, nil
+	}
+
+	description := ""
+	var schema *CollectionSchema
+	if exported != nil {
+		description = exported.Description
+		schema = exported.Schema
+	}
+
+	return ls.CreateCollection(collectionName, description, schema)
+}
+
+// importDocument validates an exported document and writes it to collection
+func (ls *LocalStorage) importDocument(collectionName string, collection *Collection, doc *Document) error {
+	if doc.ID == "" {
+		return storeerr.Validationf("document ID cannot be empty")
+	}
+
+	if doc.Embedding != nil {
+		if !doc.Embedding.hasValues() {
+			return fmt.Errorf("document has no inline embedding")
+		}
+		if doc.Embedding.Sparse != nil {
+			if err := doc.Embedding.Sparse.Validate(); err != nil {
+				return err
+			}
+		}
+		if doc.Embedding.Dimension != 0 && doc.Embedding.Dimension != doc.Embedding.size() {
+			return fmt.Errorf("embedding declares %d dimensions but has %d", doc.Embedding.Dimension, doc.Embedding.size())
+		}
+		doc.Embedding.Dimension = doc.Embedding.size()
+		doc.Embedding.Path = ""
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if doc.Embedding != nil && collection.Schema != nil && collection.Schema.VectorConfig != nil {
+		config := collection.Schema.VectorConfig
+		switch {
+		case config.Dimension == 0:
+			config.Dimension = doc.Embedding.Dimension
+		case config.Dimension != doc.Embedding.Dimension:
+			return storeerr.DimensionMismatchf("dimension mismatch: collection expects %d, got %d", config.Dimension, doc.Embedding.Dimension)
+		}
+	}
+
+	doc.CollectionID = collectionName
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now()
+	}
+	if doc.UpdatedAt.IsZero() {
+		doc.UpdatedAt = doc.CreatedAt
+	}
+
+	collection.invalidateKeyIndex()
+	return ls.putDocument(collectionName, collection, doc)
+}
@@ -0,0 +1,114 @@
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	basePath := filepath.Join(t.TempDir(), "store")
+	exportPath := filepath.Join(t.TempDir(), "vectors.jsonl")
+
+	adapter, err := NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	vectors := make(map[string][]float64)
+	for i := 0; i < 20; i++ {
+		embedding := make([]float64, 1536)
+		for j := range embedding {
+			embedding[j] = rng.Float64() - 0.5
+		}
+		vectors[fmt.Sprintf("doc-%02d", i)] = embedding
+	}
+	storeVectors(t, adapter, vectors)
+
+	if err := adapter.localStorage.Export("vectors", exportPath); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	if err := os.RemoveAll(basePath); err != nil {
+		t.Fatalf("failed to wipe storage: %v", err)
+	}
+
+	ls, err := NewLocalStorage(basePath)
+	if err != nil {
+		t.Fatalf("failed to recreate storage: %v", err)
+	}
+	report, err := ls.Import("vectors", exportPath)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if report.Imported != len(vectors) || report.Failed != 0 {
+		t.Fatalf("expected %d imported and none failed, got %+v", len(vectors), report)
+	}
+
+	restored, err := NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if count := restored.Count(); count != len(vectors) {
+		t.Errorf("expected %d documents after import, got %d", len(vectors), count)
+	}
+
+	results, err := restored.Search(&models.SearchByEmbbedingRequest{Embedding: vectors["doc-07"], TopK: 1})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Vector.ID != "doc-07" {
+		t.Fatalf("expected doc-07 as best match, got %v", results)
+	}
+	if results[0].Score < 0.9999 {
+		t.Errorf("expected exact match score, got %v", results[0].Score)
+	}
+}
+
+func TestImport_ReportsDocumentErrors(t *testing.T) {
+	source, err := NewVectorStorageAdapter(filepath.Join(t.TempDir(), "source"), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	storeVectors(t, source, map[string][]float64{"a": {1, 0}, "b": {0, 1}})
+
+	var export bytes.Buffer
+	if err := source.localStorage.ExportTo("vectors", &export); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	// Append a document with the wrong dimension and one without an ID
+	export.WriteString(`{"id": "c", "embedding": {"vector": [1, 2, 3]}}` + "\n")
+	export.WriteString(`{"embedding": {"vector": [1, 2]}}` + "\n")
+
+	target, err := NewLocalStorage(filepath.Join(t.TempDir(), "target"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	report, err := target.ImportFrom("copy", &export)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	if report.Imported != 2 || report.Failed != 2 {
+		t.Fatalf("expected 2 imported and 2 failed, got %+v", report)
+	}
+	if report.Errors[0].ID != "c" || !strings.Contains(report.Errors[0].Error, "dimension") {
+		t.Errorf("expected dimension error for c, got %+v", report.Errors[0])
+	}
+
+	collection, err := target.GetCollection("copy")
+	if err != nil {
+		t.Fatalf("collection not created: %v", err)
+	}
+	if collection.Stats.DocumentCount != 2 {
+		t.Errorf("expected rebuilt document count 2, got %d", collection.Stats.DocumentCount)
+	}
+}
@@ -0,0 +1,179 @@
+package local
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/tahcohcat/same-same/internal/storage/metaschema"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// savedFields is the file of the field summary of a collection, saved once a
+// field is counted with sketches: rebuilding it from the documents would drop
+// what deletes left in the sketches. It is only valid at Generation
+type savedFields struct {
+	Generation uint64            `json:"generation"`
+	Fields     *metaschema.Index `json:"fields"`
+}
+
+// fieldIndex returns the metadata field summary of a collection, loading the
+// saved one or else building it from the document index the first time it is
+// needed after a load or a failed batch
+// Caller must hold the write lock
+func (ls *LocalStorage) fieldIndex(collectionName string, c *Collection) *metaschema.Index {
+	if c.fields != nil {
+		return c.fields
+	}
+	if saved, err := ls.readFields(collectionName); err == nil && saved.Generation == c.Generation && saved.Fields != nil {
+		c.fields, c.fieldsSaved = saved.Fields, saved.Generation
+		return c.fields
+	} else if err != nil && !os.IsNotExist(err) {
+		ls.logger.WithError(err).WithField("collection", collectionName).Warn("failed to load the saved field summary, rebuilding it")
+	}
+
+	metadata := make([]map[string]string, 0, len(c.Documents))
+	for _, doc := range c.Documents {
+		metadata = append(metadata, convertInterfaceToStringMap(doc.Metadata))
+	}
+	c.fields = metaschema.Build(metadata)
+	return c.fields
+}
+
+// getFieldsPath returns the file of the saved field summary of a collection
+func (ls *LocalStorage) getFieldsPath(collectionName string) (string, error) {
+	if err := ValidateCollectionName(collectionName); err != nil {
+		return "", storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+	return ls.resolvePath(FieldsDir, collectionName+".json")
+}
+
+// readFields reads the saved field summary of a collection
+func (ls *LocalStorage) readFields(collectionName string) (*savedFields, error) {
+	path, err := ls.getFieldsPath(collectionName)
+	if err != nil {
+		return nil, err
+	}
+	file, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	saved := &savedFields{}
+	if err := json.NewDecoder(file).Decode(saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// saveFields saves the field summaries counted with sketches that changed since
+// they were last saved, with the schema they are valid for. A summary that fails
+// to save is rebuilt from the documents on the next load
+// Caller must hold the lock
+func (ls *LocalStorage) saveFields() {
+	for name, c := range ls.schema.Collections {
+		if c.fieldsSaved == c.Generation || !c.fields.Sketched() {
+			continue
+		}
+		if err := ls.writeFields(name, &savedFields{Generation: c.Generation, Fields: c.fields}); err != nil {
+			ls.logger.WithError(err).WithField("collection", name).Warn("failed to save the field summary")
+			continue
+		}
+		c.fieldsSaved = c.Generation
+	}
+}
+
+// writeFields replaces the saved field summary of a collection
+func (ls *LocalStorage) writeFields(collectionName string, saved *savedFields) error {
+	path, err := ls.getFieldsPath(collectionName)
+	if err != nil {
+		return err
+	}
+	file, err := ls.createFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = json.NewEncoder(file).Encode(saved)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// indexFields records a stored document in the field summary, replacing the
+// version old it overwrites, when the summary is built
+// Caller must hold the write lock
+func (c *Collection) indexFields(old, doc *Document) {
+	if c.fields == nil {
+		return
+	}
+	c.unindexFields(old)
+	c.fields.Add(convertInterfaceToStringMap(doc.Metadata))
+}
+
+// unindexFields drops a deleted document from the field summary, when it is built
+// Caller must hold the write lock
+func (c *Collection) unindexFields(doc *Document) {
+	if c.fields == nil || doc == nil {
+		return
+	}
+	c.fields.Remove(convertInterfaceToStringMap(doc.Metadata))
+}
+
+// invalidateFieldIndex drops the field summary so it is rebuilt from the document index
+func (c *Collection) invalidateFieldIndex() {
+	c.fields = nil
+}
+
+// MetadataSchema summarizes the metadata fields of the documents of namespace in a
+// collection, or of all namespaces if namespace is empty. No document file is read
+func (ls *LocalStorage) MetadataSchema(collectionName, namespace string) (*metaschema.Schema, error) {
+	ls.mu.RLock()
+	collection, exists := ls.schema.Collections[collectionName]
+	if exists && collection.fields != nil {
+		defer ls.mu.RUnlock()
+		return collection.fields.Schema(namespace), nil
+	}
+	ls.mu.RUnlock()
+
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.fieldIndex(collectionName, collection).Schema(namespace), nil
+}
+
+// Facet counts the documents of each value of a metadata field in namespace in a
+// collection, or in all namespaces if namespace is empty. No document file is read
+func (ls *LocalStorage) Facet(collectionName, namespace, field string, limit int) (*metaschema.Facet, error) {
+	ls.mu.RLock()
+	collection, exists := ls.schema.Collections[collectionName]
+	if exists && collection.fields != nil {
+		defer ls.mu.RUnlock()
+		return collection.fields.Facet(namespace, field, limit)
+	}
+	ls.mu.RUnlock()
+
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.fieldIndex(collectionName, collection).Facet(namespace, field, limit)
+}
+
+// MetadataSchema summarizes the metadata fields of the vectors of namespace, or of
+// all namespaces if namespace is empty
+func (vsa *VectorStorageAdapter) MetadataSchema(namespace string) (*metaschema.Schema, error) {
+	return vsa.localStorage.MetadataSchema(vsa.collection, namespace)
+}
+
+// Facet counts the vectors of each value of a metadata field in namespace, or in
+// all namespaces if namespace is empty
+func (vsa *VectorStorageAdapter) Facet(namespace, field string, limit int) (*metaschema.Facet, error) {
+	return vsa.localStorage.Facet(vsa.collection, namespace, field, limit)
+}
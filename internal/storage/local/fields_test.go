@@ -0,0 +1,115 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/metaschema"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+func TestMetadataSchema_MaintainedByWrites(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "books")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	vector := func(id, year string) *models.Vector {
+		return &models.Vector{
+			ID:        id,
+			Embedding: []float64{1, 0},
+			Metadata:  map[string]string{models.NamespaceKey: "lib", "year": year},
+		}
+	}
+	schema := func(a *VectorStorageAdapter) *metaschema.Schema {
+		t.Helper()
+		s, err := a.MetadataSchema("lib")
+		if err != nil {
+			t.Fatalf("schema: %v", err)
+		}
+		return s
+	}
+
+	if err := adapter.Store(vector("a", "1999")); err != nil {
+		t.Fatal(err)
+	}
+	// The summary is built from the documents, then kept up to date by writes
+	if year := schema(adapter).Fields["year"]; year.Count != 1 || year.Type != metaschema.TypeNumeric {
+		t.Fatalf("year = %+v", year)
+	}
+	if err := adapter.StoreAll([]*models.Vector{vector("b", "unknown"), vector("c", "2005")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.Store(vector("a", "2001")); err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := schema(adapter)
+	year := got.Fields["year"]
+	if got.Vectors != 2 || year.Count != 2 || year.Type != metaschema.TypeNumeric || !reflect.DeepEqual(year.Examples, []string{"2001", "2005"}) {
+		t.Errorf("after replace and delete = %d vectors, year %+v", got.Vectors, year)
+	}
+	adapter.Close()
+
+	reopened, err := NewVectorStorageAdapter(dir, "books")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if rebuilt := schema(reopened); !reflect.DeepEqual(rebuilt, got) {
+		t.Errorf("rebuilt schema = %+v, want %+v", rebuilt, got)
+	}
+}
+
+func TestFacet_SketchesPersisted(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "events")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	const users = metaschema.MaxDistinct + 50
+	for i := 0; i < users; i++ {
+		vector := &models.Vector{
+			ID:        fmt.Sprint("e", i),
+			Embedding: []float64{1, 0},
+			Metadata:  map[string]string{"user_id": fmt.Sprint("u", i), "kind": "click"},
+		}
+		if err := adapter.Store(vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 40; i++ {
+		if err := adapter.Delete(fmt.Sprint("e", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	facet, err := adapter.Facet("", "user_id", 5)
+	if err != nil {
+		t.Fatalf("facet: %v", err)
+	}
+	if !facet.CountsEstimated || !facet.DistinctEstimated || facet.Count != users-40 {
+		t.Fatalf("facet = %+v", facet)
+	}
+	if kind, err := adapter.Facet("", "kind", 0); err != nil || kind.CountsEstimated || kind.Values[0].Count != users-40 {
+		t.Errorf("exact facet = %+v, %v", kind, err)
+	}
+	if _, err := adapter.Facet("", "missing", 0); !errors.Is(err, storeerr.ErrNotFound) {
+		t.Errorf("facet of a missing field: err = %v, want not found", err)
+	}
+	adapter.Close()
+
+	// The saved sketches are loaded: a rebuild would count the deleted users out
+	reopened, err := NewVectorStorageAdapter(dir, "events")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if loaded, err := reopened.Facet("", "user_id", 5); err != nil || !reflect.DeepEqual(loaded, facet) {
+		t.Errorf("loaded facet = %+v, %v, want %+v", loaded, err, facet)
+	}
+}
@@ -0,0 +1,54 @@
+package local
+
+import (
+	"github.com/tahcohcat/same-same/internal/jobs"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Jobs returns copies of the background jobs of a collection, oldest first, bulk
+// jobs persisted before they ran as jobs included
+func (ls *LocalStorage) Jobs(collectionName string) ([]*jobs.Job, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	list := make([]*jobs.Job, 0, len(collection.BulkJobs)+len(collection.Jobs))
+	for _, legacy := range collection.BulkJobs {
+		job, err := legacy.Record()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, job)
+	}
+	for _, job := range collection.Jobs {
+		list = append(list, job.Copy())
+	}
+	return list, nil
+}
+
+// SaveJob creates or replaces a job of a collection and persists it
+// Saving a legacy bulk job moves it to the jobs of the collection
+func (ls *LocalStorage) SaveJob(collectionName string, job *jobs.Job) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	for i, legacy := range collection.BulkJobs {
+		if legacy.ID == job.ID {
+			collection.BulkJobs = append(collection.BulkJobs[:i], collection.BulkJobs[i+1:]...)
+			break
+		}
+	}
+	collection.Jobs = jobs.Put(collection.Jobs, job.Copy())
+
+	// Already holding lock
+	return ls.saveSchema()
+}
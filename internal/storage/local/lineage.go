@@ -0,0 +1,35 @@
+package local
+
+import (
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// RecordRun appends an ingest run to the history of a collection and persists it
+func (ls *LocalStorage) RecordRun(collectionName string, run *models.IngestRun) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	collection.IngestRuns = append(collection.IngestRuns, run)
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// ListRuns returns the ingest runs of a collection matching filter, newest first
+func (ls *LocalStorage) ListRuns(collectionName string, filter models.IngestRunFilter) ([]*models.IngestRun, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	return models.FilterIngestRuns(collection.IngestRuns, filter), nil
+}
@@ -0,0 +1,139 @@
+package local
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// DocumentKeyCollision is a key collision found in one document
+type DocumentKeyCollision struct {
+	ID string `json:"id"`
+	models.KeyCollision
+}
+
+// KeyNormalizationReport describes the result of NormalizeMetadataKeys
+type KeyNormalizationReport struct {
+	Collection string                 `json:"collection"`
+	DryRun     bool                   `json:"dry_run"`
+	Policy     models.KeyMergePolicy  `json:"policy"`
+	Scanned    int                    `json:"scanned"`
+	Updated    []string               `json:"updated"`    // Documents whose keys were (or would be) rewritten
+	Renamed    map[string]string      `json:"renamed"`    // Original keys and their normalized form
+	Collisions []DocumentKeyCollision `json:"collisions"` // Keys that normalize to the same key within a document
+}
+
+// Conflicts returns the number of collisions whose keys hold different values
+func (r *KeyNormalizationReport) Conflicts() int {
+	conflicts := 0
+	for _, collision := range r.Collisions {
+		if collision.Conflicting {
+			conflicts++
+		}
+	}
+	return conflicts
+}
+
+// NormalizeMetadataKeys rewrites the metadata keys of every document in a
+// collection to lowercase snake_case, merging colliding keys with policy
+// The whole collection is planned first: with KeyMergeError nothing is written
+// when any document has conflicting keys, and the report lists all of them
+func (ls *LocalStorage) NormalizeMetadataKeys(collectionName string, policy models.KeyMergePolicy, dryRun bool) (*KeyNormalizationReport, error) {
+	if policy == "" {
+		policy = models.KeyMergeNormalized
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	report := &KeyNormalizationReport{
+		Collection: collectionName,
+		DryRun:     dryRun,
+		Policy:     policy,
+		Updated:    []string{},
+		Renamed:    make(map[string]string),
+		Collisions: []DocumentKeyCollision{},
+	}
+
+	ids := make([]string, 0, len(collection.Documents))
+	for id := range collection.Documents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Conflicts are resolved with the normalized policy while planning, so that
+	// an error policy can report every conflict instead of stopping at the first
+	planPolicy := policy
+	if planPolicy == models.KeyMergeError {
+		planPolicy = models.KeyMergeNormalized
+	}
+
+	planned := make(map[string]map[string]interface{})
+	for _, id := range ids {
+		doc := collection.Documents[id]
+		report.Scanned++
+
+		metadata, collisions, err := models.NormalizeMetadataKeys(doc.Metadata, planPolicy)
+		if err != nil {
+			return nil, err
+		}
+		for _, collision := range collisions {
+			report.Collisions = append(report.Collisions, DocumentKeyCollision{ID: id, KeyCollision: collision})
+		}
+
+		changed := false
+		for key := range doc.Metadata {
+			if _, kept := metadata[key]; !kept {
+				changed = true
+				if normalized := models.NormalizeKey(key); normalized != key {
+					report.Renamed[key] = normalized
+				}
+			}
+		}
+		if changed {
+			planned[id] = metadata
+			report.Updated = append(report.Updated, id)
+		}
+	}
+
+	if policy == models.KeyMergeError && report.Conflicts() > 0 {
+		return report, fmt.Errorf("%d metadata key conflicts, choose a merge policy to resolve them", report.Conflicts())
+	}
+	if dryRun || len(planned) == 0 {
+		return report, nil
+	}
+
+	now := time.Now()
+	for _, id := range report.Updated {
+		updated := *collection.Documents[id]
+		updated.Metadata = planned[id]
+		updated.Version++
+		updated.UpdatedAt = now
+
+		if err := ls.saveDocument(collectionName, &updated); err != nil {
+			return report, fmt.Errorf("failed to save document %s: %w", id, err)
+		}
+		collection.indexFields(collection.Documents[id], &updated)
+		collection.Documents[id] = &updated
+		collection.indexRecent(&updated)
+	}
+
+	collection.invalidateKeyIndex()
+	collection.Stats.LastUpdated = now
+	collection.UpdatedAt = now
+	collection.Generation++
+
+	// Already holding lock
+	return report, ls.saveSchema()
+}
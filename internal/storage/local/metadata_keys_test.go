@@ -0,0 +1,109 @@
+package local
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func newKeyNormalizationStorage(t *testing.T) (string, *LocalStorage) {
+	t.Helper()
+	dir := t.TempDir()
+	ls, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if _, err := ls.CreateCollection("quotes", "", nil); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	docs := map[string]map[string]interface{}{
+		"clean":    {"author": "Einstein"},
+		"renamed":  {"Author": "Newton", "createdAt": "1687"},
+		"merged":   {"Genre": "physics", "genre": "physics"},
+		"conflict": {"Author": "Curie", "author": "Marie Curie"},
+	}
+	for id, metadata := range docs {
+		if err := ls.StoreDocument("quotes", &Document{ID: id, Type: TypeText, Metadata: metadata}); err != nil {
+			t.Fatalf("failed to store %s: %v", id, err)
+		}
+	}
+	return dir, ls
+}
+
+func TestNormalizeMetadataKeys_ErrorPolicyWritesNothing(t *testing.T) {
+	_, ls := newKeyNormalizationStorage(t)
+
+	report, err := ls.NormalizeMetadataKeys("quotes", models.KeyMergeError, false)
+	if err == nil {
+		t.Fatal("expected an error for conflicting keys")
+	}
+	if report == nil || report.Conflicts() != 1 || report.Collisions[0].ID != "conflict" {
+		t.Fatalf("report = %+v", report)
+	}
+
+	doc, err := ls.GetDocument("quotes", "renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.Metadata["Author"]; !ok {
+		t.Errorf("document was rewritten despite the conflict: %v", doc.Metadata)
+	}
+}
+
+func TestNormalizeMetadataKeys_DryRun(t *testing.T) {
+	_, ls := newKeyNormalizationStorage(t)
+
+	report, err := ls.NormalizeMetadataKeys("quotes", models.KeyMergeNormalized, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if want := []string{"conflict", "merged", "renamed"}; !reflect.DeepEqual(report.Updated, want) {
+		t.Errorf("updated = %v, want %v", report.Updated, want)
+	}
+	if want := map[string]string{"Author": "author", "Genre": "genre", "createdAt": "created_at"}; !reflect.DeepEqual(report.Renamed, want) {
+		t.Errorf("renamed = %v, want %v", report.Renamed, want)
+	}
+	if len(report.Collisions) != 2 || report.Conflicts() != 1 {
+		t.Errorf("collisions = %+v", report.Collisions)
+	}
+
+	doc, _ := ls.GetDocument("quotes", "renamed")
+	if _, ok := doc.Metadata["Author"]; !ok {
+		t.Errorf("dry run rewrote the document: %v", doc.Metadata)
+	}
+}
+
+func TestNormalizeMetadataKeys_Persists(t *testing.T) {
+	dir, ls := newKeyNormalizationStorage(t)
+	before, _ := ls.CollectionGeneration("quotes")
+
+	if _, err := ls.NormalizeMetadataKeys("quotes", models.KeyMergeNormalized, false); err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if after, _ := ls.CollectionGeneration("quotes"); after <= before {
+		t.Errorf("generation not bumped: %d -> %d", before, after)
+	}
+	ls.Close()
+
+	reopened, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen storage: %v", err)
+	}
+	want := map[string]map[string]interface{}{
+		"clean":    {"author": "Einstein"},
+		"renamed":  {"author": "Newton", "created_at": "1687"},
+		"merged":   {"genre": "physics"},
+		"conflict": {"author": "Marie Curie"},
+	}
+	for id, metadata := range want {
+		doc, err := reopened.GetDocument("quotes", id)
+		if err != nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+		if !reflect.DeepEqual(doc.Metadata, metadata) {
+			t.Errorf("%s metadata = %v, want %v", id, doc.Metadata, metadata)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package local
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+const (
+	// MaxCollectionNameLength is the longest accepted collection name
+	MaxCollectionNameLength = 128
+
+	// maxFileNameLength keeps encoded names well below common filesystem limits
+	maxFileNameLength = 200
+
+	// encodedPrefix marks file names holding a base64 encoded document ID,
+	// hashedPrefix marks names holding the SHA-256 of an ID too long to encode
+	// Neither character can appear in a plain file name or base64 output
+	encodedPrefix = "~"
+	hashedPrefix  = "~="
+)
+
+// ValidateCollectionName rejects collection names that are unsafe to use as a directory name
+// Names may only contain letters, digits, '_', '-' and '.', and may not start with '.'
+func ValidateCollectionName(name string) error {
+	if name == "" {
+		return fmt.Errorf("collection name cannot be empty")
+	}
+	if len(name) > MaxCollectionNameLength {
+		return fmt.Errorf("collection name exceeds %d characters", MaxCollectionNameLength)
+	}
+	if name[0] == '.' {
+		return fmt.Errorf("invalid collection name %q: cannot start with '.'", name)
+	}
+	if !isPlainName(name) {
+		return fmt.Errorf("invalid collection name %q: only letters, digits, '_', '-' and '.' are allowed", name)
+	}
+	return nil
+}
+
+// isPlainName reports whether name only holds characters that are safe in a file name
+func isPlainName(name string) bool {
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// encodeID converts a document ID into a filesystem-safe file name
+// Plain IDs are used unchanged so existing stores keep their layout, any other
+// ID is URL-safe base64 encoded (or hashed when too long) so that IDs with
+// separators, dots or control characters still work without escaping the collection
+func encodeID(id string) string {
+	if id != "" && id[0] != '.' && len(id) <= maxFileNameLength && isPlainName(id) {
+		return id
+	}
+
+	encoded := encodedPrefix + base64.RawURLEncoding.EncodeToString([]byte(id))
+	if len(encoded) <= maxFileNameLength {
+		return encoded
+	}
+
+	sum := sha256.Sum256([]byte(id))
+	return hashedPrefix + hex.EncodeToString(sum[:])
+}
+
+// resolvePath joins elem onto the storage base path and verifies the
+// cleaned result is still inside it before it is used for any file operation
+func (ls *LocalStorage) resolvePath(elem ...string) (string, error) {
+	base := filepath.Clean(ls.basePath)
+	path := filepath.Join(append([]string{base}, elem...)...)
+
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("path %q escapes storage directory", filepath.Join(elem...))
+	}
+
+	return path, nil
+}
+
+// Path helpers
+func (ls *LocalStorage) getDocumentPath(collectionName, docID string) (string, error) {
+	if err := ValidateCollectionName(collectionName); err != nil {
+		return "", storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+	return ls.resolvePath(CollectionsDir, collectionName, encodeID(docID)+".json")
+}
+
+func (ls *LocalStorage) getEmbeddingPath(collectionName, docID string) (string, error) {
+	if err := ValidateCollectionName(collectionName); err != nil {
+		return "", storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+	return ls.resolvePath(EmbeddingsDir, collectionName, encodeID(docID)+".json")
+}
+
+func (ls *LocalStorage) getContentPath(collectionName, docID, contentType string) (string, error) {
+	if err := ValidateCollectionName(collectionName); err != nil {
+		return "", storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+	return ls.resolvePath(ContentDir, collectionName, encodeID(docID), contentType)
+}
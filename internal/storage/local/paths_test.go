@@ -0,0 +1,143 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+var traversalIDs = []string{
+	"../../etc/cron.d/evil",
+	"../escape",
+	"..",
+	".hidden",
+	"/etc/passwd",
+	`..\..\windows\evil`,
+	"nested/dir/id",
+	"id\x00with-nul",
+	"line\nbreak",
+	strings.Repeat("../", 200) + "deep",
+}
+
+// assertContained fails if anything other than the storage directory was created under root
+func assertContained(t *testing.T, root string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", root, err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "store" {
+			t.Errorf("file %q was written outside the storage directory", entry.Name())
+		}
+	}
+}
+
+func TestAdapter_TraversalIDsStayInsideBasePath(t *testing.T) {
+	root := t.TempDir()
+	basePath := filepath.Join(root, "store")
+
+	adapter, err := NewVectorStorageAdapter(basePath, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	for _, id := range traversalIDs {
+		vector := &models.Vector{
+			ID:        id,
+			Embedding: []float64{1, 0, 0},
+			Metadata:  map[string]string{"text": "payload"},
+		}
+		if err := adapter.Store(vector); err != nil {
+			t.Errorf("store %q: %v", id, err)
+			continue
+		}
+
+		got, err := adapter.Get(id)
+		if err != nil {
+			t.Errorf("get %q: %v", id, err)
+			continue
+		}
+		if got.ID != id || len(got.Embedding) != 3 {
+			t.Errorf("get %q returned %q with %d dimensions", id, got.ID, len(got.Embedding))
+		}
+	}
+
+	assertContained(t, root)
+
+	// Every stored file must live directly in the collection directories
+	err = filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(basePath, path)
+		if depth := len(strings.Split(rel, string(filepath.Separator))); rel != MetadataFile && depth != 3 {
+			t.Errorf("unexpected file location %q", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk storage: %v", err)
+	}
+
+	for _, id := range traversalIDs {
+		if err := adapter.Delete(id); err != nil {
+			t.Errorf("delete %q: %v", id, err)
+		}
+	}
+	if count := adapter.Count(); count != 0 {
+		t.Errorf("expected empty collection after deletes, got %d", count)
+	}
+}
+
+func TestAdapter_RejectsUnsafeCollectionNames(t *testing.T) {
+	names := []string{"../outside", "..", "a/b", `a\b`, "/abs", ".hidden", "tab\tname", ""}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			if _, err := NewVectorStorageAdapter(filepath.Join(root, "store"), name); err == nil {
+				t.Errorf("expected collection name %q to be rejected", name)
+			}
+			assertContained(t, root)
+		})
+	}
+}
+
+func TestEncodeID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"quote_123", "quote_123"},
+		{"3f2a-uuid.v1", "3f2a-uuid.v1"},
+		{"../x", "~Li4veA"},
+		{"a/b", "~YS9i"},
+	}
+
+	for _, tt := range tests {
+		if got := encodeID(tt.id); got != tt.want {
+			t.Errorf("encodeID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("x/", 200)
+	if got := encodeID(long); !strings.HasPrefix(got, hashedPrefix) || len(got) > maxFileNameLength {
+		t.Errorf("expected long ID to be hashed, got %q", got)
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	ls := &LocalStorage{basePath: t.TempDir()}
+
+	if _, err := ls.resolvePath(CollectionsDir, "..", "..", "evil"); err == nil {
+		t.Error("expected path escaping the base path to be rejected")
+	}
+	if _, err := ls.resolvePath(CollectionsDir, "vectors", "ok.json"); err != nil {
+		t.Errorf("unexpected error for contained path: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package local
+
+import (
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Profiles returns the ranking profiles of a collection
+func (ls *LocalStorage) Profiles(collectionName string) (profile.Profiles, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	profiles := make(profile.Profiles, len(collection.Profiles))
+	for name, p := range collection.Profiles {
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// SetProfile creates or replaces a ranking profile of a collection and persists it
+func (ls *LocalStorage) SetProfile(collectionName string, p profile.Profile) error {
+	if err := p.Validate(); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	if collection.Profiles == nil {
+		collection.Profiles = make(profile.Profiles)
+	}
+	collection.Profiles[p.Name] = p
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// DeleteProfile removes a ranking profile of a collection and persists the change
+func (ls *LocalStorage) DeleteProfile(collectionName, name string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	if _, ok := collection.Profiles[name]; !ok {
+		return profile.NotFound(name)
+	}
+	delete(collection.Profiles, name)
+
+	// Already holding lock
+	return ls.saveSchema()
+}
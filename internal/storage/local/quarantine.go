@@ -0,0 +1,129 @@
+package local
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// getQuarantinePath returns the file of the quarantine of a collection, kept
+// outside the collection directory so reconciliation does not mistake it for a document
+func (ls *LocalStorage) getQuarantinePath(collectionName string) (string, error) {
+	if err := ValidateCollectionName(collectionName); err != nil {
+		return "", storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+	return ls.resolvePath(QuarantineDir, collectionName+".json")
+}
+
+// readQuarantine reads the quarantine of a collection, empty when it has none
+// The caller must hold the lock
+func (ls *LocalStorage) readQuarantine(collectionName string) (*quarantine.Queue, error) {
+	if _, exists := ls.schema.Collections[collectionName]; !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+	path, err := ls.getQuarantinePath(collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := &quarantine.Queue{}
+	file, err := openFile(path)
+	if os.IsNotExist(err) {
+		return queue, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(queue); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// writeQuarantine replaces the quarantine of a collection
+// The caller must hold the lock
+func (ls *LocalStorage) writeQuarantine(collectionName string, queue *quarantine.Queue) error {
+	path, err := ls.getQuarantinePath(collectionName)
+	if err != nil {
+		return err
+	}
+	file, err := ls.createFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = json.NewEncoder(file).Encode(queue)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// updateQuarantine applies update to the quarantine of a collection and writes it back
+func (ls *LocalStorage) updateQuarantine(collectionName string, update func(queue *quarantine.Queue) error) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	queue, err := ls.readQuarantine(collectionName)
+	if err != nil {
+		return err
+	}
+	if err := update(queue); err != nil {
+		return err
+	}
+	return ls.writeQuarantine(collectionName, queue)
+}
+
+// Quarantine keeps records of a collection that could not be stored, evicting
+// the oldest past the limit
+func (ls *LocalStorage) Quarantine(collectionName string, entries ...quarantine.Entry) error {
+	return ls.updateQuarantine(collectionName, func(queue *quarantine.Queue) error {
+		queue.Add(entries...)
+		return nil
+	})
+}
+
+// Quarantined returns the quarantined records of a collection, oldest first
+func (ls *LocalStorage) Quarantined(collectionName string) ([]quarantine.Entry, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	queue, err := ls.readQuarantine(collectionName)
+	if err != nil {
+		return nil, err
+	}
+	return queue.List(), nil
+}
+
+// RemoveQuarantined drops quarantined records of a collection
+func (ls *LocalStorage) RemoveQuarantined(collectionName string, ids ...string) error {
+	return ls.updateQuarantine(collectionName, func(queue *quarantine.Queue) error {
+		return queue.Remove(ids...)
+	})
+}
+
+// QuarantineStats returns the size and limit of the quarantine of a collection
+func (ls *LocalStorage) QuarantineStats(collectionName string) (quarantine.Stats, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	queue, err := ls.readQuarantine(collectionName)
+	if err != nil {
+		return quarantine.Stats{}, err
+	}
+	return queue.Stats(), nil
+}
+
+// SetQuarantineLimit caps the quarantined records kept for a collection
+func (ls *LocalStorage) SetQuarantineLimit(collectionName string, max int) error {
+	return ls.updateQuarantine(collectionName, func(queue *quarantine.Queue) error {
+		if err := queue.SetLimit(max); err != nil {
+			return storeerr.Wrap(storeerr.ErrValidation, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,115 @@
+package local
+
+import (
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// documentUsage returns the namespace a document is counted against and its usage
+func documentUsage(doc *Document) (string, quota.Usage) {
+	namespace, _ := doc.Metadata[models.NamespaceKey].(string)
+
+	usage := quota.Usage{Vectors: 1}
+	if doc.Embedding != nil && doc.Embedding.Sparse != nil {
+		// Sparse embeddings store an index alongside every value
+		usage.Bytes = int64(len(doc.Embedding.Sparse.Values)) * 2 * quota.EmbeddingValueSize
+	} else if doc.Embedding != nil {
+		dimension := len(doc.Embedding.Vector)
+		if dimension == 0 {
+			dimension = doc.Embedding.Dimension
+		}
+		usage.Bytes = int64(dimension) * quota.EmbeddingValueSize
+	}
+	return namespace, usage
+}
+
+// collectionUsage sums the usage of every document in a collection per namespace
+// Caller must hold the lock
+func collectionUsage(collection *Collection) map[string]quota.Usage {
+	usage := make(map[string]quota.Usage)
+	for _, doc := range collection.Documents {
+		namespace, used := documentUsage(doc)
+		usage[namespace] = usage[namespace].Add(used)
+	}
+	return usage
+}
+
+// checkQuota returns a quota error if storing docs takes a namespace over the collection limit
+// Usage is recomputed from the collection index, which is cheap next to rewriting the schema
+// on every store, so it cannot drift from files added by import or reconcile
+// Caller must hold the lock
+func checkQuota(collection *Collection, docs ...*Document) error {
+	if len(collection.Quotas) == 0 {
+		return nil
+	}
+
+	delta := make(map[string]quota.Usage)
+	for _, doc := range docs {
+		if old, exists := collection.Documents[doc.ID]; exists {
+			namespace, used := documentUsage(old)
+			delta[namespace] = delta[namespace].Sub(used)
+		}
+		namespace, used := documentUsage(doc)
+		delta[namespace] = delta[namespace].Add(used)
+	}
+
+	return collection.Quotas.Check(collectionUsage(collection), delta)
+}
+
+// Quotas returns the namespace limits of a collection
+func (ls *LocalStorage) Quotas(collectionName string) (quota.Limits, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	limits := make(quota.Limits, len(collection.Quotas))
+	for namespace, limit := range collection.Quotas {
+		limits[namespace] = limit
+	}
+	return limits, nil
+}
+
+// SetQuota sets the limit of a namespace in a collection and persists it, a zero limit removes it
+func (ls *LocalStorage) SetQuota(collectionName, namespace string, limit quota.Limit) error {
+	if err := limit.Validate(); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	if limit.IsZero() {
+		delete(collection.Quotas, namespace)
+	} else {
+		if collection.Quotas == nil {
+			collection.Quotas = make(quota.Limits)
+		}
+		collection.Quotas[namespace] = limit
+	}
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// QuotaUsage returns the vectors and embedding bytes stored per namespace in a collection
+func (ls *LocalStorage) QuotaUsage(collectionName string) (map[string]quota.Usage, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	return collectionUsage(collection), nil
+}
@@ -0,0 +1,96 @@
+package local
+
+import (
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/recency"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// documentEntry returns the recency index entry of a document
+func documentEntry(doc *Document) recency.Entry {
+	namespace, _ := doc.Metadata[models.NamespaceKey].(string)
+	return recency.Entry{ID: doc.ID, Namespace: namespace, CreatedAt: doc.CreatedAt}
+}
+
+// recentIndex returns the recency index of a collection, building it from the
+// document index the first time it is needed after a load or a failed batch
+// Caller must hold the write lock
+func (c *Collection) recentIndex() *recency.Index {
+	if c.recent != nil {
+		return c.recent
+	}
+
+	entries := make([]recency.Entry, 0, len(c.Documents))
+	for _, doc := range c.Documents {
+		entries = append(entries, documentEntry(doc))
+	}
+	c.recent = recency.Build(entries)
+	return c.recent
+}
+
+// indexRecent records a stored document in the recency index, when it is built
+// Caller must hold the write lock
+func (c *Collection) indexRecent(doc *Document) {
+	c.recent.Add(documentEntry(doc))
+}
+
+// unindexRecent drops a deleted document from the recency index, when it is built
+// Caller must hold the write lock
+func (c *Collection) unindexRecent(id string) {
+	c.recent.Remove(id)
+}
+
+// invalidateRecentIndex drops the recency index so it is rebuilt from the document index
+func (c *Collection) invalidateRecentIndex() {
+	c.recent = nil
+}
+
+// RecentDocuments returns the newest documents of namespace in a collection, newest
+// first, or of all namespaces if namespace is empty. No document file is read
+func (ls *LocalStorage) RecentDocuments(collectionName, namespace string, limit int) ([]*Document, error) {
+	ls.mu.RLock()
+	collection, exists := ls.schema.Collections[collectionName]
+	if exists && collection.recent != nil {
+		defer ls.mu.RUnlock()
+		return recentDocuments(collection, namespace, limit), nil
+	}
+	ls.mu.RUnlock()
+
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	collection.recentIndex()
+	return recentDocuments(collection, namespace, limit), nil
+}
+
+// recentDocuments returns the documents of the newest entries of the recency index
+// Caller must hold the lock and have built the index
+func recentDocuments(collection *Collection, namespace string, limit int) []*Document {
+	ids := collection.recent.Newest(namespace, limit)
+	docs := make([]*Document, 0, len(ids))
+	for _, id := range ids {
+		if doc, ok := collection.Documents[id]; ok {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// Recent returns the newest vectors of namespace, newest first, or of all
+// namespaces if namespace is empty
+func (vsa *VectorStorageAdapter) Recent(namespace string, limit int) ([]*models.Vector, error) {
+	docs, err := vsa.localStorage.RecentDocuments(vsa.collection, namespace, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([]*models.Vector, 0, len(docs))
+	for _, doc := range docs {
+		vector, _ := vsa.documentVector(doc)
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
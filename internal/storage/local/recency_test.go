@@ -0,0 +1,75 @@
+package local
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestRecent_OrderAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "feed")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	vector := func(id, namespace string, minutes int) *models.Vector {
+		return &models.Vector{
+			ID:        id,
+			Embedding: []float64{1, 0},
+			Metadata:  map[string]string{models.NamespaceKey: namespace},
+			CreatedAt: start.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+	recent := func(a *VectorStorageAdapter, namespace string, limit int) []string {
+		t.Helper()
+		vectors, err := a.Recent(namespace, limit)
+		if err != nil {
+			t.Fatalf("recent: %v", err)
+		}
+		ids := make([]string, len(vectors))
+		for i, v := range vectors {
+			ids[i] = v.ID
+			if len(v.Embedding) != 2 {
+				t.Errorf("%s: embedding not loaded", v.ID)
+			}
+		}
+		return ids
+	}
+
+	for _, v := range []*models.Vector{vector("a", "x", 1), vector("b", "y", 3)} {
+		if err := adapter.Store(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The index is built from the documents, then kept up to date by writes
+	if got, want := recent(adapter, "", 10), []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("recent = %v, want %v", got, want)
+	}
+	if err := adapter.StoreAll([]*models.Vector{vector("c", "x", 2), vector("d", "x", 4)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recent(adapter, "", 10), []string{"d", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after batch and delete = %v, want %v", got, want)
+	}
+	if got, want := recent(adapter, "x", 2), []string{"d", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recent in x = %v, want %v", got, want)
+	}
+	adapter.Close()
+
+	reopened, err := NewVectorStorageAdapter(dir, "feed")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if got, want := recent(reopened, "", 10), []string{"d", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after reopen = %v, want %v", got, want)
+	}
+	if got := recent(reopened, "y", 10); len(got) != 0 {
+		t.Errorf("recent in y = %v, want none", got)
+	}
+}
@@ -0,0 +1,448 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// DefaultReconcileBatchSize is the number of schema changes applied per lock acquisition
+const DefaultReconcileBatchSize = 100
+
+// ReconcileOptions configures a reconcile run
+type ReconcileOptions struct {
+	Collections []string `json:"collections,omitempty"` // Collections to reconcile, every collection on disk or in the schema when empty
+	Prune       bool     `json:"prune"`                 // Remove schema entries whose document file is missing
+	DryRun      bool     `json:"dry_run"`               // Report the differences without changing the schema
+	BatchSize   int      `json:"batch_size,omitempty"`
+}
+
+// ReconcileIssue is a file or schema entry reconcile could not resolve
+type ReconcileIssue struct {
+	ID     string `json:"id,omitempty"`
+	File   string `json:"file,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// CollectionReconcile is the reconcile summary of one collection
+type CollectionReconcile struct {
+	Collection    string           `json:"collection"`
+	Created       bool             `json:"created,omitempty"` // Directory found on disk without a schema entry
+	Scanned       int              `json:"scanned"`
+	Added         []string         `json:"added"`
+	Missing       []string         `json:"missing"` // Schema entries whose document file is gone
+	Pruned        []string         `json:"pruned"`
+	Conflicts     []ReconcileIssue `json:"conflicts"`
+	DocumentCount int              `json:"document_count"`
+}
+
+// ReconcileReport summarizes a reconcile run
+type ReconcileReport struct {
+	DryRun      bool                   `json:"dry_run"`
+	Collections []*CollectionReconcile `json:"collections"`
+	Added       int                    `json:"added"`
+	Missing     int                    `json:"missing"`
+	Pruned      int                    `json:"pruned"`
+	Conflicts   int                    `json:"conflicts"`
+	Duration    string                 `json:"duration"`
+}
+
+// reconcilePlan is the outcome of scanning one collection
+type reconcilePlan struct {
+	report    *CollectionReconcile
+	add       []*Document
+	totalSize int64
+}
+
+// Reconcile re-scans the collections and embeddings directories and brings the
+// schema in line with them: documents found on disk are registered, entries
+// whose document file is missing are reported (and removed with Prune), and the
+// collection stats are recomputed
+// Files are read without holding the lock, changes are applied in batches so
+// readers and writers of a live store are only blocked briefly
+func (ls *LocalStorage) Reconcile(opts ReconcileOptions) (*ReconcileReport, error) {
+	start := time.Now()
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultReconcileBatchSize
+	}
+
+	names, err := ls.reconcileCollectionNames(opts.Collections)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconcileReport{DryRun: opts.DryRun, Collections: make([]*CollectionReconcile, 0, len(names))}
+	for _, name := range names {
+		plan, err := ls.scanCollection(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !opts.DryRun {
+			if err := ls.applyReconcile(name, plan, opts); err != nil {
+				return nil, err
+			}
+		}
+
+		collection := plan.report
+		report.Collections = append(report.Collections, collection)
+		report.Added += len(collection.Added)
+		report.Missing += len(collection.Missing)
+		report.Pruned += len(collection.Pruned)
+		report.Conflicts += len(collection.Conflicts)
+	}
+	report.Duration = time.Since(start).String()
+
+	ls.logger.WithFields(logrus.Fields{
+		"collections": len(report.Collections),
+		"added":       report.Added,
+		"missing":     report.Missing,
+		"pruned":      report.Pruned,
+		"conflicts":   report.Conflicts,
+		"dry_run":     opts.DryRun,
+	}).Info("reconciled storage")
+
+	return report, nil
+}
+
+// reconcileCollectionNames returns the requested collections, or every
+// collection of the schema and the collections directory
+func (ls *LocalStorage) reconcileCollectionNames(requested []string) ([]string, error) {
+	for _, name := range requested {
+		if err := ValidateCollectionName(name); err != nil {
+			return nil, err
+		}
+	}
+	if len(requested) > 0 {
+		return requested, nil
+	}
+
+	seen := make(map[string]bool)
+	ls.mu.RLock()
+	for name := range ls.schema.Collections {
+		seen[name] = true
+	}
+	ls.mu.RUnlock()
+
+	dir, err := ls.resolvePath(CollectionsDir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateCollectionName(entry.Name()) == nil {
+			seen[entry.Name()] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// scanCollection compares the files of a collection with its schema entries
+// Only a snapshot of the schema IDs is taken under the lock
+func (ls *LocalStorage) scanCollection(name string) (*reconcilePlan, error) {
+	plan := &reconcilePlan{report: &CollectionReconcile{
+		Collection: name,
+		Added:      []string{},
+		Missing:    []string{},
+		Pruned:     []string{},
+		Conflicts:  []ReconcileIssue{},
+	}}
+	report := plan.report
+
+	known := make(map[string]bool)
+	dimension := 0
+	ls.mu.RLock()
+	collection, exists := ls.schema.Collections[name]
+	if exists {
+		for id := range collection.Documents {
+			known[id] = true
+		}
+		if collection.Schema != nil && collection.Schema.VectorConfig != nil {
+			dimension = collection.Schema.VectorConfig.Dimension
+		}
+	}
+	ls.mu.RUnlock()
+	report.Created = !exists
+
+	docDir, err := ls.resolvePath(CollectionsDir, name)
+	if err != nil {
+		return nil, err
+	}
+	docFiles, err := listDataFiles(docDir)
+	if err != nil {
+		return nil, err
+	}
+	embDir, err := ls.resolvePath(EmbeddingsDir, name)
+	if err != nil {
+		return nil, err
+	}
+	embFiles, err := listDataFiles(embDir)
+	if err != nil {
+		return nil, err
+	}
+
+	onDisk := make(map[string]bool)
+	for base, size := range docFiles {
+		report.Scanned++
+		plan.totalSize += size + embFiles[base]
+
+		file := base + ".json"
+		doc, err := readDocumentFile(docDir, file)
+		if err != nil {
+			report.Conflicts = append(report.Conflicts, ReconcileIssue{File: file, Reason: err.Error()})
+			continue
+		}
+		if doc.ID == "" || encodeID(doc.ID) != base {
+			report.Conflicts = append(report.Conflicts, ReconcileIssue{ID: doc.ID, File: file, Reason: "file name does not match document ID"})
+			continue
+		}
+		onDisk[doc.ID] = true
+
+		_, hasEmbedding := embFiles[base]
+		separate := doc.Embedding != nil && !doc.Embedding.hasValues() && doc.Embedding.Path != ""
+		if separate && !hasEmbedding {
+			report.Conflicts = append(report.Conflicts, ReconcileIssue{ID: doc.ID, File: file, Reason: "embedding file missing"})
+			continue
+		}
+
+		if known[doc.ID] {
+			continue
+		}
+
+		if doc.CollectionID != "" && doc.CollectionID != name {
+			report.Conflicts = append(report.Conflicts, ReconcileIssue{ID: doc.ID, File: file, Reason: fmt.Sprintf("document belongs to collection %s", doc.CollectionID)})
+			continue
+		}
+		if doc.Embedding != nil && dimension > 0 && doc.Embedding.Dimension > 0 && doc.Embedding.Dimension != dimension {
+			report.Conflicts = append(report.Conflicts, ReconcileIssue{ID: doc.ID, File: file, Reason: fmt.Sprintf("dimension %d does not match collection dimension %d", doc.Embedding.Dimension, dimension)})
+			continue
+		}
+
+		plan.add = append(plan.add, doc)
+	}
+
+	for base := range embFiles {
+		if _, ok := docFiles[base]; !ok {
+			report.Conflicts = append(report.Conflicts, ReconcileIssue{File: base + ".json", Reason: "embedding file without document"})
+		}
+	}
+
+	for id := range known {
+		if !onDisk[id] {
+			report.Missing = append(report.Missing, id)
+		}
+	}
+
+	sort.Slice(plan.add, func(i, j int) bool { return plan.add[i].ID < plan.add[j].ID })
+	sort.Strings(report.Missing)
+	sort.Slice(report.Conflicts, func(i, j int) bool { return report.Conflicts[i].File < report.Conflicts[j].File })
+
+	// A dry run reports what would change
+	for _, doc := range plan.add {
+		report.Added = append(report.Added, doc.ID)
+	}
+	report.DocumentCount = len(known) + len(plan.add)
+
+	return plan, nil
+}
+
+// applyReconcile registers the scanned documents and prunes missing entries in batches
+// Each entry is re-checked under the lock, so changes made since the scan win
+func (ls *LocalStorage) applyReconcile(name string, plan *reconcilePlan, opts ReconcileOptions) error {
+	report := plan.report
+
+	if report.Created {
+		if _, err := ls.CreateCollection(name, "Registered by reconcile", nil); err != nil {
+			return err
+		}
+	}
+
+	report.Added = []string{}
+	for i := 0; i < len(plan.add); i += opts.BatchSize {
+		batch := plan.add[i:min(i+opts.BatchSize, len(plan.add))]
+		added, err := ls.registerDocuments(name, batch)
+		if err != nil {
+			return err
+		}
+		report.Added = append(report.Added, added...)
+	}
+
+	if opts.Prune {
+		for i := 0; i < len(report.Missing); i += opts.BatchSize {
+			batch := report.Missing[i:min(i+opts.BatchSize, len(report.Missing))]
+			pruned, err := ls.pruneDocuments(name, batch)
+			if err != nil {
+				return err
+			}
+			report.Pruned = append(report.Pruned, pruned...)
+		}
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[name]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", name)
+	}
+	now := time.Now()
+	collection.Stats.DocumentCount = len(collection.Documents)
+	collection.Stats.TotalSize = plan.totalSize
+	collection.Stats.LastUpdated = now
+	if len(report.Added) > 0 || len(report.Pruned) > 0 {
+		collection.Generation++
+	}
+	report.DocumentCount = collection.Stats.DocumentCount
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// registerDocuments adds docs missing from the schema of a collection
+func (ls *LocalStorage) registerDocuments(name string, docs []*Document) ([]string, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[name]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", name)
+	}
+
+	collection.invalidateKeyIndex()
+	added := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if _, exists := collection.Documents[doc.ID]; exists {
+			continue
+		}
+		doc.CollectionID = name
+
+		// Inline embeddings are moved to their own file like stored documents
+		if doc.Embedding != nil && doc.Embedding.hasValues() {
+			if err := ls.putDocument(name, collection, doc); err != nil {
+				return added, fmt.Errorf("failed to register %s: %w", doc.ID, err)
+			}
+		} else {
+			if doc.Embedding != nil {
+				embPath, err := ls.getEmbeddingPath(name, doc.ID)
+				if err != nil {
+					return added, err
+				}
+				doc.Embedding.Path = embPath
+			}
+			collection.indexFields(collection.Documents[doc.ID], doc)
+			collection.Documents[doc.ID] = doc
+			collection.indexRecent(doc)
+		}
+		added = append(added, doc.ID)
+	}
+
+	return added, nil
+}
+
+// pruneDocuments removes schema entries whose document file is still missing, with their embedding file
+func (ls *LocalStorage) pruneDocuments(name string, ids []string) ([]string, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[name]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", name)
+	}
+
+	collection.invalidateKeyIndex()
+	pruned := make([]string, 0, len(ids))
+	for _, id := range ids {
+		docPath, err := ls.getDocumentPath(name, id)
+		if err != nil {
+			return pruned, err
+		}
+		if fileExists(docPath) {
+			continue
+		}
+		embPath, err := ls.getEmbeddingPath(name, id)
+		if err != nil {
+			return pruned, err
+		}
+
+		// The embedding file of a pruned entry can no longer be registered
+		removeFile(embPath)
+		collection.unindexFields(collection.Documents[id])
+		delete(collection.Documents, id)
+		collection.unindexRecent(id)
+		pruned = append(pruned, id)
+	}
+
+	return pruned, nil
+}
+
+// listDataFiles returns the size of each JSON file of dir by base name,
+// merging plain and compressed variants
+func listDataFiles(dir string) (map[string]int64, error) {
+	files := make(map[string]int64)
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		base, ok := strings.CutSuffix(strings.TrimSuffix(entry.Name(), gzipSuffix), ".json")
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files[base] += info.Size()
+	}
+
+	return files, nil
+}
+
+// readDocumentFile decodes a document file of dir
+func readDocumentFile(dir, name string) (*Document, error) {
+	file, err := openFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var doc Document
+	if err := json.NewDecoder(file).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("unreadable document file: %w", err)
+	}
+	return &doc, nil
+}
+
+// fileExists reports whether path or its compressed variant exists
+func fileExists(path string) bool {
+	if _, err := os.Stat(path); err == nil {
+		return true
+	}
+	_, err := os.Stat(path + gzipSuffix)
+	return err == nil
+}
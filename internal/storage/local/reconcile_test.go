@@ -0,0 +1,152 @@
+package local
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeDocumentFile(t *testing.T, path string, doc *Document) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), DefaultPermission); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	storeVectors(t, adapter, map[string][]float64{
+		"a": {1, 0},
+		"b": {0, 1},
+		"c": {1, 1},
+	})
+
+	collectionDir := filepath.Join(dir, CollectionsDir, "vectors")
+
+	// A document dropped in by hand, a document file lost and an unreadable file
+	writeDocumentFile(t, filepath.Join(collectionDir, "dropped.json"), &Document{
+		ID:        "dropped",
+		Type:      TypeText,
+		Metadata:  map[string]interface{}{"text": "added by hand"},
+		Embedding: &EmbeddingData{Vector: []float64{0.5, 0.5}, Dimension: 2},
+	})
+	if err := os.Remove(filepath.Join(collectionDir, "b.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(collectionDir, "broken.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := adapter.Reconcile(ReconcileOptions{DryRun: true, Prune: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	// The embedding file left behind by "b" is reported alongside the unreadable file
+	if report.Added != 1 || report.Missing != 1 || report.Pruned != 0 || report.Conflicts != 2 {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	if got := adapter.Count(); got != 3 {
+		t.Fatalf("dry run changed the count to %d", got)
+	}
+
+	report, err = adapter.Reconcile(ReconcileOptions{Prune: true, BatchSize: 1})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	collection := report.Collections[0]
+	if !reflect.DeepEqual(collection.Added, []string{"dropped"}) || !reflect.DeepEqual(collection.Pruned, []string{"b"}) {
+		t.Errorf("added = %v, pruned = %v", collection.Added, collection.Pruned)
+	}
+	if collection.DocumentCount != 3 || adapter.Count() != 3 {
+		t.Errorf("document count = %d (adapter %d), want 3", collection.DocumentCount, adapter.Count())
+	}
+
+	vector, err := adapter.Get("dropped")
+	if err != nil {
+		t.Fatalf("registered document not found: %v", err)
+	}
+	if !reflect.DeepEqual(vector.Embedding, []float64{0.5, 0.5}) {
+		t.Errorf("embedding = %v", vector.Embedding)
+	}
+
+	// The reconciled schema is persisted
+	reopened, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen: %v", err)
+	}
+	if got := reopened.Count(); got != 3 {
+		t.Errorf("reopened count = %d, want 3", got)
+	}
+
+	// Pruning removed the embedding of "b", only the unreadable file is left
+	report, err = adapter.Reconcile(ReconcileOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if report.Added != 0 || report.Missing != 0 || report.Conflicts != 1 {
+		t.Errorf("second run report = %+v", report)
+	}
+}
+
+func TestReconcile_RegistersCollections(t *testing.T) {
+	dir := t.TempDir()
+	ls, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	writeDocumentFile(t, filepath.Join(dir, CollectionsDir, "restored", "doc.json"), &Document{ID: "doc", Type: TypeText})
+	writeDocumentFile(t, filepath.Join(dir, CollectionsDir, "restored", "orphan.json"), &Document{
+		ID:        "orphan",
+		Embedding: &EmbeddingData{Dimension: 2, Path: "/elsewhere/orphan.json"},
+	})
+	writeDocumentFile(t, filepath.Join(dir, CollectionsDir, "restored", "renamed.json"), &Document{ID: "other"})
+
+	report, err := ls.Reconcile(ReconcileOptions{})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(report.Collections) != 1 || !report.Collections[0].Created {
+		t.Fatalf("collections = %+v", report.Collections)
+	}
+
+	collection := report.Collections[0]
+	if !reflect.DeepEqual(collection.Added, []string{"doc"}) {
+		t.Errorf("added = %v, want [doc]", collection.Added)
+	}
+
+	reasons := make(map[string]string)
+	for _, conflict := range collection.Conflicts {
+		reasons[conflict.File] = conflict.Reason
+	}
+	if reasons["orphan.json"] != "embedding file missing" || reasons["renamed.json"] != "file name does not match document ID" {
+		t.Errorf("conflicts = %+v", collection.Conflicts)
+	}
+
+	if _, err := ls.GetDocument("restored", "doc"); err != nil {
+		t.Errorf("registered document not found: %v", err)
+	}
+}
+
+func TestReconcile_InvalidCollection(t *testing.T) {
+	ls, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if _, err := ls.Reconcile(ReconcileOptions{Collections: []string{"../escape"}}); err == nil {
+		t.Error("expected an error for an unsafe collection name")
+	}
+}
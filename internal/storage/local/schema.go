@@ -2,6 +2,19 @@ package local
 
 import (
 	"time"
+
+	"github.com/tahcohcat/same-same/internal/jobs"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/alias"
+	"github.com/tahcohcat/same-same/internal/storage/bulk"
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/metaschema"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/recency"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+	"github.com/tahcohcat/same-same/internal/storage/uniquekey"
+	"github.com/tahcohcat/same-same/internal/storage/watch"
 )
 
 // StorageSchema represents the top-level storage structure
@@ -31,6 +44,23 @@ type Collection struct {
 	Schema      *CollectionSchema    `json:"schema,omitempty"`
 	Documents   map[string]*Document `json:"documents"`
 	Stats       CollectionStats      `json:"stats"`
+	Generation  uint64               `json:"generation,omitempty"`  // Bumped on every document mutation
+	Quotas      quota.Limits         `json:"quotas,omitempty"`      // Limits per namespace, enforced on store
+	IngestRuns  []*models.IngestRun  `json:"ingest_runs,omitempty"` // History of the ingest runs into the collection
+	UniqueKeys  []string             `json:"unique_keys,omitempty"` // Metadata fields unique within a namespace
+	Profiles    profile.Profiles     `json:"profiles,omitempty"`    // Named ranking profiles
+	Aliases     alias.Aliases        `json:"aliases,omitempty"`     // Names standing in for namespaces
+	EvalSets    eval.Sets            `json:"eval_sets,omitempty"`   // Labeled queries scored by evaluation runs
+	EvalRuns    []*eval.Run          `json:"eval_runs,omitempty"`   // History of the evaluation runs, oldest first
+	Tombstones  *tombstone.Log       `json:"tombstones,omitempty"`  // Recently deleted documents, for delta listings
+	BulkJobs    []*bulk.Job          `json:"bulk_jobs,omitempty"`   // Bulk jobs persisted before they ran as Jobs, moved there when saved
+	Jobs        []*jobs.Job          `json:"jobs,omitempty"`        // Background jobs, resumed on restart until finished
+	Watches     []*watch.Watch       `json:"watches,omitempty"`     // Standing queries evaluated against stored vectors
+
+	uniqueIndex *uniquekey.Index  // Built from Documents when first needed
+	recent      *recency.Index    // Built from Documents when first needed
+	fields      *metaschema.Index // Loaded, or built from Documents, when first needed
+	fieldsSaved uint64            // Generation the sketched field summary was last saved at
 }
 
 // CollectionSchema defines the structure and constraints for a collection
@@ -160,12 +190,27 @@ type ContentReference struct {
 
 // EmbeddingData represents vector embedding information
 type EmbeddingData struct {
-	Vector    []float64         `json:"vector,omitempty"`
-	Dimension int               `json:"dimension"`
-	Model     string            `json:"model"`
-	CreatedAt time.Time         `json:"created_at"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
-	Path      string            `json:"path,omitempty"` // Path to separate embedding file
+	Vector    []float64            `json:"vector,omitempty"`
+	Sparse    *models.SparseVector `json:"sparse,omitempty"` // Set instead of Vector for sparse embeddings
+	Dimension int                  `json:"dimension"`
+	DType     string               `json:"dtype,omitempty"` // Representation the embedding was supplied in
+	Model     string               `json:"model"`
+	CreatedAt time.Time            `json:"created_at"`
+	Metadata  map[string]string    `json:"metadata,omitempty"`
+	Path      string               `json:"path,omitempty"` // Path to separate embedding file
+}
+
+// hasValues reports whether the embedding values are held inline rather than in a separate file
+func (e *EmbeddingData) hasValues() bool {
+	return len(e.Vector) > 0 || e.Sparse != nil
+}
+
+// size returns the dimension of the inline values, zero for sparse values of unknown dimension
+func (e *EmbeddingData) size() int {
+	if e.Sparse != nil {
+		return e.Sparse.Dimension
+	}
+	return len(e.Vector)
 }
 
 // Relation represents a relationship between documents
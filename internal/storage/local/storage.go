@@ -2,6 +2,7 @@ package local
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,9 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
 )
 
 const (
@@ -18,20 +22,44 @@ const (
 	CollectionsDir    = "collections"
 	EmbeddingsDir     = "embeddings"
 	ContentDir        = "content"
+	EnrichmentDir     = "enrichment"
+	QuarantineDir     = "quarantine"
+	FieldsDir         = "fields"
+	VocabularyDir     = "vocabulary"
 )
 
 // LocalStorage implements file-based persistent storage
 type LocalStorage struct {
-	basePath string
-	schema   *StorageSchema
-	mu       sync.RWMutex
-	logger   *logrus.Logger
+	basePath      string
+	options       Options
+	schema        *StorageSchema
+	tombstoneOpts tombstone.Options
+	mu            sync.RWMutex
+	logger        *logrus.Logger
+
+	// Schema flushes, see durability.go
+	pendingOps int // Operations not yet flushed by the batched mode
+	flushes    int // Times the schema was written
+	lastFlush  time.Time
+	stopFlush  chan struct{} // Closed to stop the flush loop of the batched mode
+	flushDone  chan struct{}
+	closeOnce  sync.Once
 }
 
 // NewLocalStorage creates a new local file storage
 func NewLocalStorage(basePath string) (*LocalStorage, error) {
+	return NewLocalStorageWithOptions(basePath, Options{})
+}
+
+// NewLocalStorageWithOptions creates a new local file storage with the given options
+func NewLocalStorageWithOptions(basePath string, options Options) (*LocalStorage, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
 	ls := &LocalStorage{
 		basePath: basePath,
+		options:  options,
 		logger:   logrus.New(),
 	}
 
@@ -45,6 +73,12 @@ func NewLocalStorage(basePath string) (*LocalStorage, error) {
 		return nil, fmt.Errorf("failed to load schema: %w", err)
 	}
 
+	if options.Durability == DurabilityBatched {
+		ls.stopFlush = make(chan struct{})
+		ls.flushDone = make(chan struct{})
+		go ls.flushLoop()
+	}
+
 	return ls, nil
 }
 
@@ -86,7 +120,7 @@ func (ls *LocalStorage) loadOrCreateSchema() error {
 			Collections: make(map[string]*Collection),
 		}
 
-		return ls.saveSchema()
+		return ls.writeSchema(ls.options.Durability != DurabilityRelaxed)
 	}
 
 	// Load existing schema
@@ -109,31 +143,18 @@ func (ls *LocalStorage) loadOrCreateSchema() error {
 	return nil
 }
 
-// saveSchema persists the schema to disk
-// saveSchema persists the schema to disk. Caller must hold the lock.
-func (ls *LocalStorage) saveSchema() error {
-	ls.schema.UpdatedAt = time.Now()
-
-	metadataPath := filepath.Join(ls.basePath, MetadataFile)
-	file, err := os.Create(metadataPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(ls.schema)
-}
-
 // CreateCollection creates a new collection
 func (ls *LocalStorage) CreateCollection(name, description string, schema *CollectionSchema) (*Collection, error) {
+	if err := ValidateCollectionName(name); err != nil {
+		return nil, storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
 
 	// Check if collection already exists
 	if _, exists := ls.schema.Collections[name]; exists {
-		return nil, fmt.Errorf("collection %s already exists", name)
+		return nil, storeerr.AlreadyExistsf("collection %s already exists", name)
 	}
 
 	collection := &Collection{
@@ -151,14 +172,17 @@ func (ls *LocalStorage) CreateCollection(name, description string, schema *Colle
 		},
 	}
 
-	ls.schema.Collections[name] = collection
-
 	// Create collection directory
-	collectionPath := filepath.Join(ls.basePath, CollectionsDir, name)
+	collectionPath, err := ls.resolvePath(CollectionsDir, name)
+	if err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(collectionPath, DefaultPermission); err != nil {
 		return nil, err
 	}
 
+	ls.schema.Collections[name] = collection
+
 	// Already holding lock
 	if err := ls.saveSchema(); err != nil {
 		return nil, err
@@ -175,12 +199,87 @@ func (ls *LocalStorage) GetCollection(name string) (*Collection, error) {
 
 	collection, exists := ls.schema.Collections[name]
 	if !exists {
-		return nil, fmt.Errorf("collection %s not found", name)
+		return nil, storeerr.NotFoundf("collection %s not found", name)
 	}
 
 	return collection, nil
 }
 
+// readCollection calls read with a collection under the read lock, so that it can range
+// over the document index while documents are stored, such as by the write-behind
+// queue of tiered storage
+func (ls *LocalStorage) readCollection(name string, read func(collection *Collection)) error {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[name]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", name)
+	}
+	read(collection)
+	return nil
+}
+
+// documents returns the documents of a collection, or those of ids when ids is not nil
+// They are collected under the read lock, so callers range over them without holding it
+func (ls *LocalStorage) documents(name string, ids []string) ([]*Document, error) {
+	var docs []*Document
+	err := ls.readCollection(name, func(collection *Collection) {
+		if ids == nil {
+			docs = make([]*Document, 0, len(collection.Documents))
+			for _, doc := range collection.Documents {
+				docs = append(docs, doc)
+			}
+			return
+		}
+
+		seen := make(map[string]bool, len(ids))
+		docs = make([]*Document, 0, len(ids))
+		for _, id := range ids {
+			if doc, ok := collection.Documents[id]; ok && !seen[id] {
+				docs = append(docs, doc)
+				seen[id] = true
+			}
+		}
+	})
+	return docs, err
+}
+
+// UpdateVectorConfig replaces the vector configuration of a collection and persists it
+func (ls *LocalStorage) UpdateVectorConfig(name string, config *VectorConfig) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[name]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", name)
+	}
+
+	if collection.Schema == nil {
+		collection.Schema = &CollectionSchema{}
+	}
+	updated := *config
+	collection.Schema.VectorConfig = &updated
+	collection.UpdatedAt = time.Now()
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// CollectionGeneration returns the mutation generation of a collection
+// The generation is persisted with the schema, so it keeps increasing across restarts
+func (ls *LocalStorage) CollectionGeneration(name string) (uint64, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[name]
+	if !exists {
+		return 0, storeerr.NotFoundf("collection %s not found", name)
+	}
+
+	return collection.Generation, nil
+}
+
 // ListCollections returns all collections
 func (ls *LocalStorage) ListCollections() []*Collection {
 	ls.mu.RLock()
@@ -196,12 +295,23 @@ func (ls *LocalStorage) ListCollections() []*Collection {
 
 // StoreDocument stores a document in a collection
 func (ls *LocalStorage) StoreDocument(collectionName string, doc *Document) error {
+	if doc.ID == "" {
+		return storeerr.Validationf("document ID cannot be empty")
+	}
+
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
 
 	collection, exists := ls.schema.Collections[collectionName]
 	if !exists {
-		return fmt.Errorf("collection %s not found", collectionName)
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	if err := checkQuota(collection, doc); err != nil {
+		return err
+	}
+	if err := reserveUniqueKeys(collection, doc); err != nil {
+		return err
 	}
 
 	// Set document metadata
@@ -213,27 +323,59 @@ func (ls *LocalStorage) StoreDocument(collectionName string, doc *Document) erro
 	doc.CollectionID = collectionName
 	doc.Version++
 
-	// Store document in collection
-	collection.Documents[doc.ID] = doc
+	if err := ls.putDocument(collectionName, collection, doc); err != nil {
+		collection.invalidateKeyIndex()
+		return err
+	}
 
 	// Update collection stats
 	collection.Stats.DocumentCount = len(collection.Documents)
 	collection.Stats.LastUpdated = now
 	collection.UpdatedAt = now
+	collection.Generation++
+
+	// Already holding lock
+	if err := ls.saveSchema(); err != nil {
+		return err
+	}
 
+	ls.logger.WithFields(logrus.Fields{
+		"collection": collectionName,
+		"document":   doc.ID,
+		"version":    doc.Version,
+	}).Debug("stored document")
+
+	return nil
+}
+
+// putDocument writes doc and its embedding and content files and adds it to collection
+// Caller must hold the lock and update the collection stats and schema
+func (ls *LocalStorage) putDocument(collectionName string, collection *Collection, doc *Document) error {
 	// Save document to file
 	if err := ls.saveDocument(collectionName, doc); err != nil {
 		return err
 	}
 
 	// Save embeddings separately if present and large
-	if doc.Embedding != nil && len(doc.Embedding.Vector) > 0 {
+	if doc.Embedding != nil && doc.Embedding.hasValues() {
 		if err := ls.saveEmbedding(collectionName, doc.ID, doc.Embedding); err != nil {
 			return err
 		}
 		// Reference embedding file instead of storing inline
-		doc.Embedding.Path = ls.getEmbeddingPath(collectionName, doc.ID)
+		embPath, err := ls.getEmbeddingPath(collectionName, doc.ID)
+		if err != nil {
+			return err
+		}
+		doc.Embedding.Path = embPath
 		doc.Embedding.Vector = nil // Clear vector to save space
+		doc.Embedding.Sparse = nil
+	} else if doc.Embedding == nil {
+		// A document without embedding must not keep one it was stored with before
+		embPath, err := ls.getEmbeddingPath(collectionName, doc.ID)
+		if err != nil {
+			return err
+		}
+		removeFile(embPath)
 	}
 
 	// Save content files separately for large content
@@ -243,108 +385,175 @@ func (ls *LocalStorage) StoreDocument(collectionName string, doc *Document) erro
 		}
 	}
 
-	// Already holding lock
-	if err := ls.saveSchema(); err != nil {
-		return err
-	}
-
-	ls.logger.WithFields(logrus.Fields{
-		"collection": collectionName,
-		"document":   doc.ID,
-		"version":    doc.Version,
-	}).Debug("stored document")
+	// Store document in collection
+	collection.indexFields(collection.Documents[doc.ID], doc)
+	collection.Documents[doc.ID] = doc
+	collection.indexRecent(doc)
 
 	return nil
 }
 
 // saveDocument saves a document to its JSON file
 func (ls *LocalStorage) saveDocument(collectionName string, doc *Document) error {
-	docPath := ls.getDocumentPath(collectionName, doc.ID)
-
-	if err := os.MkdirAll(filepath.Dir(docPath), DefaultPermission); err != nil {
+	docPath, err := ls.getDocumentPath(collectionName, doc.ID)
+	if err != nil {
 		return err
 	}
+	return ls.writeDocumentFile(docPath, doc)
+}
 
-	file, err := os.Create(docPath)
+// writeDocumentFile writes doc as indented JSON to path
+func (ls *LocalStorage) writeDocumentFile(path string, doc *Document) error {
+	file, err := ls.createFile(path)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(doc)
+	err = encoder.Encode(doc)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // saveEmbedding saves embedding vector to a separate binary file
 func (ls *LocalStorage) saveEmbedding(collectionName, docID string, embedding *EmbeddingData) error {
-	embPath := ls.getEmbeddingPath(collectionName, docID)
-
-	if err := os.MkdirAll(filepath.Dir(embPath), DefaultPermission); err != nil {
+	embPath, err := ls.getEmbeddingPath(collectionName, docID)
+	if err != nil {
 		return err
 	}
+	return ls.writeEmbeddingFile(embPath, embedding)
+}
 
-	file, err := os.Create(embPath)
+// writeEmbeddingFile writes embedding as JSON to path
+func (ls *LocalStorage) writeEmbeddingFile(path string, embedding *EmbeddingData) error {
+	file, err := ls.createFile(path)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	return encoder.Encode(embedding)
+	err = json.NewEncoder(file).Encode(embedding)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // saveContent saves large content to separate files
 func (ls *LocalStorage) saveContent(collectionName, docID string, content *ContentData) error {
+	var err error
+
 	// For binary content (images, audio, video), save to content directory
 	if content.Image != nil && content.Image.Path == "" {
 		// Placeholder for actual image saving logic
-		content.Image.Path = ls.getContentPath(collectionName, docID, "image")
+		if content.Image.Path, err = ls.getContentPath(collectionName, docID, "image"); err != nil {
+			return err
+		}
 	}
 	if content.Audio != nil && content.Audio.Path == "" {
-		content.Audio.Path = ls.getContentPath(collectionName, docID, "audio")
+		if content.Audio.Path, err = ls.getContentPath(collectionName, docID, "audio"); err != nil {
+			return err
+		}
 	}
 	if content.Video != nil && content.Video.Path == "" {
-		content.Video.Path = ls.getContentPath(collectionName, docID, "video")
+		if content.Video.Path, err = ls.getContentPath(collectionName, docID, "video"); err != nil {
+			return err
+		}
 	}
 	if content.Binary != nil && content.Binary.Path == "" {
-		content.Binary.Path = ls.getContentPath(collectionName, docID, "binary")
+		if content.Binary.Path, err = ls.getContentPath(collectionName, docID, "binary"); err != nil {
+			return err
+		}
+		if ls.options.Compression != "" {
+			content.Binary.Path += gzipSuffix
+			content.Binary.Compression = ls.options.Compression
+		}
 	}
 
 	return nil
 }
 
 // GetDocument retrieves a document by ID
+// The collection is held read-locked, like GetDocuments, while its document index is read
 func (ls *LocalStorage) GetDocument(collectionName, docID string) (*Document, error) {
 	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
 	collection, exists := ls.schema.Collections[collectionName]
-	ls.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("collection %s not found", collectionName)
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
 	}
 
 	// Try to get from memory first
 	if doc, exists := collection.Documents[docID]; exists {
-		// Load embedding if it was stored separately
-		if doc.Embedding != nil && doc.Embedding.Path != "" {
-			embedding, err := ls.loadEmbedding(collectionName, docID)
-			if err == nil {
-				doc.Embedding = embedding
-			}
-		}
-		return doc, nil
+		return ls.withEmbedding(collectionName, doc), nil
 	}
 
 	// Load from file
 	return ls.loadDocument(collectionName, docID)
 }
 
+// GetDocuments retrieves the documents of docIDs in order, nil for missing ones
+// The collection is looked up once and held read-locked for the whole batch
+func (ls *LocalStorage) GetDocuments(collectionName string, docIDs []string) ([]*Document, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	docs := make([]*Document, len(docIDs))
+	for i, docID := range docIDs {
+		doc, exists := collection.Documents[docID]
+		if !exists {
+			// Fall back to the document file, like GetDocument
+			loaded, err := ls.loadDocument(collectionName, docID)
+			if errors.Is(err, storeerr.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to load document %s: %w", docID, err)
+			}
+			docs[i] = loaded
+			continue
+		}
+		docs[i] = ls.withEmbedding(collectionName, doc)
+	}
+	return docs, nil
+}
+
+// withEmbedding returns doc with its embedding loaded if it is stored separately
+// The document of the collection is copied rather than updated, so it keeps referencing
+// the embedding file and the schema never holds the values
+func (ls *LocalStorage) withEmbedding(collectionName string, doc *Document) *Document {
+	if doc.Embedding == nil || doc.Embedding.hasValues() || doc.Embedding.Path == "" {
+		return doc
+	}
+	embedding, err := ls.loadEmbedding(collectionName, doc.ID)
+	if err != nil {
+		return doc
+	}
+	loaded := *doc
+	loaded.Embedding = embedding
+	return &loaded
+}
+
 // loadDocument loads a document from its JSON file
 func (ls *LocalStorage) loadDocument(collectionName, docID string) (*Document, error) {
-	docPath := ls.getDocumentPath(collectionName, docID)
+	docPath, err := ls.getDocumentPath(collectionName, docID)
+	if err != nil {
+		return nil, err
+	}
 
-	file, err := os.Open(docPath)
+	file, err := openFile(docPath)
+	if os.IsNotExist(err) {
+		return nil, storeerr.NotFoundf("document %s not found in collection %s", docID, collectionName)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -368,9 +577,12 @@ func (ls *LocalStorage) loadDocument(collectionName, docID string) (*Document, e
 
 // loadEmbedding loads embedding from separate file
 func (ls *LocalStorage) loadEmbedding(collectionName, docID string) (*EmbeddingData, error) {
-	embPath := ls.getEmbeddingPath(collectionName, docID)
+	embPath, err := ls.getEmbeddingPath(collectionName, docID)
+	if err != nil {
+		return nil, err
+	}
 
-	file, err := os.Open(embPath)
+	file, err := openFile(embPath)
 	if err != nil {
 		return nil, err
 	}
@@ -384,19 +596,6 @@ func (ls *LocalStorage) loadEmbedding(collectionName, docID string) (*EmbeddingD
 	return &embedding, nil
 }
 
-// Path helpers
-func (ls *LocalStorage) getDocumentPath(collectionName, docID string) string {
-	return filepath.Join(ls.basePath, CollectionsDir, collectionName, fmt.Sprintf("%s.json", docID))
-}
-
-func (ls *LocalStorage) getEmbeddingPath(collectionName, docID string) string {
-	return filepath.Join(ls.basePath, EmbeddingsDir, collectionName, fmt.Sprintf("%s.json", docID))
-}
-
-func (ls *LocalStorage) getContentPath(collectionName, docID, contentType string) string {
-	return filepath.Join(ls.basePath, ContentDir, collectionName, docID, contentType)
-}
-
 // DeleteDocument deletes a document
 func (ls *LocalStorage) DeleteDocument(collectionName, docID string) error {
 	ls.mu.Lock()
@@ -404,35 +603,102 @@ func (ls *LocalStorage) DeleteDocument(collectionName, docID string) error {
 
 	collection, exists := ls.schema.Collections[collectionName]
 	if !exists {
-		return fmt.Errorf("collection %s not found", collectionName)
+		return storeerr.NotFoundf("collection %s not found", collectionName)
 	}
 
-	delete(collection.Documents, docID)
+	docPath, err := ls.getDocumentPath(collectionName, docID)
+	if err != nil {
+		return err
+	}
+	embPath, err := ls.getEmbeddingPath(collectionName, docID)
+	if err != nil {
+		return err
+	}
 
-	// Delete document file
-	docPath := ls.getDocumentPath(collectionName, docID)
-	os.Remove(docPath)
+	doc, ok := collection.Documents[docID]
+	if !ok && !fileExists(docPath) {
+		return storeerr.NotFoundf("document %s not found in collection %s", docID, collectionName)
+	}
+	if ok && collection.uniqueIndex != nil {
+		collection.uniqueIndex.Remove(docID, convertInterfaceToStringMap(doc.Metadata))
+	}
+	delete(collection.Documents, docID)
+	collection.unindexRecent(docID)
+	collection.unindexFields(doc)
+	collection.addTombstone(docID, doc, ls.tombstoneOpts)
 
-	// Delete embedding file
-	embPath := ls.getEmbeddingPath(collectionName, docID)
-	os.Remove(embPath)
+	// Delete document and embedding files
+	removeFile(docPath)
+	removeFile(embPath)
 
 	// Update stats
 	collection.Stats.DocumentCount = len(collection.Documents)
 	collection.Stats.LastUpdated = time.Now()
+	collection.Generation++
 
 	// Already holding lock
 	return ls.saveSchema()
 }
 
+// DeleteDocuments deletes the documents of ids from a collection, skipping unknown
+// IDs, and saves the schema once. It returns how many documents were deleted
+func (ls *LocalStorage) DeleteDocuments(collectionName string, ids []string) (int, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return 0, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	deleted := 0
+	for _, docID := range ids {
+		doc, ok := collection.Documents[docID]
+		if !ok {
+			continue
+		}
+		docPath, err := ls.getDocumentPath(collectionName, docID)
+		if err != nil {
+			return deleted, err
+		}
+		embPath, err := ls.getEmbeddingPath(collectionName, docID)
+		if err != nil {
+			return deleted, err
+		}
+
+		if collection.uniqueIndex != nil {
+			collection.uniqueIndex.Remove(docID, convertInterfaceToStringMap(doc.Metadata))
+		}
+		delete(collection.Documents, docID)
+		collection.unindexRecent(docID)
+		collection.unindexFields(doc)
+		collection.addTombstone(docID, doc, ls.tombstoneOpts)
+
+		removeFile(docPath)
+		removeFile(embPath)
+		deleted++
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	collection.Stats.DocumentCount = len(collection.Documents)
+	collection.Stats.LastUpdated = time.Now()
+	collection.Generation++
+
+	// Already holding lock
+	return deleted, ls.saveSchema()
+}
+
 // QueryByMetadata queries documents by metadata filters
 func (ls *LocalStorage) QueryByMetadata(collectionName string, filters map[string]interface{}) ([]*Document, error) {
 	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
 	collection, exists := ls.schema.Collections[collectionName]
-	ls.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("collection %s not found", collectionName)
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
 	}
 
 	results := make([]*Document, 0)
@@ -457,54 +723,21 @@ func (ls *LocalStorage) matchesFilters(metadata map[string]interface{}, filters
 	return true
 }
 
-// Export exports collection to a file
-func (ls *LocalStorage) Export(collectionName, outputPath string) error {
-	ls.mu.RLock()
-	collection, exists := ls.schema.Collections[collectionName]
-	ls.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("collection %s not found", collectionName)
-	}
-
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(collection)
-}
-
-// Import imports collection from a file
-func (ls *LocalStorage) Import(collectionName, inputPath string) error {
-	file, err := os.Open(inputPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	var collection Collection
-	if err := json.NewDecoder(file).Decode(&collection); err != nil {
-		return err
-	}
-
-	ls.mu.Lock()
-	ls.schema.Collections[collectionName] = &collection
-	// Already holding lock
-	err = ls.saveSchema()
-	ls.mu.Unlock()
-	return err
-}
-
-// Close closes the storage
+// Close stops the flush loop of the batched mode and flushes the schema,
+// whatever the durability mode
 func (ls *LocalStorage) Close() error {
-	return ls.saveSchema()
+	ls.closeOnce.Do(func() {
+		if ls.stopFlush != nil {
+			close(ls.stopFlush)
+			<-ls.flushDone
+		}
+	})
+	return ls.Flush()
 }
 
 // GetStats returns storage statistics
+// Stored and logical byte counts walk the data directories, so they are
+// proportional in cost to the number of stored files
 func (ls *LocalStorage) GetStats() map[string]interface{} {
 	ls.mu.RLock()
 	defer ls.mu.RUnlock()
@@ -514,11 +747,18 @@ func (ls *LocalStorage) GetStats() map[string]interface{} {
 		totalDocs += collection.Stats.DocumentCount
 	}
 
+	storedBytes, logicalBytes, compressedFiles := ls.diskUsage()
+
 	return map[string]interface{}{
-		"version":         ls.schema.Version,
-		"collections":     len(ls.schema.Collections),
-		"total_documents": totalDocs,
-		"created_at":      ls.schema.CreatedAt,
-		"updated_at":      ls.schema.UpdatedAt,
+		"version":          ls.schema.Version,
+		"collections":      len(ls.schema.Collections),
+		"total_documents":  totalDocs,
+		"created_at":       ls.schema.CreatedAt,
+		"updated_at":       ls.schema.UpdatedAt,
+		"compression":      ls.options.Compression,
+		"stored_bytes":     storedBytes,
+		"logical_bytes":    logicalBytes,
+		"compressed_files": compressedFiles,
+		"durability":       ls.durabilityStats(),
 	}
 }
@@ -0,0 +1,238 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// stagingPrefix starts the name of the directories transactions stage files in
+const stagingPrefix = ".staging-"
+
+// stagedFile is a file of a transaction waiting to replace its target
+type stagedFile struct {
+	source string // Staged file, empty to only remove the target
+	target string // Final path, without compression suffix
+}
+
+// fileMove is a rename done by a transaction, undone on rollback
+type fileMove struct {
+	from, to string
+}
+
+// transaction writes the files of many documents to a staging directory and
+// moves them into place together, keeping the files they replace until it is closed
+type transaction struct {
+	ls    *LocalStorage
+	dir   string
+	n     int
+	files []stagedFile
+	moves []fileMove
+}
+
+// beginTransaction creates the staging directory of a transaction
+// The directory is inside the storage so that moving files out of it is a rename
+func (ls *LocalStorage) beginTransaction() (*transaction, error) {
+	dir, err := os.MkdirTemp(ls.basePath, stagingPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return &transaction{ls: ls, dir: dir}, nil
+}
+
+// stagePath returns a new path in the staging directory
+func (tx *transaction) stagePath() string {
+	tx.n++
+	return filepath.Join(tx.dir, strconv.Itoa(tx.n))
+}
+
+// stageDocument writes doc and its embedding to the staging directory, in the
+// same layout putDocument writes them in place
+func (tx *transaction) stageDocument(collectionName string, doc *Document) error {
+	docPath, err := tx.ls.getDocumentPath(collectionName, doc.ID)
+	if err != nil {
+		return err
+	}
+	embPath, err := tx.ls.getEmbeddingPath(collectionName, doc.ID)
+	if err != nil {
+		return err
+	}
+
+	staged := tx.stagePath()
+	if err := tx.ls.writeDocumentFile(staged, doc); err != nil {
+		return err
+	}
+	tx.files = append(tx.files, stagedFile{source: staged, target: docPath})
+
+	if doc.Embedding != nil && doc.Embedding.hasValues() {
+		staged := tx.stagePath()
+		if err := tx.ls.writeEmbeddingFile(staged, doc.Embedding); err != nil {
+			return err
+		}
+		tx.files = append(tx.files, stagedFile{source: staged, target: embPath})
+
+		doc.Embedding.Path = embPath
+		doc.Embedding.Vector = nil
+		doc.Embedding.Sparse = nil
+	} else if doc.Embedding == nil {
+		// A document without embedding must not keep one it was stored with before
+		tx.files = append(tx.files, stagedFile{target: embPath})
+	}
+
+	if doc.Content != nil {
+		return tx.ls.saveContent(collectionName, doc.ID, doc.Content)
+	}
+	return nil
+}
+
+// commit moves the staged files into place
+// Both variants of every target are moved aside first, so a failure part way can be rolled back
+func (tx *transaction) commit() error {
+	for _, f := range tx.files {
+		for _, variant := range []string{f.target, f.target + gzipSuffix} {
+			if _, err := os.Lstat(variant); err != nil {
+				continue
+			}
+			backup := tx.stagePath()
+			if err := os.Rename(variant, backup); err != nil {
+				return err
+			}
+			tx.moves = append(tx.moves, fileMove{from: variant, to: backup})
+		}
+		if f.source == "" {
+			continue
+		}
+
+		source, target := f.source, f.target
+		if tx.ls.options.Compression == CompressionGzip {
+			source += gzipSuffix
+			target += gzipSuffix
+		}
+		if err := os.MkdirAll(filepath.Dir(target), DefaultPermission); err != nil {
+			return err
+		}
+		if err := os.Rename(source, target); err != nil {
+			return err
+		}
+		tx.moves = append(tx.moves, fileMove{from: source, to: target})
+	}
+	return nil
+}
+
+// rollback undoes the moves of commit, newest first, restoring the replaced files
+func (tx *transaction) rollback() {
+	for i := len(tx.moves) - 1; i >= 0; i-- {
+		move := tx.moves[i]
+		if err := os.Rename(move.to, move.from); err != nil {
+			tx.ls.logger.WithError(err).WithField("file", move.from).Error("failed to roll back file")
+		}
+	}
+	tx.moves = nil
+}
+
+// close removes the staging directory along with the replaced files
+func (tx *transaction) close() {
+	os.RemoveAll(tx.dir)
+}
+
+// StoreDocuments stores docs in a collection all or nothing
+// The files of every document are staged before any is moved into place and the
+// schema is saved once. If any step fails the replaced files and the document
+// index are restored, so neither readers nor a reload see part of the batch
+func (ls *LocalStorage) StoreDocuments(collectionName string, docs []*Document) error {
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return storeerr.Validationf("document ID cannot be empty")
+		}
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	if err := checkQuota(collection, docs...); err != nil {
+		return err
+	}
+	if err := reserveUniqueKeys(collection, docs...); err != nil {
+		return err
+	}
+
+	tx, err := ls.beginTransaction()
+	if err != nil {
+		collection.invalidateKeyIndex()
+		return err
+	}
+	defer tx.close()
+
+	now := time.Now()
+	for _, doc := range docs {
+		if doc.CreatedAt.IsZero() {
+			doc.CreatedAt = now
+		}
+		doc.UpdatedAt = now
+		doc.CollectionID = collectionName
+		doc.Version++
+
+		if err := tx.stageDocument(collectionName, doc); err != nil {
+			collection.invalidateKeyIndex()
+			return fmt.Errorf("failed to stage document %s: %w", doc.ID, err)
+		}
+	}
+
+	if err := tx.commit(); err != nil {
+		tx.rollback()
+		collection.invalidateKeyIndex()
+		return fmt.Errorf("failed to commit documents: %w", err)
+	}
+
+	previous := make(map[string]*Document, len(docs))
+	for _, doc := range docs {
+		if _, seen := previous[doc.ID]; !seen {
+			previous[doc.ID] = collection.Documents[doc.ID]
+		}
+		collection.indexFields(collection.Documents[doc.ID], doc)
+		collection.Documents[doc.ID] = doc
+		collection.indexRecent(doc)
+	}
+	stats, updatedAt := collection.Stats, collection.UpdatedAt
+
+	collection.Stats.DocumentCount = len(collection.Documents)
+	collection.Stats.LastUpdated = now
+	collection.UpdatedAt = now
+	collection.Generation++
+
+	// Already holding lock
+	if err := ls.saveSchema(); err != nil {
+		tx.rollback()
+		for id, doc := range previous {
+			if doc == nil {
+				delete(collection.Documents, id)
+			} else {
+				collection.Documents[id] = doc
+			}
+		}
+		collection.Stats, collection.UpdatedAt = stats, updatedAt
+		collection.Generation--
+		collection.invalidateKeyIndex()
+		collection.invalidateRecentIndex()
+		collection.invalidateFieldIndex()
+		return err
+	}
+
+	ls.logger.WithFields(logrus.Fields{
+		"collection": collectionName,
+		"documents":  len(docs),
+	}).Debug("stored documents")
+
+	return nil
+}
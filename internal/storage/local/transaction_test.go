@@ -0,0 +1,167 @@
+package local
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// stagingDirs returns the staging directories left in the storage
+func stagingDirs(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, stagingPrefix+"*"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	return matches
+}
+
+func relatedBatch() []*models.Vector {
+	return []*models.Vector{
+		{ID: "doc", Embedding: []float64{0, 1}, Metadata: map[string]string{"v": "2"}},
+		{ID: "doc#1", Embedding: []float64{1, 1}},
+		{ID: "doc#2", Embedding: []float64{1, 2}},
+	}
+}
+
+func TestAdapter_StoreAllFailingVectorStoresNothing(t *testing.T) {
+	for _, compression := range []string{"", CompressionGzip} {
+		t.Run("compression="+compression, func(t *testing.T) {
+			dir := t.TempDir()
+			adapter, err := NewVectorStorageAdapterWithOptions(dir, "vectors", nil, Options{Compression: compression})
+			if err != nil {
+				t.Fatalf("failed to create adapter: %v", err)
+			}
+			if err := adapter.Store(&models.Vector{ID: "doc", Embedding: []float64{1, 0}, Metadata: map[string]string{"v": "1"}}); err != nil {
+				t.Fatalf("store: %v", err)
+			}
+
+			failures := map[string]func(v *models.Vector){
+				// Rejected before anything is written
+				"invalid": func(v *models.Vector) { v.ID = "bad\x00id" },
+				// Rejected while its files are written to the staging directory
+				"unencodable": func(v *models.Vector) { v.Embedding = []float64{math.NaN(), 1} },
+			}
+			for name, fail := range failures {
+				for k := 0; k < 3; k++ {
+					batch := relatedBatch()
+					fail(batch[k])
+					if err := adapter.StoreAll(batch); err == nil {
+						t.Fatalf("%s vector at %d: batch was stored", name, k)
+					}
+
+					if adapter.Count() != 1 || adapter.Generation() != 1 {
+						t.Errorf("%s vector at %d: count = %d, generation = %d, want 1 and 1", name, k, adapter.Count(), adapter.Generation())
+					}
+					if v, err := adapter.Get("doc"); err != nil || v.Metadata["v"] != "1" || v.Embedding[0] != 1 {
+						t.Errorf("%s vector at %d: replaced vector = %+v, %v", name, k, v, err)
+					}
+				}
+			}
+			if dirs := stagingDirs(t, dir); len(dirs) != 0 {
+				t.Errorf("staging directories left behind: %v", dirs)
+			}
+
+			// Nothing of the failed batches survives a reload either
+			reopened, err := NewVectorStorageAdapter(dir, "vectors")
+			if err != nil {
+				t.Fatalf("failed to reopen adapter: %v", err)
+			}
+			for _, id := range []string{"doc#1", "doc#2"} {
+				if _, err := reopened.Get(id); err == nil {
+					t.Errorf("%s visible after reload", id)
+				}
+			}
+		})
+	}
+}
+
+func TestAdapter_StoreAll(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	if err := adapter.Store(&models.Vector{ID: "doc", Embedding: []float64{1, 0}}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	batch := relatedBatch()
+	batch[2].Embedding = nil
+	if err := adapter.StoreAll(batch); err != nil {
+		t.Fatalf("store all: %v", err)
+	}
+	if adapter.Generation() != 2 {
+		t.Errorf("generation = %d, want 2: a batch is one mutation", adapter.Generation())
+	}
+
+	reopened, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if reopened.Count() != 3 || reopened.CountPending("") != 1 {
+		t.Errorf("count = %d, pending = %d, want 3 and 1", reopened.Count(), reopened.CountPending(""))
+	}
+	if v, err := reopened.Get("doc"); err != nil || v.Metadata["v"] != "2" || v.Embedding[1] != 1 {
+		t.Errorf("doc = %+v, %v", v, err)
+	}
+
+	mixed := []*models.Vector{{ID: "a", Embedding: []float64{1, 0}}, {ID: "b", Embedding: []float64{1, 0, 0}}}
+	if err := adapter.StoreAll(mixed); err == nil || !strings.Contains(err.Error(), "dimension mismatch") {
+		t.Errorf("batch mixing dimensions: err = %v", err)
+	}
+}
+
+func TestTransaction_RollbackRestoresReplacedFiles(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	if err := adapter.Store(&models.Vector{ID: "doc", Embedding: []float64{1, 0}, Metadata: map[string]string{"v": "1"}}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	docPath, _ := adapter.localStorage.getDocumentPath("vectors", "doc")
+	before, err := os.ReadFile(docPath)
+	if err != nil {
+		t.Fatalf("read document: %v", err)
+	}
+
+	tx, err := adapter.localStorage.beginTransaction()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	docs := []*Document{
+		vectorDocument(&models.Vector{ID: "doc", Embedding: []float64{0, 1}, Metadata: map[string]string{"v": "2"}}, 2),
+		vectorDocument(&models.Vector{ID: "doc#1"}, 0),
+	}
+	for _, doc := range docs {
+		if err := tx.stageDocument("vectors", doc); err != nil {
+			t.Fatalf("stage: %v", err)
+		}
+	}
+	if err := tx.commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	tx.rollback()
+	tx.close()
+
+	after, err := os.ReadFile(docPath)
+	if err != nil || string(after) != string(before) {
+		t.Errorf("document after rollback = %s, %v", after, err)
+	}
+	if v, err := adapter.Get("doc"); err != nil || v.Embedding[0] != 1 {
+		t.Errorf("embedding after rollback = %+v, %v", v, err)
+	}
+	newPath, _ := adapter.localStorage.getDocumentPath("vectors", "doc#1")
+	if _, err := os.Stat(newPath); !os.IsNotExist(err) {
+		t.Errorf("new document left after rollback: %v", err)
+	}
+	if dirs := stagingDirs(t, dir); len(dirs) != 0 {
+		t.Errorf("staging directories left behind: %v", dirs)
+	}
+}
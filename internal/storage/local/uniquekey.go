@@ -0,0 +1,127 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+	"github.com/tahcohcat/same-same/internal/storage/uniquekey"
+)
+
+// keyIndex returns the unique key index of a collection, building it from the
+// document index the first time it is needed after a load, import or reconcile
+// Returns nil when the collection has no unique keys. Caller must hold the write lock
+func (c *Collection) keyIndex() (*uniquekey.Index, error) {
+	if len(c.UniqueKeys) == 0 || c.uniqueIndex != nil {
+		return c.uniqueIndex, nil
+	}
+
+	docs := make([]uniquekey.Keyed, 0, len(c.Documents))
+	for id, doc := range c.Documents {
+		docs = append(docs, uniquekey.Keyed{ID: id, Metadata: convertInterfaceToStringMap(doc.Metadata)})
+	}
+	index, err := uniquekey.Build(c.UniqueKeys, docs)
+	if err != nil {
+		return nil, fmt.Errorf("collection %s holds duplicate unique keys: %w", c.Name, err)
+	}
+	c.uniqueIndex = index
+	return index, nil
+}
+
+// documentKeys returns the metadata of a document in the collection, for uniquekey lookups
+func (c *Collection) documentKeys(id string) (map[string]string, bool) {
+	doc, ok := c.Documents[id]
+	if !ok {
+		return nil, false
+	}
+	return convertInterfaceToStringMap(doc.Metadata), true
+}
+
+// reserveUniqueKeys checks that storing docs gives none of their unique values to a
+// second document and records them. Caller must hold the write lock and call
+// invalidateKeyIndex if the documents are not stored after all
+func reserveUniqueKeys(collection *Collection, docs ...*Document) error {
+	index, err := collection.keyIndex()
+	if err != nil || index == nil {
+		return err
+	}
+
+	keyed := make([]uniquekey.Keyed, len(docs))
+	for i, doc := range docs {
+		keyed[i] = uniquekey.Keyed{ID: doc.ID, Metadata: convertInterfaceToStringMap(doc.Metadata)}
+	}
+	if err := index.Check(keyed, collection.documentKeys); err != nil {
+		return err
+	}
+	index.Apply(keyed, collection.documentKeys)
+	return nil
+}
+
+// invalidateKeyIndex drops the unique key index so it is rebuilt from the document index
+func (c *Collection) invalidateKeyIndex() {
+	c.uniqueIndex = nil
+}
+
+// UniqueKeys returns the unique metadata fields of a collection
+func (ls *LocalStorage) UniqueKeys(collectionName string) ([]string, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+	return append([]string{}, collection.UniqueKeys...), nil
+}
+
+// SetUniqueKeys declares and persists the unique metadata fields of a collection
+// It fails, keeping the previous fields, if stored documents already share a value
+func (ls *LocalStorage) SetUniqueKeys(collectionName string, fields []string) error {
+	if err := uniquekey.ValidateFields(fields); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	previous := collection.UniqueKeys
+	collection.UniqueKeys = append([]string(nil), fields...)
+	collection.invalidateKeyIndex()
+	if _, err := collection.keyIndex(); err != nil {
+		collection.UniqueKeys = previous
+		collection.invalidateKeyIndex()
+		return err
+	}
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// GetDocumentByKey returns the document of namespace holding value for a unique field
+func (ls *LocalStorage) GetDocumentByKey(collectionName, namespace, field, value string) (*Document, error) {
+	// The index may have to be built, so this takes the write lock
+	ls.mu.Lock()
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		ls.mu.Unlock()
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+	index, err := collection.keyIndex()
+	if err == nil && index == nil {
+		err = fmt.Errorf("%w: %s", uniquekey.ErrNotUnique, field)
+	}
+	var id string
+	if err == nil {
+		id, err = index.Lookup(namespace, field, value)
+	}
+	ls.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return ls.GetDocument(collectionName, id)
+}
@@ -0,0 +1,57 @@
+package local
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/uniquekey"
+)
+
+func TestUniqueKeys_PersistAndEnforce(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "tickets")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	ticket := func(id, value string) *models.Vector {
+		return &models.Vector{ID: id, Embedding: []float64{1, 0}, Metadata: map[string]string{"ticket_id": value}}
+	}
+
+	if err := adapter.SetUniqueKeys([]string{"ticket_id"}); err != nil {
+		t.Fatalf("set unique keys: %v", err)
+	}
+	if err := adapter.Store(ticket("a", "T-1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.Store(ticket("b", "T-1")); !errors.Is(err, uniquekey.ErrDuplicate) {
+		t.Fatalf("store duplicate: error = %v", err)
+	}
+	adapter.Close()
+
+	// The fields survive a restart and the index is rebuilt from the documents
+	reopened, err := NewVectorStorageAdapter(dir, "tickets")
+	if err != nil {
+		t.Fatalf("failed to reopen adapter: %v", err)
+	}
+	if fields := reopened.UniqueKeys(); len(fields) != 1 || fields[0] != "ticket_id" {
+		t.Fatalf("unique keys = %v", fields)
+	}
+	got, err := reopened.GetByKey("", "ticket_id", "T-1")
+	if err != nil || got.ID != "a" || len(got.Embedding) != 2 {
+		t.Fatalf("get by key = %+v, %v", got, err)
+	}
+	if err := reopened.Store(ticket("b", "T-1")); !errors.Is(err, uniquekey.ErrDuplicate) {
+		t.Fatalf("store duplicate after reopen: error = %v", err)
+	}
+
+	if err := reopened.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Store(ticket("b", "T-1")); err != nil {
+		t.Errorf("store after delete: %v", err)
+	}
+	if _, err := reopened.GetByKey("", "title", "x"); !errors.Is(err, uniquekey.ErrNotUnique) {
+		t.Errorf("expected ErrNotUnique, got %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Verify check names, in report order
+const (
+	VerifyCheckSchema     = "schema"     // metadata.json parses
+	VerifyCheckReferences = "references" // every indexed document has its file
+	VerifyCheckDocuments  = "documents"  // sampled document files parse and match their entry
+	VerifyCheckEmbeddings = "embeddings" // sampled embeddings parse and have their declared dimension
+	VerifyCheckChecksums  = "checksums"  // sampled binary content matches its checksum
+	VerifyCheckStats      = "stats"      // collection counters match the index and the files
+)
+
+// VerifyOptions configures a verify run
+type VerifyOptions struct {
+	Collections []string `json:"collections,omitempty"` // Collections to verify, every indexed collection when empty
+	Sample      float64  `json:"sample"`                // Fraction of documents whose files are read, 0 or 1 reads all
+	Seed        int64    `json:"seed,omitempty"`        // Seed of the sample, random when 0
+}
+
+// VerifyIssue is a piece of corruption found by a check
+type VerifyIssue struct {
+	Collection string `json:"collection,omitempty"`
+	ID         string `json:"id,omitempty"`
+	File       string `json:"file,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// VerifyCheck is the outcome of one check
+type VerifyCheck struct {
+	Name    string        `json:"name"`
+	Checked int           `json:"checked"`
+	Failed  int           `json:"failed"`
+	Issues  []VerifyIssue `json:"issues"`
+}
+
+// VerifyReport summarizes a verify run
+type VerifyReport struct {
+	Sample      float64        `json:"sample"`
+	Collections int            `json:"collections"`
+	Documents   int            `json:"documents"` // Indexed documents
+	Sampled     int            `json:"sampled"`   // Documents whose files were read
+	Checks      []*VerifyCheck `json:"checks"`
+	Corrupted   int            `json:"corrupted"` // Failures over all checks
+	Duration    string         `json:"duration"`
+}
+
+// OK reports whether no check failed
+func (r *VerifyReport) OK() bool {
+	return r.Corrupted == 0
+}
+
+// verifyRun collects the checks of a run
+type verifyRun struct {
+	ls     *LocalStorage
+	checks map[string]*VerifyCheck
+}
+
+func (v *verifyRun) pass(check string) {
+	v.checks[check].Checked++
+}
+
+func (v *verifyRun) fail(check string, issue VerifyIssue) {
+	c := v.checks[check]
+	c.Checked++
+	c.Failed++
+	c.Issues = append(c.Issues, issue)
+}
+
+// Verify checks the integrity of the local store at basePath without changing it
+// It can run on a directory no LocalStorage has opened, such as a copy that
+// does not load. The files of a sample of the documents are read in full
+func Verify(basePath string, opts VerifyOptions) (*VerifyReport, error) {
+	if _, err := os.Stat(basePath); err != nil {
+		return nil, err
+	}
+
+	schema := &StorageSchema{}
+	data, err := os.ReadFile(filepath.Join(basePath, MetadataFile))
+	if err == nil {
+		err = json.Unmarshal(data, schema)
+	}
+	return verifySchema(&LocalStorage{basePath: basePath}, schema, err, opts)
+}
+
+// Verify checks the integrity of the store files against the loaded index
+// The read lock is held throughout so the index and files are consistent,
+// which blocks writers: use a sample on large stores
+func (ls *LocalStorage) Verify(opts VerifyOptions) (*VerifyReport, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	return verifySchema(ls, ls.schema, nil, opts)
+}
+
+// verifySchema runs every check against schema, schemaErr is the error reading it
+func verifySchema(ls *LocalStorage, schema *StorageSchema, schemaErr error, opts VerifyOptions) (*VerifyReport, error) {
+	start := time.Now()
+	if opts.Sample < 0 || opts.Sample > 1 {
+		return nil, fmt.Errorf("sample must be between 0 and 1, got %v", opts.Sample)
+	}
+	if opts.Sample == 0 {
+		opts.Sample = 1
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	report := &VerifyReport{Sample: opts.Sample}
+	run := &verifyRun{ls: ls, checks: make(map[string]*VerifyCheck)}
+	for _, name := range []string{VerifyCheckSchema, VerifyCheckReferences, VerifyCheckDocuments, VerifyCheckEmbeddings, VerifyCheckChecksums, VerifyCheckStats} {
+		check := &VerifyCheck{Name: name, Issues: []VerifyIssue{}}
+		run.checks[name] = check
+		report.Checks = append(report.Checks, check)
+	}
+
+	if schemaErr != nil {
+		run.fail(VerifyCheckSchema, VerifyIssue{File: MetadataFile, Reason: schemaErr.Error()})
+	} else {
+		run.pass(VerifyCheckSchema)
+
+		names := opts.Collections
+		if len(names) == 0 {
+			for name := range schema.Collections {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+		for _, name := range names {
+			collection, exists := schema.Collections[name]
+			if !exists {
+				return nil, storeerr.NotFoundf("collection %s not found", name)
+			}
+			report.Collections++
+			report.Documents += len(collection.Documents)
+			report.Sampled += run.verifyCollection(name, collection, opts.Sample, rng)
+		}
+	}
+
+	for _, check := range report.Checks {
+		report.Corrupted += check.Failed
+	}
+	report.Duration = time.Since(start).String()
+	return report, nil
+}
+
+// verifyCollection checks one collection, returning the number of documents sampled
+func (v *verifyRun) verifyCollection(name string, collection *Collection, sample float64, rng *rand.Rand) int {
+	ids := make([]string, 0, len(collection.Documents))
+	for id := range collection.Documents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	dimension := 0
+	if collection.Schema != nil && collection.Schema.VectorConfig != nil {
+		dimension = collection.Schema.VectorConfig.Dimension
+	}
+
+	sampled := 0
+	for _, id := range ids {
+		docPath, err := v.ls.getDocumentPath(name, id)
+		if err != nil {
+			v.fail(VerifyCheckReferences, VerifyIssue{Collection: name, ID: id, Reason: err.Error()})
+			continue
+		}
+		if !fileExists(docPath) {
+			v.fail(VerifyCheckReferences, VerifyIssue{Collection: name, ID: id, File: v.relative(docPath), Reason: "document file missing"})
+			continue
+		}
+		v.pass(VerifyCheckReferences)
+
+		if sample < 1 && rng.Float64() >= sample {
+			continue
+		}
+		sampled++
+		v.verifyDocument(name, id, docPath, collection.Documents[id], dimension)
+	}
+
+	v.verifyStats(name, collection)
+	return sampled
+}
+
+// verifyDocument reads the document, embedding and content files of an indexed document
+func (v *verifyRun) verifyDocument(collectionName, id, docPath string, entry *Document, dimension int) {
+	issue := func(path, reason string) VerifyIssue {
+		return VerifyIssue{Collection: collectionName, ID: id, File: v.relative(path), Reason: reason}
+	}
+
+	doc, err := readDocumentFile(filepath.Dir(docPath), filepath.Base(docPath))
+	if err != nil {
+		v.fail(VerifyCheckDocuments, issue(docPath, err.Error()))
+		return
+	}
+	if doc.ID != id {
+		v.fail(VerifyCheckDocuments, issue(docPath, fmt.Sprintf("document file holds ID %q", doc.ID)))
+		return
+	}
+	v.pass(VerifyCheckDocuments)
+
+	// Document files keep the vector they were written with, the index points at
+	// the embedding file that search reads, so both are checked
+	var embeddings []*EmbeddingData
+	var paths []string
+	if doc.Embedding != nil && doc.Embedding.hasValues() {
+		embeddings = append(embeddings, doc.Embedding)
+		paths = append(paths, docPath)
+	}
+	separate := (doc.Embedding != nil && doc.Embedding.Path != "") || (entry != nil && entry.Embedding != nil && entry.Embedding.Path != "")
+	if separate {
+		embedding, path, err := v.readEmbedding(collectionName, id)
+		if err != nil {
+			v.fail(VerifyCheckEmbeddings, issue(path, err.Error()))
+		} else {
+			embeddings = append(embeddings, embedding)
+			paths = append(paths, path)
+		}
+	}
+
+	for i, embedding := range embeddings {
+		// Sparse embeddings of unknown dimension fit any collection
+		unknown := embedding.Sparse != nil && embedding.Dimension == 0
+		switch {
+		case embedding.Sparse != nil && embedding.Sparse.Validate() != nil:
+			v.fail(VerifyCheckEmbeddings, issue(paths[i], fmt.Sprintf("invalid sparse embedding: %v", embedding.Sparse.Validate())))
+		case embedding.Sparse != nil && embedding.Sparse.Dimension != embedding.Dimension:
+			v.fail(VerifyCheckEmbeddings, issue(paths[i], fmt.Sprintf("sparse embedding has dimension %d but declares %d", embedding.Sparse.Dimension, embedding.Dimension)))
+		case embedding.Sparse == nil && len(embedding.Vector) != embedding.Dimension:
+			v.fail(VerifyCheckEmbeddings, issue(paths[i], fmt.Sprintf("embedding has %d values but declares %d dimensions", len(embedding.Vector), embedding.Dimension)))
+		case unknown:
+			v.pass(VerifyCheckEmbeddings)
+		case entry != nil && entry.Embedding != nil && entry.Embedding.Dimension != 0 && entry.Embedding.Dimension != embedding.Dimension:
+			v.fail(VerifyCheckEmbeddings, issue(paths[i], fmt.Sprintf("embedding has %d dimensions but the index declares %d", embedding.Dimension, entry.Embedding.Dimension)))
+		case dimension > 0 && embedding.Dimension != dimension:
+			v.fail(VerifyCheckEmbeddings, issue(paths[i], fmt.Sprintf("embedding has %d dimensions but the collection expects %d", embedding.Dimension, dimension)))
+		default:
+			v.pass(VerifyCheckEmbeddings)
+		}
+	}
+
+	if doc.Content != nil && doc.Content.Binary != nil && doc.Content.Binary.Checksum != "" {
+		if _, err := v.ls.ReadBinaryContent(doc.Content.Binary); err != nil {
+			v.fail(VerifyCheckChecksums, issue(doc.Content.Binary.Path, err.Error()))
+		} else {
+			v.pass(VerifyCheckChecksums)
+		}
+	}
+}
+
+// readEmbedding reads the separate embedding file of a document
+func (v *verifyRun) readEmbedding(collectionName, id string) (*EmbeddingData, string, error) {
+	path, err := v.ls.getEmbeddingPath(collectionName, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	file, err := openFile(path)
+	if err != nil {
+		return nil, path, fmt.Errorf("embedding file missing: %w", err)
+	}
+	defer file.Close()
+
+	var embedding EmbeddingData
+	if err := json.NewDecoder(file).Decode(&embedding); err != nil {
+		return nil, path, fmt.Errorf("unreadable embedding file: %w", err)
+	}
+	return &embedding, path, nil
+}
+
+// verifyStats compares the document counter of a collection with its index and files
+func (v *verifyRun) verifyStats(name string, collection *Collection) {
+	issue := VerifyIssue{Collection: name}
+
+	if collection.Stats.DocumentCount != len(collection.Documents) {
+		issue.Reason = fmt.Sprintf("document_count is %d but the index holds %d documents", collection.Stats.DocumentCount, len(collection.Documents))
+		v.fail(VerifyCheckStats, issue)
+		return
+	}
+
+	dir, err := v.ls.resolvePath(CollectionsDir, name)
+	if err == nil {
+		var files map[string]int64
+		if files, err = listDataFiles(dir); err == nil && len(files) != len(collection.Documents) {
+			issue.File = v.relative(dir)
+			issue.Reason = fmt.Sprintf("the index holds %d documents but there are %d document files", len(collection.Documents), len(files))
+			v.fail(VerifyCheckStats, issue)
+			return
+		}
+	}
+	if err != nil {
+		issue.Reason = err.Error()
+		v.fail(VerifyCheckStats, issue)
+		return
+	}
+	v.pass(VerifyCheckStats)
+}
+
+// relative returns path relative to the storage directory for reports
+func (v *verifyRun) relative(path string) string {
+	if rel, err := filepath.Rel(filepath.Clean(v.ls.basePath), path); err == nil {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,98 @@
+package local
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func failedChecks(report *VerifyReport) map[string]int {
+	failed := make(map[string]int)
+	for _, check := range report.Checks {
+		if check.Failed > 0 {
+			failed[check.Name] = check.Failed
+		}
+	}
+	return failed
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	adapter, err := NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	storeVectors(t, adapter, map[string][]float64{
+		"a": {1, 0},
+		"b": {0, 1},
+		"c": {1, 1},
+		"d": {0.5, 0.5},
+	})
+
+	content, err := adapter.localStorage.WriteBinaryContent("vectors", "d", "bin", []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := adapter.localStorage.GetDocument("vectors", "d")
+	doc.Content = &ContentData{Type: TypeCustom, Binary: content}
+	if err := adapter.localStorage.saveDocument("vectors", doc); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(dir, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if !report.OK() || report.Documents != 4 || report.Sampled != 4 {
+		t.Fatalf("clean store report = %+v, failed %v", report, failedChecks(report))
+	}
+
+	// Lose a document file, corrupt an embedding and a content file
+	if err := os.Remove(filepath.Join(dir, CollectionsDir, "vectors", "a.json")); err != nil {
+		t.Fatal(err)
+	}
+	embedding, _ := json.Marshal(EmbeddingData{Vector: []float64{1, 1, 1}, Dimension: 2})
+	if err := os.WriteFile(filepath.Join(dir, EmbeddingsDir, "vectors", "b.json"), embedding, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(content.Path, []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = Verify(dir, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	want := map[string]int{VerifyCheckReferences: 1, VerifyCheckEmbeddings: 1, VerifyCheckChecksums: 1, VerifyCheckStats: 1}
+	got := failedChecks(report)
+	for check, count := range want {
+		if got[check] != count {
+			t.Errorf("%s failures = %d, want %d (all: %v)", check, got[check], count, got)
+		}
+	}
+	if report.OK() || report.Corrupted != 4 {
+		t.Errorf("corrupted = %d, want 4", report.Corrupted)
+	}
+
+	// A sample reads fewer files but references are still checked in full
+	report, err = Verify(dir, VerifyOptions{Sample: 0.01, Seed: 1})
+	if err != nil {
+		t.Fatalf("sampled verify failed: %v", err)
+	}
+	if report.Sampled >= 3 || failedChecks(report)[VerifyCheckReferences] != 1 {
+		t.Errorf("sampled report = %+v", report)
+	}
+
+	// An unreadable index is reported rather than returned as an error
+	if err := os.WriteFile(filepath.Join(dir, MetadataFile), []byte("{broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err = Verify(dir, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if failedChecks(report)[VerifyCheckSchema] != 1 {
+		t.Errorf("broken schema report = %v", failedChecks(report))
+	}
+}
@@ -0,0 +1,58 @@
+package local
+
+import (
+	"io"
+	"os"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// getVocabularyPath returns the file of the embedder vocabulary saved with a collection
+func (ls *LocalStorage) getVocabularyPath(collectionName string) (string, error) {
+	if err := ValidateCollectionName(collectionName); err != nil {
+		return "", storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+	return ls.resolvePath(VocabularyDir, collectionName+".json")
+}
+
+// Vocabulary returns the embedder vocabulary saved with a collection, nil when there is none
+func (ls *LocalStorage) Vocabulary(collectionName string) ([]byte, error) {
+	path, err := ls.getVocabularyPath(collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	file, err := openFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// SaveVocabulary replaces the embedder vocabulary saved with a collection
+func (ls *LocalStorage) SaveVocabulary(collectionName string, data []byte) error {
+	path, err := ls.getVocabularyPath(collectionName)
+	if err != nil {
+		return err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	file, err := ls.createFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
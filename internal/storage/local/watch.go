@@ -0,0 +1,58 @@
+package local
+
+import (
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+	"github.com/tahcohcat/same-same/internal/storage/watch"
+)
+
+// Watches returns copies of the watches of a collection, with their inboxes
+func (ls *LocalStorage) Watches(collectionName string) ([]*watch.Watch, error) {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return nil, storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	watches := make([]*watch.Watch, len(collection.Watches))
+	for i, w := range collection.Watches {
+		watches[i] = w.Copy(true)
+	}
+	return watches, nil
+}
+
+// SaveWatch creates or replaces a watch of a collection and persists it
+func (ls *LocalStorage) SaveWatch(collectionName string, w *watch.Watch) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	collection.Watches = watch.Put(collection.Watches, w.Copy(true))
+
+	// Already holding lock
+	return ls.saveSchema()
+}
+
+// DeleteWatch removes a watch from a collection and persists the change
+func (ls *LocalStorage) DeleteWatch(collectionName, id string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	collection, exists := ls.schema.Collections[collectionName]
+	if !exists {
+		return storeerr.NotFoundf("collection %s not found", collectionName)
+	}
+
+	var found bool
+	if collection.Watches, found = watch.Remove(collection.Watches, id); !found {
+		return watch.NotFound(id)
+	}
+
+	// Already holding lock
+	return ls.saveSchema()
+}
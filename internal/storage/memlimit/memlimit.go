@@ -0,0 +1,170 @@
+// Package memlimit caps the vectors an in-memory storage holds, rejecting writes
+// past the limits or evicting vectors to make room for them
+package memlimit
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Policy chooses what happens to a write that takes the storage over a limit
+type Policy string
+
+// Supported policies
+const (
+	// PolicyReject fails the write with an *Error, the default
+	PolicyReject Policy = "reject"
+	// PolicyEvictLRU drops the least recently read or written vectors
+	PolicyEvictLRU Policy = "evict-lru"
+	// PolicyEvictOldest drops the vectors with the oldest CreatedAt
+	PolicyEvictOldest Policy = "evict-oldest"
+)
+
+// Options caps the vectors held, a zero field is unlimited
+type Options struct {
+	MaxVectors int    `json:"max_vectors,omitempty"`
+	MaxBytes   int64  `json:"max_bytes,omitempty"`
+	Policy     Policy `json:"policy"`
+}
+
+// Validate rejects negative limits and unknown policies
+func (o Options) Validate() error {
+	if o.MaxVectors < 0 {
+		return fmt.Errorf("max_vectors cannot be negative")
+	}
+	if o.MaxBytes < 0 {
+		return fmt.Errorf("max_bytes cannot be negative")
+	}
+	switch o.Policy {
+	case "", PolicyReject, PolicyEvictLRU, PolicyEvictOldest:
+		return nil
+	default:
+		return fmt.Errorf("unknown memory policy %q (supported: %s, %s, %s)", o.Policy, PolicyReject, PolicyEvictLRU, PolicyEvictOldest)
+	}
+}
+
+// withDefaults fills the policy when left empty
+func (o Options) withDefaults() Options {
+	if o.Policy == "" {
+		o.Policy = PolicyReject
+	}
+	return o
+}
+
+// SizeOf estimates the bytes a vector holds: its embedding values, ID and metadata
+func SizeOf(vector *models.Vector) int64 {
+	size := quota.Of(vector).Bytes + int64(len(vector.ID))
+	for key, value := range vector.Metadata {
+		size += int64(len(key) + len(value))
+	}
+	return size
+}
+
+// Error reports a write rejected by a memory limit
+type Error struct {
+	Resource  string `json:"resource"` // "vectors" or "bytes"
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Requested int64  `json:"requested"`
+	Policy    Policy `json:"policy"`
+}
+
+func (e *Error) Error() string {
+	if e.Policy != PolicyReject {
+		return fmt.Sprintf("memory limit exceeded: %d more %s requested than can be evicted under a limit of %d (%d used)",
+			e.Requested, e.Resource, e.Limit, e.Used)
+	}
+	return fmt.Sprintf("memory limit exceeded: %d %s used of %d, %d more requested", e.Used, e.Resource, e.Limit, e.Requested)
+}
+
+// Is makes every memory limit error match storeerr.ErrQuotaExceeded
+func (e *Error) Is(target error) bool {
+	return target == storeerr.ErrQuotaExceeded
+}
+
+// Candidate is a stored vector that may be evicted
+type Candidate struct {
+	ID         string
+	Bytes      int64
+	LastAccess uint64 // Logical time of the last read or write, larger is more recent
+	CreatedAt  time.Time
+}
+
+// over returns the resource usage exceeds, and "" when within the limits
+// Only growing resources are checked, so shrinking writes pass a lowered limit
+func (o Options) over(usage, delta quota.Usage) string {
+	if o.MaxVectors > 0 && delta.Vectors > 0 && usage.Vectors > o.MaxVectors {
+		return "vectors"
+	}
+	if o.MaxBytes > 0 && delta.Bytes > 0 && usage.Bytes > o.MaxBytes {
+		return "bytes"
+	}
+	return ""
+}
+
+// Victims returns the IDs of the candidates to evict so that applying delta to
+// used stays within the limits, or an *Error when the policy rejects the write
+// or evicting every candidate is not enough. Candidates are only listed when
+// the write does not fit
+func Victims(opts Options, used, delta quota.Usage, candidates func() []Candidate) ([]string, error) {
+	opts = opts.withDefaults()
+	after := used.Add(delta)
+	resource := opts.over(after, delta)
+	if resource == "" {
+		return nil, nil
+	}
+
+	exceeded := func(resource string) error {
+		e := &Error{Resource: resource, Policy: opts.Policy, Limit: int64(opts.MaxVectors), Used: int64(used.Vectors), Requested: int64(delta.Vectors)}
+		if resource == "bytes" {
+			e.Limit, e.Used, e.Requested = opts.MaxBytes, used.Bytes, delta.Bytes
+		}
+		return e
+	}
+	if opts.Policy == PolicyReject {
+		return nil, exceeded(resource)
+	}
+
+	ordered := candidates()
+	sort.Slice(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if opts.Policy == PolicyEvictOldest && !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.LastAccess != b.LastAccess {
+			return a.LastAccess < b.LastAccess
+		}
+		return a.ID < b.ID
+	})
+
+	victims := make([]string, 0)
+	for _, c := range ordered {
+		if opts.over(after, delta) == "" {
+			break
+		}
+		after = after.Sub(quota.Usage{Vectors: 1, Bytes: c.Bytes})
+		victims = append(victims, c.ID)
+	}
+	if resource := opts.over(after, delta); resource != "" {
+		return nil, exceeded(resource)
+	}
+	return victims, nil
+}
+
+// Report is the configured limits and estimated usage of a storage
+type Report struct {
+	Options
+	Usage      quota.Usage `json:"usage"`
+	Evictions  uint64      `json:"evictions"`
+	Rejections uint64      `json:"rejections"`
+}
+
+// NewReport returns the report of a storage, with the default policy filled in
+func NewReport(opts Options, usage quota.Usage, evictions, rejections uint64) Report {
+	return Report{Options: opts.withDefaults(), Usage: usage, Evictions: evictions, Rejections: rejections}
+}
@@ -0,0 +1,78 @@
+package memlimit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+func TestSizeOf(t *testing.T) {
+	vector := &models.Vector{ID: "ab", Embedding: []float64{1, 2, 3}, Metadata: map[string]string{"k": "val"}}
+	if got, want := SizeOf(vector), int64(3*8+2+4); got != want {
+		t.Errorf("SizeOf = %d, want %d", got, want)
+	}
+}
+
+func TestVictims(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	candidates := func() []Candidate {
+		return []Candidate{
+			{ID: "a", Bytes: 10, LastAccess: 5, CreatedAt: base},
+			{ID: "b", Bytes: 10, LastAccess: 1, CreatedAt: base.Add(time.Hour)},
+			{ID: "c", Bytes: 30, LastAccess: 3, CreatedAt: base.Add(2 * time.Hour)},
+		}
+	}
+	used := quota.Usage{Vectors: 3, Bytes: 50}
+	one := quota.Usage{Vectors: 1, Bytes: 10}
+
+	tests := []struct {
+		name  string
+		opts  Options
+		delta quota.Usage
+		want  []string
+		err   bool
+	}{
+		{"unlimited", Options{}, one, nil, false},
+		{"within limits", Options{MaxVectors: 4, MaxBytes: 60}, one, nil, false},
+		{"reject", Options{MaxVectors: 3}, one, nil, true},
+		{"default policy rejects", Options{MaxBytes: 55}, one, nil, true},
+		{"evict lru", Options{MaxVectors: 3, Policy: PolicyEvictLRU}, one, []string{"b"}, false},
+		{"evict oldest", Options{MaxVectors: 3, Policy: PolicyEvictOldest}, one, []string{"a"}, false},
+		{"evict until bytes fit", Options{MaxBytes: 40, Policy: PolicyEvictLRU}, one, []string{"b", "c"}, false},
+		{"more than can be evicted", Options{MaxBytes: 40, Policy: PolicyEvictLRU}, quota.Usage{Vectors: 1, Bytes: 50}, nil, true},
+		{"shrinking write passes a lowered limit", Options{MaxVectors: 1}, quota.Usage{Bytes: -5}, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := Victims(tt.opts, used, tt.delta, candidates)
+		if tt.err {
+			var limitErr *Error
+			if !errors.As(err, &limitErr) || !errors.Is(err, storeerr.ErrQuotaExceeded) {
+				t.Errorf("%s: err = %v, want a memory limit error", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%s: victims = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	for _, opts := range []Options{{MaxVectors: -1}, {MaxBytes: -1}, {Policy: "evict-random"}} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted", opts)
+		}
+	}
+	if err := (Options{MaxVectors: 10, Policy: PolicyEvictOldest}).Validate(); err != nil {
+		t.Errorf("valid options rejected: %v", err)
+	}
+}
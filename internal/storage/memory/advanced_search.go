@@ -1,9 +1,8 @@
 package memory
 
 import (
-	"sort"
-
 	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/search"
 
 	"github.com/sirupsen/logrus"
 )
@@ -13,79 +12,71 @@ func (ms *Storage) AdvancedSearch(req *models.AdvancedSearchRequest, queryEmbedd
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	var results []*models.SearchResult
-	evaluator := models.NewFilterEvaluator()
-	queryVector := &models.Vector{Embedding: queryEmbedding}
+	evaluator := &models.FilterEvaluator{KeyFallback: req.UsesKeyFallback()}
+	filters, err := evaluator.CompileSearch(req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	queryVector := models.NewQueryVector(queryEmbedding, req.SparseQuery)
+	namespace := namespaceQuery(req.Namespace)
+	metric := req.Metric
+	if metric == "" {
+		metric = search.MetricCosine
+	}
 
 	ctxLog := logrus.WithFields(logrus.Fields{
-		"query_length": len(queryEmbedding),
+		"query_length": queryVector.Dimension(),
 		"filters":      len(req.Filters),
 	})
 
-	for _, vector := range ms.vectors {
+	results := search.TopK(ms.candidates(req.Candidates), req.TopK, func(vector *models.Vector) (*models.SearchResult, bool) {
+		// Pending vectors have nothing to compare with
+		if !vector.HasEmbedding() {
+			return nil, false
+		}
+
 		// Check embedding dimension compatibility
-		if len(vector.Embedding) != len(queryEmbedding) {
+		if !queryVector.Compatible(vector) {
 			ctxLog.WithFields(logrus.Fields{
 				"skipped_vector_id":     vector.ID,
-				"skipped_vector_length": len(vector.Embedding),
+				"skipped_vector_length": vector.Dimension(),
 			}).Warn("skipping vector due to embedding length mismatch")
-			continue
+			return nil, false
+		}
+
+		if !matchesMetadata(vector.Metadata, namespace) {
+			return nil, false
 		}
 
 		// Apply metadata filters
-		if !evaluator.Evaluate(vector.Metadata, req.Filters) {
+		if !evaluator.Matches(vector.Metadata, filters) {
 			ctxLog.WithFields(logrus.Fields{
 				"skipped_vector_id":       vector.ID,
 				"skipped_vector_metadata": vector.Metadata,
 			}).Debug("skipping vector due to metadata filter mismatch")
-			continue
+			return nil, false
 		}
 
-		// Calculate similarity score
-		vectorScore := queryVector.CosineSimilarity(vector)
+		// Calculate similarity score, or distance for the euclidean metric
+		vectorScore := search.Score(metric, queryVector, vector)
 
-		// Apply hybrid weighting if specified
-		finalScore := vectorScore
-		if req.Options != nil && req.Options.HybridWeight != nil {
-			hw := req.Options.HybridWeight
-			metadataScore := ms.calculateMetadataScore(vector.Metadata, req.Filters)
-			finalScore = (hw.Vector * vectorScore) + (hw.Metadata * metadataScore)
+		// Apply hybrid weighting and soft filter preferences
+		match := evaluator.MatchSoft(vector.Metadata, filters)
+		finalScore := search.WeightedScore(vectorScore, req.Options, match, req.SoftFilterPenalty(), metric)
+		finalScore, explanation := req.Scoring.Adjust(finalScore, vector)
+		if explanation != nil {
+			explanation.SoftFilters = match
 		}
 
-		results = append(results, &models.SearchResult{
-			Vector: vector,
-			Score:  finalScore,
-		})
-	}
+		return &models.SearchResult{
+			Vector:      vector,
+			Score:       finalScore,
+			Explanation: explanation,
+		}, true
+	}, search.Better(metric)) // best first, then by ID so ties do not depend on map order
 
-	ctxLog.WithField("matched_vectors", len(results)).Debug("advanced search completed")
-
-	// Sort by score descending
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
-
-	// Limit results
-	if req.TopK > 0 && len(results) > req.TopK {
-		results = results[:req.TopK]
-	}
-
-	ctxLog.WithField("returned_vectors", len(results)).Debug("results limited")
+	ctxLog.WithField("returned_vectors", len(results)).Debug("advanced search completed")
+	ms.touchResults(results)
 
 	return results, nil
 }
-
-// calculateMetadataScore provides a simple metadata matching score
-// Returns 1.0 if all filters match perfectly, 0.0 otherwise
-func (ms *Storage) calculateMetadataScore(metadata map[string]string, filters map[string]models.FilterExpr) float64 {
-	if len(filters) == 0 {
-		return 1.0
-	}
-
-	evaluator := models.NewFilterEvaluator()
-	if evaluator.Evaluate(metadata, filters) {
-		return 1.0
-	}
-
-	return 0.0
-}
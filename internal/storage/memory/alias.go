@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"github.com/tahcohcat/same-same/internal/storage/alias"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Aliases returns the namespace aliases
+func (ms *Storage) Aliases() alias.Aliases {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	aliases := make(alias.Aliases, len(ms.aliases))
+	for name, a := range ms.aliases {
+		aliases[name] = a
+	}
+	return aliases
+}
+
+// ResolveAlias returns the namespace an alias points at
+func (ms *Storage) ResolveAlias(name string) (string, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	a, ok := ms.aliases[name]
+	return a.Namespace, ok
+}
+
+// SetAlias creates an alias or retargets it, in a single step for readers
+func (ms *Storage) SetAlias(a alias.Alias) error {
+	if err := a.Validate(); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.aliases[a.Name] = a
+	return nil
+}
+
+// DeleteAlias removes an alias, leaving its namespace as it is
+func (ms *Storage) DeleteAlias(name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.aliases[name]; !ok {
+		return alias.NotFound(name)
+	}
+	delete(ms.aliases, name)
+	return nil
+}
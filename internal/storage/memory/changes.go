@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+)
+
+// Changes returns the vectors of namespace updated at or after since and the
+// tombstones of those deleted since, or of all namespaces if namespace is empty
+func (ms *Storage) Changes(namespace string, since time.Time) (*tombstone.Changes, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	changes := &tombstone.Changes{
+		Deleted: ms.tombstones.Since(since, namespace),
+		Horizon: ms.tombstones.Horizon,
+	}
+	query := namespaceQuery(namespace)
+	for _, vector := range ms.vectors {
+		if !vector.UpdatedAt.Before(since) && matchesMetadata(vector.Metadata, query) {
+			changes.Vectors = append(changes.Vectors, vector)
+		}
+	}
+	tombstone.SortByUpdate(changes.Vectors)
+	return changes, nil
+}
+
+// SetTombstoneOptions sets the retention of deleted vector IDs and compacts the log
+func (ms *Storage) SetTombstoneOptions(opts tombstone.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.tombstoneOpts = opts
+	ms.tombstones.Compact(time.Now(), opts)
+	return nil
+}
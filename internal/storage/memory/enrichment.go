@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/tahcohcat/same-same/internal/storage/enrichment"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// GetEnrichment returns the enrichment documents of keys, omitting unknown keys
+func (ms *Storage) GetEnrichment(keys []string) (map[string]json.RawMessage, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	docs := make(map[string]json.RawMessage, len(keys))
+	for _, key := range keys {
+		if doc, ok := ms.enrichment[key]; ok {
+			docs[key] = doc
+		}
+	}
+	return docs, nil
+}
+
+// SetEnrichment creates or replaces enrichment documents
+func (ms *Storage) SetEnrichment(entries map[string]json.RawMessage) error {
+	for key, doc := range entries {
+		if err := enrichment.Validate(key, doc); err != nil {
+			return storeerr.Wrap(storeerr.ErrValidation, err)
+		}
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.enrichment == nil {
+		ms.enrichment = make(map[string]json.RawMessage, len(entries))
+	}
+	for key, doc := range entries {
+		// Copied so the caller can reuse its buffers
+		ms.enrichment[key] = append(json.RawMessage(nil), doc...)
+	}
+	return nil
+}
+
+// DeleteEnrichment removes an enrichment document
+func (ms *Storage) DeleteEnrichment(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.enrichment[key]; !ok {
+		return enrichment.NotFound(key)
+	}
+	delete(ms.enrichment, key)
+	return nil
+}
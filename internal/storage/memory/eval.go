@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// EvalSets returns the evaluation sets
+func (ms *Storage) EvalSets() eval.Sets {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	sets := make(eval.Sets, len(ms.evalSets))
+	for name, set := range ms.evalSets {
+		sets[name] = set
+	}
+	return sets
+}
+
+// CreateEvalSet adds an evaluation set, failing with eval.ErrExists if the name is taken
+func (ms *Storage) CreateEvalSet(set eval.Set) error {
+	if err := set.Validate(); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, exists := ms.evalSets[set.Name]; exists {
+		return fmt.Errorf("%w: %s", eval.ErrExists, set.Name)
+	}
+	ms.evalSets[set.Name] = set
+	return nil
+}
+
+// DeleteEvalSet removes an evaluation set and its runs
+func (ms *Storage) DeleteEvalSet(name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.evalSets[name]; !ok {
+		return eval.NotFound(name)
+	}
+	delete(ms.evalSets, name)
+	ms.evalRuns = eval.WithoutSet(ms.evalRuns, name)
+	return nil
+}
+
+// RecordEvalRun adds an evaluation run to the run history
+func (ms *Storage) RecordEvalRun(run *eval.Run) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.evalRuns = eval.AppendRun(ms.evalRuns, run)
+	return nil
+}
+
+// ListEvalRuns returns the runs of an evaluation set, newest first
+func (ms *Storage) ListEvalRuns(set string) ([]*eval.Run, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return eval.RunsOf(ms.evalRuns, set), nil
+}
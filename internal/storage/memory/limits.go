@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SetMemoryLimits caps the vectors held, zero limits remove the caps
+// Lowering a limit below the current usage keeps the stored vectors until the next write
+func (ms *Storage) SetMemoryLimits(opts memlimit.Options) error {
+	if err := opts.Validate(); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.memOpts = opts
+	return nil
+}
+
+// MemoryReport returns the memory limits, the estimated usage and the evicted and rejected writes
+func (ms *Storage) MemoryReport() memlimit.Report {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return memlimit.NewReport(ms.memOpts, ms.memUsage, ms.evictions, ms.rejections)
+}
+
+// memoryVictims returns the vectors to evict before storing vectors, or the error
+// rejecting the write. Caller must hold the write lock
+func (ms *Storage) memoryVictims(vectors []*models.Vector) ([]string, error) {
+	if ms.memOpts.MaxVectors == 0 && ms.memOpts.MaxBytes == 0 {
+		return nil, nil
+	}
+
+	var delta quota.Usage
+	incoming := make(map[string]*models.Vector, len(vectors))
+	for _, vector := range vectors {
+		if old, seen := incoming[vector.ID]; seen {
+			delta = delta.Sub(quota.Usage{Vectors: 1, Bytes: memlimit.SizeOf(old)})
+		} else if old, ok := ms.vectors[vector.ID]; ok {
+			delta = delta.Sub(quota.Usage{Vectors: 1, Bytes: memlimit.SizeOf(old)})
+		}
+		delta = delta.Add(quota.Usage{Vectors: 1, Bytes: memlimit.SizeOf(vector)})
+		incoming[vector.ID] = vector
+	}
+
+	// Listing the candidates scans every vector, only done when the write does not fit
+	candidates := func() []memlimit.Candidate {
+		list := make([]memlimit.Candidate, 0, len(ms.vectors))
+		for id, vector := range ms.vectors {
+			if _, replaced := incoming[id]; replaced {
+				continue
+			}
+			list = append(list, memlimit.Candidate{
+				ID:         id,
+				Bytes:      memlimit.SizeOf(vector),
+				LastAccess: ms.lastAccess[id].Load(),
+				CreatedAt:  vector.CreatedAt,
+			})
+		}
+		return list
+	}
+
+	victims, err := memlimit.Victims(ms.memOpts, ms.memUsage, delta, candidates)
+	if err != nil {
+		ms.rejections++
+		return nil, err
+	}
+	return victims, nil
+}
+
+// evict removes the vectors chosen by memoryVictims, recording tombstones so
+// delta listings see them gone. Caller must hold the write lock
+func (ms *Storage) evict(ids []string, now time.Time) {
+	for _, id := range ids {
+		ms.remove(ms.vectors[id], now)
+		ms.evictions++
+	}
+	if len(ids) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"evicted": len(ids),
+			"policy":  ms.memOpts.Policy,
+		}).Debug("vectors evicted to stay within memory limits")
+	}
+}
+
+// remove deletes a stored vector from the storage and its indexes
+// Caller must hold the write lock and bump the generation
+func (ms *Storage) remove(vector *models.Vector, now time.Time) {
+	delete(ms.vectors, vector.ID)
+	ms.tombstones.Add(tombstone.Of(vector, now), ms.tombstoneOpts)
+	ms.unique.Remove(vector.ID, vector.Metadata)
+	ms.recent.Remove(vector.ID)
+	ms.fields.Remove(vector.Metadata)
+	namespace := quota.Namespace(vector)
+	ms.usage[namespace] = ms.usage[namespace].Sub(quota.Of(vector))
+	ms.memUsage = ms.memUsage.Sub(quota.Usage{Vectors: 1, Bytes: memlimit.SizeOf(vector)})
+	delete(ms.lastAccess, vector.ID)
+}
+
+// track counts a vector about to be stored in the memory usage, replacing the
+// version it overwrites, and marks it accessed. Caller must hold the write lock
+func (ms *Storage) track(vector *models.Vector) {
+	if old, ok := ms.vectors[vector.ID]; ok {
+		ms.memUsage = ms.memUsage.Sub(quota.Usage{Vectors: 1, Bytes: memlimit.SizeOf(old)})
+	} else {
+		ms.lastAccess[vector.ID] = new(atomic.Uint64)
+	}
+	ms.memUsage = ms.memUsage.Add(quota.Usage{Vectors: 1, Bytes: memlimit.SizeOf(vector)})
+	ms.touch(vector.ID)
+}
+
+// touch marks a vector as accessed for the evict-lru policy
+// Safe under the read lock: the map only changes under the write lock
+func (ms *Storage) touch(id string) {
+	if access, ok := ms.lastAccess[id]; ok {
+		access.Store(ms.clock.Add(1))
+	}
+}
+
+// touchResults marks the vectors of search results as accessed, caller must hold the lock
+func (ms *Storage) touchResults(results []*models.SearchResult) {
+	for _, result := range results {
+		ms.touch(result.Vector.ID)
+	}
+}
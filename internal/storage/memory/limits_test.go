@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+)
+
+func fill(t *testing.T, store *Storage, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := store.Store(&models.Vector{ID: id, Embedding: []float64{1, 0}}); err != nil {
+			t.Fatalf("store %s: %v", id, err)
+		}
+	}
+}
+
+func TestMemoryLimitReject(t *testing.T) {
+	store := NewStorage()
+	if err := store.SetMemoryLimits(memlimit.Options{MaxVectors: 2}); err != nil {
+		t.Fatalf("set limits: %v", err)
+	}
+	fill(t, store, "a", "b")
+
+	err := store.Store(&models.Vector{ID: "c", Embedding: []float64{1, 0}})
+	var limitErr *memlimit.Error
+	if !errors.As(err, &limitErr) || !errors.Is(err, quota.ErrExceeded) || limitErr.Resource != "vectors" {
+		t.Fatalf("store past the cap: err = %v", err)
+	}
+	if err := store.StoreBatch([]*models.Vector{{ID: "c", Embedding: []float64{1, 0}}}); err == nil {
+		t.Errorf("batch past the cap accepted")
+	}
+
+	// Overwriting a stored vector does not grow the count
+	fill(t, store, "a")
+
+	report := store.MemoryReport()
+	if store.Count() != 2 || report.Usage.Vectors != 2 || report.Rejections != 2 || report.Evictions != 0 {
+		t.Errorf("count = %d, report = %+v", store.Count(), report)
+	}
+	if report.Policy != memlimit.PolicyReject {
+		t.Errorf("policy = %q, want the default %q", report.Policy, memlimit.PolicyReject)
+	}
+}
+
+func TestMemoryLimitEvictLRU(t *testing.T) {
+	store := NewStorage()
+	store.SetMemoryLimits(memlimit.Options{MaxVectors: 3, Policy: memlimit.PolicyEvictLRU})
+	fill(t, store, "a", "b", "c")
+
+	// Reading a keeps it, b is then the least recently used
+	if _, err := store.Get("a"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	fill(t, store, "d")
+	if _, err := store.Get("b"); err == nil {
+		t.Errorf("least recently used vector kept")
+	}
+
+	// Search results count as accesses
+	store.Search(&models.SearchByEmbbedingRequest{Embedding: []float64{1, 0}, TopK: 3})
+	fill(t, store, "e")
+
+	report := store.MemoryReport()
+	if store.Count() != 3 || report.Evictions != 2 || report.Usage.Vectors != 3 {
+		t.Errorf("count = %d, report = %+v", store.Count(), report)
+	}
+	changes, _ := store.Changes("", time.Time{})
+	if len(changes.Deleted) != 2 {
+		t.Errorf("tombstones = %v, want the 2 evicted vectors", changes.Deleted)
+	}
+}
+
+func TestMemoryLimitEvictOldest(t *testing.T) {
+	store := NewStorage()
+	store.SetMemoryLimits(memlimit.Options{MaxVectors: 2, Policy: memlimit.PolicyEvictOldest})
+
+	base := time.Now().Add(-time.Hour)
+	store.StoreBatch([]*models.Vector{
+		{ID: "new", Embedding: []float64{1, 0}, CreatedAt: base.Add(time.Minute)},
+		{ID: "old", Embedding: []float64{1, 0}, CreatedAt: base},
+	})
+	store.Get("old")
+	fill(t, store, "next")
+
+	if _, err := store.Get("old"); err == nil {
+		t.Errorf("oldest vector kept")
+	}
+	if _, err := store.Get("new"); err != nil {
+		t.Errorf("newer vector evicted: %v", err)
+	}
+}
+
+func TestMemoryLimitBytes(t *testing.T) {
+	vector := func(id string) *models.Vector {
+		return &models.Vector{ID: id, Embedding: make([]float64, 10), Metadata: map[string]string{"k": "v"}}
+	}
+	size := memlimit.SizeOf(vector("a"))
+
+	store := NewStorage()
+	store.SetMemoryLimits(memlimit.Options{MaxBytes: 2*size + size/2, Policy: memlimit.PolicyEvictOldest})
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.Store(vector(id)); err != nil {
+			t.Fatalf("store %s: %v", id, err)
+		}
+	}
+	if report := store.MemoryReport(); store.Count() != 2 || report.Usage.Bytes > report.MaxBytes {
+		t.Errorf("count = %d, report = %+v", store.Count(), report)
+	}
+
+	// A batch larger than the cap cannot be made room for
+	batch := []*models.Vector{vector("x"), vector("y"), vector("z")}
+	if err := store.StoreBatch(batch); !errors.Is(err, quota.ErrExceeded) {
+		t.Errorf("oversized batch: err = %v", err)
+	}
+	if store.Count() != 2 {
+		t.Errorf("rejected batch evicted vectors, count = %d", store.Count())
+	}
+}
+
+func TestMemoryLimitConcurrentSearch(t *testing.T) {
+	store := NewStorage()
+	store.SetMemoryLimits(memlimit.Options{MaxVectors: 50, Policy: memlimit.PolicyEvictLRU})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				store.Store(&models.Vector{ID: fmt.Sprintf("w%d-%d", w, i), Embedding: []float64{float64(i), 1}})
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				results, err := store.Search(&models.SearchByEmbbedingRequest{Embedding: []float64{1, 1}, TopK: 5})
+				if err != nil {
+					t.Errorf("search: %v", err)
+					return
+				}
+				for _, result := range results {
+					store.Get(result.Vector.ID)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := store.MemoryReport()
+	if store.Count() != 50 || report.Usage.Vectors != 50 || report.Evictions != 800-50 {
+		t.Errorf("count = %d, report = %+v", store.Count(), report)
+	}
+}
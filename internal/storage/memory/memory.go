@@ -1,24 +1,72 @@
 package memory
 
 import (
-	"fmt"
+	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/alias"
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
+	"github.com/tahcohcat/same-same/internal/storage/metaschema"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/recency"
 	"github.com/tahcohcat/same-same/internal/storage/search"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+	"github.com/tahcohcat/same-same/internal/storage/uniquekey"
 
 	"github.com/sirupsen/logrus"
 )
 
 type Storage struct {
-	vectors map[string]*models.Vector
-	mu      sync.RWMutex
+	vectors       map[string]*models.Vector
+	generation    uint64 // bumped on every mutation, guarded by mu
+	limits        quota.Limits
+	usage         map[string]quota.Usage // kept up to date on every mutation so quota checks are cheap
+	unique        *uniquekey.Index       // nil when no metadata field is unique
+	recent        *recency.Index         // vectors by creation time, for newest-first listings
+	fields        *metaschema.Index      // summary of the metadata fields, for schema discovery
+	runs          []*models.IngestRun
+	profiles      profile.Profiles
+	aliases       alias.Aliases
+	quarantine    quarantine.Queue
+	evalSets      eval.Sets
+	evalRuns      []*eval.Run
+	enrichment    map[string]json.RawMessage // enrichment documents by key
+	tombstones    tombstone.Log
+	tombstoneOpts tombstone.Options
+	memOpts       memlimit.Options
+	memUsage      quota.Usage // estimated size of every vector, see memlimit.SizeOf
+	evictions     uint64
+	rejections    uint64
+	clock         atomic.Uint64                // logical time of vector accesses
+	lastAccess    map[string]*atomic.Uint64    // keys change under the write lock, values are set under the read lock
+	scan          atomic.Pointer[scanSnapshot] // vectors as a slice for searches, rebuilt under the read lock when stale
+	mu            sync.RWMutex
+}
+
+// scanSnapshot is the slice of the stored vectors at a generation
+type scanSnapshot struct {
+	generation uint64
+	vectors    []*models.Vector
 }
 
 func NewStorage() *Storage {
 	return &Storage{
-		vectors: make(map[string]*models.Vector),
+		vectors:    make(map[string]*models.Vector),
+		limits:     make(quota.Limits),
+		usage:      make(map[string]quota.Usage),
+		profiles:   make(profile.Profiles),
+		aliases:    make(alias.Aliases),
+		evalSets:   make(eval.Sets),
+		recent:     &recency.Index{},
+		fields:     &metaschema.Index{},
+		lastAccess: make(map[string]*atomic.Uint64),
 	}
 }
 
@@ -28,8 +76,21 @@ func (ms *Storage) Store(vector *models.Vector) error {
 
 	now := time.Now()
 	if vector.ID == "" {
-		return fmt.Errorf("vector ID cannot be empty")
+		return storeerr.Validationf("vector ID cannot be empty")
+	}
+
+	if err := ms.unique.Check(uniquekey.Of(vector), ms.storedMetadata); err != nil {
+		return err
+	}
+	victims, err := ms.memoryVictims([]*models.Vector{vector})
+	if err != nil {
+		return err
 	}
+	if err := ms.reserve([]*models.Vector{vector}); err != nil {
+		return err
+	}
+	ms.evict(victims, now)
+	ms.unique.Apply(uniquekey.Of(vector), ms.storedMetadata)
 
 	if _, exists := ms.vectors[vector.ID]; exists {
 		vector.UpdatedAt = now
@@ -38,7 +99,12 @@ func (ms *Storage) Store(vector *models.Vector) error {
 		vector.UpdatedAt = now
 	}
 
+	vector.CacheNorm()
+	ms.track(vector)
+	ms.indexFields(vector)
 	ms.vectors[vector.ID] = vector
+	ms.recent.Add(recency.Of(vector))
+	ms.generation++
 
 	logrus.WithFields(logrus.Fields{
 		"vector_id":  vector.ID,
@@ -48,14 +114,74 @@ func (ms *Storage) Store(vector *models.Vector) error {
 	return nil
 }
 
+// StoreBatch stores multiple vectors under a single lock
+// Unlike Store, existing timestamps are preserved so restored data keeps its age.
+// No vector is stored if any of them has an empty ID, the batch exceeds a quota
+// or it gives a unique key value to two vectors
+func (ms *Storage) StoreBatch(vectors []*models.Vector) error {
+	for _, vector := range vectors {
+		if vector.ID == "" {
+			return storeerr.Validationf("vector ID cannot be empty")
+		}
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ms.unique.Check(uniquekey.Of(vectors...), ms.storedMetadata); err != nil {
+		return err
+	}
+	victims, err := ms.memoryVictims(vectors)
+	if err != nil {
+		return err
+	}
+	if err := ms.reserve(vectors); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	ms.evict(victims, now)
+	ms.unique.Apply(uniquekey.Of(vectors...), ms.storedMetadata)
+
+	for _, vector := range vectors {
+		if vector.CreatedAt.IsZero() {
+			vector.CreatedAt = now
+		}
+		if vector.UpdatedAt.IsZero() {
+			vector.UpdatedAt = now
+		}
+		vector.CacheNorm()
+		ms.track(vector)
+		ms.indexFields(vector)
+		ms.vectors[vector.ID] = vector
+		ms.recent.Add(recency.Of(vector))
+	}
+	ms.generation++
+
+	logrus.WithField("count", len(vectors)).Debug("vector batch stored")
+
+	return nil
+}
+
+// StoreAll stores related vectors all or nothing
+// Every vector is validated, pending ones included, before StoreBatch checks the
+// batch and swaps it in under one lock, so a failure leaves the storage unchanged
+func (ms *Storage) StoreAll(vectors []*models.Vector) error {
+	if err := models.ValidateBatch(vectors, true); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+	return ms.StoreBatch(vectors)
+}
+
 func (ms *Storage) Get(id string) (*models.Vector, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
 	vector, exists := ms.vectors[id]
 	if !exists {
-		return nil, fmt.Errorf("vector with ID %s not found", id)
+		return nil, storeerr.NotFoundf("vector with ID %s not found", id)
 	}
+	ms.touch(id)
 
 	logrus.WithFields(logrus.Fields{
 		"vector_id":  vector.ID,
@@ -66,18 +192,195 @@ func (ms *Storage) Get(id string) (*models.Vector, error) {
 	return vector, nil
 }
 
+// GetMany returns the vectors of ids in order under a single lock, nil for unknown IDs
+func (ms *Storage) GetMany(ids []string) ([]*models.Vector, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	vectors := make([]*models.Vector, len(ids))
+	for i, id := range ids {
+		if vector, exists := ms.vectors[id]; exists {
+			vectors[i] = vector
+			ms.touch(id)
+		}
+	}
+	return vectors, nil
+}
+
 func (ms *Storage) Delete(id string) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	if _, exists := ms.vectors[id]; !exists {
-		return fmt.Errorf("vector with ID %s not found", id)
+	vector, exists := ms.vectors[id]
+	if !exists {
+		return storeerr.NotFoundf("vector with ID %s not found", id)
 	}
 
-	delete(ms.vectors, id)
+	ms.remove(vector, time.Now())
+	ms.generation++
 	return nil
 }
 
+// DeleteBatch deletes the stored vectors of ids under a single lock, skipping
+// unknown IDs, and returns how many were deleted
+func (ms *Storage) DeleteBatch(ids []string) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	deleted := 0
+	for _, id := range ids {
+		if vector, exists := ms.vectors[id]; exists {
+			ms.remove(vector, now)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		ms.generation++
+	}
+	return deleted, nil
+}
+
+// Generation returns the number of mutations applied since the storage was created
+func (ms *Storage) Generation() uint64 {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.generation
+}
+
+// reserve checks that storing vectors keeps every namespace within its quota and updates the usage
+// Caller must hold the write lock and store all vectors once reserve succeeds
+func (ms *Storage) reserve(vectors []*models.Vector) error {
+	delta := quota.Delta(vectors, func(id string) (string, quota.Usage, bool) {
+		old, ok := ms.vectors[id]
+		if !ok {
+			return "", quota.Usage{}, false
+		}
+		return quota.Namespace(old), quota.Of(old), true
+	})
+	if err := ms.limits.Check(ms.usage, delta); err != nil {
+		return err
+	}
+	for namespace, change := range delta {
+		ms.usage[namespace] = ms.usage[namespace].Add(change)
+	}
+	return nil
+}
+
+// Quotas returns the configured namespace limits
+func (ms *Storage) Quotas() quota.Limits {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	limits := make(quota.Limits, len(ms.limits))
+	for namespace, limit := range ms.limits {
+		limits[namespace] = limit
+	}
+	return limits
+}
+
+// SetQuota sets the limit of a namespace, a zero limit removes it
+// Lowering a limit below the current usage keeps the stored vectors but rejects new ones
+func (ms *Storage) SetQuota(namespace string, limit quota.Limit) error {
+	if err := limit.Validate(); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if limit.IsZero() {
+		delete(ms.limits, namespace)
+	} else {
+		ms.limits[namespace] = limit
+	}
+	return nil
+}
+
+// QuotaUsage returns the vectors and embedding bytes stored per namespace
+func (ms *Storage) QuotaUsage() map[string]quota.Usage {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	usage := make(map[string]quota.Usage, len(ms.usage))
+	for namespace, used := range ms.usage {
+		if used.Vectors > 0 {
+			usage[namespace] = used
+		}
+	}
+	return usage
+}
+
+// RecordRun adds an ingest run to the run history
+func (ms *Storage) RecordRun(run *models.IngestRun) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.runs = append(ms.runs, run)
+	return nil
+}
+
+// ListRuns returns the ingest runs matching filter, newest first
+func (ms *Storage) ListRuns(filter models.IngestRunFilter) ([]*models.IngestRun, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return models.FilterIngestRuns(ms.runs, filter), nil
+}
+
+// storedMetadata returns the metadata of a stored vector, caller must hold the lock
+func (ms *Storage) storedMetadata(id string) (map[string]string, bool) {
+	vector, ok := ms.vectors[id]
+	if !ok {
+		return nil, false
+	}
+	return vector.Metadata, true
+}
+
+// UniqueKeys returns the metadata fields whose values are unique within a namespace
+func (ms *Storage) UniqueKeys() []string {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.unique.Fields()
+}
+
+// SetUniqueKeys declares the unique metadata fields, replacing the previous ones
+// It fails, keeping the previous fields, if stored vectors already share a value
+func (ms *Storage) SetUniqueKeys(fields []string) error {
+	if err := uniquekey.ValidateFields(fields); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	vectors := make([]*models.Vector, 0, len(ms.vectors))
+	for _, vector := range ms.vectors {
+		vectors = append(vectors, vector)
+	}
+	index, err := uniquekey.Build(fields, uniquekey.Of(vectors...))
+	if err != nil {
+		return err
+	}
+	ms.unique = index
+	return nil
+}
+
+// GetByKey returns the vector of namespace holding value for a unique field
+func (ms *Storage) GetByKey(namespace, field, value string) (*models.Vector, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	id, err := ms.unique.Lookup(namespace, field, value)
+	if err != nil {
+		return nil, err
+	}
+	ms.touch(id)
+	return ms.vectors[id], nil
+}
+
 func (ms *Storage) List() ([]*models.Vector, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
@@ -90,6 +393,22 @@ func (ms *Storage) List() ([]*models.Vector, error) {
 	return vectors, nil
 }
 
+// ListByNamespace returns the vectors in namespace, or all vectors if namespace is empty
+func (ms *Storage) ListByNamespace(namespace string) ([]*models.Vector, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	query := namespaceQuery(namespace)
+	vectors := make([]*models.Vector, 0)
+	for _, vector := range ms.vectors {
+		if matchesMetadata(vector.Metadata, query) {
+			vectors = append(vectors, vector)
+		}
+	}
+
+	return vectors, nil
+}
+
 func (ms *Storage) Count() int {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
@@ -97,15 +416,99 @@ func (ms *Storage) Count() int {
 	return len(ms.vectors)
 }
 
+// CountByNamespace returns the number of vectors in namespace, or all vectors if namespace is empty
+func (ms *Storage) CountByNamespace(namespace string) int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if namespace == "" {
+		return len(ms.vectors)
+	}
+
+	query := namespaceQuery(namespace)
+	count := 0
+	for _, vector := range ms.vectors {
+		if matchesMetadata(vector.Metadata, query) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// CountPending returns the number of vectors without embedding in namespace,
+// or all vectors if namespace is empty
+func (ms *Storage) CountPending(namespace string) int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	query := namespaceQuery(namespace)
+	count := 0
+	for _, vector := range ms.vectors {
+		if !vector.HasEmbedding() && matchesMetadata(vector.Metadata, query) {
+			count++
+		}
+	}
+
+	return count
+}
+
 func (ms *Storage) Search(req *models.SearchByEmbbedingRequest) ([]*models.SearchResult, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
 	// Use shared search utility
+	results := search.FilterAndScoreVectors(ms.candidates(nil), req)
+	ms.touchResults(results)
+	return results, nil
+}
+
+// candidates returns the vectors a search scores: those of ids when not nil, all of them otherwise
+// Caller must hold the lock
+func (ms *Storage) candidates(ids []string) []*models.Vector {
+	if ids == nil {
+		return ms.snapshot()
+	}
+	vectors := make([]*models.Vector, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if vector, ok := ms.vectors[id]; ok && !seen[id] {
+			seen[id] = true
+			vectors = append(vectors, vector)
+		}
+	}
+	return vectors
+}
+
+// snapshot returns the stored vectors as a slice, so searches can split them between
+// workers without copying the map each time. It is rebuilt after every mutation
+// Caller must hold the lock; the slice must not be modified
+func (ms *Storage) snapshot() []*models.Vector {
+	if snap := ms.scan.Load(); snap != nil && snap.generation == ms.generation {
+		return snap.vectors
+	}
 	vectors := make([]*models.Vector, 0, len(ms.vectors))
-	for _, v := range ms.vectors {
-		vectors = append(vectors, v)
+	for _, vector := range ms.vectors {
+		vectors = append(vectors, vector)
 	}
-	results := search.FilterAndScoreVectors(vectors, req)
-	return results, nil
+	ms.scan.Store(&scanSnapshot{generation: ms.generation, vectors: vectors})
+	return vectors
+}
+
+// namespaceQuery returns the metadata a vector must carry to belong to namespace
+func namespaceQuery(namespace string) map[string]string {
+	if namespace == "" {
+		return nil
+	}
+	return map[string]string{models.NamespaceKey: namespace}
+}
+
+// matchesMetadata checks that vectorMeta contains every key/value pair of queryMeta
+func matchesMetadata(vectorMeta, queryMeta map[string]string) bool {
+	for key, value := range queryMeta {
+		if v, ok := vectorMeta[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
 }
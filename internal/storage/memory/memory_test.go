@@ -1,7 +1,14 @@
 package memory
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+
 	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+	"github.com/tahcohcat/same-same/internal/storage/uniquekey"
 
 	"testing"
 )
@@ -69,3 +76,193 @@ func TestSearch_EmbeddingLengthMismatch(t *testing.T) {
 		t.Errorf("expected 0 results, got %d", len(results))
 	}
 }
+
+func TestGeneration(t *testing.T) {
+	store := NewStorage()
+
+	_ = store.Store(&models.Vector{ID: "v1", Embedding: []float64{1, 0}})
+	_ = store.StoreBatch([]*models.Vector{{ID: "v2", Embedding: []float64{0, 1}}, {ID: "v3", Embedding: []float64{1, 1}}})
+	_ = store.Delete("v1")
+	if got := store.Generation(); got != 3 {
+		t.Errorf("generation = %d, want 3", got)
+	}
+
+	// Failed mutations change nothing
+	_ = store.Delete("missing")
+	_ = store.Store(&models.Vector{})
+	if got := store.Generation(); got != 3 {
+		t.Errorf("generation after failed mutations = %d, want 3", got)
+	}
+}
+
+func TestQuota_RejectsAndResumes(t *testing.T) {
+	store := NewStorage()
+	if err := store.SetQuota("team", quota.Limit{MaxVectors: 2}); err != nil {
+		t.Fatalf("set quota: %v", err)
+	}
+
+	teamVector := func(id string) *models.Vector {
+		return &models.Vector{ID: id, Embedding: []float64{1, 0}, Metadata: map[string]string{models.NamespaceKey: "team"}}
+	}
+
+	for _, id := range []string{"a", "b"} {
+		if err := store.Store(teamVector(id)); err != nil {
+			t.Fatalf("store %s: %v", id, err)
+		}
+	}
+
+	if err := store.Store(teamVector("c")); !errors.Is(err, quota.ErrExceeded) {
+		t.Fatalf("store over quota: error = %v, want quota error", err)
+	}
+	if err := store.StoreBatch([]*models.Vector{teamVector("c"), teamVector("d")}); !errors.Is(err, quota.ErrExceeded) {
+		t.Fatalf("batch over quota: error = %v, want quota error", err)
+	}
+	if store.Count() != 2 {
+		t.Fatalf("count = %d after rejected writes, want 2", store.Count())
+	}
+
+	// Updating a stored vector does not grow the namespace
+	if err := store.Store(teamVector("a")); err != nil {
+		t.Errorf("update within quota: %v", err)
+	}
+	// Other namespaces are unaffected
+	if err := store.Store(&models.Vector{ID: "x", Embedding: []float64{1, 0}}); err != nil {
+		t.Errorf("store without namespace: %v", err)
+	}
+
+	if err := store.Delete("b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.Store(teamVector("c")); err != nil {
+		t.Errorf("store after delete: %v", err)
+	}
+
+	if got, want := store.QuotaUsage()["team"], (quota.Usage{Vectors: 2, Bytes: 32}); got != want {
+		t.Errorf("usage = %+v, want %+v", got, want)
+	}
+}
+
+func TestUniqueKeys(t *testing.T) {
+	store := NewStorage()
+	ticket := func(id, value string) *models.Vector {
+		return &models.Vector{ID: id, Embedding: []float64{1, 0}, Metadata: map[string]string{"ticket_id": value}}
+	}
+
+	for _, vector := range []*models.Vector{ticket("a", "T-1"), ticket("b", "T-1")} {
+		if err := store.Store(vector); err != nil {
+			t.Fatalf("store %s: %v", vector.ID, err)
+		}
+	}
+	// Declaring a field already shared by stored vectors fails
+	if err := store.SetUniqueKeys([]string{"ticket_id"}); !errors.Is(err, uniquekey.ErrDuplicate) {
+		t.Fatalf("set unique keys over duplicates: error = %v", err)
+	}
+	if err := store.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetUniqueKeys([]string{"ticket_id"}); err != nil {
+		t.Fatalf("set unique keys: %v", err)
+	}
+
+	if err := store.Store(ticket("c", "T-1")); !errors.Is(err, uniquekey.ErrDuplicate) {
+		t.Fatalf("store duplicate: error = %v", err)
+	}
+	if err := store.StoreBatch([]*models.Vector{ticket("c", "T-2"), ticket("d", "T-2")}); !errors.Is(err, uniquekey.ErrDuplicate) {
+		t.Fatalf("batch with duplicates: error = %v", err)
+	}
+	if store.Count() != 1 {
+		t.Fatalf("count = %d after rejected writes, want 1", store.Count())
+	}
+
+	got, err := store.GetByKey("", "ticket_id", "T-1")
+	if err != nil || got.ID != "a" {
+		t.Fatalf("get by key = %v, %v", got, err)
+	}
+
+	// Changing the value of a frees the old one
+	if err := store.Store(ticket("a", "T-3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Store(ticket("c", "T-1")); err != nil {
+		t.Errorf("store released value: %v", err)
+	}
+	if err := store.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetByKey("", "ticket_id", "T-1"); err == nil {
+		t.Error("expected deleted value to be gone")
+	}
+}
+
+func TestStoreAll_FailingVectorStoresNothing(t *testing.T) {
+	store := NewStorage()
+	if err := store.Store(&models.Vector{ID: "doc", Embedding: []float64{1, 0}, Metadata: map[string]string{"v": "1"}}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	for k := 0; k < 3; k++ {
+		batch := []*models.Vector{
+			{ID: "doc", Embedding: []float64{0, 1}, Metadata: map[string]string{"v": "2"}},
+			{ID: "doc#1", Embedding: []float64{1, 1}},
+			{ID: "doc#2", Embedding: []float64{1, 2}},
+		}
+		batch[k].ID = "bad\x00id"
+		if err := store.StoreAll(batch); err == nil {
+			t.Fatalf("batch failing at %d was stored", k)
+		}
+
+		if store.Count() != 1 || store.Generation() != 1 {
+			t.Errorf("failing at %d: count = %d, generation = %d, want 1 and 1", k, store.Count(), store.Generation())
+		}
+		if v, _ := store.Get("doc"); v.Metadata["v"] != "1" {
+			t.Errorf("failing at %d: replaced vector changed to %v", k, v.Metadata)
+		}
+	}
+
+	batch := []*models.Vector{{ID: "doc", Embedding: []float64{0, 1}}, {ID: "doc#1"}}
+	if err := store.StoreAll(batch); err != nil {
+		t.Fatalf("store all: %v", err)
+	}
+	if store.Count() != 2 || store.Generation() != 2 {
+		t.Errorf("count = %d, generation = %d, want 2 and 2", store.Count(), store.Generation())
+	}
+
+	if err := store.StoreAll([]*models.Vector{{ID: "x", Embedding: []float64{1}}, {ID: "x", Embedding: []float64{2}}}); err == nil {
+		t.Error("batch holding an ID twice was stored")
+	}
+}
+
+func TestSearch_ParallelScanUnderWrites(t *testing.T) {
+	search.SetParallelThreshold(1)
+	search.SetWorkers(4)
+	defer search.SetParallelThreshold(0)
+	defer search.SetWorkers(0)
+
+	store := NewStorage()
+	for i := 0; i < 500; i++ {
+		store.Store(&models.Vector{ID: fmt.Sprintf("v%03d", i), Embedding: []float64{1, float64(i)}})
+	}
+
+	// Searches see the vectors of a single generation while writes go on
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			store.Store(&models.Vector{ID: fmt.Sprintf("w%03d", i), Embedding: []float64{1, 0}})
+			store.Delete(fmt.Sprintf("w%03d", i))
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		results, err := store.AdvancedSearch(&models.AdvancedSearchRequest{TopK: 3}, []float64{0, 1})
+		if err != nil || len(results) != 3 || results[0].Vector.ID != "v499" {
+			t.Fatalf("search %d = %v, %v", i, results, err)
+		}
+	}
+	wg.Wait()
+
+	// Deleted vectors are gone from the cached scan
+	if results, _ := store.Search(&models.SearchByEmbbedingRequest{Embedding: []float64{1, 0}, TopK: 1}); len(results) != 1 || results[0].Vector.ID != "v000" {
+		t.Errorf("search after writes = %v", results)
+	}
+}
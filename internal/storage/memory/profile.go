@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Profiles returns the ranking profiles
+func (ms *Storage) Profiles() profile.Profiles {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	profiles := make(profile.Profiles, len(ms.profiles))
+	for name, p := range ms.profiles {
+		profiles[name] = p
+	}
+	return profiles
+}
+
+// SetProfile creates or replaces a ranking profile
+func (ms *Storage) SetProfile(p profile.Profile) error {
+	if err := p.Validate(); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.profiles[p.Name] = p
+	return nil
+}
+
+// DeleteProfile removes a ranking profile
+func (ms *Storage) DeleteProfile(name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.profiles[name]; !ok {
+		return profile.NotFound(name)
+	}
+	delete(ms.profiles, name)
+	return nil
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Quarantine keeps records that could not be stored, evicting the oldest past the limit
+func (ms *Storage) Quarantine(entries ...quarantine.Entry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.quarantine.Add(entries...)
+	return nil
+}
+
+// Quarantined returns the quarantined records, oldest first
+func (ms *Storage) Quarantined() []quarantine.Entry {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.quarantine.List()
+}
+
+// RemoveQuarantined drops quarantined records
+func (ms *Storage) RemoveQuarantined(ids ...string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.quarantine.Remove(ids...)
+}
+
+// QuarantineStats returns the size and limit of the quarantine
+func (ms *Storage) QuarantineStats() quarantine.Stats {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.quarantine.Stats()
+}
+
+// SetQuarantineLimit caps the quarantined records kept
+func (ms *Storage) SetQuarantineLimit(max int) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ms.quarantine.SetLimit(max); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+	return nil
+}
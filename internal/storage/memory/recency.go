@@ -0,0 +1,21 @@
+package memory
+
+import (
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// Recent returns the newest vectors of namespace, newest first, or of all
+// namespaces if namespace is empty
+func (ms *Storage) Recent(namespace string, limit int) ([]*models.Vector, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	ids := ms.recent.Newest(namespace, limit)
+	vectors := make([]*models.Vector, 0, len(ids))
+	for _, id := range ids {
+		if vector, ok := ms.vectors[id]; ok {
+			vectors = append(vectors, vector)
+		}
+	}
+	return vectors, nil
+}
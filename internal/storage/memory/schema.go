@@ -0,0 +1,33 @@
+package memory
+
+import (
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/metaschema"
+)
+
+// MetadataSchema summarizes the metadata fields of the vectors of namespace, or
+// of all namespaces if namespace is empty, from counters kept up to date on every write
+func (ms *Storage) MetadataSchema(namespace string) (*metaschema.Schema, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.fields.Schema(namespace), nil
+}
+
+// Facet counts the vectors of each value of a metadata field in namespace, or in
+// all namespaces if namespace is empty, from counters kept up to date on every write
+func (ms *Storage) Facet(namespace, field string, limit int) (*metaschema.Facet, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.fields.Facet(namespace, field, limit)
+}
+
+// indexFields counts the metadata of a vector about to be stored in the field
+// summary, replacing the version it overwrites. Caller must hold the write lock
+func (ms *Storage) indexFields(vector *models.Vector) {
+	if old, ok := ms.vectors[vector.ID]; ok {
+		ms.fields.Remove(old.Metadata)
+	}
+	ms.fields.Add(vector.Metadata)
+}
@@ -1,10 +1,8 @@
 package memory
 
 import (
-	"sort"
-	"time"
-
 	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/search"
 
 	"github.com/sirupsen/logrus"
 )
@@ -16,30 +14,40 @@ func (ms *Storage) TemporalSearch(req *models.TemporalSearchRequest, queryEmbedd
 
 	config := req.GetTemporalConfig()
 	scorer := models.NewTemporalScorer(config)
-	queryVector := &models.Vector{Embedding: queryEmbedding}
+	queryVector := models.NewQueryVector(queryEmbedding, req.SparseQuery)
+	namespace := namespaceQuery(req.Namespace)
 
 	ctxLog := logrus.WithFields(logrus.Fields{
-		"query_length":   len(queryEmbedding),
+		"query_length":   queryVector.Dimension(),
 		"temporal_decay": req.TemporalDecay,
 		"lambda":         config.Lambda,
 		"reference_time": config.ReferenceTime,
 	})
 
-	var results []*models.TemporalSearchResult
-
 	// Apply metadata filters if present
-	evaluator := models.NewFilterEvaluator()
+	evaluator := &models.FilterEvaluator{KeyFallback: req.UsesKeyFallback()}
+	filters, err := evaluator.CompileSearch(req.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	results := search.TopK(ms.candidates(req.Candidates), req.TopK, func(vector *models.Vector) (*models.TemporalSearchResult, bool) {
+		// Check embedding presence and dimension
+		if !vector.HasEmbedding() {
+			return nil, false
+		}
+		if !queryVector.Compatible(vector) {
+			return nil, false
+		}
 
-	for _, vector := range ms.vectors {
-		// Check embedding dimension
-		if len(vector.Embedding) != len(queryEmbedding) {
-			continue
+		if !matchesMetadata(vector.Metadata, namespace) {
+			return nil, false
 		}
 
 		// Apply metadata filters
 		if len(req.Filters) > 0 {
-			if !evaluator.Evaluate(vector.Metadata, req.Filters) {
-				continue
+			if !evaluator.Matches(vector.Metadata, filters) {
+				return nil, false
 			}
 		}
 
@@ -47,58 +55,41 @@ func (ms *Storage) TemporalSearch(req *models.TemporalSearchRequest, queryEmbedd
 		baseScore := queryVector.CosineSimilarity(vector)
 
 		// Get document time from metadata
-		documentTime := ms.getDocumentTime(vector, config.TimeField)
+		documentTime, source := models.DocumentTime(vector, config.TimeField, config.DefaultTime)
 
-		// Apply temporal decay
+		// Apply temporal decay, then soft filter preferences
 		finalScore := scorer.ApplyDecay(baseScore, documentTime)
 		decayFactor := scorer.GetDecayFactor(documentTime)
+		match := evaluator.MatchSoft(vector.Metadata, filters)
+		finalScore = search.WeightedScore(finalScore, nil, match, req.SoftFilterPenalty(), search.MetricCosine)
+		finalScore, explanation := req.Scoring.Adjust(finalScore, vector)
+		if explanation != nil {
+			explanation.SoftFilters = match
+		}
 
-		results = append(results, &models.TemporalSearchResult{
+		return &models.TemporalSearchResult{
 			Vector:       vector,
 			Score:        finalScore,
 			BaseScore:    baseScore,
 			DecayFactor:  decayFactor,
 			DocumentTime: documentTime,
-			Age:          models.CalculateAge(documentTime, config.ReferenceTime),
-		})
-	}
-
-	ctxLog.WithField("matched_vectors", len(results)).Debug("temporal search completed")
-
-	// Sort by final score (with decay applied)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
+			TimeSource:   source,
+			TimeFallback: source != models.TimeSourceField,
+			Age:          models.CalculateAge(documentTime, config.ReferenceTime, models.DefaultAgeLocale),
+			Explanation:  explanation,
+		}, true
+	}, func(a, b *models.TemporalSearchResult) bool {
+		// By final score (with decay and score adjusters applied), then by ID so ties do not depend on map order
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Vector.ID < b.Vector.ID
 	})
 
-	// Limit results
-	if req.TopK > 0 && len(results) > req.TopK {
-		results = results[:req.TopK]
+	ctxLog.WithField("returned_vectors", len(results)).Debug("temporal search completed")
+	for _, result := range results {
+		ms.touch(result.Vector.ID)
 	}
 
-	ctxLog.WithField("returned_vectors", len(results)).Debug("results limited")
-
 	return results, nil
 }
-
-// getDocumentTime extracts timestamp from metadata
-func (ms *Storage) getDocumentTime(vector *models.Vector, timeField string) time.Time {
-	// Try the specified time field
-	if timeStr, ok := vector.Metadata[timeField]; ok {
-		if t, err := time.Parse(time.RFC3339, timeStr); err == nil {
-			return t
-		}
-	}
-
-	// Fallback to created_at
-	if !vector.CreatedAt.IsZero() {
-		return vector.CreatedAt
-	}
-
-	// Fallback to updated_at
-	if !vector.UpdatedAt.IsZero() {
-		return vector.UpdatedAt
-	}
-
-	// Default to current time (no decay)
-	return time.Now()
-}
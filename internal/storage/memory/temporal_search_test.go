@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestTemporalSearch_LegacyVectorsDecay(t *testing.T) {
+	store := NewStorage()
+	_ = store.Store(&models.Vector{ID: "recent", Embedding: []float64{1, 0}})
+
+	// Vectors restored from old exports bypass Store and keep zero timestamps
+	store.vectors["legacy"] = &models.Vector{ID: "legacy", Embedding: []float64{1, 0}}
+
+	req := &models.TemporalSearchRequest{Query: "q", TemporalDecay: models.DecayStrong}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("invalid request: %v", err)
+	}
+
+	results, err := store.TemporalSearch(req, []float64{1, 0})
+	if err != nil {
+		t.Fatalf("temporal search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	recent, legacy := results[0], results[1]
+	if recent.Vector.ID != "recent" || legacy.Vector.ID != "legacy" {
+		t.Fatalf("order = %s, %s: legacy vector should decay below the recent one", recent.Vector.ID, legacy.Vector.ID)
+	}
+	if recent.TimeFallback || recent.TimeSource != models.TimeSourceField {
+		t.Errorf("recent vector time source = %s, fallback %v", recent.TimeSource, recent.TimeFallback)
+	}
+	if !legacy.TimeFallback || legacy.TimeSource != models.TimeSourceDefault || !legacy.DocumentTime.Equal(models.DefaultDocumentTime) {
+		t.Errorf("legacy vector time = %s (%s), fallback %v", legacy.DocumentTime, legacy.TimeSource, legacy.TimeFallback)
+	}
+
+	// The default time is configurable per request
+	def := time.Now().Add(-24 * time.Hour)
+	req.DefaultTime = models.NewTimeExpr(def)
+	results, err = store.TemporalSearch(req, []float64{1, 0})
+	if err != nil {
+		t.Fatalf("temporal search failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Vector.ID == "legacy" && !result.DocumentTime.Equal(def) {
+			t.Errorf("legacy document time = %s, want %s", result.DocumentTime, def)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+// Package metaschema summarizes the metadata fields of stored vectors per namespace,
+// shared by the storage backends so the summary is kept up to date as vectors are
+// stored and deleted instead of scanning every vector on request
+package metaschema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+const (
+	// MaxDistinct is the number of distinct values counted exactly per field and
+	// namespace. Fields with more are counted with sketches, in fixed memory
+	MaxDistinct = 100
+
+	// MaxExamples is the number of example values reported per field
+	MaxExamples = 5
+
+	// maxExampleChars caps the length of example values, which may be whole documents
+	maxExampleChars = 80
+)
+
+// ErrNotFound is matched with errors.Is by the errors of fields no vector has
+var ErrNotFound = fmt.Errorf("metadata field %w", storeerr.ErrNotFound)
+
+// NotFound returns the error of a field no vector has
+func NotFound(name string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Type is the type inferred from the values of a field
+type Type string
+
+const (
+	TypeNumeric Type = "numeric"
+	TypeDate    Type = "date"
+	TypeBool    Type = "bool"
+	TypeString  Type = "string"
+)
+
+// Field summarizes the values of a metadata field
+type Field struct {
+	Count    int          `json:"count"`    // Vectors having the field
+	Type     Type         `json:"type"`     // Type of every non-empty value, string when they disagree
+	Types    map[Type]int `json:"types"`    // Non-empty values of each type
+	Examples []string     `json:"examples"` // Most frequent values, truncated
+	Distinct int          `json:"distinct"` // Distinct values, estimated when DistinctEstimated
+	// DistinctCapped is set once the field had more than MaxDistinct distinct values
+	DistinctCapped bool `json:"distinct_capped,omitempty"`
+	// DistinctEstimated is set when Distinct is a HyperLogLog estimate, within Bounds
+	DistinctEstimated bool    `json:"distinct_estimated,omitempty"`
+	Bounds            *Bounds `json:"error_bounds,omitempty"`
+}
+
+// Facet counts the vectors having each value of a metadata field, most frequent first
+type Facet struct {
+	Namespace         string       `json:"namespace,omitempty"`
+	Field             string       `json:"field"`
+	Count             int          `json:"count"` // Vectors having the field, always exact
+	Distinct          int          `json:"distinct"`
+	DistinctEstimated bool         `json:"distinct_estimated,omitempty"`
+	Values            []FacetValue `json:"values"`
+	// CountsEstimated is set when the value counts are count-min estimates, never
+	// under the exact counts and over them by at most Bounds.Count
+	CountsEstimated bool    `json:"counts_estimated,omitempty"`
+	Bounds          *Bounds `json:"error_bounds,omitempty"`
+}
+
+// FacetValue is a value of a facet and the vectors having it
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Schema summarizes the metadata fields of the vectors of a namespace, or of all
+// of them. Fields are keyed by name, so redaction rules naming a field cover its summary
+type Schema struct {
+	Namespace string            `json:"namespace,omitempty"`
+	Vectors   int               `json:"vectors"`
+	Fields    map[string]*Field `json:"fields"`
+}
+
+// field is the running summary of a field in a namespace
+type field struct {
+	count  int
+	types  map[Type]int
+	values map[string]int // Vectors per distinct value while at most MaxDistinct, nil once sketched
+	sketch *sketch        // Approximate counts once the field had more distinct values
+}
+
+func newField() *field {
+	return &field{types: make(map[Type]int), values: make(map[string]int)}
+}
+
+// addValue counts n vectors having value, moving the counts to a sketch when the
+// field reaches more than MaxDistinct distinct values
+func (f *field) addValue(value string, n int) {
+	if f.sketch == nil {
+		if _, tracked := f.values[value]; tracked || len(f.values) < MaxDistinct {
+			f.values[value] += n
+			return
+		}
+		f.sketch = newSketch()
+		for v, count := range f.values {
+			f.sketch.add(v, count)
+		}
+		f.values = nil
+	}
+	f.sketch.add(value, n)
+}
+
+// removeValue uncounts a vector having value
+func (f *field) removeValue(value string) {
+	if f.sketch != nil {
+		f.sketch.remove(value)
+		return
+	}
+	if count, tracked := f.values[value]; tracked {
+		if count <= 1 {
+			delete(f.values, value)
+		} else {
+			f.values[value] = count - 1
+		}
+	}
+}
+
+// merge adds the counts of other to f
+func (f *field) merge(other *field) {
+	f.count += other.count
+	for t, count := range other.types {
+		f.types[t] += count
+	}
+	if other.sketch == nil {
+		for value, count := range other.values {
+			f.addValue(value, count)
+		}
+		return
+	}
+	if f.sketch == nil {
+		f.sketch = newSketch()
+		for value, count := range f.values {
+			f.sketch.add(value, count)
+		}
+		f.values = nil
+	}
+	f.sketch.merge(other.sketch)
+}
+
+// counts returns the counts of the non-empty values of the field, exact or of
+// the heavy hitters, most frequent first with ties broken by value
+func (f *field) counts() []FacetValue {
+	counts := f.values
+	if f.sketch != nil {
+		counts = f.sketch.heavy
+	}
+	values := make([]FacetValue, 0, len(counts))
+	for value, count := range counts {
+		if value != "" {
+			values = append(values, FacetValue{Value: value, Count: count})
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	return values
+}
+
+// distinct returns the number of distinct values, and whether it is estimated
+func (f *field) distinct() (int, bool) {
+	if f.sketch == nil {
+		return len(f.values), false
+	}
+	// The estimate cannot be below the values seen to be distinct
+	return max(f.sketch.distinct(), MaxDistinct+1), true
+}
+
+// namespaceFields are the fields of the vectors of a namespace
+type namespaceFields struct {
+	vectors int
+	fields  map[string]*field
+}
+
+// Index is the running summary of the metadata of the stored vectors
+// Callers add the metadata of every stored vector and remove that of every vector
+// deleted or replaced, so counts follow the stored data without a rescan
+// The zero value is an empty index ready to use, it is not safe for concurrent use
+type Index struct {
+	namespaces map[string]*namespaceFields
+}
+
+// Build returns the index of the metadata of vectors
+func Build(metadata []map[string]string) *Index {
+	idx := &Index{}
+	for _, m := range metadata {
+		idx.Add(m)
+	}
+	return idx
+}
+
+// Add counts the metadata of a stored vector
+func (idx *Index) Add(metadata map[string]string) {
+	if idx == nil {
+		return
+	}
+	if idx.namespaces == nil {
+		idx.namespaces = make(map[string]*namespaceFields)
+	}
+	namespace := metadata[models.NamespaceKey]
+	ns, ok := idx.namespaces[namespace]
+	if !ok {
+		ns = &namespaceFields{fields: make(map[string]*field)}
+		idx.namespaces[namespace] = ns
+	}
+
+	ns.vectors++
+	for name, value := range metadata {
+		f, ok := ns.fields[name]
+		if !ok {
+			f = newField()
+			ns.fields[name] = f
+		}
+		f.count++
+		if value != "" {
+			f.types[TypeOf(value)]++
+		}
+		f.addValue(value, 1)
+	}
+}
+
+// Remove uncounts the metadata of a deleted vector, or of the version a store replaces
+func (idx *Index) Remove(metadata map[string]string) {
+	if idx == nil {
+		return
+	}
+	namespace := metadata[models.NamespaceKey]
+	ns, ok := idx.namespaces[namespace]
+	if !ok {
+		return
+	}
+
+	ns.vectors--
+	for name, value := range metadata {
+		f, ok := ns.fields[name]
+		if !ok {
+			continue
+		}
+		f.count--
+		if f.count <= 0 {
+			delete(ns.fields, name)
+			continue
+		}
+		if value != "" {
+			t := TypeOf(value)
+			if f.types[t]--; f.types[t] <= 0 {
+				delete(f.types, t)
+			}
+		}
+		f.removeValue(value)
+	}
+	if ns.vectors <= 0 {
+		delete(idx.namespaces, namespace)
+	}
+}
+
+// Schema returns the summary of the fields of namespace, or of every namespace if it is empty
+func (idx *Index) Schema(namespace string) *Schema {
+	schema := &Schema{Namespace: namespace, Fields: make(map[string]*Field)}
+	if idx == nil {
+		return schema
+	}
+
+	fields, vectors := idx.merge(namespace, "")
+	for name, f := range fields {
+		schema.Fields[name] = f.summary()
+	}
+	schema.Vectors = vectors
+	return schema
+}
+
+// merge returns the fields of namespace, or of every namespace merged if it is
+// empty, only the field named only when it is set, with the number of vectors
+func (idx *Index) merge(namespace, only string) (map[string]*field, int) {
+	merged := make(map[string]*field)
+	vectors := 0
+	for name, ns := range idx.namespaces {
+		if namespace != "" && name != namespace {
+			continue
+		}
+		vectors += ns.vectors
+		for fieldName, f := range ns.fields {
+			if only != "" && fieldName != only {
+				continue
+			}
+			m, ok := merged[fieldName]
+			if !ok {
+				m = newField()
+				merged[fieldName] = m
+			}
+			m.merge(f)
+		}
+	}
+	return merged, vectors
+}
+
+// Facet returns the counts of the values of a field in namespace, or in every
+// namespace if it is empty, at most limit of them when limit is positive
+// It fails with ErrNotFound when no vector has the field
+func (idx *Index) Facet(namespace, name string, limit int) (*Facet, error) {
+	if idx == nil {
+		return nil, NotFound(name)
+	}
+	fields, _ := idx.merge(namespace, name)
+	f, ok := fields[name]
+	if !ok {
+		return nil, NotFound(name)
+	}
+
+	facet := &Facet{Namespace: namespace, Field: name, Count: f.count, Values: f.counts()}
+	facet.Distinct, facet.DistinctEstimated = f.distinct()
+	if limit > 0 && len(facet.Values) > limit {
+		facet.Values = facet.Values[:limit]
+	}
+	if f.sketch != nil {
+		facet.CountsEstimated = true
+		facet.Bounds = f.sketch.bounds()
+	}
+	return facet, nil
+}
+
+// summary returns the reported form of a field
+func (f *field) summary() *Field {
+	summary := &Field{
+		Count:          f.count,
+		Type:           TypeString,
+		Types:          f.types,
+		DistinctCapped: f.sketch != nil,
+	}
+	summary.Distinct, summary.DistinctEstimated = f.distinct()
+	if f.sketch != nil {
+		summary.Bounds = f.sketch.bounds()
+	}
+	if len(f.types) == 1 {
+		for t := range f.types {
+			summary.Type = t
+		}
+	}
+
+	// The most frequent values make the examples
+	summary.Examples = make([]string, 0, MaxExamples)
+	for _, value := range f.counts() {
+		if len(summary.Examples) == MaxExamples {
+			break
+		}
+		summary.Examples = append(summary.Examples, truncate(value.Value))
+	}
+	return summary
+}
+
+// TypeOf infers the type of a metadata value. Numbers with leading zeros, such as
+// postcodes, and values like "NaN" are strings; years alone are numbers
+func TypeOf(value string) Type {
+	switch strings.ToLower(value) {
+	case "true", "false":
+		return TypeBool
+	}
+	if isNumber(value) {
+		return TypeNumeric
+	}
+	if _, err := models.ParseTime(value); err == nil {
+		return TypeDate
+	}
+	return TypeString
+}
+
+func isNumber(value string) bool {
+	digits := strings.TrimLeft(value, "+-")
+	if digits == "" || (digits[0] < '0' || digits[0] > '9') && digits[0] != '.' {
+		return false
+	}
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return false
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+// truncate shortens long example values, marking the cut with an ellipsis
+func truncate(value string) string {
+	if utf8.RuneCountInString(value) <= maxExampleChars {
+		return value
+	}
+	return string([]rune(value)[:maxExampleChars]) + "…"
+}
@@ -0,0 +1,100 @@
+package metaschema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTypeOf(t *testing.T) {
+	tests := map[string]Type{
+		"1900":                 TypeNumeric,
+		"-3.5":                 TypeNumeric,
+		"0.25":                 TypeNumeric,
+		"02134":                TypeString, // Postcode
+		"NaN":                  TypeString,
+		"Inf":                  TypeString,
+		"TRUE":                 TypeBool,
+		"2024-05-01":           TypeDate,
+		"2024-05-01T10:00:00Z": TypeDate,
+		"fiction":              TypeString,
+	}
+	for value, want := range tests {
+		if got := TypeOf(value); got != want {
+			t.Errorf("TypeOf(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestIndex_MixedValues(t *testing.T) {
+	var idx Index
+	for _, year := range []string{"1999", "2005", "2005", "unknown"} {
+		idx.Add(map[string]string{"year": year, "published": "2024-01-02", "namespace": "books"})
+	}
+	idx.Add(map[string]string{"year": "2010", "featured": "true", "namespace": "films"})
+
+	books := idx.Schema("books")
+	if books.Vectors != 4 || len(books.Fields) != 3 {
+		t.Fatalf("books schema = %+v", books)
+	}
+	year := books.Fields["year"]
+	if year.Type != TypeString || !reflect.DeepEqual(year.Types, map[Type]int{TypeNumeric: 3, TypeString: 1}) {
+		t.Errorf("mixed year = %s %v, want string with 3 numeric and 1 string", year.Type, year.Types)
+	}
+	if year.Distinct != 3 || !reflect.DeepEqual(year.Examples, []string{"2005", "1999", "unknown"}) {
+		t.Errorf("year values = %d %v", year.Distinct, year.Examples)
+	}
+	if books.Fields["published"].Type != TypeDate {
+		t.Errorf("published type = %s, want date", books.Fields["published"].Type)
+	}
+
+	all := idx.Schema("")
+	if all.Vectors != 5 || all.Fields["year"].Count != 5 || all.Fields["featured"].Type != TypeBool {
+		t.Errorf("schema of every namespace = %+v", all)
+	}
+
+	// Removing the string value leaves a numeric field
+	idx.Remove(map[string]string{"year": "unknown", "published": "2024-01-02", "namespace": "books"})
+	year = idx.Schema("books").Fields["year"]
+	if year.Type != TypeNumeric || year.Count != 3 || year.Distinct != 2 {
+		t.Errorf("year after delete = %+v", year)
+	}
+}
+
+func TestIndex_DeletesDecrement(t *testing.T) {
+	var idx Index
+	a := map[string]string{"genre": "sci-fi", "rating": "4"}
+	b := map[string]string{"genre": "sci-fi"}
+	idx.Add(a)
+	idx.Add(b)
+
+	idx.Remove(a)
+	schema := idx.Schema("")
+	if schema.Vectors != 1 || schema.Fields["genre"].Count != 1 || schema.Fields["rating"] != nil {
+		t.Errorf("after deleting a = %+v", schema)
+	}
+	idx.Remove(b)
+	if schema := idx.Schema(""); schema.Vectors != 0 || len(schema.Fields) != 0 {
+		t.Errorf("after deleting every vector = %+v", schema)
+	}
+}
+
+func TestIndex_CardinalityCap(t *testing.T) {
+	var idx Index
+	for i := 0; i < MaxDistinct+20; i++ {
+		idx.Add(map[string]string{"id": fmt.Sprint(i), "kind": "x"})
+	}
+
+	schema := idx.Schema("")
+	id := schema.Fields["id"]
+	if !id.DistinctCapped || !id.DistinctEstimated || id.Bounds == nil || len(id.Examples) != MaxExamples {
+		t.Errorf("high cardinality field = %+v", id)
+	}
+	if n := MaxDistinct + 20; math.Abs(float64(id.Distinct-n)) > id.Bounds.Distinct*float64(n) {
+		t.Errorf("distinct estimate %d of %d is off by more than %v", id.Distinct, n, id.Bounds.Distinct)
+	}
+	if kind := schema.Fields["kind"]; kind.Distinct != 1 || kind.DistinctCapped || kind.Count != MaxDistinct+20 {
+		t.Errorf("low cardinality field = %+v", kind)
+	}
+}
@@ -0,0 +1,120 @@
+package metaschema
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// persistedIndex is the JSON form of an Index, so backends can save it with its
+// sketches instead of rebuilding it, which would lose what deletes left in them
+type persistedIndex struct {
+	Namespaces map[string]persistedNamespace `json:"namespaces"`
+}
+
+type persistedNamespace struct {
+	Vectors int                       `json:"vectors"`
+	Fields  map[string]persistedField `json:"fields"`
+}
+
+type persistedField struct {
+	Count  int              `json:"count"`
+	Types  map[Type]int     `json:"types,omitempty"`
+	Values map[string]int   `json:"values,omitempty"`
+	Sketch *persistedSketch `json:"sketch,omitempty"`
+}
+
+type persistedSketch struct {
+	Registers []byte         `json:"registers"`
+	Counters  []byte         `json:"counters"` // Little endian uint32s
+	Total     int            `json:"total"`
+	Heavy     map[string]int `json:"heavy"`
+}
+
+// Sketched reports whether a field of the index is counted with sketches
+func (idx *Index) Sketched() bool {
+	if idx == nil {
+		return false
+	}
+	for _, ns := range idx.namespaces {
+		for _, f := range ns.fields {
+			if f.sketch != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MarshalJSON encodes the index with its sketches
+func (idx *Index) MarshalJSON() ([]byte, error) {
+	persisted := persistedIndex{Namespaces: make(map[string]persistedNamespace, len(idx.namespaces))}
+	for name, ns := range idx.namespaces {
+		pns := persistedNamespace{Vectors: ns.vectors, Fields: make(map[string]persistedField, len(ns.fields))}
+		for fieldName, f := range ns.fields {
+			pf := persistedField{Count: f.count, Types: f.types, Values: f.values}
+			if f.sketch != nil {
+				counters := make([]byte, 4*len(f.sketch.counters))
+				for i, count := range f.sketch.counters {
+					binary.LittleEndian.PutUint32(counters[4*i:], count)
+				}
+				pf.Sketch = &persistedSketch{Registers: f.sketch.registers, Counters: counters, Total: f.sketch.total, Heavy: f.sketch.heavy}
+			}
+			pns.Fields[fieldName] = pf
+		}
+		persisted.Namespaces[name] = pns
+	}
+	return json.Marshal(persisted)
+}
+
+// UnmarshalJSON decodes an index encoded by MarshalJSON
+func (idx *Index) UnmarshalJSON(data []byte) error {
+	var persisted persistedIndex
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return err
+	}
+
+	namespaces := make(map[string]*namespaceFields, len(persisted.Namespaces))
+	for name, pns := range persisted.Namespaces {
+		ns := &namespaceFields{vectors: pns.Vectors, fields: make(map[string]*field, len(pns.Fields))}
+		for fieldName, pf := range pns.Fields {
+			f := newField()
+			f.count = pf.Count
+			for t, count := range pf.Types {
+				f.types[t] = count
+			}
+			if pf.Sketch == nil {
+				for value, count := range pf.Values {
+					f.values[value] = count
+				}
+			} else {
+				sketch, err := pf.Sketch.sketch()
+				if err != nil {
+					return fmt.Errorf("field %s of namespace %q: %w", fieldName, name, err)
+				}
+				f.sketch, f.values = sketch, nil
+			}
+			ns.fields[fieldName] = f
+		}
+		namespaces[name] = ns
+	}
+	idx.namespaces = namespaces
+	return nil
+}
+
+// sketch decodes a persisted sketch, checking it has the current dimensions
+func (ps *persistedSketch) sketch() (*sketch, error) {
+	if len(ps.Registers) != hllRegisters || len(ps.Counters) != 4*countWidth*countDepth {
+		return nil, fmt.Errorf("sketch dimensions do not match")
+	}
+	s := newSketch()
+	copy(s.registers, ps.Registers)
+	for i := range s.counters {
+		s.counters[i] = binary.LittleEndian.Uint32(ps.Counters[4*i:])
+	}
+	s.total = ps.Total
+	for value, count := range ps.Heavy {
+		s.heavy[value] = count
+	}
+	return s, nil
+}
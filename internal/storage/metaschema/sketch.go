@@ -0,0 +1,230 @@
+package metaschema
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// Sketches of the values of a field with more than MaxDistinct distinct values,
+// which would take memory in proportion to the data to count exactly
+const (
+	// hllPrecision sets the 2^14 HyperLogLog registers estimating distinct values
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+
+	// countWidth and countDepth size the count-min sketch of the value counts:
+	// e/width bounds the error relative to the values counted, with probability
+	// 1 - e^-depth
+	countWidth = 1360
+	countDepth = 5
+
+	// MaxHeavyHitters is the number of most frequent values tracked per sketch
+	MaxHeavyHitters = MaxDistinct
+
+	// Confidence is the probability that estimates are within their Bounds
+	Confidence = 0.99
+)
+
+var (
+	// DistinctError is the relative error of distinct estimates at Confidence:
+	// 2.576 standard errors of 1.04/sqrt(registers)
+	DistinctError = 2.576 * 1.04 / math.Sqrt(hllRegisters)
+
+	// countEpsilon is the count error relative to the values counted
+	countEpsilon = math.E / countWidth
+)
+
+// Bounds are the error bounds of estimated numbers
+type Bounds struct {
+	Distinct   float64 `json:"distinct"`   // Relative error of the distinct estimate
+	Count      int     `json:"count"`      // Most a value count can be over the exact count
+	Confidence float64 `json:"confidence"` // Probability the estimates are within the bounds
+}
+
+// hashValue hashes a value for the sketches, FNV-1a finished with the murmur3
+// mixer so similar values such as sequential IDs spread over every bit. It is
+// stable across processes, so persisted sketches stay valid
+func hashValue(value string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// sketch counts the values of a field approximately in fixed memory: a
+// HyperLogLog of the distinct values, a count-min sketch of the count of each
+// value, and the values with the largest counts as heavy hitters
+// HyperLogLog cannot forget values, so deleting every vector of a value leaves
+// it in the distinct estimate: after deletes the estimate is an upper bound
+type sketch struct {
+	registers []uint8
+	counters  []uint32 // countDepth rows of countWidth counters
+	total     int      // Values counted, bounding the count error
+	heavy     map[string]int
+	floor     int // At most the smallest count in heavy, to skip values below it
+}
+
+func newSketch() *sketch {
+	return &sketch{
+		registers: make([]uint8, hllRegisters),
+		counters:  make([]uint32, countWidth*countDepth),
+		heavy:     make(map[string]int),
+	}
+}
+
+// counterIndex returns the counter of a hash in a row, with the rows indexed by
+// double hashing of the two halves of the hash
+func counterIndex(h uint64, row int) int {
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	return row*countWidth + int((h1+uint32(row)*h2)%countWidth)
+}
+
+// add counts n vectors having value
+func (s *sketch) add(value string, n int) {
+	h := hashValue(value)
+	register := h >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > s.registers[register] {
+		s.registers[register] = rank
+	}
+
+	estimate := math.MaxInt
+	for row := 0; row < countDepth; row++ {
+		i := counterIndex(h, row)
+		s.counters[i] += uint32(n)
+		estimate = min(estimate, int(s.counters[i]))
+	}
+	s.total += n
+	s.track(value, estimate)
+}
+
+// remove uncounts a vector having value, which must have been added
+func (s *sketch) remove(value string) {
+	h := hashValue(value)
+	estimate := math.MaxInt
+	for row := 0; row < countDepth; row++ {
+		i := counterIndex(h, row)
+		if s.counters[i] > 0 {
+			s.counters[i]--
+		}
+		estimate = min(estimate, int(s.counters[i]))
+	}
+	if s.total > 0 {
+		s.total--
+	}
+
+	if _, ok := s.heavy[value]; ok {
+		if estimate == 0 {
+			delete(s.heavy, value)
+		} else {
+			s.heavy[value] = estimate
+		}
+		s.floor = min(s.floor, estimate)
+	}
+}
+
+// count returns the count estimate of value, never below its exact count
+func (s *sketch) count(value string) int {
+	h := hashValue(value)
+	estimate := math.MaxInt
+	for row := 0; row < countDepth; row++ {
+		estimate = min(estimate, int(s.counters[counterIndex(h, row)]))
+	}
+	return estimate
+}
+
+// track keeps value as a heavy hitter when its count estimate is among the largest
+func (s *sketch) track(value string, estimate int) {
+	if _, ok := s.heavy[value]; ok || len(s.heavy) < MaxHeavyHitters {
+		s.heavy[value] = estimate
+		s.floor = min(s.floor, estimate)
+		return
+	}
+	if estimate <= s.floor {
+		return
+	}
+
+	smallest, smallestCount := "", math.MaxInt
+	for v, count := range s.heavy {
+		if count < smallestCount || count == smallestCount && v > smallest {
+			smallest, smallestCount = v, count
+		}
+	}
+	if estimate <= smallestCount {
+		s.floor = smallestCount
+		return
+	}
+	delete(s.heavy, smallest)
+	s.heavy[value] = estimate
+
+	s.floor = estimate
+	for _, count := range s.heavy {
+		s.floor = min(s.floor, count)
+	}
+}
+
+// merge adds the counts of other, as if its values had been added to s
+func (s *sketch) merge(other *sketch) {
+	for i, rank := range other.registers {
+		s.registers[i] = max(s.registers[i], rank)
+	}
+	for i, count := range other.counters {
+		s.counters[i] += count
+	}
+	s.total += other.total
+
+	// Values are heavy in the merge when heavy in either side
+	for value := range other.heavy {
+		s.heavy[value] = 0
+	}
+	for value := range s.heavy {
+		s.heavy[value] = s.count(value)
+	}
+	for len(s.heavy) > MaxHeavyHitters {
+		smallest, smallestCount := "", math.MaxInt
+		for v, count := range s.heavy {
+			if count < smallestCount || count == smallestCount && v > smallest {
+				smallest, smallestCount = v, count
+			}
+		}
+		delete(s.heavy, smallest)
+	}
+	s.floor = math.MaxInt
+	for _, count := range s.heavy {
+		s.floor = min(s.floor, count)
+	}
+}
+
+// distinct estimates the number of distinct values added
+func (s *sketch) distinct() int {
+	sum, zeros := 0.0, 0
+	for _, rank := range s.registers {
+		sum += 1 / float64(uint64(1)<<rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(hllRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// Linear counting is more accurate while many registers are empty
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(estimate))
+}
+
+// bounds returns the error bounds of the estimates of the sketch
+func (s *sketch) bounds() *Bounds {
+	return &Bounds{
+		Distinct:   math.Round(DistinctError*10000) / 10000,
+		Count:      int(math.Ceil(countEpsilon * float64(s.total))),
+		Confidence: Confidence,
+	}
+}
@@ -0,0 +1,134 @@
+package metaschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// zipfUsers returns n user IDs drawn from distinct users with a Zipf
+// distribution, so a few users are heavy hitters, and the exact count of each
+func zipfUsers(n int, distinct uint64) ([]string, map[string]int) {
+	zipf := rand.NewZipf(rand.New(rand.NewSource(7)), 1.2, 1, distinct-1)
+	users := make([]string, n)
+	exact := make(map[string]int)
+	for i := range users {
+		users[i] = fmt.Sprintf("user-%d", zipf.Uint64())
+		exact[users[i]]++
+	}
+	return users, exact
+}
+
+func TestSketch_DistinctWithinBound(t *testing.T) {
+	for _, n := range []int{500, 5000, 50000, 300000} {
+		var idx Index
+		for i := 0; i < n; i++ {
+			idx.Add(map[string]string{"user_id": fmt.Sprintf("u%07d", i)})
+		}
+		field := idx.Schema("").Fields["user_id"]
+		if !field.DistinctEstimated || field.Bounds == nil {
+			t.Fatalf("%d values: %+v, want an estimate", n, field)
+		}
+		if err := math.Abs(float64(field.Distinct-n)) / float64(n); err > field.Bounds.Distinct {
+			t.Errorf("%d distinct values estimated as %d: error %.4f over the bound %.4f", n, field.Distinct, err, field.Bounds.Distinct)
+		}
+		if field.Count != n {
+			t.Errorf("count = %d, want the exact %d", field.Count, n)
+		}
+	}
+}
+
+func TestSketch_HeavyHittersWithinBound(t *testing.T) {
+	users, exact := zipfUsers(200000, 1<<20)
+	var idx Index
+	for i, user := range users {
+		idx.Add(map[string]string{"user_id": user, "namespace": fmt.Sprint("shard-", i%2)})
+	}
+	// Deleted vectors leave the counts, the distinct estimate can only grow
+	for i, user := range users[:20000] {
+		idx.Remove(map[string]string{"user_id": user, "namespace": fmt.Sprint("shard-", i%2)})
+		if exact[user]--; exact[user] == 0 {
+			delete(exact, user)
+		}
+	}
+
+	facet, err := idx.Facet("", "user_id", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !facet.CountsEstimated || !facet.DistinctEstimated || facet.Bounds == nil || len(facet.Values) != 10 {
+		t.Fatalf("facet = %+v", facet)
+	}
+	if facet.Bounds.Count > 500 {
+		t.Errorf("count bound %d is too loose to be useful", facet.Bounds.Count)
+	}
+
+	top := make([]FacetValue, 0, len(exact))
+	for user, count := range exact {
+		top = append(top, FacetValue{Value: user, Count: count})
+	}
+	sortFacetValues(top)
+	for i, value := range facet.Values {
+		want := exact[value.Value]
+		if value.Count < want || value.Count > want+facet.Bounds.Count {
+			t.Errorf("%s counted %d, exact %d, bound %d", value.Value, value.Count, want, facet.Bounds.Count)
+		}
+		if value.Value != top[i].Value {
+			t.Errorf("heavy hitter %d = %s, want %s", i, value.Value, top[i].Value)
+		}
+	}
+
+	if facet.Distinct < int(float64(len(exact))*(1-facet.Bounds.Distinct)) {
+		t.Errorf("%d distinct users estimated as %d", len(exact), facet.Distinct)
+	}
+}
+
+func sortFacetValues(values []FacetValue) {
+	f := &field{values: make(map[string]int, len(values))}
+	for _, value := range values {
+		f.values[value.Value] = value.Count
+	}
+	copy(values, f.counts())
+}
+
+func TestIndex_PersistsSketches(t *testing.T) {
+	users, _ := zipfUsers(5000, 1<<12)
+	var idx Index
+	for _, user := range users {
+		idx.Add(map[string]string{"user_id": user, "plan": "free", "namespace": "app"})
+	}
+	if !idx.Sketched() {
+		t.Fatal("expected the user_id field to be sketched")
+	}
+
+	data, err := json.Marshal(&idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored Index
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := idx.Facet("app", "user_id", 5)
+	got, _ := restored.Facet("app", "user_id", 5)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("restored facet = %+v, want %+v", got, want)
+	}
+	if plan, _ := restored.Facet("app", "plan", 0); plan.CountsEstimated || plan.Values[0].Count != 5000 {
+		t.Errorf("restored exact facet = %+v", plan)
+	}
+
+	// The restored index keeps counting
+	restored.Add(map[string]string{"user_id": users[0], "namespace": "app"})
+	if got, _ := restored.Facet("app", "user_id", 0); got.Count != 5001 {
+		t.Errorf("count after an add = %d", got.Count)
+	}
+	if _, err := restored.Facet("app", "missing", 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("facet of a missing field: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Package profile defines named ranking profiles: search defaults stored
+// server-side that requests select by name instead of repeating them
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// ErrNotFound is matched with errors.Is by the errors of unknown profiles
+var ErrNotFound = fmt.Errorf("profile %w", storeerr.ErrNotFound)
+
+// NotFound returns the error of an unknown profile
+func NotFound(name string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Profile bundles search defaults
+// Fields a request sets explicitly take precedence over the profile, and fields
+// neither sets fall back to the server defaults
+type Profile struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	Metric         string               `json:"metric,omitempty"`
+	HybridWeight   *models.HybridWeight `json:"hybrid_weight,omitempty"`
+	MinScore       *float64             `json:"min_score,omitempty"`
+	MetadataFields []string             `json:"metadata_fields,omitempty"`
+
+	// Filters are combined with the request filters, which replace the profile
+	// filter of any field they also filter
+	Filters models.Filters `json:"filters,omitempty"`
+
+	// TemporalDecay and TimeField apply to temporal searches only
+	TemporalDecay models.TemporalDecayStrength `json:"temporal_decay,omitempty"`
+	TimeField     string                       `json:"time_field,omitempty"`
+
+	// ScoreExpr rewrites the score of each result, see models.ScoreExpr
+	ScoreExpr string `json:"score_expr,omitempty"`
+}
+
+// Profiles are ranking profiles keyed by name
+type Profiles map[string]Profile
+
+// Validate checks the name and every default of the profile
+func (p *Profile) Validate() error {
+	if err := ValidateName(p.Name); err != nil {
+		return err
+	}
+	if p.Metric != "" {
+		if err := search.ValidateMetric(p.Metric); err != nil {
+			return fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+	}
+	if p.MinScore != nil && search.Ascending(p.Metric) {
+		return fmt.Errorf("profile %s: min_score cannot be used with the %s metric", p.Name, p.Metric)
+	}
+	options := &models.SearchOptions{HybridWeight: p.HybridWeight}
+	if err := options.Validate(); err != nil {
+		return fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+	for _, field := range p.MetadataFields {
+		if field == "" {
+			return fmt.Errorf("profile %s: metadata_fields cannot contain an empty field name", p.Name)
+		}
+	}
+	if _, err := models.NewFilterEvaluator().CompileSearch(p.Filters); err != nil {
+		return fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+	if p.ScoreExpr != "" {
+		if _, err := models.CompileScoreExpr(p.ScoreExpr); err != nil {
+			return fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+	}
+	switch p.TemporalDecay {
+	case "", models.DecayStrong, models.DecayMedium, models.DecayWeak, models.DecayNone:
+	default:
+		return fmt.Errorf("profile %s: invalid temporal_decay value: %s (must be: strong, medium, weak, none)", p.Name, p.TemporalDecay)
+	}
+	return nil
+}
+
+// ValidateName checks that name can be used in a URL path
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("profile name cannot be empty")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("invalid profile name %q: only letters, digits, '-', '_' and '.' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// Sorted returns the profiles ordered by name
+func (ps Profiles) Sorted() []Profile {
+	sorted := make([]Profile, 0, len(ps))
+	for _, p := range ps {
+		sorted = append(sorted, p)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// Load reads and validates the profiles of a JSON file holding a list of profiles
+func Load(path string) ([]Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+
+	var profiles []Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool, len(profiles))
+	for i := range profiles {
+		if err := profiles[i].Validate(); err != nil {
+			return nil, err
+		}
+		if seen[profiles[i].Name] {
+			return nil, fmt.Errorf("profile %s is defined twice", profiles[i].Name)
+		}
+		seen[profiles[i].Name] = true
+	}
+	return profiles, nil
+}
+
+// Apply fills the fields req leaves unset with the profile defaults
+// req is a search request of any endpoint; requests of other types are left unchanged
+func (p *Profile) Apply(req interface{}) {
+	switch req := req.(type) {
+	case *models.SearchByTextRequest:
+		p.applyParams(&req.SearchParams)
+		req.Filters = p.applyFilters(req.Filters)
+		req.Options = p.applyOptions(req.Options)
+	case *models.AdvancedSearchRequest:
+		p.applyParams(&req.SearchParams)
+		req.Filters = p.applyFilters(req.Filters)
+		req.Options = p.applyOptions(req.Options)
+	case *models.SearchByEmbbedingRequest:
+		p.applyParams(&req.SearchParams)
+		req.Filters = p.applyFilters(req.Filters)
+		req.Options = p.applyOptions(req.Options)
+	case *models.TemporalSearchRequest:
+		p.applyParams(&req.SearchParams)
+		req.Filters = p.applyFilters(req.Filters)
+		req.Options = p.applyOptions(req.Options)
+		if req.TemporalDecay == "" {
+			req.TemporalDecay = p.TemporalDecay
+		}
+		if req.TimeField == "" {
+			req.TimeField = p.TimeField
+		}
+	}
+}
+
+func (p *Profile) applyParams(params *models.SearchParams) {
+	if params.Metric == "" {
+		params.Metric = p.Metric
+	}
+	// A min_score is meaningless for a metric the request chose over the profile one
+	if params.MinScore == nil && params.Metric == p.Metric {
+		params.MinScore = p.MinScore
+	}
+	if params.MetadataFields == nil && p.MetadataFields != nil {
+		params.MetadataFields = append([]string(nil), p.MetadataFields...)
+	}
+	if params.ScoreExpr == "" {
+		params.ScoreExpr = p.ScoreExpr
+	}
+}
+
+// applyFilters returns the profile filters with the request filters replacing
+// those of the same field
+func (p *Profile) applyFilters(filters models.Filters) models.Filters {
+	if len(p.Filters) == 0 {
+		return filters
+	}
+	merged := make(models.Filters, len(p.Filters)+len(filters))
+	for field, expr := range p.Filters {
+		merged[field] = expr
+	}
+	for field, expr := range filters {
+		merged[field] = expr
+	}
+	return merged
+}
+
+func (p *Profile) applyOptions(options *models.SearchOptions) *models.SearchOptions {
+	if p.HybridWeight == nil || (options != nil && options.HybridWeight != nil) {
+		return options
+	}
+	weight := *p.HybridWeight
+	return &models.SearchOptions{HybridWeight: &weight}
+}
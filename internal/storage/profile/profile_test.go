@@ -0,0 +1,131 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestValidate(t *testing.T) {
+	minScore := 0.5
+	tests := []struct {
+		name    string
+		profile Profile
+		wantErr bool
+	}{
+		{name: "empty profile", profile: Profile{Name: "plain"}},
+		{name: "full profile", profile: Profile{
+			Name:           "news",
+			Metric:         "dot",
+			HybridWeight:   &models.HybridWeight{Vector: 0.8, Metadata: 0.2},
+			MinScore:       &minScore,
+			MetadataFields: []string{"title"},
+			Filters:        models.Filters{"type": {"eq": "article"}},
+			TemporalDecay:  models.DecayStrong,
+			TimeField:      "published_at",
+			ScoreExpr:      "score * (1 + 0.1*log(1+num(metadata.popularity, 0)))",
+		}},
+		{name: "missing name", profile: Profile{}, wantErr: true},
+		{name: "name with slash", profile: Profile{Name: "a/b"}, wantErr: true},
+		{name: "unknown metric", profile: Profile{Name: "p", Metric: "manhattan"}, wantErr: true},
+		{name: "min score with euclidean", profile: Profile{Name: "p", Metric: "euclidean", MinScore: &minScore}, wantErr: true},
+		{name: "weights not summing to one", profile: Profile{Name: "p", HybridWeight: &models.HybridWeight{Vector: 0.5, Metadata: 0.2}}, wantErr: true},
+		{name: "empty metadata field", profile: Profile{Name: "p", MetadataFields: []string{""}}, wantErr: true},
+		{name: "invalid decay", profile: Profile{Name: "p", TemporalDecay: "fast"}, wantErr: true},
+		{name: "invalid score expression", profile: Profile{Name: "p", ScoreExpr: "score * popularity"}, wantErr: true},
+		{name: "invalid filter", profile: Profile{Name: "p", Filters: models.Filters{"ra*": {models.MatchModifier: "most"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	profiles, err := Load(write("ok.json", `[{"name": "news", "temporal_decay": "strong"}, {"name": "archive"}]`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(profiles) != 2 || profiles[0].TemporalDecay != models.DecayStrong {
+		t.Errorf("profiles = %+v", profiles)
+	}
+
+	for name, content := range map[string]string{
+		"invalid.json":   `[{"name": "news", "metric": "manhattan"}]`,
+		"duplicate.json": `[{"name": "news"}, {"name": "news"}]`,
+		"malformed.json": `{"name": "news"}`,
+		"expr.json":      `[{"name": "news", "score_expr": "score *"}]`,
+	} {
+		if _, err := Load(write(name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestApply_RequestTakesPrecedence(t *testing.T) {
+	profileScore, requestScore := 0.3, 0.6
+	p := Profile{
+		Name:           "news",
+		Metric:         "dot",
+		HybridWeight:   &models.HybridWeight{Vector: 0.7, Metadata: 0.3},
+		MinScore:       &profileScore,
+		MetadataFields: []string{"title"},
+		Filters:        models.Filters{"type": {"eq": "article"}, "lang": {"eq": "en"}},
+		TemporalDecay:  models.DecayStrong,
+		TimeField:      "published_at",
+		ScoreExpr:      "score * 2",
+	}
+
+	// Unset fields take the profile values
+	req := &models.TemporalSearchRequest{Query: "q"}
+	p.Apply(req)
+	if req.Metric != "dot" || req.MinScore == nil || *req.MinScore != profileScore || len(req.MetadataFields) != 1 ||
+		req.Options == nil || req.Options.HybridWeight.Vector != 0.7 || len(req.Filters) != 2 ||
+		req.TemporalDecay != models.DecayStrong || req.TimeField != "published_at" || req.ScoreExpr != "score * 2" {
+		t.Errorf("profile not applied: %+v", req)
+	}
+
+	// Explicit fields are kept, filters are replaced per field
+	req = &models.TemporalSearchRequest{
+		Query:         "q",
+		Filters:       models.Filters{"lang": {"eq": "fr"}},
+		Options:       &models.SearchOptions{HybridWeight: &models.HybridWeight{Vector: 1}},
+		TemporalDecay: models.DecayNone,
+		TimeField:     "created_at",
+		SearchParams:  models.SearchParams{MinScore: &requestScore, MetadataFields: []string{}, ScoreExpr: "score"},
+	}
+	p.Apply(req)
+	if *req.MinScore != requestScore || len(req.MetadataFields) != 0 || req.Options.HybridWeight.Vector != 1 ||
+		req.TemporalDecay != models.DecayNone || req.TimeField != "created_at" || req.ScoreExpr != "score" {
+		t.Errorf("request fields overridden: %+v", req)
+	}
+	if req.Filters["lang"]["eq"] != "fr" || req.Filters["type"]["eq"] != "article" {
+		t.Errorf("filters = %v, want the request lang and the profile type", req.Filters)
+	}
+	if p.Filters["lang"]["eq"] != "en" {
+		t.Errorf("profile filters were modified: %v", p.Filters)
+	}
+
+	// The profile min_score does not apply to a metric the request chose
+	text := &models.SearchByTextRequest{Text: "q", SearchParams: models.SearchParams{Metric: "euclidean"}}
+	p.Apply(text)
+	if text.MinScore != nil {
+		t.Errorf("min_score = %v applied to the euclidean metric", *text.MinScore)
+	}
+}
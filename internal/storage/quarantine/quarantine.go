@@ -0,0 +1,157 @@
+// Package quarantine keeps the records that batches and ingests could not store,
+// with the reason and their original payload, so they can be fixed and retried
+package quarantine
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// DefaultMaxEntries caps a quarantine that sets no limit
+const DefaultMaxEntries = 10000
+
+// Kinds of quarantined payloads
+const (
+	KindVector = "vector" // A models.VectorInput as sent to the batch endpoint
+	KindRecord = "record" // A Record read by an ingest, embedded again on retry
+)
+
+// ErrNotFound is matched with errors.Is by the errors of unknown entries
+var ErrNotFound = fmt.Errorf("quarantine entry %w", storeerr.ErrNotFound)
+
+// NotFound returns the error of an unknown entry
+func NotFound(id string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// Entry is a quarantined record
+type Entry struct {
+	ID            string          `json:"id"`
+	Kind          string          `json:"kind"`
+	Source        string          `json:"source"` // "batch" or the name of the ingest source
+	Namespace     string          `json:"namespace,omitempty"`
+	Reason        string          `json:"reason"` // Failure category, such as dimension_too_large
+	Error         string          `json:"error"`
+	Payload       json.RawMessage `json:"payload"`
+	QuarantinedAt time.Time       `json:"quarantined_at"`
+}
+
+// Record is the payload of a KindRecord entry
+type Record struct {
+	ID       string            `json:"id,omitempty"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// NewEntry returns an entry of kind holding payload encoded as JSON
+func NewEntry(kind, source, reason string, cause error, payload interface{}) (Entry, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to encode quarantined payload: %w", err)
+	}
+	entry := Entry{Kind: kind, Source: source, Reason: reason, Payload: data}
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+	return entry, nil
+}
+
+// Stats summarizes a quarantine
+type Stats struct {
+	Entries    int            `json:"entries"`
+	MaxEntries int            `json:"max_entries"`
+	Evicted    int            `json:"evicted"` // Entries dropped, oldest first, to stay under MaxEntries
+	ByReason   map[string]int `json:"by_reason,omitempty"`
+}
+
+// Queue holds quarantined entries oldest first
+type Queue struct {
+	Entries    []Entry `json:"entries"`
+	MaxEntries int     `json:"max_entries,omitempty"` // DefaultMaxEntries when 0
+	Evicted    int     `json:"evicted,omitempty"`
+}
+
+// Limit returns the number of entries the queue keeps
+func (q *Queue) Limit() int {
+	if q.MaxEntries > 0 {
+		return q.MaxEntries
+	}
+	return DefaultMaxEntries
+}
+
+// SetLimit changes the number of entries the queue keeps, evicting the oldest past it
+func (q *Queue) SetLimit(max int) error {
+	if max < 0 {
+		return fmt.Errorf("invalid quarantine limit %d: must be positive, or 0 for the default", max)
+	}
+	q.MaxEntries = max
+	q.evict()
+	return nil
+}
+
+// Add appends entries, stamping their ID and time when unset, and evicts the
+// oldest entries past the limit
+func (q *Queue) Add(entries ...Entry) {
+	now := time.Now().UTC()
+	for _, entry := range entries {
+		if entry.ID == "" {
+			entry.ID = uuid.New()
+		}
+		if entry.QuarantinedAt.IsZero() {
+			entry.QuarantinedAt = now
+		}
+		q.Entries = append(q.Entries, entry)
+	}
+	q.evict()
+}
+
+// evict drops the oldest entries past the limit
+func (q *Queue) evict() {
+	if over := len(q.Entries) - q.Limit(); over > 0 {
+		q.Entries = append([]Entry(nil), q.Entries[over:]...)
+		q.Evicted += over
+	}
+}
+
+// Remove drops the entries with the given IDs, failing without a change when one is unknown
+func (q *Queue) Remove(ids ...string) error {
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	kept := make([]Entry, 0, len(q.Entries))
+	for _, entry := range q.Entries {
+		if remove[entry.ID] {
+			delete(remove, entry.ID)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	for id := range remove {
+		return NotFound(id)
+	}
+	q.Entries = kept
+	return nil
+}
+
+// List returns a copy of the entries, oldest first
+func (q *Queue) List() []Entry {
+	return append([]Entry(nil), q.Entries...)
+}
+
+// Stats returns the size, limit and evictions of the queue and its entries by reason
+func (q *Queue) Stats() Stats {
+	stats := Stats{Entries: len(q.Entries), MaxEntries: q.Limit(), Evicted: q.Evicted}
+	if len(q.Entries) > 0 {
+		stats.ByReason = make(map[string]int)
+		for _, entry := range q.Entries {
+			stats.ByReason[entry.Reason]++
+		}
+	}
+	return stats
+}
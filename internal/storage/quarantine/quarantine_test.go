@@ -0,0 +1,65 @@
+package quarantine
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestQueue_EvictsOldestFirst(t *testing.T) {
+	q := &Queue{MaxEntries: 3}
+	for i := 0; i < 5; i++ {
+		q.Add(Entry{ID: fmt.Sprint(i), Reason: []string{"embed_error", "invalid_vector"}[i%2]})
+	}
+
+	entries := q.List()
+	if len(entries) != 3 || entries[0].ID != "2" || entries[2].ID != "4" {
+		t.Fatalf("entries = %+v, want 2, 3 and 4", entries)
+	}
+	if entries[0].QuarantinedAt.IsZero() {
+		t.Error("expected entries to be stamped")
+	}
+
+	stats := q.Stats()
+	if stats.Entries != 3 || stats.Evicted != 2 || stats.MaxEntries != 3 {
+		t.Errorf("stats = %+v, want 3 entries and 2 evicted", stats)
+	}
+	if stats.ByReason["embed_error"] != 2 || stats.ByReason["invalid_vector"] != 1 {
+		t.Errorf("by reason = %v", stats.ByReason)
+	}
+
+	if err := q.SetLimit(1); err != nil {
+		t.Fatal(err)
+	}
+	if entries := q.List(); len(entries) != 1 || entries[0].ID != "4" || q.Evicted != 4 {
+		t.Errorf("after lowering the limit: %+v, %d evicted", entries, q.Evicted)
+	}
+	if err := q.SetLimit(-1); err == nil {
+		t.Error("expected a negative limit to be rejected")
+	}
+}
+
+func TestQueue_Remove(t *testing.T) {
+	q := &Queue{}
+	q.Add(Entry{Reason: "embed_error"}, Entry{Reason: "embed_error"})
+	entries := q.List()
+	if entries[0].ID == "" || entries[0].ID == entries[1].ID {
+		t.Fatalf("expected distinct generated IDs, got %+v", entries)
+	}
+	if q.Limit() != DefaultMaxEntries {
+		t.Errorf("limit = %d, want the default", q.Limit())
+	}
+
+	if err := q.Remove(entries[0].ID, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("removing an unknown entry: err = %v, want ErrNotFound", err)
+	}
+	if len(q.List()) != 2 {
+		t.Error("a failed removal must not change the queue")
+	}
+	if err := q.Remove(entries[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := q.List(); len(remaining) != 1 || remaining[0].ID != entries[1].ID {
+		t.Errorf("remaining = %+v", remaining)
+	}
+}
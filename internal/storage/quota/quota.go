@@ -0,0 +1,176 @@
+// Package quota defines per-namespace storage limits shared by the storage backends
+package quota
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// EmbeddingValueSize is the number of bytes counted per embedding component,
+// the size of the float64 values vectors are held in
+const EmbeddingValueSize = 8
+
+// ErrExceeded is matched by every quota error with errors.Is
+var ErrExceeded = storeerr.ErrQuotaExceeded
+
+// Limit caps the vectors stored in a namespace, a zero field is unlimited
+type Limit struct {
+	MaxVectors int   `json:"max_vectors,omitempty"`
+	MaxBytes   int64 `json:"max_bytes,omitempty"`
+}
+
+// IsZero reports whether the limit sets no cap
+func (l Limit) IsZero() bool {
+	return l.MaxVectors == 0 && l.MaxBytes == 0
+}
+
+// Validate rejects negative limits
+func (l Limit) Validate() error {
+	if l.MaxVectors < 0 {
+		return fmt.Errorf("max_vectors cannot be negative")
+	}
+	if l.MaxBytes < 0 {
+		return fmt.Errorf("max_bytes cannot be negative")
+	}
+	return nil
+}
+
+// Usage is the number of vectors and embedding bytes stored in a namespace
+// It is also used as a signed change of usage
+type Usage struct {
+	Vectors int   `json:"vectors"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// Of returns the usage of a single vector
+// Sparse embeddings store an index alongside every value
+func Of(vector *models.Vector) Usage {
+	if vector.Sparse != nil {
+		return Usage{Vectors: 1, Bytes: int64(len(vector.Sparse.Values)) * 2 * EmbeddingValueSize}
+	}
+	return Usage{Vectors: 1, Bytes: int64(len(vector.Embedding)) * EmbeddingValueSize}
+}
+
+// Namespace returns the namespace a vector is counted against
+func Namespace(vector *models.Vector) string {
+	return vector.Metadata[models.NamespaceKey]
+}
+
+// Add returns the sum of two usages
+func (u Usage) Add(other Usage) Usage {
+	return Usage{Vectors: u.Vectors + other.Vectors, Bytes: u.Bytes + other.Bytes}
+}
+
+// Sub returns u minus other
+func (u Usage) Sub(other Usage) Usage {
+	return Usage{Vectors: u.Vectors - other.Vectors, Bytes: u.Bytes - other.Bytes}
+}
+
+// Error reports a write rejected by a namespace limit
+type Error struct {
+	Namespace string `json:"namespace"`
+	Resource  string `json:"resource"` // "vectors" or "bytes"
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Requested int64  `json:"requested"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("quota exceeded for namespace %q: %d %s used of %d, %d more requested",
+		e.Namespace, e.Used, e.Resource, e.Limit, e.Requested)
+}
+
+// Is makes every quota error match ErrExceeded
+func (e *Error) Is(target error) bool {
+	return target == ErrExceeded
+}
+
+// Limits maps namespaces to their limit, the empty namespace holds vectors stored without one
+type Limits map[string]Limit
+
+// Check returns an *Error if applying delta to usage takes a namespace over its limit
+// Only growing namespaces are checked, so deletes and shrinking updates always pass
+// even when a lowered limit is already exceeded
+func (l Limits) Check(usage, delta map[string]Usage) error {
+	namespaces := make([]string, 0, len(delta))
+	for namespace := range delta {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		limit, ok := l[namespace]
+		if !ok {
+			continue
+		}
+		used, change := usage[namespace], delta[namespace]
+
+		if limit.MaxVectors > 0 && change.Vectors > 0 && used.Vectors+change.Vectors > limit.MaxVectors {
+			return &Error{Namespace: namespace, Resource: "vectors", Limit: int64(limit.MaxVectors), Used: int64(used.Vectors), Requested: int64(change.Vectors)}
+		}
+		if limit.MaxBytes > 0 && change.Bytes > 0 && used.Bytes+change.Bytes > limit.MaxBytes {
+			return &Error{Namespace: namespace, Resource: "bytes", Limit: limit.MaxBytes, Used: used.Bytes, Requested: change.Bytes}
+		}
+	}
+	return nil
+}
+
+// Lookup returns the namespace and usage of the stored vector with an ID, and false when there is none
+type Lookup func(id string) (namespace string, usage Usage, ok bool)
+
+// Delta returns the usage change of storing vectors over the versions found by previous
+func Delta(vectors []*models.Vector, previous Lookup) map[string]Usage {
+	delta := make(map[string]Usage)
+	replaced := make(map[string]*models.Vector, len(vectors))
+
+	for _, vector := range vectors {
+		if old, seen := replaced[vector.ID]; seen {
+			delta[Namespace(old)] = delta[Namespace(old)].Sub(Of(old))
+		} else if namespace, used, ok := previous(vector.ID); ok {
+			delta[namespace] = delta[namespace].Sub(used)
+		}
+		delta[Namespace(vector)] = delta[Namespace(vector)].Add(Of(vector))
+		replaced[vector.ID] = vector
+	}
+
+	return delta
+}
+
+// Status is the limit and usage of a namespace
+type Status struct {
+	Namespace string `json:"namespace"`
+	Limit     *Limit `json:"limit,omitempty"`
+	Usage     Usage  `json:"usage"`
+}
+
+// Report lists the status of every namespace with a limit or stored vectors, sorted by namespace
+func Report(limits Limits, usage map[string]Usage) []Status {
+	seen := make(map[string]bool)
+	statuses := make([]Status, 0, len(limits)+len(usage))
+
+	add := func(namespace string) {
+		if seen[namespace] {
+			return
+		}
+		seen[namespace] = true
+		status := Status{Namespace: namespace, Usage: usage[namespace]}
+		if limit, ok := limits[namespace]; ok {
+			status.Limit = &limit
+		}
+		statuses = append(statuses, status)
+	}
+	for namespace := range limits {
+		add(namespace)
+	}
+	for namespace, used := range usage {
+		if used.Vectors > 0 {
+			add(namespace)
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Namespace < statuses[j].Namespace })
+	return statuses
+}
@@ -0,0 +1,73 @@
+package quota
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func vector(id, namespace string, dimension int) *models.Vector {
+	return &models.Vector{
+		ID:        id,
+		Embedding: make([]float64, dimension),
+		Metadata:  map[string]string{models.NamespaceKey: namespace},
+	}
+}
+
+func TestLimitsCheck(t *testing.T) {
+	limits := Limits{"team": {MaxVectors: 2, MaxBytes: 64}}
+	usage := map[string]Usage{"team": {Vectors: 1, Bytes: 16}}
+
+	tests := []struct {
+		name     string
+		delta    map[string]Usage
+		resource string
+	}{
+		{"within limit", map[string]Usage{"team": {Vectors: 1, Bytes: 16}}, ""},
+		{"too many vectors", map[string]Usage{"team": {Vectors: 2, Bytes: 16}}, "vectors"},
+		{"too many bytes", map[string]Usage{"team": {Vectors: 1, Bytes: 56}}, "bytes"},
+		{"shrinking always passes", map[string]Usage{"team": {Vectors: -1, Bytes: -16}}, ""},
+		{"unlimited namespace", map[string]Usage{"other": {Vectors: 100, Bytes: 1 << 20}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limits.Check(usage, tt.delta)
+			if tt.resource == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var qe *Error
+			if !errors.As(err, &qe) || !errors.Is(err, ErrExceeded) {
+				t.Fatalf("error = %v, want quota error", err)
+			}
+			if qe.Resource != tt.resource || qe.Namespace != "team" {
+				t.Errorf("error = %+v, want %s of team", qe, tt.resource)
+			}
+		})
+	}
+}
+
+func TestDelta(t *testing.T) {
+	stored := map[string]*models.Vector{"a": vector("a", "one", 4)}
+	lookup := func(id string) (string, Usage, bool) {
+		if v, ok := stored[id]; ok {
+			return Namespace(v), Of(v), true
+		}
+		return "", Usage{}, false
+	}
+
+	// Moving a into another namespace and storing b twice counts b once
+	delta := Delta([]*models.Vector{vector("a", "two", 2), vector("b", "two", 2), vector("b", "two", 2)}, lookup)
+
+	if got, want := delta["one"], (Usage{Vectors: -1, Bytes: -32}); got != want {
+		t.Errorf("delta[one] = %+v, want %+v", got, want)
+	}
+	if got, want := delta["two"], (Usage{Vectors: 2, Bytes: 32}); got != want {
+		t.Errorf("delta[two] = %+v, want %+v", got, want)
+	}
+}
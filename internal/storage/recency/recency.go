@@ -0,0 +1,146 @@
+// Package recency keeps the stored vectors ordered by creation time, shared by
+// the storage backends so newest-first listings do not scan every vector
+package recency
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// minCompaction is the number of removed entries below which the index is never compacted
+const minCompaction = 64
+
+// Entry is a vector in the index
+type Entry struct {
+	ID        string
+	Namespace string
+	CreatedAt time.Time
+
+	seq uint64 // Order of insertion, breaking ties between equal creation times
+}
+
+// Of returns the entry of vector
+func Of(vector *models.Vector) Entry {
+	return Entry{ID: vector.ID, Namespace: vector.Metadata[models.NamespaceKey], CreatedAt: vector.CreatedAt}
+}
+
+// Index is an append-only log of entries, oldest first
+// Removing or replacing a vector only marks its entry as dead, dead entries are
+// dropped when they outnumber the live ones. Entries are appended in creation
+// order, so inserting one that is older than the newest, such as a restored vector,
+// costs a copy of the entries after it
+// The zero value is an empty index ready to use, it is not safe for concurrent use
+type Index struct {
+	entries []Entry
+	live    map[string]Entry // Live entry of each ID
+	seq     uint64
+}
+
+// Build returns the index of entries
+func Build(entries []Entry) *Index {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+
+	idx := &Index{entries: make([]Entry, 0, len(entries)), live: make(map[string]Entry, len(entries))}
+	for _, e := range entries {
+		idx.seq++
+		e.seq = idx.seq
+		idx.entries = append(idx.entries, e)
+		idx.live[e.ID] = e
+	}
+	return idx
+}
+
+// Add indexes a stored vector, replacing the entry it had
+// A vector stored again with the same creation time and namespace keeps its entry
+func (idx *Index) Add(e Entry) {
+	if idx == nil {
+		return
+	}
+	if idx.live == nil {
+		idx.live = make(map[string]Entry)
+	}
+	if old, ok := idx.live[e.ID]; ok && old.CreatedAt.Equal(e.CreatedAt) && old.Namespace == e.Namespace {
+		return
+	}
+
+	idx.seq++
+	e.seq = idx.seq
+	idx.live[e.ID] = e
+
+	n := len(idx.entries)
+	if n == 0 || !e.CreatedAt.Before(idx.entries[n-1].CreatedAt) {
+		idx.entries = append(idx.entries, e)
+	} else {
+		at := sort.Search(n, func(i int) bool {
+			return idx.entries[i].CreatedAt.After(e.CreatedAt)
+		})
+		idx.entries = append(idx.entries, Entry{})
+		copy(idx.entries[at+1:], idx.entries[at:])
+		idx.entries[at] = e
+	}
+	idx.compact()
+}
+
+// Remove drops the entry of a deleted vector
+func (idx *Index) Remove(id string) {
+	if idx == nil {
+		return
+	}
+	if _, ok := idx.live[id]; ok {
+		delete(idx.live, id)
+		idx.compact()
+	}
+}
+
+// Newest returns the IDs of the newest vectors of namespace, newest first, at most limit
+// An empty namespace matches every vector
+func (idx *Index) Newest(namespace string, limit int) []string {
+	ids := make([]string, 0)
+	if idx == nil {
+		return ids
+	}
+	for i := len(idx.entries) - 1; i >= 0 && len(ids) < limit; i-- {
+		e := idx.entries[i]
+		if !idx.isLive(e) {
+			continue
+		}
+		if namespace == "" || e.Namespace == namespace {
+			ids = append(ids, e.ID)
+		}
+	}
+	return ids
+}
+
+// Len returns the number of indexed vectors
+func (idx *Index) Len() int {
+	if idx == nil {
+		return 0
+	}
+	return len(idx.live)
+}
+
+// compact drops the dead entries once they outnumber the live ones
+func (idx *Index) compact() {
+	dead := len(idx.entries) - len(idx.live)
+	if dead < minCompaction || dead <= len(idx.live) {
+		return
+	}
+
+	entries := make([]Entry, 0, len(idx.live))
+	for _, e := range idx.entries {
+		if idx.isLive(e) {
+			entries = append(entries, e)
+		}
+	}
+	idx.entries = entries
+}
+
+// isLive reports whether e is the live entry of its vector
+func (idx *Index) isLive(e Entry) bool {
+	live, ok := idx.live[e.ID]
+	return ok && live.seq == e.seq
+}
@@ -0,0 +1,76 @@
+package recency
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndex_InterleavedInserts(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	var idx Index
+	idx.Add(Entry{ID: "a", Namespace: "x", CreatedAt: at(1)})
+	idx.Add(Entry{ID: "b", Namespace: "y", CreatedAt: at(3)})
+	idx.Add(Entry{ID: "c", Namespace: "x", CreatedAt: at(2)}) // Restored with an older time
+	idx.Add(Entry{ID: "d", Namespace: "x", CreatedAt: at(3)}) // Same time as b, inserted later
+	idx.Add(Entry{ID: "e", Namespace: "y", CreatedAt: at(0)})
+
+	if got, want := idx.Newest("", 10), []string{"d", "b", "c", "a", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("newest = %v, want %v", got, want)
+	}
+	if got, want := idx.Newest("x", 2), []string{"d", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("newest in x = %v, want %v", got, want)
+	}
+
+	// Storing a vector again keeps its place unless its time or namespace changed
+	idx.Add(Entry{ID: "a", Namespace: "x", CreatedAt: at(1)})
+	idx.Add(Entry{ID: "e", Namespace: "x", CreatedAt: at(4)})
+	if got, want := idx.Newest("", 10), []string{"e", "d", "b", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after restore = %v, want %v", got, want)
+	}
+	if got, want := idx.Newest("y", 10), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("newest in y after move = %v, want %v", got, want)
+	}
+	if idx.Len() != 5 {
+		t.Errorf("len = %d, want 5", idx.Len())
+	}
+}
+
+func TestIndex_Remove(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	idx := Build(nil)
+	for i := 0; i < 200; i++ {
+		idx.Add(Entry{ID: fmt.Sprint(i), CreatedAt: start.Add(time.Duration(i) * time.Second)})
+	}
+	for i := 0; i < 200; i++ {
+		if i != 10 && i != 150 {
+			idx.Remove(fmt.Sprint(i))
+		}
+	}
+	idx.Remove("missing")
+
+	if got, want := idx.Newest("", 10), []string{"150", "10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("newest = %v, want %v", got, want)
+	}
+	// Dead entries are dropped once they outnumber the live ones
+	if len(idx.entries) > minCompaction+2 {
+		t.Errorf("index holds %d entries for 2 vectors", len(idx.entries))
+	}
+}
+
+func TestBuild(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	idx := Build([]Entry{
+		{ID: "b", CreatedAt: start.Add(time.Hour)},
+		{ID: "a", CreatedAt: start},
+		{ID: "c", CreatedAt: start.Add(2 * time.Hour)},
+	})
+	idx.Add(Entry{ID: "d", CreatedAt: start.Add(30 * time.Minute)})
+
+	if got, want := idx.Newest("", 3), []string{"c", "b", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("newest = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// Supported distance metrics
+const (
+	MetricCosine    = "cosine"
+	MetricEuclidean = "euclidean"
+	MetricDot       = "dot"
+)
+
+// ValidateMetric checks that metric is a supported distance metric
+func ValidateMetric(metric string) error {
+	switch metric {
+	case MetricCosine, MetricEuclidean, MetricDot:
+		return nil
+	default:
+		return fmt.Errorf("unsupported metric %q: must be one of %s, %s, %s", metric, MetricCosine, MetricEuclidean, MetricDot)
+	}
+}
+
+// Score scores vector against query with metric
+// Cosine and dot scores are similarities, euclidean scores are distances
+func Score(metric string, query, vector *models.Vector) float64 {
+	switch metric {
+	case MetricEuclidean:
+		return query.EuclideanDistance(vector)
+	case MetricDot:
+		return query.Dot(vector)
+	default:
+		return query.CosineSimilarity(vector)
+	}
+}
+
+// Ascending reports whether lower scores rank first for metric
+func Ascending(metric string) bool {
+	return metric == MetricEuclidean
+}
+
+// SortResults orders results best first for metric
+// Equal scores are ordered by vector ID, so rankings do not depend on storage order
+func SortResults(results []*models.SearchResult, metric string) {
+	ascending := Ascending(metric)
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return (results[i].Score < results[j].Score) == ascending
+		}
+		return results[i].Vector.ID < results[j].Vector.ID
+	})
+}
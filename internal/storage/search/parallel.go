@@ -0,0 +1,185 @@
+package search
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// DefaultParallelThreshold is the number of vectors below which searches are scanned serially,
+// since starting workers costs more than it saves on small collections
+const DefaultParallelThreshold = 16384
+
+// workers is the configured number of scan workers, zero for GOMAXPROCS
+var workers atomic.Int64
+
+// parallelThreshold is the configured threshold, zero for the default
+var parallelThreshold atomic.Int64
+
+// Workers returns the number of workers a scan is partitioned between
+func Workers() int {
+	if n := workers.Load(); n > 0 {
+		return int(n)
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// SetWorkers sets the number of scan workers, GOMAXPROCS when n is not positive
+func SetWorkers(n int) {
+	workers.Store(int64(max(n, 0)))
+}
+
+// ParseWorkers parses a SEARCH_WORKERS value, zero when it is empty
+func ParseWorkers(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid SEARCH_WORKERS %q: must be a positive integer", value)
+	}
+	return n, nil
+}
+
+// ParallelThreshold returns the number of vectors from which scans are parallel
+func ParallelThreshold() int {
+	if n := parallelThreshold.Load(); n > 0 {
+		return int(n)
+	}
+	return DefaultParallelThreshold
+}
+
+// SetParallelThreshold sets the number of vectors from which scans are parallel,
+// the default when n is not positive
+func SetParallelThreshold(n int) {
+	parallelThreshold.Store(int64(max(n, 0)))
+}
+
+// TopK scores vectors and returns the k best results, best first, or every result when
+// k is not positive. score returns false for the vectors the search skips, and better
+// must be a total order, such as score then vector ID, so the results do not depend on
+// how the vectors were partitioned.
+// From ParallelThreshold vectors the slice is split between Workers goroutines, each
+// keeping its own top k, merged once they are done. score is called concurrently and
+// must only read the vectors
+func TopK[R any](vectors []*models.Vector, k int, score func(*models.Vector) (R, bool), better func(a, b R) bool) []R {
+	n := Workers()
+	if len(vectors) < ParallelThreshold() || n < 2 {
+		return scan(vectors, k, score, better).sorted()
+	}
+	n = min(n, len(vectors))
+
+	partials := make([]*topK[R], n)
+	size := (len(vectors) + n - 1) / n
+	var wg sync.WaitGroup
+	for i := range partials {
+		start, end := i*size, min((i+1)*size, len(vectors))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			partials[i] = scan(vectors[start:end], k, score, better)
+		}()
+	}
+	wg.Wait()
+
+	merged := partials[0]
+	for _, partial := range partials[1:] {
+		for _, r := range partial.items {
+			merged.push(r)
+		}
+	}
+	return merged.sorted()
+}
+
+// scan scores vectors on the calling goroutine
+func scan[R any](vectors []*models.Vector, k int, score func(*models.Vector) (R, bool), better func(a, b R) bool) *topK[R] {
+	t := &topK[R]{k: k, better: better}
+	for _, vector := range vectors {
+		if r, ok := score(vector); ok {
+			t.push(r)
+		}
+	}
+	return t
+}
+
+// topK keeps the k best results pushed, or all of them when k is not positive
+// Bounded, items is a heap with the worst result first, so it is replaced in O(log k)
+type topK[R any] struct {
+	k      int
+	better func(a, b R) bool
+	items  []R
+}
+
+func (t *topK[R]) push(r R) {
+	if t.k <= 0 {
+		t.items = append(t.items, r)
+		return
+	}
+	if len(t.items) < t.k {
+		t.items = append(t.items, r)
+		t.up(len(t.items) - 1)
+		return
+	}
+	if t.better(r, t.items[0]) {
+		t.items[0] = r
+		t.down(0)
+	}
+}
+
+// worse orders the heap: the worst result is at the root
+func (t *topK[R]) worse(i, j int) bool {
+	return t.better(t.items[j], t.items[i])
+}
+
+func (t *topK[R]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !t.worse(i, parent) {
+			return
+		}
+		t.items[i], t.items[parent] = t.items[parent], t.items[i]
+		i = parent
+	}
+}
+
+func (t *topK[R]) down(i int) {
+	for {
+		worst := i
+		if left := 2*i + 1; left < len(t.items) && t.worse(left, worst) {
+			worst = left
+		}
+		if right := 2*i + 2; right < len(t.items) && t.worse(right, worst) {
+			worst = right
+		}
+		if worst == i {
+			return
+		}
+		t.items[i], t.items[worst] = t.items[worst], t.items[i]
+		i = worst
+	}
+}
+
+// sorted returns the results best first
+func (t *topK[R]) sorted() []R {
+	sort.Slice(t.items, func(i, j int) bool {
+		return t.better(t.items[i], t.items[j])
+	})
+	return t.items
+}
+
+// Better orders search results best first for metric, then by vector ID
+// It is the total order SortResults sorts with, for use with TopK
+func Better(metric string) func(a, b *models.SearchResult) bool {
+	ascending := Ascending(metric)
+	return func(a, b *models.SearchResult) bool {
+		if a.Score != b.Score {
+			return (a.Score < b.Score) == ascending
+		}
+		return a.Vector.ID < b.Vector.ID
+	}
+}
@@ -0,0 +1,117 @@
+package search
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func randomVectors(n, dimension int) []*models.Vector {
+	rng := rand.New(rand.NewSource(1))
+	vectors := make([]*models.Vector, n)
+	for i := range vectors {
+		embedding := make([]float64, dimension)
+		for j := range embedding {
+			embedding[j] = rng.Float64()*2 - 1
+		}
+		vectors[i] = &models.Vector{ID: fmt.Sprintf("v%07d", i), Embedding: embedding}
+		vectors[i].CacheNorm()
+	}
+	return vectors
+}
+
+// withScan sets the scan settings for the duration of a test or benchmark
+func withScan(tb testing.TB, n, threshold int) {
+	tb.Helper()
+	prevWorkers, prevThreshold := workers.Load(), parallelThreshold.Load()
+	SetWorkers(n)
+	SetParallelThreshold(threshold)
+	tb.Cleanup(func() {
+		workers.Store(prevWorkers)
+		parallelThreshold.Store(prevThreshold)
+	})
+}
+
+func TestTopK_ParallelMatchesSerial(t *testing.T) {
+	vectors := randomVectors(5000, 16)
+	// Duplicates score the same, so ties must be broken by ID whatever the partitioning
+	vectors = append(vectors, &models.Vector{ID: "dup", Embedding: vectors[0].Embedding})
+	req := &models.SearchByEmbbedingRequest{Embedding: vectors[0].Embedding, TopK: 25}
+
+	withScan(t, 1, 0)
+	want := FilterAndScoreVectorsByMetric(vectors, req, MetricEuclidean)
+	if len(want) != 25 || want[0].Vector.ID != "dup" || want[1].Vector.ID != "v0000000" {
+		t.Fatalf("serial results start with %v", want[:2])
+	}
+
+	for _, workers := range []int{2, 3, 8, 7000} {
+		withScan(t, workers, 1)
+		if got := FilterAndScoreVectorsByMetric(vectors, req, MetricEuclidean); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers returned other results than the serial scan", workers)
+		}
+	}
+
+	// Without a limit every result is kept, best first
+	all := TopK(vectors, 0, func(v *models.Vector) (*models.SearchResult, bool) {
+		return &models.SearchResult{Vector: v, Score: Score(MetricCosine, vectors[1], v)}, v.ID != "dup"
+	}, Better(MetricCosine))
+	if len(all) != 5000 || all[0].Vector.ID != "v0000001" {
+		t.Errorf("unlimited scan returned %d results", len(all))
+	}
+}
+
+func TestTopK_ConcurrentSearches(t *testing.T) {
+	withScan(t, 4, 1)
+	vectors := randomVectors(2000, 8)
+	req := &models.SearchByEmbbedingRequest{Embedding: vectors[5].Embedding, TopK: 1}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if results := FilterAndScoreVectors(vectors, req); len(results) != 1 || results[0].Vector.ID != "v0000005" {
+				t.Errorf("concurrent search returned %v", results)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParseWorkers(t *testing.T) {
+	if n, err := ParseWorkers(""); err != nil || n != 0 {
+		t.Errorf("empty = %d, %v", n, err)
+	}
+	if n, err := ParseWorkers("8"); err != nil || n != 8 {
+		t.Errorf("8 = %d, %v", n, err)
+	}
+	for _, value := range []string{"0", "-1", "many"} {
+		if _, err := ParseWorkers(value); err == nil {
+			t.Errorf("%q was accepted", value)
+		}
+	}
+}
+
+// BenchmarkTopK scans 1M vectors with 1 to 8 workers, to show how searches scale with cores
+// Run with: go test ./internal/storage/search -run '^$' -bench TopK -benchtime 10x
+func BenchmarkTopK(b *testing.B) {
+	if testing.Short() {
+		b.Skip("builds 1M vectors")
+	}
+	vectors := randomVectors(1_000_000, 64)
+	req := &models.SearchByEmbbedingRequest{Embedding: vectors[0].Embedding, TopK: 10}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			withScan(b, workers, 0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				FilterAndScoreVectors(vectors, req)
+			}
+		})
+	}
+}
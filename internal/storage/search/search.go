@@ -1,145 +1,88 @@
 package search
 
 import (
-	"fmt"
-	"sort"
-
 	"github.com/tahcohcat/same-same/internal/models"
 )
 
 // FilterAndScoreVectors applies advanced filtering and scoring to a slice of vectors.
-// It returns the top N results sorted by score.
+// It returns the top N results sorted by score, scanning large slices in parallel.
 func FilterAndScoreVectors(vectors []*models.Vector, req *models.SearchByEmbbedingRequest) []*models.SearchResult {
-	var results []*models.SearchResult
-	queryVector := &models.Vector{Embedding: req.Embedding}
+	return FilterAndScoreVectorsByMetric(vectors, req, MetricCosine)
+}
 
-	for _, vector := range vectors {
-		if len(vector.Embedding) != len(req.Embedding) {
-			continue
-		}
-		// Advanced filters
-		if len(req.Filters) > 0 && !matchesAdvancedFilters(vector.Metadata, req.Filters) {
-			continue
-		}
-		score := queryVector.CosineSimilarity(vector)
-		results = append(results, &models.SearchResult{
-			Vector: vector,
-			Score:  score,
-		})
-	}
+// FilterAndScoreVectorsByMetric is FilterAndScoreVectors scoring with the given distance metric.
+// Euclidean results are distances sorted ascending, other metrics are similarities sorted descending.
+func FilterAndScoreVectorsByMetric(vectors []*models.Vector, req *models.SearchByEmbbedingRequest, metric string) []*models.SearchResult {
+	queryVector := models.NewQueryVector(req.Embedding, req.Sparse)
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
+	evaluator := &models.FilterEvaluator{KeyFallback: req.UsesKeyFallback()}
+	filters, err := evaluator.CompileSearch(req.Filters)
+	if err != nil {
+		// Invalid filters match nothing rather than everything
+		return nil
+	}
 
 	topK := req.TopK
 	if topK <= 0 {
 		topK = 10
 	}
-	if len(results) > topK {
-		results = results[:topK]
-	}
-	return results
+	return TopK(vectors, topK, func(vector *models.Vector) (*models.SearchResult, bool) {
+		// Pending vectors are not searchable
+		if !vector.HasEmbedding() || !queryVector.Compatible(vector) {
+			return nil, false
+		}
+		if !MatchesNamespace(vector.Metadata, req.Namespace) {
+			return nil, false
+		}
+		// Metadata filters
+		if !evaluator.Matches(vector.Metadata, filters) {
+			return nil, false
+		}
+		match := evaluator.MatchSoft(vector.Metadata, filters)
+		score := WeightedScore(Score(metric, queryVector, vector), req.Options, match, req.SoftFilterPenalty(), metric)
+		score, explanation := req.Scoring.Adjust(score, vector)
+		if explanation != nil {
+			explanation.SoftFilters = match
+		}
+		return &models.SearchResult{
+			Vector:      vector,
+			Score:       score,
+			Explanation: explanation,
+		}, true
+	}, Better(metric))
 }
 
-// matchesMetadata checks legacy metadata equality
-func matchesMetadata(vectorMeta, queryMeta map[string]string) bool {
-	for key, value := range queryMeta {
-		if vectorMeta[key] != value {
-			return false
-		}
+// WeightedScore combines the score of a vector with how it fared against the soft filters
+// of the search. With hybrid weights the fraction of soft filters satisfied is the metadata
+// score, 1 without soft filters since the vector passed every hard one. Otherwise the
+// vector loses penalty times the fraction missed, or gains it with the euclidean metric
+func WeightedScore(score float64, options *models.SearchOptions, match *models.SoftFilterMatch, penalty float64, metric string) float64 {
+	if options != nil && options.HybridWeight != nil {
+		hw := options.HybridWeight
+		return hw.Vector*score + hw.Metadata*match.Fraction()
 	}
-	return true
+	missed := penalty * (1 - match.Fraction())
+	if Ascending(metric) {
+		return score + missed
+	}
+	return score - missed
 }
 
-// Advanced filter support
-func matchesAdvancedFilters(vectorMeta map[string]string, filters []models.MetadataFilter) bool {
-	for _, filter := range filters {
-		val, ok := vectorMeta[filter.Field]
-		if !ok {
-			return false
-		}
-		switch filter.Operator {
-		case "=":
-			if val != fmt.Sprintf("%v", filter.Value) {
-				return false
-			}
-		case "in":
-			arr, ok := filter.Value.([]interface{})
-			if !ok {
-				return false
-			}
-			found := false
-			for _, v := range arr {
-				if val == fmt.Sprintf("%v", v) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return false
-			}
-		case "not_in":
-			arr, ok := filter.Value.([]interface{})
-			if !ok {
-				return false
-			}
-			for _, v := range arr {
-				if val == fmt.Sprintf("%v", v) {
-					return false
-				}
-			}
-		case ">=":
-			if !compareNumeric(val, filter.Value, ">=") {
-				return false
-			}
-		case "<=":
-			if !compareNumeric(val, filter.Value, "<=") {
-				return false
-			}
-		case ">":
-			if !compareNumeric(val, filter.Value, ">") {
-				return false
-			}
-		case "<":
-			if !compareNumeric(val, filter.Value, "<") {
-				return false
-			}
-		default:
-			return false
-		}
+// MatchesNamespace reports whether metadata belongs to namespace
+// An empty namespace matches every vector
+func MatchesNamespace(metadata map[string]string, namespace string) bool {
+	if namespace == "" {
+		return true
 	}
-	return true
+	return matchesMetadata(metadata, map[string]string{models.NamespaceKey: namespace})
 }
 
-func compareNumeric(a string, b interface{}, op string) bool {
-	var af, bf float64
-	_, err := fmt.Sscanf(a, "%f", &af)
-	if err != nil {
-		return false
-	}
-	switch v := b.(type) {
-	case float64:
-		bf = v
-	case int:
-		bf = float64(v)
-	case string:
-		_, err := fmt.Sscanf(v, "%f", &bf)
-		if err != nil {
+// matchesMetadata checks legacy metadata equality
+func matchesMetadata(vectorMeta, queryMeta map[string]string) bool {
+	for key, value := range queryMeta {
+		if vectorMeta[key] != value {
 			return false
 		}
-	default:
-		return false
 	}
-	switch op {
-	case ">=":
-		return af >= bf
-	case "<=":
-		return af <= bf
-	case ">":
-		return af > bf
-	case "<":
-		return af < bf
-	}
-	return false
+	return true
 }
@@ -1,6 +1,27 @@
 package storage
 
-import "github.com/tahcohcat/same-same/internal/models"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/jobs"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/alias"
+	"github.com/tahcohcat/same-same/internal/storage/enrichment"
+	"github.com/tahcohcat/same-same/internal/storage/eval"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
+	"github.com/tahcohcat/same-same/internal/storage/metaschema"
+	"github.com/tahcohcat/same-same/internal/storage/profile"
+	"github.com/tahcohcat/same-same/internal/storage/quarantine"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+	"github.com/tahcohcat/same-same/internal/storage/uniquekey"
+	"github.com/tahcohcat/same-same/internal/storage/watch"
+)
 
 // Storage is the interface for vector storage backends
 // Both memory and local file storage should implement this
@@ -9,10 +30,397 @@ import "github.com/tahcohcat/same-same/internal/models"
 type Storage interface {
 	Store(vector *models.Vector) error
 	Get(id string) (*models.Vector, error)
+	// GetMany returns the vectors of ids in order, nil for the IDs not stored
+	GetMany(ids []string) ([]*models.Vector, error)
 	List() ([]*models.Vector, error)
+	ListByNamespace(namespace string) ([]*models.Vector, error)
 	Delete(id string) error
 	Count() int
+	CountByNamespace(namespace string) int
 	Search(req *models.SearchByEmbbedingRequest) ([]*models.SearchResult, error)
 	AdvancedSearch(req *models.AdvancedSearchRequest, queryEmbedding []float64) ([]*models.SearchResult, error)
 	TemporalSearch(req *models.TemporalSearchRequest, queryEmbedding []float64) ([]*models.TemporalSearchResult, error)
 }
+
+// BatchStorer is implemented by backends that can store many vectors at once
+type BatchStorer interface {
+	StoreBatch(vectors []*models.Vector) error
+}
+
+// StoreBatch stores vectors using the backend batch path when available,
+// falling back to storing them one by one
+func StoreBatch(s Storage, vectors []*models.Vector) error {
+	if bs, ok := s.(BatchStorer); ok {
+		return bs.StoreBatch(vectors)
+	}
+
+	for _, vector := range vectors {
+		if err := s.Store(vector); err != nil {
+			return fmt.Errorf("failed to store vector %s: %w", vector.ID, err)
+		}
+	}
+	return nil
+}
+
+// BatchDeleter is implemented by backends that can delete many vectors at once
+type BatchDeleter interface {
+	DeleteBatch(ids []string) (int, error)
+}
+
+// DeleteBatch deletes the vectors of ids, returning how many existed and were deleted,
+// using the backend batch path when available and deleting them one by one otherwise
+func DeleteBatch(s Storage, ids []string) (int, error) {
+	if bd, ok := s.(BatchDeleter); ok {
+		return bd.DeleteBatch(ids)
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if err := s.Delete(id); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return deleted, fmt.Errorf("failed to delete vector %s: %w", id, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// AtomicStorer is implemented by backends that can store related vectors, such
+// as a document and its chunks, all or nothing: when StoreAll fails none of the
+// vectors is stored and vectors they would have replaced are left unchanged
+type AtomicStorer interface {
+	StoreAll(vectors []*models.Vector) error
+}
+
+// StoreAll stores vectors all or nothing, failing if the backend cannot
+func StoreAll(s Storage, vectors []*models.Vector) error {
+	as, ok := s.(AtomicStorer)
+	if !ok {
+		return fmt.Errorf("storage backend does not support atomic batches")
+	}
+	return as.StoreAll(vectors)
+}
+
+// PendingCounter is implemented by backends that count pending vectors, stored
+// without an embedding, without listing them
+type PendingCounter interface {
+	CountPending(namespace string) int
+}
+
+// CountPending returns the number of pending vectors in namespace, or in all
+// namespaces if namespace is empty
+func CountPending(s Storage, namespace string) (int, error) {
+	if pc, ok := s.(PendingCounter); ok {
+		return pc.CountPending(namespace), nil
+	}
+
+	vectors, err := s.ListByNamespace(namespace)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, vector := range vectors {
+		if !vector.HasEmbedding() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ProfileStore is implemented by backends that hold named ranking profiles
+type ProfileStore interface {
+	Profiles() profile.Profiles
+	SetProfile(p profile.Profile) error
+	DeleteProfile(name string) error
+}
+
+// AliasStore is implemented by backends that hold namespace aliases
+type AliasStore interface {
+	Aliases() alias.Aliases
+	// ResolveAlias returns the namespace an alias points at, a single map lookup
+	ResolveAlias(name string) (string, bool)
+	SetAlias(a alias.Alias) error
+	DeleteAlias(name string) error
+}
+
+// QuarantineStore is implemented by backends that keep the records batches and
+// ingests could not store, capped with the oldest entries evicted first
+type QuarantineStore interface {
+	Quarantine(entries ...quarantine.Entry) error
+	Quarantined() []quarantine.Entry
+	// RemoveQuarantined drops entries, once retried, failing when one is unknown
+	RemoveQuarantined(ids ...string) error
+	QuarantineStats() quarantine.Stats
+	// SetQuarantineLimit caps the entries kept, 0 for quarantine.DefaultMaxEntries
+	SetQuarantineLimit(max int) error
+}
+
+// EvalStore is implemented by backends that hold evaluation sets and the history of their runs
+type EvalStore interface {
+	EvalSets() eval.Sets
+	CreateEvalSet(set eval.Set) error
+	DeleteEvalSet(name string) error
+	RecordEvalRun(run *eval.Run) error
+	ListEvalRuns(set string) ([]*eval.Run, error)
+}
+
+// JobStore is implemented by backends that persist background jobs, such as bulk
+// maintenance, so unfinished jobs resume after a restart
+type JobStore interface {
+	Jobs() ([]*jobs.Job, error)
+	SaveJob(job *jobs.Job) error
+}
+
+// WatchStore is implemented by backends that persist watches, the standing queries
+// evaluated against stored vectors, so they survive a restart
+type WatchStore interface {
+	Watches() ([]*watch.Watch, error)
+	SaveWatch(w *watch.Watch) error
+	DeleteWatch(id string) error
+}
+
+// EnrichmentStore is implemented by backends that keep enrichment documents
+// alongside the vectors. GetEnrichment omits the keys it does not hold, and
+// DeleteEnrichment fails with enrichment.ErrNotFound for them
+type EnrichmentStore interface {
+	GetEnrichment(keys []string) (map[string]json.RawMessage, error)
+	SetEnrichment(entries map[string]json.RawMessage) error
+	DeleteEnrichment(key string) error
+}
+
+// VocabularyStore is implemented by backends that save the vocabulary of an
+// embedders.Fitter with the vectors it embedded
+type VocabularyStore interface {
+	// Vocabulary returns the saved vocabulary, nil when there is none
+	Vocabulary() ([]byte, error)
+	SaveVocabulary(data []byte) error
+}
+
+// LoadVocabulary loads the vocabulary saved with the vectors of s into e, so that it
+// embeds into their space, and reports whether there was one. It does nothing when e
+// learns no vocabulary or the backend keeps none
+func LoadVocabulary(s Storage, e embedders.Embedder) (bool, error) {
+	vs, ok := s.(VocabularyStore)
+	fitter, fits := e.(embedders.Fitter)
+	if !ok || !fits {
+		return false, nil
+	}
+	data, err := vs.Vocabulary()
+	if err != nil || data == nil {
+		return false, err
+	}
+	return true, fitter.LoadVocabulary(data)
+}
+
+// SaveVocabulary saves the fixed vocabulary of e with the vectors of s
+// It does nothing when e has no fixed vocabulary or the backend keeps none
+func SaveVocabulary(s Storage, e embedders.Embedder) error {
+	vs, ok := s.(VocabularyStore)
+	fitter, fits := e.(embedders.Fitter)
+	if !ok || !fits || !fitter.Fixed() {
+		return nil
+	}
+	data, err := fitter.SaveVocabulary()
+	if err != nil {
+		return err
+	}
+	return vs.SaveVocabulary(data)
+}
+
+// DeleteEnrichment deletes the enrichment document of key, if any
+// It does nothing when the backend keeps no enrichment
+func DeleteEnrichment(s Storage, key string) error {
+	es, ok := s.(EnrichmentStore)
+	if !ok {
+		return nil
+	}
+	if err := es.DeleteEnrichment(key); err != nil && !errors.Is(err, enrichment.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// GenerationTracker is implemented by backends that count their mutations
+// The generation increases on every Store, Delete and batch write, so clients
+// can tell whether cached results are still current
+type GenerationTracker interface {
+	Generation() uint64
+}
+
+// Generation returns the mutation generation of s, and false when the backend does not track it
+func Generation(s Storage) (uint64, bool) {
+	if gt, ok := s.(GenerationTracker); ok {
+		return gt.Generation(), true
+	}
+	return 0, false
+}
+
+// QuotaManager is implemented by backends that enforce per-namespace quotas on Store and StoreBatch
+// Rejected writes return a *quota.Error matching quota.ErrExceeded
+type QuotaManager interface {
+	Quotas() quota.Limits
+	SetQuota(namespace string, limit quota.Limit) error
+	QuotaUsage() map[string]quota.Usage
+}
+
+// MemoryManager is implemented by backends holding every vector in memory within limits
+// Writes past a limit are rejected with a *memlimit.Error matching ErrQuotaExceeded,
+// or evict stored vectors, as the policy chooses
+type MemoryManager interface {
+	MemoryReport() memlimit.Report
+	SetMemoryLimits(opts memlimit.Options) error
+}
+
+// ChangeLister is implemented by backends that list the vectors updated and deleted since a time
+// Deleted vector IDs are kept as tombstones in a log bounded by the tombstone options
+type ChangeLister interface {
+	Changes(namespace string, since time.Time) (*tombstone.Changes, error)
+	SetTombstoneOptions(opts tombstone.Options) error
+}
+
+// RecentLister is implemented by backends that keep their vectors ordered by
+// creation time, listing the newest without scanning every vector
+type RecentLister interface {
+	Recent(namespace string, limit int) ([]*models.Vector, error)
+}
+
+// Recent returns the newest vectors of namespace, newest first, or of all
+// namespaces if namespace is empty, sorting a full listing when the backend
+// does not keep them ordered
+func Recent(s Storage, namespace string, limit int) ([]*models.Vector, error) {
+	if rl, ok := s.(RecentLister); ok {
+		return rl.Recent(namespace, limit)
+	}
+
+	vectors, err := s.ListByNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(vectors, func(i, j int) bool {
+		return vectors[i].CreatedAt.After(vectors[j].CreatedAt)
+	})
+	if len(vectors) > limit {
+		vectors = vectors[:limit]
+	}
+	return vectors, nil
+}
+
+// SchemaDescriber is implemented by backends that keep a summary of the metadata
+// fields of their vectors up to date as vectors are stored and deleted
+type SchemaDescriber interface {
+	MetadataSchema(namespace string) (*metaschema.Schema, error)
+}
+
+// MetadataSchema summarizes the metadata fields of the vectors of namespace, or of
+// all namespaces if namespace is empty, scanning a full listing when the backend
+// does not keep a summary
+func MetadataSchema(s Storage, namespace string) (*metaschema.Schema, error) {
+	if sd, ok := s.(SchemaDescriber); ok {
+		return sd.MetadataSchema(namespace)
+	}
+
+	vectors, err := s.ListByNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make([]map[string]string, len(vectors))
+	for i, vector := range vectors {
+		metadata[i] = vector.Metadata
+	}
+	return metaschema.Build(metadata).Schema(namespace), nil
+}
+
+// FacetCounter is implemented by backends that keep the counts of the values of
+// the metadata fields of their vectors up to date as vectors are stored and deleted
+type FacetCounter interface {
+	Facet(namespace, field string, limit int) (*metaschema.Facet, error)
+}
+
+// Facet counts the vectors of each value of a metadata field in namespace, or in
+// all namespaces if namespace is empty, the limit most frequent when limit is
+// positive. Fields with many distinct values are counted approximately
+func Facet(s Storage, namespace, field string, limit int) (*metaschema.Facet, error) {
+	if fc, ok := s.(FacetCounter); ok {
+		return fc.Facet(namespace, field, limit)
+	}
+
+	vectors, err := s.ListByNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make([]map[string]string, len(vectors))
+	for i, vector := range vectors {
+		metadata[i] = vector.Metadata
+	}
+	return metaschema.Build(metadata).Facet(namespace, field, limit)
+}
+
+// RunRecorder is implemented by backends that keep a history of ingest runs
+type RunRecorder interface {
+	RecordRun(run *models.IngestRun) error
+	ListRuns(filter models.IngestRunFilter) ([]*models.IngestRun, error)
+}
+
+// UniqueKeyIndexer is implemented by backends that enforce unique metadata fields
+// A value of a unique field belongs to one vector per namespace, writes giving it
+// to another vector return a *uniquekey.Error matching uniquekey.ErrDuplicate
+type UniqueKeyIndexer interface {
+	UniqueKeys() []string
+	SetUniqueKeys(fields []string) error
+	GetByKey(namespace, field, value string) (*models.Vector, error)
+}
+
+// ResolveUniqueKeys gives each vector the ID of the stored vector sharing one of
+// its unique field values, so storing it updates that vector instead of conflicting
+// Vectors of the same slice sharing a value get the same ID, the last one wins
+func ResolveUniqueKeys(s Storage, vectors []*models.Vector) error {
+	indexer, ok := s.(UniqueKeyIndexer)
+	if !ok {
+		return nil
+	}
+	fields := indexer.UniqueKeys()
+	if len(fields) == 0 {
+		return nil
+	}
+
+	type key struct{ namespace, field, value string }
+	assigned := make(map[key]string)
+
+	for _, vector := range vectors {
+		namespace := vector.Metadata[models.NamespaceKey]
+		resolved := ""
+		for _, field := range fields {
+			value := vector.Metadata[field]
+			if value == "" {
+				continue
+			}
+
+			k := key{namespace, field, value}
+			id, seen := assigned[k]
+			if !seen {
+				if existing, err := indexer.GetByKey(namespace, field, value); err == nil {
+					id = existing.ID
+				}
+			}
+			if id == "" {
+				continue
+			}
+			if resolved != "" && resolved != id {
+				return &uniquekey.Error{Namespace: namespace, Field: field, Value: value, ID: resolved, ExistingID: id}
+			}
+			resolved = id
+		}
+
+		if resolved != "" {
+			vector.ID = resolved
+		}
+		for _, field := range fields {
+			if value := vector.Metadata[field]; value != "" {
+				assigned[key{namespace, field, value}] = vector.ID
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Package storeerr defines the kinds of error storage backends return, so callers
+// can tell a missing vector or a rejected write from a failing disk. Package storage
+// re-exports them; backends use this package since they cannot import storage
+package storeerr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds, matched with errors.Is
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrAlreadyExists     = errors.New("already exists")
+	ErrDimensionMismatch = errors.New("dimension mismatch")
+	ErrValidation        = errors.New("invalid")
+	ErrQuotaExceeded     = errors.New("quota exceeded")
+)
+
+// kindError gives err a kind without changing its message
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap matches both the kind and the errors wrapped by err
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// Wrap returns err matching kind with errors.Is, keeping its message
+// A nil err stays nil, and errors already of kind are returned unchanged
+func Wrap(kind, err error) error {
+	if err == nil || errors.Is(err, kind) {
+		return err
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// NotFoundf formats an error matching ErrNotFound
+func NotFoundf(format string, args ...interface{}) error {
+	return Wrap(ErrNotFound, fmt.Errorf(format, args...))
+}
+
+// AlreadyExistsf formats an error matching ErrAlreadyExists
+func AlreadyExistsf(format string, args ...interface{}) error {
+	return Wrap(ErrAlreadyExists, fmt.Errorf(format, args...))
+}
+
+// DimensionMismatchf formats an error matching ErrDimensionMismatch
+func DimensionMismatchf(format string, args ...interface{}) error {
+	return Wrap(ErrDimensionMismatch, fmt.Errorf(format, args...))
+}
+
+// Validationf formats an error matching ErrValidation
+func Validationf(format string, args ...interface{}) error {
+	return Wrap(ErrValidation, fmt.Errorf(format, args...))
+}
@@ -0,0 +1,634 @@
+// Package tiered combines the memory and local backends: the local tier holds every
+// vector durably, the memory tier the vectors read and searched
+package tiered
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/metaschema"
+	"github.com/tahcohcat/same-same/internal/storage/quota"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+	"github.com/tahcohcat/same-same/internal/storage/tombstone"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultQueueSize is the number of writes write-behind mode lets wait for the local tier
+const DefaultQueueSize = 1024
+
+// PreloadAll loads every vector of the local tier into the memory tier when opened
+const PreloadAll = -1
+
+// Options configures a tiered storage
+type Options struct {
+	// Preload is the number of the most recently updated vectors loaded into the
+	// memory tier when the storage is opened, within its limits. Zero loads none,
+	// PreloadAll every vector
+	Preload int
+
+	// Memory caps the memory tier. Past a limit its policy evicts vectors, or leaves
+	// new ones out under the reject policy; the local tier keeps them either way
+	Memory memlimit.Options
+
+	// WriteBehind returns from writes once the memory tier holds them, writing them
+	// to the local tier in the background. Writes waiting for the local tier, at most
+	// QueueSize, are lost if the process dies before Close or Flush
+	WriteBehind bool
+	QueueSize   int
+}
+
+// Validate rejects negative queue sizes and invalid memory limits
+func (o Options) Validate() error {
+	if o.QueueSize < 0 {
+		return fmt.Errorf("invalid queue size %d: must not be negative", o.QueueSize)
+	}
+	return o.Memory.Validate()
+}
+
+// Stats reports the reads served by each tier and the writes waiting for the local tier
+type Stats struct {
+	MemoryVectors int     `json:"memory_vectors"`
+	Hits          uint64  `json:"hits"`   // Reads served by the memory tier
+	Misses        uint64  `json:"misses"` // Reads that fell back to the local tier
+	HitRate       float64 `json:"hit_rate"`
+	WriteBehind   bool    `json:"write_behind"`
+	QueueDepth    int     `json:"queue_depth,omitempty"`
+	QueueSize     int     `json:"queue_size,omitempty"`
+	// WriteFailures counts the queued writes the local tier refused, which are
+	// dropped from the memory tier too
+	WriteFailures uint64          `json:"write_failures,omitempty"`
+	Memory        memlimit.Report `json:"memory"`
+}
+
+// Storage serves reads and searches from a memory tier in front of a local tier
+// Methods it does not override, such as profiles, quotas and unique keys, are
+// those of the local tier
+type Storage struct {
+	*local.VectorStorageAdapter
+
+	hot  *memory.Storage
+	opts Options
+
+	hits, misses atomic.Uint64
+
+	// writeMu orders writes to both tiers, so they apply them in the same order
+	writeMu sync.Mutex
+
+	// Write-behind mode only
+	queue    chan write
+	pendMu   sync.Mutex
+	pending  map[string]*models.Vector // Latest queued version of each vector, held by the memory tier
+	failures atomic.Uint64
+	stopped  chan struct{}
+	closed   bool // guarded by writeMu
+}
+
+// write is a write queued for the local tier, or a flush marker when done is set
+type write struct {
+	vectors []*models.Vector
+	cached  []*models.Vector // The copies held by the memory tier and pending
+	atomic  bool
+	done    chan struct{}
+}
+
+// New puts a memory tier in front of cold, preloading it as opts configures
+func New(cold *local.VectorStorageAdapter, opts Options) (*Storage, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	hot := memory.NewStorage()
+	if err := hot.SetMemoryLimits(opts.Memory); err != nil {
+		return nil, err
+	}
+
+	s := &Storage{VectorStorageAdapter: cold, hot: hot, opts: opts}
+	if err := s.preload(); err != nil {
+		return nil, err
+	}
+
+	if opts.WriteBehind {
+		if s.opts.QueueSize == 0 {
+			s.opts.QueueSize = DefaultQueueSize
+		}
+		s.queue = make(chan write, s.opts.QueueSize)
+		s.pending = make(map[string]*models.Vector)
+		s.stopped = make(chan struct{})
+		go s.writeBehind()
+	}
+	return s, nil
+}
+
+// preload loads the most recently updated vectors of the local tier into the memory
+// tier, as many as Preload asks for and its limits allow
+func (s *Storage) preload() error {
+	if s.opts.Preload == 0 {
+		return nil
+	}
+
+	vectors, err := s.VectorStorageAdapter.List()
+	if err != nil {
+		return fmt.Errorf("failed to preload the memory tier: %w", err)
+	}
+	sort.Slice(vectors, func(i, j int) bool {
+		if !vectors[i].UpdatedAt.Equal(vectors[j].UpdatedAt) {
+			return vectors[i].UpdatedAt.After(vectors[j].UpdatedAt)
+		}
+		return vectors[i].ID < vectors[j].ID
+	})
+	if s.opts.Preload > 0 && len(vectors) > s.opts.Preload {
+		vectors = vectors[:s.opts.Preload]
+	}
+
+	// Stop at the limits rather than have the policy evict the vectors just loaded
+	var bytes int64
+	limits := s.opts.Memory
+	for i, vector := range vectors {
+		bytes += memlimit.SizeOf(vector)
+		if (limits.MaxVectors > 0 && i >= limits.MaxVectors) || (limits.MaxBytes > 0 && bytes > limits.MaxBytes) {
+			vectors = vectors[:i]
+			break
+		}
+	}
+
+	// The oldest first, so an LRU policy evicts them before the newest
+	for i, j := 0, len(vectors)-1; i < j; i, j = i+1, j-1 {
+		vectors[i], vectors[j] = vectors[j], vectors[i]
+	}
+	if err := s.hot.StoreBatch(vectors); err != nil {
+		return fmt.Errorf("failed to preload the memory tier: %w", err)
+	}
+
+	logrus.WithField("vectors", len(vectors)).Debug("memory tier preloaded")
+	return nil
+}
+
+// Store writes vector to both tiers, or to the memory tier and the write-behind queue
+func (s *Storage) Store(vector *models.Vector) error {
+	if vector.ID == "" {
+		return storeerr.Validationf("vector ID cannot be empty")
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	stamp(time.Now(), vector)
+	if s.opts.WriteBehind {
+		return s.enqueue([]*models.Vector{vector}, false)
+	}
+
+	if err := s.VectorStorageAdapter.Store(vector); err != nil {
+		return err
+	}
+	s.cache([]*models.Vector{vector})
+	return nil
+}
+
+// StoreAll writes related vectors to both tiers all or nothing, or queues them
+// to be written to the local tier together
+func (s *Storage) StoreAll(vectors []*models.Vector) error {
+	if err := models.ValidateBatch(vectors, true); err != nil {
+		return storeerr.Wrap(storeerr.ErrValidation, err)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	now := time.Now()
+	for _, vector := range vectors {
+		stamp(now, vector)
+	}
+	if s.opts.WriteBehind {
+		return s.enqueue(vectors, true)
+	}
+
+	if err := s.VectorStorageAdapter.StoreAll(vectors); err != nil {
+		return err
+	}
+	s.cache(vectors)
+	return nil
+}
+
+// stamp sets the timestamps of a vector about to be stored, as the local tier would
+func stamp(now time.Time, vector *models.Vector) {
+	if vector.CreatedAt.IsZero() {
+		vector.CreatedAt = now
+	}
+	vector.UpdatedAt = now
+}
+
+// cache puts copies of vectors written to the local tier in the memory tier
+// Vectors its limits leave out are dropped from it, so it never serves an older
+// version. Caller must hold writeMu
+func (s *Storage) cache(vectors []*models.Vector) {
+	copies := make([]*models.Vector, len(vectors))
+	ids := make([]string, len(vectors))
+	for i, vector := range vectors {
+		copied := *vector
+		copies[i], ids[i] = &copied, vector.ID
+	}
+	if err := s.hot.StoreBatch(copies); err != nil {
+		s.hot.DeleteBatch(ids)
+	}
+}
+
+// Get returns a vector from the memory tier, or loads it from the local tier into it
+func (s *Storage) Get(id string) (*models.Vector, error) {
+	if vector, err := s.hot.Get(id); err == nil {
+		s.hits.Add(1)
+		return vector, nil
+	}
+	if vector, ok := s.queued(id); ok {
+		s.hits.Add(1)
+		return vector, nil
+	}
+
+	s.misses.Add(1)
+	generation := s.VectorStorageAdapter.Generation()
+	vector, err := s.VectorStorageAdapter.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	s.populate(generation, []*models.Vector{vector})
+	return vector, nil
+}
+
+// GetMany returns the vectors of ids in order, nil for the IDs not stored, loading
+// those missing from the memory tier from the local tier
+func (s *Storage) GetMany(ids []string) ([]*models.Vector, error) {
+	vectors, err := s.hot.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	var at []int
+	for i, vector := range vectors {
+		if vector == nil {
+			if queued, ok := s.queued(ids[i]); ok {
+				vectors[i] = queued
+				continue
+			}
+			missing = append(missing, ids[i])
+			at = append(at, i)
+		}
+	}
+	s.hits.Add(uint64(len(ids) - len(missing)))
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	s.misses.Add(uint64(len(missing)))
+	generation := s.VectorStorageAdapter.Generation()
+	loaded, err := s.VectorStorageAdapter.GetMany(missing)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]*models.Vector, 0, len(loaded))
+	for i, vector := range loaded {
+		vectors[at[i]] = vector
+		if vector != nil {
+			found = append(found, vector)
+		}
+	}
+	s.populate(generation, found)
+	return vectors, nil
+}
+
+// populate caches vectors read from the local tier at generation, unless it was
+// written since, which could have replaced them
+func (s *Storage) populate(generation uint64, vectors []*models.Vector) {
+	if len(vectors) == 0 {
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if s.VectorStorageAdapter.Generation() != generation {
+		return
+	}
+	for _, vector := range vectors {
+		if _, ok := s.queued(vector.ID); ok {
+			return
+		}
+	}
+	s.cache(vectors)
+}
+
+// Delete deletes a vector from both tiers, once the queued writes are applied
+func (s *Storage) Delete(id string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.drain()
+	if err := s.VectorStorageAdapter.Delete(id); err != nil {
+		return err
+	}
+	if err := s.hot.Delete(id); err != nil && !errors.Is(err, storeerr.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// DeleteBatch deletes vectors from both tiers, once the queued writes are applied
+func (s *Storage) DeleteBatch(ids []string) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.drain()
+	deleted, err := s.VectorStorageAdapter.DeleteBatch(ids)
+	if err != nil {
+		return deleted, err
+	}
+	s.hot.DeleteBatch(ids)
+	return deleted, nil
+}
+
+// Search searches the vectors of the memory tier
+func (s *Storage) Search(req *models.SearchByEmbbedingRequest) ([]*models.SearchResult, error) {
+	return s.hot.Search(req)
+}
+
+// AdvancedSearch searches the vectors of the memory tier
+func (s *Storage) AdvancedSearch(req *models.AdvancedSearchRequest, queryEmbedding []float64) ([]*models.SearchResult, error) {
+	return s.hot.AdvancedSearch(req, queryEmbedding)
+}
+
+// TemporalSearch searches the vectors of the memory tier
+func (s *Storage) TemporalSearch(req *models.TemporalSearchRequest, queryEmbedding []float64) ([]*models.TemporalSearchResult, error) {
+	return s.hot.TemporalSearch(req, queryEmbedding)
+}
+
+// List returns every vector of the local tier
+func (s *Storage) List() ([]*models.Vector, error) {
+	s.settle()
+	return s.VectorStorageAdapter.List()
+}
+
+// ListByNamespace returns the vectors of the local tier in namespace
+func (s *Storage) ListByNamespace(namespace string) ([]*models.Vector, error) {
+	s.settle()
+	return s.VectorStorageAdapter.ListByNamespace(namespace)
+}
+
+// Count returns the number of vectors of the local tier
+func (s *Storage) Count() int {
+	s.settle()
+	return s.VectorStorageAdapter.Count()
+}
+
+// CountByNamespace returns the number of vectors of the local tier in namespace
+func (s *Storage) CountByNamespace(namespace string) int {
+	s.settle()
+	return s.VectorStorageAdapter.CountByNamespace(namespace)
+}
+
+// GetByKey looks a unique key value up in the local tier
+func (s *Storage) GetByKey(namespace, field, value string) (*models.Vector, error) {
+	s.settle()
+	return s.VectorStorageAdapter.GetByKey(namespace, field, value)
+}
+
+// Changes lists the vectors of the local tier updated and deleted since a time
+func (s *Storage) Changes(namespace string, since time.Time) (*tombstone.Changes, error) {
+	s.settle()
+	return s.VectorStorageAdapter.Changes(namespace, since)
+}
+
+// Recent returns the newest vectors of the local tier
+func (s *Storage) Recent(namespace string, limit int) ([]*models.Vector, error) {
+	s.settle()
+	return s.VectorStorageAdapter.Recent(namespace, limit)
+}
+
+// IDs returns the IDs of the vectors of the local tier in namespace
+func (s *Storage) IDs(namespace string) ([]string, error) {
+	s.settle()
+	return s.VectorStorageAdapter.IDs(namespace)
+}
+
+// Facet counts the values of a metadata field over the vectors of the local tier
+func (s *Storage) Facet(namespace, field string, limit int) (*metaschema.Facet, error) {
+	s.settle()
+	return s.VectorStorageAdapter.Facet(namespace, field, limit)
+}
+
+// MetadataSchema returns the metadata schema of the vectors of the local tier
+func (s *Storage) MetadataSchema(namespace string) (*metaschema.Schema, error) {
+	s.settle()
+	return s.VectorStorageAdapter.MetadataSchema(namespace)
+}
+
+// CountPending returns the number of vectors of the local tier waiting for an embedding
+func (s *Storage) CountPending(namespace string) int {
+	s.settle()
+	return s.VectorStorageAdapter.CountPending(namespace)
+}
+
+// QuotaUsage returns the usage of the namespace quotas of the local tier
+func (s *Storage) QuotaUsage() map[string]quota.Usage {
+	s.settle()
+	return s.VectorStorageAdapter.QuotaUsage()
+}
+
+// Verify checks the files of the local tier once the queued writes reached them
+func (s *Storage) Verify(opts local.VerifyOptions) (*local.VerifyReport, error) {
+	s.settle()
+	return s.VectorStorageAdapter.Verify(opts)
+}
+
+// Reconcile reconciles the local tier, then reloads the memory tier from it
+func (s *Storage) Reconcile(opts local.ReconcileOptions) (*local.ReconcileReport, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.drain()
+	report, err := s.VectorStorageAdapter.Reconcile(opts)
+	if err != nil || opts.DryRun {
+		return report, err
+	}
+
+	vectors, _ := s.hot.List()
+	ids := make([]string, len(vectors))
+	for i, vector := range vectors {
+		ids[i] = vector.ID
+	}
+	s.hot.DeleteBatch(ids)
+	return report, s.preload()
+}
+
+// Generation increases on every write to either tier
+func (s *Storage) Generation() uint64 {
+	return s.hot.Generation() + s.VectorStorageAdapter.Generation()
+}
+
+// SetMemoryLimits caps the memory tier
+func (s *Storage) SetMemoryLimits(opts memlimit.Options) error {
+	if err := s.hot.SetMemoryLimits(opts); err != nil {
+		return err
+	}
+	s.opts.Memory = opts
+	return nil
+}
+
+// MemoryReport returns the limits and usage of the memory tier
+func (s *Storage) MemoryReport() memlimit.Report {
+	return s.hot.MemoryReport()
+}
+
+// TierStats reports the reads served by each tier and the write-behind queue
+func (s *Storage) TierStats() Stats {
+	hits, misses := s.hits.Load(), s.misses.Load()
+	stats := Stats{
+		MemoryVectors: s.hot.Count(),
+		Hits:          hits,
+		Misses:        misses,
+		WriteBehind:   s.opts.WriteBehind,
+		WriteFailures: s.failures.Load(),
+		Memory:        s.hot.MemoryReport(),
+	}
+	if lookups := hits + misses; lookups > 0 {
+		stats.HitRate = float64(hits) / float64(lookups)
+	}
+	if s.opts.WriteBehind {
+		stats.QueueDepth = len(s.queue)
+		stats.QueueSize = s.opts.QueueSize
+	}
+	return stats
+}
+
+// GetStats returns the statistics of the local tier with those of the tiers
+func (s *Storage) GetStats() map[string]interface{} {
+	stats := s.VectorStorageAdapter.GetStats()
+	stats["tiers"] = s.TierStats()
+	return stats
+}
+
+// Flush waits for the queued writes, then flushes the local tier to disk
+func (s *Storage) Flush() error {
+	s.settle()
+	return s.VectorStorageAdapter.Flush()
+}
+
+// Close writes the queued writes to the local tier and closes it
+func (s *Storage) Close() error {
+	s.writeMu.Lock()
+	if s.opts.WriteBehind && !s.closed {
+		s.closed = true
+		close(s.queue)
+		<-s.stopped
+	}
+	s.writeMu.Unlock()
+	return s.VectorStorageAdapter.Close()
+}
+
+// enqueue puts copies of vectors in the memory tier and queues them for the local
+// tier, waiting while the queue is full. Caller must hold writeMu
+func (s *Storage) enqueue(vectors []*models.Vector, atomic bool) error {
+	if s.closed {
+		return errors.New("tiered storage is closed")
+	}
+
+	w := write{
+		vectors: make([]*models.Vector, len(vectors)),
+		cached:  make([]*models.Vector, len(vectors)),
+		atomic:  atomic,
+	}
+	ids := make([]string, len(vectors))
+	for i, vector := range vectors {
+		queued, cached := *vector, *vector
+		w.vectors[i], w.cached[i], ids[i] = &queued, &cached, vector.ID
+	}
+
+	s.pendMu.Lock()
+	// Vectors the memory tier refuses are still read from pending until written
+	if err := s.hot.StoreBatch(w.cached); err != nil {
+		s.hot.DeleteBatch(ids)
+	}
+	for _, cached := range w.cached {
+		s.pending[cached.ID] = cached
+	}
+	s.pendMu.Unlock()
+
+	s.queue <- w
+	return nil
+}
+
+// queued returns the latest version of a vector waiting for the local tier
+func (s *Storage) queued(id string) (*models.Vector, bool) {
+	if !s.opts.WriteBehind {
+		return nil, false
+	}
+
+	s.pendMu.Lock()
+	defer s.pendMu.Unlock()
+
+	vector, ok := s.pending[id]
+	return vector, ok
+}
+
+// writeBehind applies the queued writes to the local tier until the queue is closed
+// A write the local tier refuses is logged and dropped from the memory tier, unless
+// a later write replaced it there
+func (s *Storage) writeBehind() {
+	defer close(s.stopped)
+
+	for w := range s.queue {
+		if w.done != nil {
+			close(w.done)
+			continue
+		}
+
+		var err error
+		if w.atomic {
+			err = s.VectorStorageAdapter.StoreAll(w.vectors)
+		} else {
+			err = s.VectorStorageAdapter.Store(w.vectors[0])
+		}
+
+		s.pendMu.Lock()
+		for _, cached := range w.cached {
+			if s.pending[cached.ID] != cached {
+				continue
+			}
+			delete(s.pending, cached.ID)
+			if err != nil {
+				s.hot.Delete(cached.ID)
+			}
+		}
+		s.pendMu.Unlock()
+
+		if err != nil {
+			s.failures.Add(1)
+			logrus.WithError(err).WithField("vectors", len(w.vectors)).Error("failed to write behind to the local tier")
+		}
+	}
+}
+
+// settle waits for the queued writes to be applied to the local tier
+func (s *Storage) settle() {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.drain()
+}
+
+// drain waits for the writes queued so far. Caller must hold writeMu
+func (s *Storage) drain() {
+	if !s.opts.WriteBehind || s.closed {
+		return
+	}
+	done := make(chan struct{})
+	s.queue <- write{done: done}
+	<-done
+}
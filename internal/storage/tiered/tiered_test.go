@@ -0,0 +1,240 @@
+package tiered
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memlimit"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// openLocal opens the local storage of dir, closed when the test ends
+func openLocal(t *testing.T, dir string) *local.VectorStorageAdapter {
+	t.Helper()
+	adapter, err := local.NewVectorStorageAdapter(dir, "vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { adapter.Close() })
+	return adapter
+}
+
+func vector(id string, x float64) *models.Vector {
+	return &models.Vector{ID: id, Embedding: []float64{x, 1}}
+}
+
+func TestStorage_ReadThrough(t *testing.T) {
+	dir := t.TempDir()
+	cold := openLocal(t, dir)
+	for i, id := range []string{"a", "b", "c"} {
+		if err := cold.Store(vector(id, float64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := New(cold, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.TierStats(); stats.MemoryVectors != 0 {
+		t.Fatalf("memory tier loaded %d vectors without preload", stats.MemoryVectors)
+	}
+
+	// A miss loads the vector into the memory tier, where the next read finds it
+	for i := 0; i < 2; i++ {
+		if got, err := s.Get("a"); err != nil || got.ID != "a" {
+			t.Fatalf("get a = %v, %v", got, err)
+		}
+	}
+	vectors, err := s.GetMany([]string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vectors[0] == nil || vectors[1] == nil || vectors[2] != nil {
+		t.Errorf("get many = %v", vectors)
+	}
+	if _, err := s.Get("missing"); !errors.Is(err, storeerr.ErrNotFound) {
+		t.Errorf("get missing vector: err = %v", err)
+	}
+	stats := s.TierStats()
+	if stats.Hits != 2 || stats.Misses != 4 || stats.MemoryVectors != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// Writes reach both tiers, deletes leave neither holding the vector
+	if err := s.Store(vector("d", 3)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cold.Get("d"); err != nil {
+		t.Errorf("stored vector missing from the local tier: %v", err)
+	}
+	results, err := s.Search(&models.SearchByEmbbedingRequest{Embedding: []float64{3, 1}, TopK: 1})
+	if err != nil || len(results) != 1 || results[0].Vector.ID != "d" {
+		t.Errorf("search = %v, %v", results, err)
+	}
+	if err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("a"); !errors.Is(err, storeerr.ErrNotFound) {
+		t.Errorf("deleted vector read back: err = %v", err)
+	}
+	if stats := s.GetStats()["tiers"].(Stats); stats.MemoryVectors != 2 {
+		t.Errorf("after store and delete, stats = %+v", stats)
+	}
+}
+
+func TestStorage_Preload(t *testing.T) {
+	dir := t.TempDir()
+	cold := openLocal(t, dir)
+	for i := 0; i < 5; i++ {
+		if err := cold.Store(vector(fmt.Sprintf("v%d", i), float64(i))); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// The most recently updated vectors are loaded, as many as asked for
+	s, err := New(cold, Options{Preload: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"v3", "v4"} {
+		if _, err := s.hot.Get(id); err != nil {
+			t.Errorf("recent vector %s not preloaded", id)
+		}
+	}
+	if count := s.hot.Count(); count != 2 {
+		t.Errorf("preloaded %d vectors, want 2", count)
+	}
+
+	// Preloading everything stops at the memory limits, which evict on later reads
+	limits := memlimit.Options{MaxVectors: 3, Policy: memlimit.PolicyEvictLRU}
+	s, err = New(cold, Options{Preload: PreloadAll, Memory: limits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := s.hot.Count(); count != 3 {
+		t.Errorf("preloaded %d vectors past the limit of 3", count)
+	}
+	if _, err := s.Get("v0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.hot.Get("v2"); err == nil || s.hot.Count() != 3 {
+		t.Errorf("least recently used vector kept, %d vectors in memory", s.hot.Count())
+	}
+	if count := s.Count(); count != 5 {
+		t.Errorf("local tier holds %d vectors, want 5", count)
+	}
+}
+
+func TestStorage_WriteBehind(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(openLocal(t, dir), Options{WriteBehind: true, QueueSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const stored = 20
+	for i := 0; i < stored; i++ {
+		if err := s.Store(vector(fmt.Sprintf("v%d", i), float64(i))); err != nil {
+			t.Fatal(err)
+		}
+		// Queued writes are read back before they reach the local tier
+		if _, err := s.Get(fmt.Sprintf("v%d", i)); err != nil {
+			t.Fatalf("queued vector not readable: %v", err)
+		}
+	}
+	if err := s.StoreAll([]*models.Vector{vector("x", 1), vector("y", 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store(vector("gone", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.TierStats(); stats.QueueDepth > stats.QueueSize || stats.QueueSize != 4 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// A write the local tier refuses is dropped from the memory tier too
+	if err := s.Store(&models.Vector{ID: "wide", Embedding: []float64{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("wide"); !errors.Is(err, storeerr.ErrNotFound) {
+		t.Errorf("refused write still read: err = %v", err)
+	}
+	if stats := s.TierStats(); stats.WriteFailures != 1 || stats.QueueDepth != 0 {
+		t.Errorf("after flush, stats = %+v", stats)
+	}
+
+	// Close writes what is queued, so reopening finds every write
+	if err := s.Store(vector("last", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store(vector("late", 1)); err == nil {
+		t.Error("store after close succeeded")
+	}
+
+	reopened := openLocal(t, dir)
+	if count := reopened.Count(); count != stored+3 {
+		t.Errorf("reopened local tier holds %d vectors, want %d", count, stored+3)
+	}
+	for _, id := range []string{"v0", "v19", "x", "y", "last"} {
+		if _, err := reopened.Get(id); err != nil {
+			t.Errorf("write of %s lost: %v", id, err)
+		}
+	}
+	if _, err := reopened.Get("gone"); !errors.Is(err, storeerr.ErrNotFound) {
+		t.Errorf("deleted vector written back: err = %v", err)
+	}
+}
+
+func TestStorage_WriteBehindReads(t *testing.T) {
+	s, err := New(openLocal(t, t.TempDir()), Options{WriteBehind: true, QueueSize: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	// Reads answered by the local tier wait for the writes queued before them
+	const stored = 200
+	for i := 0; i < stored; i++ {
+		v := vector(fmt.Sprintf("v%d", i), float64(i))
+		v.Metadata = map[string]string{models.NamespaceKey: "x", "color": "red"}
+		if i%2 == 0 {
+			v.Embedding = nil
+		}
+		if err := s.Store(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids, err := s.IDs("x"); err != nil || len(ids) != stored {
+		t.Errorf("ids = %d, %v", len(ids), err)
+	}
+	if facet, err := s.Facet("x", "color", 10); err != nil || facet.Count != stored {
+		t.Errorf("facet = %+v, %v", facet, err)
+	}
+	if schema, err := s.MetadataSchema("x"); err != nil || schema.Vectors != stored {
+		t.Errorf("schema = %+v, %v", schema, err)
+	}
+	if pending := s.CountPending("x"); pending != stored/2 {
+		t.Errorf("pending = %d, want %d", pending, stored/2)
+	}
+	if usage := s.QuotaUsage(); usage["x"].Vectors != stored {
+		t.Errorf("quota usage = %+v", usage)
+	}
+	if report, err := s.Verify(local.VerifyOptions{}); err != nil || report.Documents != stored || !report.OK() {
+		t.Errorf("verify = %+v, %v", report, err)
+	}
+}
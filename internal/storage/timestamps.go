@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// TimestampFixReport summarizes a FixTimestamps run
+type TimestampFixReport struct {
+	Field      string `json:"field"`
+	DryRun     bool   `json:"dry_run"`
+	Fixed      int    `json:"fixed"`       // CreatedAt set from the field
+	AlreadySet int    `json:"already_set"` // CreatedAt kept, without overwrite
+	Missing    int    `json:"missing"`     // Field not present
+	Unparsable int    `json:"unparsable"`  // Field not a recognized time
+}
+
+// Untouched returns the number of vectors left unchanged
+func (r *TimestampFixReport) Untouched() int {
+	return r.AlreadySet + r.Missing + r.Unparsable
+}
+
+// FixTimestamps backfills the CreatedAt of the vectors in s from a metadata field
+// Only vectors without a CreatedAt are changed unless overwrite is set,
+// so legacy vectors get a usable time for temporal search
+func FixTimestamps(s Storage, field string, overwrite, dryRun bool) (*TimestampFixReport, error) {
+	if field == "" {
+		return nil, fmt.Errorf("metadata field is required")
+	}
+
+	vectors, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TimestampFixReport{Field: field, DryRun: dryRun}
+	for _, vector := range vectors {
+		if !overwrite && !vector.CreatedAt.IsZero() {
+			report.AlreadySet++
+			continue
+		}
+
+		value, ok := vector.Metadata[field]
+		if !ok || value == "" {
+			report.Missing++
+			continue
+		}
+
+		createdAt, err := models.ParseTime(value)
+		if err != nil {
+			report.Unparsable++
+			continue
+		}
+
+		if !dryRun {
+			fixed := *vector
+			fixed.CreatedAt = createdAt
+			if err := s.Store(&fixed); err != nil {
+				return report, fmt.Errorf("failed to store vector %s: %w", vector.ID, err)
+			}
+		}
+		report.Fixed++
+	}
+
+	return report, nil
+}
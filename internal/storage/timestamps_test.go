@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+)
+
+func TestFixTimestamps(t *testing.T) {
+	adapter, err := local.NewVectorStorageAdapter(t.TempDir(), "vectors")
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	vectors := map[string]string{
+		"dated":    "2019-06-01",
+		"garbled":  "last summer",
+		"undated":  "",
+		"datetime": "2018-01-02T03:04:05Z",
+	}
+	for id, published := range vectors {
+		metadata := map[string]string{}
+		if published != "" {
+			metadata["published_at"] = published
+		}
+		if err := adapter.Store(&models.Vector{ID: id, Embedding: []float64{1, 0}, Metadata: metadata}); err != nil {
+			t.Fatalf("store %s: %v", id, err)
+		}
+	}
+
+	// Stored vectors already have a creation time
+	report, err := FixTimestamps(adapter, "published_at", false, false)
+	if err != nil {
+		t.Fatalf("fix failed: %v", err)
+	}
+	if report.Fixed != 0 || report.AlreadySet != 4 {
+		t.Errorf("report without overwrite = %+v", report)
+	}
+
+	report, err = FixTimestamps(adapter, "published_at", true, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if report.Fixed != 2 || report.Missing != 1 || report.Unparsable != 1 {
+		t.Errorf("dry run report = %+v", report)
+	}
+	if vector, _ := adapter.Get("dated"); vector.CreatedAt.Year() == 2019 {
+		t.Error("dry run changed the creation time")
+	}
+
+	if _, err := FixTimestamps(adapter, "published_at", true, false); err != nil {
+		t.Fatalf("fix failed: %v", err)
+	}
+	vector, err := adapter.Get("dated")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if !vector.CreatedAt.Equal(time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("created_at = %s, want 2019-06-01", vector.CreatedAt)
+	}
+	if len(vector.Embedding) != 2 {
+		t.Errorf("embedding lost while fixing: %v", vector.Embedding)
+	}
+}
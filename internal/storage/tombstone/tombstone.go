@@ -0,0 +1,115 @@
+// Package tombstone keeps a bounded log of deleted vectors shared by the storage
+// backends, so clients mirroring a storage through delta listings see deletions
+package tombstone
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// Defaults of Options fields left at zero
+const (
+	DefaultRetention  = 7 * 24 * time.Hour
+	DefaultMaxEntries = 100000
+)
+
+// Tombstone records the deletion of a vector
+type Tombstone struct {
+	ID        string    `json:"id"`
+	Namespace string    `json:"namespace,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Options bounds the log: tombstones are dropped once older than Retention
+// or when more than MaxEntries are kept, oldest first
+type Options struct {
+	Retention  time.Duration
+	MaxEntries int
+}
+
+// withDefaults fills the fields left at zero
+func (o Options) withDefaults() Options {
+	if o.Retention <= 0 {
+		o.Retention = DefaultRetention
+	}
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = DefaultMaxEntries
+	}
+	return o
+}
+
+// Log holds tombstones oldest first
+// The zero value is an empty log ready to use, it is not safe for concurrent use
+type Log struct {
+	Entries []Tombstone `json:"entries,omitempty"`
+
+	// Horizon is the deletion time of the newest dropped tombstone. Deletions up to
+	// then may be missing, so clients that last synced before it must resync fully
+	Horizon time.Time `json:"horizon,omitempty"`
+}
+
+// Of returns the tombstone of vector deleted at deletedAt
+func Of(vector *models.Vector, deletedAt time.Time) Tombstone {
+	return Tombstone{ID: vector.ID, Namespace: vector.Metadata[models.NamespaceKey], DeletedAt: deletedAt}
+}
+
+// Add records a deletion and compacts the log
+func (l *Log) Add(t Tombstone, opts Options) {
+	l.Entries = append(l.Entries, t)
+	l.Compact(t.DeletedAt, opts)
+}
+
+// Compact drops the tombstones older than the retention at now and the oldest
+// ones beyond the maximum number of entries, advancing the horizon
+func (l *Log) Compact(now time.Time, opts Options) {
+	opts = opts.withDefaults()
+	cutoff := now.Add(-opts.Retention)
+
+	drop := sort.Search(len(l.Entries), func(i int) bool {
+		return l.Entries[i].DeletedAt.After(cutoff)
+	})
+	if excess := len(l.Entries) - opts.MaxEntries; excess > drop {
+		drop = excess
+	}
+	if drop == 0 {
+		return
+	}
+
+	l.Horizon = l.Entries[drop-1].DeletedAt
+	l.Entries = append([]Tombstone(nil), l.Entries[drop:]...)
+}
+
+// Since returns the tombstones of namespace deleted at or after since, oldest first
+// An empty namespace matches every tombstone
+func (l *Log) Since(since time.Time, namespace string) []Tombstone {
+	start := sort.Search(len(l.Entries), func(i int) bool {
+		return !l.Entries[i].DeletedAt.Before(since)
+	})
+
+	tombstones := make([]Tombstone, 0, len(l.Entries)-start)
+	for _, t := range l.Entries[start:] {
+		if namespace == "" || t.Namespace == namespace {
+			tombstones = append(tombstones, t)
+		}
+	}
+	return tombstones
+}
+
+// Changes are the vectors updated and deleted since a time
+type Changes struct {
+	Vectors []*models.Vector // Oldest update first
+	Deleted []Tombstone      // Oldest deletion first
+	Horizon time.Time        // Horizon of the tombstone log
+}
+
+// SortByUpdate orders vectors by update time, then ID
+func SortByUpdate(vectors []*models.Vector) {
+	sort.Slice(vectors, func(i, j int) bool {
+		if !vectors[i].UpdatedAt.Equal(vectors[j].UpdatedAt) {
+			return vectors[i].UpdatedAt.Before(vectors[j].UpdatedAt)
+		}
+		return vectors[i].ID < vectors[j].ID
+	})
+}
@@ -0,0 +1,31 @@
+package tombstone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLog_Compact(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	opts := Options{Retention: time.Hour, MaxEntries: 3}
+
+	var log Log
+	for i, id := range []string{"a", "b", "c", "d"} {
+		log.Add(Tombstone{ID: id, Namespace: "ns", DeletedAt: start.Add(time.Duration(i) * time.Minute)}, opts)
+	}
+	if len(log.Entries) != 3 || log.Entries[0].ID != "b" || !log.Horizon.Equal(start) {
+		t.Fatalf("after exceeding max entries: entries = %v, horizon = %v", log.Entries, log.Horizon)
+	}
+
+	log.Add(Tombstone{ID: "e", DeletedAt: start.Add(62 * time.Minute)}, opts)
+	if len(log.Entries) != 2 || log.Entries[0].ID != "d" || !log.Horizon.Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("after retention: entries = %v, horizon = %v", log.Entries, log.Horizon)
+	}
+
+	if got := log.Since(start.Add(3*time.Minute), "ns"); len(got) != 1 || got[0].ID != "d" {
+		t.Errorf("since in namespace = %v, want d", got)
+	}
+	if got := log.Since(start.Add(4*time.Minute), ""); len(got) != 1 || got[0].ID != "e" {
+		t.Errorf("since = %v, want e", got)
+	}
+}
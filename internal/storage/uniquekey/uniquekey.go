@@ -0,0 +1,229 @@
+// Package uniquekey enforces unique metadata fields, natural keys such as a
+// ticket ID, for the storage backends. Uniqueness is scoped to a namespace
+package uniquekey
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// ErrDuplicate is matched by every unique key conflict with errors.Is
+var ErrDuplicate = errors.New("duplicate unique key")
+
+// ErrNotUnique is returned when looking up a field that is not declared unique
+var ErrNotUnique = errors.New("field is not a unique key")
+
+// Error reports a write whose unique field value is already held by another vector
+type Error struct {
+	Namespace  string `json:"namespace"`
+	Field      string `json:"field"`
+	Value      string `json:"value"`
+	ID         string `json:"id"`
+	ExistingID string `json:"existing_id"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %q in namespace %q is already used by vector %s", e.Field, e.Value, e.Namespace, e.ExistingID)
+}
+
+// Is makes every conflict match ErrDuplicate and storeerr.ErrAlreadyExists
+func (e *Error) Is(target error) bool {
+	return target == ErrDuplicate || target == storeerr.ErrAlreadyExists
+}
+
+// ValidateFields rejects empty and repeated field names
+func ValidateFields(fields []string) error {
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("unique key field cannot be empty")
+		}
+		if seen[field] {
+			return fmt.Errorf("unique key field %q is repeated", field)
+		}
+		seen[field] = true
+	}
+	return nil
+}
+
+type entry struct {
+	namespace, field, value string
+}
+
+// Index maps the unique field values of each namespace to the ID of the vector holding them
+type Index struct {
+	fields []string
+	ids    map[entry]string
+}
+
+// Keyed is the part of a stored vector the index reads
+type Keyed struct {
+	ID       string
+	Metadata map[string]string
+}
+
+// Lookup returns the metadata of the stored vector with an ID, and false when there is none
+type Lookup func(id string) (metadata map[string]string, ok bool)
+
+// NewIndex returns an empty index of fields, nil when no field is unique
+func NewIndex(fields []string) *Index {
+	if len(fields) == 0 {
+		return nil
+	}
+	sorted := append([]string(nil), fields...)
+	sort.Strings(sorted)
+	return &Index{fields: sorted, ids: make(map[entry]string)}
+}
+
+// Build returns an index of fields over the stored vectors, failing if two of them share a value
+func Build(fields []string, vectors []Keyed) (*Index, error) {
+	ix := NewIndex(fields)
+	if ix == nil {
+		return nil, nil
+	}
+	sort.Slice(vectors, func(i, j int) bool { return vectors[i].ID < vectors[j].ID })
+	for _, vector := range vectors {
+		for _, e := range ix.entries(vector.Metadata) {
+			if owner, taken := ix.ids[e]; taken && owner != vector.ID {
+				return nil, &Error{Namespace: e.namespace, Field: e.field, Value: e.value, ID: vector.ID, ExistingID: owner}
+			}
+			ix.ids[e] = vector.ID
+		}
+	}
+	return ix, nil
+}
+
+// Fields returns the unique fields, sorted
+func (ix *Index) Fields() []string {
+	if ix == nil {
+		return []string{}
+	}
+	return append([]string(nil), ix.fields...)
+}
+
+// Has reports whether field is unique
+func (ix *Index) Has(field string) bool {
+	if ix == nil {
+		return false
+	}
+	for _, f := range ix.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the ID of the vector holding value for field in namespace
+func (ix *Index) Lookup(namespace, field, value string) (string, error) {
+	if !ix.Has(field) {
+		return "", fmt.Errorf("%w: %s", ErrNotUnique, field)
+	}
+	id, ok := ix.ids[entry{namespace, field, value}]
+	if !ok {
+		return "", storeerr.NotFoundf("no vector with %s %q in namespace %q", field, value, namespace)
+	}
+	return id, nil
+}
+
+// entries returns the index entries of a vector's metadata
+func (ix *Index) entries(metadata map[string]string) []entry {
+	namespace := metadata[models.NamespaceKey]
+	var entries []entry
+	for _, field := range ix.fields {
+		if value, ok := metadata[field]; ok && value != "" {
+			entries = append(entries, entry{namespace, field, value})
+		}
+	}
+	return entries
+}
+
+// Check returns an *Error if storing vectors, in order, would give a unique
+// value to two vectors. Values released by vectors being replaced are free
+func (ix *Index) Check(vectors []Keyed, previous Lookup) error {
+	if ix == nil {
+		return nil
+	}
+
+	// staged holds the owners changed by earlier vectors, "" for released values
+	staged := make(map[entry]string)
+	current := make(map[string]map[string]string, len(vectors))
+	owner := func(e entry) (string, bool) {
+		if id, ok := staged[e]; ok {
+			return id, id != ""
+		}
+		id, ok := ix.ids[e]
+		return id, ok
+	}
+
+	for _, vector := range vectors {
+		old, replaced := current[vector.ID]
+		if !replaced {
+			old, replaced = previous(vector.ID)
+		}
+		if replaced {
+			for _, e := range ix.entries(old) {
+				if id, ok := owner(e); ok && id == vector.ID {
+					staged[e] = ""
+				}
+			}
+		}
+
+		for _, e := range ix.entries(vector.Metadata) {
+			if id, ok := owner(e); ok && id != vector.ID {
+				return &Error{Namespace: e.namespace, Field: e.field, Value: e.value, ID: vector.ID, ExistingID: id}
+			}
+			staged[e] = vector.ID
+		}
+		current[vector.ID] = vector.Metadata
+	}
+	return nil
+}
+
+// Apply updates the index for vectors that passed Check and are about to replace the versions found by previous
+func (ix *Index) Apply(vectors []Keyed, previous Lookup) {
+	if ix == nil {
+		return
+	}
+
+	current := make(map[string]map[string]string, len(vectors))
+	for _, vector := range vectors {
+		old, replaced := current[vector.ID]
+		if !replaced {
+			old, replaced = previous(vector.ID)
+		}
+		if replaced {
+			ix.Remove(vector.ID, old)
+		}
+		for _, e := range ix.entries(vector.Metadata) {
+			ix.ids[e] = vector.ID
+		}
+		current[vector.ID] = vector.Metadata
+	}
+}
+
+// Remove drops the values held by a deleted or replaced vector
+func (ix *Index) Remove(id string, metadata map[string]string) {
+	if ix == nil {
+		return
+	}
+	for _, e := range ix.entries(metadata) {
+		if ix.ids[e] == id {
+			delete(ix.ids, e)
+		}
+	}
+}
+
+// Of returns the keyed form of vectors
+func Of(vectors ...*models.Vector) []Keyed {
+	keyed := make([]Keyed, len(vectors))
+	for i, vector := range vectors {
+		keyed[i] = Keyed{ID: vector.ID, Metadata: vector.Metadata}
+	}
+	return keyed
+}
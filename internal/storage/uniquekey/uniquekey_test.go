@@ -0,0 +1,88 @@
+package uniquekey
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func keyed(id, ticket string, namespace string) Keyed {
+	metadata := map[string]string{"ticket_id": ticket}
+	if namespace != "" {
+		metadata[models.NamespaceKey] = namespace
+	}
+	return Keyed{ID: id, Metadata: metadata}
+}
+
+func TestIndex_Check(t *testing.T) {
+	stored := []Keyed{keyed("a", "T-1", ""), keyed("b", "T-2", "")}
+	index, err := Build([]string{"ticket_id"}, stored)
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	previous := func(id string) (map[string]string, bool) {
+		for _, vector := range stored {
+			if vector.ID == id {
+				return vector.Metadata, true
+			}
+		}
+		return nil, false
+	}
+
+	tests := []struct {
+		name    string
+		vectors []Keyed
+		wantErr bool
+	}{
+		{"new value", []Keyed{keyed("c", "T-3", "")}, false},
+		{"same vector keeps its value", []Keyed{keyed("a", "T-1", "")}, false},
+		{"taken value", []Keyed{keyed("c", "T-1", "")}, true},
+		{"other namespace", []Keyed{keyed("c", "T-1", "team")}, false},
+		{"value released in the same batch", []Keyed{keyed("a", "T-9", ""), keyed("c", "T-1", "")}, false},
+		{"value shared within a batch", []Keyed{keyed("c", "T-3", ""), keyed("d", "T-3", "")}, true},
+		{"no value", []Keyed{{ID: "c", Metadata: map[string]string{}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := index.Check(tt.vectors, previous)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrDuplicate) {
+				t.Errorf("error %v does not match ErrDuplicate", err)
+			}
+		})
+	}
+}
+
+func TestIndex_ApplyAndRemove(t *testing.T) {
+	index := NewIndex([]string{"ticket_id"})
+	none := func(string) (map[string]string, bool) { return nil, false }
+
+	index.Apply([]Keyed{keyed("a", "T-1", "")}, none)
+	if id, err := index.Lookup("", "ticket_id", "T-1"); err != nil || id != "a" {
+		t.Fatalf("lookup = %q, %v", id, err)
+	}
+
+	// Replacing a with a new value frees the old one
+	index.Apply([]Keyed{keyed("a", "T-2", "")}, func(string) (map[string]string, bool) {
+		return keyed("a", "T-1", "").Metadata, true
+	})
+	if _, err := index.Lookup("", "ticket_id", "T-1"); err == nil {
+		t.Error("expected the replaced value to be released")
+	}
+
+	index.Remove("a", keyed("a", "T-2", "").Metadata)
+	if _, err := index.Lookup("", "ticket_id", "T-2"); err == nil {
+		t.Error("expected the removed value to be released")
+	}
+
+	if _, err := index.Lookup("", "title", "x"); !errors.Is(err, ErrNotUnique) {
+		t.Errorf("expected ErrNotUnique, got %v", err)
+	}
+	if _, err := Build([]string{"ticket_id"}, []Keyed{keyed("a", "T-1", ""), keyed("b", "T-1", "")}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected a duplicate error, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package watch
+
+import (
+	"math"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+// compiled is an active watch prepared for scoring
+type compiled struct {
+	watch   *Watch
+	norm    float64
+	filters *models.CompiledFilters
+}
+
+// Evaluator scores stored vectors against the watches active when it was built,
+// until they expire
+// Watches are grouped by dimension, so a vector is only compared with the
+// watches of its own dimension and the norm of each side is computed once
+type Evaluator struct {
+	byDimension map[int][]compiled
+	evaluator   *models.FilterEvaluator
+	watches     int
+}
+
+// Result is the outcome of evaluating a batch of vectors
+type Result struct {
+	Matches map[string][]Match // By watch ID
+
+	Vectors     int // Vectors of the batch
+	Comparisons int // Vector and watch pairs scored
+	Skipped     int // Vectors with no active watch of their dimension
+	Duration    time.Duration
+}
+
+// NewEvaluator prepares the watches active at now
+// Watches with an invalid filter or a zero query embedding never match and are left out
+func NewEvaluator(watches []*Watch, keyFallback bool, now time.Time) *Evaluator {
+	e := &Evaluator{
+		byDimension: make(map[int][]compiled),
+		evaluator:   &models.FilterEvaluator{KeyFallback: keyFallback},
+	}
+	for _, w := range watches {
+		if w.State(now) != StateActive {
+			continue
+		}
+		norm := vectorNorm(w.Embedding)
+		if norm == 0 {
+			continue
+		}
+		filters, err := e.evaluator.Compile(w.Request.Filters)
+		if err != nil {
+			continue
+		}
+		dimension := len(w.Embedding)
+		e.byDimension[dimension] = append(e.byDimension[dimension], compiled{watch: w, norm: norm, filters: filters})
+		e.watches++
+	}
+	return e
+}
+
+// Active returns the number of watches the evaluator scores vectors against
+func (e *Evaluator) Active() int {
+	return e.watches
+}
+
+// Evaluate scores vectors against the watches of their dimension, returning the
+// matches at or above the threshold of each watch. Pending and sparse-only
+// vectors, and vectors of another dimension than every watch, are skipped
+func (e *Evaluator) Evaluate(vectors []*models.Vector, now time.Time) *Result {
+	start := time.Now()
+	result := &Result{Matches: make(map[string][]Match), Vectors: len(vectors)}
+
+	for _, vector := range vectors {
+		candidates := e.byDimension[len(vector.Embedding)]
+		norm := vectorNorm(vector.Embedding)
+		if len(candidates) == 0 || norm == 0 {
+			result.Skipped++
+			continue
+		}
+
+		for i := range candidates {
+			c := &candidates[i]
+			if c.watch.State(now) != StateActive {
+				continue // Expired since the evaluator was built
+			}
+			if !search.MatchesNamespace(vector.Metadata, c.watch.Request.Namespace) || !e.evaluator.Matches(vector.Metadata, c.filters) {
+				continue
+			}
+			c.watch.Stats.Evaluated++
+			result.Comparisons++
+
+			score := dot(c.watch.Embedding, vector.Embedding) / (c.norm * norm)
+			if score < c.watch.Request.Threshold {
+				continue
+			}
+			c.watch.Stats.Matched++
+			c.watch.Stats.LastMatchAt = &now
+			metadata := make(map[string]string, len(vector.Metadata))
+			for k, v := range vector.Metadata {
+				metadata[k] = v
+			}
+			result.Matches[c.watch.ID] = append(result.Matches[c.watch.ID], Match{
+				VectorID:  vector.ID,
+				Score:     score,
+				Metadata:  metadata,
+				MatchedAt: now,
+			})
+		}
+	}
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func vectorNorm(v []float64) float64 {
+	return math.Sqrt(dot(v, v))
+}
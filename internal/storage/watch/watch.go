@@ -0,0 +1,247 @@
+// Package watch defines standing queries: a query embedding and a similarity
+// threshold that newly stored vectors are scored against, the matches being
+// delivered to a webhook or kept in an inbox
+package watch
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage/storeerr"
+)
+
+// Delivery targets
+const (
+	DeliveryWebhook = "webhook" // Matches are POSTed to the webhook URL
+	DeliveryInbox   = "inbox"   // Matches are kept for GET /api/v1/watches/{id}/matches
+)
+
+// Watch states
+const (
+	StateActive   = "active"
+	StateDisabled = "disabled"
+	StateExpired  = "expired"
+)
+
+const (
+	// MaxInbox is the number of matches kept in the inbox of a watch, older ones are dropped
+	MaxInbox = 100
+
+	// DefaultRetries is the number of times a failed webhook delivery is retried
+	DefaultRetries = 3
+	MaxRetries     = 10
+)
+
+// ErrNotFound is matched with errors.Is by the errors of unknown watches
+var ErrNotFound = fmt.Errorf("watch %w", storeerr.ErrNotFound)
+
+// NotFound returns the error of an unknown watch
+func NotFound(id string) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// Request registers a watch
+type Request struct {
+	// Query is embedded by the embedder of Namespace, unless Embedding is given
+	Query     string    `json:"query,omitempty"`
+	Embedding []float64 `json:"embedding,omitempty"`
+
+	// Threshold is the cosine similarity at or above which a vector matches
+	Threshold float64 `json:"threshold"`
+
+	// Namespace and Filters restrict the vectors the watch is evaluated against
+	Namespace string         `json:"namespace,omitempty"`
+	Filters   models.Filters `json:"filters,omitempty"`
+
+	// Webhook is the URL matches are POSTed to, they are kept in the inbox of
+	// the watch when it is empty
+	Webhook string `json:"webhook,omitempty"`
+	Retries *int   `json:"retries,omitempty"` // Webhook retries, DefaultRetries when unset
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Validate checks the request and fills the retries default
+func (r *Request) Validate() error {
+	if r.Query == "" && len(r.Embedding) == 0 {
+		return fmt.Errorf("a query or an embedding is required")
+	}
+	for _, value := range r.Embedding {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("embedding cannot contain NaN or infinite values")
+		}
+	}
+	if r.Threshold < -1 || r.Threshold > 1 {
+		return fmt.Errorf("threshold must be between -1 and 1")
+	}
+	if _, err := models.NewFilterEvaluator().Compile(r.Filters); err != nil {
+		return err
+	}
+
+	if r.Webhook != "" {
+		u, err := url.Parse(r.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook must be an http or https URL")
+		}
+	}
+	if r.Retries == nil {
+		retries := DefaultRetries
+		r.Retries = &retries
+	}
+	if *r.Retries < 0 || *r.Retries > MaxRetries {
+		return fmt.Errorf("retries must be between 0 and %d", MaxRetries)
+	}
+	return nil
+}
+
+// Update changes a watch, its unset fields are left unchanged
+type Update struct {
+	Enabled   *bool      `json:"enabled,omitempty"`
+	Threshold *float64   `json:"threshold,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Stats count the evaluations and deliveries of a watch
+type Stats struct {
+	Evaluated   int        `json:"evaluated"` // Vectors scored against the watch
+	Matched     int        `json:"matched"`
+	Delivered   int        `json:"delivered"` // Matches delivered to the webhook or inbox
+	Failed      int        `json:"failed"`    // Matches whose webhook delivery failed
+	LastError   string     `json:"last_error,omitempty"`
+	LastMatchAt *time.Time `json:"last_match_at,omitempty"`
+}
+
+// Match is a stored vector scoring at or above the threshold of a watch
+type Match struct {
+	VectorID  string            `json:"vector_id"`
+	Score     float64           `json:"score"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	MatchedAt time.Time         `json:"matched_at"`
+}
+
+// Watch is a registered standing query
+type Watch struct {
+	ID      string  `json:"id"`
+	Request Request `json:"request"`
+
+	// Embedding is the query embedding, Request.Embedding or the embedded Query
+	Embedding []float64 `json:"embedding"`
+	Delivery  string    `json:"delivery"`
+	Enabled   bool      `json:"enabled"`
+
+	Stats Stats   `json:"stats"`
+	Inbox []Match `json:"inbox,omitempty"` // Newest last, at most MaxInbox
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// New returns an enabled watch of req with its query embedding
+func New(id string, req Request, embedding []float64, now time.Time) *Watch {
+	delivery := DeliveryInbox
+	if req.Webhook != "" {
+		delivery = DeliveryWebhook
+	}
+	return &Watch{
+		ID:        id,
+		Request:   req,
+		Embedding: embedding,
+		Delivery:  delivery,
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// State returns whether the watch is active, disabled or expired at now
+func (w *Watch) State(now time.Time) string {
+	switch {
+	case !w.Enabled:
+		return StateDisabled
+	case w.Request.ExpiresAt != nil && !now.Before(*w.Request.ExpiresAt):
+		return StateExpired
+	default:
+		return StateActive
+	}
+}
+
+// Apply changes the watch by u
+func (w *Watch) Apply(u Update, now time.Time) error {
+	if u.Threshold != nil {
+		if *u.Threshold < -1 || *u.Threshold > 1 {
+			return fmt.Errorf("threshold must be between -1 and 1")
+		}
+		w.Request.Threshold = *u.Threshold
+	}
+	if u.Enabled != nil {
+		w.Enabled = *u.Enabled
+	}
+	if u.ExpiresAt != nil {
+		expires := *u.ExpiresAt
+		w.Request.ExpiresAt = &expires
+	}
+	w.UpdatedAt = now
+	return nil
+}
+
+// Record counts matches delivered to the watch, or failed to be when err is set,
+// keeping the matches of an inbox watch
+func (w *Watch) Record(matches []Match, err error) {
+	if err != nil {
+		w.Stats.Failed += len(matches)
+		w.Stats.LastError = err.Error()
+		return
+	}
+	w.Stats.Delivered += len(matches)
+	if w.Delivery != DeliveryInbox {
+		return
+	}
+	w.Inbox = append(w.Inbox, matches...)
+	if len(w.Inbox) > MaxInbox {
+		w.Inbox = append([]Match(nil), w.Inbox[len(w.Inbox)-MaxInbox:]...)
+	}
+}
+
+// Copy returns a copy of the watch that shares no slice with it, without its
+// inbox unless inbox is set
+func (w *Watch) Copy(inbox bool) *Watch {
+	copied := *w
+	copied.Embedding = append([]float64(nil), w.Embedding...)
+	copied.Inbox = nil
+	if inbox {
+		copied.Inbox = append([]Match(nil), w.Inbox...)
+	}
+	return &copied
+}
+
+// Sorted returns watches oldest first
+func Sorted(watches []*Watch) []*Watch {
+	sorted := append([]*Watch(nil), watches...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.Before(sorted[j].CreatedAt) })
+	return sorted
+}
+
+// Put replaces the watch of the same ID in watches or appends it
+func Put(watches []*Watch, w *Watch) []*Watch {
+	for i, existing := range watches {
+		if existing.ID == w.ID {
+			watches[i] = w
+			return watches
+		}
+	}
+	return append(watches, w)
+}
+
+// Remove returns watches without the watch of id, and whether it was found
+func Remove(watches []*Watch, id string) ([]*Watch, bool) {
+	for i, w := range watches {
+		if w.ID == id {
+			return append(watches[:i], watches[i+1:]...), true
+		}
+	}
+	return watches, false
+}
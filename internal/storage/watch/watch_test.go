@@ -0,0 +1,120 @@
+package watch
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+func TestRequest_Validate(t *testing.T) {
+	tooMany := MaxRetries + 1
+	tests := []struct {
+		name    string
+		req     Request
+		wantErr bool
+	}{
+		{name: "query", req: Request{Query: "disk full on db-1", Threshold: 0.8}},
+		{name: "embedding and webhook", req: Request{Embedding: []float64{1, 0}, Threshold: 0.5, Webhook: "https://hooks.example.com/x"}},
+		{name: "no query", req: Request{Threshold: 0.5}, wantErr: true},
+		{name: "threshold too high", req: Request{Query: "q", Threshold: 1.5}, wantErr: true},
+		{name: "webhook not http", req: Request{Query: "q", Webhook: "ftp://example.com"}, wantErr: true},
+		{name: "webhook without host", req: Request{Query: "q", Webhook: "http://"}, wantErr: true},
+		{name: "too many retries", req: Request{Query: "q", Retries: &tooMany}, wantErr: true},
+		{name: "invalid filter", req: Request{Query: "q", Filters: models.Filters{"ra*": {models.MatchModifier: "most"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *tt.req.Retries != DefaultRetries {
+				t.Errorf("retries = %d, want the default", *tt.req.Retries)
+			}
+		})
+	}
+}
+
+func TestEvaluator_Threshold(t *testing.T) {
+	now := time.Now()
+	// [3, 4] scores exactly 0.6 against [1, 0]
+	vectors := []*models.Vector{
+		{ID: "at", Embedding: []float64{3, 4}},
+		{ID: "above", Embedding: []float64{4, 3}},
+		{ID: "other-dimension", Embedding: []float64{1, 0, 0}},
+		{ID: "pending"},
+	}
+
+	for _, tt := range []struct {
+		threshold float64
+		want      []string
+	}{
+		{0.6, []string{"at", "above"}},
+		{0.60001, []string{"above"}},
+		{0.8, []string{"above"}},
+		{0.80001, nil},
+	} {
+		w := New("w", Request{Threshold: tt.threshold}, []float64{1, 0}, now)
+		result := NewEvaluator([]*Watch{w}, false, now).Evaluate(vectors, now)
+
+		var got []string
+		for _, match := range result.Matches["w"] {
+			got = append(got, match.VectorID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("threshold %v matched %v, want %v", tt.threshold, got, tt.want)
+		}
+		if result.Comparisons != 2 || result.Skipped != 2 || w.Stats.Evaluated != 2 || w.Stats.Matched != len(tt.want) {
+			t.Errorf("threshold %v: result %+v, stats %+v", tt.threshold, result, w.Stats)
+		}
+	}
+}
+
+func TestEvaluator_ActiveWatches(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	vectors := []*models.Vector{
+		{ID: "doc", Embedding: []float64{1, 0}, Metadata: map[string]string{models.NamespaceKey: "incidents", "severity": "high"}},
+	}
+
+	disabled := New("disabled", Request{}, []float64{1, 0}, now)
+	disabled.Enabled = false
+	watches := []*Watch{
+		New("all", Request{}, []float64{1, 0}, now),
+		New("namespace", Request{Namespace: "incidents"}, []float64{1, 0}, now),
+		New("other-namespace", Request{Namespace: "docs"}, []float64{1, 0}, now),
+		New("filtered-out", Request{Filters: models.Filters{"severity": {"eq": "low"}}}, []float64{1, 0}, now),
+		New("expired", Request{ExpiresAt: &past}, []float64{1, 0}, now),
+		disabled,
+	}
+
+	e := NewEvaluator(watches, false, now)
+	if e.Active() != 4 {
+		t.Errorf("Active() = %d, want 4", e.Active())
+	}
+	result := e.Evaluate(vectors, now)
+	if len(result.Matches) != 2 || result.Matches["all"] == nil || result.Matches["namespace"] == nil {
+		t.Errorf("matches = %v, want all and namespace", result.Matches)
+	}
+	if watches[4].State(now) != StateExpired || disabled.State(now) != StateDisabled {
+		t.Errorf("states = %s, %s", watches[4].State(now), disabled.State(now))
+	}
+}
+
+func TestWatch_RecordInbox(t *testing.T) {
+	now := time.Now()
+	w := New("w", Request{}, []float64{1}, now)
+	for i := 0; i < MaxInbox+5; i++ {
+		w.Record([]Match{{VectorID: fmt.Sprintf("v%d", i)}}, nil)
+	}
+	if len(w.Inbox) != MaxInbox || w.Inbox[0].VectorID != "v5" || w.Stats.Delivered != MaxInbox+5 {
+		t.Errorf("inbox holds %d matches from %s, %d delivered", len(w.Inbox), w.Inbox[0].VectorID, w.Stats.Delivered)
+	}
+
+	w.Record([]Match{{VectorID: "lost"}}, fmt.Errorf("unreachable"))
+	if w.Stats.Failed != 1 || w.Stats.LastError != "unreachable" || len(w.Inbox) != MaxInbox {
+		t.Errorf("stats after failure = %+v", w.Stats)
+	}
+}
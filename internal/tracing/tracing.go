@@ -0,0 +1,129 @@
+// Package tracing emits OpenTelemetry spans for searches and ingestion
+//
+// Tracing is off unless OTEL_EXPORTER_OTLP_ENDPOINT is set when Init is called;
+// Start then returns the context unchanged with a no-op span, without allocating
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/tahcohcat/same-same/internal/version"
+)
+
+// EndpointEnv enables tracing, the other OTEL_EXPORTER_OTLP_* variables configure the exporter
+const EndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+// instrumentationName names the tracer of every span
+const instrumentationName = "github.com/tahcohcat/same-same"
+
+// Attribute keys
+const (
+	EmbedderProvider = attribute.Key("embedder.provider")
+	EmbedderModel    = attribute.Key("embedder.model")
+	Namespace        = attribute.Key("same_same.namespace")
+	TopK             = attribute.Key("same_same.top_k")
+	Results          = attribute.Key("same_same.results")
+	Source           = attribute.Key("same_same.ingest.source")
+	BatchSize        = attribute.Key("same_same.batch.size")
+	BatchStored      = attribute.Key("same_same.batch.stored")
+	BatchFailed      = attribute.Key("same_same.batch.failed")
+)
+
+// tracer is nil while tracing is off
+var tracer atomic.Pointer[trace.Tracer]
+
+// Init installs an OTLP/HTTP tracer provider when OTEL_EXPORTER_OTLP_ENDPOINT is set
+// The returned function flushes and stops it, it does nothing when tracing is off
+func Init(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	if os.Getenv(EndpointEnv) == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.version", version.Get().Version),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the traced service: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	SetProvider(provider)
+	return func(ctx context.Context) error {
+		SetProvider(nil)
+		return provider.Shutdown(ctx)
+	}, nil
+}
+
+// SetProvider traces with provider, or turns tracing off when it is nil
+// Init calls it, tests can pass a provider recording spans in memory
+func SetProvider(provider trace.TracerProvider) {
+	if provider == nil {
+		tracer.Store(nil)
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		return
+	}
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	t := provider.Tracer(instrumentationName)
+	tracer.Store(&t)
+}
+
+// Enabled reports whether spans are recorded
+func Enabled() bool {
+	return tracer.Load() != nil
+}
+
+// Start starts a span as a child of the span of ctx
+// Attributes are best set after checking span.IsRecording, so that they are not
+// built while tracing is off
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	t := tracer.Load()
+	if t == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return (*t).Start(ctx, name)
+}
+
+// StartEmbed starts the span of a call to embedder, attributed with its name
+// and, for embedders backed by a named model, the model
+func StartEmbed(ctx context.Context, name string, embedder interface{ Name() string }) (context.Context, trace.Span) {
+	ctx, span := Start(ctx, name)
+	if span.IsRecording() {
+		span.SetAttributes(EmbedderProvider.String(embedder.Name()))
+		if modeled, ok := embedder.(interface{ Model() string }); ok && modeled.Model() != "" {
+			span.SetAttributes(EmbedderModel.String(modeled.Model()))
+		}
+	}
+	return ctx, span
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil && span.IsRecording() {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
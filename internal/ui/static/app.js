@@ -0,0 +1,179 @@
+"use strict";
+
+// The admin API key is kept in localStorage and sent with every request,
+// so admin-only endpoints work once it has been entered
+const keyStorage = "same-same.apiKey";
+const keyInput = document.getElementById("api-key");
+keyInput.value = localStorage.getItem(keyStorage) || "";
+keyInput.addEventListener("change", () => {
+  localStorage.setItem(keyStorage, keyInput.value);
+  loadStats();
+});
+
+async function api(path, options = {}) {
+  const headers = Object.assign({ "Accept": "application/json" }, options.headers);
+  if (keyInput.value) {
+    headers["X-API-Key"] = keyInput.value;
+  }
+  if (options.body !== undefined) {
+    headers["Content-Type"] = "application/json";
+    options.body = JSON.stringify(options.body);
+  }
+  const response = await fetch(path, Object.assign({}, options, { headers }));
+  if (!response.ok) {
+    const message = (await response.text()).trim();
+    throw new Error(`${response.status} ${message || response.statusText}`);
+  }
+  return response.json();
+}
+
+function element(tag, className, text) {
+  const el = document.createElement(tag);
+  if (className) el.className = className;
+  if (text !== undefined) el.textContent = text;
+  return el;
+}
+
+function setStatus(message, isError) {
+  const status = document.getElementById("status");
+  status.textContent = message;
+  status.classList.toggle("error", Boolean(isError));
+}
+
+// age describes how long ago a timestamp was, such as "3 days ago"
+function age(timestamp) {
+  const created = Date.parse(timestamp);
+  if (!created || created <= 0) return "";
+  const seconds = Math.max(0, (Date.now() - created) / 1000);
+  const units = [["year", 31536000], ["month", 2592000], ["day", 86400], ["hour", 3600], ["minute", 60]];
+  for (const [unit, size] of units) {
+    const n = Math.floor(seconds / size);
+    if (n >= 1) return `${n} ${unit}${n > 1 ? "s" : ""} ago`;
+  }
+  return "just now";
+}
+
+async function loadStats() {
+  const stats = document.getElementById("stats");
+  const namespace = document.getElementById("namespace").value;
+  const widgets = [];
+  try {
+    const count = await api("/api/v1/vectors/count" + (namespace ? "?namespace=" + encodeURIComponent(namespace) : ""));
+    widgets.push(["vectors", count.count]);
+    const embedder = await api("/api/v1/embedder/stats");
+    widgets.push(["embedder", embedder.type]);
+    if (embedder.vocabulary_size !== undefined) widgets.push(["vocabulary", embedder.vocabulary_size]);
+    const generation = await api("/api/v1/vectors/generation").catch(() => null);
+    if (generation) widgets.push(["generation", generation.generation]);
+  } catch (err) {
+    widgets.push(["error", err.message]);
+  }
+
+  stats.replaceChildren(...widgets.map(([label, value]) => {
+    const span = element("span", "", `${label} `);
+    span.appendChild(element("strong", "", String(value)));
+    return span;
+  }));
+}
+
+// Filters
+
+const filterRows = document.getElementById("filter-rows");
+document.getElementById("add-filter").addEventListener("click", () => {
+  const row = document.getElementById("filter-row").content.firstElementChild.cloneNode(true);
+  row.querySelector(".remove").addEventListener("click", () => row.remove());
+  filterRows.appendChild(row);
+});
+
+// filterValue converts an input to the JSON the filter operator expects
+function filterValue(op, raw) {
+  if (op === "exists") return raw !== "false";
+  if (op === "in") return raw.split(",").map((v) => v.trim()).filter(Boolean);
+  if (raw !== "" && !isNaN(Number(raw)) && ["gt", "gte", "lt", "lte"].includes(op)) return Number(raw);
+  return raw;
+}
+
+function filters() {
+  const result = {};
+  for (const row of filterRows.children) {
+    const field = row.querySelector(".field").value.trim();
+    if (!field) continue;
+    const op = row.querySelector(".op").value;
+    result[field] = Object.assign(result[field] || {}, { [op]: filterValue(op, row.querySelector(".value").value) });
+  }
+  return result;
+}
+
+// Search
+
+document.getElementById("search").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const body = {
+    text: document.getElementById("query").value,
+    top_K: Number(document.getElementById("top-k").value) || 10,
+  };
+  const namespace = document.getElementById("namespace").value.trim();
+  if (namespace) body.namespace = namespace;
+  const activeFilters = filters();
+  if (Object.keys(activeFilters).length > 0) body.filters = activeFilters;
+
+  setStatus("Searching...");
+  try {
+    const started = performance.now();
+    const response = await api("/api/v1/search", { method: "POST", body });
+    const elapsed = Math.round(performance.now() - started);
+    const warnings = (response.meta && response.meta.warnings) || [];
+    setStatus(`${response.matches.length} results in ${elapsed} ms` + (warnings.length ? ` (${warnings.join("; ")})` : ""));
+    renderResults(response.matches);
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+  loadStats();
+});
+
+function renderResults(matches) {
+  const list = document.getElementById("results");
+  list.replaceChildren(...matches.map((match) => {
+    const vector = match.vector;
+    const metadata = Object.assign({}, vector.metadata);
+    const text = metadata.text || "";
+    delete metadata.text;
+
+    const item = element("li");
+    const heading = element("div");
+    heading.appendChild(element("span", "score", Number(match.score).toFixed(4)));
+    heading.appendChild(element("span", "", vector.id + " "));
+    heading.appendChild(element("span", "age", age(vector.created_at)));
+    item.appendChild(heading);
+    item.appendChild(element("div", "text", text));
+    item.appendChild(element("div", "metadata", Object.entries(metadata).map(([k, v]) => `${k}: ${v}`).join(" · ")));
+    item.addEventListener("click", () => showVector(vector.id));
+    return item;
+  }));
+}
+
+// Vector detail
+
+async function showVector(id) {
+  const detail = document.getElementById("detail");
+  document.getElementById("detail-id").textContent = id;
+  const body = document.getElementById("detail-body");
+  body.textContent = "Loading...";
+  detail.hidden = false;
+  try {
+    const vector = await api("/api/v1/vectors/" + encodeURIComponent(id));
+    if (Array.isArray(vector.embedding) && vector.embedding.length > 16) {
+      const dimension = vector.embedding.length;
+      vector.embedding = vector.embedding.slice(0, 16).concat([`... ${dimension} dimensions`]);
+    }
+    body.textContent = JSON.stringify(vector, null, 2);
+  } catch (err) {
+    body.textContent = err.message;
+  }
+}
+
+document.getElementById("close-detail").addEventListener("click", () => {
+  document.getElementById("detail").hidden = true;
+});
+
+loadStats();
@@ -0,0 +1,66 @@
+// Package ui serves a single-page web UI for exploring the vector store, built
+// from plain HTML and JavaScript calling the JSON API
+package ui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Prefix is the path the UI is served under
+const Prefix = "/ui/"
+
+//go:embed static
+var static embed.FS
+
+// asset is an embedded file with the ETag of its content
+type asset struct {
+	content []byte
+	etag    string
+}
+
+// Handler serves the UI under Prefix. Assets are revalidated with their ETag:
+// the page on every load, scripts and styles after five minutes
+func Handler() http.Handler {
+	assets := make(map[string]asset)
+	fs.WalkDir(static, "static", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := static.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		assets[strings.TrimPrefix(name, "static/")] = asset{content: content, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+		return nil
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, Prefix)
+		if name == "" {
+			name = "index.html"
+		}
+		a, ok := assets[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if name == "index.html" {
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Cache-Control", "public, max-age=300")
+		}
+		w.Header().Set("ETag", a.etag)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, path.Base(name), time.Time{}, bytes.NewReader(a.content))
+	})
+}
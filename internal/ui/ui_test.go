@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	h := Handler()
+	serve := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		path, contentType, cacheControl string
+	}{
+		{Prefix, "text/html", "no-cache"},
+		{Prefix + "index.html", "text/html", "no-cache"},
+		{Prefix + "app.js", "javascript", "public, max-age=300"},
+		{Prefix + "style.css", "text/css", "public, max-age=300"},
+	}
+	for _, tt := range tests {
+		rec := serve(tt.path, "")
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d", tt.path, rec.Code)
+			continue
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.Contains(ct, tt.contentType) {
+			t.Errorf("%s: content type = %q, want %s", tt.path, ct, tt.contentType)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != tt.cacheControl {
+			t.Errorf("%s: cache control = %q, want %q", tt.path, cc, tt.cacheControl)
+		}
+
+		etag := rec.Header().Get("ETag")
+		if etag == "" {
+			t.Errorf("%s: no ETag", tt.path)
+			continue
+		}
+		if rec := serve(tt.path, etag); rec.Code != http.StatusNotModified {
+			t.Errorf("%s: revalidation status = %d, want %d", tt.path, rec.Code, http.StatusNotModified)
+		}
+	}
+
+	if rec := serve(Prefix+"missing.js", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing asset: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,25 @@
+package version
+
+import "testing"
+
+func TestGet_LinkerFlags(t *testing.T) {
+	defer func(v, c, b string) { Version, Commit, BuildTime = v, c, b }(Version, Commit, BuildTime)
+	Version, Commit, BuildTime = "v1.2.0", "abc1234", "2026-01-02_03:04:05"
+
+	info := Get()
+	if info.Version != "v1.2.0" || info.Commit != "abc1234" || info.BuildTime != "2026-01-02_03:04:05" {
+		t.Errorf("Get() = %+v, want the linked values", info)
+	}
+	if info.GoVersion == "" {
+		t.Error("Get() has no Go version")
+	}
+}
+
+func TestGet_Fallback(t *testing.T) {
+	defer func(v string) { Version = v }(Version)
+	Version = ""
+
+	if info := Get(); info.Version == "" {
+		t.Errorf("Get() = %+v, want a fallback version", info)
+	}
+}
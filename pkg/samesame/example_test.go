@@ -0,0 +1,83 @@
+package samesame_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/tahcohcat/same-same/pkg/samesame"
+)
+
+func Example() {
+	ss, err := samesame.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, text := range []string{
+		"the cat sat on the mat",
+		"stock markets fell sharply today",
+		"a kitten sleeping on a rug",
+	} {
+		if _, err := ss.Add(text, nil); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	results, err := ss.Query("cat on a mat", 1)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(results[0].Text)
+	// Output: the cat sat on the mat
+}
+
+func ExampleSameSame_Query_filters() {
+	ss, err := samesame.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = ss.AddBatch([]samesame.Document{
+		{Text: "to be or not to be", Metadata: map[string]string{"author": "Shakespeare", "year": "1600"}},
+		{Text: "to be is to do", Metadata: map[string]string{"author": "Socrates", "year": "-400"}},
+		{Text: "be yourself, everyone else is taken", Metadata: map[string]string{"author": "Wilde", "year": "1890"}, ID: "wilde"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results, err := ss.Query("to be", 5,
+		samesame.WithFilters(samesame.Filters{"year": {"gte": 1000}}),
+		samesame.WithMinScore(0.1),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, result := range results {
+		fmt.Println(result.Metadata["author"])
+	}
+	// Output:
+	// Shakespeare
+	// Wilde
+}
+
+func ExampleWithNamespace() {
+	ss, err := samesame.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := ss.Add("deploy the service", map[string]string{"namespace": "ops"}); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := ss.Add("deploy the troops", map[string]string{"namespace": "history"}); err != nil {
+		log.Fatal(err)
+	}
+
+	results, err := ss.Query("deploy", 5, samesame.WithNamespace("ops"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(results), results[0].Text)
+	// Output: 1 deploy the service
+}
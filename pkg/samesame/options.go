@@ -0,0 +1,124 @@
+package samesame
+
+import (
+	"fmt"
+
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+// Supported metrics of WithMetric
+const (
+	MetricCosine    = search.MetricCosine
+	MetricEuclidean = search.MetricEuclidean
+	MetricDot       = search.MetricDot
+)
+
+// Filters are metadata filter expressions keyed by field, as accepted by the
+// search API, for example Filters{"year": {"gte": 2000}, "author": {"in": []string{"Woolf"}}}
+type Filters = models.Filters
+
+// config holds the settings of New
+type config struct {
+	embedder Embedder
+	storage  storage.Storage
+}
+
+// Option configures New
+type Option func(*config) error
+
+// WithEmbedder sets the embedder, the hash embedder by default
+// Embedders whose dimension changes as they learn, such as TF-IDF, fail the
+// dimension check once it differs from the stored vectors
+func WithEmbedder(embedder Embedder) Option {
+	return func(c *config) error {
+		if embedder == nil {
+			return fmt.Errorf("embedder cannot be nil")
+		}
+		c.embedder = embedder
+		return nil
+	}
+}
+
+// WithLocalStorage persists vectors in a collection of a local file storage directory
+// Vectors already in the collection are searched and their dimension enforced
+func WithLocalStorage(path, collection string) Option {
+	return func(c *config) error {
+		if collection == "" {
+			collection = "default"
+		}
+		adapter, err := local.NewVectorStorageAdapter(path, collection)
+		if err != nil {
+			return fmt.Errorf("failed to open local storage: %w", err)
+		}
+		c.storage = adapter
+		return nil
+	}
+}
+
+// query holds the settings of Query
+type query struct {
+	namespace   string
+	filters     Filters
+	minScore    *float64
+	metric      string
+	keyFallback bool
+}
+
+// QueryOption configures Query
+type QueryOption func(*query)
+
+// WithNamespace restricts a query to vectors whose "namespace" metadata is namespace
+func WithNamespace(namespace string) QueryOption {
+	return func(q *query) {
+		q.namespace = namespace
+	}
+}
+
+// WithFilters restricts a query to vectors whose metadata matches filters
+// Calling it again replaces the filters
+func WithFilters(filters Filters) QueryOption {
+	return func(q *query) {
+		q.filters = filters
+	}
+}
+
+// WithMinScore drops results scoring below score
+// It cannot be used with the euclidean metric, whose scores are distances
+func WithMinScore(score float64) QueryOption {
+	return func(q *query) {
+		q.minScore = &score
+	}
+}
+
+// WithMetric scores results with MetricCosine (the default), MetricDot or
+// MetricEuclidean, which ranks the nearest vectors first
+func WithMetric(metric string) QueryOption {
+	return func(q *query) {
+		q.metric = metric
+	}
+}
+
+// WithKeyFallback lets filters on a metadata key also match keys that differ
+// only in case or separators, such as "Author" for "author"
+func WithKeyFallback() QueryOption {
+	return func(q *query) {
+		q.keyFallback = true
+	}
+}
+
+// validate checks the query options
+func (q *query) validate() error {
+	if err := search.ValidateMetric(q.metric); err != nil {
+		return err
+	}
+	if q.minScore != nil && search.Ascending(q.metric) {
+		return fmt.Errorf("min score cannot be used with the %s metric", q.metric)
+	}
+	if _, err := models.NewFilterEvaluator().Compile(q.filters); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// Package samesame is an in-process API over an embedder and a vector storage,
+// for using same-same as a library rather than a server:
+//
+//	ss, err := samesame.New()
+//	id, err := ss.Add("the quick brown fox", nil)
+//	results, err := ss.Query("a fast fox", 5)
+//
+// Vectors get a generated ID, the text in their "text" metadata and the name of
+// the embedder that produced them, like vectors stored through the server.
+package samesame
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pborman/uuid"
+
+	"github.com/tahcohcat/same-same/internal/embedders"
+	"github.com/tahcohcat/same-same/internal/embedders/quotes/local/hash"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/storage"
+	"github.com/tahcohcat/same-same/internal/storage/local"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/internal/storage/search"
+)
+
+// TextKey is the metadata field holding the text a vector was embedded from
+const TextKey = "text"
+
+// Embedder turns text into embeddings
+// Every embedder of same-same implements it, as can any caller type
+type Embedder interface {
+	Embed(text string) ([]float64, error)
+	Name() string
+}
+
+// Document is a text to add, with optional ID and metadata
+type Document struct {
+	ID       string // Generated when empty
+	Text     string
+	Metadata map[string]string
+}
+
+// Result is a vector matching a query
+type Result struct {
+	ID       string
+	Text     string
+	Score    float64 // Similarity, or distance for the euclidean metric
+	Metadata map[string]string
+}
+
+// SameSame embeds texts and stores and searches their vectors
+// It is safe for concurrent use
+type SameSame struct {
+	embedder Embedder
+	storage  storage.Storage
+
+	mu        sync.Mutex
+	dimension int // Dimension of stored vectors, zero until known
+}
+
+// New returns a SameSame using the hash embedder and in-memory storage unless
+// configured otherwise by opts
+func New(opts ...Option) (*SameSame, error) {
+	cfg := &config{}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	embedder := cfg.embedder
+	if embedder == nil {
+		embedder = hash.NewHashEmbedder()
+	}
+
+	ss := &SameSame{embedder: embedder, storage: cfg.storage}
+	if ss.storage == nil {
+		ss.storage = memory.NewStorage()
+	}
+
+	dimension, err := storedDimension(ss.storage)
+	if err != nil {
+		return nil, err
+	}
+	ss.dimension = dimension
+
+	return ss, nil
+}
+
+// storedDimension returns the dimension of the vectors already in s, zero when empty
+func storedDimension(s storage.Storage) (int, error) {
+	if adapter, ok := s.(*local.VectorStorageAdapter); ok {
+		return adapter.VectorConfig().Dimension, nil
+	}
+
+	vectors, err := s.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect storage: %w", err)
+	}
+	for _, vector := range vectors {
+		if dimension := vector.Dimension(); dimension > 0 {
+			return dimension, nil
+		}
+	}
+	return 0, nil
+}
+
+// Add embeds text and stores it with metadata, returning the ID of the new vector
+func (ss *SameSame) Add(text string, metadata map[string]string) (string, error) {
+	ids, err := ss.AddBatch([]Document{{Text: text, Metadata: metadata}})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// AddBatch embeds and stores documents, returning their IDs in order
+// Nothing is stored if any document fails to embed or has the wrong dimension
+func (ss *SameSame) AddBatch(docs []Document) ([]string, error) {
+	vectors := make([]*models.Vector, len(docs))
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		if doc.Text == "" {
+			return nil, fmt.Errorf("document %d: text cannot be empty", i)
+		}
+
+		embedding, err := ss.embedder.Embed(doc.Text)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: failed to embed: %w", i, err)
+		}
+
+		id := doc.ID
+		if id == "" {
+			id = uuid.New()
+		}
+		vector := &models.Vector{ID: id, Embedding: embedding, Metadata: ss.stamp(doc)}
+		if err := vector.Validate(); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+
+		vectors[i] = vector
+		ids[i] = id
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	dimension := ss.dimension
+	for i, vector := range vectors {
+		if dimension == 0 {
+			dimension = len(vector.Embedding)
+		}
+		if len(vector.Embedding) != dimension {
+			return nil, fmt.Errorf("document %d: embedding has %d dimensions, the store holds %d", i, len(vector.Embedding), dimension)
+		}
+	}
+
+	if err := storage.StoreBatch(ss.storage, vectors); err != nil {
+		return nil, err
+	}
+	ss.dimension = dimension
+
+	return ids, nil
+}
+
+// stamp returns a copy of the document metadata with its text and the embedder name
+func (ss *SameSame) stamp(doc Document) map[string]string {
+	metadata := make(map[string]string, len(doc.Metadata)+2)
+	for key, value := range doc.Metadata {
+		metadata[key] = value
+	}
+	metadata[TextKey] = doc.Text
+	metadata[models.EmbedderNameKey] = ss.embedder.Name()
+	return metadata
+}
+
+// Query returns the topK vectors most similar to text, best first
+func (ss *SameSame) Query(text string, topK int, opts ...QueryOption) ([]Result, error) {
+	if text == "" {
+		return nil, fmt.Errorf("query text cannot be empty")
+	}
+	if topK <= 0 {
+		return nil, fmt.Errorf("topK must be positive, got %d", topK)
+	}
+
+	q := &query{metric: search.MetricCosine}
+	for _, opt := range opts {
+		opt(q)
+	}
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+
+	embedding, err := embedders.EmbedQuery(ss.embedder, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	ss.mu.Lock()
+	dimension := ss.dimension
+	ss.mu.Unlock()
+	if dimension > 0 && len(embedding) != dimension {
+		return nil, fmt.Errorf("query embedding has %d dimensions, the store holds %d", len(embedding), dimension)
+	}
+
+	vectors, err := ss.storage.ListByNamespace(q.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// The threshold is applied before the top K are taken, so it can only drop results
+	scored := search.FilterAndScoreVectorsByMetric(vectors, &models.SearchByEmbbedingRequest{
+		Embedding:    embedding,
+		TopK:         len(vectors),
+		Namespace:    q.namespace,
+		Filters:      q.filters,
+		SearchParams: models.SearchParams{KeyFallback: &q.keyFallback},
+	}, q.metric)
+
+	results := make([]Result, 0, topK)
+	for _, result := range scored {
+		if len(results) == topK {
+			break
+		}
+		if q.minScore != nil && result.Score < *q.minScore {
+			continue
+		}
+		// Storage may return its own maps, so callers get a copy
+		metadata := make(map[string]string, len(result.Vector.Metadata))
+		for key, value := range result.Vector.Metadata {
+			metadata[key] = value
+		}
+		results = append(results, Result{
+			ID:       result.Vector.ID,
+			Text:     metadata[TextKey],
+			Score:    result.Score,
+			Metadata: metadata,
+		})
+	}
+
+	return results, nil
+}
+
+// Delete removes the vector with the given ID
+func (ss *SameSame) Delete(id string) error {
+	return ss.storage.Delete(id)
+}
+
+// Count returns the number of stored vectors
+func (ss *SameSame) Count() int {
+	return ss.storage.Count()
+}
+
+// Close releases the storage
+func (ss *SameSame) Close() error {
+	if closer, ok := ss.storage.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package samesame
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/same-same/internal/models"
+)
+
+// lengthEmbedder embeds text into as many dimensions as it has words
+type lengthEmbedder struct{}
+
+func (lengthEmbedder) Embed(text string) ([]float64, error) {
+	words := strings.Fields(text)
+	embedding := make([]float64, len(words))
+	for i := range embedding {
+		embedding[i] = 1
+	}
+	return embedding, nil
+}
+
+func (lengthEmbedder) Name() string { return "length" }
+
+func TestAdd_StampsVectors(t *testing.T) {
+	ss, err := New()
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	metadata := map[string]string{"author": "Woolf"}
+	id, err := ss.Add("a room of one's own", metadata)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if id == "" {
+		t.Fatal("Add returned an empty ID")
+	}
+	if len(metadata) != 1 {
+		t.Errorf("Add modified the caller's metadata: %v", metadata)
+	}
+
+	stored, err := ss.storage.Get(id)
+	if err != nil {
+		t.Fatalf("vector was not stored: %v", err)
+	}
+	want := map[string]string{"author": "Woolf", TextKey: "a room of one's own", models.EmbedderNameKey: "local.hash"}
+	for key, value := range want {
+		if stored.Metadata[key] != value {
+			t.Errorf("metadata %s = %q, want %q", key, stored.Metadata[key], value)
+		}
+	}
+
+	if ss.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", ss.Count())
+	}
+	if err := ss.Delete(id); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if ss.Count() != 0 {
+		t.Errorf("Count() after Delete = %d, want 0", ss.Count())
+	}
+}
+
+func TestAddBatch_DimensionCheck(t *testing.T) {
+	ss, err := New(WithEmbedder(lengthEmbedder{}))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if _, err := ss.AddBatch([]Document{{Text: "two words"}, {Text: "three words now"}}); err == nil {
+		t.Fatal("expected a dimension error for a mixed batch")
+	}
+	if ss.Count() != 0 {
+		t.Fatalf("a failed batch stored %d vectors", ss.Count())
+	}
+
+	ids, err := ss.AddBatch([]Document{{Text: "two words", ID: "a"}, {Text: "more words"}})
+	if err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "a" || ids[1] == "" {
+		t.Errorf("ids = %v", ids)
+	}
+
+	if _, err := ss.Add("now three words", nil); err == nil {
+		t.Error("expected a dimension error against the stored vectors")
+	}
+	if _, err := ss.Query("three word query", 1); err == nil {
+		t.Error("expected a dimension error for the query")
+	}
+	if _, err := ss.Add("", nil); err == nil {
+		t.Error("expected an error for empty text")
+	}
+}
+
+func TestQuery_Options(t *testing.T) {
+	ss, err := New()
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for i, text := range []string{"red apples", "green apples", "blue ocean"} {
+		if _, err := ss.Add(text, map[string]string{"rank": fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	results, err := ss.Query("red apples", 3, WithMetric(MetricEuclidean))
+	if err != nil {
+		t.Fatalf("euclidean query failed: %v", err)
+	}
+	if len(results) != 3 || results[0].Text != "red apples" || results[0].Score > results[1].Score {
+		t.Errorf("euclidean results = %+v, want nearest first", results)
+	}
+
+	results, err = ss.Query("red apples", 3, WithFilters(Filters{"rank": {"gte": 1}}))
+	if err != nil {
+		t.Fatalf("filtered query failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Text != "green apples" {
+		t.Errorf("filtered results = %+v", results)
+	}
+
+	results, err = ss.Query("red apples", 3, WithMinScore(0.99))
+	if err != nil {
+		t.Fatalf("min score query failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("min score results = %+v, want only the exact match", results)
+	}
+
+	results[0].Metadata["rank"] = "changed"
+	again, _ := ss.Query("red apples", 1)
+	if again[0].Metadata["rank"] != "0" {
+		t.Error("result metadata is shared with the storage")
+	}
+
+	invalid := map[string][]QueryOption{
+		"unknown metric":       {WithMetric("manhattan")},
+		"min score, euclidean": {WithMetric(MetricEuclidean), WithMinScore(1)},
+		"invalid filter":       {WithFilters(Filters{"ra*": {models.MatchModifier: "most"}})},
+	}
+	for name, opts := range invalid {
+		if _, err := ss.Query("apples", 1, opts...); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := ss.Query("apples", 0); err == nil {
+		t.Error("expected an error for topK 0")
+	}
+}
+
+func TestWithLocalStorage_Reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	ss, err := New(WithLocalStorage(dir, "notes"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	id, err := ss.Add("persisted note", nil)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := ss.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := New(WithLocalStorage(dir, "notes"), WithEmbedder(lengthEmbedder{}))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if reopened.Count() != 1 {
+		t.Fatalf("Count() after reopen = %d, want 1", reopened.Count())
+	}
+	if _, err := reopened.Add("two words", nil); err == nil {
+		t.Error("expected a dimension error against the persisted vectors")
+	}
+
+	withHash, err := New(WithLocalStorage(dir, "notes"))
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	results, err := withHash.Query("persisted note", 1)
+	if err != nil || len(results) != 1 || results[0].ID != id {
+		t.Errorf("results = %+v, %v", results, err)
+	}
+}
@@ -0,0 +1,193 @@
+// Package samesametest runs the same-same HTTP API in process for integration
+// tests of services built against it, with no model, API key or data directory:
+//
+//	client, teardown := samesametest.NewServer(t, samesametest.WithVectors(
+//		samesametest.Vector{ID: "q1", Text: "the quick brown fox"},
+//	))
+//	defer teardown()
+//	status, err := client.Do(http.MethodPost, "/api/v1/search", body, &response)
+//
+// The server uses memory storage and a FixtureEmbedder unless WithEmbedder is given.
+package samesametest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/same-same/internal/embedders/fixture"
+	"github.com/tahcohcat/same-same/internal/models"
+	"github.com/tahcohcat/same-same/internal/server"
+	"github.com/tahcohcat/same-same/internal/storage/memory"
+	"github.com/tahcohcat/same-same/pkg/samesame"
+)
+
+// FixtureEmbedder embeds texts to stable vectors derived from a seeded hash,
+// or to the canned vectors set with SetVector. It is the "fixture" embedder type
+// of the server and the ingest command
+type FixtureEmbedder = fixture.FixtureEmbedder
+
+// NewFixtureEmbedder creates a fixture embedder, of fixture.DefaultDimension when dimension is zero
+func NewFixtureEmbedder(dimension int, seed uint64) *FixtureEmbedder {
+	return fixture.NewFixtureEmbedder(dimension, seed)
+}
+
+// Vector is a vector stored before the server starts
+type Vector struct {
+	ID        string
+	Text      string    // Embedded when Embedding is empty, stored in the "text" metadata
+	Embedding []float64 // Stored as is when set
+	Namespace string
+	Metadata  map[string]string
+}
+
+// config holds the settings of NewServer
+type config struct {
+	embedder samesame.Embedder
+	vectors  []Vector
+	adminKey *string
+}
+
+// Option configures NewServer
+type Option func(*config)
+
+// WithEmbedder sets the embedder of the server, a FixtureEmbedder by default
+func WithEmbedder(embedder samesame.Embedder) Option {
+	return func(c *config) {
+		c.embedder = embedder
+	}
+}
+
+// WithAdminKey sets the key of the admin endpoints, ADMIN_API_KEY by default
+func WithAdminKey(key string) Option {
+	return func(c *config) {
+		c.adminKey = &key
+	}
+}
+
+// WithVectors stores vectors before the server starts
+func WithVectors(vectors ...Vector) Option {
+	return func(c *config) {
+		c.vectors = append(c.vectors, vectors...)
+	}
+}
+
+// Client calls the API of a test server
+type Client struct {
+	URL      string // Base URL of the server, without trailing slash
+	HTTP     *http.Client
+	Embedder samesame.Embedder // Embedder of the server, to compute expected embeddings
+}
+
+// NewServer starts the full API router on an httptest server, failing t if a
+// fixture vector cannot be stored. The teardown stops the server; it also runs
+// when the test ends, so calling it is optional
+func NewServer(t testing.TB, opts ...Option) (*Client, func()) {
+	t.Helper()
+
+	c := config{embedder: NewFixtureEmbedder(0, 0)}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	store := memory.NewStorage()
+	for _, vector := range c.vectors {
+		stored, err := c.vector(vector)
+		if err != nil {
+			t.Fatalf("samesametest: %v", err)
+		}
+		if err := store.Store(stored); err != nil {
+			t.Fatalf("samesametest: failed to store fixture vector %s: %v", vector.ID, err)
+		}
+	}
+
+	serverOpts := []server.Option{server.WithStorage(store), server.WithEmbedder(c.embedder)}
+	if c.adminKey != nil {
+		serverOpts = append(serverOpts, server.WithAdminKey(*c.adminKey))
+	}
+	srv, err := server.NewServerWithOptions(serverOpts...)
+	if err != nil {
+		t.Fatalf("samesametest: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	var once sync.Once
+	teardown := func() { once.Do(ts.Close) }
+	t.Cleanup(teardown)
+
+	return &Client{URL: ts.URL, HTTP: ts.Client(), Embedder: c.embedder}, teardown
+}
+
+// vector converts a fixture vector to a stored one, embedding its text if needed
+func (c *config) vector(v Vector) (*models.Vector, error) {
+	if v.ID == "" {
+		return nil, fmt.Errorf("fixture vector has no ID")
+	}
+
+	embedding := v.Embedding
+	if len(embedding) == 0 {
+		if v.Text == "" {
+			return nil, fmt.Errorf("fixture vector %s has neither text nor embedding", v.ID)
+		}
+		var err error
+		if embedding, err = c.embedder.Embed(v.Text); err != nil {
+			return nil, fmt.Errorf("failed to embed fixture vector %s: %w", v.ID, err)
+		}
+	}
+
+	metadata := make(map[string]string, len(v.Metadata)+3)
+	for key, value := range v.Metadata {
+		metadata[key] = value
+	}
+	if v.Text != "" {
+		metadata[samesame.TextKey] = v.Text
+	}
+	if v.Namespace != "" {
+		metadata[models.NamespaceKey] = v.Namespace
+	}
+	metadata[models.EmbedderNameKey] = c.embedder.Name()
+
+	now := time.Now()
+	return &models.Vector{ID: v.ID, Embedding: embedding, Metadata: metadata, CreatedAt: now, UpdatedAt: now}, nil
+}
+
+// Do sends body encoded as JSON to path and decodes the response into out,
+// returning the status code. A nil body sends none and a nil out discards the response
+func (c *Client) Do(method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.URL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to decode %s %s response (status %d): %w", method, path, resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,54 @@
+package samesametest
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNewServer(t *testing.T) {
+	embedder := NewFixtureEmbedder(4, 0)
+	if err := embedder.SetVector("cat", []float64{1, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := embedder.SetVector("feline", []float64{0.9, 0.1, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, teardown := NewServer(t, WithEmbedder(embedder), WithVectors(
+		Vector{ID: "cat", Text: "cat", Metadata: map[string]string{"kind": "animal"}},
+		Vector{ID: "car", Embedding: []float64{0, 0, 1, 0}, Namespace: "vehicles"},
+	))
+	defer teardown()
+
+	var count map[string]int
+	if status, err := client.Do(http.MethodGet, "/api/v1/vectors/count", nil, &count); err != nil || status != http.StatusOK {
+		t.Fatalf("count: status %d, err %v", status, err)
+	}
+	if count["count"] != 2 {
+		t.Errorf("count = %v, want 2 fixture vectors", count)
+	}
+
+	var response struct {
+		Matches []struct {
+			Vector struct {
+				ID       string            `json:"id"`
+				Metadata map[string]string `json:"metadata"`
+			} `json:"vector"`
+		} `json:"matches"`
+	}
+	body := map[string]interface{}{"text": "feline", "top_K": 1}
+	if status, err := client.Do(http.MethodPost, "/api/v1/search", body, &response); err != nil || status != http.StatusOK {
+		t.Fatalf("search: status %d, err %v", status, err)
+	}
+	if len(response.Matches) != 1 || response.Matches[0].Vector.ID != "cat" {
+		t.Fatalf("matches = %+v, want cat", response.Matches)
+	}
+	if metadata := response.Matches[0].Vector.Metadata; metadata["text"] != "cat" || metadata["kind"] != "animal" || metadata["embedder.name"] != "fixture" {
+		t.Errorf("metadata = %v", metadata)
+	}
+
+	teardown()
+	if _, err := client.Do(http.MethodGet, "/health", nil, nil); err == nil {
+		t.Errorf("server still serving after teardown")
+	}
+}